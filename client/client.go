@@ -0,0 +1,422 @@
+// Package client 实现BACnet/IP客户端：用Who-Is发现设备并缓存I-Am，发出ReadProperty、
+// ReadPropertyMultiple、ReadRange、WriteProperty和ConfirmedPrivateTransfer请求，订阅COV并接收通知。报文编解码与服务端共用protocol和encoding包，
+// 因此同一个程序可以既作为设备提供数据又访问网络上的其他设备
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+	"github.com/iotzf/bacnet-server/protocol"
+)
+
+// ErrTimeout 重试后仍没有收到应答
+var ErrTimeout = errors.New("请求超时")
+
+// Options 创建客户端的配置，零值字段使用默认值
+type Options struct {
+	Address          string             // 本地UDP地址，为空时为":0"；设备广播的I-Am只会发往47808端口
+	ReusePort        bool               // 以SO_REUSEPORT与本机其他BACnet协议栈共享端口
+	BroadcastAddress string             // Who-Is的广播地址（ip:port），为空时为传输的广播地址的47808端口
+	Transport        protocol.Transport // 使用的传输，不为nil时忽略以上配置
+	Timeout          time.Duration      // 每次发送后等待应答的时间（APDU_Timeout），为0时为3秒
+	Retries          int                // 超时后的重试次数（Number_Of_APDU_Retries），为0时为3，为负数时不重试
+	Logger           *slog.Logger       // 为nil时使用slog.Default()
+}
+
+// Device 应答过I-Am的设备
+type Device struct {
+	ID           model.ObjectIdentifier
+	Address      net.Addr
+	MaxAPDU      uint32
+	Segmentation uint32
+	VendorID     uint32
+	Seen         time.Time // 最近一次收到I-Am的时间
+}
+
+// pendingKey 标识一个等待应答的确认请求
+type pendingKey struct {
+	addr     string
+	invokeID byte
+}
+
+// Client BACnet/IP客户端，可以被多个goroutine并发使用。每个请求分配独立的InvokeID，
+// 超时后以同一个InvokeID重发
+type Client struct {
+	transport protocol.Transport
+	broadcast net.Addr
+	timeout   time.Duration
+	retries   int
+	logger    *slog.Logger
+
+	mu            sync.Mutex
+	nextInvokeID  byte
+	nextProcessID uint32 // 最近分配的COV订阅者进程ID
+	pending       map[pendingKey]chan *protocol.APDU
+	devices       map[uint32]Device        // 按实例号缓存的I-Am
+	watchers      map[chan Device]struct{} // 等待I-Am的Who-Is
+	subscriptions map[subscriptionKey]*Subscription
+	done          chan struct{}
+}
+
+// New 按options创建客户端并开始接收应答
+func New(options Options) (*Client, error) {
+	transport := options.Transport
+	if transport == nil {
+		address := options.Address
+		if address == "" {
+			address = ":0"
+		}
+		var err error
+		if options.ReusePort {
+			transport, err = protocol.NewSharedUDPTransport(address)
+		} else {
+			transport, err = protocol.NewUDPTransport(address)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	c := &Client{
+		transport:     transport,
+		timeout:       options.Timeout,
+		retries:       options.Retries,
+		logger:        options.Logger,
+		pending:       make(map[pendingKey]chan *protocol.APDU),
+		devices:       make(map[uint32]Device),
+		watchers:      make(map[chan Device]struct{}),
+		subscriptions: make(map[subscriptionKey]*Subscription),
+		done:          make(chan struct{}),
+	}
+	if c.timeout <= 0 {
+		c.timeout = 3 * time.Second
+	}
+	switch {
+	case c.retries == 0:
+		c.retries = 3
+	case c.retries < 0:
+		c.retries = 0
+	}
+	if c.logger == nil {
+		c.logger = slog.Default()
+	}
+	if options.BroadcastAddress != "" {
+		addr, err := transport.ResolveAddr(options.BroadcastAddress)
+		if err != nil {
+			transport.Close()
+			return nil, fmt.Errorf("广播地址: %w", err)
+		}
+		c.broadcast = addr
+	} else if addr, ok := transport.BroadcastAddr().(*net.UDPAddr); ok {
+		c.broadcast = &net.UDPAddr{IP: addr.IP, Port: protocol.DefaultPort}
+	} else {
+		c.broadcast = transport.BroadcastAddr()
+	}
+	go c.receive()
+	return c, nil
+}
+
+// Close 关闭传输，等待中的请求返回错误
+func (c *Client) Close() error {
+	return c.transport.Close()
+}
+
+// LocalAddr 返回客户端的本地地址
+func (c *Client) LocalAddr() net.Addr {
+	return c.transport.LocalAddr()
+}
+
+// receive 接收数据报直到传输关闭：应答交给等待中的请求，I-Am更新设备缓存，COV通知交给订阅
+func (c *Client) receive() {
+	defer close(c.done)
+	buffer := make([]byte, c.transport.MTU())
+	for {
+		n, from, err := c.transport.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+		apdu, err := protocol.DecodeDatagram(buffer[:n])
+		if err != nil {
+			continue
+		}
+		switch apdu.PDUType {
+		case protocol.BACnetAPDUTypeUnconfirmedServiceRequest:
+			switch *apdu.ServiceChoice {
+			case protocol.BACnetServiceUnconfirmedIAm:
+				c.handleIAm(apdu, from)
+			case protocol.BACnetServiceUnconfirmedCOVNotification:
+				c.handleNotification(apdu, from)
+			}
+		case protocol.BACnetAPDUTypeConfirmedServiceRequest:
+			if *apdu.ServiceChoice == protocol.BACnetServiceConfirmedCOVNotification {
+				c.handleNotification(apdu, from)
+			} else {
+				// 客户端不提供其他服务
+				reject := []byte{protocol.BACnetAPDUTypeReject << 4, *apdu.InvokeID, protocol.RejectReasonUnrecognizedService}
+				c.transport.WriteTo(protocol.EncodeDatagram(reject, false), from)
+			}
+		default:
+			c.complete(from, apdu)
+		}
+	}
+}
+
+// handleIAm 缓存I-Am并通知等待中的Who-Is
+func (c *Client) handleIAm(apdu *protocol.APDU, from net.Addr) {
+	found, err := protocol.DecodeIAm(apdu.Payload)
+	if err != nil {
+		c.logger.Debug("无法解码I-Am", "from", from, "error", err)
+		return
+	}
+	device := Device{ID: found.ID, Address: from, MaxAPDU: found.MaxAPDU, Segmentation: found.Segmentation, VendorID: found.VendorID, Seen: time.Now()}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.devices[device.ID.Instance] = device
+	for watcher := range c.watchers {
+		select {
+		case watcher <- device:
+		default:
+		}
+	}
+}
+
+// complete 将应答交给InvokeID和来源地址匹配的请求，迟到的应答被丢弃
+func (c *Client) complete(from net.Addr, apdu *protocol.APDU) {
+	if apdu.InvokeID == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if response, ok := c.pending[pendingKey{addr: from.String(), invokeID: *apdu.InvokeID}]; ok {
+		select {
+		case response <- apdu:
+		default:
+		}
+	}
+}
+
+// begin 为发往addr的请求分配一个空闲的InvokeID
+func (c *Client) begin(addr net.Addr) (byte, chan *protocol.APDU, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := 0; i < 256; i++ {
+		invokeID := c.nextInvokeID
+		c.nextInvokeID++
+		key := pendingKey{addr: addr.String(), invokeID: invokeID}
+		if _, busy := c.pending[key]; !busy {
+			response := make(chan *protocol.APDU, 1)
+			c.pending[key] = response
+			return invokeID, response, nil
+		}
+	}
+	return 0, nil, fmt.Errorf("没有空闲的InvokeID: %s", addr)
+}
+
+// end 释放InvokeID
+func (c *Client) end(addr net.Addr, invokeID byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.pending, pendingKey{addr: addr.String(), invokeID: invokeID})
+}
+
+// request 发送确认请求并等待应答，超时后重发；Error、Reject和Abort应答作为错误返回
+func (c *Client) request(ctx context.Context, addr net.Addr, service byte, payload []byte) (*protocol.APDU, error) {
+	invokeID, response, err := c.begin(addr)
+	if err != nil {
+		return nil, err
+	}
+	defer c.end(addr, invokeID)
+	datagram := protocol.EncodeDatagram(protocol.EncodeConfirmedRequest(invokeID, service, payload), false)
+	for attempt := 0; ; attempt++ {
+		if _, err := c.transport.WriteTo(datagram, addr); err != nil {
+			return nil, err
+		}
+		timer := time.NewTimer(c.timeout)
+		select {
+		case apdu := <-response:
+			timer.Stop()
+			if err := apdu.Err(); err != nil {
+				return nil, err
+			}
+			return apdu, nil
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-c.done:
+			timer.Stop()
+			return nil, net.ErrClosed
+		case <-timer.C:
+		}
+		if attempt == c.retries {
+			return nil, fmt.Errorf("%w: %s服务%d，InvokeID=%d，重试%d次", ErrTimeout, addr, service, invokeID, c.retries)
+		}
+		c.logger.Debug("请求超时，重发", "peer", addr, "service", service, "invoke_id", invokeID, "attempt", attempt+1)
+	}
+}
+
+// watch 登记一个接收I-Am的通道，返回取消登记的函数
+func (c *Client) watch() (chan Device, func()) {
+	watcher := make(chan Device, 64)
+	c.mu.Lock()
+	c.watchers[watcher] = struct{}{}
+	c.mu.Unlock()
+	return watcher, func() {
+		c.mu.Lock()
+		delete(c.watchers, watcher)
+		c.mu.Unlock()
+	}
+}
+
+// WhoIs 向to（为nil时广播）发送Who-Is，在超时时间内收集应答的设备并按实例号排序。
+// high不为0时只询问实例号在low到high之间的设备
+func (c *Client) WhoIs(ctx context.Context, to net.Addr, low, high uint32) ([]Device, error) {
+	watcher, cancel := c.watch()
+	defer cancel()
+	broadcast := to == nil
+	if broadcast {
+		to = c.broadcast
+	}
+	if _, err := c.transport.WriteTo(protocol.EncodeDatagram(protocol.EncodeWhoIs(low, high), broadcast), to); err != nil {
+		return nil, err
+	}
+	found := make(map[uint32]Device)
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case device := <-watcher:
+			if high == 0 || device.ID.Instance >= low && device.ID.Instance <= high {
+				found[device.ID.Instance] = device
+			}
+		case <-ctx.Done():
+			return sortDevices(found), ctx.Err()
+		case <-timer.C:
+			return sortDevices(found), nil
+		}
+	}
+}
+
+// Device 返回设备的地址等信息：缓存中没有时广播只询问该设备的Who-Is，按重试次数重发
+func (c *Client) Device(ctx context.Context, instance uint32) (Device, error) {
+	c.mu.Lock()
+	device, ok := c.devices[instance]
+	c.mu.Unlock()
+	if ok {
+		return device, nil
+	}
+	watcher, cancel := c.watch()
+	defer cancel()
+	whoIs := protocol.EncodeDatagram(protocol.EncodeWhoIs(instance, instance), true)
+	for attempt := 0; attempt <= c.retries; attempt++ {
+		if _, err := c.transport.WriteTo(whoIs, c.broadcast); err != nil {
+			return Device{}, err
+		}
+		timer := time.NewTimer(c.timeout)
+	wait:
+		for {
+			select {
+			case device := <-watcher:
+				if device.ID.Instance == instance {
+					timer.Stop()
+					return device, nil
+				}
+			case <-ctx.Done():
+				timer.Stop()
+				return Device{}, ctx.Err()
+			case <-timer.C:
+				break wait
+			}
+		}
+	}
+	return Device{}, fmt.Errorf("%w: 设备%d没有应答I-Am", ErrTimeout, instance)
+}
+
+// Devices 返回缓存中的设备，按实例号排序
+func (c *Client) Devices() []Device {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return sortDevices(c.devices)
+}
+
+// Forget 从缓存中删除设备，设备更换地址后下次Device重新发现
+func (c *Client) Forget(instance uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.devices, instance)
+}
+
+// sortDevices 按实例号排序
+func sortDevices(devices map[uint32]Device) []Device {
+	list := make([]Device, 0, len(devices))
+	for _, device := range devices {
+		list = append(list, device)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID.Instance < list[j].ID.Instance })
+	return list
+}
+
+// ReadProperty 读取属性值，返回解码后的应用标签值，值为数组或列表时返回[]interface{}
+func (c *Client) ReadProperty(ctx context.Context, addr net.Addr, oid model.ObjectIdentifier, prop model.PropertyIdentifier) (interface{}, error) {
+	return c.readProperty(ctx, addr, oid, prop, nil)
+}
+
+// ReadPropertyIndex 读取数组属性的一个元素，index为0时读取数组长度
+func (c *Client) ReadPropertyIndex(ctx context.Context, addr net.Addr, oid model.ObjectIdentifier, prop model.PropertyIdentifier, index uint32) (interface{}, error) {
+	return c.readProperty(ctx, addr, oid, prop, &index)
+}
+
+// readProperty 发送ReadProperty请求并解码应答中的属性值
+func (c *Client) readProperty(ctx context.Context, addr net.Addr, oid model.ObjectIdentifier, prop model.PropertyIdentifier, index *uint32) (interface{}, error) {
+	ack, err := c.request(ctx, addr, protocol.BACnetServiceConfirmedReadProperty, protocol.EncodeReadPropertyRequest(oid, prop, index))
+	if err != nil {
+		return nil, err
+	}
+	return protocol.DecodeReadPropertyAck(ack.Payload)
+}
+
+// ReadPropertyMultiple 在一个请求中读取多个对象的属性，按应答的顺序返回每个属性的结果，
+// 单个属性的错误放在结果的Err中
+func (c *Client) ReadPropertyMultiple(ctx context.Context, addr net.Addr, specs []protocol.ReadAccessSpecification) ([]protocol.PropertyResult, error) {
+	ack, err := c.request(ctx, addr, protocol.BACnetServiceConfirmedReadPropertyMultiple, protocol.EncodeReadPropertyMultipleRequest(specs))
+	if err != nil {
+		return nil, err
+	}
+	return protocol.DecodeReadPropertyMultipleAck(ack.Payload)
+}
+
+// ReadRange 读取日志类对象的缓冲区中request指定范围的记录，多对象趋势日志的记录值为model.LogMultipleDatum
+func (c *Client) ReadRange(ctx context.Context, addr net.Addr, request protocol.ReadRangeRequest) (protocol.ReadRangeResult, error) {
+	ack, err := c.request(ctx, addr, protocol.BACnetServiceConfirmedReadRange, protocol.EncodeReadRangeRequest(request))
+	if err != nil {
+		return protocol.ReadRangeResult{}, err
+	}
+	return protocol.DecodeReadRangeAck(ack.Payload, request.ObjectID.Type == model.ObjectTypeTrendLogMultiple)
+}
+
+// WriteProperty 写入属性值，value按encoding.EncodeApplication编码（nil写入NULL以释放命令），
+// priority为0时不携带优先级
+func (c *Client) WriteProperty(ctx context.Context, addr net.Addr, oid model.ObjectIdentifier, prop model.PropertyIdentifier, value interface{}, priority uint8) error {
+	payload, err := protocol.EncodeWritePropertyRequest(oid, prop, value, priority)
+	if err != nil {
+		return err
+	}
+	_, err = c.request(ctx, addr, protocol.BACnetServiceConfirmedWriteProperty, payload)
+	return err
+}
+
+// PrivateTransfer 发出ConfirmedPrivateTransfer，parameters为编码后的服务参数（可为nil），返回应答中resultBlock的编码内容
+func (c *Client) PrivateTransfer(ctx context.Context, addr net.Addr, vendorID, serviceNumber uint32, parameters []byte) ([]byte, error) {
+	ack, err := c.request(ctx, addr, protocol.BACnetServiceConfirmedPrivateTransfer, protocol.EncodePrivateTransferRequest(vendorID, serviceNumber, parameters))
+	if err != nil {
+		return nil, err
+	}
+	_, _, result, err := protocol.DecodePrivateTransferAck(ack.Payload)
+	return result, err
+}
@@ -0,0 +1,313 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iotzf/bacnet-server/encoding"
+	"github.com/iotzf/bacnet-server/model"
+	"github.com/iotzf/bacnet-server/protocol"
+)
+
+// startServer 在内存网段上启动一个带两个对象的设备
+func startServer(t *testing.T, network *protocol.LoopbackNetwork, instance uint32) (*protocol.BACnetServer, *model.Analog) {
+	t.Helper()
+	device := model.NewDevice(instance, "Client Test Device", "")
+	sensor := model.NewAnalogValue(1, "Sensor", model.UnitsDegreesCelsius)
+	sensor.UpdatePresentValue(21.5)
+	device.AddObject(sensor)
+	device.AddObject(model.NewBinaryValue(1, "Fan").BACnetObject)
+	server, err := protocol.NewServer(device, protocol.Options{Transport: network.Attach()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Start(context.Background())
+	t.Cleanup(server.Stop)
+	return server, sensor
+}
+
+func TestClient(t *testing.T) {
+	network := protocol.NewLoopbackNetwork()
+	startServer(t, network, 1001)
+	server, sensor := startServer(t, network, 1002)
+	c, err := New(Options{Transport: network.Attach(), Timeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ctx := context.Background()
+
+	devices, err := c.WhoIs(ctx, nil, 0, 0)
+	if err != nil || len(devices) != 2 || devices[0].ID.Instance != 1001 || devices[1].ID.Instance != 1002 {
+		t.Fatalf("WhoIs() = %+v, %v", devices, err)
+	}
+	if cached := c.Devices(); len(cached) != 2 {
+		t.Errorf("Devices() = %+v", cached)
+	}
+	if devices, _ := c.WhoIs(ctx, nil, 1002, 1010); len(devices) != 1 || devices[0].ID.Instance != 1002 {
+		t.Errorf("WhoIs(1002-1010) = %+v", devices)
+	}
+	// 缓存中没有时发现设备
+	c.Forget(1002)
+	device, err := c.Device(ctx, 1002)
+	if err != nil || device.Address == nil || device.MaxAPDU == 0 {
+		t.Fatalf("Device(1002) = %+v, %v", device, err)
+	}
+	addr := device.Address
+
+	value, err := c.ReadProperty(ctx, addr, sensor.GetObjectIdentifier(), model.PropertyIdentifierPresentValue)
+	if err != nil || value != float32(21.5) {
+		t.Fatalf("ReadProperty() = %v (%T), %v", value, value, err)
+	}
+	if size, err := c.ReadPropertyIndex(ctx, addr, model.ObjectIdentifier{Type: model.ObjectTypeDevice, Instance: 1002}, model.PropertyIdentifierObjectList, 0); err != nil || size != uint32(3) {
+		t.Errorf("ReadPropertyIndex(Object_List, 0) = %v, %v", size, err)
+	}
+	if err := c.WriteProperty(ctx, addr, sensor.GetObjectIdentifier(), model.PropertyIdentifierPresentValue, float32(25), 8); err != nil {
+		t.Fatalf("WriteProperty() = %v", err)
+	}
+	missing := model.ObjectIdentifier{Type: model.ObjectTypeAnalogValue, Instance: 99}
+	var bacnetErr *protocol.Error
+	if _, err := c.ReadProperty(ctx, addr, missing, model.PropertyIdentifierPresentValue); !errors.As(err, &bacnetErr) || bacnetErr.Code != protocol.ErrorCodeUnknownObject {
+		t.Errorf("ReadProperty() of missing object = %v", err)
+	}
+
+	results, err := c.ReadPropertyMultiple(ctx, addr, []protocol.ReadAccessSpecification{
+		{ObjectID: sensor.GetObjectIdentifier(), Properties: []protocol.PropertyReference{
+			{PropertyID: model.PropertyIdentifierPresentValue},
+			{PropertyID: model.PropertyIdentifierObjectName},
+		}},
+		{ObjectID: missing, Properties: []protocol.PropertyReference{{PropertyID: model.PropertyIdentifierPresentValue}}},
+	})
+	if err != nil || len(results) != 3 {
+		t.Fatalf("ReadPropertyMultiple() = %+v, %v", results, err)
+	}
+	if results[0].Value != float32(25) || results[1].Value != "Sensor" || results[2].Err == nil {
+		t.Errorf("ReadPropertyMultiple() = %+v", results)
+	}
+
+	// 厂商私有服务
+	server.HandlePrivateTransfer(555, 7, func(request protocol.PrivateTransfer) ([]byte, error) {
+		return request.Parameters, nil
+	})
+	if result, err := c.PrivateTransfer(ctx, addr, 555, 7, encoding.EncodeUnsigned(42)); err != nil || !bytes.Equal(result, encoding.EncodeUnsigned(42)) {
+		t.Errorf("PrivateTransfer() = % X, %v", result, err)
+	}
+	if _, err := c.PrivateTransfer(ctx, addr, 555, 8, nil); !errors.As(err, &bacnetErr) || bacnetErr.Code != protocol.ErrorCodeOptionalFunctionalityNotSupported {
+		t.Errorf("PrivateTransfer() of unknown service = %v", err)
+	}
+
+	// 并发请求分配不同的InvokeID
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.ReadProperty(ctx, addr, sensor.GetObjectIdentifier(), model.PropertyIdentifierObjectName); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent ReadProperty() = %v", err)
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	network := protocol.NewLoopbackNetwork()
+	silent := network.Attach() // 收到请求但从不应答
+	defer silent.Close()
+	c, err := New(Options{Transport: network.Attach(), Timeout: 20 * time.Millisecond, Retries: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	oid := model.ObjectIdentifier{Type: model.ObjectTypeAnalogValue, Instance: 1}
+	if _, err := c.ReadProperty(context.Background(), silent.LocalAddr(), oid, model.PropertyIdentifierPresentValue); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("ReadProperty() = %v, want ErrTimeout", err)
+	}
+	// 第一次发送和两次重试使用同一个InvokeID
+	buffer := make([]byte, silent.MTU())
+	var invokeIDs []byte
+	for i := 0; i < 3; i++ {
+		n, _, err := silent.ReadFrom(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		apdu, err := protocol.DecodeDatagram(buffer[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		invokeIDs = append(invokeIDs, *apdu.InvokeID)
+	}
+	if invokeIDs[0] != invokeIDs[1] || invokeIDs[1] != invokeIDs[2] {
+		t.Errorf("invoke IDs = %v", invokeIDs)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.Device(ctx, 42); !errors.Is(err, context.Canceled) {
+		t.Errorf("Device() with cancelled context = %v", err)
+	}
+}
+
+func TestServiceChoice(t *testing.T) {
+	network := protocol.NewLoopbackNetwork()
+	silent := network.Attach()
+	defer silent.Close()
+	c, err := New(Options{Transport: network.Attach(), Timeout: 10 * time.Millisecond, Retries: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ctx := context.Background()
+	addr := silent.LocalAddr()
+	oid := model.ObjectIdentifier{Type: model.ObjectTypeAnalogValue, Instance: 1}
+
+	// 服务选择按ASHRAE 135第21节的编号，不使用常量以免与服务端共用同一个错误
+	tests := []struct {
+		name      string
+		call      func()
+		confirmed bool
+		service   byte
+	}{
+		{"Who-Is", func() { c.WhoIs(ctx, addr, 0, 0) }, false, 8},
+		{"ReadProperty", func() { c.ReadProperty(ctx, addr, oid, model.PropertyIdentifierPresentValue) }, true, 12},
+		{"ReadPropertyMultiple", func() {
+			c.ReadPropertyMultiple(ctx, addr, []protocol.ReadAccessSpecification{
+				{ObjectID: oid, Properties: []protocol.PropertyReference{{PropertyID: model.PropertyIdentifierPresentValue}}},
+			})
+		}, true, 14},
+		{"WriteProperty", func() { c.WriteProperty(ctx, addr, oid, model.PropertyIdentifierPresentValue, float32(1), 0) }, true, 15},
+		{"ReadRange", func() {
+			c.ReadRange(ctx, addr, protocol.ReadRangeRequest{ObjectID: oid, PropertyID: model.PropertyIdentifierLogBuffer})
+		}, true, 26},
+		{"ConfirmedPrivateTransfer", func() { c.PrivateTransfer(ctx, addr, 0, 1, nil) }, true, 18},
+		{"SubscribeCOV", func() { c.SubscribeCOV(ctx, addr, oid, time.Minute, false) }, true, 5},
+		{"SubscribeCOVProperty", func() {
+			c.SubscribeCOVProperty(ctx, addr, oid, model.PropertyIdentifierPresentValue, time.Minute, false)
+		}, true, 28},
+	}
+	buffer := make([]byte, silent.MTU())
+	for _, tt := range tests {
+		tt.call()
+		n, _, err := silent.ReadFrom(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// BVLC占4字节，NPDU占2字节，确认请求的服务选择在PDU类型、最大分段/APDU和InvokeID之后
+		apdu := buffer[6:n]
+		if tt.confirmed {
+			if apdu[0]>>4 != 0 || apdu[3] != tt.service {
+				t.Errorf("%s APDU = % x, want a confirmed request for service %d", tt.name, apdu, tt.service)
+			}
+		} else if apdu[0]>>4 != 1 || apdu[1] != tt.service {
+			t.Errorf("%s APDU = % x, want an unconfirmed request for service %d", tt.name, apdu, tt.service)
+		}
+	}
+}
+
+func TestSubscribeCOV(t *testing.T) {
+	network := protocol.NewLoopbackNetwork()
+	server, sensor := startServer(t, network, 1001)
+	c, err := New(Options{Transport: network.Attach(), Timeout: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ctx := context.Background()
+	device, err := c.Device(ctx, 1001)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// next 在超时时间内取出订阅的下一个通知
+	next := func(s *Subscription) Notification {
+		t.Helper()
+		select {
+		case n := <-s.Notifications():
+			return n
+		case <-time.After(time.Second):
+			t.Fatal("等待COV通知超时")
+			return Notification{}
+		}
+	}
+
+	unconfirmed, err := c.SubscribeCOV(ctx, device.Address, sensor.GetObjectIdentifier(), time.Second, false)
+	if err != nil {
+		t.Fatalf("SubscribeCOV() = %v", err)
+	}
+	server.SimulateDataChange(sensor.GetObjectIdentifier(), model.PropertyIdentifierPresentValue, 30.0)
+	if n := next(unconfirmed); n.ObjectID != sensor.GetObjectIdentifier() || n.Device != 1001 || n.Values[model.PropertyIdentifierPresentValue] != float32(30) ||
+		n.Confirmed || n.TimeRemaining > 1 {
+		t.Errorf("notification = %+v", n)
+	}
+
+	// 确认通知由客户端应答，服务端不会重发
+	confirmed, err := c.SubscribeCOVProperty(ctx, device.Address, sensor.GetObjectIdentifier(), model.PropertyIdentifierPresentValue, 0, true)
+	if err != nil {
+		t.Fatalf("SubscribeCOVProperty() = %v", err)
+	}
+	if confirmed.ProcessID() == unconfirmed.ProcessID() {
+		t.Errorf("both subscriptions use process %d", confirmed.ProcessID())
+	}
+	server.SimulateDataChange(sensor.GetObjectIdentifier(), model.PropertyIdentifierPresentValue, 31.0)
+	if n := next(confirmed); !n.Confirmed || n.Values[model.PropertyIdentifierPresentValue] != float32(31) || n.TimeRemaining != 0 {
+		t.Errorf("confirmed notification = %+v", n)
+	}
+	if n := next(unconfirmed); n.Values[model.PropertyIdentifierPresentValue] != float32(31) {
+		t.Errorf("notification = %+v", n)
+	}
+
+	// 有效期经过3/4后以相同的进程ID续订，服务端更新原有的订阅
+	expires := func() time.Time {
+		server.Lock()
+		defer server.Unlock()
+		for _, sub := range sensor.COVSubscriptions() {
+			if sub.SubscriberProcessID == unconfirmed.ProcessID() {
+				return sub.Expires
+			}
+		}
+		return time.Time{}
+	}
+	first := expires()
+	deadline := time.Now().Add(2 * time.Second)
+	for !expires().After(first) && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !expires().After(first) || unconfirmed.Err() != nil {
+		t.Fatalf("subscription not renewed: expires %v, err %v", expires(), unconfirmed.Err())
+	}
+	if subs := sensor.COVSubscriptions(); len(subs) != 2 {
+		t.Errorf("%d subscriptions on the server, want 2", len(subs))
+	}
+	server.SimulateDataChange(sensor.GetObjectIdentifier(), model.PropertyIdentifierPresentValue, 32.0)
+	if n := next(unconfirmed); n.Values[model.PropertyIdentifierPresentValue] != float32(32) {
+		t.Errorf("notification after renewal = %+v", n)
+	}
+	select {
+	case n := <-unconfirmed.Notifications():
+		t.Errorf("duplicate notification %+v", n)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := unconfirmed.Cancel(); err != nil {
+		t.Errorf("Cancel() = %v", err)
+	}
+	if _, open := <-unconfirmed.Notifications(); open {
+		t.Error("notifications not closed after Cancel")
+	}
+	if subs := sensor.COVSubscriptions(); len(subs) != 1 {
+		t.Errorf("%d subscriptions after Cancel, want 1", len(subs))
+	}
+	if _, err := c.SubscribeCOV(ctx, device.Address, model.ObjectIdentifier{Type: model.ObjectTypeAnalogValue, Instance: 99}, time.Minute, false); err == nil {
+		t.Error("SubscribeCOV() of missing object: want error")
+	}
+}
@@ -0,0 +1,234 @@
+package client
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/iotzf/bacnet-server/encoding"
+	"github.com/iotzf/bacnet-server/model"
+	"github.com/iotzf/bacnet-server/protocol"
+)
+
+// renewFraction 订阅经过有效期的这一比例后续订
+const renewFraction = 0.75
+
+// Notification 收到的COV通知
+type Notification struct {
+	ObjectID      model.ObjectIdentifier                   // 订阅的对象
+	Device        uint32                                   // 发出通知的设备的实例号
+	TimeRemaining uint32                                   // 订阅的剩余时间（秒），永久有效的订阅为0
+	Values        map[model.PropertyIdentifier]interface{} // 通知中的属性值
+	Confirmed     bool                                     // 是否为确认通知，客户端已应答SimpleAck
+	Received      time.Time
+}
+
+// Subscription 客户端维护的COV订阅，以客户端分配的订阅者进程ID标识：有效期经过3/4时以相同的进程ID重新订阅，
+// 服务端据此更新原有的订阅
+type Subscription struct {
+	client    *Client
+	addr      net.Addr
+	processID uint32
+	objectID  model.ObjectIdentifier
+	property  *model.PropertyIdentifier // SubscribeCOVProperty监控的属性，为空时为SubscribeCOV
+	lifetime  uint32                    // 秒，为0时永久有效，不续订
+	confirmed bool
+
+	notifications chan Notification
+	stop          chan struct{}
+	stopped       sync.Once
+	done          chan struct{}
+
+	mu  sync.Mutex
+	err error // 最近一次续订的错误
+}
+
+// subscriptionKey 按设备地址和订阅者进程ID查找订阅
+type subscriptionKey struct {
+	addr      string
+	processID uint32
+}
+
+// SubscribeCOV 订阅对象的COV通知，lifetime为0时订阅永久有效，否则按秒取整并自动续订
+func (c *Client) SubscribeCOV(ctx context.Context, addr net.Addr, oid model.ObjectIdentifier, lifetime time.Duration, confirmed bool) (*Subscription, error) {
+	return c.subscribe(ctx, addr, oid, nil, lifetime, confirmed)
+}
+
+// SubscribeCOVProperty 订阅对象的一个属性的COV通知，其他同SubscribeCOV
+func (c *Client) SubscribeCOVProperty(ctx context.Context, addr net.Addr, oid model.ObjectIdentifier, property model.PropertyIdentifier, lifetime time.Duration, confirmed bool) (*Subscription, error) {
+	return c.subscribe(ctx, addr, oid, &property, lifetime, confirmed)
+}
+
+// subscribe 分配订阅者进程ID，发出第一次订阅并开始续订
+func (c *Client) subscribe(ctx context.Context, addr net.Addr, oid model.ObjectIdentifier, property *model.PropertyIdentifier, lifetime time.Duration, confirmed bool) (*Subscription, error) {
+	s := &Subscription{
+		client:        c,
+		addr:          addr,
+		objectID:      oid,
+		property:      property,
+		lifetime:      uint32((lifetime + time.Second - 1) / time.Second),
+		confirmed:     confirmed,
+		notifications: make(chan Notification, 64),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	// 订阅在第一次请求前登记，服务端在应答之前发出的通知也能被路由
+	c.mu.Lock()
+	c.nextProcessID++
+	s.processID = c.nextProcessID
+	key := subscriptionKey{addr: addr.String(), processID: s.processID}
+	c.subscriptions[key] = s
+	c.mu.Unlock()
+	if err := s.request(ctx, false); err != nil {
+		c.mu.Lock()
+		delete(c.subscriptions, key)
+		c.mu.Unlock()
+		return nil, err
+	}
+	go s.renew()
+	return s, nil
+}
+
+// request 发出SubscribeCOV或SubscribeCOVProperty请求，cancel为true时取消订阅
+func (s *Subscription) request(ctx context.Context, cancel bool) error {
+	request := protocol.SubscribeCOVRequest{SubscriberProcessID: s.processID, ObjectID: s.objectID}
+	if !cancel {
+		request.IssueConfirmedNotif, request.Lifetime = &s.confirmed, &s.lifetime
+	}
+	service := byte(protocol.BACnetServiceConfirmedSubscribeCOV)
+	payload, err := protocol.EncodeSubscribeCOVRequest(request)
+	if s.property != nil {
+		service = protocol.BACnetServiceConfirmedSubscribeCOVProperty
+		payload, err = protocol.EncodeSubscribeCOVPropertyRequest(protocol.SubscribeCOVPropertyRequest{
+			SubscribeCOVRequest: request,
+			Property:            protocol.PropertyReference{PropertyID: *s.property},
+		})
+	}
+	if err != nil {
+		return err
+	}
+	_, err = s.client.request(ctx, s.addr, service, payload)
+	return err
+}
+
+// renew 在有效期到达前续订，失败时每个超时时间重试一次，直到Cancel或客户端关闭
+func (s *Subscription) renew() {
+	defer close(s.done)
+	if s.lifetime == 0 {
+		select {
+		case <-s.stop:
+		case <-s.client.done:
+		}
+		return
+	}
+	c := s.client
+	interval := time.Duration(float64(s.lifetime) * renewFraction * float64(time.Second))
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-c.done:
+			return
+		case <-timer.C:
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			select {
+			case <-s.stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		err := s.request(ctx, false)
+		cancel()
+		s.mu.Lock()
+		s.err = err
+		s.mu.Unlock()
+		if err != nil {
+			c.logger.Warn("续订COV失败", "peer", s.addr, "object", s.objectID, "error", err)
+			timer.Reset(c.timeout)
+			continue
+		}
+		timer.Reset(interval)
+	}
+}
+
+// ProcessID 返回订阅者进程ID，服务端的通知以此标识订阅
+func (s *Subscription) ProcessID() uint32 {
+	return s.processID
+}
+
+// ObjectID 返回订阅的对象
+func (s *Subscription) ObjectID() model.ObjectIdentifier {
+	return s.objectID
+}
+
+// Err 返回最近一次续订的错误，续订成功后为nil
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Notifications 返回收到的通知，Cancel后关闭；没有及时取走时新的通知被丢弃
+func (s *Subscription) Notifications() <-chan Notification {
+	return s.notifications
+}
+
+// Cancel 停止续订并取消服务端的订阅，服务端的错误被返回但订阅总是停止
+func (s *Subscription) Cancel() error {
+	var err error
+	s.stopped.Do(func() {
+		close(s.stop)
+		<-s.done
+		c := s.client
+		// 关闭通道前确保receive不再向其发送
+		c.mu.Lock()
+		delete(c.subscriptions, subscriptionKey{addr: s.addr.String(), processID: s.processID})
+		close(s.notifications)
+		c.mu.Unlock()
+		ctx, cancel := context.WithTimeout(context.Background(), c.timeout*time.Duration(c.retries+1))
+		defer cancel()
+		err = s.request(ctx, true)
+	})
+	return err
+}
+
+// handleNotification 解码COV通知并交给对应的订阅，确认通知先应答SimpleAck
+func (c *Client) handleNotification(apdu *protocol.APDU, from net.Addr) {
+	confirmed := apdu.PDUType == protocol.BACnetAPDUTypeConfirmedServiceRequest
+	if confirmed {
+		c.transport.WriteTo(protocol.EncodeDatagram(protocol.EncodeSimpleAck(*apdu.InvokeID, *apdu.ServiceChoice), false), from)
+	}
+	cov, err := protocol.ParseCOVNotification(apdu)
+	if err != nil {
+		c.logger.Debug("无法解码COV通知", "from", from, "error", err)
+		return
+	}
+	values := make(map[model.PropertyIdentifier]interface{}, len(cov.Values))
+	for _, v := range cov.Values {
+		value, _, err := encoding.DecodeApplication(v.Value)
+		if err != nil {
+			c.logger.Debug("无法解码COV通知的属性值", "from", from, "property", v.PropertyID, "error", err)
+			return
+		}
+		values[v.PropertyID] = value
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.subscriptions[subscriptionKey{addr: from.String(), processID: cov.SubscriberProcessID}]
+	if !ok || cov.MonitoredObject != s.objectID {
+		c.logger.Debug("未知订阅的COV通知", "from", from, "process", cov.SubscriberProcessID, "object", cov.MonitoredObject)
+		return
+	}
+	n := Notification{ObjectID: s.objectID, Device: cov.InitiatingDevice.Instance, TimeRemaining: cov.TimeRemaining,
+		Values: values, Confirmed: confirmed, Received: time.Now()}
+	select {
+	case s.notifications <- n:
+	default:
+		c.logger.Warn("COV通知队列已满，丢弃通知", "object", s.objectID, "process", cov.SubscriberProcessID)
+	}
+}
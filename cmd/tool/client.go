@@ -0,0 +1,392 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/iotzf/bacnet-server/encoding"
+	"github.com/iotzf/bacnet-server/model"
+	"github.com/iotzf/bacnet-server/protocol"
+)
+
+// clientOptions 客户端子命令共用的参数
+type clientOptions struct {
+	bind      *string
+	reusePort *bool
+	timeout   *time.Duration
+}
+
+// newClientFlags 创建客户端子命令的参数集
+func newClientFlags(name string) (*flag.FlagSet, clientOptions) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	return flags, clientOptions{
+		bind:      flags.String("bind", ":0", "Local address to send from; devices that broadcast I-Am reach only :47808"),
+		reusePort: flags.Bool("reuse-port", false, "Share the local port with a BACnet stack on this host using SO_REUSEPORT"),
+		timeout:   flags.Duration("timeout", 3*time.Second, "Time to wait for replies"),
+	}
+}
+
+// openTransport 打开客户端子命令使用的UDP传输，测试中替换为回环网络上的传输
+var openTransport = func(bind string, reusePort bool) (protocol.Transport, error) {
+	if reusePort {
+		return protocol.NewSharedUDPTransport(bind)
+	}
+	return protocol.NewUDPTransport(bind)
+}
+
+// client 按参数打开传输并创建客户端
+func (o clientOptions) client() (*protocol.TestClient, error) {
+	transport, err := openTransport(*o.bind, *o.reusePort)
+	if err != nil {
+		return nil, err
+	}
+	return protocol.NewTestClient(transport, *o.timeout), nil
+}
+
+// discover 广播Who-Is（或发往指定地址）并列出应答的设备
+func discover(args []string) error {
+	flags, options := newClientFlags("discover")
+	target := flags.String("target", "", "Send Who-Is to this address instead of broadcasting, ip[:port]")
+	low := flags.Uint("low", 0, "Lowest device instance to ask for")
+	high := flags.Uint("high", 0, "Highest device instance to ask for (0 for all devices)")
+	if args = parseArgs(flags, args); len(args) != 0 {
+		return errors.New("usage: discover [flags]")
+	}
+	if *low > *high && *high != 0 {
+		return errors.New("-low must not exceed -high")
+	}
+
+	var to net.Addr
+	if *target != "" {
+		addr, err := resolveDevice(*target)
+		if err != nil {
+			return err
+		}
+		to = addr
+	}
+	client, err := options.client()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	devices, err := client.Discover(to, uint32(*low), uint32(*high))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%-10s %-22s %-8s %-12s %s\n", "DEVICE", "ADDRESS", "MAX-APDU", "SEGMENTATION", "VENDOR")
+	for _, device := range devices {
+		fmt.Printf("%-10d %-22s %-8d %-12s %d\n", device.ID.Instance, device.Address, device.MaxAPDU,
+			segmentationName(device.Segmentation), device.VendorID)
+	}
+	fmt.Fprintf(os.Stderr, "%d device(s) found\n", len(devices))
+	return nil
+}
+
+// read 读取属性并输出值
+func read(args []string) error {
+	flags, options := newClientFlags("read")
+	index := flags.Int("index", -1, "Array index to read (0 for the array size, -1 for the whole property)")
+	args = parseArgs(flags, args)
+	if len(args) != 3 {
+		return errors.New("usage: read [flags] <address> <object> <property>")
+	}
+	addr, oid, prop, err := parseTarget(args)
+	if err != nil {
+		return err
+	}
+	client, err := options.client()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	var value interface{}
+	if *index >= 0 {
+		value, err = client.ReadPropertyIndex(addr, oid, prop, uint32(*index))
+	} else {
+		value, err = client.ReadProperty(addr, oid, prop)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println(formatValue(oid.Type, prop, value))
+	return nil
+}
+
+// write 写入属性
+func write(args []string) error {
+	flags, options := newClientFlags("write")
+	priority := flags.Uint("priority", 0, "Command priority 1-16 (0 to omit, the device then uses 16)")
+	datatype := flags.String("type", "", "Datatype of the value: null, boolean, unsigned, signed, real, double, string or enumerated (default from the property)")
+	args = parseArgs(flags, args)
+	if len(args) != 4 {
+		return errors.New("usage: write [flags] <address> <object> <property> <value>")
+	}
+	if *priority > 16 {
+		return errors.New("-priority must be 1-16")
+	}
+	addr, oid, prop, err := parseTarget(args)
+	if err != nil {
+		return err
+	}
+	value, err := parseValue(oid.Type, prop, *datatype, args[3])
+	if err != nil {
+		return err
+	}
+	client, err := options.client()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if err := client.WriteProperty(addr, oid, prop, value, uint8(*priority)); err != nil {
+		return err
+	}
+	fmt.Println("ok")
+	return nil
+}
+
+// covProcessID cov子命令的订阅者进程ID
+const covProcessID = 1
+
+// cov 订阅对象的COV通知并逐条输出，在生命周期过半时续订，直到收到终止信号
+func cov(args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	return watchCOV(ctx, args)
+}
+
+// watchCOV 同cov，直到ctx取消
+func watchCOV(ctx context.Context, args []string) error {
+	flags, options := newClientFlags("cov")
+	lifetime := flags.Duration("lifetime", 5*time.Minute, "Subscription lifetime, renewed at half of it")
+	confirmed := flags.Bool("confirmed", false, "Ask for confirmed COV notifications")
+	args = parseArgs(flags, args)
+	if len(args) != 2 {
+		return errors.New("usage: cov [flags] <address> <object>")
+	}
+	if *lifetime < 2*time.Second {
+		return errors.New("-lifetime must be at least 2s")
+	}
+	addr, err := resolveDevice(args[0])
+	if err != nil {
+		return err
+	}
+	oid, err := model.ParseObjectIdentifier(args[1])
+	if err != nil {
+		return err
+	}
+	client, err := options.client()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	subscribe := func() error {
+		// 以相同的进程ID续订时服务端更新原有的订阅
+		err := client.SubscribeCOV(addr, covProcessID, oid, uint32(*lifetime/time.Second), *confirmed)
+		if err == nil {
+			fmt.Fprintf(os.Stderr, "subscribed to %s, process %d\n", formatObject(oid), covProcessID)
+		}
+		return err
+	}
+	if err := subscribe(); err != nil {
+		return err
+	}
+
+	renew := time.NewTicker(*lifetime / 2)
+	defer renew.Stop()
+	for ctx.Err() == nil {
+		select {
+		case <-renew.C:
+			if err := subscribe(); err != nil {
+				fmt.Fprintf(os.Stderr, "renewing subscription failed: %v\n", err)
+			}
+		default:
+		}
+		apdu, err := client.Notification()
+		if err != nil {
+			continue // 超时后检查续订和终止信号
+		}
+		notification, err := protocol.ParseCOVNotification(apdu)
+		if err != nil || notification.SubscriberProcessID != covProcessID || notification.MonitoredObject != oid {
+			continue
+		}
+		received := time.Now().Format("15:04:05.000")
+		for _, v := range notification.Values {
+			value, _, err := encoding.DecodeApplication(v.Value)
+			if err != nil {
+				continue
+			}
+			fmt.Printf("%s %s %s = %s\n", received, formatObject(oid), v.PropertyID, formatValue(oid.Type, v.PropertyID, value))
+		}
+	}
+	return nil
+}
+
+// parseArgs 解析参数并返回位置参数，参数可以出现在位置参数之后；负数作为位置参数（如写入的值）
+func parseArgs(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for len(args) > 0 {
+		end := len(args)
+		for i, arg := range args {
+			if _, err := strconv.ParseFloat(arg, 64); err == nil && strings.HasPrefix(arg, "-") {
+				end = i
+				break
+			}
+		}
+		flags.Parse(args[:end])
+		rest := append(append([]string{}, flags.Args()...), args[end:]...)
+		if len(rest) == 0 {
+			break
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+	return positional
+}
+
+// resolveDevice 解析设备地址，未给出端口时使用47808
+func resolveDevice(text string) (*net.UDPAddr, error) {
+	if _, _, err := net.SplitHostPort(text); err != nil {
+		text = net.JoinHostPort(text, strconv.Itoa(protocol.DefaultPort))
+	}
+	return net.ResolveUDPAddr("udp", text)
+}
+
+// parseTarget 解析地址、对象标识符和属性三个参数
+func parseTarget(args []string) (*net.UDPAddr, model.ObjectIdentifier, model.PropertyIdentifier, error) {
+	addr, err := resolveDevice(args[0])
+	if err != nil {
+		return nil, model.ObjectIdentifier{}, 0, err
+	}
+	oid, err := model.ParseObjectIdentifier(args[1])
+	if err != nil {
+		return nil, oid, 0, err
+	}
+	prop, err := model.ParsePropertyIdentifier(args[2])
+	return addr, oid, prop, err
+}
+
+// parseValue 按datatype解析要写入的值，datatype为空时按属性元数据推断，没有元数据时按文本推断
+func parseValue(objType model.ObjectType, prop model.PropertyIdentifier, datatype, text string) (interface{}, error) {
+	if datatype == "" {
+		if strings.EqualFold(text, "null") {
+			return nil, nil
+		}
+		datatype = "string"
+		if meta, ok := model.LookupPropertyMetadata(objType, prop); ok {
+			switch meta.Datatype {
+			case model.DatatypeBoolean:
+				datatype = "boolean"
+			case model.DatatypeUnsigned:
+				datatype = "unsigned"
+			case model.DatatypeSigned:
+				datatype = "signed"
+			case model.DatatypeReal:
+				datatype = "real"
+			case model.DatatypeDouble:
+				datatype = "double"
+			case model.DatatypeEnumerated:
+				datatype = "enumerated"
+			}
+		} else if _, err := strconv.ParseUint(text, 10, 32); err == nil {
+			datatype = "unsigned"
+		} else if _, err := strconv.ParseFloat(text, 32); err == nil {
+			datatype = "real"
+		}
+	}
+
+	switch datatype {
+	case "null":
+		return nil, nil
+	case "boolean":
+		return strconv.ParseBool(text)
+	case "unsigned":
+		v, err := strconv.ParseUint(text, 10, 32)
+		return uint32(v), err
+	case "signed":
+		v, err := strconv.ParseInt(text, 10, 32)
+		return int32(v), err
+	case "real":
+		v, err := strconv.ParseFloat(text, 32)
+		return float32(v), err
+	case "double":
+		return strconv.ParseFloat(text, 64)
+	case "string":
+		return text, nil
+	case "enumerated":
+		// 二值对象的状态可以写为active/inactive
+		switch strings.ToLower(text) {
+		case "active", "on", "true":
+			return encoding.Enumerated(1), nil
+		case "inactive", "off", "false":
+			return encoding.Enumerated(0), nil
+		}
+		v, err := strconv.ParseUint(text, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid enumerated value %q", text)
+		}
+		return encoding.Enumerated(v), nil
+	}
+	return nil, fmt.Errorf("unknown datatype %q", datatype)
+}
+
+// formatValue 以可读的文本输出读到的值，常见的枚举显示名称
+func formatValue(objType model.ObjectType, prop model.PropertyIdentifier, value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = formatValue(objType, prop, item)
+		}
+		return "{" + strings.Join(items, ", ") + "}"
+	case model.ObjectIdentifier:
+		return formatObject(v)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case string:
+		return strconv.Quote(v)
+	case []byte:
+		return fmt.Sprintf("%X", v)
+	case encoding.Enumerated:
+		switch prop {
+		case model.PropertyIdentifierUnits:
+			return model.EngineeringUnits(v).String()
+		case model.PropertyIdentifierObjectType:
+			return model.ObjectType(v).String()
+		case model.PropertyIdentifierEventState:
+			return model.EventState(v).String()
+		case model.PropertyIdentifierPresentValue, model.PropertyIdentifierRelinquishDefault:
+			if meta, ok := model.LookupPropertyMetadata(objType, prop); ok && meta.Datatype == model.DatatypeEnumerated && v <= 1 {
+				return []string{"inactive", "active"}[v]
+			}
+		}
+		return strconv.FormatUint(uint64(v), 10)
+	}
+	return fmt.Sprint(value)
+}
+
+// formatObject 以type:instance形式输出对象标识符
+func formatObject(oid model.ObjectIdentifier) string {
+	return fmt.Sprintf("%s:%d", oid.Type, oid.Instance)
+}
+
+// segmentationName 返回BACnetSegmentation的名称
+func segmentationName(segmentation uint32) string {
+	names := []string{"both", "transmit", "receive", "none"}
+	if int(segmentation) < len(names) {
+		return names[segmentation]
+	}
+	return strconv.Itoa(int(segmentation))
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+	"github.com/iotzf/bacnet-server/protocol"
+)
+
+// startLoopbackServer 在回环网络上启动服务端，客户端子命令在测试期间改用同一网络上的传输，返回服务端地址
+func startLoopbackServer(t *testing.T) (*protocol.BACnetServer, *model.Device, string) {
+	t.Helper()
+	network := protocol.NewLoopbackNetwork()
+	device := model.NewDevice(1234, "Tool Device", "")
+	device.AddObject(model.NewAnalogValue(1, "Setpoint", model.UnitsDegreesCelsius))
+	device.AddObject(model.NewBinaryOutput(1, "Fan"))
+	transport := network.Attach()
+	server, err := protocol.NewServer(device, protocol.Options{Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Start(context.Background())
+	t.Cleanup(server.Stop)
+
+	open := openTransport
+	openTransport = func(string, bool) (protocol.Transport, error) { return network.Attach(), nil }
+	t.Cleanup(func() { openTransport = open })
+	return server, device, transport.LocalAddr().String()
+}
+
+// captureStdout 把标准输出重定向到管道并逐行送出，done恢复标准输出并在读完后关闭lines
+func captureStdout(t *testing.T) (lines <-chan string, done func()) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	out := make(chan string, 64)
+	go func() {
+		defer r.Close()
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			out <- scanner.Text()
+		}
+		close(out)
+	}()
+	var once sync.Once
+	done = func() {
+		once.Do(func() {
+			os.Stdout = stdout
+			w.Close()
+		})
+	}
+	t.Cleanup(done)
+	return out, done
+}
+
+// runCommand 运行子命令并返回其输出到标准输出的各行
+func runCommand(t *testing.T, command func([]string) error, args ...string) []string {
+	t.Helper()
+	lines, done := captureStdout(t)
+	err := command(args)
+	done()
+	var out []string
+	for line := range lines {
+		out = append(out, line)
+	}
+	if err != nil {
+		t.Fatalf("%v: %v", args, err)
+	}
+	return out
+}
+
+func TestDiscoverCommand(t *testing.T) {
+	_, _, addr := startLoopbackServer(t)
+
+	tests := []struct {
+		name  string
+		args  []string
+		found bool
+	}{
+		{"broadcast", []string{"-timeout", "200ms"}, true},
+		{"target", []string{"-timeout", "200ms", "-target", addr}, true},
+		{"in range", []string{"-timeout", "200ms", "-low", "1000", "-high", "2000"}, true},
+		{"out of range", []string{"-timeout", "200ms", "-low", "2000", "-high", "3000"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := runCommand(t, discover, tt.args...)
+			if len(out) == 0 || !strings.HasPrefix(out[0], "DEVICE") {
+				t.Fatalf("output = %q, want a header line", out)
+			}
+			if !tt.found {
+				if len(out) != 1 {
+					t.Errorf("output = %q, want no devices", out)
+				}
+				return
+			}
+			if len(out) != 2 {
+				t.Fatalf("output = %q, want one device", out)
+			}
+			fields := strings.Fields(out[1])
+			if len(fields) != 5 || fields[0] != "1234" || fields[1] != addr || fields[2] != "1024" || fields[3] != "transmit" {
+				t.Errorf("device line = %q", out[1])
+			}
+		})
+	}
+}
+
+func TestReadWriteCommands(t *testing.T) {
+	server, device, addr := startLoopbackServer(t)
+
+	if out := runCommand(t, write, addr, "analog-value:1", "present-value", "-12.5", "-priority", "8"); len(out) != 1 || out[0] != "ok" {
+		t.Fatalf("write output = %q, want ok", out)
+	}
+	server.Lock()
+	value, _ := device.FindObject(model.ObjectIdentifier{Type: model.ObjectTypeAnalogValue, Instance: 1}).ReadProperty(model.PropertyIdentifierPresentValue)
+	server.Unlock()
+	if value != float32(-12.5) {
+		t.Errorf("device Present_Value = %v, want -12.5", value)
+	}
+	runCommand(t, write, addr, "binary-output:1", "present-value", "active")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{addr, "analog-value:1", "present-value"}, "-12.5"},
+		{[]string{addr, "analog-value:1", "units"}, model.UnitsDegreesCelsius.String()},
+		{[]string{addr, "analog-value:1", "object-name"}, `"Setpoint"`},
+		{[]string{"-index", "8", addr, "analog-value:1", "priority-array"}, "-12.5"},
+		{[]string{"-index", "0", addr, "analog-value:1", "priority-array"}, "16"},
+		{[]string{addr, "binary-output:1", "present-value"}, "active"},
+		{[]string{addr, "binary-output:1", "relinquish-default"}, "inactive"},
+	}
+	for _, tt := range tests {
+		out := runCommand(t, read, tt.args...)
+		if len(out) != 1 || out[0] != tt.want {
+			t.Errorf("read %v = %q, want %q", tt.args, out, tt.want)
+		}
+	}
+}
+
+func TestCOVCommand(t *testing.T) {
+	server, _, addr := startLoopbackServer(t)
+
+	lines, done := captureStdout(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() {
+		result <- watchCOV(ctx, []string{"-timeout", "50ms", addr, "analog-value:1"})
+	}()
+
+	// wait 等待包含want的通知行
+	wait := func(want string) {
+		t.Helper()
+		timeout := time.After(2 * time.Second)
+		for {
+			select {
+			case line := <-lines:
+				if strings.Contains(line, want) {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("no notification line containing %q", want)
+			}
+		}
+	}
+	for deadline := time.Now().Add(2 * time.Second); server.Metrics().COVSubscriptions == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("cov did not subscribe")
+		}
+	}
+	server.SimulateDataChange(model.ObjectIdentifier{Type: model.ObjectTypeAnalogValue, Instance: 1}, model.PropertyIdentifierPresentValue, float32(30))
+	wait("analog-value:1 present-value = 30")
+
+	cancel()
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("watchCOV() = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("watchCOV did not return after cancel")
+	}
+	done()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+	"github.com/iotzf/bacnet-server/protocol"
+)
+
+// load 按给定速率向设备发出混合请求，结束或收到终止信号后输出各请求的延迟百分位数
+func load(args []string) error {
+	flags, options := newClientFlags("load")
+	rate := flags.Float64("rate", 100, "Requests per second")
+	duration := flags.Duration("duration", 10*time.Second, "How long to generate load")
+	clients := flags.Int("clients", 8, "Concurrent clients, each with its own socket and at most one outstanding request")
+	mixText := flags.String("mix", protocol.DefaultLoadMix.String(), "Weights of the request mix: whois, rp (ReadProperty), rpm (ReadPropertyMultiple), wp (WriteProperty) and cov (SubscribeCOV)")
+	objects := flags.String("objects", "analog-value:1", "Comma-separated objects to read, write and subscribe to in turn")
+	deviceID := flags.Int("device", -1, "Device instance asked for by Who-Is (-1 to learn it from the target first)")
+	priority := flags.Uint("priority", 16, "Priority of the writes (0 to send none)")
+	lifetime := flags.Uint("lifetime", 60, "Lifetime in seconds of the COV subscriptions")
+	if args = parseArgs(flags, args); len(args) != 1 {
+		return errors.New("usage: load [flags] <address>")
+	}
+	if *clients < 1 || *priority > 16 {
+		return errors.New("-clients must be positive and -priority at most 16")
+	}
+	addr, err := resolveDevice(args[0])
+	if err != nil {
+		return err
+	}
+	mix, err := protocol.ParseLoadMix(*mixText)
+	if err != nil {
+		return err
+	}
+	test := &protocol.LoadTest{Target: addr, Mix: mix, Rate: *rate, Duration: *duration, Priority: uint8(*priority), Lifetime: uint32(*lifetime)}
+	for _, text := range strings.Split(*objects, ",") {
+		if text = strings.TrimSpace(text); text == "" {
+			continue
+		}
+		oid, err := model.ParseObjectIdentifier(text)
+		if err != nil {
+			return err
+		}
+		test.Objects = append(test.Objects, oid)
+	}
+
+	pool := make([]*protocol.TestClient, *clients)
+	for i := range pool {
+		if pool[i], err = options.client(); err != nil {
+			return err
+		}
+		defer pool[i].Close()
+	}
+	if mix[protocol.LoadWhoIs] > 0 {
+		if *deviceID < 0 {
+			devices, err := pool[0].Discover(addr, 0, 0)
+			if err != nil {
+				return err
+			}
+			if len(devices) == 0 {
+				return fmt.Errorf("no I-Am from %s, give -device", addr)
+			}
+			*deviceID = int(devices[0].ID.Instance)
+		}
+		test.Device = uint32(*deviceID)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	fmt.Fprintf(os.Stderr, "sending %g requests/s (%s) to %s for %s with %d clients\n", *rate, mix, addr, *duration, *clients)
+	report, err := test.Run(ctx, pool)
+	if report != nil {
+		report.WriteTo(os.Stdout)
+	}
+	if errors.Is(err, context.Canceled) {
+		return nil // 按Ctrl-C提前结束时输出已完成部分的结果
+	}
+	return err
+}
@@ -0,0 +1,691 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/iotzf/bacnet-server/config"
+	"github.com/iotzf/bacnet-server/modbus"
+	"github.com/iotzf/bacnet-server/model"
+	"github.com/iotzf/bacnet-server/mstp"
+	"github.com/iotzf/bacnet-server/protocol"
+	"github.com/iotzf/bacnet-server/simulation"
+)
+
+// sampleSimulation 为示例对象中的传感器设置模拟曲线
+func sampleSimulation(engine *simulation.Engine, device *model.Device) {
+	profiles := []struct {
+		instance uint32
+		profile  simulation.Profile
+	}{
+		// 温度在18-30°C之间以10分钟为周期起伏
+		{1, simulation.Profile{Kind: simulation.Sine, Offset: 24, Amplitude: 6, Period: 10 * time.Minute, Noise: 0.1}},
+		// 湿度在30-80%之间缓慢漂移
+		{2, simulation.Profile{Kind: simulation.RandomWalk, Offset: 55, Amplitude: 25, Step: 2}},
+		// 压力在3.0-6.0 bar之间随机变化
+		{3, simulation.Profile{Kind: simulation.Random, Offset: 4.5, Amplitude: 1.5}},
+	}
+	for _, p := range profiles {
+		if obj := device.FindObject(model.ObjectIdentifier{Type: model.ObjectTypeAnalogInput, Instance: p.instance}); obj != nil {
+			engine.Set(obj, p.profile)
+		}
+	}
+}
+
+// serve 运行BACnet服务端，直到收到终止信号
+func serve(args []string) {
+	// 定义命令行参数
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	port := flags.Int("port", 47808, "Port to listen on for BACnet messages (0 for an ephemeral port, requires -bbmd)")
+	reusePort := flags.Bool("reuse-port", false, "Share the port with another BACnet stack on this host using SO_REUSEPORT")
+	interfaces := flags.String("interface", "", "Comma-separated network interfaces or IPv4 addresses to bind to (empty for all interfaces)")
+	deviceID := flags.Uint("device-id", 1001, "Device instance number")
+	deviceName := flags.String("device-name", "Go BACnet Server", "Name of the BACnet device")
+	location := flags.String("location", "Test Location", "Physical location of the device")
+	configFile := flags.String("config", "", "YAML, JSON or EDE (.csv) site file defining the device and its objects, replaces -device-id, -device-name, -location and the sample objects")
+	preset := flags.String("preset", "", "Built-in site of equipment templates (AHU, VAV, chiller, meter) to serve instead of -config: "+strings.Join(config.PresetNames(), ", "))
+	picsFile := flags.String("pics", "", "Write an EPICS conformance statement for the configured services, datalinks and objects to this file (- for stdout), then exit")
+	exportEDE := flags.String("export-ede", "", "Write the object database to this EDE (.csv) file and its state text file, then exit")
+	stateFile := flags.String("state-file", "bacnet-state.json", "File for persisting priority arrays (empty to disable)")
+	snapshotFile := flags.String("snapshot-file", "", "File for periodic snapshots of present values, priority arrays, schedules and log buffers, restored at startup (empty to disable)")
+	snapshotInterval := flags.Duration("snapshot-interval", time.Minute, "Interval between snapshots")
+	timeSyncInterval := flags.Duration("time-sync-interval", 0, "Act as the site time master, sending TimeSynchronization to Time_Synchronization_Recipients (broadcast when none) at this interval in whole minutes (0 to disable)")
+	timeSyncUTC := flags.Bool("time-sync-utc", false, "Broadcast UTCTimeSynchronization instead of TimeSynchronization when no recipients are configured")
+	auditLogger := flags.String("audit-logger", "", "Forward audit notifications to this audit logger: a device instance number or an ip:port (empty to only record them in Audit Log objects)")
+	auditConfirmed := flags.Bool("audit-confirmed", false, "Forward audit notifications with ConfirmedAuditNotification")
+	quarantineDir := flags.String("quarantine-dir", "", "Directory for saving datagrams that crash the decoder (empty to disable)")
+	bbmd := flags.String("bbmd", "", "Address of a remote BBMD to register with as a foreign device, ip:port (empty to disable)")
+	ttl := flags.Uint("ttl", 60, "Time-to-live in seconds for foreign device registration")
+	mstpPort := flags.String("mstp-port", "", "Serial port for an MS/TP datalink, e.g. /dev/ttyUSB0 (empty to disable)")
+	mstpBaud := flags.Int("mstp-baud", 38400, "MS/TP baud rate")
+	mstpMAC := flags.Uint("mstp-mac", 1, "MS/TP station address (0-127)")
+	mstpMaxMaster := flags.Uint("mstp-max-master", 127, "Highest MS/TP master address on the trunk")
+	mstpMaxInfoFrames := flags.Int("mstp-max-info-frames", 1, "Maximum frames sent per MS/TP token")
+	network := flags.Uint("network", 0, "BACnet network number of the IP network, enables routing between datalinks (0 to disable)")
+	mstpNetwork := flags.Uint("mstp-network", 0, "BACnet network number of the MS/TP trunk when routing")
+	virtualNetwork := flags.Uint("virtual-network", 0, "Network number of a virtual network of simulated devices behind the router (0 to disable)")
+	virtualDevices := flags.Uint("virtual-devices", 0, "Number of simulated devices on the virtual network, numbered after -device-id")
+	dscp := flags.Uint("dscp", 0, "DSCP value (0-63) for outgoing BACnet/IP packets (0 to leave unmarked)")
+	bdt := flags.String("bdt", "", "Comma-separated BBMD broadcast distribution table, ip:port[/mask] including this device (empty to disable BBMD)")
+	readOnly := flags.Bool("read-only", false, "Reject every service that modifies the device (writes, file writes, object creation)")
+	denyWrites := flags.String("deny-writes", "", "Comma-separated IPs or subnets (CIDR) whose write and file services are rejected")
+	rateLimit := flags.Float64("rate-limit", 0, "Maximum datagrams per second accepted from one source IP (0 for no limit)")
+	rateBurst := flags.Int("rate-burst", 20, "Burst of datagrams allowed from one source IP above -rate-limit")
+	whoIsRateLimit := flags.Float64("whois-rate-limit", 0, "Maximum Who-Is and Who-Has requests answered per second (0 for no limit)")
+	trace := flags.Bool("trace", false, "Write every BACnet/IP datagram to stderr with a layered decode and hex dump")
+	pcapFile := flags.String("pcap", "", "Write every BACnet/IP datagram to this pcap file for Wireshark (empty to disable)")
+	metricsAddr := flags.String("metrics-addr", "", "HTTP address serving /metrics (Prometheus) and /debug/vars (JSON), e.g. :9090 (empty to disable)")
+	dashboardAddr := flags.String("dashboard-addr", "", "HTTP address serving a web dashboard with live values, alarms, subscriptions and property editing, e.g. :8080 (empty to disable)")
+	consoleAddr := flags.String("console", "", "Interactive console for listing objects, setting values, raising alarms and dropping subscriptions: - for stdin, unix:/path for a Unix socket or a TCP address such as :7000 for telnet (empty to disable)")
+	scenarioFile := flags.String("scenario", "", "YAML or JSON scenario of timed console commands (set values, raise faults, drop comms, expire subscriptions) to run after startup, progress is printed to stdout")
+	scenarioExit := flags.Bool("scenario-exit", false, "Stop the server when the scenario ends, exiting with status 1 if a step failed")
+	opcuaAddr := flags.String("opcua-addr", "", "TCP address of an embedded OPC UA server mirroring the object tree, e.g. :4840 (empty to disable)")
+	workers := flags.Int("workers", 1, "Number of goroutines processing datagrams concurrently")
+	covQueueSize := flags.Int("cov-queue-size", 64, "COV notifications queued per subscriber; new notifications are dropped and counted when a slow subscriber's queue is full")
+	logLevel := flags.String("log-level", "info", "Log level: packet, debug, info, warn or error (packet logs every datagram)")
+	logFormat := flags.String("log-format", "text", "Log format: text or json")
+	flags.Parse(args)
+
+	logger, err := newLogger(*logLevel, *logFormat)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
+
+	// 创建BACnet设备：指定了配置文件时按站点配置创建，否则使用示例对象
+	var site *config.Site
+	var device *model.Device
+	var gateways []*modbus.Gateway
+	engine := simulation.NewEngine()
+	engine.Logger = logger
+	if *configFile != "" && *preset != "" {
+		fmt.Println("-config and -preset cannot be used together")
+		os.Exit(1)
+	}
+	if *configFile != "" || *preset != "" {
+		if *preset != "" {
+			site, err = config.LoadPreset(*preset)
+		} else {
+			site, err = config.Load(*configFile)
+		}
+		if err == nil {
+			device, err = site.Build()
+		}
+		if err == nil {
+			err = site.Simulate(engine, device)
+		}
+		if err == nil {
+			gateways, err = site.Gateways(device)
+		}
+		if err != nil {
+			fmt.Printf("Failed to load site config: %v\n", err)
+			os.Exit(1)
+		}
+		*deviceID = uint(device.GetObjectIdentifier().Instance)
+		*location = site.Device.Location
+	} else {
+		device = model.NewDevice(uint32(*deviceID), *deviceName, *location)
+		addSampleObjects(device)
+		sampleSimulation(engine, device)
+	}
+	var scenario *protocol.Scenario
+	if *scenarioFile != "" {
+		if scenario, err = config.LoadScenario(*scenarioFile); err != nil {
+			fmt.Printf("Failed to load scenario: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *exportEDE != "" {
+		if err := config.SaveEDE(device, *exportEDE); err != nil {
+			fmt.Printf("Failed to export EDE: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Exported %d objects to %s and %s\n", len(device.Objects())+1, *exportEDE, config.StateTextsPath(*exportEDE))
+		return
+	}
+
+	// 临时端口上收不到其他设备发往47808的广播，只能经BBMD收发
+	if *port == 0 && *bbmd == "" {
+		fmt.Println("-port 0 (ephemeral port) requires -bbmd to register as a foreign device")
+		os.Exit(1)
+	}
+
+	if *dscp > 63 {
+		fmt.Println("DSCP must be at most 63")
+		os.Exit(1)
+	}
+
+	// 配置了BDT时作为BBMD运行
+	var entries []protocol.BDTEntry
+	if *bdt != "" {
+		for _, text := range strings.Split(*bdt, ",") {
+			entry, err := protocol.ParseBDTEntry(strings.TrimSpace(text))
+			if err != nil {
+				fmt.Printf("Invalid BDT entry: %v\n", err)
+				os.Exit(1)
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	// 创建BACnet服务器：配置了远程BBMD时作为外部设备注册，配置了网络号时在各数据链路之间路由
+	options := protocol.Options{
+		Address:            fmt.Sprintf(":%d", *port),
+		ReusePort:          *reusePort,
+		DSCP:               byte(*dscp),
+		BDT:                entries,
+		ForeignBBMD:        *bbmd,
+		ForeignTTL:         uint16(*ttl),
+		Network:            uint16(*network),
+		ReadOnly:           *readOnly,
+		RateLimit:          *rateLimit,
+		RateBurst:          *rateBurst,
+		DiscoveryRateLimit: *whoIsRateLimit,
+		Workers:            *workers,
+		COVQueueSize:       *covQueueSize,
+		StateFile:          *stateFile,
+		SnapshotFile:       *snapshotFile,
+		SnapshotInterval:   *snapshotInterval,
+		TimeSyncInterval:   *timeSyncInterval,
+		UTCTimeSync:        *timeSyncUTC,
+		QuarantineDir:      *quarantineDir,
+		MetricsAddress:     *metricsAddr,
+		DashboardAddress:   *dashboardAddr,
+		OPCUAAddress:       *opcuaAddr,
+		ConsoleAddress:     *consoleAddr,
+		CaptureFile:        *pcapFile,
+		Logger:             logger,
+	}
+	if *auditLogger != "" {
+		recipient, err := parseAuditLogger(*auditLogger)
+		if err != nil {
+			fmt.Printf("Invalid -audit-logger: %v\n", err)
+			os.Exit(1)
+		}
+		options.AuditLogger = recipient
+		options.AuditConfirmed = *auditConfirmed
+	}
+	if *interfaces != "" {
+		for _, name := range strings.Split(*interfaces, ",") {
+			options.Interfaces = append(options.Interfaces, strings.TrimSpace(name))
+		}
+	}
+	if *consoleAddr == "-" {
+		options.ConsoleAddress = ""
+	}
+	if *denyWrites != "" {
+		services := append(append([]byte{}, protocol.WriteServices...), protocol.FileServices...)
+		for _, source := range strings.Split(*denyWrites, ",") {
+			options.ACL = append(options.ACL, protocol.ACLRule{Source: strings.TrimSpace(source), Services: services, Deny: true})
+		}
+	}
+	server, err := protocol.NewServer(device, options)
+	if err != nil {
+		fmt.Printf("Failed to create BACnet server: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *trace {
+		server.SetTrace(os.Stderr)
+	}
+	server.SetSimulation(engine)
+
+	// 配置了虚拟网络时在其上创建模拟设备
+	if *virtualNetwork != 0 {
+		if *network == 0 {
+			fmt.Println("-network is required for a virtual network")
+			os.Exit(1)
+		}
+		if err := addVirtualDevices(server, uint16(*virtualNetwork), uint32(*deviceID)+1, int(*virtualDevices), *location); err != nil {
+			fmt.Printf("Failed to create virtual network: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *picsFile != "" {
+		if err := writePICS(server, *picsFile, *mstpPort, *mstpBaud, *network != 0); err != nil {
+			fmt.Printf("Failed to write PICS: %v\n", err)
+			os.Exit(1)
+		}
+		server.Stop()
+		return
+	}
+
+	// 收到终止信号时优雅关闭服务器
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// 启动服务器
+	server.Start(ctx)
+
+	// 配置了串口时同时在MS/TP网段上提供服务
+	if *mstpPort != "" {
+		if *mstpMAC > mstp.MaxMasterAddress || *mstpMaxMaster > mstp.MaxMasterAddress {
+			fmt.Printf("MS/TP addresses must be at most %d\n", mstp.MaxMasterAddress)
+			os.Exit(1)
+		}
+		if *network != 0 && *mstpNetwork == 0 {
+			fmt.Println("-mstp-network is required when routing")
+			os.Exit(1)
+		}
+		node, err := startMSTP(server, *mstpPort, *mstpBaud, mstp.Config{
+			MAC:           byte(*mstpMAC),
+			MaxMaster:     byte(*mstpMaxMaster),
+			MaxInfoFrames: *mstpMaxInfoFrames,
+			Logger:        logger,
+		}, uint16(*mstpNetwork))
+		if err != nil {
+			fmt.Printf("Failed to start MS/TP datalink: %v\n", err)
+			os.Exit(1)
+		}
+		defer node.Close()
+	}
+
+	// 启动数据模拟，控制台的sim命令可以在运行中修改曲线
+	go engine.Run(ctx)
+	for _, gateway := range gateways {
+		gateway.Logger = logger
+		defer gateway.Close()
+		go gateway.Run(ctx)
+	}
+	// 配置了代理时镜像下游设备，代理对象在运行中加入服务端
+	if site != nil {
+		p, err := site.NewProxy(server, logger)
+		if err != nil {
+			fmt.Printf("Failed to start proxy: %v\n", err)
+			os.Exit(1)
+		}
+		if p != nil {
+			defer p.Close()
+			go p.Run(ctx)
+		}
+		// 配置了趋势采集时定期读取远程趋势日志，状态由仪表盘的/api/harvest提供
+		h, err := site.NewHarvester(logger)
+		if err != nil {
+			fmt.Printf("Failed to start trend harvesting: %v\n", err)
+			os.Exit(1)
+		}
+		if h != nil {
+			server.HandleDashboardAPI("harvest", h.StatusHandler())
+			defer h.Close()
+			go h.Run(ctx)
+		}
+	}
+	if *consoleAddr == "-" {
+		go server.ServeConsole(os.Stdin, os.Stdout)
+	}
+
+	exitCode := 0
+	if scenario != nil {
+		go func() {
+			err := server.RunScenario(ctx, scenario, printProgress)
+			if err != nil && ctx.Err() == nil {
+				fmt.Printf("Scenario failed: %v\n", err)
+			}
+			if *scenarioExit {
+				if err != nil {
+					exitCode = 1
+				}
+				stop()
+			}
+		}()
+	}
+
+	// 等待终止信号，服务器处理完进行中的请求后关闭
+	<-ctx.Done()
+	<-server.Done()
+	slog.Info("程序已退出")
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
+}
+
+// parseAuditLogger 解析审计记录设备：设备实例号，或B/IP地址（ip:port）
+func parseAuditLogger(text string) (*model.Recipient, error) {
+	if instance, err := strconv.ParseUint(text, 10, 22); err == nil {
+		device := model.ObjectIdentifier{Type: model.ObjectTypeDevice, Instance: uint32(instance)}
+		return &model.Recipient{Device: &device}, nil
+	}
+	addr, err := net.ResolveUDPAddr("udp4", text)
+	if err != nil {
+		return nil, err
+	}
+	mac := append(addr.IP.To4(), byte(addr.Port>>8), byte(addr.Port))
+	return &model.Recipient{Address: &model.Address{MAC: mac}}, nil
+}
+
+// printProgress 在标准输出打印场景的一步
+func printProgress(p protocol.ScenarioProgress) {
+	result := p.Output
+	if p.Err != nil {
+		result = "error: " + p.Err.Error()
+	}
+	fmt.Printf("[%d/%d] +%s %s\n", p.Step, p.Total, p.At, p.Command)
+	for _, line := range strings.Split(result, "\n") {
+		if line != "" {
+			fmt.Printf("    %s\n", line)
+		}
+	}
+}
+
+// writePICS 将服务端的一致性声明写入path，path为-时写到标准输出。MS/TP链路在启动后才打开，
+// 按命令行参数补充到数据链路选项中
+func writePICS(server *protocol.BACnetServer, path, mstpPort string, mstpBaud int, routing bool) error {
+	pics := server.PICS()
+	if mstpPort != "" {
+		link := fmt.Sprintf("MS/TP master (Clause 9), baud rate(s): %d", mstpBaud)
+		if routing {
+			link += ", routed"
+		}
+		pics.DataLinks = append(pics.DataLinks, link)
+	}
+	if path == "-" {
+		_, err := pics.WriteTo(os.Stdout)
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := pics.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// addVirtualDevices 在虚拟网络上创建count个模拟设备，实例号从first开始
+func addVirtualDevices(server *protocol.BACnetServer, network uint16, first uint32, count int, location string) error {
+	virtual, err := server.AddVirtualNetwork(network)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < count; i++ {
+		instance := first + uint32(i)
+		device := model.NewDevice(instance, fmt.Sprintf("Virtual Device %d", instance), location)
+		addSampleObjects(device)
+		if _, err := virtual.AddDevice(device); err != nil {
+			return err
+		}
+	}
+	slog.Info("虚拟网络已启动", "network", network, "devices", count)
+	return nil
+}
+
+// startMSTP 打开串口并运行MS/TP主节点。network不为0时节点作为路由器端口连接到该网络，
+// 否则收到的NPDU直接交给服务端处理
+func startMSTP(server *protocol.BACnetServer, portName string, baud int, config mstp.Config, network uint16) (*mstp.Node, error) {
+	port, err := mstp.OpenSerial(portName, baud)
+	if err != nil {
+		return nil, err
+	}
+	var routerPort *protocol.RouterPort
+	node, err := mstp.NewNode(port, config, func(npdu []byte, source byte, expectingReply bool) []byte {
+		var response []byte
+		var err error
+		if routerPort != nil {
+			response, err = routerPort.HandleNPDU(npdu, []byte{source})
+		} else {
+			response, err = server.HandleNPDU(npdu, fmt.Sprintf("mstp:%d", source))
+		}
+		if err != nil {
+			server.Logger().Debug("处理MS/TP消息失败", "peer", source, "error", err)
+			return nil
+		}
+		return response
+	})
+	if err != nil {
+		port.Close()
+		return nil, err
+	}
+	if network != 0 {
+		if routerPort, err = server.AddRouterPort(network, node); err != nil {
+			node.Close()
+			return nil, err
+		}
+	}
+	go node.Run()
+	slog.Info("MS/TP数据链路已启动", "port", portName, "baud", baud, "mac", config.MAC)
+	return node, nil
+}
+
+// addSampleObjects 向设备添加示例对象
+func addSampleObjects(device *model.Device) {
+	// 添加模拟输入对象 (温度传感器)
+	tempSensor := model.NewAnalogInput(1, "Temperature Sensor", model.UnitsDegreesCelsius)
+	tempSensor.SetRange(-40, 85)
+	tempSensor.Resolution = 0.1
+	tempSensor.WriteProperty(model.PropertyIdentifierDescription, "Room temperature sensor")
+	tempSensor.UpdatePresentValue(22.5) // 22.5°C
+	device.AddObject(tempSensor)
+
+	// 添加模拟输入对象 (湿度传感器)
+	humiditySensor := model.NewAnalogInput(2, "Humidity Sensor", model.UnitsPercentRelativeHumidity)
+	humiditySensor.SetRange(0, 100)
+	humiditySensor.WriteProperty(model.PropertyIdentifierDescription, "Room humidity sensor")
+	humiditySensor.UpdatePresentValue(45.0) // 45%
+	device.AddObject(humiditySensor)
+
+	// 添加二进制输出对象 (灯光控制)
+	lightSwitch := model.NewBinaryOutput(1, "Light Switch")
+	lightSwitch.WriteProperty(model.PropertyIdentifierDescription, "Main room light")
+	lightSwitch.ActiveText, lightSwitch.InactiveText = "On", "Off"
+	lightSwitch.WriteProperty(model.PropertyIdentifierPresentValue, false) // 关闭状态
+	device.AddObject(lightSwitch)
+
+	// 添加二进制输出对象 (空调控制)
+	acSwitch := model.NewBinaryOutput(2, "AC Switch")
+	acSwitch.WriteProperty(model.PropertyIdentifierDescription, "Air conditioner control")
+	acSwitch.ActiveText, acSwitch.InactiveText = "Running", "Stopped"
+	acSwitch.WriteProperty(model.PropertyIdentifierPresentValue, true) // 开启状态
+	acSwitch.MinimumOnTime, acSwitch.MinimumOffTime = 180, 180         // 压缩机保护：最短开/停3分钟
+	device.AddObject(acSwitch)
+
+	// 添加模拟值对象 (设定温度)
+	setpoint := model.NewAnalogValue(1, "Temperature Setpoint", model.UnitsDegreesCelsius)
+	setpoint.SetRange(10, 35)
+	setpoint.WriteProperty(model.PropertyIdentifierDescription, "Desired room temperature")
+	setpoint.WriteProperty(model.PropertyIdentifierPresentValue, 22.0) // 22.0°C
+	device.AddObject(setpoint)
+
+	// 添加支持告警的压力传感器
+	pressureSensor := model.NewAnalogInput(3, "Pressure Sensor with Alarm", model.UnitsBars)
+	pressureSensor.SetRange(0, 10)
+	pressureSensor.WriteProperty(model.PropertyIdentifierDescription, "Water pressure sensor with alarm capability")
+	pressureSensor.UpdatePresentValue(4.5) // 4.5 bar
+	pressureSensor.SetEventState(model.EventStateNormal)
+	pressureSensor.SetNotificationClass(1)
+	pressureSensor.SetStatusFlags(0) // 无标志
+	device.AddObject(pressureSensor)
+
+	// 添加通知类对象
+	notificationClass := model.NewBACnetObject(model.ObjectTypeNotificationClass, 1, "Default Notification Class")
+	notificationClass.WriteProperty(model.PropertyIdentifierDescription, "Default notification settings")
+	notificationClass.WriteProperty(model.PropertyIdentifierPriority, 10) // 中等优先级
+	device.AddObject(notificationClass)
+
+	// 添加事件日志对象
+	eventLog := model.NewEventLog(1, "System Event Log", 1000)
+	eventLog.WriteProperty(model.PropertyIdentifierDescription, "System-wide event log")
+	device.AddObject(eventLog)
+
+	// 添加审计日志对象
+	auditLog := model.NewAuditLog(1, "Audit Log", 1000)
+	auditLog.WriteProperty(model.PropertyIdentifierDescription, "Writes and file changes made by clients and operators")
+	device.AddObject(auditLog)
+
+	// 添加趋势日志对象 (每分钟记录温度)
+	tempTrend := model.NewTrendLog(1, "Temperature Trend", 1440)
+	tempTrend.LogReference = &model.DeviceObjectPropertyReference{
+		ObjectIdentifier:   tempSensor.GetObjectIdentifier(),
+		PropertyIdentifier: model.PropertyIdentifierPresentValue,
+	}
+	device.AddObject(tempTrend)
+
+	// 添加多对象趋势日志 (每5分钟同时记录温湿度)
+	climateTrend := model.NewTrendLogMultiple(1, "Climate Trend", 288)
+	climateTrend.LogInterval = 30000
+	climateTrend.LogReferences = []model.DeviceObjectPropertyReference{
+		{ObjectIdentifier: tempSensor.GetObjectIdentifier(), PropertyIdentifier: model.PropertyIdentifierPresentValue},
+		{ObjectIdentifier: humiditySensor.GetObjectIdentifier(), PropertyIdentifier: model.PropertyIdentifierPresentValue},
+	}
+	device.AddObject(climateTrend)
+
+	// 添加节假日日历 (元旦和每年5月的第一个星期一)
+	holidays := model.NewCalendar(1, "Holidays")
+	newYear := model.Date{Year: model.DateTimeAny, Month: 1, Day: 1, Weekday: model.DateTimeAny}
+	holidays.DateList = []model.CalendarEntry{
+		{Date: &newYear},
+		{WeekNDay: &model.WeekNDay{Month: 5, WeekOfMonth: 1, DayOfWeek: 1}},
+	}
+	device.AddObject(holidays)
+
+	// 添加温度设定日程 (工作日8:00-18:00为22°C，节假日全天16°C)
+	setpointSchedule := model.NewSchedule(1, "Setpoint Schedule", 18.0)
+	for day := 0; day < 5; day++ {
+		setpointSchedule.WeeklySchedule[day] = []model.TimeValue{
+			{Time: 8 * time.Hour, Value: 22.0},
+			{Time: 18 * time.Hour, Value: nil},
+		}
+	}
+	holidayRef := holidays.GetObjectIdentifier()
+	setpointSchedule.ExceptionSchedule = []model.SpecialEvent{
+		{CalendarReference: &holidayRef, TimeValues: []model.TimeValue{{Time: 0, Value: 16.0}}, Priority: 1},
+	}
+	setpointSchedule.References = []model.DeviceObjectPropertyReference{
+		{ObjectIdentifier: setpoint.GetObjectIdentifier(), PropertyIdentifier: model.PropertyIdentifierPresentValue},
+	}
+	device.AddObject(setpointSchedule)
+
+	// 添加冷水阀和温度控制回路 (正作用：温度高于设定值时开大阀门)
+	coolingValve := model.NewAnalogOutput(1, "Cooling Valve", model.UnitsPercent)
+	coolingValve.SetRange(0, 100)
+	coolingValve.WriteProperty(model.PropertyIdentifierDescription, "Chilled water valve position (%)")
+	coolingValve.WriteProperty(model.PropertyIdentifierPresentValue, 0.0)
+	device.AddObject(coolingValve)
+
+	temperatureLoop := model.NewLoop(1, "Temperature Control Loop")
+	temperatureLoop.ControlledVariableReference = model.DeviceObjectPropertyReference{
+		ObjectIdentifier: tempSensor.GetObjectIdentifier(), PropertyIdentifier: model.PropertyIdentifierPresentValue,
+	}
+	temperatureLoop.SetpointReference = &model.ObjectPropertyReference{
+		ObjectIdentifier: setpoint.GetObjectIdentifier(), PropertyIdentifier: model.PropertyIdentifierPresentValue,
+	}
+	temperatureLoop.ManipulatedVariableReference = model.DeviceObjectPropertyReference{
+		ObjectIdentifier: coolingValve.GetObjectIdentifier(), PropertyIdentifier: model.PropertyIdentifierPresentValue,
+	}
+	temperatureLoop.Action = model.LoopActionDirect
+	temperatureLoop.OutputUnits = model.UnitsPercent
+	temperatureLoop.ControlledVariableUnits = model.UnitsDegreesCelsius
+	temperatureLoop.ProportionalConstant = 20
+	temperatureLoop.IntegralConstant = 0.05
+	temperatureLoop.UpdateInterval = 5000
+	device.AddObject(temperatureLoop)
+
+	// 添加程序对象 (客户端写Program_Change时回调)
+	nightPurge := model.NewProgram(1, "Night Purge", func(p *model.Program, change model.ProgramChange) error {
+		slog.Info("程序收到控制请求", "object", p.GetObjectName(), "change", change)
+		return nil
+	})
+	nightPurge.ProgramLocation = "main.go"
+	device.AddObject(nightPurge)
+
+	// 添加电能表累加器和千瓦时脉冲转换器 (每个脉冲0.01kWh)
+	energyMeter := model.NewAccumulator(1, "Energy Meter Pulses")
+	energyMeter.HighLimit = 600
+	device.AddObject(energyMeter)
+
+	energyConverter := model.NewPulseConverter(1, "Energy Consumption")
+	energyConverter.InputReference = &model.DeviceObjectPropertyReference{
+		ObjectIdentifier: energyMeter.GetObjectIdentifier(), PropertyIdentifier: model.PropertyIdentifierPresentValue,
+	}
+	energyConverter.ScaleFactor = 0.01
+	device.AddObject(energyConverter)
+
+	// 添加温度平均值对象 (15分钟窗口，每30秒采样)
+	tempAverage := model.NewAveraging(1, "Temperature 15min Average", 900, 30)
+	tempAverage.ObjectPropertyReference = &model.DeviceObjectPropertyReference{
+		ObjectIdentifier: tempSensor.GetObjectIdentifier(), PropertyIdentifier: model.PropertyIdentifierPresentValue,
+	}
+	device.AddObject(tempAverage)
+
+	// 添加空调运行模式多态值对象
+	operatingMode := model.NewMultiStateValue(1, "AC Operating Mode", []string{"Off", "Heat", "Cool", "Auto"})
+	operatingMode.WriteProperty(model.PropertyIdentifierPresentValue, uint32(4))
+	device.AddObject(operatingMode)
+
+	// 添加传感器全局组 (汇总所有模拟输入的当前值)
+	sensorGroup := model.NewGlobalGroup(1, "Sensor Group")
+	for _, sensor := range []*model.Analog{tempSensor, humiditySensor, pressureSensor} {
+		sensorGroup.GroupMembers = append(sensorGroup.GroupMembers, model.DeviceObjectPropertyReference{
+			ObjectIdentifier: sensor.GetObjectIdentifier(), PropertyIdentifier: model.PropertyIdentifierPresentValue,
+		})
+		sensorGroup.GroupMemberNames = append(sensorGroup.GroupMemberNames, sensor.GetObjectName())
+	}
+	device.AddObject(sensorGroup)
+
+	// 添加火灾报警点和防火分区
+	smokeDetector := model.NewLifeSafetyPoint(1, "Smoke Detector 1F")
+	device.AddObject(smokeDetector)
+	fireZone := model.NewLifeSafetyZone(1, "Fire Zone 1F")
+	fireZone.ZoneMembers = []model.ObjectIdentifier{smokeDetector.GetObjectIdentifier()}
+	smokeDetector.MemberOf = []model.ObjectIdentifier{fireZone.GetObjectIdentifier()}
+	device.AddObject(fireZone)
+
+	// 添加值对象族示例
+	device.AddObject(model.NewCharacterStringValue(1, "Operator Message", "System normal"))
+	device.AddObject(model.NewIntegerValue(1, "Temperature Offset", -2))
+	device.AddObject(model.NewPositiveIntegerValue(1, "Occupant Count", 0))
+	device.AddObject(model.NewLargeAnalogValue(1, "Lifetime Energy", 123456.789))
+	device.AddObject(model.NewDateTimeValue(1, "Last Maintenance", time.Now()))
+	device.AddObject(model.NewOctetStringValue(1, "Controller MAC", []byte{0x00, 0x1A, 0x2B, 0x3C, 0x4D, 0x5E}))
+
+	// 添加文件对象 (配置文件)
+	configFile := model.NewBACnetFile(1, "Configuration File", model.FileAccessMethodStream)
+	device.AddObject(configFile.BACnetObject)
+
+	// 添加事件注册对象
+	eventEnrollment := model.NewBACnetObject(model.ObjectTypeEventEnrollment, 1, "Pressure Alarm Enrollment")
+	eventEnrollment.WriteProperty(model.PropertyIdentifierDescription, "Enrollment for pressure alarm events")
+	device.AddObject(eventEnrollment)
+
+	slog.Debug("已添加示例对象", "device", device.GetObjectIdentifier().Instance, "objects", len(device.Objects()))
+}
+
+// newLogger 按日志级别和格式创建输出到标准错误的日志
+func newLogger(level, format string) (*slog.Logger, error) {
+	var l slog.Level
+	if strings.EqualFold(level, "packet") {
+		l = protocol.LevelPacket
+	} else if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("无效的日志级别: %s", level)
+	}
+	options := &slog.HandlerOptions{
+		Level: l,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			// 逐包日志的级别显示为PACKET而不是DEBUG-4
+			if a.Key == slog.LevelKey && len(groups) == 0 && a.Value.Any() == protocol.LevelPacket {
+				a.Value = slog.StringValue("PACKET")
+			}
+			return a
+		},
+	}
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stderr, options)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, options)), nil
+	default:
+		return nil, fmt.Errorf("无效的日志格式: %s", format)
+	}
+}
@@ -0,0 +1,476 @@
+// Package config 从YAML或JSON配置文件加载站点：设备、对象及其初始属性值、单位、告警限值、
+// COV增量、模拟行为和Modbus网关数据点，使不写Go代码也能定义一个站点。
+// 也可以导入和导出工程工具之间交换数据点表使用的EDE格式，以及读取按时间执行测试步骤的场景文件
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+	"github.com/iotzf/bacnet-server/simulation"
+)
+
+// Site 配置文件描述的站点
+type Site struct {
+	Device  DeviceConfig   `json:"device"`
+	Objects []ObjectConfig `json:"objects"`
+	Modbus  []ModbusConfig `json:"modbus"`  // Modbus网关，数据点映射为对象
+	Rules   []RuleConfig   `json:"rules"`   // 模拟的行为规则
+	Proxy   *ProxyConfig   `json:"proxy"`   // 镜像下游BACnet设备的代理
+	Harvest *HarvestConfig `json:"harvest"` // 采集远程设备的趋势日志
+
+	Equipment []EquipmentConfig `json:"equipment"` // 按模板创建的设备，展开后加入Objects和Rules
+}
+
+// DeviceConfig 设备对象的配置
+type DeviceConfig struct {
+	Instance    uint32         `json:"instance"`
+	Name        string         `json:"name"`
+	Location    string         `json:"location"`
+	Description string         `json:"description"`
+	Properties  map[string]any `json:"properties"` // 其他属性的初始值，键为属性名称（如vendor-name）或编号
+}
+
+// ObjectConfig 一个对象的配置，只使用与对象类型相关的字段
+type ObjectConfig struct {
+	Type         Text              `json:"type"` // 对象类型名称（如analog-input）或编号
+	Instance     uint32            `json:"instance"`
+	Name         string            `json:"name"`
+	Description  string            `json:"description"`
+	PresentValue any               `json:"present_value"` // 初始值，可命令对象写入Relinquish_Default
+	Units        Text              `json:"units"`         // 模拟量的工程单位名称（如degrees-celsius）或编号
+	Min          *float64          `json:"min"`           // 模拟量的Min_Pres_Value
+	Max          *float64          `json:"max"`           // 模拟量的Max_Pres_Value
+	Resolution   float64           `json:"resolution"`    // 模拟输入的分辨率
+	COVIncrement *float64          `json:"cov_increment"`
+	ActiveText   string            `json:"active_text"`   // 二进制对象
+	InactiveText string            `json:"inactive_text"` // 二进制对象
+	States       []string          `json:"states"`        // 多态对象的State_Text
+	Alarm        *AlarmConfig      `json:"alarm"`
+	Properties   map[string]any    `json:"properties"` // 其他属性的初始值，键为属性名称或编号
+	Simulation   *SimulationConfig `json:"simulation"`
+}
+
+// AlarmConfig 模拟量的告警限值，写入对应的内在报警属性
+type AlarmConfig struct {
+	HighLimit         *float64 `json:"high_limit"`
+	LowLimit          *float64 `json:"low_limit"`
+	Deadband          float64  `json:"deadband"`
+	TimeDelay         uint32   `json:"time_delay"`        // 秒，越限持续该时间后报警
+	TimeDelayNormal   *uint32  `json:"time_delay_normal"` // 秒，恢复持续该时间后回到normal，为空时同time_delay
+	NotificationClass uint32   `json:"notification_class"`
+}
+
+// SimulationConfig 对象Present_Value的模拟行为，范围写为min和max或者offset和amplitude，
+// 都未给出时二进制和多态对象取全部状态，模拟量以初始值为中心
+type SimulationConfig struct {
+	Kind      string   `json:"kind"`      // random（默认）、sine、ramp、random-walk、square或csv
+	Min       float64  `json:"min"`       // 模拟量的下限
+	Max       float64  `json:"max"`       // 模拟量的上限
+	Offset    float64  `json:"offset"`    // 中心值
+	Amplitude float64  `json:"amplitude"` // 振幅
+	Period    Duration `json:"period"`    // 正弦、锯齿和方波的周期，为0时为1分钟
+	Noise     float64  `json:"noise"`     // 叠加的高斯噪声的标准差
+	Step      float64  `json:"step"`      // 随机游走每步的最大变化
+	File      string   `json:"file"`      // csv回放的样本文件，Load时相对路径按配置文件所在目录解析
+	Interval  Duration `json:"interval"`  // 更新周期，为0时为5秒
+}
+
+// RuleConfig 一条行为规则，表达式的语法见simulation.Expr
+type RuleConfig struct {
+	Name     string   `json:"name"`
+	Target   string   `json:"target"`   // 目标对象，如analog-input:1
+	When     string   `json:"when"`     // 条件表达式，为空时总是成立
+	Value    string   `json:"value"`    // 条件成立时目标Present_Value的表达式
+	Event    string   `json:"event"`    // 条件成立时目标转换到的事件状态：offnormal、fault、high-limit或low-limit
+	Message  string   `json:"message"`  // 事件通知的消息文本
+	Interval Duration `json:"interval"` // 求值周期，为0时为1秒
+}
+
+// Text 名称或编号，配置中写为字符串或数字
+type Text string
+
+// UnmarshalJSON 接受字符串或数字
+func (t *Text) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*t = Text(text)
+		return nil
+	}
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err != nil {
+		return fmt.Errorf("应为名称或编号: %s", data)
+	}
+	*t = Text(number.String())
+	return nil
+}
+
+// Duration 时长，配置中写为time.ParseDuration的格式（如5s）或秒数
+type Duration time.Duration
+
+// UnmarshalJSON 接受时长字符串或秒数
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		value, err := time.ParseDuration(text)
+		if err != nil {
+			return err
+		}
+		*d = Duration(value)
+		return nil
+	}
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err != nil {
+		return fmt.Errorf("无效的时长: %s", data)
+	}
+	*d = Duration(seconds * float64(time.Second))
+	return nil
+}
+
+// MarshalJSON 输出为时长字符串
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Load 读取配置文件，扩展名为.json时按JSON解析，为.csv时按EDE数据点表解析（见LoadEDE），否则按YAML解析
+func Load(path string) (*Site, error) {
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return LoadEDE(path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	format := "yaml"
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		format = "json"
+	}
+	site, err := Parse(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	// 模拟回放文件的相对路径相对于配置文件所在目录
+	for _, o := range site.Objects {
+		if o.Simulation != nil && o.Simulation.File != "" && !filepath.IsAbs(o.Simulation.File) {
+			o.Simulation.File = filepath.Join(filepath.Dir(path), o.Simulation.File)
+		}
+	}
+	return site, nil
+}
+
+// Parse 解析format（yaml或json）格式的配置，不认识的字段视为错误
+func Parse(data []byte, format string) (*Site, error) {
+	var site Site
+	if err := decode(data, format, &site); err != nil {
+		return nil, err
+	}
+	if err := site.expandEquipment(); err != nil {
+		return nil, err
+	}
+	return &site, nil
+}
+
+// decode 将format（yaml或json）格式的文档解码到v，不认识的字段视为错误
+func decode(data []byte, format string, v any) error {
+	switch format {
+	case "json":
+	case "yaml", "yml":
+		document, err := parseYAML(data)
+		if err != nil {
+			return err
+		}
+		if data, err = json.Marshal(document); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("不支持的配置格式: %s", format)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// Build 按配置创建设备及其对象
+func (s *Site) Build() (*model.Device, error) {
+	if s.Device.Name == "" {
+		return nil, errors.New("设备名称不能为空")
+	}
+	device := model.NewDevice(s.Device.Instance, s.Device.Name, s.Device.Location)
+	if s.Device.Description != "" {
+		device.WriteProperty(model.PropertyIdentifierDescription, s.Device.Description)
+	}
+	if err := writeProperties(device, s.Device.Properties); err != nil {
+		return nil, fmt.Errorf("设备: %w", err)
+	}
+	for i, o := range s.Objects {
+		obj, err := o.build()
+		if err == nil {
+			err = device.AddObject(obj)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("对象%d（%s）: %w", i+1, o.Name, err)
+		}
+	}
+	return device, nil
+}
+
+// Identifier 返回配置的对象标识符
+func (o ObjectConfig) Identifier() (model.ObjectIdentifier, error) {
+	objType, err := model.ParseObjectType(string(o.Type))
+	if err != nil {
+		return model.ObjectIdentifier{}, err
+	}
+	return model.ObjectIdentifier{Type: objType, Instance: o.Instance}, nil
+}
+
+// build 创建对象并写入初始属性值
+func (o ObjectConfig) build() (model.Object, error) {
+	oid, err := o.Identifier()
+	if err != nil {
+		return nil, err
+	}
+	if o.Name == "" {
+		return nil, errors.New("对象名称不能为空")
+	}
+	units := model.UnitsNoUnits
+	if o.Units != "" {
+		if units, err = model.ParseEngineeringUnits(string(o.Units)); err != nil {
+			return nil, err
+		}
+	}
+
+	var obj model.Object
+	var base *model.BACnetObject
+	switch oid.Type {
+	case model.ObjectTypeAnalogInput, model.ObjectTypeAnalogOutput, model.ObjectTypeAnalogValue:
+		analog := map[model.ObjectType]func(uint32, string, model.EngineeringUnits) *model.Analog{
+			model.ObjectTypeAnalogInput:  model.NewAnalogInput,
+			model.ObjectTypeAnalogOutput: model.NewAnalogOutput,
+			model.ObjectTypeAnalogValue:  model.NewAnalogValue,
+		}[oid.Type](oid.Instance, o.Name, units)
+		min, max := math.Inf(-1), math.Inf(1)
+		if o.Min != nil {
+			min = *o.Min
+		}
+		if o.Max != nil {
+			max = *o.Max
+		}
+		analog.SetRange(min, max)
+		analog.Resolution = o.Resolution
+		obj, base = analog, analog.BACnetObject
+	case model.ObjectTypeBinaryInput, model.ObjectTypeBinaryOutput, model.ObjectTypeBinaryValue:
+		binary := map[model.ObjectType]func(uint32, string) *model.Binary{
+			model.ObjectTypeBinaryInput:  model.NewBinaryInput,
+			model.ObjectTypeBinaryOutput: model.NewBinaryOutput,
+			model.ObjectTypeBinaryValue:  model.NewBinaryValue,
+		}[oid.Type](oid.Instance, o.Name)
+		binary.ActiveText, binary.InactiveText = o.ActiveText, o.InactiveText
+		obj, base = binary, binary.BACnetObject
+	case model.ObjectTypeMultiStateInput, model.ObjectTypeMultiStateOutput, model.ObjectTypeMultiStateValue:
+		if len(o.States) == 0 {
+			return nil, errors.New("多态对象需要states")
+		}
+		multiState := map[model.ObjectType]func(uint32, string, []string) *model.MultiState{
+			model.ObjectTypeMultiStateInput:  model.NewMultiStateInput,
+			model.ObjectTypeMultiStateOutput: model.NewMultiStateOutput,
+			model.ObjectTypeMultiStateValue:  model.NewMultiStateValue,
+		}[oid.Type](oid.Instance, o.Name, o.States)
+		obj, base = multiState, multiState.BACnetObject
+	case model.ObjectTypeCharacterStringValue:
+		value := model.NewCharacterStringValue(oid.Instance, o.Name, "")
+		obj, base = value, value.BACnetObject
+	case model.ObjectTypeIntegerValue:
+		value := model.NewIntegerValue(oid.Instance, o.Name, 0)
+		obj, base = value, value.BACnetObject
+	case model.ObjectTypePositiveIntegerValue:
+		value := model.NewPositiveIntegerValue(oid.Instance, o.Name, 0)
+		obj, base = value, value.BACnetObject
+	case model.ObjectTypeLargeAnalogValue:
+		value := model.NewLargeAnalogValue(oid.Instance, o.Name, 0)
+		obj, base = value, value.BACnetObject
+	default:
+		// 其他对象类型（如通知类）只保存配置的属性
+		base = model.NewBACnetObject(oid.Type, oid.Instance, o.Name)
+		obj = base
+	}
+
+	if o.Description != "" {
+		obj.WriteProperty(model.PropertyIdentifierDescription, o.Description)
+	}
+	if o.COVIncrement != nil {
+		obj.WriteProperty(model.PropertyIdentifierCOVIncrement, float32(*o.COVIncrement))
+	}
+	if o.Alarm != nil {
+		o.Alarm.apply(base)
+	}
+	if err := writeProperties(obj, o.Properties); err != nil {
+		return nil, err
+	}
+	if o.PresentValue != nil {
+		if err := setPresentValue(obj, o.PresentValue); err != nil {
+			return nil, fmt.Errorf("present_value: %w", err)
+		}
+	}
+	if o.Simulation != nil {
+		if _, err := o.Simulation.kind(); err != nil {
+			return nil, err
+		}
+	}
+	return obj, nil
+}
+
+// Simulate 将配置了simulation的对象和行为规则加入模拟引擎，引擎需要调用Run开始更新
+func (s *Site) Simulate(engine *simulation.Engine, device *model.Device) error {
+	for i, o := range s.Objects {
+		if o.Simulation == nil {
+			continue
+		}
+		oid, err := o.Identifier()
+		if err != nil {
+			return fmt.Errorf("对象%d（%s）: %w", i+1, o.Name, err)
+		}
+		obj := device.FindObject(oid)
+		if obj == nil {
+			return fmt.Errorf("对象%d（%s）: 对象不存在", i+1, o.Name)
+		}
+		profile, err := o.Simulation.profile()
+		if err == nil {
+			err = engine.Set(obj, profile)
+		}
+		if err != nil {
+			return fmt.Errorf("对象%d（%s）: simulation: %w", i+1, o.Name, err)
+		}
+	}
+	for i, r := range s.Rules {
+		rule, err := r.rule(device)
+		if err == nil {
+			err = engine.AddRule(device, rule)
+		}
+		if err != nil {
+			return fmt.Errorf("规则%d（%s）: %w", i+1, r.Name, err)
+		}
+	}
+	return nil
+}
+
+// rule 转换为模拟引擎的规则
+func (r RuleConfig) rule(device *model.Device) (simulation.Rule, error) {
+	oid, err := model.ParseObjectIdentifier(r.Target)
+	if err != nil {
+		return simulation.Rule{}, fmt.Errorf("target: %w", err)
+	}
+	target := device.FindObject(oid)
+	if target == nil {
+		return simulation.Rule{}, fmt.Errorf("target: 对象不存在: %s", r.Target)
+	}
+	rule := simulation.Rule{Name: r.Name, Target: target, When: r.When, Value: r.Value, Message: r.Message, Interval: time.Duration(r.Interval)}
+	if r.Event != "" {
+		if rule.Event, err = model.ParseEventState(r.Event); err != nil {
+			return simulation.Rule{}, err
+		}
+	}
+	if rule.Name == "" {
+		rule.Name = r.Target
+	}
+	return rule, nil
+}
+
+// kind 解析模拟方式，未给出时为random
+func (c *SimulationConfig) kind() (simulation.Kind, error) {
+	if c.Kind == "" {
+		return simulation.Random, nil
+	}
+	return simulation.ParseKind(c.Kind)
+}
+
+// profile 转换为模拟曲线，csv回放时加载样本文件
+func (c *SimulationConfig) profile() (simulation.Profile, error) {
+	kind, err := c.kind()
+	if err != nil {
+		return simulation.Profile{}, err
+	}
+	profile := simulation.Profile{
+		Kind:      kind,
+		Offset:    c.Offset,
+		Amplitude: math.Abs(c.Amplitude),
+		Period:    time.Duration(c.Period),
+		Noise:     c.Noise,
+		Step:      c.Step,
+		Interval:  time.Duration(c.Interval),
+	}
+	if c.Min != 0 || c.Max != 0 {
+		profile.SetRange(c.Min, c.Max)
+	}
+	if kind == simulation.Playback {
+		if c.File == "" {
+			return profile, errors.New("csv回放需要file")
+		}
+		profile.File = c.File
+		if profile.Samples, err = simulation.LoadCSV(profile.File); err != nil {
+			return profile, err
+		}
+	}
+	return profile, nil
+}
+
+// apply 写入告警限值、限值使能和通知类
+func (a *AlarmConfig) apply(obj *model.BACnetObject) {
+	limitEnable := model.NewBitString(2) // low-limit-enable、high-limit-enable
+	if a.LowLimit != nil {
+		obj.WriteProperty(model.PropertyIdentifierLowLimit, float32(*a.LowLimit))
+		limitEnable.Set(0, true)
+	}
+	if a.HighLimit != nil {
+		obj.WriteProperty(model.PropertyIdentifierHighLimit, float32(*a.HighLimit))
+		limitEnable.Set(1, true)
+	}
+	obj.WriteProperty(model.PropertyIdentifierLimitEnable, limitEnable)
+	obj.WriteProperty(model.PropertyIdentifierDeadband, float32(a.Deadband))
+	obj.WriteProperty(model.PropertyIdentifierTimeDelay, a.TimeDelay)
+	if a.TimeDelayNormal != nil {
+		obj.WriteProperty(model.PropertyIdentifierTimeDelayNormal, *a.TimeDelayNormal)
+	}
+	obj.WriteProperty(model.PropertyIdentifierEventEnable, model.BitStringFromUint(3, uint64(model.AckedTransitionsAll)))
+	obj.SetNotificationClass(a.NotificationClass)
+}
+
+// setPresentValue 设置初始值：可命令对象写入Relinquish_Default，其他对象按现场值更新
+func setPresentValue(obj model.Object, value any) error {
+	value, err := model.CoerceValue(obj, model.PropertyIdentifierPresentValue, value)
+	if err != nil {
+		return err
+	}
+	if c, ok := obj.(model.CommandableObject); ok && c.Commandable(model.PropertyIdentifierPresentValue) {
+		return obj.WriteProperty(model.PropertyIdentifierRelinquishDefault, value)
+	}
+	if updater, ok := obj.(model.PresentValueUpdater); ok {
+		return updater.UpdatePresentValue(value)
+	}
+	return obj.WriteProperty(model.PropertyIdentifierPresentValue, value)
+}
+
+// writeProperties 按属性名称或编号写入初始值
+func writeProperties(obj model.Object, properties map[string]any) error {
+	for name, value := range properties {
+		prop, err := model.ParsePropertyIdentifier(name)
+		if err != nil {
+			return err
+		}
+		converted, err := model.CoerceValue(obj, prop, value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if err := obj.WriteProperty(prop, converted); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
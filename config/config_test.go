@@ -0,0 +1,513 @@
+package config
+
+import (
+	"encoding/json"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+	"github.com/iotzf/bacnet-server/simulation"
+)
+
+func TestLoadSite(t *testing.T) {
+	site, err := Load("testdata/site.yaml")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	device, err := site.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	if device.GetObjectIdentifier().Instance != 2001 || device.GetObjectName() != "AHU-1 Controller" {
+		t.Fatalf("device = %v %q", device.GetObjectIdentifier(), device.GetObjectName())
+	}
+	if vendor, _ := device.ReadProperty(model.PropertyIdentifierVendorName); vendor != "Example Controls" {
+		t.Errorf("Vendor_Name = %v", vendor)
+	}
+
+	ai, ok := device.FindObject(model.ObjectIdentifier{Type: model.ObjectTypeAnalogInput, Instance: 1}).(*model.Analog)
+	if !ok {
+		t.Fatal("analog-input 1 not created")
+	}
+	checks := []struct {
+		prop model.PropertyIdentifier
+		want interface{}
+	}{
+		{model.PropertyIdentifierUnits, model.UnitsDegreesCelsius},
+		{model.PropertyIdentifierCOVIncrement, float32(0.5)},
+		{model.PropertyIdentifierHighLimit, float32(30)},
+		{model.PropertyIdentifierLowLimit, float32(5)},
+		{model.PropertyIdentifierTimeDelay, uint32(60)},
+		{model.PropertyIdentifierTimeDelayNormal, uint32(120)},
+		{model.PropertyIdentifierLimitEnable, model.BitString{true, true}},
+	}
+	for _, c := range checks {
+		if got, _ := ai.ReadProperty(c.prop); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("AI1 property %d = %#v, want %#v", c.prop, got, c.want)
+		}
+	}
+	if ai.Resolution != 0.1 {
+		t.Errorf("AI1 Resolution = %v", ai.Resolution)
+	}
+	if pv, _ := ai.ReadProperty(model.PropertyIdentifierPresentValue); toFloat(pv) != 14.5 {
+		t.Errorf("AI1 Present_Value = %v", pv)
+	}
+
+	// 可命令对象的初始值写入Relinquish_Default
+	bo := device.FindObject(model.ObjectIdentifier{Type: model.ObjectTypeBinaryOutput, Instance: 1})
+	if rd, _ := bo.ReadProperty(model.PropertyIdentifierRelinquishDefault); rd != true {
+		t.Errorf("BO1 Relinquish_Default = %v", rd)
+	}
+	if pv, _ := bo.ReadProperty(model.PropertyIdentifierPresentValue); pv != true {
+		t.Errorf("BO1 Present_Value = %v", pv)
+	}
+
+	msv := device.FindObject(model.ObjectIdentifier{Type: model.ObjectTypeMultiStateValue, Instance: 1})
+	if states, _ := msv.ReadProperty(model.PropertyIdentifierStateText); !reflect.DeepEqual(states, []string{"Off", "Low", "Medium", "High"}) {
+		t.Errorf("MSV1 State_Text = %v", states)
+	}
+	if pv, _ := msv.ReadProperty(model.PropertyIdentifierPresentValue); toFloat(pv) != 2 {
+		t.Errorf("MSV1 Present_Value = %v", pv)
+	}
+
+	nc := device.FindObject(model.ObjectIdentifier{Type: model.ObjectTypeNotificationClass, Instance: 1})
+	if priority, _ := nc.ReadProperty(model.PropertyIdentifierPriority); priority != uint32(10) {
+		t.Errorf("NC1 Priority = %#v", priority)
+	}
+
+	if simulation := site.Objects[0].Simulation; simulation == nil || time.Duration(simulation.Interval) != 10*time.Second || simulation.Max != 18 {
+		t.Errorf("simulation = %+v", simulation)
+	}
+}
+
+func TestSiteGateways(t *testing.T) {
+	site, err := Load("testdata/site.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	device, err := site.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gateways, err := site.Gateways(device)
+	if err != nil {
+		t.Fatalf("Gateways() error = %v", err)
+	}
+	defer gateways[0].Close()
+	if len(gateways) != 1 {
+		t.Fatalf("%d gateways, want 1", len(gateways))
+	}
+	if point := site.Modbus[0].Points[1]; point.WordOrder != "little" || time.Duration(site.Modbus[0].Interval) != 2*time.Second {
+		t.Errorf("modbus config = %+v", site.Modbus[0])
+	}
+	ai, ok := device.FindObject(model.ObjectIdentifier{Type: model.ObjectTypeAnalogInput, Instance: 101}).(*model.Analog)
+	if !ok || ai.GetObjectName() != "Return Air Temp" || ai.Units != model.UnitsDegreesCelsius {
+		t.Errorf("analog-input 101 = %+v", ai)
+	}
+	for _, oid := range []model.ObjectIdentifier{{Type: model.ObjectTypeAnalogValue, Instance: 101}, {Type: model.ObjectTypeBinaryOutput, Instance: 101}} {
+		if p, ok := device.FindObject(oid).(model.ProvidedObject); !ok || p.PropertyProvider(model.PropertyIdentifierPresentValue) == nil {
+			t.Errorf("%v has no write-through provider", oid)
+		}
+	}
+
+	site.Modbus[0].Points[0].Register = "flip-flop"
+	if _, err := site.Gateways(model.NewDevice(1, "x", "")); err == nil || !strings.Contains(err.Error(), "flip-flop") {
+		t.Errorf("unknown register: err = %v", err)
+	}
+}
+
+func TestSiteSimulation(t *testing.T) {
+	site, err := Load("testdata/site.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	device, err := site.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := simulation.NewEngine()
+	if err := site.Simulate(engine, device); err != nil {
+		t.Fatalf("Simulate() error = %v", err)
+	}
+	points := engine.Points()
+	if len(points) != 3 {
+		t.Fatalf("%d simulated points, want 3", len(points))
+	}
+	if p := points[0].Profile; p.Kind != simulation.Random || p.Offset != 15 || p.Amplitude != 3 || p.Interval != 10*time.Second {
+		t.Errorf("analog-input profile = %+v", p)
+	}
+	if p := points[1].Profile; p.Kind != simulation.Square || p.Period != time.Hour || p.Offset != 0.5 {
+		t.Errorf("binary-output profile = %+v", p)
+	}
+	// 回放文件相对于配置文件所在目录
+	if p := points[2].Profile; p.Kind != simulation.Playback || p.File != filepath.Join("testdata", "fan_mode.csv") || len(p.Samples) != 5 || p.Samples[2].At != 12*time.Hour {
+		t.Errorf("multi-state-value profile = %+v", p)
+	}
+
+	if rules := engine.Rules(); len(rules) != 2 || rules[1].Target != (model.ObjectIdentifier{Type: model.ObjectTypeAnalogInput, Instance: 1}) {
+		t.Errorf("rules = %+v", rules)
+	}
+	// 风机运行时送风温度从当前值（随机值12-18）开始跟随设定值
+	engine.Step(time.Now())
+	if rules := engine.Rules(); !rules[0].Active || rules[0].Err != nil || math.Abs(rules[0].Value-15) > 3.2 || rules[1].Active {
+		t.Errorf("rules after step = %+v", rules)
+	}
+
+	for _, tt := range []struct {
+		rule RuleConfig
+		want string
+	}{
+		{RuleConfig{Target: "analog-input:9", Value: "1"}, "analog-input:9"},
+		{RuleConfig{Target: "analog-input:1", Value: "analog-value:1 +"}, "不完整"},
+		{RuleConfig{Target: "analog-input:1", When: "true", Event: "on-fire"}, "on-fire"},
+	} {
+		bad := &Site{Rules: []RuleConfig{tt.rule}}
+		if err := bad.Simulate(simulation.NewEngine(), device); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("rule %+v: err = %v, want containing %q", tt.rule, err, tt.want)
+		}
+	}
+
+	site.Objects[0].Simulation.Kind = "triangle"
+	if _, err := site.Build(); err == nil || !strings.Contains(err.Error(), "triangle") {
+		t.Errorf("unknown kind: err = %v", err)
+	}
+	site.Objects[0].Simulation.Kind = "csv"
+	if err := site.Simulate(simulation.NewEngine(), device); err == nil || !strings.Contains(err.Error(), "file") {
+		t.Errorf("csv without file: err = %v", err)
+	}
+}
+
+func TestEquipmentTemplates(t *testing.T) {
+	for _, name := range PresetNames() {
+		site, err := LoadPreset(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		device, err := site.Build()
+		if err != nil {
+			t.Fatalf("%s: Build() error = %v", name, err)
+		}
+		engine := simulation.NewEngine()
+		if err := site.Simulate(engine, device); err != nil {
+			t.Fatalf("%s: Simulate() error = %v", name, err)
+		}
+		engine.Step(time.Now())
+		for _, r := range engine.Rules() {
+			if r.Err != nil {
+				t.Errorf("%s: rule %s: %v", name, r.Name, r.Err)
+			}
+		}
+	}
+
+	site, err := Parse([]byte(`
+device:
+  name: Test
+objects:
+  - type: analog-input
+    instance: 7
+    name: Outside Air
+equipment:
+  - template: vav
+    name: VAV-1
+    count: 2
+  - template: meter
+`), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, vav, _ := TemplateDescription("vav")
+	_, meter, _ := TemplateDescription("meter")
+	if len(site.Objects) != 1+2*vav+meter {
+		t.Fatalf("%d objects, want %d", len(site.Objects), 1+2*vav+meter)
+	}
+	// 实例号接在已有对象之后，名称带设备序号
+	if o := site.Objects[1]; o.Instance != 8 || o.Name != "VAV-1-1 Zone Temp" {
+		t.Errorf("first point = %s %d %q", o.Type, o.Instance, o.Name)
+	}
+	if o := site.Objects[len(site.Objects)-1]; o.Name != "METER Frequency" {
+		t.Errorf("last point = %q", o.Name)
+	}
+	if _, err := site.Build(); err != nil {
+		t.Errorf("Build() error = %v", err)
+	}
+	// 规则引用本台设备的点位
+	if r := site.Rules[0]; r.Name != "VAV-1-1 airflow" || r.Target != "analog-input:10" || !strings.Contains(r.Value, "analog-output:1 / 100 * analog-value:5") {
+		t.Errorf("rule = %+v", r)
+	}
+
+	if _, err := Parse([]byte("equipment:\n  - template: boiler\n"), "yaml"); err == nil || !strings.Contains(err.Error(), "boiler") {
+		t.Errorf("unknown template: err = %v", err)
+	}
+	if _, err := LoadPreset("campus"); err == nil || !strings.Contains(err.Error(), "office") {
+		t.Errorf("unknown preset: err = %v", err)
+	}
+}
+
+func TestSiteProxy(t *testing.T) {
+	site, err := Parse([]byte(`
+proxy:
+  bind: 127.0.0.1:0
+  interval: 5s
+  devices:
+    - instance: 2001
+      address: 127.0.0.1
+      objects: [analog-input:1, binary-output:2]
+      instance_offset: 1000
+`), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	device, err := site.Proxy.Devices[0].device()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.Address.String() != "127.0.0.1:47808" || len(device.Objects) != 2 || device.Objects[1].Type != model.ObjectTypeBinaryOutput {
+		t.Errorf("proxy device = %+v", device)
+	}
+	p, err := site.NewProxy(model.NewDevice(1, "x", ""), nil)
+	if err != nil || p == nil {
+		t.Fatalf("NewProxy() = %v, %v", p, err)
+	}
+	p.Close()
+
+	site.Proxy.Devices[0].Objects = []string{"schedule:1"}
+	if _, err := site.NewProxy(model.NewDevice(1, "x", ""), nil); err == nil {
+		t.Error("unsupported proxy object accepted")
+	}
+	if p, err := (&Site{}).NewProxy(model.NewDevice(1, "x", ""), nil); p != nil || err != nil {
+		t.Errorf("NewProxy() without proxy = %v, %v", p, err)
+	}
+}
+
+func TestSiteHarvester(t *testing.T) {
+	site, err := Parse([]byte(`
+harvest:
+  interval: 15m
+  sources:
+    - device: 2001
+      address: 127.0.0.1:47809
+      object: trend-log:1
+    - device: 2001
+      object: trend-log-multiple:1
+      name: ahu
+`), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	site.Harvest.Dir = t.TempDir()
+	h, err := site.NewHarvester(nil)
+	if err != nil || h == nil {
+		t.Fatalf("NewHarvester() = %v, %v", h, err)
+	}
+	h.Close()
+	if status := h.Status(); len(status) != 2 || status[0].Name != "2001_trend-log_1" || status[1].Name != "ahu" {
+		t.Errorf("status = %+v", status)
+	}
+
+	site.Harvest.Sources[1].Object = "analog-input:1"
+	if _, err := site.NewHarvester(nil); err == nil || !strings.Contains(err.Error(), "analog-input:1") {
+		t.Errorf("analog input source: err = %v", err)
+	}
+}
+
+func TestLoadScenario(t *testing.T) {
+	scenario, err := LoadScenario("testdata/fan_failure.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scenario.Name != "Supply fan failure" || len(scenario.Steps) != 7 {
+		t.Fatalf("scenario = %+v", scenario)
+	}
+	// after相对上一步，at相对场景开始
+	want := []time.Duration{0, 30 * time.Second, 35 * time.Second, time.Minute, 2 * time.Minute, 2 * time.Minute, 3 * time.Minute}
+	for i, step := range scenario.Steps {
+		if step.At != want[i] {
+			t.Errorf("step %d at %s, want %s", i+1, step.At, want[i])
+		}
+	}
+	if got := scenario.Steps[2].Command; got != `alarm analog-input:1 high-limit "Supply air above setpoint"` {
+		t.Errorf("command = %q", got)
+	}
+
+	for _, tt := range []struct{ name, yaml, want string }{
+		{"no steps", "name: empty\n", "没有步骤"},
+		{"unknown command", "steps:\n  - do: reboot now\n", "reboot"},
+		{"missing do", "steps:\n  - at: 1s\n", "do"},
+		{"at and after", "steps:\n  - at: 1s\n    after: 2s\n    do: comms down\n", "after"},
+		{"out of order", "steps:\n  - at: 1m\n    do: comms down\n  - at: 30s\n    do: comms up\n", "第2步"},
+	} {
+		if _, err := ParseScenario([]byte(tt.yaml), "yaml"); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want containing %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestLoadEDE(t *testing.T) {
+	site, err := Load("testdata/ahu_EDE.csv")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	device, err := site.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if device.GetObjectIdentifier().Instance != 2002 || device.GetObjectName() != "AHU-2 Controller" || len(device.Objects()) != 5 {
+		t.Fatalf("device = %v %q with %d objects", device.GetObjectIdentifier(), device.GetObjectName(), len(device.Objects()))
+	}
+
+	ai := device.FindObject(model.ObjectIdentifier{Type: model.ObjectTypeAnalogInput, Instance: 1}).(*model.Analog)
+	if pv, _ := ai.ReadProperty(model.PropertyIdentifierPresentValue); toFloat(pv) != 14.5 || ai.Units != model.UnitsDegreesCelsius || ai.MaxPresValue != 85 {
+		t.Errorf("AI1 = %v %v max %v", pv, ai.Units, ai.MaxPresValue)
+	}
+	if high, _ := ai.ReadProperty(model.PropertyIdentifierHighLimit); high != float32(30) {
+		t.Errorf("AI1 High_Limit = %v", high)
+	}
+	bo := device.FindObject(model.ObjectIdentifier{Type: model.ObjectTypeBinaryOutput, Instance: 1}).(*model.Binary)
+	if rd, _ := bo.ReadProperty(model.PropertyIdentifierRelinquishDefault); rd != true || bo.InactiveText != "Off" || bo.ActiveText != "On" {
+		t.Errorf("BO1 = %v %q/%q", rd, bo.InactiveText, bo.ActiveText)
+	}
+	mode := device.FindObject(model.ObjectIdentifier{Type: model.ObjectTypeMultiStateValue, Instance: 1}).(*model.MultiState)
+	if !reflect.DeepEqual(mode.StateText, []string{"Auto", "Heat", "Cool"}) {
+		t.Errorf("MSV1 State_Text = %v", mode.StateText)
+	}
+	stage := device.FindObject(model.ObjectIdentifier{Type: model.ObjectTypeMultiStateInput, Instance: 1}).(*model.MultiState)
+	if len(stage.StateText) != 3 {
+		t.Errorf("MSI1 State_Text = %v", stage.StateText)
+	}
+
+	if _, err := ParseEDE(strings.NewReader("1;1;a;0;1\n1;2;b;0;2\n"), nil); err == nil || !strings.Contains(err.Error(), "第2行") {
+		t.Errorf("two devices: err = %v", err)
+	}
+	if _, err := ParseEDE(strings.NewReader("k;1;a;4;1;;;;;;;;;7\n"), nil); err == nil || !strings.Contains(err.Error(), "状态文本7") {
+		t.Errorf("missing state text: err = %v", err)
+	}
+}
+
+func TestExportEDE(t *testing.T) {
+	site, err := Load("testdata/site.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	device, err := site.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "site_EDE.csv")
+	if err := SaveEDE(device, path); err != nil {
+		t.Fatalf("SaveEDE() error = %v", err)
+	}
+	imported, err := LoadEDE(path)
+	if err != nil {
+		t.Fatalf("LoadEDE() error = %v", err)
+	}
+	restored, err := imported.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if restored.GetObjectIdentifier() != device.GetObjectIdentifier() || restored.GetObjectName() != device.GetObjectName() || len(restored.Objects()) != len(device.Objects()) {
+		t.Fatalf("exported device %v %q with %d objects", restored.GetObjectIdentifier(), restored.GetObjectName(), len(restored.Objects()))
+	}
+	for _, obj := range device.Objects() {
+		other := restored.FindObject(obj.GetObjectIdentifier())
+		if other == nil || other.GetObjectName() != obj.GetObjectName() {
+			t.Errorf("%v not exported", obj.GetObjectIdentifier())
+			continue
+		}
+		for _, prop := range []model.PropertyIdentifier{model.PropertyIdentifierPresentValue, model.PropertyIdentifierUnits,
+			model.PropertyIdentifierStateText, model.PropertyIdentifierActiveText, model.PropertyIdentifierHighLimit} {
+			want, _ := obj.ReadProperty(prop)
+			if got, _ := other.ReadProperty(prop); !reflect.DeepEqual(got, want) {
+				t.Errorf("%v property %v = %#v, want %#v", obj.GetObjectIdentifier(), prop, got, want)
+			}
+		}
+	}
+}
+
+func TestParseJSONMatchesYAML(t *testing.T) {
+	yamlSite, err := Load("testdata/site.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(yamlSite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonSite, err := Parse(data, "json")
+	if err != nil {
+		t.Fatalf("Parse(json) error = %v", err)
+	}
+	if !reflect.DeepEqual(yamlSite, jsonSite) {
+		t.Errorf("JSON site differs from YAML site:\n%+v\n%+v", jsonSite, yamlSite)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"unknown field", "device:\n  name: x\n  color: red\n", "color"},
+		{"bad indent", "device:\n  name: x\n   instance: 1\n", "第3行"},
+		{"unknown type", "device:\n  name: x\nobjects:\n  - type: toaster\n    name: t\n", "toaster"},
+		{"unknown units", "device:\n  name: x\nobjects:\n  - type: analog-input\n    name: t\n    units: furlongs\n", "furlongs"},
+		{"missing states", "device:\n  name: x\nobjects:\n  - type: multi-state-input\n    name: t\n", "states"},
+		{"bad value", "device:\n  name: x\nobjects:\n  - type: binary-value\n    name: t\n    present_value: maybe\n", "present_value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site, err := Parse([]byte(tt.yaml), "yaml")
+			if err == nil {
+				_, err = site.Build()
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseYAML(t *testing.T) {
+	got, err := parseYAML([]byte(`
+# comment
+a: 1            # trailing comment
+b: "x # not a comment"
+c: [1, 'two', "th,ree"]
+d:
+- e: true
+  f: ~
+-
+  - nested
+g: Bob's room # comment
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"a": 1.0,
+		"b": "x # not a comment",
+		"c": []any{1.0, "two", "th,ree"},
+		"d": []any{map[string]any{"e": true, "f": nil}, []any{"nested"}},
+		"g": "Bob's room",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseYAML() = %#v\nwant %#v", got, want)
+	}
+}
+
+func toFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case float32:
+		return float64(v)
+	case float64:
+		return v
+	case uint32:
+		return float64(v)
+	}
+	return -1
+}
@@ -0,0 +1,431 @@
+package config
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// EDE（Engineering Data Exchange）数据点表的列，按EDE 2.2版布局
+const (
+	edeKeyName = iota
+	edeDeviceInstance
+	edeObjectName
+	edeObjectType
+	edeObjectInstance
+	edeDescription
+	edePresentValue
+	edeMinPresentValue
+	edeMaxPresentValue
+	edeSettable
+	edeSupportsCOV
+	edeHighLimit
+	edeLowLimit
+	edeStateTextReference
+	edeUnitCode
+	edeVendorAddress
+	edeColumns
+)
+
+// edeHeader 数据点表的列标题
+var edeHeader = []string{"# keyname", "device obj.-instance", "object-name", "object-type", "object-instance",
+	"description", "present-value-default", "min-present-value", "max-present-value", "settable", "supports COV",
+	"hi-limit", "low-limit", "state-text-reference", "unit-code", "vendor-specific-address"}
+
+// LoadEDE 读取EDE数据点表，同目录下的状态文本表（如site_EDE.csv对应site_StateTexts.csv）存在时一并读取
+func LoadEDE(path string) (*Site, error) {
+	points, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var stateTexts io.Reader
+	if data, err := os.ReadFile(StateTextsPath(path)); err == nil {
+		stateTexts = bytes.NewReader(data)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	site, err := ParseEDE(bytes.NewReader(points), stateTexts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return site, nil
+}
+
+// StateTextsPath 返回EDE数据点表对应的状态文本表路径：文件名以_EDE结尾时替换为_StateTexts，否则追加_StateTexts
+func StateTextsPath(path string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	if strings.HasSuffix(strings.ToUpper(base), "_EDE") {
+		base = base[:len(base)-len("_EDE")]
+	}
+	return base + "_StateTexts" + ext
+}
+
+// ParseEDE 解析EDE数据点表和状态文本表（可以为nil）为站点配置。
+// 设备行（对象类型8）给出设备的实例号和名称，没有设备行时设备名称取PROJECT_NAME；
+// 最小/最大值写入模拟量的范围，高/低限值写入内在报警，状态文本写入二进制和多态对象
+func ParseEDE(points io.Reader, stateTexts io.Reader) (*Site, error) {
+	texts := map[string][]string{}
+	if stateTexts != nil {
+		_, rows, err := readEDE(stateTexts)
+		if err != nil {
+			return nil, fmt.Errorf("状态文本表: %w", err)
+		}
+		for _, row := range rows {
+			texts[row.fields[0]] = slices.DeleteFunc(row.fields[1:], func(text string) bool { return text == "" })
+		}
+	}
+
+	header, rows, err := readEDE(points)
+	if err != nil {
+		return nil, err
+	}
+	site := &Site{Device: DeviceConfig{Name: header["PROJECT_NAME"]}}
+	hasDevice := false
+	for _, row := range rows {
+		fields := row.fields
+		if len(fields) < edeColumns {
+			fields = append(fields, make([]string, edeColumns-len(fields))...)
+		}
+		o, err := parseEDEPoint(fields, texts)
+		if err != nil {
+			return nil, fmt.Errorf("第%d行: %w", row.line, err)
+		}
+		device, err := strconv.ParseUint(fields[edeDeviceInstance], 10, 22)
+		if err != nil {
+			return nil, fmt.Errorf("第%d行: 无效的设备实例号: %s", row.line, fields[edeDeviceInstance])
+		}
+		if !hasDevice {
+			site.Device.Instance, hasDevice = uint32(device), true
+		} else if uint32(device) != site.Device.Instance {
+			return nil, fmt.Errorf("第%d行: 数据点属于另一个设备%d，每个文件只能包含一个设备", row.line, device)
+		}
+		if oid, _ := o.Identifier(); oid.Type == model.ObjectTypeDevice {
+			site.Device.Name, site.Device.Description = o.Name, o.Description
+			continue
+		}
+		site.Objects = append(site.Objects, o)
+	}
+	if !hasDevice {
+		return nil, errors.New("没有数据点")
+	}
+	return site, nil
+}
+
+// edeRow 数据行及其行号
+type edeRow struct {
+	line   int
+	fields []string
+}
+
+// readEDE 读取EDE文件，返回文件头（PROJECT_NAME等键值行）和数据行。
+// 以#开头的行为注释，分隔符为分号，整个文件没有分号时为逗号
+func readEDE(r io.Reader) (map[string]string, []edeRow, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = ';'
+	if !bytes.ContainsRune(data, ';') {
+		reader.Comma = ','
+	}
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header := map[string]string{}
+	var rows []edeRow
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			return header, rows, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		if strings.Join(fields, "") == "" {
+			continue
+		}
+		if key := strings.ToUpper(fields[0]); isEDEHeaderKey(key) {
+			if len(fields) > 1 {
+				header[key] = fields[1]
+			}
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		rows = append(rows, edeRow{line: line, fields: fields})
+	}
+}
+
+// isEDEHeaderKey 判断是否为文件头的键
+func isEDEHeaderKey(key string) bool {
+	switch key {
+	case "PROJECT_NAME", "VERSION_OF_REFERENCEFILE", "TIMESTAMP_OF_LAST_CHANGE", "AUTHOR_OF_LAST_CHANGE", "VERSION_OF_LAYOUT":
+		return true
+	}
+	return false
+}
+
+// parseEDEPoint 将数据点行转换为对象配置
+func parseEDEPoint(fields []string, texts map[string][]string) (ObjectConfig, error) {
+	instance, err := strconv.ParseUint(fields[edeObjectInstance], 10, 22)
+	if err != nil {
+		return ObjectConfig{}, fmt.Errorf("无效的对象实例号: %s", fields[edeObjectInstance])
+	}
+	o := ObjectConfig{
+		Type:        Text(fields[edeObjectType]),
+		Instance:    uint32(instance),
+		Name:        fields[edeObjectName],
+		Description: fields[edeDescription],
+	}
+	oid, err := o.Identifier()
+	if err != nil {
+		return ObjectConfig{}, err
+	}
+	if o.Name == "" {
+		o.Name = fields[edeKeyName]
+	}
+
+	number := func(column int) (*float64, error) {
+		text := fields[column]
+		if text == "" {
+			return nil, nil
+		}
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			// 小数点写为逗号
+			if value, err = strconv.ParseFloat(strings.Replace(text, ",", ".", 1), 64); err != nil {
+				return nil, fmt.Errorf("%s: 无效的数值: %s", edeHeader[column], text)
+			}
+		}
+		return &value, nil
+	}
+	var high, low, maximum *float64
+	if o.Min, err = number(edeMinPresentValue); err == nil {
+		if maximum, err = number(edeMaxPresentValue); err == nil {
+			if high, err = number(edeHighLimit); err == nil {
+				low, err = number(edeLowLimit)
+			}
+		}
+	}
+	if err != nil {
+		return ObjectConfig{}, err
+	}
+	if value := fields[edePresentValue]; value != "" {
+		if n, err := number(edePresentValue); err == nil {
+			o.PresentValue = *n
+		} else {
+			o.PresentValue = value
+		}
+	}
+	if fields[edeUnitCode] != "" {
+		o.Units = Text(fields[edeUnitCode])
+	}
+
+	states := texts[fields[edeStateTextReference]]
+	if fields[edeStateTextReference] != "" && states == nil {
+		return ObjectConfig{}, fmt.Errorf("状态文本%s不存在", fields[edeStateTextReference])
+	}
+	switch oid.Type {
+	case model.ObjectTypeAnalogInput, model.ObjectTypeAnalogOutput, model.ObjectTypeAnalogValue:
+		o.Max = maximum
+		if high != nil || low != nil {
+			o.Alarm = &AlarmConfig{HighLimit: high, LowLimit: low}
+		}
+	case model.ObjectTypeBinaryInput, model.ObjectTypeBinaryOutput, model.ObjectTypeBinaryValue:
+		o.Min = nil
+		if len(states) >= 2 {
+			o.InactiveText, o.ActiveText = states[0], states[1]
+		}
+	case model.ObjectTypeMultiStateInput, model.ObjectTypeMultiStateOutput, model.ObjectTypeMultiStateValue:
+		o.Min = nil
+		o.States = states
+		if o.States == nil && maximum != nil && *maximum >= 1 && *maximum <= 255 {
+			// 没有状态文本时按状态数生成
+			for i := 1; i <= int(*maximum); i++ {
+				o.States = append(o.States, fmt.Sprintf("State %d", i))
+			}
+		}
+	default:
+		o.Min = nil
+	}
+	return o, nil
+}
+
+// SaveEDE 将设备的对象导出为EDE数据点表path，二进制和多态对象的状态文本写入StateTextsPath(path)
+func SaveEDE(device *model.Device, path string) error {
+	var points, stateTexts bytes.Buffer
+	if err := ExportEDE(device, &points, &stateTexts); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, points.Bytes(), 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(StateTextsPath(path), stateTexts.Bytes(), 0o644)
+}
+
+// ExportEDE 将设备及其对象写为EDE数据点表和状态文本表，相同的状态文本共用一个编号
+func ExportEDE(device *model.Device, points, stateTexts io.Writer) error {
+	name := device.GetObjectName()
+	instance := strconv.FormatUint(uint64(device.GetObjectIdentifier().Instance), 10)
+	fileHeader := [][]string{
+		{"PROJECT_NAME", name},
+		{"VERSION_OF_REFERENCEFILE", "1"},
+		{"TIMESTAMP_OF_LAST_CHANGE", device.Clock().Now().Format("2006-01-02")},
+		{"AUTHOR_OF_LAST_CHANGE", "bacnet-server"},
+		{"VERSION_OF_LAYOUT", "2.2"},
+	}
+
+	var texts [][]string
+	reference := func(states []string) string {
+		if len(states) == 0 {
+			return ""
+		}
+		for i, existing := range texts {
+			if slices.Equal(existing, states) {
+				return strconv.Itoa(i + 1)
+			}
+		}
+		texts = append(texts, states)
+		return strconv.Itoa(len(texts))
+	}
+
+	w := csv.NewWriter(points)
+	w.Comma = ';'
+	w.WriteAll(fileHeader)
+	mandatory := make([]string, edeColumns)
+	for i := range mandatory {
+		mandatory[i] = "optional"
+		if i <= edeObjectInstance {
+			mandatory[i] = "mandatory"
+		}
+	}
+	mandatory[0] = "#" + mandatory[0]
+	w.Write(mandatory)
+	w.Write(edeHeader)
+	for _, obj := range append([]model.Object{device}, device.Objects()...) {
+		w.Write(edePoint(obj, instance, reference))
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	w = csv.NewWriter(stateTexts)
+	w.Comma = ';'
+	w.WriteAll(fileHeader)
+	w.Write([]string{"#Reference Number", "Text 1 or Inactive-Text", "Text 2 or Active-Text", "Text 3 ..."})
+	for i, states := range texts {
+		w.Write(append([]string{strconv.Itoa(i + 1)}, states...))
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// edePoint 返回对象的数据点行
+func edePoint(obj model.Object, device string, reference func([]string) string) []string {
+	oid := obj.GetObjectIdentifier()
+	fields := make([]string, edeColumns)
+	fields[edeKeyName] = fmt.Sprintf("%s:%d", oid.Type, oid.Instance)
+	fields[edeDeviceInstance] = device
+	fields[edeObjectName] = obj.GetObjectName()
+	fields[edeObjectType] = strconv.Itoa(int(oid.Type))
+	fields[edeObjectInstance] = strconv.FormatUint(uint64(oid.Instance), 10)
+	if description, _ := obj.ReadProperty(model.PropertyIdentifierDescription); description != nil {
+		fields[edeDescription], _ = description.(string)
+	}
+
+	props := model.ExpandPropertyReference(obj, model.PropertyIdentifierAll)
+	if slices.Contains(props, model.PropertyIdentifierPresentValue) {
+		prop := model.PropertyIdentifierPresentValue
+		commandable := false
+		if c, ok := obj.(model.CommandableObject); ok && c.Commandable(prop) {
+			prop, commandable = model.PropertyIdentifierRelinquishDefault, true
+		}
+		value, _ := obj.ReadProperty(prop)
+		fields[edePresentValue] = edeValue(value)
+		meta, _ := model.LookupPropertyMetadata(oid.Type, model.PropertyIdentifierPresentValue)
+		fields[edeSettable] = edeFlag(commandable || meta.Conformance == model.ConformanceWritable)
+		fields[edeSupportsCOV] = "Y"
+	}
+	switch o := obj.(type) {
+	case *model.Analog:
+		if !math.IsInf(o.MinPresValue, 0) {
+			fields[edeMinPresentValue] = edeValue(o.MinPresValue)
+		}
+		if !math.IsInf(o.MaxPresValue, 0) {
+			fields[edeMaxPresentValue] = edeValue(o.MaxPresValue)
+		}
+		fields[edeUnitCode] = strconv.Itoa(int(o.Units))
+		if limits, _ := o.ReadProperty(model.PropertyIdentifierLimitEnable); limits != nil {
+			if enable, ok := limits.(model.BitString); ok {
+				if enable.Bit(1) {
+					high, _ := o.ReadProperty(model.PropertyIdentifierHighLimit)
+					fields[edeHighLimit] = edeValue(high)
+				}
+				if enable.Bit(0) {
+					low, _ := o.ReadProperty(model.PropertyIdentifierLowLimit)
+					fields[edeLowLimit] = edeValue(low)
+				}
+			}
+		}
+	case *model.Binary:
+		if o.InactiveText != "" || o.ActiveText != "" {
+			fields[edeStateTextReference] = reference([]string{o.InactiveText, o.ActiveText})
+		}
+	case *model.MultiState:
+		fields[edeMinPresentValue] = "1"
+		fields[edeMaxPresentValue] = strconv.Itoa(len(o.StateText))
+		fields[edeStateTextReference] = reference(o.StateText)
+	}
+	return fields
+}
+
+// edeValue 将属性值写为文本：布尔值写为0或1，枚举写为编号
+func edeValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return v
+	}
+	// 枚举类型实现了String，按编号输出
+	if rv := reflect.ValueOf(value); rv.CanUint() {
+		return strconv.FormatUint(rv.Uint(), 10)
+	} else if rv.CanInt() {
+		return strconv.FormatInt(rv.Int(), 10)
+	}
+	return fmt.Sprint(value)
+}
+
+func edeFlag(b bool) string {
+	if b {
+		return "Y"
+	}
+	return "N"
+}
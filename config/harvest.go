@@ -0,0 +1,70 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/iotzf/bacnet-server/harvest"
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// HarvestConfig 趋势采集：按周期用ReadRange读取远程设备的趋势日志，记录保存为CSV文件
+type HarvestConfig struct {
+	Bind      string                `json:"bind"`       // 客户端的本地地址，为空时为":0"
+	ReusePort bool                  `json:"reuse_port"` // 以SO_REUSEPORT与服务端共享端口
+	Interval  Duration              `json:"interval"`   // 采集周期，为0时为5分钟
+	Timeout   Duration              `json:"timeout"`    // 等待应答的时间，为0时为3秒
+	Batch     int                   `json:"batch"`      // 每个ReadRange请求的最大记录数，为0时为50
+	Dir       string                `json:"dir"`        // 保存CSV文件的目录，为空时为trends
+	Sources   []HarvestSourceConfig `json:"sources"`
+}
+
+// HarvestSourceConfig 一个被采集的日志对象
+type HarvestSourceConfig struct {
+	Name    string `json:"name"`    // CSV文件名和状态中的名称，为空时按设备和对象生成
+	Device  uint32 `json:"device"`  // 远程设备的实例号
+	Address string `json:"address"` // ip[:port]，为空时用Who-Is发现
+	Object  string `json:"object"`  // 如trend-log:1
+}
+
+// NewHarvester 按配置创建趋势采集器，没有配置采集时返回nil。采集器需要调用Run开始采集
+func (s *Site) NewHarvester(logger *slog.Logger) (*harvest.Harvester, error) {
+	if s.Harvest == nil || len(s.Harvest.Sources) == 0 {
+		return nil, nil
+	}
+	sources := make([]harvest.Source, len(s.Harvest.Sources))
+	for i, c := range s.Harvest.Sources {
+		source := harvest.Source{Name: c.Name, Device: c.Device}
+		var err error
+		if source.Object, err = model.ParseObjectIdentifier(c.Object); err == nil && c.Address != "" {
+			source.Address, err = resolveDevice(c.Address)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("采集源%d（%s）: %w", i+1, c.Object, err)
+		}
+		sources[i] = source
+	}
+	dir := s.Harvest.Dir
+	if dir == "" {
+		dir = "trends"
+	}
+	sink, err := harvest.NewCSVSink(dir)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newClient(s.Harvest.Bind, s.Harvest.ReusePort, s.Harvest.Timeout, logger)
+	if err != nil {
+		return nil, fmt.Errorf("采集客户端: %w", err)
+	}
+	h := harvest.New(c, sink, time.Duration(s.Harvest.Interval))
+	h.Logger = logger
+	h.Batch = s.Harvest.Batch
+	for i, source := range sources {
+		if err := h.AddSource(source); err != nil {
+			h.Close()
+			return nil, fmt.Errorf("采集源%d（%s）: %w", i+1, s.Harvest.Sources[i].Object, err)
+		}
+	}
+	return h, nil
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/iotzf/bacnet-server/modbus"
+	"github.com/iotzf/bacnet-server/model"
+	"github.com/iotzf/bacnet-server/mstp"
+)
+
+// ModbusConfig 一个Modbus网关：轮询一条Modbus TCP连接或RTU串口上的数据点
+type ModbusConfig struct {
+	Address  string              `json:"address"`  // host:port为Modbus TCP，rtu:/dev/ttyUSB0为Modbus RTU
+	Baud     int                 `json:"baud"`     // RTU波特率，为0时为9600
+	Interval Duration            `json:"interval"` // 轮询周期，为0时为1秒
+	Timeout  Duration            `json:"timeout"`  // 等待应答的时间，为0时为1秒
+	Points   []ModbusPointConfig `json:"points"`
+}
+
+// ModbusPointConfig 一个映射为BACnet对象的Modbus数据点
+type ModbusPointConfig struct {
+	Type      Text    `json:"type"` // analog-input/output/value或binary-input/output/value
+	Instance  uint32  `json:"instance"`
+	Name      string  `json:"name"`
+	Units     Text    `json:"units"`
+	Unit      uint8   `json:"unit"`       // 从站地址，为0时为1
+	Register  string  `json:"register"`   // coil、discrete-input、input-register或holding-register（默认）
+	Address   uint16  `json:"address"`    // 从0开始的地址
+	DataType  string  `json:"datatype"`   // uint16（默认）、int16、uint32、int32或float32
+	WordOrder string  `json:"word_order"` // 32位数值的字序：big（默认，高字在前）或little
+	Scale     float64 `json:"scale"`      // 工程值 = 原始值 * scale + offset
+	Offset    float64 `json:"offset"`
+}
+
+// Gateways 按配置创建Modbus网关并将数据点对象加入设备，网关需要调用Run开始轮询
+func (s *Site) Gateways(device *model.Device) ([]*modbus.Gateway, error) {
+	var gateways []*modbus.Gateway
+	for i, c := range s.Modbus {
+		gateway, err := c.gateway(device)
+		if err != nil {
+			for _, g := range gateways {
+				g.Close()
+			}
+			return nil, fmt.Errorf("Modbus网关%d（%s）: %w", i+1, c.Address, err)
+		}
+		gateways = append(gateways, gateway)
+	}
+	return gateways, nil
+}
+
+// gateway 打开连接并创建网关
+func (c ModbusConfig) gateway(device *model.Device) (*modbus.Gateway, error) {
+	points := make([]modbus.Point, len(c.Points))
+	for i, p := range c.Points {
+		point, err := p.point()
+		if err != nil {
+			return nil, fmt.Errorf("数据点%d（%s）: %w", i+1, p.Name, err)
+		}
+		points[i] = point
+	}
+
+	var client *modbus.Client
+	if name, ok := strings.CutPrefix(c.Address, "rtu:"); ok {
+		baud := c.Baud
+		if baud == 0 {
+			baud = 9600
+		}
+		port, err := mstp.OpenSerial(name, baud)
+		if err != nil {
+			return nil, err
+		}
+		client = modbus.NewRTUClient(port, time.Duration(c.Timeout))
+	} else if c.Address != "" {
+		client = modbus.NewTCPClient(c.Address, time.Duration(c.Timeout))
+	} else {
+		return nil, fmt.Errorf("未配置地址")
+	}
+
+	gateway := modbus.NewGateway(client, time.Duration(c.Interval))
+	for i, p := range points {
+		if _, err := gateway.AddPoint(device, p); err != nil {
+			gateway.Close()
+			return nil, fmt.Errorf("数据点%d（%s）: %w", i+1, p.Name, err)
+		}
+	}
+	return gateway, nil
+}
+
+// point 转换为网关数据点
+func (p ModbusPointConfig) point() (modbus.Point, error) {
+	objType, err := model.ParseObjectType(string(p.Type))
+	if err != nil {
+		return modbus.Point{}, err
+	}
+	point := modbus.Point{
+		ObjectType: objType,
+		Instance:   p.Instance,
+		Name:       p.Name,
+		Units:      model.UnitsNoUnits,
+		Unit:       p.Unit,
+		Table:      modbus.HoldingRegisters,
+		Address:    p.Address,
+		Scale:      p.Scale,
+		Offset:     p.Offset,
+	}
+	if point.Unit == 0 {
+		point.Unit = 1
+	}
+	if p.Units != "" {
+		if point.Units, err = model.ParseEngineeringUnits(string(p.Units)); err != nil {
+			return modbus.Point{}, err
+		}
+	}
+	if p.Register != "" {
+		if point.Table, err = modbus.ParseTable(p.Register); err != nil {
+			return modbus.Point{}, err
+		}
+	}
+	if p.DataType != "" {
+		if point.DataType, err = modbus.ParseDataType(p.DataType); err != nil {
+			return modbus.Point{}, err
+		}
+	}
+	switch p.WordOrder {
+	case "", "big":
+	case "little":
+		point.WordSwap = true
+	default:
+		return modbus.Point{}, fmt.Errorf("未知的字序: %q", p.WordOrder)
+	}
+	return point, nil
+}
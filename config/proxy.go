@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/iotzf/bacnet-server/client"
+	"github.com/iotzf/bacnet-server/model"
+	"github.com/iotzf/bacnet-server/protocol"
+	"github.com/iotzf/bacnet-server/proxy"
+)
+
+// ProxyConfig 网关/代理：把下游BACnet设备的对象镜像为本设备的代理对象
+type ProxyConfig struct {
+	Bind      string              `json:"bind"`       // 客户端的本地地址，为空时为":0"；用Who-Is发现设备时需要绑定47808端口
+	ReusePort bool                `json:"reuse_port"` // 以SO_REUSEPORT与服务端共享端口
+	Interval  Duration            `json:"interval"`   // 刷新周期，为0时为10秒
+	Timeout   Duration            `json:"timeout"`    // 等待应答的时间，为0时为3秒
+	Devices   []ProxyDeviceConfig `json:"devices"`
+}
+
+// ProxyDeviceConfig 一个下游设备
+type ProxyDeviceConfig struct {
+	Instance       uint32   `json:"instance"`
+	Address        string   `json:"address"`         // ip[:port]，为空时用Who-Is发现
+	Objects        []string `json:"objects"`         // 镜像的对象（如analog-input:1），为空时镜像全部模拟量和二进制对象
+	InstanceOffset uint32   `json:"instance_offset"` // 代理对象的实例号 = 下游对象的实例号 + instance_offset
+	Prefix         string   `json:"prefix"`          // 代理对象名称的前缀，为空时为"<设备实例号>."
+}
+
+// NewProxy 按配置创建代理，代理对象加入objects；没有配置代理时返回nil。代理需要调用Run开始镜像
+func (s *Site) NewProxy(objects proxy.Objects, logger *slog.Logger) (*proxy.Proxy, error) {
+	if s.Proxy == nil || len(s.Proxy.Devices) == 0 {
+		return nil, nil
+	}
+	devices := make([]proxy.Device, len(s.Proxy.Devices))
+	for i, d := range s.Proxy.Devices {
+		device, err := d.device()
+		if err != nil {
+			return nil, fmt.Errorf("代理设备%d（%d）: %w", i+1, d.Instance, err)
+		}
+		devices[i] = device
+	}
+	c, err := newClient(s.Proxy.Bind, s.Proxy.ReusePort, s.Proxy.Timeout, logger)
+	if err != nil {
+		return nil, fmt.Errorf("代理客户端: %w", err)
+	}
+	p := proxy.New(c, objects, time.Duration(s.Proxy.Interval))
+	p.Logger = logger
+	for i, d := range devices {
+		if err := p.AddDevice(d); err != nil {
+			p.Close()
+			return nil, fmt.Errorf("代理设备%d（%d）: %w", i+1, d.Instance, err)
+		}
+	}
+	return p, nil
+}
+
+// device 转换为代理的下游设备
+func (d ProxyDeviceConfig) device() (proxy.Device, error) {
+	device := proxy.Device{Instance: d.Instance, InstanceOffset: d.InstanceOffset, NamePrefix: d.Prefix}
+	if d.Address != "" {
+		addr, err := resolveDevice(d.Address)
+		if err != nil {
+			return proxy.Device{}, err
+		}
+		device.Address = addr
+	}
+	for _, text := range d.Objects {
+		oid, err := model.ParseObjectIdentifier(text)
+		if err != nil {
+			return proxy.Device{}, err
+		}
+		device.Objects = append(device.Objects, oid)
+	}
+	return device, nil
+}
+
+// newClient 创建代理和趋势采集使用的BACnet客户端
+func newClient(bind string, reusePort bool, timeout Duration, logger *slog.Logger) (*client.Client, error) {
+	return client.New(client.Options{
+		Address:   bind,
+		ReusePort: reusePort,
+		Timeout:   time.Duration(timeout),
+		Logger:    logger,
+	})
+}
+
+// resolveDevice 解析ip[:port]形式的设备地址，默认端口为47808
+func resolveDevice(address string) (net.Addr, error) {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, strconv.Itoa(protocol.DefaultPort))
+	}
+	return net.ResolveUDPAddr("udp4", address)
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/iotzf/bacnet-server/protocol"
+)
+
+// ScenarioConfig 场景文件：按时间执行的测试步骤，每步是一条控制台命令
+type ScenarioConfig struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Steps       []StepConfig `json:"steps"`
+}
+
+// StepConfig 场景的一步，时间写为at（相对场景开始）或after（相对上一步，默认0）
+type StepConfig struct {
+	At    *Duration `json:"at"`
+	After Duration  `json:"after"`
+	Do    string    `json:"do"` // 控制台命令，如drive analog-input:1 35或comms down
+}
+
+// LoadScenario 读取场景文件，扩展名为.json时按JSON解析，否则按YAML解析
+func LoadScenario(path string) (*protocol.Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	format := "yaml"
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		format = "json"
+	}
+	scenario, err := ParseScenario(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if scenario.Name == "" {
+		scenario.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return scenario, nil
+}
+
+// ParseScenario 解析format（yaml或json）格式的场景，换算各步的时间并检查命令
+func ParseScenario(data []byte, format string) (*protocol.Scenario, error) {
+	var c ScenarioConfig
+	if err := decode(data, format, &c); err != nil {
+		return nil, err
+	}
+	scenario := &protocol.Scenario{Name: c.Name}
+	var at time.Duration
+	for i, step := range c.Steps {
+		if step.At != nil && step.After != 0 {
+			return nil, fmt.Errorf("第%d步: at和after只能给出一个", i+1)
+		}
+		if step.Do == "" {
+			return nil, fmt.Errorf("第%d步: 缺少do", i+1)
+		}
+		if step.At != nil {
+			at = time.Duration(*step.At)
+		} else {
+			at += time.Duration(step.After)
+		}
+		scenario.Steps = append(scenario.Steps, protocol.ScenarioStep{At: at, Command: step.Do})
+	}
+	if err := scenario.Validate(); err != nil {
+		return nil, err
+	}
+	return scenario, nil
+}
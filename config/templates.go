@@ -0,0 +1,327 @@
+package config
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// EquipmentConfig 按模板创建的一组设备的点位，Parse时展开为对象和行为规则
+type EquipmentConfig struct {
+	Template string `json:"template"` // ahu、vav、chiller或meter
+	Name     string `json:"name"`     // 对象名称的前缀，为空时为模板名称的大写；count大于1时依次加上-1、-2等序号
+	Count    int    `json:"count"`    // 台数，为0时为1
+}
+
+// point 模板中的一个点位，名称加上设备名称前缀成为对象名称
+type point struct {
+	typ    string
+	name   string
+	units  string
+	value  any
+	low    float64 // 模拟范围的下限，sim为空时不使用
+	high   float64
+	sim    string       // 模拟方式，为空时由规则驱动或保持初始值
+	period Duration     // 正弦和锯齿的周期
+	alarm  *AlarmConfig // 告警限值
+	texts  []string     // 二进制对象的Active_Text和Inactive_Text，或多态对象的State_Text
+}
+
+// template 设备模板：点位和描述点位关系的规则。规则中的{点位名称}在展开时替换为对象标识符
+type template struct {
+	description string
+	points      []point
+	rules       []RuleConfig
+}
+
+// limits 返回上下限告警，使用通知类1
+func limits(low, high float64) *AlarmConfig {
+	return &AlarmConfig{LowLimit: &low, HighLimit: &high, Deadband: (high - low) / 50, TimeDelay: 30, NotificationClass: 1}
+}
+
+// highLimit 返回只有上限的告警
+func highLimit(high float64) *AlarmConfig {
+	return &AlarmConfig{HighLimit: &high, Deadband: high / 50, TimeDelay: 30, NotificationClass: 1}
+}
+
+const day = Duration(24 * 60 * 60 * 1e9)
+
+var templates = map[string]template{
+	"ahu": {
+		description: "air handling unit with supply and return fans, coils, dampers and air quality",
+		points: []point{
+			{typ: "analog-input", name: "Supply Air Temp", units: "degrees-celsius", value: 13, alarm: limits(5, 30)},
+			{typ: "analog-input", name: "Return Air Temp", units: "degrees-celsius", value: 23, low: 21.5, high: 24.5, sim: "random-walk"},
+			{typ: "analog-input", name: "Mixed Air Temp", units: "degrees-celsius", value: 19},
+			{typ: "analog-input", name: "Outside Air Temp", units: "degrees-celsius", value: 15, low: 8, high: 22, sim: "sine", period: day},
+			{typ: "analog-input", name: "Supply Air Humidity", units: "percent-relative-humidity", value: 50, low: 40, high: 60, sim: "random-walk"},
+			{typ: "analog-input", name: "Return Air Humidity", units: "percent-relative-humidity", value: 45, low: 35, high: 55, sim: "random-walk"},
+			{typ: "analog-input", name: "Duct Static Pressure", units: "pascals", value: 400, alarm: highLimit(600)},
+			{typ: "analog-input", name: "Supply Airflow", units: "cubic-meters-per-hour", value: 9000},
+			{typ: "analog-input", name: "Filter Differential Pressure", units: "pascals", value: 120, low: 80, high: 300, sim: "ramp", period: day, alarm: highLimit(250)},
+			{typ: "analog-input", name: "Return Air CO2", units: "parts-per-million", value: 600, low: 450, high: 900, sim: "random-walk", alarm: highLimit(1200)},
+			{typ: "analog-input", name: "Supply Fan Speed", units: "percent", value: 75},
+			{typ: "analog-output", name: "Cooling Valve", units: "percent", value: 40},
+			{typ: "analog-output", name: "Heating Valve", units: "percent", value: 0},
+			{typ: "analog-output", name: "Outside Air Damper", units: "percent", value: 30},
+			{typ: "analog-output", name: "Supply Fan Speed Command", units: "percent", value: 75},
+			{typ: "analog-output", name: "Return Fan Speed Command", units: "percent", value: 70},
+			{typ: "binary-input", name: "Supply Fan Status", value: true, texts: []string{"Running", "Stopped"}},
+			{typ: "binary-input", name: "Return Fan Status", value: true, texts: []string{"Running", "Stopped"}},
+			{typ: "binary-input", name: "Filter Alarm", value: false, texts: []string{"Dirty", "Clean"}},
+			{typ: "binary-input", name: "Freeze Stat", value: false, texts: []string{"Tripped", "Normal"}},
+			{typ: "binary-input", name: "Smoke Detector", value: false, texts: []string{"Alarm", "Normal"}},
+			{typ: "binary-output", name: "Supply Fan Command", value: true, texts: []string{"On", "Off"}},
+			{typ: "binary-output", name: "Return Fan Command", value: true, texts: []string{"On", "Off"}},
+			{typ: "analog-value", name: "Supply Air Temp Setpoint", units: "degrees-celsius", value: 13},
+			{typ: "analog-value", name: "Duct Static Pressure Setpoint", units: "pascals", value: 400},
+			{typ: "analog-value", name: "Minimum Outside Air Damper", units: "percent", value: 20},
+			{typ: "analog-value", name: "Return Air CO2 Setpoint", units: "parts-per-million", value: 1000},
+			{typ: "multi-state-value", name: "Operating Mode", value: 2, texts: []string{"Off", "Occupied", "Unoccupied", "Warm-Up", "Cool-Down"}},
+		},
+		rules: []RuleConfig{
+			{Name: "supply fan proof", Target: "{Supply Fan Status}", Value: "delay({Supply Fan Command}, 10s)"},
+			{Name: "return fan proof", Target: "{Return Fan Status}", Value: "delay({Return Fan Command}, 10s)"},
+			{Name: "supply fan speed", Target: "{Supply Fan Speed}", Value: "lag({Supply Fan Speed Command} * {Supply Fan Status}, 20s)"},
+			{Name: "duct static", Target: "{Duct Static Pressure}", Value: "{Supply Fan Speed} / 100 * 550 + random(-8, 8)"},
+			{Name: "supply airflow", Target: "{Supply Airflow}", Value: "{Supply Fan Speed} / 100 * 12000 + random(-150, 150)"},
+			{Name: "mixed air", Target: "{Mixed Air Temp}", Value: "{Outside Air Temp} * {Outside Air Damper} / 100 + {Return Air Temp} * (1 - {Outside Air Damper} / 100)"},
+			{Name: "supply air running", Target: "{Supply Air Temp}", When: "{Supply Fan Status}", Value: "lag({Supply Air Temp Setpoint}, 5m) + random(-0.2, 0.2)"},
+			{Name: "supply air stopped", Target: "{Supply Air Temp}", When: "!{Supply Fan Status}", Value: "lag({Mixed Air Temp}, 15m)"},
+			{Name: "filter alarm", Target: "{Filter Alarm}", Value: "{Filter Differential Pressure} > 250"},
+		},
+	},
+	"vav": {
+		description: "variable air volume box with reheat",
+		points: []point{
+			{typ: "analog-input", name: "Zone Temp", units: "degrees-celsius", value: 22.5, alarm: limits(16, 28)},
+			{typ: "analog-input", name: "Discharge Air Temp", units: "degrees-celsius", value: 14},
+			{typ: "analog-input", name: "Airflow", units: "liters-per-second", value: 200},
+			{typ: "analog-input", name: "Zone CO2", units: "parts-per-million", value: 600, low: 450, high: 1000, sim: "random-walk", alarm: highLimit(1200)},
+			{typ: "analog-input", name: "Zone Humidity", units: "percent-relative-humidity", value: 45, low: 35, high: 55, sim: "random-walk"},
+			{typ: "analog-output", name: "Damper Position", units: "percent", value: 50},
+			{typ: "analog-output", name: "Reheat Valve", units: "percent", value: 0},
+			{typ: "analog-value", name: "Zone Cooling Setpoint", units: "degrees-celsius", value: 24},
+			{typ: "analog-value", name: "Zone Heating Setpoint", units: "degrees-celsius", value: 20},
+			{typ: "analog-value", name: "Airflow Setpoint", units: "liters-per-second", value: 200},
+			{typ: "analog-value", name: "Minimum Airflow", units: "liters-per-second", value: 80},
+			{typ: "analog-value", name: "Maximum Airflow", units: "liters-per-second", value: 400},
+			{typ: "binary-input", name: "Occupancy", value: true, sim: "random", texts: []string{"Occupied", "Unoccupied"}},
+			{typ: "multi-state-value", name: "Occupancy Mode", value: 1, texts: []string{"Occupied", "Unoccupied", "Standby"}},
+		},
+		rules: []RuleConfig{
+			{Name: "airflow", Target: "{Airflow}", Value: "lag({Damper Position} / 100 * {Maximum Airflow}, 30s) + random(-4, 4)"},
+			{Name: "discharge air", Target: "{Discharge Air Temp}", Value: "lag(13 + {Reheat Valve} / 100 * 22, 2m)"},
+			{Name: "zone temp", Target: "{Zone Temp}", Value: "lag(({Zone Cooling Setpoint} + {Zone Heating Setpoint}) / 2 + 1.5 * {Occupancy} - {Airflow} / {Maximum Airflow}, 20m) + random(-0.05, 0.05)"},
+		},
+	},
+	"chiller": {
+		description: "water-cooled chiller with chilled and condenser water loops",
+		points: []point{
+			{typ: "analog-input", name: "Chilled Water Supply Temp", units: "degrees-celsius", value: 7, alarm: limits(3, 12)},
+			{typ: "analog-input", name: "Chilled Water Return Temp", units: "degrees-celsius", value: 12},
+			{typ: "analog-input", name: "Condenser Water Supply Temp", units: "degrees-celsius", value: 28, low: 26, high: 30, sim: "sine", period: day},
+			{typ: "analog-input", name: "Condenser Water Return Temp", units: "degrees-celsius", value: 32},
+			{typ: "analog-input", name: "Chilled Water Flow", units: "liters-per-second", value: 35, low: 30, high: 40, sim: "random-walk"},
+			{typ: "analog-input", name: "Chiller Load", units: "percent", value: 60},
+			{typ: "analog-input", name: "Chiller Power", units: "kilowatts", value: 210},
+			{typ: "analog-input", name: "Evaporator Pressure", units: "kilopascals", value: 345, low: 330, high: 360, sim: "random-walk"},
+			{typ: "analog-input", name: "Condenser Pressure", units: "kilopascals", value: 900, low: 850, high: 950, sim: "random-walk"},
+			{typ: "binary-input", name: "Run Status", value: true, texts: []string{"Running", "Stopped"}},
+			{typ: "binary-input", name: "Chiller Alarm", value: false, texts: []string{"Alarm", "Normal"}},
+			{typ: "binary-input", name: "Flow Switch", value: true, texts: []string{"Flow", "No Flow"}},
+			{typ: "binary-output", name: "Chiller Enable", value: true, texts: []string{"Enabled", "Disabled"}},
+			{typ: "analog-value", name: "Chilled Water Supply Setpoint", units: "degrees-celsius", value: 7},
+			{typ: "analog-value", name: "Demand Limit", units: "percent", value: 100},
+			{typ: "analog-value", name: "Run Hours", units: "hours", value: 12500},
+		},
+		rules: []RuleConfig{
+			{Name: "run status", Target: "{Run Status}", Value: "delay({Chiller Enable}, 30s)"},
+			{Name: "load", Target: "{Chiller Load}", Value: "clamp(({Condenser Water Supply Temp} - 24) * 15 + random(-2, 2), 0, {Demand Limit}) * {Run Status}"},
+			{Name: "power", Target: "{Chiller Power}", Value: "{Chiller Load} / 100 * 350 + random(-3, 3) * {Run Status}"},
+			{Name: "supply running", Target: "{Chilled Water Supply Temp}", When: "{Run Status}", Value: "lag({Chilled Water Supply Setpoint}, 10m) + random(-0.1, 0.1)"},
+			{Name: "supply stopped", Target: "{Chilled Water Supply Temp}", When: "!{Run Status}", Value: "lag(14, 30m)"},
+			{Name: "return", Target: "{Chilled Water Return Temp}", Value: "{Chilled Water Supply Temp} + {Chiller Load} / 100 * 6"},
+			{Name: "condenser return", Target: "{Condenser Water Return Temp}", Value: "{Condenser Water Supply Temp} + {Chiller Load} / 100 * 5"},
+			{Name: "flow switch", Target: "{Flow Switch}", Value: "{Chilled Water Flow} > 5"},
+			{Name: "run hours", Target: "{Run Hours}", Value: "self + {Run Status} * 5 / 3600"},
+		},
+	},
+	"meter": {
+		description: "three-phase electricity meter",
+		points: []point{
+			{typ: "analog-input", name: "Active Power", units: "kilowatts", value: 80, low: 40, high: 120, sim: "sine", period: day},
+			{typ: "analog-input", name: "Reactive Power", units: "kilovolt-amperes-reactive", value: 26},
+			{typ: "analog-input", name: "Power Factor", units: "power-factor", value: 0.95, low: 0.92, high: 0.98, sim: "random-walk"},
+			{typ: "analog-input", name: "Energy", units: "kilowatt-hours", value: 125000},
+			{typ: "analog-input", name: "Voltage L1-N", units: "volts", value: 230, low: 225, high: 235, sim: "random-walk", alarm: limits(207, 253)},
+			{typ: "analog-input", name: "Voltage L2-N", units: "volts", value: 230, low: 225, high: 235, sim: "random-walk", alarm: limits(207, 253)},
+			{typ: "analog-input", name: "Voltage L3-N", units: "volts", value: 230, low: 225, high: 235, sim: "random-walk", alarm: limits(207, 253)},
+			{typ: "analog-input", name: "Current L1", units: "amperes", value: 120},
+			{typ: "analog-input", name: "Current L2", units: "amperes", value: 120},
+			{typ: "analog-input", name: "Current L3", units: "amperes", value: 120},
+			{typ: "analog-input", name: "Frequency", units: "hertz", value: 50, low: 49.95, high: 50.05, sim: "random-walk"},
+		},
+		rules: []RuleConfig{
+			{Name: "reactive power", Target: "{Reactive Power}", Value: "{Active Power} * 0.33"},
+			{Name: "energy", Target: "{Energy}", Value: "self + {Active Power} * 5 / 3600"},
+			{Name: "current L1", Target: "{Current L1}", Value: "{Active Power} * 1000 / 3 / {Voltage L1-N} / {Power Factor} + random(-1, 1)"},
+			{Name: "current L2", Target: "{Current L2}", Value: "{Active Power} * 1000 / 3 / {Voltage L2-N} / {Power Factor} + random(-1, 1)"},
+			{Name: "current L3", Target: "{Current L3}", Value: "{Active Power} * 1000 / 3 / {Voltage L3-N} / {Power Factor} + random(-1, 1)"},
+		},
+	},
+}
+
+// templateRuleInterval 模板规则的求值周期
+const templateRuleInterval = Duration(5 * 1e9)
+
+// TemplateNames 返回可用的设备模板名称
+func TemplateNames() []string {
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// TemplateDescription 返回模板的说明和点位数
+func TemplateDescription(name string) (string, int, bool) {
+	t, ok := templates[name]
+	return t.description, len(t.points), ok
+}
+
+// AddEquipment 按模板为一台名为name的设备加入对象和行为规则。对象名称为“name 点位名称”，
+// 实例号接在站点中同类型对象的最大实例号之后
+func (s *Site) AddEquipment(templateName, name string) error {
+	t, ok := templates[templateName]
+	if !ok {
+		return fmt.Errorf("未知的设备模板: %s，可用的模板: %s", templateName, strings.Join(TemplateNames(), "、"))
+	}
+	next := make(map[string]uint32)
+	for _, o := range s.Objects {
+		oid, err := o.Identifier()
+		if err != nil {
+			continue
+		}
+		key := oid.Type.String()
+		if o.Instance >= next[key] {
+			next[key] = o.Instance + 1
+		}
+	}
+
+	refs := make([]string, 0, 2*len(t.points))
+	for _, p := range t.points {
+		objType, err := model.ParseObjectType(p.typ)
+		if err != nil {
+			return err
+		}
+		key := objType.String()
+		if next[key] == 0 {
+			next[key] = 1
+		}
+		o := p.config(name)
+		o.Instance = next[key]
+		next[key]++
+		s.Objects = append(s.Objects, o)
+		refs = append(refs, "{"+p.name+"}", fmt.Sprintf("%s:%d", key, o.Instance))
+	}
+	replacer := strings.NewReplacer(refs...)
+	for _, r := range t.rules {
+		r.Name = name + " " + r.Name
+		r.Target, r.When, r.Value = replacer.Replace(r.Target), replacer.Replace(r.When), replacer.Replace(r.Value)
+		if r.Interval == 0 {
+			r.Interval = templateRuleInterval
+		}
+		s.Rules = append(s.Rules, r)
+	}
+	return nil
+}
+
+// config 返回点位在名为equipment的设备中的对象配置，实例号由调用方分配
+func (p point) config(equipment string) ObjectConfig {
+	o := ObjectConfig{Type: Text(p.typ), Name: equipment + " " + p.name, Units: Text(p.units), PresentValue: p.value, Alarm: p.alarm}
+	if n, ok := p.value.(int); ok {
+		o.PresentValue = float64(n) // 与JSON解码的数值一致
+	}
+	switch {
+	case strings.HasPrefix(p.typ, "binary-") && len(p.texts) == 2:
+		o.ActiveText, o.InactiveText = p.texts[0], p.texts[1]
+	case strings.HasPrefix(p.typ, "multi-state-"):
+		o.States = p.texts
+	}
+	if p.sim != "" {
+		o.Simulation = &SimulationConfig{Kind: p.sim, Min: p.low, Max: p.high, Period: p.period, Interval: Duration(10 * 1e9)}
+		if p.sim == "random-walk" {
+			o.Simulation.Step = (p.high - p.low) / 40
+		}
+		if strings.HasPrefix(p.typ, "binary-") {
+			o.Simulation.Interval = Duration(5 * 60 * 1e9) // 人员进出等状态变化较慢
+		}
+	}
+	if value, ok := o.PresentValue.(float64); ok && o.Units != "" {
+		increment := value / 100
+		if p.sim != "" {
+			increment = (p.high - p.low) / 50
+		}
+		if increment < 0 {
+			increment = -increment
+		}
+		o.COVIncrement = &increment
+	}
+	return o
+}
+
+// expandEquipment 按equipment部分加入对象和规则
+func (s *Site) expandEquipment() error {
+	for i, e := range s.Equipment {
+		count := e.Count
+		if count <= 0 {
+			count = 1
+		}
+		name := e.Name
+		if name == "" {
+			name = strings.ToUpper(e.Template)
+		}
+		for n := 1; n <= count; n++ {
+			unit := name
+			if count > 1 {
+				unit = fmt.Sprintf("%s-%d", name, n)
+			}
+			if err := s.AddEquipment(e.Template, unit); err != nil {
+				return fmt.Errorf("设备%d（%s）: %w", i+1, unit, err)
+			}
+		}
+	}
+	return nil
+}
+
+//go:embed presets/*.yaml
+var presets embed.FS
+
+// PresetNames 返回内置的站点预设名称
+func PresetNames() []string {
+	entries, _ := presets.ReadDir("presets")
+	var names []string
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".yaml"))
+	}
+	return names
+}
+
+// LoadPreset 加载内置的站点预设，预设用设备模板组成一栋建筑
+func LoadPreset(name string) (*Site, error) {
+	data, err := presets.ReadFile(path.Join("presets", name+".yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("未知的站点预设: %s，可用的预设: %s", name, strings.Join(PresetNames(), "、"))
+	}
+	site, err := Parse(data, "yaml")
+	if err != nil {
+		return nil, fmt.Errorf("预设%s: %w", name, err)
+	}
+	return site, nil
+}
@@ -0,0 +1,269 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// yamlLine 去掉注释和缩进后的一行
+type yamlLine struct {
+	number int
+	indent int
+	text   string
+}
+
+// yamlParser 配置文件使用的YAML子集解析器：块映射、块序列、标量、[a, b]形式的流序列和#注释。
+// 不支持锚点、多文档、多行字符串和{}形式的流映射
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// parseYAML 将YAML文本解析为map[string]any、[]any和标量组成的值
+func parseYAML(data []byte) (any, error) {
+	p := &yamlParser{}
+	for i, raw := range strings.Split(string(data), "\n") {
+		raw = strings.TrimRight(raw, "\r")
+		if strings.HasPrefix(raw, "---") || strings.HasPrefix(raw, "...") {
+			continue
+		}
+		text := stripComment(raw)
+		trimmed := strings.TrimLeft(text, " ")
+		if strings.TrimSpace(trimmed) == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("第%d行: 缩进不能使用制表符", i+1)
+		}
+		p.lines = append(p.lines, yamlLine{number: i + 1, indent: len(text) - len(trimmed), text: strings.TrimRight(trimmed, " \t")})
+	}
+	if len(p.lines) == 0 {
+		return map[string]any{}, nil
+	}
+	value, err := p.parseNode(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("第%d行: 缩进无效", p.lines[p.pos].number)
+	}
+	return value, nil
+}
+
+// parseNode 解析从当前行开始、缩进为indent的块
+func (p *yamlParser) parseNode(indent int) (any, error) {
+	line := p.lines[p.pos]
+	if line.text == "-" || strings.HasPrefix(line.text, "- ") {
+		return p.parseSequence(indent)
+	}
+	return p.parseMapping(indent)
+}
+
+// parseSequence 解析块序列
+func (p *yamlParser) parseSequence(indent int) (any, error) {
+	items := []any{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		isItem := line.text == "-" || strings.HasPrefix(line.text, "- ")
+		if line.indent < indent || (line.indent == indent && !isItem) {
+			// 与键同缩进的序列在下一个键处结束
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("第%d行: 应为序列项", line.number)
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+		if rest == "" {
+			// 项的内容在后续缩进更深的行中
+			p.pos++
+			if p.pos >= len(p.lines) || p.lines[p.pos].indent <= indent {
+				items = append(items, nil)
+				continue
+			}
+			value, err := p.parseNode(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+			continue
+		}
+		if _, _, isKey := splitKey(rest); isKey {
+			// "- key: value"：将该行改写为缩进到key处的映射，与后续同缩进的行组成一个映射
+			p.lines[p.pos] = yamlLine{number: line.number, indent: indent + len(line.text) - len(rest), text: rest}
+			value, err := p.parseMapping(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+			continue
+		}
+		value, err := parseScalar(rest, line.number)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, value)
+		p.pos++
+	}
+	return items, nil
+}
+
+// parseMapping 解析块映射
+func (p *yamlParser) parseMapping(indent int) (any, error) {
+	mapping := map[string]any{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("第%d行: 缩进无效", line.number)
+		}
+		key, rest, ok := splitKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("第%d行: 应为\"键: 值\"", line.number)
+		}
+		if _, exists := mapping[key]; exists {
+			return nil, fmt.Errorf("第%d行: 重复的键%s", line.number, key)
+		}
+		p.pos++
+		if rest != "" {
+			value, err := parseScalar(rest, line.number)
+			if err != nil {
+				return nil, err
+			}
+			mapping[key] = value
+			continue
+		}
+		// 值在后续行中：缩进更深的块，或与键同缩进的序列
+		if p.pos < len(p.lines) {
+			next := p.lines[p.pos]
+			isItem := next.text == "-" || strings.HasPrefix(next.text, "- ")
+			if next.indent > indent || (next.indent == indent && isItem) {
+				value, err := p.parseNode(next.indent)
+				if err != nil {
+					return nil, err
+				}
+				mapping[key] = value
+				continue
+			}
+		}
+		mapping[key] = nil
+	}
+	return mapping, nil
+}
+
+// splitKey 拆分"键: 值"，键可以加引号
+func splitKey(text string) (key, rest string, ok bool) {
+	if strings.HasPrefix(text, "\"") || strings.HasPrefix(text, "'") {
+		end := strings.IndexByte(text[1:], text[0])
+		if end < 0 {
+			return "", "", false
+		}
+		key, text = text[1:end+1], text[end+2:]
+		if !strings.HasPrefix(text, ":") || (len(text) > 1 && text[1] != ' ') {
+			return "", "", false
+		}
+		return key, strings.TrimSpace(text[1:]), true
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), i > 0
+		}
+	}
+	return "", "", false
+}
+
+// parseScalar 解析标量或[a, b]形式的流序列
+func parseScalar(text string, number int) (any, error) {
+	switch {
+	case strings.HasPrefix(text, "["):
+		if !strings.HasSuffix(text, "]") {
+			return nil, fmt.Errorf("第%d行: 流序列缺少]", number)
+		}
+		items := []any{}
+		for _, item := range splitFlow(text[1 : len(text)-1]) {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			value, err := parseScalar(item, number)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		}
+		return items, nil
+	case strings.HasPrefix(text, "{"):
+		return nil, fmt.Errorf("第%d行: 不支持流映射", number)
+	case strings.HasPrefix(text, "\""):
+		value, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, fmt.Errorf("第%d行: 无效的字符串%s", number, text)
+		}
+		return value, nil
+	case strings.HasPrefix(text, "'"):
+		if len(text) < 2 || !strings.HasSuffix(text, "'") {
+			return nil, fmt.Errorf("第%d行: 无效的字符串%s", number, text)
+		}
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	}
+	switch text {
+	case "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	if value, err := strconv.ParseFloat(text, 64); err == nil && !math.IsInf(value, 0) && !math.IsNaN(value) && !strings.ContainsAny(text, "xXpP_") {
+		return value, nil
+	}
+	if value, err := strconv.ParseInt(text, 0, 64); err == nil {
+		return float64(value), nil
+	}
+	return text, nil
+}
+
+// splitFlow 按顶层逗号拆分流序列的内容，引号内的逗号不拆分
+func splitFlow(text string) []string {
+	var items []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			items = append(items, text[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, text[start:])
+}
+
+// stripComment 去掉引号外以#开始的注释
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" \t[,:", line[i-1]) >= 0):
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
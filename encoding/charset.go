@@ -0,0 +1,101 @@
+package encoding
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// CharacterString的字符集编号（ASHRAE 135 第20.2.9节）
+const (
+	CharacterSetUTF8      = 0 // ANSI X3.4/UTF-8
+	CharacterSetDBCS      = 1 // IBM/Microsoft DBCS
+	CharacterSetJISX0208  = 2 // JIS X 0208
+	CharacterSetUCS4      = 3 // ISO 10646 UCS-4
+	CharacterSetUCS2      = 4 // ISO 10646 UCS-2
+	CharacterSetISO8859_1 = 5 // ISO 8859-1
+)
+
+// ErrCharacterSetNotSupported 字符集不支持或字符无法用该字符集表示
+var ErrCharacterSetNotSupported = errors.New("character set not supported")
+
+// EncodeCharacterStringValue 按指定字符集编码字符串值（首字节为字符集）
+func EncodeCharacterStringValue(s string, charset uint8) ([]byte, error) {
+	value := []byte{charset}
+	switch charset {
+	case CharacterSetUTF8:
+		return append(value, s...), nil
+	case CharacterSetUCS2:
+		for _, r := range s {
+			if r > 0xFFFF {
+				return nil, fmt.Errorf("%w: 字符%q超出UCS-2范围", ErrCharacterSetNotSupported, r)
+			}
+			value = append(value, byte(r>>8), byte(r))
+		}
+		return value, nil
+	case CharacterSetUCS4:
+		for _, r := range s {
+			value = append(value, byte(r>>24), byte(r>>16), byte(r>>8), byte(r))
+		}
+		return value, nil
+	case CharacterSetISO8859_1:
+		for _, r := range s {
+			if r > 0xFF {
+				return nil, fmt.Errorf("%w: 字符%q超出ISO 8859-1范围", ErrCharacterSetNotSupported, r)
+			}
+			value = append(value, byte(r))
+		}
+		return value, nil
+	}
+	return nil, fmt.Errorf("%w: %d", ErrCharacterSetNotSupported, charset)
+}
+
+// DecodeCharacterStringValue 解码字符串值并转换为UTF-8，返回字符串和字符集
+func DecodeCharacterStringValue(value []byte) (string, uint8, error) {
+	if len(value) < 1 {
+		return "", 0, fmt.Errorf("字符串缺少字符集")
+	}
+	charset, data := value[0], value[1:]
+	switch charset {
+	case CharacterSetUTF8:
+		if !utf8.Valid(data) {
+			return "", charset, fmt.Errorf("无效的UTF-8字符串")
+		}
+		return string(data), charset, nil
+	case CharacterSetUCS2:
+		if len(data)%2 != 0 {
+			return "", charset, fmt.Errorf("UCS-2字符串长度无效: %d", len(data))
+		}
+		runes := make([]rune, 0, len(data)/2)
+		for i := 0; i < len(data); i += 2 {
+			runes = append(runes, rune(data[i])<<8|rune(data[i+1]))
+		}
+		return string(runes), charset, nil
+	case CharacterSetUCS4:
+		if len(data)%4 != 0 {
+			return "", charset, fmt.Errorf("UCS-4字符串长度无效: %d", len(data))
+		}
+		runes := make([]rune, 0, len(data)/4)
+		for i := 0; i < len(data); i += 4 {
+			runes = append(runes, rune(DecodeUnsignedBytes(data[i:i+4])))
+		}
+		return string(runes), charset, nil
+	case CharacterSetISO8859_1:
+		// ISO 8859-1的码位与Unicode前256个码位相同
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes), charset, nil
+	}
+	return "", charset, fmt.Errorf("%w: %d", ErrCharacterSetNotSupported, charset)
+}
+
+// EncodeCharacterStringCharset 按指定字符集编码应用标签CharacterString
+func EncodeCharacterStringCharset(s string, charset uint8) ([]byte, error) {
+	value, err := EncodeCharacterStringValue(s, charset)
+	if err != nil {
+		return nil, err
+	}
+	return append(EncodeTag(TagCharacterString, false, len(value)), value...), nil
+}
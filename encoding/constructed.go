@@ -0,0 +1,499 @@
+package encoding
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// 本文件实现常用构造类型的编解码。Encode*只编码序列内容，外层上下文标签由调用方添加；
+// Decoder的同名方法从当前位置读取一个完整的值。
+
+// unspecifiedDate 全通配的日期，DateRange中表示不限制
+var unspecifiedDate = Date{Year: 0xFF, Month: 0xFF, Day: 0xFF, Weekday: 0xFF}
+
+// EncodeDeviceObjectPropertyReference 编码BACnetDeviceObjectPropertyReference
+//
+//	objectIdentifier   [0] BACnetObjectIdentifier,
+//	propertyIdentifier [1] BACnetPropertyIdentifier,
+//	propertyArrayIndex [2] Unsigned OPTIONAL,
+//	deviceIdentifier   [3] BACnetObjectIdentifier OPTIONAL
+func EncodeDeviceObjectPropertyReference(ref model.DeviceObjectPropertyReference) []byte {
+	out := EncodeObjectPropertyReference(model.ObjectPropertyReference{
+		ObjectIdentifier:   ref.ObjectIdentifier,
+		PropertyIdentifier: ref.PropertyIdentifier,
+		ArrayIndex:         ref.ArrayIndex,
+	})
+	if ref.DeviceIdentifier != nil {
+		out = append(out, EncodeContextObjectIdentifier(3, *ref.DeviceIdentifier)...)
+	}
+	return out
+}
+
+// DeviceObjectPropertyReference 读取BACnetDeviceObjectPropertyReference
+func (d *Decoder) DeviceObjectPropertyReference() (model.DeviceObjectPropertyReference, error) {
+	ref, err := d.ObjectPropertyReference()
+	if err != nil {
+		return model.DeviceObjectPropertyReference{}, err
+	}
+	result := model.DeviceObjectPropertyReference{
+		ObjectIdentifier:   ref.ObjectIdentifier,
+		PropertyIdentifier: ref.PropertyIdentifier,
+		ArrayIndex:         ref.ArrayIndex,
+	}
+	if d.IsContext(3) {
+		device, err := d.ContextObjectIdentifier(3)
+		if err != nil {
+			return result, err
+		}
+		result.DeviceIdentifier = &device
+	}
+	return result, nil
+}
+
+// EncodeObjectPropertyReference 编码BACnetObjectPropertyReference
+//
+//	objectIdentifier   [0] BACnetObjectIdentifier,
+//	propertyIdentifier [1] BACnetPropertyIdentifier,
+//	propertyArrayIndex [2] Unsigned OPTIONAL
+func EncodeObjectPropertyReference(ref model.ObjectPropertyReference) []byte {
+	out := EncodeContextObjectIdentifier(0, ref.ObjectIdentifier)
+	out = append(out, EncodeContextEnumerated(1, uint32(ref.PropertyIdentifier))...)
+	if ref.ArrayIndex != nil {
+		out = append(out, EncodeContextUnsigned(2, *ref.ArrayIndex)...)
+	}
+	return out
+}
+
+// ObjectPropertyReference 读取BACnetObjectPropertyReference
+func (d *Decoder) ObjectPropertyReference() (model.ObjectPropertyReference, error) {
+	var ref model.ObjectPropertyReference
+	var err error
+	if ref.ObjectIdentifier, err = d.ContextObjectIdentifier(0); err != nil {
+		return ref, err
+	}
+	prop, err := d.ContextEnumerated(1)
+	if err != nil {
+		return ref, err
+	}
+	ref.PropertyIdentifier = model.PropertyIdentifier(prop)
+	if d.IsContext(2) {
+		index, err := d.ContextUnsigned(2)
+		if err != nil {
+			return ref, err
+		}
+		ref.ArrayIndex = &index
+	}
+	return ref, nil
+}
+
+// EncodeTimeStamp 编码BACnetTimeStamp
+//
+//	BACnetTimeStamp ::= CHOICE { time [0] Time, sequenceNumber [1] Unsigned, dateTime [2] BACnetDateTime }
+//
+// 未指定的dateTime编码为全通配符
+func EncodeTimeStamp(ts model.TimeStamp) []byte {
+	switch {
+	case ts.Time != nil:
+		t := *ts.Time
+		return append(EncodeTag(0, true, 4), t.Hour, t.Minute, t.Second, t.Hundredths)
+	case ts.SequenceNumber != nil:
+		return EncodeContextUnsigned(1, *ts.SequenceNumber)
+	}
+	var dt time.Time
+	if ts.DateTime != nil {
+		dt = *ts.DateTime
+	}
+	return EncodeConstructed(2, EncodeDateTime(dt))
+}
+
+// EncodeDateTime 编码BACnetDateTime（应用标签Date后接Time），零值时间编码为全通配符
+func EncodeDateTime(t time.Time) []byte {
+	if t.IsZero() {
+		out := EncodeDate(unspecifiedDate)
+		return append(out, EncodeTime(Time{Hour: 0xFF, Minute: 0xFF, Second: 0xFF, Hundredths: 0xFF})...)
+	}
+	out := EncodeDate(NewDate(t))
+	return append(out, EncodeTime(NewTime(t))...)
+}
+
+// TimeStamp 读取BACnetTimeStamp，全通配符的dateTime解码为零值时间
+func (d *Decoder) TimeStamp() (model.TimeStamp, error) {
+	var ts model.TimeStamp
+	switch {
+	case d.IsContext(0):
+		value, err := d.ContextValue(0)
+		if err != nil || len(value) != 4 {
+			return ts, fmt.Errorf("时间戳的时间值无效")
+		}
+		t := DecodeTimeValue(value)
+		ts.Time = &t
+	case d.IsContext(1):
+		seq, err := d.ContextUnsigned(1)
+		if err != nil {
+			return ts, err
+		}
+		ts.SequenceNumber = &seq
+	case d.IsOpening(2):
+		d.Opening(2)
+		date, err := d.ApplicationDate()
+		if err != nil {
+			return ts, fmt.Errorf("时间戳的日期值无效")
+		}
+		tm, err := d.ApplicationTime()
+		if err != nil {
+			return ts, fmt.Errorf("时间戳的时间值无效")
+		}
+		if err := d.Closing(2); err != nil {
+			return ts, err
+		}
+		var dt time.Time
+		if date.Year != 0xFF || tm.Hour != 0xFF {
+			dt = DateTime(date, tm)
+		}
+		ts.DateTime = &dt
+	default:
+		return ts, fmt.Errorf("未知的时间戳选项")
+	}
+	return ts, nil
+}
+
+// EncodePrescale 编码BACnetPrescale
+//
+//	BACnetPrescale ::= SEQUENCE { multiplier [0] Unsigned, moduloDivide [1] Unsigned }
+func EncodePrescale(p model.Prescale) []byte {
+	out := EncodeContextUnsigned(0, p.Multiplier)
+	return append(out, EncodeContextUnsigned(1, p.ModuloDivide)...)
+}
+
+// Prescale 读取BACnetPrescale
+func (d *Decoder) Prescale() (model.Prescale, error) {
+	var p model.Prescale
+	var err error
+	if p.Multiplier, err = d.ContextUnsigned(0); err != nil {
+		return p, err
+	}
+	if p.ModuloDivide, err = d.ContextUnsigned(1); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+// EncodeScale 编码BACnetScale
+//
+//	BACnetScale ::= CHOICE { floatScale [0] REAL, integerScale [1] INTEGER }
+func EncodeScale(s model.Scale) []byte {
+	if s.FloatScale != nil {
+		return EncodeContextReal(0, *s.FloatScale)
+	}
+	var integer int32
+	if s.IntegerScale != nil {
+		integer = *s.IntegerScale
+	}
+	return EncodeContextSigned(1, integer)
+}
+
+// Scale 读取BACnetScale
+func (d *Decoder) Scale() (model.Scale, error) {
+	var s model.Scale
+	switch {
+	case d.IsContext(0):
+		value, err := d.ContextValue(0)
+		if err != nil || len(value) != 4 {
+			return s, fmt.Errorf("floatScale值无效")
+		}
+		scale := math.Float32frombits(DecodeUnsignedBytes(value))
+		s.FloatScale = &scale
+	case d.IsContext(1):
+		value, err := d.ContextValue(1)
+		if err != nil || len(value) == 0 || len(value) > 4 {
+			return s, fmt.Errorf("integerScale值无效")
+		}
+		scale := DecodeSignedBytes(value)
+		s.IntegerScale = &scale
+	default:
+		return s, fmt.Errorf("未知的Scale选项")
+	}
+	return s, nil
+}
+
+// EncodeRecipient 编码BACnetRecipient
+//
+//	BACnetRecipient ::= CHOICE { device [0] BACnetObjectIdentifier, address [1] BACnetAddress }
+//	BACnetAddress ::= SEQUENCE { network-number Unsigned16, mac-address OCTET STRING }
+func EncodeRecipient(r model.Recipient) []byte {
+	if r.Device != nil {
+		return EncodeContextObjectIdentifier(0, *r.Device)
+	}
+	var address model.Address
+	if r.Address != nil {
+		address = *r.Address
+	}
+	content := EncodeUnsigned(uint32(address.Network))
+	content = append(content, EncodeOctetString(address.MAC)...)
+	return EncodeConstructed(1, content)
+}
+
+// Recipient 读取BACnetRecipient
+func (d *Decoder) Recipient() (model.Recipient, error) {
+	var r model.Recipient
+	if d.IsContext(0) {
+		device, err := d.ContextObjectIdentifier(0)
+		if err != nil {
+			return r, err
+		}
+		r.Device = &device
+		return r, nil
+	}
+	if err := d.Opening(1); err != nil {
+		return r, fmt.Errorf("未知的接收者选项")
+	}
+	network, err := d.ApplicationUnsigned()
+	if err != nil || network > 0xFFFF {
+		return r, fmt.Errorf("接收者的网络号无效")
+	}
+	t, mac, err := d.ApplicationValue()
+	if err != nil || t.Number != TagOctetString {
+		return r, fmt.Errorf("接收者的MAC地址无效")
+	}
+	if err := d.Closing(1); err != nil {
+		return r, err
+	}
+	r.Address = &model.Address{Network: uint16(network), MAC: append([]byte(nil), mac...)}
+	return r, nil
+}
+
+// EncodeDestination 编码BACnetDestination
+//
+//	validDays BACnetDaysOfWeek, fromTime Time, toTime Time, recipient BACnetRecipient,
+//	processIdentifier Unsigned32, issueConfirmedNotifications BOOLEAN, transitions BACnetEventTransitionBits
+func EncodeDestination(dest model.Destination) []byte {
+	out := EncodeBitString(dest.ValidDays)
+	out = append(out, EncodeTime(dest.FromTime)...)
+	out = append(out, EncodeTime(dest.ToTime)...)
+	out = append(out, EncodeRecipient(dest.Recipient)...)
+	out = append(out, EncodeUnsigned(dest.ProcessIdentifier)...)
+	out = append(out, EncodeBoolean(dest.IssueConfirmedNotifications)...)
+	return append(out, EncodeBitString(dest.Transitions)...)
+}
+
+// Destination 读取BACnetDestination
+func (d *Decoder) Destination() (model.Destination, error) {
+	var dest model.Destination
+	var err error
+	if dest.ValidDays, err = d.applicationBitString(); err != nil {
+		return dest, fmt.Errorf("Valid_Days无效")
+	}
+	if dest.FromTime, err = d.ApplicationTime(); err != nil {
+		return dest, err
+	}
+	if dest.ToTime, err = d.ApplicationTime(); err != nil {
+		return dest, err
+	}
+	if dest.Recipient, err = d.Recipient(); err != nil {
+		return dest, err
+	}
+	if dest.ProcessIdentifier, err = d.ApplicationUnsigned(); err != nil {
+		return dest, err
+	}
+	confirmed, err := d.Application()
+	issue, ok := confirmed.(bool)
+	if err != nil || !ok {
+		return dest, fmt.Errorf("Issue_Confirmed_Notifications无效")
+	}
+	dest.IssueConfirmedNotifications = issue
+	if dest.Transitions, err = d.applicationBitString(); err != nil {
+		return dest, fmt.Errorf("Transitions无效")
+	}
+	return dest, nil
+}
+
+// applicationBitString 读取应用标签BitString
+func (d *Decoder) applicationBitString() (BitString, error) {
+	value, err := d.Application()
+	if err != nil {
+		return nil, err
+	}
+	bits, ok := value.(BitString)
+	if !ok {
+		return nil, fmt.Errorf("期望应用标签BitString")
+	}
+	return bits, nil
+}
+
+// EncodeDateRange 编码BACnetDateRange，零值时间表示不限制，编码为全通配日期
+//
+//	BACnetDateRange ::= SEQUENCE { startDate Date, endDate Date }
+func EncodeDateRange(r model.DateRange) []byte {
+	dateOrAny := func(t time.Time) Date {
+		if t.IsZero() {
+			return unspecifiedDate
+		}
+		return NewDate(t)
+	}
+	return append(EncodeDate(dateOrAny(r.StartDate)), EncodeDate(dateOrAny(r.EndDate))...)
+}
+
+// DateRange 读取BACnetDateRange，端点不是具体日期时视为不限制
+func (d *Decoder) DateRange() (model.DateRange, error) {
+	var r model.DateRange
+	start, err := d.ApplicationDate()
+	if err != nil {
+		return r, err
+	}
+	end, err := d.ApplicationDate()
+	if err != nil {
+		return r, err
+	}
+	return model.DateRange{StartDate: start.Time(), EndDate: end.Time()}, nil
+}
+
+// EncodeTimeValue 编码BACnetTimeValue，Value为nil时编码为Null（释放）
+//
+//	BACnetTimeValue ::= SEQUENCE { time Time, value ABSTRACT-SYNTAX.&Type }
+func EncodeTimeValue(tv model.TimeValue) ([]byte, error) {
+	value, err := EncodeApplication(tv.Value)
+	if err != nil {
+		return nil, err
+	}
+	return append(EncodeTime(model.TimeOfDay(tv.Time)), value...), nil
+}
+
+// TimeValue 读取BACnetTimeValue
+func (d *Decoder) TimeValue() (model.TimeValue, error) {
+	t, err := d.ApplicationTime()
+	if err != nil {
+		return model.TimeValue{}, err
+	}
+	value, err := d.Application()
+	if err != nil {
+		return model.TimeValue{}, err
+	}
+	return model.TimeValue{Time: t.Duration(), Value: value}, nil
+}
+
+// EncodeCalendarEntry 编码BACnetCalendarEntry
+//
+//	BACnetCalendarEntry ::= CHOICE { date [0] Date, dateRange [1] BACnetDateRange, weekNDay [2] BACnetWeekNDay }
+//	BACnetWeekNDay ::= OCTET STRING (SIZE (3)) -- 月份、月中的周、星期
+func EncodeCalendarEntry(e model.CalendarEntry) []byte {
+	switch {
+	case e.Date != nil:
+		return append(EncodeTag(0, true, 4), e.Date.Year, e.Date.Month, e.Date.Day, e.Date.Weekday)
+	case e.DateRange != nil:
+		return EncodeConstructed(1, EncodeDateRange(*e.DateRange))
+	case e.WeekNDay != nil:
+		return EncodeContextOctetString(2, []byte{e.WeekNDay.Month, e.WeekNDay.WeekOfMonth, e.WeekNDay.DayOfWeek})
+	}
+	return nil
+}
+
+// CalendarEntry 读取BACnetCalendarEntry
+func (d *Decoder) CalendarEntry() (model.CalendarEntry, error) {
+	var e model.CalendarEntry
+	switch {
+	case d.IsContext(0):
+		value, err := d.ContextValue(0)
+		if err != nil || len(value) != 4 {
+			return e, fmt.Errorf("日历条目的日期值无效")
+		}
+		date := DecodeDateValue(value)
+		e.Date = &date
+	case d.IsOpening(1):
+		d.Opening(1)
+		r, err := d.DateRange()
+		if err != nil {
+			return e, err
+		}
+		if err := d.Closing(1); err != nil {
+			return e, err
+		}
+		e.DateRange = &r
+	case d.IsContext(2):
+		value, err := d.ContextValue(2)
+		if err != nil || len(value) != 3 {
+			return e, fmt.Errorf("日历条目的WeekNDay值无效")
+		}
+		e.WeekNDay = &model.WeekNDay{Month: value[0], WeekOfMonth: value[1], DayOfWeek: value[2]}
+	default:
+		return e, fmt.Errorf("未知的日历条目选项")
+	}
+	return e, nil
+}
+
+// EncodeSpecialEvent 编码BACnetSpecialEvent，切换值无法编码时返回错误
+//
+//	BACnetSpecialEvent ::= SEQUENCE {
+//	  period CHOICE { calendarEntry [0] BACnetCalendarEntry, calendarReference [1] BACnetObjectIdentifier },
+//	  listOfTimeValues [2] SEQUENCE OF BACnetTimeValue,
+//	  eventPriority    [3] Unsigned (1..16) }
+func EncodeSpecialEvent(e model.SpecialEvent) ([]byte, error) {
+	var out []byte
+	if e.CalendarReference != nil {
+		out = EncodeContextObjectIdentifier(1, *e.CalendarReference)
+	} else {
+		var entry model.CalendarEntry
+		if e.Calendar != nil {
+			entry = *e.Calendar
+		}
+		out = EncodeConstructed(0, EncodeCalendarEntry(entry))
+	}
+	var timeValues []byte
+	for _, tv := range e.TimeValues {
+		encoded, err := EncodeTimeValue(tv)
+		if err != nil {
+			return nil, err
+		}
+		timeValues = append(timeValues, encoded...)
+	}
+	out = append(out, EncodeConstructed(2, timeValues)...)
+	return append(out, EncodeContextUnsigned(3, uint32(e.Priority))...), nil
+}
+
+// SpecialEvent 读取BACnetSpecialEvent，优先级超出1..16时返回错误
+func (d *Decoder) SpecialEvent() (model.SpecialEvent, error) {
+	var e model.SpecialEvent
+	switch {
+	case d.IsOpening(0):
+		d.Opening(0)
+		entry, err := d.CalendarEntry()
+		if err != nil {
+			return e, err
+		}
+		if err := d.Closing(0); err != nil {
+			return e, err
+		}
+		e.Calendar = &entry
+	case d.IsContext(1):
+		ref, err := d.ContextObjectIdentifier(1)
+		if err != nil {
+			return e, err
+		}
+		e.CalendarReference = &ref
+	default:
+		return e, fmt.Errorf("例外日程缺少日期或日历引用")
+	}
+	if err := d.Opening(2); err != nil {
+		return e, err
+	}
+	e.TimeValues = []model.TimeValue{}
+	for !d.IsClosing(2) {
+		tv, err := d.TimeValue()
+		if err != nil {
+			return e, err
+		}
+		e.TimeValues = append(e.TimeValues, tv)
+	}
+	d.Closing(2)
+	priority, err := d.ContextUnsigned(3)
+	if err != nil {
+		return e, err
+	}
+	if priority < 1 || priority > 16 {
+		return e, fmt.Errorf("例外日程优先级必须在1-16之间")
+	}
+	e.Priority = uint8(priority)
+	return e, nil
+}
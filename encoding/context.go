@@ -0,0 +1,88 @@
+package encoding
+
+import (
+	"math"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// EncodeOpeningTag 编码上下文开始标签
+func EncodeOpeningTag(number uint8) []byte {
+	if number <= 14 {
+		return []byte{number<<4 | 0x0E}
+	}
+	return []byte{0xFE, number}
+}
+
+// EncodeClosingTag 编码上下文结束标签
+func EncodeClosingTag(number uint8) []byte {
+	if number <= 14 {
+		return []byte{number<<4 | 0x0F}
+	}
+	return []byte{0xFF, number}
+}
+
+// EncodeConstructed 以指定编号的开始/结束标签包裹已编码的数据
+func EncodeConstructed(number uint8, content []byte) []byte {
+	out := EncodeOpeningTag(number)
+	out = append(out, content...)
+	return append(out, EncodeClosingTag(number)...)
+}
+
+// EncodeContextNull 编码上下文标签Null
+func EncodeContextNull(number uint8) []byte {
+	return EncodeTag(number, true, 0)
+}
+
+// EncodeContextBoolean 编码上下文标签Boolean（与应用标签不同，值占一个数据字节）
+func EncodeContextBoolean(number uint8, v bool) []byte {
+	if v {
+		return append(EncodeTag(number, true, 1), 0x01)
+	}
+	return append(EncodeTag(number, true, 1), 0x00)
+}
+
+// EncodeContextUnsigned 编码上下文标签Unsigned
+func EncodeContextUnsigned(number uint8, v uint32) []byte {
+	value := EncodeUnsignedBytes(v)
+	return append(EncodeTag(number, true, len(value)), value...)
+}
+
+// EncodeContextSigned 编码上下文标签Signed
+func EncodeContextSigned(number uint8, v int32) []byte {
+	value := EncodeSignedBytes(v)
+	return append(EncodeTag(number, true, len(value)), value...)
+}
+
+// EncodeContextReal 编码上下文标签Real
+func EncodeContextReal(number uint8, v float32) []byte {
+	bits := math.Float32bits(v)
+	return append(EncodeTag(number, true, 4), byte(bits>>24), byte(bits>>16), byte(bits>>8), byte(bits))
+}
+
+// EncodeContextEnumerated 编码上下文标签Enumerated
+func EncodeContextEnumerated(number uint8, v uint32) []byte {
+	return EncodeContextUnsigned(number, v)
+}
+
+// EncodeContextOctetString 编码上下文标签OctetString
+func EncodeContextOctetString(number uint8, v []byte) []byte {
+	return append(EncodeTag(number, true, len(v)), v...)
+}
+
+// EncodeContextCharacterString 编码上下文标签CharacterString（UTF-8字符集）
+func EncodeContextCharacterString(number uint8, s string) []byte {
+	value := append([]byte{CharacterSetUTF8}, []byte(s)...)
+	return append(EncodeTag(number, true, len(value)), value...)
+}
+
+// EncodeContextBitString 编码上下文标签BitString
+func EncodeContextBitString(number uint8, bits []bool) []byte {
+	value := EncodeBitStringValue(bits)
+	return append(EncodeTag(number, true, len(value)), value...)
+}
+
+// EncodeContextObjectIdentifier 编码上下文标签ObjectIdentifier
+func EncodeContextObjectIdentifier(number uint8, oid model.ObjectIdentifier) []byte {
+	return append(EncodeTag(number, true, 4), EncodeObjectIdentifierValue(oid)...)
+}
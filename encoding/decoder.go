@@ -0,0 +1,324 @@
+package encoding
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// Decoder 按顺序解析标签化的服务参数
+type Decoder struct {
+	data   []byte
+	offset int
+}
+
+// NewDecoder 创建解析data的Decoder
+func NewDecoder(data []byte) *Decoder {
+	return &Decoder{data: data}
+}
+
+// Offset 返回已解析的字节数
+func (d *Decoder) Offset() int {
+	return d.offset
+}
+
+// Remaining 返回尚未解析的数据
+func (d *Decoder) Remaining() []byte {
+	return d.data[d.offset:]
+}
+
+// Peek 查看下一个标签但不移动偏移量
+func (d *Decoder) Peek() (Tag, int, error) {
+	return DecodeTag(d.data[d.offset:])
+}
+
+// Done 判断是否已解析完全部数据
+func (d *Decoder) Done() bool {
+	return d.offset >= len(d.data)
+}
+
+// IsContext 判断下一个标签是否为指定编号的上下文标签（非开始/结束标签）
+func (d *Decoder) IsContext(number uint8) bool {
+	if d.Done() {
+		return false
+	}
+	t, _, err := d.Peek()
+	return err == nil && t.Context && !t.Opening && !t.Closing && t.Number == number
+}
+
+// IsOpening 判断下一个标签是否为指定编号的开始标签
+func (d *Decoder) IsOpening(number uint8) bool {
+	if d.Done() {
+		return false
+	}
+	t, _, err := d.Peek()
+	return err == nil && t.Opening && t.Number == number
+}
+
+// IsClosing 判断下一个标签是否为指定编号的结束标签
+func (d *Decoder) IsClosing(number uint8) bool {
+	if d.Done() {
+		return false
+	}
+	t, _, err := d.Peek()
+	return err == nil && t.Closing && t.Number == number
+}
+
+// Opening 读取指定编号的开始标签
+func (d *Decoder) Opening(number uint8) error {
+	if !d.IsOpening(number) {
+		if d.Done() {
+			return fmt.Errorf("缺少开始标签%d: %w", number, ErrTruncated)
+		}
+		return fmt.Errorf("期望开始标签%d", number)
+	}
+	_, n, _ := d.Peek()
+	d.offset += n
+	return nil
+}
+
+// Closing 读取指定编号的结束标签
+func (d *Decoder) Closing(number uint8) error {
+	if !d.IsClosing(number) {
+		if d.Done() {
+			return fmt.Errorf("缺少结束标签%d: %w", number, ErrTruncated)
+		}
+		return fmt.Errorf("期望结束标签%d", number)
+	}
+	_, n, _ := d.Peek()
+	d.offset += n
+	return nil
+}
+
+// Constructed 读取指定编号开始/结束标签之间的原始数据，内部可以嵌套其他构造值
+func (d *Decoder) Constructed(number uint8) ([]byte, error) {
+	if err := d.Opening(number); err != nil {
+		return nil, err
+	}
+	start := d.offset
+	depth := 0
+	for !d.Done() {
+		t, n, err := d.Peek()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case t.Closing && depth == 0:
+			if t.Number != number {
+				return nil, fmt.Errorf("期望结束标签%d，实际为%d", number, t.Number)
+			}
+			content := d.data[start:d.offset]
+			d.offset += n
+			return content, nil
+		case t.Opening:
+			depth++
+			d.offset += n
+		case t.Closing:
+			depth--
+			d.offset += n
+		default:
+			if err := d.skip(t, n); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return nil, fmt.Errorf("缺少结束标签%d: %w", number, ErrTruncated)
+}
+
+// skip 跳过一个基本类型标签及其数据
+func (d *Decoder) skip(t Tag, n int) error {
+	// 应用布尔类型的值存放在长度字段中，没有数据字节
+	if !t.Context && t.Number == TagBoolean {
+		d.offset += n
+		return nil
+	}
+	end := d.offset + n + int(t.Length)
+	if t.Length > uint32(len(d.data)) || end > len(d.data) {
+		return fmt.Errorf("标签%d长度越界: %w", t.Number, ErrTruncated)
+	}
+	d.offset = end
+	return nil
+}
+
+// ContextValue 读取指定编号上下文标签的原始值
+func (d *Decoder) ContextValue(number uint8) ([]byte, error) {
+	if d.Done() {
+		return nil, fmt.Errorf("缺少上下文标签%d: %w", number, ErrTruncated)
+	}
+	t, n, err := d.Peek()
+	if err != nil {
+		return nil, err
+	}
+	if !t.Context || t.Opening || t.Closing || t.Number != number {
+		return nil, fmt.Errorf("期望上下文标签%d", number)
+	}
+	start := d.offset + n
+	end := start + int(t.Length)
+	if t.Length > uint32(len(d.data)) || end > len(d.data) {
+		return nil, fmt.Errorf("上下文标签%d长度越界: %w", number, ErrTruncated)
+	}
+	d.offset = end
+	return d.data[start:end], nil
+}
+
+// ContextUnsigned 读取上下文标签Unsigned
+func (d *Decoder) ContextUnsigned(number uint8) (uint32, error) {
+	value, err := d.ContextValue(number)
+	if err != nil {
+		return 0, err
+	}
+	if len(value) == 0 || len(value) > 4 {
+		return 0, fmt.Errorf("上下文标签%d的无符号整数长度无效", number)
+	}
+	return DecodeUnsignedBytes(value), nil
+}
+
+// ContextEnumerated 读取上下文标签Enumerated
+func (d *Decoder) ContextEnumerated(number uint8) (uint32, error) {
+	value, err := d.ContextValue(number)
+	if err != nil {
+		return 0, err
+	}
+	if len(value) == 0 || len(value) > 4 {
+		return 0, fmt.Errorf("上下文标签%d的枚举值长度无效", number)
+	}
+	return DecodeUnsignedBytes(value), nil
+}
+
+// ContextBoolean 读取上下文标签Boolean
+func (d *Decoder) ContextBoolean(number uint8) (bool, error) {
+	value, err := d.ContextValue(number)
+	if err != nil {
+		return false, err
+	}
+	if len(value) != 1 || value[0] > 1 {
+		return false, fmt.Errorf("上下文标签%d的布尔值无效", number)
+	}
+	return value[0] == 1, nil
+}
+
+// ContextObjectIdentifier 读取上下文标签ObjectIdentifier
+func (d *Decoder) ContextObjectIdentifier(number uint8) (model.ObjectIdentifier, error) {
+	value, err := d.ContextValue(number)
+	if err != nil {
+		return model.ObjectIdentifier{}, err
+	}
+	if len(value) != 4 {
+		return model.ObjectIdentifier{}, fmt.Errorf("上下文标签%d的对象标识符长度无效", number)
+	}
+	return DecodeObjectIdentifierValue(value), nil
+}
+
+// ContextCharacterString 读取上下文标签CharacterString并转换为UTF-8
+func (d *Decoder) ContextCharacterString(number uint8) (string, error) {
+	value, err := d.ContextValue(number)
+	if err != nil {
+		return "", err
+	}
+	str, _, err := DecodeCharacterStringValue(value)
+	return str, err
+}
+
+// ContextBitString 读取上下文标签BitString
+func (d *Decoder) ContextBitString(number uint8) (BitString, error) {
+	value, err := d.ContextValue(number)
+	if err != nil {
+		return nil, err
+	}
+	if len(value) < 1 || value[0] > 7 {
+		return nil, fmt.Errorf("上下文标签%d的位串值无效", number)
+	}
+	return BitString(DecodeBitStringValue(value)), nil
+}
+
+// ApplicationValue 读取一个应用标签，返回标签信息和原始值
+func (d *Decoder) ApplicationValue() (Tag, []byte, error) {
+	if d.Done() {
+		return Tag{}, nil, fmt.Errorf("缺少应用标签: %w", ErrTruncated)
+	}
+	t, n, err := d.Peek()
+	if err != nil {
+		return Tag{}, nil, err
+	}
+	if t.Context {
+		return Tag{}, nil, fmt.Errorf("期望应用标签，实际为上下文标签%d", t.Number)
+	}
+	start := d.offset + n
+	// 应用布尔类型的值存放在长度字段中，没有数据字节
+	if t.Number == TagBoolean {
+		d.offset = start
+		return t, nil, nil
+	}
+	end := start + int(t.Length)
+	if t.Length > uint32(len(d.data)) || end > len(d.data) {
+		return Tag{}, nil, fmt.Errorf("应用标签%d长度越界: %w", t.Number, ErrTruncated)
+	}
+	d.offset = end
+	return t, d.data[start:end], nil
+}
+
+// Application 读取并解码一个应用标签值，结果类型同DecodeApplication
+func (d *Decoder) Application() (interface{}, error) {
+	value, n, err := DecodeApplication(d.data[d.offset:])
+	if err != nil {
+		return nil, err
+	}
+	d.offset += n
+	return value, nil
+}
+
+// ApplicationUnsigned 读取应用标签Unsigned
+func (d *Decoder) ApplicationUnsigned() (uint32, error) {
+	t, value, err := d.ApplicationValue()
+	if err != nil {
+		return 0, err
+	}
+	if t.Number != TagUnsignedInt || len(value) == 0 || len(value) > 4 {
+		return 0, fmt.Errorf("期望应用标签Unsigned")
+	}
+	return DecodeUnsignedBytes(value), nil
+}
+
+// ApplicationSigned 读取应用标签Signed
+func (d *Decoder) ApplicationSigned() (int32, error) {
+	t, value, err := d.ApplicationValue()
+	if err != nil {
+		return 0, err
+	}
+	if t.Number != TagSignedInt || len(value) == 0 || len(value) > 4 {
+		return 0, fmt.Errorf("期望应用标签Signed")
+	}
+	return DecodeSignedBytes(value), nil
+}
+
+// ApplicationDate 读取应用标签Date
+func (d *Decoder) ApplicationDate() (Date, error) {
+	t, value, err := d.ApplicationValue()
+	if err != nil || t.Number != TagDate || len(value) != 4 {
+		return Date{}, fmt.Errorf("日期值无效")
+	}
+	return DecodeDateValue(value), nil
+}
+
+// ApplicationTime 读取应用标签Time
+func (d *Decoder) ApplicationTime() (Time, error) {
+	t, value, err := d.ApplicationValue()
+	if err != nil || t.Number != TagTime || len(value) != 4 {
+		return Time{}, fmt.Errorf("时间值无效")
+	}
+	return DecodeTimeValue(value), nil
+}
+
+// ApplicationDateTime 读取应用标签Date和Time组成的BACnetDateTime
+func (d *Decoder) ApplicationDateTime() (time.Time, error) {
+	date, err := d.ApplicationDate()
+	if err != nil {
+		return time.Time{}, err
+	}
+	tm, err := d.ApplicationTime()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return DateTime(date, tm), nil
+}
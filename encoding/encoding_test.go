@@ -0,0 +1,578 @@
+package encoding
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+func TestApplicationRoundTrip(t *testing.T) {
+	values := []interface{}{
+		nil,
+		true,
+		false,
+		uint32(0),
+		uint32(0x12345678),
+		int32(-1),
+		int32(-70000),
+		int64(-1 << 40),
+		int64(1<<63 - 1),
+		float32(21.5),
+		float64(-3.25),
+		[]byte{0x01, 0x02},
+		"温度",
+		strings.Repeat("x", 300),
+		BitString{true, false, true, true, false, false, false, false, true},
+		Enumerated(3),
+		Date{Year: 124, Month: 5, Day: 17, Weekday: 5},
+		Time{Hour: 13, Minute: 30, Second: 0, Hundredths: 0xFF},
+		model.ObjectIdentifier{Type: model.ObjectTypeAnalogInput, Instance: 4194302},
+	}
+	for _, value := range values {
+		encoded, err := EncodeApplication(value)
+		if err != nil {
+			t.Fatalf("EncodeApplication(%v) error: %v", value, err)
+		}
+		decoded, n, err := DecodeApplication(encoded)
+		if err != nil {
+			t.Fatalf("DecodeApplication(% X) error: %v", encoded, err)
+		}
+		if n != len(encoded) {
+			t.Errorf("%T: consumed %d of %d bytes", value, n, len(encoded))
+		}
+		if !reflect.DeepEqual(decoded, value) {
+			t.Errorf("round trip mismatch: got %#v, want %#v", decoded, value)
+		}
+	}
+}
+
+func TestEncodeKnownValues(t *testing.T) {
+	tests := []struct {
+		name string
+		got  []byte
+		want []byte
+	}{
+		{"unsigned", EncodeUnsigned(72), []byte{0x21, 0x48}},
+		{"signed", EncodeSigned(-1), []byte{0x31, 0xFF}},
+		{"signed 64", EncodeSigned64(-1 << 40), []byte{0x35, 0x06, 0xFF, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{"signed 64 minimal", EncodeSigned64(-129), []byte{0x32, 0xFF, 0x7F}},
+		{"double", EncodeDouble(1.0), []byte{0x55, 0x08, 0x3F, 0xF0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{"octet string", EncodeOctetString([]byte{0xDE, 0xAD}), []byte{0x62, 0xDE, 0xAD}},
+		{"real", EncodeReal(72.0), []byte{0x44, 0x42, 0x90, 0x00, 0x00}},
+		{"enumerated", EncodeEnumerated(1), []byte{0x91, 0x01}},
+		{"character string", EncodeCharacterString("AB"), []byte{0x73, 0x00, 0x41, 0x42}},
+		{"bit string", EncodeBitString([]bool{false, true, false, false}), []byte{0x82, 0x04, 0x40}},
+		{"object identifier", EncodeObjectIdentifier(model.ObjectIdentifier{Type: model.ObjectTypeAnalogInput, Instance: 1}), []byte{0xC4, 0x00, 0x00, 0x00, 0x01}},
+		{"extended tag number", EncodeTag(20, true, 1), []byte{0xF9, 20}},
+		{"16-bit length", EncodeTag(TagOctetString, false, 300), []byte{0x65, 254, 0x01, 0x2C}},
+	}
+	for _, tt := range tests {
+		if !bytes.Equal(tt.got, tt.want) {
+			t.Errorf("%s: got % X, want % X", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestDecodeApplicationErrors(t *testing.T) {
+	inputs := [][]byte{
+		{},
+		{0x21}, // 长度越界
+		{0x35, 0x09, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09}, // Signed超过8字节
+		{0x44, 0x00, 0x00}, // REAL长度不足
+		{0x75, 0x02, 0x04}, // 不支持的字符集
+		{0x09, 0x01},       // 上下文标签
+		{0xD0},             // 保留的应用标签
+	}
+	for _, input := range inputs {
+		if _, _, err := DecodeApplication(input); err == nil {
+			t.Errorf("DecodeApplication(% X) expected error", input)
+		}
+	}
+}
+
+func TestDecoderConstructed(t *testing.T) {
+	// [0] 对象标识符，[3] { Real, [1] { [0] TRUE } }，[4] 优先级，[20] { Null }
+	inner := EncodeConstructed(1, EncodeContextBoolean(0, true))
+	data := EncodeContextObjectIdentifier(0, model.ObjectIdentifier{Type: model.ObjectTypeAnalogValue, Instance: 7})
+	data = append(data, EncodeConstructed(3, append(EncodeReal(1.5), inner...))...)
+	data = append(data, EncodeContextUnsigned(4, 8)...)
+	data = append(data, EncodeConstructed(20, EncodeNull())...)
+
+	d := NewDecoder(data)
+	oid, err := d.ContextObjectIdentifier(0)
+	if err != nil || oid.Instance != 7 {
+		t.Fatalf("ContextObjectIdentifier = %v, %v", oid, err)
+	}
+	content, err := d.Constructed(3)
+	if err != nil {
+		t.Fatalf("Constructed(3) error: %v", err)
+	}
+	if want := append(EncodeReal(1.5), inner...); !bytes.Equal(content, want) {
+		t.Errorf("Constructed(3) = % X, want % X", content, want)
+	}
+	if priority, err := d.ContextUnsigned(4); err != nil || priority != 8 {
+		t.Errorf("ContextUnsigned(4) = %d, %v", priority, err)
+	}
+	if !d.IsOpening(20) {
+		t.Fatalf("expected extended opening tag 20")
+	}
+	d.Opening(20)
+	if value, err := d.Application(); err != nil || value != nil {
+		t.Errorf("Application() = %v, %v", value, err)
+	}
+	if err := d.Closing(20); err != nil || !d.Done() {
+		t.Errorf("Closing(20) = %v, done=%v", err, d.Done())
+	}
+
+	// 缺少结束标签或结束标签编号不匹配
+	for _, input := range [][]byte{{0x3E, 0x21, 0x01}, {0x3E, 0x21, 0x01, 0x4F}} {
+		if _, err := NewDecoder(input).Constructed(3); err == nil {
+			t.Errorf("Constructed(% X) expected error", input)
+		}
+	}
+}
+
+func TestCharacterSets(t *testing.T) {
+	tests := []struct {
+		charset uint8
+		text    string
+		value   []byte
+	}{
+		{CharacterSetUTF8, "Zone é", []byte{0x00, 'Z', 'o', 'n', 'e', ' ', 0xC3, 0xA9}},
+		{CharacterSetUCS2, "AHU-温", []byte{0x04, 0x00, 'A', 0x00, 'H', 0x00, 'U', 0x00, '-', 0x6E, 0x29}},
+		{CharacterSetUCS4, "é", []byte{0x03, 0x00, 0x00, 0x00, 0xE9}},
+		{CharacterSetISO8859_1, "Zone é", []byte{0x05, 'Z', 'o', 'n', 'e', ' ', 0xE9}},
+	}
+	for _, tt := range tests {
+		value, err := EncodeCharacterStringValue(tt.text, tt.charset)
+		if err != nil || !bytes.Equal(value, tt.value) {
+			t.Errorf("EncodeCharacterStringValue(%q, %d) = % X, %v, want % X", tt.text, tt.charset, value, err, tt.value)
+		}
+		text, charset, err := DecodeCharacterStringValue(tt.value)
+		if err != nil || text != tt.text || charset != tt.charset {
+			t.Errorf("DecodeCharacterStringValue(% X) = %q, %d, %v", tt.value, text, charset, err)
+		}
+	}
+
+	// 超过254字节的UCS-2字符串使用16位扩展长度
+	long := strings.Repeat("名", 200)
+	encoded, err := EncodeCharacterStringCharset(long, CharacterSetUCS2)
+	if err != nil || !bytes.Equal(encoded[:4], []byte{0x75, 254, 0x01, 0x91}) {
+		t.Fatalf("EncodeCharacterStringCharset() header = % X, %v", encoded[:4], err)
+	}
+	if decoded, _, err := DecodeApplication(encoded); err != nil || decoded != long {
+		t.Errorf("DecodeApplication(long UCS-2) = %v", err)
+	}
+
+	if _, err := EncodeCharacterStringValue("温", CharacterSetISO8859_1); !errors.Is(err, ErrCharacterSetNotSupported) {
+		t.Errorf("ISO 8859-1 out of range error = %v", err)
+	}
+	if _, _, err := DecodeApplication([]byte{0x73, CharacterSetDBCS, 0x82, 0xA0}); !errors.Is(err, ErrCharacterSetNotSupported) {
+		t.Errorf("DBCS error = %v", err)
+	}
+	for _, value := range [][]byte{{CharacterSetUCS2, 0x00}, {CharacterSetUTF8, 0xFF}} {
+		if _, _, err := DecodeCharacterStringValue(value); err == nil {
+			t.Errorf("DecodeCharacterStringValue(% X) expected error", value)
+		}
+	}
+}
+
+func TestDateTimeWildcards(t *testing.T) {
+	wild := model.DateTimeAny
+	// 每年1月1日，星期任意
+	newYear := Date{Year: wild, Month: 1, Day: 1, Weekday: wild}
+	if got := EncodeDate(newYear); !bytes.Equal(got, []byte{0xA4, 0xFF, 0x01, 0x01, 0xFF}) {
+		t.Errorf("EncodeDate(wildcard) = % X", got)
+	}
+	if decoded, _, err := DecodeApplication(EncodeDate(newYear)); err != nil || decoded != newYear {
+		t.Errorf("DecodeApplication(wildcard date) = %v, %v", decoded, err)
+	}
+
+	tests := []struct {
+		date Date
+		at   time.Time
+		want bool
+	}{
+		{newYear, time.Date(2031, 1, 1, 12, 0, 0, 0, time.Local), true},
+		{newYear, time.Date(2031, 1, 2, 0, 0, 0, 0, time.Local), false},
+		{Date{Year: 124, Month: 5, Day: 17, Weekday: wild}, time.Date(2024, 5, 17, 0, 0, 0, 0, time.Local), true},
+		{Date{Year: 124, Month: 5, Day: 17, Weekday: wild}, time.Date(2025, 5, 17, 0, 0, 0, 0, time.Local), false},
+		{Date{Year: wild, Month: model.DateEvenMonth, Day: model.DateLastDay, Weekday: wild}, time.Date(2024, 2, 29, 0, 0, 0, 0, time.Local), true},
+		{Date{Year: wild, Month: model.DateEvenMonth, Day: model.DateLastDay, Weekday: wild}, time.Date(2024, 2, 28, 0, 0, 0, 0, time.Local), false},
+		{Date{Year: wild, Month: model.DateOddMonth, Day: wild, Weekday: wild}, time.Date(2024, 2, 1, 0, 0, 0, 0, time.Local), false},
+		{Date{Year: wild, Month: wild, Day: model.DateOddDay, Weekday: 5}, time.Date(2024, 5, 17, 0, 0, 0, 0, time.Local), true},
+		{Date{Year: wild, Month: wild, Day: model.DateEvenDay, Weekday: wild}, time.Date(2024, 5, 17, 0, 0, 0, 0, time.Local), false},
+	}
+	for _, tt := range tests {
+		if got := tt.date.Matches(tt.at); got != tt.want {
+			t.Errorf("%+v.Matches(%s) = %v, want %v", tt.date, tt.at.Format("2006-01-02"), got, tt.want)
+		}
+	}
+
+	// 每小时的第30分钟
+	halfPast := Time{Hour: wild, Minute: 30, Second: 0, Hundredths: wild}
+	if got := EncodeTime(halfPast); !bytes.Equal(got, []byte{0xB4, 0xFF, 0x1E, 0x00, 0xFF}) {
+		t.Errorf("EncodeTime(wildcard) = % X", got)
+	}
+	if !halfPast.Matches(time.Date(2024, 5, 17, 9, 30, 0, 0, time.Local)) || halfPast.Matches(time.Date(2024, 5, 17, 9, 31, 0, 0, time.Local)) {
+		t.Errorf("Time.Matches wildcard hour mismatch")
+	}
+	if halfPast.IsSpecific() || newYear.IsSpecific() || !NewDate(time.Now()).IsSpecific() {
+		t.Errorf("IsSpecific mismatch")
+	}
+
+	entry := model.CalendarEntry{Date: &newYear}
+	if !entry.Matches(time.Date(2040, 1, 1, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("CalendarEntry with wildcard year should match every January 1st")
+	}
+}
+
+func TestBitString(t *testing.T) {
+	bits := model.BitStringFromUint(10, 0x205)
+	if bits.Len() != 10 || !bits.Bit(0) || bits.Bit(1) || !bits.Bit(2) || !bits.Bit(9) || bits.Bit(10) {
+		t.Fatalf("BitStringFromUint(10, 0x205) = %v", bits)
+	}
+	if got := EncodeBitString(bits); !bytes.Equal(got, []byte{0x83, 0x06, 0xA0, 0x40}) {
+		t.Errorf("EncodeBitString() = % X", got)
+	}
+	bits.Set(12, true)
+	if bits.Len() != 13 || bits.Uint() != 0x1205 {
+		t.Errorf("Set(12) = %v, Uint() = %#x", bits, bits.Uint())
+	}
+
+	d := NewDecoder(EncodeContextBitString(2, model.NewBitString(4)))
+	if decoded, err := d.ContextBitString(2); err != nil || !reflect.DeepEqual(decoded, BitString{false, false, false, false}) {
+		t.Errorf("ContextBitString(2) = %v, %v", decoded, err)
+	}
+	if _, _, err := DecodeApplication([]byte{0x82, 0x08, 0x00}); err == nil {
+		t.Error("DecodeApplication(unused bits > 7) expected error")
+	}
+}
+
+func TestConstructedRoundTrip(t *testing.T) {
+	index := uint32(2)
+	device := model.ObjectIdentifier{Type: model.ObjectTypeDevice, Instance: 100}
+	ai := model.ObjectIdentifier{Type: model.ObjectTypeAnalogInput, Instance: 1}
+	ref := model.DeviceObjectPropertyReference{ObjectIdentifier: ai, PropertyIdentifier: model.PropertyIdentifierPresentValue, ArrayIndex: &index, DeviceIdentifier: &device}
+	if got, err := NewDecoder(EncodeDeviceObjectPropertyReference(ref)).DeviceObjectPropertyReference(); err != nil || !reflect.DeepEqual(got, ref) {
+		t.Errorf("DeviceObjectPropertyReference = %+v, %v", got, err)
+	}
+	objRef := model.ObjectPropertyReference{ObjectIdentifier: ai, PropertyIdentifier: model.PropertyIdentifierStatusFlags}
+	if got := EncodeObjectPropertyReference(objRef); !bytes.Equal(got, []byte{0x0C, 0x00, 0x00, 0x00, 0x01, 0x19, 0x6F}) {
+		t.Errorf("EncodeObjectPropertyReference() = % X", got)
+	}
+	if got, err := NewDecoder(EncodeObjectPropertyReference(objRef)).ObjectPropertyReference(); err != nil || !reflect.DeepEqual(got, objRef) {
+		t.Errorf("ObjectPropertyReference = %+v, %v", got, err)
+	}
+
+	at := time.Date(2024, 5, 17, 13, 30, 15, 250000000, time.Local)
+	seq := uint32(42)
+	tm := NewTime(at)
+	var unspecified time.Time
+	for _, ts := range []model.TimeStamp{{Time: &tm}, {SequenceNumber: &seq}, {DateTime: &at}, {DateTime: &unspecified}} {
+		if got, err := NewDecoder(EncodeTimeStamp(ts)).TimeStamp(); err != nil || !reflect.DeepEqual(got, ts) {
+			t.Errorf("TimeStamp round trip = %+v, %v, want %+v", got, err, ts)
+		}
+	}
+
+	for _, r := range []model.Recipient{
+		{Device: &device},
+		{Address: &model.Address{Network: 5, MAC: []byte{192, 168, 1, 10, 0xBA, 0xC0}}},
+	} {
+		if got, err := NewDecoder(EncodeRecipient(r)).Recipient(); err != nil || !reflect.DeepEqual(got, r) {
+			t.Errorf("Recipient round trip = %+v, %v", got, err)
+		}
+	}
+	dest := model.Destination{
+		ValidDays:         model.BitStringFromUint(7, 0x1F),
+		FromTime:          Time{Hour: 8},
+		ToTime:            Time{Hour: 18},
+		Recipient:         model.Recipient{Device: &device},
+		ProcessIdentifier: 7,
+		Transitions:       model.BitStringFromUint(3, 0x07),
+	}
+	if got, err := NewDecoder(EncodeDestination(dest)).Destination(); err != nil || !reflect.DeepEqual(got, dest) {
+		t.Errorf("Destination round trip = %+v, %v", got, err)
+	}
+
+	period := model.DateRange{StartDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)}
+	encoded := EncodeDateRange(period)
+	if !bytes.Equal(encoded[5:], []byte{0xA4, 0xFF, 0xFF, 0xFF, 0xFF}) {
+		t.Errorf("EncodeDateRange() open end = % X", encoded)
+	}
+	if got, err := NewDecoder(encoded).DateRange(); err != nil || !got.StartDate.Equal(period.StartDate) || !got.EndDate.IsZero() {
+		t.Errorf("DateRange round trip = %+v, %v", got, err)
+	}
+
+	tv := model.TimeValue{Time: 8*time.Hour + 30*time.Minute, Value: float32(21.5)}
+	encoded, err := EncodeTimeValue(tv)
+	if err != nil || !bytes.Equal(encoded[:5], []byte{0xB4, 8, 30, 0, 0}) {
+		t.Fatalf("EncodeTimeValue() = % X, %v", encoded, err)
+	}
+	if got, err := NewDecoder(encoded).TimeValue(); err != nil || !reflect.DeepEqual(got, tv) {
+		t.Errorf("TimeValue round trip = %+v, %v", got, err)
+	}
+
+	newYear := Date{Year: 0xFF, Month: 1, Day: 1, Weekday: 0xFF}
+	for _, entry := range []model.CalendarEntry{
+		{Date: &newYear},
+		{DateRange: &model.DateRange{StartDate: time.Date(2024, 7, 1, 0, 0, 0, 0, time.Local), EndDate: time.Date(2024, 8, 31, 0, 0, 0, 0, time.Local)}},
+		{WeekNDay: &model.WeekNDay{Month: 0xFF, WeekOfMonth: 6, DayOfWeek: 5}},
+	} {
+		if got, err := NewDecoder(EncodeCalendarEntry(entry)).CalendarEntry(); err != nil || !reflect.DeepEqual(got, entry) {
+			t.Errorf("CalendarEntry round trip = %+v, %v, want %+v", got, err, entry)
+		}
+	}
+	calendar := model.ObjectIdentifier{Type: model.ObjectTypeCalendar, Instance: 1}
+	for _, event := range []model.SpecialEvent{
+		{Calendar: &model.CalendarEntry{Date: &newYear}, TimeValues: []model.TimeValue{tv}, Priority: 3},
+		{CalendarReference: &calendar, TimeValues: []model.TimeValue{}, Priority: 16},
+	} {
+		encoded, err := EncodeSpecialEvent(event)
+		if err != nil {
+			t.Fatalf("EncodeSpecialEvent() error = %v", err)
+		}
+		if got, err := NewDecoder(encoded).SpecialEvent(); err != nil || !reflect.DeepEqual(got, event) {
+			t.Errorf("SpecialEvent round trip = %+v, %v, want %+v", got, err, event)
+		}
+	}
+	encoded, _ = EncodeSpecialEvent(model.SpecialEvent{CalendarReference: &calendar, Priority: 17})
+	if _, err := NewDecoder(encoded).SpecialEvent(); err == nil {
+		t.Error("SpecialEvent priority 17: want error")
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	type reference struct {
+		Object   model.ObjectIdentifier   `bacnet:"0"`
+		Property model.PropertyIdentifier `bacnet:"1,enum"`
+		Index    *uint32                  `bacnet:"2"`
+	}
+	type request struct {
+		Process    uint32      `bacnet:"0"`
+		Target     reference   `bacnet:"1"`
+		Confirmed  bool        `bacnet:"2"`
+		Lifetime   uint32      `bacnet:"3,optional"`
+		References []reference `bacnet:"4"`
+		Name       string
+		Offset     int64
+		Flags      BitString
+		Value      interface{} `bacnet:"5"`
+		Raw        []byte      `bacnet:"6,raw"`
+		internal   int
+	}
+
+	index := uint32(3)
+	ai := model.ObjectIdentifier{Type: model.ObjectTypeAnalogInput, Instance: 1}
+	in := request{
+		Process:    7,
+		Target:     reference{Object: ai, Property: model.PropertyIdentifierPresentValue, Index: &index},
+		Confirmed:  true,
+		References: []reference{{Object: ai, Property: model.PropertyIdentifierStatusFlags}},
+		Name:       "AHU-1",
+		Offset:     -1 << 40,
+		Flags:      model.BitStringFromUint(4, 0x9),
+		Value:      float32(21.5),
+		Raw:        EncodeReal(1),
+	}
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal() error: %v", err)
+	}
+	// 手工编码的前缀：[0] 7，[1] { [0] oid，[1] 85，[2] 3 }，[2] TRUE，（省略[3]）
+	want := EncodeContextUnsigned(0, 7)
+	want = append(want, EncodeConstructed(1, append(append(EncodeContextObjectIdentifier(0, ai), EncodeContextEnumerated(1, 85)...), EncodeContextUnsigned(2, 3)...))...)
+	want = append(want, EncodeContextBoolean(2, true)...)
+	if !bytes.HasPrefix(data, want) {
+		t.Errorf("Marshal() = % X, want prefix % X", data, want)
+	}
+
+	var out request
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal() error: %v", err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("Unmarshal() = %+v, want %+v", out, in)
+	}
+
+	invalid := [][]byte{
+		data[:len(data)-1], // 截断
+		append(append([]byte{}, data...), 0x21, 0x01), // 多余数据
+		data[2:], // 缺少[0]
+	}
+	for _, input := range invalid {
+		if err := Unmarshal(input, &out); err == nil {
+			t.Errorf("Unmarshal(% X) expected error", input)
+		}
+	}
+
+	// 整数超出字段范围
+	var small struct {
+		Priority uint8 `bacnet:"4"`
+	}
+	if err := Unmarshal(EncodeContextUnsigned(4, 256), &small); err == nil {
+		t.Error("Unmarshal(priority 256 into uint8) expected error")
+	}
+	var bad struct {
+		Value []byte `bacnet:",raw"`
+	}
+	if _, err := Marshal(bad); err == nil {
+		t.Error("Marshal(raw without context number) expected error")
+	}
+}
+
+func TestReader(t *testing.T) {
+	r := NewReader([]byte{0x81, 0x0A, 0x00, 0x10, 0x00, 0x80, 0x00, 0x01, 0xAA})
+	if b, err := r.Byte(); err != nil || b != 0x81 {
+		t.Errorf("Byte() = %02X, %v", b, err)
+	}
+	if b, err := r.Peek(); err != nil || b != 0x0A || r.Offset() != 1 {
+		t.Errorf("Peek() = %02X, %v, offset %d", b, err, r.Offset())
+	}
+	r.Byte()
+	if v, err := r.Uint16(); err != nil || v != 0x0010 {
+		t.Errorf("Uint16() = %d, %v", v, err)
+	}
+	if v, err := r.Uint32(); err != nil || v != 0x00800001 {
+		t.Errorf("Uint32() = %08X, %v", v, err)
+	}
+	if r.Len() != 1 || !bytes.Equal(r.Remaining(), []byte{0xAA}) {
+		t.Errorf("Len() = %d, Remaining() = % X", r.Len(), r.Remaining())
+	}
+
+	// 越界读取返回ErrTruncated且不移动偏移量
+	if _, err := r.Uint16(); !errors.Is(err, ErrTruncated) || r.Offset() != 8 {
+		t.Errorf("Uint16() past end = %v, offset %d", err, r.Offset())
+	}
+	if _, err := r.Bytes(-1); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Bytes(-1) = %v, want ErrTruncated", err)
+	}
+	r.Byte()
+	if _, err := r.Byte(); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Byte() at end = %v, want ErrTruncated", err)
+	}
+
+	// Decoder和Unmarshal用同样的错误区分数据不足和多余数据
+	var request struct {
+		Object model.ObjectIdentifier `bacnet:"0"`
+	}
+	if err := Unmarshal([]byte{0x0C, 0x00, 0x80}, &request); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Unmarshal(truncated) = %v, want ErrTruncated", err)
+	}
+	if err := Unmarshal(nil, &request); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Unmarshal(empty) = %v, want ErrTruncated", err)
+	}
+	data := append(EncodeContextObjectIdentifier(0, model.ObjectIdentifier{}), 0x21, 0x01)
+	if err := Unmarshal(data, &request); !errors.Is(err, ErrTrailingData) {
+		t.Errorf("Unmarshal(trailing) = %v, want ErrTrailingData", err)
+	}
+}
+
+// FuzzDecodeApplication 任意输入都不能panic，成功解码的值重新编码后必须能稳定往返
+func FuzzDecodeApplication(f *testing.F) {
+	for _, seed := range [][]byte{
+		EncodeNull(), EncodeBoolean(true), EncodeUnsigned(70000), EncodeReal(21.5),
+		EncodeCharacterString("温度"), EncodeBitString(BitString{true, false, true}),
+		EncodeDate(Date{Year: 124, Month: 1, Day: 2, Weekday: 0xFF}), {0x35, 0x09, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		value, n, err := DecodeApplication(data)
+		if err != nil {
+			return
+		}
+		if n <= 0 || n > len(data) {
+			t.Fatalf("DecodeApplication(% X) consumed %d bytes", data, n)
+		}
+		encoded, err := EncodeApplication(value)
+		if err != nil {
+			return
+		}
+		// 比较重新编码的字节，NaN等值无法直接比较
+		again, _, err := DecodeApplication(encoded)
+		if err != nil {
+			t.Fatalf("DecodeApplication(% X) = %v", encoded, err)
+		}
+		if reencoded, _ := EncodeApplication(again); !bytes.Equal(reencoded, encoded) {
+			t.Fatalf("round trip of %#v = % X, want % X", value, reencoded, encoded)
+		}
+	})
+}
+
+// FuzzDecoder 构造类型的解码器遇到任意输入都不能panic或越界
+func FuzzDecoder(f *testing.F) {
+	ai := model.ObjectIdentifier{Type: model.ObjectTypeAnalogInput, Instance: 1}
+	seq := uint32(5)
+	f.Add(EncodeDeviceObjectPropertyReference(model.DeviceObjectPropertyReference{ObjectIdentifier: ai, PropertyIdentifier: 85, DeviceIdentifier: &ai}))
+	f.Add(EncodeTimeStamp(model.TimeStamp{SequenceNumber: &seq}))
+	f.Add(EncodeConstructed(0, EncodeUnsigned(1)))
+	f.Add(EncodeDestination(model.Destination{ValidDays: BitString{true}, Recipient: model.Recipient{Address: &model.Address{MAC: []byte{1}}}}))
+
+	decoders := []func(d *Decoder) error{
+		func(d *Decoder) error { _, err := d.DeviceObjectPropertyReference(); return err },
+		func(d *Decoder) error { _, err := d.TimeStamp(); return err },
+		func(d *Decoder) error { _, err := d.Recipient(); return err },
+		func(d *Decoder) error { _, err := d.Destination(); return err },
+		func(d *Decoder) error { _, err := d.DateRange(); return err },
+		func(d *Decoder) error { _, err := d.TimeValue(); return err },
+		func(d *Decoder) error { _, err := d.Constructed(0); return err },
+		func(d *Decoder) error { _, err := d.ContextBitString(0); return err },
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, decode := range decoders {
+			d := NewDecoder(data)
+			decode(d)
+			if d.Offset() > len(data) {
+				t.Fatalf("offset %d beyond %d bytes", d.Offset(), len(data))
+			}
+		}
+	})
+}
+
+// TestWriter Writer的编码与各Encode函数一致
+func TestWriter(t *testing.T) {
+	oid := model.ObjectIdentifier{Type: model.ObjectTypeAnalogValue, Instance: 0x3FFFFF}
+	values := []interface{}{nil, true, false, uint32(0), uint32(300), uint32(1 << 24), float32(72.5), "",
+		strings.Repeat("x", 300), Enumerated(5), oid, int32(-2), BitString{true, false}, model.EventStateOffNormal}
+
+	w := GetWriter()
+	defer PutWriter(w)
+	var want []byte
+	for _, value := range values {
+		if err := w.Application(value); err != nil {
+			t.Fatalf("Application(%#v) = %v", value, err)
+		}
+		encoded, _ := EncodeApplication(value)
+		want = append(want, encoded...)
+	}
+	w.OpeningTag(3)
+	w.ContextObjectIdentifier(0, oid)
+	w.ContextEnumerated(1, 85)
+	w.ContextUnsigned(20, 70000)
+	w.ClosingTag(3)
+	w.OpeningTag(20)
+	w.ClosingTag(20)
+	want = append(want, EncodeConstructed(3, append(append(EncodeContextObjectIdentifier(0, oid),
+		EncodeContextEnumerated(1, 85)...), EncodeContextUnsigned(20, 70000)...))...)
+	want = append(want, EncodeConstructed(20, nil)...)
+	if !bytes.Equal(w.Bytes(), want) {
+		t.Errorf("Writer = % X\nwant     % X", w.Bytes(), want)
+	}
+
+	mark := w.Len()
+	w.Real(1)
+	w.Truncate(mark)
+	if err := w.Application(struct{}{}); err == nil || w.Len() != mark {
+		t.Errorf("Application(struct{}{}) = %v, len %d; want error and nothing written", err, w.Len())
+	}
+	w.Reset()
+	if w.Len() != 0 {
+		t.Errorf("Len() after Reset = %d", w.Len())
+	}
+}
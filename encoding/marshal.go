@@ -0,0 +1,474 @@
+package encoding
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// Marshal和Unmarshal按结构体字段顺序编解码BACnet序列（SEQUENCE），字段标签格式为
+//
+//	`bacnet:"编号[,选项...]"`
+//
+// 编号为上下文标签编号，省略编号（如`bacnet:",enum"`）或没有标签的字段按应用标签编码；
+// 标签为"-"的字段被忽略。选项：
+//
+//	optional  零值时省略，解码时缺少该字段不报错（指针字段总是可选的）
+//	enum      整数按Enumerated编码
+//	raw       []byte字段保存开始/结束标签之间已编码的数据（ABSTRACT-SYNTAX.&Type），必须带上下文编号
+//
+// 字段类型与BACnet类型的对应关系：bool为Boolean，无符号整数为Unsigned（或Enumerated），
+// 有符号整数为Signed，float32为Real，float64为Double，[]byte为OctetString，string为CharacterString，
+// BitString、Date、Time、model.ObjectIdentifier为同名类型，interface{}为任意应用标签值。
+// 带上下文编号的结构体编码为构造值，不带编号的结构体内联展开；
+// 其他切片为SEQUENCE OF，必须带上下文编号，元素依次编码在开始/结束标签之间。
+
+var (
+	bitStringType        = reflect.TypeOf(BitString(nil))
+	bytesType            = reflect.TypeOf([]byte(nil))
+	dateType             = reflect.TypeOf(Date{})
+	timeType             = reflect.TypeOf(Time{})
+	objectIdentifierType = reflect.TypeOf(model.ObjectIdentifier{})
+)
+
+// fieldTag 解析后的字段标签
+type fieldTag struct {
+	context  bool
+	number   uint8
+	optional bool
+	enum     bool
+	raw      bool
+}
+
+// parseFieldTag 解析结构体字段的bacnet标签，skip为true表示忽略该字段
+func parseFieldTag(field reflect.StructField) (tag fieldTag, skip bool, err error) {
+	value, ok := field.Tag.Lookup("bacnet")
+	if !ok {
+		return tag, false, nil
+	}
+	if value == "-" {
+		return tag, true, nil
+	}
+	parts := strings.Split(value, ",")
+	if parts[0] != "" {
+		number, err := strconv.ParseUint(parts[0], 10, 8)
+		if err != nil || number > 254 {
+			return tag, false, fmt.Errorf("字段%s的上下文标签编号无效: %q", field.Name, parts[0])
+		}
+		tag.context, tag.number = true, uint8(number)
+	}
+	for _, option := range parts[1:] {
+		switch option {
+		case "optional":
+			tag.optional = true
+		case "enum":
+			tag.enum = true
+		case "raw":
+			if !tag.context || field.Type.Kind() != reflect.Slice || field.Type.Elem().Kind() != reflect.Uint8 {
+				return tag, false, fmt.Errorf("字段%s: raw只能用于带上下文编号的[]byte字段", field.Name)
+			}
+			tag.raw = true
+		default:
+			return tag, false, fmt.Errorf("字段%s的标签选项未知: %q", field.Name, option)
+		}
+	}
+	return tag, false, nil
+}
+
+// Marshal 将结构体编码为BACnet序列，不包含外层标签
+func Marshal(v interface{}) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Marshal需要结构体，实际为%T", v)
+	}
+	return marshalStruct(rv)
+}
+
+// marshalStruct 依次编码结构体字段
+func marshalStruct(rv reflect.Value) ([]byte, error) {
+	var out []byte
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag, skip, err := parseFieldTag(field)
+		if err != nil {
+			return nil, err
+		}
+		if skip {
+			continue
+		}
+		fv := rv.Field(i)
+		if fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		} else if tag.optional && fv.IsZero() {
+			continue
+		}
+		encoded, err := marshalField(fv, tag)
+		if err != nil {
+			return nil, fmt.Errorf("字段%s: %w", field.Name, err)
+		}
+		out = append(out, encoded...)
+	}
+	return out, nil
+}
+
+// marshalField 编码一个字段值
+func marshalField(fv reflect.Value, tag fieldTag) ([]byte, error) {
+	switch {
+	case tag.raw:
+		return EncodeConstructed(tag.number, fv.Bytes()), nil
+	case fv.Kind() == reflect.Interface:
+		encoded, err := EncodeApplication(fv.Interface())
+		if err != nil || !tag.context {
+			return encoded, err
+		}
+		return EncodeConstructed(tag.number, encoded), nil
+	case fv.Kind() == reflect.Struct && !isPrimitiveType(fv.Type()):
+		content, err := marshalStruct(fv)
+		if err != nil || !tag.context {
+			return content, err
+		}
+		return EncodeConstructed(tag.number, content), nil
+	case fv.Kind() == reflect.Slice && !isPrimitiveType(fv.Type()):
+		if !tag.context {
+			return nil, fmt.Errorf("SEQUENCE OF必须使用上下文标签")
+		}
+		var content []byte
+		for i := 0; i < fv.Len(); i++ {
+			encoded, err := marshalField(fv.Index(i), fieldTag{enum: tag.enum})
+			if err != nil {
+				return nil, err
+			}
+			content = append(content, encoded...)
+		}
+		return EncodeConstructed(tag.number, content), nil
+	}
+
+	number, value, err := primitiveValue(fv, tag.enum)
+	if err != nil {
+		return nil, err
+	}
+	if tag.context {
+		return append(EncodeTag(tag.number, true, len(value)), value...), nil
+	}
+	// 应用布尔类型的值存放在长度字段中
+	if number == TagBoolean {
+		return EncodeBoolean(value[0] == 1), nil
+	}
+	return append(EncodeTag(number, false, len(value)), value...), nil
+}
+
+// isPrimitiveType 判断类型是否按基本类型编码（而不是构造值或SEQUENCE OF）
+func isPrimitiveType(t reflect.Type) bool {
+	switch t {
+	case bitStringType, bytesType, dateType, timeType, objectIdentifierType:
+		return true
+	}
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+}
+
+// primitiveValue 返回基本类型值对应的应用标签编号和数据
+func primitiveValue(fv reflect.Value, enum bool) (uint8, []byte, error) {
+	switch fv.Type() {
+	case bitStringType:
+		return TagBitString, EncodeBitStringValue(fv.Interface().(BitString)), nil
+	case dateType:
+		d := fv.Interface().(Date)
+		return TagDate, []byte{d.Year, d.Month, d.Day, d.Weekday}, nil
+	case timeType:
+		t := fv.Interface().(Time)
+		return TagTime, []byte{t.Hour, t.Minute, t.Second, t.Hundredths}, nil
+	case objectIdentifierType:
+		return TagObjectIdentifier, EncodeObjectIdentifierValue(fv.Interface().(model.ObjectIdentifier)), nil
+	}
+
+	switch fv.Kind() {
+	case reflect.Bool:
+		if fv.Bool() {
+			return TagBoolean, []byte{1}, nil
+		}
+		return TagBoolean, []byte{0}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if fv.Uint() > math.MaxUint32 {
+			return 0, nil, fmt.Errorf("无符号整数超出范围: %d", fv.Uint())
+		}
+		if enum {
+			return TagEnumerated, EncodeUnsignedBytes(uint32(fv.Uint())), nil
+		}
+		return TagUnsignedInt, EncodeUnsignedBytes(uint32(fv.Uint())), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return TagSignedInt, EncodeSignedBytes64(fv.Int()), nil
+	case reflect.Float32:
+		bits := math.Float32bits(float32(fv.Float()))
+		return TagReal, []byte{byte(bits >> 24), byte(bits >> 16), byte(bits >> 8), byte(bits)}, nil
+	case reflect.Float64:
+		return TagDouble, EncodeDouble(fv.Float())[2:], nil
+	case reflect.String:
+		return TagCharacterString, append([]byte{CharacterSetUTF8}, fv.String()...), nil
+	case reflect.Slice:
+		return TagOctetString, append([]byte(nil), fv.Bytes()...), nil
+	}
+	return 0, nil, fmt.Errorf("不支持编码的字段类型: %s", fv.Type())
+}
+
+// Unmarshal 将BACnet序列解码到v指向的结构体，数据必须被完整解析
+func Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Unmarshal需要结构体指针，实际为%T", v)
+	}
+	d := NewDecoder(data)
+	if err := unmarshalStruct(d, rv.Elem()); err != nil {
+		return err
+	}
+	if !d.Done() {
+		return fmt.Errorf("序列包含多余数据: %w", ErrTrailingData)
+	}
+	return nil
+}
+
+// unmarshalStruct 依次解码结构体字段
+func unmarshalStruct(d *Decoder, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag, skip, err := parseFieldTag(field)
+		if err != nil {
+			return err
+		}
+		if skip {
+			continue
+		}
+
+		fv := rv.Field(i)
+		optional := tag.optional || fv.Kind() == reflect.Ptr
+		if !fieldPresent(d, fv.Type(), tag) {
+			if optional {
+				continue
+			}
+			if d.Done() {
+				return fmt.Errorf("字段%s: %w", field.Name, ErrTruncated)
+			}
+			if tag.context {
+				return fmt.Errorf("字段%s: 缺少上下文标签%d", field.Name, tag.number)
+			}
+			return fmt.Errorf("字段%s: 缺少应用标签", field.Name)
+		}
+		if fv.Kind() == reflect.Ptr {
+			fv.Set(reflect.New(fv.Type().Elem()))
+			fv = fv.Elem()
+		}
+		if err := unmarshalField(d, fv, tag); err != nil {
+			return fmt.Errorf("字段%s: %w", field.Name, err)
+		}
+	}
+	return nil
+}
+
+// fieldPresent 判断下一个标签是否为该字段
+func fieldPresent(d *Decoder, t reflect.Type, tag fieldTag) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if !tag.context {
+		if d.Done() {
+			return false
+		}
+		next, _, err := d.Peek()
+		return err == nil && (!next.Context || t.Kind() == reflect.Struct && !isPrimitiveType(t))
+	}
+	if tag.raw || t.Kind() == reflect.Interface || !isPrimitiveType(t) && (t.Kind() == reflect.Struct || t.Kind() == reflect.Slice) {
+		return d.IsOpening(tag.number)
+	}
+	return d.IsContext(tag.number)
+}
+
+// unmarshalField 解码一个字段值
+func unmarshalField(d *Decoder, fv reflect.Value, tag fieldTag) error {
+	switch {
+	case tag.raw:
+		content, err := d.Constructed(tag.number)
+		if err != nil {
+			return err
+		}
+		fv.SetBytes(append([]byte{}, content...))
+		return nil
+	case fv.Kind() == reflect.Interface:
+		if !tag.context {
+			value, err := d.Application()
+			if err != nil {
+				return err
+			}
+			return setInterface(fv, value)
+		}
+		content, err := d.Constructed(tag.number)
+		if err != nil {
+			return err
+		}
+		value, n, err := DecodeApplication(content)
+		if err != nil {
+			return err
+		}
+		if n != len(content) {
+			return fmt.Errorf("上下文标签%d中只能有一个值", tag.number)
+		}
+		return setInterface(fv, value)
+	case fv.Kind() == reflect.Struct && !isPrimitiveType(fv.Type()):
+		if !tag.context {
+			return unmarshalStruct(d, fv)
+		}
+		content, err := d.Constructed(tag.number)
+		if err != nil {
+			return err
+		}
+		inner := NewDecoder(content)
+		if err := unmarshalStruct(inner, fv); err != nil {
+			return err
+		}
+		if !inner.Done() {
+			return fmt.Errorf("上下文标签%d包含多余数据", tag.number)
+		}
+		return nil
+	case fv.Kind() == reflect.Slice && !isPrimitiveType(fv.Type()):
+		if !tag.context {
+			return fmt.Errorf("SEQUENCE OF必须使用上下文标签")
+		}
+		content, err := d.Constructed(tag.number)
+		if err != nil {
+			return err
+		}
+		inner := NewDecoder(content)
+		elements := reflect.MakeSlice(fv.Type(), 0, 0)
+		for !inner.Done() {
+			element := reflect.New(fv.Type().Elem()).Elem()
+			if err := unmarshalField(inner, element, fieldTag{enum: tag.enum}); err != nil {
+				return err
+			}
+			elements = reflect.Append(elements, element)
+		}
+		fv.Set(elements)
+		return nil
+	}
+
+	if tag.context {
+		value, err := d.ContextValue(tag.number)
+		if err != nil {
+			return err
+		}
+		number, _, err := primitiveValue(fv, tag.enum)
+		if err != nil {
+			return err
+		}
+		return setPrimitive(fv, number, value)
+	}
+	t, value, err := d.ApplicationValue()
+	if err != nil {
+		return err
+	}
+	if t.Number == TagBoolean {
+		value = []byte{byte(t.Length)}
+	}
+	number, _, err := primitiveValue(fv, t.Number == TagEnumerated)
+	if err != nil {
+		return err
+	}
+	if number != t.Number {
+		return fmt.Errorf("期望应用标签%d，实际为%d", number, t.Number)
+	}
+	return setPrimitive(fv, number, value)
+}
+
+// setInterface 将解码出的应用标签值赋给interface{}字段
+func setInterface(fv reflect.Value, value interface{}) error {
+	if value == nil {
+		fv.Set(reflect.Zero(fv.Type()))
+		return nil
+	}
+	rv := reflect.ValueOf(value)
+	if !rv.Type().AssignableTo(fv.Type()) {
+		return fmt.Errorf("值类型%T不能赋给%s", value, fv.Type())
+	}
+	fv.Set(rv)
+	return nil
+}
+
+// setPrimitive 按应用标签编号解析基本类型数据并赋给字段
+func setPrimitive(fv reflect.Value, number uint8, value []byte) error {
+	switch number {
+	case TagBoolean:
+		if len(value) != 1 || value[0] > 1 {
+			return fmt.Errorf("布尔值无效")
+		}
+		fv.SetBool(value[0] == 1)
+	case TagUnsignedInt, TagEnumerated:
+		if len(value) == 0 || len(value) > 4 {
+			return fmt.Errorf("无符号整数长度无效: %d", len(value))
+		}
+		n := uint64(DecodeUnsignedBytes(value))
+		if fv.OverflowUint(n) {
+			return fmt.Errorf("无符号整数%d超出%s范围", n, fv.Type())
+		}
+		fv.SetUint(n)
+	case TagSignedInt:
+		if len(value) == 0 || len(value) > 8 {
+			return fmt.Errorf("有符号整数长度无效: %d", len(value))
+		}
+		n := DecodeSignedBytes64(value)
+		if fv.OverflowInt(n) {
+			return fmt.Errorf("有符号整数%d超出%s范围", n, fv.Type())
+		}
+		fv.SetInt(n)
+	case TagReal:
+		if len(value) != 4 {
+			return fmt.Errorf("REAL长度无效: %d", len(value))
+		}
+		fv.SetFloat(float64(math.Float32frombits(DecodeUnsignedBytes(value))))
+	case TagDouble:
+		if len(value) != 8 {
+			return fmt.Errorf("DOUBLE长度无效: %d", len(value))
+		}
+		fv.SetFloat(math.Float64frombits(uint64(DecodeUnsignedBytes(value[:4]))<<32 | uint64(DecodeUnsignedBytes(value[4:]))))
+	case TagOctetString:
+		fv.SetBytes(append([]byte(nil), value...))
+	case TagCharacterString:
+		s, _, err := DecodeCharacterStringValue(value)
+		if err != nil {
+			return err
+		}
+		fv.SetString(s)
+	case TagBitString:
+		if len(value) < 1 || value[0] > 7 {
+			return fmt.Errorf("位串值无效")
+		}
+		fv.Set(reflect.ValueOf(BitString(DecodeBitStringValue(value))))
+	case TagDate, TagTime, TagObjectIdentifier:
+		if len(value) != 4 {
+			return fmt.Errorf("应用标签%d长度无效: %d", number, len(value))
+		}
+		switch number {
+		case TagDate:
+			fv.Set(reflect.ValueOf(DecodeDateValue(value)))
+		case TagTime:
+			fv.Set(reflect.ValueOf(DecodeTimeValue(value)))
+		default:
+			fv.Set(reflect.ValueOf(DecodeObjectIdentifierValue(value)))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package encoding
+
+import (
+	"math"
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// EncodeUnsignedBytes 以最少字节数编码无符号整数（大端序）
+func EncodeUnsignedBytes(v uint32) []byte {
+	switch {
+	case v < 0x100:
+		return []byte{byte(v)}
+	case v < 0x10000:
+		return []byte{byte(v >> 8), byte(v)}
+	case v < 0x1000000:
+		return []byte{byte(v >> 16), byte(v >> 8), byte(v)}
+	default:
+		return []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+	}
+}
+
+// EncodeSignedBytes 以最少字节数编码补码有符号整数（大端序）
+func EncodeSignedBytes(v int32) []byte {
+	switch {
+	case v >= -0x80 && v < 0x80:
+		return []byte{byte(v)}
+	case v >= -0x8000 && v < 0x8000:
+		return []byte{byte(v >> 8), byte(v)}
+	case v >= -0x800000 && v < 0x800000:
+		return []byte{byte(v >> 16), byte(v >> 8), byte(v)}
+	default:
+		return []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+	}
+}
+
+// EncodeSignedBytes64 以最少字节数编码64位补码有符号整数（大端序）
+func EncodeSignedBytes64(v int64) []byte {
+	n := 1
+	for n < 8 && (v < -(1<<(8*n-1)) || v >= 1<<(8*n-1)) {
+		n++
+	}
+	value := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		value[i] = byte(v)
+		v >>= 8
+	}
+	return value
+}
+
+// DecodeUnsignedBytes 解码大端序无符号整数
+func DecodeUnsignedBytes(data []byte) uint32 {
+	var v uint32
+	for _, b := range data {
+		v = v<<8 | uint32(b)
+	}
+	return v
+}
+
+// DecodeSignedBytes 解码大端序补码有符号整数
+func DecodeSignedBytes(data []byte) int32 {
+	if len(data) == 0 {
+		return 0
+	}
+	v := int32(int8(data[0]))
+	for _, b := range data[1:] {
+		v = v<<8 | int32(b)
+	}
+	return v
+}
+
+// DecodeSignedBytes64 解码不超过8字节的大端序补码有符号整数
+func DecodeSignedBytes64(data []byte) int64 {
+	if len(data) == 0 {
+		return 0
+	}
+	v := int64(int8(data[0]))
+	for _, b := range data[1:] {
+		v = v<<8 | int64(b)
+	}
+	return v
+}
+
+// EncodeObjectIdentifierValue 编码对象标识符的4字节值（类型10位，实例22位）
+func EncodeObjectIdentifierValue(oid model.ObjectIdentifier) []byte {
+	v := uint32(oid.Type)<<22 | (oid.Instance & 0x3FFFFF)
+	return []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+}
+
+// DecodeObjectIdentifierValue 解码对象标识符的4字节值
+func DecodeObjectIdentifierValue(data []byte) model.ObjectIdentifier {
+	v := DecodeUnsignedBytes(data)
+	return model.ObjectIdentifier{
+		Type:     model.ObjectType(v >> 22),
+		Instance: v & 0x3FFFFF,
+	}
+}
+
+// EncodeBitStringValue 编码位串值：首字节为未使用位数，随后按高位在前排列
+func EncodeBitStringValue(bits []bool) []byte {
+	byteCount := (len(bits) + 7) / 8
+	value := make([]byte, 1+byteCount)
+	value[0] = byte(byteCount*8 - len(bits))
+	for i, b := range bits {
+		if b {
+			value[1+i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return value
+}
+
+// DecodeBitStringValue 解码位串值
+func DecodeBitStringValue(data []byte) []bool {
+	if len(data) == 0 {
+		return nil
+	}
+	count := (len(data)-1)*8 - int(data[0])
+	if count < 0 {
+		count = 0
+	}
+	bits := make([]bool, count)
+	for i := range bits {
+		bits[i] = data[1+i/8]&(0x80>>(i%8)) != 0
+	}
+	return bits
+}
+
+// EncodeNull 编码应用标签Null
+func EncodeNull() []byte {
+	return []byte{TagNull << 4}
+}
+
+// EncodeBoolean 编码应用标签Boolean（值位于长度字段中）
+func EncodeBoolean(v bool) []byte {
+	if v {
+		return []byte{TagBoolean<<4 | 1}
+	}
+	return []byte{TagBoolean << 4}
+}
+
+// EncodeUnsigned 编码应用标签Unsigned
+func EncodeUnsigned(v uint32) []byte {
+	value := EncodeUnsignedBytes(v)
+	return append(EncodeTag(TagUnsignedInt, false, len(value)), value...)
+}
+
+// EncodeSigned 编码应用标签Signed
+func EncodeSigned(v int32) []byte {
+	value := EncodeSignedBytes(v)
+	return append(EncodeTag(TagSignedInt, false, len(value)), value...)
+}
+
+// EncodeSigned64 编码64位应用标签Signed
+func EncodeSigned64(v int64) []byte {
+	value := EncodeSignedBytes64(v)
+	return append(EncodeTag(TagSignedInt, false, len(value)), value...)
+}
+
+// EncodeReal 编码应用标签Real
+func EncodeReal(v float32) []byte {
+	bits := math.Float32bits(v)
+	return append(EncodeTag(TagReal, false, 4), byte(bits>>24), byte(bits>>16), byte(bits>>8), byte(bits))
+}
+
+// EncodeDouble 编码应用标签Double
+func EncodeDouble(v float64) []byte {
+	bits := math.Float64bits(v)
+	out := EncodeTag(TagDouble, false, 8)
+	for shift := 56; shift >= 0; shift -= 8 {
+		out = append(out, byte(bits>>shift))
+	}
+	return out
+}
+
+// EncodeOctetString 编码应用标签OctetString
+func EncodeOctetString(v []byte) []byte {
+	return append(EncodeTag(TagOctetString, false, len(v)), v...)
+}
+
+// EncodeCharacterString 编码应用标签CharacterString（UTF-8字符集）
+func EncodeCharacterString(s string) []byte {
+	value := append([]byte{CharacterSetUTF8}, []byte(s)...)
+	return append(EncodeTag(TagCharacterString, false, len(value)), value...)
+}
+
+// EncodeBitString 编码应用标签BitString，bits[0]为第一个位
+func EncodeBitString(bits []bool) []byte {
+	value := EncodeBitStringValue(bits)
+	return append(EncodeTag(TagBitString, false, len(value)), value...)
+}
+
+// EncodeEnumerated 编码应用标签Enumerated
+func EncodeEnumerated(v uint32) []byte {
+	value := EncodeUnsignedBytes(v)
+	return append(EncodeTag(TagEnumerated, false, len(value)), value...)
+}
+
+// EncodeDate 编码应用标签Date
+func EncodeDate(d Date) []byte {
+	return append(EncodeTag(TagDate, false, 4), d.Year, d.Month, d.Day, d.Weekday)
+}
+
+// EncodeTime 编码应用标签Time
+func EncodeTime(t Time) []byte {
+	return append(EncodeTag(TagTime, false, 4), t.Hour, t.Minute, t.Second, t.Hundredths)
+}
+
+// EncodeObjectIdentifier 编码应用标签ObjectIdentifier
+func EncodeObjectIdentifier(oid model.ObjectIdentifier) []byte {
+	return append(EncodeTag(TagObjectIdentifier, false, 4), EncodeObjectIdentifierValue(oid)...)
+}
+
+// BitString BACnet位串，见model.BitString
+type BitString = model.BitString
+
+// Date BACnet日期的4字节值，0xFF表示通配（任意），见model.Date
+type Date = model.Date
+
+// Time BACnet时间的4字节值，0xFF表示通配（任意），见model.Time
+type Time = model.Time
+
+// NewDate 由time.Time生成BACnet日期
+func NewDate(t time.Time) Date {
+	return model.NewDate(t)
+}
+
+// NewTime 由time.Time生成BACnet时间
+func NewTime(t time.Time) Time {
+	return model.NewTime(t)
+}
+
+// DecodeDateValue 解码日期的4字节值
+func DecodeDateValue(data []byte) Date {
+	return Date{Year: data[0], Month: data[1], Day: data[2], Weekday: data[3]}
+}
+
+// DecodeTimeValue 解码时间的4字节值
+func DecodeTimeValue(data []byte) Time {
+	return Time{Hour: data[0], Minute: data[1], Second: data[2], Hundredths: data[3]}
+}
+
+// DateTime 将日期和时间合成为本地时间，通配符按零值处理
+func DateTime(d Date, t Time) time.Time {
+	wild := func(b uint8) int {
+		if b == 0xFF {
+			return 0
+		}
+		return int(b)
+	}
+	return time.Date(1900+wild(d.Year), time.Month(wild(d.Month)), wild(d.Day),
+		wild(t.Hour), wild(t.Minute), wild(t.Second), wild(t.Hundredths)*10000000, time.Local)
+}
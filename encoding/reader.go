@@ -0,0 +1,86 @@
+package encoding
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrTruncated 数据在字段或标签结束前用完
+var ErrTruncated = errors.New("data truncated")
+
+// ErrTrailingData 数据解析完成后还有多余字节
+var ErrTrailingData = errors.New("trailing data")
+
+// Reader 按顺序读取定长字段（BVLC、NPDU、APDU头部等未标签化的数据），
+// 所有读取都做长度检查，越界时返回ErrTruncated而不会panic
+type Reader struct {
+	data   []byte
+	offset int
+}
+
+// NewReader 创建读取data的Reader
+func NewReader(data []byte) *Reader {
+	return &Reader{data: data}
+}
+
+// Offset 返回已读取的字节数
+func (r *Reader) Offset() int {
+	return r.offset
+}
+
+// Len 返回剩余的字节数
+func (r *Reader) Len() int {
+	return len(r.data) - r.offset
+}
+
+// Remaining 返回尚未读取的数据
+func (r *Reader) Remaining() []byte {
+	return r.data[r.offset:]
+}
+
+// Peek 查看下一个字节但不移动偏移量
+func (r *Reader) Peek() (byte, error) {
+	if r.Len() < 1 {
+		return 0, fmt.Errorf("偏移%d处缺少1字节: %w", r.offset, ErrTruncated)
+	}
+	return r.data[r.offset], nil
+}
+
+// Byte 读取一个字节
+func (r *Reader) Byte() (byte, error) {
+	if r.Len() < 1 {
+		return 0, fmt.Errorf("偏移%d处缺少1字节: %w", r.offset, ErrTruncated)
+	}
+	b := r.data[r.offset]
+	r.offset++
+	return b, nil
+}
+
+// Uint16 读取大端序的2字节无符号整数
+func (r *Reader) Uint16() (uint16, error) {
+	b, err := r.Bytes(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(b), nil
+}
+
+// Uint32 读取大端序的4字节无符号整数
+func (r *Reader) Uint32() (uint32, error) {
+	b, err := r.Bytes(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(b), nil
+}
+
+// Bytes 读取n个字节，返回的切片与原数据共享内存
+func (r *Reader) Bytes(n int) ([]byte, error) {
+	if n < 0 || r.Len() < n {
+		return nil, fmt.Errorf("偏移%d处缺少%d字节: %w", r.offset, n, ErrTruncated)
+	}
+	b := r.data[r.offset : r.offset+n]
+	r.offset += n
+	return b, nil
+}
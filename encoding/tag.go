@@ -0,0 +1,124 @@
+// Package encoding 实现BACnet应用层数据的标签编码规则（ASHRAE 135 第20.2节）
+package encoding
+
+import "fmt"
+
+// 应用标签编号（ASHRAE 135 第20.2.1.4节），13-15保留给ASHRAE
+const (
+	TagNull             = 0
+	TagBoolean          = 1
+	TagUnsignedInt      = 2
+	TagSignedInt        = 3
+	TagReal             = 4
+	TagDouble           = 5
+	TagOctetString      = 6
+	TagCharacterString  = 7
+	TagBitString        = 8
+	TagEnumerated       = 9
+	TagDate             = 10
+	TagTime             = 11
+	TagObjectIdentifier = 12
+)
+
+// Tag 表示解析出的标签头部
+type Tag struct {
+	Number  uint8  // 标签编号
+	Context bool   // true 表示上下文标签，false 表示应用标签
+	Opening bool   // 开始标签 (0x_E)
+	Closing bool   // 结束标签 (0x_F)
+	Length  uint32 // 数据长度；应用布尔类型时为布尔值本身
+}
+
+// EncodeTag 编码标签头部，标签编号大于14或长度大于4时使用扩展格式
+func EncodeTag(number uint8, context bool, length int) []byte {
+	return AppendTag(make([]byte, 0, 7), number, context, length)
+}
+
+// AppendTag 将标签头部追加到dst
+func AppendTag(dst []byte, number uint8, context bool, length int) []byte {
+	first := byte(5) // 长度大于4时在扩展长度字节中给出
+	if length < 5 {
+		first = byte(length)
+	}
+	if context {
+		first |= 0x08
+	}
+	if number <= 14 {
+		dst = append(dst, first|number<<4)
+	} else {
+		dst = append(dst, first|0xF0, number)
+	}
+
+	switch {
+	case length < 5:
+	case length < 254:
+		dst = append(dst, byte(length))
+	case length < 65536:
+		dst = append(dst, 254, byte(length>>8), byte(length))
+	default:
+		dst = append(dst, 255, byte(length>>24), byte(length>>16), byte(length>>8), byte(length))
+	}
+	return dst
+}
+
+// DecodeTag 解析一个标签头部，返回标签信息和头部占用的字节数
+func DecodeTag(data []byte) (Tag, int, error) {
+	if len(data) < 1 {
+		return Tag{}, 0, fmt.Errorf("数据太短，无法解析标签: %w", ErrTruncated)
+	}
+
+	first := data[0]
+	t := Tag{
+		Number:  first >> 4,
+		Context: first&0x08 != 0,
+	}
+	offset := 1
+
+	// 扩展标签编号
+	if t.Number == 0x0F {
+		if len(data) < 2 {
+			return Tag{}, 0, fmt.Errorf("扩展标签编号缺失: %w", ErrTruncated)
+		}
+		t.Number = data[1]
+		offset++
+	}
+
+	lvt := first & 0x07
+	switch {
+	case lvt < 5:
+		t.Length = uint32(lvt)
+		return t, offset, nil
+	case t.Context && lvt == 6:
+		t.Opening = true
+		return t, offset, nil
+	case t.Context && lvt == 7:
+		t.Closing = true
+		return t, offset, nil
+	case lvt > 5:
+		return Tag{}, 0, fmt.Errorf("应用标签不能是开始/结束标签: %02X", first)
+	}
+
+	// 扩展长度
+	if offset >= len(data) {
+		return Tag{}, 0, fmt.Errorf("扩展长度缺失: %w", ErrTruncated)
+	}
+	ext := data[offset]
+	offset++
+	switch {
+	case ext < 254:
+		t.Length = uint32(ext)
+	case ext == 254:
+		if offset+2 > len(data) {
+			return Tag{}, 0, fmt.Errorf("16位扩展长度缺失: %w", ErrTruncated)
+		}
+		t.Length = uint32(data[offset])<<8 | uint32(data[offset+1])
+		offset += 2
+	default:
+		if offset+4 > len(data) {
+			return Tag{}, 0, fmt.Errorf("32位扩展长度缺失: %w", ErrTruncated)
+		}
+		t.Length = uint32(data[offset])<<24 | uint32(data[offset+1])<<16 | uint32(data[offset+2])<<8 | uint32(data[offset+3])
+		offset += 4
+	}
+	return t, offset, nil
+}
@@ -0,0 +1,165 @@
+package encoding
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// Enumerated 应用标签Enumerated的值，用于和Unsigned区分
+type Enumerated uint32
+
+// EncodeApplication 按Go类型选择应用标签编码值，nil编码为Null，
+// model中以无符号整数为底层类型的命名类型（如EventState、EngineeringUnits）编码为Enumerated
+func EncodeApplication(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return EncodeNull(), nil
+	case bool:
+		return EncodeBoolean(v), nil
+	case uint8:
+		return EncodeUnsigned(uint32(v)), nil
+	case uint16:
+		return EncodeUnsigned(uint32(v)), nil
+	case uint32:
+		return EncodeUnsigned(v), nil
+	case uint:
+		if v > math.MaxUint32 {
+			return nil, fmt.Errorf("无符号整数超出范围: %d", v)
+		}
+		return EncodeUnsigned(uint32(v)), nil
+	case int8:
+		return EncodeSigned(int32(v)), nil
+	case int16:
+		return EncodeSigned(int32(v)), nil
+	case int32:
+		return EncodeSigned(v), nil
+	case int:
+		return EncodeSigned64(int64(v)), nil
+	case int64:
+		return EncodeSigned64(v), nil
+	case float32:
+		return EncodeReal(v), nil
+	case float64:
+		return EncodeDouble(v), nil
+	case []byte:
+		return EncodeOctetString(v), nil
+	case string:
+		return EncodeCharacterString(v), nil
+	case []bool:
+		return EncodeBitString(v), nil
+	case BitString:
+		return EncodeBitString(v), nil
+	case Enumerated:
+		return EncodeEnumerated(uint32(v)), nil
+	case Date:
+		return EncodeDate(v), nil
+	case Time:
+		return EncodeTime(v), nil
+	case model.ObjectIdentifier:
+		return EncodeObjectIdentifier(v), nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return EncodeEnumerated(uint32(rv.Uint())), nil
+	}
+	return nil, fmt.Errorf("不支持编码的值类型: %T", value)
+}
+
+// DecodeApplication 解码一个应用标签值，返回值和消耗的字节数。
+// 解码结果类型：Null为nil，Unsigned为uint32，Signed为int32（超过4字节时为int64），Real为float32，Double为float64，
+// OctetString为[]byte，CharacterString为转换为UTF-8的string，BitString为BitString，Enumerated为Enumerated，
+// Date为Date，Time为Time，ObjectIdentifier为model.ObjectIdentifier
+func DecodeApplication(data []byte) (interface{}, int, error) {
+	t, n, err := DecodeTag(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if t.Context {
+		return nil, 0, fmt.Errorf("期望应用标签，实际为上下文标签%d", t.Number)
+	}
+
+	// 应用布尔类型的值存放在长度字段中，没有数据字节
+	if t.Number == TagBoolean {
+		if t.Length > 1 {
+			return nil, 0, fmt.Errorf("布尔值无效: %d", t.Length)
+		}
+		return t.Length == 1, n, nil
+	}
+
+	end := n + int(t.Length)
+	if t.Length > uint32(len(data)) || end > len(data) {
+		return nil, 0, fmt.Errorf("应用标签%d长度越界: %w", t.Number, ErrTruncated)
+	}
+	value := data[n:end]
+
+	switch t.Number {
+	case TagNull:
+		if len(value) != 0 {
+			return nil, 0, fmt.Errorf("Null值不能带数据")
+		}
+		return nil, end, nil
+	case TagUnsignedInt:
+		if len(value) == 0 || len(value) > 4 {
+			return nil, 0, fmt.Errorf("无符号整数长度无效: %d", len(value))
+		}
+		return DecodeUnsignedBytes(value), end, nil
+	case TagSignedInt:
+		if len(value) == 0 || len(value) > 8 {
+			return nil, 0, fmt.Errorf("有符号整数长度无效: %d", len(value))
+		}
+		if len(value) > 4 {
+			return DecodeSignedBytes64(value), end, nil
+		}
+		return DecodeSignedBytes(value), end, nil
+	case TagReal:
+		if len(value) != 4 {
+			return nil, 0, fmt.Errorf("REAL长度无效: %d", len(value))
+		}
+		return math.Float32frombits(DecodeUnsignedBytes(value)), end, nil
+	case TagDouble:
+		if len(value) != 8 {
+			return nil, 0, fmt.Errorf("DOUBLE长度无效: %d", len(value))
+		}
+		bits := uint64(DecodeUnsignedBytes(value[:4]))<<32 | uint64(DecodeUnsignedBytes(value[4:]))
+		return math.Float64frombits(bits), end, nil
+	case TagOctetString:
+		return append([]byte(nil), value...), end, nil
+	case TagCharacterString:
+		str, _, err := DecodeCharacterStringValue(value)
+		if err != nil {
+			return nil, 0, err
+		}
+		return str, end, nil
+	case TagBitString:
+		if len(value) < 1 || value[0] > 7 {
+			return nil, 0, fmt.Errorf("位串值无效")
+		}
+		return BitString(DecodeBitStringValue(value)), end, nil
+	case TagEnumerated:
+		if len(value) == 0 || len(value) > 4 {
+			return nil, 0, fmt.Errorf("枚举值长度无效: %d", len(value))
+		}
+		return Enumerated(DecodeUnsignedBytes(value)), end, nil
+	case TagDate:
+		if len(value) != 4 {
+			return nil, 0, fmt.Errorf("日期长度无效: %d", len(value))
+		}
+		return DecodeDateValue(value), end, nil
+	case TagTime:
+		if len(value) != 4 {
+			return nil, 0, fmt.Errorf("时间长度无效: %d", len(value))
+		}
+		return DecodeTimeValue(value), end, nil
+	case TagObjectIdentifier:
+		if len(value) != 4 {
+			return nil, 0, fmt.Errorf("对象标识符长度无效: %d", len(value))
+		}
+		return DecodeObjectIdentifierValue(value), end, nil
+	}
+	return nil, 0, fmt.Errorf("保留的应用标签: %d", t.Number)
+}
@@ -0,0 +1,189 @@
+package encoding
+
+import (
+	"math"
+	"reflect"
+	"sync"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// maxPooledWriter 归还到池中的缓冲区的最大容量，编码过大应答（如长列表）后的缓冲区直接丢弃
+const maxPooledWriter = 64 * 1024
+
+var writerPool = sync.Pool{
+	New: func() interface{} { return &Writer{buf: make([]byte, 0, 1500)} },
+}
+
+// Writer 追加式的编码缓冲区，标签和值直接写入同一个切片，不为每个元素分配小切片。
+// 由GetWriter从池中取得，用完后以PutWriter归还，归还后不能再使用Bytes返回的切片
+type Writer struct {
+	buf []byte
+}
+
+// GetWriter 从池中取得一个空的Writer
+func GetWriter() *Writer {
+	w := writerPool.Get().(*Writer)
+	w.buf = w.buf[:0]
+	return w
+}
+
+// PutWriter 将Writer归还到池中
+func PutWriter(w *Writer) {
+	if cap(w.buf) > maxPooledWriter {
+		return
+	}
+	writerPool.Put(w)
+}
+
+// Bytes 返回已编码的数据，引用Writer内部的缓冲区
+func (w *Writer) Bytes() []byte {
+	return w.buf
+}
+
+// Len 返回已编码的字节数
+func (w *Writer) Len() int {
+	return len(w.buf)
+}
+
+// Reset 清空已编码的数据
+func (w *Writer) Reset() {
+	w.buf = w.buf[:0]
+}
+
+// Truncate 只保留前n个字节，用于放弃编码到一半的元素
+func (w *Writer) Truncate(n int) {
+	w.buf = w.buf[:n]
+}
+
+// Write 追加原始字节，实现io.Writer
+func (w *Writer) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	return len(p), nil
+}
+
+// WriteByte 追加一个字节，实现io.ByteWriter
+func (w *Writer) WriteByte(b byte) error {
+	w.buf = append(w.buf, b)
+	return nil
+}
+
+// Tag 编码标签头部
+func (w *Writer) Tag(number uint8, context bool, length int) {
+	w.buf = AppendTag(w.buf, number, context, length)
+}
+
+// OpeningTag 编码上下文开始标签
+func (w *Writer) OpeningTag(number uint8) {
+	if number <= 14 {
+		w.buf = append(w.buf, number<<4|0x0E)
+	} else {
+		w.buf = append(w.buf, 0xFE, number)
+	}
+}
+
+// ClosingTag 编码上下文结束标签
+func (w *Writer) ClosingTag(number uint8) {
+	if number <= 14 {
+		w.buf = append(w.buf, number<<4|0x0F)
+	} else {
+		w.buf = append(w.buf, 0xFF, number)
+	}
+}
+
+// unsigned 编码标签和最少字节数的无符号整数
+func (w *Writer) unsigned(number uint8, context bool, v uint32) {
+	switch {
+	case v < 0x100:
+		w.buf = append(AppendTag(w.buf, number, context, 1), byte(v))
+	case v < 0x10000:
+		w.buf = append(AppendTag(w.buf, number, context, 2), byte(v>>8), byte(v))
+	case v < 0x1000000:
+		w.buf = append(AppendTag(w.buf, number, context, 3), byte(v>>16), byte(v>>8), byte(v))
+	default:
+		w.buf = append(AppendTag(w.buf, number, context, 4), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	}
+}
+
+// ContextUnsigned 编码上下文标签Unsigned
+func (w *Writer) ContextUnsigned(number uint8, v uint32) {
+	w.unsigned(number, true, v)
+}
+
+// ContextEnumerated 编码上下文标签Enumerated
+func (w *Writer) ContextEnumerated(number uint8, v uint32) {
+	w.unsigned(number, true, v)
+}
+
+// ContextObjectIdentifier 编码上下文标签ObjectIdentifier
+func (w *Writer) ContextObjectIdentifier(number uint8, oid model.ObjectIdentifier) {
+	w.objectIdentifier(number, true, oid)
+}
+
+// objectIdentifier 编码标签和4字节的对象标识符
+func (w *Writer) objectIdentifier(number uint8, context bool, oid model.ObjectIdentifier) {
+	v := uint32(oid.Type)<<22 | oid.Instance&0x3FFFFF
+	w.buf = append(AppendTag(w.buf, number, context, 4), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// Unsigned 编码应用标签Unsigned
+func (w *Writer) Unsigned(v uint32) {
+	w.unsigned(TagUnsignedInt, false, v)
+}
+
+// Enumerated 编码应用标签Enumerated
+func (w *Writer) Enumerated(v uint32) {
+	w.unsigned(TagEnumerated, false, v)
+}
+
+// Real 编码应用标签Real
+func (w *Writer) Real(v float32) {
+	bits := math.Float32bits(v)
+	w.buf = append(w.buf, TagReal<<4|4, byte(bits>>24), byte(bits>>16), byte(bits>>8), byte(bits))
+}
+
+// CharacterString 编码应用标签CharacterString（UTF-8字符集）
+func (w *Writer) CharacterString(s string) {
+	w.buf = append(AppendTag(w.buf, TagCharacterString, false, len(s)+1), CharacterSetUTF8)
+	w.buf = append(w.buf, s...)
+}
+
+// Application 按EncodeApplication的规则编码值，常用类型直接写入缓冲区
+func (w *Writer) Application(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		w.buf = append(w.buf, TagNull<<4)
+	case bool:
+		if v {
+			w.buf = append(w.buf, TagBoolean<<4|1)
+		} else {
+			w.buf = append(w.buf, TagBoolean<<4)
+		}
+	case uint8:
+		w.Unsigned(uint32(v))
+	case uint16:
+		w.Unsigned(uint32(v))
+	case uint32:
+		w.Unsigned(v)
+	case float32:
+		w.Real(v)
+	case string:
+		w.CharacterString(v)
+	case Enumerated:
+		w.Enumerated(uint32(v))
+	case model.ObjectIdentifier:
+		w.objectIdentifier(TagObjectIdentifier, false, v)
+	default:
+		// model中以无符号整数为底层类型的命名类型编码为Enumerated
+		if rv := reflect.ValueOf(value); rv.Kind() >= reflect.Uint8 && rv.Kind() <= reflect.Uint32 {
+			w.Enumerated(uint32(rv.Uint()))
+			return nil
+		}
+		encoded, err := EncodeApplication(value)
+		if err != nil {
+			return err
+		}
+		w.buf = append(w.buf, encoded...)
+	}
+	return nil
+}
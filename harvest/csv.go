@@ -0,0 +1,136 @@
+package harvest
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// csvHeader CSV文件的表头，多对象趋势日志的每个值占一列
+var csvHeader = []string{"sequence", "timestamp", "status_flags", "value"}
+
+// CSVSink 把每个日志对象的记录追加到目录下的<名称>.csv，重启后从文件的最后一行继续采集
+type CSVSink struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewCSVSink 创建保存到dir的CSVSink，目录不存在时创建
+func NewCSVSink(dir string) (*CSVSink, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &CSVSink{dir: dir}, nil
+}
+
+// path 返回日志对象的CSV文件路径，名称中不能用于文件名的字符替换为_
+func (s *CSVSink) path(source Source) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == '.' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, source.Name)
+	return filepath.Join(s.dir, name+".csv")
+}
+
+// WriteRecords 追加记录，新文件先写表头
+func (s *CSVSink) WriteRecords(source Source, records []model.LogRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, err := os.OpenFile(s.path(source), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w := csv.NewWriter(file)
+	if info, err := file.Stat(); err == nil && info.Size() == 0 {
+		w.Write(csvHeader)
+	}
+	for _, record := range records {
+		row := []string{strconv.FormatUint(uint64(record.SequenceNumber), 10), record.Timestamp.Format(time.RFC3339Nano), ""}
+		if record.StatusFlags != nil {
+			row[2] = strconv.Itoa(int(*record.StatusFlags))
+		}
+		if values, ok := record.Datum.(model.LogMultipleDatum); ok {
+			for _, value := range values {
+				row = append(row, formatValue(value))
+			}
+		} else {
+			row = append(row, formatValue(record.Datum))
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// LastRecord 读取文件最后一行的序列号和时间
+func (s *CSVSink) LastRecord(source Source) (uint32, time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, err := os.Open(s.path(source))
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	defer file.Close()
+	// 只读取文件末尾，最后一行不会超过4KB
+	info, err := file.Stat()
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	offset := info.Size() - 4096
+	if offset < 0 {
+		offset = 0
+	}
+	tail := make([]byte, info.Size()-offset)
+	if _, err := file.ReadAt(tail, offset); err != nil && err != io.EOF {
+		return 0, time.Time{}, false
+	}
+	lines := bytes.Split(bytes.TrimRight(tail, "\n"), []byte("\n"))
+	r := csv.NewReader(bytes.NewReader(lines[len(lines)-1]))
+	r.FieldsPerRecord = -1
+	row, err := r.Read()
+	if err != nil || len(row) < 2 {
+		return 0, time.Time{}, false
+	}
+	sequence, err := strconv.ParseUint(row[0], 10, 32)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, row[1])
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	return uint32(sequence), timestamp, true
+}
+
+// formatValue 格式化记录值：日志状态写为status:<位>，读取失败写为failure:<错误>
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case model.LogStatus:
+		return fmt.Sprintf("status:%03b", uint8(v))
+	case model.LogFailure:
+		return fmt.Sprintf("failure:%v", v.Err)
+	case []byte:
+		return hex.EncodeToString(v)
+	}
+	return fmt.Sprint(value)
+}
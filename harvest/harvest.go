@@ -0,0 +1,267 @@
+// Package harvest 按周期用ReadRange从远程设备的趋势日志中采集新的记录，交给Sink保存到本地文件或时序数据库。
+// 采集按记录的序列号续接，远程缓冲区覆盖了尚未采集的记录时按时间补读并统计丢失的记录数
+package harvest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/iotzf/bacnet-server/client"
+	"github.com/iotzf/bacnet-server/model"
+	"github.com/iotzf/bacnet-server/protocol"
+)
+
+// firstTime 第一次采集时按时间读取的参考时间，早于任何记录
+var firstTime = time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Source 一个被采集的远程日志对象
+type Source struct {
+	Name    string                 // 保存记录和状态中使用的名称，为空时为"<设备实例号>_<对象类型>_<实例号>"
+	Device  uint32                 // 远程设备的实例号
+	Address net.Addr               // 设备地址，为nil时用Who-Is发现
+	Object  model.ObjectIdentifier // 趋势日志、多对象趋势日志或事件日志
+}
+
+// Sink 保存采集到的记录，返回错误时本次采集的记录不算完成，下个周期重新读取
+type Sink interface {
+	WriteRecords(source Source, records []model.LogRecord) error
+}
+
+// Resumer 由能够记住采集进度的Sink实现，重启后从最后保存的记录之后继续采集
+type Resumer interface {
+	LastRecord(source Source) (sequence uint32, timestamp time.Time, ok bool)
+}
+
+// Status 一个日志对象的采集状态
+type Status struct {
+	Name         string    `json:"name"`
+	Device       uint32    `json:"device"`
+	Object       string    `json:"object"`
+	LastHarvest  time.Time `json:"lastHarvest,omitempty"`  // 最近一次成功采集的时间
+	LastSequence uint32    `json:"lastSequence,omitempty"` // 最后采集的记录的序列号
+	LastRecord   time.Time `json:"lastRecord,omitempty"`   // 最后采集的记录的时间
+	Records      uint64    `json:"records"`                // 本次运行采集的记录数
+	Missed       uint64    `json:"missed"`                 // 采集前已被远程缓冲区覆盖的记录数
+	Error        string    `json:"error,omitempty"`        // 最近一次采集的错误
+}
+
+// source 采集中的日志对象
+type source struct {
+	Source
+	known  bool      // next有效，按序列号续接
+	next   uint32    // 下一条要采集的记录的序列号
+	last   time.Time // 最后采集的记录的时间
+	status Status    // 由Harvester.mu保护
+}
+
+// Harvester 按周期依次采集每个日志对象
+type Harvester struct {
+	Logger *slog.Logger // 为nil时使用slog.Default()
+	Batch  int          // 每个ReadRange请求的最大记录数，为0时为50
+	Clock  model.Clock  // 采集周期和采集时间使用的时钟，为nil时使用model.SystemClock
+
+	client   *client.Client
+	sink     Sink
+	interval time.Duration
+	mu       sync.Mutex // 保护sources和各自的status
+	sources  []*source
+}
+
+// New 创建采集器，记录交给sink保存，每interval采集一次
+func New(c *client.Client, sink Sink, interval time.Duration) *Harvester {
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+	return &Harvester{client: c, sink: sink, interval: interval}
+}
+
+// logger 返回采集器使用的日志
+func (h *Harvester) logger() *slog.Logger {
+	if h.Logger != nil {
+		return h.Logger
+	}
+	return slog.Default()
+}
+
+// clock 返回采集器使用的时钟
+func (h *Harvester) clock() model.Clock {
+	if h.Clock != nil {
+		return h.Clock
+	}
+	return model.SystemClock
+}
+
+// AddSource 加入要采集的日志对象，sink实现Resumer时从上次保存的记录之后继续
+func (h *Harvester) AddSource(s Source) error {
+	switch s.Object.Type {
+	case model.ObjectTypeTrendLog, model.ObjectTypeTrendLogMultiple, model.ObjectTypeEventLog:
+	default:
+		return fmt.Errorf("不能采集%s对象", s.Object.Type)
+	}
+	if s.Name == "" {
+		s.Name = fmt.Sprintf("%d_%s_%d", s.Device, s.Object.Type, s.Object.Instance)
+	}
+	src := &source{Source: s, status: Status{Name: s.Name, Device: s.Device, Object: fmt.Sprintf("%s:%d", s.Object.Type, s.Object.Instance)}}
+	if resumer, ok := h.sink.(Resumer); ok {
+		if sequence, timestamp, ok := resumer.LastRecord(s); ok {
+			src.known, src.next, src.last = true, sequence+1, timestamp
+			src.status.LastSequence, src.status.LastRecord = sequence, timestamp
+		}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, other := range h.sources {
+		if other.Name == s.Name {
+			return fmt.Errorf("采集源%s重复", s.Name)
+		}
+	}
+	h.sources = append(h.sources, src)
+	return nil
+}
+
+// Close 关闭客户端
+func (h *Harvester) Close() error {
+	return h.client.Close()
+}
+
+// Run 立即采集一次，之后每个周期采集，直到ctx取消
+func (h *Harvester) Run(ctx context.Context) {
+	ticker := h.clock().NewTicker(h.interval)
+	defer ticker.Stop()
+	h.Harvest(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			h.Harvest(ctx)
+		}
+	}
+}
+
+// Harvest 依次采集每个日志对象中的新记录，错误记入状态
+func (h *Harvester) Harvest(ctx context.Context) {
+	h.mu.Lock()
+	sources := append([]*source(nil), h.sources...)
+	h.mu.Unlock()
+	for _, s := range sources {
+		if ctx.Err() != nil {
+			return
+		}
+		err := h.harvest(ctx, s)
+		h.mu.Lock()
+		if err != nil {
+			s.status.Error = err.Error()
+		} else {
+			s.status.Error = ""
+			s.status.LastHarvest = h.clock().Now()
+		}
+		h.mu.Unlock()
+		if err != nil {
+			h.logger().Warn("采集趋势日志失败", "source", s.Name, "error", err)
+			if s.Address == nil {
+				h.client.Forget(s.Device)
+			}
+		}
+	}
+}
+
+// harvest 读取一个日志对象中的新记录：按序列号从下一条开始读取，读不到时（没有新记录或已被覆盖）
+// 再按最后记录的时间读取一次
+func (h *Harvester) harvest(ctx context.Context, s *source) error {
+	addr := s.Address
+	if addr == nil {
+		device, err := h.client.Device(ctx, s.Device)
+		if err != nil {
+			return err
+		}
+		addr = device.Address
+	}
+	batch := h.Batch
+	if batch <= 0 {
+		batch = 50
+	}
+	byTime := !s.known
+	for {
+		request := protocol.ReadRangeRequest{ObjectID: s.Object, PropertyID: model.PropertyIdentifierLogBuffer, Count: int32(batch)}
+		if byTime {
+			request.RangeType = protocol.ReadRangeByTime
+			request.ReferenceTime = s.last
+			if !s.known {
+				request.ReferenceTime = firstTime
+			}
+		} else {
+			request.RangeType = protocol.ReadRangeBySequenceNumber
+			request.Reference = s.next
+		}
+		result, err := h.client.ReadRange(ctx, addr, request)
+		if err != nil {
+			return err
+		}
+		if len(result.Records) == 0 {
+			if !byTime {
+				byTime = true
+				continue
+			}
+			return nil
+		}
+		if result.FirstSequenceNumber == nil {
+			return errors.New("ReadRange应答缺少序列号")
+		}
+		records := result.Records
+		if s.known {
+			// 按时间读取可能包含已采集的记录
+			for len(records) > 0 && records[0].SequenceNumber < s.next {
+				records = records[1:]
+			}
+			if len(records) == 0 {
+				return nil
+			}
+		}
+		var missed uint32
+		if s.known && records[0].SequenceNumber > s.next {
+			missed = records[0].SequenceNumber - s.next
+			h.logger().Warn("趋势日志记录在采集前被覆盖", "source", s.Name, "missed", missed)
+		}
+		if err := h.sink.WriteRecords(s.Source, records); err != nil {
+			return fmt.Errorf("保存记录失败: %w", err)
+		}
+		last := records[len(records)-1]
+		s.known, s.next, s.last = true, last.SequenceNumber+1, last.Timestamp
+		h.mu.Lock()
+		s.status.Records += uint64(len(records))
+		s.status.Missed += uint64(missed)
+		s.status.LastSequence, s.status.LastRecord = last.SequenceNumber, last.Timestamp
+		h.mu.Unlock()
+		if !result.MoreItems {
+			return nil
+		}
+		byTime = false
+	}
+}
+
+// Status 返回各日志对象的采集状态
+func (h *Harvester) Status() []Status {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	status := make([]Status, len(h.sources))
+	for i, s := range h.sources {
+		status[i] = s.status
+	}
+	return status
+}
+
+// StatusHandler 返回以JSON提供采集状态的HTTP处理器，可以注册到仪表盘的/api/下
+func (h *Harvester) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(h.Status())
+	})
+}
@@ -0,0 +1,141 @@
+package harvest
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iotzf/bacnet-server/client"
+	"github.com/iotzf/bacnet-server/model"
+	"github.com/iotzf/bacnet-server/protocol"
+)
+
+// readCSV 读取采集到的CSV文件，不含表头
+func readCSV(t *testing.T, path string) [][]string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	r := csv.NewReader(file)
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows[1:]
+}
+
+func TestHarvester(t *testing.T) {
+	network := protocol.NewLoopbackNetwork()
+	device := model.NewDevice(2001, "Remote", "")
+	trend := model.NewTrendLog(1, "Zone Temp Trend", 150)
+	multiple := model.NewTrendLogMultiple(1, "AHU Trend", 10)
+	device.AddObject(trend)
+	device.AddObject(multiple)
+	start := time.Date(2026, 3, 1, 8, 0, 0, 0, time.Local)
+	appendRecords := func(n int) {
+		for i := 0; i < n; i++ {
+			seq := trend.Buffer().TotalRecordCount
+			trend.Buffer().Append(start.Add(time.Duration(seq)*time.Minute), float32(seq)/2)
+		}
+	}
+	appendRecords(120)
+	multiple.Buffer().Append(start, model.LogMultipleDatum{float32(18.5), true, nil})
+	server, err := protocol.NewServer(device, protocol.Options{Transport: network.Attach()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Start(context.Background())
+	defer server.Stop()
+
+	newHarvester := func(sink Sink) *Harvester {
+		c, err := client.New(client.Options{Transport: network.Attach(), Timeout: 200 * time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := New(c, sink, time.Minute)
+		for _, oid := range []model.ObjectIdentifier{trend.GetObjectIdentifier(), multiple.GetObjectIdentifier()} {
+			if err := h.AddSource(Source{Device: 2001, Object: oid}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return h
+	}
+	dir := t.TempDir()
+	sink, err := NewCSVSink(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newHarvester(sink)
+	defer h.Close()
+	if err := h.AddSource(Source{Device: 2001, Object: trend.GetObjectIdentifier()}); err == nil {
+		t.Error("duplicate source accepted")
+	}
+	if err := h.AddSource(Source{Device: 2001, Object: model.ObjectIdentifier{Type: model.ObjectTypeAnalogInput, Instance: 1}}); err == nil {
+		t.Error("analog input accepted as a source")
+	}
+
+	// 第一次采集读取全部记录，超过一个请求的记录分批读取
+	ctx := context.Background()
+	h.Harvest(ctx)
+	path := filepath.Join(dir, "2001_trend-log_1.csv")
+	rows := readCSV(t, path)
+	if len(rows) != 120 || rows[0][0] != "1" || rows[119][0] != "120" || rows[1][3] != "0.5" {
+		t.Fatalf("%d rows harvested, first %v, last %v", len(rows), rows[0], rows[len(rows)-1])
+	}
+	if stamp, _ := time.Parse(time.RFC3339Nano, rows[2][1]); !stamp.Equal(start.Add(2 * time.Minute)) {
+		t.Errorf("timestamp %s, want %s", rows[2][1], start.Add(2*time.Minute))
+	}
+	if rows := readCSV(t, filepath.Join(dir, "2001_trend-log-multiple_1.csv")); len(rows) != 1 || len(rows[0]) != 6 || rows[0][3] != "18.5" || rows[0][4] != "true" || rows[0][5] != "null" {
+		t.Errorf("trend-log-multiple rows = %v", rows)
+	}
+
+	// 之后只采集新记录
+	appendRecords(3)
+	h.Harvest(ctx)
+	if rows := readCSV(t, path); len(rows) != 123 || rows[122][0] != "123" {
+		t.Errorf("%d rows after new records", len(rows))
+	}
+
+	// 缓冲区覆盖了未采集的记录时按时间补读，统计丢失的记录
+	appendRecords(200)
+	h.Harvest(ctx)
+	rows = readCSV(t, path)
+	if len(rows) != 273 || rows[123][0] != "174" || rows[272][0] != "323" {
+		t.Errorf("%d rows after overwrite, first new %v", len(rows), rows[123])
+	}
+	status := h.Status()
+	if len(status) != 2 || status[0].Records != 273 || status[0].Missed != 50 || status[0].LastSequence != 323 || status[0].Error != "" || status[0].LastHarvest.IsZero() {
+		t.Errorf("status = %+v", status)
+	}
+
+	// 重启后从CSV文件的最后一条记录继续
+	restarted := newHarvester(sink)
+	defer restarted.Close()
+	restarted.Harvest(ctx)
+	if rows := readCSV(t, path); len(rows) != 273 {
+		t.Errorf("%d rows after restart, want 273", len(rows))
+	}
+
+	// 状态接口
+	recorder := httptest.NewRecorder()
+	restarted.StatusHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/api/harvest", nil))
+	var served []Status
+	if err := json.Unmarshal(recorder.Body.Bytes(), &served); err != nil || len(served) != 2 || served[0].Object != "trend-log:1" || served[0].LastSequence != 323 || served[0].Records != 0 {
+		t.Errorf("status API = %s, %v", recorder.Body, err)
+	}
+
+	// 设备离线时记录错误
+	server.Stop()
+	restarted.Harvest(ctx)
+	if status := restarted.Status(); status[0].Error == "" {
+		t.Error("no error recorded while the device is offline")
+	}
+}
@@ -0,0 +1,454 @@
+package modbus
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// Table Modbus数据区
+type Table uint8
+
+const (
+	Coils            Table = iota // 线圈，可读写的位
+	DiscreteInputs                // 离散输入，只读的位
+	InputRegisters                // 输入寄存器，只读
+	HoldingRegisters              // 保持寄存器，可读写
+)
+
+var tableNames = []string{"coil", "discrete-input", "input-register", "holding-register"}
+
+func (t Table) String() string {
+	if int(t) < len(tableNames) {
+		return tableNames[t]
+	}
+	return fmt.Sprintf("table-%d", t)
+}
+
+// ParseTable 按名称解析数据区，名称为coil、discrete-input、input-register或holding-register
+func ParseTable(name string) (Table, error) {
+	for i, n := range tableNames {
+		if n == name {
+			return Table(i), nil
+		}
+	}
+	return 0, fmt.Errorf("未知的Modbus数据区: %q", name)
+}
+
+// writable 判断数据区是否可写
+func (t Table) writable() bool {
+	return t == Coils || t == HoldingRegisters
+}
+
+// bits 判断数据区是否按位访问
+func (t Table) bits() bool {
+	return t == Coils || t == DiscreteInputs
+}
+
+// DataType 寄存器中数值的编码
+type DataType uint8
+
+const (
+	Uint16  DataType = iota // 一个寄存器，无符号
+	Int16                   // 一个寄存器，有符号
+	Uint32                  // 两个寄存器，无符号
+	Int32                   // 两个寄存器，有符号
+	Float32                 // 两个寄存器，IEEE 754单精度
+)
+
+var dataTypeNames = []string{"uint16", "int16", "uint32", "int32", "float32"}
+
+func (d DataType) String() string {
+	if int(d) < len(dataTypeNames) {
+		return dataTypeNames[d]
+	}
+	return fmt.Sprintf("datatype-%d", d)
+}
+
+// ParseDataType 按名称解析数据类型
+func ParseDataType(name string) (DataType, error) {
+	for i, n := range dataTypeNames {
+		if n == name {
+			return DataType(i), nil
+		}
+	}
+	return 0, fmt.Errorf("未知的Modbus数据类型: %q", name)
+}
+
+// registers 返回数据类型占用的寄存器数
+func (d DataType) registers() uint16 {
+	if d >= Uint32 {
+		return 2
+	}
+	return 1
+}
+
+// Point 一个映射为BACnet对象的Modbus数据点
+type Point struct {
+	ObjectType model.ObjectType       // 模拟或二进制的输入、输出、值对象
+	Instance   uint32                 // 对象实例号
+	Name       string                 // 对象名称，为空时按数据区和地址生成
+	Units      model.EngineeringUnits // 模拟量对象的单位
+	Unit       byte                   // Modbus从站地址
+	Table      Table                  // 数据区
+	Address    uint16                 // 起始地址（从0开始）
+	DataType   DataType               // 寄存器中数值的编码，位数据区忽略
+	WordSwap   bool                   // 32位数值低字在前
+	Scale      float64                // 工程值 = 原始值 * Scale + Offset，为0时按1处理
+	Offset     float64
+}
+
+// writable 判断数据点是否写穿到Modbus：输出和值对象映射到线圈或保持寄存器
+func (p *Point) writable() bool {
+	switch p.ObjectType {
+	case model.ObjectTypeAnalogOutput, model.ObjectTypeAnalogValue, model.ObjectTypeBinaryOutput, model.ObjectTypeBinaryValue:
+		return p.Table.writable()
+	}
+	return false
+}
+
+// analog 判断数据点是否映射为模拟量对象
+func (p *Point) analog() bool {
+	switch p.ObjectType {
+	case model.ObjectTypeAnalogInput, model.ObjectTypeAnalogOutput, model.ObjectTypeAnalogValue:
+		return true
+	}
+	return false
+}
+
+// scale 返回换算系数
+func (p *Point) scale() float64 {
+	if p.Scale == 0 {
+		return 1
+	}
+	return p.Scale
+}
+
+// decode 将读到的寄存器换算为工程值
+func (p *Point) decode(registers []uint16) float64 {
+	var raw float64
+	switch p.DataType {
+	case Int16:
+		raw = float64(int16(registers[0]))
+	case Uint32, Int32, Float32:
+		hi, lo := registers[0], registers[1]
+		if p.WordSwap {
+			hi, lo = lo, hi
+		}
+		bits := uint32(hi)<<16 | uint32(lo)
+		switch p.DataType {
+		case Uint32:
+			raw = float64(bits)
+		case Int32:
+			raw = float64(int32(bits))
+		default:
+			raw = float64(math.Float32frombits(bits))
+		}
+	default:
+		raw = float64(registers[0])
+	}
+	return raw*p.scale() + p.Offset
+}
+
+// encode 将工程值换算为要写入的寄存器，整数类型四舍五入，超出范围时返回错误
+func (p *Point) encode(value float64) ([]uint16, error) {
+	raw := (value - p.Offset) / p.scale()
+	var bits uint32
+	switch p.DataType {
+	case Float32:
+		bits = math.Float32bits(float32(raw))
+	default:
+		raw = math.Round(raw)
+		min, max := 0.0, float64(math.MaxUint16)
+		switch p.DataType {
+		case Int16:
+			min, max = math.MinInt16, math.MaxInt16
+		case Uint32:
+			max = math.MaxUint32
+		case Int32:
+			min, max = math.MinInt32, math.MaxInt32
+		}
+		if raw < min || raw > max {
+			return nil, model.ErrValueOutOfRange
+		}
+		bits = uint32(int64(raw))
+	}
+	if p.DataType.registers() == 1 {
+		return []uint16{uint16(bits)}, nil
+	}
+	hi, lo := uint16(bits>>16), uint16(bits)
+	if p.WordSwap {
+		hi, lo = lo, hi
+	}
+	return []uint16{hi, lo}, nil
+}
+
+// pointObject 数据点映射的模拟量或二进制对象
+type pointObject interface {
+	model.CommandableObject
+	PriorityArray(prop model.PropertyIdentifier) model.PriorityArray
+	SetPropertyProvider(prop model.PropertyIdentifier, provider model.PropertyProvider)
+	SetReliability(reliability model.Reliability)
+}
+
+// gatewayPoint 网关中的数据点及其BACnet对象
+type gatewayPoint struct {
+	Point
+	device  *model.Device // 对象所在的设备，轮询更新对象时持有其锁
+	object  pointObject
+	written interface{} // 最近一次写入或读到的现场值，相同的值不重复写入
+}
+
+// Gateway 按周期轮询Modbus数据点并更新对应BACnet对象的Present_Value，
+// 输出和值对象的写入经属性提供者写穿到Modbus，写入失败时BACnet请求返回错误
+type Gateway struct {
+	Logger *slog.Logger // 为nil时使用slog.Default()
+	Clock  model.Clock  // 轮询周期使用的时钟，为nil时使用model.SystemClock
+
+	client   *Client
+	interval time.Duration
+	mu       sync.Mutex // 保护points
+	points   []*gatewayPoint
+}
+
+// NewGateway 创建网关，每interval轮询一次全部数据点
+func NewGateway(client *Client, interval time.Duration) *Gateway {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return &Gateway{client: client, interval: interval}
+}
+
+// logger 返回网关使用的日志
+func (g *Gateway) logger() *slog.Logger {
+	if g.Logger != nil {
+		return g.Logger
+	}
+	return slog.Default()
+}
+
+// clock 返回网关使用的时钟
+func (g *Gateway) clock() model.Clock {
+	if g.Clock != nil {
+		return g.Clock
+	}
+	return model.SystemClock
+}
+
+// AddPoint 为数据点创建BACnet对象并加入设备
+func (g *Gateway) AddPoint(device *model.Device, p Point) (model.Object, error) {
+	if p.Table > HoldingRegisters {
+		return nil, fmt.Errorf("未知的Modbus数据区: %d", p.Table)
+	}
+	if p.DataType > Float32 {
+		return nil, fmt.Errorf("未知的Modbus数据类型: %d", p.DataType)
+	}
+	if !p.Table.bits() && uint32(p.Address)+uint32(p.DataType.registers()) > 0x10000 {
+		return nil, fmt.Errorf("Modbus地址超出范围: %d", p.Address)
+	}
+	if p.Name == "" {
+		p.Name = fmt.Sprintf("Modbus %d %s %d", p.Unit, p.Table, p.Address)
+	}
+	var object pointObject
+	switch p.ObjectType {
+	case model.ObjectTypeAnalogInput:
+		object = model.NewAnalogInput(p.Instance, p.Name, p.Units)
+	case model.ObjectTypeAnalogOutput:
+		object = model.NewAnalogOutput(p.Instance, p.Name, p.Units)
+	case model.ObjectTypeAnalogValue:
+		object = model.NewAnalogValue(p.Instance, p.Name, p.Units)
+	case model.ObjectTypeBinaryInput:
+		object = model.NewBinaryInput(p.Instance, p.Name)
+	case model.ObjectTypeBinaryOutput:
+		object = model.NewBinaryOutput(p.Instance, p.Name)
+	case model.ObjectTypeBinaryValue:
+		object = model.NewBinaryValue(p.Instance, p.Name)
+	default:
+		return nil, fmt.Errorf("Modbus数据点不支持对象类型%d", p.ObjectType)
+	}
+	if (p.ObjectType == model.ObjectTypeAnalogOutput || p.ObjectType == model.ObjectTypeBinaryOutput) && !p.Table.writable() {
+		return nil, fmt.Errorf("输出对象%s只能映射到线圈或保持寄存器", p.Name)
+	}
+	if err := device.AddObject(object); err != nil {
+		return nil, err
+	}
+
+	point := &gatewayPoint{Point: p, device: device, object: object}
+	if p.writable() {
+		object.SetPropertyProvider(model.PropertyIdentifierPresentValue, model.ProviderFuncs{
+			Read: func(obj model.Object, prop model.PropertyIdentifier) (interface{}, error) {
+				return obj.ReadProperty(prop)
+			},
+			Write: func(obj model.Object, prop model.PropertyIdentifier, value interface{}, priority uint8) error {
+				return g.command(point, value, priority)
+			},
+		})
+	}
+	g.mu.Lock()
+	g.points = append(g.points, point)
+	g.mu.Unlock()
+	return object, nil
+}
+
+// Close 关闭Modbus连接
+func (g *Gateway) Close() error {
+	return g.client.Close()
+}
+
+// Run 立即轮询一次，之后每个周期轮询，直到ctx取消
+func (g *Gateway) Run(ctx context.Context) {
+	ticker := g.clock().NewTicker(g.interval)
+	defer ticker.Stop()
+	g.Poll()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			g.Poll()
+		}
+	}
+}
+
+// Poll 读取全部数据点。输入点更新Present_Value，写穿的点更新Relinquish_Default，
+// 没有优先级命令时Present_Value跟随现场值；读取失败时对象的Reliability为communication-failure。
+// 读取Modbus时不持有设备锁，更新对象时持有
+func (g *Gateway) Poll() {
+	g.mu.Lock()
+	points := append([]*gatewayPoint(nil), g.points...)
+	g.mu.Unlock()
+	for _, point := range points {
+		value, err := g.read(point)
+		point.device.Lock()
+		g.update(point, value, err)
+		point.device.Unlock()
+	}
+}
+
+// update 按一次读取的结果更新数据点的对象，调用时持有对象所在设备的锁
+func (g *Gateway) update(point *gatewayPoint, value interface{}, err error) {
+	g.setFault(point, err)
+	if err != nil {
+		g.logger().Warn("读取Modbus数据点失败", "object", point.object.GetObjectName(), "unit", point.Unit, "table", point.Table, "address", point.Address, "error", err)
+		return
+	}
+	if point.writable() {
+		if err := point.object.WriteProperty(model.PropertyIdentifierRelinquishDefault, value); err != nil {
+			g.logger().Warn("更新Relinquish_Default失败", "object", point.object.GetObjectName(), "value", value, "error", err)
+		}
+		g.mu.Lock()
+		point.written = value
+		g.mu.Unlock()
+		return
+	}
+	if updater, ok := point.object.(model.PresentValueUpdater); ok {
+		if err := updater.UpdatePresentValue(value); err != nil {
+			g.logger().Warn("更新Present_Value失败", "object", point.object.GetObjectName(), "value", value, "error", err)
+		}
+	}
+}
+
+// read 读取数据点，模拟量返回float32，二进制量返回bool
+func (g *Gateway) read(point *gatewayPoint) (interface{}, error) {
+	var number float64
+	switch point.Table {
+	case Coils, DiscreteInputs:
+		read := g.client.ReadCoils
+		if point.Table == DiscreteInputs {
+			read = g.client.ReadDiscreteInputs
+		}
+		bits, err := read(point.Unit, point.Address, 1)
+		if err != nil {
+			return nil, err
+		}
+		if bits[0] {
+			number = 1
+		}
+	default:
+		read := g.client.ReadHoldingRegisters
+		if point.Table == InputRegisters {
+			read = g.client.ReadInputRegisters
+		}
+		registers, err := read(point.Unit, point.Address, point.DataType.registers())
+		if err != nil {
+			return nil, err
+		}
+		number = point.decode(registers)
+	}
+	if point.analog() {
+		return float32(number), nil
+	}
+	return number != 0, nil
+}
+
+// write 将有效值写入数据点
+func (g *Gateway) write(point *gatewayPoint, value interface{}) error {
+	var number float64
+	switch v := value.(type) {
+	case bool:
+		if v {
+			number = 1
+		}
+	case float32:
+		number = float64(v)
+	default:
+		return model.ErrInvalidDataType
+	}
+	if point.Table == Coils {
+		return g.client.WriteSingleCoil(point.Unit, point.Address, number != 0)
+	}
+	if !point.analog() {
+		// 二进制对象映射到保持寄存器时写入0或1
+		return g.client.WriteSingleRegister(point.Unit, point.Address, uint16(number))
+	}
+	registers, err := point.encode(number)
+	if err != nil {
+		return err
+	}
+	if len(registers) == 1 {
+		return g.client.WriteSingleRegister(point.Unit, point.Address, registers[0])
+	}
+	return g.client.WriteMultipleRegisters(point.Unit, point.Address, registers)
+}
+
+// command 按优先级命令写穿的数据点，有效值变化时写入Modbus，写入失败时撤销该优先级的命令。
+// 由属性提供者在写入对象时调用，调用方持有设备锁
+func (g *Gateway) command(point *gatewayPoint, value interface{}, priority uint8) error {
+	object := point.object
+	previous := object.PriorityArray(model.PropertyIdentifierPresentValue)
+	if err := object.WritePropertyWithPriority(model.PropertyIdentifierPresentValue, value, priority); err != nil {
+		return err
+	}
+	effective, _ := object.ReadProperty(model.PropertyIdentifierPresentValue)
+	g.mu.Lock()
+	unchanged := effective == point.written
+	g.mu.Unlock()
+	if unchanged {
+		return nil
+	}
+	err := g.write(point, effective)
+	g.setFault(point, err)
+	if err != nil {
+		object.WritePropertyWithPriority(model.PropertyIdentifierPresentValue, previous[priority-1], priority)
+		g.logger().Warn("写入Modbus数据点失败", "object", point.object.GetObjectName(), "value", effective, "error", err)
+		return err
+	}
+	g.mu.Lock()
+	point.written = effective
+	g.mu.Unlock()
+	return nil
+}
+
+// setFault 按通信结果设置对象的Reliability：失败时为communication-failure，Status_Flags的FAULT位随之置位
+func (g *Gateway) setFault(point *gatewayPoint, err error) {
+	reliability := model.ReliabilityNoFaultDetected
+	if err != nil {
+		reliability = model.ReliabilityCommunicationFailure
+	}
+	point.object.SetReliability(reliability)
+}
@@ -0,0 +1,189 @@
+// Package modbus 实现Modbus TCP和RTU主站（客户端），以及将Modbus寄存器映射为BACnet对象的网关
+package modbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// 功能码
+const (
+	FuncReadCoils              byte = 0x01
+	FuncReadDiscreteInputs     byte = 0x02
+	FuncReadHoldingRegisters   byte = 0x03
+	FuncReadInputRegisters     byte = 0x04
+	FuncWriteSingleCoil        byte = 0x05
+	FuncWriteSingleRegister    byte = 0x06
+	FuncWriteMultipleCoils     byte = 0x0F
+	FuncWriteMultipleRegisters byte = 0x10
+)
+
+// 一次读写的最大数量
+const (
+	maxReadBits       = 2000
+	maxReadRegisters  = 125
+	maxWriteRegisters = 123
+)
+
+// ErrTimeout 等待从站应答超时
+var ErrTimeout = errors.New("modbus: 等待应答超时")
+
+// Exception 从站返回的异常应答
+type Exception struct {
+	Function byte // 请求的功能码
+	Code     byte // 异常码
+}
+
+// exceptionNames 常见异常码的含义
+var exceptionNames = map[byte]string{
+	1:  "非法功能码",
+	2:  "非法数据地址",
+	3:  "非法数据值",
+	4:  "从站设备故障",
+	6:  "从站设备忙",
+	10: "网关路径不可用",
+	11: "网关目标设备无响应",
+}
+
+func (e *Exception) Error() string {
+	if name, ok := exceptionNames[e.Code]; ok {
+		return fmt.Sprintf("modbus: 功能码0x%02X异常: %s", e.Function, name)
+	}
+	return fmt.Sprintf("modbus: 功能码0x%02X异常: %d", e.Function, e.Code)
+}
+
+// transport 发送一个请求PDU并返回应答PDU（功能码开始，不含地址和校验）
+type transport interface {
+	send(unit byte, pdu []byte) ([]byte, error)
+	Close() error
+}
+
+// Client Modbus主站，同一时间只有一个请求在进行，可以被多个goroutine共用
+type Client struct {
+	mu        sync.Mutex
+	transport transport
+}
+
+// Close 关闭连接
+func (c *Client) Close() error {
+	return c.transport.Close()
+}
+
+// ReadCoils 读取线圈，返回count个位
+func (c *Client) ReadCoils(unit byte, address, count uint16) ([]bool, error) {
+	return c.readBits(unit, FuncReadCoils, address, count)
+}
+
+// ReadDiscreteInputs 读取离散输入，返回count个位
+func (c *Client) ReadDiscreteInputs(unit byte, address, count uint16) ([]bool, error) {
+	return c.readBits(unit, FuncReadDiscreteInputs, address, count)
+}
+
+// ReadHoldingRegisters 读取保持寄存器
+func (c *Client) ReadHoldingRegisters(unit byte, address, count uint16) ([]uint16, error) {
+	return c.readRegisters(unit, FuncReadHoldingRegisters, address, count)
+}
+
+// ReadInputRegisters 读取输入寄存器
+func (c *Client) ReadInputRegisters(unit byte, address, count uint16) ([]uint16, error) {
+	return c.readRegisters(unit, FuncReadInputRegisters, address, count)
+}
+
+// WriteSingleCoil 写单个线圈
+func (c *Client) WriteSingleCoil(unit byte, address uint16, value bool) error {
+	var data uint16
+	if value {
+		data = 0xFF00
+	}
+	_, err := c.request(unit, FuncWriteSingleCoil, address, data)
+	return err
+}
+
+// WriteSingleRegister 写单个保持寄存器
+func (c *Client) WriteSingleRegister(unit byte, address, value uint16) error {
+	_, err := c.request(unit, FuncWriteSingleRegister, address, value)
+	return err
+}
+
+// WriteMultipleRegisters 写连续的保持寄存器
+func (c *Client) WriteMultipleRegisters(unit byte, address uint16, values []uint16) error {
+	if len(values) == 0 || len(values) > maxWriteRegisters {
+		return fmt.Errorf("modbus: 写寄存器数量无效: %d", len(values))
+	}
+	data := make([]byte, 1+2*len(values))
+	data[0] = byte(2 * len(values))
+	for i, value := range values {
+		binary.BigEndian.PutUint16(data[1+2*i:], value)
+	}
+	_, err := c.request(unit, FuncWriteMultipleRegisters, address, uint16(len(values)), data...)
+	return err
+}
+
+// readBits 读取线圈或离散输入
+func (c *Client) readBits(unit, function byte, address, count uint16) ([]bool, error) {
+	if count == 0 || count > maxReadBits {
+		return nil, fmt.Errorf("modbus: 读取数量无效: %d", count)
+	}
+	data, err := c.request(unit, function, address, count)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 1 || int(data[0]) != len(data)-1 || int(data[0]) < (int(count)+7)/8 {
+		return nil, fmt.Errorf("modbus: 应答长度无效")
+	}
+	bits := make([]bool, count)
+	for i := range bits {
+		bits[i] = data[1+i/8]&(1<<(i%8)) != 0
+	}
+	return bits, nil
+}
+
+// readRegisters 读取保持或输入寄存器
+func (c *Client) readRegisters(unit, function byte, address, count uint16) ([]uint16, error) {
+	if count == 0 || count > maxReadRegisters {
+		return nil, fmt.Errorf("modbus: 读取数量无效: %d", count)
+	}
+	data, err := c.request(unit, function, address, count)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 1 || int(data[0]) != len(data)-1 || int(data[0]) != 2*int(count) {
+		return nil, fmt.Errorf("modbus: 应答长度无效")
+	}
+	registers := make([]uint16, count)
+	for i := range registers {
+		registers[i] = binary.BigEndian.Uint16(data[1+2*i:])
+	}
+	return registers, nil
+}
+
+// request 发送"功能码 地址 数量/值 [数据]"形式的请求，返回应答中功能码之后的数据
+func (c *Client) request(unit, function byte, address, value uint16, data ...byte) ([]byte, error) {
+	pdu := make([]byte, 5, 5+len(data))
+	pdu[0] = function
+	binary.BigEndian.PutUint16(pdu[1:], address)
+	binary.BigEndian.PutUint16(pdu[3:], value)
+	pdu = append(pdu, data...)
+
+	c.mu.Lock()
+	response, err := c.transport.send(unit, pdu)
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if len(response) == 0 {
+		return nil, fmt.Errorf("modbus: 空应答")
+	}
+	switch response[0] {
+	case function:
+		return response[1:], nil
+	case function | 0x80:
+		if len(response) < 2 {
+			return nil, fmt.Errorf("modbus: 异常应答长度无效")
+		}
+		return nil, &Exception{Function: function, Code: response[1]}
+	}
+	return nil, fmt.Errorf("modbus: 应答的功能码0x%02X与请求0x%02X不符", response[0], function)
+}
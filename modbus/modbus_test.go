@@ -0,0 +1,254 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// slave 内存中的Modbus从站，地址为failAddress的请求返回非法数据地址异常
+type slave struct {
+	mu          sync.Mutex
+	coils       map[uint16]bool
+	inputs      map[uint16]bool
+	holding     map[uint16]uint16
+	input       map[uint16]uint16
+	failAddress uint16
+}
+
+func newSlave() *slave {
+	return &slave{
+		coils:       map[uint16]bool{},
+		inputs:      map[uint16]bool{},
+		holding:     map[uint16]uint16{},
+		input:       map[uint16]uint16{},
+		failAddress: 0xFFFF,
+	}
+}
+
+// handle 处理请求PDU并返回应答PDU
+func (s *slave) handle(pdu []byte) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	function := pdu[0]
+	address := binary.BigEndian.Uint16(pdu[1:])
+	value := binary.BigEndian.Uint16(pdu[3:])
+	if address == s.failAddress {
+		return []byte{function | 0x80, 2}
+	}
+	switch function {
+	case FuncReadCoils, FuncReadDiscreteInputs:
+		bits := s.coils
+		if function == FuncReadDiscreteInputs {
+			bits = s.inputs
+		}
+		data := make([]byte, (value+7)/8)
+		for i := uint16(0); i < value; i++ {
+			if bits[address+i] {
+				data[i/8] |= 1 << (i % 8)
+			}
+		}
+		return append([]byte{function, byte(len(data))}, data...)
+	case FuncReadHoldingRegisters, FuncReadInputRegisters:
+		registers := s.holding
+		if function == FuncReadInputRegisters {
+			registers = s.input
+		}
+		response := []byte{function, byte(2 * value)}
+		for i := uint16(0); i < value; i++ {
+			response = binary.BigEndian.AppendUint16(response, registers[address+i])
+		}
+		return response
+	case FuncWriteSingleCoil:
+		s.coils[address] = value == 0xFF00
+		return pdu
+	case FuncWriteSingleRegister:
+		s.holding[address] = value
+		return pdu
+	case FuncWriteMultipleRegisters:
+		for i := uint16(0); i < value; i++ {
+			s.holding[address+i] = binary.BigEndian.Uint16(pdu[6+2*i:])
+		}
+		return pdu[:5]
+	}
+	return []byte{function | 0x80, 1}
+}
+
+// serveTCP 以Modbus TCP提供从站，返回监听地址
+func (s *slave) serveTCP(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				for {
+					header := make([]byte, 7)
+					if _, err := io.ReadFull(conn, header); err != nil {
+						return
+					}
+					pdu := make([]byte, binary.BigEndian.Uint16(header[4:])-1)
+					if _, err := io.ReadFull(conn, pdu); err != nil {
+						return
+					}
+					response := s.handle(pdu)
+					binary.BigEndian.PutUint16(header[4:], uint16(1+len(response)))
+					conn.Write(append(header, response...))
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestCRC16(t *testing.T) {
+	frame := []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x0A}
+	if crc := CRC16(frame); crc != 0xCDC5 {
+		t.Errorf("CRC16 = %04X, want CDC5", crc)
+	}
+}
+
+func TestRTUClient(t *testing.T) {
+	s := newSlave()
+	s.holding[10] = 1234
+	master, port := net.Pipe()
+	defer master.Close()
+	go func() {
+		defer port.Close()
+		buf := make([]byte, 256)
+		for {
+			n, err := port.Read(buf)
+			if err != nil {
+				return
+			}
+			frame := buf[:n]
+			if CRC16(frame[:n-2]) != binary.LittleEndian.Uint16(frame[n-2:]) {
+				t.Errorf("request CRC mismatch: % X", frame)
+				return
+			}
+			response := append([]byte{frame[0]}, s.handle(frame[1:n-2])...)
+			response = binary.LittleEndian.AppendUint16(response, CRC16(response))
+			// 分两次写出，模拟串口数据分段到达
+			port.Write(response[:2])
+			port.Write(response[2:])
+		}
+	}()
+
+	client := NewRTUClient(master, time.Second)
+	registers, err := client.ReadHoldingRegisters(1, 10, 2)
+	if err != nil || registers[0] != 1234 || registers[1] != 0 {
+		t.Fatalf("ReadHoldingRegisters = %v, %v", registers, err)
+	}
+	if err := client.WriteSingleCoil(1, 3, true); err != nil || !s.coils[3] {
+		t.Errorf("WriteSingleCoil: %v, coil = %v", err, s.coils[3])
+	}
+	s.failAddress = 7
+	var exception *Exception
+	if _, err := client.ReadInputRegisters(1, 7, 1); !errors.As(err, &exception) || exception.Code != 2 {
+		t.Errorf("read failing address: err = %v", err)
+	}
+}
+
+func TestGateway(t *testing.T) {
+	s := newSlave()
+	s.input[0] = uint16(0xFFFF - 14) // -15 * 0.1 = -1.5
+	s.inputs[4] = true
+	s.holding[20], s.holding[21] = 0x41B4, 0x0000 // 22.5
+	s.coils[2] = true
+	client, err := DialTCP(s.serveTCP(t), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	device := model.NewDevice(1, "Gateway", "")
+	gateway := NewGateway(client, time.Second)
+	points := []Point{
+		{ObjectType: model.ObjectTypeAnalogInput, Instance: 1, Unit: 1, Table: InputRegisters, Address: 0, DataType: Int16, Scale: 0.1},
+		{ObjectType: model.ObjectTypeBinaryInput, Instance: 1, Unit: 1, Table: DiscreteInputs, Address: 4},
+		{ObjectType: model.ObjectTypeAnalogOutput, Instance: 1, Name: "Setpoint", Unit: 1, Table: HoldingRegisters, Address: 20, DataType: Float32},
+		{ObjectType: model.ObjectTypeBinaryOutput, Instance: 1, Unit: 1, Table: Coils, Address: 2},
+	}
+	objects := make([]model.Object, len(points))
+	for i, p := range points {
+		if objects[i], err = gateway.AddPoint(device, p); err != nil {
+			t.Fatalf("AddPoint %d: %v", i, err)
+		}
+	}
+	if _, err := gateway.AddPoint(device, Point{ObjectType: model.ObjectTypeAnalogOutput, Instance: 2, Table: InputRegisters}); err == nil {
+		t.Error("analog output mapped to an input register was accepted")
+	}
+	ai, bi, ao, bo := objects[0].(*model.Analog), objects[1].(*model.Binary), objects[2].(*model.Analog), objects[3].(*model.Binary)
+	if ai.GetObjectName() != "Modbus 1 input-register 0" {
+		t.Errorf("default name = %q", ai.GetObjectName())
+	}
+
+	gateway.Poll()
+	if math.Abs(ai.Value()+1.5) > 1e-6 || !bi.Active() || ao.Value() != 22.5 || !bo.Active() {
+		t.Fatalf("after poll: ai %v, bi %v, ao %v, bo %v", ai.Value(), bi.Active(), ao.Value(), bo.Active())
+	}
+	if value, _ := ao.ReadProperty(model.PropertyIdentifierRelinquishDefault); value != float32(22.5) {
+		t.Errorf("ao Relinquish_Default = %v", value)
+	}
+
+	// 写穿：有效值变化时写入Modbus，释放后恢复为Relinquish_Default
+	if err := model.WriteWithPriority(ao, model.PropertyIdentifierPresentValue, float32(-4.25), 8); err != nil {
+		t.Fatal(err)
+	}
+	if bits := uint32(s.holding[20])<<16 | uint32(s.holding[21]); math.Float32frombits(bits) != -4.25 {
+		t.Errorf("holding register = %v, want -4.25", math.Float32frombits(bits))
+	}
+	if err := model.WriteWithPriority(bo, model.PropertyIdentifierPresentValue, false, 16); err != nil || s.coils[2] {
+		t.Errorf("write coil: %v, coil = %v", err, s.coils[2])
+	}
+	model.WriteWithPriority(ao, model.PropertyIdentifierPresentValue, nil, 8)
+	if bits := uint32(s.holding[20])<<16 | uint32(s.holding[21]); math.Float32frombits(bits) != 22.5 || ao.Value() != 22.5 {
+		t.Errorf("after relinquish: register %v, Present_Value %v", math.Float32frombits(bits), ao.Value())
+	}
+
+	// 通信失败时设置FAULT标志并撤销命令，恢复后清除
+	s.failAddress = 2
+	if err := model.WriteWithPriority(bo, model.PropertyIdentifierPresentValue, true, 8); err == nil {
+		t.Error("write to failing coil succeeded")
+	}
+	if bo.Active() || bo.PriorityArray(model.PropertyIdentifierPresentValue)[7] != nil || bo.GetStatusFlags()&model.StatusFlagFault == 0 ||
+		bo.GetReliability() != model.ReliabilityCommunicationFailure {
+		t.Errorf("after failed write: active %v, priority 8 = %v, flags %04b", bo.Active(), bo.PriorityArray(model.PropertyIdentifierPresentValue)[7], bo.GetStatusFlags())
+	}
+	s.failAddress = 0xFFFF
+	gateway.Poll()
+	if bo.GetStatusFlags()&model.StatusFlagFault != 0 {
+		t.Error("FAULT not cleared after a successful poll")
+	}
+}
+
+func TestPointEncoding(t *testing.T) {
+	p := Point{DataType: Int32, WordSwap: true, Scale: 0.01, Offset: 10}
+	registers, err := p.encode(-100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint16{0xD508, 0xFFFF}; registers[0] != want[0] || registers[1] != want[1] {
+		t.Errorf("encode = %04X, want %04X", registers, want)
+	}
+	if value := p.decode(registers); math.Abs(value+100) > 1e-9 {
+		t.Errorf("decode = %v, want -100", value)
+	}
+	if _, err := (&Point{DataType: Uint16}).encode(-1); !errors.Is(err, model.ErrValueOutOfRange) {
+		t.Errorf("encode out of range: err = %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// rtuTransport Modbus RTU传输。串口读操作可能一直阻塞，因此由单独的goroutine读取，
+// 请求方在超时时间内等待完整的应答帧
+type rtuTransport struct {
+	port    io.ReadWriteCloser
+	timeout time.Duration
+	input   chan []byte
+	errs    chan error
+	pending []byte
+}
+
+// NewRTUClient 在已打开的串口上创建Modbus RTU主站
+func NewRTUClient(port io.ReadWriteCloser, timeout time.Duration) *Client {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	t := &rtuTransport{
+		port:    port,
+		timeout: timeout,
+		input:   make(chan []byte, 16),
+		errs:    make(chan error, 1),
+	}
+	go t.receive()
+	return &Client{transport: t}
+}
+
+// receive 读取串口数据直到出错
+func (t *rtuTransport) receive() {
+	buf := make([]byte, 256)
+	for {
+		n, err := t.port.Read(buf)
+		if n > 0 {
+			t.input <- append([]byte(nil), buf[:n]...)
+		}
+		if err != nil {
+			t.errs <- err
+			close(t.input)
+			return
+		}
+	}
+}
+
+// send 发送RTU帧并等待同一从站的应答，广播地址0不等待应答
+func (t *rtuTransport) send(unit byte, pdu []byte) ([]byte, error) {
+	// 丢弃上一次超时请求迟到的数据
+	t.pending = t.pending[:0]
+	for drained := false; !drained; {
+		select {
+		case _, ok := <-t.input:
+			if !ok {
+				return nil, t.closed()
+			}
+		default:
+			drained = true
+		}
+	}
+
+	frame := append([]byte{unit}, pdu...)
+	frame = binary.LittleEndian.AppendUint16(frame, CRC16(frame))
+	if _, err := t.port.Write(frame); err != nil {
+		return nil, fmt.Errorf("modbus: 写串口失败: %v", err)
+	}
+	if unit == 0 {
+		return []byte{pdu[0]}, nil
+	}
+
+	timer := time.NewTimer(t.timeout)
+	defer timer.Stop()
+	for {
+		if n := rtuFrameLength(t.pending); n > 0 && len(t.pending) >= n {
+			response := t.pending[:n]
+			if CRC16(response[:n-2]) != binary.LittleEndian.Uint16(response[n-2:]) {
+				return nil, fmt.Errorf("modbus: 应答CRC校验错误")
+			}
+			if response[0] != unit {
+				return nil, fmt.Errorf("modbus: 应答的从站地址%d与请求%d不符", response[0], unit)
+			}
+			return append([]byte(nil), response[1:n-2]...), nil
+		}
+		select {
+		case data, ok := <-t.input:
+			if !ok {
+				return nil, t.closed()
+			}
+			t.pending = append(t.pending, data...)
+		case <-timer.C:
+			return nil, ErrTimeout
+		}
+	}
+}
+
+// closed 返回读goroutine退出的原因
+func (t *rtuTransport) closed() error {
+	select {
+	case err := <-t.errs:
+		t.errs <- err
+		return fmt.Errorf("modbus: 读串口失败: %v", err)
+	default:
+		return fmt.Errorf("modbus: 串口已关闭")
+	}
+}
+
+// rtuFrameLength 根据已收到的字节推算应答帧的总长度，长度未知时返回0
+func rtuFrameLength(frame []byte) int {
+	if len(frame) < 3 {
+		return 0
+	}
+	function := frame[1]
+	switch {
+	case function&0x80 != 0:
+		return 5
+	case function >= FuncReadCoils && function <= FuncReadInputRegisters:
+		return 3 + int(frame[2]) + 2
+	default:
+		return 8
+	}
+}
+
+// Close 关闭串口
+func (t *rtuTransport) Close() error {
+	return t.port.Close()
+}
+
+// CRC16 计算Modbus RTU帧校验（多项式0xA001，初值0xFFFF），帧中按低字节在前发送
+func CRC16(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
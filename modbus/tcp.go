@@ -0,0 +1,110 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+// DefaultTimeout 未指定时等待应答的时间
+const DefaultTimeout = time.Second
+
+// tcpTransport Modbus TCP传输，连接断开后在下一个请求时重新连接
+type tcpTransport struct {
+	address string
+	timeout time.Duration
+	conn    net.Conn
+	tid     uint16
+}
+
+// DialTCP 连接address（host:port，未给出端口时为502）上的Modbus TCP从站或网关
+func DialTCP(address string, timeout time.Duration) (*Client, error) {
+	client := NewTCPClient(address, timeout)
+	if err := client.transport.(*tcpTransport).connect(); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// NewTCPClient 创建Modbus TCP主站，在第一个请求时才建立连接，从站暂时不可达时请求返回错误
+func NewTCPClient(address string, timeout time.Duration) *Client {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "502")
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Client{transport: &tcpTransport{address: address, timeout: timeout}}
+}
+
+// connect 建立连接
+func (t *tcpTransport) connect() error {
+	conn, err := net.DialTimeout("tcp", t.address, t.timeout)
+	if err != nil {
+		return fmt.Errorf("modbus: 连接%s失败: %v", t.address, err)
+	}
+	t.conn = conn
+	return nil
+}
+
+// send 发送MBAP帧并等待事务号相同的应答，出错时断开连接
+func (t *tcpTransport) send(unit byte, pdu []byte) ([]byte, error) {
+	if t.conn == nil {
+		if err := t.connect(); err != nil {
+			return nil, err
+		}
+	}
+	t.tid++
+	frame := make([]byte, 7, 7+len(pdu))
+	binary.BigEndian.PutUint16(frame[0:], t.tid)
+	binary.BigEndian.PutUint16(frame[4:], uint16(1+len(pdu)))
+	frame[6] = unit
+	frame = append(frame, pdu...)
+
+	t.conn.SetDeadline(time.Now().Add(t.timeout))
+	if _, err := t.conn.Write(frame); err != nil {
+		return nil, t.fail(err)
+	}
+	for {
+		header := make([]byte, 7)
+		if _, err := io.ReadFull(t.conn, header); err != nil {
+			return nil, t.fail(err)
+		}
+		length := binary.BigEndian.Uint16(header[4:])
+		if binary.BigEndian.Uint16(header[2:]) != 0 || length < 2 || length > 254 {
+			return nil, t.fail(errors.New("无效的MBAP头"))
+		}
+		response := make([]byte, length-1)
+		if _, err := io.ReadFull(t.conn, response); err != nil {
+			return nil, t.fail(err)
+		}
+		// 丢弃之前超时的请求迟到的应答
+		if binary.BigEndian.Uint16(header[0:]) == t.tid {
+			return response, nil
+		}
+	}
+}
+
+// fail 断开连接并返回错误
+func (t *tcpTransport) fail(err error) error {
+	t.conn.Close()
+	t.conn = nil
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return ErrTimeout
+	}
+	return fmt.Errorf("modbus: %s: %v", t.address, err)
+}
+
+// Close 关闭连接
+func (t *tcpTransport) Close() error {
+	if t.conn == nil {
+		return nil
+	}
+	err := t.conn.Close()
+	t.conn = nil
+	return err
+}
@@ -0,0 +1,340 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Prescale 预分频（BACnetPrescale）：每个输入脉冲计multiplier，满moduloDivide计1
+type Prescale struct {
+	Multiplier   uint32
+	ModuloDivide uint32
+}
+
+// Scale 累加器的换算系数（BACnetScale），FloatScale和IntegerScale二选一：
+// FloatScale为每个计数代表的工程量，IntegerScale为10的幂次
+type Scale struct {
+	FloatScale   *float32
+	IntegerScale *int32
+}
+
+// Factor 返回每个计数代表的工程量
+func (s Scale) Factor() float64 {
+	switch {
+	case s.FloatScale != nil:
+		return float64(*s.FloatScale)
+	case s.IntegerScale != nil:
+		return math.Pow10(int(*s.IntegerScale))
+	}
+	return 1
+}
+
+// Accumulator 表示BACnet累加器对象，对输入脉冲计数并统计脉冲速率
+type Accumulator struct {
+	*BACnetObject
+	Scale                   Scale
+	Prescale                Prescale
+	MaxPresValue            uint32
+	ValueChangeTime         time.Time
+	ValueBeforeChange       uint32
+	ValueSet                uint32
+	PulseRate               uint32
+	HighLimit               uint32 // 0表示不监视
+	LowLimit                uint32
+	LimitMonitoringInterval uint32 // 脉冲速率统计周期（秒）
+
+	remainder      uint32    // 预分频余数
+	intervalPulses uint32    // 当前统计周期内的脉冲数
+	intervalStart  time.Time // 当前统计周期开始时间
+}
+
+// NewAccumulator 创建一个新的累加器对象
+func NewAccumulator(instance uint32, name string) *Accumulator {
+	accumulator := &Accumulator{
+		BACnetObject:            NewBACnetObject(ObjectTypeAccumulator, instance, name),
+		Scale:                   Scale{IntegerScale: new(int32)},
+		Prescale:                Prescale{Multiplier: 1, ModuloDivide: 1},
+		MaxPresValue:            0xFFFFFFFF,
+		LimitMonitoringInterval: 60,
+	}
+	accumulator.Properties[PropertyIdentifierPresentValue] = uint32(0)
+	return accumulator
+}
+
+// Value 返回当前计数值
+func (a *Accumulator) Value() uint32 {
+	value, _ := a.Properties[PropertyIdentifierPresentValue].(uint32)
+	return value
+}
+
+// AddPulses 输入脉冲，经预分频后累加到Present_Value，超过Max_Pres_Value时回绕
+func (a *Accumulator) AddPulses(pulses uint32) {
+	a.intervalPulses += pulses
+
+	divide := a.Prescale.ModuloDivide
+	if divide == 0 {
+		divide = 1
+	}
+	raw := uint64(a.remainder) + uint64(pulses)*uint64(a.Prescale.Multiplier)
+	a.remainder = uint32(raw % uint64(divide))
+
+	span := uint64(a.MaxPresValue) + 1
+	value := (uint64(a.Value()) + raw/uint64(divide)) % span
+	a.BACnetObject.WriteProperty(PropertyIdentifierPresentValue, uint32(value))
+}
+
+// SetValue 设定计数值并记录变化前的值（对应写Value_Set）
+func (a *Accumulator) SetValue(value uint32, now time.Time) error {
+	if value > a.MaxPresValue {
+		return fmt.Errorf("Value_Set不能大于Max_Pres_Value")
+	}
+	a.ValueBeforeChange = a.Value()
+	a.ValueSet = value
+	a.ValueChangeTime = now
+	a.remainder = 0
+	a.BACnetObject.WriteProperty(PropertyIdentifierPresentValue, value)
+	return nil
+}
+
+// Execute 每个Limit_Monitoring_Interval结束时更新Pulse_Rate并检查上下限
+func (a *Accumulator) Execute(device *Device, now time.Time) {
+	if a.intervalStart.IsZero() {
+		a.intervalStart = now
+		return
+	}
+	if a.LimitMonitoringInterval == 0 || now.Sub(a.intervalStart) < time.Duration(a.LimitMonitoringInterval)*time.Second {
+		return
+	}
+	a.PulseRate = a.intervalPulses
+	a.intervalPulses = 0
+	a.intervalStart = now
+	a.checkPulseRate()
+}
+
+// checkPulseRate 检查Pulse_Rate是否超出High_Limit/Low_Limit并产生事件
+func (a *Accumulator) checkPulseRate() {
+	state := EventStateNormal
+	switch {
+	case a.HighLimit > 0 && a.PulseRate > a.HighLimit:
+		state = EventStateHighLimit
+	case a.PulseRate < a.LowLimit:
+		state = EventStateLowLimit
+	}
+	if state != a.GetEventState() {
+		a.GenerateEvent(state, fmt.Sprintf("脉冲速率: %d", a.PulseRate))
+	}
+}
+
+// ReadProperty 读取累加器属性
+func (a *Accumulator) ReadProperty(prop PropertyIdentifier) (interface{}, error) {
+	switch prop {
+	case PropertyIdentifierScale:
+		return a.Scale, nil
+	case PropertyIdentifierPrescale:
+		return a.Prescale, nil
+	case PropertyIdentifierMaxPresValue:
+		return a.MaxPresValue, nil
+	case PropertyIdentifierValueChangeTime:
+		return DateTime{a.ValueChangeTime}, nil
+	case PropertyIdentifierValueBeforeChange:
+		return a.ValueBeforeChange, nil
+	case PropertyIdentifierValueSet:
+		return a.ValueSet, nil
+	case PropertyIdentifierPulseRate:
+		return a.PulseRate, nil
+	case PropertyIdentifierHighLimit:
+		return a.HighLimit, nil
+	case PropertyIdentifierLowLimit:
+		return a.LowLimit, nil
+	case PropertyIdentifierLimitMonitoringInterval:
+		return a.LimitMonitoringInterval, nil
+	}
+	return a.BACnetObject.ReadProperty(prop)
+}
+
+// WriteProperty 写入累加器属性，写Present_Value或Value_Set时记录变化前的值
+func (a *Accumulator) WriteProperty(prop PropertyIdentifier, value interface{}) error {
+	switch prop {
+	case PropertyIdentifierPresentValue, PropertyIdentifierValueSet:
+		count, ok := toUint32(value)
+		if !ok {
+			return fmt.Errorf("计数值类型无效")
+		}
+		return a.SetValue(count, a.now())
+	case PropertyIdentifierScale:
+		scale, ok := value.(Scale)
+		if !ok || (scale.FloatScale == nil) == (scale.IntegerScale == nil) {
+			return fmt.Errorf("Scale值无效")
+		}
+		a.Scale = scale
+		return nil
+	case PropertyIdentifierPrescale:
+		prescale, ok := value.(Prescale)
+		if !ok || prescale.Multiplier == 0 || prescale.ModuloDivide == 0 {
+			return fmt.Errorf("Prescale值无效")
+		}
+		a.Prescale = prescale
+		return nil
+	case PropertyIdentifierPulseRate, PropertyIdentifierValueBeforeChange, PropertyIdentifierValueChangeTime:
+		return fmt.Errorf("只读属性")
+	}
+
+	if target := a.unsignedField(prop); target != nil {
+		number, ok := toUint32(value)
+		if !ok {
+			return fmt.Errorf("属性值必须为无符号整数")
+		}
+		*target = number
+		return nil
+	}
+	return a.BACnetObject.WriteProperty(prop, value)
+}
+
+// unsignedField 返回无符号整数型累加器参数对应的字段
+func (a *Accumulator) unsignedField(prop PropertyIdentifier) *uint32 {
+	switch prop {
+	case PropertyIdentifierMaxPresValue:
+		return &a.MaxPresValue
+	case PropertyIdentifierHighLimit:
+		return &a.HighLimit
+	case PropertyIdentifierLowLimit:
+		return &a.LowLimit
+	case PropertyIdentifierLimitMonitoringInterval:
+		return &a.LimitMonitoringInterval
+	}
+	return nil
+}
+
+// PulseConverter 表示BACnet脉冲转换器对象，将输入计数的增量按Scale_Factor换算为工程量
+type PulseConverter struct {
+	*BACnetObject
+	InputReference    *DeviceObjectPropertyReference // 输入计数（通常为累加器的Present_Value）
+	ScaleFactor       float32
+	AdjustValue       float32
+	Count             uint32
+	UpdateTime        time.Time
+	CountChangeTime   time.Time
+	CountBeforeChange uint32
+
+	lastInput   uint32
+	inputPrimed bool
+}
+
+// NewPulseConverter 创建一个新的脉冲转换器对象
+func NewPulseConverter(instance uint32, name string) *PulseConverter {
+	converter := &PulseConverter{
+		BACnetObject: NewBACnetObject(ObjectTypePulseConverter, instance, name),
+		ScaleFactor:  1,
+	}
+	converter.Properties[PropertyIdentifierPresentValue] = float32(0)
+	return converter
+}
+
+// Value 返回当前工程量
+func (p *PulseConverter) Value() float32 {
+	value, _ := p.Properties[PropertyIdentifierPresentValue].(float32)
+	return value
+}
+
+// AddCount 累加计数增量并更新Present_Value
+func (p *PulseConverter) AddCount(delta uint32, now time.Time) {
+	if delta == 0 {
+		return
+	}
+	p.Count += delta
+	p.UpdateTime = now
+	p.BACnetObject.WriteProperty(PropertyIdentifierPresentValue, p.Value()+float32(delta)*p.ScaleFactor)
+}
+
+// Execute 读取输入计数并累加增量，累加器回绕时按Max_Pres_Value修正
+func (p *PulseConverter) Execute(device *Device, now time.Time) {
+	if p.InputReference == nil {
+		return
+	}
+	obj, err := device.ResolveReference(*p.InputReference)
+	if err != nil {
+		return
+	}
+	value, err := ReadPropertyValue(obj, p.InputReference.PropertyIdentifier)
+	if err != nil {
+		return
+	}
+	input, ok := toUint32(value)
+	if !ok {
+		return
+	}
+	if !p.inputPrimed {
+		p.lastInput = input
+		p.inputPrimed = true
+		return
+	}
+
+	delta := input - p.lastInput
+	if input < p.lastInput {
+		if accumulator, ok := obj.(*Accumulator); ok && accumulator.MaxPresValue != 0xFFFFFFFF {
+			delta = accumulator.MaxPresValue - p.lastInput + input + 1
+		}
+	}
+	p.lastInput = input
+	p.AddCount(delta, now)
+}
+
+// ReadProperty 读取脉冲转换器属性
+func (p *PulseConverter) ReadProperty(prop PropertyIdentifier) (interface{}, error) {
+	switch prop {
+	case PropertyIdentifierInputReference:
+		if p.InputReference == nil {
+			return nil, nil
+		}
+		return *p.InputReference, nil
+	case PropertyIdentifierScaleFactor:
+		return p.ScaleFactor, nil
+	case PropertyIdentifierAdjustValue:
+		return p.AdjustValue, nil
+	case PropertyIdentifierCount:
+		return p.Count, nil
+	case PropertyIdentifierUpdateTime:
+		return DateTime{p.UpdateTime}, nil
+	case PropertyIdentifierCountChangeTime:
+		return DateTime{p.CountChangeTime}, nil
+	case PropertyIdentifierCountBeforeChange:
+		return p.CountBeforeChange, nil
+	}
+	return p.BACnetObject.ReadProperty(prop)
+}
+
+// WriteProperty 写入脉冲转换器属性，写Adjust_Value时清零Count并修正Present_Value
+func (p *PulseConverter) WriteProperty(prop PropertyIdentifier, value interface{}) error {
+	switch prop {
+	case PropertyIdentifierAdjustValue:
+		adjust, ok := toFloat64(value)
+		if !ok {
+			return fmt.Errorf("Adjust_Value类型无效")
+		}
+		p.AdjustValue = float32(adjust)
+		p.CountBeforeChange = p.Count
+		p.Count = 0
+		p.CountChangeTime = p.now()
+		p.BACnetObject.WriteProperty(PropertyIdentifierPresentValue, p.Value()+float32(adjust))
+		return nil
+	case PropertyIdentifierScaleFactor:
+		scale, ok := toFloat64(value)
+		if !ok {
+			return fmt.Errorf("Scale_Factor类型无效")
+		}
+		p.ScaleFactor = float32(scale)
+		return nil
+	case PropertyIdentifierInputReference:
+		ref, ok := value.(DeviceObjectPropertyReference)
+		if !ok {
+			return fmt.Errorf("Input_Reference类型无效")
+		}
+		p.InputReference = &ref
+		p.inputPrimed = false
+		return nil
+	case PropertyIdentifierCount, PropertyIdentifierUpdateTime, PropertyIdentifierCountChangeTime, PropertyIdentifierCountBeforeChange:
+		return fmt.Errorf("只读属性")
+	}
+	return p.BACnetObject.WriteProperty(prop, value)
+}
@@ -0,0 +1,125 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAccumulatorPrescale(t *testing.T) {
+	accumulator := NewAccumulator(1, "Meter")
+	accumulator.Prescale = Prescale{Multiplier: 3, ModuloDivide: 2}
+
+	// 每个脉冲计3，满2计1，余数保留到下一次
+	for i, want := range []uint32{1, 3, 4, 6} {
+		accumulator.AddPulses(1)
+		if got := accumulator.Value(); got != want {
+			t.Fatalf("pulse %d Present_Value = %d, want %d", i, got, want)
+		}
+	}
+
+	// 设定计数值时清除余数并记录变化前的值
+	now := time.Date(2024, 3, 1, 8, 0, 0, 0, time.Local)
+	if err := accumulator.SetValue(10, now); err != nil {
+		t.Fatal(err)
+	}
+	accumulator.AddPulses(1)
+	if got := accumulator.Value(); got != 11 {
+		t.Errorf("Present_Value after SetValue = %d, want 11", got)
+	}
+	if accumulator.ValueBeforeChange != 6 {
+		t.Errorf("Value_Before_Change = %d, want 6", accumulator.ValueBeforeChange)
+	}
+	if got, _ := accumulator.ReadProperty(PropertyIdentifierValueChangeTime); got != (DateTime{now}) {
+		t.Errorf("Value_Change_Time = %v, want %v", got, now)
+	}
+}
+
+func TestAccumulatorWrap(t *testing.T) {
+	accumulator := NewAccumulator(1, "Meter")
+	accumulator.MaxPresValue = 99
+	accumulator.SetValue(98, time.Time{})
+
+	accumulator.AddPulses(5)
+	if got := accumulator.Value(); got != 3 {
+		t.Errorf("Present_Value after wrap = %d, want 3", got)
+	}
+	if err := accumulator.SetValue(100, time.Time{}); err == nil {
+		t.Error("SetValue above Max_Pres_Value succeeded")
+	}
+}
+
+func TestAccumulatorScale(t *testing.T) {
+	accumulator := NewAccumulator(1, "Meter")
+	if got := accumulator.Scale.Factor(); got != 1 {
+		t.Errorf("default Scale.Factor() = %v, want 1", got)
+	}
+
+	half := float32(0.5)
+	if err := accumulator.WriteProperty(PropertyIdentifierScale, Scale{FloatScale: &half}); err != nil {
+		t.Fatal(err)
+	}
+	if got := accumulator.Scale.Factor(); got != 0.5 {
+		t.Errorf("floatScale Factor() = %v, want 0.5", got)
+	}
+	kilo := int32(3)
+	if err := accumulator.WriteProperty(PropertyIdentifierScale, Scale{IntegerScale: &kilo}); err != nil {
+		t.Fatal(err)
+	}
+	if got := accumulator.Scale.Factor(); got != 1000 {
+		t.Errorf("integerScale Factor() = %v, want 1000", got)
+	}
+
+	// 两个选项必须且只能设置一个
+	for _, scale := range []Scale{{}, {FloatScale: &half, IntegerScale: &kilo}} {
+		if err := accumulator.WriteProperty(PropertyIdentifierScale, scale); err == nil {
+			t.Errorf("WriteProperty(Scale=%+v) succeeded", scale)
+		}
+	}
+	if err := accumulator.WriteProperty(PropertyIdentifierPrescale, Prescale{Multiplier: 1}); err == nil {
+		t.Error("WriteProperty(Prescale moduloDivide=0) succeeded")
+	}
+}
+
+func TestPulseConverterCount(t *testing.T) {
+	device := NewDevice(1, "Device", "")
+	accumulator := NewAccumulator(1, "Meter")
+	accumulator.MaxPresValue = 999
+	converter := NewPulseConverter(1, "Energy")
+	converter.ScaleFactor = 0.5
+	converter.InputReference = &DeviceObjectPropertyReference{
+		ObjectIdentifier: accumulator.GetObjectIdentifier(), PropertyIdentifier: PropertyIdentifierPresentValue,
+	}
+	device.AddObject(accumulator)
+	device.AddObject(converter)
+
+	start := time.Date(2024, 3, 1, 8, 0, 0, 0, time.Local)
+	accumulator.SetValue(990, start)
+	converter.Execute(device, start)
+
+	// 第一次执行只记录输入，之后按增量换算，累加器回绕时按Max_Pres_Value修正
+	accumulator.AddPulses(6)
+	converter.Execute(device, start.Add(time.Second))
+	accumulator.AddPulses(8)
+	converter.Execute(device, start.Add(2*time.Second))
+
+	if converter.Count != 14 {
+		t.Errorf("Count = %d, want 14", converter.Count)
+	}
+	if got, _ := converter.ReadProperty(PropertyIdentifierPresentValue); got != float32(7) {
+		t.Errorf("Present_Value = %v (%T), want float32(7)", got, got)
+	}
+	if got, _ := converter.ReadProperty(PropertyIdentifierUpdateTime); got != (DateTime{start.Add(2 * time.Second)}) {
+		t.Errorf("Update_Time = %v", got)
+	}
+
+	// 写Adjust_Value清零Count并修正Present_Value
+	if err := converter.WriteProperty(PropertyIdentifierAdjustValue, float32(-2)); err != nil {
+		t.Fatal(err)
+	}
+	if converter.Count != 0 || converter.CountBeforeChange != 14 {
+		t.Errorf("Count = %d, Count_Before_Change = %d", converter.Count, converter.CountBeforeChange)
+	}
+	if got := converter.Value(); got != 5 {
+		t.Errorf("Present_Value after Adjust_Value = %v, want 5", got)
+	}
+}
@@ -0,0 +1,235 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Analog 表示BACnet模拟输入、输出和值对象，Present_Value为float64
+// Present_Value以float32（REAL）存储，写入时按Min_Pres_Value/Max_Pres_Value检查范围
+type Analog struct {
+	*BACnetObject
+	Units        EngineeringUnits
+	MinPresValue float64
+	MaxPresValue float64
+	Resolution   float64 // 仅模拟输入：测量分辨率，0表示不量化
+
+	pendingState EventState // 等待Time_Delay或Time_Delay_Normal到期的事件状态
+	pendingSince time.Time  // 开始满足pendingState条件的时间，零值表示没有等待的转换
+}
+
+// NewAnalogInput 创建模拟输入对象
+func NewAnalogInput(instance uint32, name string, units EngineeringUnits) *Analog {
+	return newAnalog(ObjectTypeAnalogInput, instance, name, units)
+}
+
+// NewAnalogOutput 创建模拟输出对象
+func NewAnalogOutput(instance uint32, name string, units EngineeringUnits) *Analog {
+	return newAnalog(ObjectTypeAnalogOutput, instance, name, units)
+}
+
+// NewAnalogValue 创建模拟值对象
+func NewAnalogValue(instance uint32, name string, units EngineeringUnits) *Analog {
+	return newAnalog(ObjectTypeAnalogValue, instance, name, units)
+}
+
+// newAnalog 创建模拟量对象，默认不限制取值范围
+func newAnalog(objType ObjectType, instance uint32, name string, units EngineeringUnits) *Analog {
+	analog := &Analog{
+		BACnetObject: NewBACnetObject(objType, instance, name),
+		Units:        units,
+		MinPresValue: math.Inf(-1),
+		MaxPresValue: math.Inf(1),
+	}
+	analog.Properties[PropertyIdentifierPresentValue] = float32(0)
+	if analog.Commandable(PropertyIdentifierPresentValue) {
+		analog.Properties[PropertyIdentifierRelinquishDefault] = float32(0)
+	}
+	return analog
+}
+
+// SetRange 设置Min_Pres_Value和Max_Pres_Value
+func (a *Analog) SetRange(min, max float64) {
+	a.MinPresValue, a.MaxPresValue = min, max
+}
+
+// Value 返回当前Present_Value
+func (a *Analog) Value() float64 {
+	value, _ := toFloat64(a.Properties[PropertyIdentifierPresentValue])
+	return value
+}
+
+// ReadProperty 读取模拟量对象属性
+func (a *Analog) ReadProperty(prop PropertyIdentifier) (interface{}, error) {
+	switch prop {
+	case PropertyIdentifierUnits:
+		return a.Units, nil
+	case PropertyIdentifierMinPresValue:
+		return float32(a.MinPresValue), nil
+	case PropertyIdentifierMaxPresValue:
+		return float32(a.MaxPresValue), nil
+	case PropertyIdentifierResolution:
+		return float32(a.Resolution), nil
+	}
+	return a.BACnetObject.ReadProperty(prop)
+}
+
+// WriteProperty 写入模拟量对象属性，Present_Value超出Min/Max_Pres_Value时返回ErrValueOutOfRange
+func (a *Analog) WriteProperty(prop PropertyIdentifier, value interface{}) error {
+	switch prop {
+	case PropertyIdentifierPresentValue:
+		return a.WritePropertyWithPriority(prop, value, 16)
+	case PropertyIdentifierRelinquishDefault:
+		number, err := a.checkPresentValue(value)
+		if err != nil {
+			return err
+		}
+		return a.BACnetObject.WriteProperty(prop, number)
+	case PropertyIdentifierUnits:
+		units, ok := toUint32(value)
+		if !ok || units > math.MaxUint16 {
+			return fmt.Errorf("Units类型无效")
+		}
+		a.Units = EngineeringUnits(units)
+		return nil
+	case PropertyIdentifierMinPresValue, PropertyIdentifierMaxPresValue, PropertyIdentifierResolution:
+		number, ok := toFloat64(value)
+		if !ok {
+			return fmt.Errorf("属性值必须为数值类型")
+		}
+		switch prop {
+		case PropertyIdentifierMinPresValue:
+			a.MinPresValue = number
+		case PropertyIdentifierMaxPresValue:
+			a.MaxPresValue = number
+		default:
+			a.Resolution = number
+		}
+		return nil
+	}
+	return a.BACnetObject.WriteProperty(prop, value)
+}
+
+// WritePropertyWithPriority 按优先级写入，Present_Value同样进行范围检查，nil表示释放该优先级
+func (a *Analog) WritePropertyWithPriority(prop PropertyIdentifier, value interface{}, priority uint8) error {
+	if prop == PropertyIdentifierPresentValue {
+		if a.isInput() && !a.OutOfService() {
+			return ErrWriteAccessDenied
+		}
+		if value == nil {
+			return a.BACnetObject.WritePropertyWithPriority(prop, nil, priority)
+		}
+		number, err := a.checkPresentValue(value)
+		if err != nil {
+			return err
+		}
+		value = number
+	}
+	return a.BACnetObject.WritePropertyWithPriority(prop, value, priority)
+}
+
+// UpdatePresentValue 由现场驱动或模拟数据更新Present_Value，对象停用时忽略更新
+func (a *Analog) UpdatePresentValue(value interface{}) error {
+	if a.OutOfService() {
+		return nil
+	}
+	number, err := a.checkPresentValue(value)
+	if err != nil {
+		return err
+	}
+	return a.BACnetObject.WriteProperty(PropertyIdentifierPresentValue, number)
+}
+
+// checkPresentValue 检查并转换Present_Value，模拟输入按Resolution量化
+func (a *Analog) checkPresentValue(value interface{}) (float32, error) {
+	number, ok := toFloat64(value)
+	if !ok {
+		return 0, fmt.Errorf("Present_Value类型无效")
+	}
+	if number < a.MinPresValue || number > a.MaxPresValue {
+		return 0, ErrValueOutOfRange
+	}
+	if a.GetObjectType() == ObjectTypeAnalogInput && a.Resolution > 0 {
+		number = math.Round(number/a.Resolution) * a.Resolution
+	}
+	return float32(number), nil
+}
+
+// Execute 按OUT_OF_RANGE算法检测Present_Value越限：越限持续Time_Delay秒后转换到high-limit或low-limit，
+// 回到限值减去（加上）Deadband以内持续Time_Delay_Normal秒后恢复normal，条件中途不再满足时重新计时
+func (a *Analog) Execute(device *Device, now time.Time) {
+	target, delay, ok := a.outOfRange()
+	if !ok || target == a.GetEventState() {
+		a.pendingSince = time.Time{}
+		return
+	}
+	if a.pendingSince.IsZero() || a.pendingState != target {
+		a.pendingState, a.pendingSince = target, now
+	}
+	if now.Sub(a.pendingSince) < delay {
+		return
+	}
+	a.pendingSince = time.Time{}
+	limit := PropertyIdentifierLowLimit
+	if target == EventStateHighLimit || target == EventStateNormal && a.GetEventState() == EventStateHighLimit {
+		limit = PropertyIdentifierHighLimit
+	}
+	exceeded, _ := toFloat64(a.Properties[limit])
+	deadband, _ := toFloat64(a.Properties[PropertyIdentifierDeadband])
+	a.generateEvent(target, fmt.Sprintf("Present_Value: %g", a.Value()), OutOfRangeEventValues{
+		ExceedingValue: float32(a.Value()),
+		Deadband:       float32(deadband),
+		ExceededLimit:  float32(exceeded),
+	})
+}
+
+// outOfRange 返回当前值要求的事件状态和转换前条件需要保持的时间。
+// 没有启用的限值、Event_Detection_Enable为false或对象处于fault等其他状态时ok为false；
+// 对象处于high-limit（low-limit）时禁用该限值立即恢复normal
+func (a *Analog) outOfRange() (state EventState, delay time.Duration, ok bool) {
+	if enabled, isBool := a.Properties[PropertyIdentifierEventDetectionEnable].(bool); isBool && !enabled {
+		return 0, 0, false
+	}
+	limitEnable, _ := a.Properties[PropertyIdentifierLimitEnable].(BitString)
+	high, highEnabled := toFloat64(a.Properties[PropertyIdentifierHighLimit])
+	highEnabled = highEnabled && limitEnable.Bit(1)
+	low, lowEnabled := toFloat64(a.Properties[PropertyIdentifierLowLimit])
+	lowEnabled = lowEnabled && limitEnable.Bit(0)
+	deadband, _ := toFloat64(a.Properties[PropertyIdentifierDeadband])
+	timeDelay, _ := toUint32(a.Properties[PropertyIdentifierTimeDelay])
+	timeDelayNormal, hasNormal := toUint32(a.Properties[PropertyIdentifierTimeDelayNormal])
+	if !hasNormal {
+		timeDelayNormal = timeDelay
+	}
+
+	value := a.Value()
+	current := a.GetEventState()
+	switch {
+	case highEnabled && value > high:
+		return EventStateHighLimit, time.Duration(timeDelay) * time.Second, true
+	case lowEnabled && value < low:
+		return EventStateLowLimit, time.Duration(timeDelay) * time.Second, true
+	}
+	switch current {
+	case EventStateNormal:
+		return EventStateNormal, 0, highEnabled || lowEnabled
+	case EventStateHighLimit:
+		if !highEnabled {
+			return EventStateNormal, 0, true
+		}
+		if value >= high-deadband {
+			return EventStateHighLimit, 0, true
+		}
+	case EventStateLowLimit:
+		if !lowEnabled {
+			return EventStateNormal, 0, true
+		}
+		if value <= low+deadband {
+			return EventStateLowLimit, 0, true
+		}
+	default:
+		return 0, 0, false
+	}
+	return EventStateNormal, time.Duration(timeDelayNormal) * time.Second, true
+}
@@ -0,0 +1,112 @@
+package model
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAnalogPresentValueRange(t *testing.T) {
+	valve := NewAnalogOutput(1, "Valve", UnitsPercent)
+	valve.SetRange(0, 100)
+
+	tests := []struct {
+		value interface{}
+		err   error
+	}{
+		{float32(0), nil},
+		{100.0, nil},
+		{uint32(50), nil},
+		{float32(-0.5), ErrValueOutOfRange},
+		{100.5, ErrValueOutOfRange},
+	}
+	for _, tt := range tests {
+		if err := valve.WriteProperty(PropertyIdentifierPresentValue, tt.value); !errors.Is(err, tt.err) {
+			t.Errorf("WriteProperty(Present_Value=%v) = %v, want %v", tt.value, err, tt.err)
+		}
+	}
+	// 被拒绝的写入不改变Present_Value，范围检查同样适用于Relinquish_Default
+	if got := valve.Value(); got != 50 {
+		t.Errorf("Present_Value = %v, want 50", got)
+	}
+	if err := valve.WriteProperty(PropertyIdentifierRelinquishDefault, float32(101)); !errors.Is(err, ErrValueOutOfRange) {
+		t.Errorf("WriteProperty(Relinquish_Default=101) = %v, want ErrValueOutOfRange", err)
+	}
+	if err := valve.WritePropertyWithPriority(PropertyIdentifierPresentValue, float32(150), 8); !errors.Is(err, ErrValueOutOfRange) {
+		t.Errorf("WritePropertyWithPriority(150, 8) = %v, want ErrValueOutOfRange", err)
+	}
+	if err := valve.WriteProperty(PropertyIdentifierPresentValue, "50"); err == nil {
+		t.Error("WriteProperty(Present_Value=\"50\") succeeded")
+	}
+
+	// 读取范围属性为REAL
+	if got, _ := valve.ReadProperty(PropertyIdentifierMaxPresValue); got != float32(100) {
+		t.Errorf("Max_Pres_Value = %v (%T), want float32(100)", got, got)
+	}
+}
+
+func TestAnalogInputResolution(t *testing.T) {
+	sensor := NewAnalogInput(1, "Temperature", UnitsDegreesCelsius)
+	sensor.Resolution = 0.5
+
+	if err := sensor.UpdatePresentValue(21.3); err != nil {
+		t.Fatal(err)
+	}
+	if got := sensor.Value(); got != 21.5 {
+		t.Errorf("quantized Present_Value = %v, want 21.5", got)
+	}
+	// 输入对象在运行中不接受写入，停用时忽略现场更新
+	if err := sensor.WriteProperty(PropertyIdentifierPresentValue, float32(30)); !errors.Is(err, ErrWriteAccessDenied) {
+		t.Errorf("WriteProperty(Present_Value) in service = %v, want ErrWriteAccessDenied", err)
+	}
+	sensor.WriteProperty(PropertyIdentifierOutOfService, true)
+	sensor.UpdatePresentValue(25.0)
+	if got := sensor.Value(); got != 21.5 {
+		t.Errorf("Present_Value after update while out of service = %v, want 21.5", got)
+	}
+}
+
+func TestAnalogUnits(t *testing.T) {
+	sensor := NewAnalogValue(1, "Flow", UnitsNoUnits)
+
+	// 命名类型和解码得到的无符号数都可以写入
+	if err := sensor.WriteProperty(PropertyIdentifierUnits, UnitsLitersPerSecond); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := sensor.ReadProperty(PropertyIdentifierUnits); got != UnitsLitersPerSecond {
+		t.Errorf("Units = %v, want %v", got, UnitsLitersPerSecond)
+	}
+	if err := sensor.WriteProperty(PropertyIdentifierUnits, uint32(UnitsDegreesCelsius)); err != nil || sensor.Units != UnitsDegreesCelsius {
+		t.Errorf("WriteProperty(Units=62) = %v, Units = %v", err, sensor.Units)
+	}
+	if err := sensor.WriteProperty(PropertyIdentifierUnits, uint32(70000)); err == nil {
+		t.Error("WriteProperty(Units=70000) succeeded")
+	}
+}
+
+func TestEngineeringUnitsNames(t *testing.T) {
+	for _, tt := range []struct {
+		units EngineeringUnits
+		value uint16
+		name  string
+	}{
+		{UnitsDegreesCelsius, 62, "degrees-celsius"},
+		{UnitsPercent, 98, "percent"},
+		{UnitsNoUnits, 95, "no-units"},
+	} {
+		if uint16(tt.units) != tt.value || tt.units.String() != tt.name {
+			t.Errorf("%s = %d, want %s = %d", tt.units, uint16(tt.units), tt.name, tt.value)
+		}
+		if parsed, err := ParseEngineeringUnits(tt.name); err != nil || parsed != tt.units {
+			t.Errorf("ParseEngineeringUnits(%q) = %v, %v", tt.name, parsed, err)
+		}
+	}
+	if parsed, err := ParseEngineeringUnits("units-300"); err != nil || parsed != 300 {
+		t.Errorf("ParseEngineeringUnits(units-300) = %v, %v", parsed, err)
+	}
+	if got := EngineeringUnits(300).String(); got != "units-300" {
+		t.Errorf("EngineeringUnits(300).String() = %q", got)
+	}
+	if _, err := ParseEngineeringUnits("furlongs"); err == nil {
+		t.Error("ParseEngineeringUnits(furlongs) succeeded")
+	}
+}
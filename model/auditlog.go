@@ -0,0 +1,109 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// AuditOperation 审计的操作（BACnetAuditOperation）
+type AuditOperation uint8
+
+const (
+	AuditOperationRead              AuditOperation = 0
+	AuditOperationWrite             AuditOperation = 1
+	AuditOperationCreate            AuditOperation = 2
+	AuditOperationDelete            AuditOperation = 3
+	AuditOperationLifeSafety        AuditOperation = 4
+	AuditOperationAcknowledgeAlarm  AuditOperation = 5
+	AuditOperationDeviceDisableComm AuditOperation = 6
+	AuditOperationDeviceEnableComm  AuditOperation = 7
+	AuditOperationDeviceReset       AuditOperation = 8
+	AuditOperationDeviceBackup      AuditOperation = 9
+	AuditOperationDeviceRestore     AuditOperation = 10
+	AuditOperationSubscription      AuditOperation = 11
+	AuditOperationNotification      AuditOperation = 12
+	AuditOperationAuditingFailure   AuditOperation = 13
+	AuditOperationNetworkChanges    AuditOperation = 14
+	AuditOperationGeneral           AuditOperation = 15
+)
+
+// AuditFailure 被审计的操作失败时的BACnet错误类别和错误代码
+type AuditFailure struct {
+	Class uint16
+	Code  uint16
+}
+
+// AuditNotification 一条审计通知（BACnetAuditNotification），只包含本设备作为目标设备时能给出的字段
+type AuditNotification struct {
+	SourceDevice   Recipient           // 发起操作的设备，网络上的客户端为其B/IP地址，本地操作为本设备
+	Operation      AuditOperation      // 操作类型
+	TargetDevice   ObjectIdentifier    // 执行操作的设备
+	TargetObject   *ObjectIdentifier   // 被操作的对象
+	TargetProperty *PropertyIdentifier // 被写入的属性
+	TargetPriority uint8               // 写入的优先级，为0时不带
+	TargetValue    interface{}         `json:"-"` // 写入的值，为nil时不带
+	Result         *AuditFailure       // 操作失败时的错误，成功时为nil
+}
+
+// AuditLog 表示BACnet审计日志对象，记录设备上可审计的操作
+type AuditLog struct {
+	*BACnetObject
+	Log *LogBuffer
+}
+
+// NewAuditLog 创建一个新的审计日志对象
+func NewAuditLog(instance uint32, name string, bufferSize uint32) *AuditLog {
+	auditLog := &AuditLog{
+		BACnetObject: NewBACnetObject(ObjectTypeAuditLog, instance, name),
+		Log:          NewLogBuffer(bufferSize),
+	}
+	auditLog.Properties[PropertyIdentifierLogEnable] = true
+	return auditLog
+}
+
+// Buffer 返回日志缓冲区
+func (a *AuditLog) Buffer() *LogBuffer {
+	return a.Log
+}
+
+// Enabled 判断日志是否启用
+func (a *AuditLog) Enabled() bool {
+	enabled, _ := a.Properties[PropertyIdentifierLogEnable].(bool)
+	return enabled
+}
+
+// LogAudit 记录一条审计通知，缓冲区满且为停止模式时禁用日志
+func (a *AuditLog) LogAudit(timestamp time.Time, notification AuditNotification) {
+	if !a.Enabled() {
+		return
+	}
+	a.Log.Append(timestamp, notification)
+	if a.Log.StopWhenFull && a.Log.Full() {
+		a.Properties[PropertyIdentifierLogEnable] = false
+		logger().Warn("审计日志缓冲区已满，停止记录", "object", a.Name)
+	}
+}
+
+// ReadProperty 读取审计日志属性，缓冲区相关属性实时计算
+func (a *AuditLog) ReadProperty(prop PropertyIdentifier) (interface{}, error) {
+	if value, ok := a.Log.readBufferProperty(prop); ok {
+		return value, nil
+	}
+	return a.BACnetObject.ReadProperty(prop)
+}
+
+// WriteProperty 写入审计日志属性
+func (a *AuditLog) WriteProperty(prop PropertyIdentifier, value interface{}) error {
+	if handled, err := a.Log.writeBufferProperty(prop, value, a.now()); handled {
+		return err
+	}
+	if prop == PropertyIdentifierLogEnable {
+		enable, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("Log_Enable类型无效")
+		}
+		setLogEnable(a.BACnetObject, a.Log, enable)
+		return nil
+	}
+	return a.BACnetObject.WriteProperty(prop, value)
+}
@@ -0,0 +1,184 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// averagingSample 平均值对象的一个采样，valid为false表示采样失败
+type averagingSample struct {
+	value     float64
+	timestamp time.Time
+	valid     bool
+}
+
+// Averaging 表示BACnet平均值对象，在滑动窗口内统计被引用属性的最小值、最大值、平均值和方差
+type Averaging struct {
+	*BACnetObject
+	ObjectPropertyReference *DeviceObjectPropertyReference
+	WindowInterval          uint32 // 窗口时长（秒）
+	WindowSamples           uint32 // 窗口内采样数
+
+	samples    []averagingSample
+	attempted  uint32
+	lastSample time.Time
+}
+
+// NewAveraging 创建一个新的平均值对象
+func NewAveraging(instance uint32, name string, windowInterval, windowSamples uint32) *Averaging {
+	return &Averaging{
+		BACnetObject:   NewBACnetObject(ObjectTypeAveraging, instance, name),
+		WindowInterval: windowInterval,
+		WindowSamples:  windowSamples,
+		samples:        []averagingSample{},
+	}
+}
+
+// sampleInterval 返回采样间隔 Window_Interval / Window_Samples
+func (a *Averaging) sampleInterval() time.Duration {
+	if a.WindowSamples == 0 {
+		return 0
+	}
+	return time.Duration(a.WindowInterval) * time.Second / time.Duration(a.WindowSamples)
+}
+
+// Execute 按采样间隔读取被引用属性并加入窗口
+func (a *Averaging) Execute(device *Device, now time.Time) {
+	interval := a.sampleInterval()
+	if a.ObjectPropertyReference == nil || interval == 0 {
+		return
+	}
+	if !a.lastSample.IsZero() && now.Sub(a.lastSample) < interval {
+		return
+	}
+	a.lastSample = now
+
+	sample := averagingSample{timestamp: now}
+	if obj, err := device.ResolveReference(*a.ObjectPropertyReference); err == nil {
+		if value, err := ReadPropertyValue(obj, a.ObjectPropertyReference.PropertyIdentifier); err == nil {
+			sample.value, sample.valid = toFloat64(value)
+		}
+	}
+	a.addSample(sample)
+}
+
+// addSample 加入一个采样，超过Window_Samples时丢弃最旧的采样
+func (a *Averaging) addSample(sample averagingSample) {
+	a.samples = append(a.samples, sample)
+	if uint32(len(a.samples)) > a.WindowSamples {
+		a.samples = a.samples[uint32(len(a.samples))-a.WindowSamples:]
+	}
+	if a.attempted < a.WindowSamples {
+		a.attempted++
+	}
+}
+
+// Reset 清空采样窗口
+func (a *Averaging) Reset() {
+	a.samples = []averagingSample{}
+	a.attempted = 0
+	a.lastSample = time.Time{}
+}
+
+// statistics 计算窗口统计值；没有有效采样时最小值为+INF，最大值为-INF，平均值和方差为NaN
+func (a *Averaging) statistics() (minimum, maximum, average, variance float64, minTime, maxTime time.Time, valid uint32) {
+	minimum, maximum = math.Inf(1), math.Inf(-1)
+	var sum, sumSquares float64
+	for _, s := range a.samples {
+		if !s.valid {
+			continue
+		}
+		valid++
+		sum += s.value
+		sumSquares += s.value * s.value
+		if s.value < minimum {
+			minimum, minTime = s.value, s.timestamp
+		}
+		if s.value > maximum {
+			maximum, maxTime = s.value, s.timestamp
+		}
+	}
+	if valid == 0 {
+		return minimum, maximum, math.NaN(), math.NaN(), minTime, maxTime, 0
+	}
+	average = sum / float64(valid)
+	variance = sumSquares/float64(valid) - average*average
+	if variance < 0 {
+		variance = 0
+	}
+	return minimum, maximum, average, variance, minTime, maxTime, valid
+}
+
+// ReadProperty 读取平均值对象属性
+func (a *Averaging) ReadProperty(prop PropertyIdentifier) (interface{}, error) {
+	switch prop {
+	case PropertyIdentifierObjectPropertyReference:
+		if a.ObjectPropertyReference == nil {
+			return nil, nil
+		}
+		return *a.ObjectPropertyReference, nil
+	case PropertyIdentifierWindowInterval:
+		return a.WindowInterval, nil
+	case PropertyIdentifierWindowSamples:
+		return a.WindowSamples, nil
+	case PropertyIdentifierAttemptedSamples:
+		return a.attempted, nil
+	}
+
+	minimum, maximum, average, variance, minTime, maxTime, valid := a.statistics()
+	switch prop {
+	case PropertyIdentifierValidSamples:
+		return valid, nil
+	case PropertyIdentifierMinimumValue:
+		return float32(minimum), nil
+	case PropertyIdentifierMinimumValueTimestamp:
+		return DateTime{minTime}, nil
+	case PropertyIdentifierMaximumValue:
+		return float32(maximum), nil
+	case PropertyIdentifierMaximumValueTimestamp:
+		return DateTime{maxTime}, nil
+	case PropertyIdentifierAverageValue:
+		return float32(average), nil
+	case PropertyIdentifierVarianceValue:
+		return float32(variance), nil
+	}
+	return a.BACnetObject.ReadProperty(prop)
+}
+
+// WriteProperty 写入平均值对象属性，修改窗口参数或写Attempted_Samples为0时重新开始统计
+func (a *Averaging) WriteProperty(prop PropertyIdentifier, value interface{}) error {
+	switch prop {
+	case PropertyIdentifierAttemptedSamples:
+		if count, ok := toUint32(value); !ok || count != 0 {
+			return fmt.Errorf("Attempted_Samples只能写入0")
+		}
+		a.Reset()
+		return nil
+	case PropertyIdentifierWindowInterval, PropertyIdentifierWindowSamples:
+		number, ok := toUint32(value)
+		if !ok || number == 0 {
+			return fmt.Errorf("窗口参数必须为正整数")
+		}
+		if prop == PropertyIdentifierWindowInterval {
+			a.WindowInterval = number
+		} else {
+			a.WindowSamples = number
+		}
+		a.Reset()
+		return nil
+	case PropertyIdentifierObjectPropertyReference:
+		ref, ok := value.(DeviceObjectPropertyReference)
+		if !ok {
+			return fmt.Errorf("Object_Property_Reference类型无效")
+		}
+		a.ObjectPropertyReference = &ref
+		a.Reset()
+		return nil
+	case PropertyIdentifierValidSamples, PropertyIdentifierMinimumValue, PropertyIdentifierMinimumValueTimestamp,
+		PropertyIdentifierMaximumValue, PropertyIdentifierMaximumValueTimestamp,
+		PropertyIdentifierAverageValue, PropertyIdentifierVarianceValue:
+		return fmt.Errorf("只读属性")
+	}
+	return a.BACnetObject.WriteProperty(prop, value)
+}
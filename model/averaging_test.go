@@ -0,0 +1,86 @@
+package model
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAveragingWindow(t *testing.T) {
+	device := NewDevice(1, "Device", "")
+	sensor := NewAnalogValue(1, "Sensor", UnitsDegreesCelsius)
+	averaging := NewAveraging(1, "Average", 30, 3)
+	averaging.ObjectPropertyReference = &DeviceObjectPropertyReference{
+		ObjectIdentifier: sensor.GetObjectIdentifier(), PropertyIdentifier: PropertyIdentifierPresentValue,
+	}
+	device.AddObject(sensor)
+	device.AddObject(averaging)
+
+	start := time.Date(2024, 3, 1, 8, 0, 0, 0, time.Local)
+	sample := func(i int, value float32) {
+		sensor.WriteProperty(PropertyIdentifierPresentValue, value)
+		averaging.Execute(device, start.Add(time.Duration(i)*10*time.Second))
+	}
+	read := func(prop PropertyIdentifier) interface{} {
+		t.Helper()
+		value, err := averaging.ReadProperty(prop)
+		if err != nil {
+			t.Fatalf("ReadProperty(%d) = %v", prop, err)
+		}
+		return value
+	}
+
+	// 没有采样时最小值为+INF，最大值为-INF，平均值为NaN，时间戳未指定
+	if got := read(PropertyIdentifierMinimumValue); got != float32(math.Inf(1)) {
+		t.Errorf("empty Minimum_Value = %v", got)
+	}
+	if got := read(PropertyIdentifierAverageValue).(float32); !math.IsNaN(float64(got)) {
+		t.Errorf("empty Average_Value = %v", got)
+	}
+	if got := read(PropertyIdentifierMinimumValueTimestamp); got != (DateTime{}) {
+		t.Errorf("empty Minimum_Value_Timestamp = %v", got)
+	}
+
+	for i, value := range []float32{20, 26, 23} {
+		sample(i, value)
+	}
+	// 采样间隔内的重复执行不采样
+	sensor.WriteProperty(PropertyIdentifierPresentValue, float32(99))
+	averaging.Execute(device, start.Add(25*time.Second))
+
+	if got := read(PropertyIdentifierAverageValue); got != float32(23) {
+		t.Errorf("Average_Value = %v, want 23", got)
+	}
+	if got := read(PropertyIdentifierVarianceValue); got != float32(6) {
+		t.Errorf("Variance_Value = %v, want 6", got)
+	}
+	if got := read(PropertyIdentifierMinimumValueTimestamp); got != (DateTime{start}) {
+		t.Errorf("Minimum_Value_Timestamp = %v, want %v", got, start)
+	}
+	if got := read(PropertyIdentifierMaximumValueTimestamp); got != (DateTime{start.Add(10 * time.Second)}) {
+		t.Errorf("Maximum_Value_Timestamp = %v", got)
+	}
+
+	// 窗口满后丢弃最旧的采样，最小值和时间戳随之更新
+	sample(3, 24)
+	if got := read(PropertyIdentifierMinimumValue); got != float32(23) {
+		t.Errorf("Minimum_Value after slide = %v, want 23", got)
+	}
+	if got := read(PropertyIdentifierMinimumValueTimestamp); got != (DateTime{start.Add(20 * time.Second)}) {
+		t.Errorf("Minimum_Value_Timestamp after slide = %v", got)
+	}
+	if got := read(PropertyIdentifierAttemptedSamples); got != uint32(3) {
+		t.Errorf("Attempted_Samples = %v, want 3", got)
+	}
+
+	// 写Attempted_Samples为0重新开始统计，其他值被拒绝
+	if err := averaging.WriteProperty(PropertyIdentifierAttemptedSamples, uint32(1)); err == nil {
+		t.Error("WriteProperty(Attempted_Samples=1) succeeded")
+	}
+	if err := averaging.WriteProperty(PropertyIdentifierAttemptedSamples, uint32(0)); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(PropertyIdentifierValidSamples); got != uint32(0) {
+		t.Errorf("Valid_Samples after reset = %v, want 0", got)
+	}
+}
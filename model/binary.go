@@ -0,0 +1,267 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// Polarity 二进制对象极性（BACnetPolarity）
+type Polarity uint8
+
+const (
+	PolarityNormal  Polarity = iota
+	PolarityReverse          // 物理状态与逻辑状态相反
+)
+
+// Binary 表示BACnet二进制输入、输出和值对象，Present_Value为bool（true为ACTIVE）
+type Binary struct {
+	*BACnetObject
+	Polarity       Polarity
+	ActiveText     string
+	InactiveText   string
+	MinimumOnTime  uint32 // 最短开启时间（秒），0表示不限制
+	MinimumOffTime uint32 // 最短关闭时间（秒），0表示不限制
+
+	physical     bool      // 二进制输入的现场信号
+	lastChange   time.Time // Present_Value上次变化时间
+	minimumUntil time.Time // 最短开关时间占用优先级6的截止时间
+}
+
+// minimumTimePriority 最短开关时间占用的优先级
+const minimumTimePriority = 6
+
+// NewBinaryInput 创建二进制输入对象
+func NewBinaryInput(instance uint32, name string) *Binary {
+	return newBinary(ObjectTypeBinaryInput, instance, name)
+}
+
+// NewBinaryOutput 创建二进制输出对象
+func NewBinaryOutput(instance uint32, name string) *Binary {
+	return newBinary(ObjectTypeBinaryOutput, instance, name)
+}
+
+// NewBinaryValue 创建二进制值对象
+func NewBinaryValue(instance uint32, name string) *Binary {
+	return newBinary(ObjectTypeBinaryValue, instance, name)
+}
+
+// newBinary 创建二进制对象，初始为INACTIVE
+func newBinary(objType ObjectType, instance uint32, name string) *Binary {
+	binary := &Binary{
+		BACnetObject: NewBACnetObject(objType, instance, name),
+		ActiveText:   "Active",
+		InactiveText: "Inactive",
+	}
+	binary.Properties[PropertyIdentifierPresentValue] = false
+	if binary.Commandable(PropertyIdentifierPresentValue) {
+		binary.Properties[PropertyIdentifierRelinquishDefault] = false
+	}
+	return binary
+}
+
+// Active 返回当前逻辑状态
+func (b *Binary) Active() bool {
+	active, _ := b.Properties[PropertyIdentifierPresentValue].(bool)
+	return active
+}
+
+// StateText 返回当前状态的描述文字
+func (b *Binary) StateText() string {
+	if b.Active() {
+		return b.ActiveText
+	}
+	return b.InactiveText
+}
+
+// SetPhysicalInput 更新二进制输入的现场信号，按极性换算为Present_Value；对象停用时仅记录现场信号
+func (b *Binary) SetPhysicalInput(physical bool) {
+	b.physical = physical
+	if b.OutOfService() {
+		return
+	}
+	b.setActive(physical != (b.Polarity == PolarityReverse), b.now())
+}
+
+// UpdatePresentValue 由现场驱动或模拟数据更新Present_Value，二进制输入按现场信号处理
+func (b *Binary) UpdatePresentValue(value interface{}) error {
+	active, err := binaryPresentValue(value)
+	if err != nil {
+		return err
+	}
+	if b.GetObjectType() == ObjectTypeBinaryInput {
+		b.SetPhysicalInput(active != (b.Polarity == PolarityReverse))
+		return nil
+	}
+	if b.OutOfService() {
+		return nil
+	}
+	return b.WriteProperty(PropertyIdentifierPresentValue, active)
+}
+
+// binaryPresentValue 将bool或枚举值0/1转换为逻辑状态
+func binaryPresentValue(value interface{}) (bool, error) {
+	if active, ok := value.(bool); ok {
+		return active, nil
+	}
+	state, ok := toUint32(value)
+	if !ok {
+		return false, fmt.Errorf("Present_Value类型无效")
+	}
+	if state > 1 {
+		return false, ErrValueOutOfRange
+	}
+	return state == 1, nil
+}
+
+// PhysicalOutput 返回二进制输出按极性换算后的驱动信号
+func (b *Binary) PhysicalOutput() bool {
+	return b.Active() != (b.Polarity == PolarityReverse)
+}
+
+// Command 以优先级16命令新的逻辑状态
+func (b *Binary) Command(active bool) error {
+	return b.WritePropertyWithPriority(PropertyIdentifierPresentValue, active, 16)
+}
+
+// WritePropertyWithPriority 按优先级写入，nil表示释放该优先级；
+// 可命令对象状态变化后按最短开关时间占用优先级6，期间低优先级命令暂不生效
+func (b *Binary) WritePropertyWithPriority(prop PropertyIdentifier, value interface{}, priority uint8) error {
+	if prop != PropertyIdentifierPresentValue {
+		return b.BACnetObject.WritePropertyWithPriority(prop, value, priority)
+	}
+	if value != nil {
+		active, err := binaryPresentValue(value)
+		if err != nil {
+			return err
+		}
+		value = active
+	}
+	if !b.Commandable(prop) {
+		// 输入对象的Present_Value仅在停用时允许覆盖
+		if b.isInput() && !b.OutOfService() {
+			return ErrWriteAccessDenied
+		}
+		active, _ := value.(bool)
+		b.setActive(active, b.now())
+		return nil
+	}
+	wasActive := b.Active()
+	if err := b.BACnetObject.WritePropertyWithPriority(prop, value, priority); err != nil {
+		return err
+	}
+	if b.Active() != wasActive {
+		b.startMinimumTime(b.now())
+	} else if active, ok := value.(bool); ok && active != wasActive && !b.minimumUntil.IsZero() && priority > minimumTimePriority {
+		logger().Debug("最短开关时间未到，命令推迟执行", "object", b.Name, "state", b.StateText(), "delay", b.minimumUntil.Sub(b.now()))
+	}
+	return nil
+}
+
+// startMinimumTime 状态变化后记录变化时间，并在最短开关时间内以优先级6保持当前状态
+func (b *Binary) startMinimumTime(now time.Time) {
+	b.lastChange = now
+	minimum := b.MinimumOffTime
+	if b.Active() {
+		minimum = b.MinimumOnTime
+	}
+	if minimum == 0 {
+		b.minimumUntil = time.Time{}
+		return
+	}
+	b.minimumUntil = now.Add(time.Duration(minimum) * time.Second)
+	b.BACnetObject.WritePropertyWithPriority(PropertyIdentifierPresentValue, b.Active(), minimumTimePriority)
+}
+
+// setActive 更新非可命令对象的Present_Value并记录变化时间
+func (b *Binary) setActive(active bool, now time.Time) {
+	if b.Active() != active {
+		b.lastChange = now
+	}
+	b.BACnetObject.WriteProperty(PropertyIdentifierPresentValue, active)
+}
+
+// Execute 最短开关时间到期后释放优先级6，使低优先级命令生效
+func (b *Binary) Execute(device *Device, now time.Time) {
+	if b.minimumUntil.IsZero() || now.Before(b.minimumUntil) {
+		return
+	}
+	b.minimumUntil = time.Time{}
+	wasActive := b.Active()
+	b.BACnetObject.WritePropertyWithPriority(PropertyIdentifierPresentValue, nil, minimumTimePriority)
+	if b.Active() != wasActive {
+		b.startMinimumTime(now)
+	}
+}
+
+// ReadProperty 读取二进制对象属性
+func (b *Binary) ReadProperty(prop PropertyIdentifier) (interface{}, error) {
+	switch prop {
+	case PropertyIdentifierPolarity:
+		return b.Polarity, nil
+	case PropertyIdentifierActiveText:
+		return b.ActiveText, nil
+	case PropertyIdentifierInactiveText:
+		return b.InactiveText, nil
+	case PropertyIdentifierMinimumOnTime:
+		return b.MinimumOnTime, nil
+	case PropertyIdentifierMinimumOffTime:
+		return b.MinimumOffTime, nil
+	}
+	return b.BACnetObject.ReadProperty(prop)
+}
+
+// WriteProperty 写入二进制对象属性，Present_Value接受bool或枚举值0/1
+func (b *Binary) WriteProperty(prop PropertyIdentifier, value interface{}) error {
+	switch prop {
+	case PropertyIdentifierPresentValue:
+		return b.WritePropertyWithPriority(prop, value, 16)
+	case PropertyIdentifierRelinquishDefault:
+		active, err := binaryPresentValue(value)
+		if err != nil {
+			return err
+		}
+		return b.BACnetObject.WriteProperty(prop, active)
+	case PropertyIdentifierOutOfService:
+		if err := b.BACnetObject.WriteProperty(prop, value); err != nil {
+			return err
+		}
+		if b.isInput() && !b.OutOfService() {
+			// 恢复运行后Present_Value重新跟随现场信号
+			b.SetPhysicalInput(b.physical)
+		}
+		return nil
+	case PropertyIdentifierPolarity:
+		polarity, ok := toUint32(value)
+		if !ok || polarity > uint32(PolarityReverse) {
+			return ErrValueOutOfRange
+		}
+		b.Polarity = Polarity(polarity)
+		if b.GetObjectType() == ObjectTypeBinaryInput {
+			b.SetPhysicalInput(b.physical)
+		}
+		return nil
+	case PropertyIdentifierActiveText, PropertyIdentifierInactiveText:
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("状态文字类型无效")
+		}
+		if prop == PropertyIdentifierActiveText {
+			b.ActiveText = text
+		} else {
+			b.InactiveText = text
+		}
+		return nil
+	case PropertyIdentifierMinimumOnTime, PropertyIdentifierMinimumOffTime:
+		seconds, ok := toUint32(value)
+		if !ok {
+			return fmt.Errorf("最短开关时间类型无效")
+		}
+		if prop == PropertyIdentifierMinimumOnTime {
+			b.MinimumOnTime = seconds
+		} else {
+			b.MinimumOffTime = seconds
+		}
+		return nil
+	}
+	return b.BACnetObject.WriteProperty(prop, value)
+}
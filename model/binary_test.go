@@ -0,0 +1,120 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBinaryMinimumOnOffTime(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 3, 1, 8, 0, 0, 0, time.Local))
+	fan := NewBinaryOutput(1, "Fan")
+	fan.SetClock(clock)
+	fan.MinimumOnTime = 60
+	fan.MinimumOffTime = 30
+
+	if err := fan.Command(true); err != nil {
+		t.Fatal(err)
+	}
+	// 最短开启时间内低优先级的关闭命令暂不生效
+	fan.Command(false)
+	if !fan.Active() {
+		t.Fatal("fan switched off within Minimum_On_Time")
+	}
+	clock.Advance(59 * time.Second)
+	fan.Execute(nil, clock.Now())
+	if !fan.Active() {
+		t.Fatal("fan switched off before Minimum_On_Time elapsed")
+	}
+
+	// 到期后释放优先级6，关闭命令生效并开始最短关闭时间
+	clock.Advance(time.Second)
+	fan.Execute(nil, clock.Now())
+	if fan.Active() {
+		t.Fatal("fan still on after Minimum_On_Time")
+	}
+	fan.Command(true)
+	if fan.Active() {
+		t.Error("fan switched on within Minimum_Off_Time")
+	}
+
+	// 高于优先级6的命令立即生效
+	if err := fan.WritePropertyWithPriority(PropertyIdentifierPresentValue, true, 1); err != nil {
+		t.Fatal(err)
+	}
+	if !fan.Active() {
+		t.Error("priority 1 command did not override Minimum_Off_Time")
+	}
+}
+
+func TestBinaryPolarity(t *testing.T) {
+	input := NewBinaryInput(1, "Door")
+	input.SetPhysicalInput(true)
+	if !input.Active() {
+		t.Fatal("normal polarity input inactive with physical signal on")
+	}
+
+	// 写入命名类型的极性，反极性时现场信号取反
+	if err := input.WriteProperty(PropertyIdentifierPolarity, PolarityReverse); err != nil {
+		t.Fatal(err)
+	}
+	if input.Active() {
+		t.Error("reverse polarity input active with physical signal on")
+	}
+	if err := input.WriteProperty(PropertyIdentifierPolarity, uint32(2)); !errors.Is(err, ErrValueOutOfRange) {
+		t.Errorf("WriteProperty(Polarity=2) = %v, want ErrValueOutOfRange", err)
+	}
+
+	output := NewBinaryOutput(1, "Valve")
+	output.Polarity = PolarityReverse
+	output.Command(true)
+	if output.PhysicalOutput() {
+		t.Error("reverse polarity output drives signal when active")
+	}
+}
+
+func TestBinaryStateText(t *testing.T) {
+	pump := NewBinaryValue(1, "Pump")
+	pump.WriteProperty(PropertyIdentifierActiveText, "Running")
+	pump.WriteProperty(PropertyIdentifierInactiveText, "Stopped")
+
+	if got := pump.StateText(); got != "Stopped" {
+		t.Errorf("StateText() = %q, want Stopped", got)
+	}
+	// Present_Value接受bool或枚举值0/1
+	if err := pump.WriteProperty(PropertyIdentifierPresentValue, uint32(1)); err != nil {
+		t.Fatal(err)
+	}
+	if got := pump.StateText(); got != "Running" {
+		t.Errorf("StateText() = %q, want Running", got)
+	}
+	if err := pump.WriteProperty(PropertyIdentifierPresentValue, uint32(2)); !errors.Is(err, ErrValueOutOfRange) {
+		t.Errorf("WriteProperty(Present_Value=2) = %v, want ErrValueOutOfRange", err)
+	}
+	if err := pump.WriteProperty(PropertyIdentifierActiveText, 1); err == nil {
+		t.Error("WriteProperty(Active_Text=1) succeeded")
+	}
+}
+
+func TestBinaryInputOutOfService(t *testing.T) {
+	input := NewBinaryInput(1, "Door")
+	if err := input.WriteProperty(PropertyIdentifierPresentValue, true); !errors.Is(err, ErrWriteAccessDenied) {
+		t.Fatalf("WriteProperty(Present_Value) in service = %v, want ErrWriteAccessDenied", err)
+	}
+
+	// 停用时可以覆盖Present_Value，现场信号只被记录
+	input.WriteProperty(PropertyIdentifierOutOfService, true)
+	if err := input.WriteProperty(PropertyIdentifierPresentValue, uint32(1)); err != nil {
+		t.Fatal(err)
+	}
+	input.SetPhysicalInput(false)
+	if !input.Active() {
+		t.Error("physical input changed Present_Value while out of service")
+	}
+
+	// 恢复运行后跟随现场信号
+	input.WriteProperty(PropertyIdentifierOutOfService, false)
+	if input.Active() {
+		t.Error("Present_Value did not follow physical input after returning to service")
+	}
+}
@@ -0,0 +1,50 @@
+package model
+
+// BitString BACnet位串，下标0为第一个位，切片长度即位数
+type BitString []bool
+
+// NewBitString 创建指定位数、各位均为0的位串
+func NewBitString(length int) BitString {
+	return make(BitString, length)
+}
+
+// BitStringFromUint 由整数创建位串，第i位取value的第i个二进制位
+func BitStringFromUint(length int, value uint64) BitString {
+	bits := NewBitString(length)
+	for i := range bits {
+		bits[i] = i < 64 && value&(1<<i) != 0
+	}
+	return bits
+}
+
+// Len 返回位数
+func (b BitString) Len() int {
+	return len(b)
+}
+
+// Bit 返回第i位，超出位数时返回false
+func (b BitString) Bit(i int) bool {
+	return i >= 0 && i < len(b) && b[i]
+}
+
+// Set 设置第i位，超出位数时自动扩展
+func (b *BitString) Set(i int, v bool) {
+	if i < 0 {
+		return
+	}
+	for len(*b) <= i {
+		*b = append(*b, false)
+	}
+	(*b)[i] = v
+}
+
+// Uint 将前64位转换为整数，第i位对应第i个二进制位
+func (b BitString) Uint() uint64 {
+	var value uint64
+	for i, bit := range b {
+		if bit && i < 64 {
+			value |= 1 << i
+		}
+	}
+	return value
+}
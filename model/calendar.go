@@ -0,0 +1,166 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// 通配符取值（BACnetWeekNDay）
+const (
+	WeekNDayAny        uint8 = 0xFF
+	WeekNDayOddMonths  uint8 = 13 // 月份：奇数月
+	WeekNDayEvenMonths uint8 = 14 // 月份：偶数月
+	WeekNDayLastWeek   uint8 = 6  // 周次：当月最后7天
+)
+
+// DateRange 表示日期范围（含首尾两天），零值表示不限制
+type DateRange struct {
+	StartDate time.Time
+	EndDate   time.Time
+}
+
+// Contains 判断日期是否处于范围内
+func (r DateRange) Contains(t time.Time) bool {
+	day := dateOnly(t)
+	if !r.StartDate.IsZero() && day.Before(dateOnly(r.StartDate)) {
+		return false
+	}
+	if !r.EndDate.IsZero() && day.After(dateOnly(r.EndDate)) {
+		return false
+	}
+	return true
+}
+
+// WeekNDay 表示按月份、周次和星期匹配的日期
+type WeekNDay struct {
+	Month       uint8 // 1-12，13奇数月，14偶数月，0xFF任意
+	WeekOfMonth uint8 // 1-5对应第1-7、8-14...天，6为最后7天，0xFF任意
+	DayOfWeek   uint8 // 1=星期一...7=星期日，0xFF任意
+}
+
+// Matches 判断日期是否匹配
+func (w WeekNDay) Matches(t time.Time) bool {
+	month := uint8(t.Month())
+	switch w.Month {
+	case WeekNDayAny:
+	case WeekNDayOddMonths:
+		if month%2 == 0 {
+			return false
+		}
+	case WeekNDayEvenMonths:
+		if month%2 != 0 {
+			return false
+		}
+	default:
+		if w.Month != month {
+			return false
+		}
+	}
+
+	switch w.WeekOfMonth {
+	case WeekNDayAny:
+	case WeekNDayLastWeek:
+		lastDay := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+		if t.Day() <= lastDay-7 {
+			return false
+		}
+	default:
+		if uint8((t.Day()-1)/7+1) != w.WeekOfMonth {
+			return false
+		}
+	}
+
+	return w.DayOfWeek == WeekNDayAny || w.DayOfWeek == isoWeekday(t)
+}
+
+// CalendarEntry 日历条目（BACnetCalendarEntry），三个字段中只应设置一个
+type CalendarEntry struct {
+	Date      *Date // 可包含通配符，如每年1月1日
+	DateRange *DateRange
+	WeekNDay  *WeekNDay
+}
+
+// Matches 判断日期是否匹配日历条目
+func (e CalendarEntry) Matches(t time.Time) bool {
+	switch {
+	case e.Date != nil:
+		return e.Date.Matches(t)
+	case e.DateRange != nil:
+		return e.DateRange.Contains(t)
+	case e.WeekNDay != nil:
+		return e.WeekNDay.Matches(t)
+	}
+	return false
+}
+
+// Calendar 表示BACnet日历对象，Present_Value表示当天是否在Date_List中
+type Calendar struct {
+	*BACnetObject
+	DateList []CalendarEntry
+}
+
+// NewCalendar 创建一个新的日历对象
+func NewCalendar(instance uint32, name string) *Calendar {
+	calendar := &Calendar{
+		BACnetObject: NewBACnetObject(ObjectTypeCalendar, instance, name),
+		DateList:     []CalendarEntry{},
+	}
+	calendar.Properties[PropertyIdentifierPresentValue] = false
+	return calendar
+}
+
+// Evaluate 判断指定日期是否匹配Date_List中的任一条目
+func (c *Calendar) Evaluate(t time.Time) bool {
+	for _, entry := range c.DateList {
+		if entry.Matches(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Execute 周期性更新Present_Value，值变化时通知COV订阅者
+func (c *Calendar) Execute(device *Device, now time.Time) {
+	c.BACnetObject.WriteProperty(PropertyIdentifierPresentValue, c.Evaluate(now))
+}
+
+// ReadProperty 读取日历属性
+func (c *Calendar) ReadProperty(prop PropertyIdentifier) (interface{}, error) {
+	switch prop {
+	case PropertyIdentifierPresentValue:
+		return c.Evaluate(c.now()), nil
+	case PropertyIdentifierDateList:
+		return append([]CalendarEntry{}, c.DateList...), nil
+	}
+	return c.BACnetObject.ReadProperty(prop)
+}
+
+// WriteProperty 写入日历属性，Present_Value只读
+func (c *Calendar) WriteProperty(prop PropertyIdentifier, value interface{}) error {
+	switch prop {
+	case PropertyIdentifierPresentValue:
+		return fmt.Errorf("日历的Present_Value为只读属性")
+	case PropertyIdentifierDateList:
+		entries, ok := value.([]CalendarEntry)
+		if !ok {
+			return fmt.Errorf("Date_List类型无效")
+		}
+		c.DateList = append([]CalendarEntry{}, entries...)
+		c.BACnetObject.WriteProperty(PropertyIdentifierPresentValue, c.Evaluate(c.now()))
+		return nil
+	}
+	return c.BACnetObject.WriteProperty(prop, value)
+}
+
+// dateOnly 去掉时间部分
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// isoWeekday 返回BACnet星期编号：1=星期一...7=星期日
+func isoWeekday(t time.Time) uint8 {
+	if t.Weekday() == time.Sunday {
+		return 7
+	}
+	return uint8(t.Weekday())
+}
@@ -0,0 +1,144 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCalendarEntryMatches(t *testing.T) {
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 12, 0, 0, 0, time.Local)
+	}
+	christmas := Date{Year: DateTimeAny, Month: 12, Day: 25, Weekday: DateTimeAny}
+	lastDay := Date{Year: DateTimeAny, Month: DateTimeAny, Day: DateLastDay, Weekday: DateTimeAny}
+	holidays := DateRange{StartDate: day(2024, 7, 1), EndDate: day(2024, 8, 31)}
+	thanksgiving := WeekNDay{Month: 11, WeekOfMonth: 4, DayOfWeek: 4}
+	lastSunday := WeekNDay{Month: WeekNDayAny, WeekOfMonth: WeekNDayLastWeek, DayOfWeek: 7}
+
+	tests := []struct {
+		name  string
+		entry CalendarEntry
+		date  time.Time
+		want  bool
+	}{
+		{"wildcard year", CalendarEntry{Date: &christmas}, day(2031, 12, 25), true},
+		{"wildcard year other day", CalendarEntry{Date: &christmas}, day(2031, 12, 26), false},
+		{"last day of leap February", CalendarEntry{Date: &lastDay}, day(2024, 2, 29), true},
+		{"not last day", CalendarEntry{Date: &lastDay}, day(2024, 2, 28), false},
+		{"range first day", CalendarEntry{DateRange: &holidays}, day(2024, 7, 1), true},
+		{"range last day", CalendarEntry{DateRange: &holidays}, day(2024, 8, 31), true},
+		{"after range", CalendarEntry{DateRange: &holidays}, day(2024, 9, 1), false},
+		{"fourth Thursday", CalendarEntry{WeekNDay: &thanksgiving}, day(2024, 11, 28), true},
+		{"third Thursday", CalendarEntry{WeekNDay: &thanksgiving}, day(2024, 11, 21), false},
+		{"last Sunday", CalendarEntry{WeekNDay: &lastSunday}, day(2024, 3, 31), true},
+		{"earlier Sunday", CalendarEntry{WeekNDay: &lastSunday}, day(2024, 3, 24), false},
+		{"empty entry", CalendarEntry{}, day(2024, 1, 1), false},
+	}
+	for _, tt := range tests {
+		if got := tt.entry.Matches(tt.date); got != tt.want {
+			t.Errorf("%s: Matches(%s) = %v, want %v", tt.name, tt.date.Format("2006-01-02"), got, tt.want)
+		}
+	}
+}
+
+func TestCalendarDateList(t *testing.T) {
+	calendar := NewCalendar(1, "Holidays")
+	newYear := Date{Year: DateTimeAny, Month: 1, Day: 1, Weekday: DateTimeAny}
+	weekend := WeekNDay{Month: WeekNDayAny, WeekOfMonth: WeekNDayAny, DayOfWeek: 6}
+	if err := calendar.WriteProperty(PropertyIdentifierDateList, []CalendarEntry{{Date: &newYear}, {WeekNDay: &weekend}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		date time.Time
+		want bool
+	}{
+		{time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local), true},  // 元旦（星期三）
+		{time.Date(2025, 1, 4, 0, 0, 0, 0, time.Local), true},  // 星期六
+		{time.Date(2025, 1, 6, 0, 0, 0, 0, time.Local), false}, // 星期一
+	} {
+		if got := calendar.Evaluate(tt.date); got != tt.want {
+			t.Errorf("Evaluate(%s) = %v, want %v", tt.date.Format("2006-01-02"), got, tt.want)
+		}
+	}
+
+	if err := calendar.WriteProperty(PropertyIdentifierPresentValue, true); err == nil {
+		t.Error("WriteProperty(Present_Value) succeeded")
+	}
+	if err := calendar.WriteProperty(PropertyIdentifierDateList, "2025-01-01"); err == nil {
+		t.Error("WriteProperty(Date_List) with invalid type succeeded")
+	}
+}
+
+func TestScheduleExceptionSchedule(t *testing.T) {
+	device := NewDevice(1, "Device", "")
+	holidays := NewCalendar(1, "Holidays")
+	christmas := Date{Year: DateTimeAny, Month: 12, Day: 25, Weekday: DateTimeAny}
+	holidays.DateList = []CalendarEntry{{Date: &christmas}}
+	device.AddObject(holidays)
+
+	schedule := NewSchedule(1, "Occupancy", float32(16))
+	for day := 0; day < 5; day++ {
+		schedule.WeeklySchedule[day] = []TimeValue{{Time: 8 * time.Hour, Value: float32(21)}, {Time: 18 * time.Hour}}
+	}
+	calendarRef := holidays.GetObjectIdentifier()
+	christmasEve := Date{Year: DateTimeAny, Month: 12, Day: 24, Weekday: DateTimeAny}
+	schedule.ExceptionSchedule = []SpecialEvent{
+		// 引用日历：圣诞节全天保持低温
+		{CalendarReference: &calendarRef, TimeValues: []TimeValue{{Time: 0, Value: float32(12)}}, Priority: 10},
+		// 平安夜下午提前结束，优先级高于下一条
+		{Calendar: &CalendarEntry{Date: &christmasEve}, TimeValues: []TimeValue{{Time: 14 * time.Hour, Value: float32(17)}}, Priority: 5},
+		{Calendar: &CalendarEntry{Date: &christmasEve}, TimeValues: []TimeValue{{Time: 0, Value: float32(19)}}, Priority: 8},
+	}
+	device.AddObject(schedule)
+
+	at := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2024, month, day, hour, 0, 0, 0, time.Local)
+	}
+	tests := []struct {
+		name string
+		now  time.Time
+		want interface{}
+	}{
+		{"weekday occupied", at(12, 23, 9), float32(21)},
+		{"weekday released", at(12, 23, 19), float32(16)},
+		{"weekday before first switch", at(12, 23, 7), float32(16)},
+		{"calendar reference", at(12, 25, 9), float32(12)},
+		{"lower priority event before higher starts", at(12, 24, 9), float32(19)},
+		{"higher priority event", at(12, 24, 15), float32(17)},
+		{"weekend", at(12, 28, 9), float32(16)},
+	}
+	for _, tt := range tests {
+		if got := schedule.Evaluate(device, tt.now); got != tt.want {
+			t.Errorf("%s: Evaluate() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Effective_Period之外使用Schedule_Default
+	schedule.EffectivePeriod = DateRange{StartDate: at(1, 1, 0), EndDate: at(12, 24, 0)}
+	if got := schedule.Evaluate(device, at(12, 25, 9)); got != float32(16) {
+		t.Errorf("outside Effective_Period = %v, want 16", got)
+	}
+}
+
+func TestScheduleWritesReferences(t *testing.T) {
+	device := NewDevice(1, "Device", "")
+	setpoint := NewAnalogValue(1, "Setpoint", UnitsDegreesCelsius)
+	schedule := NewSchedule(1, "Occupancy", float32(16))
+	for day := range schedule.WeeklySchedule {
+		schedule.WeeklySchedule[day] = []TimeValue{{Time: 8 * time.Hour, Value: float32(21)}}
+	}
+	schedule.References = []DeviceObjectPropertyReference{{
+		ObjectIdentifier: setpoint.GetObjectIdentifier(), PropertyIdentifier: PropertyIdentifierPresentValue,
+	}}
+	device.AddObject(setpoint)
+	device.AddObject(schedule)
+
+	schedule.Execute(device, time.Date(2024, 3, 1, 9, 0, 0, 0, time.Local))
+	if got, _ := setpoint.ReadProperty(PropertyIdentifierPresentValue); got != float32(21) {
+		t.Errorf("referenced Present_Value = %v, want 21", got)
+	}
+	if got := setpoint.PriorityArray(PropertyIdentifierPresentValue)[schedule.PriorityForWriting-1]; got != float32(21) {
+		t.Errorf("priority %d slot = %v, want 21", schedule.PriorityForWriting, got)
+	}
+}
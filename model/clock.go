@@ -0,0 +1,134 @@
+package model
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock 时间来源。对象取自所属设备的时钟（Device.SetClock），服务端、网关等组件取自各自配置的时钟，
+// 测试中可以替换为FakeClock快进订阅有效期、时间表和趋势记录间隔
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker 时钟创建的周期定时器
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// SystemClock 使用系统时间的时钟
+var SystemClock Clock = systemClock{}
+
+// systemClock 以time包实现的时钟
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) NewTicker(d time.Duration) Ticker { return systemTicker{time.NewTicker(d)} }
+
+// systemTicker 包装time.Ticker
+type systemTicker struct{ *time.Ticker }
+
+func (t systemTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// FakeClock 只在调用Advance时前进的时钟，用于测试
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+// fakeTicker FakeClock创建的定时器
+type fakeTicker struct {
+	clock  *FakeClock
+	period time.Duration
+	next   time.Time
+	c      chan time.Time
+	stop   chan struct{}
+	once   sync.Once
+}
+
+// NewFakeClock 创建从start开始的时钟
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// Now 返回时钟的当前时间
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTicker 创建每隔d触发一次的定时器，只在Advance经过触发时间时触发
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("model: FakeClock.NewTicker的周期必须为正数")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTicker{clock: c, period: d, next: c.now.Add(d), c: make(chan time.Time), stop: make(chan struct{})}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Advance 将时钟前进d，按时间顺序逐个触发经过的定时器。每次触发都等到接收方取走，
+// 因此返回时除最后一次触发外，接收方都已处理完毕
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	target := c.now.Add(d)
+	c.mu.Unlock()
+	for {
+		c.mu.Lock()
+		var due *fakeTicker
+		for _, t := range c.tickers {
+			if !t.next.After(target) && (due == nil || t.next.Before(due.next)) {
+				due = t
+			}
+		}
+		if due == nil {
+			c.now = target
+			c.mu.Unlock()
+			return
+		}
+		c.now = due.next
+		due.next = due.next.Add(due.period)
+		now := c.now
+		c.mu.Unlock()
+
+		select {
+		case due.c <- now:
+		case <-due.stop:
+		}
+	}
+}
+
+// Set 将时钟设置为t，不触发定时器，用于跳过一段时间（如修改系统时间）
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+	for _, ticker := range c.tickers {
+		ticker.next = t.Add(ticker.period)
+	}
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.c }
+
+// Stop 停止定时器，之后Advance不再触发它
+func (t *fakeTicker) Stop() {
+	t.once.Do(func() {
+		close(t.stop)
+		c := t.clock
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for i, other := range c.tickers {
+			if other == t {
+				c.tickers = append(c.tickers[:i], c.tickers[i+1:]...)
+				break
+			}
+		}
+	})
+}
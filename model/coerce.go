@@ -0,0 +1,120 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// CoerceValue 将从JSON或YAML等文本格式解码得到的值（float64、string、bool或列表）转换为属性使用的Go类型：
+// 优先按属性元数据的数据类型，其次按属性当前值的类型
+func CoerceValue(obj Object, prop PropertyIdentifier, value interface{}) (interface{}, error) {
+	datatype := DatatypeAny
+	if meta, ok := LookupPropertyMetadata(obj.GetObjectIdentifier().Type, prop); ok {
+		datatype = meta.Datatype
+	}
+	current, _ := obj.ReadProperty(prop)
+	if datatype == DatatypeEnumerated {
+		if _, isBool := current.(bool); isBool {
+			datatype = DatatypeBoolean
+		}
+	}
+	if datatype == DatatypeAny && current != nil {
+		datatype = datatypeOf(current)
+	}
+
+	switch datatype {
+	case DatatypeBoolean:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			return strconv.ParseBool(v)
+		case float64:
+			return v != 0, nil
+		}
+	case DatatypeReal:
+		if number, ok := value.(float64); ok {
+			return float32(number), nil
+		}
+	case DatatypeDouble:
+		if number, ok := value.(float64); ok {
+			return number, nil
+		}
+	case DatatypeUnsigned, DatatypeEnumerated:
+		number, ok := value.(float64)
+		if !ok || number < 0 || number > math.MaxUint32 || number != math.Trunc(number) {
+			break
+		}
+		// 沿用当前值的Go类型（如枚举类型）
+		if current != nil {
+			v := reflect.ValueOf(current)
+			if kind := v.Kind(); kind >= reflect.Uint && kind <= reflect.Uint64 {
+				return reflect.ValueOf(uint64(number)).Convert(v.Type()).Interface(), nil
+			}
+		}
+		return uint32(number), nil
+	case DatatypeSigned:
+		if number, ok := value.(float64); ok && number >= math.MinInt32 && number <= math.MaxInt32 && number == math.Trunc(number) {
+			return int32(number), nil
+		}
+	case DatatypeCharacterString:
+		if text, ok := value.(string); ok {
+			return text, nil
+		}
+	case DatatypeDateTime:
+		// RFC 3339格式的时间，空字符串表示未指定
+		if text, ok := value.(string); ok {
+			if text == "" {
+				return DateTime{}, nil
+			}
+			if t, err := time.Parse(time.RFC3339, text); err == nil {
+				return DateTime{t}, nil
+			}
+		}
+	default:
+		switch v := value.(type) {
+		case string, bool:
+			return v, nil
+		case float64:
+			// 类型未知时非负整数按Unsigned保存
+			if v >= 0 && v <= math.MaxUint32 && v == math.Trunc(v) {
+				return uint32(v), nil
+			}
+			return float32(v), nil
+		case []interface{}:
+			// 列表按字符串数组保存（如State_Text）
+			texts := make([]string, len(v))
+			for i, item := range v {
+				text, ok := item.(string)
+				if !ok {
+					return nil, fmt.Errorf("只支持字符串列表: %v", value)
+				}
+				texts[i] = text
+			}
+			return texts, nil
+		}
+	}
+	return nil, fmt.Errorf("值%v不能用于该属性", value)
+}
+
+// datatypeOf 按Go类型推断属性的数据类型
+func datatypeOf(value interface{}) Datatype {
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Bool:
+		return DatatypeBoolean
+	case reflect.Float32:
+		return DatatypeReal
+	case reflect.Float64:
+		return DatatypeDouble
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return DatatypeUnsigned
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return DatatypeSigned
+	case reflect.String:
+		return DatatypeCharacterString
+	}
+	return DatatypeAny
+}
@@ -0,0 +1,157 @@
+package model
+
+import "time"
+
+// 日期和时间的通配符取值（ASHRAE 135 第20.2.12、20.2.13节）
+const (
+	DateTimeAny   uint8 = 0xFF // 任意年/月/日/星期/时/分/秒/百分秒
+	DateOddMonth  uint8 = 13   // 月份：奇数月
+	DateEvenMonth uint8 = 14   // 月份：偶数月
+	DateLastDay   uint8 = 32   // 日：当月最后一天
+	DateOddDay    uint8 = 33   // 日：奇数日
+	DateEvenDay   uint8 = 34   // 日：偶数日
+)
+
+// Date BACnet日期，各字段为0xFF时表示通配（任意）
+type Date struct {
+	Year    uint8 // 年份-1900
+	Month   uint8 // 1-12，13奇数月，14偶数月
+	Day     uint8 // 1-31，32最后一天，33奇数日，34偶数日
+	Weekday uint8 // 1=周一 ... 7=周日
+}
+
+// Time BACnet时间，各字段为0xFF时表示通配（任意）
+type Time struct {
+	Hour       uint8
+	Minute     uint8
+	Second     uint8
+	Hundredths uint8 // 百分之一秒
+}
+
+// NewDate 由time.Time生成不含通配符的日期
+func NewDate(t time.Time) Date {
+	return Date{Year: uint8(t.Year() - 1900), Month: uint8(t.Month()), Day: uint8(t.Day()), Weekday: isoWeekday(t)}
+}
+
+// NewTime 由time.Time生成不含通配符的时间
+func NewTime(t time.Time) Time {
+	return Time{Hour: uint8(t.Hour()), Minute: uint8(t.Minute()), Second: uint8(t.Second()), Hundredths: uint8(t.Nanosecond() / 10000000)}
+}
+
+// TimeOfDay 由距当天零点的时间生成BACnet时间
+func TimeOfDay(d time.Duration) Time {
+	return Time{
+		Hour:       uint8(d / time.Hour),
+		Minute:     uint8(d % time.Hour / time.Minute),
+		Second:     uint8(d % time.Minute / time.Second),
+		Hundredths: uint8(d % time.Second / (10 * time.Millisecond)),
+	}
+}
+
+// IsSpecific 判断日期是否为不含通配符的具体日期（星期可以通配）
+func (d Date) IsSpecific() bool {
+	return d.Year != DateTimeAny && d.Month >= 1 && d.Month <= 12 && d.Day >= 1 && d.Day <= 31
+}
+
+// Matches 判断日期是否匹配，通配字段匹配任意值
+func (d Date) Matches(t time.Time) bool {
+	if d.Year != DateTimeAny && int(d.Year)+1900 != t.Year() {
+		return false
+	}
+
+	month := uint8(t.Month())
+	switch d.Month {
+	case DateTimeAny:
+	case DateOddMonth:
+		if month%2 == 0 {
+			return false
+		}
+	case DateEvenMonth:
+		if month%2 != 0 {
+			return false
+		}
+	default:
+		if d.Month != month {
+			return false
+		}
+	}
+
+	day := uint8(t.Day())
+	switch d.Day {
+	case DateTimeAny:
+	case DateLastDay:
+		if t.AddDate(0, 0, 1).Month() == t.Month() {
+			return false
+		}
+	case DateOddDay:
+		if day%2 == 0 {
+			return false
+		}
+	case DateEvenDay:
+		if day%2 != 0 {
+			return false
+		}
+	default:
+		if d.Day != day {
+			return false
+		}
+	}
+
+	return d.Weekday == DateTimeAny || d.Weekday == isoWeekday(t)
+}
+
+// Time 转换为本地零点的time.Time，日期含通配符时返回零值
+func (d Date) Time() time.Time {
+	if !d.IsSpecific() {
+		return time.Time{}
+	}
+	return time.Date(int(d.Year)+1900, time.Month(d.Month), int(d.Day), 0, 0, 0, 0, time.Local)
+}
+
+// IsSpecific 判断时间是否不含通配符
+func (t Time) IsSpecific() bool {
+	return t.Hour != DateTimeAny && t.Minute != DateTimeAny && t.Second != DateTimeAny && t.Hundredths != DateTimeAny
+}
+
+// Matches 判断时刻是否匹配，通配字段匹配任意值
+func (t Time) Matches(at time.Time) bool {
+	return (t.Hour == DateTimeAny || int(t.Hour) == at.Hour()) &&
+		(t.Minute == DateTimeAny || int(t.Minute) == at.Minute()) &&
+		(t.Second == DateTimeAny || int(t.Second) == at.Second()) &&
+		(t.Hundredths == DateTimeAny || int(t.Hundredths) == at.Nanosecond()/10000000)
+}
+
+// Duration 返回距当天零点的时间，通配字段按零处理
+func (t Time) Duration() time.Duration {
+	wild := func(b uint8) time.Duration {
+		if b == DateTimeAny {
+			return 0
+		}
+		return time.Duration(b)
+	}
+	return wild(t.Hour)*time.Hour + wild(t.Minute)*time.Minute + wild(t.Second)*time.Second + wild(t.Hundredths)*10*time.Millisecond
+}
+
+// TimeStamp 时间戳（BACnetTimeStamp），三个选项中只应设置一个
+type TimeStamp struct {
+	Time           *Time
+	SequenceNumber *uint32
+	DateTime       *time.Time // 零值时间表示未指定
+}
+
+// DateTime 按BACnetDateTime（Date后接Time）编码的时间，用于标准规定为BACnetDateTime而非BACnetTimeStamp的属性，
+// 零值时间表示未指定
+type DateTime struct {
+	time.Time
+}
+
+// toDateTime 转换写入BACnetDateTime属性的值，接受DateTime和time.Time
+func toDateTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case DateTime:
+		return v.Time, true
+	case time.Time:
+		return v, true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,6 @@
+// Package model 定义BACnet对象模型：对象和属性标识符、数据类型，以及设备和各类标准对象。
+//
+// 对象由NewAnalogInput、NewBinaryOutput、NewSchedule等构造函数创建并加入model.Device，
+// 服务端读写属性时经Object接口访问。可命令对象按优先级数组处理写入，
+// 支持COV和事件的对象经NotificationSender发送通知
+package model
@@ -0,0 +1,102 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+// EventLog 表示BACnet事件日志对象，记录设备产生的事件通知
+type EventLog struct {
+	*BACnetObject
+	Log *LogBuffer
+}
+
+// NewEventLog 创建一个新的事件日志对象
+func NewEventLog(instance uint32, name string, bufferSize uint32) *EventLog {
+	eventLog := &EventLog{
+		BACnetObject: NewBACnetObject(ObjectTypeEventLog, instance, name),
+		Log:          NewLogBuffer(bufferSize),
+	}
+	eventLog.Properties[PropertyIdentifierLogEnable] = true
+	return eventLog
+}
+
+// Buffer 返回日志缓冲区
+func (e *EventLog) Buffer() *LogBuffer {
+	return e.Log
+}
+
+// Enabled 判断日志是否启用
+func (e *EventLog) Enabled() bool {
+	enabled, _ := e.Properties[PropertyIdentifierLogEnable].(bool)
+	return enabled
+}
+
+// LogNotification 记录一条事件通知，缓冲区满且为停止模式时禁用日志
+func (e *EventLog) LogNotification(timestamp time.Time, notification EventNotification) {
+	if !e.Enabled() {
+		return
+	}
+	e.Log.Append(timestamp, notification)
+	if e.Log.StopWhenFull && e.Log.Full() {
+		e.Properties[PropertyIdentifierLogEnable] = false
+		logger().Warn("事件日志缓冲区已满，停止记录", "object", e.Name)
+	}
+}
+
+// ReadProperty 读取事件日志属性，缓冲区相关属性实时计算
+func (e *EventLog) ReadProperty(prop PropertyIdentifier) (interface{}, error) {
+	if value, ok := e.Log.readBufferProperty(prop); ok {
+		return value, nil
+	}
+	return e.BACnetObject.ReadProperty(prop)
+}
+
+// WriteProperty 写入事件日志属性
+func (e *EventLog) WriteProperty(prop PropertyIdentifier, value interface{}) error {
+	if handled, err := e.Log.writeBufferProperty(prop, value, e.now()); handled {
+		return err
+	}
+	if prop == PropertyIdentifierLogEnable {
+		enable, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("Log_Enable类型无效")
+		}
+		setLogEnable(e.BACnetObject, e.Log, enable)
+		return nil
+	}
+	return e.BACnetObject.WriteProperty(prop, value)
+}
+
+// setLogEnable 启用或禁用日志，状态变化时写入日志状态记录
+func setLogEnable(o *BACnetObject, log *LogBuffer, enable bool) {
+	enabled, _ := o.Properties[PropertyIdentifierLogEnable].(bool)
+	if enable == enabled {
+		return
+	}
+	if enable {
+		o.Properties[PropertyIdentifierLogEnable] = true
+		log.Append(o.now(), LogStatus(0))
+	} else {
+		log.Append(o.now(), LogStatusLogDisabled)
+		o.Properties[PropertyIdentifierLogEnable] = false
+	}
+}
+
+// toUint32 将整数转换为uint32，包括以整数为底层类型的命名类型（如WriteProperty按当前值类型转换的枚举值）
+func toUint32(value interface{}) (uint32, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n := v.Uint(); n <= math.MaxUint32 {
+			return uint32(n), true
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n := v.Int(); n >= 0 && n <= math.MaxUint32 {
+			return uint32(n), true
+		}
+	}
+	return 0, false
+}
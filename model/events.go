@@ -0,0 +1,148 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+// EventType 表示BACnet事件类型（BACnetEventType）
+type EventType uint32
+
+const (
+	EventTypeChangeOfBitstring  EventType = 0
+	EventTypeChangeOfState      EventType = 1
+	EventTypeChangeOfValue      EventType = 2
+	EventTypeCommandFailure     EventType = 3
+	EventTypeFloatingLimit      EventType = 4
+	EventTypeOutOfRange         EventType = 5
+	EventTypeChangeOfLifeSafety EventType = 8
+	EventTypeBufferReady        EventType = 10
+)
+
+// Acked_Transitions 和 Event_Time_Stamps 的转换索引
+const (
+	TransitionIndexToOffNormal = 0
+	TransitionIndexToFault     = 1
+	TransitionIndexToNormal    = 2
+)
+
+// AckedTransitionsAll 表示三种转换均已确认
+const AckedTransitionsAll uint8 = 1<<TransitionIndexToOffNormal | 1<<TransitionIndexToFault | 1<<TransitionIndexToNormal
+
+// ErrInvalidTimeStamp 确认的时间戳与对象记录的转换时间戳不一致
+var ErrInvalidTimeStamp = errors.New("invalid time stamp")
+
+// EventNotification 表示一条待发送的事件通知
+type EventNotification struct {
+	ProcessID         uint32
+	EventObject       ObjectIdentifier
+	TimeStamp         time.Time
+	NotificationClass uint32
+	Priority          uint8
+	EventType         EventType
+	MessageText       string
+	NotifyType        NotifyType
+	AckRequired       bool
+	FromState         EventState
+	ToState           EventState
+	EventValues       interface{} // 可选：事件参数，如BufferReadyEventValues
+}
+
+// BufferReadyEventValues buffer-ready事件参数
+type BufferReadyEventValues struct {
+	BufferProperty       DeviceObjectPropertyReference
+	PreviousNotification uint32
+	CurrentNotification  uint32
+}
+
+// OutOfRangeEventValues out-of-range事件参数
+type OutOfRangeEventValues struct {
+	ExceedingValue float32
+	StatusFlags    uint8 // 转换后的状态标志
+	Deadband       float32
+	ExceededLimit  float32
+}
+
+// TransitionForState 返回转换到指定事件状态所对应的转换索引
+func TransitionForState(state EventState) int {
+	switch state {
+	case EventStateNormal:
+		return TransitionIndexToNormal
+	case EventStateFault:
+		return TransitionIndexToFault
+	default:
+		return TransitionIndexToOffNormal
+	}
+}
+
+// GetAckedTransitions 获取Acked_Transitions位（bit0=to-offnormal, bit1=to-fault, bit2=to-normal）
+func (o *BACnetObject) GetAckedTransitions() uint8 {
+	if v, exists := o.Properties[PropertyIdentifierAckedTransitions]; exists {
+		if bits, ok := v.(uint8); ok {
+			return bits & AckedTransitionsAll
+		}
+	}
+	return AckedTransitionsAll
+}
+
+// GetEventTimeStamps 获取Event_Time_Stamps数组（to-offnormal, to-fault, to-normal），零值表示未发生
+func (o *BACnetObject) GetEventTimeStamps() []time.Time {
+	timeStamps := make([]time.Time, 3)
+	if v, exists := o.Properties[PropertyIdentifierEventTimeStamps]; exists {
+		if ts, ok := v.([]time.Time); ok {
+			copy(timeStamps, ts)
+		}
+	}
+	return timeStamps
+}
+
+// AcknowledgeAlarm 确认指定事件状态的转换，并发送确认通知
+// timeStamp必须与Event_Time_Stamps中记录的该转换时间一致（精确到百分之一秒）
+func (o *BACnetObject) AcknowledgeAlarm(state EventState, timeStamp time.Time) error {
+	transition := TransitionForState(state)
+	recorded := o.GetEventTimeStamps()[transition]
+	if recorded.IsZero() || !sameHundredths(recorded, timeStamp) {
+		return ErrInvalidTimeStamp
+	}
+
+	o.Properties[PropertyIdentifierAckedTransitions] = o.GetAckedTransitions() | 1<<transition
+	logger().Debug("告警已确认", "object", o.Name, "transition", transition, "time", recorded)
+
+	o.sendEventNotification(EventNotification{
+		EventObject:       o.Identifier,
+		TimeStamp:         recorded,
+		NotificationClass: o.GetNotificationClass(),
+		EventType:         eventTypeForObject(o.GetObjectType()),
+		NotifyType:        NotifyTypeAckNotification,
+		ToState:           state,
+	})
+	return nil
+}
+
+// sendEventNotification 通过通知发送器发送事件通知
+func (o *BACnetObject) sendEventNotification(notification EventNotification) {
+	if o.Notifier == nil {
+		logger().Debug("未设置通知发送器，事件通知未发送", "object", o.Name, "notify_type", notification.NotifyType, "to_state", notification.ToState)
+		return
+	}
+	if err := o.Notifier.SendEventNotification(notification); err != nil {
+		logger().Warn("发送事件通知失败", "object", o.Name, "error", err)
+	}
+}
+
+// eventTypeForObject 根据对象类型返回其内部告警使用的事件类型
+func eventTypeForObject(objType ObjectType) EventType {
+	switch objType {
+	case ObjectTypeAnalogInput, ObjectTypeAnalogOutput, ObjectTypeAnalogValue:
+		return EventTypeOutOfRange
+	case ObjectTypeLifeSafetyPoint, ObjectTypeLifeSafetyZone:
+		return EventTypeChangeOfLifeSafety
+	default:
+		return EventTypeChangeOfState
+	}
+}
+
+// sameHundredths 比较两个时间是否在百分之一秒精度内相等
+func sameHundredths(a, b time.Time) bool {
+	return a.Truncate(10 * time.Millisecond).Equal(b.Truncate(10 * time.Millisecond))
+}
@@ -0,0 +1,182 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// PropertyAccessResult 全局组成员的读取结果（BACnetPropertyAccessResult），Err非空表示读取失败
+type PropertyAccessResult struct {
+	Reference DeviceObjectPropertyReference
+	Value     interface{}
+	Err       error
+}
+
+// GlobalGroup 表示BACnet全局组对象，汇总一组属性引用的当前值和状态标志。
+// 成员只能引用本设备的对象，加入设备后写入引用其他设备的Group_Members返回ErrRemoteReference
+type GlobalGroup struct {
+	*BACnetObject
+	GroupMembers            []DeviceObjectPropertyReference
+	GroupMemberNames        []string
+	RequestedUpdateInterval uint32 // 轮询刷新间隔（秒），0表示仅靠成员变化刷新
+	COVUPeriod              uint32 // 大于0时Present_Value变化会通知COV订阅者
+
+	lastUpdate         time.Time
+	observed           map[ObjectIdentifier]bool                 // 已注册观察者的本地成员
+	referenceValidator func(DeviceObjectPropertyReference) error // 写入成员前的检查，由设备设置
+}
+
+// NewGlobalGroup 创建一个新的全局组对象
+func NewGlobalGroup(instance uint32, name string) *GlobalGroup {
+	group := &GlobalGroup{
+		BACnetObject:            NewBACnetObject(ObjectTypeGlobalGroup, instance, name),
+		GroupMembers:            []DeviceObjectPropertyReference{},
+		RequestedUpdateInterval: 60,
+		observed:                make(map[ObjectIdentifier]bool),
+	}
+	group.Properties[PropertyIdentifierPresentValue] = []PropertyAccessResult{}
+	group.Properties[PropertyIdentifierMemberStatusFlags] = uint8(0)
+	return group
+}
+
+// Execute 注册本地成员的观察者，并按Requested_Update_Interval轮询刷新
+func (g *GlobalGroup) Execute(device *Device, now time.Time) {
+	g.bindObservers(device)
+
+	interval := time.Duration(g.RequestedUpdateInterval) * time.Second
+	if !g.lastUpdate.IsZero() && (interval == 0 || now.Sub(g.lastUpdate) < interval) {
+		return
+	}
+	g.Refresh(device, now)
+}
+
+// bindObservers 在本地成员对象上注册观察者，成员属性变化时立即刷新
+func (g *GlobalGroup) bindObservers(device *Device) {
+	for _, member := range g.GroupMembers {
+		obj, err := device.ResolveReference(member)
+		if err != nil || g.observed[obj.GetObjectIdentifier()] {
+			continue
+		}
+		observable, ok := obj.(interface{ AddPropertyObserver(PropertyObserver) })
+		if !ok {
+			continue
+		}
+		g.observed[obj.GetObjectIdentifier()] = true
+		observable.AddPropertyObserver(func(source Object, prop PropertyIdentifier, value interface{}) {
+			if g.isMember(source.GetObjectIdentifier(), prop) {
+				g.Refresh(device, g.now())
+			}
+		})
+	}
+}
+
+// isMember 判断属性是否是组成员
+func (g *GlobalGroup) isMember(oid ObjectIdentifier, prop PropertyIdentifier) bool {
+	for _, member := range g.GroupMembers {
+		if member.ObjectIdentifier == oid && (member.PropertyIdentifier == prop || prop == PropertyIdentifierStatusFlags) {
+			return true
+		}
+	}
+	return false
+}
+
+// Refresh 读取所有成员，更新Present_Value和Member_Status_Flags
+// 任一成员读取失败时Member_Status_Flags置故障位
+func (g *GlobalGroup) Refresh(device *Device, now time.Time) {
+	g.lastUpdate = now
+
+	results := make([]PropertyAccessResult, len(g.GroupMembers))
+	var flags uint8
+	for i, member := range g.GroupMembers {
+		results[i].Reference = member
+		obj, err := device.ResolveReference(member)
+		if err == nil {
+			results[i].Value, err = ReadPropertyValue(obj, member.PropertyIdentifier)
+		}
+		if err != nil {
+			results[i].Err = err
+			flags |= StatusFlagFault
+			continue
+		}
+		if f := statusFlagsOf(obj); f != nil {
+			flags |= *f
+		}
+	}
+
+	if reflect.DeepEqual(results, g.Properties[PropertyIdentifierPresentValue]) &&
+		flags == g.Properties[PropertyIdentifierMemberStatusFlags] {
+		return
+	}
+	g.Properties[PropertyIdentifierMemberStatusFlags] = flags
+	if g.COVUPeriod > 0 {
+		g.BACnetObject.WriteProperty(PropertyIdentifierPresentValue, results)
+	} else {
+		g.Properties[PropertyIdentifierPresentValue] = results
+	}
+}
+
+// SetReferenceValidator 设置写入Group_Members前检查每个成员的函数，用于拒绝本设备无法解析的引用
+func (g *GlobalGroup) SetReferenceValidator(validator func(DeviceObjectPropertyReference) error) {
+	g.referenceValidator = validator
+}
+
+// ReadProperty 读取全局组属性
+func (g *GlobalGroup) ReadProperty(prop PropertyIdentifier) (interface{}, error) {
+	switch prop {
+	case PropertyIdentifierGroupMembers:
+		return append([]DeviceObjectPropertyReference{}, g.GroupMembers...), nil
+	case PropertyIdentifierGroupMemberNames:
+		return append([]string{}, g.GroupMemberNames...), nil
+	case PropertyIdentifierRequestedUpdateInterval:
+		return g.RequestedUpdateInterval, nil
+	case PropertyIdentifierCOVUPeriod:
+		return g.COVUPeriod, nil
+	case PropertyIdentifierMemberStatusFlags:
+		flags, _ := g.Properties[prop].(uint8)
+		return StatusFlagsBits(flags), nil
+	}
+	return g.BACnetObject.ReadProperty(prop)
+}
+
+// WriteProperty 写入全局组属性，Present_Value和Member_Status_Flags只读
+func (g *GlobalGroup) WriteProperty(prop PropertyIdentifier, value interface{}) error {
+	switch prop {
+	case PropertyIdentifierGroupMembers:
+		members, ok := value.([]DeviceObjectPropertyReference)
+		if !ok {
+			return fmt.Errorf("Group_Members类型无效")
+		}
+		if g.referenceValidator != nil {
+			for _, member := range members {
+				if err := g.referenceValidator(member); err != nil {
+					return err
+				}
+			}
+		}
+		g.GroupMembers = append([]DeviceObjectPropertyReference{}, members...)
+		g.lastUpdate = time.Time{}
+		return nil
+	case PropertyIdentifierGroupMemberNames:
+		names, ok := value.([]string)
+		if !ok {
+			return fmt.Errorf("Group_Member_Names类型无效")
+		}
+		g.GroupMemberNames = append([]string{}, names...)
+		return nil
+	case PropertyIdentifierRequestedUpdateInterval, PropertyIdentifierCOVUPeriod:
+		number, ok := toUint32(value)
+		if !ok {
+			return fmt.Errorf("属性值必须为无符号整数")
+		}
+		if prop == PropertyIdentifierRequestedUpdateInterval {
+			g.RequestedUpdateInterval = number
+		} else {
+			g.COVUPeriod = number
+		}
+		return nil
+	case PropertyIdentifierPresentValue, PropertyIdentifierMemberStatusFlags:
+		return fmt.Errorf("只读属性")
+	}
+	return g.BACnetObject.WriteProperty(prop, value)
+}
@@ -0,0 +1,122 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGlobalGroupMembers(t *testing.T) {
+	device := NewDevice(1, "Device", "")
+	temperature := NewAnalogValue(1, "Temperature", UnitsDegreesCelsius)
+	humidity := NewAnalogValue(2, "Humidity", UnitsPercentRelativeHumidity)
+	group := NewGlobalGroup(1, "Zone")
+	for _, obj := range []Object{temperature, humidity, group} {
+		device.AddObject(obj)
+	}
+	temperature.WriteProperty(PropertyIdentifierPresentValue, float32(21))
+	humidity.WriteProperty(PropertyIdentifierPresentValue, float32(45))
+	group.GroupMembers = []DeviceObjectPropertyReference{
+		{ObjectIdentifier: temperature.GetObjectIdentifier(), PropertyIdentifier: PropertyIdentifierPresentValue},
+		{ObjectIdentifier: humidity.GetObjectIdentifier(), PropertyIdentifier: PropertyIdentifierPresentValue},
+	}
+
+	presentValue := func() []PropertyAccessResult {
+		t.Helper()
+		value, err := group.ReadProperty(PropertyIdentifierPresentValue)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return value.([]PropertyAccessResult)
+	}
+	memberStatusFlags := func() BitString {
+		t.Helper()
+		value, _ := group.ReadProperty(PropertyIdentifierMemberStatusFlags)
+		return value.(BitString)
+	}
+
+	start := time.Date(2024, 3, 1, 8, 0, 0, 0, time.Local)
+	group.Execute(device, start)
+	if results := presentValue(); len(results) != 2 || results[0].Value != float32(21) || results[1].Value != float32(45) {
+		t.Fatalf("Present_Value = %+v", results)
+	}
+	if got := memberStatusFlags(); got.Uint() != 0 {
+		t.Errorf("Member_Status_Flags = %v, want all clear", got)
+	}
+
+	// 本地成员变化时立即刷新，无需等待Requested_Update_Interval
+	temperature.WriteProperty(PropertyIdentifierPresentValue, float32(23))
+	if results := presentValue(); results[0].Value != float32(23) {
+		t.Errorf("Present_Value after member change = %+v", results)
+	}
+
+	// 成员的状态标志汇总到Member_Status_Flags
+	humidity.WriteProperty(PropertyIdentifierOutOfService, true)
+	if got := memberStatusFlags(); got.Uint() != StatusFlagsBits(StatusFlagOutOfService).Uint() {
+		t.Errorf("Member_Status_Flags = %v, want out-of-service", got)
+	}
+
+	// 写Group_Members后下一次执行立即刷新，读取失败的成员记录错误并置故障位
+	members := append(group.GroupMembers, DeviceObjectPropertyReference{
+		ObjectIdentifier: ObjectIdentifier{Type: ObjectTypeAnalogValue, Instance: 99}, PropertyIdentifier: PropertyIdentifierPresentValue,
+	})
+	if err := group.WriteProperty(PropertyIdentifierGroupMembers, members); err != nil {
+		t.Fatal(err)
+	}
+	group.Execute(device, start.Add(time.Second))
+	if results := presentValue(); len(results) != 3 || results[2].Err == nil {
+		t.Errorf("Present_Value with missing member = %+v", results)
+	}
+	if flags, _ := group.Properties[PropertyIdentifierMemberStatusFlags].(uint8); flags&StatusFlagFault == 0 {
+		t.Errorf("Member_Status_Flags = %04b, want fault", flags)
+	}
+
+	// 加入设备后不接受引用其他设备的成员，引用本设备的标识符照常接受
+	local, remote := device.GetObjectIdentifier(), ObjectIdentifier{Type: ObjectTypeDevice, Instance: 2}
+	for _, deviceID := range []ObjectIdentifier{local, remote} {
+		members := append(members, DeviceObjectPropertyReference{
+			ObjectIdentifier: ObjectIdentifier{Type: ObjectTypeAnalogValue, Instance: 1}, PropertyIdentifier: PropertyIdentifierPresentValue, DeviceIdentifier: &deviceID,
+		})
+		err := group.WriteProperty(PropertyIdentifierGroupMembers, members)
+		if want := deviceID == remote; errors.Is(err, ErrRemoteReference) != want {
+			t.Errorf("write member on %v: err = %v, want remote reference error %v", deviceID, err, want)
+		}
+	}
+	if len(group.GroupMembers) != 4 {
+		t.Errorf("Group_Members after rejected write = %+v", group.GroupMembers)
+	}
+
+	if err := group.WriteProperty(PropertyIdentifierPresentValue, []PropertyAccessResult{}); err == nil {
+		t.Error("WriteProperty(Present_Value) succeeded")
+	}
+}
+
+func TestGlobalGroupUpdateInterval(t *testing.T) {
+	device := NewDevice(1, "Device", "")
+	// 以Schedule作为成员：不注册观察者，只能依靠轮询刷新
+	schedule := NewSchedule(1, "Occupancy", float32(16))
+	group := NewGlobalGroup(1, "Zone")
+	group.RequestedUpdateInterval = 60
+	group.GroupMembers = []DeviceObjectPropertyReference{
+		{ObjectIdentifier: schedule.GetObjectIdentifier(), PropertyIdentifier: PropertyIdentifierScheduleDefault},
+	}
+	device.AddObject(schedule)
+	device.AddObject(group)
+
+	start := time.Date(2024, 3, 1, 8, 0, 0, 0, time.Local)
+	group.Execute(device, start)
+	schedule.ScheduleDefault = float32(18)
+
+	value := func() interface{} {
+		results, _ := group.ReadProperty(PropertyIdentifierPresentValue)
+		return results.([]PropertyAccessResult)[0].Value
+	}
+	group.Execute(device, start.Add(30*time.Second))
+	if got := value(); got != float32(16) {
+		t.Errorf("Present_Value before interval = %v, want 16", got)
+	}
+	group.Execute(device, start.Add(time.Minute))
+	if got := value(); got != float32(18) {
+		t.Errorf("Present_Value after interval = %v, want 18", got)
+	}
+}
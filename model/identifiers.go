@@ -0,0 +1,718 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 本文件中的对象类型和属性标识符取值遵循ASHRAE 135第21章，名称表与常量一一对应
+
+// ObjectType 表示BACnet中的对象类型（10位，0-127为标准类型，128-1023为厂商专有类型）
+type ObjectType uint16
+
+// 标准BACnet对象类型
+const (
+	ObjectTypeAnalogInput           ObjectType = 0
+	ObjectTypeAnalogOutput          ObjectType = 1
+	ObjectTypeAnalogValue           ObjectType = 2
+	ObjectTypeBinaryInput           ObjectType = 3
+	ObjectTypeBinaryOutput          ObjectType = 4
+	ObjectTypeBinaryValue           ObjectType = 5
+	ObjectTypeCalendar              ObjectType = 6
+	ObjectTypeCommand               ObjectType = 7
+	ObjectTypeDevice                ObjectType = 8
+	ObjectTypeEventEnrollment       ObjectType = 9
+	ObjectTypeFile                  ObjectType = 10
+	ObjectTypeGroup                 ObjectType = 11
+	ObjectTypeLoop                  ObjectType = 12
+	ObjectTypeMultiStateInput       ObjectType = 13
+	ObjectTypeMultiStateOutput      ObjectType = 14
+	ObjectTypeNotificationClass     ObjectType = 15
+	ObjectTypeProgram               ObjectType = 16
+	ObjectTypeSchedule              ObjectType = 17
+	ObjectTypeAveraging             ObjectType = 18
+	ObjectTypeMultiStateValue       ObjectType = 19
+	ObjectTypeTrendLog              ObjectType = 20
+	ObjectTypeLifeSafetyPoint       ObjectType = 21
+	ObjectTypeLifeSafetyZone        ObjectType = 22
+	ObjectTypeAccumulator           ObjectType = 23
+	ObjectTypePulseConverter        ObjectType = 24
+	ObjectTypeEventLog              ObjectType = 25
+	ObjectTypeGlobalGroup           ObjectType = 26
+	ObjectTypeTrendLogMultiple      ObjectType = 27
+	ObjectTypeLoadControl           ObjectType = 28
+	ObjectTypeStructuredView        ObjectType = 29
+	ObjectTypeAccessDoor            ObjectType = 30
+	ObjectTypeTimer                 ObjectType = 31
+	ObjectTypeAccessCredential      ObjectType = 32
+	ObjectTypeAccessPoint           ObjectType = 33
+	ObjectTypeAccessRights          ObjectType = 34
+	ObjectTypeAccessUser            ObjectType = 35
+	ObjectTypeAccessZone            ObjectType = 36
+	ObjectTypeCredentialDataInput   ObjectType = 37
+	ObjectTypeNetworkSecurity       ObjectType = 38
+	ObjectTypeBitStringValue        ObjectType = 39
+	ObjectTypeCharacterStringValue  ObjectType = 40
+	ObjectTypeDatePatternValue      ObjectType = 41
+	ObjectTypeDateValue             ObjectType = 42
+	ObjectTypeDateTimePatternValue  ObjectType = 43
+	ObjectTypeDateTimeValue         ObjectType = 44
+	ObjectTypeIntegerValue          ObjectType = 45
+	ObjectTypeLargeAnalogValue      ObjectType = 46
+	ObjectTypeOctetStringValue      ObjectType = 47
+	ObjectTypePositiveIntegerValue  ObjectType = 48
+	ObjectTypeTimePatternValue      ObjectType = 49
+	ObjectTypeTimeValue             ObjectType = 50
+	ObjectTypeNotificationForwarder ObjectType = 51
+	ObjectTypeAlertEnrollment       ObjectType = 52
+	ObjectTypeChannel               ObjectType = 53
+	ObjectTypeLightingOutput        ObjectType = 54
+	ObjectTypeBinaryLightingOutput  ObjectType = 55
+	ObjectTypeNetworkPort           ObjectType = 56
+	ObjectTypeElevatorGroup         ObjectType = 57
+	ObjectTypeEscalator             ObjectType = 58
+	ObjectTypeLift                  ObjectType = 59
+	ObjectTypeStaging               ObjectType = 60
+	ObjectTypeAuditLog              ObjectType = 61
+	ObjectTypeAuditReporter         ObjectType = 62
+	ObjectTypeColor                 ObjectType = 63
+	ObjectTypeColorTemperature      ObjectType = 64
+)
+
+// objectTypeNames 标准对象类型名称表
+var objectTypeNames = map[ObjectType]string{
+	ObjectTypeAnalogInput:           "analog-input",
+	ObjectTypeAnalogOutput:          "analog-output",
+	ObjectTypeAnalogValue:           "analog-value",
+	ObjectTypeBinaryInput:           "binary-input",
+	ObjectTypeBinaryOutput:          "binary-output",
+	ObjectTypeBinaryValue:           "binary-value",
+	ObjectTypeCalendar:              "calendar",
+	ObjectTypeCommand:               "command",
+	ObjectTypeDevice:                "device",
+	ObjectTypeEventEnrollment:       "event-enrollment",
+	ObjectTypeFile:                  "file",
+	ObjectTypeGroup:                 "group",
+	ObjectTypeLoop:                  "loop",
+	ObjectTypeMultiStateInput:       "multi-state-input",
+	ObjectTypeMultiStateOutput:      "multi-state-output",
+	ObjectTypeNotificationClass:     "notification-class",
+	ObjectTypeProgram:               "program",
+	ObjectTypeSchedule:              "schedule",
+	ObjectTypeAveraging:             "averaging",
+	ObjectTypeMultiStateValue:       "multi-state-value",
+	ObjectTypeTrendLog:              "trend-log",
+	ObjectTypeLifeSafetyPoint:       "life-safety-point",
+	ObjectTypeLifeSafetyZone:        "life-safety-zone",
+	ObjectTypeAccumulator:           "accumulator",
+	ObjectTypePulseConverter:        "pulse-converter",
+	ObjectTypeEventLog:              "event-log",
+	ObjectTypeGlobalGroup:           "global-group",
+	ObjectTypeTrendLogMultiple:      "trend-log-multiple",
+	ObjectTypeLoadControl:           "load-control",
+	ObjectTypeStructuredView:        "structured-view",
+	ObjectTypeAccessDoor:            "access-door",
+	ObjectTypeTimer:                 "timer",
+	ObjectTypeAccessCredential:      "access-credential",
+	ObjectTypeAccessPoint:           "access-point",
+	ObjectTypeAccessRights:          "access-rights",
+	ObjectTypeAccessUser:            "access-user",
+	ObjectTypeAccessZone:            "access-zone",
+	ObjectTypeCredentialDataInput:   "credential-data-input",
+	ObjectTypeNetworkSecurity:       "network-security",
+	ObjectTypeBitStringValue:        "bitstring-value",
+	ObjectTypeCharacterStringValue:  "characterstring-value",
+	ObjectTypeDatePatternValue:      "date-pattern-value",
+	ObjectTypeDateValue:             "date-value",
+	ObjectTypeDateTimePatternValue:  "datetime-pattern-value",
+	ObjectTypeDateTimeValue:         "datetime-value",
+	ObjectTypeIntegerValue:          "integer-value",
+	ObjectTypeLargeAnalogValue:      "large-analog-value",
+	ObjectTypeOctetStringValue:      "octetstring-value",
+	ObjectTypePositiveIntegerValue:  "positive-integer-value",
+	ObjectTypeTimePatternValue:      "time-pattern-value",
+	ObjectTypeTimeValue:             "time-value",
+	ObjectTypeNotificationForwarder: "notification-forwarder",
+	ObjectTypeAlertEnrollment:       "alert-enrollment",
+	ObjectTypeChannel:               "channel",
+	ObjectTypeLightingOutput:        "lighting-output",
+	ObjectTypeBinaryLightingOutput:  "binary-lighting-output",
+	ObjectTypeNetworkPort:           "network-port",
+	ObjectTypeElevatorGroup:         "elevator-group",
+	ObjectTypeEscalator:             "escalator",
+	ObjectTypeLift:                  "lift",
+	ObjectTypeStaging:               "staging",
+	ObjectTypeAuditLog:              "audit-log",
+	ObjectTypeAuditReporter:         "audit-reporter",
+	ObjectTypeColor:                 "color",
+	ObjectTypeColorTemperature:      "color-temperature",
+}
+
+// String 返回对象类型的标准名称，未知类型返回数值形式
+func (t ObjectType) String() string {
+	if name, ok := objectTypeNames[t]; ok {
+		return name
+	}
+	if name, ok := proprietaryObjectTypeNames[t]; ok {
+		return name
+	}
+	if t >= FirstProprietaryObjectType {
+		return fmt.Sprintf("proprietary-%d", uint16(t))
+	}
+	return fmt.Sprintf("object-type-%d", uint16(t))
+}
+
+// ParseObjectType 按标准名称（如analog-input）、已注册的专有类型名称或数值解析对象类型
+func ParseObjectType(text string) (ObjectType, error) {
+	for t, name := range objectTypeNames {
+		if strings.EqualFold(name, text) {
+			return t, nil
+		}
+	}
+	for t, name := range proprietaryObjectTypeNames {
+		if strings.EqualFold(name, text) {
+			return t, nil
+		}
+	}
+	number, err := parseIdentifierNumber(text, "object-type-", "proprietary-")
+	if err != nil || number > 1023 {
+		return 0, fmt.Errorf("未知的对象类型: %s", text)
+	}
+	return ObjectType(number), nil
+}
+
+// ParseObjectIdentifier 解析"类型:实例号"形式的对象标识符，如analog-input:1或0:1
+func ParseObjectIdentifier(text string) (ObjectIdentifier, error) {
+	typeText, instanceText, ok := strings.Cut(text, ":")
+	if !ok {
+		return ObjectIdentifier{}, fmt.Errorf("对象标识符应为类型:实例号: %s", text)
+	}
+	objType, err := ParseObjectType(typeText)
+	if err != nil {
+		return ObjectIdentifier{}, err
+	}
+	instance, err := strconv.ParseUint(instanceText, 10, 22)
+	if err != nil {
+		return ObjectIdentifier{}, fmt.Errorf("无效的实例号: %s", instanceText)
+	}
+	return ObjectIdentifier{Type: objType, Instance: uint32(instance)}, nil
+}
+
+// PropertyIdentifier 表示BACnet中的属性标识符（22位，512以上为厂商专有属性）
+type PropertyIdentifier uint32
+
+// 标准BACnet属性标识符
+const (
+	PropertyIdentifierAckedTransitions                 PropertyIdentifier = 0
+	PropertyIdentifierAckRequired                      PropertyIdentifier = 1
+	PropertyIdentifierAction                           PropertyIdentifier = 2
+	PropertyIdentifierActionText                       PropertyIdentifier = 3
+	PropertyIdentifierActiveText                       PropertyIdentifier = 4
+	PropertyIdentifierActiveVTSessions                 PropertyIdentifier = 5
+	PropertyIdentifierAlarmValue                       PropertyIdentifier = 6
+	PropertyIdentifierAlarmValues                      PropertyIdentifier = 7
+	PropertyIdentifierAll                              PropertyIdentifier = 8
+	PropertyIdentifierAllWritesSuccessful              PropertyIdentifier = 9
+	PropertyIdentifierAPDUSegmentTimeout               PropertyIdentifier = 10
+	PropertyIdentifierAPDUTimeout                      PropertyIdentifier = 11
+	PropertyIdentifierApplicationSoftwareVersion       PropertyIdentifier = 12
+	PropertyIdentifierArchive                          PropertyIdentifier = 13
+	PropertyIdentifierBias                             PropertyIdentifier = 14
+	PropertyIdentifierChangeOfStateCount               PropertyIdentifier = 15
+	PropertyIdentifierChangeOfStateTime                PropertyIdentifier = 16
+	PropertyIdentifierNotificationClass                PropertyIdentifier = 17
+	PropertyIdentifierControlledVariableReference      PropertyIdentifier = 19
+	PropertyIdentifierControlledVariableUnits          PropertyIdentifier = 20
+	PropertyIdentifierControlledVariableValue          PropertyIdentifier = 21
+	PropertyIdentifierCOVIncrement                     PropertyIdentifier = 22
+	PropertyIdentifierDateList                         PropertyIdentifier = 23
+	PropertyIdentifierDaylightSavingsStatus            PropertyIdentifier = 24
+	PropertyIdentifierDeadband                         PropertyIdentifier = 25
+	PropertyIdentifierDerivativeConstant               PropertyIdentifier = 26
+	PropertyIdentifierDerivativeConstantUnits          PropertyIdentifier = 27
+	PropertyIdentifierDescription                      PropertyIdentifier = 28
+	PropertyIdentifierDescriptionOfHalt                PropertyIdentifier = 29
+	PropertyIdentifierDeviceAddressBinding             PropertyIdentifier = 30
+	PropertyIdentifierDeviceType                       PropertyIdentifier = 31
+	PropertyIdentifierEffectivePeriod                  PropertyIdentifier = 32
+	PropertyIdentifierElapsedActiveTime                PropertyIdentifier = 33
+	PropertyIdentifierErrorLimit                       PropertyIdentifier = 34
+	PropertyIdentifierEventEnable                      PropertyIdentifier = 35
+	PropertyIdentifierEventState                       PropertyIdentifier = 36
+	PropertyIdentifierEventType                        PropertyIdentifier = 37
+	PropertyIdentifierExceptionSchedule                PropertyIdentifier = 38
+	PropertyIdentifierFaultValues                      PropertyIdentifier = 39
+	PropertyIdentifierFeedbackValue                    PropertyIdentifier = 40
+	PropertyIdentifierFileAccessMethod                 PropertyIdentifier = 41
+	PropertyIdentifierFileSize                         PropertyIdentifier = 42
+	PropertyIdentifierFileType                         PropertyIdentifier = 43
+	PropertyIdentifierFirmwareRevision                 PropertyIdentifier = 44
+	PropertyIdentifierHighLimit                        PropertyIdentifier = 45
+	PropertyIdentifierInactiveText                     PropertyIdentifier = 46
+	PropertyIdentifierInProcess                        PropertyIdentifier = 47
+	PropertyIdentifierInstanceOf                       PropertyIdentifier = 48
+	PropertyIdentifierIntegralConstant                 PropertyIdentifier = 49
+	PropertyIdentifierIntegralConstantUnits            PropertyIdentifier = 50
+	PropertyIdentifierLimitEnable                      PropertyIdentifier = 52
+	PropertyIdentifierListOfGroupMembers               PropertyIdentifier = 53
+	PropertyIdentifierListOfObjectPropertyReferences   PropertyIdentifier = 54
+	PropertyIdentifierLocalDate                        PropertyIdentifier = 56
+	PropertyIdentifierLocalTime                        PropertyIdentifier = 57
+	PropertyIdentifierLocation                         PropertyIdentifier = 58
+	PropertyIdentifierLowLimit                         PropertyIdentifier = 59
+	PropertyIdentifierManipulatedVariableReference     PropertyIdentifier = 60
+	PropertyIdentifierMaximumOutput                    PropertyIdentifier = 61
+	PropertyIdentifierMaxAPDULengthAccepted            PropertyIdentifier = 62
+	PropertyIdentifierMaxInfoFrames                    PropertyIdentifier = 63
+	PropertyIdentifierMaxMaster                        PropertyIdentifier = 64
+	PropertyIdentifierMaxPresValue                     PropertyIdentifier = 65
+	PropertyIdentifierMinimumOffTime                   PropertyIdentifier = 66
+	PropertyIdentifierMinimumOnTime                    PropertyIdentifier = 67
+	PropertyIdentifierMinimumOutput                    PropertyIdentifier = 68
+	PropertyIdentifierMinPresValue                     PropertyIdentifier = 69
+	PropertyIdentifierModelName                        PropertyIdentifier = 70
+	PropertyIdentifierModificationDate                 PropertyIdentifier = 71
+	PropertyIdentifierNotifyType                       PropertyIdentifier = 72
+	PropertyIdentifierNumberOfAPDURetries              PropertyIdentifier = 73
+	PropertyIdentifierNumberOfStates                   PropertyIdentifier = 74
+	PropertyIdentifierObjectIdentifier                 PropertyIdentifier = 75
+	PropertyIdentifierObjectList                       PropertyIdentifier = 76
+	PropertyIdentifierObjectName                       PropertyIdentifier = 77
+	PropertyIdentifierObjectPropertyReference          PropertyIdentifier = 78
+	PropertyIdentifierObjectType                       PropertyIdentifier = 79
+	PropertyIdentifierOptional                         PropertyIdentifier = 80
+	PropertyIdentifierOutOfService                     PropertyIdentifier = 81
+	PropertyIdentifierOutputUnits                      PropertyIdentifier = 82
+	PropertyIdentifierEventParameters                  PropertyIdentifier = 83
+	PropertyIdentifierPolarity                         PropertyIdentifier = 84
+	PropertyIdentifierPresentValue                     PropertyIdentifier = 85
+	PropertyIdentifierPriority                         PropertyIdentifier = 86
+	PropertyIdentifierPriorityArray                    PropertyIdentifier = 87
+	PropertyIdentifierPriorityForWriting               PropertyIdentifier = 88
+	PropertyIdentifierProcessIdentifier                PropertyIdentifier = 89
+	PropertyIdentifierProgramChange                    PropertyIdentifier = 90
+	PropertyIdentifierProgramLocation                  PropertyIdentifier = 91
+	PropertyIdentifierProgramState                     PropertyIdentifier = 92
+	PropertyIdentifierProportionalConstant             PropertyIdentifier = 93
+	PropertyIdentifierProportionalConstantUnits        PropertyIdentifier = 94
+	PropertyIdentifierProtocolObjectTypesSupported     PropertyIdentifier = 96
+	PropertyIdentifierProtocolServicesSupported        PropertyIdentifier = 97
+	PropertyIdentifierProtocolVersion                  PropertyIdentifier = 98
+	PropertyIdentifierReadOnly                         PropertyIdentifier = 99
+	PropertyIdentifierReasonForHalt                    PropertyIdentifier = 100
+	PropertyIdentifierRecipientList                    PropertyIdentifier = 102
+	PropertyIdentifierReliability                      PropertyIdentifier = 103
+	PropertyIdentifierRelinquishDefault                PropertyIdentifier = 104
+	PropertyIdentifierRequired                         PropertyIdentifier = 105
+	PropertyIdentifierResolution                       PropertyIdentifier = 106
+	PropertyIdentifierSegmentationSupported            PropertyIdentifier = 107
+	PropertyIdentifierSetpoint                         PropertyIdentifier = 108
+	PropertyIdentifierSetpointReference                PropertyIdentifier = 109
+	PropertyIdentifierStateText                        PropertyIdentifier = 110
+	PropertyIdentifierStatusFlags                      PropertyIdentifier = 111
+	PropertyIdentifierSystemStatus                     PropertyIdentifier = 112
+	PropertyIdentifierTimeDelay                        PropertyIdentifier = 113
+	PropertyIdentifierTimeOfActiveTimeReset            PropertyIdentifier = 114
+	PropertyIdentifierTimeOfStateCountReset            PropertyIdentifier = 115
+	PropertyIdentifierTimeSynchronizationRecipients    PropertyIdentifier = 116
+	PropertyIdentifierUnits                            PropertyIdentifier = 117
+	PropertyIdentifierUpdateInterval                   PropertyIdentifier = 118
+	PropertyIdentifierUTCOffset                        PropertyIdentifier = 119
+	PropertyIdentifierVendorIdentifier                 PropertyIdentifier = 120
+	PropertyIdentifierVendorName                       PropertyIdentifier = 121
+	PropertyIdentifierVTClassesSupported               PropertyIdentifier = 122
+	PropertyIdentifierWeeklySchedule                   PropertyIdentifier = 123
+	PropertyIdentifierAttemptedSamples                 PropertyIdentifier = 124
+	PropertyIdentifierAverageValue                     PropertyIdentifier = 125
+	PropertyIdentifierBufferSize                       PropertyIdentifier = 126
+	PropertyIdentifierClientCOVIncrement               PropertyIdentifier = 127
+	PropertyIdentifierCOVResubscriptionInterval        PropertyIdentifier = 128
+	PropertyIdentifierEventTimeStamps                  PropertyIdentifier = 130
+	PropertyIdentifierLogBuffer                        PropertyIdentifier = 131
+	PropertyIdentifierLogDeviceObjectProperty          PropertyIdentifier = 132
+	PropertyIdentifierLogEnable                        PropertyIdentifier = 133
+	PropertyIdentifierLogInterval                      PropertyIdentifier = 134
+	PropertyIdentifierMaximumValue                     PropertyIdentifier = 135
+	PropertyIdentifierMinimumValue                     PropertyIdentifier = 136
+	PropertyIdentifierNotificationThreshold            PropertyIdentifier = 137
+	PropertyIdentifierProtocolRevision                 PropertyIdentifier = 139
+	PropertyIdentifierRecordsSinceNotification         PropertyIdentifier = 140
+	PropertyIdentifierRecordCount                      PropertyIdentifier = 141
+	PropertyIdentifierStartTime                        PropertyIdentifier = 142
+	PropertyIdentifierStopTime                         PropertyIdentifier = 143
+	PropertyIdentifierStopWhenFull                     PropertyIdentifier = 144
+	PropertyIdentifierTotalRecordCount                 PropertyIdentifier = 145
+	PropertyIdentifierValidSamples                     PropertyIdentifier = 146
+	PropertyIdentifierWindowInterval                   PropertyIdentifier = 147
+	PropertyIdentifierWindowSamples                    PropertyIdentifier = 148
+	PropertyIdentifierMaximumValueTimestamp            PropertyIdentifier = 149
+	PropertyIdentifierMinimumValueTimestamp            PropertyIdentifier = 150
+	PropertyIdentifierVarianceValue                    PropertyIdentifier = 151
+	PropertyIdentifierActiveCOVSubscriptions           PropertyIdentifier = 152
+	PropertyIdentifierBackupFailureTimeout             PropertyIdentifier = 153
+	PropertyIdentifierConfigurationFiles               PropertyIdentifier = 154
+	PropertyIdentifierDatabaseRevision                 PropertyIdentifier = 155
+	PropertyIdentifierDirectReading                    PropertyIdentifier = 156
+	PropertyIdentifierLastRestoreTime                  PropertyIdentifier = 157
+	PropertyIdentifierMaintenanceRequired              PropertyIdentifier = 158
+	PropertyIdentifierMemberOf                         PropertyIdentifier = 159
+	PropertyIdentifierMode                             PropertyIdentifier = 160
+	PropertyIdentifierOperationExpected                PropertyIdentifier = 161
+	PropertyIdentifierSetting                          PropertyIdentifier = 162
+	PropertyIdentifierSilenced                         PropertyIdentifier = 163
+	PropertyIdentifierTrackingValue                    PropertyIdentifier = 164
+	PropertyIdentifierZoneMembers                      PropertyIdentifier = 165
+	PropertyIdentifierLifeSafetyAlarmValues            PropertyIdentifier = 166
+	PropertyIdentifierMaxSegmentsAccepted              PropertyIdentifier = 167
+	PropertyIdentifierProfileName                      PropertyIdentifier = 168
+	PropertyIdentifierAutoSlaveDiscovery               PropertyIdentifier = 169
+	PropertyIdentifierManualSlaveAddressBinding        PropertyIdentifier = 170
+	PropertyIdentifierSlaveAddressBinding              PropertyIdentifier = 171
+	PropertyIdentifierSlaveProxyEnable                 PropertyIdentifier = 172
+	PropertyIdentifierLastNotifyRecord                 PropertyIdentifier = 173
+	PropertyIdentifierScheduleDefault                  PropertyIdentifier = 174
+	PropertyIdentifierAcceptedModes                    PropertyIdentifier = 175
+	PropertyIdentifierAdjustValue                      PropertyIdentifier = 176
+	PropertyIdentifierCount                            PropertyIdentifier = 177
+	PropertyIdentifierCountBeforeChange                PropertyIdentifier = 178
+	PropertyIdentifierCountChangeTime                  PropertyIdentifier = 179
+	PropertyIdentifierCOVPeriod                        PropertyIdentifier = 180
+	PropertyIdentifierInputReference                   PropertyIdentifier = 181
+	PropertyIdentifierLimitMonitoringInterval          PropertyIdentifier = 182
+	PropertyIdentifierLoggingObject                    PropertyIdentifier = 183
+	PropertyIdentifierLoggingRecord                    PropertyIdentifier = 184
+	PropertyIdentifierPrescale                         PropertyIdentifier = 185
+	PropertyIdentifierPulseRate                        PropertyIdentifier = 186
+	PropertyIdentifierScale                            PropertyIdentifier = 187
+	PropertyIdentifierScaleFactor                      PropertyIdentifier = 188
+	PropertyIdentifierUpdateTime                       PropertyIdentifier = 189
+	PropertyIdentifierValueBeforeChange                PropertyIdentifier = 190
+	PropertyIdentifierValueSet                         PropertyIdentifier = 191
+	PropertyIdentifierValueChangeTime                  PropertyIdentifier = 192
+	PropertyIdentifierAlignIntervals                   PropertyIdentifier = 193
+	PropertyIdentifierIntervalOffset                   PropertyIdentifier = 195
+	PropertyIdentifierLastRestartReason                PropertyIdentifier = 196
+	PropertyIdentifierLoggingType                      PropertyIdentifier = 197
+	PropertyIdentifierRestartNotificationRecipients    PropertyIdentifier = 202
+	PropertyIdentifierTimeOfDeviceRestart              PropertyIdentifier = 203
+	PropertyIdentifierTimeSynchronizationInterval      PropertyIdentifier = 204
+	PropertyIdentifierTrigger                          PropertyIdentifier = 205
+	PropertyIdentifierUTCTimeSynchronizationRecipients PropertyIdentifier = 206
+	PropertyIdentifierNodeSubtype                      PropertyIdentifier = 207
+	PropertyIdentifierNodeType                         PropertyIdentifier = 208
+	PropertyIdentifierStructuredObjectList             PropertyIdentifier = 209
+	PropertyIdentifierSubordinateAnnotations           PropertyIdentifier = 210
+	PropertyIdentifierSubordinateList                  PropertyIdentifier = 211
+	PropertyIdentifierActualShedLevel                  PropertyIdentifier = 212
+	PropertyIdentifierDutyWindow                       PropertyIdentifier = 213
+	PropertyIdentifierExpectedShedLevel                PropertyIdentifier = 214
+	PropertyIdentifierFullDutyBaseline                 PropertyIdentifier = 215
+	PropertyIdentifierRequestedShedLevel               PropertyIdentifier = 218
+	PropertyIdentifierShedDuration                     PropertyIdentifier = 219
+	PropertyIdentifierShedLevelDescriptions            PropertyIdentifier = 220
+	PropertyIdentifierShedLevels                       PropertyIdentifier = 221
+	PropertyIdentifierStateDescription                 PropertyIdentifier = 222
+	PropertyIdentifierDoorAlarmState                   PropertyIdentifier = 226
+	PropertyIdentifierDoorExtendedPulseTime            PropertyIdentifier = 227
+	PropertyIdentifierDoorMembers                      PropertyIdentifier = 228
+	PropertyIdentifierDoorOpenTooLongTime              PropertyIdentifier = 229
+	PropertyIdentifierDoorPulseTime                    PropertyIdentifier = 230
+	PropertyIdentifierDoorStatus                       PropertyIdentifier = 231
+	PropertyIdentifierDoorUnlockDelayTime              PropertyIdentifier = 232
+	PropertyIdentifierLockStatus                       PropertyIdentifier = 233
+	PropertyIdentifierMaskedAlarmValues                PropertyIdentifier = 234
+	PropertyIdentifierSecuredStatus                    PropertyIdentifier = 235
+	PropertyIdentifierGroupMembers                     PropertyIdentifier = 345
+	PropertyIdentifierGroupMemberNames                 PropertyIdentifier = 346
+	PropertyIdentifierMemberStatusFlags                PropertyIdentifier = 347
+	PropertyIdentifierRequestedUpdateInterval          PropertyIdentifier = 348
+	PropertyIdentifierCOVUPeriod                       PropertyIdentifier = 349
+	PropertyIdentifierCOVURecipients                   PropertyIdentifier = 350
+	PropertyIdentifierEventMessageTexts                PropertyIdentifier = 351
+	PropertyIdentifierEventMessageTextsConfig          PropertyIdentifier = 352
+	PropertyIdentifierEventDetectionEnable             PropertyIdentifier = 353
+	PropertyIdentifierEventAlgorithmInhibit            PropertyIdentifier = 354
+	PropertyIdentifierEventAlgorithmInhibitRef         PropertyIdentifier = 355
+	PropertyIdentifierTimeDelayNormal                  PropertyIdentifier = 356
+	PropertyIdentifierReliabilityEvaluationInhibit     PropertyIdentifier = 357
+	PropertyIdentifierPropertyList                     PropertyIdentifier = 371
+)
+
+// propertyIdentifierNames 标准属性名称表
+var propertyIdentifierNames = map[PropertyIdentifier]string{
+	PropertyIdentifierAckedTransitions:                 "acked-transitions",
+	PropertyIdentifierAckRequired:                      "ack-required",
+	PropertyIdentifierAction:                           "action",
+	PropertyIdentifierActionText:                       "action-text",
+	PropertyIdentifierActiveText:                       "active-text",
+	PropertyIdentifierActiveVTSessions:                 "active-vt-sessions",
+	PropertyIdentifierAlarmValue:                       "alarm-value",
+	PropertyIdentifierAlarmValues:                      "alarm-values",
+	PropertyIdentifierAll:                              "all",
+	PropertyIdentifierAllWritesSuccessful:              "all-writes-successful",
+	PropertyIdentifierAPDUSegmentTimeout:               "apdu-segment-timeout",
+	PropertyIdentifierAPDUTimeout:                      "apdu-timeout",
+	PropertyIdentifierApplicationSoftwareVersion:       "application-software-version",
+	PropertyIdentifierArchive:                          "archive",
+	PropertyIdentifierBias:                             "bias",
+	PropertyIdentifierChangeOfStateCount:               "change-of-state-count",
+	PropertyIdentifierChangeOfStateTime:                "change-of-state-time",
+	PropertyIdentifierNotificationClass:                "notification-class",
+	PropertyIdentifierControlledVariableReference:      "controlled-variable-reference",
+	PropertyIdentifierControlledVariableUnits:          "controlled-variable-units",
+	PropertyIdentifierControlledVariableValue:          "controlled-variable-value",
+	PropertyIdentifierCOVIncrement:                     "cov-increment",
+	PropertyIdentifierDateList:                         "date-list",
+	PropertyIdentifierDaylightSavingsStatus:            "daylight-savings-status",
+	PropertyIdentifierDeadband:                         "deadband",
+	PropertyIdentifierDerivativeConstant:               "derivative-constant",
+	PropertyIdentifierDerivativeConstantUnits:          "derivative-constant-units",
+	PropertyIdentifierDescription:                      "description",
+	PropertyIdentifierDescriptionOfHalt:                "description-of-halt",
+	PropertyIdentifierDeviceAddressBinding:             "device-address-binding",
+	PropertyIdentifierDeviceType:                       "device-type",
+	PropertyIdentifierEffectivePeriod:                  "effective-period",
+	PropertyIdentifierElapsedActiveTime:                "elapsed-active-time",
+	PropertyIdentifierErrorLimit:                       "error-limit",
+	PropertyIdentifierEventEnable:                      "event-enable",
+	PropertyIdentifierEventState:                       "event-state",
+	PropertyIdentifierEventType:                        "event-type",
+	PropertyIdentifierExceptionSchedule:                "exception-schedule",
+	PropertyIdentifierFaultValues:                      "fault-values",
+	PropertyIdentifierFeedbackValue:                    "feedback-value",
+	PropertyIdentifierFileAccessMethod:                 "file-access-method",
+	PropertyIdentifierFileSize:                         "file-size",
+	PropertyIdentifierFileType:                         "file-type",
+	PropertyIdentifierFirmwareRevision:                 "firmware-revision",
+	PropertyIdentifierHighLimit:                        "high-limit",
+	PropertyIdentifierInactiveText:                     "inactive-text",
+	PropertyIdentifierInProcess:                        "in-process",
+	PropertyIdentifierInstanceOf:                       "instance-of",
+	PropertyIdentifierIntegralConstant:                 "integral-constant",
+	PropertyIdentifierIntegralConstantUnits:            "integral-constant-units",
+	PropertyIdentifierLimitEnable:                      "limit-enable",
+	PropertyIdentifierListOfGroupMembers:               "list-of-group-members",
+	PropertyIdentifierListOfObjectPropertyReferences:   "list-of-object-property-references",
+	PropertyIdentifierLocalDate:                        "local-date",
+	PropertyIdentifierLocalTime:                        "local-time",
+	PropertyIdentifierLocation:                         "location",
+	PropertyIdentifierLowLimit:                         "low-limit",
+	PropertyIdentifierManipulatedVariableReference:     "manipulated-variable-reference",
+	PropertyIdentifierMaximumOutput:                    "maximum-output",
+	PropertyIdentifierMaxAPDULengthAccepted:            "max-apdu-length-accepted",
+	PropertyIdentifierMaxInfoFrames:                    "max-info-frames",
+	PropertyIdentifierMaxMaster:                        "max-master",
+	PropertyIdentifierMaxPresValue:                     "max-pres-value",
+	PropertyIdentifierMinimumOffTime:                   "minimum-off-time",
+	PropertyIdentifierMinimumOnTime:                    "minimum-on-time",
+	PropertyIdentifierMinimumOutput:                    "minimum-output",
+	PropertyIdentifierMinPresValue:                     "min-pres-value",
+	PropertyIdentifierModelName:                        "model-name",
+	PropertyIdentifierModificationDate:                 "modification-date",
+	PropertyIdentifierNotifyType:                       "notify-type",
+	PropertyIdentifierNumberOfAPDURetries:              "number-of-apdu-retries",
+	PropertyIdentifierNumberOfStates:                   "number-of-states",
+	PropertyIdentifierObjectIdentifier:                 "object-identifier",
+	PropertyIdentifierObjectList:                       "object-list",
+	PropertyIdentifierObjectName:                       "object-name",
+	PropertyIdentifierObjectPropertyReference:          "object-property-reference",
+	PropertyIdentifierObjectType:                       "object-type",
+	PropertyIdentifierOptional:                         "optional",
+	PropertyIdentifierOutOfService:                     "out-of-service",
+	PropertyIdentifierOutputUnits:                      "output-units",
+	PropertyIdentifierEventParameters:                  "event-parameters",
+	PropertyIdentifierPolarity:                         "polarity",
+	PropertyIdentifierPresentValue:                     "present-value",
+	PropertyIdentifierPriority:                         "priority",
+	PropertyIdentifierPriorityArray:                    "priority-array",
+	PropertyIdentifierPriorityForWriting:               "priority-for-writing",
+	PropertyIdentifierProcessIdentifier:                "process-identifier",
+	PropertyIdentifierProgramChange:                    "program-change",
+	PropertyIdentifierProgramLocation:                  "program-location",
+	PropertyIdentifierProgramState:                     "program-state",
+	PropertyIdentifierProportionalConstant:             "proportional-constant",
+	PropertyIdentifierProportionalConstantUnits:        "proportional-constant-units",
+	PropertyIdentifierProtocolObjectTypesSupported:     "protocol-object-types-supported",
+	PropertyIdentifierProtocolServicesSupported:        "protocol-services-supported",
+	PropertyIdentifierProtocolVersion:                  "protocol-version",
+	PropertyIdentifierReadOnly:                         "read-only",
+	PropertyIdentifierReasonForHalt:                    "reason-for-halt",
+	PropertyIdentifierRecipientList:                    "recipient-list",
+	PropertyIdentifierReliability:                      "reliability",
+	PropertyIdentifierRelinquishDefault:                "relinquish-default",
+	PropertyIdentifierRequired:                         "required",
+	PropertyIdentifierResolution:                       "resolution",
+	PropertyIdentifierSegmentationSupported:            "segmentation-supported",
+	PropertyIdentifierSetpoint:                         "setpoint",
+	PropertyIdentifierSetpointReference:                "setpoint-reference",
+	PropertyIdentifierStateText:                        "state-text",
+	PropertyIdentifierStatusFlags:                      "status-flags",
+	PropertyIdentifierSystemStatus:                     "system-status",
+	PropertyIdentifierTimeDelay:                        "time-delay",
+	PropertyIdentifierTimeOfActiveTimeReset:            "time-of-active-time-reset",
+	PropertyIdentifierTimeOfStateCountReset:            "time-of-state-count-reset",
+	PropertyIdentifierTimeSynchronizationRecipients:    "time-synchronization-recipients",
+	PropertyIdentifierUnits:                            "units",
+	PropertyIdentifierUpdateInterval:                   "update-interval",
+	PropertyIdentifierUTCOffset:                        "utc-offset",
+	PropertyIdentifierVendorIdentifier:                 "vendor-identifier",
+	PropertyIdentifierVendorName:                       "vendor-name",
+	PropertyIdentifierVTClassesSupported:               "vt-classes-supported",
+	PropertyIdentifierWeeklySchedule:                   "weekly-schedule",
+	PropertyIdentifierAttemptedSamples:                 "attempted-samples",
+	PropertyIdentifierAverageValue:                     "average-value",
+	PropertyIdentifierBufferSize:                       "buffer-size",
+	PropertyIdentifierClientCOVIncrement:               "client-cov-increment",
+	PropertyIdentifierCOVResubscriptionInterval:        "cov-resubscription-interval",
+	PropertyIdentifierEventTimeStamps:                  "event-time-stamps",
+	PropertyIdentifierLogBuffer:                        "log-buffer",
+	PropertyIdentifierLogDeviceObjectProperty:          "log-device-object-property",
+	PropertyIdentifierLogEnable:                        "enable",
+	PropertyIdentifierLogInterval:                      "log-interval",
+	PropertyIdentifierMaximumValue:                     "maximum-value",
+	PropertyIdentifierMinimumValue:                     "minimum-value",
+	PropertyIdentifierNotificationThreshold:            "notification-threshold",
+	PropertyIdentifierProtocolRevision:                 "protocol-revision",
+	PropertyIdentifierRecordsSinceNotification:         "records-since-notification",
+	PropertyIdentifierRecordCount:                      "record-count",
+	PropertyIdentifierStartTime:                        "start-time",
+	PropertyIdentifierStopTime:                         "stop-time",
+	PropertyIdentifierStopWhenFull:                     "stop-when-full",
+	PropertyIdentifierTotalRecordCount:                 "total-record-count",
+	PropertyIdentifierValidSamples:                     "valid-samples",
+	PropertyIdentifierWindowInterval:                   "window-interval",
+	PropertyIdentifierWindowSamples:                    "window-samples",
+	PropertyIdentifierMaximumValueTimestamp:            "maximum-value-timestamp",
+	PropertyIdentifierMinimumValueTimestamp:            "minimum-value-timestamp",
+	PropertyIdentifierVarianceValue:                    "variance-value",
+	PropertyIdentifierActiveCOVSubscriptions:           "active-cov-subscriptions",
+	PropertyIdentifierBackupFailureTimeout:             "backup-failure-timeout",
+	PropertyIdentifierConfigurationFiles:               "configuration-files",
+	PropertyIdentifierDatabaseRevision:                 "database-revision",
+	PropertyIdentifierDirectReading:                    "direct-reading",
+	PropertyIdentifierLastRestoreTime:                  "last-restore-time",
+	PropertyIdentifierMaintenanceRequired:              "maintenance-required",
+	PropertyIdentifierMemberOf:                         "member-of",
+	PropertyIdentifierMode:                             "mode",
+	PropertyIdentifierOperationExpected:                "operation-expected",
+	PropertyIdentifierSetting:                          "setting",
+	PropertyIdentifierSilenced:                         "silenced",
+	PropertyIdentifierTrackingValue:                    "tracking-value",
+	PropertyIdentifierZoneMembers:                      "zone-members",
+	PropertyIdentifierLifeSafetyAlarmValues:            "life-safety-alarm-values",
+	PropertyIdentifierMaxSegmentsAccepted:              "max-segments-accepted",
+	PropertyIdentifierProfileName:                      "profile-name",
+	PropertyIdentifierAutoSlaveDiscovery:               "auto-slave-discovery",
+	PropertyIdentifierManualSlaveAddressBinding:        "manual-slave-address-binding",
+	PropertyIdentifierSlaveAddressBinding:              "slave-address-binding",
+	PropertyIdentifierSlaveProxyEnable:                 "slave-proxy-enable",
+	PropertyIdentifierLastNotifyRecord:                 "last-notify-record",
+	PropertyIdentifierScheduleDefault:                  "schedule-default",
+	PropertyIdentifierAcceptedModes:                    "accepted-modes",
+	PropertyIdentifierAdjustValue:                      "adjust-value",
+	PropertyIdentifierCount:                            "count",
+	PropertyIdentifierCountBeforeChange:                "count-before-change",
+	PropertyIdentifierCountChangeTime:                  "count-change-time",
+	PropertyIdentifierCOVPeriod:                        "cov-period",
+	PropertyIdentifierInputReference:                   "input-reference",
+	PropertyIdentifierLimitMonitoringInterval:          "limit-monitoring-interval",
+	PropertyIdentifierLoggingObject:                    "logging-object",
+	PropertyIdentifierLoggingRecord:                    "logging-record",
+	PropertyIdentifierPrescale:                         "prescale",
+	PropertyIdentifierPulseRate:                        "pulse-rate",
+	PropertyIdentifierScale:                            "scale",
+	PropertyIdentifierScaleFactor:                      "scale-factor",
+	PropertyIdentifierUpdateTime:                       "update-time",
+	PropertyIdentifierValueBeforeChange:                "value-before-change",
+	PropertyIdentifierValueSet:                         "value-set",
+	PropertyIdentifierValueChangeTime:                  "value-change-time",
+	PropertyIdentifierAlignIntervals:                   "align-intervals",
+	PropertyIdentifierIntervalOffset:                   "interval-offset",
+	PropertyIdentifierLastRestartReason:                "last-restart-reason",
+	PropertyIdentifierLoggingType:                      "logging-type",
+	PropertyIdentifierRestartNotificationRecipients:    "restart-notification-recipients",
+	PropertyIdentifierTimeOfDeviceRestart:              "time-of-device-restart",
+	PropertyIdentifierTimeSynchronizationInterval:      "time-synchronization-interval",
+	PropertyIdentifierTrigger:                          "trigger",
+	PropertyIdentifierUTCTimeSynchronizationRecipients: "utc-time-synchronization-recipients",
+	PropertyIdentifierNodeSubtype:                      "node-subtype",
+	PropertyIdentifierNodeType:                         "node-type",
+	PropertyIdentifierStructuredObjectList:             "structured-object-list",
+	PropertyIdentifierSubordinateAnnotations:           "subordinate-annotations",
+	PropertyIdentifierSubordinateList:                  "subordinate-list",
+	PropertyIdentifierActualShedLevel:                  "actual-shed-level",
+	PropertyIdentifierDutyWindow:                       "duty-window",
+	PropertyIdentifierExpectedShedLevel:                "expected-shed-level",
+	PropertyIdentifierFullDutyBaseline:                 "full-duty-baseline",
+	PropertyIdentifierRequestedShedLevel:               "requested-shed-level",
+	PropertyIdentifierShedDuration:                     "shed-duration",
+	PropertyIdentifierShedLevelDescriptions:            "shed-level-descriptions",
+	PropertyIdentifierShedLevels:                       "shed-levels",
+	PropertyIdentifierStateDescription:                 "state-description",
+	PropertyIdentifierDoorAlarmState:                   "door-alarm-state",
+	PropertyIdentifierDoorExtendedPulseTime:            "door-extended-pulse-time",
+	PropertyIdentifierDoorMembers:                      "door-members",
+	PropertyIdentifierDoorOpenTooLongTime:              "door-open-too-long-time",
+	PropertyIdentifierDoorPulseTime:                    "door-pulse-time",
+	PropertyIdentifierDoorStatus:                       "door-status",
+	PropertyIdentifierDoorUnlockDelayTime:              "door-unlock-delay-time",
+	PropertyIdentifierLockStatus:                       "lock-status",
+	PropertyIdentifierMaskedAlarmValues:                "masked-alarm-values",
+	PropertyIdentifierSecuredStatus:                    "secured-status",
+	PropertyIdentifierGroupMembers:                     "group-members",
+	PropertyIdentifierGroupMemberNames:                 "group-member-names",
+	PropertyIdentifierMemberStatusFlags:                "member-status-flags",
+	PropertyIdentifierRequestedUpdateInterval:          "requested-update-interval",
+	PropertyIdentifierCOVUPeriod:                       "covu-period",
+	PropertyIdentifierCOVURecipients:                   "covu-recipients",
+	PropertyIdentifierEventMessageTexts:                "event-message-texts",
+	PropertyIdentifierEventMessageTextsConfig:          "event-message-texts-config",
+	PropertyIdentifierEventDetectionEnable:             "event-detection-enable",
+	PropertyIdentifierEventAlgorithmInhibit:            "event-algorithm-inhibit",
+	PropertyIdentifierEventAlgorithmInhibitRef:         "event-algorithm-inhibit-ref",
+	PropertyIdentifierTimeDelayNormal:                  "time-delay-normal",
+	PropertyIdentifierReliabilityEvaluationInhibit:     "reliability-evaluation-inhibit",
+	PropertyIdentifierPropertyList:                     "property-list",
+}
+
+// String 返回属性标识符的标准名称，未知属性返回数值形式
+func (p PropertyIdentifier) String() string {
+	if name, ok := propertyIdentifierNames[p]; ok {
+		return name
+	}
+	if prop, ok := proprietaryProperties[p]; ok {
+		return prop.Name
+	}
+	if p >= FirstProprietaryProperty {
+		return fmt.Sprintf("proprietary-%d", uint32(p))
+	}
+	return fmt.Sprintf("property-%d", uint32(p))
+}
+
+// ParsePropertyIdentifier 按标准名称（如present-value）、已注册的专有属性名称或数值解析属性标识符
+func ParsePropertyIdentifier(text string) (PropertyIdentifier, error) {
+	for p, name := range propertyIdentifierNames {
+		if strings.EqualFold(name, text) {
+			return p, nil
+		}
+	}
+	for p, prop := range proprietaryProperties {
+		if strings.EqualFold(prop.Name, text) {
+			return p, nil
+		}
+	}
+	number, err := parseIdentifierNumber(text, "property-", "proprietary-")
+	if err != nil || number >= 1<<22 {
+		return 0, fmt.Errorf("未知的属性: %s", text)
+	}
+	return PropertyIdentifier(number), nil
+}
+
+// parseIdentifierNumber 解析数值形式的标识符，可以带String返回的前缀
+func parseIdentifierNumber(text string, prefixes ...string) (uint64, error) {
+	for _, prefix := range prefixes {
+		text = strings.TrimPrefix(text, prefix)
+	}
+	return strconv.ParseUint(text, 10, 32)
+}
@@ -0,0 +1,115 @@
+package model
+
+import "testing"
+
+func TestObjectTypeValues(t *testing.T) {
+	// 抽查ASHRAE 135第21章BACnetObjectType的取值
+	for _, tt := range []struct {
+		objType ObjectType
+		value   uint16
+		name    string
+	}{
+		{ObjectTypeAnalogInput, 0, "analog-input"},
+		{ObjectTypeDevice, 8, "device"},
+		{ObjectTypeLoop, 12, "loop"},
+		{ObjectTypeTrendLog, 20, "trend-log"},
+		{ObjectTypeAccumulator, 23, "accumulator"},
+		{ObjectTypeGlobalGroup, 26, "global-group"},
+		{ObjectTypeCharacterStringValue, 40, "characterstring-value"},
+		{ObjectTypeDateTimeValue, 44, "datetime-value"},
+		{ObjectTypeNetworkPort, 56, "network-port"},
+	} {
+		if uint16(tt.objType) != tt.value || tt.objType.String() != tt.name {
+			t.Errorf("%s = %d, want %s = %d", tt.objType, uint16(tt.objType), tt.name, tt.value)
+		}
+	}
+}
+
+func TestPropertyIdentifierValues(t *testing.T) {
+	// 抽查ASHRAE 135第21章BACnetPropertyIdentifier的取值
+	for _, tt := range []struct {
+		prop  PropertyIdentifier
+		value uint32
+		name  string
+	}{
+		{PropertyIdentifierDescription, 28, "description"},
+		{PropertyIdentifierEventState, 36, "event-state"},
+		{PropertyIdentifierObjectIdentifier, 75, "object-identifier"},
+		{PropertyIdentifierObjectName, 77, "object-name"},
+		{PropertyIdentifierPresentValue, 85, "present-value"},
+		{PropertyIdentifierPriorityArray, 87, "priority-array"},
+		{PropertyIdentifierStatusFlags, 111, "status-flags"},
+		{PropertyIdentifierUnits, 117, "units"},
+		{PropertyIdentifierLogBuffer, 131, "log-buffer"},
+		{PropertyIdentifierPropertyList, 371, "property-list"},
+	} {
+		if uint32(tt.prop) != tt.value || tt.prop.String() != tt.name {
+			t.Errorf("%s = %d, want %s = %d", tt.prop, uint32(tt.prop), tt.name, tt.value)
+		}
+	}
+}
+
+func TestIdentifierNamesRoundTrip(t *testing.T) {
+	// 名称表中的名称唯一，并能解析回同一个取值
+	seen := map[string]bool{}
+	for objType, name := range objectTypeNames {
+		if seen[name] {
+			t.Errorf("duplicate object type name %q", name)
+		}
+		seen[name] = true
+		if parsed, err := ParseObjectType(name); err != nil || parsed != objType {
+			t.Errorf("ParseObjectType(%q) = %d, %v, want %d", name, parsed, err, objType)
+		}
+	}
+	seen = map[string]bool{}
+	for prop, name := range propertyIdentifierNames {
+		if seen[name] {
+			t.Errorf("duplicate property name %q", name)
+		}
+		seen[name] = true
+		if parsed, err := ParsePropertyIdentifier(name); err != nil || parsed != prop {
+			t.Errorf("ParsePropertyIdentifier(%q) = %d, %v, want %d", name, parsed, err, prop)
+		}
+	}
+}
+
+func TestParseIdentifierNumbers(t *testing.T) {
+	if got := ObjectType(200).String(); got != "proprietary-200" {
+		t.Errorf("ObjectType(200).String() = %q", got)
+	}
+	if got, err := ParseObjectType("proprietary-200"); err != nil || got != 200 {
+		t.Errorf("ParseObjectType(proprietary-200) = %d, %v", got, err)
+	}
+	if _, err := ParseObjectType("1024"); err == nil {
+		t.Error("ParseObjectType(1024) succeeded")
+	}
+	if got, err := ParsePropertyIdentifier("property-18"); err != nil || got != 18 {
+		t.Errorf("ParsePropertyIdentifier(property-18) = %d, %v", got, err)
+	}
+	if _, err := ParsePropertyIdentifier("4194304"); err == nil {
+		t.Error("ParsePropertyIdentifier(1<<22) succeeded")
+	}
+
+	oid, err := ParseObjectIdentifier("analog-input:7")
+	if err != nil || oid != (ObjectIdentifier{Type: ObjectTypeAnalogInput, Instance: 7}) {
+		t.Errorf("ParseObjectIdentifier(analog-input:7) = %v, %v", oid, err)
+	}
+	if _, err := ParseObjectIdentifier("device:4194304"); err == nil {
+		t.Error("ParseObjectIdentifier with 23-bit instance succeeded")
+	}
+}
+
+func TestLegacyIdentifiers(t *testing.T) {
+	if got, ok := ObjectTypeFromLegacy(1); !ok || got != ObjectTypeAnalogInput {
+		t.Errorf("ObjectTypeFromLegacy(1) = %v, %v", got, ok)
+	}
+	if _, ok := ObjectTypeFromLegacy(0); ok {
+		t.Error("ObjectTypeFromLegacy(0) succeeded")
+	}
+	if got, ok := PropertyIdentifierFromLegacy(4); !ok || got != PropertyIdentifierPresentValue {
+		t.Errorf("PropertyIdentifierFromLegacy(4) = %v, %v", got, ok)
+	}
+	if _, ok := PropertyIdentifierFromLegacy(uint32(len(legacyPropertyIdentifiers))); ok {
+		t.Error("PropertyIdentifierFromLegacy past the table succeeded")
+	}
+}
@@ -0,0 +1,201 @@
+package model
+
+// 兼容旧版本的属性名称，新代码应使用对应的标准名称
+const (
+	// Deprecated: 使用PropertyIdentifierVendorName
+	PropertyIdentifierManufacturerName = PropertyIdentifierVendorName
+	// Deprecated: 使用PropertyIdentifierAPDUTimeout
+	PropertyIdentifierApdutimeout = PropertyIdentifierAPDUTimeout
+	// Deprecated: 使用PropertyIdentifierNumberOfAPDURetries
+	PropertyIdentifierNumberOfApduRetries = PropertyIdentifierNumberOfAPDURetries
+	// Deprecated: 使用PropertyIdentifierAckedTransitions
+	PropertyIdentifierAcknowledgedTransitions = PropertyIdentifierAckedTransitions
+)
+
+// 旧版本遗留的非标准属性，使用厂商专有编号
+const (
+	PropertyIdentifierFileOpeningTag        PropertyIdentifier = 512
+	PropertyIdentifierFileClosingTag        PropertyIdentifier = 513
+	PropertyIdentifierTimeOfStateChange     PropertyIdentifier = 514
+	PropertyIdentifierTimeOfLastStateChange PropertyIdentifier = 515
+)
+
+// legacyObjectTypes 旧版本对象类型编号（从1开始顺序编号）到标准编号的映射
+var legacyObjectTypes = []ObjectType{
+	1:  ObjectTypeAnalogInput,
+	2:  ObjectTypeAnalogOutput,
+	3:  ObjectTypeAnalogValue,
+	4:  ObjectTypeBinaryInput,
+	5:  ObjectTypeBinaryOutput,
+	6:  ObjectTypeBinaryValue,
+	7:  ObjectTypeDevice,
+	8:  ObjectTypeTrendLog,
+	9:  ObjectTypeSchedule,
+	10: ObjectTypeMultiStateInput,
+	11: ObjectTypeMultiStateOutput,
+	12: ObjectTypeFile,
+	13: ObjectTypeNotificationClass,
+	14: ObjectTypeEventLog,
+	15: ObjectTypeEventEnrollment,
+	16: ObjectTypeTrendLogMultiple,
+	17: ObjectTypeCalendar,
+	18: ObjectTypeLoop,
+	19: ObjectTypeProgram,
+	20: ObjectTypeAccumulator,
+	21: ObjectTypePulseConverter,
+	22: ObjectTypeAveraging,
+	23: ObjectTypeMultiStateValue,
+	24: ObjectTypeCharacterStringValue,
+	25: ObjectTypeIntegerValue,
+	26: ObjectTypePositiveIntegerValue,
+	27: ObjectTypeLargeAnalogValue,
+	28: ObjectTypeDateTimeValue,
+	29: ObjectTypeOctetStringValue,
+	30: ObjectTypeGlobalGroup,
+	31: ObjectTypeLifeSafetyPoint,
+	32: ObjectTypeLifeSafetyZone,
+}
+
+// legacyPropertyIdentifiers 旧版本属性编号（从1开始顺序编号）到当前编号的映射
+var legacyPropertyIdentifiers = []PropertyIdentifier{
+	1:   PropertyIdentifierObjectIdentifier,
+	2:   PropertyIdentifierObjectType,
+	3:   PropertyIdentifierObjectName,
+	4:   PropertyIdentifierPresentValue,
+	5:   PropertyIdentifierDescription,
+	6:   PropertyIdentifierDeviceType,
+	7:   PropertyIdentifierManufacturerName,
+	8:   PropertyIdentifierModelName,
+	9:   PropertyIdentifierFirmwareRevision,
+	10:  PropertyIdentifierApplicationSoftwareVersion,
+	11:  PropertyIdentifierLocation,
+	12:  PropertyIdentifierNumberOfApduRetries,
+	13:  PropertyIdentifierSegmentationSupported,
+	14:  PropertyIdentifierApdutimeout,
+	15:  PropertyIdentifierEventState,
+	16:  PropertyIdentifierOutOfService,
+	17:  PropertyIdentifierNotificationClass,
+	18:  PropertyIdentifierAlarmValue,
+	19:  PropertyIdentifierAcknowledgedTransitions,
+	20:  PropertyIdentifierNotifyType,
+	21:  PropertyIdentifierEventDetectionEnable,
+	22:  PropertyIdentifierAckedTransitions,
+	23:  PropertyIdentifierEventTimeStamps,
+	24:  PropertyIdentifierTimeOfStateChange,
+	25:  PropertyIdentifierTimeOfLastStateChange,
+	26:  PropertyIdentifierStatusFlags,
+	27:  PropertyIdentifierFileSize,
+	28:  PropertyIdentifierFileAccessMethod,
+	29:  PropertyIdentifierFileOpeningTag,
+	30:  PropertyIdentifierFileClosingTag,
+	31:  PropertyIdentifierPriority,
+	32:  PropertyIdentifierLogEnable,
+	33:  PropertyIdentifierStopWhenFull,
+	34:  PropertyIdentifierBufferSize,
+	35:  PropertyIdentifierRecordCount,
+	36:  PropertyIdentifierTotalRecordCount,
+	37:  PropertyIdentifierLogBuffer,
+	38:  PropertyIdentifierLogDeviceObjectProperty,
+	39:  PropertyIdentifierLogInterval,
+	40:  PropertyIdentifierLoggingType,
+	41:  PropertyIdentifierStartTime,
+	42:  PropertyIdentifierStopTime,
+	43:  PropertyIdentifierRecordsSinceNotification,
+	44:  PropertyIdentifierNotificationThreshold,
+	45:  PropertyIdentifierLastNotifyRecord,
+	46:  PropertyIdentifierTrigger,
+	47:  PropertyIdentifierDateList,
+	48:  PropertyIdentifierEffectivePeriod,
+	49:  PropertyIdentifierWeeklySchedule,
+	50:  PropertyIdentifierExceptionSchedule,
+	51:  PropertyIdentifierScheduleDefault,
+	52:  PropertyIdentifierListOfObjectPropertyReferences,
+	53:  PropertyIdentifierPriorityForWriting,
+	54:  PropertyIdentifierControlledVariableReference,
+	55:  PropertyIdentifierControlledVariableValue,
+	56:  PropertyIdentifierSetpointReference,
+	57:  PropertyIdentifierSetpoint,
+	58:  PropertyIdentifierManipulatedVariableReference,
+	59:  PropertyIdentifierAction,
+	60:  PropertyIdentifierProportionalConstant,
+	61:  PropertyIdentifierIntegralConstant,
+	62:  PropertyIdentifierDerivativeConstant,
+	63:  PropertyIdentifierBias,
+	64:  PropertyIdentifierMaximumOutput,
+	65:  PropertyIdentifierMinimumOutput,
+	66:  PropertyIdentifierUpdateInterval,
+	67:  PropertyIdentifierProgramState,
+	68:  PropertyIdentifierProgramChange,
+	69:  PropertyIdentifierReasonForHalt,
+	70:  PropertyIdentifierDescriptionOfHalt,
+	71:  PropertyIdentifierProgramLocation,
+	72:  PropertyIdentifierInstanceOf,
+	73:  PropertyIdentifierScale,
+	74:  PropertyIdentifierPrescale,
+	75:  PropertyIdentifierMaxPresValue,
+	76:  PropertyIdentifierValueChangeTime,
+	77:  PropertyIdentifierValueBeforeChange,
+	78:  PropertyIdentifierValueSet,
+	79:  PropertyIdentifierPulseRate,
+	80:  PropertyIdentifierHighLimit,
+	81:  PropertyIdentifierLowLimit,
+	82:  PropertyIdentifierLimitMonitoringInterval,
+	83:  PropertyIdentifierInputReference,
+	84:  PropertyIdentifierScaleFactor,
+	85:  PropertyIdentifierAdjustValue,
+	86:  PropertyIdentifierCount,
+	87:  PropertyIdentifierUpdateTime,
+	88:  PropertyIdentifierCountChangeTime,
+	89:  PropertyIdentifierCountBeforeChange,
+	90:  PropertyIdentifierObjectPropertyReference,
+	91:  PropertyIdentifierWindowInterval,
+	92:  PropertyIdentifierWindowSamples,
+	93:  PropertyIdentifierAttemptedSamples,
+	94:  PropertyIdentifierValidSamples,
+	95:  PropertyIdentifierMinimumValue,
+	96:  PropertyIdentifierMinimumValueTimestamp,
+	97:  PropertyIdentifierMaximumValue,
+	98:  PropertyIdentifierMaximumValueTimestamp,
+	99:  PropertyIdentifierAverageValue,
+	100: PropertyIdentifierVarianceValue,
+	101: PropertyIdentifierNumberOfStates,
+	102: PropertyIdentifierStateText,
+	103: PropertyIdentifierGroupMembers,
+	104: PropertyIdentifierGroupMemberNames,
+	105: PropertyIdentifierMemberStatusFlags,
+	106: PropertyIdentifierRequestedUpdateInterval,
+	107: PropertyIdentifierCOVUPeriod,
+	108: PropertyIdentifierMode,
+	109: PropertyIdentifierAcceptedModes,
+	110: PropertyIdentifierOperationExpected,
+	111: PropertyIdentifierSilenced,
+	112: PropertyIdentifierTrackingValue,
+	113: PropertyIdentifierZoneMembers,
+	114: PropertyIdentifierMemberOf,
+	115: PropertyIdentifierPolarity,
+	116: PropertyIdentifierActiveText,
+	117: PropertyIdentifierInactiveText,
+	118: PropertyIdentifierMinimumOnTime,
+	119: PropertyIdentifierMinimumOffTime,
+	120: PropertyIdentifierUnits,
+	121: PropertyIdentifierMinPresValue,
+	122: PropertyIdentifierResolution,
+	123: PropertyIdentifierPriorityArray,
+	124: PropertyIdentifierRelinquishDefault,
+}
+
+// ObjectTypeFromLegacy 将旧版本保存的对象类型编号转换为标准编号
+func ObjectTypeFromLegacy(value uint8) (ObjectType, bool) {
+	if value == 0 || int(value) >= len(legacyObjectTypes) {
+		return 0, false
+	}
+	return legacyObjectTypes[value], true
+}
+
+// PropertyIdentifierFromLegacy 将旧版本保存的属性编号转换为当前编号
+func PropertyIdentifierFromLegacy(value uint32) (PropertyIdentifier, bool) {
+	if value == 0 || value >= uint32(len(legacyPropertyIdentifiers)) {
+		return 0, false
+	}
+	return legacyPropertyIdentifiers[value], true
+}
@@ -0,0 +1,298 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// LifeSafetyState 生命安全状态（BACnetLifeSafetyState）
+type LifeSafetyState uint8
+
+const (
+	LifeSafetyStateQuiet LifeSafetyState = iota
+	LifeSafetyStatePreAlarm
+	LifeSafetyStateAlarm
+	LifeSafetyStateFault
+	LifeSafetyStateFaultPreAlarm
+	LifeSafetyStateFaultAlarm
+	LifeSafetyStateNotReady
+	LifeSafetyStateActive
+	LifeSafetyStateTamper
+	LifeSafetyStateTestAlarm
+	LifeSafetyStateTestActive
+	LifeSafetyStateTestFault
+	LifeSafetyStateTestFaultAlarm
+	LifeSafetyStateHoldup
+	LifeSafetyStateDuress
+	LifeSafetyStateTamperAlarm
+	LifeSafetyStateAbnormal
+	LifeSafetyStateEmergencyPower
+	LifeSafetyStateDelayed
+	LifeSafetyStateBlocked
+	LifeSafetyStateLocalAlarm
+	LifeSafetyStateGeneralAlarm
+	LifeSafetyStateSupervisory
+	LifeSafetyStateTestSupervisory
+)
+
+// LifeSafetyMode 生命安全工作模式（BACnetLifeSafetyMode）
+type LifeSafetyMode uint8
+
+const (
+	LifeSafetyModeOff LifeSafetyMode = iota
+	LifeSafetyModeOn
+	LifeSafetyModeTest
+	LifeSafetyModeManned
+	LifeSafetyModeUnmanned
+	LifeSafetyModeArmed
+	LifeSafetyModeDisarmed
+	LifeSafetyModePrearmed
+	LifeSafetyModeSlow
+	LifeSafetyModeFast
+	LifeSafetyModeDisconnected
+	LifeSafetyModeEnabled
+	LifeSafetyModeDisabled
+	LifeSafetyModeAutomaticReleaseDisabled
+	LifeSafetyModeDefault
+)
+
+// LifeSafetyOperation 生命安全操作（BACnetLifeSafetyOperation）
+type LifeSafetyOperation uint8
+
+const (
+	LifeSafetyOperationNone LifeSafetyOperation = iota
+	LifeSafetyOperationSilence
+	LifeSafetyOperationSilenceAudible
+	LifeSafetyOperationSilenceVisual
+	LifeSafetyOperationReset
+	LifeSafetyOperationResetAlarm
+	LifeSafetyOperationResetFault
+	LifeSafetyOperationUnsilence
+	LifeSafetyOperationUnsilenceAudible
+	LifeSafetyOperationUnsilenceVisual
+)
+
+// SilencedState 消音状态（BACnetSilencedState）
+type SilencedState uint8
+
+const (
+	SilencedStateUnsilenced SilencedState = iota
+	SilencedStateAudibleSilenced
+	SilencedStateVisibleSilenced
+	SilencedStateAllSilenced
+)
+
+// LifeSafety 表示BACnet生命安全点和生命安全区对象
+// Tracking_Value跟随现场状态；报警和故障状态锁存到Present_Value，直到收到复位操作
+type LifeSafety struct {
+	*BACnetObject
+	Mode              LifeSafetyMode
+	AcceptedModes     []LifeSafetyMode
+	OperationExpected LifeSafetyOperation
+	Silenced          SilencedState
+	TrackingValue     LifeSafetyState
+	ZoneMembers       []ObjectIdentifier // 仅生命安全区：区内成员
+	MemberOf          []ObjectIdentifier // 所属的生命安全区
+}
+
+// NewLifeSafetyPoint 创建生命安全点对象
+func NewLifeSafetyPoint(instance uint32, name string) *LifeSafety {
+	return newLifeSafety(ObjectTypeLifeSafetyPoint, instance, name)
+}
+
+// NewLifeSafetyZone 创建生命安全区对象
+func NewLifeSafetyZone(instance uint32, name string) *LifeSafety {
+	return newLifeSafety(ObjectTypeLifeSafetyZone, instance, name)
+}
+
+// newLifeSafety 创建生命安全对象，初始为ON模式、安静状态
+func newLifeSafety(objType ObjectType, instance uint32, name string) *LifeSafety {
+	lifeSafety := &LifeSafety{
+		BACnetObject:  NewBACnetObject(objType, instance, name),
+		Mode:          LifeSafetyModeOn,
+		AcceptedModes: []LifeSafetyMode{LifeSafetyModeOff, LifeSafetyModeOn, LifeSafetyModeTest},
+		ZoneMembers:   []ObjectIdentifier{},
+		MemberOf:      []ObjectIdentifier{},
+	}
+	lifeSafety.Properties[PropertyIdentifierPresentValue] = LifeSafetyStateQuiet
+	return lifeSafety
+}
+
+// State 返回当前Present_Value
+func (l *LifeSafety) State() LifeSafetyState {
+	state, _ := l.Properties[PropertyIdentifierPresentValue].(LifeSafetyState)
+	return state
+}
+
+// SetTrackingValue 更新现场状态；报警和故障状态锁存，回到安静时Present_Value保持到复位
+func (l *LifeSafety) SetTrackingValue(state LifeSafetyState) {
+	l.TrackingValue = state
+	if l.Mode == LifeSafetyModeOff || l.Mode == LifeSafetyModeDisabled {
+		return
+	}
+	if state == LifeSafetyStateQuiet && isLatchingState(l.State()) {
+		l.OperationExpected = LifeSafetyOperationReset
+		return
+	}
+	if state != l.State() && (state == LifeSafetyStateQuiet || lifeSafetySeverity(state) >= lifeSafetySeverity(l.State())) {
+		l.setState(state)
+	}
+}
+
+// setState 更新Present_Value并产生对应的事件
+func (l *LifeSafety) setState(state LifeSafetyState) {
+	l.BACnetObject.WriteProperty(PropertyIdentifierPresentValue, state)
+	switch {
+	case isFaultState(state):
+		l.OperationExpected = LifeSafetyOperationResetFault
+		l.GenerateEvent(EventStateFault, fmt.Sprintf("生命安全状态: %d", state))
+	case isLatchingState(state):
+		l.OperationExpected = LifeSafetyOperationSilence
+		l.Silenced = SilencedStateUnsilenced
+		l.GenerateEvent(EventStateOffNormal, fmt.Sprintf("生命安全状态: %d", state))
+	default:
+		l.OperationExpected = LifeSafetyOperationNone
+		if l.GetEventState() != EventStateNormal {
+			l.GenerateEvent(EventStateNormal, "生命安全状态恢复")
+		}
+	}
+}
+
+// Operate 执行生命安全操作（消音、复位等）
+func (l *LifeSafety) Operate(operation LifeSafetyOperation) error {
+	switch operation {
+	case LifeSafetyOperationNone:
+	case LifeSafetyOperationSilence:
+		l.Silenced = SilencedStateAllSilenced
+	case LifeSafetyOperationSilenceAudible:
+		l.Silenced |= SilencedStateAudibleSilenced
+	case LifeSafetyOperationSilenceVisual:
+		l.Silenced |= SilencedStateVisibleSilenced
+	case LifeSafetyOperationUnsilence:
+		l.Silenced = SilencedStateUnsilenced
+	case LifeSafetyOperationUnsilenceAudible:
+		l.Silenced &^= SilencedStateAudibleSilenced
+	case LifeSafetyOperationUnsilenceVisual:
+		l.Silenced &^= SilencedStateVisibleSilenced
+	case LifeSafetyOperationReset, LifeSafetyOperationResetAlarm, LifeSafetyOperationResetFault:
+		state := l.State()
+		if operation == LifeSafetyOperationResetAlarm && isFaultState(state) ||
+			operation == LifeSafetyOperationResetFault && !isFaultState(state) {
+			return nil
+		}
+		// 现场仍处于报警/故障时复位无效
+		if l.TrackingValue != LifeSafetyStateQuiet {
+			return nil
+		}
+		l.Silenced = SilencedStateUnsilenced
+		l.setState(LifeSafetyStateQuiet)
+	default:
+		return fmt.Errorf("未知的生命安全操作: %d", operation)
+	}
+	if l.OperationExpected == LifeSafetyOperationSilence && l.Silenced == SilencedStateAllSilenced {
+		l.OperationExpected = LifeSafetyOperationReset
+	}
+	logger().Debug("生命安全对象执行操作", "object", l.Name, "operation", operation)
+	return nil
+}
+
+// Execute 生命安全区根据成员的最严重状态更新Tracking_Value
+func (l *LifeSafety) Execute(device *Device, now time.Time) {
+	if l.GetObjectType() != ObjectTypeLifeSafetyZone || len(l.ZoneMembers) == 0 {
+		return
+	}
+	worst := LifeSafetyStateQuiet
+	for _, member := range l.ZoneMembers {
+		if point, ok := device.FindObject(member).(*LifeSafety); ok && lifeSafetySeverity(point.State()) > lifeSafetySeverity(worst) {
+			worst = point.State()
+		}
+	}
+	if worst != l.TrackingValue {
+		l.SetTrackingValue(worst)
+	}
+}
+
+// ReadProperty 读取生命安全对象属性
+func (l *LifeSafety) ReadProperty(prop PropertyIdentifier) (interface{}, error) {
+	switch prop {
+	case PropertyIdentifierMode:
+		return l.Mode, nil
+	case PropertyIdentifierAcceptedModes:
+		return append([]LifeSafetyMode{}, l.AcceptedModes...), nil
+	case PropertyIdentifierOperationExpected:
+		return l.OperationExpected, nil
+	case PropertyIdentifierSilenced:
+		return l.Silenced, nil
+	case PropertyIdentifierTrackingValue:
+		return l.TrackingValue, nil
+	case PropertyIdentifierZoneMembers:
+		return append([]ObjectIdentifier{}, l.ZoneMembers...), nil
+	case PropertyIdentifierMemberOf:
+		return append([]ObjectIdentifier{}, l.MemberOf...), nil
+	}
+	return l.BACnetObject.ReadProperty(prop)
+}
+
+// WriteProperty 写入生命安全对象属性，Mode必须是Accepted_Modes之一
+func (l *LifeSafety) WriteProperty(prop PropertyIdentifier, value interface{}) error {
+	switch prop {
+	case PropertyIdentifierMode:
+		mode, ok := toUint32(value)
+		if !ok {
+			return fmt.Errorf("Mode类型无效")
+		}
+		for _, accepted := range l.AcceptedModes {
+			if uint32(accepted) == mode {
+				l.Mode = accepted
+				return nil
+			}
+		}
+		return ErrValueOutOfRange
+	case PropertyIdentifierZoneMembers:
+		members, ok := value.([]ObjectIdentifier)
+		if !ok {
+			return fmt.Errorf("Zone_Members类型无效")
+		}
+		l.ZoneMembers = append([]ObjectIdentifier{}, members...)
+		return nil
+	case PropertyIdentifierPresentValue, PropertyIdentifierTrackingValue, PropertyIdentifierSilenced,
+		PropertyIdentifierOperationExpected, PropertyIdentifierAcceptedModes:
+		return fmt.Errorf("只读属性")
+	}
+	return l.BACnetObject.WriteProperty(prop, value)
+}
+
+// isFaultState 判断是否为故障类状态
+func isFaultState(state LifeSafetyState) bool {
+	switch state {
+	case LifeSafetyStateFault, LifeSafetyStateFaultPreAlarm, LifeSafetyStateFaultAlarm, LifeSafetyStateTestFault, LifeSafetyStateTestFaultAlarm:
+		return true
+	}
+	return false
+}
+
+// isLatchingState 判断是否为需要复位的报警或故障状态
+func isLatchingState(state LifeSafetyState) bool {
+	switch state {
+	case LifeSafetyStateQuiet, LifeSafetyStateNotReady, LifeSafetyStateActive, LifeSafetyStateTestActive,
+		LifeSafetyStateDelayed, LifeSafetyStateBlocked:
+		return false
+	}
+	return true
+}
+
+// lifeSafetySeverity 返回状态的严重程度，用于比较和区域汇总
+func lifeSafetySeverity(state LifeSafetyState) int {
+	switch state {
+	case LifeSafetyStateQuiet:
+		return 0
+	case LifeSafetyStateAlarm, LifeSafetyStateFaultAlarm, LifeSafetyStateGeneralAlarm, LifeSafetyStateLocalAlarm,
+		LifeSafetyStateHoldup, LifeSafetyStateDuress, LifeSafetyStateTamperAlarm:
+		return 4
+	case LifeSafetyStatePreAlarm, LifeSafetyStateFaultPreAlarm, LifeSafetyStateSupervisory:
+		return 3
+	case LifeSafetyStateFault, LifeSafetyStateTamper, LifeSafetyStateAbnormal, LifeSafetyStateEmergencyPower:
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,110 @@
+package model
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLifeSafetySilence(t *testing.T) {
+	point := NewLifeSafetyPoint(1, "Smoke Detector")
+	point.SetTrackingValue(LifeSafetyStateAlarm)
+	if point.OperationExpected != LifeSafetyOperationSilence || point.Silenced != SilencedStateUnsilenced {
+		t.Fatalf("after alarm: Operation_Expected = %d, Silenced = %d", point.OperationExpected, point.Silenced)
+	}
+
+	// 分别消音声响和灯光，全部消音后等待复位
+	steps := []struct {
+		operation LifeSafetyOperation
+		silenced  SilencedState
+		expected  LifeSafetyOperation
+	}{
+		{LifeSafetyOperationSilenceAudible, SilencedStateAudibleSilenced, LifeSafetyOperationSilence},
+		{LifeSafetyOperationSilenceVisual, SilencedStateAllSilenced, LifeSafetyOperationReset},
+		{LifeSafetyOperationUnsilenceAudible, SilencedStateVisibleSilenced, LifeSafetyOperationReset},
+		{LifeSafetyOperationUnsilence, SilencedStateUnsilenced, LifeSafetyOperationReset},
+		{LifeSafetyOperationSilence, SilencedStateAllSilenced, LifeSafetyOperationReset},
+		{LifeSafetyOperationUnsilenceVisual, SilencedStateAudibleSilenced, LifeSafetyOperationReset},
+	}
+	for _, step := range steps {
+		if err := point.Operate(step.operation); err != nil {
+			t.Fatalf("Operate(%d) = %v", step.operation, err)
+		}
+		if point.Silenced != step.silenced || point.OperationExpected != step.expected {
+			t.Errorf("after operation %d: Silenced = %d, Operation_Expected = %d, want %d and %d",
+				step.operation, point.Silenced, point.OperationExpected, step.silenced, step.expected)
+		}
+	}
+	if silenced, _ := point.ReadProperty(PropertyIdentifierSilenced); silenced != SilencedStateAudibleSilenced {
+		t.Errorf("Silenced = %v", silenced)
+	}
+
+	// 复位清除消音状态
+	point.SetTrackingValue(LifeSafetyStateQuiet)
+	if err := point.Operate(LifeSafetyOperationReset); err != nil || point.State() != LifeSafetyStateQuiet || point.Silenced != SilencedStateUnsilenced {
+		t.Errorf("after reset: state = %d, Silenced = %d, err = %v", point.State(), point.Silenced, err)
+	}
+}
+
+func TestLifeSafetyOperationExpected(t *testing.T) {
+	point := NewLifeSafetyPoint(1, "Smoke Detector")
+	expected := func() LifeSafetyOperation {
+		value, _ := point.ReadProperty(PropertyIdentifierOperationExpected)
+		return value.(LifeSafetyOperation)
+	}
+
+	// 故障等待故障复位，报警复位不作用于故障
+	point.SetTrackingValue(LifeSafetyStateFault)
+	if got := expected(); got != LifeSafetyOperationResetFault {
+		t.Errorf("Operation_Expected in fault = %d, want reset-fault", got)
+	}
+	point.SetTrackingValue(LifeSafetyStateQuiet)
+	point.Operate(LifeSafetyOperationResetAlarm)
+	if point.State() != LifeSafetyStateFault {
+		t.Errorf("reset-alarm cleared a fault: state = %d", point.State())
+	}
+	point.Operate(LifeSafetyOperationResetFault)
+	if point.State() != LifeSafetyStateQuiet || expected() != LifeSafetyOperationNone {
+		t.Errorf("after reset-fault: state = %d, Operation_Expected = %d", point.State(), expected())
+	}
+
+	// 现场仍在报警时复位无效，回到安静后等待复位
+	point.SetTrackingValue(LifeSafetyStateAlarm)
+	point.Operate(LifeSafetyOperationReset)
+	if point.State() != LifeSafetyStateAlarm {
+		t.Errorf("reset while tracking alarm: state = %d", point.State())
+	}
+	point.SetTrackingValue(LifeSafetyStateQuiet)
+	if got := expected(); got != LifeSafetyOperationReset {
+		t.Errorf("Operation_Expected after alarm cleared = %d, want reset", got)
+	}
+
+	// 未知操作被拒绝，Operation_Expected等状态属性只读
+	if err := point.Operate(LifeSafetyOperationUnsilenceVisual + 1); err == nil {
+		t.Error("Operate(unknown) succeeded")
+	}
+	for _, prop := range []PropertyIdentifier{PropertyIdentifierOperationExpected, PropertyIdentifierSilenced, PropertyIdentifierTrackingValue} {
+		if err := point.WriteProperty(prop, uint32(0)); err == nil {
+			t.Errorf("WriteProperty(%d) succeeded", prop)
+		}
+	}
+}
+
+func TestLifeSafetyMode(t *testing.T) {
+	point := NewLifeSafetyPoint(1, "Smoke Detector")
+	if err := point.WriteProperty(PropertyIdentifierMode, LifeSafetyModeTest); err != nil || point.Mode != LifeSafetyModeTest {
+		t.Errorf("write accepted mode: Mode = %d, err = %v", point.Mode, err)
+	}
+	if err := point.WriteProperty(PropertyIdentifierMode, LifeSafetyModeArmed); !errors.Is(err, ErrValueOutOfRange) || point.Mode != LifeSafetyModeTest {
+		t.Errorf("write mode not in Accepted_Modes: Mode = %d, err = %v", point.Mode, err)
+	}
+	if err := point.WriteProperty(PropertyIdentifierMode, "on"); err == nil {
+		t.Error("write mode as string succeeded")
+	}
+
+	// 关闭时不跟随现场状态
+	point.WriteProperty(PropertyIdentifierMode, LifeSafetyModeOff)
+	point.SetTrackingValue(LifeSafetyStateAlarm)
+	if point.State() != LifeSafetyStateQuiet || point.TrackingValue != LifeSafetyStateAlarm {
+		t.Errorf("mode off: state = %d, Tracking_Value = %d", point.State(), point.TrackingValue)
+	}
+}
@@ -0,0 +1,21 @@
+package model
+
+import (
+	"log/slog"
+	"sync/atomic"
+)
+
+var defaultLogger atomic.Pointer[slog.Logger]
+
+// SetLogger 设置对象模型记录日志使用的日志，为nil时使用slog.Default()
+func SetLogger(logger *slog.Logger) {
+	defaultLogger.Store(logger)
+}
+
+// logger 返回对象模型使用的日志
+func logger() *slog.Logger {
+	if l := defaultLogger.Load(); l != nil {
+		return l
+	}
+	return slog.Default()
+}
@@ -0,0 +1,221 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// LogStatus 日志状态位（BACnetLogStatus）
+type LogStatus uint8
+
+const (
+	LogStatusLogDisabled LogStatus = 1 << iota
+	LogStatusBufferPurged
+	LogStatusLogInterrupted
+)
+
+// LogFailure 表示读取被记录属性失败
+type LogFailure struct {
+	Err error
+}
+
+// LogRecord 表示日志缓冲区中的一条记录
+type LogRecord struct {
+	SequenceNumber uint32      // 记录序列号（等于记录写入时的Total_Record_Count）
+	Timestamp      time.Time   // 记录时间
+	Datum          interface{} // 记录内容：LogStatus、LogFailure、EventNotification、AuditNotification或属性值
+	StatusFlags    *uint8      // 可选：被记录对象的状态标志
+}
+
+// LogBuffer 实现日志类对象共用的记录缓冲区
+type LogBuffer struct {
+	Records          []LogRecord // 缓冲区记录，按时间先后排列
+	BufferSize       uint32      // 缓冲区最大记录数
+	StopWhenFull     bool        // 缓冲区满时停止记录（否则覆盖最旧记录）
+	TotalRecordCount uint32      // 累计写入的记录总数
+}
+
+// NewLogBuffer 创建一个指定大小的日志缓冲区
+func NewLogBuffer(bufferSize uint32) *LogBuffer {
+	return &LogBuffer{
+		Records:    []LogRecord{},
+		BufferSize: bufferSize,
+	}
+}
+
+// Append 追加一条记录，缓冲区已满且StopWhenFull为true时返回false
+func (b *LogBuffer) Append(timestamp time.Time, datum interface{}) bool {
+	if b.BufferSize == 0 {
+		return false
+	}
+	if uint32(len(b.Records)) >= b.BufferSize {
+		if b.StopWhenFull {
+			return false
+		}
+		// 环形覆盖：丢弃最旧的记录
+		b.Records = b.Records[uint32(len(b.Records))-b.BufferSize+1:]
+	}
+
+	// Total_Record_Count达到最大值后从1重新开始
+	b.TotalRecordCount++
+	if b.TotalRecordCount == 0 {
+		b.TotalRecordCount = 1
+	}
+	b.Records = append(b.Records, LogRecord{
+		SequenceNumber: b.TotalRecordCount,
+		Timestamp:      timestamp,
+		Datum:          datum,
+	})
+	return true
+}
+
+// Full 判断缓冲区是否已满
+func (b *LogBuffer) Full() bool {
+	return uint32(len(b.Records)) >= b.BufferSize
+}
+
+// Count 返回当前缓冲区中的记录数（Record_Count）
+func (b *LogBuffer) Count() uint32 {
+	return uint32(len(b.Records))
+}
+
+// Clear 清空缓冲区（写Record_Count为0时使用）
+func (b *LogBuffer) Clear() {
+	b.Records = []LogRecord{}
+}
+
+// SetBufferSize 修改缓冲区大小，超出部分丢弃最旧的记录
+func (b *LogBuffer) SetBufferSize(size uint32) {
+	b.BufferSize = size
+	if uint32(len(b.Records)) > size {
+		b.Records = b.Records[uint32(len(b.Records))-size:]
+	}
+}
+
+// readBufferProperty 读取日志缓冲区相关属性，不是缓冲区属性时返回false
+func (b *LogBuffer) readBufferProperty(prop PropertyIdentifier) (interface{}, bool) {
+	switch prop {
+	case PropertyIdentifierBufferSize:
+		return b.BufferSize, true
+	case PropertyIdentifierStopWhenFull:
+		return b.StopWhenFull, true
+	case PropertyIdentifierRecordCount:
+		return b.Count(), true
+	case PropertyIdentifierTotalRecordCount:
+		return b.TotalRecordCount, true
+	}
+	return nil, false
+}
+
+// writeBufferProperty 写入日志缓冲区相关属性，清空时以now记录缓冲区清除，不是缓冲区属性时返回false
+func (b *LogBuffer) writeBufferProperty(prop PropertyIdentifier, value interface{}, now time.Time) (bool, error) {
+	switch prop {
+	case PropertyIdentifierRecordCount:
+		// 只允许写0以清空缓冲区
+		if count, ok := toUint32(value); !ok || count != 0 {
+			return true, fmt.Errorf("Record_Count只能写入0")
+		}
+		b.Clear()
+		b.Append(now, LogStatusBufferPurged)
+		return true, nil
+	case PropertyIdentifierBufferSize:
+		size, ok := toUint32(value)
+		if !ok {
+			return true, fmt.Errorf("Buffer_Size类型无效")
+		}
+		b.SetBufferSize(size)
+		return true, nil
+	case PropertyIdentifierStopWhenFull:
+		stop, ok := value.(bool)
+		if !ok {
+			return true, fmt.Errorf("Stop_When_Full类型无效")
+		}
+		b.StopWhenFull = stop
+		return true, nil
+	}
+	return false, nil
+}
+
+// logNotification 趋势类日志共用的buffer-ready通知状态
+type logNotification struct {
+	NotificationThreshold    uint32 // 达到该记录数时发送buffer-ready事件，0表示不通知
+	RecordsSinceNotification uint32
+	LastNotifyRecord         uint32
+}
+
+// recorded 记录数加一，达到通知阈值时发送buffer-ready事件
+func (n *logNotification) recorded(o *BACnetObject, log *LogBuffer, now time.Time) {
+	n.RecordsSinceNotification++
+	if n.NotificationThreshold == 0 || n.RecordsSinceNotification < n.NotificationThreshold {
+		return
+	}
+
+	current := log.TotalRecordCount
+	o.sendEventNotification(EventNotification{
+		EventObject:       o.Identifier,
+		TimeStamp:         now,
+		NotificationClass: o.GetNotificationClass(),
+		EventType:         EventTypeBufferReady,
+		NotifyType:        NotifyTypeEvent,
+		FromState:         EventStateNormal,
+		ToState:           EventStateNormal,
+		EventValues: BufferReadyEventValues{
+			BufferProperty: DeviceObjectPropertyReference{
+				ObjectIdentifier:   o.Identifier,
+				PropertyIdentifier: PropertyIdentifierLogBuffer,
+			},
+			PreviousNotification: n.LastNotifyRecord,
+			CurrentNotification:  current,
+		},
+	})
+	n.LastNotifyRecord = current
+	n.RecordsSinceNotification = 0
+}
+
+// readNotificationProperty 读取通知相关属性，不是通知属性时返回false
+func (n *logNotification) readNotificationProperty(prop PropertyIdentifier) (interface{}, bool) {
+	switch prop {
+	case PropertyIdentifierRecordsSinceNotification:
+		return n.RecordsSinceNotification, true
+	case PropertyIdentifierNotificationThreshold:
+		return n.NotificationThreshold, true
+	case PropertyIdentifierLastNotifyRecord:
+		return n.LastNotifyRecord, true
+	}
+	return nil, false
+}
+
+// writeNotificationThreshold 写入Notification_Threshold
+func (n *logNotification) writeNotificationThreshold(value interface{}) error {
+	threshold, ok := toUint32(value)
+	if !ok {
+		return fmt.Errorf("Notification_Threshold类型无效")
+	}
+	n.NotificationThreshold = threshold
+	return nil
+}
+
+// inLogWindow 判断当前时间是否处于Start_Time和Stop_Time之间，零值表示不限制
+func inLogWindow(start, stop, now time.Time) bool {
+	if !start.IsZero() && now.Before(start) {
+		return false
+	}
+	if !stop.IsZero() && now.After(stop) {
+		return false
+	}
+	return true
+}
+
+// stopIfFull 缓冲区在StopWhenFull模式下写满时禁用日志
+func stopIfFull(o *BACnetObject, log *LogBuffer) {
+	if log.StopWhenFull && log.Full() {
+		o.Properties[PropertyIdentifierLogEnable] = false
+		logger().Warn("日志缓冲区已满，停止记录", "object", o.Name)
+	}
+}
+
+// LogObject 定义拥有日志缓冲区并支持ReadRange访问的对象
+type LogObject interface {
+	Object
+	Buffer() *LogBuffer
+}
@@ -0,0 +1,291 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// LoopAction 控制动作方向
+type LoopAction uint8
+
+const (
+	LoopActionDirect  LoopAction = iota // 正作用：被控量高于设定值时输出增大
+	LoopActionReverse                   // 反作用：被控量低于设定值时输出增大
+)
+
+// Loop 表示BACnet回路对象，按Update_Interval执行PID运算并写出控制量
+type Loop struct {
+	*BACnetObject
+	ControlledVariableReference  DeviceObjectPropertyReference
+	ManipulatedVariableReference DeviceObjectPropertyReference
+	SetpointReference            *ObjectPropertyReference // 为空时使用Setpoint
+	Setpoint                     float32
+	Action                       LoopAction
+	ProportionalConstant         float32
+	IntegralConstant             float32 // 积分系数（每秒）
+	DerivativeConstant           float32 // 微分系数（秒）
+	Bias                         float32
+	MaximumOutput                float32
+	MinimumOutput                float32
+	OutputUnits                  EngineeringUnits
+	ControlledVariableUnits      EngineeringUnits
+	UpdateInterval               uint32 // 运算周期（毫秒）
+	PriorityForWriting           uint8
+
+	controlledValue float32
+	integral        float64
+	lastError       float64
+	lastUpdate      time.Time
+}
+
+// NewLoop 创建一个新的回路对象
+func NewLoop(instance uint32, name string) *Loop {
+	loop := &Loop{
+		BACnetObject:            NewBACnetObject(ObjectTypeLoop, instance, name),
+		Action:                  LoopActionReverse,
+		ProportionalConstant:    1,
+		MaximumOutput:           100,
+		OutputUnits:             UnitsNoUnits,
+		ControlledVariableUnits: UnitsNoUnits,
+		UpdateInterval:          1000,
+		PriorityForWriting:      16,
+	}
+	loop.Properties[PropertyIdentifierPresentValue] = float32(0)
+	return loop
+}
+
+// Execute 到达Update_Interval时执行一次PID运算
+func (l *Loop) Execute(device *Device, now time.Time) {
+	interval := time.Duration(l.UpdateInterval) * time.Millisecond
+	if !l.lastUpdate.IsZero() && now.Sub(l.lastUpdate) < interval {
+		return
+	}
+
+	controlled, err := l.readReference(device, l.ControlledVariableReference)
+	if err != nil {
+		logger().Warn("回路读取被控量失败", "object", l.Name, "error", err)
+		return
+	}
+	setpoint := float64(l.Setpoint)
+	if ref := l.SetpointReference; ref != nil {
+		setpointRef := DeviceObjectPropertyReference{
+			ObjectIdentifier:   ref.ObjectIdentifier,
+			PropertyIdentifier: ref.PropertyIdentifier,
+			ArrayIndex:         ref.ArrayIndex,
+		}
+		if setpoint, err = l.readReference(device, setpointRef); err != nil {
+			logger().Warn("回路读取设定值失败", "object", l.Name, "error", err)
+			return
+		}
+	}
+
+	var dt float64
+	if !l.lastUpdate.IsZero() {
+		dt = now.Sub(l.lastUpdate).Seconds()
+	}
+	l.lastUpdate = now
+	l.controlledValue = float32(controlled)
+
+	output := float32(l.compute(setpoint, controlled, dt))
+	l.BACnetObject.WriteProperty(PropertyIdentifierPresentValue, output)
+
+	obj, err := device.ResolveReference(l.ManipulatedVariableReference)
+	if err != nil {
+		logger().Warn("回路写出控制量失败", "object", l.Name, "error", err)
+		return
+	}
+	if err := WriteWithPriority(obj, l.ManipulatedVariableReference.PropertyIdentifier, output, l.PriorityForWriting); err != nil {
+		logger().Warn("回路写出控制量失败", "object", l.Name, "error", err)
+	}
+}
+
+// compute 计算PID输出，输出饱和时停止积分以防止积分饱和
+func (l *Loop) compute(setpoint, controlled, dt float64) float64 {
+	e := setpoint - controlled
+	if l.Action == LoopActionDirect {
+		e = -e
+	}
+
+	var derivative float64
+	if dt > 0 {
+		derivative = (e - l.lastError) / dt
+	}
+	l.lastError = e
+
+	integral := l.integral + e*dt
+	output := float64(l.Bias) + float64(l.ProportionalConstant)*e + float64(l.IntegralConstant)*integral + float64(l.DerivativeConstant)*derivative
+	switch {
+	case output > float64(l.MaximumOutput):
+		output = float64(l.MaximumOutput)
+	case output < float64(l.MinimumOutput):
+		output = float64(l.MinimumOutput)
+	default:
+		l.integral = integral
+	}
+	return output
+}
+
+// readReference 读取被引用属性的数值
+func (l *Loop) readReference(device *Device, ref DeviceObjectPropertyReference) (float64, error) {
+	obj, err := device.ResolveReference(ref)
+	if err != nil {
+		return 0, err
+	}
+	value, err := ReadPropertyValue(obj, ref.PropertyIdentifier)
+	if err != nil {
+		return 0, err
+	}
+	number, ok := toFloat64(value)
+	if !ok {
+		return 0, fmt.Errorf("属性值不是数值类型: %v", value)
+	}
+	return number, nil
+}
+
+// Reset 清除积分和微分状态
+func (l *Loop) Reset() {
+	l.integral = 0
+	l.lastError = 0
+	l.lastUpdate = time.Time{}
+}
+
+// ReadProperty 读取回路属性
+func (l *Loop) ReadProperty(prop PropertyIdentifier) (interface{}, error) {
+	switch prop {
+	case PropertyIdentifierControlledVariableReference:
+		return l.ControlledVariableReference, nil
+	case PropertyIdentifierControlledVariableValue:
+		return l.controlledValue, nil
+	case PropertyIdentifierSetpointReference:
+		return SetpointReference{Reference: l.SetpointReference}, nil
+	case PropertyIdentifierSetpoint:
+		return l.Setpoint, nil
+	case PropertyIdentifierManipulatedVariableReference:
+		return l.ManipulatedVariableReference, nil
+	case PropertyIdentifierAction:
+		return l.Action, nil
+	case PropertyIdentifierProportionalConstant:
+		return l.ProportionalConstant, nil
+	case PropertyIdentifierIntegralConstant:
+		return l.IntegralConstant, nil
+	case PropertyIdentifierDerivativeConstant:
+		return l.DerivativeConstant, nil
+	case PropertyIdentifierBias:
+		return l.Bias, nil
+	case PropertyIdentifierMaximumOutput:
+		return l.MaximumOutput, nil
+	case PropertyIdentifierMinimumOutput:
+		return l.MinimumOutput, nil
+	case PropertyIdentifierOutputUnits:
+		return l.OutputUnits, nil
+	case PropertyIdentifierControlledVariableUnits:
+		return l.ControlledVariableUnits, nil
+	case PropertyIdentifierUpdateInterval:
+		return l.UpdateInterval, nil
+	case PropertyIdentifierPriorityForWriting:
+		return l.PriorityForWriting, nil
+	}
+	return l.BACnetObject.ReadProperty(prop)
+}
+
+// WriteProperty 写入回路属性
+func (l *Loop) WriteProperty(prop PropertyIdentifier, value interface{}) error {
+	switch prop {
+	case PropertyIdentifierControlledVariableReference, PropertyIdentifierManipulatedVariableReference:
+		ref, ok := value.(DeviceObjectPropertyReference)
+		if !ok {
+			return fmt.Errorf("属性引用类型无效")
+		}
+		if prop == PropertyIdentifierControlledVariableReference {
+			l.ControlledVariableReference = ref
+		} else {
+			l.ManipulatedVariableReference = ref
+		}
+		l.Reset()
+		return nil
+	case PropertyIdentifierSetpointReference:
+		ref, ok := value.(SetpointReference)
+		if !ok {
+			return fmt.Errorf("Setpoint_Reference类型无效")
+		}
+		l.SetpointReference = ref.Reference
+		l.Reset()
+		return nil
+	case PropertyIdentifierAction:
+		action, ok := toUint32(value)
+		if !ok || action > uint32(LoopActionReverse) {
+			return fmt.Errorf("Action值无效")
+		}
+		l.Action = LoopAction(action)
+		l.Reset()
+		return nil
+	case PropertyIdentifierUpdateInterval:
+		interval, ok := toUint32(value)
+		if !ok {
+			return fmt.Errorf("Update_Interval类型无效")
+		}
+		l.UpdateInterval = interval
+		return nil
+	case PropertyIdentifierPriorityForWriting:
+		priority, ok := toUint32(value)
+		if !ok || priority < 1 || priority > 16 {
+			return fmt.Errorf("Priority_For_Writing必须在1-16之间")
+		}
+		l.PriorityForWriting = uint8(priority)
+		return nil
+	}
+
+	if target := l.floatField(prop); target != nil {
+		number, ok := toFloat64(value)
+		if !ok {
+			return fmt.Errorf("属性值必须为数值类型")
+		}
+		*target = float32(number)
+		return nil
+	}
+	return l.BACnetObject.WriteProperty(prop, value)
+}
+
+// floatField 返回数值型回路参数对应的字段
+func (l *Loop) floatField(prop PropertyIdentifier) *float32 {
+	switch prop {
+	case PropertyIdentifierSetpoint:
+		return &l.Setpoint
+	case PropertyIdentifierProportionalConstant:
+		return &l.ProportionalConstant
+	case PropertyIdentifierIntegralConstant:
+		return &l.IntegralConstant
+	case PropertyIdentifierDerivativeConstant:
+		return &l.DerivativeConstant
+	case PropertyIdentifierBias:
+		return &l.Bias
+	case PropertyIdentifierMaximumOutput:
+		return &l.MaximumOutput
+	case PropertyIdentifierMinimumOutput:
+		return &l.MinimumOutput
+	}
+	return nil
+}
+
+// toFloat64 将常见数值类型转换为float64
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,98 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoopProportional(t *testing.T) {
+	loop := NewLoop(1, "Loop")
+	loop.ProportionalConstant = 2
+	loop.Bias = 10
+
+	// 反作用：被控量低于设定值2时输出 = Bias + Kp*2
+	if got := loop.compute(20, 18, 0); got != 14 {
+		t.Errorf("reverse output = %v, want 14", got)
+	}
+	// 被控量高于设定值时输出减小，低于Minimum_Output时限幅
+	if got := loop.compute(20, 23, 0); got != 4 {
+		t.Errorf("reverse output above setpoint = %v, want 4", got)
+	}
+	if got := loop.compute(20, 30, 0); got != 0 {
+		t.Errorf("clamped output = %v, want 0", got)
+	}
+}
+
+func TestLoopDirectAction(t *testing.T) {
+	loop := NewLoop(1, "Loop")
+	loop.Action = LoopActionDirect
+	loop.ProportionalConstant = 5
+
+	// 正作用：被控量高于设定值时输出增大
+	if got := loop.compute(20, 22, 0); got != 10 {
+		t.Errorf("direct output = %v, want 10", got)
+	}
+	if got := loop.compute(20, 18, 0); got != 0 {
+		t.Errorf("direct output below setpoint = %v, want 0", got)
+	}
+}
+
+func TestLoopIntegralWindup(t *testing.T) {
+	loop := NewLoop(1, "Loop")
+	loop.ProportionalConstant = 0
+	loop.IntegralConstant = 1
+	loop.MaximumOutput = 10
+
+	// 误差5持续累积，输出达到Maximum_Output后积分不再增加
+	for i, want := range []float64{5, 10, 10, 10} {
+		if got := loop.compute(20, 15, 1); got != want {
+			t.Fatalf("step %d output = %v, want %v", i, got, want)
+		}
+	}
+	if loop.integral != 10 {
+		t.Errorf("integral = %v, want 10", loop.integral)
+	}
+	// 误差反向时输出立即离开上限
+	if got := loop.compute(20, 21, 1); got != 9 {
+		t.Errorf("output after reversal = %v, want 9", got)
+	}
+}
+
+func TestLoopExecute(t *testing.T) {
+	device := NewDevice(1, "Device", "")
+	temperature := NewAnalogValue(1, "Temperature", UnitsDegreesCelsius)
+	setpoint := NewAnalogValue(2, "Setpoint", UnitsDegreesCelsius)
+	valve := NewAnalogValue(3, "Valve", UnitsPercent)
+	loop := NewLoop(1, "Loop")
+	for _, obj := range []Object{temperature, setpoint, valve, loop} {
+		device.AddObject(obj)
+	}
+	temperature.WriteProperty(PropertyIdentifierPresentValue, float32(25))
+	setpoint.WriteProperty(PropertyIdentifierPresentValue, float32(22))
+	loop.ControlledVariableReference = DeviceObjectPropertyReference{
+		ObjectIdentifier: temperature.GetObjectIdentifier(), PropertyIdentifier: PropertyIdentifierPresentValue,
+	}
+	loop.SetpointReference = &ObjectPropertyReference{
+		ObjectIdentifier: setpoint.GetObjectIdentifier(), PropertyIdentifier: PropertyIdentifierPresentValue,
+	}
+	loop.ManipulatedVariableReference = DeviceObjectPropertyReference{
+		ObjectIdentifier: valve.GetObjectIdentifier(), PropertyIdentifier: PropertyIdentifierPresentValue,
+	}
+	loop.Action = LoopActionDirect
+	loop.ProportionalConstant = 10
+
+	loop.Execute(device, time.Unix(0, 0))
+
+	if got, _ := loop.ReadProperty(PropertyIdentifierPresentValue); got != float32(30) {
+		t.Errorf("Present_Value = %v (%T), want float32(30)", got, got)
+	}
+	if got, _ := loop.ReadProperty(PropertyIdentifierControlledVariableValue); got != float32(25) {
+		t.Errorf("Controlled_Variable_Value = %v (%T), want float32(25)", got, got)
+	}
+	if got, _ := valve.ReadProperty(PropertyIdentifierPresentValue); got != float32(30) {
+		t.Errorf("valve Present_Value = %v (%T), want float32(30)", got, got)
+	}
+	if got, _ := loop.ReadProperty(PropertyIdentifierSetpointReference); got.(SetpointReference).Reference != loop.SetpointReference {
+		t.Errorf("Setpoint_Reference = %+v", got)
+	}
+}
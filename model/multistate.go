@@ -0,0 +1,178 @@
+package model
+
+import (
+	"fmt"
+)
+
+// MultiState 表示BACnet多态输入、输出和值对象，Present_Value取值范围为1..Number_Of_States
+type MultiState struct {
+	*BACnetObject
+	StateText []string // 下标0对应状态1
+}
+
+// NewMultiStateInput 创建多态输入对象
+func NewMultiStateInput(instance uint32, name string, stateText []string) *MultiState {
+	return newMultiState(ObjectTypeMultiStateInput, instance, name, stateText)
+}
+
+// NewMultiStateOutput 创建多态输出对象
+func NewMultiStateOutput(instance uint32, name string, stateText []string) *MultiState {
+	return newMultiState(ObjectTypeMultiStateOutput, instance, name, stateText)
+}
+
+// NewMultiStateValue 创建多态值对象
+func NewMultiStateValue(instance uint32, name string, stateText []string) *MultiState {
+	return newMultiState(ObjectTypeMultiStateValue, instance, name, stateText)
+}
+
+// newMultiState 创建多态对象，Present_Value初始为状态1
+func newMultiState(objType ObjectType, instance uint32, name string, stateText []string) *MultiState {
+	multiState := &MultiState{
+		BACnetObject: NewBACnetObject(objType, instance, name),
+		StateText:    append([]string{}, stateText...),
+	}
+	multiState.Properties[PropertyIdentifierPresentValue] = uint32(1)
+	if multiState.Commandable(PropertyIdentifierPresentValue) {
+		multiState.Properties[PropertyIdentifierRelinquishDefault] = uint32(1)
+	}
+	return multiState
+}
+
+// NumberOfStates 返回状态数
+func (m *MultiState) NumberOfStates() uint32 {
+	return uint32(len(m.StateText))
+}
+
+// CurrentStateText 返回当前状态的描述文字
+func (m *MultiState) CurrentStateText() string {
+	state, _ := m.Properties[PropertyIdentifierPresentValue].(uint32)
+	if state < 1 || state > m.NumberOfStates() {
+		return ""
+	}
+	return m.StateText[state-1]
+}
+
+// ReadProperty 读取多态对象属性
+func (m *MultiState) ReadProperty(prop PropertyIdentifier) (interface{}, error) {
+	switch prop {
+	case PropertyIdentifierNumberOfStates:
+		return m.NumberOfStates(), nil
+	case PropertyIdentifierStateText:
+		return append([]string{}, m.StateText...), nil
+	}
+	return m.BACnetObject.ReadProperty(prop)
+}
+
+// WriteProperty 写入多态对象属性，Present_Value超出1..Number_Of_States时返回ErrValueOutOfRange
+func (m *MultiState) WriteProperty(prop PropertyIdentifier, value interface{}) error {
+	switch prop {
+	case PropertyIdentifierPresentValue:
+		return m.WritePropertyWithPriority(prop, value, 16)
+	case PropertyIdentifierRelinquishDefault:
+		state, err := m.checkState(value)
+		if err != nil {
+			return err
+		}
+		return m.BACnetObject.WriteProperty(prop, state)
+	case PropertyIdentifierNumberOfStates:
+		count, ok := toUint32(value)
+		if !ok {
+			return fmt.Errorf("Number_Of_States类型无效")
+		}
+		if count == 0 {
+			return ErrValueOutOfRange
+		}
+		m.resize(count)
+		return nil
+	case PropertyIdentifierStateText:
+		texts, ok := value.([]string)
+		if !ok {
+			return fmt.Errorf("State_Text类型无效")
+		}
+		if len(texts) == 0 {
+			return ErrValueOutOfRange
+		}
+		m.StateText = append([]string{}, texts...)
+		m.clampPresentValue()
+		return nil
+	}
+	return m.BACnetObject.WriteProperty(prop, value)
+}
+
+// WritePropertyWithPriority 按优先级写入，Present_Value同样检查状态范围，nil表示释放该优先级
+func (m *MultiState) WritePropertyWithPriority(prop PropertyIdentifier, value interface{}, priority uint8) error {
+	if prop == PropertyIdentifierPresentValue {
+		if m.isInput() && !m.OutOfService() {
+			return ErrWriteAccessDenied
+		}
+		if value == nil {
+			return m.BACnetObject.WritePropertyWithPriority(prop, nil, priority)
+		}
+		state, err := m.checkState(value)
+		if err != nil {
+			return err
+		}
+		value = state
+	}
+	return m.BACnetObject.WritePropertyWithPriority(prop, value, priority)
+}
+
+// UpdatePresentValue 由现场驱动或模拟数据更新Present_Value，对象停用时忽略更新
+func (m *MultiState) UpdatePresentValue(value interface{}) error {
+	if m.OutOfService() {
+		return nil
+	}
+	return m.writePresentValue(value)
+}
+
+// writePresentValue 检查状态范围后写入Present_Value
+func (m *MultiState) writePresentValue(value interface{}) error {
+	state, err := m.checkState(value)
+	if err != nil {
+		return err
+	}
+	return m.BACnetObject.WriteProperty(PropertyIdentifierPresentValue, state)
+}
+
+// checkState 检查状态值是否在1..Number_Of_States范围内
+func (m *MultiState) checkState(value interface{}) (uint32, error) {
+	state, ok := toUint32(value)
+	if !ok {
+		return 0, fmt.Errorf("Present_Value类型无效")
+	}
+	if state < 1 || state > m.NumberOfStates() {
+		return 0, ErrValueOutOfRange
+	}
+	return state, nil
+}
+
+// resize 修改状态数，新增状态的描述为空
+func (m *MultiState) resize(count uint32) {
+	if count < m.NumberOfStates() {
+		m.StateText = m.StateText[:count]
+	} else {
+		for m.NumberOfStates() < count {
+			m.StateText = append(m.StateText, "")
+		}
+	}
+	m.clampPresentValue()
+}
+
+// clampPresentValue 状态数减少后将超出范围的Present_Value、优先级数组和Relinquish_Default调整为最大状态
+func (m *MultiState) clampPresentValue() {
+	if !m.Commandable(PropertyIdentifierPresentValue) {
+		if state, _ := m.Properties[PropertyIdentifierPresentValue].(uint32); state > m.NumberOfStates() {
+			m.BACnetObject.WriteProperty(PropertyIdentifierPresentValue, m.NumberOfStates())
+		}
+		return
+	}
+	for priority, value := range m.PrioritizedProperties[PropertyIdentifierPresentValue] {
+		if state, _ := value.(uint32); state > m.NumberOfStates() {
+			m.PrioritizedProperties[PropertyIdentifierPresentValue][priority] = m.NumberOfStates()
+		}
+	}
+	if state, _ := m.Properties[PropertyIdentifierRelinquishDefault].(uint32); state > m.NumberOfStates() {
+		m.Properties[PropertyIdentifierRelinquishDefault] = m.NumberOfStates()
+	}
+	m.updateEffectiveValue(PropertyIdentifierPresentValue)
+}
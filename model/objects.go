@@ -0,0 +1,1198 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrValueOutOfRange 写入的值超出属性允许的范围
+var ErrValueOutOfRange = errors.New("value out of range")
+
+// ErrWriteAccessDenied 属性当前不允许写入（如未停用的输入对象的Present_Value）
+var ErrWriteAccessDenied = errors.New("write access denied")
+
+// ErrDuplicateName 设备内已有同名对象
+var ErrDuplicateName = errors.New("duplicate name")
+
+// ErrInvalidArrayIndex 数组下标超出数组长度
+var ErrInvalidArrayIndex = errors.New("invalid array index")
+
+// ErrPropertyIsNotAnArray 对非数组属性使用了数组下标
+var ErrPropertyIsNotAnArray = errors.New("property is not an array")
+
+// ErrRemoteReference 引用了其他设备的对象，本设备只能解析本地对象的引用
+var ErrRemoteReference = errors.New("remote device references not supported")
+
+// 告警状态枚举
+type EventState uint8
+
+const (
+	EventStateNormal EventState = iota
+	EventStateFault
+	EventStateOffNormal
+	EventStateHighLimit
+	EventStateLowLimit
+)
+
+// String 返回事件状态的标准名称
+func (s EventState) String() string {
+	switch s {
+	case EventStateNormal:
+		return "normal"
+	case EventStateFault:
+		return "fault"
+	case EventStateOffNormal:
+		return "offnormal"
+	case EventStateHighLimit:
+		return "high-limit"
+	case EventStateLowLimit:
+		return "low-limit"
+	}
+	return fmt.Sprintf("event-state-%d", uint8(s))
+}
+
+// ParseEventState 按标准名称（如high-limit）解析事件状态
+func ParseEventState(text string) (EventState, error) {
+	for s := EventStateNormal; s <= EventStateLowLimit; s++ {
+		if strings.EqualFold(s.String(), text) {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("未知的事件状态: %s", text)
+}
+
+// 通知类型枚举
+type NotifyType uint8
+
+const (
+	NotifyTypeAlarm NotifyType = iota
+	NotifyTypeEvent
+	NotifyTypeAckNotification
+)
+
+// 状态标志位
+const (
+	StatusFlagInAlarm = 1 << iota
+	StatusFlagFault
+	StatusFlagOverridden
+	StatusFlagOutOfService
+)
+
+// StatusFlagsBits 将状态标志转换为BACnetStatusFlags位串：in-alarm、fault、overridden、out-of-service
+func StatusFlagsBits(flags uint8) BitString {
+	return BitStringFromUint(4, uint64(flags))
+}
+
+// Reliability 对象的可靠性（BACnetReliability），不为no-fault-detected时Status_Flags的FAULT位置位
+type Reliability uint32
+
+const (
+	ReliabilityNoFaultDetected      Reliability = 0
+	ReliabilityNoSensor             Reliability = 1
+	ReliabilityOverRange            Reliability = 2
+	ReliabilityUnderRange           Reliability = 3
+	ReliabilityOpenLoop             Reliability = 4
+	ReliabilityShortedLoop          Reliability = 5
+	ReliabilityNoOutput             Reliability = 6
+	ReliabilityUnreliableOther      Reliability = 7
+	ReliabilityProcessError         Reliability = 8
+	ReliabilityMultiStateFault      Reliability = 9
+	ReliabilityConfigurationError   Reliability = 10
+	ReliabilityCommunicationFailure Reliability = 12
+)
+
+// EventTransition 表示事件转换状态
+type EventTransition uint8
+
+const (
+	EventTransitionToNormal EventTransition = iota
+	EventTransitionToFault
+	EventTransitionToOffNormal
+	EventTransitionToHighLimit
+	EventTransitionToLowLimit
+)
+
+// 文件访问方法枚举
+type FileAccessMethod uint8
+
+const (
+	FileAccessMethodStream FileAccessMethod = iota
+	FileAccessMethodRecord
+)
+
+// BACnetEvent 表示BACnet事件
+type BACnetEvent struct {
+	EventType         ObjectType
+	EventState        EventState
+	TimeStamp         time.Time // 时间戳类型，表示事件发生的实际时间
+	MessageText       string
+	NotificationClass uint32
+}
+
+// COVSubscription 表示变化通知订阅
+type COVSubscription struct {
+	SubscriptionID                 uint32               // 变化通知订阅ID，由服务端分配
+	SubscriberProcessID            uint32               // 订阅者进程ID，与客户端地址一起标识订阅者
+	DeviceID                       uint32               // 设备ID
+	ObjectIdentifier               ObjectIdentifier     // 对象标识符
+	Lifetime                       uint32               // 订阅有效期（秒）
+	IssueConfirmedCOVNotifications bool                 // 是否确认发送变化通知
+	MonitoredProperties            []PropertyIdentifier // 监控的属性列表
+	Timestamp                      time.Time            // 订阅创建时间戳
+	Expires                        time.Time            // 到期时间，零值表示不过期（Lifetime为0）
+	ClientAddress                  string               // 客户端IP地址和端口，格式: "192.168.1.1:1234"
+	RemoteAddress                  *Address             // 订阅者在远程网络上时为其网络号和MAC地址，通知经ClientAddress处的路由器转发
+}
+
+// BACnetFile 表示BACnet文件对象
+type BACnetFile struct {
+	*BACnetObject
+	FileData     []byte
+	AccessMethod FileAccessMethod
+	OpeningTag   string
+	ClosingTag   string
+}
+
+// Alarmable 定义可告警对象接口
+type Alarmable interface {
+	Object
+	GetEventState() EventState
+	SetEventState(state EventState)
+	GetNotificationClass() uint32
+	SetNotificationClass(class uint32)
+	GetStatusFlags() uint8
+	SetStatusFlags(flags uint8)
+	GenerateEvent(state EventState, message string)
+	AcknowledgeAlarm(state EventState, timeStamp time.Time) error
+}
+
+// ObjectIdentifier 表示BACnet对象标识符
+type ObjectIdentifier struct {
+	Type     ObjectType
+	Instance uint32
+}
+
+// Object 定义BACnet对象的接口
+type Object interface {
+	GetObjectIdentifier() ObjectIdentifier
+	GetObjectName() string
+	GetObjectType() ObjectType
+	ReadProperty(prop PropertyIdentifier) (interface{}, error)
+	WriteProperty(prop PropertyIdentifier, value interface{}) error
+}
+
+// COVValue COV通知中的一个属性值
+type COVValue struct {
+	PropertyIdentifier PropertyIdentifier
+	Value              interface{}
+}
+
+// NotificationSender 通知发送器接口
+type NotificationSender interface {
+	SendCOVNotification(subscription COVSubscription, values []COVValue) error
+	SendEventNotification(notification EventNotification) error
+}
+
+// ConfirmedNotificationSender 支持以确认请求发送COV通知的发送器
+type ConfirmedNotificationSender interface {
+	SendConfirmedCOVNotification(subscription COVSubscription, values []COVValue) error
+}
+
+// COVSubscribable 定义支持COV订阅的对象
+type COVSubscribable interface {
+	Object
+	AddCOVSubscription(subscription COVSubscription)
+	RemoveCOVSubscription(subscriptionID uint32) bool
+}
+
+// PresentValueUpdater 定义可由现场驱动或模拟数据更新Present_Value的对象，
+// 对象Out_Of_Service为true时驱动更新被忽略
+type PresentValueUpdater interface {
+	UpdatePresentValue(value interface{}) error
+}
+
+// CommandableObject 定义带优先级数组的可命令对象
+type CommandableObject interface {
+	Object
+	Commandable(prop PropertyIdentifier) bool
+	WritePropertyWithPriority(prop PropertyIdentifier, value interface{}, priority uint8) error
+}
+
+// WriteWithPriority 可命令属性按优先级写入，其他属性忽略优先级使用对象自身的WriteProperty，
+// 属性绑定了提供者时写入提供者
+func WriteWithPriority(obj Object, prop PropertyIdentifier, value interface{}, priority uint8) error {
+	if provider := providerOf(obj, prop); provider != nil {
+		return provider.WriteProperty(obj, prop, value, priority)
+	}
+	if c, ok := obj.(CommandableObject); ok && c.Commandable(prop) {
+		return c.WritePropertyWithPriority(prop, value, priority)
+	}
+	return obj.WriteProperty(prop, value)
+}
+
+// PriorityArray 可命令属性的16级优先级数组，下标0对应优先级1，nil表示该级未命令
+type PriorityArray [16]interface{}
+
+// ReadPropertyElement 按数组下标读取数组属性，下标0返回数组长度，1..N返回对应元素
+func ReadPropertyElement(obj Object, prop PropertyIdentifier, index uint32) (interface{}, error) {
+	value, err := ReadPropertyValue(obj, prop)
+	if err != nil {
+		return nil, err
+	}
+	v := reflect.ValueOf(value)
+	// 字节切片是OctetString而不是数组
+	if (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) || v.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, ErrPropertyIsNotAnArray
+	}
+	if index == 0 {
+		return uint32(v.Len()), nil
+	}
+	if int(index) > v.Len() {
+		return nil, ErrInvalidArrayIndex
+	}
+	return v.Index(int(index) - 1).Interface(), nil
+}
+
+// maxArrayLength 写入数组长度（下标0）时允许的最大长度
+const maxArrayLength = 1024
+
+// PropertyWithElement 返回将数组属性的第index个元素替换为element后的整个属性值，不修改对象。
+// 下标0写入数组长度：长度可变的数组截断或以零值补齐，固定长度的数组只能写入原长度
+func PropertyWithElement(obj Object, prop PropertyIdentifier, index uint32, element interface{}) (interface{}, error) {
+	value, err := ReadPropertyValue(obj, prop)
+	if err != nil {
+		return nil, err
+	}
+	v := reflect.ValueOf(value)
+	if (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) || v.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, ErrPropertyIsNotAnArray
+	}
+	if index == 0 {
+		length, ok := toUint32(element)
+		if !ok {
+			return nil, ErrInvalidDataType
+		}
+		if int(length) == v.Len() {
+			return value, nil
+		}
+		if v.Kind() == reflect.Array {
+			return nil, ErrWriteAccessDenied
+		}
+		if length > maxArrayLength {
+			return nil, ErrValueOutOfRange
+		}
+		resized := reflect.MakeSlice(v.Type(), int(length), int(length))
+		reflect.Copy(resized, v)
+		return resized.Interface(), nil
+	}
+	if int(index) > v.Len() {
+		return nil, ErrInvalidArrayIndex
+	}
+	elemType := v.Type().Elem()
+	e := reflect.ValueOf(element)
+	if element == nil {
+		if elemType.Kind() != reflect.Interface {
+			return nil, ErrInvalidDataType
+		}
+		e = reflect.Zero(elemType)
+	} else if !e.Type().AssignableTo(elemType) {
+		return nil, ErrInvalidDataType
+	}
+	var updated reflect.Value
+	if v.Kind() == reflect.Slice {
+		updated = reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(updated, v)
+	} else {
+		updated = reflect.New(v.Type()).Elem()
+		updated.Set(v)
+	}
+	updated.Index(int(index) - 1).Set(e)
+	return updated.Interface(), nil
+}
+
+// PropertyObserver 属性有效值变化时的回调，用于对象之间的内部联动（如COV方式的趋势记录）
+type PropertyObserver func(obj Object, prop PropertyIdentifier, value interface{})
+
+// Executable 定义需要由设备周期性驱动的对象（如趋势日志轮询）
+type Executable interface {
+	Execute(device *Device, now time.Time)
+}
+
+// BACnetObject 实现基础的BACnet对象
+type BACnetObject struct {
+	Identifier            ObjectIdentifier                             // 对象标识符
+	Name                  string                                       // 对象名称
+	Properties            map[PropertyIdentifier]interface{}           // 属性值映射，可命令属性保存当前有效值
+	PrioritizedProperties map[PropertyIdentifier]map[uint8]interface{} // 可命令属性的优先级数组（1-16，1最高）
+	Events                []BACnetEvent                                // 事件列表
+	Subscriptions         []COVSubscription                            // 变化通知订阅列表
+	Notifier              NotificationSender                           // 通知发送器
+	observers             []PropertyObserver                           // 内部属性观察者
+	nameValidator         func(name string) error                      // 改名前的检查（由所属设备设置）
+	clock                 Clock                                        // 时间来源（由所属设备设置），为nil时使用SystemClock
+	providers             map[PropertyIdentifier]PropertyProvider      // 由外部提供者读写的属性
+}
+
+// SetClock 设置对象的时钟，为nil时使用SystemClock。对象加入设备后使用设备的时钟
+func (o *BACnetObject) SetClock(clock Clock) {
+	o.clock = clock
+}
+
+// Clock 返回对象使用的时钟
+func (o *BACnetObject) Clock() Clock {
+	if o.clock == nil {
+		return SystemClock
+	}
+	return o.clock
+}
+
+// now 返回对象时钟的当前时间
+func (o *BACnetObject) now() time.Time {
+	return o.Clock().Now()
+}
+
+// NewBACnetObject 创建一个新的BACnet对象，并按属性元数据填充默认值
+func NewBACnetObject(objType ObjectType, instance uint32, name string) *BACnetObject {
+	object := &BACnetObject{
+		Identifier: ObjectIdentifier{
+			Type:     objType,
+			Instance: instance,
+		},
+		Name:                  name,
+		Properties:            make(map[PropertyIdentifier]interface{}),
+		PrioritizedProperties: make(map[PropertyIdentifier]map[uint8]interface{}),
+		Events:                []BACnetEvent{},
+		Subscriptions:         []COVSubscription{},
+		Notifier:              nil, // 初始化为nil，由外部设置
+	}
+	applyPropertyDefaults(object)
+	return object
+}
+
+// GetObjectIdentifier 获取对象标识符
+func (o *BACnetObject) GetObjectIdentifier() ObjectIdentifier {
+	return o.Identifier
+}
+
+// GetObjectName 获取对象名称
+func (o *BACnetObject) GetObjectName() string {
+	return o.Name
+}
+
+// GetObjectType 获取对象类型
+func (o *BACnetObject) GetObjectType() ObjectType {
+	return o.Identifier.Type
+}
+
+// ReadProperty 读取对象属性
+func (o *BACnetObject) ReadProperty(prop PropertyIdentifier) (interface{}, error) {
+	switch {
+	case prop == PropertyIdentifierObjectIdentifier:
+		return o.Identifier, nil
+	case prop == PropertyIdentifierObjectName:
+		return o.Name, nil
+	case prop == PropertyIdentifierObjectType:
+		return o.Identifier.Type, nil
+	case prop == PropertyIdentifierStatusFlags:
+		return StatusFlagsBits(o.GetStatusFlags()), nil
+	case prop == PropertyIdentifierReliability:
+		if _, exists := o.Properties[prop]; exists {
+			return o.GetReliability(), nil
+		}
+	case prop == PropertyIdentifierAckedTransitions:
+		if _, exists := o.Properties[prop]; exists {
+			return BitStringFromUint(3, uint64(o.GetAckedTransitions())), nil
+		}
+	case prop == PropertyIdentifierPriorityArray && o.Commandable(PropertyIdentifierPresentValue):
+		return o.PriorityArray(PropertyIdentifierPresentValue), nil
+	}
+
+	// 可命令属性的有效值在写入时已按优先级数组计算
+	if o.Properties != nil {
+		value, exists := o.Properties[prop]
+		if !exists {
+			return nil, nil // 属性不存在
+		}
+		return value, nil
+	}
+	return nil, nil
+}
+
+// WriteProperty 写入对象属性（默认优先级16）
+func (o *BACnetObject) WriteProperty(prop PropertyIdentifier, value interface{}) error {
+	return o.WritePropertyWithPriority(prop, value, 16)
+}
+
+// WritePropertyWithPriority 按照BACnet协议，使用指定优先级写入对象属性
+// 可命令属性写入优先级数组（1-16，1最高），写入nil表示释放该优先级；其他属性忽略优先级直接写入
+func (o *BACnetObject) WritePropertyWithPriority(prop PropertyIdentifier, value interface{}, priority uint8) error {
+	// 初始化必要的映射
+	if o.Properties == nil {
+		o.Properties = make(map[PropertyIdentifier]interface{})
+	}
+	if o.PrioritizedProperties == nil {
+		o.PrioritizedProperties = make(map[PropertyIdentifier]map[uint8]interface{})
+	}
+
+	if o.Commandable(prop) {
+		if priority < 1 || priority > 16 {
+			return fmt.Errorf("invalid priority value, must be between 1-16")
+		}
+		if _, exists := o.PrioritizedProperties[prop]; !exists {
+			o.PrioritizedProperties[prop] = make(map[uint8]interface{})
+		}
+		if value == nil {
+			delete(o.PrioritizedProperties[prop], priority)
+		} else {
+			o.PrioritizedProperties[prop][priority] = value
+		}
+		o.updateEffectiveValue(prop)
+		return nil
+	}
+	if value == nil {
+		return fmt.Errorf("属性%d不可命令，不能写入NULL", prop)
+	}
+	if prop == PropertyIdentifierObjectName {
+		name, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("Object_Name类型无效")
+		}
+		if o.nameValidator != nil && name != o.Name {
+			if err := o.nameValidator(name); err != nil {
+				return err
+			}
+		}
+		oldName := o.Name
+		o.Name = name
+		o.notifyChange(prop, oldName, name)
+		return nil
+	}
+
+	// 获取当前有效值（用于比较是否变化）
+	oldValue, _ := o.ReadProperty(prop)
+	oldFlags := o.GetStatusFlags()
+	o.Properties[prop] = value
+	o.notifyChange(prop, oldValue, value)
+
+	// 所有优先级都已释放时，Present_Value随Relinquish_Default变化
+	if prop == PropertyIdentifierRelinquishDefault && o.Commandable(PropertyIdentifierPresentValue) {
+		o.updateEffectiveValue(PropertyIdentifierPresentValue)
+	}
+	// Out_Of_Service、Reliability和Event_State的变化会改变Status_Flags，同样需要通知订阅者
+	o.notifyStatusFlags(oldFlags)
+	return nil
+}
+
+// notifyStatusFlags Status_Flags与oldFlags不同时通知订阅者和内部观察者
+func (o *BACnetObject) notifyStatusFlags(oldFlags uint8) {
+	if newFlags := o.GetStatusFlags(); newFlags != oldFlags {
+		o.notifyChange(PropertyIdentifierStatusFlags, StatusFlagsBits(oldFlags), StatusFlagsBits(newFlags))
+	}
+}
+
+// Commandable 判断属性是否为带优先级数组的可命令属性（输出对象和值对象的Present_Value）
+func (o *BACnetObject) Commandable(prop PropertyIdentifier) bool {
+	if prop != PropertyIdentifierPresentValue {
+		return false
+	}
+	switch o.GetObjectType() {
+	case ObjectTypeAnalogOutput, ObjectTypeAnalogValue, ObjectTypeBinaryOutput,
+		ObjectTypeBinaryValue, ObjectTypeMultiStateOutput, ObjectTypeMultiStateValue:
+		return true
+	}
+	return false
+}
+
+// PriorityArray 返回可命令属性当前的优先级数组
+func (o *BACnetObject) PriorityArray(prop PropertyIdentifier) PriorityArray {
+	var array PriorityArray
+	for priority, value := range o.PrioritizedProperties[prop] {
+		if priority >= 1 && priority <= 16 {
+			array[priority-1] = value
+		}
+	}
+	return array
+}
+
+// updateEffectiveValue 取优先级最高的命令值作为有效值，全部释放时使用Relinquish_Default
+func (o *BACnetObject) updateEffectiveValue(prop PropertyIdentifier) {
+	oldValue := o.Properties[prop]
+	newValue, hasDefault := o.Properties[PropertyIdentifierRelinquishDefault]
+	if !hasDefault {
+		// 未设置Relinquish_Default时保持当前值
+		newValue = oldValue
+	}
+	for priority := uint8(1); priority <= 16; priority++ {
+		if value, ok := o.PrioritizedProperties[prop][priority]; ok {
+			newValue = value
+			break
+		}
+	}
+	o.Properties[prop] = newValue
+	o.notifyChange(prop, oldValue, newValue)
+}
+
+// notifyChange 有效值变化时通知COV订阅者和内部观察者
+func (o *BACnetObject) notifyChange(prop PropertyIdentifier, oldValue, newValue interface{}) {
+	if reflect.DeepEqual(oldValue, newValue) {
+		return
+	}
+	if oldValue != nil && newValue != nil {
+		o.NotifySubscribers(prop, oldValue, newValue)
+	}
+	for _, observer := range o.observers {
+		observer(o, prop, newValue)
+	}
+}
+
+// OutOfService 判断对象是否处于停用状态
+func (o *BACnetObject) OutOfService() bool {
+	outOfService, _ := o.Properties[PropertyIdentifierOutOfService].(bool)
+	return outOfService
+}
+
+// UpdatePresentValue 由现场驱动或模拟数据更新Present_Value，对象停用时忽略更新
+func (o *BACnetObject) UpdatePresentValue(value interface{}) error {
+	if o.OutOfService() {
+		return nil
+	}
+	return o.WriteProperty(PropertyIdentifierPresentValue, value)
+}
+
+// isInput 判断对象是否为输入对象，输入对象的Present_Value仅在停用时允许写入
+func (o *BACnetObject) isInput() bool {
+	switch o.GetObjectType() {
+	case ObjectTypeAnalogInput, ObjectTypeBinaryInput, ObjectTypeMultiStateInput:
+		return true
+	}
+	return false
+}
+
+// AddPropertyObserver 注册属性变化观察者
+func (o *BACnetObject) AddPropertyObserver(observer PropertyObserver) {
+	o.observers = append(o.observers, observer)
+}
+
+// SetNameValidator 设置改名前的检查函数，用于设备范围内的对象名唯一性
+func (o *BACnetObject) SetNameValidator(validator func(name string) error) {
+	o.nameValidator = validator
+}
+
+// SetNotifier 设置通知发送器
+func (o *BACnetObject) SetNotifier(notifier NotificationSender) {
+	o.Notifier = notifier
+}
+
+// GetEventState 获取对象的事件状态
+func (o *BACnetObject) GetEventState() EventState {
+	if state, exists := o.Properties[PropertyIdentifierEventState]; exists {
+		if s, ok := state.(EventState); ok {
+			return s
+		}
+	}
+	return EventStateNormal
+}
+
+// SetEventState 设置对象的事件状态
+func (o *BACnetObject) SetEventState(state EventState) {
+	oldFlags := o.GetStatusFlags()
+	o.Properties[PropertyIdentifierEventState] = state
+	o.notifyStatusFlags(oldFlags)
+}
+
+// GetReliability 获取对象的可靠性，没有Reliability属性时为no-fault-detected
+func (o *BACnetObject) GetReliability() Reliability {
+	switch v := o.Properties[PropertyIdentifierReliability].(type) {
+	case Reliability:
+		return v
+	case uint32:
+		return Reliability(v)
+	}
+	return ReliabilityNoFaultDetected
+}
+
+// SetReliability 设置对象的可靠性
+func (o *BACnetObject) SetReliability(reliability Reliability) {
+	oldFlags := o.GetStatusFlags()
+	o.Properties[PropertyIdentifierReliability] = reliability
+	o.notifyStatusFlags(oldFlags)
+}
+
+// GetNotificationClass 获取通知类
+func (o *BACnetObject) GetNotificationClass() uint32 {
+	if class, exists := o.Properties[PropertyIdentifierNotificationClass]; exists {
+		if c, ok := class.(uint32); ok {
+			return c
+		}
+	}
+	return 0
+}
+
+// SetNotificationClass 设置通知类
+func (o *BACnetObject) SetNotificationClass(class uint32) {
+	o.Properties[PropertyIdentifierNotificationClass] = class
+}
+
+// GetStatusFlags 获取状态标志：IN_ALARM由Event_State、FAULT由Reliability、OUT_OF_SERVICE由Out_Of_Service决定，
+// 再合并SetStatusFlags设置的标志
+func (o *BACnetObject) GetStatusFlags() uint8 {
+	flags, _ := o.Properties[PropertyIdentifierStatusFlags].(uint8)
+	if o.GetEventState() != EventStateNormal {
+		flags |= StatusFlagInAlarm
+	}
+	if o.GetReliability() != ReliabilityNoFaultDetected {
+		flags |= StatusFlagFault
+	}
+	if o.OutOfService() {
+		flags |= StatusFlagOutOfService
+	}
+	return flags
+}
+
+// SetStatusFlags 设置对象自身状态以外的标志，如OVERRIDDEN或代理镜像的下游对象的标志
+func (o *BACnetObject) SetStatusFlags(flags uint8) {
+	oldFlags := o.GetStatusFlags()
+	o.Properties[PropertyIdentifierStatusFlags] = flags &^ StatusFlagOutOfService
+	o.notifyStatusFlags(oldFlags)
+}
+
+// GenerateEvent 生成事件
+func (o *BACnetObject) GenerateEvent(state EventState, message string) {
+	o.generateEvent(state, message, nil)
+}
+
+// generateEvent 生成带事件参数的事件，参数中的状态标志取转换后的值
+func (o *BACnetObject) generateEvent(state EventState, message string, values interface{}) {
+	fromState := o.GetEventState()
+	event := BACnetEvent{
+		EventType:         o.GetObjectType(),
+		EventState:        state,
+		TimeStamp:         o.now(),
+		MessageText:       message,
+		NotificationClass: o.GetNotificationClass(),
+	}
+	o.Events = append(o.Events, event)
+	o.SetEventState(state)
+	if v, ok := values.(OutOfRangeEventValues); ok {
+		v.StatusFlags = o.GetStatusFlags()
+		values = v
+	}
+
+	// 记录转换时间戳，并将该转换标记为未确认
+	transition := TransitionForState(state)
+	timeStamps := o.GetEventTimeStamps()
+	timeStamps[transition] = event.TimeStamp
+	o.Properties[PropertyIdentifierEventTimeStamps] = timeStamps
+	o.Properties[PropertyIdentifierAckedTransitions] = o.GetAckedTransitions() &^ (1 << transition)
+
+	o.sendEventNotification(EventNotification{
+		EventObject:       o.Identifier,
+		TimeStamp:         event.TimeStamp,
+		NotificationClass: event.NotificationClass,
+		EventType:         eventTypeForObject(o.GetObjectType()),
+		MessageText:       message,
+		NotifyType:        NotifyTypeAlarm,
+		AckRequired:       true,
+		FromState:         fromState,
+		ToState:           state,
+		EventValues:       values,
+	})
+}
+
+// AddCOVSubscription 添加一个COV订阅
+func (o *BACnetObject) AddCOVSubscription(subscription COVSubscription) {
+	o.Subscriptions = append(o.Subscriptions, subscription)
+}
+
+// COVSubscriptions 返回当前的COV订阅
+func (o *BACnetObject) COVSubscriptions() []COVSubscription {
+	return append([]COVSubscription(nil), o.Subscriptions...)
+}
+
+// RemoveCOVSubscription 移除指定ID的COV订阅
+func (o *BACnetObject) RemoveCOVSubscription(subscriptionID uint32) bool {
+	for i, sub := range o.Subscriptions {
+		if sub.SubscriptionID == subscriptionID {
+			o.Subscriptions = append(o.Subscriptions[:i], o.Subscriptions[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ExpireCOVSubscriptions 移除在now之前到期的COV订阅，返回移除的订阅
+func (o *BACnetObject) ExpireCOVSubscriptions(now time.Time) []COVSubscription {
+	var expired []COVSubscription
+	kept := o.Subscriptions[:0]
+	for _, sub := range o.Subscriptions {
+		if !sub.Expires.IsZero() && !now.Before(sub.Expires) {
+			expired = append(expired, sub)
+			continue
+		}
+		kept = append(kept, sub)
+	}
+	o.Subscriptions = kept
+	return expired
+}
+
+// NotifySubscribers 通知所有订阅者属性变化
+func (o *BACnetObject) NotifySubscribers(propertyIdentifier PropertyIdentifier, oldValue, newValue interface{}) {
+	currentTime := o.now()
+	values := o.covValues(propertyIdentifier, newValue)
+
+	for i, sub := range o.Subscriptions {
+		// 检查是否监控了该属性
+		monitorThisProperty := false
+		if len(sub.MonitoredProperties) == 0 {
+			// 没有指定监控属性，则监控所有属性
+			monitorThisProperty = true
+		} else {
+			for _, prop := range sub.MonitoredProperties {
+				if prop == propertyIdentifier {
+					monitorThisProperty = true
+					break
+				}
+			}
+		}
+
+		if monitorThisProperty && sub.ClientAddress != "" {
+			// 更新订阅时间戳
+			o.Subscriptions[i].Timestamp = currentTime
+
+			// 确认订阅通过确认请求发送，由发送器按APDU_Timeout和Number_Of_APDU_Retries重试
+			if confirmed, ok := o.Notifier.(ConfirmedNotificationSender); ok && sub.IssueConfirmedCOVNotifications {
+				if err := confirmed.SendConfirmedCOVNotification(o.Subscriptions[i], values); err != nil {
+					logger().Warn("发送确认COV通知失败", "peer", sub.ClientAddress, "subscription", sub.SubscriptionID, "error", err)
+				}
+			} else if o.Notifier != nil {
+				// 如果设置了Notifier，则使用它发送真实的COV通知
+				if err := o.Notifier.SendCOVNotification(o.Subscriptions[i], values); err != nil {
+					logger().Warn("发送COV通知失败", "peer", sub.ClientAddress, "subscription", sub.SubscriptionID, "error", err)
+				}
+			} else {
+				// 没有Notifier时，输出模拟发送日志
+				logger().Debug("未设置通知发送器，COV通知未发送", "peer", sub.ClientAddress, "subscription", sub.SubscriptionID, "object", o.Name)
+			}
+		}
+	}
+}
+
+// covValues 返回COV通知的属性值列表：Present_Value或Status_Flags变化时依次为Present_Value和Status_Flags，
+// 其他属性只有该属性的新值
+func (o *BACnetObject) covValues(prop PropertyIdentifier, newValue interface{}) []COVValue {
+	if prop != PropertyIdentifierPresentValue && prop != PropertyIdentifierStatusFlags {
+		return []COVValue{{PropertyIdentifier: prop, Value: newValue}}
+	}
+	var values []COVValue
+	if prop == PropertyIdentifierPresentValue {
+		values = append(values, COVValue{PropertyIdentifier: prop, Value: newValue})
+	} else if presentValue, ok := o.Properties[PropertyIdentifierPresentValue]; ok {
+		values = append(values, COVValue{PropertyIdentifier: PropertyIdentifierPresentValue, Value: presentValue})
+	}
+	return append(values, COVValue{PropertyIdentifier: PropertyIdentifierStatusFlags, Value: StatusFlagsBits(o.GetStatusFlags())})
+}
+
+// NewBACnetFile 创建一个新的BACnet文件对象
+func NewBACnetFile(instance uint32, name string, accessMethod FileAccessMethod) *BACnetFile {
+	fileObj := &BACnetFile{
+		BACnetObject: NewBACnetObject(ObjectTypeFile, instance, name),
+		FileData:     []byte{},
+		AccessMethod: accessMethod,
+		OpeningTag:   "",
+		ClosingTag:   "",
+	}
+
+	// 设置文件对象的基本属性
+	fileObj.WriteProperty(PropertyIdentifierFileSize, uint32(0))
+	fileObj.WriteProperty(PropertyIdentifierFileAccessMethod, accessMethod)
+	fileObj.WriteProperty(PropertyIdentifierFileOpeningTag, "")
+	fileObj.WriteProperty(PropertyIdentifierFileClosingTag, "")
+
+	return fileObj
+}
+
+// ReadFile 读取文件数据
+func (f *BACnetFile) ReadFile(start uint32, count uint32) ([]byte, error) {
+	if start >= uint32(len(f.FileData)) {
+		return []byte{}, nil
+	}
+
+	end := start + count
+	if end > uint32(len(f.FileData)) {
+		end = uint32(len(f.FileData))
+	}
+
+	return f.FileData[start:end], nil
+}
+
+// WriteFile 写入文件数据
+func (f *BACnetFile) WriteFile(start uint32, data []byte) error {
+	if start > uint32(len(f.FileData)) {
+		// 如果起始位置超出当前文件大小，先扩展文件
+		newData := make([]byte, start+uint32(len(data)))
+		copy(newData, f.FileData)
+		f.FileData = newData
+	} else if start+uint32(len(data)) > uint32(len(f.FileData)) {
+		// 如果写入超出当前文件大小，扩展文件
+		newData := make([]byte, start+uint32(len(data)))
+		copy(newData, f.FileData[:start])
+		f.FileData = newData
+	}
+
+	// 写入数据
+	copy(f.FileData[start:], data)
+
+	// 更新文件大小属性
+	f.WriteProperty(PropertyIdentifierFileSize, uint32(len(f.FileData)))
+
+	return nil
+}
+
+// DeleteFile 删除文件内容
+func (f *BACnetFile) DeleteFile() error {
+	f.FileData = []byte{}
+	f.WriteProperty(PropertyIdentifierFileSize, uint32(0))
+	return nil
+}
+
+// 确认请求的默认超时（毫秒）和重试次数
+const (
+	DefaultAPDUTimeout         uint32 = 3000
+	DefaultNumberOfAPDURetries uint32 = 3
+	DefaultAPDUSegmentTimeout  uint32 = 2000
+)
+
+// 设备协议属性的默认值
+const (
+	DefaultMaxAPDULengthAccepted uint32 = 1024
+	DefaultVendorIdentifier      uint32 = 0
+	ProtocolVersion              uint32 = 1
+	ProtocolRevision             uint32 = 22
+)
+
+// DeviceStatus 设备状态（BACnetDeviceStatus）
+type DeviceStatus uint8
+
+const (
+	DeviceStatusOperational DeviceStatus = iota
+	DeviceStatusOperationalReadOnly
+	DeviceStatusDownloadRequired
+	DeviceStatusDownloadInProgress
+	DeviceStatusNonOperational
+	DeviceStatusBackupInProgress
+)
+
+// Segmentation 设备支持的分段（BACnetSegmentation）
+type Segmentation uint8
+
+const (
+	SegmentationBoth Segmentation = iota
+	SegmentationTransmit
+	SegmentationReceive
+	SegmentationNone
+)
+
+// Device 表示BACnet设备对象
+type Device struct {
+	*BACnetObject
+	objects []Object                    // 按添加顺序排列的对象，用于Object_List
+	index   map[ObjectIdentifier]Object // 按对象标识符索引的对象
+	names   map[string]Object           // 按Object_Name索引的对象（包括设备自身）
+	mu      sync.Mutex                  // 设备锁，见Lock
+}
+
+// NewDevice 创建一个新的BACnet设备
+func NewDevice(instance uint32, name string, location string) *Device {
+	device := &Device{
+		BACnetObject: NewBACnetObject(ObjectTypeDevice, instance, name),
+		index:        map[ObjectIdentifier]Object{},
+		names:        map[string]Object{},
+	}
+
+	// 设置设备基本属性
+	device.WriteProperty(PropertyIdentifierLocation, location)
+	device.WriteProperty(PropertyIdentifierSystemStatus, DeviceStatusOperational)
+	device.WriteProperty(PropertyIdentifierVendorIdentifier, DefaultVendorIdentifier)
+	device.WriteProperty(PropertyIdentifierProtocolVersion, ProtocolVersion)
+	device.WriteProperty(PropertyIdentifierProtocolRevision, ProtocolRevision)
+	device.WriteProperty(PropertyIdentifierMaxAPDULengthAccepted, DefaultMaxAPDULengthAccepted)
+	// 应答可以分段发送，不接收分段的请求
+	device.WriteProperty(PropertyIdentifierSegmentationSupported, SegmentationTransmit)
+	device.WriteProperty(PropertyIdentifierDeviceType, "Go BACnet Server")
+	device.WriteProperty(PropertyIdentifierManufacturerName, "Go BACnet Simulator")
+	device.WriteProperty(PropertyIdentifierModelName, "Simulator v1.0")
+	device.WriteProperty(PropertyIdentifierFirmwareRevision, "1.0")
+	device.WriteProperty(PropertyIdentifierApplicationSoftwareVersion, "1.0")
+	device.WriteProperty(PropertyIdentifierDatabaseRevision, uint32(1))
+	device.WriteProperty(PropertyIdentifierAPDUTimeout, DefaultAPDUTimeout)
+	device.WriteProperty(PropertyIdentifierNumberOfAPDURetries, DefaultNumberOfAPDURetries)
+	device.WriteProperty(PropertyIdentifierAPDUSegmentTimeout, DefaultAPDUSegmentTimeout)
+	// 零值时间表示尚未恢复过，编码为通配符
+	device.WriteProperty(PropertyIdentifierLastRestoreTime, time.Time{})
+	device.WriteProperty(PropertyIdentifierTimeOfDeviceRestart, time.Time{})
+	device.names[name] = device
+	device.watchObjectName(device)
+
+	return device
+}
+
+// SetClock 设置设备及其对象的时钟，为nil时使用SystemClock，之后加入的对象同样使用该时钟
+func (d *Device) SetClock(clock Clock) {
+	d.clock = clock
+	for _, obj := range d.objects {
+		if timed, ok := obj.(interface{ SetClock(Clock) }); ok {
+			timed.SetClock(clock)
+		}
+	}
+}
+
+// Lock 锁定设备。设备和对象的方法本身不加锁，处理请求、运行对象调度器以及控制台、仪表盘、
+// 数据模拟等在其他goroutine中访问设备或其对象的代码在访问期间持有设备锁
+func (d *Device) Lock() {
+	d.mu.Lock()
+}
+
+// Unlock 解锁设备
+func (d *Device) Unlock() {
+	d.mu.Unlock()
+}
+
+// AddObject 向设备添加对象，对象名与设备内已有对象重复时返回ErrDuplicateName
+func (d *Device) AddObject(obj Object) error {
+	identifier := obj.GetObjectIdentifier()
+	if _, exists := d.index[identifier]; exists || identifier == d.Identifier {
+		return fmt.Errorf("对象 %d:%d 已存在", identifier.Type, identifier.Instance)
+	}
+	if other, exists := d.names[obj.GetObjectName()]; exists {
+		id := other.GetObjectIdentifier()
+		return fmt.Errorf("%w: %s 已被对象 %d:%d 使用", ErrDuplicateName, obj.GetObjectName(), id.Type, id.Instance)
+	}
+	d.objects = append(d.objects, obj)
+	d.index[identifier] = obj
+	d.names[obj.GetObjectName()] = obj
+	d.watchObjectName(obj)
+	if checked, ok := obj.(interface {
+		SetReferenceValidator(func(DeviceObjectPropertyReference) error)
+	}); ok {
+		checked.SetReferenceValidator(d.checkReference)
+	}
+	if timed, ok := obj.(interface{ SetClock(Clock) }); ok {
+		timed.SetClock(d.clock)
+	}
+	d.IncrementDatabaseRevision()
+	return nil
+}
+
+// RemoveObject 从设备中删除对象，对象不存在时返回false
+func (d *Device) RemoveObject(identifier ObjectIdentifier) bool {
+	if _, exists := d.index[identifier]; !exists {
+		return false
+	}
+	for i, obj := range d.objects {
+		if obj.GetObjectIdentifier() == identifier {
+			d.objects = append(d.objects[:i], d.objects[i+1:]...)
+			delete(d.index, identifier)
+			if d.names[obj.GetObjectName()] == obj {
+				delete(d.names, obj.GetObjectName())
+			}
+			if validated, ok := obj.(interface{ SetNameValidator(func(string) error) }); ok {
+				validated.SetNameValidator(nil)
+			}
+			if checked, ok := obj.(interface {
+				SetReferenceValidator(func(DeviceObjectPropertyReference) error)
+			}); ok {
+				checked.SetReferenceValidator(nil)
+			}
+			d.IncrementDatabaseRevision()
+			return true
+		}
+	}
+	return false
+}
+
+// FindObjectByName 通过Object_Name查找对象（包括设备自身），不存在时返回nil
+func (d *Device) FindObjectByName(name string) Object {
+	return d.names[name]
+}
+
+// DatabaseRevision 返回设备的Database_Revision
+func (d *Device) DatabaseRevision() uint32 {
+	revision, _ := d.Properties[PropertyIdentifierDatabaseRevision].(uint32)
+	return revision
+}
+
+// IncrementDatabaseRevision 对象创建、删除或改名时递增Database_Revision，供监控系统检测配置变化
+func (d *Device) IncrementDatabaseRevision() {
+	d.WriteProperty(PropertyIdentifierDatabaseRevision, d.DatabaseRevision()+1)
+}
+
+// watchObjectName 拒绝与其他对象重名的改名，改名后更新名称索引并递增Database_Revision，已删除的对象不再计入
+func (d *Device) watchObjectName(obj Object) {
+	identifier := obj.GetObjectIdentifier()
+	if validated, ok := obj.(interface{ SetNameValidator(func(string) error) }); ok {
+		validated.SetNameValidator(func(name string) error {
+			if other, exists := d.names[name]; exists && other.GetObjectIdentifier() != identifier {
+				return fmt.Errorf("%w: %s", ErrDuplicateName, name)
+			}
+			return nil
+		})
+	}
+	observable, ok := obj.(interface{ AddPropertyObserver(PropertyObserver) })
+	if !ok {
+		return
+	}
+	current := obj.GetObjectName()
+	observable.AddPropertyObserver(func(_ Object, prop PropertyIdentifier, value interface{}) {
+		if prop != PropertyIdentifierObjectName {
+			return
+		}
+		if identifier == d.Identifier || d.FindObject(identifier) != nil {
+			delete(d.names, current)
+			current, _ = value.(string)
+			d.names[current] = obj
+			d.IncrementDatabaseRevision()
+		}
+	})
+}
+
+// ObjectList 返回设备包含的全部对象标识符，设备对象自身排在第一个
+func (d *Device) ObjectList() []ObjectIdentifier {
+	list := make([]ObjectIdentifier, 0, len(d.objects)+1)
+	list = append(list, d.Identifier)
+	for _, obj := range d.objects {
+		list = append(list, obj.GetObjectIdentifier())
+	}
+	return list
+}
+
+// WriteProperty 写入设备属性，超时属性必须大于0
+func (d *Device) WriteProperty(prop PropertyIdentifier, value interface{}) error {
+	switch prop {
+	case PropertyIdentifierAPDUTimeout, PropertyIdentifierAPDUSegmentTimeout:
+		if ms, ok := value.(uint32); ok && ms == 0 {
+			return ErrValueOutOfRange
+		}
+	}
+	return d.BACnetObject.WriteProperty(prop, value)
+}
+
+// APDUTimeout 返回确认请求等待应答的超时时间
+func (d *Device) APDUTimeout() time.Duration {
+	return d.durationProperty(PropertyIdentifierAPDUTimeout, DefaultAPDUTimeout)
+}
+
+// APDUSegmentTimeout 返回分段传输中等待下一分段或SegmentAck的超时时间
+func (d *Device) APDUSegmentTimeout() time.Duration {
+	return d.durationProperty(PropertyIdentifierAPDUSegmentTimeout, DefaultAPDUSegmentTimeout)
+}
+
+// NumberOfAPDURetries 返回确认请求超时后的重试次数
+func (d *Device) NumberOfAPDURetries() int {
+	retries, ok := d.Properties[PropertyIdentifierNumberOfAPDURetries].(uint32)
+	if !ok {
+		return int(DefaultNumberOfAPDURetries)
+	}
+	return int(retries)
+}
+
+// TimeSynchronizationInterval 返回定期发送时间同步的周期（Time_Synchronization_Interval，分钟），为0时不定期发送
+func (d *Device) TimeSynchronizationInterval() time.Duration {
+	minutes, _ := d.Properties[PropertyIdentifierTimeSynchronizationInterval].(uint32)
+	return time.Duration(minutes) * time.Minute
+}
+
+// AlignIntervals 返回定期的时间同步是否对齐到整点或零点（Align_Intervals），
+// 对齐时offset为周期开始后的偏移（Interval_Offset）
+func (d *Device) AlignIntervals() (align bool, offset time.Duration) {
+	align, _ = d.Properties[PropertyIdentifierAlignIntervals].(bool)
+	minutes, _ := d.Properties[PropertyIdentifierIntervalOffset].(uint32)
+	return align, time.Duration(minutes) * time.Minute
+}
+
+// LocalTime 返回t对应的设备本地时间：设置了UTC_Offset（UTC减本地标准时间的分钟数）时按其换算，否则为t本身
+func (d *Device) LocalTime(t time.Time) time.Time {
+	offset, ok := d.Properties[PropertyIdentifierUTCOffset].(int32)
+	if !ok {
+		return t
+	}
+	return t.UTC().Add(-time.Duration(offset) * time.Minute)
+}
+
+// durationProperty 将以毫秒为单位的属性转换为时间间隔
+func (d *Device) durationProperty(prop PropertyIdentifier, fallback uint32) time.Duration {
+	ms, ok := d.Properties[prop].(uint32)
+	if !ok || ms == 0 {
+		ms = fallback
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// ReadProperty 读取设备属性，Object_List根据当前对象实时生成
+func (d *Device) ReadProperty(prop PropertyIdentifier) (interface{}, error) {
+	switch prop {
+	case PropertyIdentifierObjectList:
+		return d.ObjectList(), nil
+	case PropertyIdentifierProtocolObjectTypesSupported:
+		return ObjectTypesSupported(), nil
+	}
+	return d.BACnetObject.ReadProperty(prop)
+}
+
+// ExpireCOVSubscriptions 移除设备及其对象中在now之前到期的COV订阅，返回移除的订阅
+func (d *Device) ExpireCOVSubscriptions(now time.Time) []COVSubscription {
+	expired := d.BACnetObject.ExpireCOVSubscriptions(now)
+	for _, obj := range d.objects {
+		if o, ok := obj.(interface {
+			ExpireCOVSubscriptions(now time.Time) []COVSubscription
+		}); ok {
+			expired = append(expired, o.ExpireCOVSubscriptions(now)...)
+		}
+	}
+	return expired
+}
+
+// Execute 驱动设备中所有需要周期性执行的对象
+func (d *Device) Execute(now time.Time) {
+	for _, obj := range d.objects {
+		if e, ok := obj.(Executable); ok {
+			e.Execute(d, now)
+		}
+	}
+}
+
+// ResolveReference 解析设备对象属性引用，返回被引用的对象（可以是设备自身）
+func (d *Device) ResolveReference(ref DeviceObjectPropertyReference) (Object, error) {
+	if err := d.checkReference(ref); err != nil {
+		return nil, err
+	}
+	if ref.ObjectIdentifier == d.Identifier {
+		return d, nil
+	}
+	obj := d.FindObject(ref.ObjectIdentifier)
+	if obj == nil {
+		return nil, fmt.Errorf("引用的对象不存在: %d:%d", ref.ObjectIdentifier.Type, ref.ObjectIdentifier.Instance)
+	}
+	return obj, nil
+}
+
+// checkReference 检查引用是否指向本设备，引用其他设备时返回ErrRemoteReference
+func (d *Device) checkReference(ref DeviceObjectPropertyReference) error {
+	if ref.DeviceIdentifier != nil && *ref.DeviceIdentifier != d.Identifier {
+		return fmt.Errorf("%w: device:%d", ErrRemoteReference, ref.DeviceIdentifier.Instance)
+	}
+	return nil
+}
+
+// FindObject 通过标识符查找对象（不包括设备自身）
+func (d *Device) FindObject(identifier ObjectIdentifier) Object {
+	return d.index[identifier]
+}
+
+// Objects 返回设备中按添加顺序排列的对象，调用方不应修改返回的切片
+func (d *Device) Objects() []Object {
+	return d.objects
+}
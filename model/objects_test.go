@@ -0,0 +1,78 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDeviceClock(t *testing.T) {
+	start := time.Date(2024, 1, 15, 8, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	device := NewDevice(1, "Test Device", "")
+	before := NewAnalogValue(1, "Before", UnitsNoUnits)
+	device.AddObject(before)
+	if device.Clock() != SystemClock || before.Clock() != SystemClock {
+		t.Fatal("default clock is not SystemClock")
+	}
+
+	// 设备的时钟传给已有的和之后加入的对象，事件时间戳取自对象的时钟
+	device.SetClock(clock)
+	after := NewAnalogValue(2, "After", UnitsNoUnits)
+	device.AddObject(after)
+	if before.Clock() != clock || after.Clock() != clock {
+		t.Errorf("object clocks = %v, %v, want the device clock", before.Clock(), after.Clock())
+	}
+	clock.Advance(time.Minute)
+	after.GenerateEvent(EventStateHighLimit, "too high")
+	if got := after.Events[len(after.Events)-1].TimeStamp; !got.Equal(start.Add(time.Minute)) {
+		t.Errorf("event time stamp = %v, want %v", got, start.Add(time.Minute))
+	}
+
+	device.SetClock(nil)
+	if before.Clock() != SystemClock {
+		t.Error("object clock not reset with the device clock")
+	}
+}
+
+// benchmarkDevice 创建包含大量点位的设备，用于对象查找基准测试
+func benchmarkDevice(b *testing.B, points uint32) *Device {
+	b.Helper()
+	device := NewDevice(1, "Benchmark Device", "")
+	for i := uint32(1); i <= points; i++ {
+		if err := device.AddObject(NewAnalogValue(i, fmt.Sprintf("Point %d", i), UnitsNoUnits)); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return device
+}
+
+func BenchmarkFindObject(b *testing.B) {
+	device := benchmarkDevice(b, 10000)
+	last := ObjectIdentifier{Type: ObjectTypeAnalogValue, Instance: 10000}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if device.FindObject(last) == nil {
+			b.Fatal("object not found")
+		}
+	}
+}
+
+// BenchmarkFindObjectLinearScan 作为对照的逐个比较查找方式
+func BenchmarkFindObjectLinearScan(b *testing.B) {
+	device := benchmarkDevice(b, 10000)
+	last := ObjectIdentifier{Type: ObjectTypeAnalogValue, Instance: 10000}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var found Object
+		for _, obj := range device.Objects() {
+			if obj.GetObjectIdentifier() == last {
+				found = obj
+				break
+			}
+		}
+		if found == nil {
+			b.Fatal("object not found")
+		}
+	}
+}
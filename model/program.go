@@ -0,0 +1,187 @@
+package model
+
+import (
+	"fmt"
+)
+
+// ProgramState 程序状态（BACnetProgramState）
+type ProgramState uint8
+
+const (
+	ProgramStateIdle ProgramState = iota
+	ProgramStateLoading
+	ProgramStateRunning
+	ProgramStateWaiting
+	ProgramStateHalted
+	ProgramStateUnloading
+)
+
+// ProgramChange 程序控制请求（BACnetProgramRequest）
+type ProgramChange uint8
+
+const (
+	ProgramChangeReady ProgramChange = iota
+	ProgramChangeLoad
+	ProgramChangeRun
+	ProgramChangeHalt
+	ProgramChangeRestart
+	ProgramChangeUnload
+)
+
+// ProgramError 停止原因（BACnetProgramError）
+type ProgramError uint8
+
+const (
+	ProgramErrorNormal ProgramError = iota
+	ProgramErrorLoadFailed
+	ProgramErrorInternal
+	ProgramErrorProgram
+	ProgramErrorOther
+)
+
+// ProgramCallback 程序控制回调，由嵌入方注册以执行LOAD/RUN/HALT/RESTART/UNLOAD
+// 返回错误时程序进入HALTED状态，错误信息写入Description_Of_Halt
+type ProgramCallback func(program *Program, change ProgramChange) error
+
+// Program 表示BACnet程序对象，客户端写Program_Change驱动嵌入方的Go代码
+type Program struct {
+	*BACnetObject
+	State             ProgramState
+	ReasonForHalt     ProgramError
+	DescriptionOfHalt string
+	ProgramLocation   string
+	InstanceOf        string
+	callback          ProgramCallback
+}
+
+// NewProgram 创建一个新的程序对象
+func NewProgram(instance uint32, name string, callback ProgramCallback) *Program {
+	return &Program{
+		BACnetObject: NewBACnetObject(ObjectTypeProgram, instance, name),
+		State:        ProgramStateIdle,
+		InstanceOf:   name,
+		callback:     callback,
+	}
+}
+
+// SetCallback 注册程序控制回调
+func (p *Program) SetCallback(callback ProgramCallback) {
+	p.callback = callback
+}
+
+// SetState 由程序自身更新运行状态（如进入WAITING）
+func (p *Program) SetState(state ProgramState) {
+	p.State = state
+	p.Properties[PropertyIdentifierProgramState] = state
+}
+
+// Halt 由程序自身报告停止及原因
+func (p *Program) Halt(reason ProgramError, description string) {
+	p.ReasonForHalt = reason
+	p.DescriptionOfHalt = description
+	p.SetState(ProgramStateHalted)
+	logger().Info("程序已停止", "object", p.Name, "reason", reason, "description", description)
+}
+
+// Request 处理程序控制请求
+func (p *Program) Request(change ProgramChange) error {
+	if change == ProgramChangeReady {
+		return nil
+	}
+	if !programChangeAllowed(p.State, change) {
+		return fmt.Errorf("程序状态%d下不允许请求%d", p.State, change)
+	}
+
+	// 未加载的程序在RUN时先加载
+	if p.State == ProgramStateIdle && change == ProgramChangeRun {
+		if err := p.Request(ProgramChangeLoad); err != nil {
+			return err
+		}
+		if p.ReasonForHalt == ProgramErrorLoadFailed {
+			return nil
+		}
+	}
+
+	switch change {
+	case ProgramChangeLoad:
+		p.SetState(ProgramStateLoading)
+	case ProgramChangeUnload:
+		p.SetState(ProgramStateUnloading)
+	}
+
+	if p.callback != nil {
+		if err := p.callback(p, change); err != nil {
+			reason := ProgramErrorProgram
+			if change == ProgramChangeLoad {
+				reason = ProgramErrorLoadFailed
+			}
+			p.Halt(reason, err.Error())
+			return nil
+		}
+	}
+
+	switch change {
+	case ProgramChangeLoad, ProgramChangeHalt:
+		p.ReasonForHalt = ProgramErrorNormal
+		p.DescriptionOfHalt = ""
+		p.SetState(ProgramStateHalted)
+	case ProgramChangeRun, ProgramChangeRestart:
+		p.SetState(ProgramStateRunning)
+	case ProgramChangeUnload:
+		p.SetState(ProgramStateIdle)
+	}
+	logger().Debug("程序处理请求", "object", p.Name, "change", change, "state", p.State)
+	return nil
+}
+
+// programChangeAllowed 判断当前状态下是否允许该请求
+func programChangeAllowed(state ProgramState, change ProgramChange) bool {
+	switch change {
+	case ProgramChangeLoad:
+		return state == ProgramStateIdle || state == ProgramStateHalted
+	case ProgramChangeRun:
+		return state == ProgramStateIdle || state == ProgramStateHalted || state == ProgramStateRunning
+	case ProgramChangeHalt:
+		return state == ProgramStateRunning || state == ProgramStateWaiting || state == ProgramStateHalted
+	case ProgramChangeRestart:
+		return state != ProgramStateIdle && state != ProgramStateLoading && state != ProgramStateUnloading
+	case ProgramChangeUnload:
+		return state != ProgramStateIdle && state != ProgramStateLoading && state != ProgramStateUnloading
+	}
+	return false
+}
+
+// ReadProperty 读取程序属性
+func (p *Program) ReadProperty(prop PropertyIdentifier) (interface{}, error) {
+	switch prop {
+	case PropertyIdentifierProgramState:
+		return p.State, nil
+	case PropertyIdentifierProgramChange:
+		// 请求同步处理，读取时总是READY
+		return ProgramChangeReady, nil
+	case PropertyIdentifierReasonForHalt:
+		return p.ReasonForHalt, nil
+	case PropertyIdentifierDescriptionOfHalt:
+		return p.DescriptionOfHalt, nil
+	case PropertyIdentifierProgramLocation:
+		return p.ProgramLocation, nil
+	case PropertyIdentifierInstanceOf:
+		return p.InstanceOf, nil
+	}
+	return p.BACnetObject.ReadProperty(prop)
+}
+
+// WriteProperty 写入程序属性，Program_Change触发程序控制请求
+func (p *Program) WriteProperty(prop PropertyIdentifier, value interface{}) error {
+	switch prop {
+	case PropertyIdentifierProgramChange:
+		change, ok := toUint32(value)
+		if !ok || change > uint32(ProgramChangeUnload) {
+			return fmt.Errorf("Program_Change值无效")
+		}
+		return p.Request(ProgramChange(change))
+	case PropertyIdentifierProgramState, PropertyIdentifierReasonForHalt, PropertyIdentifierDescriptionOfHalt:
+		return fmt.Errorf("只读属性")
+	}
+	return p.BACnetObject.WriteProperty(prop, value)
+}
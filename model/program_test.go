@@ -0,0 +1,84 @@
+package model
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestProgramChange(t *testing.T) {
+	var changes []ProgramChange
+	var fail error
+	program := NewProgram(1, "Program", func(p *Program, change ProgramChange) error {
+		changes = append(changes, change)
+		return fail
+	})
+
+	// 写Program_Change驱动状态机，读取Program_Change总是READY
+	for _, tt := range []struct {
+		change ProgramChange
+		state  ProgramState
+	}{
+		{ProgramChangeLoad, ProgramStateHalted},
+		{ProgramChangeRun, ProgramStateRunning},
+		{ProgramChangeHalt, ProgramStateHalted},
+	} {
+		if err := program.WriteProperty(PropertyIdentifierProgramChange, uint32(tt.change)); err != nil {
+			t.Fatalf("WriteProperty(Program_Change=%d) = %v", tt.change, err)
+		}
+		if got, _ := program.ReadProperty(PropertyIdentifierProgramState); got != tt.state {
+			t.Errorf("after change %d Program_State = %v, want %d", tt.change, got, tt.state)
+		}
+	}
+	if got, _ := program.ReadProperty(PropertyIdentifierProgramChange); got != ProgramChangeReady {
+		t.Errorf("Program_Change = %v, want ready", got)
+	}
+	if want := []ProgramChange{ProgramChangeLoad, ProgramChangeRun, ProgramChangeHalt}; !reflect.DeepEqual(changes, want) {
+		t.Errorf("callback changes = %v, want %v", changes, want)
+	}
+
+	// 回调失败时程序停止并记录原因
+	fail = errors.New("script error")
+	if err := program.WriteProperty(PropertyIdentifierProgramChange, uint32(ProgramChangeRun)); err != nil {
+		t.Fatal(err)
+	}
+	if program.State != ProgramStateHalted || program.ReasonForHalt != ProgramErrorProgram || program.DescriptionOfHalt != "script error" {
+		t.Errorf("after failed run state = %d, reason = %d, description = %q", program.State, program.ReasonForHalt, program.DescriptionOfHalt)
+	}
+
+	// 当前状态下不允许的请求被拒绝，且不调用回调
+	fail = nil
+	program.SetState(ProgramStateIdle)
+	calls := len(changes)
+	if err := program.WriteProperty(PropertyIdentifierProgramChange, uint32(ProgramChangeHalt)); err == nil {
+		t.Error("WriteProperty(Program_Change=halt) in idle succeeded")
+	}
+	if len(changes) != calls {
+		t.Errorf("callback invoked for rejected change: %v", changes[calls:])
+	}
+	if err := program.WriteProperty(PropertyIdentifierProgramState, uint32(ProgramStateRunning)); err == nil {
+		t.Error("WriteProperty(Program_State) succeeded")
+	}
+}
+
+func TestProgramRunLoadsFirst(t *testing.T) {
+	var changes []ProgramChange
+	program := NewProgram(1, "Program", func(p *Program, change ProgramChange) error {
+		changes = append(changes, change)
+		if change == ProgramChangeLoad {
+			return errors.New("file not found")
+		}
+		return nil
+	})
+
+	// 未加载的程序在RUN时先加载，加载失败时停止且不再运行
+	if err := program.Request(ProgramChangeRun); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(changes, []ProgramChange{ProgramChangeLoad}) {
+		t.Errorf("callback changes = %v, want [load]", changes)
+	}
+	if program.State != ProgramStateHalted || program.ReasonForHalt != ProgramErrorLoadFailed {
+		t.Errorf("state = %d, reason = %d, want halted with load-failed", program.State, program.ReasonForHalt)
+	}
+}
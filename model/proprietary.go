@@ -0,0 +1,88 @@
+package model
+
+import "fmt"
+
+// 厂商专有编号的起始值
+const (
+	FirstProprietaryObjectType ObjectType         = 128
+	LastProprietaryObjectType  ObjectType         = 1023
+	FirstProprietaryProperty   PropertyIdentifier = 512
+	LastProprietaryProperty    PropertyIdentifier = 4194303
+)
+
+// PropertyEncoder 将专有属性值编码为BACnet应用层数据
+type PropertyEncoder func(value interface{}) ([]byte, error)
+
+// PropertyDecoder 从BACnet应用层数据解码专有属性值，返回值和消耗的字节数
+type PropertyDecoder func(data []byte) (interface{}, int, error)
+
+// ProprietaryProperty 描述一个厂商专有属性及其编解码函数
+type ProprietaryProperty struct {
+	Identifier PropertyIdentifier
+	Name       string
+	Encode     PropertyEncoder // 为nil时使用默认编码
+	Decode     PropertyDecoder // 为nil时属性只读
+}
+
+// ProprietaryObjectType 描述一个厂商专有对象类型
+type ProprietaryObjectType struct {
+	Type       ObjectType
+	Name       string
+	Properties []PropertyMetadata // 除Object_Identifier、Object_Name、Object_Type和Description之外的属性
+}
+
+// proprietaryProperties 已注册的专有属性
+var proprietaryProperties = map[PropertyIdentifier]ProprietaryProperty{
+	PropertyIdentifierFileOpeningTag:        {Identifier: PropertyIdentifierFileOpeningTag, Name: "file-opening-tag"},
+	PropertyIdentifierFileClosingTag:        {Identifier: PropertyIdentifierFileClosingTag, Name: "file-closing-tag"},
+	PropertyIdentifierTimeOfStateChange:     {Identifier: PropertyIdentifierTimeOfStateChange, Name: "time-of-state-change"},
+	PropertyIdentifierTimeOfLastStateChange: {Identifier: PropertyIdentifierTimeOfLastStateChange, Name: "time-of-last-state-change"},
+}
+
+// proprietaryObjectTypeNames 已注册的专有对象类型名称
+var proprietaryObjectTypeNames = map[ObjectType]string{}
+
+// RegisterProprietaryProperty 注册专有属性，并将其作为可选属性加入指定对象类型的属性元数据
+func RegisterProprietaryProperty(prop ProprietaryProperty, datatype Datatype, objTypes ...ObjectType) error {
+	if prop.Identifier < FirstProprietaryProperty || prop.Identifier > LastProprietaryProperty {
+		return fmt.Errorf("专有属性编号必须在%d-%d之间: %d", FirstProprietaryProperty, LastProprietaryProperty, prop.Identifier)
+	}
+	if existing, ok := proprietaryProperties[prop.Identifier]; ok {
+		return fmt.Errorf("专有属性%d已注册为%s", prop.Identifier, existing.Name)
+	}
+	proprietaryProperties[prop.Identifier] = prop
+
+	meta := propOptional(prop.Identifier, datatype, prop.Decode != nil)
+	for _, objType := range objTypes {
+		if _, exists := LookupPropertyMetadata(objType, prop.Identifier); !exists {
+			propertyRegistry[objType] = append(propertyRegistry[objType], meta)
+		}
+	}
+	return nil
+}
+
+// RegisterProprietaryObjectType 注册专有对象类型及其属性元数据
+func RegisterProprietaryObjectType(objType ProprietaryObjectType) error {
+	if objType.Type < FirstProprietaryObjectType || objType.Type > LastProprietaryObjectType {
+		return fmt.Errorf("专有对象类型编号必须在%d-%d之间: %d", FirstProprietaryObjectType, LastProprietaryObjectType, objType.Type)
+	}
+	if name, ok := proprietaryObjectTypeNames[objType.Type]; ok {
+		return fmt.Errorf("专有对象类型%d已注册为%s", objType.Type, name)
+	}
+	proprietaryObjectTypeNames[objType.Type] = objType.Name
+	// 保留先于对象类型注册的专有属性
+	registered := propertyRegistry[objType.Type]
+	propertyRegistry[objType.Type] = objectProperties(objType.Properties)
+	for _, meta := range registered {
+		if _, exists := LookupPropertyMetadata(objType.Type, meta.Identifier); !exists {
+			propertyRegistry[objType.Type] = append(propertyRegistry[objType.Type], meta)
+		}
+	}
+	return nil
+}
+
+// LookupProprietaryProperty 查找已注册的专有属性
+func LookupProprietaryProperty(prop PropertyIdentifier) (ProprietaryProperty, bool) {
+	p, ok := proprietaryProperties[prop]
+	return p, ok
+}
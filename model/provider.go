@@ -0,0 +1,66 @@
+package model
+
+// PropertyProvider 从外部数据源（Modbus、串口、数据库等）提供属性值，
+// 绑定后读写该属性都经过提供者，不再使用对象内存中的值
+type PropertyProvider interface {
+	ReadProperty(obj Object, prop PropertyIdentifier) (interface{}, error)
+	WriteProperty(obj Object, prop PropertyIdentifier, value interface{}, priority uint8) error
+}
+
+// ProviderFuncs 以函数实现PropertyProvider，Write为nil时属性只读
+type ProviderFuncs struct {
+	Read  func(obj Object, prop PropertyIdentifier) (interface{}, error)
+	Write func(obj Object, prop PropertyIdentifier, value interface{}, priority uint8) error
+}
+
+// ReadProperty 调用Read
+func (f ProviderFuncs) ReadProperty(obj Object, prop PropertyIdentifier) (interface{}, error) {
+	return f.Read(obj, prop)
+}
+
+// WriteProperty 调用Write，Write为nil时返回ErrWriteAccessDenied
+func (f ProviderFuncs) WriteProperty(obj Object, prop PropertyIdentifier, value interface{}, priority uint8) error {
+	if f.Write == nil {
+		return ErrWriteAccessDenied
+	}
+	return f.Write(obj, prop, value, priority)
+}
+
+// ProvidedObject 定义可以将属性绑定到外部提供者的对象，嵌入BACnetObject的对象都实现该接口
+type ProvidedObject interface {
+	PropertyProvider(prop PropertyIdentifier) PropertyProvider
+}
+
+// SetPropertyProvider 将属性绑定到外部提供者，provider为nil时解除绑定
+func (o *BACnetObject) SetPropertyProvider(prop PropertyIdentifier, provider PropertyProvider) {
+	if provider == nil {
+		delete(o.providers, prop)
+		return
+	}
+	if o.providers == nil {
+		o.providers = make(map[PropertyIdentifier]PropertyProvider)
+	}
+	o.providers[prop] = provider
+}
+
+// PropertyProvider 返回属性绑定的提供者，未绑定时为nil
+func (o *BACnetObject) PropertyProvider(prop PropertyIdentifier) PropertyProvider {
+	return o.providers[prop]
+}
+
+// providerOf 返回对象属性绑定的提供者
+func providerOf(obj Object, prop PropertyIdentifier) PropertyProvider {
+	if p, ok := obj.(ProvidedObject); ok {
+		return p.PropertyProvider(prop)
+	}
+	return nil
+}
+
+// ReadPropertyValue 读取属性当前值，属性绑定了提供者时从提供者读取。
+// 提供者的值变化不会自动通知COV订阅者，需要时由数据源调用NotifySubscribers
+func ReadPropertyValue(obj Object, prop PropertyIdentifier) (interface{}, error) {
+	if provider := providerOf(obj, prop); provider != nil {
+		return provider.ReadProperty(obj, prop)
+	}
+	return obj.ReadProperty(prop)
+}
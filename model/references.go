@@ -0,0 +1,50 @@
+package model
+
+// DeviceObjectPropertyReference 表示设备对象属性引用（BACnetDeviceObjectPropertyReference）
+type DeviceObjectPropertyReference struct {
+	ObjectIdentifier   ObjectIdentifier
+	PropertyIdentifier PropertyIdentifier
+	ArrayIndex         *uint32           // 可选：数组下标
+	DeviceIdentifier   *ObjectIdentifier // 可选：为空表示本设备
+}
+
+// ObjectPropertyReference 表示本设备内的对象属性引用（BACnetObjectPropertyReference）
+type ObjectPropertyReference struct {
+	ObjectIdentifier   ObjectIdentifier
+	PropertyIdentifier PropertyIdentifier
+	ArrayIndex         *uint32 // 可选：数组下标
+}
+
+// Address BACnet地址（BACnetAddress），Network为0表示本地网络，MAC为空表示广播
+type Address struct {
+	Network uint16
+	MAC     []byte
+}
+
+// AddressBinding 设备与其BACnet地址的绑定（BACnetAddressBinding），Device_Address_Binding的元素
+type AddressBinding struct {
+	Device  ObjectIdentifier
+	Address Address
+}
+
+// Recipient 通知接收者（BACnetRecipient），Device和Address二选一
+type Recipient struct {
+	Device  *ObjectIdentifier
+	Address *Address
+}
+
+// Destination 通知类对象Recipient_List中的条目（BACnetDestination）
+type Destination struct {
+	ValidDays                   BitString // 7位：星期一...星期日
+	FromTime                    Time
+	ToTime                      Time
+	Recipient                   Recipient
+	ProcessIdentifier           uint32
+	IssueConfirmedNotifications bool
+	Transitions                 BitString // 3位：to-offnormal、to-fault、to-normal
+}
+
+// SetpointReference 回路设定值引用（BACnetSetpointReference），Reference为空表示使用Setpoint属性
+type SetpointReference struct {
+	Reference *ObjectPropertyReference
+}
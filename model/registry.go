@@ -0,0 +1,568 @@
+package model
+
+import (
+	"errors"
+	"reflect"
+	"time"
+)
+
+// ErrUnknownProperty 对象类型不支持该属性
+var ErrUnknownProperty = errors.New("unknown property")
+
+// ErrInvalidDataType 写入值的数据类型与属性定义不符
+var ErrInvalidDataType = errors.New("invalid data type")
+
+// Datatype 属性的BACnet数据类型
+type Datatype uint8
+
+const (
+	DatatypeAny Datatype = iota // 构造类型、列表或数组，不做类型检查
+	DatatypeBoolean
+	DatatypeUnsigned
+	DatatypeSigned
+	DatatypeReal
+	DatatypeDouble
+	DatatypeOctetString
+	DatatypeCharacterString
+	DatatypeBitString
+	DatatypeEnumerated
+	DatatypeDate
+	DatatypeTime
+	DatatypeObjectIdentifier
+	DatatypeDateTime // BACnetDateTime
+)
+
+// Accepts 判断值的Go类型是否符合该数据类型
+func (d Datatype) Accepts(value interface{}) bool {
+	kind := reflect.ValueOf(value).Kind()
+	isUnsigned := kind >= reflect.Uint && kind <= reflect.Uint64
+	isSigned := kind >= reflect.Int && kind <= reflect.Int64
+	switch d {
+	case DatatypeBoolean:
+		return kind == reflect.Bool
+	case DatatypeUnsigned:
+		return isUnsigned
+	case DatatypeSigned:
+		return isSigned
+	case DatatypeReal, DatatypeDouble:
+		return kind == reflect.Float32 || kind == reflect.Float64
+	case DatatypeOctetString:
+		_, ok := value.([]byte)
+		return ok
+	case DatatypeCharacterString:
+		return kind == reflect.String
+	case DatatypeBitString:
+		_, ok := value.(BitString)
+		return ok || isUnsigned
+	case DatatypeEnumerated:
+		// 二进制对象的BACnetBinaryPV以bool表示
+		return isUnsigned || kind == reflect.Bool
+	case DatatypeDate:
+		_, isDate := value.(Date)
+		_, isTime := value.(time.Time)
+		return isDate || isTime
+	case DatatypeTime:
+		_, isTimeOfDay := value.(Time)
+		_, isTime := value.(time.Time)
+		return isTimeOfDay || isTime
+	case DatatypeObjectIdentifier:
+		_, ok := value.(ObjectIdentifier)
+		return ok
+	case DatatypeDateTime:
+		_, ok := toDateTime(value)
+		return ok
+	}
+	return true
+}
+
+// Conformance 属性一致性代码
+type Conformance uint8
+
+const (
+	ConformanceRequired Conformance = iota // R：必需，只读
+	ConformanceWritable                    // W：必需，可写
+	ConformanceOptional                    // O：可选
+)
+
+// PropertyMetadata 描述对象类型中的一个属性
+type PropertyMetadata struct {
+	Identifier  PropertyIdentifier
+	Datatype    Datatype
+	Conformance Conformance
+	Writable    bool        // 是否允许通过WriteProperty写入
+	Default     interface{} // 创建对象时自动填充的默认值，nil表示不填充
+}
+
+// propRequired 必需的只读属性
+func propRequired(id PropertyIdentifier, datatype Datatype) PropertyMetadata {
+	return PropertyMetadata{Identifier: id, Datatype: datatype, Conformance: ConformanceRequired}
+}
+
+// propWritable 必需的可写属性
+func propWritable(id PropertyIdentifier, datatype Datatype) PropertyMetadata {
+	return PropertyMetadata{Identifier: id, Datatype: datatype, Conformance: ConformanceWritable, Writable: true}
+}
+
+// propOptional 可选属性
+func propOptional(id PropertyIdentifier, datatype Datatype, writable bool) PropertyMetadata {
+	return PropertyMetadata{Identifier: id, Datatype: datatype, Conformance: ConformanceOptional, Writable: writable}
+}
+
+// withDefault 为属性设置默认值
+func withDefault(meta PropertyMetadata, value interface{}) PropertyMetadata {
+	meta.Default = value
+	return meta
+}
+
+// writableRequired 必需但实现上允许写入的属性（如Out_Of_Service）
+func writableRequired(id PropertyIdentifier, datatype Datatype) PropertyMetadata {
+	meta := propRequired(id, datatype)
+	meta.Writable = true
+	return meta
+}
+
+var (
+	// statusProperties 点对象共有的状态属性
+	statusProperties = []PropertyMetadata{
+		propRequired(PropertyIdentifierStatusFlags, DatatypeBitString),
+		withDefault(propRequired(PropertyIdentifierEventState, DatatypeEnumerated), EventStateNormal),
+		withDefault(writableRequired(PropertyIdentifierOutOfService, DatatypeBoolean), false),
+		propOptional(PropertyIdentifierReliability, DatatypeEnumerated, true),
+	}
+
+	// intrinsicReportingProperties 内部告警相关的可选属性
+	intrinsicReportingProperties = []PropertyMetadata{
+		propOptional(PropertyIdentifierTimeDelay, DatatypeUnsigned, true),
+		propOptional(PropertyIdentifierTimeDelayNormal, DatatypeUnsigned, true),
+		propOptional(PropertyIdentifierNotificationClass, DatatypeUnsigned, true),
+		propOptional(PropertyIdentifierEventEnable, DatatypeBitString, true),
+		propOptional(PropertyIdentifierAckedTransitions, DatatypeBitString, false),
+		propOptional(PropertyIdentifierNotifyType, DatatypeEnumerated, true),
+		propOptional(PropertyIdentifierEventTimeStamps, DatatypeAny, false),
+		propOptional(PropertyIdentifierEventDetectionEnable, DatatypeBoolean, true),
+	}
+
+	// logProperties 日志对象共有的缓冲区属性
+	logProperties = []PropertyMetadata{
+		propRequired(PropertyIdentifierStatusFlags, DatatypeBitString),
+		withDefault(propRequired(PropertyIdentifierEventState, DatatypeEnumerated), EventStateNormal),
+		propWritable(PropertyIdentifierLogEnable, DatatypeBoolean),
+		propOptional(PropertyIdentifierStartTime, DatatypeDateTime, true),
+		propOptional(PropertyIdentifierStopTime, DatatypeDateTime, true),
+		writableRequired(PropertyIdentifierStopWhenFull, DatatypeBoolean),
+		propRequired(PropertyIdentifierBufferSize, DatatypeUnsigned),
+		propRequired(PropertyIdentifierLogBuffer, DatatypeAny),
+		propWritable(PropertyIdentifierRecordCount, DatatypeUnsigned),
+		propRequired(PropertyIdentifierTotalRecordCount, DatatypeUnsigned),
+		propOptional(PropertyIdentifierNotificationThreshold, DatatypeUnsigned, true),
+		propOptional(PropertyIdentifierRecordsSinceNotification, DatatypeUnsigned, false),
+		propOptional(PropertyIdentifierLastNotifyRecord, DatatypeUnsigned, false),
+		propOptional(PropertyIdentifierNotificationClass, DatatypeUnsigned, true),
+	}
+)
+
+// commandProperties 可命令对象的优先级属性，required表示该对象类型必需支持命令
+func commandProperties(datatype Datatype, required bool) []PropertyMetadata {
+	if required {
+		return []PropertyMetadata{
+			propRequired(PropertyIdentifierPriorityArray, DatatypeAny),
+			writableRequired(PropertyIdentifierRelinquishDefault, datatype),
+		}
+	}
+	return []PropertyMetadata{
+		propOptional(PropertyIdentifierPriorityArray, DatatypeAny, false),
+		propOptional(PropertyIdentifierRelinquishDefault, datatype, true),
+	}
+}
+
+// objectProperties 组合对象类型的属性表，自动加入所有对象共有的属性
+func objectProperties(groups ...[]PropertyMetadata) []PropertyMetadata {
+	properties := []PropertyMetadata{
+		propRequired(PropertyIdentifierObjectIdentifier, DatatypeObjectIdentifier),
+		writableRequired(PropertyIdentifierObjectName, DatatypeCharacterString),
+		propRequired(PropertyIdentifierObjectType, DatatypeEnumerated),
+		propOptional(PropertyIdentifierDescription, DatatypeCharacterString, true),
+	}
+	for _, group := range groups {
+		properties = append(properties, group...)
+	}
+	return properties
+}
+
+// analogProperties 模拟量对象的属性
+func analogProperties(presentValue PropertyMetadata, command []PropertyMetadata) []PropertyMetadata {
+	return objectProperties([]PropertyMetadata{
+		presentValue,
+		propOptional(PropertyIdentifierDeviceType, DatatypeCharacterString, true),
+		propRequired(PropertyIdentifierUnits, DatatypeEnumerated),
+		propOptional(PropertyIdentifierMinPresValue, DatatypeReal, true),
+		propOptional(PropertyIdentifierMaxPresValue, DatatypeReal, true),
+		propOptional(PropertyIdentifierResolution, DatatypeReal, true),
+		propOptional(PropertyIdentifierCOVIncrement, DatatypeReal, true),
+		propOptional(PropertyIdentifierHighLimit, DatatypeReal, true),
+		propOptional(PropertyIdentifierLowLimit, DatatypeReal, true),
+		propOptional(PropertyIdentifierDeadband, DatatypeReal, true),
+		propOptional(PropertyIdentifierLimitEnable, DatatypeBitString, true),
+	}, statusProperties, command, intrinsicReportingProperties)
+}
+
+// binaryProperties 二进制对象的属性
+func binaryProperties(presentValue PropertyMetadata, command []PropertyMetadata) []PropertyMetadata {
+	return objectProperties([]PropertyMetadata{
+		presentValue,
+		propOptional(PropertyIdentifierDeviceType, DatatypeCharacterString, true),
+		writableRequired(PropertyIdentifierPolarity, DatatypeEnumerated),
+		propOptional(PropertyIdentifierInactiveText, DatatypeCharacterString, true),
+		propOptional(PropertyIdentifierActiveText, DatatypeCharacterString, true),
+		propOptional(PropertyIdentifierMinimumOffTime, DatatypeUnsigned, true),
+		propOptional(PropertyIdentifierMinimumOnTime, DatatypeUnsigned, true),
+		propOptional(PropertyIdentifierAlarmValue, DatatypeEnumerated, true),
+	}, statusProperties, command, intrinsicReportingProperties)
+}
+
+// multiStateProperties 多态对象的属性
+func multiStateProperties(presentValue PropertyMetadata, command []PropertyMetadata) []PropertyMetadata {
+	return objectProperties([]PropertyMetadata{
+		presentValue,
+		propOptional(PropertyIdentifierDeviceType, DatatypeCharacterString, true),
+		propRequired(PropertyIdentifierNumberOfStates, DatatypeUnsigned),
+		propOptional(PropertyIdentifierStateText, DatatypeAny, true),
+		propOptional(PropertyIdentifierAlarmValues, DatatypeAny, true),
+	}, statusProperties, command, intrinsicReportingProperties)
+}
+
+// valueProperties 基本数据类型值对象的属性
+func valueProperties(datatype Datatype) []PropertyMetadata {
+	return objectProperties([]PropertyMetadata{
+		writableRequired(PropertyIdentifierPresentValue, datatype),
+		propRequired(PropertyIdentifierStatusFlags, DatatypeBitString),
+		withDefault(propOptional(PropertyIdentifierEventState, DatatypeEnumerated, false), EventStateNormal),
+		withDefault(propOptional(PropertyIdentifierOutOfService, DatatypeBoolean, true), false),
+	}, commandProperties(datatype, false))
+}
+
+// propertyRegistry 各对象类型的属性元数据（ASHRAE 135第12章）
+var propertyRegistry = map[ObjectType][]PropertyMetadata{
+	ObjectTypeAnalogInput: analogProperties(writableRequired(PropertyIdentifierPresentValue, DatatypeReal), nil),
+	ObjectTypeAnalogOutput: analogProperties(propWritable(PropertyIdentifierPresentValue, DatatypeReal),
+		commandProperties(DatatypeReal, true)),
+	ObjectTypeAnalogValue: analogProperties(writableRequired(PropertyIdentifierPresentValue, DatatypeReal),
+		commandProperties(DatatypeReal, false)),
+	ObjectTypeBinaryInput: binaryProperties(writableRequired(PropertyIdentifierPresentValue, DatatypeEnumerated), nil),
+	ObjectTypeBinaryOutput: binaryProperties(propWritable(PropertyIdentifierPresentValue, DatatypeEnumerated),
+		commandProperties(DatatypeEnumerated, true)),
+	ObjectTypeBinaryValue: binaryProperties(writableRequired(PropertyIdentifierPresentValue, DatatypeEnumerated),
+		commandProperties(DatatypeEnumerated, false)),
+	ObjectTypeMultiStateInput: multiStateProperties(writableRequired(PropertyIdentifierPresentValue, DatatypeUnsigned), nil),
+	ObjectTypeMultiStateOutput: multiStateProperties(propWritable(PropertyIdentifierPresentValue, DatatypeUnsigned),
+		commandProperties(DatatypeUnsigned, true)),
+	ObjectTypeMultiStateValue: multiStateProperties(writableRequired(PropertyIdentifierPresentValue, DatatypeUnsigned),
+		commandProperties(DatatypeUnsigned, false)),
+
+	ObjectTypeDevice: objectProperties([]PropertyMetadata{
+		propRequired(PropertyIdentifierSystemStatus, DatatypeEnumerated),
+		propRequired(PropertyIdentifierVendorName, DatatypeCharacterString),
+		propRequired(PropertyIdentifierVendorIdentifier, DatatypeUnsigned),
+		propRequired(PropertyIdentifierModelName, DatatypeCharacterString),
+		propRequired(PropertyIdentifierFirmwareRevision, DatatypeCharacterString),
+		propRequired(PropertyIdentifierApplicationSoftwareVersion, DatatypeCharacterString),
+		propOptional(PropertyIdentifierLocation, DatatypeCharacterString, true),
+		propOptional(PropertyIdentifierDeviceType, DatatypeCharacterString, true),
+		propRequired(PropertyIdentifierProtocolVersion, DatatypeUnsigned),
+		propRequired(PropertyIdentifierProtocolRevision, DatatypeUnsigned),
+		propRequired(PropertyIdentifierProtocolServicesSupported, DatatypeBitString),
+		propRequired(PropertyIdentifierProtocolObjectTypesSupported, DatatypeBitString),
+		propRequired(PropertyIdentifierObjectList, DatatypeAny),
+		propRequired(PropertyIdentifierMaxAPDULengthAccepted, DatatypeUnsigned),
+		propRequired(PropertyIdentifierSegmentationSupported, DatatypeEnumerated),
+		writableRequired(PropertyIdentifierAPDUTimeout, DatatypeUnsigned),
+		writableRequired(PropertyIdentifierNumberOfAPDURetries, DatatypeUnsigned),
+		propOptional(PropertyIdentifierAPDUSegmentTimeout, DatatypeUnsigned, true),
+		propRequired(PropertyIdentifierDeviceAddressBinding, DatatypeAny),
+		propRequired(PropertyIdentifierDatabaseRevision, DatatypeUnsigned),
+		propOptional(PropertyIdentifierLastRestoreTime, DatatypeAny, false),
+		propOptional(PropertyIdentifierTimeOfDeviceRestart, DatatypeAny, false),
+		propOptional(PropertyIdentifierLocalDate, DatatypeDate, false),
+		propOptional(PropertyIdentifierLocalTime, DatatypeTime, false),
+		propOptional(PropertyIdentifierUTCOffset, DatatypeSigned, true),
+		propOptional(PropertyIdentifierTimeSynchronizationRecipients, DatatypeAny, true),
+		propOptional(PropertyIdentifierUTCTimeSynchronizationRecipients, DatatypeAny, true),
+		propOptional(PropertyIdentifierTimeSynchronizationInterval, DatatypeUnsigned, true),
+		propOptional(PropertyIdentifierAlignIntervals, DatatypeBoolean, true),
+		propOptional(PropertyIdentifierIntervalOffset, DatatypeUnsigned, true),
+		propOptional(PropertyIdentifierDaylightSavingsStatus, DatatypeBoolean, false),
+		propOptional(PropertyIdentifierActiveCOVSubscriptions, DatatypeAny, false),
+	}),
+
+	ObjectTypeNotificationClass: objectProperties([]PropertyMetadata{
+		propRequired(PropertyIdentifierNotificationClass, DatatypeUnsigned),
+		writableRequired(PropertyIdentifierPriority, DatatypeAny),
+		writableRequired(PropertyIdentifierAckRequired, DatatypeBitString),
+		writableRequired(PropertyIdentifierRecipientList, DatatypeAny),
+	}),
+
+	ObjectTypeEventEnrollment: objectProperties([]PropertyMetadata{
+		propRequired(PropertyIdentifierEventType, DatatypeEnumerated),
+		writableRequired(PropertyIdentifierNotifyType, DatatypeEnumerated),
+		writableRequired(PropertyIdentifierEventParameters, DatatypeAny),
+		writableRequired(PropertyIdentifierObjectPropertyReference, DatatypeAny),
+		withDefault(propRequired(PropertyIdentifierEventState, DatatypeEnumerated), EventStateNormal),
+		withDefault(writableRequired(PropertyIdentifierEventEnable, DatatypeBitString), BitStringFromUint(3, uint64(AckedTransitionsAll))),
+		propRequired(PropertyIdentifierAckedTransitions, DatatypeBitString),
+		writableRequired(PropertyIdentifierNotificationClass, DatatypeUnsigned),
+		propRequired(PropertyIdentifierEventTimeStamps, DatatypeAny),
+		propRequired(PropertyIdentifierStatusFlags, DatatypeBitString),
+	}),
+
+	ObjectTypeFile: objectProperties([]PropertyMetadata{
+		propRequired(PropertyIdentifierFileType, DatatypeCharacterString),
+		propRequired(PropertyIdentifierFileSize, DatatypeUnsigned),
+		propRequired(PropertyIdentifierModificationDate, DatatypeAny),
+		writableRequired(PropertyIdentifierArchive, DatatypeBoolean),
+		propRequired(PropertyIdentifierReadOnly, DatatypeBoolean),
+		propRequired(PropertyIdentifierFileAccessMethod, DatatypeEnumerated),
+		propOptional(PropertyIdentifierFileOpeningTag, DatatypeCharacterString, false),
+		propOptional(PropertyIdentifierFileClosingTag, DatatypeCharacterString, false),
+	}),
+
+	ObjectTypeEventLog: objectProperties(logProperties),
+
+	ObjectTypeAuditLog: objectProperties(logProperties),
+
+	ObjectTypeTrendLog: objectProperties(logProperties, []PropertyMetadata{
+		propOptional(PropertyIdentifierLogDeviceObjectProperty, DatatypeAny, true),
+		propOptional(PropertyIdentifierLogInterval, DatatypeUnsigned, true),
+		writableRequired(PropertyIdentifierLoggingType, DatatypeEnumerated),
+		propOptional(PropertyIdentifierTrigger, DatatypeBoolean, true),
+	}),
+
+	ObjectTypeTrendLogMultiple: objectProperties(logProperties, []PropertyMetadata{
+		writableRequired(PropertyIdentifierLogDeviceObjectProperty, DatatypeAny),
+		writableRequired(PropertyIdentifierLogInterval, DatatypeUnsigned),
+		writableRequired(PropertyIdentifierLoggingType, DatatypeEnumerated),
+		propOptional(PropertyIdentifierTrigger, DatatypeBoolean, true),
+	}),
+
+	ObjectTypeCalendar: objectProperties([]PropertyMetadata{
+		propRequired(PropertyIdentifierPresentValue, DatatypeBoolean),
+		writableRequired(PropertyIdentifierDateList, DatatypeAny),
+	}),
+
+	ObjectTypeSchedule: objectProperties([]PropertyMetadata{
+		propRequired(PropertyIdentifierPresentValue, DatatypeAny),
+		writableRequired(PropertyIdentifierEffectivePeriod, DatatypeAny),
+		propOptional(PropertyIdentifierWeeklySchedule, DatatypeAny, true),
+		propOptional(PropertyIdentifierExceptionSchedule, DatatypeAny, true),
+		writableRequired(PropertyIdentifierScheduleDefault, DatatypeAny),
+		writableRequired(PropertyIdentifierListOfObjectPropertyReferences, DatatypeAny),
+		writableRequired(PropertyIdentifierPriorityForWriting, DatatypeUnsigned),
+		propRequired(PropertyIdentifierStatusFlags, DatatypeBitString),
+		withDefault(writableRequired(PropertyIdentifierOutOfService, DatatypeBoolean), false),
+	}),
+
+	ObjectTypeLoop: objectProperties([]PropertyMetadata{
+		propRequired(PropertyIdentifierPresentValue, DatatypeReal),
+		propOptional(PropertyIdentifierUpdateInterval, DatatypeUnsigned, true),
+		propRequired(PropertyIdentifierOutputUnits, DatatypeEnumerated),
+		writableRequired(PropertyIdentifierManipulatedVariableReference, DatatypeAny),
+		writableRequired(PropertyIdentifierControlledVariableReference, DatatypeAny),
+		propRequired(PropertyIdentifierControlledVariableValue, DatatypeReal),
+		propRequired(PropertyIdentifierControlledVariableUnits, DatatypeEnumerated),
+		writableRequired(PropertyIdentifierSetpointReference, DatatypeAny),
+		writableRequired(PropertyIdentifierSetpoint, DatatypeReal),
+		writableRequired(PropertyIdentifierAction, DatatypeEnumerated),
+		propOptional(PropertyIdentifierProportionalConstant, DatatypeReal, true),
+		propOptional(PropertyIdentifierIntegralConstant, DatatypeReal, true),
+		propOptional(PropertyIdentifierDerivativeConstant, DatatypeReal, true),
+		propOptional(PropertyIdentifierBias, DatatypeReal, true),
+		propOptional(PropertyIdentifierMaximumOutput, DatatypeReal, true),
+		propOptional(PropertyIdentifierMinimumOutput, DatatypeReal, true),
+		writableRequired(PropertyIdentifierPriorityForWriting, DatatypeUnsigned),
+	}, statusProperties),
+
+	ObjectTypeProgram: objectProperties([]PropertyMetadata{
+		propRequired(PropertyIdentifierProgramState, DatatypeEnumerated),
+		propWritable(PropertyIdentifierProgramChange, DatatypeEnumerated),
+		propOptional(PropertyIdentifierReasonForHalt, DatatypeEnumerated, false),
+		propOptional(PropertyIdentifierDescriptionOfHalt, DatatypeCharacterString, false),
+		propOptional(PropertyIdentifierProgramLocation, DatatypeCharacterString, false),
+		propOptional(PropertyIdentifierInstanceOf, DatatypeCharacterString, false),
+		propRequired(PropertyIdentifierStatusFlags, DatatypeBitString),
+		withDefault(writableRequired(PropertyIdentifierOutOfService, DatatypeBoolean), false),
+	}),
+
+	ObjectTypeAccumulator: objectProperties([]PropertyMetadata{
+		writableRequired(PropertyIdentifierPresentValue, DatatypeUnsigned),
+		propRequired(PropertyIdentifierScale, DatatypeAny),
+		propRequired(PropertyIdentifierUnits, DatatypeEnumerated),
+		propOptional(PropertyIdentifierPrescale, DatatypeAny, false),
+		propRequired(PropertyIdentifierMaxPresValue, DatatypeUnsigned),
+		propOptional(PropertyIdentifierValueChangeTime, DatatypeAny, false),
+		propOptional(PropertyIdentifierValueBeforeChange, DatatypeUnsigned, false),
+		propOptional(PropertyIdentifierValueSet, DatatypeUnsigned, true),
+		propOptional(PropertyIdentifierPulseRate, DatatypeUnsigned, false),
+		propOptional(PropertyIdentifierHighLimit, DatatypeUnsigned, true),
+		propOptional(PropertyIdentifierLowLimit, DatatypeUnsigned, true),
+		propOptional(PropertyIdentifierLimitMonitoringInterval, DatatypeUnsigned, true),
+	}, statusProperties, intrinsicReportingProperties),
+
+	ObjectTypePulseConverter: objectProperties([]PropertyMetadata{
+		writableRequired(PropertyIdentifierPresentValue, DatatypeReal),
+		propOptional(PropertyIdentifierInputReference, DatatypeAny, true),
+		propRequired(PropertyIdentifierUnits, DatatypeEnumerated),
+		writableRequired(PropertyIdentifierScaleFactor, DatatypeReal),
+		propWritable(PropertyIdentifierAdjustValue, DatatypeReal),
+		propRequired(PropertyIdentifierCount, DatatypeUnsigned),
+		propRequired(PropertyIdentifierUpdateTime, DatatypeAny),
+		propRequired(PropertyIdentifierCountChangeTime, DatatypeAny),
+		propRequired(PropertyIdentifierCountBeforeChange, DatatypeUnsigned),
+	}, statusProperties, intrinsicReportingProperties),
+
+	ObjectTypeAveraging: objectProperties([]PropertyMetadata{
+		propRequired(PropertyIdentifierMinimumValue, DatatypeReal),
+		propOptional(PropertyIdentifierMinimumValueTimestamp, DatatypeAny, false),
+		propRequired(PropertyIdentifierAverageValue, DatatypeReal),
+		propOptional(PropertyIdentifierVarianceValue, DatatypeReal, false),
+		propRequired(PropertyIdentifierMaximumValue, DatatypeReal),
+		propOptional(PropertyIdentifierMaximumValueTimestamp, DatatypeAny, false),
+		propWritable(PropertyIdentifierAttemptedSamples, DatatypeUnsigned),
+		propRequired(PropertyIdentifierValidSamples, DatatypeUnsigned),
+		writableRequired(PropertyIdentifierObjectPropertyReference, DatatypeAny),
+		propWritable(PropertyIdentifierWindowInterval, DatatypeUnsigned),
+		propWritable(PropertyIdentifierWindowSamples, DatatypeUnsigned),
+	}),
+
+	ObjectTypeCharacterStringValue: valueProperties(DatatypeCharacterString),
+	ObjectTypeIntegerValue:         valueProperties(DatatypeSigned),
+	ObjectTypePositiveIntegerValue: valueProperties(DatatypeUnsigned),
+	ObjectTypeLargeAnalogValue:     valueProperties(DatatypeDouble),
+	ObjectTypeDateTimeValue:        valueProperties(DatatypeAny),
+	ObjectTypeOctetStringValue:     valueProperties(DatatypeOctetString),
+
+	ObjectTypeGlobalGroup: objectProperties([]PropertyMetadata{
+		writableRequired(PropertyIdentifierGroupMembers, DatatypeAny),
+		propOptional(PropertyIdentifierGroupMemberNames, DatatypeAny, true),
+		propRequired(PropertyIdentifierPresentValue, DatatypeAny),
+		propRequired(PropertyIdentifierMemberStatusFlags, DatatypeBitString),
+		propOptional(PropertyIdentifierRequestedUpdateInterval, DatatypeUnsigned, true),
+		propOptional(PropertyIdentifierCOVUPeriod, DatatypeUnsigned, true),
+	}, statusProperties),
+
+	ObjectTypeLifeSafetyPoint: objectProperties([]PropertyMetadata{
+		writableRequired(PropertyIdentifierPresentValue, DatatypeEnumerated),
+		propRequired(PropertyIdentifierTrackingValue, DatatypeEnumerated),
+		propWritable(PropertyIdentifierMode, DatatypeEnumerated),
+		propRequired(PropertyIdentifierAcceptedModes, DatatypeAny),
+		propRequired(PropertyIdentifierSilenced, DatatypeEnumerated),
+		propRequired(PropertyIdentifierOperationExpected, DatatypeEnumerated),
+		propOptional(PropertyIdentifierMemberOf, DatatypeAny, false),
+	}, statusProperties, intrinsicReportingProperties),
+
+	ObjectTypeLifeSafetyZone: objectProperties([]PropertyMetadata{
+		writableRequired(PropertyIdentifierPresentValue, DatatypeEnumerated),
+		propRequired(PropertyIdentifierTrackingValue, DatatypeEnumerated),
+		propWritable(PropertyIdentifierMode, DatatypeEnumerated),
+		propRequired(PropertyIdentifierAcceptedModes, DatatypeAny),
+		propRequired(PropertyIdentifierSilenced, DatatypeEnumerated),
+		propRequired(PropertyIdentifierOperationExpected, DatatypeEnumerated),
+		writableRequired(PropertyIdentifierZoneMembers, DatatypeAny),
+		propOptional(PropertyIdentifierMemberOf, DatatypeAny, false),
+	}, statusProperties, intrinsicReportingProperties),
+}
+
+// RegisterObjectProperties 注册或替换对象类型的属性元数据
+func RegisterObjectProperties(objType ObjectType, properties []PropertyMetadata) {
+	propertyRegistry[objType] = append([]PropertyMetadata{}, properties...)
+}
+
+// ObjectProperties 返回对象类型的属性元数据，未注册的类型返回nil
+func ObjectProperties(objType ObjectType) []PropertyMetadata {
+	return propertyRegistry[objType]
+}
+
+// ObjectTypesSupported 返回Protocol_Object_Types_Supported位串，已注册属性元数据的标准对象类型置位
+func ObjectTypesSupported() BitString {
+	var bits BitString
+	for objType := range propertyRegistry {
+		if objType < 128 {
+			bits.Set(int(objType), true)
+		}
+	}
+	return bits
+}
+
+// LookupPropertyMetadata 查找对象类型中指定属性的元数据
+func LookupPropertyMetadata(objType ObjectType, prop PropertyIdentifier) (PropertyMetadata, bool) {
+	for _, meta := range propertyRegistry[objType] {
+		if meta.Identifier == prop {
+			return meta, true
+		}
+	}
+	return PropertyMetadata{}, false
+}
+
+// ValidateWrite 按属性元数据检查写入请求，未注册的对象类型不做检查
+func ValidateWrite(obj Object, prop PropertyIdentifier, value interface{}) error {
+	objType := obj.GetObjectIdentifier().Type
+	if _, registered := propertyRegistry[objType]; !registered {
+		return nil
+	}
+	meta, ok := LookupPropertyMetadata(objType, prop)
+	if !ok {
+		return ErrUnknownProperty
+	}
+	if !meta.Writable {
+		return ErrWriteAccessDenied
+	}
+	if value == nil {
+		// NULL仅用于释放可命令属性的优先级
+		if c, isCommandable := obj.(CommandableObject); isCommandable && c.Commandable(prop) {
+			return nil
+		}
+		return ErrInvalidDataType
+	}
+	if !meta.Datatype.Accepts(value) {
+		return ErrInvalidDataType
+	}
+	return nil
+}
+
+// ExpandPropertyReference 展开ALL、REQUIRED和OPTIONAL特殊属性标识符，其他属性原样返回；
+// ALL和OPTIONAL只包含对象实际具有值的属性
+func ExpandPropertyReference(obj Object, prop PropertyIdentifier) []PropertyIdentifier {
+	if prop != PropertyIdentifierAll && prop != PropertyIdentifierRequired && prop != PropertyIdentifierOptional {
+		return []PropertyIdentifier{prop}
+	}
+	properties := propertyRegistry[obj.GetObjectIdentifier().Type]
+	if properties == nil {
+		properties = objectProperties()
+	}
+	var result []PropertyIdentifier
+	for _, meta := range properties {
+		required := meta.Conformance != ConformanceOptional
+		switch {
+		case prop == PropertyIdentifierRequired && !required:
+			continue
+		case prop == PropertyIdentifierOptional && required:
+			continue
+		case prop != PropertyIdentifierRequired:
+			if value, _ := obj.ReadProperty(meta.Identifier); value == nil {
+				continue
+			}
+		}
+		result = append(result, meta.Identifier)
+	}
+	return result
+}
+
+// applyPropertyDefaults 为新建对象填充属性元数据中定义的默认值
+func applyPropertyDefaults(o *BACnetObject) {
+	for _, meta := range propertyRegistry[o.GetObjectType()] {
+		if meta.Default == nil {
+			continue
+		}
+		if _, exists := o.Properties[meta.Identifier]; !exists {
+			o.Properties[meta.Identifier] = meta.Default
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// TimeValue 表示一天中的切换点（BACnetTimeValue），Value为nil表示释放
+type TimeValue struct {
+	Time  time.Duration // 距当天零点的时间
+	Value interface{}
+}
+
+// SpecialEvent 例外日程条目（BACnetSpecialEvent）
+// Calendar和CalendarReference二选一：直接给出日历条目，或引用日历对象
+type SpecialEvent struct {
+	Calendar          *CalendarEntry
+	CalendarReference *ObjectIdentifier
+	TimeValues        []TimeValue
+	Priority          uint8 // 1最高，16最低
+}
+
+// Schedule 表示BACnet日程对象，根据周日程和例外日程计算Present_Value并写入被引用的属性
+type Schedule struct {
+	*BACnetObject
+	EffectivePeriod    DateRange
+	WeeklySchedule     [7][]TimeValue // 下标0=星期一...6=星期日
+	ExceptionSchedule  []SpecialEvent
+	ScheduleDefault    interface{}
+	References         []DeviceObjectPropertyReference // List_Of_Object_Property_References
+	PriorityForWriting uint8
+}
+
+// NewSchedule 创建一个新的日程对象
+func NewSchedule(instance uint32, name string, scheduleDefault interface{}) *Schedule {
+	schedule := &Schedule{
+		BACnetObject:       NewBACnetObject(ObjectTypeSchedule, instance, name),
+		ExceptionSchedule:  []SpecialEvent{},
+		ScheduleDefault:    scheduleDefault,
+		References:         []DeviceObjectPropertyReference{},
+		PriorityForWriting: 16,
+	}
+	schedule.Properties[PropertyIdentifierPresentValue] = scheduleDefault
+	return schedule
+}
+
+// Evaluate 计算指定时间的日程值
+// 先按优先级查找当天生效的例外日程，其次使用周日程，都没有时返回Schedule_Default
+func (s *Schedule) Evaluate(device *Device, now time.Time) interface{} {
+	if !s.EffectivePeriod.Contains(now) {
+		return s.ScheduleDefault
+	}
+
+	events := make([]SpecialEvent, 0, len(s.ExceptionSchedule))
+	for _, event := range s.ExceptionSchedule {
+		if s.eventActive(device, event, now) {
+			events = append(events, event)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Priority < events[j].Priority
+	})
+	for _, event := range events {
+		if value, ok := currentTimeValue(event.TimeValues, now); ok {
+			return value
+		}
+	}
+
+	if value, ok := currentTimeValue(s.WeeklySchedule[isoWeekday(now)-1], now); ok {
+		return value
+	}
+	return s.ScheduleDefault
+}
+
+// eventActive 判断例外日程条目当天是否生效
+func (s *Schedule) eventActive(device *Device, event SpecialEvent, now time.Time) bool {
+	if event.Calendar != nil {
+		return event.Calendar.Matches(now)
+	}
+	if event.CalendarReference == nil || device == nil {
+		return false
+	}
+	if calendar, ok := device.FindObject(*event.CalendarReference).(*Calendar); ok {
+		return calendar.Evaluate(now)
+	}
+	return false
+}
+
+// currentTimeValue 返回当天不晚于now的最后一个非释放切换值
+func currentTimeValue(timeValues []TimeValue, now time.Time) (interface{}, bool) {
+	elapsed := now.Sub(dateOnly(now))
+	var latest *TimeValue
+	for i := range timeValues {
+		tv := &timeValues[i]
+		if tv.Time <= elapsed && (latest == nil || tv.Time >= latest.Time) {
+			latest = tv
+		}
+	}
+	if latest == nil || latest.Value == nil {
+		return nil, false
+	}
+	return latest.Value, true
+}
+
+// Execute 周期性计算Present_Value，变化时写入所有被引用的属性
+func (s *Schedule) Execute(device *Device, now time.Time) {
+	value := s.Evaluate(device, now)
+	current := s.Properties[PropertyIdentifierPresentValue]
+	if reflect.DeepEqual(value, current) {
+		return
+	}
+
+	s.BACnetObject.WriteProperty(PropertyIdentifierPresentValue, value)
+	logger().Debug("日程输出变化", "object", s.Name, "value", value)
+
+	for _, ref := range s.References {
+		obj, err := device.ResolveReference(ref)
+		if err != nil {
+			logger().Warn("日程写入失败", "object", s.Name, "error", err)
+			continue
+		}
+		if err := WriteWithPriority(obj, ref.PropertyIdentifier, value, s.PriorityForWriting); err != nil {
+			logger().Warn("日程写入失败", "object", s.Name, "error", err)
+		}
+	}
+}
+
+// ReadProperty 读取日程属性
+func (s *Schedule) ReadProperty(prop PropertyIdentifier) (interface{}, error) {
+	switch prop {
+	case PropertyIdentifierEffectivePeriod:
+		return s.EffectivePeriod, nil
+	case PropertyIdentifierWeeklySchedule:
+		return s.WeeklySchedule, nil
+	case PropertyIdentifierExceptionSchedule:
+		return append([]SpecialEvent{}, s.ExceptionSchedule...), nil
+	case PropertyIdentifierScheduleDefault:
+		return s.ScheduleDefault, nil
+	case PropertyIdentifierListOfObjectPropertyReferences:
+		return append([]DeviceObjectPropertyReference{}, s.References...), nil
+	case PropertyIdentifierPriorityForWriting:
+		return s.PriorityForWriting, nil
+	}
+	return s.BACnetObject.ReadProperty(prop)
+}
+
+// WriteProperty 写入日程属性
+func (s *Schedule) WriteProperty(prop PropertyIdentifier, value interface{}) error {
+	switch prop {
+	case PropertyIdentifierEffectivePeriod:
+		period, ok := value.(DateRange)
+		if !ok {
+			return fmt.Errorf("Effective_Period类型无效")
+		}
+		s.EffectivePeriod = period
+		return nil
+	case PropertyIdentifierWeeklySchedule:
+		weekly, ok := value.([7][]TimeValue)
+		if !ok {
+			return fmt.Errorf("Weekly_Schedule类型无效")
+		}
+		s.WeeklySchedule = weekly
+		return nil
+	case PropertyIdentifierExceptionSchedule:
+		events, ok := value.([]SpecialEvent)
+		if !ok {
+			return fmt.Errorf("Exception_Schedule类型无效")
+		}
+		for _, event := range events {
+			if event.Priority < 1 || event.Priority > 16 {
+				return fmt.Errorf("例外日程优先级必须在1-16之间")
+			}
+		}
+		s.ExceptionSchedule = append([]SpecialEvent{}, events...)
+		return nil
+	case PropertyIdentifierScheduleDefault:
+		s.ScheduleDefault = value
+		return nil
+	case PropertyIdentifierListOfObjectPropertyReferences:
+		refs, ok := value.([]DeviceObjectPropertyReference)
+		if !ok {
+			return fmt.Errorf("List_Of_Object_Property_References类型无效")
+		}
+		s.References = append([]DeviceObjectPropertyReference{}, refs...)
+		return nil
+	case PropertyIdentifierPriorityForWriting:
+		priority, ok := toUint32(value)
+		if !ok || priority < 1 || priority > 16 {
+			return fmt.Errorf("Priority_For_Writing必须在1-16之间")
+		}
+		s.PriorityForWriting = uint8(priority)
+		return nil
+	}
+	return s.BACnetObject.WriteProperty(prop, value)
+}
@@ -0,0 +1,407 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// snapshotVersion 快照文件格式的版本
+const snapshotVersion = 1
+
+// deviceSnapshot 设备快照：各对象的现场值、命令状态、日程和日志缓冲区
+type deviceSnapshot struct {
+	Version int              `json:"version"`
+	Time    time.Time        `json:"time"`
+	Objects []objectSnapshot `json:"objects"`
+}
+
+// objectSnapshot 一个对象的快照，只包含该对象类型需要恢复的部分
+type objectSnapshot struct {
+	Type         ObjectType        `json:"type"`
+	Instance     uint32            `json:"instance"`
+	PresentValue *stateValue       `json:"presentValue,omitempty"` // 不可命令的输入和值对象
+	Command      *commandState     `json:"command,omitempty"`      // 可命令对象
+	Schedule     *scheduleSnapshot `json:"schedule,omitempty"`
+	Log          *logSnapshot      `json:"log,omitempty"` // 趋势日志和事件日志
+}
+
+// scheduleSnapshot 日程对象客户端可以修改的部分
+type scheduleSnapshot struct {
+	WeeklySchedule    [7][]timeValueSnapshot `json:"weeklySchedule"`
+	ExceptionSchedule []specialEventSnapshot `json:"exceptionSchedule,omitempty"`
+	ScheduleDefault   *stateValue            `json:"scheduleDefault,omitempty"`
+}
+
+// timeValueSnapshot 日程中的一个时间值，Value为nil表示NULL（释放）
+type timeValueSnapshot struct {
+	Time  time.Duration `json:"time"`
+	Value *stateValue   `json:"value,omitempty"`
+}
+
+// specialEventSnapshot 例外日程条目
+type specialEventSnapshot struct {
+	Calendar          *CalendarEntry      `json:"calendar,omitempty"`
+	CalendarReference *ObjectIdentifier   `json:"calendarReference,omitempty"`
+	TimeValues        []timeValueSnapshot `json:"timeValues"`
+	Priority          uint8               `json:"priority"`
+}
+
+// logSnapshot 日志缓冲区的记录和累计记录数
+type logSnapshot struct {
+	TotalRecordCount uint32              `json:"totalRecordCount"`
+	Records          []logRecordSnapshot `json:"records"`
+}
+
+// logRecordSnapshot 日志缓冲区中的一条记录
+type logRecordSnapshot struct {
+	SequenceNumber uint32      `json:"sequenceNumber"`
+	Timestamp      time.Time   `json:"timestamp"`
+	Datum          *stateValue `json:"datum"`
+	StatusFlags    *uint8      `json:"statusFlags,omitempty"`
+}
+
+// eventNotificationState 事件日志中记录的事件通知，事件参数只保存buffer-ready参数
+type eventNotificationState struct {
+	EventNotification
+	EventValues *BufferReadyEventValues `json:"EventValues,omitempty"`
+}
+
+// auditNotificationState 审计日志中记录的审计通知，写入的值按属性值持久化，无法持久化时不保存
+type auditNotificationState struct {
+	AuditNotification
+	TargetValue *stateValue `json:"TargetValue,omitempty"`
+}
+
+// SaveSnapshot 将设备的现场值、可命令对象的优先级数组、日程和日志缓冲区保存到快照文件。
+// 无法持久化的值（如八位字节串）被跳过
+func SaveSnapshot(device *Device, path string) error {
+	data, err := json.Marshal(takeSnapshot(device))
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// takeSnapshot 生成设备快照
+func takeSnapshot(device *Device) deviceSnapshot {
+	snapshot := deviceSnapshot{Version: snapshotVersion, Time: device.now()}
+	for _, obj := range device.Objects() {
+		identifier := obj.GetObjectIdentifier()
+		object := objectSnapshot{Type: identifier.Type, Instance: identifier.Instance}
+		if state, ok, err := commandStateOf(obj); err != nil {
+			logger().Debug("跳过无法持久化的命令状态", "object", obj.GetObjectName(), "error", err)
+		} else if ok {
+			object.Command = &state
+		} else if hasFieldValue(obj) {
+			value, _ := obj.ReadProperty(PropertyIdentifierPresentValue)
+			if v, err := newStateValue(value); err == nil {
+				object.PresentValue = v
+			}
+		}
+		switch o := obj.(type) {
+		case *Schedule:
+			object.Schedule = snapshotSchedule(o)
+		case interface{ Buffer() *LogBuffer }:
+			object.Log = snapshotLog(o.Buffer())
+		}
+		if object.PresentValue != nil || object.Command != nil || object.Schedule != nil || object.Log != nil {
+			snapshot.Objects = append(snapshot.Objects, object)
+		}
+	}
+	return snapshot
+}
+
+// hasFieldValue 判断对象的Present_Value是否由现场或客户端给出（而不是由对象自己计算）
+func hasFieldValue(obj Object) bool {
+	switch obj.(type) {
+	case *Analog, *Binary, *MultiState, *ValueObject:
+		return true
+	}
+	return false
+}
+
+// snapshotSchedule 保存日程对象的周日程、例外日程和默认值
+func snapshotSchedule(s *Schedule) *scheduleSnapshot {
+	snapshot := &scheduleSnapshot{}
+	for day, values := range s.WeeklySchedule {
+		snapshot.WeeklySchedule[day] = snapshotTimeValues(values)
+	}
+	for _, event := range s.ExceptionSchedule {
+		snapshot.ExceptionSchedule = append(snapshot.ExceptionSchedule, specialEventSnapshot{
+			Calendar:          event.Calendar,
+			CalendarReference: event.CalendarReference,
+			TimeValues:        snapshotTimeValues(event.TimeValues),
+			Priority:          event.Priority,
+		})
+	}
+	if s.ScheduleDefault != nil {
+		snapshot.ScheduleDefault, _ = newStateValue(s.ScheduleDefault)
+	}
+	return snapshot
+}
+
+// snapshotTimeValues 保存时间值列表
+func snapshotTimeValues(values []TimeValue) []timeValueSnapshot {
+	snapshots := make([]timeValueSnapshot, 0, len(values))
+	for _, tv := range values {
+		snapshot := timeValueSnapshot{Time: tv.Time}
+		if tv.Value != nil {
+			v, err := newStateValue(tv.Value)
+			if err != nil {
+				continue
+			}
+			snapshot.Value = v
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots
+}
+
+// snapshotLog 保存日志缓冲区的记录
+func snapshotLog(buffer *LogBuffer) *logSnapshot {
+	snapshot := &logSnapshot{TotalRecordCount: buffer.TotalRecordCount, Records: []logRecordSnapshot{}}
+	for _, record := range buffer.Records {
+		datum, err := newLogDatum(record.Datum)
+		if err != nil {
+			continue
+		}
+		snapshot.Records = append(snapshot.Records, logRecordSnapshot{
+			SequenceNumber: record.SequenceNumber,
+			Timestamp:      record.Timestamp,
+			Datum:          datum,
+			StatusFlags:    record.StatusFlags,
+		})
+	}
+	return snapshot
+}
+
+// newLogDatum 将日志记录的内容转换为持久化值
+func newLogDatum(datum interface{}) (*stateValue, error) {
+	var kind string
+	var value interface{}
+	switch d := datum.(type) {
+	case LogStatus:
+		kind, value = "logStatus", uint8(d)
+	case LogFailure:
+		kind, value = "logFailure", d.Err.Error()
+	case EventNotification:
+		state := eventNotificationState{EventNotification: d}
+		if values, ok := d.EventValues.(BufferReadyEventValues); ok {
+			state.EventValues = &values
+		}
+		kind, value = "eventNotification", state
+	case AuditNotification:
+		state := auditNotificationState{AuditNotification: d}
+		if d.TargetValue != nil {
+			state.TargetValue, _ = newStateValue(d.TargetValue)
+		}
+		kind, value = "auditNotification", state
+	case LogMultipleDatum:
+		columns := make([]*stateValue, len(d))
+		for i, column := range d {
+			v, err := newLogDatum(column)
+			if err != nil {
+				return nil, err
+			}
+			columns[i] = v
+		}
+		kind, value = "logMultiple", columns
+	default:
+		return newStateValue(datum)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return &stateValue{Type: kind, Value: data}, nil
+}
+
+// decodeLogDatum 还原日志记录的内容
+func decodeLogDatum(v *stateValue) (interface{}, error) {
+	switch v.Type {
+	case "logStatus":
+		var status uint8
+		err := json.Unmarshal(v.Value, &status)
+		return LogStatus(status), err
+	case "logFailure":
+		var text string
+		err := json.Unmarshal(v.Value, &text)
+		return LogFailure{Err: errors.New(text)}, err
+	case "eventNotification":
+		var state eventNotificationState
+		if err := json.Unmarshal(v.Value, &state); err != nil {
+			return nil, err
+		}
+		notification := state.EventNotification
+		if state.EventValues != nil {
+			notification.EventValues = *state.EventValues
+		}
+		return notification, nil
+	case "auditNotification":
+		var state auditNotificationState
+		if err := json.Unmarshal(v.Value, &state); err != nil {
+			return nil, err
+		}
+		notification := state.AuditNotification
+		if state.TargetValue != nil {
+			value, err := state.TargetValue.decode()
+			if err != nil {
+				return nil, err
+			}
+			notification.TargetValue = value
+		}
+		return notification, nil
+	case "logMultiple":
+		var columns []*stateValue
+		if err := json.Unmarshal(v.Value, &columns); err != nil {
+			return nil, err
+		}
+		datum := make(LogMultipleDatum, len(columns))
+		for i, column := range columns {
+			value, err := decodeLogDatum(column)
+			if err != nil {
+				return nil, err
+			}
+			datum[i] = value
+		}
+		return datum, nil
+	}
+	return v.decode()
+}
+
+// LoadSnapshot 从快照文件恢复设备状态，文件不存在时不做处理。
+// 快照中不存在于设备的对象被忽略，配置中新增的对象保持默认值
+func LoadSnapshot(device *Device, path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var snapshot deviceSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("解析快照文件失败: %v", err)
+	}
+	if snapshot.Version != snapshotVersion {
+		return fmt.Errorf("不支持的快照版本: %d", snapshot.Version)
+	}
+
+	for _, object := range snapshot.Objects {
+		identifier := ObjectIdentifier{Type: object.Type, Instance: object.Instance}
+		obj := device.FindObject(identifier)
+		if obj == nil {
+			logger().Warn("快照中的对象不存在，已忽略", "object_type", object.Type, "instance", object.Instance)
+			continue
+		}
+		if object.PresentValue != nil {
+			value, err := object.PresentValue.decode()
+			if err != nil {
+				return err
+			}
+			if updater, ok := obj.(PresentValueUpdater); ok {
+				err = updater.UpdatePresentValue(value)
+			} else {
+				err = obj.WriteProperty(PropertyIdentifierPresentValue, value)
+			}
+			if err != nil {
+				logger().Warn("恢复Present_Value失败", "object", obj.GetObjectName(), "error", err)
+			}
+		}
+		if object.Command != nil {
+			if err := restoreCommandState(device, *object.Command); err != nil {
+				return err
+			}
+		}
+		if schedule, ok := obj.(*Schedule); ok && object.Schedule != nil {
+			if err := object.Schedule.restore(schedule); err != nil {
+				return fmt.Errorf("恢复日程%s失败: %v", obj.GetObjectName(), err)
+			}
+		}
+		if logObject, ok := obj.(interface{ Buffer() *LogBuffer }); ok && object.Log != nil {
+			if err := object.Log.restore(logObject.Buffer()); err != nil {
+				return fmt.Errorf("恢复日志%s失败: %v", obj.GetObjectName(), err)
+			}
+		}
+	}
+	device.WriteProperty(PropertyIdentifierLastRestoreTime, device.now())
+	return nil
+}
+
+// restore 将快照写回日程对象
+func (s *scheduleSnapshot) restore(schedule *Schedule) error {
+	var weekly [7][]TimeValue
+	for day, values := range s.WeeklySchedule {
+		timeValues, err := restoreTimeValues(values)
+		if err != nil {
+			return err
+		}
+		weekly[day] = timeValues
+	}
+	var exceptions []SpecialEvent
+	for _, event := range s.ExceptionSchedule {
+		timeValues, err := restoreTimeValues(event.TimeValues)
+		if err != nil {
+			return err
+		}
+		exceptions = append(exceptions, SpecialEvent{
+			Calendar:          event.Calendar,
+			CalendarReference: event.CalendarReference,
+			TimeValues:        timeValues,
+			Priority:          event.Priority,
+		})
+	}
+	schedule.WeeklySchedule = weekly
+	schedule.ExceptionSchedule = exceptions
+	if s.ScheduleDefault != nil {
+		value, err := s.ScheduleDefault.decode()
+		if err != nil {
+			return err
+		}
+		schedule.ScheduleDefault = value
+	}
+	return nil
+}
+
+// restoreTimeValues 还原时间值列表
+func restoreTimeValues(snapshots []timeValueSnapshot) ([]TimeValue, error) {
+	var values []TimeValue
+	for _, snapshot := range snapshots {
+		tv := TimeValue{Time: snapshot.Time}
+		if snapshot.Value != nil {
+			value, err := snapshot.Value.decode()
+			if err != nil {
+				return nil, err
+			}
+			tv.Value = value
+		}
+		values = append(values, tv)
+	}
+	return values, nil
+}
+
+// restore 将快照中的记录写回日志缓冲区，超过缓冲区大小时保留最新的记录
+func (l *logSnapshot) restore(buffer *LogBuffer) error {
+	records := make([]LogRecord, 0, len(l.Records))
+	for _, snapshot := range l.Records {
+		datum, err := decodeLogDatum(snapshot.Datum)
+		if err != nil {
+			return err
+		}
+		records = append(records, LogRecord{
+			SequenceNumber: snapshot.SequenceNumber,
+			Timestamp:      snapshot.Timestamp,
+			Datum:          datum,
+			StatusFlags:    snapshot.StatusFlags,
+		})
+	}
+	if buffer.BufferSize > 0 && uint32(len(records)) > buffer.BufferSize {
+		records = records[uint32(len(records))-buffer.BufferSize:]
+	}
+	buffer.Records = records
+	buffer.TotalRecordCount = l.TotalRecordCount
+	return nil
+}
@@ -0,0 +1,208 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// commandState 可命令对象持久化的优先级数组和Relinquish_Default
+type commandState struct {
+	Type              ObjectType            `json:"type"`
+	Instance          uint32                `json:"instance"`
+	RelinquishDefault *stateValue           `json:"relinquishDefault,omitempty"`
+	PriorityArray     map[uint8]*stateValue `json:"priorityArray,omitempty"`
+}
+
+// stateValue 带类型标记的属性值，保证重新加载后仍为原来的Go类型
+type stateValue struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// newStateValue 将属性值转换为带类型标记的持久化值
+func newStateValue(value interface{}) (*stateValue, error) {
+	var kind string
+	switch value.(type) {
+	case bool:
+		kind = "bool"
+	case float32:
+		kind = "real"
+	case float64:
+		kind = "double"
+	case uint32:
+		kind = "unsigned"
+	case int32:
+		kind = "signed"
+	case string:
+		kind = "string"
+	default:
+		return nil, fmt.Errorf("不支持持久化的值类型: %T", value)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return &stateValue{Type: kind, Value: data}, nil
+}
+
+// decode 按类型标记还原属性值
+func (v *stateValue) decode() (interface{}, error) {
+	switch v.Type {
+	case "bool":
+		var value bool
+		err := json.Unmarshal(v.Value, &value)
+		return value, err
+	case "real":
+		var value float32
+		err := json.Unmarshal(v.Value, &value)
+		return value, err
+	case "double":
+		var value float64
+		err := json.Unmarshal(v.Value, &value)
+		return value, err
+	case "unsigned":
+		var value uint32
+		err := json.Unmarshal(v.Value, &value)
+		return value, err
+	case "signed":
+		var value int32
+		err := json.Unmarshal(v.Value, &value)
+		return value, err
+	case "string":
+		var value string
+		err := json.Unmarshal(v.Value, &value)
+		return value, err
+	}
+	return nil, fmt.Errorf("未知的值类型: %s", v.Type)
+}
+
+// SaveCommandState 将设备中可命令对象的优先级数组和Relinquish_Default保存到状态文件
+func SaveCommandState(device *Device, path string) error {
+	var states []commandState
+	for _, obj := range device.Objects() {
+		state, ok, err := commandStateOf(obj)
+		if err != nil {
+			return err
+		}
+		if ok {
+			states = append(states, state)
+		}
+	}
+
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// commandStateOf 返回可命令对象的优先级数组和Relinquish_Default，对象不可命令时返回false
+func commandStateOf(obj Object) (commandState, bool, error) {
+	c, ok := obj.(CommandableObject)
+	if !ok || !c.Commandable(PropertyIdentifierPresentValue) {
+		return commandState{}, false, nil
+	}
+	identifier := obj.GetObjectIdentifier()
+	state := commandState{Type: identifier.Type, Instance: identifier.Instance}
+	if value, _ := obj.ReadProperty(PropertyIdentifierRelinquishDefault); value != nil {
+		v, err := newStateValue(value)
+		if err != nil {
+			return state, false, err
+		}
+		state.RelinquishDefault = v
+	}
+	array, _ := obj.ReadProperty(PropertyIdentifierPriorityArray)
+	slots, _ := array.(PriorityArray)
+	for i, value := range slots {
+		priority := uint8(i + 1)
+		// 最短开关时间占用的优先级6在重启后由对象重新计算
+		if b, isBinary := obj.(*Binary); isBinary && priority == minimumTimePriority && !b.minimumUntil.IsZero() {
+			continue
+		}
+		if value == nil {
+			continue
+		}
+		v, err := newStateValue(value)
+		if err != nil {
+			return state, false, err
+		}
+		if state.PriorityArray == nil {
+			state.PriorityArray = make(map[uint8]*stateValue)
+		}
+		state.PriorityArray[priority] = v
+	}
+	return state, true, nil
+}
+
+// writeFileAtomic 先写临时文件再重命名，避免写入中断导致状态文件损坏
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadCommandState 从状态文件恢复可命令对象的优先级数组和Relinquish_Default，文件不存在时不做处理
+func LoadCommandState(device *Device, path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var states []commandState
+	if err := json.Unmarshal(data, &states); err != nil {
+		return fmt.Errorf("解析状态文件失败: %v", err)
+	}
+
+	for _, state := range states {
+		if err := restoreCommandState(device, state); err != nil {
+			return err
+		}
+	}
+	device.WriteProperty(PropertyIdentifierLastRestoreTime, device.now())
+	return nil
+}
+
+// restoreCommandState 恢复一个可命令对象的优先级数组和Relinquish_Default
+func restoreCommandState(device *Device, state commandState) error {
+	identifier := ObjectIdentifier{Type: state.Type, Instance: state.Instance}
+	c, ok := device.FindObject(identifier).(CommandableObject)
+	if !ok || !c.Commandable(PropertyIdentifierPresentValue) {
+		logger().Warn("状态文件中的对象不存在或不可命令，已忽略", "object_type", state.Type, "instance", state.Instance)
+		return nil
+	}
+	if state.RelinquishDefault != nil {
+		value, err := state.RelinquishDefault.decode()
+		if err != nil {
+			return err
+		}
+		if err := c.WriteProperty(PropertyIdentifierRelinquishDefault, value); err != nil {
+			logger().Warn("恢复Relinquish_Default失败", "object", c.GetObjectName(), "error", err)
+		}
+	}
+	for priority, v := range state.PriorityArray {
+		value, err := v.decode()
+		if err != nil {
+			return err
+		}
+		if err := c.WritePropertyWithPriority(PropertyIdentifierPresentValue, value, priority); err != nil {
+			logger().Warn("恢复命令失败", "object", c.GetObjectName(), "priority", priority, "error", err)
+		}
+	}
+	return nil
+}
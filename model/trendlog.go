@@ -0,0 +1,236 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// LoggingType 趋势日志记录方式
+type LoggingType uint8
+
+const (
+	LoggingTypePolled LoggingType = iota
+	LoggingTypeCOV
+	LoggingTypeTriggered
+)
+
+// TrendLog 表示BACnet趋势日志对象，按轮询、COV或触发方式记录被引用属性的值
+type TrendLog struct {
+	*BACnetObject
+	Log          *LogBuffer
+	LogReference *DeviceObjectPropertyReference // 被记录的属性引用
+	LogInterval  uint32                         // 轮询间隔（百分之一秒）
+	LoggingType  LoggingType
+	StartTime    time.Time // 零值表示不限制
+	StopTime     time.Time // 零值表示不限制
+	logNotification
+
+	device   *Device   // 最近一次执行时所属的设备，用于触发记录
+	observed Object    // 已注册COV观察者的对象
+	lastPoll time.Time // 上次轮询时间
+}
+
+// NewTrendLog 创建一个新的趋势日志对象
+func NewTrendLog(instance uint32, name string, bufferSize uint32) *TrendLog {
+	trendLog := &TrendLog{
+		BACnetObject: NewBACnetObject(ObjectTypeTrendLog, instance, name),
+		Log:          NewLogBuffer(bufferSize),
+		LogInterval:  6000,
+		LoggingType:  LoggingTypePolled,
+	}
+	trendLog.Properties[PropertyIdentifierLogEnable] = true
+	return trendLog
+}
+
+// Buffer 返回日志缓冲区
+func (t *TrendLog) Buffer() *LogBuffer {
+	return t.Log
+}
+
+// Enabled 判断日志是否启用
+func (t *TrendLog) Enabled() bool {
+	enabled, _ := t.Properties[PropertyIdentifierLogEnable].(bool)
+	return enabled
+}
+
+// inWindow 判断当前时间是否处于Start_Time和Stop_Time之间
+func (t *TrendLog) inWindow(now time.Time) bool {
+	return inLogWindow(t.StartTime, t.StopTime, now)
+}
+
+// Execute 周期性执行：绑定COV观察者并按Log_Interval轮询记录
+func (t *TrendLog) Execute(device *Device, now time.Time) {
+	t.device = device
+	if t.LogReference == nil {
+		return
+	}
+
+	if t.LoggingType == LoggingTypeCOV {
+		t.bindObserver(device)
+		return
+	}
+
+	if t.LoggingType != LoggingTypePolled || t.LogInterval == 0 {
+		return
+	}
+	interval := time.Duration(t.LogInterval) * 10 * time.Millisecond
+	if !t.lastPoll.IsZero() && now.Sub(t.lastPoll) < interval {
+		return
+	}
+	t.lastPoll = now
+	t.acquire(device, now)
+}
+
+// bindObserver 在被引用对象上注册COV观察者
+func (t *TrendLog) bindObserver(device *Device) {
+	obj, err := device.ResolveReference(*t.LogReference)
+	if err != nil || obj == t.observed {
+		return
+	}
+	observable, ok := obj.(interface{ AddPropertyObserver(PropertyObserver) })
+	if !ok {
+		return
+	}
+	t.observed = obj
+	observable.AddPropertyObserver(func(source Object, prop PropertyIdentifier, value interface{}) {
+		if t.LoggingType != LoggingTypeCOV || t.LogReference == nil || prop != t.LogReference.PropertyIdentifier ||
+			source.GetObjectIdentifier() != t.LogReference.ObjectIdentifier {
+			return
+		}
+		t.record(t.now(), value, statusFlagsOf(source))
+	})
+}
+
+// acquire 读取被引用属性并写入一条记录
+func (t *TrendLog) acquire(device *Device, now time.Time) {
+	obj, err := device.ResolveReference(*t.LogReference)
+	if err != nil {
+		t.record(now, LogFailure{Err: err}, nil)
+		return
+	}
+	value, err := ReadPropertyValue(obj, t.LogReference.PropertyIdentifier)
+	if err == nil && value == nil {
+		err = fmt.Errorf("属性不存在: %d", t.LogReference.PropertyIdentifier)
+	}
+	if err != nil {
+		t.record(now, LogFailure{Err: err}, nil)
+		return
+	}
+	t.record(now, value, statusFlagsOf(obj))
+}
+
+// record 写入一条记录并处理缓冲区满和buffer-ready通知
+func (t *TrendLog) record(now time.Time, datum interface{}, statusFlags *uint8) {
+	if !t.Enabled() || !t.inWindow(now) {
+		return
+	}
+	if !t.Log.Append(now, datum) {
+		return
+	}
+	t.Log.Records[len(t.Log.Records)-1].StatusFlags = statusFlags
+	t.recorded(t.BACnetObject, t.Log, now)
+	stopIfFull(t.BACnetObject, t.Log)
+}
+
+// Trigger 立即采集一条记录（对应写Trigger属性为TRUE）
+func (t *TrendLog) Trigger(now time.Time) error {
+	if t.LogReference == nil || t.device == nil {
+		return fmt.Errorf("趋势日志未配置被记录的属性")
+	}
+	t.acquire(t.device, now)
+	return nil
+}
+
+// ReadProperty 读取趋势日志属性
+func (t *TrendLog) ReadProperty(prop PropertyIdentifier) (interface{}, error) {
+	if value, ok := t.Log.readBufferProperty(prop); ok {
+		return value, nil
+	}
+	if value, ok := t.readNotificationProperty(prop); ok {
+		return value, nil
+	}
+	switch prop {
+	case PropertyIdentifierLogDeviceObjectProperty:
+		if t.LogReference == nil {
+			return nil, nil
+		}
+		return *t.LogReference, nil
+	case PropertyIdentifierLogInterval:
+		return t.LogInterval, nil
+	case PropertyIdentifierLoggingType:
+		return t.LoggingType, nil
+	case PropertyIdentifierStartTime:
+		return DateTime{t.StartTime}, nil
+	case PropertyIdentifierStopTime:
+		return DateTime{t.StopTime}, nil
+	case PropertyIdentifierTrigger:
+		return false, nil
+	}
+	return t.BACnetObject.ReadProperty(prop)
+}
+
+// WriteProperty 写入趋势日志属性
+func (t *TrendLog) WriteProperty(prop PropertyIdentifier, value interface{}) error {
+	if handled, err := t.Log.writeBufferProperty(prop, value, t.now()); handled {
+		return err
+	}
+	switch prop {
+	case PropertyIdentifierLogEnable:
+		enable, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("Log_Enable类型无效")
+		}
+		setLogEnable(t.BACnetObject, t.Log, enable)
+		return nil
+	case PropertyIdentifierLogDeviceObjectProperty:
+		ref, ok := value.(DeviceObjectPropertyReference)
+		if !ok {
+			return fmt.Errorf("Log_DeviceObjectPropertyReference类型无效")
+		}
+		t.LogReference = &ref
+		t.observed = nil
+		return nil
+	case PropertyIdentifierLogInterval:
+		interval, ok := toUint32(value)
+		if !ok {
+			return fmt.Errorf("Log_Interval类型无效")
+		}
+		t.LogInterval = interval
+		return nil
+	case PropertyIdentifierLoggingType:
+		loggingType, ok := toUint32(value)
+		if !ok || loggingType > uint32(LoggingTypeTriggered) {
+			return fmt.Errorf("Logging_Type值无效")
+		}
+		t.LoggingType = LoggingType(loggingType)
+		return nil
+	case PropertyIdentifierStartTime, PropertyIdentifierStopTime:
+		tm, ok := toDateTime(value)
+		if !ok {
+			return fmt.Errorf("Start_Time/Stop_Time类型无效")
+		}
+		if prop == PropertyIdentifierStartTime {
+			t.StartTime = tm
+		} else {
+			t.StopTime = tm
+		}
+		return nil
+	case PropertyIdentifierNotificationThreshold:
+		return t.writeNotificationThreshold(value)
+	case PropertyIdentifierTrigger:
+		if trigger, ok := value.(bool); ok && trigger {
+			return t.Trigger(t.now())
+		}
+		return nil
+	}
+	return t.BACnetObject.WriteProperty(prop, value)
+}
+
+// statusFlagsOf 读取对象的状态标志
+func statusFlagsOf(obj Object) *uint8 {
+	if a, ok := obj.(interface{ GetStatusFlags() uint8 }); ok {
+		flags := a.GetStatusFlags()
+		return &flags
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+// newTrendLogFixture 创建记录传感器Present_Value的趋势日志
+func newTrendLogFixture(loggingType LoggingType) (*Device, *Analog, *TrendLog) {
+	device := NewDevice(1, "Device", "")
+	sensor := NewAnalogValue(1, "Sensor", UnitsDegreesCelsius)
+	trendLog := NewTrendLog(1, "Trend", 3)
+	trendLog.LoggingType = loggingType
+	trendLog.LogReference = &DeviceObjectPropertyReference{
+		ObjectIdentifier: sensor.GetObjectIdentifier(), PropertyIdentifier: PropertyIdentifierPresentValue,
+	}
+	device.AddObject(sensor)
+	device.AddObject(trendLog)
+	return device, sensor, trendLog
+}
+
+// loggedValues 返回日志缓冲区中记录的值
+func loggedValues(log *LogBuffer) []interface{} {
+	values := []interface{}{}
+	for _, record := range log.Records {
+		values = append(values, record.Datum)
+	}
+	return values
+}
+
+func TestTrendLogPolled(t *testing.T) {
+	device, sensor, trendLog := newTrendLogFixture(LoggingTypePolled)
+	trendLog.LogInterval = 1000 // 10秒
+
+	start := time.Date(2024, 3, 1, 8, 0, 0, 0, time.Local)
+	for i, value := range []float32{20, 21, 22, 23} {
+		sensor.WriteProperty(PropertyIdentifierPresentValue, value)
+		trendLog.Execute(device, start.Add(time.Duration(i)*5*time.Second))
+	}
+
+	// 只在Log_Interval到达时采样
+	if got := loggedValues(trendLog.Log); len(got) != 2 || got[0] != float32(20) || got[1] != float32(22) {
+		t.Errorf("records = %v, want [20 22]", got)
+	}
+	if record := trendLog.Log.Records[1]; !record.Timestamp.Equal(start.Add(10*time.Second)) || record.SequenceNumber != 2 || record.StatusFlags == nil {
+		t.Errorf("second record = %+v", record)
+	}
+}
+
+func TestTrendLogCOV(t *testing.T) {
+	device, sensor, trendLog := newTrendLogFixture(LoggingTypeCOV)
+	trendLog.Execute(device, time.Now())
+
+	sensor.WriteProperty(PropertyIdentifierPresentValue, float32(20))
+	sensor.WriteProperty(PropertyIdentifierDescription, "ignored")
+	sensor.WriteProperty(PropertyIdentifierPresentValue, float32(24))
+
+	// 只记录被引用属性的变化
+	if got := loggedValues(trendLog.Log); len(got) != 2 || got[0] != float32(20) || got[1] != float32(24) {
+		t.Errorf("records = %v, want [20 24]", got)
+	}
+}
+
+func TestTrendLogTriggered(t *testing.T) {
+	device, sensor, trendLog := newTrendLogFixture(LoggingTypeTriggered)
+	if err := trendLog.Trigger(time.Now()); err == nil {
+		t.Error("Trigger before Execute succeeded")
+	}
+
+	trendLog.Execute(device, time.Now())
+	if trendLog.Log.Count() != 0 {
+		t.Fatalf("triggered log recorded without trigger: %v", loggedValues(trendLog.Log))
+	}
+	sensor.WriteProperty(PropertyIdentifierPresentValue, float32(19))
+	if err := trendLog.WriteProperty(PropertyIdentifierTrigger, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := loggedValues(trendLog.Log); len(got) != 1 || got[0] != float32(19) {
+		t.Errorf("records = %v, want [19]", got)
+	}
+
+	// 无法读取被引用属性时记录失败
+	trendLog.LogReference.ObjectIdentifier.Instance = 99
+	trendLog.Trigger(time.Now())
+	if _, ok := trendLog.Log.Records[1].Datum.(LogFailure); !ok {
+		t.Errorf("record for missing object = %#v, want LogFailure", trendLog.Log.Records[1].Datum)
+	}
+}
+
+func TestTrendLogWindowAndStopWhenFull(t *testing.T) {
+	device, _, trendLog := newTrendLogFixture(LoggingTypeTriggered)
+	trendLog.Execute(device, time.Now())
+
+	start := time.Date(2024, 3, 1, 8, 0, 0, 0, time.Local)
+	if err := trendLog.WriteProperty(PropertyIdentifierStartTime, DateTime{start}); err != nil {
+		t.Fatalf("write Start_Time: %v", err)
+	}
+	if value, _ := trendLog.ReadProperty(PropertyIdentifierStartTime); value != (DateTime{start}) {
+		t.Errorf("Start_Time = %#v, want DateTime", value)
+	}
+	trendLog.StopTime = start.Add(time.Hour)
+	trendLog.Log.StopWhenFull = true
+
+	// Start_Time之前和Stop_Time之后不记录
+	trendLog.Trigger(start.Add(-time.Second))
+	trendLog.Trigger(start.Add(2 * time.Hour))
+	if trendLog.Log.Count() != 0 {
+		t.Fatalf("records outside window = %d", trendLog.Log.Count())
+	}
+
+	// 缓冲区写满后停止记录并禁用日志
+	for i := 0; i < 4; i++ {
+		trendLog.Trigger(start.Add(time.Duration(i) * time.Minute))
+	}
+	if trendLog.Log.Count() != 3 || trendLog.Enabled() {
+		t.Errorf("Record_Count = %d, Log_Enable = %v, want 3 and false", trendLog.Log.Count(), trendLog.Enabled())
+	}
+}
@@ -0,0 +1,186 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// LogMultipleDatum 趋势日志多对象的一条多列记录，每列对应一个被引用属性的值或LogFailure
+type LogMultipleDatum []interface{}
+
+// TrendLogMultiple 表示BACnet多对象趋势日志对象，按同一间隔采集多个属性到同一缓冲区
+type TrendLogMultiple struct {
+	*BACnetObject
+	Log           *LogBuffer
+	LogReferences []DeviceObjectPropertyReference // 被记录的属性引用列表
+	LogInterval   uint32                          // 轮询间隔（百分之一秒）
+	LoggingType   LoggingType                     // 仅支持轮询和触发方式
+	StartTime     time.Time                       // 零值表示不限制
+	StopTime      time.Time                       // 零值表示不限制
+	logNotification
+
+	device   *Device   // 最近一次执行时所属的设备，用于触发记录
+	lastPoll time.Time // 上次轮询时间
+}
+
+// NewTrendLogMultiple 创建一个新的多对象趋势日志对象
+func NewTrendLogMultiple(instance uint32, name string, bufferSize uint32) *TrendLogMultiple {
+	trendLog := &TrendLogMultiple{
+		BACnetObject:  NewBACnetObject(ObjectTypeTrendLogMultiple, instance, name),
+		Log:           NewLogBuffer(bufferSize),
+		LogReferences: []DeviceObjectPropertyReference{},
+		LogInterval:   6000,
+		LoggingType:   LoggingTypePolled,
+	}
+	trendLog.Properties[PropertyIdentifierLogEnable] = true
+	return trendLog
+}
+
+// Buffer 返回日志缓冲区
+func (t *TrendLogMultiple) Buffer() *LogBuffer {
+	return t.Log
+}
+
+// Enabled 判断日志是否启用
+func (t *TrendLogMultiple) Enabled() bool {
+	enabled, _ := t.Properties[PropertyIdentifierLogEnable].(bool)
+	return enabled
+}
+
+// Execute 周期性执行：按Log_Interval轮询所有被引用属性
+func (t *TrendLogMultiple) Execute(device *Device, now time.Time) {
+	t.device = device
+	if t.LoggingType != LoggingTypePolled || t.LogInterval == 0 || len(t.LogReferences) == 0 {
+		return
+	}
+	interval := time.Duration(t.LogInterval) * 10 * time.Millisecond
+	if !t.lastPoll.IsZero() && now.Sub(t.lastPoll) < interval {
+		return
+	}
+	t.lastPoll = now
+	t.acquire(device, now)
+}
+
+// acquire 读取所有被引用属性并写入一条多列记录
+func (t *TrendLogMultiple) acquire(device *Device, now time.Time) {
+	if !t.Enabled() || !inLogWindow(t.StartTime, t.StopTime, now) {
+		return
+	}
+
+	datum := make(LogMultipleDatum, len(t.LogReferences))
+	for i, ref := range t.LogReferences {
+		obj, err := device.ResolveReference(ref)
+		if err != nil {
+			datum[i] = LogFailure{Err: err}
+			continue
+		}
+		value, err := ReadPropertyValue(obj, ref.PropertyIdentifier)
+		if err == nil && value == nil {
+			err = fmt.Errorf("属性不存在: %d", ref.PropertyIdentifier)
+		}
+		if err != nil {
+			datum[i] = LogFailure{Err: err}
+			continue
+		}
+		datum[i] = value
+	}
+
+	if !t.Log.Append(now, datum) {
+		return
+	}
+	t.recorded(t.BACnetObject, t.Log, now)
+	stopIfFull(t.BACnetObject, t.Log)
+}
+
+// Trigger 立即采集一条记录（对应写Trigger属性为TRUE）
+func (t *TrendLogMultiple) Trigger(now time.Time) error {
+	if len(t.LogReferences) == 0 || t.device == nil {
+		return fmt.Errorf("趋势日志未配置被记录的属性")
+	}
+	t.acquire(t.device, now)
+	return nil
+}
+
+// ReadProperty 读取多对象趋势日志属性
+func (t *TrendLogMultiple) ReadProperty(prop PropertyIdentifier) (interface{}, error) {
+	if value, ok := t.Log.readBufferProperty(prop); ok {
+		return value, nil
+	}
+	if value, ok := t.readNotificationProperty(prop); ok {
+		return value, nil
+	}
+	switch prop {
+	case PropertyIdentifierLogDeviceObjectProperty:
+		return append([]DeviceObjectPropertyReference{}, t.LogReferences...), nil
+	case PropertyIdentifierLogInterval:
+		return t.LogInterval, nil
+	case PropertyIdentifierLoggingType:
+		return t.LoggingType, nil
+	case PropertyIdentifierStartTime:
+		return DateTime{t.StartTime}, nil
+	case PropertyIdentifierStopTime:
+		return DateTime{t.StopTime}, nil
+	case PropertyIdentifierTrigger:
+		return false, nil
+	}
+	return t.BACnetObject.ReadProperty(prop)
+}
+
+// WriteProperty 写入多对象趋势日志属性
+func (t *TrendLogMultiple) WriteProperty(prop PropertyIdentifier, value interface{}) error {
+	if handled, err := t.Log.writeBufferProperty(prop, value, t.now()); handled {
+		return err
+	}
+	switch prop {
+	case PropertyIdentifierLogEnable:
+		enable, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("Log_Enable类型无效")
+		}
+		setLogEnable(t.BACnetObject, t.Log, enable)
+		return nil
+	case PropertyIdentifierLogDeviceObjectProperty:
+		refs, ok := value.([]DeviceObjectPropertyReference)
+		if !ok {
+			return fmt.Errorf("Log_DeviceObjectPropertyReference类型无效")
+		}
+		// 列定义变化后旧记录不再对应，清空缓冲区
+		t.LogReferences = append([]DeviceObjectPropertyReference{}, refs...)
+		t.Log.Clear()
+		t.Log.Append(t.now(), LogStatusBufferPurged)
+		return nil
+	case PropertyIdentifierLogInterval:
+		interval, ok := toUint32(value)
+		if !ok {
+			return fmt.Errorf("Log_Interval类型无效")
+		}
+		t.LogInterval = interval
+		return nil
+	case PropertyIdentifierLoggingType:
+		loggingType, ok := toUint32(value)
+		if !ok || LoggingType(loggingType) == LoggingTypeCOV || loggingType > uint32(LoggingTypeTriggered) {
+			return fmt.Errorf("Logging_Type值无效")
+		}
+		t.LoggingType = LoggingType(loggingType)
+		return nil
+	case PropertyIdentifierStartTime, PropertyIdentifierStopTime:
+		tm, ok := toDateTime(value)
+		if !ok {
+			return fmt.Errorf("Start_Time/Stop_Time类型无效")
+		}
+		if prop == PropertyIdentifierStartTime {
+			t.StartTime = tm
+		} else {
+			t.StopTime = tm
+		}
+		return nil
+	case PropertyIdentifierNotificationThreshold:
+		return t.writeNotificationThreshold(value)
+	case PropertyIdentifierTrigger:
+		if trigger, ok := value.(bool); ok && trigger {
+			return t.Trigger(t.now())
+		}
+		return nil
+	}
+	return t.BACnetObject.WriteProperty(prop, value)
+}
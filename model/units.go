@@ -0,0 +1,574 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// EngineeringUnits 工程单位（BACnetEngineeringUnits），取值与ASHRAE 135一致
+type EngineeringUnits uint16
+
+const (
+	// 面积
+	UnitsSquareMeters      EngineeringUnits = 0
+	UnitsSquareFeet        EngineeringUnits = 1
+	UnitsSquareCentimeters EngineeringUnits = 116
+	UnitsSquareInches      EngineeringUnits = 115
+
+	// 货币
+	UnitsCurrency1  EngineeringUnits = 105
+	UnitsCurrency2  EngineeringUnits = 106
+	UnitsCurrency3  EngineeringUnits = 107
+	UnitsCurrency4  EngineeringUnits = 108
+	UnitsCurrency5  EngineeringUnits = 109
+	UnitsCurrency6  EngineeringUnits = 110
+	UnitsCurrency7  EngineeringUnits = 111
+	UnitsCurrency8  EngineeringUnits = 112
+	UnitsCurrency9  EngineeringUnits = 113
+	UnitsCurrency10 EngineeringUnits = 114
+
+	// 电气
+	UnitsMilliamperes            EngineeringUnits = 2
+	UnitsAmperes                 EngineeringUnits = 3
+	UnitsAmperesPerMeter         EngineeringUnits = 167
+	UnitsAmperesPerSquareMeter   EngineeringUnits = 168
+	UnitsAmpereSquareMeters      EngineeringUnits = 169
+	UnitsDecibels                EngineeringUnits = 199
+	UnitsDecibelsMillivolt       EngineeringUnits = 200
+	UnitsDecibelsVolt            EngineeringUnits = 201
+	UnitsFarads                  EngineeringUnits = 170
+	UnitsHenrys                  EngineeringUnits = 171
+	UnitsOhms                    EngineeringUnits = 4
+	UnitsOhmMeterSquaredPerMeter EngineeringUnits = 237
+	UnitsOhmMeters               EngineeringUnits = 172
+	UnitsMilliohms               EngineeringUnits = 145
+	UnitsKilohms                 EngineeringUnits = 122
+	UnitsMegohms                 EngineeringUnits = 123
+	UnitsMicrosiemens            EngineeringUnits = 190
+	UnitsMillisiemens            EngineeringUnits = 202
+	UnitsSiemens                 EngineeringUnits = 173
+	UnitsSiemensPerMeter         EngineeringUnits = 174
+	UnitsTeslas                  EngineeringUnits = 175
+	UnitsVolts                   EngineeringUnits = 5
+	UnitsMillivolts              EngineeringUnits = 124
+	UnitsKilovolts               EngineeringUnits = 6
+	UnitsMegavolts               EngineeringUnits = 7
+	UnitsVoltAmperes             EngineeringUnits = 8
+	UnitsKilovoltAmperes         EngineeringUnits = 9
+	UnitsMegavoltAmperes         EngineeringUnits = 10
+	UnitsVoltAmperesReactive     EngineeringUnits = 11
+	UnitsKilovoltAmperesReactive EngineeringUnits = 12
+	UnitsMegavoltAmperesReactive EngineeringUnits = 13
+	UnitsVoltsPerDegreeKelvin    EngineeringUnits = 176
+	UnitsVoltsPerMeter           EngineeringUnits = 177
+	UnitsDegreesPhase            EngineeringUnits = 14
+	UnitsPowerFactor             EngineeringUnits = 15
+	UnitsWebers                  EngineeringUnits = 178
+
+	// 能量
+	UnitsAmpereSeconds               EngineeringUnits = 238
+	UnitsVoltAmpereHours             EngineeringUnits = 239
+	UnitsKilovoltAmpereHours         EngineeringUnits = 240
+	UnitsMegavoltAmpereHours         EngineeringUnits = 241
+	UnitsVoltAmpereHoursReactive     EngineeringUnits = 242
+	UnitsKilovoltAmpereHoursReactive EngineeringUnits = 243
+	UnitsMegavoltAmpereHoursReactive EngineeringUnits = 244
+	UnitsVoltSquareHours             EngineeringUnits = 245
+	UnitsAmpereSquareHours           EngineeringUnits = 246
+	UnitsJoules                      EngineeringUnits = 16
+	UnitsKilojoules                  EngineeringUnits = 17
+	UnitsKilojoulesPerKilogram       EngineeringUnits = 125
+	UnitsMegajoules                  EngineeringUnits = 126
+	UnitsWattHours                   EngineeringUnits = 18
+	UnitsKilowattHours               EngineeringUnits = 19
+	UnitsMegawattHours               EngineeringUnits = 146
+	UnitsWattHoursReactive           EngineeringUnits = 203
+	UnitsKilowattHoursReactive       EngineeringUnits = 204
+	UnitsMegawattHoursReactive       EngineeringUnits = 205
+	UnitsBtus                        EngineeringUnits = 20
+	UnitsKiloBtus                    EngineeringUnits = 147
+	UnitsMegaBtus                    EngineeringUnits = 148
+	UnitsTherms                      EngineeringUnits = 21
+	UnitsTonHours                    EngineeringUnits = 22
+
+	// 焓
+	UnitsJoulesPerKilogramDryAir     EngineeringUnits = 23
+	UnitsKilojoulesPerKilogramDryAir EngineeringUnits = 149
+	UnitsMegajoulesPerKilogramDryAir EngineeringUnits = 150
+	UnitsBtusPerPoundDryAir          EngineeringUnits = 24
+	UnitsBtusPerPound                EngineeringUnits = 117
+
+	// 熵
+	UnitsJoulesPerDegreeKelvin         EngineeringUnits = 127
+	UnitsKilojoulesPerDegreeKelvin     EngineeringUnits = 151
+	UnitsMegajoulesPerDegreeKelvin     EngineeringUnits = 152
+	UnitsJoulesPerKilogramDegreeKelvin EngineeringUnits = 128
+
+	// 力
+	UnitsNewton EngineeringUnits = 153
+
+	// 频率
+	UnitsCyclesPerHour   EngineeringUnits = 25
+	UnitsCyclesPerMinute EngineeringUnits = 26
+	UnitsHertz           EngineeringUnits = 27
+	UnitsKilohertz       EngineeringUnits = 129
+	UnitsMegahertz       EngineeringUnits = 130
+	UnitsPerHour         EngineeringUnits = 131
+
+	// 湿度
+	UnitsGramsOfWaterPerKilogramDryAir EngineeringUnits = 28
+	UnitsPercentRelativeHumidity       EngineeringUnits = 29
+
+	// 长度
+	UnitsMicrometers EngineeringUnits = 194
+	UnitsMillimeters EngineeringUnits = 30
+	UnitsCentimeters EngineeringUnits = 118
+	UnitsKilometers  EngineeringUnits = 193
+	UnitsMeters      EngineeringUnits = 31
+	UnitsInches      EngineeringUnits = 32
+	UnitsFeet        EngineeringUnits = 33
+
+	// 光照
+	UnitsCandelas               EngineeringUnits = 179
+	UnitsCandelasPerSquareMeter EngineeringUnits = 180
+	UnitsWattsPerSquareFoot     EngineeringUnits = 34
+	UnitsWattsPerSquareMeter    EngineeringUnits = 35
+	UnitsLumens                 EngineeringUnits = 36
+	UnitsLuxes                  EngineeringUnits = 37
+	UnitsFootCandles            EngineeringUnits = 38
+
+	// 质量
+	UnitsMilligrams EngineeringUnits = 196
+	UnitsGrams      EngineeringUnits = 195
+	UnitsKilograms  EngineeringUnits = 39
+	UnitsPoundsMass EngineeringUnits = 40
+	UnitsTons       EngineeringUnits = 41
+
+	// 质量流量
+	UnitsGramsPerSecond      EngineeringUnits = 154
+	UnitsGramsPerMinute      EngineeringUnits = 155
+	UnitsKilogramsPerSecond  EngineeringUnits = 42
+	UnitsKilogramsPerMinute  EngineeringUnits = 43
+	UnitsKilogramsPerHour    EngineeringUnits = 44
+	UnitsPoundsMassPerSecond EngineeringUnits = 119
+	UnitsPoundsMassPerMinute EngineeringUnits = 45
+	UnitsPoundsMassPerHour   EngineeringUnits = 46
+	UnitsTonsPerHour         EngineeringUnits = 156
+
+	// 功率
+	UnitsMilliwatts        EngineeringUnits = 132
+	UnitsWatts             EngineeringUnits = 47
+	UnitsKilowatts         EngineeringUnits = 48
+	UnitsMegawatts         EngineeringUnits = 49
+	UnitsBtusPerHour       EngineeringUnits = 50
+	UnitsKiloBtusPerHour   EngineeringUnits = 157
+	UnitsHorsepower        EngineeringUnits = 51
+	UnitsTonsRefrigeration EngineeringUnits = 52
+
+	// 压力
+	UnitsPascals                  EngineeringUnits = 53
+	UnitsHectopascals             EngineeringUnits = 133
+	UnitsKilopascals              EngineeringUnits = 54
+	UnitsMillibars                EngineeringUnits = 134
+	UnitsBars                     EngineeringUnits = 55
+	UnitsPoundsForcePerSquareInch EngineeringUnits = 56
+	UnitsMillimetersOfWater       EngineeringUnits = 206
+	UnitsCentimetersOfWater       EngineeringUnits = 57
+	UnitsInchesOfWater            EngineeringUnits = 58
+	UnitsMillimetersOfMercury     EngineeringUnits = 59
+	UnitsCentimetersOfMercury     EngineeringUnits = 60
+	UnitsInchesOfMercury          EngineeringUnits = 61
+
+	// 温度
+	UnitsDegreesCelsius         EngineeringUnits = 62
+	UnitsDegreesKelvin          EngineeringUnits = 63
+	UnitsDegreesKelvinPerHour   EngineeringUnits = 181
+	UnitsDegreesKelvinPerMinute EngineeringUnits = 182
+	UnitsDegreesFahrenheit      EngineeringUnits = 64
+	UnitsDegreeDaysCelsius      EngineeringUnits = 65
+	UnitsDegreeDaysFahrenheit   EngineeringUnits = 66
+	UnitsDeltaDegreesFahrenheit EngineeringUnits = 120
+	UnitsDeltaDegreesKelvin     EngineeringUnits = 121
+
+	// 时间
+	UnitsYears             EngineeringUnits = 67
+	UnitsMonths            EngineeringUnits = 68
+	UnitsWeeks             EngineeringUnits = 69
+	UnitsDays              EngineeringUnits = 70
+	UnitsHours             EngineeringUnits = 71
+	UnitsMinutes           EngineeringUnits = 72
+	UnitsSeconds           EngineeringUnits = 73
+	UnitsHundredthsSeconds EngineeringUnits = 158
+	UnitsMilliseconds      EngineeringUnits = 159
+
+	// 扭矩
+	UnitsNewtonMeters EngineeringUnits = 160
+
+	// 速度
+	UnitsMillimetersPerSecond EngineeringUnits = 161
+	UnitsMillimetersPerMinute EngineeringUnits = 162
+	UnitsMetersPerSecond      EngineeringUnits = 74
+	UnitsMetersPerMinute      EngineeringUnits = 163
+	UnitsMetersPerHour        EngineeringUnits = 164
+	UnitsKilometersPerHour    EngineeringUnits = 75
+	UnitsFeetPerSecond        EngineeringUnits = 76
+	UnitsFeetPerMinute        EngineeringUnits = 77
+	UnitsMilesPerHour         EngineeringUnits = 78
+
+	// 体积
+	UnitsCubicFeet       EngineeringUnits = 79
+	UnitsCubicMeters     EngineeringUnits = 80
+	UnitsImperialGallons EngineeringUnits = 81
+	UnitsMilliliters     EngineeringUnits = 197
+	UnitsLiters          EngineeringUnits = 82
+	UnitsUsGallons       EngineeringUnits = 83
+
+	// 体积流量
+	UnitsCubicFeetPerSecond       EngineeringUnits = 142
+	UnitsCubicFeetPerMinute       EngineeringUnits = 84
+	UnitsCubicFeetPerHour         EngineeringUnits = 191
+	UnitsCubicMetersPerSecond     EngineeringUnits = 85
+	UnitsCubicMetersPerMinute     EngineeringUnits = 165
+	UnitsCubicMetersPerHour       EngineeringUnits = 135
+	UnitsImperialGallonsPerMinute EngineeringUnits = 86
+	UnitsMillilitersPerSecond     EngineeringUnits = 198
+	UnitsLitersPerSecond          EngineeringUnits = 87
+	UnitsLitersPerMinute          EngineeringUnits = 88
+	UnitsLitersPerHour            EngineeringUnits = 136
+	UnitsUsGallonsPerMinute       EngineeringUnits = 89
+	UnitsUsGallonsPerHour         EngineeringUnits = 192
+
+	// 其他
+	UnitsDegreesAngular                  EngineeringUnits = 90
+	UnitsDegreesCelsiusPerHour           EngineeringUnits = 91
+	UnitsDegreesCelsiusPerMinute         EngineeringUnits = 92
+	UnitsDegreesFahrenheitPerHour        EngineeringUnits = 93
+	UnitsDegreesFahrenheitPerMinute      EngineeringUnits = 94
+	UnitsJouleSeconds                    EngineeringUnits = 183
+	UnitsKilogramsPerCubicMeter          EngineeringUnits = 186
+	UnitsKwHoursPerSquareMeter           EngineeringUnits = 137
+	UnitsKwHoursPerSquareFoot            EngineeringUnits = 138
+	UnitsMegajoulesPerSquareMeter        EngineeringUnits = 139
+	UnitsMegajoulesPerSquareFoot         EngineeringUnits = 140
+	UnitsNoUnits                         EngineeringUnits = 95
+	UnitsNewtonSeconds                   EngineeringUnits = 187
+	UnitsNewtonsPerMeter                 EngineeringUnits = 188
+	UnitsPartsPerMillion                 EngineeringUnits = 96
+	UnitsPartsPerBillion                 EngineeringUnits = 97
+	UnitsPercent                         EngineeringUnits = 98
+	UnitsPercentObscurationPerFoot       EngineeringUnits = 143
+	UnitsPercentObscurationPerMeter      EngineeringUnits = 144
+	UnitsPercentPerSecond                EngineeringUnits = 99
+	UnitsPerMinute                       EngineeringUnits = 100
+	UnitsPerSecond                       EngineeringUnits = 101
+	UnitsPsiPerDegreeFahrenheit          EngineeringUnits = 102
+	UnitsRadians                         EngineeringUnits = 103
+	UnitsRadiansPerSecond                EngineeringUnits = 184
+	UnitsRevolutionsPerMinute            EngineeringUnits = 104
+	UnitsSquareMetersPerNewton           EngineeringUnits = 185
+	UnitsWattsPerMeterPerDegreeKelvin    EngineeringUnits = 189
+	UnitsWattsPerSquareMeterDegreeKelvin EngineeringUnits = 141
+	UnitsPerMille                        EngineeringUnits = 207
+	UnitsGramsPerGram                    EngineeringUnits = 208
+	UnitsKilogramsPerKilogram            EngineeringUnits = 209
+	UnitsGramsPerKilogram                EngineeringUnits = 210
+	UnitsMilligramsPerGram               EngineeringUnits = 211
+	UnitsMilligramsPerKilogram           EngineeringUnits = 212
+	UnitsGramsPerMilliliter              EngineeringUnits = 213
+	UnitsGramsPerLiter                   EngineeringUnits = 214
+	UnitsMilligramsPerLiter              EngineeringUnits = 215
+	UnitsMicrogramsPerLiter              EngineeringUnits = 216
+	UnitsGramsPerCubicMeter              EngineeringUnits = 217
+	UnitsMilligramsPerCubicMeter         EngineeringUnits = 218
+	UnitsMicrogramsPerCubicMeter         EngineeringUnits = 219
+	UnitsNanogramsPerCubicMeter          EngineeringUnits = 220
+	UnitsGramsPerCubicCentimeter         EngineeringUnits = 221
+	UnitsBecquerels                      EngineeringUnits = 222
+	UnitsKilobecquerels                  EngineeringUnits = 223
+	UnitsMegabecquerels                  EngineeringUnits = 224
+	UnitsGray                            EngineeringUnits = 225
+	UnitsMilligray                       EngineeringUnits = 226
+	UnitsMicrogray                       EngineeringUnits = 227
+	UnitsSieverts                        EngineeringUnits = 228
+	UnitsMillisieverts                   EngineeringUnits = 229
+	UnitsMicrosieverts                   EngineeringUnits = 230
+	UnitsMicrosievertsPerHour            EngineeringUnits = 231
+	UnitsDecibelsA                       EngineeringUnits = 232
+	UnitsNephelometricTurbidityUnit      EngineeringUnits = 233
+	UnitsPH                              EngineeringUnits = 234
+	UnitsGramsPerSquareMeter             EngineeringUnits = 235
+	UnitsMinutesPerDegreeKelvin          EngineeringUnits = 236
+)
+
+// unitNames 工程单位的名称，为常量名去掉Units前缀后的小写连字符形式（如degrees-celsius）
+var unitNames = map[EngineeringUnits]string{
+	UnitsSquareMeters:                    "square-meters",
+	UnitsSquareFeet:                      "square-feet",
+	UnitsSquareCentimeters:               "square-centimeters",
+	UnitsSquareInches:                    "square-inches",
+	UnitsCurrency1:                       "currency1",
+	UnitsCurrency2:                       "currency2",
+	UnitsCurrency3:                       "currency3",
+	UnitsCurrency4:                       "currency4",
+	UnitsCurrency5:                       "currency5",
+	UnitsCurrency6:                       "currency6",
+	UnitsCurrency7:                       "currency7",
+	UnitsCurrency8:                       "currency8",
+	UnitsCurrency9:                       "currency9",
+	UnitsCurrency10:                      "currency10",
+	UnitsMilliamperes:                    "milliamperes",
+	UnitsAmperes:                         "amperes",
+	UnitsAmperesPerMeter:                 "amperes-per-meter",
+	UnitsAmperesPerSquareMeter:           "amperes-per-square-meter",
+	UnitsAmpereSquareMeters:              "ampere-square-meters",
+	UnitsDecibels:                        "decibels",
+	UnitsDecibelsMillivolt:               "decibels-millivolt",
+	UnitsDecibelsVolt:                    "decibels-volt",
+	UnitsFarads:                          "farads",
+	UnitsHenrys:                          "henrys",
+	UnitsOhms:                            "ohms",
+	UnitsOhmMeterSquaredPerMeter:         "ohm-meter-squared-per-meter",
+	UnitsOhmMeters:                       "ohm-meters",
+	UnitsMilliohms:                       "milliohms",
+	UnitsKilohms:                         "kilohms",
+	UnitsMegohms:                         "megohms",
+	UnitsMicrosiemens:                    "microsiemens",
+	UnitsMillisiemens:                    "millisiemens",
+	UnitsSiemens:                         "siemens",
+	UnitsSiemensPerMeter:                 "siemens-per-meter",
+	UnitsTeslas:                          "teslas",
+	UnitsVolts:                           "volts",
+	UnitsMillivolts:                      "millivolts",
+	UnitsKilovolts:                       "kilovolts",
+	UnitsMegavolts:                       "megavolts",
+	UnitsVoltAmperes:                     "volt-amperes",
+	UnitsKilovoltAmperes:                 "kilovolt-amperes",
+	UnitsMegavoltAmperes:                 "megavolt-amperes",
+	UnitsVoltAmperesReactive:             "volt-amperes-reactive",
+	UnitsKilovoltAmperesReactive:         "kilovolt-amperes-reactive",
+	UnitsMegavoltAmperesReactive:         "megavolt-amperes-reactive",
+	UnitsVoltsPerDegreeKelvin:            "volts-per-degree-kelvin",
+	UnitsVoltsPerMeter:                   "volts-per-meter",
+	UnitsDegreesPhase:                    "degrees-phase",
+	UnitsPowerFactor:                     "power-factor",
+	UnitsWebers:                          "webers",
+	UnitsAmpereSeconds:                   "ampere-seconds",
+	UnitsVoltAmpereHours:                 "volt-ampere-hours",
+	UnitsKilovoltAmpereHours:             "kilovolt-ampere-hours",
+	UnitsMegavoltAmpereHours:             "megavolt-ampere-hours",
+	UnitsVoltAmpereHoursReactive:         "volt-ampere-hours-reactive",
+	UnitsKilovoltAmpereHoursReactive:     "kilovolt-ampere-hours-reactive",
+	UnitsMegavoltAmpereHoursReactive:     "megavolt-ampere-hours-reactive",
+	UnitsVoltSquareHours:                 "volt-square-hours",
+	UnitsAmpereSquareHours:               "ampere-square-hours",
+	UnitsJoules:                          "joules",
+	UnitsKilojoules:                      "kilojoules",
+	UnitsKilojoulesPerKilogram:           "kilojoules-per-kilogram",
+	UnitsMegajoules:                      "megajoules",
+	UnitsWattHours:                       "watt-hours",
+	UnitsKilowattHours:                   "kilowatt-hours",
+	UnitsMegawattHours:                   "megawatt-hours",
+	UnitsWattHoursReactive:               "watt-hours-reactive",
+	UnitsKilowattHoursReactive:           "kilowatt-hours-reactive",
+	UnitsMegawattHoursReactive:           "megawatt-hours-reactive",
+	UnitsBtus:                            "btus",
+	UnitsKiloBtus:                        "kilo-btus",
+	UnitsMegaBtus:                        "mega-btus",
+	UnitsTherms:                          "therms",
+	UnitsTonHours:                        "ton-hours",
+	UnitsJoulesPerKilogramDryAir:         "joules-per-kilogram-dry-air",
+	UnitsKilojoulesPerKilogramDryAir:     "kilojoules-per-kilogram-dry-air",
+	UnitsMegajoulesPerKilogramDryAir:     "megajoules-per-kilogram-dry-air",
+	UnitsBtusPerPoundDryAir:              "btus-per-pound-dry-air",
+	UnitsBtusPerPound:                    "btus-per-pound",
+	UnitsJoulesPerDegreeKelvin:           "joules-per-degree-kelvin",
+	UnitsKilojoulesPerDegreeKelvin:       "kilojoules-per-degree-kelvin",
+	UnitsMegajoulesPerDegreeKelvin:       "megajoules-per-degree-kelvin",
+	UnitsJoulesPerKilogramDegreeKelvin:   "joules-per-kilogram-degree-kelvin",
+	UnitsNewton:                          "newton",
+	UnitsCyclesPerHour:                   "cycles-per-hour",
+	UnitsCyclesPerMinute:                 "cycles-per-minute",
+	UnitsHertz:                           "hertz",
+	UnitsKilohertz:                       "kilohertz",
+	UnitsMegahertz:                       "megahertz",
+	UnitsPerHour:                         "per-hour",
+	UnitsGramsOfWaterPerKilogramDryAir:   "grams-of-water-per-kilogram-dry-air",
+	UnitsPercentRelativeHumidity:         "percent-relative-humidity",
+	UnitsMicrometers:                     "micrometers",
+	UnitsMillimeters:                     "millimeters",
+	UnitsCentimeters:                     "centimeters",
+	UnitsKilometers:                      "kilometers",
+	UnitsMeters:                          "meters",
+	UnitsInches:                          "inches",
+	UnitsFeet:                            "feet",
+	UnitsCandelas:                        "candelas",
+	UnitsCandelasPerSquareMeter:          "candelas-per-square-meter",
+	UnitsWattsPerSquareFoot:              "watts-per-square-foot",
+	UnitsWattsPerSquareMeter:             "watts-per-square-meter",
+	UnitsLumens:                          "lumens",
+	UnitsLuxes:                           "luxes",
+	UnitsFootCandles:                     "foot-candles",
+	UnitsMilligrams:                      "milligrams",
+	UnitsGrams:                           "grams",
+	UnitsKilograms:                       "kilograms",
+	UnitsPoundsMass:                      "pounds-mass",
+	UnitsTons:                            "tons",
+	UnitsGramsPerSecond:                  "grams-per-second",
+	UnitsGramsPerMinute:                  "grams-per-minute",
+	UnitsKilogramsPerSecond:              "kilograms-per-second",
+	UnitsKilogramsPerMinute:              "kilograms-per-minute",
+	UnitsKilogramsPerHour:                "kilograms-per-hour",
+	UnitsPoundsMassPerSecond:             "pounds-mass-per-second",
+	UnitsPoundsMassPerMinute:             "pounds-mass-per-minute",
+	UnitsPoundsMassPerHour:               "pounds-mass-per-hour",
+	UnitsTonsPerHour:                     "tons-per-hour",
+	UnitsMilliwatts:                      "milliwatts",
+	UnitsWatts:                           "watts",
+	UnitsKilowatts:                       "kilowatts",
+	UnitsMegawatts:                       "megawatts",
+	UnitsBtusPerHour:                     "btus-per-hour",
+	UnitsKiloBtusPerHour:                 "kilo-btus-per-hour",
+	UnitsHorsepower:                      "horsepower",
+	UnitsTonsRefrigeration:               "tons-refrigeration",
+	UnitsPascals:                         "pascals",
+	UnitsHectopascals:                    "hectopascals",
+	UnitsKilopascals:                     "kilopascals",
+	UnitsMillibars:                       "millibars",
+	UnitsBars:                            "bars",
+	UnitsPoundsForcePerSquareInch:        "pounds-force-per-square-inch",
+	UnitsMillimetersOfWater:              "millimeters-of-water",
+	UnitsCentimetersOfWater:              "centimeters-of-water",
+	UnitsInchesOfWater:                   "inches-of-water",
+	UnitsMillimetersOfMercury:            "millimeters-of-mercury",
+	UnitsCentimetersOfMercury:            "centimeters-of-mercury",
+	UnitsInchesOfMercury:                 "inches-of-mercury",
+	UnitsDegreesCelsius:                  "degrees-celsius",
+	UnitsDegreesKelvin:                   "degrees-kelvin",
+	UnitsDegreesKelvinPerHour:            "degrees-kelvin-per-hour",
+	UnitsDegreesKelvinPerMinute:          "degrees-kelvin-per-minute",
+	UnitsDegreesFahrenheit:               "degrees-fahrenheit",
+	UnitsDegreeDaysCelsius:               "degree-days-celsius",
+	UnitsDegreeDaysFahrenheit:            "degree-days-fahrenheit",
+	UnitsDeltaDegreesFahrenheit:          "delta-degrees-fahrenheit",
+	UnitsDeltaDegreesKelvin:              "delta-degrees-kelvin",
+	UnitsYears:                           "years",
+	UnitsMonths:                          "months",
+	UnitsWeeks:                           "weeks",
+	UnitsDays:                            "days",
+	UnitsHours:                           "hours",
+	UnitsMinutes:                         "minutes",
+	UnitsSeconds:                         "seconds",
+	UnitsHundredthsSeconds:               "hundredths-seconds",
+	UnitsMilliseconds:                    "milliseconds",
+	UnitsNewtonMeters:                    "newton-meters",
+	UnitsMillimetersPerSecond:            "millimeters-per-second",
+	UnitsMillimetersPerMinute:            "millimeters-per-minute",
+	UnitsMetersPerSecond:                 "meters-per-second",
+	UnitsMetersPerMinute:                 "meters-per-minute",
+	UnitsMetersPerHour:                   "meters-per-hour",
+	UnitsKilometersPerHour:               "kilometers-per-hour",
+	UnitsFeetPerSecond:                   "feet-per-second",
+	UnitsFeetPerMinute:                   "feet-per-minute",
+	UnitsMilesPerHour:                    "miles-per-hour",
+	UnitsCubicFeet:                       "cubic-feet",
+	UnitsCubicMeters:                     "cubic-meters",
+	UnitsImperialGallons:                 "imperial-gallons",
+	UnitsMilliliters:                     "milliliters",
+	UnitsLiters:                          "liters",
+	UnitsUsGallons:                       "us-gallons",
+	UnitsCubicFeetPerSecond:              "cubic-feet-per-second",
+	UnitsCubicFeetPerMinute:              "cubic-feet-per-minute",
+	UnitsCubicFeetPerHour:                "cubic-feet-per-hour",
+	UnitsCubicMetersPerSecond:            "cubic-meters-per-second",
+	UnitsCubicMetersPerMinute:            "cubic-meters-per-minute",
+	UnitsCubicMetersPerHour:              "cubic-meters-per-hour",
+	UnitsImperialGallonsPerMinute:        "imperial-gallons-per-minute",
+	UnitsMillilitersPerSecond:            "milliliters-per-second",
+	UnitsLitersPerSecond:                 "liters-per-second",
+	UnitsLitersPerMinute:                 "liters-per-minute",
+	UnitsLitersPerHour:                   "liters-per-hour",
+	UnitsUsGallonsPerMinute:              "us-gallons-per-minute",
+	UnitsUsGallonsPerHour:                "us-gallons-per-hour",
+	UnitsDegreesAngular:                  "degrees-angular",
+	UnitsDegreesCelsiusPerHour:           "degrees-celsius-per-hour",
+	UnitsDegreesCelsiusPerMinute:         "degrees-celsius-per-minute",
+	UnitsDegreesFahrenheitPerHour:        "degrees-fahrenheit-per-hour",
+	UnitsDegreesFahrenheitPerMinute:      "degrees-fahrenheit-per-minute",
+	UnitsJouleSeconds:                    "joule-seconds",
+	UnitsKilogramsPerCubicMeter:          "kilograms-per-cubic-meter",
+	UnitsKwHoursPerSquareMeter:           "kw-hours-per-square-meter",
+	UnitsKwHoursPerSquareFoot:            "kw-hours-per-square-foot",
+	UnitsMegajoulesPerSquareMeter:        "megajoules-per-square-meter",
+	UnitsMegajoulesPerSquareFoot:         "megajoules-per-square-foot",
+	UnitsNoUnits:                         "no-units",
+	UnitsNewtonSeconds:                   "newton-seconds",
+	UnitsNewtonsPerMeter:                 "newtons-per-meter",
+	UnitsPartsPerMillion:                 "parts-per-million",
+	UnitsPartsPerBillion:                 "parts-per-billion",
+	UnitsPercent:                         "percent",
+	UnitsPercentObscurationPerFoot:       "percent-obscuration-per-foot",
+	UnitsPercentObscurationPerMeter:      "percent-obscuration-per-meter",
+	UnitsPercentPerSecond:                "percent-per-second",
+	UnitsPerMinute:                       "per-minute",
+	UnitsPerSecond:                       "per-second",
+	UnitsPsiPerDegreeFahrenheit:          "psi-per-degree-fahrenheit",
+	UnitsRadians:                         "radians",
+	UnitsRadiansPerSecond:                "radians-per-second",
+	UnitsRevolutionsPerMinute:            "revolutions-per-minute",
+	UnitsSquareMetersPerNewton:           "square-meters-per-newton",
+	UnitsWattsPerMeterPerDegreeKelvin:    "watts-per-meter-per-degree-kelvin",
+	UnitsWattsPerSquareMeterDegreeKelvin: "watts-per-square-meter-degree-kelvin",
+	UnitsPerMille:                        "per-mille",
+	UnitsGramsPerGram:                    "grams-per-gram",
+	UnitsKilogramsPerKilogram:            "kilograms-per-kilogram",
+	UnitsGramsPerKilogram:                "grams-per-kilogram",
+	UnitsMilligramsPerGram:               "milligrams-per-gram",
+	UnitsMilligramsPerKilogram:           "milligrams-per-kilogram",
+	UnitsGramsPerMilliliter:              "grams-per-milliliter",
+	UnitsGramsPerLiter:                   "grams-per-liter",
+	UnitsMilligramsPerLiter:              "milligrams-per-liter",
+	UnitsMicrogramsPerLiter:              "micrograms-per-liter",
+	UnitsGramsPerCubicMeter:              "grams-per-cubic-meter",
+	UnitsMilligramsPerCubicMeter:         "milligrams-per-cubic-meter",
+	UnitsMicrogramsPerCubicMeter:         "micrograms-per-cubic-meter",
+	UnitsNanogramsPerCubicMeter:          "nanograms-per-cubic-meter",
+	UnitsGramsPerCubicCentimeter:         "grams-per-cubic-centimeter",
+	UnitsBecquerels:                      "becquerels",
+	UnitsKilobecquerels:                  "kilobecquerels",
+	UnitsMegabecquerels:                  "megabecquerels",
+	UnitsGray:                            "gray",
+	UnitsMilligray:                       "milligray",
+	UnitsMicrogray:                       "microgray",
+	UnitsSieverts:                        "sieverts",
+	UnitsMillisieverts:                   "millisieverts",
+	UnitsMicrosieverts:                   "microsieverts",
+	UnitsMicrosievertsPerHour:            "microsieverts-per-hour",
+	UnitsDecibelsA:                       "decibels-a",
+	UnitsNephelometricTurbidityUnit:      "nephelometric-turbidity-unit",
+	UnitsPH:                              "ph",
+	UnitsGramsPerSquareMeter:             "grams-per-square-meter",
+	UnitsMinutesPerDegreeKelvin:          "minutes-per-degree-kelvin",
+}
+
+// String 返回工程单位的名称，未知单位返回数值形式
+func (u EngineeringUnits) String() string {
+	if name, ok := unitNames[u]; ok {
+		return name
+	}
+	return fmt.Sprintf("units-%d", uint16(u))
+}
+
+// ParseEngineeringUnits 按名称（如degrees-celsius）或数值解析工程单位
+func ParseEngineeringUnits(text string) (EngineeringUnits, error) {
+	for units, name := range unitNames {
+		if strings.EqualFold(name, text) {
+			return units, nil
+		}
+	}
+	number, err := strconv.ParseUint(strings.TrimPrefix(text, "units-"), 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("未知的工程单位: %s", text)
+	}
+	return EngineeringUnits(number), nil
+}
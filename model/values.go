@@ -0,0 +1,124 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// ValueObject 表示标准值对象族（字符串、整数、正整数、大模拟量、日期时间、八位字节串值），
+// 写入Present_Value时按对象类型校验并转换数据类型
+type ValueObject struct {
+	*BACnetObject
+}
+
+// NewCharacterStringValue 创建字符串值对象，Present_Value类型为string
+func NewCharacterStringValue(instance uint32, name string, value string) *ValueObject {
+	return newValueObject(ObjectTypeCharacterStringValue, instance, name, value)
+}
+
+// NewIntegerValue 创建整数值对象，Present_Value类型为int32
+func NewIntegerValue(instance uint32, name string, value int32) *ValueObject {
+	return newValueObject(ObjectTypeIntegerValue, instance, name, value)
+}
+
+// NewPositiveIntegerValue 创建正整数值对象，Present_Value类型为uint32
+func NewPositiveIntegerValue(instance uint32, name string, value uint32) *ValueObject {
+	return newValueObject(ObjectTypePositiveIntegerValue, instance, name, value)
+}
+
+// NewLargeAnalogValue 创建大模拟量值对象，Present_Value类型为float64（Double）
+func NewLargeAnalogValue(instance uint32, name string, value float64) *ValueObject {
+	return newValueObject(ObjectTypeLargeAnalogValue, instance, name, value)
+}
+
+// NewDateTimeValue 创建日期时间值对象，Present_Value类型为time.Time
+func NewDateTimeValue(instance uint32, name string, value time.Time) *ValueObject {
+	return newValueObject(ObjectTypeDateTimeValue, instance, name, value)
+}
+
+// NewOctetStringValue 创建八位字节串值对象，Present_Value类型为[]byte
+func NewOctetStringValue(instance uint32, name string, value []byte) *ValueObject {
+	return newValueObject(ObjectTypeOctetStringValue, instance, name, append([]byte{}, value...))
+}
+
+// newValueObject 创建值对象并设置初始Present_Value
+func newValueObject(objType ObjectType, instance uint32, name string, value interface{}) *ValueObject {
+	valueObject := &ValueObject{BACnetObject: NewBACnetObject(objType, instance, name)}
+	valueObject.Properties[PropertyIdentifierPresentValue] = value
+	return valueObject
+}
+
+// WriteProperty 写入值对象属性，Present_Value转换为对象类型要求的数据类型
+func (v *ValueObject) WriteProperty(prop PropertyIdentifier, value interface{}) error {
+	if prop == PropertyIdentifierPresentValue {
+		converted, err := v.convertPresentValue(value)
+		if err != nil {
+			return err
+		}
+		value = converted
+	}
+	return v.BACnetObject.WriteProperty(prop, value)
+}
+
+// convertPresentValue 将写入值转换为对象类型对应的Present_Value数据类型
+func (v *ValueObject) convertPresentValue(value interface{}) (interface{}, error) {
+	switch v.GetObjectType() {
+	case ObjectTypeCharacterStringValue:
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+	case ObjectTypeIntegerValue:
+		if n, ok := toInt64(value); ok {
+			if n < math.MinInt32 || n > math.MaxInt32 {
+				return nil, ErrValueOutOfRange
+			}
+			return int32(n), nil
+		}
+	case ObjectTypePositiveIntegerValue:
+		if n, ok := toInt64(value); ok {
+			if n < 0 || n > math.MaxUint32 {
+				return nil, ErrValueOutOfRange
+			}
+			return uint32(n), nil
+		}
+	case ObjectTypeLargeAnalogValue:
+		if f, ok := toFloat64(value); ok {
+			return f, nil
+		}
+	case ObjectTypeDateTimeValue:
+		if t, ok := value.(time.Time); ok {
+			return t, nil
+		}
+	case ObjectTypeOctetStringValue:
+		if b, ok := value.([]byte); ok {
+			return append([]byte{}, b...), nil
+		}
+	}
+	return nil, fmt.Errorf("Present_Value数据类型无效: %T", value)
+}
+
+// toInt64 将整数类型转换为int64
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint:
+		return int64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,80 @@
+package model
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestValueObjectPresentValue(t *testing.T) {
+	now := time.Date(2024, 3, 1, 8, 30, 0, 0, time.Local)
+	tests := []struct {
+		name  string
+		obj   *ValueObject
+		value interface{}
+		want  interface{}
+	}{
+		{"CharacterString", NewCharacterStringValue(1, "Text", ""), "hello", "hello"},
+		{"Integer from uint32", NewIntegerValue(1, "Offset", 0), uint32(70000), int32(70000)},
+		{"Integer minimum", NewIntegerValue(1, "Offset", 0), int64(math.MinInt32), int32(math.MinInt32)},
+		{"Positive Integer from int32", NewPositiveIntegerValue(1, "Count", 0), int32(5), uint32(5)},
+		{"Positive Integer maximum", NewPositiveIntegerValue(1, "Count", 0), int64(math.MaxUint32), uint32(math.MaxUint32)},
+		{"Large Analog from REAL", NewLargeAnalogValue(1, "Energy", 0), float32(1.5), 1.5},
+		{"DateTime", NewDateTimeValue(1, "Next", time.Time{}), now, now},
+		{"OctetString", NewOctetStringValue(1, "Raw", nil), []byte{0x01, 0x02}, []byte{0x01, 0x02}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.obj.WriteProperty(PropertyIdentifierPresentValue, tt.value); err != nil {
+				t.Fatalf("WriteProperty() = %v", err)
+			}
+			if got, _ := tt.obj.ReadProperty(PropertyIdentifierPresentValue); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Present_Value = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValueObjectRangeChecks(t *testing.T) {
+	tests := []struct {
+		name  string
+		obj   *ValueObject
+		value interface{}
+		want  error // nil表示只要求返回错误
+	}{
+		{"Integer above range", NewIntegerValue(1, "Offset", 0), int64(math.MaxInt32) + 1, ErrValueOutOfRange},
+		{"Integer below range", NewIntegerValue(1, "Offset", 0), int64(math.MinInt32) - 1, ErrValueOutOfRange},
+		{"Positive Integer negative", NewPositiveIntegerValue(1, "Count", 0), int32(-1), ErrValueOutOfRange},
+		{"Positive Integer above range", NewPositiveIntegerValue(1, "Count", 0), int64(math.MaxUint32) + 1, ErrValueOutOfRange},
+		{"Integer from REAL", NewIntegerValue(1, "Offset", 0), float32(1), nil},
+		{"CharacterString from integer", NewCharacterStringValue(1, "Text", ""), uint32(1), nil},
+		{"DateTime from string", NewDateTimeValue(1, "Next", time.Time{}), "2024-03-01", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, _ := tt.obj.ReadProperty(PropertyIdentifierPresentValue)
+			err := tt.obj.WriteProperty(PropertyIdentifierPresentValue, tt.value)
+			if err == nil || (tt.want != nil && !errors.Is(err, tt.want)) {
+				t.Fatalf("WriteProperty(%#v) = %v, want %v", tt.value, err, tt.want)
+			}
+			if after, _ := tt.obj.ReadProperty(PropertyIdentifierPresentValue); !reflect.DeepEqual(after, before) {
+				t.Errorf("Present_Value changed to %#v after rejected write", after)
+			}
+		})
+	}
+}
+
+func TestOctetStringValueCopies(t *testing.T) {
+	initial := []byte{0x01}
+	obj := NewOctetStringValue(1, "Raw", initial)
+	written := []byte{0x02, 0x03}
+	obj.WriteProperty(PropertyIdentifierPresentValue, written)
+
+	// 修改调用方的切片不影响Present_Value
+	initial[0], written[0] = 0xFF, 0xFF
+	if got, _ := obj.ReadProperty(PropertyIdentifierPresentValue); !reflect.DeepEqual(got, []byte{0x02, 0x03}) {
+		t.Errorf("Present_Value = % X, want 02 03", got)
+	}
+}
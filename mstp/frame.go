@@ -0,0 +1,140 @@
+// Package mstp 实现BACnet MS/TP（主从/令牌传递）数据链路层（Clause 9）
+package mstp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MS/TP帧类型
+const (
+	FrameTypeToken                       = 0x00
+	FrameTypePollForMaster               = 0x01
+	FrameTypeReplyToPollForMaster        = 0x02
+	FrameTypeTestRequest                 = 0x03
+	FrameTypeTestResponse                = 0x04
+	FrameTypeBACnetDataExpectingReply    = 0x05
+	FrameTypeBACnetDataNotExpectingReply = 0x06
+	FrameTypeReplyPostponed              = 0x07
+)
+
+const (
+	// BroadcastAddress MS/TP广播地址
+	BroadcastAddress = 0xFF
+	// MaxMasterAddress 主节点地址的最大值
+	MaxMasterAddress = 127
+	// MaxDataLength 非扩展帧数据的最大长度
+	MaxDataLength = 501
+
+	preamble1    = 0x55
+	preamble2    = 0xFF
+	headerLength = 8 // 前导码(2) + 帧类型(1) + 目标(1) + 源(1) + 长度(2) + 头部CRC(1)
+)
+
+// ErrBadCRC 帧头部或数据的CRC校验失败
+var ErrBadCRC = errors.New("bad CRC")
+
+// Frame MS/TP帧
+type Frame struct {
+	Type        byte
+	Destination byte
+	Source      byte
+	Data        []byte
+}
+
+// headerCRC 计算一个字节后的头部CRC（Annex G.1）
+func headerCRC(data byte, crc byte) byte {
+	c := uint16(crc ^ data)
+	c = c ^ (c << 1) ^ (c << 2) ^ (c << 3) ^ (c << 4) ^ (c << 5) ^ (c << 6) ^ (c << 7)
+	return byte((c & 0xFE) ^ ((c >> 8) & 1))
+}
+
+// dataCRC 计算一个字节后的数据CRC（Annex G.2，CRC-CCITT）
+func dataCRC(data byte, crc uint16) uint16 {
+	low := (crc & 0xFF) ^ uint16(data)
+	return (crc >> 8) ^ (low << 8) ^ (low << 3) ^ (low << 12) ^ (low >> 4) ^ (low & 0x0F) ^ ((low & 0x0F) << 7)
+}
+
+// Encode 编码MS/TP帧，数据长度超过MaxDataLength时返回错误
+func (f Frame) Encode() ([]byte, error) {
+	if len(f.Data) > MaxDataLength {
+		return nil, fmt.Errorf("MS/TP帧数据过长: %d字节", len(f.Data))
+	}
+	out := make([]byte, 0, headerLength+len(f.Data)+2)
+	out = append(out, preamble1, preamble2, f.Type, f.Destination, f.Source, byte(len(f.Data)>>8), byte(len(f.Data)))
+	crc := byte(0xFF)
+	for _, b := range out[2:] {
+		crc = headerCRC(b, crc)
+	}
+	out = append(out, ^crc)
+	if len(f.Data) == 0 {
+		return out, nil
+	}
+
+	crc16 := uint16(0xFFFF)
+	for _, b := range f.Data {
+		crc16 = dataCRC(b, crc16)
+	}
+	crc16 = ^crc16
+	out = append(out, f.Data...)
+	return append(out, byte(crc16), byte(crc16>>8)), nil // 数据CRC低字节在前
+}
+
+// ReadFrame 从r读取下一个MS/TP帧，跳过前导码之前的字节。
+// CRC错误时返回ErrBadCRC，调用方可以继续读取下一帧
+func ReadFrame(r *bufio.Reader) (Frame, error) {
+	// 查找前导码0x55 0xFF
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return Frame{}, err
+		}
+		if b != preamble1 {
+			continue
+		}
+		next, err := r.Peek(1)
+		if err != nil {
+			return Frame{}, err
+		}
+		if next[0] == preamble2 {
+			r.ReadByte()
+			break
+		}
+	}
+
+	header := make([]byte, headerLength-2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return Frame{}, err
+	}
+	crc := byte(0xFF)
+	for _, b := range header {
+		crc = headerCRC(b, crc)
+	}
+	if crc != 0x55 { // 包含CRC字节在内的余数
+		return Frame{}, fmt.Errorf("MS/TP帧头部: %w", ErrBadCRC)
+	}
+
+	frame := Frame{Type: header[0], Destination: header[1], Source: header[2]}
+	length := int(header[3])<<8 | int(header[4])
+	if length == 0 {
+		return frame, nil
+	}
+	if length > MaxDataLength {
+		return Frame{}, fmt.Errorf("MS/TP帧数据过长: %d字节", length)
+	}
+	data := make([]byte, length+2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return Frame{}, err
+	}
+	crc16 := uint16(0xFFFF)
+	for _, b := range data {
+		crc16 = dataCRC(b, crc16)
+	}
+	if crc16 != 0xF0B8 { // 包含CRC字节在内的余数
+		return Frame{}, fmt.Errorf("MS/TP帧数据: %w", ErrBadCRC)
+	}
+	frame.Data = data[:length]
+	return frame, nil
+}
@@ -0,0 +1,121 @@
+package mstp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestFrameCRC(t *testing.T) {
+	// Annex G中的示例：发给0x10的令牌帧，以及数据01 22 30的CRC
+	token, err := Frame{Type: FrameTypeToken, Destination: 0x10, Source: 0x05}.Encode()
+	if want := []byte{0x55, 0xFF, 0x00, 0x10, 0x05, 0x00, 0x00, 0x8C}; err != nil || !bytes.Equal(token, want) {
+		t.Errorf("token frame = % X, %v; want % X", token, err, want)
+	}
+	data, err := Frame{Type: FrameTypeBACnetDataNotExpectingReply, Destination: 1, Source: 2, Data: []byte{0x01, 0x22, 0x30}}.Encode()
+	if err != nil || !bytes.Equal(data[len(data)-2:], []byte{0x10, 0xBD}) {
+		t.Errorf("data frame = % X, %v", data, err)
+	}
+	if _, err := (Frame{Data: make([]byte, MaxDataLength+1)}).Encode(); err == nil {
+		t.Error("Encode() of oversized frame: want error")
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	frame := Frame{Type: FrameTypeBACnetDataExpectingReply, Destination: 3, Source: 7, Data: []byte{0x01, 0x04, 0x00, 0x05}}
+	encoded, _ := frame.Encode()
+	corrupt := append([]byte(nil), encoded...)
+	corrupt[9] ^= 0x01
+
+	var stream []byte
+	stream = append(stream, 0x00, 0x55, 0x55) // 前导码之前的噪声
+	stream = append(stream, corrupt...)
+	stream = append(stream, encoded...)
+	r := bufio.NewReader(bytes.NewReader(stream))
+
+	if _, err := ReadFrame(r); !errors.Is(err, ErrBadCRC) {
+		t.Fatalf("corrupt frame: err = %v, want ErrBadCRC", err)
+	}
+	got, err := ReadFrame(r)
+	if err != nil || got.Type != frame.Type || got.Destination != 3 || got.Source != 7 || !bytes.Equal(got.Data, frame.Data) {
+		t.Fatalf("ReadFrame() = %+v, %v", got, err)
+	}
+	if _, err := ReadFrame(r); err != io.EOF {
+		t.Errorf("ReadFrame() at end = %v, want EOF", err)
+	}
+}
+
+// pipePort 以两个管道模拟串口
+type pipePort struct {
+	*io.PipeReader
+	*io.PipeWriter
+}
+
+func (p pipePort) Close() error {
+	p.PipeReader.Close()
+	return p.PipeWriter.Close()
+}
+
+func TestNodeTokenPassing(t *testing.T) {
+	toNode, fromPeer := io.Pipe()
+	fromNode, toPeer := io.Pipe()
+	node, err := NewNode(pipePort{toNode, toPeer}, Config{MAC: 3, MaxMaster: 5}, func(npdu []byte, source byte, expectingReply bool) []byte {
+		return append([]byte{source}, npdu...)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Close()
+	go node.Run()
+
+	frames := make(chan Frame, 16)
+	go func() {
+		r := bufio.NewReader(fromNode)
+		for {
+			frame, err := ReadFrame(r)
+			if err != nil {
+				close(frames)
+				return
+			}
+			frames <- frame
+		}
+	}()
+	send := func(frame Frame) {
+		data, _ := frame.Encode()
+		fromPeer.Write(data)
+	}
+	expect := func(frameType, destination byte, data []byte) {
+		t.Helper()
+		select {
+		case frame := <-frames:
+			if frame.Type != frameType || frame.Destination != destination || frame.Source != 3 || !bytes.Equal(frame.Data, data) {
+				t.Fatalf("frame = %+v, want type %d to %d with % X", frame, frameType, destination, data)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no frame of type %d to %d", frameType, destination)
+		}
+	}
+
+	send(Frame{Type: FrameTypePollForMaster, Destination: 3, Source: 1})
+	expect(FrameTypeReplyToPollForMaster, 1, nil)
+
+	// 确认请求立即应答
+	send(Frame{Type: FrameTypeBACnetDataExpectingReply, Destination: 3, Source: 1, Data: []byte{0xAA}})
+	expect(FrameTypeBACnetDataNotExpectingReply, 1, []byte{1, 0xAA})
+
+	// 持有令牌时发送排队的数据，然后轮询寻找后继主节点，将令牌交给应答的节点1
+	if err := node.Send(BroadcastAddress, []byte{0xBB}, true); err != nil {
+		t.Fatal(err)
+	}
+	send(Frame{Type: FrameTypeToken, Destination: 3, Source: 1})
+	expect(FrameTypeBACnetDataNotExpectingReply, BroadcastAddress, []byte{0xBB})
+	for _, station := range []byte{4, 5, 0} {
+		expect(FrameTypePollForMaster, station, nil)
+	}
+	expect(FrameTypePollForMaster, 1, nil)
+	send(Frame{Type: FrameTypeReplyToPollForMaster, Destination: 3, Source: 1})
+	expect(FrameTypeToken, 1, nil)
+}
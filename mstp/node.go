@@ -0,0 +1,381 @@
+package mstp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// MS/TP定时参数（Clause 9.5.3）
+const (
+	tNoToken      = 500 * time.Millisecond // 判定令牌丢失的静默时间
+	tReplyTimeout = 255 * time.Millisecond // 等待数据应答的时间
+	tUsageTimeout = 50 * time.Millisecond  // 传递令牌或轮询后等待对方使用链路的时间
+	tSlot         = 10 * time.Millisecond  // 令牌丢失后按地址错开生成令牌的时隙
+	nPoll         = 50                     // 每传递多少次令牌轮询一次空闲地址
+	nRetryToken   = 1                      // 传递令牌无应答时的重试次数
+)
+
+// Handler 处理收到的NPDU，返回的应答NPDU发回给源节点，无应答时返回nil
+type Handler func(npdu []byte, source byte, expectingReply bool) []byte
+
+// Config MS/TP主节点配置
+type Config struct {
+	MAC           byte // 本节点地址，0-127
+	MaxMaster     byte // 网段上主节点地址的最大值，为0时使用127
+	MaxInfoFrames int  // 每次持有令牌最多发送的数据帧数，为0时使用1
+
+	Logger *slog.Logger // 为nil时使用slog.Default()
+}
+
+// Node MS/TP主节点：参与令牌传递，持有令牌时发送排队的数据帧
+type Node struct {
+	port    io.ReadWriteCloser
+	config  Config
+	handler Handler
+	log     *slog.Logger
+	frames  chan Frame // 接收到的帧
+	queue   chan Frame // 等待令牌发送的数据帧
+	done    chan struct{}
+	close   sync.Once
+
+	nextStation byte // 令牌的下一个接收者（NS）
+	pollStation byte // 下一个轮询的地址（PS）
+	tokenCount  int
+	soleMaster  bool
+}
+
+// NewNode 在port上创建MS/TP主节点，收到的NPDU交给handler处理
+func NewNode(port io.ReadWriteCloser, config Config, handler Handler) (*Node, error) {
+	if config.MaxMaster == 0 {
+		config.MaxMaster = MaxMasterAddress
+	}
+	if config.MaxInfoFrames <= 0 {
+		config.MaxInfoFrames = 1
+	}
+	if config.MAC > config.MaxMaster || config.MaxMaster > MaxMasterAddress {
+		return nil, fmt.Errorf("无效的MS/TP地址: MAC=%d, MaxMaster=%d", config.MAC, config.MaxMaster)
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+	return &Node{
+		port:        port,
+		config:      config,
+		handler:     handler,
+		log:         config.Logger.With("mac", config.MAC),
+		frames:      make(chan Frame, 16),
+		queue:       make(chan Frame, 32),
+		done:        make(chan struct{}),
+		nextStation: config.MAC,
+		pollStation: config.MAC,
+	}, nil
+}
+
+// Send 将NPDU排队，在下次持有令牌时发往destination（BroadcastAddress为广播）
+func (n *Node) Send(destination byte, npdu []byte, expectingReply bool) error {
+	frameType := byte(FrameTypeBACnetDataNotExpectingReply)
+	if expectingReply && destination != BroadcastAddress {
+		frameType = FrameTypeBACnetDataExpectingReply
+	}
+	select {
+	case n.queue <- Frame{Type: frameType, Destination: destination, Source: n.config.MAC, Data: npdu}:
+		return nil
+	default:
+		return errors.New("MS/TP发送队列已满")
+	}
+}
+
+// SendNPDU 向mac发送NPDU，mac为空时广播，是否期望应答取自NPDU控制字节。
+// 使节点可以作为路由器端口的数据链路
+func (n *Node) SendNPDU(npdu []byte, mac []byte) error {
+	destination := byte(BroadcastAddress)
+	if len(mac) > 0 {
+		if len(mac) != 1 {
+			return fmt.Errorf("无效的MS/TP地址: % X", mac)
+		}
+		destination = mac[0]
+	}
+	return n.Send(destination, npdu, len(npdu) > 1 && npdu[1]&0x04 != 0)
+}
+
+// LocalMAC 返回本站地址
+func (n *Node) LocalMAC() []byte {
+	return []byte{n.config.MAC}
+}
+
+// Close 停止节点并关闭串口
+func (n *Node) Close() error {
+	var err error
+	n.close.Do(func() {
+		close(n.done)
+		err = n.port.Close()
+	})
+	return err
+}
+
+// Run 运行主节点状态机，直到Close或串口出错
+func (n *Node) Run() {
+	go n.receive()
+
+	useToken := false
+	for !n.closed() {
+		if useToken {
+			n.useToken()
+			useToken = n.doneWithToken()
+			continue
+		}
+		frame, ok := n.waitFrame(tNoToken + time.Duration(n.config.MAC)*tSlot)
+		if n.closed() {
+			return
+		}
+		if !ok {
+			// 令牌丢失：从下一个地址开始轮询，寻找后继主节点
+			n.log.Info("MS/TP令牌丢失，开始轮询主节点")
+			n.tokenCount = 0
+			useToken = n.findSuccessor(n.next(n.config.MAC))
+			continue
+		}
+		useToken = n.answer(frame)
+	}
+}
+
+// receive 从串口读取帧，CRC错误的帧被丢弃
+func (n *Node) receive() {
+	defer close(n.frames)
+	r := bufio.NewReader(n.port)
+	for {
+		frame, err := ReadFrame(r)
+		if errors.Is(err, ErrBadCRC) {
+			continue
+		}
+		if err == nil && frame.Source == n.config.MAC {
+			continue // RS-485收发器回显的本节点发送的帧
+		}
+		if err != nil {
+			if !n.closed() {
+				n.log.Warn("MS/TP读取失败", "error", err)
+			}
+			return
+		}
+		select {
+		case n.frames <- frame:
+		case <-n.done:
+			return
+		}
+	}
+}
+
+// closed 判断节点是否已停止
+func (n *Node) closed() bool {
+	select {
+	case <-n.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// waitFrame 等待下一个帧，超时或节点停止时返回false
+func (n *Node) waitFrame(timeout time.Duration) (Frame, bool) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case frame, ok := <-n.frames:
+		if !ok {
+			n.Close()
+		}
+		return frame, ok
+	case <-timer.C:
+	case <-n.done:
+	}
+	return Frame{}, false
+}
+
+// write 发送一个帧
+func (n *Node) write(frame Frame) {
+	data, err := frame.Encode()
+	if err == nil {
+		_, err = n.port.Write(data)
+	}
+	if err != nil && !n.closed() {
+		n.log.Warn("MS/TP发送失败", "error", err)
+	}
+}
+
+// reply 向源节点发送应答帧
+func (n *Node) reply(frameType byte, to byte, data []byte) {
+	n.write(Frame{Type: frameType, Destination: to, Source: n.config.MAC, Data: data})
+}
+
+// answer 处理发给本节点或广播的帧，收到令牌时返回true
+func (n *Node) answer(frame Frame) bool {
+	forMe := frame.Destination == n.config.MAC
+	if !forMe && frame.Destination != BroadcastAddress {
+		return false
+	}
+
+	switch frame.Type {
+	case FrameTypeToken:
+		return forMe
+	case FrameTypePollForMaster:
+		if forMe {
+			n.reply(FrameTypeReplyToPollForMaster, frame.Source, nil)
+		}
+	case FrameTypeTestRequest:
+		if forMe {
+			n.reply(FrameTypeTestResponse, frame.Source, frame.Data)
+		}
+	case FrameTypeBACnetDataExpectingReply:
+		if !forMe {
+			n.deliver(frame)
+			break
+		}
+		// 应答必须在Treply_delay内发出，处理程序没有应答时通知对方应答推迟
+		if response := n.handler(frame.Data, frame.Source, true); response != nil {
+			n.reply(FrameTypeBACnetDataNotExpectingReply, frame.Source, response)
+		} else {
+			n.reply(FrameTypeReplyPostponed, frame.Source, nil)
+		}
+	case FrameTypeBACnetDataNotExpectingReply:
+		n.deliver(frame)
+	}
+	return false
+}
+
+// deliver 将不需要立即应答的数据交给处理程序，有应答（如Who-Is的I-Am）时排队发送
+func (n *Node) deliver(frame Frame) {
+	if response := n.handler(frame.Data, frame.Source, false); response != nil {
+		if err := n.Send(frame.Source, response, false); err != nil {
+			n.log.Warn("MS/TP应答排队失败", "peer", frame.Source, "error", err)
+		}
+	}
+}
+
+// useToken 持有令牌时发送最多MaxInfoFrames个排队的数据帧
+func (n *Node) useToken() {
+	for i := 0; i < n.config.MaxInfoFrames; i++ {
+		var frame Frame
+		select {
+		case frame = <-n.queue:
+		default:
+			return
+		}
+		n.write(frame)
+		if frame.Type == FrameTypeBACnetDataExpectingReply {
+			n.waitForReply(frame.Destination)
+		}
+	}
+}
+
+// waitForReply 等待对方对确认请求的应答
+func (n *Node) waitForReply(from byte) {
+	deadline := time.Now().Add(tReplyTimeout)
+	for {
+		frame, ok := n.waitFrame(time.Until(deadline))
+		if !ok {
+			return
+		}
+		if frame.Source != from || frame.Destination != n.config.MAC {
+			n.answer(frame)
+			continue
+		}
+		switch frame.Type {
+		case FrameTypeBACnetDataNotExpectingReply, FrameTypeTestResponse:
+			n.deliver(frame)
+			return
+		case FrameTypeReplyPostponed:
+			return
+		}
+	}
+}
+
+// doneWithToken 发送完数据后决定令牌去向，本节点继续持有令牌时返回true
+func (n *Node) doneWithToken() bool {
+	n.tokenCount++
+	if n.tokenCount >= nPoll {
+		// 定期轮询本节点与NS之间的一个空闲地址，发现新的主节点时将令牌交给它
+		n.tokenCount = 0
+		n.pollStation = n.next(n.pollStation)
+		if n.pollStation == n.nextStation || n.pollStation == n.config.MAC {
+			n.pollStation = n.config.MAC
+		} else if n.pollForMaster(n.pollStation) {
+			n.nextStation, n.soleMaster = n.pollStation, false
+			n.pollStation = n.config.MAC
+			return n.passToken()
+		}
+	}
+
+	if n.nextStation == n.config.MAC && !n.soleMaster {
+		// 尚不知道后继主节点
+		return n.findSuccessor(n.next(n.config.MAC))
+	}
+	if n.soleMaster {
+		// 唯一的主节点：没有数据时短暂等待，避免空转
+		if frame, ok := n.waitFrame(tUsageTimeout); ok {
+			n.answer(frame)
+		}
+		return true
+	}
+	return n.passToken()
+}
+
+// passToken 将令牌传给NS，对方没有使用链路时重试，仍无响应则寻找新的后继主节点
+func (n *Node) passToken() bool {
+	for retry := 0; retry <= nRetryToken; retry++ {
+		n.reply(FrameTypeToken, n.nextStation, nil)
+		if frame, ok := n.waitFrame(tUsageTimeout); ok {
+			return n.answer(frame)
+		}
+		if n.closed() {
+			return false
+		}
+	}
+	n.log.Warn("MS/TP节点未接收令牌", "peer", n.nextStation)
+	return n.findSuccessor(n.next(n.nextStation))
+}
+
+// findSuccessor 从start开始依次轮询，第一个应答的节点成为NS；
+// 没有其他主节点时本节点成为唯一主节点并继续持有令牌
+func (n *Node) findSuccessor(start byte) bool {
+	for station := start; station != n.config.MAC; station = n.next(station) {
+		if n.pollForMaster(station) {
+			n.nextStation, n.soleMaster = station, false
+			n.pollStation = n.config.MAC
+			return n.passToken()
+		}
+		if n.closed() {
+			return false
+		}
+	}
+	if !n.soleMaster {
+		n.log.Info("MS/TP网段上没有其他主节点")
+	}
+	n.nextStation, n.soleMaster = n.config.MAC, true
+	return true
+}
+
+// pollForMaster 向station发送Poll-For-Master，对方应答时返回true
+func (n *Node) pollForMaster(station byte) bool {
+	n.reply(FrameTypePollForMaster, station, nil)
+	deadline := time.Now().Add(tUsageTimeout)
+	for {
+		frame, ok := n.waitFrame(time.Until(deadline))
+		if !ok {
+			return false
+		}
+		if frame.Type == FrameTypeReplyToPollForMaster && frame.Source == station && frame.Destination == n.config.MAC {
+			return true
+		}
+		n.answer(frame)
+	}
+}
+
+// next 返回地址环上的下一个主节点地址
+func (n *Node) next(station byte) byte {
+	return byte((int(station) + 1) % (int(n.config.MaxMaster) + 1))
+}
@@ -0,0 +1,43 @@
+package mstp
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// baudRates 支持的MS/TP波特率（Clause 9.2.2）
+var baudRates = map[int]uint32{
+	9600:   syscall.B9600,
+	19200:  syscall.B19200,
+	38400:  syscall.B38400,
+	57600:  syscall.B57600,
+	115200: syscall.B115200,
+}
+
+// OpenSerial 以原始模式、8N1和给定波特率打开串口
+func OpenSerial(name string, baud int) (*os.File, error) {
+	speed, ok := baudRates[baud]
+	if !ok {
+		return nil, fmt.Errorf("不支持的MS/TP波特率: %d", baud)
+	}
+	port, err := os.OpenFile(name, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// 原始模式：不做行处理、回显和字符转换；VMIN=1时读取阻塞到至少收到1字节
+	termios := syscall.Termios{
+		Cflag:  speed | syscall.CS8 | syscall.CREAD | syscall.CLOCAL,
+		Ispeed: speed,
+		Ospeed: speed,
+	}
+	termios.Cc[syscall.VMIN] = 1
+	termios.Cc[syscall.VTIME] = 0
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, port.Fd(), syscall.TCSETS, uintptr(unsafe.Pointer(&termios))); errno != 0 {
+		port.Close()
+		return nil, fmt.Errorf("配置串口%s失败: %v", name, errno)
+	}
+	return port, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package mstp
+
+import (
+	"errors"
+	"os"
+)
+
+// OpenSerial 以原始模式、8N1和给定波特率打开串口，目前只支持Linux
+func OpenSerial(name string, baud int) (*os.File, error) {
+	return nil, errors.New("MS/TP串口目前只支持Linux")
+}
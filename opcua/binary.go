@@ -0,0 +1,638 @@
+package opcua
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// 内置数据类型编号（Part 6, 5.1.2）
+const (
+	typeNull            byte = 0
+	typeBoolean         byte = 1
+	typeSByte           byte = 2
+	typeByte            byte = 3
+	typeInt16           byte = 4
+	typeUInt16          byte = 5
+	typeInt32           byte = 6
+	typeUInt32          byte = 7
+	typeInt64           byte = 8
+	typeUInt64          byte = 9
+	typeFloat           byte = 10
+	typeDouble          byte = 11
+	typeString          byte = 12
+	typeDateTime        byte = 13
+	typeGuid            byte = 14
+	typeByteString      byte = 15
+	typeXMLElement      byte = 16
+	typeNodeID          byte = 17
+	typeExpandedNodeID  byte = 18
+	typeStatusCode      byte = 19
+	typeQualifiedName   byte = 20
+	typeLocalizedText   byte = 21
+	typeExtensionObject byte = 22
+	typeDataValue       byte = 23
+	typeVariant         byte = 24
+	typeDiagnosticInfo  byte = 25
+)
+
+// errDecoding 消息比声明的结构短或含有无效的编码
+var errDecoding = errors.New("opcua: 解码失败")
+
+// epochOffset DateTime的起点1601-01-01到Unix时间起点的秒数，DateTime以100纳秒为单位计数
+const epochOffset = 11644473600
+
+// NodeID 节点标识符。Name不为空时为字符串标识符，否则为数字标识符。
+// GUID和ByteString标识符解码为以"g="或"b="开头的Name，不会与本服务端的节点相同
+type NodeID struct {
+	Namespace uint16
+	ID        uint32
+	Name      string
+}
+
+// numeric 返回命名空间0中的数字节点标识符
+func numeric(id uint32) NodeID {
+	return NodeID{ID: id}
+}
+
+func (n NodeID) String() string {
+	if n.Name != "" {
+		return fmt.Sprintf("ns=%d;s=%s", n.Namespace, n.Name)
+	}
+	return fmt.Sprintf("ns=%d;i=%d", n.Namespace, n.ID)
+}
+
+// QualifiedName 带命名空间的浏览名称
+type QualifiedName struct {
+	Namespace uint16
+	Name      string
+}
+
+// ExtensionObject 以二进制编码的结构体，Body为nil时没有内容
+type ExtensionObject struct {
+	TypeID NodeID
+	Body   []byte
+}
+
+// Variant 带内置类型编号的值，数组的Value为[]interface{}，Type为0时为空值
+type Variant struct {
+	Type  byte
+	Array bool
+	Value interface{}
+}
+
+// DataValue 属性值及其状态和时间戳
+type DataValue struct {
+	Value           Variant
+	HasValue        bool
+	Status          StatusCode
+	SourceTimestamp time.Time
+	ServerTimestamp time.Time
+}
+
+// encoder OPC UA二进制编码（小端序）
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) byte(v byte)      { e.buf = append(e.buf, v) }
+func (e *encoder) uint16(v uint16)  { e.buf = binary.LittleEndian.AppendUint16(e.buf, v) }
+func (e *encoder) uint32(v uint32)  { e.buf = binary.LittleEndian.AppendUint32(e.buf, v) }
+func (e *encoder) uint64(v uint64)  { e.buf = binary.LittleEndian.AppendUint64(e.buf, v) }
+func (e *encoder) int32(v int32)    { e.uint32(uint32(v)) }
+func (e *encoder) int64(v int64)    { e.uint64(uint64(v)) }
+func (e *encoder) float(v float32)  { e.uint32(math.Float32bits(v)) }
+func (e *encoder) double(v float64) { e.uint64(math.Float64bits(v)) }
+func (e *encoder) raw(p []byte)     { e.buf = append(e.buf, p...) }
+
+func (e *encoder) boolean(v bool) {
+	if v {
+		e.byte(1)
+	} else {
+		e.byte(0)
+	}
+}
+
+func (e *encoder) string(s string) {
+	e.int32(int32(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// nullString 编码空字符串（长度-1）
+func (e *encoder) nullString() { e.int32(-1) }
+
+// byteString nil编码为空值
+func (e *encoder) byteString(p []byte) {
+	if p == nil {
+		e.int32(-1)
+		return
+	}
+	e.int32(int32(len(p)))
+	e.buf = append(e.buf, p...)
+}
+
+// dateTime 零值编码为0
+func (e *encoder) dateTime(t time.Time) {
+	if t.IsZero() {
+		e.int64(0)
+		return
+	}
+	e.int64(max((t.Unix()+epochOffset)*1e7+int64(t.Nanosecond()/100), 0))
+}
+
+func (e *encoder) statusCode(s StatusCode) { e.uint32(uint32(s)) }
+
+// nodeID 按标识符选择最短的编码
+func (e *encoder) nodeID(n NodeID) {
+	switch {
+	case n.Name != "":
+		e.byte(0x03)
+		e.uint16(n.Namespace)
+		e.string(n.Name)
+	case n.Namespace == 0 && n.ID <= 0xFF:
+		e.byte(0x00)
+		e.byte(byte(n.ID))
+	case n.Namespace <= 0xFF && n.ID <= 0xFFFF:
+		e.byte(0x01)
+		e.byte(byte(n.Namespace))
+		e.uint16(uint16(n.ID))
+	default:
+		e.byte(0x02)
+		e.uint16(n.Namespace)
+		e.uint32(n.ID)
+	}
+}
+
+// expandedNodeID 编码为不带命名空间URI和服务器索引的ExpandedNodeId
+func (e *encoder) expandedNodeID(n NodeID) { e.nodeID(n) }
+
+func (e *encoder) qualifiedName(q QualifiedName) {
+	e.uint16(q.Namespace)
+	e.string(q.Name)
+}
+
+// localizedText 只编码文本，text为空时两者都不编码
+func (e *encoder) localizedText(text string) {
+	if text == "" {
+		e.byte(0)
+		return
+	}
+	e.byte(0x02)
+	e.string(text)
+}
+
+// extensionObject Body为nil时编码为没有内容的空对象
+func (e *encoder) extensionObject(x ExtensionObject) {
+	e.nodeID(x.TypeID)
+	if x.Body == nil {
+		e.byte(0)
+		return
+	}
+	e.byte(0x01)
+	e.byteString(x.Body)
+}
+
+// diagnosticInfos 编码空的DiagnosticInfo数组
+func (e *encoder) diagnosticInfos() { e.int32(-1) }
+
+func (e *encoder) stringArray(values []string) {
+	if values == nil {
+		e.int32(-1)
+		return
+	}
+	e.int32(int32(len(values)))
+	for _, v := range values {
+		e.string(v)
+	}
+}
+
+func (e *encoder) statusCodes(values []StatusCode) {
+	e.int32(int32(len(values)))
+	for _, v := range values {
+		e.statusCode(v)
+	}
+}
+
+func (e *encoder) uint32Array(values []uint32) {
+	e.int32(int32(len(values)))
+	for _, v := range values {
+		e.uint32(v)
+	}
+}
+
+func (e *encoder) variant(v Variant) {
+	mask := v.Type
+	if v.Array {
+		mask |= 0x80
+	}
+	e.byte(mask)
+	if v.Type == typeNull {
+		return
+	}
+	if !v.Array {
+		e.scalar(v.Type, v.Value)
+		return
+	}
+	items, _ := v.Value.([]interface{})
+	e.int32(int32(len(items)))
+	for _, item := range items {
+		e.scalar(v.Type, item)
+	}
+}
+
+// scalar 按内置类型编码一个值，值的Go类型必须与类型编号对应
+func (e *encoder) scalar(t byte, v interface{}) {
+	switch t {
+	case typeBoolean:
+		e.boolean(v.(bool))
+	case typeSByte:
+		e.byte(byte(v.(int8)))
+	case typeByte:
+		e.byte(v.(byte))
+	case typeInt16:
+		e.uint16(uint16(v.(int16)))
+	case typeUInt16:
+		e.uint16(v.(uint16))
+	case typeInt32:
+		e.int32(v.(int32))
+	case typeUInt32:
+		e.uint32(v.(uint32))
+	case typeInt64:
+		e.int64(v.(int64))
+	case typeUInt64:
+		e.uint64(v.(uint64))
+	case typeFloat:
+		e.float(v.(float32))
+	case typeDouble:
+		e.double(v.(float64))
+	case typeString, typeXMLElement:
+		e.string(v.(string))
+	case typeDateTime:
+		e.dateTime(v.(time.Time))
+	case typeByteString:
+		e.byteString(v.([]byte))
+	case typeNodeID:
+		e.nodeID(v.(NodeID))
+	case typeExpandedNodeID:
+		e.expandedNodeID(v.(NodeID))
+	case typeStatusCode:
+		e.statusCode(v.(StatusCode))
+	case typeQualifiedName:
+		e.qualifiedName(v.(QualifiedName))
+	case typeLocalizedText:
+		e.localizedText(v.(string))
+	case typeExtensionObject:
+		e.extensionObject(v.(ExtensionObject))
+	case typeDataValue:
+		e.dataValue(v.(DataValue))
+	case typeVariant:
+		e.variant(v.(Variant))
+	default:
+		panic(fmt.Sprintf("opcua: 不支持编码内置类型%d", t))
+	}
+}
+
+func (e *encoder) dataValue(d DataValue) {
+	var mask byte
+	if d.HasValue {
+		mask |= 0x01
+	}
+	if d.Status != StatusGood {
+		mask |= 0x02
+	}
+	if !d.SourceTimestamp.IsZero() {
+		mask |= 0x04
+	}
+	if !d.ServerTimestamp.IsZero() {
+		mask |= 0x08
+	}
+	e.byte(mask)
+	if d.HasValue {
+		e.variant(d.Value)
+	}
+	if d.Status != StatusGood {
+		e.statusCode(d.Status)
+	}
+	if !d.SourceTimestamp.IsZero() {
+		e.dateTime(d.SourceTimestamp)
+	}
+	if !d.ServerTimestamp.IsZero() {
+		e.dateTime(d.ServerTimestamp)
+	}
+}
+
+// decoder OPC UA二进制解码，出错后所有读取返回零值，错误保存在err中
+type decoder struct {
+	buf []byte
+	err error
+}
+
+// take 取出n个字节，不足时记录错误
+func (d *decoder) take(n int) []byte {
+	if d.err != nil || n < 0 || n > len(d.buf) {
+		d.err = errDecoding
+		return nil
+	}
+	p := d.buf[:n]
+	d.buf = d.buf[n:]
+	return p
+}
+
+func (d *decoder) byte() byte {
+	if p := d.take(1); p != nil {
+		return p[0]
+	}
+	return 0
+}
+
+func (d *decoder) boolean() bool { return d.byte() != 0 }
+
+func (d *decoder) uint16() uint16 {
+	if p := d.take(2); p != nil {
+		return binary.LittleEndian.Uint16(p)
+	}
+	return 0
+}
+
+func (d *decoder) uint32() uint32 {
+	if p := d.take(4); p != nil {
+		return binary.LittleEndian.Uint32(p)
+	}
+	return 0
+}
+
+func (d *decoder) uint64() uint64 {
+	if p := d.take(8); p != nil {
+		return binary.LittleEndian.Uint64(p)
+	}
+	return 0
+}
+
+func (d *decoder) int32() int32           { return int32(d.uint32()) }
+func (d *decoder) int64() int64           { return int64(d.uint64()) }
+func (d *decoder) float() float32         { return math.Float32frombits(d.uint32()) }
+func (d *decoder) double() float64        { return math.Float64frombits(d.uint64()) }
+func (d *decoder) statusCode() StatusCode { return StatusCode(d.uint32()) }
+
+// length 读取数组或字符串的长度，-1（空值）返回-1
+func (d *decoder) length() int {
+	n := d.int32()
+	if n < -1 || int(n) > len(d.buf) {
+		// 每个元素至少占一个字节，长度超过剩余字节数时消息无效
+		d.err = errDecoding
+		return -1
+	}
+	return int(n)
+}
+
+func (d *decoder) string() string {
+	n := d.length()
+	if n <= 0 {
+		return ""
+	}
+	return string(d.take(n))
+}
+
+func (d *decoder) byteString() []byte {
+	n := d.length()
+	if n < 0 {
+		return nil
+	}
+	return append([]byte{}, d.take(n)...)
+}
+
+func (d *decoder) dateTime() time.Time {
+	ticks := d.int64()
+	if ticks <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(ticks/1e7-epochOffset, ticks%1e7*100).UTC()
+}
+
+func (d *decoder) nodeID() NodeID {
+	return d.nodeIDWithFlags(d.byte() & 0x3F)
+}
+
+// nodeIDWithFlags 按编码字节的低6位解码节点标识符
+func (d *decoder) nodeIDWithFlags(encoding byte) NodeID {
+	switch encoding {
+	case 0x00:
+		return NodeID{ID: uint32(d.byte())}
+	case 0x01:
+		ns := d.byte()
+		return NodeID{Namespace: uint16(ns), ID: uint32(d.uint16())}
+	case 0x02:
+		ns := d.uint16()
+		return NodeID{Namespace: ns, ID: d.uint32()}
+	case 0x03:
+		ns := d.uint16()
+		return NodeID{Namespace: ns, Name: d.string()}
+	case 0x04:
+		ns := d.uint16()
+		return NodeID{Namespace: ns, Name: fmt.Sprintf("g=%x", d.take(16))}
+	case 0x05:
+		ns := d.uint16()
+		return NodeID{Namespace: ns, Name: fmt.Sprintf("b=%x", d.byteString())}
+	}
+	d.err = errDecoding
+	return NodeID{}
+}
+
+// expandedNodeID 解码ExpandedNodeId，忽略命名空间URI和服务器索引
+func (d *decoder) expandedNodeID() NodeID {
+	encoding := d.byte()
+	id := d.nodeIDWithFlags(encoding & 0x3F)
+	if encoding&0x80 != 0 {
+		d.string()
+	}
+	if encoding&0x40 != 0 {
+		d.uint32()
+	}
+	return id
+}
+
+func (d *decoder) qualifiedName() QualifiedName {
+	ns := d.uint16()
+	return QualifiedName{Namespace: ns, Name: d.string()}
+}
+
+// localizedText 返回文本，忽略语言
+func (d *decoder) localizedText() string {
+	mask := d.byte()
+	if mask&0x01 != 0 {
+		d.string()
+	}
+	if mask&0x02 != 0 {
+		return d.string()
+	}
+	return ""
+}
+
+func (d *decoder) extensionObject() ExtensionObject {
+	x := ExtensionObject{TypeID: d.nodeID()}
+	switch d.byte() {
+	case 0x00:
+	case 0x01:
+		x.Body = d.byteString()
+	case 0x02:
+		x.Body = []byte(d.string())
+	default:
+		d.err = errDecoding
+	}
+	return x
+}
+
+// diagnosticInfo 跳过一个DiagnosticInfo
+func (d *decoder) diagnosticInfo() {
+	mask := d.byte()
+	for _, bit := range []byte{0x01, 0x02, 0x08, 0x04} {
+		if mask&bit != 0 {
+			d.int32()
+		}
+	}
+	if mask&0x10 != 0 {
+		d.string()
+	}
+	if mask&0x20 != 0 {
+		d.statusCode()
+	}
+	if mask&0x40 != 0 && d.err == nil {
+		d.diagnosticInfo()
+	}
+}
+
+func (d *decoder) stringArray() []string {
+	n := d.length()
+	if n < 0 {
+		return nil
+	}
+	values := make([]string, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		values = append(values, d.string())
+	}
+	return values
+}
+
+func (d *decoder) uint32Array() []uint32 {
+	n := d.length()
+	if n < 0 {
+		return nil
+	}
+	values := make([]uint32, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		values = append(values, d.uint32())
+	}
+	return values
+}
+
+// array 读取数组长度，对每个元素调用item，直到出错
+func (d *decoder) array(item func()) int {
+	n := d.length()
+	for i := 0; i < n && d.err == nil; i++ {
+		item()
+	}
+	return n
+}
+
+func (d *decoder) variant() Variant {
+	mask := d.byte()
+	v := Variant{Type: mask & 0x3F, Array: mask&0x80 != 0}
+	if v.Type > typeDiagnosticInfo {
+		d.err = errDecoding
+		return Variant{}
+	}
+	if !v.Array {
+		if v.Type != typeNull {
+			v.Value = d.scalar(v.Type)
+		}
+		return v
+	}
+	items := []interface{}{}
+	d.array(func() { items = append(items, d.scalar(v.Type)) })
+	v.Value = items
+	if mask&0x40 != 0 {
+		d.array(func() { d.int32() })
+	}
+	return v
+}
+
+// scalar 按内置类型解码一个值
+func (d *decoder) scalar(t byte) interface{} {
+	switch t {
+	case typeBoolean:
+		return d.boolean()
+	case typeSByte:
+		return int8(d.byte())
+	case typeByte:
+		return d.byte()
+	case typeInt16:
+		return int16(d.uint16())
+	case typeUInt16:
+		return d.uint16()
+	case typeInt32:
+		return d.int32()
+	case typeUInt32:
+		return d.uint32()
+	case typeInt64:
+		return d.int64()
+	case typeUInt64:
+		return d.uint64()
+	case typeFloat:
+		return d.float()
+	case typeDouble:
+		return d.double()
+	case typeString, typeXMLElement:
+		return d.string()
+	case typeDateTime:
+		return d.dateTime()
+	case typeGuid:
+		return d.take(16)
+	case typeByteString:
+		return d.byteString()
+	case typeNodeID:
+		return d.nodeID()
+	case typeExpandedNodeID:
+		return d.expandedNodeID()
+	case typeStatusCode:
+		return d.statusCode()
+	case typeQualifiedName:
+		return d.qualifiedName()
+	case typeLocalizedText:
+		return d.localizedText()
+	case typeExtensionObject:
+		return d.extensionObject()
+	case typeDataValue:
+		return d.dataValue()
+	case typeVariant:
+		return d.variant()
+	case typeDiagnosticInfo:
+		d.diagnosticInfo()
+	}
+	return nil
+}
+
+func (d *decoder) dataValue() DataValue {
+	mask := d.byte()
+	var v DataValue
+	if mask&0x01 != 0 {
+		v.Value, v.HasValue = d.variant(), true
+	}
+	if mask&0x02 != 0 {
+		v.Status = d.statusCode()
+	}
+	if mask&0x04 != 0 {
+		v.SourceTimestamp = d.dateTime()
+	}
+	if mask&0x10 != 0 {
+		d.uint16()
+	}
+	if mask&0x08 != 0 {
+		v.ServerTimestamp = d.dateTime()
+	}
+	if mask&0x20 != 0 {
+		d.uint16()
+	}
+	return v
+}
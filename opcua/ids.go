@@ -0,0 +1,206 @@
+package opcua
+
+import "fmt"
+
+// StatusCode OPC UA状态码，高两位为0时表示成功
+type StatusCode uint32
+
+// 使用到的状态码（Part 4, 7.39和Part 6, A.2）
+const (
+	StatusGood                         StatusCode = 0
+	StatusBadUnexpectedError           StatusCode = 0x80010000
+	StatusBadInternalError             StatusCode = 0x80020000
+	StatusBadDecodingError             StatusCode = 0x80070000
+	StatusBadTimeout                   StatusCode = 0x800A0000
+	StatusBadServiceUnsupported        StatusCode = 0x800B0000
+	StatusBadShutdown                  StatusCode = 0x800C0000
+	StatusBadNothingToDo               StatusCode = 0x800F0000
+	StatusBadTooManyOperations         StatusCode = 0x80100000
+	StatusBadUserAccessDenied          StatusCode = 0x801F0000
+	StatusBadIdentityTokenInvalid      StatusCode = 0x80200000
+	StatusBadSecureChannelIDInvalid    StatusCode = 0x80220000
+	StatusBadSessionIDInvalid          StatusCode = 0x80250000
+	StatusBadSessionNotActivated       StatusCode = 0x80270000
+	StatusBadSubscriptionIDInvalid     StatusCode = 0x80280000
+	StatusBadTimestampsToReturnInvalid StatusCode = 0x802B0000
+	StatusBadNodeIDUnknown             StatusCode = 0x80340000
+	StatusBadAttributeIDInvalid        StatusCode = 0x80350000
+	StatusBadIndexRangeInvalid         StatusCode = 0x80360000
+	StatusBadNotReadable               StatusCode = 0x803A0000
+	StatusBadNotWritable               StatusCode = 0x803B0000
+	StatusBadOutOfRange                StatusCode = 0x803C0000
+	StatusBadMonitoringModeInvalid     StatusCode = 0x80410000
+	StatusBadMonitoredItemIDInvalid    StatusCode = 0x80420000
+	StatusBadContinuationPointInvalid  StatusCode = 0x804A0000
+	StatusBadBrowseDirectionInvalid    StatusCode = 0x804D0000
+	StatusBadSecurityPolicyRejected    StatusCode = 0x80550000
+	StatusBadTooManySubscriptions      StatusCode = 0x80770000
+	StatusBadTooManyPublishRequests    StatusCode = 0x80780000
+	StatusBadNoSubscription            StatusCode = 0x80790000
+	StatusBadSequenceNumberUnknown     StatusCode = 0x807A0000
+	StatusBadMessageNotAvailable       StatusCode = 0x807B0000
+	StatusBadTCPMessageTypeInvalid     StatusCode = 0x807E0000
+	StatusBadTCPSecureChannelUnknown   StatusCode = 0x807F0000
+	StatusBadTCPMessageTooLarge        StatusCode = 0x80800000
+	StatusBadTypeMismatch              StatusCode = 0x80740000
+)
+
+func (s StatusCode) Error() string {
+	return fmt.Sprintf("opcua: 状态码0x%08X", uint32(s))
+}
+
+// 服务请求和应答的二进制编码节点号
+const (
+	idServiceFault                 = 397
+	idFindServersRequest           = 422
+	idFindServersResponse          = 425
+	idGetEndpointsRequest          = 428
+	idGetEndpointsResponse         = 431
+	idOpenSecureChannelRequest     = 446
+	idOpenSecureChannelResponse    = 449
+	idCloseSecureChannelRequest    = 452
+	idCreateSessionRequest         = 461
+	idCreateSessionResponse        = 464
+	idActivateSessionRequest       = 467
+	idActivateSessionResponse      = 470
+	idCloseSessionRequest          = 473
+	idCloseSessionResponse         = 476
+	idBrowseRequest                = 527
+	idBrowseResponse               = 530
+	idBrowseNextRequest            = 533
+	idBrowseNextResponse           = 536
+	idReadRequest                  = 631
+	idReadResponse                 = 634
+	idWriteRequest                 = 673
+	idWriteResponse                = 676
+	idCreateMonitoredItemsRequest  = 751
+	idCreateMonitoredItemsResponse = 754
+	idDeleteMonitoredItemsRequest  = 781
+	idDeleteMonitoredItemsResponse = 784
+	idCreateSubscriptionRequest    = 787
+	idCreateSubscriptionResponse   = 790
+	idModifySubscriptionRequest    = 793
+	idModifySubscriptionResponse   = 796
+	idSetPublishingModeRequest     = 799
+	idSetPublishingModeResponse    = 802
+	idDataChangeNotification       = 811
+	idPublishRequest               = 826
+	idPublishResponse              = 829
+	idRepublishRequest             = 832
+	idRepublishResponse            = 835
+	idDeleteSubscriptionsRequest   = 847
+	idDeleteSubscriptionsResponse  = 850
+	idAnonymousIdentityToken       = 321
+	idServerStatusDataTypeEncoding = 864
+)
+
+// 命名空间0中的节点
+const (
+	idBoolean              = 1
+	idInt32                = 6
+	idUInt32               = 7
+	idDouble               = 11
+	idString               = 12
+	idDateTime             = 13
+	idBaseDataType         = 24
+	idReferences           = 31
+	idNonHierarchical      = 32
+	idHierarchical         = 33
+	idHasChild             = 34
+	idOrganizes            = 35
+	idHasEventSource       = 36
+	idHasModellingRule     = 37
+	idHasEncoding          = 38
+	idHasDescription       = 39
+	idHasTypeDefinition    = 40
+	idGeneratesEvent       = 41
+	idAggregates           = 44
+	idHasSubtype           = 45
+	idHasProperty          = 46
+	idHasComponent         = 47
+	idHasNotifier          = 48
+	idBaseObjectType       = 58
+	idFolderType           = 61
+	idBaseDataVariableType = 63
+	idPropertyType         = 68
+	idRootFolder           = 84
+	idObjectsFolder        = 85
+	idTypesFolder          = 86
+	idViewsFolder          = 87
+	idServerState          = 852
+	idServerStatusDataType = 862
+	idServerType           = 2004
+	idServerStatusType     = 2138
+	idServer               = 2253
+	idServerArray          = 2254
+	idNamespaceArray       = 2255
+	idServerStatus         = 2256
+	idServerStartTime      = 2257
+	idServerCurrentTime    = 2258
+	idServerStatusState    = 2259
+)
+
+// 节点类别
+const (
+	nodeClassObject        uint32 = 1
+	nodeClassVariable      uint32 = 2
+	nodeClassObjectType    uint32 = 8
+	nodeClassVariableType  uint32 = 16
+	nodeClassReferenceType uint32 = 32
+	nodeClassDataType      uint32 = 64
+)
+
+// 属性编号
+const (
+	attrNodeID                  = 1
+	attrNodeClass               = 2
+	attrBrowseName              = 3
+	attrDisplayName             = 4
+	attrDescription             = 5
+	attrWriteMask               = 6
+	attrUserWriteMask           = 7
+	attrIsAbstract              = 8
+	attrSymmetric               = 9
+	attrEventNotifier           = 12
+	attrValue                   = 13
+	attrDataType                = 14
+	attrValueRank               = 15
+	attrArrayDimensions         = 16
+	attrAccessLevel             = 17
+	attrUserAccessLevel         = 18
+	attrMinimumSamplingInterval = 19
+	attrHistorizing             = 20
+)
+
+// referenceSupertypes 引用类型的父类型，用于按IncludeSubtypes过滤引用
+var referenceSupertypes = map[uint32]uint32{
+	idNonHierarchical:   idReferences,
+	idHierarchical:      idReferences,
+	idHasChild:          idHierarchical,
+	idOrganizes:         idHierarchical,
+	idHasEventSource:    idHierarchical,
+	idHasModellingRule:  idNonHierarchical,
+	idHasEncoding:       idNonHierarchical,
+	idHasDescription:    idNonHierarchical,
+	idHasTypeDefinition: idNonHierarchical,
+	idGeneratesEvent:    idNonHierarchical,
+	idAggregates:        idHasChild,
+	idHasSubtype:        idHasChild,
+	idHasProperty:       idAggregates,
+	idHasComponent:      idAggregates,
+	idHasNotifier:       idHasEventSource,
+}
+
+// isSubtype 判断引用类型t是否为base或其子类型
+func isSubtype(t, base uint32) bool {
+	for {
+		if t == base {
+			return true
+		}
+		parent, ok := referenceSupertypes[t]
+		if !ok {
+			return false
+		}
+		t = parent
+	}
+}
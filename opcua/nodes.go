@@ -0,0 +1,616 @@
+package opcua
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// 读取时返回的时间戳（TimestampsToReturn）
+const (
+	timestampsSource  = 0
+	timestampsServer  = 1
+	timestampsBoth    = 2
+	timestampsNeither = 3
+)
+
+// 浏览方向（BrowseDirection）
+const (
+	browseForward = 0
+	browseInverse = 1
+	browseBoth    = 2
+)
+
+// 变量的访问级别
+const (
+	accessRead  = 0x01
+	accessWrite = 0x02
+)
+
+// minimumSamplingInterval 变量的最小采样周期（毫秒），与订阅的最小发布周期相同
+const minimumSamplingInterval = 100
+
+// node 地址空间中的一个节点。命名空间0中的节点在创建服务端时生成，
+// BACnet对象和属性的节点在访问时按标识符生成
+type node struct {
+	id          NodeID
+	class       uint32
+	browseName  QualifiedName
+	displayName string
+	description string
+	typeDef     uint32 // 类型定义，类型节点为0
+	dataType    uint32 // 静态变量的数据类型，为0时按值的类型
+	abstract    bool
+	references  []reference // 静态节点的引用
+
+	value  func() Variant // 静态变量的值
+	object model.Object   // BACnet对象或属性所属的对象
+	prop   model.PropertyIdentifier
+	isProp bool
+}
+
+// reference 节点的一个引用
+type reference struct {
+	typeID  uint32
+	forward bool
+	target  NodeID
+}
+
+// typeDefinition 返回节点的类型定义，类型节点返回空标识符
+func (n *node) typeDefinition() NodeID {
+	if n.typeDef == 0 {
+		return NodeID{}
+	}
+	return numeric(n.typeDef)
+}
+
+// builtinTypeNames 内置数据类型节点的名称，节点号与内置类型编号相同
+var builtinTypeNames = map[byte]string{
+	typeBoolean:    "Boolean",
+	typeSByte:      "SByte",
+	typeByte:       "Byte",
+	typeInt16:      "Int16",
+	typeUInt16:     "UInt16",
+	typeInt32:      "Int32",
+	typeUInt32:     "UInt32",
+	typeInt64:      "Int64",
+	typeUInt64:     "UInt64",
+	typeFloat:      "Float",
+	typeDouble:     "Double",
+	typeString:     "String",
+	typeDateTime:   "DateTime",
+	typeByteString: "ByteString",
+	typeNodeID:     "NodeId",
+	typeStatusCode: "StatusCode",
+	typeVariant:    "BaseDataType",
+}
+
+// referenceTypeNames 引用类型节点的名称
+var referenceTypeNames = map[uint32]string{
+	idReferences:        "References",
+	idNonHierarchical:   "NonHierarchicalReferences",
+	idHierarchical:      "HierarchicalReferences",
+	idHasChild:          "HasChild",
+	idOrganizes:         "Organizes",
+	idHasEventSource:    "HasEventSource",
+	idHasModellingRule:  "HasModellingRule",
+	idHasEncoding:       "HasEncoding",
+	idHasDescription:    "HasDescription",
+	idHasTypeDefinition: "HasTypeDefinition",
+	idGeneratesEvent:    "GeneratesEvent",
+	idAggregates:        "Aggregates",
+	idHasSubtype:        "HasSubtype",
+	idHasProperty:       "HasProperty",
+	idHasComponent:      "HasComponent",
+	idHasNotifier:       "HasNotifier",
+}
+
+// staticNodes 生成命名空间0中的节点：根目录、服务器对象和用到的类型
+func (s *Server) staticNodes() map[uint32]*node {
+	nodes := make(map[uint32]*node)
+	add := func(id, class uint32, name string, typeDef uint32) *node {
+		n := &node{id: numeric(id), class: class, browseName: QualifiedName{Name: name}, displayName: name, typeDef: typeDef}
+		nodes[id] = n
+		return n
+	}
+	link := func(source, typeID uint32, target NodeID) {
+		nodes[source].references = append(nodes[source].references, reference{typeID: typeID, forward: true, target: target})
+		if target.Namespace == 0 && target.Name == "" {
+			if n := nodes[target.ID]; n != nil {
+				n.references = append(n.references, reference{typeID: typeID, target: numeric(source)})
+			}
+		}
+	}
+
+	// 类型
+	for t, name := range builtinTypeNames {
+		add(uint32(t), nodeClassDataType, name, 0)
+	}
+	nodes[idBaseDataType].abstract = true
+	add(idServerState, nodeClassDataType, "ServerState", 0)
+	add(idServerStatusDataType, nodeClassDataType, "ServerStatusDataType", 0)
+	for id, name := range referenceTypeNames {
+		add(id, nodeClassReferenceType, name, 0)
+	}
+	for _, id := range []uint32{idReferences, idNonHierarchical, idHierarchical, idHasChild, idAggregates} {
+		nodes[id].abstract = true
+	}
+	subtypes := make([]uint32, 0, len(referenceSupertypes))
+	for id := range referenceSupertypes {
+		subtypes = append(subtypes, id)
+	}
+	sort.Slice(subtypes, func(i, j int) bool { return subtypes[i] < subtypes[j] })
+	for _, id := range subtypes {
+		link(referenceSupertypes[id], idHasSubtype, numeric(id))
+	}
+	add(idBaseObjectType, nodeClassObjectType, "BaseObjectType", 0)
+	add(idFolderType, nodeClassObjectType, "FolderType", 0)
+	add(idServerType, nodeClassObjectType, "ServerType", 0)
+	add(idBaseDataVariableType, nodeClassVariableType, "BaseDataVariableType", 0)
+	add(idPropertyType, nodeClassVariableType, "PropertyType", 0)
+	add(idServerStatusType, nodeClassVariableType, "ServerStatusType", 0)
+	link(idBaseObjectType, idHasSubtype, numeric(idFolderType))
+	link(idBaseObjectType, idHasSubtype, numeric(idServerType))
+	link(idBaseDataVariableType, idHasSubtype, numeric(idServerStatusType))
+
+	// 目录
+	add(idRootFolder, nodeClassObject, "Root", idFolderType)
+	add(idObjectsFolder, nodeClassObject, "Objects", idFolderType)
+	add(idTypesFolder, nodeClassObject, "Types", idFolderType)
+	add(idViewsFolder, nodeClassObject, "Views", idFolderType)
+	link(idRootFolder, idOrganizes, numeric(idObjectsFolder))
+	link(idRootFolder, idOrganizes, numeric(idTypesFolder))
+	link(idRootFolder, idOrganizes, numeric(idViewsFolder))
+	link(idObjectsFolder, idOrganizes, s.objectNodeID(s.device.GetObjectIdentifier()))
+
+	// 服务器对象
+	add(idServer, nodeClassObject, "Server", idServerType)
+	link(idObjectsFolder, idOrganizes, numeric(idServer))
+	serverArray := add(idServerArray, nodeClassVariable, "ServerArray", idPropertyType)
+	serverArray.value = func() Variant {
+		return Variant{Type: typeString, Array: true, Value: []interface{}{applicationURI}}
+	}
+	namespaces := add(idNamespaceArray, nodeClassVariable, "NamespaceArray", idPropertyType)
+	namespaces.value = func() Variant {
+		return Variant{Type: typeString, Array: true, Value: []interface{}{"http://opcfoundation.org/UA/", applicationURI}}
+	}
+	status := add(idServerStatus, nodeClassVariable, "ServerStatus", idServerStatusType)
+	status.dataType = idServerStatusDataType
+	status.value = func() Variant {
+		return Variant{Type: typeExtensionObject, Value: s.serverStatus()}
+	}
+	startTime := add(idServerStartTime, nodeClassVariable, "StartTime", idBaseDataVariableType)
+	startTime.value = func() Variant { return Variant{Type: typeDateTime, Value: s.start} }
+	currentTime := add(idServerCurrentTime, nodeClassVariable, "CurrentTime", idBaseDataVariableType)
+	currentTime.value = func() Variant { return Variant{Type: typeDateTime, Value: s.now()} }
+	state := add(idServerStatusState, nodeClassVariable, "State", idBaseDataVariableType)
+	state.dataType = idServerState
+	state.value = func() Variant { return Variant{Type: typeInt32, Value: int32(0)} } // Running
+	link(idServer, idHasProperty, numeric(idServerArray))
+	link(idServer, idHasProperty, numeric(idNamespaceArray))
+	link(idServer, idHasComponent, numeric(idServerStatus))
+	link(idServerStatus, idHasComponent, numeric(idServerStartTime))
+	link(idServerStatus, idHasComponent, numeric(idServerCurrentTime))
+	link(idServerStatus, idHasComponent, numeric(idServerStatusState))
+	return nodes
+}
+
+// serverStatus 编码ServerStatusDataType
+func (s *Server) serverStatus() ExtensionObject {
+	e := &encoder{}
+	e.dateTime(s.start)
+	e.dateTime(s.now())
+	e.int32(0) // Running
+	e.string(productURI)
+	e.string("iotzf")
+	e.string("BACnet Server")
+	e.string("1.0")
+	e.string("")
+	e.dateTime(s.start)
+	e.uint32(0) // SecondsTillShutdown
+	e.localizedText("")
+	return ExtensionObject{TypeID: numeric(idServerStatusDataTypeEncoding), Body: e.buf}
+}
+
+// objectNodeID 返回BACnet对象的节点标识符，如ns=1;s=analog-input:1
+func (s *Server) objectNodeID(oid model.ObjectIdentifier) NodeID {
+	return NodeID{Namespace: namespaceIndex, Name: fmt.Sprintf("%s:%d", oid.Type, oid.Instance)}
+}
+
+// propertyNodeID 返回BACnet属性的节点标识符，如ns=1;s=analog-input:1/present-value
+func (s *Server) propertyNodeID(oid model.ObjectIdentifier, prop model.PropertyIdentifier) NodeID {
+	id := s.objectNodeID(oid)
+	id.Name += "/" + prop.String()
+	return id
+}
+
+// findObject 按标识符查找对象，包括设备自身
+func (s *Server) findObject(oid model.ObjectIdentifier) model.Object {
+	if oid == s.device.GetObjectIdentifier() {
+		return s.device
+	}
+	return s.device.FindObject(oid)
+}
+
+// lookup 查找节点，节点不存在时返回nil
+func (s *Server) lookup(id NodeID) *node {
+	if id.Namespace == 0 {
+		if id.Name != "" {
+			return nil
+		}
+		return s.static[id.ID]
+	}
+	if id.Namespace != namespaceIndex || id.Name == "" {
+		return nil
+	}
+	objectText, propText, isProp := strings.Cut(id.Name, "/")
+	typeText, instanceText, ok := strings.Cut(objectText, ":")
+	if !ok {
+		return nil
+	}
+	objType, err := model.ParseObjectType(typeText)
+	if err != nil {
+		return nil
+	}
+	instance, err := strconv.ParseUint(instanceText, 10, 22)
+	if err != nil {
+		return nil
+	}
+	oid := model.ObjectIdentifier{Type: objType, Instance: uint32(instance)}
+	obj := s.findObject(oid)
+	if obj == nil {
+		return nil
+	}
+	if !isProp {
+		return s.objectNode(obj)
+	}
+	prop, err := model.ParsePropertyIdentifier(propText)
+	if err != nil {
+		return nil
+	}
+	for _, p := range model.ExpandPropertyReference(obj, model.PropertyIdentifierAll) {
+		if p == prop {
+			return s.propertyNode(obj, prop)
+		}
+	}
+	return nil
+}
+
+func (s *Server) objectNode(obj model.Object) *node {
+	oid := obj.GetObjectIdentifier()
+	n := &node{
+		id:          s.objectNodeID(oid),
+		class:       nodeClassObject,
+		browseName:  QualifiedName{Namespace: namespaceIndex, Name: obj.GetObjectName()},
+		displayName: obj.GetObjectName(),
+		typeDef:     idBaseObjectType,
+		object:      obj,
+	}
+	if description, _ := obj.ReadProperty(model.PropertyIdentifierDescription); description != nil {
+		n.description, _ = description.(string)
+	}
+	return n
+}
+
+func (s *Server) propertyNode(obj model.Object, prop model.PropertyIdentifier) *node {
+	return &node{
+		id:          s.propertyNodeID(obj.GetObjectIdentifier(), prop),
+		class:       nodeClassVariable,
+		browseName:  QualifiedName{Namespace: namespaceIndex, Name: prop.String()},
+		displayName: prop.String(),
+		typeDef:     idBaseDataVariableType,
+		object:      obj,
+		prop:        prop,
+		isProp:      true,
+	}
+}
+
+// nodeReferences 返回节点的全部引用
+func (s *Server) nodeReferences(n *node) []reference {
+	if n.object == nil {
+		return n.references
+	}
+	oid := n.object.GetObjectIdentifier()
+	if n.isProp {
+		return []reference{
+			{typeID: idHasComponent, target: s.objectNodeID(oid)},
+			{typeID: idHasTypeDefinition, forward: true, target: numeric(idBaseDataVariableType)},
+		}
+	}
+	var refs []reference
+	if n.object == model.Object(s.device) {
+		refs = append(refs, reference{typeID: idOrganizes, target: numeric(idObjectsFolder)})
+		for _, obj := range s.device.Objects() {
+			refs = append(refs, reference{typeID: idOrganizes, forward: true, target: s.objectNodeID(obj.GetObjectIdentifier())})
+		}
+	} else {
+		refs = append(refs, reference{typeID: idOrganizes, target: s.objectNodeID(s.device.GetObjectIdentifier())})
+	}
+	refs = append(refs, reference{typeID: idHasTypeDefinition, forward: true, target: numeric(idBaseObjectType)})
+	for _, prop := range model.ExpandPropertyReference(n.object, model.PropertyIdentifierAll) {
+		refs = append(refs, reference{typeID: idHasComponent, forward: true, target: s.propertyNodeID(oid, prop)})
+	}
+	return refs
+}
+
+// browse 返回节点上满足条件的引用
+func (s *Server) browse(id NodeID, direction int32, refType NodeID, includeSubtypes bool, classMask uint32) ([]referenceDescription, StatusCode) {
+	if direction < browseForward || direction > browseBoth {
+		return nil, StatusBadBrowseDirectionInvalid
+	}
+	s.device.Lock()
+	defer s.device.Unlock()
+	n := s.lookup(id)
+	if n == nil {
+		return nil, StatusBadNodeIDUnknown
+	}
+	var refs []referenceDescription
+	for _, ref := range s.nodeReferences(n) {
+		if (direction == browseForward && !ref.forward) || (direction == browseInverse && ref.forward) {
+			continue
+		}
+		if refType != (NodeID{}) {
+			if refType.Namespace != 0 || refType.Name != "" {
+				continue
+			}
+			if ref.typeID != refType.ID && !(includeSubtypes && isSubtype(ref.typeID, refType.ID)) {
+				continue
+			}
+		}
+		target := s.lookup(ref.target)
+		if target == nil || (classMask != 0 && classMask&target.class == 0) {
+			continue
+		}
+		refs = append(refs, referenceDescription{typeID: ref.typeID, forward: ref.forward, target: target})
+	}
+	return refs, StatusGood
+}
+
+// read 读取节点属性
+func (s *Server) read(item readValueID, timestamps int32) DataValue {
+	s.device.Lock()
+	defer s.device.Unlock()
+	now := s.now()
+	result := func(v Variant) DataValue {
+		return DataValue{Value: v, HasValue: true}
+	}
+	n := s.lookup(item.node)
+	if n == nil {
+		return DataValue{Status: StatusBadNodeIDUnknown}
+	}
+	if item.indexRange != "" {
+		return DataValue{Status: StatusBadIndexRangeInvalid}
+	}
+	isType := n.class == nodeClassObjectType || n.class == nodeClassVariableType ||
+		n.class == nodeClassReferenceType || n.class == nodeClassDataType
+	isVariable := n.class == nodeClassVariable
+
+	switch item.attribute {
+	case attrNodeID:
+		return result(Variant{Type: typeNodeID, Value: n.id})
+	case attrNodeClass:
+		return result(Variant{Type: typeInt32, Value: int32(n.class)})
+	case attrBrowseName:
+		return result(Variant{Type: typeQualifiedName, Value: n.browseName})
+	case attrDisplayName:
+		return result(Variant{Type: typeLocalizedText, Value: n.displayName})
+	case attrDescription:
+		return result(Variant{Type: typeLocalizedText, Value: n.description})
+	case attrWriteMask, attrUserWriteMask:
+		return result(Variant{Type: typeUInt32, Value: uint32(0)})
+	case attrIsAbstract:
+		if isType {
+			return result(Variant{Type: typeBoolean, Value: n.abstract})
+		}
+	case attrSymmetric:
+		if n.class == nodeClassReferenceType {
+			return result(Variant{Type: typeBoolean, Value: false})
+		}
+	case attrEventNotifier:
+		if n.class == nodeClassObject {
+			return result(Variant{Type: typeByte, Value: byte(0)})
+		}
+	case attrValue:
+		if isVariable {
+			value, status := s.value(n)
+			dv := DataValue{Value: value, HasValue: status == StatusGood, Status: status}
+			if timestamps == timestampsSource || timestamps == timestampsBoth {
+				dv.SourceTimestamp = now
+			}
+			if timestamps == timestampsServer || timestamps == timestampsBoth {
+				dv.ServerTimestamp = now
+			}
+			return dv
+		}
+	case attrDataType:
+		if isVariable {
+			if n.dataType != 0 {
+				return result(Variant{Type: typeNodeID, Value: numeric(n.dataType)})
+			}
+			value, _ := s.value(n)
+			dataType := uint32(value.Type)
+			if value.Type == typeNull {
+				dataType = idBaseDataType
+			}
+			return result(Variant{Type: typeNodeID, Value: numeric(dataType)})
+		}
+	case attrValueRank:
+		if isVariable {
+			value, _ := s.value(n)
+			rank := int32(-1)
+			switch {
+			case value.Array:
+				rank = 1
+			case value.Type == typeNull:
+				rank = -2
+			}
+			return result(Variant{Type: typeInt32, Value: rank})
+		}
+	case attrArrayDimensions:
+		if isVariable {
+			value, _ := s.value(n)
+			if !value.Array {
+				return result(Variant{})
+			}
+			items, _ := value.Value.([]interface{})
+			return result(Variant{Type: typeUInt32, Array: true, Value: []interface{}{uint32(len(items))}})
+		}
+	case attrAccessLevel, attrUserAccessLevel:
+		if isVariable {
+			level := byte(accessRead)
+			if s.writable(n) {
+				level |= accessWrite
+			}
+			return result(Variant{Type: typeByte, Value: level})
+		}
+	case attrMinimumSamplingInterval:
+		if isVariable {
+			return result(Variant{Type: typeDouble, Value: float64(minimumSamplingInterval)})
+		}
+	case attrHistorizing:
+		if isVariable {
+			return result(Variant{Type: typeBoolean, Value: false})
+		}
+	}
+	return DataValue{Status: StatusBadAttributeIDInvalid}
+}
+
+// value 读取变量的值
+func (s *Server) value(n *node) (Variant, StatusCode) {
+	if !n.isProp {
+		return n.value(), StatusGood
+	}
+	value, err := model.ReadPropertyValue(n.object, n.prop)
+	if err != nil {
+		return Variant{}, StatusBadNotReadable
+	}
+	return toVariant(value), StatusGood
+}
+
+// writable 判断变量是否可以写入：属性元数据标记为可写的属性
+func (s *Server) writable(n *node) bool {
+	if !n.isProp {
+		return false
+	}
+	meta, ok := model.LookupPropertyMetadata(n.object.GetObjectIdentifier().Type, n.prop)
+	return ok && meta.Writable
+}
+
+// write 写入变量的值，空值释放命令
+func (s *Server) write(id NodeID, attribute uint32, value DataValue) StatusCode {
+	s.device.Lock()
+	defer s.device.Unlock()
+	n := s.lookup(id)
+	if n == nil {
+		return StatusBadNodeIDUnknown
+	}
+	if attribute != attrValue {
+		if attribute < attrNodeID || attribute > attrHistorizing {
+			return StatusBadAttributeIDInvalid
+		}
+		return StatusBadNotWritable
+	}
+	if !s.writable(n) {
+		return StatusBadNotWritable
+	}
+	var v interface{}
+	if value.HasValue && value.Value.Type != typeNull {
+		coerced, err := model.CoerceValue(n.object, n.prop, fromVariant(value.Value))
+		if err != nil {
+			return StatusBadTypeMismatch
+		}
+		v = coerced
+	}
+	if s.config.Write != nil {
+		return writeStatus(s.config.Write(n.object, n.prop, v))
+	}
+	if err := model.ValidateWrite(n.object, n.prop, v); err != nil {
+		return writeStatus(err)
+	}
+	return writeStatus(model.WriteWithPriority(n.object, n.prop, v, 16))
+}
+
+// toVariant 将BACnet属性值转换为Variant：数值按宽度映射，对象标识符映射为文本，
+// 切片映射为数组，其他类型映射为字符串
+func toVariant(value interface{}) Variant {
+	switch v := value.(type) {
+	case nil:
+		return Variant{}
+	case model.ObjectIdentifier:
+		return Variant{Type: typeString, Value: fmt.Sprintf("%s:%d", v.Type, v.Instance)}
+	case time.Time:
+		return Variant{Type: typeDateTime, Value: v}
+	case []byte:
+		return Variant{Type: typeByteString, Value: v}
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return Variant{Type: typeBoolean, Value: rv.Bool()}
+	case reflect.Float32:
+		return Variant{Type: typeFloat, Value: float32(rv.Float())}
+	case reflect.Float64:
+		return Variant{Type: typeDouble, Value: rv.Float()}
+	case reflect.Uint64:
+		return Variant{Type: typeUInt64, Value: rv.Uint()}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return Variant{Type: typeUInt32, Value: uint32(rv.Uint())}
+	case reflect.Int64:
+		return Variant{Type: typeInt64, Value: rv.Int()}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return Variant{Type: typeInt32, Value: int32(rv.Int())}
+	case reflect.String:
+		return Variant{Type: typeString, Value: rv.String()}
+	case reflect.Slice, reflect.Array:
+		element := toVariant(reflect.Zero(rv.Type().Elem()).Interface())
+		if element.Array || element.Type == typeNull || rv.Type().Elem().Kind() == reflect.Interface {
+			break
+		}
+		items := make([]interface{}, rv.Len())
+		for i := range items {
+			items[i] = toVariant(rv.Index(i).Interface()).Value
+		}
+		return Variant{Type: element.Type, Array: true, Value: items}
+	}
+	return Variant{Type: typeString, Value: fmt.Sprint(value)}
+}
+
+// fromVariant 将Variant转换为CoerceValue接受的形式：数值为float64，数组为[]interface{}
+func fromVariant(v Variant) interface{} {
+	if v.Array {
+		items, _ := v.Value.([]interface{})
+		values := make([]interface{}, len(items))
+		for i, item := range items {
+			values[i] = fromVariant(Variant{Type: v.Type, Value: item})
+		}
+		return values
+	}
+	switch x := v.Value.(type) {
+	case int8:
+		return float64(x)
+	case byte:
+		return float64(x)
+	case int16:
+		return float64(x)
+	case uint16:
+		return float64(x)
+	case int32:
+		return float64(x)
+	case uint32:
+		return float64(x)
+	case int64:
+		return float64(x)
+	case uint64:
+		return float64(x)
+	case float32:
+		return float64(x)
+	case Variant:
+		return fromVariant(x)
+	}
+	return v.Value
+}
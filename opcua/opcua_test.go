@@ -0,0 +1,508 @@
+package opcua
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// client 测试用的最小OPC UA客户端
+type client struct {
+	t        *testing.T
+	nc       net.Conn
+	channel  uint32
+	token    uint32
+	sequence uint32
+	request  uint32
+	auth     NodeID
+}
+
+func dial(t *testing.T, addr string) *client {
+	nc, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nc.SetDeadline(time.Now().Add(10 * time.Second))
+	c := &client{t: t, nc: nc}
+
+	e := &encoder{}
+	e.raw([]byte("HELF\x00\x00\x00\x00"))
+	e.uint32(0)
+	e.uint32(65535)
+	e.uint32(65535)
+	e.uint32(0)
+	e.uint32(0)
+	e.string("opc.tcp://" + addr)
+	c.write(e)
+	if msgType, _ := c.read(); msgType != "ACKF" {
+		t.Fatalf("Hello answered with %s", msgType)
+	}
+
+	e = &encoder{}
+	e.raw([]byte("OPNF\x00\x00\x00\x00"))
+	e.uint32(0)
+	e.string(securityPolicyNone)
+	e.byteString(nil)
+	e.byteString(nil)
+	e.uint32(1)
+	e.uint32(1)
+	e.nodeID(numeric(idOpenSecureChannelRequest))
+	c.requestHeader(e)
+	e.uint32(0)
+	e.int32(0) // Issue
+	e.int32(1) // None
+	e.byteString(nil)
+	e.uint32(60000)
+	c.write(e)
+	msgType, d := c.read()
+	if msgType != "OPNF" {
+		t.Fatalf("OpenSecureChannel answered with %s", msgType)
+	}
+	d.uint32()
+	d.string()
+	d.byteString()
+	d.byteString()
+	d.uint32()
+	d.uint32()
+	if typeID := d.nodeID(); typeID != numeric(idOpenSecureChannelResponse) {
+		t.Fatalf("OpenSecureChannel response type %v", typeID)
+	}
+	checkResponseHeader(t, d)
+	d.uint32()
+	c.channel = d.uint32()
+	c.token = d.uint32()
+	return c
+}
+
+func (c *client) write(e *encoder) {
+	binary.LittleEndian.PutUint32(e.buf[4:], uint32(len(e.buf)))
+	if _, err := c.nc.Write(e.buf); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// read 读取一个消息块，返回消息类型和块类型以及头之后的内容
+func (c *client) read() (string, *decoder) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(c.nc, header); err != nil {
+		c.t.Fatal(err)
+	}
+	body := make([]byte, binary.LittleEndian.Uint32(header[4:])-8)
+	if _, err := io.ReadFull(c.nc, body); err != nil {
+		c.t.Fatal(err)
+	}
+	return string(header[:4]), &decoder{buf: body}
+}
+
+func (c *client) requestHeader(e *encoder) {
+	c.request++
+	e.nodeID(c.auth)
+	e.dateTime(time.Now())
+	e.uint32(c.request)
+	e.uint32(0)
+	e.nullString()
+	e.uint32(0)
+	e.extensionObject(ExtensionObject{})
+}
+
+// send 发送请求，body编码请求头之后的字段
+func (c *client) send(typeID uint32, body func(e *encoder)) {
+	payload := &encoder{}
+	payload.nodeID(numeric(typeID))
+	c.requestHeader(payload)
+	body(payload)
+	e := &encoder{}
+	e.raw([]byte("MSGF\x00\x00\x00\x00"))
+	e.uint32(c.channel)
+	e.uint32(c.token)
+	c.sequence++
+	e.uint32(c.sequence)
+	e.uint32(c.request)
+	e.raw(payload.buf)
+	c.write(e)
+}
+
+// receive 读取应答，返回应答类型和服务结果，d位于应答头之后
+func (c *client) receive() (uint32, StatusCode, *decoder) {
+	var body []byte
+	for {
+		msgType, d := c.read()
+		if msgType[:3] != "MSG" {
+			c.t.Fatalf("unexpected %s message", msgType)
+		}
+		d.take(16)
+		body = append(body, d.buf...)
+		if msgType[3] == 'F' {
+			break
+		}
+	}
+	d := &decoder{buf: body}
+	typeID := d.nodeID()
+	return typeID.ID, checkResponseHeader(c.t, d), d
+}
+
+func (c *client) call(typeID uint32, body func(e *encoder)) (StatusCode, *decoder) {
+	c.send(typeID, body)
+	got, status, d := c.receive()
+	if got != typeID+3 && got != idServiceFault {
+		c.t.Fatalf("request %d answered with %d", typeID, got)
+	}
+	return status, d
+}
+
+func checkResponseHeader(t *testing.T, d *decoder) StatusCode {
+	d.dateTime()
+	d.uint32()
+	status := d.statusCode()
+	d.diagnosticInfo()
+	d.stringArray()
+	d.extensionObject()
+	if d.err != nil {
+		t.Fatalf("response header: %v", d.err)
+	}
+	return status
+}
+
+func readRequest(nodes ...NodeID) func(e *encoder) {
+	return func(e *encoder) {
+		e.double(0)
+		e.int32(timestampsBoth)
+		e.int32(int32(len(nodes)))
+		for _, node := range nodes {
+			e.nodeID(node)
+			e.uint32(attrValue)
+			e.nullString()
+			e.qualifiedName(QualifiedName{})
+		}
+	}
+}
+
+func readValues(t *testing.T, d *decoder) []DataValue {
+	var values []DataValue
+	d.array(func() { values = append(values, d.dataValue()) })
+	if d.err != nil {
+		t.Fatal(d.err)
+	}
+	return values
+}
+
+// browseNames 浏览节点的正向层次引用，返回目标节点的标识符
+func browseNames(t *testing.T, c *client, node NodeID) map[string]uint32 {
+	status, d := c.call(idBrowseRequest, func(e *encoder) {
+		e.nodeID(NodeID{})
+		e.dateTime(time.Time{})
+		e.uint32(0)
+		e.uint32(0)
+		e.int32(1)
+		e.nodeID(node)
+		e.int32(browseForward)
+		e.nodeID(numeric(idHierarchical))
+		e.boolean(true)
+		e.uint32(0)
+		e.uint32(0x3F)
+	})
+	if status != StatusGood {
+		t.Fatalf("Browse %v: %v", node, status)
+	}
+	refs := map[string]uint32{}
+	d.array(func() {
+		if status := d.statusCode(); status != StatusGood {
+			t.Errorf("Browse %v result: %v", node, status)
+		}
+		d.byteString()
+		d.array(func() {
+			refType := d.nodeID()
+			d.boolean()
+			target := d.expandedNodeID()
+			d.qualifiedName()
+			d.localizedText()
+			d.int32()
+			d.expandedNodeID()
+			refs[target.String()] = refType.ID
+		})
+	})
+	if d.err != nil {
+		t.Fatal(d.err)
+	}
+	return refs
+}
+
+func TestServer(t *testing.T) {
+	clock := model.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	device := model.NewDevice(1001, "Plant", "")
+	ai := model.NewAnalogInput(1, "Outdoor Temp", model.UnitsDegreesCelsius)
+	ai.UpdatePresentValue(float32(21.5))
+	av := model.NewAnalogValue(1, "Setpoint", model.UnitsDegreesCelsius)
+	device.AddObject(ai)
+	device.AddObject(av)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(device, Config{Clock: clock})
+	go server.Serve(listener)
+	defer server.Close()
+
+	c := dial(t, listener.Addr().String())
+	status, d := c.call(idGetEndpointsRequest, func(e *encoder) {
+		e.string("opc.tcp://plant:4840")
+		e.stringArray(nil)
+		e.stringArray(nil)
+	})
+	if status != StatusGood || d.int32() != 1 || d.string() != "opc.tcp://plant:4840" {
+		t.Fatalf("GetEndpoints = %v", status)
+	}
+
+	status, d = c.call(idCreateSessionRequest, func(e *encoder) {
+		e.string("urn:test")
+		e.nullString()
+		e.localizedText("test")
+		e.int32(1)
+		e.nullString()
+		e.nullString()
+		e.stringArray(nil)
+		e.nullString()
+		e.string("opc.tcp://" + listener.Addr().String())
+		e.string("test session")
+		e.byteString(nil)
+		e.byteString(nil)
+		e.double(60000)
+		e.uint32(0)
+	})
+	if status != StatusGood {
+		t.Fatalf("CreateSession = %v", status)
+	}
+	d.nodeID()
+	c.auth = d.nodeID()
+
+	pv := NodeID{Namespace: namespaceIndex, Name: "analog-input:1/present-value"}
+	if status, _ := c.call(idReadRequest, readRequest(pv)); status != StatusBadSessionNotActivated {
+		t.Errorf("Read before ActivateSession = %v", status)
+	}
+	anonymous := &encoder{}
+	anonymous.string("anonymous")
+	if status, _ := c.call(idActivateSessionRequest, func(e *encoder) {
+		e.nullString()
+		e.byteString(nil)
+		e.int32(0)
+		e.stringArray(nil)
+		e.extensionObject(ExtensionObject{TypeID: numeric(idAnonymousIdentityToken), Body: anonymous.buf})
+		e.nullString()
+		e.byteString(nil)
+	}); status != StatusGood {
+		t.Fatalf("ActivateSession = %v", status)
+	}
+
+	// 对象树
+	if refs := browseNames(t, c, numeric(idObjectsFolder)); refs["ns=1;s=device:1001"] != idOrganizes || refs["ns=0;i=2253"] != idOrganizes {
+		t.Errorf("Objects folder references = %v", refs)
+	}
+	refs := browseNames(t, c, NodeID{Namespace: namespaceIndex, Name: "device:1001"})
+	if refs["ns=1;s=analog-input:1"] != idOrganizes || refs["ns=1;s=analog-value:1"] != idOrganizes || refs["ns=1;s=device:1001/object-name"] != idHasComponent {
+		t.Errorf("device references = %v", refs)
+	}
+
+	// 读取和写入
+	unknown := NodeID{Namespace: namespaceIndex, Name: "analog-input:9/present-value"}
+	status, d = c.call(idReadRequest, readRequest(pv, unknown, numeric(idServerStatusState)))
+	values := readValues(t, d)
+	if status != StatusGood || len(values) != 3 {
+		t.Fatalf("Read = %v, %d values", status, len(values))
+	}
+	if v := values[0].Value; v.Type != typeFloat || v.Value != float32(21.5) || values[0].SourceTimestamp != clock.Now() {
+		t.Errorf("present-value = %+v", values[0])
+	}
+	if values[1].Status != StatusBadNodeIDUnknown {
+		t.Errorf("unknown node status = %v", values[1].Status)
+	}
+	if values[2].Value.Value != int32(0) {
+		t.Errorf("server state = %+v", values[2])
+	}
+
+	setpoint := NodeID{Namespace: namespaceIndex, Name: "analog-value:1/present-value"}
+	write := func(node NodeID, value Variant) StatusCode {
+		status, d := c.call(idWriteRequest, func(e *encoder) {
+			e.int32(1)
+			e.nodeID(node)
+			e.uint32(attrValue)
+			e.nullString()
+			e.dataValue(DataValue{Value: value, HasValue: true})
+		})
+		results := make([]StatusCode, 0, 1)
+		d.array(func() { results = append(results, d.statusCode()) })
+		if status != StatusGood || len(results) != 1 {
+			t.Fatalf("Write = %v, %v", status, results)
+		}
+		return results[0]
+	}
+	if status := write(setpoint, Variant{Type: typeDouble, Value: 42.0}); status != StatusGood {
+		t.Errorf("write setpoint = %v", status)
+	}
+	if value, _ := av.ReadProperty(model.PropertyIdentifierPresentValue); value != float32(42) {
+		t.Errorf("setpoint = %v", value)
+	}
+	if status := write(NodeID{Namespace: namespaceIndex, Name: "analog-value:1/object-type"}, Variant{Type: typeUInt32, Value: uint32(1)}); status != StatusBadNotWritable {
+		t.Errorf("write object-type = %v", status)
+	}
+	if status := write(setpoint, Variant{Type: typeString, Value: "warm"}); status != StatusBadTypeMismatch {
+		t.Errorf("write string = %v", status)
+	}
+
+	// 订阅
+	status, d = c.call(idCreateSubscriptionRequest, func(e *encoder) {
+		e.double(1000)
+		e.uint32(30)
+		e.uint32(10)
+		e.uint32(0)
+		e.boolean(true)
+		e.byte(0)
+	})
+	subscription := d.uint32()
+	if interval := d.double(); status != StatusGood || interval != 1000 {
+		t.Fatalf("CreateSubscription = %v, interval %v", status, interval)
+	}
+	status, d = c.call(idCreateMonitoredItemsRequest, func(e *encoder) {
+		e.uint32(subscription)
+		e.int32(timestampsSource)
+		e.int32(1)
+		e.nodeID(setpoint)
+		e.uint32(attrValue)
+		e.nullString()
+		e.qualifiedName(QualifiedName{})
+		e.int32(monitoringReporting)
+		e.uint32(7)
+		e.double(1000)
+		e.extensionObject(ExtensionObject{})
+		e.uint32(1)
+		e.boolean(true)
+	})
+	if d.int32() != 1 || d.statusCode() != StatusGood || status != StatusGood {
+		t.Fatalf("CreateMonitoredItems = %v", status)
+	}
+
+	// publish 发送发布请求并等待下一个发布周期的通知
+	publish := func(acks ...uint32) (uint32, []DataValue, []StatusCode) {
+		c.send(idPublishRequest, func(e *encoder) {
+			e.int32(int32(len(acks)))
+			for _, ack := range acks {
+				e.uint32(subscription)
+				e.uint32(ack)
+			}
+		})
+		c.call(idReadRequest, readRequest(pv)) // 确认发布请求已排队
+		clock.Advance(time.Second)
+		typeID, status, d := c.receive()
+		if typeID != idPublishResponse || status != StatusGood {
+			t.Fatalf("Publish answered with %d, %v", typeID, status)
+		}
+		if id := d.uint32(); id != subscription {
+			t.Errorf("subscription id = %d", id)
+		}
+		d.uint32Array()
+		d.boolean()
+		sequence := d.uint32()
+		d.dateTime()
+		var values []DataValue
+		d.array(func() {
+			x := d.extensionObject()
+			if x.TypeID != numeric(idDataChangeNotification) {
+				t.Errorf("notification type %v", x.TypeID)
+			}
+			body := &decoder{buf: x.Body}
+			body.array(func() {
+				if handle := body.uint32(); handle != 7 {
+					t.Errorf("client handle = %d", handle)
+				}
+				values = append(values, body.dataValue())
+			})
+		})
+		var results []StatusCode
+		d.array(func() { results = append(results, d.statusCode()) })
+		if d.err != nil {
+			t.Fatal(d.err)
+		}
+		return sequence, values, results
+	}
+	sequence, values, _ := publish()
+	if sequence != 1 || len(values) != 1 || values[0].Value.Value != float32(42) {
+		t.Fatalf("initial notification %d = %+v", sequence, values)
+	}
+	model.WriteWithPriority(av, model.PropertyIdentifierPresentValue, float32(50), 16)
+	sequence, values, results := publish(1)
+	if sequence != 2 || len(values) != 1 || values[0].Value.Value != float32(50) {
+		t.Errorf("change notification %d = %+v", sequence, values)
+	}
+	if len(results) != 1 || results[0] != StatusGood {
+		t.Errorf("acknowledgement results = %v", results)
+	}
+
+	republish := func(sequence uint32) StatusCode {
+		status, _ := c.call(idRepublishRequest, func(e *encoder) {
+			e.uint32(subscription)
+			e.uint32(sequence)
+		})
+		return status
+	}
+	if status := republish(2); status != StatusGood {
+		t.Errorf("Republish(2) = %v", status)
+	}
+	if status := republish(1); status != StatusBadMessageNotAvailable {
+		t.Errorf("Republish(1) = %v", status)
+	}
+
+	if status, _ := c.call(idCloseSessionRequest, func(e *encoder) { e.boolean(true) }); status != StatusGood {
+		t.Errorf("CloseSession = %v", status)
+	}
+	if status, _ := c.call(idReadRequest, readRequest(pv)); status != StatusBadSessionIDInvalid {
+		t.Errorf("Read after CloseSession = %v", status)
+	}
+}
+
+func TestToVariant(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  Variant
+	}{
+		{nil, Variant{}},
+		{true, Variant{Type: typeBoolean, Value: true}},
+		{float32(1.5), Variant{Type: typeFloat, Value: float32(1.5)}},
+		{model.UnitsDegreesCelsius, Variant{Type: typeUInt32, Value: uint32(model.UnitsDegreesCelsius)}},
+		{model.ObjectIdentifier{Type: model.ObjectTypeAnalogInput, Instance: 3}, Variant{Type: typeString, Value: "analog-input:3"}},
+		{model.BitString{true, false}, Variant{Type: typeBoolean, Array: true, Value: []interface{}{true, false}}},
+		{[]string{"Off", "On"}, Variant{Type: typeString, Array: true, Value: []interface{}{"Off", "On"}}},
+	}
+	for _, tt := range tests {
+		got := toVariant(tt.value)
+		e := &encoder{}
+		e.variant(got)
+		d := &decoder{buf: e.buf}
+		if decoded := d.variant(); d.err != nil || decoded.Type != tt.want.Type || decoded.Array != tt.want.Array {
+			t.Errorf("%v: round trip = %+v, %v", tt.value, decoded, d.err)
+		}
+		if got.Type != tt.want.Type || got.Array != tt.want.Array || (got.Value != nil && !equalValue(got.Value, tt.want.Value)) {
+			t.Errorf("toVariant(%#v) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func equalValue(a, b interface{}) bool {
+	x, ok := a.([]interface{})
+	if !ok {
+		return a == b
+	}
+	y, _ := b.([]interface{})
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
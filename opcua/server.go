@@ -0,0 +1,472 @@
+// Package opcua 实现内嵌的OPC UA服务端（opc.tcp二进制协议，无安全策略、匿名登录），
+// 将BACnet设备的对象树映射为地址空间：对象为节点，属性为变量。读取变量返回BACnet属性的当前值，
+// 写入变量经Config.Write写回对象，订阅按发布周期采样属性值并推送变化，
+// 使只支持OPC UA的SCADA系统也能访问同一批点位
+package opcua
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// 协议常量
+const (
+	protocolVersion    = 0
+	bufferSize         = 65535    // 本端收发缓冲区（单个消息块）的大小
+	minBufferSize      = 8192     // 协议允许的最小缓冲区
+	maxMessageSize     = 16 << 20 // 重组后请求消息的最大长度
+	securityPolicyNone = "http://opcfoundation.org/UA/SecurityPolicy#None"
+	transportProfile   = "http://opcfoundation.org/UA-Profile/Transport/uatcp-uasc-uabinary"
+	applicationURI     = "urn:iotzf:bacnet-server"
+	productURI         = "https://github.com/iotzf/bacnet-server"
+	namespaceIndex     = 1 // BACnet对象所在的命名空间
+	maxTokenLifetime   = time.Hour
+	minTokenLifetime   = 10 * time.Second
+)
+
+// Config OPC UA服务端的配置
+type Config struct {
+	// Write 写入BACnet属性，value已按属性类型转换，nil表示释放命令，调用时持有设备锁。
+	// 为nil时按属性元数据检查后以优先级16写入对象
+	Write    func(obj model.Object, prop model.PropertyIdentifier, value interface{}) error
+	Endpoint string       // GetEndpoints返回的端点URL，为空时按客户端请求的URL或监听地址生成
+	Logger   *slog.Logger // 为nil时使用slog.Default()
+	Clock    model.Clock  // 时间戳和订阅周期使用的时钟，为nil时使用设备的时钟
+}
+
+// Server OPC UA服务端，会话和订阅属于建立它们的安全通道，通道关闭时一并删除
+type Server struct {
+	device *model.Device
+	config Config
+	start  time.Time
+	static map[uint32]*node // 命名空间0中的节点
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[*conn]struct{}
+	closed    bool
+	nextID    uint32 // 安全通道、会话和订阅的编号
+}
+
+// NewServer 创建device的OPC UA服务端，调用Serve后开始接受连接。读写和浏览对象时持有设备锁
+func NewServer(device *model.Device, config Config) *Server {
+	s := &Server{
+		device:    device,
+		config:    config,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[*conn]struct{}),
+	}
+	s.start = s.now()
+	s.static = s.staticNodes()
+	return s
+}
+
+// logger 返回服务端使用的日志
+func (s *Server) logger() *slog.Logger {
+	if s.config.Logger != nil {
+		return s.config.Logger
+	}
+	return slog.Default()
+}
+
+// clock 返回服务端使用的时钟
+func (s *Server) clock() model.Clock {
+	if s.config.Clock != nil {
+		return s.config.Clock
+	}
+	return s.device.Clock()
+}
+
+func (s *Server) now() time.Time {
+	return s.clock().Now()
+}
+
+// newID 分配安全通道、会话或订阅的编号
+func (s *Server) newID() uint32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	return s.nextID
+}
+
+// Serve 在listener上接受连接，直到listener关闭或调用Close
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return net.ErrClosed
+	}
+	s.listeners[listener] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, listener)
+		s.mu.Unlock()
+	}()
+
+	for {
+		nc, err := listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		c := &conn{server: s, nc: nc, endpoint: s.config.Endpoint, sessions: make(map[string]*session)}
+		if c.endpoint == "" {
+			c.endpoint = "opc.tcp://" + listener.Addr().String()
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			nc.Close()
+			return nil
+		}
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+		go c.serve()
+	}
+}
+
+// Close 停止接受连接并关闭全部连接
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	listeners := s.listeners
+	conns := s.conns
+	s.listeners = map[net.Listener]struct{}{}
+	s.conns = map[*conn]struct{}{}
+	s.mu.Unlock()
+	for l := range listeners {
+		l.Close()
+	}
+	for c := range conns {
+		c.nc.Close()
+	}
+	return nil
+}
+
+// conn 一个TCP连接及其上的安全通道
+type conn struct {
+	server   *Server
+	nc       net.Conn
+	endpoint string
+
+	receiveSize uint32 // 对方发来的消息块的最大长度
+	sendSize    uint32 // 发给对方的消息块的最大长度
+	channelID   uint32
+	tokenID     uint32
+	chunks      map[uint32][]byte // 按请求号重组中的消息块
+
+	writeMu  sync.Mutex // 保护sequence和写连接，发布应答由订阅的goroutine发送
+	sequence uint32
+
+	mu       sync.Mutex // 保护sessions
+	sessions map[string]*session
+}
+
+// serve 处理连接上的消息直到连接关闭
+func (c *conn) serve() {
+	defer func() {
+		c.nc.Close()
+		c.mu.Lock()
+		sessions := c.sessions
+		c.sessions = map[string]*session{}
+		c.mu.Unlock()
+		for _, sess := range sessions {
+			sess.close()
+		}
+		c.server.mu.Lock()
+		delete(c.server.conns, c)
+		c.server.mu.Unlock()
+	}()
+
+	if err := c.hello(); err != nil {
+		c.server.logger().Debug("OPC UA握手失败", "peer", c.nc.RemoteAddr(), "error", err)
+		return
+	}
+	for {
+		msgType, chunk, body, err := c.readChunk()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				c.server.logger().Debug("OPC UA连接断开", "peer", c.nc.RemoteAddr(), "error", err)
+			}
+			return
+		}
+		switch msgType {
+		case "OPN":
+			err = c.openSecureChannel(body)
+		case "MSG":
+			err = c.message(chunk, body)
+		case "CLO":
+			return
+		default:
+			err = c.sendError(StatusBadTCPMessageTypeInvalid, "未知的消息类型"+msgType)
+		}
+		if err != nil {
+			c.server.logger().Debug("OPC UA消息处理失败", "peer", c.nc.RemoteAddr(), "error", err)
+			return
+		}
+	}
+}
+
+// readChunk 读取一个消息块，返回消息类型、块类型和头之后的内容
+func (c *conn) readChunk() (string, byte, []byte, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(c.nc, header); err != nil {
+		return "", 0, nil, err
+	}
+	size := binary.LittleEndian.Uint32(header[4:])
+	limit := c.receiveSize
+	if limit == 0 {
+		limit = bufferSize
+	}
+	if size < 8 || size > limit {
+		c.sendError(StatusBadTCPMessageTooLarge, "消息块过大")
+		return "", 0, nil, fmt.Errorf("消息块长度无效: %d", size)
+	}
+	body := make([]byte, size-8)
+	if _, err := io.ReadFull(c.nc, body); err != nil {
+		return "", 0, nil, err
+	}
+	return string(header[:3]), header[3], body, nil
+}
+
+// hello 处理Hello消息并应答Acknowledge，协商消息块大小
+func (c *conn) hello() error {
+	msgType, _, body, err := c.readChunk()
+	if err != nil {
+		return err
+	}
+	if msgType != "HEL" {
+		c.sendError(StatusBadTCPMessageTypeInvalid, "应先发送Hello")
+		return fmt.Errorf("第一个消息为%s", msgType)
+	}
+	d := &decoder{buf: body}
+	d.uint32() // ProtocolVersion
+	receive := d.uint32()
+	send := d.uint32()
+	d.uint32() // MaxMessageSize
+	d.uint32() // MaxChunkCount
+	d.string() // EndpointUrl
+	if d.err != nil || receive < minBufferSize || send < minBufferSize {
+		c.sendError(StatusBadDecodingError, "无效的Hello")
+		return errDecoding
+	}
+	c.sendSize = min(receive, bufferSize)
+	c.receiveSize = min(send, bufferSize)
+
+	e := &encoder{}
+	e.raw([]byte("ACKF\x00\x00\x00\x00"))
+	e.uint32(protocolVersion)
+	e.uint32(c.receiveSize)
+	e.uint32(c.sendSize)
+	e.uint32(maxMessageSize)
+	e.uint32(0) // 不限制消息块数
+	binary.LittleEndian.PutUint32(e.buf[4:], uint32(len(e.buf)))
+	_, err = c.nc.Write(e.buf)
+	return err
+}
+
+// sendError 发送Error消息，之后连接将被关闭
+func (c *conn) sendError(status StatusCode, reason string) error {
+	e := &encoder{}
+	e.raw([]byte("ERRF\x00\x00\x00\x00"))
+	e.statusCode(status)
+	e.string(reason)
+	binary.LittleEndian.PutUint32(e.buf[4:], uint32(len(e.buf)))
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := c.nc.Write(e.buf)
+	return err
+}
+
+// openSecureChannel 处理OpenSecureChannel请求，只接受无安全策略
+func (c *conn) openSecureChannel(body []byte) error {
+	d := &decoder{buf: body}
+	channelID := d.uint32()
+	policy := d.string()
+	d.byteString() // SenderCertificate
+	d.byteString() // ReceiverCertificateThumbprint
+	d.uint32()     // SequenceNumber
+	requestID := d.uint32()
+	typeID := d.nodeID()
+	header := decodeRequestHeader(d)
+	d.uint32() // ClientProtocolVersion
+	requestType := d.int32()
+	mode := d.int32()
+	d.byteString() // ClientNonce
+	lifetime := time.Duration(d.uint32()) * time.Millisecond
+	if d.err != nil || typeID != numeric(idOpenSecureChannelRequest) {
+		c.sendError(StatusBadDecodingError, "无效的OpenSecureChannel请求")
+		return errDecoding
+	}
+	if policy != securityPolicyNone || mode != 1 {
+		c.sendError(StatusBadSecurityPolicyRejected, "只支持SecurityPolicy#None")
+		return fmt.Errorf("不支持的安全策略%s", policy)
+	}
+	switch {
+	case requestType == 0 && c.channelID == 0:
+		c.channelID = c.server.newID()
+	case requestType == 1 && channelID == c.channelID && c.channelID != 0:
+		// 续约，令牌号加一
+	default:
+		c.sendError(StatusBadTCPSecureChannelUnknown, "安全通道无效")
+		return fmt.Errorf("安全通道%d无效", channelID)
+	}
+	c.tokenID++
+	lifetime = min(max(lifetime, minTokenLifetime), maxTokenLifetime)
+
+	e := &encoder{}
+	e.responseHeader(header.handle, StatusGood, c.server.now())
+	e.uint32(protocolVersion)
+	e.uint32(c.channelID)
+	e.uint32(c.tokenID)
+	e.dateTime(c.server.now())
+	e.uint32(uint32(lifetime / time.Millisecond))
+	e.byteString([]byte{}) // ServerNonce
+
+	msg := &encoder{}
+	msg.raw([]byte("OPNF\x00\x00\x00\x00"))
+	msg.uint32(c.channelID)
+	msg.string(securityPolicyNone)
+	msg.byteString(nil)
+	msg.byteString(nil)
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.sequence++
+	msg.uint32(c.sequence)
+	msg.uint32(requestID)
+	msg.nodeID(numeric(idOpenSecureChannelResponse))
+	msg.raw(e.buf)
+	binary.LittleEndian.PutUint32(msg.buf[4:], uint32(len(msg.buf)))
+	_, err := c.nc.Write(msg.buf)
+	return err
+}
+
+// message 处理MSG消息块，收齐最后一块后处理请求
+func (c *conn) message(chunk byte, body []byte) error {
+	d := &decoder{buf: body}
+	channelID := d.uint32()
+	d.uint32() // TokenId
+	d.uint32() // SequenceNumber
+	requestID := d.uint32()
+	if d.err != nil {
+		return errDecoding
+	}
+	if channelID != c.channelID || c.channelID == 0 {
+		c.sendError(StatusBadSecureChannelIDInvalid, "安全通道无效")
+		return fmt.Errorf("安全通道%d无效", channelID)
+	}
+	if c.chunks == nil {
+		c.chunks = make(map[uint32][]byte)
+	}
+	switch chunk {
+	case 'C':
+		c.chunks[requestID] = append(c.chunks[requestID], d.buf...)
+		if len(c.chunks[requestID]) > maxMessageSize {
+			c.sendError(StatusBadTCPMessageTooLarge, "请求消息过大")
+			return fmt.Errorf("请求%d过大", requestID)
+		}
+		return nil
+	case 'A':
+		delete(c.chunks, requestID)
+		return nil
+	}
+	request := append(c.chunks[requestID], d.buf...)
+	delete(c.chunks, requestID)
+	typeID, response := c.handle(requestID, request)
+	if response == nil {
+		return nil // 发布请求稍后应答
+	}
+	return c.send(requestID, typeID, response)
+}
+
+// send 发送应答消息，超过对方的消息块大小时拆分为多块
+func (c *conn) send(requestID uint32, typeID uint32, body []byte) error {
+	e := &encoder{}
+	e.nodeID(numeric(typeID))
+	e.raw(body)
+	payload := e.buf
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	maxBody := int(c.sendSize) - 24
+	for {
+		n := min(len(payload), maxBody)
+		chunkType := byte('F')
+		if n < len(payload) {
+			chunkType = 'C'
+		}
+		msg := &encoder{buf: make([]byte, 0, 24+n)}
+		msg.raw([]byte{'M', 'S', 'G', chunkType, 0, 0, 0, 0})
+		msg.uint32(c.channelID)
+		msg.uint32(c.tokenID)
+		c.sequence++
+		msg.uint32(c.sequence)
+		msg.uint32(requestID)
+		msg.raw(payload[:n])
+		binary.LittleEndian.PutUint32(msg.buf[4:], uint32(len(msg.buf)))
+		if _, err := c.nc.Write(msg.buf); err != nil {
+			return err
+		}
+		payload = payload[n:]
+		if len(payload) == 0 {
+			return nil
+		}
+	}
+}
+
+// requestHeader 请求头中用到的字段
+type requestHeader struct {
+	token  NodeID
+	handle uint32
+}
+
+func decodeRequestHeader(d *decoder) requestHeader {
+	var h requestHeader
+	h.token = d.nodeID()
+	d.dateTime()
+	h.handle = d.uint32()
+	d.uint32() // ReturnDiagnostics
+	d.string() // AuditEntryId
+	d.uint32() // TimeoutHint
+	d.extensionObject()
+	return h
+}
+
+// responseHeader 编码应答头
+func (e *encoder) responseHeader(handle uint32, status StatusCode, now time.Time) {
+	e.dateTime(now)
+	e.uint32(handle)
+	e.statusCode(status)
+	e.byte(0) // ServiceDiagnostics
+	e.int32(-1)
+	e.extensionObject(ExtensionObject{})
+}
+
+// fault 返回ServiceFault应答
+func (c *conn) fault(handle uint32, status StatusCode) (uint32, []byte) {
+	e := &encoder{}
+	e.responseHeader(handle, status, c.server.now())
+	return idServiceFault, e.buf
+}
+
+// nonce 返回32字节的随机数
+func nonce() []byte {
+	p := make([]byte, 32)
+	rand.Read(p)
+	return p
+}
@@ -0,0 +1,505 @@
+package opcua
+
+import (
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// 会话参数
+const (
+	minSessionTimeout       = 10 * time.Second
+	maxSessionTimeout       = time.Hour
+	maxContinuationPoints   = 16 // 每个会话保存的浏览续传点数
+	maxOperationsPerRequest = 10000
+)
+
+// session 客户端会话
+type session struct {
+	conn      *conn
+	id        NodeID
+	token     NodeID
+	name      string
+	activated bool
+
+	mu            sync.Mutex // 保护以下字段，订阅的goroutine也会访问
+	subscriptions map[uint32]*subscription
+	publish       []publishRequest
+	continuations map[string]continuation
+	closed        bool
+}
+
+// continuation 浏览结果中尚未返回的引用
+type continuation struct {
+	references []referenceDescription
+	max        int
+}
+
+// close 删除会话的全部订阅
+func (sess *session) close() {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	sess.closed = true
+	for _, sub := range sess.subscriptions {
+		sub.stop()
+	}
+	sess.subscriptions = nil
+	sess.publish = nil
+}
+
+// handle 处理一个请求，返回应答类型和内容。发布请求返回nil，稍后由订阅应答
+func (c *conn) handle(requestID uint32, body []byte) (uint32, []byte) {
+	d := &decoder{buf: body}
+	typeID := d.nodeID()
+	header := decodeRequestHeader(d)
+	if d.err != nil {
+		return c.fault(header.handle, StatusBadDecodingError)
+	}
+	if typeID.Namespace != 0 || typeID.Name != "" {
+		return c.fault(header.handle, StatusBadServiceUnsupported)
+	}
+
+	// 不需要会话的服务
+	switch typeID.ID {
+	case idGetEndpointsRequest:
+		return c.getEndpoints(d, header)
+	case idFindServersRequest:
+		return c.findServers(d, header)
+	case idCreateSessionRequest:
+		return c.createSession(d, header)
+	case idCloseSecureChannelRequest:
+		c.nc.Close()
+		return 0, nil
+	}
+
+	c.mu.Lock()
+	sess := c.sessions[header.token.Name]
+	c.mu.Unlock()
+	if sess == nil || header.token.Name == "" {
+		return c.fault(header.handle, StatusBadSessionIDInvalid)
+	}
+	switch typeID.ID {
+	case idActivateSessionRequest:
+		return c.activateSession(d, header, sess)
+	case idCloseSessionRequest:
+		c.mu.Lock()
+		delete(c.sessions, header.token.Name)
+		c.mu.Unlock()
+		sess.close()
+		return c.empty(idCloseSessionResponse, header)
+	}
+	if !sess.activated {
+		return c.fault(header.handle, StatusBadSessionNotActivated)
+	}
+
+	var response func(*decoder, requestHeader, *session) (uint32, []byte)
+	switch typeID.ID {
+	case idBrowseRequest:
+		response = c.browse
+	case idBrowseNextRequest:
+		response = c.browseNext
+	case idReadRequest:
+		response = c.read
+	case idWriteRequest:
+		response = c.write
+	case idCreateSubscriptionRequest:
+		response = c.createSubscription
+	case idModifySubscriptionRequest:
+		response = c.modifySubscription
+	case idSetPublishingModeRequest:
+		response = c.setPublishingMode
+	case idDeleteSubscriptionsRequest:
+		response = c.deleteSubscriptions
+	case idCreateMonitoredItemsRequest:
+		response = c.createMonitoredItems
+	case idDeleteMonitoredItemsRequest:
+		response = c.deleteMonitoredItems
+	case idRepublishRequest:
+		response = c.republish
+	case idPublishRequest:
+		return c.queuePublish(d, header, sess, requestID)
+	default:
+		c.server.logger().Debug("不支持的OPC UA服务", "type", typeID.ID)
+		return c.fault(header.handle, StatusBadServiceUnsupported)
+	}
+	typeID2, body2 := response(d, header, sess)
+	if d.err != nil {
+		return c.fault(header.handle, StatusBadDecodingError)
+	}
+	return typeID2, body2
+}
+
+// empty 返回只有应答头的应答
+func (c *conn) empty(typeID uint32, header requestHeader) (uint32, []byte) {
+	e := &encoder{}
+	e.responseHeader(header.handle, StatusGood, c.server.now())
+	return typeID, e.buf
+}
+
+// endpointURL 返回端点URL：配置了端点时使用配置，否则使用客户端请求的URL
+func (c *conn) endpointURL(requested string) string {
+	if c.server.config.Endpoint == "" && requested != "" {
+		return requested
+	}
+	return c.endpoint
+}
+
+// applicationDescription 编码本服务端的ApplicationDescription
+func (c *conn) applicationDescription(e *encoder, url string) {
+	e.string(applicationURI)
+	e.string(productURI)
+	c.server.device.Lock()
+	name := c.server.device.GetObjectName()
+	c.server.device.Unlock()
+	e.localizedText("BACnet " + name)
+	e.int32(0) // Server
+	e.nullString()
+	e.nullString()
+	e.stringArray([]string{url})
+}
+
+// endpointDescription 编码唯一的端点：无安全策略、匿名登录
+func (c *conn) endpointDescription(e *encoder, url string) {
+	e.string(url)
+	c.applicationDescription(e, url)
+	e.byteString(nil) // ServerCertificate
+	e.int32(1)        // MessageSecurityMode None
+	e.string(securityPolicyNone)
+	e.int32(1) // UserIdentityTokens
+	e.string("anonymous")
+	e.int32(0) // Anonymous
+	e.nullString()
+	e.nullString()
+	e.nullString()
+	e.string(transportProfile)
+	e.byte(0) // SecurityLevel
+}
+
+func (c *conn) getEndpoints(d *decoder, header requestHeader) (uint32, []byte) {
+	url := d.string()
+	d.stringArray() // LocaleIds
+	d.stringArray() // ProfileUris
+	if d.err != nil {
+		return c.fault(header.handle, StatusBadDecodingError)
+	}
+	e := &encoder{}
+	e.responseHeader(header.handle, StatusGood, c.server.now())
+	e.int32(1)
+	c.endpointDescription(e, c.endpointURL(url))
+	return idGetEndpointsResponse, e.buf
+}
+
+func (c *conn) findServers(d *decoder, header requestHeader) (uint32, []byte) {
+	url := d.string()
+	d.stringArray() // LocaleIds
+	d.stringArray() // ServerUris
+	if d.err != nil {
+		return c.fault(header.handle, StatusBadDecodingError)
+	}
+	e := &encoder{}
+	e.responseHeader(header.handle, StatusGood, c.server.now())
+	e.int32(1)
+	c.applicationDescription(e, c.endpointURL(url))
+	return idFindServersResponse, e.buf
+}
+
+// skipApplicationDescription 跳过客户端的ApplicationDescription
+func skipApplicationDescription(d *decoder) {
+	d.string()
+	d.string()
+	d.localizedText()
+	d.int32()
+	d.string()
+	d.string()
+	d.stringArray()
+}
+
+func (c *conn) createSession(d *decoder, header requestHeader) (uint32, []byte) {
+	skipApplicationDescription(d)
+	d.string() // ServerUri
+	url := d.string()
+	name := d.string()
+	d.byteString() // ClientNonce
+	d.byteString() // ClientCertificate
+	timeout := time.Duration(d.double() * float64(time.Millisecond))
+	d.uint32() // MaxResponseMessageSize
+	if d.err != nil {
+		return c.fault(header.handle, StatusBadDecodingError)
+	}
+	timeout = min(max(timeout, minSessionTimeout), maxSessionTimeout)
+
+	sess := &session{
+		conn:          c,
+		id:            NodeID{Namespace: namespaceIndex, ID: c.server.newID()},
+		token:         NodeID{Namespace: namespaceIndex, Name: hex.EncodeToString(nonce()[:16])},
+		name:          name,
+		subscriptions: make(map[uint32]*subscription),
+		continuations: make(map[string]continuation),
+	}
+	c.mu.Lock()
+	c.sessions[sess.token.Name] = sess
+	c.mu.Unlock()
+	c.server.logger().Info("OPC UA会话已创建", "peer", c.nc.RemoteAddr(), "session", name)
+
+	e := &encoder{}
+	e.responseHeader(header.handle, StatusGood, c.server.now())
+	e.nodeID(sess.id)
+	e.nodeID(sess.token)
+	e.double(float64(timeout / time.Millisecond))
+	e.byteString(nonce())
+	e.byteString(nil) // ServerCertificate
+	e.int32(1)
+	c.endpointDescription(e, c.endpointURL(url))
+	e.int32(0)        // ServerSoftwareCertificates
+	e.nullString()    // ServerSignature.Algorithm
+	e.byteString(nil) // ServerSignature.Signature
+	e.uint32(maxMessageSize)
+	return idCreateSessionResponse, e.buf
+}
+
+func (c *conn) activateSession(d *decoder, header requestHeader, sess *session) (uint32, []byte) {
+	d.string()     // ClientSignature.Algorithm
+	d.byteString() // ClientSignature.Signature
+	d.array(func() { d.byteString(); d.byteString() })
+	d.stringArray() // LocaleIds
+	token := d.extensionObject()
+	d.string()
+	d.byteString()
+	if d.err != nil {
+		return c.fault(header.handle, StatusBadDecodingError)
+	}
+	if token.TypeID != (NodeID{}) && token.TypeID != numeric(idAnonymousIdentityToken) {
+		return c.fault(header.handle, StatusBadIdentityTokenInvalid)
+	}
+	sess.activated = true
+
+	e := &encoder{}
+	e.responseHeader(header.handle, StatusGood, c.server.now())
+	e.byteString(nonce())
+	e.int32(0) // Results
+	e.diagnosticInfos()
+	return idActivateSessionResponse, e.buf
+}
+
+// referenceDescription 浏览结果中的一个引用
+type referenceDescription struct {
+	typeID  uint32
+	forward bool
+	target  *node
+}
+
+func (e *encoder) referenceDescription(r referenceDescription) {
+	e.nodeID(numeric(r.typeID))
+	e.boolean(r.forward)
+	e.expandedNodeID(r.target.id)
+	e.qualifiedName(r.target.browseName)
+	e.localizedText(r.target.displayName)
+	e.int32(int32(r.target.class))
+	e.expandedNodeID(r.target.typeDefinition())
+}
+
+// browseResult 编码一个BrowseResult，引用超过max时保存续传点
+func (c *conn) browseResult(e *encoder, sess *session, status StatusCode, refs []referenceDescription, max int) {
+	e.statusCode(status)
+	var point []byte
+	if max > 0 && len(refs) > max {
+		point = nonce()[:8]
+		sess.mu.Lock()
+		if len(sess.continuations) >= maxContinuationPoints {
+			for key := range sess.continuations {
+				delete(sess.continuations, key)
+				break
+			}
+		}
+		sess.continuations[string(point)] = continuation{references: refs[max:], max: max}
+		sess.mu.Unlock()
+		refs = refs[:max]
+	}
+	e.byteString(point)
+	e.int32(int32(len(refs)))
+	for _, ref := range refs {
+		e.referenceDescription(ref)
+	}
+}
+
+func (c *conn) browse(d *decoder, header requestHeader, sess *session) (uint32, []byte) {
+	d.nodeID()   // View.ViewId
+	d.dateTime() // View.Timestamp
+	d.uint32()   // View.ViewVersion
+	max := int(d.uint32())
+	type description struct {
+		node           NodeID
+		direction      int32
+		referenceType  NodeID
+		includeSubtype bool
+		classMask      uint32
+	}
+	var descriptions []description
+	d.array(func() {
+		var desc description
+		desc.node = d.nodeID()
+		desc.direction = d.int32()
+		desc.referenceType = d.nodeID()
+		desc.includeSubtype = d.boolean()
+		desc.classMask = d.uint32()
+		d.uint32() // ResultMask，总是返回全部字段
+		descriptions = append(descriptions, desc)
+	})
+	if d.err != nil {
+		return c.fault(header.handle, StatusBadDecodingError)
+	}
+	if len(descriptions) == 0 {
+		return c.fault(header.handle, StatusBadNothingToDo)
+	}
+	if len(descriptions) > maxOperationsPerRequest {
+		return c.fault(header.handle, StatusBadTooManyOperations)
+	}
+
+	e := &encoder{}
+	e.responseHeader(header.handle, StatusGood, c.server.now())
+	e.int32(int32(len(descriptions)))
+	for _, desc := range descriptions {
+		refs, status := c.server.browse(desc.node, desc.direction, desc.referenceType, desc.includeSubtype, desc.classMask)
+		c.browseResult(e, sess, status, refs, max)
+	}
+	e.diagnosticInfos()
+	return idBrowseResponse, e.buf
+}
+
+func (c *conn) browseNext(d *decoder, header requestHeader, sess *session) (uint32, []byte) {
+	release := d.boolean()
+	var points [][]byte
+	d.array(func() { points = append(points, d.byteString()) })
+	if d.err != nil {
+		return c.fault(header.handle, StatusBadDecodingError)
+	}
+	if len(points) == 0 {
+		return c.fault(header.handle, StatusBadNothingToDo)
+	}
+
+	e := &encoder{}
+	e.responseHeader(header.handle, StatusGood, c.server.now())
+	e.int32(int32(len(points)))
+	for _, point := range points {
+		sess.mu.Lock()
+		cont, ok := sess.continuations[string(point)]
+		delete(sess.continuations, string(point))
+		sess.mu.Unlock()
+		switch {
+		case !ok:
+			c.browseResult(e, sess, StatusBadContinuationPointInvalid, nil, 0)
+		case release:
+			c.browseResult(e, sess, StatusGood, nil, 0)
+		default:
+			c.browseResult(e, sess, StatusGood, cont.references, cont.max)
+		}
+	}
+	e.diagnosticInfos()
+	return idBrowseNextResponse, e.buf
+}
+
+// readValueID 读取或监视的节点属性
+type readValueID struct {
+	node       NodeID
+	attribute  uint32
+	indexRange string
+}
+
+func decodeReadValueID(d *decoder) readValueID {
+	var r readValueID
+	r.node = d.nodeID()
+	r.attribute = d.uint32()
+	r.indexRange = d.string()
+	d.qualifiedName() // DataEncoding
+	return r
+}
+
+func (c *conn) read(d *decoder, header requestHeader, sess *session) (uint32, []byte) {
+	d.double() // MaxAge，总是读取当前值
+	timestamps := d.int32()
+	var items []readValueID
+	d.array(func() { items = append(items, decodeReadValueID(d)) })
+	if d.err != nil {
+		return c.fault(header.handle, StatusBadDecodingError)
+	}
+	if timestamps < 0 || timestamps > timestampsNeither {
+		return c.fault(header.handle, StatusBadTimestampsToReturnInvalid)
+	}
+	if len(items) == 0 {
+		return c.fault(header.handle, StatusBadNothingToDo)
+	}
+	if len(items) > maxOperationsPerRequest {
+		return c.fault(header.handle, StatusBadTooManyOperations)
+	}
+
+	e := &encoder{}
+	e.responseHeader(header.handle, StatusGood, c.server.now())
+	e.int32(int32(len(items)))
+	for _, item := range items {
+		e.dataValue(c.server.read(item, timestamps))
+	}
+	e.diagnosticInfos()
+	return idReadResponse, e.buf
+}
+
+func (c *conn) write(d *decoder, header requestHeader, sess *session) (uint32, []byte) {
+	type writeValue struct {
+		node       NodeID
+		attribute  uint32
+		indexRange string
+		value      DataValue
+	}
+	var items []writeValue
+	d.array(func() {
+		var w writeValue
+		w.node = d.nodeID()
+		w.attribute = d.uint32()
+		w.indexRange = d.string()
+		w.value = d.dataValue()
+		items = append(items, w)
+	})
+	if d.err != nil {
+		return c.fault(header.handle, StatusBadDecodingError)
+	}
+	if len(items) == 0 {
+		return c.fault(header.handle, StatusBadNothingToDo)
+	}
+	if len(items) > maxOperationsPerRequest {
+		return c.fault(header.handle, StatusBadTooManyOperations)
+	}
+
+	results := make([]StatusCode, len(items))
+	for i, item := range items {
+		if item.indexRange != "" {
+			results[i] = StatusBadIndexRangeInvalid
+			continue
+		}
+		results[i] = c.server.write(item.node, item.attribute, item.value)
+		if results[i] == StatusGood {
+			c.server.logger().Info("经OPC UA写入属性", "peer", c.nc.RemoteAddr(), "node", item.node.String())
+		}
+	}
+	e := &encoder{}
+	e.responseHeader(header.handle, StatusGood, c.server.now())
+	e.statusCodes(results)
+	e.diagnosticInfos()
+	return idWriteResponse, e.buf
+}
+
+// writeStatus 将写入BACnet属性的错误映射为状态码
+func writeStatus(err error) StatusCode {
+	switch {
+	case err == nil:
+		return StatusGood
+	case errors.Is(err, model.ErrValueOutOfRange):
+		return StatusBadOutOfRange
+	case errors.Is(err, model.ErrInvalidDataType):
+		return StatusBadTypeMismatch
+	case errors.Is(err, model.ErrWriteAccessDenied):
+		return StatusBadUserAccessDenied
+	}
+	return StatusBadNotWritable
+}
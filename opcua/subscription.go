@@ -0,0 +1,519 @@
+package opcua
+
+import (
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// 订阅参数
+const (
+	minPublishingInterval      = minimumSamplingInterval * time.Millisecond
+	maxPublishingInterval      = time.Hour
+	defaultKeepAliveCount      = 10
+	maxSubscriptionsPerSession = 100
+	maxPublishRequests         = 10 // 每个会话排队的发布请求数
+	maxRetransmitMessages      = 32 // 每个订阅保存的待确认通知数
+)
+
+// 监视模式
+const (
+	monitoringDisabled  = 0
+	monitoringSampling  = 1
+	monitoringReporting = 2
+)
+
+// publishRequest 排队等待通知的发布请求
+type publishRequest struct {
+	requestID uint32
+	handle    uint32
+	results   []StatusCode // 请求中确认的结果
+}
+
+// subscription 订阅，按发布周期采样监视项，有变化时用排队的发布请求推送通知
+type subscription struct {
+	id               uint32
+	sess             *session
+	interval         time.Duration
+	lifetime         uint32
+	keepAlive        uint32
+	maxNotifications uint32
+	publishing       bool
+
+	items      map[uint32]*monitoredItem
+	sequence   uint32            // 最后一个通知的序号
+	retransmit map[uint32][]byte // 尚未确认的通知，按序号保存编码后的NotificationMessage
+
+	keepAliveCount uint32 // 距上次发送消息的周期数
+	lifetimeCount  uint32 // 没有发布请求可用的周期数
+	ticker         model.Ticker
+	done           chan struct{}
+}
+
+// monitoredItem 监视项，只保存最后一个未发送的变化
+type monitoredItem struct {
+	id         uint32
+	handle     uint32
+	item       readValueID
+	mode       int32
+	timestamps int32
+	last       DataValue
+	pending    *DataValue
+}
+
+// start 启动按发布周期运行的goroutine
+func (sub *subscription) start() {
+	sub.ticker = sub.sess.conn.server.clock().NewTicker(sub.interval)
+	sub.done = make(chan struct{})
+	go func(ticker model.Ticker, done chan struct{}) {
+		for {
+			select {
+			case <-ticker.C():
+				sub.sess.mu.Lock()
+				if sub.done != done {
+					sub.sess.mu.Unlock() // 等待锁期间订阅已停止或重启
+					return
+				}
+				sub.tick()
+				sub.sess.mu.Unlock()
+			case <-done:
+				return
+			}
+		}
+	}(sub.ticker, sub.done)
+}
+
+// stop 停止订阅的goroutine，调用方持有sess.mu
+func (sub *subscription) stop() {
+	if sub.done != nil {
+		sub.ticker.Stop()
+		close(sub.done)
+		sub.done = nil
+	}
+}
+
+// revise 修正订阅参数
+func (sub *subscription) revise(interval float64, lifetime, keepAlive, maxNotifications uint32) {
+	sub.interval = min(max(time.Duration(interval*float64(time.Millisecond)), minPublishingInterval), maxPublishingInterval)
+	if keepAlive == 0 {
+		keepAlive = defaultKeepAliveCount
+	}
+	sub.keepAlive = keepAlive
+	sub.lifetime = max(lifetime, 3*keepAlive)
+	sub.maxNotifications = maxNotifications
+}
+
+// sample 采样监视项，值或状态变化时保存待发送的通知
+func (sub *subscription) sample(item *monitoredItem) {
+	if item.mode == monitoringDisabled {
+		return
+	}
+	value := sub.sess.conn.server.read(item.item, item.timestamps)
+	if value.Status == item.last.Status && value.HasValue == item.last.HasValue && reflect.DeepEqual(value.Value, item.last.Value) {
+		return
+	}
+	item.last = value
+	if item.mode == monitoringReporting {
+		item.pending = &value
+	}
+}
+
+// tick 一个发布周期：采样，有通知时发布，否则按需发送保活消息，发布请求长期不足时删除订阅
+func (sub *subscription) tick() {
+	if sub.sess.closed {
+		return
+	}
+	ids := make([]uint32, 0, len(sub.items))
+	for id, item := range sub.items {
+		sub.sample(item)
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var ready []*monitoredItem
+	for _, id := range ids {
+		if item := sub.items[id]; item.pending != nil {
+			ready = append(ready, item)
+		}
+	}
+	sub.keepAliveCount++
+	switch {
+	case sub.publishing && len(ready) > 0:
+		if sub.publish(ready) {
+			return
+		}
+	case sub.keepAliveCount >= sub.keepAlive:
+		if sub.publish(nil) {
+			return
+		}
+	default:
+		return
+	}
+	sub.lifetimeCount++
+	if sub.lifetimeCount >= sub.lifetime {
+		sub.sess.conn.server.logger().Info("OPC UA订阅已过期", "subscription", sub.id)
+		sub.stop()
+		delete(sub.sess.subscriptions, sub.id)
+	}
+}
+
+// publish 用排队的发布请求发送通知，items为空时发送保活消息。没有发布请求时返回false
+func (sub *subscription) publish(items []*monitoredItem) bool {
+	sess := sub.sess
+	if len(sess.publish) == 0 {
+		return false
+	}
+	request := sess.publish[0]
+	sess.publish = sess.publish[1:]
+
+	more := false
+	if sub.maxNotifications > 0 && len(items) > int(sub.maxNotifications) {
+		items, more = items[:sub.maxNotifications], true
+	}
+	var message []byte
+	if len(items) == 0 {
+		message = sub.notificationMessage(sub.sequence+1, nil)
+	} else {
+		sub.sequence++
+		message = sub.notificationMessage(sub.sequence, items)
+		for _, item := range items {
+			item.pending = nil
+		}
+		if len(sub.retransmit) >= maxRetransmitMessages {
+			delete(sub.retransmit, sub.available()[0])
+		}
+		sub.retransmit[sub.sequence] = message
+	}
+	sub.keepAliveCount = 0
+	sub.lifetimeCount = 0
+
+	e := &encoder{}
+	e.responseHeader(request.handle, StatusGood, sess.conn.server.now())
+	e.uint32(sub.id)
+	e.uint32Array(sub.available())
+	e.boolean(more)
+	e.raw(message)
+	e.statusCodes(request.results)
+	e.diagnosticInfos()
+	if err := sess.conn.send(request.requestID, idPublishResponse, e.buf); err != nil {
+		sess.conn.nc.Close()
+	}
+	return true
+}
+
+// notificationMessage 编码NotificationMessage，items为空时为保活消息
+func (sub *subscription) notificationMessage(sequence uint32, items []*monitoredItem) []byte {
+	e := &encoder{}
+	e.uint32(sequence)
+	e.dateTime(sub.sess.conn.server.now())
+	if len(items) == 0 {
+		e.int32(0)
+		return e.buf
+	}
+	body := &encoder{}
+	body.int32(int32(len(items)))
+	for _, item := range items {
+		body.uint32(item.handle)
+		body.dataValue(*item.pending)
+	}
+	body.diagnosticInfos()
+	e.int32(1)
+	e.extensionObject(ExtensionObject{TypeID: numeric(idDataChangeNotification), Body: body.buf})
+	return e.buf
+}
+
+// available 返回尚未确认的通知序号
+func (sub *subscription) available() []uint32 {
+	sequences := make([]uint32, 0, len(sub.retransmit))
+	for sequence := range sub.retransmit {
+		sequences = append(sequences, sequence)
+	}
+	sort.Slice(sequences, func(i, j int) bool { return sequences[i] < sequences[j] })
+	return sequences
+}
+
+// queuePublish 处理确认并将发布请求排队，由订阅的goroutine应答
+func (c *conn) queuePublish(d *decoder, header requestHeader, sess *session, requestID uint32) (uint32, []byte) {
+	type ack struct{ subscription, sequence uint32 }
+	var acks []ack
+	d.array(func() { acks = append(acks, ack{d.uint32(), d.uint32()}) })
+	if d.err != nil {
+		return c.fault(header.handle, StatusBadDecodingError)
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	results := make([]StatusCode, len(acks))
+	for i, a := range acks {
+		sub := sess.subscriptions[a.subscription]
+		switch {
+		case sub == nil:
+			results[i] = StatusBadSubscriptionIDInvalid
+		case sub.retransmit[a.sequence] == nil:
+			results[i] = StatusBadSequenceNumberUnknown
+		default:
+			delete(sub.retransmit, a.sequence)
+		}
+	}
+	if len(sess.subscriptions) == 0 {
+		return c.fault(header.handle, StatusBadNoSubscription)
+	}
+	if len(sess.publish) >= maxPublishRequests {
+		oldest := sess.publish[0]
+		sess.publish = sess.publish[1:]
+		typeID, body := c.fault(oldest.handle, StatusBadTooManyPublishRequests)
+		if err := c.send(oldest.requestID, typeID, body); err != nil {
+			c.nc.Close()
+		}
+	}
+	sess.publish = append(sess.publish, publishRequest{requestID: requestID, handle: header.handle, results: results})
+	return 0, nil
+}
+
+func (c *conn) createSubscription(d *decoder, header requestHeader, sess *session) (uint32, []byte) {
+	interval := d.double()
+	lifetime := d.uint32()
+	keepAlive := d.uint32()
+	maxNotifications := d.uint32()
+	publishing := d.boolean()
+	d.byte() // Priority
+	if d.err != nil {
+		return c.fault(header.handle, StatusBadDecodingError)
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if len(sess.subscriptions) >= maxSubscriptionsPerSession {
+		return c.fault(header.handle, StatusBadTooManySubscriptions)
+	}
+	sub := &subscription{
+		id:         c.server.newID(),
+		sess:       sess,
+		publishing: publishing,
+		items:      make(map[uint32]*monitoredItem),
+		retransmit: make(map[uint32][]byte),
+	}
+	sub.revise(interval, lifetime, keepAlive, maxNotifications)
+	sess.subscriptions[sub.id] = sub
+	sub.start()
+
+	e := &encoder{}
+	e.responseHeader(header.handle, StatusGood, c.server.now())
+	e.uint32(sub.id)
+	e.double(float64(sub.interval) / float64(time.Millisecond))
+	e.uint32(sub.lifetime)
+	e.uint32(sub.keepAlive)
+	return idCreateSubscriptionResponse, e.buf
+}
+
+func (c *conn) modifySubscription(d *decoder, header requestHeader, sess *session) (uint32, []byte) {
+	id := d.uint32()
+	interval := d.double()
+	lifetime := d.uint32()
+	keepAlive := d.uint32()
+	maxNotifications := d.uint32()
+	d.byte() // Priority
+	if d.err != nil {
+		return c.fault(header.handle, StatusBadDecodingError)
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	sub := sess.subscriptions[id]
+	if sub == nil {
+		return c.fault(header.handle, StatusBadSubscriptionIDInvalid)
+	}
+	previous := sub.interval
+	sub.revise(interval, lifetime, keepAlive, maxNotifications)
+	if sub.interval != previous {
+		sub.stop()
+		sub.start()
+	}
+
+	e := &encoder{}
+	e.responseHeader(header.handle, StatusGood, c.server.now())
+	e.double(float64(sub.interval) / float64(time.Millisecond))
+	e.uint32(sub.lifetime)
+	e.uint32(sub.keepAlive)
+	return idModifySubscriptionResponse, e.buf
+}
+
+func (c *conn) setPublishingMode(d *decoder, header requestHeader, sess *session) (uint32, []byte) {
+	publishing := d.boolean()
+	ids := d.uint32Array()
+	if d.err != nil {
+		return c.fault(header.handle, StatusBadDecodingError)
+	}
+	if len(ids) == 0 {
+		return c.fault(header.handle, StatusBadNothingToDo)
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	results := make([]StatusCode, len(ids))
+	for i, id := range ids {
+		if sub := sess.subscriptions[id]; sub != nil {
+			sub.publishing = publishing
+		} else {
+			results[i] = StatusBadSubscriptionIDInvalid
+		}
+	}
+	e := &encoder{}
+	e.responseHeader(header.handle, StatusGood, c.server.now())
+	e.statusCodes(results)
+	e.diagnosticInfos()
+	return idSetPublishingModeResponse, e.buf
+}
+
+func (c *conn) deleteSubscriptions(d *decoder, header requestHeader, sess *session) (uint32, []byte) {
+	ids := d.uint32Array()
+	if d.err != nil {
+		return c.fault(header.handle, StatusBadDecodingError)
+	}
+	if len(ids) == 0 {
+		return c.fault(header.handle, StatusBadNothingToDo)
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	results := make([]StatusCode, len(ids))
+	for i, id := range ids {
+		if sub := sess.subscriptions[id]; sub != nil {
+			sub.stop()
+			delete(sess.subscriptions, id)
+		} else {
+			results[i] = StatusBadSubscriptionIDInvalid
+		}
+	}
+	e := &encoder{}
+	e.responseHeader(header.handle, StatusGood, c.server.now())
+	e.statusCodes(results)
+	e.diagnosticInfos()
+	return idDeleteSubscriptionsResponse, e.buf
+}
+
+func (c *conn) createMonitoredItems(d *decoder, header requestHeader, sess *session) (uint32, []byte) {
+	id := d.uint32()
+	timestamps := d.int32()
+	type createRequest struct {
+		item   readValueID
+		mode   int32
+		handle uint32
+	}
+	var requests []createRequest
+	d.array(func() {
+		var r createRequest
+		r.item = decodeReadValueID(d)
+		r.mode = d.int32()
+		r.handle = d.uint32()
+		d.double()          // SamplingInterval，按发布周期采样
+		d.extensionObject() // Filter，值或状态变化时通知
+		d.uint32()          // QueueSize，只保存最后一个变化
+		d.boolean()         // DiscardOldest
+		requests = append(requests, r)
+	})
+	if d.err != nil {
+		return c.fault(header.handle, StatusBadDecodingError)
+	}
+	if timestamps < 0 || timestamps > timestampsNeither {
+		return c.fault(header.handle, StatusBadTimestampsToReturnInvalid)
+	}
+	if len(requests) == 0 {
+		return c.fault(header.handle, StatusBadNothingToDo)
+	}
+	if len(requests) > maxOperationsPerRequest {
+		return c.fault(header.handle, StatusBadTooManyOperations)
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	sub := sess.subscriptions[id]
+	if sub == nil {
+		return c.fault(header.handle, StatusBadSubscriptionIDInvalid)
+	}
+	e := &encoder{}
+	e.responseHeader(header.handle, StatusGood, c.server.now())
+	e.int32(int32(len(requests)))
+	for _, r := range requests {
+		status := StatusGood
+		var itemID uint32
+		if r.mode < monitoringDisabled || r.mode > monitoringReporting {
+			status = StatusBadMonitoringModeInvalid
+		} else if value := c.server.read(r.item, timestamps); value.Status == StatusBadNodeIDUnknown || value.Status == StatusBadAttributeIDInvalid {
+			status = value.Status
+		} else {
+			itemID = c.server.newID()
+			item := &monitoredItem{id: itemID, handle: r.handle, item: r.item, mode: r.mode, timestamps: timestamps}
+			if item.mode != monitoringDisabled {
+				item.last = value
+			}
+			if item.mode == monitoringReporting {
+				item.pending = &value // 首个通知为当前值
+			}
+			sub.items[itemID] = item
+		}
+		e.statusCode(status)
+		e.uint32(itemID)
+		e.double(float64(sub.interval) / float64(time.Millisecond))
+		e.uint32(1)
+		e.extensionObject(ExtensionObject{})
+	}
+	e.diagnosticInfos()
+	return idCreateMonitoredItemsResponse, e.buf
+}
+
+func (c *conn) deleteMonitoredItems(d *decoder, header requestHeader, sess *session) (uint32, []byte) {
+	id := d.uint32()
+	ids := d.uint32Array()
+	if d.err != nil {
+		return c.fault(header.handle, StatusBadDecodingError)
+	}
+	if len(ids) == 0 {
+		return c.fault(header.handle, StatusBadNothingToDo)
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	sub := sess.subscriptions[id]
+	if sub == nil {
+		return c.fault(header.handle, StatusBadSubscriptionIDInvalid)
+	}
+	results := make([]StatusCode, len(ids))
+	for i, itemID := range ids {
+		if sub.items[itemID] != nil {
+			delete(sub.items, itemID)
+		} else {
+			results[i] = StatusBadMonitoredItemIDInvalid
+		}
+	}
+	e := &encoder{}
+	e.responseHeader(header.handle, StatusGood, c.server.now())
+	e.statusCodes(results)
+	e.diagnosticInfos()
+	return idDeleteMonitoredItemsResponse, e.buf
+}
+
+func (c *conn) republish(d *decoder, header requestHeader, sess *session) (uint32, []byte) {
+	id := d.uint32()
+	sequence := d.uint32()
+	if d.err != nil {
+		return c.fault(header.handle, StatusBadDecodingError)
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	sub := sess.subscriptions[id]
+	if sub == nil {
+		return c.fault(header.handle, StatusBadSubscriptionIDInvalid)
+	}
+	message := sub.retransmit[sequence]
+	if message == nil {
+		return c.fault(header.handle, StatusBadMessageNotAvailable)
+	}
+	e := &encoder{}
+	e.responseHeader(header.handle, StatusGood, c.server.now())
+	e.raw(message)
+	return idRepublishResponse, e.buf
+}
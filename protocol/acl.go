@@ -0,0 +1,121 @@
+package protocol
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// WriteServices 修改设备状态的确认服务，只读模式下全部拒绝
+var WriteServices = []byte{
+	BACnetServiceConfirmedWriteProperty,
+	BACnetServiceConfirmedWritePropertyMultiple,
+	BACnetServiceConfirmedAtomicWriteFile,
+	BACnetServiceConfirmedLifeSafetyOperation,
+	BACnetServiceConfirmedCreateObject,
+	BACnetServiceConfirmedDeleteObject,
+}
+
+// FileServices 文件访问的确认服务
+var FileServices = []byte{
+	BACnetServiceConfirmedAtomicReadFile,
+	BACnetServiceConfirmedAtomicWriteFile,
+}
+
+// ACLRule 访问控制规则，按来源地址允许或拒绝确认服务
+type ACLRule struct {
+	Source   string // 来源IP或子网（如"10.0.0.0/8"），为空时匹配所有来源，包括非IP数据链路上的客户端
+	Services []byte // 规则适用的确认服务选择，为空时适用于所有确认服务
+	Deny     bool   // 为true时拒绝匹配的请求，否则允许
+}
+
+// aclRule 解析后的访问控制规则
+type aclRule struct {
+	network  *net.IPNet // 为nil时匹配所有来源
+	services []byte
+	deny     bool
+}
+
+// SetACL 设置访问控制列表，在Start之前调用。请求按顺序匹配规则，第一个匹配的规则决定是否允许，
+// 没有匹配的规则时允许。被拒绝的请求以security类的access-denied错误应答
+func (s *BACnetServer) SetACL(rules []ACLRule) error {
+	acl := make([]aclRule, 0, len(rules))
+	for _, rule := range rules {
+		parsed := aclRule{services: rule.Services, deny: rule.Deny}
+		if rule.Source != "" {
+			network, err := parseSource(rule.Source)
+			if err != nil {
+				return err
+			}
+			parsed.network = network
+		}
+		acl = append(acl, parsed)
+	}
+	s.acl = acl
+	return nil
+}
+
+// SetReadOnly 设置只读模式，在Start之前调用。只读模式下WriteServices中的服务
+// 以security类的write-access-denied错误应答，本地API仍可修改对象
+func (s *BACnetServer) SetReadOnly(readOnly bool) {
+	s.readOnly = readOnly
+}
+
+// parseSource 解析规则的来源，单个IP视为/32（IPv6为/128）子网
+func parseSource(source string) (*net.IPNet, error) {
+	if strings.Contains(source, "/") {
+		_, network, err := net.ParseCIDR(source)
+		if err != nil {
+			return nil, fmt.Errorf("无效的访问控制来源: %s", source)
+		}
+		return network, nil
+	}
+	ip := net.ParseIP(source)
+	if ip == nil {
+		return nil, fmt.Errorf("无效的访问控制来源: %s", source)
+	}
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)}, nil
+}
+
+// checkAccess 检查ctx的客户端是否可以使用确认服务service，拒绝时返回应答的错误类别和代码
+func (s *BACnetServer) checkAccess(ctx *RequestContext, service byte) (byte, byte, bool) {
+	if s.readOnly && containsService(WriteServices, service) {
+		return ErrorClassSecurity, ErrorCodeWriteAccessDenied, false
+	}
+	ip := peerIP(ctx.peer())
+	for _, rule := range s.acl {
+		if rule.network != nil && (ip == nil || !rule.network.Contains(ip)) {
+			continue
+		}
+		if len(rule.services) > 0 && !containsService(rule.services, service) {
+			continue
+		}
+		if rule.deny {
+			return ErrorClassSecurity, ErrorCodeAccessDenied, false
+		}
+		return 0, 0, true
+	}
+	return 0, 0, true
+}
+
+// peerIP 返回客户端地址中的IP，非IP地址（如MS/TP）返回nil
+func peerIP(peer string) net.IP {
+	host, _, err := net.SplitHostPort(peer)
+	if err != nil {
+		host = peer
+	}
+	return net.ParseIP(host)
+}
+
+// containsService 判断服务选择是否在列表中
+func containsService(services []byte, service byte) bool {
+	for _, s := range services {
+		if s == service {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,327 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/iotzf/bacnet-server/encoding"
+)
+
+// APDU类型常量  常见 PDU 类型编码（高 4 位）
+const (
+	BACnetAPDUTypeConfirmedServiceRequest   = 0x0
+	BACnetAPDUTypeUnconfirmedServiceRequest = 0x1
+	BACnetAPDUTypeSimpleAck                 = 0x2
+	BACnetAPDUTypeComplexAck                = 0x3
+	BACnetAPDUTypeSegmentAck                = 0x4
+	BACnetAPDUTypeError                     = 0x5
+	BACnetAPDUTypeReject                    = 0x6
+	BACnetAPDUTypeAbort                     = 0x7
+)
+
+// BACnet服务类型常量
+const (
+	BACnetServiceUnconfirmedIAm                 = 0x00
+	BACnetServiceUnconfirmedWhoIs               = 0x08
+	BACnetServiceUnconfirmedWhoHas              = 0x07
+	BACnetServiceUnconfirmedIHave               = 0x01
+	BACnetServiceUnconfirmedCOVNotification     = 0x02
+	BACnetServiceUnconfirmedPrivateTransfer     = 0x04
+	BACnetServiceConfirmedReadProperty          = 0x0c
+	BACnetServiceConfirmedWriteProperty         = 0x0f
+	BACnetServiceConfirmedReadPropertyMultiple  = 0x0e
+	BACnetServiceConfirmedWritePropertyMultiple = 0x10
+	BACnetServiceConfirmedAcknowledgeAlarm      = 0x00
+	BACnetServiceUnconfirmedEventNotification   = 0x03
+	BACnetServiceConfirmedAtomicReadFile        = 0x06
+	BACnetServiceConfirmedAtomicWriteFile       = 0x07
+	BACnetServiceConfirmedSubscribeCOV          = 0x05
+	BACnetServiceConfirmedSubscribeCOVProperty  = 0x1c
+	BACnetServiceConfirmedReadRange             = 0x1a
+	BACnetServiceConfirmedLifeSafetyOperation   = 0x1b
+	BACnetServiceConfirmedPrivateTransfer       = 0x12
+	BACnetServiceConfirmedCreateObject          = 0x0a // 未实现，用于访问控制规则
+	BACnetServiceConfirmedDeleteObject          = 0x0b // 未实现，用于访问控制规则
+)
+
+// BACnetServicesSupported的位编号（ASHRAE 135 第21节），与服务选择码不同
+const (
+	servicesSupportedAcknowledgeAlarm      = 0
+	servicesSupportedSubscribeCOV          = 5
+	servicesSupportedAtomicReadFile        = 6
+	servicesSupportedAtomicWriteFile       = 7
+	servicesSupportedReadProperty          = 12
+	servicesSupportedReadPropertyMultiple  = 14
+	servicesSupportedWriteProperty         = 15
+	servicesSupportedWritePropertyMultiple = 16
+	servicesSupportedPrivateTransfer       = 18
+	servicesSupportedIAm                   = 26
+	servicesSupportedIHave                 = 27
+	servicesSupportedUnconfirmedCOV        = 28
+	servicesSupportedUnconfirmedEvent      = 29
+	servicesSupportedUnconfirmedTransfer   = 30
+	servicesSupportedWhoHas                = 33
+	servicesSupportedWhoIs                 = 34
+	servicesSupportedReadRange             = 35
+	servicesSupportedLifeSafetyOperation   = 37
+	servicesSupportedSubscribeCOVProperty  = 38
+	servicesSupportedCount                 = 41 // 位串长度
+)
+
+// APDU 表示解析后的 APDU 内容（尽量包含常用字段）
+type APDU struct {
+	PDUType            byte   // 高4位 PDU 类型（原始值）
+	ControlFlags       byte   // 低4位控制标志（原始字节 & 0x0F）
+	InvokeID           *byte  // 可选（仅存在于某些 PDU）
+	MaxResponse        *byte  // 可选：确认请求接受的最大分段数和最大APDU长度（octet1）
+	ServiceChoice      *byte  // 可选：服务选择器（存在于大多数服务相关 PDU）
+	SequenceNumber     *byte  // 可选（分段场景）
+	ProposedWindowSize *byte  // 可选（分段场景）
+	Payload            []byte // 剩余服务参数 / 有效载荷
+	Raw                []byte // 原始 APDU 数据副本
+}
+
+// ParseAPDU 解析传入的 APDU 字节，返回结构化信息。
+// 解析遵循常见 BACnet APDU 帧格式的约定：
+// - Confirmed service request: octet0(type/flags), octet1(maxSegs/maxApdu), octet2(invokeID), octet3(serviceChoice), octet4..payload
+// - Unconfirmed service: octet0(type/flags), octet1(serviceChoice), octet2..payload
+// - SimpleAck: octet0(type), octet1(invokeID), octet2(serviceChoice)
+// - ComplexAck: octet0(type/flags), octet1(invokeID), [octet2(sequence), octet3(window),] serviceChoice, payload
+// - SegmentAck: octet0(type/flags), octet1(invokeID), octet2(sequence), octet3(window)
+// - Error: octet0(type), octet1(invokeID), octet2(serviceChoice), octet3..error data
+// - Reject/Abort: octet0(type/flags), octet1(invokeID), octet2(reason)
+// 所有字段经encoding.Reader读取，长度不足时返回错误。
+func ParseAPDU(data []byte) (*APDU, error) {
+	if len(data) < 1 {
+		return nil, errors.New("empty APDU")
+	}
+
+	raw := make([]byte, len(data))
+	copy(raw, data)
+
+	r := encoding.NewReader(data)
+	first, _ := r.Byte()
+	pduType := first >> 4
+	control := first & 0x0F
+
+	result := &APDU{
+		PDUType:      pduType,
+		ControlFlags: control,
+		Raw:          raw,
+	}
+
+	var err error
+	switch pduType {
+	case BACnetAPDUTypeConfirmedServiceRequest:
+		// octet1(maxSegs/maxApdu)，分段请求另有序号和建议窗口大小
+		if result.MaxResponse, err = readBytePtr(r); err != nil {
+			break
+		}
+		if result.InvokeID, err = readBytePtr(r); err != nil {
+			break
+		}
+		if control&0x08 != 0 {
+			if result.SequenceNumber, err = readBytePtr(r); err != nil {
+				break
+			}
+			if result.ProposedWindowSize, err = readBytePtr(r); err != nil {
+				break
+			}
+		}
+		result.ServiceChoice, err = readBytePtr(r)
+
+	case BACnetAPDUTypeUnconfirmedServiceRequest:
+		// Who-Is Who-Has I-Have I-Am
+		result.ServiceChoice, err = readBytePtr(r)
+
+	case BACnetAPDUTypeSimpleAck, BACnetAPDUTypeError:
+		if result.InvokeID, err = readBytePtr(r); err != nil {
+			break
+		}
+		result.ServiceChoice, err = readBytePtr(r)
+
+	case BACnetAPDUTypeComplexAck:
+		if result.InvokeID, err = readBytePtr(r); err != nil {
+			break
+		}
+		if control&0x08 != 0 {
+			// 分段应答带序号和建议窗口大小
+			if result.SequenceNumber, err = readBytePtr(r); err != nil {
+				break
+			}
+			if result.ProposedWindowSize, err = readBytePtr(r); err != nil {
+				break
+			}
+		}
+		result.ServiceChoice, err = readBytePtr(r)
+
+	case BACnetAPDUTypeSegmentAck:
+		if result.InvokeID, err = readBytePtr(r); err != nil {
+			break
+		}
+		if result.SequenceNumber, err = readBytePtr(r); err != nil {
+			break
+		}
+		result.ProposedWindowSize, err = readBytePtr(r)
+
+	case BACnetAPDUTypeReject, BACnetAPDUTypeAbort:
+		// 原因代码留在Payload中
+		result.InvokeID, err = readBytePtr(r)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s PDU too short: %w", pduTypeName(pduType), err)
+	}
+
+	// 未知或未实现的 PDU 类型，剩余数据原样返回给调用者进一步处理
+	if r.Len() > 0 {
+		result.Payload = r.Remaining()
+	}
+	return result, nil
+}
+
+// readBytePtr 读取一个字节并返回其指针，用于APDU的可选字段
+func readBytePtr(r *encoding.Reader) (*byte, error) {
+	b, err := r.Byte()
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// encodeSimpleAck 构建SimpleAck APDU：PDU类型、invokeID、服务选择
+func encodeSimpleAck(invokeID byte, serviceChoice byte) []byte {
+	return []byte{BACnetAPDUTypeSimpleAck << 4, invokeID, serviceChoice}
+}
+
+// encodeComplexAck 构建ComplexAck APDU：PDU类型、invokeID、服务选择，随后为服务数据
+func encodeComplexAck(invokeID byte, serviceChoice byte, serviceData []byte) []byte {
+	out := make([]byte, 0, 3+len(serviceData))
+	out = append(out, BACnetAPDUTypeComplexAck<<4, invokeID, serviceChoice)
+	return append(out, serviceData...)
+}
+
+// apduWriter 流式构建ComplexAck：服务数据直接编码到池中的缓冲区，完成时一次复制出应答
+type apduWriter struct {
+	*encoding.Writer
+}
+
+// newComplexAckWriter 取得缓冲区并写入ComplexAck头部
+func newComplexAckWriter(invokeID byte, serviceChoice byte) apduWriter {
+	w := apduWriter{encoding.GetWriter()}
+	w.Write([]byte{BACnetAPDUTypeComplexAck << 4, invokeID, serviceChoice})
+	return w
+}
+
+// finish 返回编码完成的APDU并归还缓冲区
+func (w apduWriter) finish() []byte {
+	out := append(make([]byte, 0, w.Len()), w.Bytes()...)
+	encoding.PutWriter(w.Writer)
+	return out
+}
+
+// discard 放弃编码（如改为应答错误）并归还缓冲区
+func (w apduWriter) discard() {
+	encoding.PutWriter(w.Writer)
+}
+
+// pduTypeName 返回 PDU 类型可读名称
+func pduTypeName(t byte) string {
+	switch t {
+	case BACnetAPDUTypeConfirmedServiceRequest:
+		return "ConfirmedServiceRequest"
+	case BACnetAPDUTypeUnconfirmedServiceRequest:
+		return "UnconfirmedServiceRequest"
+	case BACnetAPDUTypeSimpleAck:
+		return "SimpleAck"
+	case BACnetAPDUTypeComplexAck:
+		return "ComplexAck"
+	case BACnetAPDUTypeSegmentAck:
+		return "SegmentAck"
+	case BACnetAPDUTypeError:
+		return "Error"
+	case BACnetAPDUTypeReject:
+		return "Reject"
+	case BACnetAPDUTypeAbort:
+		return "Abort"
+	default:
+		return "Unknown"
+	}
+}
+
+// String 返回 APDU 的可读字符串表示，便于调试
+func (a *APDU) String() string {
+	if a == nil {
+		return "<nil APDU>"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "APDU{Type:%s(0x%02x), Flags:0x%02x", pduTypeName(a.PDUType), a.PDUType, a.ControlFlags)
+
+	if a.InvokeID != nil {
+		fmt.Fprintf(&sb, ", InvokeID:%d", *a.InvokeID)
+	}
+	if a.ServiceChoice != nil {
+		fmt.Fprintf(&sb, ", ServiceChoice:0x%02x", *a.ServiceChoice)
+	}
+	if a.SequenceNumber != nil {
+		fmt.Fprintf(&sb, ", Sequence:%d", *a.SequenceNumber)
+	}
+	if a.ProposedWindowSize != nil {
+		fmt.Fprintf(&sb, ", Window:%d", *a.ProposedWindowSize)
+	}
+
+	if len(a.Payload) > 0 {
+		fmt.Fprintf(&sb, ", PayloadLen:%d", len(a.Payload))
+		// 显示前最多16字节的 payload 前缀，超过则以 ... 结尾
+		prefixLen := 16
+		if len(a.Payload) < prefixLen {
+			prefixLen = len(a.Payload)
+		}
+		fmt.Fprintf(&sb, ", PayloadPrefix:% x", a.Payload[:prefixLen])
+		if len(a.Payload) > prefixLen {
+			sb.WriteString("...")
+		}
+	}
+
+	sb.WriteString("}")
+	return sb.String()
+}
+
+func (a *APDU) ServiceName() string {
+	serviceName := "未知"
+	switch *a.ServiceChoice {
+	case BACnetServiceConfirmedReadProperty:
+		serviceName = "ReadProperty"
+	case BACnetServiceConfirmedWriteProperty:
+		serviceName = "WriteProperty"
+	case BACnetServiceConfirmedReadPropertyMultiple:
+		serviceName = "ReadPropertyMultiple"
+	case BACnetServiceConfirmedWritePropertyMultiple:
+		serviceName = "WritePropertyMultiple"
+	case BACnetServiceConfirmedAcknowledgeAlarm:
+		serviceName = "AcknowledgeAlarm"
+	case BACnetServiceConfirmedAtomicReadFile:
+		serviceName = "AtomicReadFile"
+	case BACnetServiceConfirmedAtomicWriteFile:
+		serviceName = "AtomicWriteFile"
+	case BACnetServiceConfirmedSubscribeCOV:
+		serviceName = "SubscribeCOV"
+	case BACnetServiceConfirmedSubscribeCOVProperty:
+		serviceName = "SubscribeCOVProperty"
+	case BACnetServiceConfirmedReadRange:
+		serviceName = "ReadRange"
+	case BACnetServiceConfirmedLifeSafetyOperation:
+		serviceName = "LifeSafetyOperation"
+	case BACnetServiceConfirmedPrivateTransfer:
+		serviceName = "ConfirmedPrivateTransfer"
+	case BACnetServiceConfirmedCreateObject:
+		serviceName = "CreateObject"
+	case BACnetServiceConfirmedDeleteObject:
+		serviceName = "DeleteObject"
+	case BACnetServiceConfirmedCOVNotification:
+		serviceName = "ConfirmedCOVNotification"
+	default:
+		serviceName = fmt.Sprintf("未知服务(0x%02x)", *a.ServiceChoice)
+	}
+	return serviceName
+}
@@ -0,0 +1,128 @@
+package protocol
+
+import (
+	"time"
+
+	"github.com/iotzf/bacnet-server/encoding"
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// 审计通知服务的服务选择
+const (
+	BACnetServiceConfirmedAuditNotification   = 0x20
+	BACnetServiceUnconfirmedAuditNotification = 0x0c
+)
+
+// audit 记录一个可审计的操作：追加到设备中的所有审计日志对象，配置了审计记录设备时转发审计通知。
+// ctx为nil时为本地操作（控制台、仪表盘等），来源设备为本设备，经B/IP以外的数据链路收到的请求也记为本设备；
+// err不为nil时记录操作失败的错误
+func (s *BACnetServer) audit(ctx *RequestContext, notification model.AuditNotification, err error) {
+	if s.device == nil {
+		return
+	}
+	notification.TargetDevice = s.device.GetObjectIdentifier()
+	if ctx != nil && ctx.ReplyAddr != nil {
+		notification.SourceDevice = model.Recipient{Address: &model.Address{MAC: bipMAC(ctx.ReplyAddr)}}
+	} else {
+		notification.SourceDevice = model.Recipient{Device: &notification.TargetDevice}
+	}
+	if err != nil {
+		class, code := writeErrorCode(err)
+		notification.Result = &model.AuditFailure{Class: uint16(class), Code: uint16(code)}
+	}
+
+	now := s.now()
+	for _, obj := range s.device.Objects() {
+		if auditLog, ok := obj.(*model.AuditLog); ok {
+			auditLog.LogAudit(now, notification)
+		}
+	}
+	if s.auditLogger != nil {
+		s.forwardAudit(*s.auditLogger, now, notification)
+	}
+}
+
+// auditWrite 记录一次属性写入，可命令属性记录写入的优先级
+func (s *BACnetServer) auditWrite(ctx *RequestContext, obj model.Object, prop model.PropertyIdentifier, value interface{}, priority uint8, err error) {
+	target := obj.GetObjectIdentifier()
+	notification := model.AuditNotification{Operation: model.AuditOperationWrite, TargetObject: &target, TargetProperty: &prop, TargetValue: value}
+	if c, ok := obj.(model.CommandableObject); ok && c.Commandable(prop) {
+		notification.TargetPriority = priority
+	}
+	s.audit(ctx, notification, err)
+}
+
+// auditFileError 将文件操作的错误转换为审计记录的file类access-denied错误
+func auditFileError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Class: ErrorClassFile, Code: ErrorCodeFileAccessDenied}
+}
+
+// forwardAudit 向审计记录设备发送审计通知，设备要求确认时等待应答。通知经接收者的发送队列按记录的顺序发出
+func (s *BACnetServer) forwardAudit(recipient model.Recipient, now time.Time, notification model.AuditNotification) {
+	parameters := encoding.EncodeConstructed(0, encodeAuditNotification(now, notification))
+	if !s.auditConfirmed {
+		apdu := append([]byte{BACnetAPDUTypeUnconfirmedServiceRequest << 4, BACnetServiceUnconfirmedAuditNotification}, parameters...)
+		s.sendToRecipient(recipient, apdu, "审计通知")
+		return
+	}
+	s.enqueueToRecipient(recipient, "审计通知", func() {
+		addr, target, err := s.resolveRecipient(recipient)
+		if err == nil {
+			var reply *APDU
+			if reply, err = s.sendRoutedRequest(addr, target, BACnetServiceConfirmedAuditNotification, parameters); err == nil {
+				err = reply.Err()
+			}
+		}
+		if err != nil {
+			s.Logger().Warn("确认审计通知失败", "recipient", recipientString(recipient), "error", err)
+		}
+	})
+}
+
+// encodeAuditNotification 编码BACnetAuditNotification，目标时间戳为记录时间
+//
+//	BACnetAuditNotification ::= SEQUENCE {
+//	  source-timestamp [0] BACnetTimeStamp OPTIONAL,
+//	  target-timestamp [1] BACnetTimeStamp OPTIONAL,
+//	  source-device    [2] BACnetRecipient,
+//	  source-object    [3] BACnetObjectIdentifier OPTIONAL,
+//	  operation        [4] BACnetAuditOperation,
+//	  source-comment   [5] CharacterString OPTIONAL,
+//	  target-comment   [6] CharacterString OPTIONAL,
+//	  invoke-id        [7] Unsigned8 OPTIONAL,
+//	  source-user-id   [8] Unsigned16 OPTIONAL,
+//	  source-user-role [9] Unsigned8 OPTIONAL,
+//	  target-device    [10] BACnetRecipient,
+//	  target-object    [11] BACnetObjectIdentifier OPTIONAL,
+//	  target-property  [12] BACnetPropertyReference OPTIONAL,
+//	  target-priority  [13] Unsigned (1..16) OPTIONAL,
+//	  target-value     [14] ABSTRACT-SYNTAX.&Type OPTIONAL,
+//	  current-value    [15] ABSTRACT-SYNTAX.&Type OPTIONAL,
+//	  result           [16] Error OPTIONAL }
+func encodeAuditNotification(timestamp time.Time, n model.AuditNotification) []byte {
+	out := encodeTimeStamp(1, timestamp)
+	out = append(out, encoding.EncodeConstructed(2, encoding.EncodeRecipient(n.SourceDevice))...)
+	out = append(out, encoding.EncodeContextEnumerated(4, uint32(n.Operation))...)
+	out = append(out, encoding.EncodeConstructed(10, encoding.EncodeRecipient(model.Recipient{Device: &n.TargetDevice}))...)
+	if n.TargetObject != nil {
+		out = append(out, encoding.EncodeContextObjectIdentifier(11, *n.TargetObject)...)
+	}
+	if n.TargetProperty != nil {
+		out = append(out, encoding.EncodeConstructed(12, encoding.EncodeContextEnumerated(0, uint32(*n.TargetProperty)))...)
+	}
+	if n.TargetPriority != 0 {
+		out = append(out, encoding.EncodeContextUnsigned(13, uint32(n.TargetPriority))...)
+	}
+	if n.TargetValue != nil {
+		out = append(out, encoding.EncodeConstructed(14, encodeBACnetValue(n.TargetValue))...)
+	}
+	if n.Result != nil {
+		result := encoding.EncodeEnumerated(uint32(n.Result.Class))
+		result = append(result, encoding.EncodeEnumerated(uint32(n.Result.Code))...)
+		out = append(out, encoding.EncodeConstructed(16, result)...)
+	}
+	return out
+}
@@ -0,0 +1,175 @@
+package protocol
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/iotzf/bacnet-server/encoding"
+)
+
+// bdtEntryLength BDT表项的编码长度：B/IP地址(6) + 广播分发掩码(4)
+const bdtEntryLength = 10
+
+// BDTEntry 广播分发表（BDT）表项：对端BBMD的B/IP地址和广播分发掩码
+type BDTEntry struct {
+	Address *net.UDPAddr
+	Mask    net.IPMask // 全1时广播直接发给对端BBMD（两跳分发），否则发往对端子网的定向广播地址
+}
+
+// ParseBDTEntry 解析"IP:端口[/掩码]"形式的BDT表项，掩码缺省为255.255.255.255
+func ParseBDTEntry(text string) (BDTEntry, error) {
+	host, mask, hasMask := strings.Cut(text, "/")
+	addr, err := net.ResolveUDPAddr("udp4", host)
+	if err != nil || addr.IP.To4() == nil {
+		return BDTEntry{}, fmt.Errorf("无效的BDT地址: %s", host)
+	}
+	entry := BDTEntry{Address: addr, Mask: net.CIDRMask(32, 32)}
+	if hasMask {
+		ip := net.ParseIP(mask).To4()
+		if ip == nil {
+			return BDTEntry{}, fmt.Errorf("无效的广播分发掩码: %s", mask)
+		}
+		entry.Mask = net.IPMask(ip)
+	}
+	return entry, nil
+}
+
+// String 返回"IP:端口/掩码"形式的表项
+func (e BDTEntry) String() string {
+	return fmt.Sprintf("%s/%s", e.Address, net.IP(e.Mask))
+}
+
+// forwardAddress 返回向该表项分发广播的目标地址：对端IP与掩码反码按位或
+func (e BDTEntry) forwardAddress() *net.UDPAddr {
+	ip := e.Address.IP.To4()
+	out := make(net.IP, net.IPv4len)
+	for i := range out {
+		out[i] = ip[i] | ^e.Mask[i]
+	}
+	return &net.UDPAddr{IP: out, Port: e.Address.Port}
+}
+
+// twoHop 掩码全1时对端收到转发后需要在其本地子网再广播
+func (e BDTEntry) twoHop() bool {
+	ones, bits := e.Mask.Size()
+	return ones == bits
+}
+
+// bvlcFrame 待发送的BVLC帧及其目标地址
+type bvlcFrame struct {
+	addr *net.UDPAddr
+	data []byte
+}
+
+// SetBroadcastDistributionTable 启用BBMD功能并设置广播分发表，表中应包含本设备自身
+func (s *BACnetServer) SetBroadcastDistributionTable(entries []BDTEntry) {
+	s.bdtMu.Lock()
+	defer s.bdtMu.Unlock()
+	s.bbmd = true
+	s.bdt = append([]BDTEntry(nil), entries...)
+}
+
+// BroadcastDistributionTable 返回当前广播分发表的副本
+func (s *BACnetServer) BroadcastDistributionTable() []BDTEntry {
+	s.bdtMu.Lock()
+	defer s.bdtMu.Unlock()
+	return append([]BDTEntry(nil), s.bdt...)
+}
+
+// handleWriteBDT 处理Write-Broadcast-Distribution-Table，以新表替换BDT
+func (s *BACnetServer) handleWriteBDT(r *encoding.Reader) []byte {
+	if !s.bbmd || r.Len()%bdtEntryLength != 0 {
+		return encodeBVLCResult(BVLCResultWriteBDTNAK)
+	}
+	var entries []BDTEntry
+	for r.Len() > 0 {
+		addr, _ := readBIPAddress(r)
+		mask, _ := r.Bytes(net.IPv4len)
+		entries = append(entries, BDTEntry{Address: addr, Mask: net.IPMask(append([]byte(nil), mask...))})
+	}
+	s.SetBroadcastDistributionTable(entries)
+	s.Logger().Info("BDT已更新", "entries", len(entries))
+	return encodeBVLCResult(BVLCResultSuccessfulCompletion)
+}
+
+// handleReadBDT 处理Read-Broadcast-Distribution-Table，以Read-BDT-Ack返回BDT
+func (s *BACnetServer) handleReadBDT() []byte {
+	if !s.bbmd {
+		return encodeBVLCResult(BVLCResultReadBDTNAK)
+	}
+	var payload []byte
+	for _, entry := range s.BroadcastDistributionTable() {
+		payload = append(payload, encodeBIPAddress(entry.Address)...)
+		payload = append(payload, entry.Mask...)
+	}
+	return encodeBVLC(BVLCReadBroadcastDistributionTableAck, payload)
+}
+
+// broadcastForwards 本地子网上的广播以Forwarded-NPDU分发给BDT中的其他BBMD和除origin外的外部设备
+func (s *BACnetServer) broadcastForwards(npdu []byte, origin *net.UDPAddr) []bvlcFrame {
+	if !s.bbmd || origin == nil {
+		return nil
+	}
+	message := encodeForwardedNPDU(origin, npdu)
+	var frames []bvlcFrame
+	for _, entry := range s.BroadcastDistributionTable() {
+		if s.isLocalBIPAddress(entry.Address) {
+			continue
+		}
+		frames = append(frames, bvlcFrame{addr: entry.forwardAddress(), data: message})
+	}
+	return append(frames, s.foreignDeviceForwards(message, origin)...)
+}
+
+// peerForwards 对端BBMD发来的Forwarded-NPDU原样发给外部设备，两跳分发时还要在本地子网广播
+func (s *BACnetServer) peerForwards(npdu []byte, origin, from *net.UDPAddr) []bvlcFrame {
+	if !s.bbmd || from == nil || s.isLocalBIPAddress(from) {
+		return nil
+	}
+	for _, entry := range s.BroadcastDistributionTable() {
+		if entry.Address.IP.Equal(from.IP) && entry.Address.Port == from.Port {
+			message := encodeForwardedNPDU(origin, npdu)
+			frames := s.foreignDeviceForwards(message, origin)
+			if entry.twoHop() { // 否则对端已发往本子网的定向广播
+				frames = append(frames, bvlcFrame{addr: s.broadcastAddr(), data: message})
+			}
+			return frames
+		}
+	}
+	return nil
+}
+
+// isLocalBIPAddress 判断地址是否为本设备的B/IP地址
+func (s *BACnetServer) isLocalBIPAddress(addr *net.UDPAddr) bool {
+	if addr.Port != s.broadcastAddr().Port {
+		return false
+	}
+	if s.transport != nil {
+		if local := udpAddr(s.transport.LocalAddr()); local != nil && local.IP != nil && !local.IP.IsUnspecified() {
+			return local.IP.Equal(addr.IP)
+		}
+	}
+	ifaceAddrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, a := range ifaceAddrs {
+		if ipNet, ok := a.(*net.IPNet); ok && ipNet.IP.Equal(addr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// sendFrames 发送BBMD转发的帧
+func (s *BACnetServer) sendFrames(frames []bvlcFrame) {
+	if s.transport == nil {
+		return
+	}
+	for _, frame := range frames {
+		if _, err := s.writeTo(frame.data, frame.addr); err != nil {
+			s.Logger().Warn("转发广播失败", "peer", frame.addr.String(), "error", err)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/iotzf/bacnet-server/encoding"
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// errDeviceNotFound 广播Who-Is后没有收到设备的I-Am
+var errDeviceNotFound = errors.New("设备没有应答Who-Is")
+
+// addressBinding 地址绑定表中的一项
+type addressBinding struct {
+	address model.Address // 设备的BACnet地址，网络号为0表示本地网络
+	via     net.Addr      // 发送的B/IP地址：本地网络上的设备为其地址，远程网络上的设备为转发I-Am的路由器
+}
+
+// bindingTable 设备地址绑定表（Device_Address_Binding），由收到的I-Am建立
+type bindingTable struct {
+	mu       sync.Mutex
+	bindings map[uint32]addressBinding
+	waiters  map[uint32][]chan struct{} // 等待设备I-Am的解析
+}
+
+// learn 记录设备的地址并唤醒等待该设备的解析
+func (t *bindingTable) learn(instance uint32, binding addressBinding) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.bindings == nil {
+		t.bindings = make(map[uint32]addressBinding)
+	}
+	t.bindings[instance] = binding
+	for _, waiter := range t.waiters[instance] {
+		close(waiter)
+	}
+	delete(t.waiters, instance)
+}
+
+// lookup 返回设备的地址绑定
+func (t *bindingTable) lookup(instance uint32) (addressBinding, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	binding, ok := t.bindings[instance]
+	return binding, ok
+}
+
+// wait 登记等待设备的I-Am，返回收到I-Am时关闭的通道和取消等待的函数
+func (t *bindingTable) wait(instance uint32) (<-chan struct{}, func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.waiters == nil {
+		t.waiters = make(map[uint32][]chan struct{})
+	}
+	waiter := make(chan struct{})
+	t.waiters[instance] = append(t.waiters[instance], waiter)
+	return waiter, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		waiters := t.waiters[instance]
+		for i, w := range waiters {
+			if w == waiter {
+				t.waiters[instance] = append(waiters[:i], waiters[i+1:]...)
+				break
+			}
+		}
+		if len(t.waiters[instance]) == 0 {
+			delete(t.waiters, instance)
+		}
+	}
+}
+
+// list 返回绑定表，按设备实例号排序
+func (t *bindingTable) list() []model.AddressBinding {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	list := make([]model.AddressBinding, 0, len(t.bindings))
+	for instance, binding := range t.bindings {
+		list = append(list, model.AddressBinding{
+			Device:  model.ObjectIdentifier{Type: model.ObjectTypeDevice, Instance: instance},
+			Address: binding.address,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Device.Instance < list[j].Device.Instance })
+	return list
+}
+
+// AddressBindings 返回从I-Am学到的设备地址绑定，按设备实例号排序，即Device_Address_Binding属性的值
+func (s *BACnetServer) AddressBindings() []model.AddressBinding {
+	return s.bindings.list()
+}
+
+// learnAddress 从收到的I-Am记录设备的地址：经路由器转发的I-Am记录源网络和MAC，
+// 发往该设备的请求交给转发的路由器。只记录经BACnet/IP收到的I-Am
+func (s *BACnetServer) learnAddress(ctx *RequestContext, npdu NPDU, data []byte) {
+	if ctx.ReplyAddr == nil || len(data) < 2 || data[0] != BACnetAPDUTypeUnconfirmedServiceRequest<<4 || data[1] != BACnetServiceUnconfirmedIAm {
+		return
+	}
+	iAm, err := DecodeIAm(data[2:])
+	if err != nil || iAm.ID.Type != model.ObjectTypeDevice || iAm.ID == s.device.GetObjectIdentifier() {
+		return
+	}
+	binding := addressBinding{address: model.Address{MAC: bipMAC(ctx.ReplyAddr)}, via: ctx.ReplyAddr}
+	if npdu.SourceNetwork != nil {
+		binding.address = model.Address{Network: *npdu.SourceNetwork, MAC: npdu.SourceMAC}
+	}
+	s.bindings.learn(iAm.ID.Instance, binding)
+	s.logPacket("记录设备地址", "device", iAm.ID.Instance, "network", binding.address.Network, "via", ctx.ReplyAddr)
+}
+
+// resolveDevice 返回设备的地址绑定，绑定表中没有时广播只询问该设备的Who-Is，
+// 按设备的APDU_Timeout等待I-Am并按Number_Of_APDU_Retries重发
+func (s *BACnetServer) resolveDevice(instance uint32) (addressBinding, error) {
+	if binding, ok := s.bindings.lookup(instance); ok {
+		return binding, nil
+	}
+	received, cancel := s.bindings.wait(instance)
+	defer cancel()
+	whoIs := append([]byte{0x01, 0x00}, EncodeWhoIs(instance, instance)...)
+	done := s.Done()
+	timeout, retries := s.apduRetries()
+	for attempt := 0; attempt <= retries; attempt++ {
+		if _, err := s.broadcastNPDU(whoIs); err != nil {
+			return addressBinding{}, fmt.Errorf("广播Who-Is失败: %v", err)
+		}
+		select {
+		case <-received:
+			binding, _ := s.bindings.lookup(instance)
+			return binding, nil
+		case <-done:
+			return addressBinding{}, errServerStopped
+		case <-time.After(timeout):
+		}
+	}
+	return addressBinding{}, fmt.Errorf("%w: %d", errDeviceNotFound, instance)
+}
+
+// resolveRecipient 返回发往通知接收者的B/IP地址，接收者在远程网络上时dest为NPDU的目标网络和MAC。
+// 设备按地址绑定表解析，表中没有时用Who-Is查找；远程网络上的地址在本地广播，由路由器转发
+func (s *BACnetServer) resolveRecipient(recipient model.Recipient) (addr net.Addr, dest *model.Address, err error) {
+	switch {
+	case recipient.Device != nil:
+		binding, err := s.resolveDevice(recipient.Device.Instance)
+		if err != nil {
+			return nil, nil, err
+		}
+		if binding.address.Network != 0 {
+			dest = &binding.address
+		}
+		return binding.via, dest, nil
+	case recipient.Address != nil:
+		address := recipient.Address
+		if address.Network != 0 {
+			return s.broadcastAddr(), address, nil
+		}
+		if len(address.MAC) == 0 {
+			return s.broadcastAddr(), nil, nil
+		}
+		udp, err := readBIPAddress(encoding.NewReader(address.MAC))
+		if err != nil {
+			return nil, nil, fmt.Errorf("接收者地址: %w", err)
+		}
+		return udp, nil, nil
+	}
+	return nil, nil, errors.New("接收者没有设备或地址")
+}
+
+// sendToRecipient 在接收者的发送队列中解析接收者并发送未确认请求apdu，广播地址和远程网络上的地址在本地广播；
+// what为日志中请求的名称
+func (s *BACnetServer) sendToRecipient(recipient model.Recipient, apdu []byte, what string) {
+	s.enqueueToRecipient(recipient, what, func() {
+		addr, target, err := s.resolveRecipient(recipient)
+		if err != nil {
+			s.Logger().Warn(what+"的接收者无法解析", "recipient", recipientString(recipient), "error", err)
+			return
+		}
+		function := byte(BVLCOriginalUnicastNPDU)
+		if address := recipient.Address; address != nil && (address.Network != 0 || len(address.MAC) == 0) {
+			function = BVLCOriginalBroadcastNPDU
+		}
+		if _, err := s.writeTo(encodeBVLC(function, append(destinationNPDU(target, false).Encode(), apdu...)), addr); err != nil {
+			s.Logger().Warn("发送"+what+"失败", "recipient", recipientString(recipient), "error", err)
+		}
+	})
+}
+
+// destinationNPDU 返回发往dest的NPDU头部，dest为nil时发往本地网络
+func destinationNPDU(dest *model.Address, expectingReply bool) NPDU {
+	npdu := NPDU{Version: 0x01, Control: ControlInfo{ExpectingReply: expectingReply}}
+	if dest != nil {
+		network := dest.Network
+		npdu.DestinationNetwork = &network
+		npdu.DestinationMAC = dest.MAC
+	}
+	return npdu
+}
@@ -0,0 +1,131 @@
+package protocol
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/iotzf/bacnet-server/encoding"
+)
+
+// BVLC类型和功能码（BACnet/IP，Annex J）
+const (
+	BVLCTypeBACnetIP                      = 0x81
+	BVLCResult                            = 0x00
+	BVLCWriteBroadcastDistributionTable   = 0x01
+	BVLCReadBroadcastDistributionTable    = 0x02
+	BVLCReadBroadcastDistributionTableAck = 0x03
+	BVLCForwardedNPDU                     = 0x04
+	BVLCRegisterForeignDevice             = 0x05
+	BVLCReadForeignDeviceTable            = 0x06
+	BVLCReadForeignDeviceTableAck         = 0x07
+	BVLCDeleteForeignDeviceTableEntry     = 0x08
+	BVLCDistributeBroadcastToNetwork      = 0x09
+	BVLCOriginalUnicastNPDU               = 0x0a
+	BVLCOriginalBroadcastNPDU             = 0x0b
+	BVLCSecureBVLL                        = 0x0c
+	bvlcHeaderLength                      = 4
+	bipAddressLength                      = 6 // B/IP地址：IPv4地址(4) + UDP端口(2)
+)
+
+// BVLC-Result结果码（Annex J.2.1）
+const (
+	BVLCResultSuccessfulCompletion        uint16 = 0x0000
+	BVLCResultWriteBDTNAK                 uint16 = 0x0010
+	BVLCResultReadBDTNAK                  uint16 = 0x0020
+	BVLCResultRegisterForeignDeviceNAK    uint16 = 0x0030
+	BVLCResultReadFDTNAK                  uint16 = 0x0040
+	BVLCResultDeleteFDTEntryNAK           uint16 = 0x0050
+	BVLCResultDistributeBroadcastToNetNAK uint16 = 0x0060
+)
+
+// bvlcResultNames BVLC-Result结果码名称
+var bvlcResultNames = map[uint16]string{
+	BVLCResultSuccessfulCompletion:        "成功",
+	BVLCResultWriteBDTNAK:                 "Write-BDT NAK",
+	BVLCResultReadBDTNAK:                  "Read-BDT NAK",
+	BVLCResultRegisterForeignDeviceNAK:    "Register-Foreign-Device NAK",
+	BVLCResultReadFDTNAK:                  "Read-FDT NAK",
+	BVLCResultDeleteFDTEntryNAK:           "Delete-FDT-Entry NAK",
+	BVLCResultDistributeBroadcastToNetNAK: "Distribute-Broadcast-To-Network NAK",
+}
+
+// bvlcResultName 返回BVLC-Result结果码的可读名称
+func bvlcResultName(code uint16) string {
+	if name, ok := bvlcResultNames[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("未知结果码(0x%04x)", code)
+}
+
+// encodeBVLC 为NPDU数据添加BVLC头部
+func encodeBVLC(function byte, payload []byte) []byte {
+	length := len(payload) + bvlcHeaderLength
+	out := make([]byte, 0, length)
+	out = append(out, BVLCTypeBACnetIP, function, byte(length>>8), byte(length))
+	return append(out, payload...)
+}
+
+// encodeBVLCResult 编码BVLC-Result
+func encodeBVLCResult(code uint16) []byte {
+	return encodeBVLC(BVLCResult, []byte{byte(code >> 8), byte(code)})
+}
+
+// readBIPAddress 读取6字节的B/IP地址：IPv4地址(4) + UDP端口(2)
+func readBIPAddress(r *encoding.Reader) (*net.UDPAddr, error) {
+	ip, err := r.Bytes(4)
+	if err != nil {
+		return nil, fmt.Errorf("B/IP地址: %w", err)
+	}
+	port, err := r.Uint16()
+	if err != nil {
+		return nil, fmt.Errorf("B/IP端口: %w", err)
+	}
+	return &net.UDPAddr{IP: net.IPv4(ip[0], ip[1], ip[2], ip[3]), Port: int(port)}, nil
+}
+
+// encodeBIPAddress 编码6字节的B/IP地址
+func encodeBIPAddress(addr *net.UDPAddr) []byte {
+	ip := addr.IP.To4()
+	if ip == nil {
+		ip = net.IPv4zero.To4()
+	}
+	return append(append([]byte(nil), ip...), byte(addr.Port>>8), byte(addr.Port))
+}
+
+// encodeForwardedNPDU 编码携带原始发送方地址的Forwarded-NPDU
+func encodeForwardedNPDU(origin *net.UDPAddr, npdu []byte) []byte {
+	return encodeBVLC(BVLCForwardedNPDU, append(encodeBIPAddress(origin), npdu...))
+}
+
+// frameResponse 为应用层应答添加NPDU和BVLC头部；已是完整BVLC帧的应答（如I-Am）原样返回。
+// APDU类型只有0-7，首字节为BVLC类型0x81的数据不可能是APDU
+func frameResponse(response []byte) []byte {
+	if len(response) == 0 || response[0] == BVLCTypeBACnetIP {
+		return response
+	}
+	return encodeBVLC(BVLCOriginalUnicastNPDU, append([]byte{0x01, 0x00}, response...))
+}
+
+// frameReply 为对request的应用层应答添加NPDU和BVLC头部，NPDU由replyNPDU构造；
+// 已是完整帧的单播应答在请求经路由器转发时换成带目标网络的NPDU
+func frameReply(request NPDU, response []byte) []byte {
+	if len(response) == 0 {
+		return response
+	}
+	if response[0] == BVLCTypeBACnetIP {
+		if request.SourceNetwork == nil || len(response) < bvlcHeaderLength || response[1] != BVLCOriginalUnicastNPDU {
+			return response
+		}
+		_, offset, err := ParseNPDU(response[bvlcHeaderLength:])
+		if err != nil {
+			return response
+		}
+		response = response[bvlcHeaderLength+offset:]
+	}
+	// BVLC头部、NPDU和应答在一次分配中编码，NPDU通常不超过16字节
+	out := make([]byte, bvlcHeaderLength, bvlcHeaderLength+16+len(response))
+	out = replyNPDU(request).appendTo(out)
+	out = append(out, response...)
+	out[0], out[1], out[2], out[3] = BVLCTypeBACnetIP, BVLCOriginalUnicastNPDU, byte(len(out)>>8), byte(len(out))
+	return out
+}
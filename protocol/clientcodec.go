@@ -0,0 +1,521 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/iotzf/bacnet-server/encoding"
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// 客户端使用的报文编解码，TestClient和client包共用，格式与服务端的解析一致
+
+// EncodeDatagram 为APDU加上NPDU和BVLC头部，broadcast为true时使用Original-Broadcast-NPDU，
+// 确认请求置位期望应答
+func EncodeDatagram(apdu []byte, broadcast bool) []byte {
+	function := byte(BVLCOriginalUnicastNPDU)
+	if broadcast {
+		function = BVLCOriginalBroadcastNPDU
+	}
+	control := byte(0x00)
+	if apdu[0]>>4 == BACnetAPDUTypeConfirmedServiceRequest {
+		control = 0x04 // 期望应答
+	}
+	return encodeBVLC(function, append([]byte{0x01, control}, apdu...))
+}
+
+// DecodeDatagram 去掉BVLC和NPDU头部并解析APDU，网络层消息返回错误。
+// APDU不引用data，接收缓冲区可以立即重用
+func DecodeDatagram(data []byte) (*APDU, error) {
+	r := encoding.NewReader(append([]byte(nil), data...))
+	header, err := r.Bytes(bvlcHeaderLength)
+	if err != nil || header[0] != BVLCTypeBACnetIP {
+		return nil, errors.New("不是BACnet/IP数据报")
+	}
+	if header[1] == BVLCForwardedNPDU {
+		if _, err := readBIPAddress(r); err != nil {
+			return nil, err
+		}
+	}
+	npdu, offset, err := ParseNPDU(r.Remaining())
+	if err != nil {
+		return nil, err
+	}
+	if npdu.Control.NetworkMessageFlag {
+		return nil, errors.New("网络层消息")
+	}
+	return ParseAPDU(r.Remaining()[offset:])
+}
+
+// EncodeConfirmedRequest 编码确认请求的APDU，不分段，接受1476字节的应答
+func EncodeConfirmedRequest(invokeID, service byte, payload []byte) []byte {
+	return append([]byte{BACnetAPDUTypeConfirmedServiceRequest << 4, 0x05, invokeID, service}, payload...)
+}
+
+// EncodeSimpleAck 编码对确认请求的SimpleAck
+func EncodeSimpleAck(invokeID, service byte) []byte {
+	return []byte{BACnetAPDUTypeSimpleAck << 4, invokeID, service}
+}
+
+// Err 返回应答表示的错误：Error应答为*Error，Reject和Abort带原因，确认应答为nil
+func (a *APDU) Err() error {
+	switch a.PDUType {
+	case BACnetAPDUTypeSimpleAck, BACnetAPDUTypeComplexAck:
+		return nil
+	case BACnetAPDUTypeError:
+		// 错误应答以*Error返回，可以按类别和代码判断
+		if class, code, err := decodeErrorPayload(a.Payload); err == nil {
+			return &Error{Class: byte(class), Code: byte(code)}
+		}
+	case BACnetAPDUTypeReject:
+		if len(a.Payload) > 0 {
+			return fmt.Errorf("请求被拒绝: %s", rejectReasonName(a.Payload[0]))
+		}
+	case BACnetAPDUTypeAbort:
+		if len(a.Payload) > 0 {
+			return fmt.Errorf("请求被放弃: %s", abortReasonName(a.Payload[0]))
+		}
+	}
+	return fmt.Errorf("请求失败: %s", a.String())
+}
+
+// EncodeWhoIs 编码Who-Is，high不为0时只询问实例号在low到high之间的设备
+func EncodeWhoIs(low, high uint32) []byte {
+	apdu := []byte{BACnetAPDUTypeUnconfirmedServiceRequest << 4, BACnetServiceUnconfirmedWhoIs}
+	if high != 0 {
+		apdu = append(apdu, encoding.EncodeContextUnsigned(0, low)...)
+		apdu = append(apdu, encoding.EncodeContextUnsigned(1, high)...)
+	}
+	return apdu
+}
+
+// DecodeIAm 解码I-Am的四个应用标签参数，Address由调用方填写
+func DecodeIAm(data []byte) (DiscoveredDevice, error) {
+	var device DiscoveredDevice
+	d := encoding.NewDecoder(data)
+	value, err := d.Application()
+	if err != nil {
+		return device, err
+	}
+	oid, ok := value.(model.ObjectIdentifier)
+	if !ok {
+		return device, fmt.Errorf("I-Am设备标识符类型错误: %T", value)
+	}
+	device.ID = oid
+	if device.MaxAPDU, err = d.ApplicationUnsigned(); err != nil {
+		return device, err
+	}
+	segmentation, err := d.Application()
+	if err != nil {
+		return device, err
+	}
+	if enum, ok := segmentation.(encoding.Enumerated); ok {
+		device.Segmentation = uint32(enum)
+	}
+	device.VendorID, err = d.ApplicationUnsigned()
+	return device, err
+}
+
+// EncodeReadPropertyRequest 编码ReadProperty请求参数，index为nil时读取整个属性
+func EncodeReadPropertyRequest(oid model.ObjectIdentifier, prop model.PropertyIdentifier, index *uint32) []byte {
+	data := encoding.EncodeContextObjectIdentifier(0, oid)
+	data = append(data, encoding.EncodeContextEnumerated(1, uint32(prop))...)
+	if index != nil {
+		data = append(data, encoding.EncodeContextUnsigned(2, *index)...)
+	}
+	return data
+}
+
+// DecodeReadPropertyAck 解码ReadProperty应答中的属性值，值为数组或列表时返回[]interface{}
+func DecodeReadPropertyAck(data []byte) (interface{}, error) {
+	d := encoding.NewDecoder(data)
+	if _, err := d.ContextObjectIdentifier(0); err != nil {
+		return nil, err
+	}
+	if _, err := d.ContextEnumerated(1); err != nil {
+		return nil, err
+	}
+	if d.IsContext(2) {
+		if _, err := d.ContextUnsigned(2); err != nil {
+			return nil, err
+		}
+	}
+	value, err := d.Constructed(3)
+	if err != nil {
+		return nil, err
+	}
+	return decodePropertyValue(value)
+}
+
+// decodePropertyValue 解码开始和结束标签之间的应用标签值，多个值时返回[]interface{}
+func decodePropertyValue(value []byte) (interface{}, error) {
+	var values []interface{}
+	for len(value) > 0 {
+		decoded, n, err := encoding.DecodeApplication(value)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, decoded)
+		value = value[n:]
+	}
+	if len(values) == 1 {
+		return values[0], nil
+	}
+	return values, nil
+}
+
+// PropertyResult ReadPropertyMultiple应答中一个属性的结果
+type PropertyResult struct {
+	ObjectID   model.ObjectIdentifier
+	PropertyID model.PropertyIdentifier
+	ArrayIndex *uint32
+	Value      interface{} // 值为数组或列表时为[]interface{}
+	Err        error       // 读取该属性的错误，为*Error
+}
+
+// EncodeReadPropertyMultipleRequest 编码ReadPropertyMultiple请求参数
+func EncodeReadPropertyMultipleRequest(specs []ReadAccessSpecification) []byte {
+	w := encoding.GetWriter()
+	defer encoding.PutWriter(w)
+	for _, spec := range specs {
+		w.ContextObjectIdentifier(0, spec.ObjectID)
+		w.OpeningTag(1)
+		for _, ref := range spec.Properties {
+			w.ContextEnumerated(0, uint32(ref.PropertyID))
+			if ref.ArrayIndex != nil {
+				w.ContextUnsigned(1, *ref.ArrayIndex)
+			}
+		}
+		w.ClosingTag(1)
+	}
+	return append([]byte(nil), w.Bytes()...)
+}
+
+// DecodeReadPropertyMultipleAck 按应答的顺序返回每个属性的结果
+func DecodeReadPropertyMultipleAck(data []byte) ([]PropertyResult, error) {
+	var results []PropertyResult
+	d := encoding.NewDecoder(data)
+	for !d.Done() {
+		oid, err := d.ContextObjectIdentifier(0)
+		if err != nil {
+			return nil, err
+		}
+		if err := d.Opening(1); err != nil {
+			return nil, err
+		}
+		for !d.IsClosing(1) {
+			result := PropertyResult{ObjectID: oid}
+			prop, err := d.ContextEnumerated(2)
+			if err != nil {
+				return nil, err
+			}
+			result.PropertyID = model.PropertyIdentifier(prop)
+			if d.IsContext(3) {
+				index, err := d.ContextUnsigned(3)
+				if err != nil {
+					return nil, err
+				}
+				result.ArrayIndex = &index
+			}
+			if d.IsOpening(5) {
+				raw, err := d.Constructed(5)
+				if err != nil {
+					return nil, err
+				}
+				class, code, err := decodeErrorPayload(raw)
+				if err != nil {
+					return nil, err
+				}
+				result.Err = &Error{Class: byte(class), Code: byte(code)}
+			} else {
+				raw, err := d.Constructed(4)
+				if err != nil {
+					return nil, err
+				}
+				if result.Value, err = decodePropertyValue(raw); err != nil {
+					return nil, err
+				}
+			}
+			results = append(results, result)
+		}
+		if err := d.Closing(1); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// EncodeWritePropertyRequest 编码WriteProperty请求参数，value按EncodeApplication编码（nil写入NULL以释放命令），
+// priority为0时不携带优先级
+func EncodeWritePropertyRequest(oid model.ObjectIdentifier, prop model.PropertyIdentifier, value interface{}, priority uint8) ([]byte, error) {
+	encoded, err := encoding.EncodeApplication(value)
+	if err != nil {
+		return nil, err
+	}
+	return encoding.Marshal(WritePropertyRequest{ObjectID: oid, PropertyID: prop, Value: encoded, Priority: priority})
+}
+
+// EncodeSubscribeCOVRequest 编码SubscribeCOV请求参数，确认通知标志和有效期都为空时为取消订阅
+func EncodeSubscribeCOVRequest(r SubscribeCOVRequest) ([]byte, error) {
+	return encoding.Marshal(r)
+}
+
+// EncodeSubscribeCOVPropertyRequest 编码SubscribeCOVProperty请求参数，取消订阅同EncodeSubscribeCOVRequest
+func EncodeSubscribeCOVPropertyRequest(r SubscribeCOVPropertyRequest) ([]byte, error) {
+	return encoding.Marshal(r)
+}
+
+// Value 解码通知中属性的值，通知不包含该属性时ok为false
+func (n COVNotification) Value(prop model.PropertyIdentifier) (value interface{}, ok bool, err error) {
+	for _, v := range n.Values {
+		if v.PropertyID == prop {
+			value, _, err = encoding.DecodeApplication(v.Value)
+			return value, true, err
+		}
+	}
+	return nil, false, nil
+}
+
+// ParseCOVNotification 解码COV通知的参数，其他请求返回错误
+func ParseCOVNotification(apdu *APDU) (COVNotification, error) {
+	var n COVNotification
+	if apdu.ServiceChoice == nil {
+		return n, errors.New("不是COV通知")
+	}
+	unconfirmed := apdu.PDUType == BACnetAPDUTypeUnconfirmedServiceRequest && *apdu.ServiceChoice == BACnetServiceUnconfirmedCOVNotification
+	confirmed := apdu.PDUType == BACnetAPDUTypeConfirmedServiceRequest && *apdu.ServiceChoice == BACnetServiceConfirmedCOVNotification
+	if !unconfirmed && !confirmed {
+		return n, errors.New("不是COV通知")
+	}
+	err := encoding.Unmarshal(apdu.Payload, &n)
+	return n, err
+}
+
+// EncodeReadRangeRequest 编码ReadRange请求参数，RangeType为ReadRangeAll时不带范围
+func EncodeReadRangeRequest(r ReadRangeRequest) []byte {
+	out := encoding.EncodeContextObjectIdentifier(0, r.ObjectID)
+	out = append(out, encoding.EncodeContextUnsigned(1, uint32(r.PropertyID))...)
+	if r.ArrayIndex != nil {
+		out = append(out, encoding.EncodeContextUnsigned(2, *r.ArrayIndex)...)
+	}
+	var reference []byte
+	switch r.RangeType {
+	case ReadRangeByPosition, ReadRangeBySequenceNumber:
+		reference = encoding.EncodeUnsigned(r.Reference)
+	case ReadRangeByTime:
+		reference = append(encoding.EncodeDate(encoding.NewDate(r.ReferenceTime)), encoding.EncodeTime(encoding.NewTime(r.ReferenceTime))...)
+	default:
+		return out
+	}
+	return append(out, encoding.EncodeConstructed(uint8(r.RangeType), append(reference, encoding.EncodeSigned(r.Count)...))...)
+}
+
+// ReadRangeResult ReadRange应答
+type ReadRangeResult struct {
+	FirstItem bool // 结果包含缓冲区的第一条记录
+	LastItem  bool // 结果包含缓冲区的最后一条记录
+	MoreItems bool // 范围内还有未返回的记录
+	Records   []model.LogRecord
+	// FirstSequenceNumber 第一条记录的序列号，按序列号或时间读取时才有，之后的记录依次加1
+	FirstSequenceNumber *uint32
+}
+
+// DecodeReadRangeAck 解码ReadRange应答。记录的序列号按FirstSequenceNumber依次填写，没有时为0；
+// multiple为true时按多对象趋势日志的记录解码log-data
+func DecodeReadRangeAck(data []byte, multiple bool) (ReadRangeResult, error) {
+	var result ReadRangeResult
+	d := encoding.NewDecoder(data)
+	if _, err := d.ContextObjectIdentifier(0); err != nil {
+		return result, err
+	}
+	if _, err := d.ContextUnsigned(1); err != nil {
+		return result, err
+	}
+	if d.IsContext(2) {
+		if _, err := d.ContextUnsigned(2); err != nil {
+			return result, err
+		}
+	}
+	flags, err := d.ContextBitString(3)
+	if err != nil {
+		return result, err
+	}
+	result.FirstItem, result.LastItem, result.MoreItems = flags.Bit(0), flags.Bit(1), flags.Bit(2)
+	count, err := d.ContextUnsigned(4)
+	if err != nil {
+		return result, err
+	}
+	items, err := d.Constructed(5)
+	if err != nil {
+		return result, err
+	}
+	if d.IsContext(6) {
+		first, err := d.ContextUnsigned(6)
+		if err != nil {
+			return result, err
+		}
+		result.FirstSequenceNumber = &first
+	}
+	records := encoding.NewDecoder(items)
+	for !records.Done() {
+		record, err := decodeLogRecord(records, multiple)
+		if err != nil {
+			return result, fmt.Errorf("记录%d: %w", len(result.Records)+1, err)
+		}
+		if result.FirstSequenceNumber != nil {
+			record.SequenceNumber = *result.FirstSequenceNumber + uint32(len(result.Records))
+		}
+		result.Records = append(result.Records, record)
+	}
+	if len(result.Records) != int(count) {
+		return result, fmt.Errorf("记录数%d与itemCount %d不符", len(result.Records), count)
+	}
+	return result, nil
+}
+
+// decodeLogRecord 解码一条BACnetLogRecord或BACnetLogMultipleRecord，格式见encodeLogRecord。
+// 事件日志的通知记录以未解码的[]byte返回
+func decodeLogRecord(d *encoding.Decoder, multiple bool) (model.LogRecord, error) {
+	var record model.LogRecord
+	if err := d.Opening(0); err != nil {
+		return record, err
+	}
+	timestamp, err := d.ApplicationDateTime()
+	if err != nil {
+		return record, err
+	}
+	record.Timestamp = timestamp
+	if err := d.Closing(0); err != nil {
+		return record, err
+	}
+	if err := d.Opening(1); err != nil {
+		return record, err
+	}
+	switch {
+	case d.IsContext(0):
+		status, err := d.ContextBitString(0)
+		if err != nil {
+			return record, err
+		}
+		var bits model.LogStatus
+		for i := 0; i < 3; i++ {
+			if status.Bit(i) {
+				bits |= 1 << i
+			}
+		}
+		record.Datum = bits
+	case d.IsOpening(1) && multiple:
+		d.Opening(1)
+		var values model.LogMultipleDatum
+		for !d.IsClosing(1) {
+			value, err := decodeLogDatumValue(d, 1)
+			if err != nil {
+				return record, err
+			}
+			values = append(values, value)
+		}
+		d.Closing(1)
+		record.Datum = values
+	case d.IsOpening(1):
+		notification, err := d.Constructed(1)
+		if err != nil {
+			return record, err
+		}
+		record.Datum = append([]byte(nil), notification...)
+	default:
+		if record.Datum, err = decodeLogDatumValue(d, 0); err != nil {
+			return record, err
+		}
+	}
+	if err := d.Closing(1); err != nil {
+		return record, err
+	}
+	if d.IsContext(2) {
+		bits, err := d.ContextBitString(2)
+		if err != nil {
+			return record, err
+		}
+		var flags uint8
+		for i := 0; i < 4; i++ {
+			if bits.Bit(i) {
+				flags |= 1 << i
+			}
+		}
+		record.StatusFlags = &flags
+	}
+	return record, nil
+}
+
+// decodeLogDatumValue 解码一个日志值选项，多对象趋势日志的选项编号比单对象趋势日志小offset，
+// 对应encodeLogDatumValue。failure解码为model.LogFailure，any-value解码为应用标签值
+func decodeLogDatumValue(d *encoding.Decoder, offset uint8) (interface{}, error) {
+	t, _, err := d.Peek()
+	if err != nil {
+		return nil, err
+	}
+	if !t.Context {
+		return nil, fmt.Errorf("期望上下文标签")
+	}
+	if t.Opening {
+		content, err := d.Constructed(t.Number)
+		if err != nil {
+			return nil, err
+		}
+		switch t.Number {
+		case 8 - offset:
+			class, code, err := decodeErrorPayload(content)
+			if err != nil {
+				return nil, err
+			}
+			return model.LogFailure{Err: &Error{Class: byte(class), Code: byte(code)}}, nil
+		case 10 - 2*offset:
+			return decodePropertyValue(content)
+		}
+		return nil, fmt.Errorf("未知的日志值选项%d", t.Number)
+	}
+	value, err := d.ContextValue(t.Number)
+	if err != nil {
+		return nil, err
+	}
+	switch t.Number + offset {
+	case 1:
+		if t.Length != 1 || len(value) != 1 {
+			return nil, fmt.Errorf("布尔值无效")
+		}
+		return value[0] != 0, nil
+	case 2:
+		if len(value) != 4 {
+			return nil, fmt.Errorf("REAL长度无效: %d", len(value))
+		}
+		return math.Float32frombits(encoding.DecodeUnsignedBytes(value)), nil
+	case 3, 4:
+		if len(value) == 0 || len(value) > 4 {
+			return nil, fmt.Errorf("无符号值长度无效: %d", len(value))
+		}
+		return encoding.DecodeUnsignedBytes(value), nil
+	case 5:
+		if len(value) == 0 || len(value) > 4 {
+			return nil, fmt.Errorf("有符号值长度无效: %d", len(value))
+		}
+		return encoding.DecodeSignedBytes(value), nil
+	case 7:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("未知的日志值选项%d", t.Number)
+}
+
+// EncodePrivateTransferRequest 编码ConfirmedPrivateTransfer或UnconfirmedPrivateTransfer的请求，
+// parameters为编码后的服务参数，为nil时不带serviceParameters
+func EncodePrivateTransferRequest(vendorID, serviceNumber uint32, parameters []byte) []byte {
+	return encodePrivateTransfer(vendorID, serviceNumber, parameters)
+}
+
+// DecodePrivateTransferAck 解码ConfirmedPrivateTransfer-ACK，result为resultBlock的编码内容，没有时为nil
+func DecodePrivateTransferAck(data []byte) (vendorID, serviceNumber uint32, result []byte, err error) {
+	ack, err := parsePrivateTransferRequest(data)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("ConfirmedPrivateTransfer-ACK: %w", err)
+	}
+	return ack.VendorID, ack.ServiceNumber, ack.Parameters, nil
+}
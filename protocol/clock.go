@@ -0,0 +1,32 @@
+package protocol
+
+import (
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// SetClock 设置服务端和设备的时钟，为nil时使用model.SystemClock。应在Start之前调用。
+// 设备中的对象同样使用该时钟记录趋势、事件时间戳和订阅有效期
+func (s *BACnetServer) SetClock(clock model.Clock) {
+	s.clock = clock
+	if s.device != nil {
+		s.device.SetClock(clock)
+	}
+}
+
+// Clock 返回服务端使用的时钟，没有设置时为设备的时钟
+func (s *BACnetServer) Clock() model.Clock {
+	if s.clock != nil {
+		return s.clock
+	}
+	if s.device != nil {
+		return s.device.Clock()
+	}
+	return model.SystemClock
+}
+
+// now 返回服务端时钟的当前时间
+func (s *BACnetServer) now() time.Time {
+	return s.Clock().Now()
+}
@@ -0,0 +1,523 @@
+package protocol
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+	"github.com/iotzf/bacnet-server/simulation"
+)
+
+// consoleCommand 控制台命令
+type consoleCommand struct {
+	usage string
+	help  string
+	run   func(s *BACnetServer, w io.Writer, args []string) error
+}
+
+// errConsoleQuit 结束控制台会话
+var errConsoleQuit = errors.New("quit")
+
+// consoleCommands 控制台命令表，对象写作analog-input:1、对象名称或device
+var consoleCommands map[string]consoleCommand
+
+func init() {
+	consoleCommands = map[string]consoleCommand{
+		"help":          {"help", "list the commands", consoleHelp},
+		"objects":       {"objects [type]", "list the objects, optionally of one type", consoleObjects},
+		"show":          {"show <object>", "show all properties of an object", consoleShow},
+		"set":           {"set <object> <property> <value> [priority]", "write a property like a BACnet client, value null relinquishes", consoleSet},
+		"drive":         {"drive <object> <value>", "update Present_Value as the field would", consoleDrive},
+		"alarm":         {"alarm <object> <event-state> [message]", "raise an event (offnormal, fault, high-limit, low-limit)", consoleAlarm},
+		"clear":         {"clear <object> [message]", "return the object to normal", consoleClear},
+		"subscriptions": {"subscriptions", "list the COV subscriptions", consoleSubscriptions},
+		"expire":        {"expire <object> [process-id]", "drop COV subscriptions of an object", consoleExpire},
+		"comms":         {"comms [down|up]", "show or change the communication state, down drops every datagram in and out", consoleComms},
+		"sim":           {"sim [<object> pause|resume|stop|name=value...]", "list simulated points or change a profile (kind, min, max, offset, amplitude, period, noise, step, file, interval)", consoleSimulation},
+		"rules":         {"rules", "list the simulation rules and their last result", consoleRules},
+		"transactions":  {"transactions", "list the confirmed requests waiting for a reply", consoleTransactions},
+		"quit":          {"quit", "end the session", func(*BACnetServer, io.Writer, []string) error { return errConsoleQuit }},
+	}
+}
+
+// ServeConsole 在r和w上运行交互式控制台，逐行执行命令直到quit或r结束。
+// 经控制台的写入与网络写入一样经过写保护、钩子和校验
+func (s *BACnetServer) ServeConsole(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	fmt.Fprint(w, "> ")
+	for scanner.Scan() {
+		args := splitConsoleLine(scanner.Text())
+		if len(args) > 0 {
+			name := args[0]
+			if name == "exit" {
+				name = "quit"
+			}
+			command, ok := consoleCommands[name]
+			if !ok {
+				fmt.Fprintf(w, "unknown command %q, try help\n", args[0])
+			} else if err := s.runConsoleCommand(command, w, args[1:]); err == errConsoleQuit {
+				return nil
+			} else if err != nil {
+				fmt.Fprintf(w, "error: %v\n", err)
+			}
+		}
+		fmt.Fprint(w, "> ")
+	}
+	return scanner.Err()
+}
+
+// runConsoleCommand 持有设备锁执行控制台命令，输出在释放锁后写入w，慢的控制台连接不会阻塞请求处理
+func (s *BACnetServer) runConsoleCommand(command consoleCommand, w io.Writer, args []string) error {
+	var out bytes.Buffer
+	s.device.Lock()
+	err := command.run(s, &out, args)
+	s.device.Unlock()
+	if _, werr := out.WriteTo(w); werr != nil && err == nil {
+		err = werr
+	}
+	return err
+}
+
+// serveConsole 在addr上接受控制台连接，addr以unix:开头时为Unix套接字，否则为TCP地址，服务端关闭时停止
+func (s *BACnetServer) serveConsole(addr string) error {
+	network, address := "tcp", addr
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		network, address = "unix", path
+		os.Remove(path) // 上次运行留下的套接字文件
+	}
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return fmt.Errorf("监听控制台失败: %v", err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					s.Logger().Warn("控制台退出", "addr", addr, "error", err)
+				}
+				return
+			}
+			go func() {
+				defer conn.Close()
+				s.Logger().Info("控制台已连接", "peer", conn.RemoteAddr().String())
+				if err := s.ServeConsole(conn, conn); err != nil && !errors.Is(err, net.ErrClosed) {
+					s.Logger().Debug("控制台连接结束", "error", err)
+				}
+			}()
+		}
+	}()
+	go func() {
+		<-s.Done()
+		listener.Close()
+	}()
+	s.Logger().Info("控制台已启动", "addr", listener.Addr().String())
+	return nil
+}
+
+// splitConsoleLine 按空白切分命令行，双引号内的空白不切分
+func splitConsoleLine(line string) []string {
+	var args []string
+	var current strings.Builder
+	quoted, started := false, false
+	for _, r := range strings.TrimSpace(line) {
+		switch {
+		case r == '"':
+			quoted, started = !quoted, true
+		case !quoted && (r == ' ' || r == '\t'):
+			if started {
+				args = append(args, current.String())
+				current.Reset()
+				started = false
+			}
+		default:
+			current.WriteRune(r)
+			started = true
+		}
+	}
+	if started {
+		args = append(args, current.String())
+	}
+	return args
+}
+
+// SetSimulation 设置控制台sim命令操纵的模拟引擎，引擎更新对象时持有设备锁
+func (s *BACnetServer) SetSimulation(engine *simulation.Engine) {
+	if engine != nil {
+		engine.Locker = s.device
+	}
+	s.simulation = engine
+}
+
+// consoleObject 按对象标识符（如analog-input:1）、对象名称或device查找对象
+func (s *BACnetServer) consoleObject(text string) (model.Object, error) {
+	if text == "device" {
+		return s.device, nil
+	}
+	if oid, err := model.ParseObjectIdentifier(text); err == nil {
+		if oid == s.device.GetObjectIdentifier() {
+			return s.device, nil
+		}
+		if obj := s.device.FindObject(oid); obj != nil {
+			return obj, nil
+		}
+		return nil, fmt.Errorf("对象不存在: %s", text)
+	}
+	for _, obj := range s.device.Objects() {
+		if obj.GetObjectName() == text {
+			return obj, nil
+		}
+	}
+	return nil, fmt.Errorf("对象不存在: %s", text)
+}
+
+// consoleValue 将命令行中的值转换为属性使用的Go类型：null为nil，active和inactive为布尔值，数字按数字处理
+func consoleValue(obj model.Object, prop model.PropertyIdentifier, text string) (interface{}, error) {
+	var value interface{} = text
+	switch text {
+	case "null":
+		return nil, nil
+	case "active":
+		value = true
+	case "inactive":
+		value = false
+	default:
+		if number, err := strconv.ParseFloat(text, 64); err == nil {
+			value = number
+		} else if b, err := strconv.ParseBool(text); err == nil {
+			value = b
+		}
+	}
+	coerced, err := model.CoerceValue(obj, prop, value)
+	if _, isText := value.(string); err != nil && !isText {
+		// 如字符串属性写入"123"
+		return model.CoerceValue(obj, prop, text)
+	}
+	return coerced, err
+}
+
+func consoleHelp(s *BACnetServer, w io.Writer, args []string) error {
+	names := []string{"objects", "show", "set", "drive", "alarm", "clear", "subscriptions", "expire", "comms", "sim", "rules", "transactions", "help", "quit"}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(tw, "%s\t%s\n", consoleCommands[name].usage, consoleCommands[name].help)
+	}
+	return tw.Flush()
+}
+
+func consoleObjects(s *BACnetServer, w io.Writer, args []string) error {
+	var filter *model.ObjectType
+	if len(args) > 0 {
+		objType, err := model.ParseObjectType(args[0])
+		if err != nil {
+			return err
+		}
+		filter = &objType
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "OBJECT\tNAME\tVALUE\tSTATUS\tEVENT")
+	for _, summary := range s.objectSummaries() {
+		if filter != nil && summary.Type != filter.String() {
+			continue
+		}
+		value := ""
+		if summary.PresentValue != nil {
+			value = fmt.Sprint(summary.PresentValue)
+			if len(value) > 40 { // 如全局组的值列表
+				value = value[:37] + "..."
+			}
+			if summary.Units != "" {
+				value += " " + summary.Units
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", summary.ID, summary.Name, value, strings.Join(summary.StatusFlags, ","), summary.EventState)
+	}
+	return tw.Flush()
+}
+
+func consoleShow(s *BACnetServer, w io.Writer, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: " + consoleCommands["show"].usage)
+	}
+	obj, err := s.consoleObject(args[0])
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, view := range propertyViews(obj) {
+		writable := ""
+		if view.Writable {
+			writable = "W"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%v\n", view.Name, writable, view.Value)
+	}
+	return tw.Flush()
+}
+
+func consoleSet(s *BACnetServer, w io.Writer, args []string) error {
+	if len(args) != 3 && len(args) != 4 {
+		return errors.New("usage: " + consoleCommands["set"].usage)
+	}
+	obj, err := s.consoleObject(args[0])
+	if err != nil {
+		return err
+	}
+	prop, err := model.ParsePropertyIdentifier(args[1])
+	if err != nil {
+		return err
+	}
+	value, err := consoleValue(obj, prop, args[2])
+	if err != nil {
+		return err
+	}
+	priority := uint64(16)
+	if len(args) == 4 {
+		if priority, err = strconv.ParseUint(args[3], 10, 8); err != nil || priority < 1 || priority > 16 {
+			return errors.New("优先级应为1-16")
+		}
+	}
+	if err := s.writeProperty(nil, obj, prop, value, uint8(priority)); err != nil {
+		return err
+	}
+	s.Logger().Info("经控制台写入属性", "object", obj.GetObjectName(), "property", prop, "value", value, "priority", priority)
+	return consoleShowValue(w, obj, prop)
+}
+
+func consoleDrive(s *BACnetServer, w io.Writer, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: " + consoleCommands["drive"].usage)
+	}
+	obj, err := s.consoleObject(args[0])
+	if err != nil {
+		return err
+	}
+	if _, ok := obj.(model.PresentValueUpdater); !ok {
+		return fmt.Errorf("%s没有现场输入", args[0])
+	}
+	value, err := consoleValue(obj, model.PropertyIdentifierPresentValue, args[1])
+	if err != nil {
+		return err
+	}
+	s.simulateDataChange(obj.GetObjectIdentifier(), model.PropertyIdentifierPresentValue, value)
+	return consoleShowValue(w, obj, model.PropertyIdentifierPresentValue)
+}
+
+func consoleAlarm(s *BACnetServer, w io.Writer, args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: " + consoleCommands["alarm"].usage)
+	}
+	obj, err := s.consoleObject(args[0])
+	if err != nil {
+		return err
+	}
+	state, err := model.ParseEventState(args[1])
+	if err != nil {
+		return err
+	}
+	message := strings.Join(args[2:], " ")
+	if message == "" {
+		message = "控制台触发的事件"
+	}
+	return consoleEvent(w, obj, state, message)
+}
+
+func consoleClear(s *BACnetServer, w io.Writer, args []string) error {
+	if len(args) < 1 {
+		return errors.New("usage: " + consoleCommands["clear"].usage)
+	}
+	obj, err := s.consoleObject(args[0])
+	if err != nil {
+		return err
+	}
+	message := strings.Join(args[1:], " ")
+	if message == "" {
+		message = "控制台恢复正常"
+	}
+	return consoleEvent(w, obj, model.EventStateNormal, message)
+}
+
+// consoleEvent 使对象转换到state并发送事件通知
+func consoleEvent(w io.Writer, obj model.Object, state model.EventState, message string) error {
+	generator, ok := obj.(interface {
+		GenerateEvent(state model.EventState, message string)
+	})
+	if !ok {
+		return fmt.Errorf("%s不支持事件", objectID(obj.GetObjectIdentifier()))
+	}
+	generator.GenerateEvent(state, message)
+	return consoleShowValue(w, obj, model.PropertyIdentifierEventState)
+}
+
+func consoleSubscriptions(s *BACnetServer, w io.Writer, args []string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "OBJECT\tCLIENT\tPROCESS\tCONFIRMED\tLIFETIME\tEXPIRES")
+	for _, view := range s.subscriptionViews() {
+		expires := "never"
+		if !view.Expires.IsZero() {
+			expires = view.Expires.Format("15:04:05")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%t\t%d\t%s\n", view.Object, view.Client, view.ProcessID, view.Confirmed, view.Lifetime, expires)
+	}
+	return tw.Flush()
+}
+
+func consoleExpire(s *BACnetServer, w io.Writer, args []string) error {
+	if len(args) != 1 && len(args) != 2 {
+		return errors.New("usage: " + consoleCommands["expire"].usage)
+	}
+	obj, err := s.consoleObject(args[0])
+	if err != nil {
+		return err
+	}
+	subscribable, ok := obj.(interface {
+		COVSubscriptions() []model.COVSubscription
+		RemoveCOVSubscription(subscriptionID uint32) bool
+	})
+	if !ok {
+		return fmt.Errorf("%s不支持COV订阅", args[0])
+	}
+	var processID uint64
+	if len(args) == 2 {
+		if processID, err = strconv.ParseUint(args[1], 10, 32); err != nil {
+			return fmt.Errorf("无效的订阅进程号: %s", args[1])
+		}
+	}
+	removed := 0
+	for _, sub := range subscribable.COVSubscriptions() {
+		if len(args) == 2 && sub.SubscriberProcessID != uint32(processID) {
+			continue
+		}
+		if subscribable.RemoveCOVSubscription(sub.SubscriptionID) {
+			removed++
+			s.Logger().Info("经控制台移除COV订阅", "object", obj.GetObjectName(), "client", sub.ClientAddress, "subscription_id", sub.SubscriptionID)
+		}
+	}
+	fmt.Fprintf(w, "%d subscription(s) removed\n", removed)
+	return nil
+}
+
+func consoleComms(s *BACnetServer, w io.Writer, args []string) error {
+	switch {
+	case len(args) == 0:
+	case len(args) == 1 && args[0] == "down":
+		s.SetOffline(true)
+	case len(args) == 1 && args[0] == "up":
+		s.SetOffline(false)
+	default:
+		return errors.New("usage: " + consoleCommands["comms"].usage)
+	}
+	if s.Offline() {
+		fmt.Fprintln(w, "communication down")
+	} else {
+		fmt.Fprintln(w, "communication up")
+	}
+	return nil
+}
+
+func consoleSimulation(s *BACnetServer, w io.Writer, args []string) error {
+	if s.simulation == nil {
+		return errors.New("模拟未启用")
+	}
+	if len(args) == 0 {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "OBJECT\tNAME\tSTATE\tVALUE\tPROFILE")
+		for _, point := range s.simulation.Points() {
+			state := "running"
+			if point.Paused {
+				state = "paused"
+			}
+			value := ""
+			if point.Value != nil {
+				value = fmt.Sprint(displayValue(point.Value))
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", objectID(point.Object), point.Name, state, value, point.Profile)
+		}
+		return tw.Flush()
+	}
+	obj, err := s.consoleObject(args[0])
+	if err != nil {
+		return err
+	}
+	oid := obj.GetObjectIdentifier()
+	if len(args) == 2 && !strings.Contains(args[1], "=") {
+		var ok bool
+		switch args[1] {
+		case "pause":
+			ok = s.simulation.Pause(oid, true)
+		case "resume":
+			ok = s.simulation.Pause(oid, false)
+		case "stop":
+			ok = s.simulation.Remove(oid)
+		default:
+			return errors.New("usage: " + consoleCommands["sim"].usage)
+		}
+		if !ok {
+			return fmt.Errorf("%s未模拟", args[0])
+		}
+		fmt.Fprintf(w, "%s %s\n", objectID(oid), args[1])
+		return nil
+	}
+	profile, _ := s.simulation.Profile(oid)
+	for _, arg := range args[1:] {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return errors.New("usage: " + consoleCommands["sim"].usage)
+		}
+		if err := profile.Set(name, value); err != nil {
+			return err
+		}
+	}
+	if err := s.simulation.Set(obj, profile); err != nil {
+		return err
+	}
+	profile, _ = s.simulation.Profile(oid)
+	fmt.Fprintf(w, "%s %s\n", objectID(oid), profile)
+	return nil
+}
+
+func consoleRules(s *BACnetServer, w io.Writer, args []string) error {
+	if s.simulation == nil {
+		return errors.New("模拟未启用")
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RULE\tTARGET\tACTIVE\tVALUE\tERROR")
+	for _, r := range s.simulation.Rules() {
+		errText := ""
+		if r.Err != nil {
+			errText = r.Err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%t\t%g\t%s\n", r.Name, objectID(r.Target), r.Active, r.Value, errText)
+	}
+	return tw.Flush()
+}
+
+func consoleTransactions(s *BACnetServer, w io.Writer, args []string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PEER\tINVOKE-ID\tSERVICE\tWAITING")
+	now := s.now()
+	for _, t := range s.Transactions() {
+		service := t.Service
+		name := (&APDU{ServiceChoice: &service}).ServiceName()
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", t.Peer, t.InvokeID, name, now.Sub(t.Started).Round(time.Millisecond))
+	}
+	return tw.Flush()
+}
+
+// consoleShowValue 输出写入后的属性值
+func consoleShowValue(w io.Writer, obj model.Object, prop model.PropertyIdentifier) error {
+	value, err := model.ReadPropertyValue(obj, prop)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%s %s = %v\n", objectID(obj.GetObjectIdentifier()), prop, displayValue(value))
+	return nil
+}
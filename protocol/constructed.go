@@ -0,0 +1,269 @@
+package protocol
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/iotzf/bacnet-server/encoding"
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// constructedDecoders 值为构造类型的标准属性的解码函数，其余属性按单个应用标签值解码
+var constructedDecoders = map[model.PropertyIdentifier]func(d *encoding.Decoder) (interface{}, error){
+	model.PropertyIdentifierEffectivePeriod:                  decodeDateRange,
+	model.PropertyIdentifierWeeklySchedule:                   decodeWeeklySchedule,
+	model.PropertyIdentifierListOfObjectPropertyReferences:   decodeReferenceList,
+	model.PropertyIdentifierGroupMembers:                     decodeReferenceList,
+	model.PropertyIdentifierObjectPropertyReference:          decodeReference,
+	model.PropertyIdentifierControlledVariableReference:      decodeReference,
+	model.PropertyIdentifierManipulatedVariableReference:     decodeReference,
+	model.PropertyIdentifierSetpointReference:                decodeSetpointReference,
+	model.PropertyIdentifierPrescale:                         decodePrescale,
+	model.PropertyIdentifierScale:                            decodeScale,
+	model.PropertyIdentifierRecipientList:                    decodeRecipientList,
+	model.PropertyIdentifierTimeSynchronizationRecipients:    decodeRecipients,
+	model.PropertyIdentifierUTCTimeSynchronizationRecipients: decodeRecipients,
+	model.PropertyIdentifierStateText:                        decodeStateText,
+	model.PropertyIdentifierDateList:                         decodeDateList,
+	model.PropertyIdentifierExceptionSchedule:                decodeExceptionSchedule,
+	model.PropertyIdentifierStartTime:                        decodeDateTime,
+	model.PropertyIdentifierStopTime:                         decodeDateTime,
+}
+
+// constructedElementDecoders 元素为构造类型的数组属性的元素解码函数，用于写入单个数组元素
+var constructedElementDecoders = map[model.PropertyIdentifier]func(d *encoding.Decoder) (interface{}, error){
+	model.PropertyIdentifierWeeklySchedule:    decodeDailySchedule,
+	model.PropertyIdentifierExceptionSchedule: decodeSpecialEvent,
+	model.PropertyIdentifierGroupMembers:      decodeReference,
+}
+
+// decodeConstructedValue 按属性解码构造类型的值，返回值和消耗的字节数；ok为false表示属性不是构造类型
+func decodeConstructedValue(prop model.PropertyIdentifier, data []byte) (value interface{}, n int, ok bool, err error) {
+	decode, ok := constructedDecoders[prop]
+	if !ok {
+		return nil, 0, false, nil
+	}
+	d := encoding.NewDecoder(data)
+	value, err = decode(d)
+	return value, d.Offset(), true, err
+}
+
+// decodeConstructedElement 按属性解码数组元素，返回值和消耗的字节数；ok为false表示元素不是构造类型
+func decodeConstructedElement(prop model.PropertyIdentifier, data []byte) (value interface{}, n int, ok bool, err error) {
+	decode, ok := constructedElementDecoders[prop]
+	if !ok {
+		return nil, 0, false, nil
+	}
+	d := encoding.NewDecoder(data)
+	value, err = decode(d)
+	return value, d.Offset(), true, err
+}
+
+// decodeDateRange 解码Effective_Period
+func decodeDateRange(d *encoding.Decoder) (interface{}, error) {
+	return d.DateRange()
+}
+
+// decodeDateTime 解码BACnetDateTime（Date后接Time），全通配符解码为零值时间（未指定）
+func decodeDateTime(d *encoding.Decoder) (interface{}, error) {
+	date, err := d.ApplicationDate()
+	if err != nil {
+		return nil, err
+	}
+	tm, err := d.ApplicationTime()
+	if err != nil {
+		return nil, err
+	}
+	if date.Year == 0xFF && tm.Hour == 0xFF {
+		return model.DateTime{}, nil
+	}
+	return model.DateTime{Time: encoding.DateTime(date, tm)}, nil
+}
+
+// decodeReference 解码BACnetDeviceObjectPropertyReference，也接受不带设备标识符的BACnetObjectPropertyReference
+func decodeReference(d *encoding.Decoder) (interface{}, error) {
+	return d.DeviceObjectPropertyReference()
+}
+
+// decodeSetpointReference 解码BACnetSetpointReference，没有[0]引用时表示使用Setpoint属性
+func decodeSetpointReference(d *encoding.Decoder) (interface{}, error) {
+	if !d.IsOpening(0) {
+		return model.SetpointReference{}, nil
+	}
+	if err := d.Opening(0); err != nil {
+		return nil, err
+	}
+	ref, err := d.ObjectPropertyReference()
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Closing(0); err != nil {
+		return nil, err
+	}
+	return model.SetpointReference{Reference: &ref}, nil
+}
+
+// decodePrescale 解码累加器的Prescale（BACnetPrescale）
+func decodePrescale(d *encoding.Decoder) (interface{}, error) {
+	return d.Prescale()
+}
+
+// decodeScale 解码累加器的Scale（BACnetScale）
+func decodeScale(d *encoding.Decoder) (interface{}, error) {
+	return d.Scale()
+}
+
+// decodeReferenceList 解码SEQUENCE OF BACnetDeviceObjectPropertyReference
+func decodeReferenceList(d *encoding.Decoder) (interface{}, error) {
+	refs := []model.DeviceObjectPropertyReference{}
+	for !d.Done() {
+		ref, err := d.DeviceObjectPropertyReference()
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// decodeRecipientList 解码Recipient_List（SEQUENCE OF BACnetDestination）
+func decodeRecipientList(d *encoding.Decoder) (interface{}, error) {
+	destinations := []model.Destination{}
+	for !d.Done() {
+		dest, err := d.Destination()
+		if err != nil {
+			return nil, err
+		}
+		destinations = append(destinations, dest)
+	}
+	return destinations, nil
+}
+
+// decodeRecipients 解码SEQUENCE OF BACnetRecipient
+func decodeRecipients(d *encoding.Decoder) (interface{}, error) {
+	recipients := []model.Recipient{}
+	for !d.Done() {
+		recipient, err := d.Recipient()
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, recipient)
+	}
+	return recipients, nil
+}
+
+// decodeWeeklySchedule 解码Weekly_Schedule：7个BACnetDailySchedule
+//
+//	BACnetDailySchedule ::= SEQUENCE { day-schedule [0] SEQUENCE OF BACnetTimeValue }
+func decodeWeeklySchedule(d *encoding.Decoder) (interface{}, error) {
+	var weekly [7][]model.TimeValue
+	for day := range weekly {
+		if !d.IsOpening(0) {
+			return nil, fmt.Errorf("Weekly_Schedule应包含7天")
+		}
+		timeValues, err := decodeDailySchedule(d)
+		if err != nil {
+			return nil, err
+		}
+		weekly[day] = timeValues.([]model.TimeValue)
+	}
+	return weekly, nil
+}
+
+// decodeDailySchedule 解码一个BACnetDailySchedule，即Weekly_Schedule的一个数组元素
+func decodeDailySchedule(d *encoding.Decoder) (interface{}, error) {
+	if err := d.Opening(0); err != nil {
+		return nil, err
+	}
+	timeValues := []model.TimeValue{}
+	for !d.IsClosing(0) {
+		tv, err := d.TimeValue()
+		if err != nil {
+			return nil, err
+		}
+		timeValues = append(timeValues, tv)
+	}
+	d.Closing(0)
+	return timeValues, nil
+}
+
+// decodeStateText 解码State_Text（ARRAY OF CharacterString），元素不是CharacterString时返回ErrInvalidDataType
+func decodeStateText(d *encoding.Decoder) (interface{}, error) {
+	texts := []string{}
+	for !d.Done() {
+		value, err := d.Application()
+		if err != nil {
+			return nil, err
+		}
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("State_Text的元素应为CharacterString: %w", model.ErrInvalidDataType)
+		}
+		texts = append(texts, text)
+	}
+	return texts, nil
+}
+
+// decodeDateList 解码Date_List（BACnetLIST OF BACnetCalendarEntry）
+func decodeDateList(d *encoding.Decoder) (interface{}, error) {
+	entries := []model.CalendarEntry{}
+	for !d.Done() {
+		entry, err := d.CalendarEntry()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// decodeExceptionSchedule 解码Exception_Schedule（ARRAY OF BACnetSpecialEvent）
+func decodeExceptionSchedule(d *encoding.Decoder) (interface{}, error) {
+	events := []model.SpecialEvent{}
+	for !d.Done() {
+		event, err := d.SpecialEvent()
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// decodeSpecialEvent 解码一个BACnetSpecialEvent，即Exception_Schedule的一个数组元素
+func decodeSpecialEvent(d *encoding.Decoder) (interface{}, error) {
+	return d.SpecialEvent()
+}
+
+// encodeDailySchedule 编码BACnetDailySchedule，无法编码的切换值编码为Null
+func encodeDailySchedule(timeValues []model.TimeValue) []byte {
+	var content []byte
+	for _, tv := range timeValues {
+		encoded, err := encoding.EncodeTimeValue(tv)
+		if err != nil {
+			slog.Warn("编码日程切换值失败", "error", err)
+			encoded, _ = encoding.EncodeTimeValue(model.TimeValue{Time: tv.Time})
+		}
+		content = append(content, encoded...)
+	}
+	return encoding.EncodeConstructed(0, content)
+}
+
+// encodeSpecialEvent 编码BACnetSpecialEvent，无法编码的切换值编码为Null
+func encodeSpecialEvent(event model.SpecialEvent) []byte {
+	encoded, err := encoding.EncodeSpecialEvent(event)
+	if err == nil {
+		return encoded
+	}
+	slog.Warn("编码例外日程失败", "error", err)
+	timeValues := make([]model.TimeValue, len(event.TimeValues))
+	for i, tv := range event.TimeValues {
+		if _, err := encoding.EncodeTimeValue(tv); err != nil {
+			tv.Value = nil
+		}
+		timeValues[i] = tv
+	}
+	event.TimeValues = timeValues
+	encoded, _ = encoding.EncodeSpecialEvent(event)
+	return encoded
+}
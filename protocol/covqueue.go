@@ -0,0 +1,136 @@
+package protocol
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// defaultCOVQueueSize 未配置时每个订阅者的COV通知队列长度
+const defaultCOVQueueSize = 64
+
+// errCOVQueueFull 订阅者的COV通知队列已满，通知被丢弃
+var errCOVQueueFull = errors.New("COV通知队列已满，通知被丢弃")
+
+// covNotification 排队等待发送的一个COV通知
+type covNotification struct {
+	clientAddr     string
+	addr           net.Addr
+	dest           *model.Address // 订阅者在远程网络上时NPDU的目标网络和MAC，由addr处的路由器转发
+	subscriptionID uint32
+	parameters     []byte // 编码完成的通知参数
+	confirmed      bool
+}
+
+// covQueue 一个订阅者（客户端地址）的COV通知队列，有通知时由一个发送goroutine按顺序发送，队列空后退出
+type covQueue struct {
+	notifications chan covNotification
+	sending       bool // 是否有发送goroutine，由covFanout.mu保护
+}
+
+// covFanout 按订阅者分配的有界COV通知队列。对象在写入属性时只把通知放入队列，
+// 慢的或不可达的订阅者只会填满自己的队列，队列满时丢弃新的通知并计数
+type covFanout struct {
+	mu      sync.Mutex
+	queues  map[string]*covQueue
+	size    int    // 每个队列的长度，为0时为defaultCOVQueueSize
+	dropped uint64 // 队列满时丢弃的通知数，原子访问
+}
+
+// enqueueCOV 将通知放入订阅者的队列，需要时启动发送goroutine；服务端已开始关闭时返回errServerStopped
+func (s *BACnetServer) enqueueCOV(n covNotification) error {
+	f := &s.covQueues
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.queues == nil {
+		f.queues = make(map[string]*covQueue)
+	}
+	q, ok := f.queues[n.clientAddr]
+	if !ok {
+		size := f.size
+		if size <= 0 {
+			size = defaultCOVQueueSize
+		}
+		q = &covQueue{notifications: make(chan covNotification, size)}
+		f.queues[n.clientAddr] = q
+	}
+	if !q.sending {
+		if !s.track() {
+			return errServerStopped
+		}
+		q.sending = true
+		go s.sendCOVQueue(n.clientAddr, q)
+	}
+	select {
+	case q.notifications <- n:
+		return nil
+	default:
+		atomic.AddUint64(&f.dropped, 1)
+		return errCOVQueueFull
+	}
+}
+
+// sendCOVQueue 依次发送订阅者队列中的通知，队列空时移除队列并退出；Shutdown等待已排队的通知发送完
+func (s *BACnetServer) sendCOVQueue(clientAddr string, q *covQueue) {
+	defer s.inflight.Done()
+	f := &s.covQueues
+	for {
+		select {
+		case n := <-q.notifications:
+			s.deliverCOV(n)
+		default:
+			f.mu.Lock()
+			if len(q.notifications) > 0 {
+				f.mu.Unlock()
+				continue
+			}
+			q.sending = false
+			delete(f.queues, clientAddr)
+			f.mu.Unlock()
+			return
+		}
+	}
+}
+
+// deliverCOV 发送一个COV通知，确认通知等待应答，超时按设备的重试次数重发；
+// 订阅者在远程网络上时与对路由请求的应答一样以其网络和地址为NPDU的目标
+func (s *BACnetServer) deliverCOV(n covNotification) {
+	if n.confirmed {
+		reply, err := s.sendRoutedRequest(n.addr, n.dest, BACnetServiceConfirmedCOVNotification, n.parameters)
+		if err != nil {
+			s.Logger().Warn("确认COV通知失败", "peer", n.clientAddr, "subscription", n.subscriptionID, "error", err)
+			return
+		}
+		atomic.AddUint64(&s.metrics.covNotifications, 1)
+		s.Logger().Debug("确认COV通知已应答", "peer", n.clientAddr, "subscription", n.subscriptionID, "reply", reply.String())
+		return
+	}
+	apdu := append([]byte{BACnetAPDUTypeUnconfirmedServiceRequest << 4, BACnetServiceUnconfirmedCOVNotification}, n.parameters...)
+	bytes, err := s.writeTo(encodeBVLC(BVLCOriginalUnicastNPDU, append(destinationNPDU(n.dest, false).Encode(), apdu...)), n.addr)
+	if err != nil {
+		s.Logger().Warn("发送COV通知失败", "peer", n.clientAddr, "subscription", n.subscriptionID, "error", err)
+		return
+	}
+	atomic.AddUint64(&s.metrics.covNotifications, 1)
+	s.Logger().Debug("已发送COV通知", "peer", n.clientAddr, "subscription", n.subscriptionID, "bytes", bytes)
+}
+
+// COVQueueDepths 返回各订阅者队列中等待发送的COV通知数，键为客户端地址，只包含有通知在发送的订阅者
+func (s *BACnetServer) COVQueueDepths() map[string]int {
+	f := &s.covQueues
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	depths := make(map[string]int, len(f.queues))
+	for clientAddr, q := range f.queues {
+		depths[clientAddr] = len(q.notifications)
+	}
+	return depths
+}
+
+// COVNotificationsDropped 返回因订阅者队列已满而丢弃的COV通知数
+func (s *BACnetServer) COVNotificationsDropped() uint64 {
+	return atomic.LoadUint64(&s.covQueues.dropped)
+}
@@ -0,0 +1,502 @@
+package protocol
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+//go:embed dashboard.html
+var dashboardPage []byte
+
+// dashboardInterval 仪表盘事件流推送变化的周期
+const dashboardInterval = time.Second
+
+// objectSummary 仪表盘对象列表中的一行
+type objectSummary struct {
+	ID           string      `json:"id"` // 如analog-input:1
+	Type         string      `json:"type"`
+	Instance     uint32      `json:"instance"`
+	Name         string      `json:"name"`
+	PresentValue interface{} `json:"presentValue,omitempty"`
+	Units        string      `json:"units,omitempty"`
+	StatusFlags  []string    `json:"statusFlags"`
+	EventState   string      `json:"eventState,omitempty"`
+}
+
+// alarmView 仪表盘告警列表中的一项，内容与GetEventInformation的结果相同
+type alarmView struct {
+	objectSummary
+	NotificationClass  uint32               `json:"notificationClass"`
+	Priority           uint8                `json:"priority"`           // 通知类的事件优先级
+	UnackedTransitions []string             `json:"unackedTransitions"` // 未确认的转换：to-offnormal、to-fault、to-normal
+	EventTimeStamps    map[string]time.Time `json:"eventTimeStamps"`    // 各转换最近一次发生的时间，没有发生过的转换不列出
+}
+
+// alarmFilter /api/alarms的查询条件，为空的条件不限制
+type alarmFilter struct {
+	eventStates []model.EventState
+	classes     []uint32
+	minPriority uint8
+	maxPriority uint8
+}
+
+// transitionNames Acked_Transitions和Event_Time_Stamps各转换的名称
+var transitionNames = [3]string{"to-offnormal", "to-fault", "to-normal"}
+
+// propertyView 对象详情中的一个属性
+type propertyView struct {
+	ID       model.PropertyIdentifier `json:"id"`
+	Name     string                   `json:"name"`
+	Value    interface{}              `json:"value"`
+	Writable bool                     `json:"writable"`
+}
+
+// subscriptionView 仪表盘中的一个COV订阅
+type subscriptionView struct {
+	Object    string    `json:"object"`
+	Client    string    `json:"client"`
+	ProcessID uint32    `json:"processId"`
+	Confirmed bool      `json:"confirmed"`
+	Lifetime  uint32    `json:"lifetime"`
+	Expires   time.Time `json:"expires,omitempty"`
+}
+
+// writeRequest 仪表盘写属性的请求体，Value为null时释放Priority上的命令
+type writeRequest struct {
+	Value    interface{} `json:"value"`
+	Priority uint8       `json:"priority"`
+}
+
+// DashboardHandler 返回仪表盘的HTTP处理器：/为网页，/api/下为对象、告警和订阅的JSON接口，/api/alarms可以按
+// 事件状态（eventState）、通知类（notificationClass）和优先级（priority，如64或0-63）过滤，条件可以重复，
+// /api/events以Server-Sent Events推送对象值的变化，/api/pics为EPICS文本，其他组件的接口由HandleDashboardAPI注册。经仪表盘的写入与网络写入一样经过写保护、钩子和校验
+func (s *BACnetServer) DashboardHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(dashboardPage)
+	})
+	mux.HandleFunc("GET /api/objects", func(w http.ResponseWriter, r *http.Request) {
+		s.device.Lock()
+		summaries := s.objectSummaries()
+		s.device.Unlock()
+		writeJSON(w, summaries)
+	})
+	mux.HandleFunc("GET /api/objects/{type}/{instance}", func(w http.ResponseWriter, r *http.Request) {
+		s.device.Lock()
+		obj, err := s.dashboardObject(r)
+		var view map[string]interface{}
+		if err == nil {
+			view = map[string]interface{}{"object": summarize(obj), "properties": propertyViews(obj)}
+		}
+		s.device.Unlock()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, view)
+	})
+	mux.HandleFunc("PUT /api/objects/{type}/{instance}/{property}", s.handleDashboardWrite)
+	mux.HandleFunc("GET /api/alarms", func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseAlarmFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.device.Lock()
+		alarms := s.alarmViews(filter)
+		s.device.Unlock()
+		writeJSON(w, alarms)
+	})
+	mux.HandleFunc("GET /api/subscriptions", func(w http.ResponseWriter, r *http.Request) {
+		s.device.Lock()
+		subscriptions := s.subscriptionViews()
+		s.device.Unlock()
+		writeJSON(w, subscriptions)
+	})
+	mux.HandleFunc("GET /api/events", s.handleDashboardEvents)
+	mux.HandleFunc("GET /api/pics", func(w http.ResponseWriter, r *http.Request) {
+		var pics bytes.Buffer
+		s.device.Lock()
+		s.PICS().WriteTo(&pics)
+		s.device.Unlock()
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		pics.WriteTo(w)
+	})
+	mux.HandleFunc("/api/{name}", func(w http.ResponseWriter, r *http.Request) {
+		handler, ok := s.dashboardAPIs.Load(r.PathValue("name"))
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		handler.(http.Handler).ServeHTTP(w, r)
+	})
+	return mux
+}
+
+// HandleDashboardAPI 在仪表盘的/api/<name>下提供其他组件的接口（如趋势采集的状态），服务端运行中也可以注册
+func (s *BACnetServer) HandleDashboardAPI(name string, handler http.Handler) {
+	s.dashboardAPIs.Store(name, handler)
+}
+
+// serveDashboard 在addr上提供仪表盘，服务端关闭时停止
+func (s *BACnetServer) serveDashboard(addr string) error {
+	return s.serveHTTP("仪表盘", addr, s.DashboardHandler())
+}
+
+// handleDashboardWrite 写入属性，可命令属性未给出优先级时按16写入
+func (s *BACnetServer) handleDashboardWrite(w http.ResponseWriter, r *http.Request) {
+	prop, err := model.ParsePropertyIdentifier(r.PathValue("property"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var request writeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "无效的请求: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.Priority == 0 {
+		request.Priority = 16
+	}
+	if request.Priority > 16 {
+		http.Error(w, "优先级应为1-16", http.StatusBadRequest)
+		return
+	}
+
+	s.device.Lock()
+	defer s.device.Unlock()
+	obj, err := s.dashboardObject(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	value := request.Value
+	if value != nil {
+		if value, err = model.CoerceValue(obj, prop, value); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := s.writeProperty(nil, obj, prop, value, request.Priority); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	s.Logger().Info("经仪表盘写入属性", "object", obj.GetObjectName(), "property", prop, "value", value, "priority", request.Priority)
+	writeJSON(w, summarize(obj))
+}
+
+// handleDashboardEvents 以Server-Sent Events推送对象列表：连接时推送全部对象，之后每秒推送有变化的对象
+func (s *BACnetServer) handleDashboardEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "不支持事件流", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	ticker := time.NewTicker(dashboardInterval)
+	defer ticker.Stop()
+	last := map[string]string{}
+	for {
+		var changed []objectSummary
+		s.device.Lock()
+		summaries := s.objectSummaries()
+		s.device.Unlock()
+		for _, summary := range summaries {
+			data, _ := json.Marshal(summary)
+			if last[summary.ID] != string(data) {
+				last[summary.ID] = string(data)
+				changed = append(changed, summary)
+			}
+		}
+		if len(changed) > 0 {
+			data, _ := json.Marshal(changed)
+			if _, err := fmt.Fprintf(w, "event: objects\ndata: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+		select {
+		case <-ticker.C:
+		case <-r.Context().Done():
+			return
+		case <-s.Done():
+			return
+		}
+	}
+}
+
+// dashboardObject 按路径中的对象类型（名称或编号）和实例号查找对象，设备对象也可以访问
+func (s *BACnetServer) dashboardObject(r *http.Request) (model.Object, error) {
+	objType, err := model.ParseObjectType(r.PathValue("type"))
+	if err != nil {
+		return nil, err
+	}
+	instance, err := strconv.ParseUint(r.PathValue("instance"), 10, 22)
+	if err != nil {
+		return nil, fmt.Errorf("无效的实例号: %s", r.PathValue("instance"))
+	}
+	oid := model.ObjectIdentifier{Type: objType, Instance: uint32(instance)}
+	if oid == s.device.GetObjectIdentifier() {
+		return s.device, nil
+	}
+	if obj := s.device.FindObject(oid); obj != nil {
+		return obj, nil
+	}
+	return nil, errors.New("对象不存在")
+}
+
+// objectSummaries 返回设备和全部对象的摘要
+func (s *BACnetServer) objectSummaries() []objectSummary {
+	summaries := []objectSummary{summarize(s.device)}
+	for _, obj := range s.device.Objects() {
+		summaries = append(summaries, summarize(obj))
+	}
+	return summaries
+}
+
+// alarmViews 返回事件状态不为normal或有未确认转换的对象，按filter过滤
+func (s *BACnetServer) alarmViews(filter alarmFilter) []alarmView {
+	alarms := []alarmView{}
+	for _, obj := range s.device.Objects() {
+		alarmable, ok := obj.(interface {
+			model.Alarmable
+			GetAckedTransitions() uint8
+			GetEventTimeStamps() []time.Time
+		})
+		if !ok {
+			continue
+		}
+		state := alarmable.GetEventState()
+		acked := alarmable.GetAckedTransitions()
+		if state == model.EventStateNormal && acked == model.AckedTransitionsAll {
+			continue
+		}
+		class := alarmable.GetNotificationClass()
+		view := alarmView{
+			objectSummary:      summarize(obj),
+			NotificationClass:  class,
+			Priority:           s.notificationPriority(class),
+			UnackedTransitions: []string{},
+			EventTimeStamps:    map[string]time.Time{},
+		}
+		if !filter.match(state, class, view.Priority) {
+			continue
+		}
+		view.EventState = state.String()
+		for i, name := range transitionNames {
+			if acked&(1<<i) == 0 {
+				view.UnackedTransitions = append(view.UnackedTransitions, name)
+			}
+		}
+		for i, stamp := range alarmable.GetEventTimeStamps() {
+			if !stamp.IsZero() {
+				view.EventTimeStamps[transitionNames[i]] = stamp
+			}
+		}
+		alarms = append(alarms, view)
+	}
+	return alarms
+}
+
+// parseAlarmFilter 解析/api/alarms的查询参数，事件状态写名称，优先级写单个值或“下限-上限”
+func parseAlarmFilter(query url.Values) (alarmFilter, error) {
+	filter := alarmFilter{maxPriority: math.MaxUint8}
+	for _, text := range query["eventState"] {
+		state, err := model.ParseEventState(text)
+		if err != nil {
+			return filter, err
+		}
+		filter.eventStates = append(filter.eventStates, state)
+	}
+	for _, text := range query["notificationClass"] {
+		class, err := strconv.ParseUint(text, 10, 32)
+		if err != nil {
+			return filter, fmt.Errorf("无效的通知类: %s", text)
+		}
+		filter.classes = append(filter.classes, uint32(class))
+	}
+	if text := query.Get("priority"); text != "" {
+		low, high, isRange := strings.Cut(text, "-")
+		if !isRange {
+			high = low
+		}
+		min, err := strconv.ParseUint(low, 10, 8)
+		if err != nil {
+			return filter, fmt.Errorf("无效的优先级: %s", text)
+		}
+		max, err := strconv.ParseUint(high, 10, 8)
+		if err != nil || max < min {
+			return filter, fmt.Errorf("无效的优先级: %s", text)
+		}
+		filter.minPriority, filter.maxPriority = uint8(min), uint8(max)
+	}
+	return filter, nil
+}
+
+// match 判断告警是否满足查询条件
+func (f alarmFilter) match(state model.EventState, class uint32, priority uint8) bool {
+	if len(f.eventStates) > 0 && !slices.Contains(f.eventStates, state) {
+		return false
+	}
+	if len(f.classes) > 0 && !slices.Contains(f.classes, class) {
+		return false
+	}
+	return priority >= f.minPriority && priority <= f.maxPriority
+}
+
+// subscriptionViews 返回全部对象的COV订阅
+func (s *BACnetServer) subscriptionViews() []subscriptionView {
+	views := []subscriptionView{}
+	for _, obj := range s.device.Objects() {
+		subscribable, ok := obj.(interface {
+			COVSubscriptions() []model.COVSubscription
+		})
+		if !ok {
+			continue
+		}
+		for _, sub := range subscribable.COVSubscriptions() {
+			views = append(views, subscriptionView{
+				Object:    objectID(obj.GetObjectIdentifier()),
+				Client:    sub.ClientAddress,
+				ProcessID: sub.SubscriberProcessID,
+				Confirmed: sub.IssueConfirmedCOVNotifications,
+				Lifetime:  sub.Lifetime,
+				Expires:   sub.Expires,
+			})
+		}
+	}
+	return views
+}
+
+// summarize 返回对象的摘要
+func summarize(obj model.Object) objectSummary {
+	oid := obj.GetObjectIdentifier()
+	summary := objectSummary{
+		ID:          objectID(oid),
+		Type:        oid.Type.String(),
+		Instance:    oid.Instance,
+		Name:        obj.GetObjectName(),
+		StatusFlags: []string{},
+	}
+	if value, _ := obj.ReadProperty(model.PropertyIdentifierPresentValue); value != nil {
+		summary.PresentValue = displayValue(value)
+	}
+	if units, ok := readProperty(obj, model.PropertyIdentifierUnits).(model.EngineeringUnits); ok {
+		summary.Units = units.String()
+	}
+	if flags, ok := readProperty(obj, model.PropertyIdentifierStatusFlags).(model.BitString); ok {
+		for i, name := range []string{"in-alarm", "fault", "overridden", "out-of-service"} {
+			if flags.Bit(i) {
+				summary.StatusFlags = append(summary.StatusFlags, name)
+			}
+		}
+	}
+	if state, ok := readProperty(obj, model.PropertyIdentifierEventState).(model.EventState); ok {
+		summary.EventState = state.String()
+	}
+	return summary
+}
+
+// propertyViews 返回对象的全部属性及其是否可写
+func propertyViews(obj model.Object) []propertyView {
+	objType := obj.GetObjectIdentifier().Type
+	views := []propertyView{}
+	for _, prop := range model.ExpandPropertyReference(obj, model.PropertyIdentifierAll) {
+		value, err := model.ReadPropertyValue(obj, prop)
+		if err != nil {
+			continue
+		}
+		meta, _ := model.LookupPropertyMetadata(objType, prop)
+		views = append(views, propertyView{ID: prop, Name: prop.String(), Value: displayValue(value), Writable: meta.Writable})
+	}
+	return views
+}
+
+// readProperty 读取属性，出错时返回nil
+func readProperty(obj model.Object, prop model.PropertyIdentifier) interface{} {
+	value, err := obj.ReadProperty(prop)
+	if err != nil {
+		return nil
+	}
+	return value
+}
+
+// objectID 返回对象标识符的文本形式，如analog-input:1
+func objectID(oid model.ObjectIdentifier) string {
+	return fmt.Sprintf("%s:%d", oid.Type, oid.Instance)
+}
+
+// displayValue 将属性值转换为可以编码为JSON的形式：枚举显示名称，对象标识符显示为文本，非有限浮点数显示为字符串
+func displayValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case model.ObjectIdentifier:
+		return objectID(v)
+	case model.BitString:
+		return []bool(v)
+	case time.Time:
+		if v.IsZero() {
+			return nil
+		}
+		return v.Format(time.RFC3339)
+	case []byte:
+		return fmt.Sprintf("%X", v)
+	case float32:
+		return displayFloat(float64(v), 32)
+	case float64:
+		return displayFloat(v, 64)
+	case fmt.Stringer:
+		return v.String()
+	case error:
+		return v.Error()
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		items := make([]interface{}, rv.Len())
+		for i := range items {
+			items[i] = displayValue(rv.Index(i).Interface())
+		}
+		return items
+	case reflect.Bool, reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return value
+	}
+	if _, err := json.Marshal(value); err != nil {
+		return fmt.Sprint(value)
+	}
+	return value
+}
+
+// displayFloat 按原精度显示浮点数（float32的0.1不显示为0.10000000149），非有限的浮点数无法编码为JSON，显示为字符串
+func displayFloat(f float64, bitSize int) interface{} {
+	text := strconv.FormatFloat(f, 'g', -1, bitSize)
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return text
+	}
+	f, _ = strconv.ParseFloat(text, 64)
+	return f
+}
+
+// writeJSON 以JSON写出应答
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
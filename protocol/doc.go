@@ -0,0 +1,18 @@
+// Package protocol 实现BACnet/IP服务端：BVLC、NPDU和APDU的编解码，
+// 确认和未确认服务的处理，COV和事件通知，BBMD、外部设备和路由器功能。
+//
+// 一个服务端对应一个model.Device，设备中的对象由model包的构造函数创建：
+//
+//	device := model.NewDevice(1001, "My Device", "Building A")
+//	device.AddObject(model.NewAnalogInput(1, "Temperature", model.UnitsDegreesCelsius))
+//
+//	server, err := protocol.NewServer(device, protocol.Options{Address: ":47808"})
+//	if err != nil {
+//		return err
+//	}
+//	server.Start(ctx)
+//	<-server.Done()
+//
+// Start的ctx结束时服务端停止接收请求，等待处理中的请求完成后关闭传输。
+// 其他数据链路（如mstp包的MS/TP节点）可经HandleNPDU或AddRouterPort接入同一个服务端
+package protocol
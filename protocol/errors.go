@@ -0,0 +1,289 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/iotzf/bacnet-server/encoding"
+)
+
+// 错误类别（ASHRAE 135 第21节 BACnetErrorClass）
+const (
+	ErrorClassDevice        = 0
+	ErrorClassObject        = 1
+	ErrorClassProperty      = 2
+	ErrorClassResources     = 3
+	ErrorClassSecurity      = 4
+	ErrorClassService       = 5
+	ErrorClassVT            = 6
+	ErrorClassCommunication = 7
+
+	ErrorClassCov  = ErrorClassService // COV订阅错误属于services类
+	ErrorClassFile = ErrorClassObject  // 文件访问错误属于object类
+)
+
+// 错误代码（ASHRAE 135 第21节 BACnetErrorCode）
+const (
+	ErrorCodeOther                             = 0
+	ErrorCodeDeviceBusy                        = 3
+	ErrorCodeFileAccessDenied                  = 5  // 文件访问被拒绝
+	ErrorCodeInconsistentParameters            = 7  // 参数不一致
+	ErrorCodeInvalidDataType                   = 9  // 无效的数据类型
+	ErrorCodeInvalidFileAccessMethod           = 10 // 文件访问方式无效
+	ErrorCodeInvalidFileStartPosition          = 11 // 文件起始位置无效
+	ErrorCodeInvalidParameterDataType          = 13 // 参数数据类型无效
+	ErrorCodeInvalidTimeStamp                  = 14 // 时间戳无效
+	ErrorCodeMissingRequiredParameter          = 16 // 缺少必需参数
+	ErrorCodeNoSpaceToWriteProperty            = 20 // 没有空间写入属性
+	ErrorCodeReadAccessDenied                  = 27 // 读访问被拒绝
+	ErrorCodeServiceRequestDenied              = 29 // 服务请求被拒绝
+	ErrorCodeTimeout                           = 30 // 超时
+	ErrorCodeUnknownObject                     = 31 // 对象不存在
+	ErrorCodeUnknownProperty                   = 32 // 属性不存在
+	ErrorCodeUnsupportedObjectType             = 36 // 对象类型不支持
+	ErrorCodeValueOutOfRange                   = 37 // 值超出范围
+	ErrorCodeWriteAccessDenied                 = 40 // 写访问被拒绝
+	ErrorCodeCharacterSetNotSupported          = 41 // 字符集不支持
+	ErrorCodeInvalidArrayIndex                 = 42 // 数组下标无效
+	ErrorCodeCovSubscriptionFailed             = 43 // COV订阅失败
+	ErrorCodeNotCovProperty                    = 44 // 属性不支持COV
+	ErrorCodeOptionalFunctionalityNotSupported = 45 // 不支持的可选功能
+	ErrorCodeDatatypeNotSupported              = 47 // 数据类型不支持
+	ErrorCodeDuplicateName                     = 48 // 对象名重复
+	ErrorCodeDuplicateObjectID                 = 49 // 对象标识符重复
+	ErrorCodePropertyIsNotAnArray              = 50 // 属性不是数组
+	ErrorCodeAccessDenied                      = 85 // 访问被拒绝
+
+	// 以下为服务处理中使用的别名
+	ErrorCodeObjectNotExist          = ErrorCodeUnknownObject
+	ErrorCodePropertyNotExist        = ErrorCodeUnknownProperty
+	ErrorCodePropertyNotReadable     = ErrorCodeReadAccessDenied
+	ErrorCodePropertyNotWritable     = ErrorCodeWriteAccessDenied
+	ErrorCodeObjectNotOfRequiredType = ErrorCodeUnsupportedObjectType
+	ErrorCodeCovObject               = ErrorCodeCovSubscriptionFailed
+	ErrorCodeCovProperty             = ErrorCodeNotCovProperty
+)
+
+// 拒绝原因（ASHRAE 135 第21节 BACnetRejectReason）
+const (
+	RejectReasonOther                    = 0
+	RejectReasonBufferOverflow           = 1
+	RejectReasonInconsistentParameters   = 2
+	RejectReasonInvalidParameterDataType = 3
+	RejectReasonInvalidTag               = 4
+	RejectReasonMissingRequiredParameter = 5
+	RejectReasonParameterOutOfRange      = 6
+	RejectReasonTooManyArguments         = 7
+	RejectReasonUndefinedEnumeration     = 8
+	RejectReasonUnrecognizedService      = 9
+)
+
+// rejectReasonNames 拒绝原因名称
+var rejectReasonNames = map[byte]string{
+	RejectReasonOther:                    "其他原因",
+	RejectReasonBufferOverflow:           "缓冲区溢出",
+	RejectReasonInconsistentParameters:   "参数不一致",
+	RejectReasonInvalidParameterDataType: "参数数据类型无效",
+	RejectReasonInvalidTag:               "标签无效",
+	RejectReasonMissingRequiredParameter: "缺少必需参数",
+	RejectReasonParameterOutOfRange:      "参数超出范围",
+	RejectReasonTooManyArguments:         "参数过多",
+	RejectReasonUndefinedEnumeration:     "未定义的枚举值",
+	RejectReasonUnrecognizedService:      "无法识别的服务",
+}
+
+// 放弃原因（ASHRAE 135 第21节 BACnetAbortReason）
+const (
+	AbortReasonOther                        = 0
+	AbortReasonBufferOverflow               = 1
+	AbortReasonInvalidAPDUInThisState       = 2
+	AbortReasonPreemptedByHigherPriority    = 3
+	AbortReasonSegmentationNotSupported     = 4
+	AbortReasonSecurityError                = 5
+	AbortReasonInsufficientSecurity         = 6
+	AbortReasonWindowSizeOutOfRange         = 7
+	AbortReasonApplicationExceededReplyTime = 8
+	AbortReasonOutOfResources               = 9
+	AbortReasonTSMTimeout                   = 10
+	AbortReasonAPDUTooLong                  = 11
+)
+
+// abortReasonNames 放弃原因名称
+var abortReasonNames = map[byte]string{
+	AbortReasonOther:                        "其他原因",
+	AbortReasonBufferOverflow:               "缓冲区溢出",
+	AbortReasonInvalidAPDUInThisState:       "当前状态下的APDU无效",
+	AbortReasonPreemptedByHigherPriority:    "被更高优先级的任务抢占",
+	AbortReasonSegmentationNotSupported:     "不支持分段",
+	AbortReasonSecurityError:                "安全错误",
+	AbortReasonInsufficientSecurity:         "安全级别不足",
+	AbortReasonWindowSizeOutOfRange:         "窗口大小超出范围",
+	AbortReasonApplicationExceededReplyTime: "应用程序应答超时",
+	AbortReasonOutOfResources:               "资源不足",
+	AbortReasonTSMTimeout:                   "事务超时",
+	AbortReasonAPDUTooLong:                  "APDU过长",
+}
+
+// errorClassNames 错误类别名称
+var errorClassNames = map[uint32]string{
+	ErrorClassDevice:        "设备错误",
+	ErrorClassObject:        "对象错误",
+	ErrorClassProperty:      "属性错误",
+	ErrorClassResources:     "资源错误",
+	ErrorClassSecurity:      "安全错误",
+	ErrorClassService:       "服务错误",
+	ErrorClassVT:            "虚拟终端错误",
+	ErrorClassCommunication: "通信错误",
+}
+
+// errorCodeNames 错误代码名称
+var errorCodeNames = map[uint32]string{
+	ErrorCodeOther:                             "其他",
+	ErrorCodeDeviceBusy:                        "设备忙",
+	ErrorCodeFileAccessDenied:                  "文件访问被拒绝",
+	ErrorCodeInconsistentParameters:            "参数不一致",
+	ErrorCodeInvalidDataType:                   "数据类型无效",
+	ErrorCodeInvalidFileAccessMethod:           "文件访问方式无效",
+	ErrorCodeInvalidFileStartPosition:          "文件起始位置无效",
+	ErrorCodeInvalidParameterDataType:          "参数数据类型无效",
+	ErrorCodeInvalidTimeStamp:                  "时间戳无效",
+	ErrorCodeMissingRequiredParameter:          "缺少必需参数",
+	ErrorCodeNoSpaceToWriteProperty:            "没有空间写入属性",
+	ErrorCodeReadAccessDenied:                  "读访问被拒绝",
+	ErrorCodeServiceRequestDenied:              "服务请求被拒绝",
+	ErrorCodeTimeout:                           "超时",
+	ErrorCodeUnknownObject:                     "对象不存在",
+	ErrorCodeUnknownProperty:                   "属性不存在",
+	ErrorCodeUnsupportedObjectType:             "对象类型不支持",
+	ErrorCodeValueOutOfRange:                   "值超出范围",
+	ErrorCodeWriteAccessDenied:                 "写访问被拒绝",
+	ErrorCodeCharacterSetNotSupported:          "字符集不支持",
+	ErrorCodeInvalidArrayIndex:                 "数组下标无效",
+	ErrorCodeCovSubscriptionFailed:             "COV订阅失败",
+	ErrorCodeNotCovProperty:                    "属性不支持COV",
+	ErrorCodeOptionalFunctionalityNotSupported: "不支持的可选功能",
+	ErrorCodeDatatypeNotSupported:              "数据类型不支持",
+	ErrorCodeDuplicateName:                     "对象名重复",
+	ErrorCodeDuplicateObjectID:                 "对象标识符重复",
+	ErrorCodePropertyIsNotAnArray:              "属性不是数组",
+	ErrorCodeAccessDenied:                      "访问被拒绝",
+}
+
+// errorClassName 返回错误类别的可读名称
+func errorClassName(class uint32) string {
+	if name, ok := errorClassNames[class]; ok {
+		return name
+	}
+	return fmt.Sprintf("未知错误类别(%d)", class)
+}
+
+// errorCodeName 返回错误代码的可读名称
+func errorCodeName(code uint32) string {
+	if name, ok := errorCodeNames[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("未知错误代码(%d)", code)
+}
+
+// rejectReasonName 返回拒绝原因的可读名称
+func rejectReasonName(reason byte) string {
+	if name, ok := rejectReasonNames[reason]; ok {
+		return name
+	}
+	return fmt.Sprintf("未知拒绝原因(0x%02x)", reason)
+}
+
+// abortReasonName 返回放弃原因的可读名称
+func abortReasonName(reason byte) string {
+	if name, ok := abortReasonNames[reason]; ok {
+		return name
+	}
+	return fmt.Sprintf("未知放弃原因(0x%02x)", reason)
+}
+
+// Error 带BACnet错误类别和代码的错误，钩子或属性提供者返回该错误时服务端以其类别和代码应答
+type Error struct {
+	Class byte
+	Code  byte
+}
+
+// Error 返回错误类别和代码的名称
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", errorClassName(uint32(e.Class)), errorCodeName(uint32(e.Code)))
+}
+
+// asError 将err转换为*Error，不是*Error的错误使用给定的类别和代码
+func asError(err error, errorClass, errorCode byte) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return &Error{Class: errorClass, Code: errorCode}
+}
+
+// createErrorResponse 创建错误响应
+//
+//	BACnet-Error-PDU ::= PDU类型、invokeID、服务选择，随后为
+//	Error ::= SEQUENCE { error-class ENUMERATED, error-code ENUMERATED }
+func (s *BACnetServer) createErrorResponse(invokeID byte, serviceType byte, errorClass, errorCode byte) []byte {
+	s.metrics.errors.inc(fmt.Sprintf("%d/%d", errorClass, errorCode))
+	response := []byte{BACnetAPDUTypeError << 4, invokeID, serviceType}
+	response = append(response, encoding.EncodeEnumerated(uint32(errorClass))...)
+	return append(response, encoding.EncodeEnumerated(uint32(errorCode))...)
+}
+
+// createRejectResponse 创建拒绝响应，用于无法解析的确认请求
+//
+//	BACnet-Reject-PDU ::= PDU类型、invokeID、reject-reason
+func (s *BACnetServer) createRejectResponse(invokeID byte, reason byte) []byte {
+	s.metrics.rejects.inc(strconv.Itoa(int(reason)))
+	return []byte{BACnetAPDUTypeReject << 4, invokeID, reason}
+}
+
+// createAbortResponse 创建服务端发出的放弃响应，用于无法交付的应答
+//
+//	BACnet-Abort-PDU ::= PDU类型和SRV标志、invokeID、abort-reason
+func (s *BACnetServer) createAbortResponse(invokeID byte, reason byte) []byte {
+	return []byte{BACnetAPDUTypeAbort<<4 | 0x01, invokeID, reason}
+}
+
+// rejectReasonFor 将请求解析错误映射为拒绝原因
+func rejectReasonFor(err error) byte {
+	switch {
+	case errors.Is(err, encoding.ErrTruncated):
+		return RejectReasonMissingRequiredParameter
+	case errors.Is(err, encoding.ErrTrailingData):
+		return RejectReasonTooManyArguments
+	}
+	return RejectReasonInvalidTag
+}
+
+// decodeErrorPayload 解析Error PDU的服务数据，返回错误类别和错误代码；
+// 部分服务（如WritePropertyMultiple）的错误以上下文标签0包裹
+func decodeErrorPayload(data []byte) (uint32, uint32, error) {
+	d := encoding.NewDecoder(data)
+	wrapped := d.IsOpening(0)
+	if wrapped {
+		d.Opening(0)
+	}
+	class, err := d.Application()
+	if err != nil {
+		return 0, 0, err
+	}
+	code, err := d.Application()
+	if err != nil {
+		return 0, 0, err
+	}
+	classValue, ok1 := class.(encoding.Enumerated)
+	codeValue, ok2 := code.(encoding.Enumerated)
+	if !ok1 || !ok2 {
+		return 0, 0, fmt.Errorf("错误类别和错误代码应为Enumerated")
+	}
+	if wrapped {
+		if err := d.Closing(0); err != nil {
+			return 0, 0, err
+		}
+	}
+	return uint32(classValue), uint32(codeValue), nil
+}
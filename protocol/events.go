@@ -0,0 +1,221 @@
+package protocol
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/iotzf/bacnet-server/encoding"
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// 默认事件通知优先级（未配置通知类时使用）
+const defaultEventPriority = 100
+
+// SendEventNotification 发送事件通知：通知类对象配置了Recipient_List时发给当前有效、接收该转换的接收者，
+// 否则以UnconfirmedEventNotification广播
+func (s *BACnetServer) SendEventNotification(notification model.EventNotification) error {
+	if notification.Priority == 0 {
+		notification.Priority = s.notificationPriority(notification.NotificationClass)
+	}
+
+	// 所有产生的通知都记录到事件日志对象
+	s.logEventNotification(notification)
+
+	if destinations, ok := s.notificationRecipients(notification); ok {
+		for _, dest := range destinations {
+			s.notifyRecipient(dest, notification)
+		}
+		return nil
+	}
+
+	apdu := []byte{BACnetAPDUTypeUnconfirmedServiceRequest << 4, BACnetServiceUnconfirmedEventNotification}
+	apdu = append(apdu, s.encodeEventNotification(notification)...)
+	n, err := s.broadcastNPDU(append([]byte{0x01, 0x00}, apdu...))
+	if err != nil {
+		return fmt.Errorf("发送事件通知失败: %v", err)
+	}
+
+	s.Logger().Debug("已广播事件通知", "object_type", notification.EventObject.Type, "instance", notification.EventObject.Instance,
+		"notify_type", notification.NotifyType, "to_state", notification.ToState, "bytes", n)
+	return nil
+}
+
+// notificationRecipients 返回通知类对象Recipient_List中当前有效且接收该转换的接收者，
+// 通知类对象不存在或没有配置Recipient_List时ok为false
+func (s *BACnetServer) notificationRecipients(notification model.EventNotification) (destinations []model.Destination, ok bool) {
+	if s.device == nil {
+		return nil, false
+	}
+	nc := s.device.FindObject(model.ObjectIdentifier{Type: model.ObjectTypeNotificationClass, Instance: notification.NotificationClass})
+	if nc == nil {
+		return nil, false
+	}
+	value, _ := nc.ReadProperty(model.PropertyIdentifierRecipientList)
+	list, ok := value.([]model.Destination)
+	if !ok || len(list) == 0 {
+		return nil, false
+	}
+	now := s.now()
+	// 位串从星期一开始
+	weekday := (int(now.Weekday()) + 6) % 7
+	timeOfDay := now.Sub(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()))
+	transition := model.TransitionForState(notification.ToState)
+	for _, dest := range list {
+		if len(dest.ValidDays) > 0 && !dest.ValidDays.Bit(weekday) {
+			continue
+		}
+		// To_Time为00:00表示到当天结束
+		from, to := dest.FromTime.Duration(), dest.ToTime.Duration()
+		if to == 0 {
+			to = 24 * time.Hour
+		}
+		if timeOfDay < from || timeOfDay > to {
+			continue
+		}
+		if len(dest.Transitions) > 0 && !dest.Transitions.Bit(transition) {
+			continue
+		}
+		destinations = append(destinations, dest)
+	}
+	return destinations, true
+}
+
+// notifyRecipient 向一个接收者发送事件通知。接收者为设备时可能要先用Who-Is查找地址，
+// 确认通知要等待应答，因此在接收者的发送队列中按顺序发送；关闭服务端时等待已排队的通知完成
+func (s *BACnetServer) notifyRecipient(dest model.Destination, notification model.EventNotification) {
+	notification.ProcessID = dest.ProcessIdentifier
+	parameters := s.encodeEventNotification(notification)
+	if !dest.IssueConfirmedNotifications {
+		apdu := append([]byte{BACnetAPDUTypeUnconfirmedServiceRequest << 4, BACnetServiceUnconfirmedEventNotification}, parameters...)
+		s.sendToRecipient(dest.Recipient, apdu, "事件通知")
+		return
+	}
+	s.enqueueToRecipient(dest.Recipient, "事件通知", func() {
+		addr, target, err := s.resolveRecipient(dest.Recipient)
+		if err != nil {
+			s.Logger().Warn("事件通知的接收者无法解析", "recipient", recipientString(dest.Recipient), "error", err)
+			return
+		}
+		reply, err := s.sendRoutedRequest(addr, target, BACnetServiceConfirmedEventNotification, parameters)
+		if err == nil {
+			err = reply.Err()
+		}
+		if err != nil {
+			s.Logger().Warn("确认事件通知失败", "recipient", recipientString(dest.Recipient), "error", err)
+		}
+	})
+}
+
+// recipientString 返回通知接收者的可读形式，用于日志
+func recipientString(recipient model.Recipient) string {
+	switch {
+	case recipient.Device != nil:
+		return fmt.Sprintf("device:%d", recipient.Device.Instance)
+	case recipient.Address != nil:
+		return fmt.Sprintf("%d:%x", recipient.Address.Network, recipient.Address.MAC)
+	}
+	return "-"
+}
+
+// encodeEventNotification 编码事件通知服务参数
+func (s *BACnetServer) encodeEventNotification(n model.EventNotification) []byte {
+	out := encoding.EncodeContextUnsigned(0, n.ProcessID)
+	out = append(out, encoding.EncodeContextObjectIdentifier(1, s.device.GetObjectIdentifier())...)
+	out = append(out, encoding.EncodeContextObjectIdentifier(2, n.EventObject)...)
+	out = append(out, encodeTimeStamp(3, n.TimeStamp)...)
+	out = append(out, encoding.EncodeContextUnsigned(4, n.NotificationClass)...)
+	out = append(out, encoding.EncodeContextUnsigned(5, uint32(n.Priority))...)
+	out = append(out, encoding.EncodeContextEnumerated(6, uint32(n.EventType))...)
+	if n.MessageText != "" {
+		out = append(out, encoding.EncodeContextCharacterString(7, n.MessageText)...)
+	}
+	out = append(out, encoding.EncodeContextEnumerated(8, uint32(n.NotifyType))...)
+	// 确认通知不携带ackRequired和fromState
+	if n.NotifyType != model.NotifyTypeAckNotification {
+		out = append(out, encoding.EncodeContextBoolean(9, n.AckRequired)...)
+		out = append(out, encoding.EncodeContextEnumerated(10, uint32(n.FromState))...)
+	}
+	out = append(out, encoding.EncodeContextEnumerated(11, uint32(n.ToState))...)
+	switch values := n.EventValues.(type) {
+	case model.OutOfRangeEventValues:
+		out = append(out, encoding.EncodeOpeningTag(12)...)
+		out = append(out, encoding.EncodeOpeningTag(5)...)
+		out = append(out, encoding.EncodeContextReal(0, values.ExceedingValue)...)
+		out = append(out, encoding.EncodeContextBitString(1, model.StatusFlagsBits(values.StatusFlags))...)
+		out = append(out, encoding.EncodeContextReal(2, values.Deadband)...)
+		out = append(out, encoding.EncodeContextReal(3, values.ExceededLimit)...)
+		out = append(out, encoding.EncodeClosingTag(5)...)
+		out = append(out, encoding.EncodeClosingTag(12)...)
+	case model.BufferReadyEventValues:
+		out = append(out, encoding.EncodeOpeningTag(12)...)
+		out = append(out, encoding.EncodeOpeningTag(10)...)
+		out = append(out, encodeDeviceObjectPropertyReference(0, values.BufferProperty)...)
+		out = append(out, encoding.EncodeContextUnsigned(1, values.PreviousNotification)...)
+		out = append(out, encoding.EncodeContextUnsigned(2, values.CurrentNotification)...)
+		out = append(out, encoding.EncodeClosingTag(10)...)
+		out = append(out, encoding.EncodeClosingTag(12)...)
+	}
+	return out
+}
+
+// encodeDeviceObjectPropertyReference 以开始/结束标签包裹编码BACnetDeviceObjectPropertyReference
+func encodeDeviceObjectPropertyReference(number uint8, ref model.DeviceObjectPropertyReference) []byte {
+	out := encoding.EncodeOpeningTag(number)
+	out = append(out, encoding.EncodeContextObjectIdentifier(0, ref.ObjectIdentifier)...)
+	out = append(out, encoding.EncodeContextEnumerated(1, uint32(ref.PropertyIdentifier))...)
+	if ref.ArrayIndex != nil {
+		out = append(out, encoding.EncodeContextUnsigned(2, *ref.ArrayIndex)...)
+	}
+	if ref.DeviceIdentifier != nil {
+		out = append(out, encoding.EncodeContextObjectIdentifier(3, *ref.DeviceIdentifier)...)
+	}
+	return append(out, encoding.EncodeClosingTag(number)...)
+}
+
+// logEventNotification 将事件通知追加到设备中的所有事件日志对象
+func (s *BACnetServer) logEventNotification(notification model.EventNotification) {
+	if s.device == nil {
+		return
+	}
+	now := s.now()
+	for _, obj := range s.device.Objects() {
+		if eventLog, ok := obj.(*model.EventLog); ok {
+			eventLog.LogNotification(now, notification)
+		}
+	}
+}
+
+// notificationPriority 从通知类对象读取事件优先级
+func (s *BACnetServer) notificationPriority(class uint32) uint8 {
+	if s.device == nil {
+		return defaultEventPriority
+	}
+	nc := s.device.FindObject(model.ObjectIdentifier{Type: model.ObjectTypeNotificationClass, Instance: class})
+	if nc == nil {
+		return defaultEventPriority
+	}
+	value, _ := nc.ReadProperty(model.PropertyIdentifierPriority)
+	switch v := value.(type) {
+	case int:
+		return uint8(v)
+	case uint8:
+		return v
+	case uint32:
+		return uint8(v)
+	}
+	return defaultEventPriority
+}
+
+// broadcastAddr 返回本地广播地址，优先使用配置的地址
+func (s *BACnetServer) broadcastAddr() *net.UDPAddr {
+	if s.broadcast != nil {
+		return s.broadcast
+	}
+	if s.transport != nil {
+		if addr := udpAddr(s.transport.BroadcastAddr()); addr != nil {
+			return addr
+		}
+	}
+	return &net.UDPAddr{IP: net.IPv4bcast, Port: 47808}
+}
@@ -0,0 +1,146 @@
+package protocol
+
+import (
+	"net"
+	"time"
+
+	"github.com/iotzf/bacnet-server/encoding"
+)
+
+// fdtGracePeriod 外部设备注册在TTL之外的宽限时间（Annex J.5.2.3）
+const fdtGracePeriod = 30 * time.Second
+
+// ForeignDevice 外部设备表（FDT）表项
+type ForeignDevice struct {
+	Address *net.UDPAddr
+	TTL     uint16    // 注册时请求的生存时间（秒）
+	Expires time.Time // TTL加宽限时间后的过期时间
+}
+
+// remaining 返回距过期的剩余秒数，最大65535
+func (fd ForeignDevice) remaining(now time.Time) uint16 {
+	seconds := fd.Expires.Sub(now) / time.Second
+	if seconds < 0 {
+		return 0
+	}
+	if seconds > 0xFFFF {
+		return 0xFFFF
+	}
+	return uint16(seconds)
+}
+
+// ForeignDeviceTable 返回当前未过期的外部设备
+func (s *BACnetServer) ForeignDeviceTable() []ForeignDevice {
+	return s.foreignDevices(s.now())
+}
+
+// foreignDevices 清除过期的注册并返回其余外部设备
+func (s *BACnetServer) foreignDevices(now time.Time) []ForeignDevice {
+	s.bdtMu.Lock()
+	defer s.bdtMu.Unlock()
+	var devices []ForeignDevice
+	for key, fd := range s.fdt {
+		if !now.Before(fd.Expires) {
+			s.Logger().Info("外部设备注册已过期", "peer", fd.Address.String())
+			delete(s.fdt, key)
+			continue
+		}
+		devices = append(devices, fd)
+	}
+	return devices
+}
+
+// registerForeignDevice 添加或刷新外部设备注册
+func (s *BACnetServer) registerForeignDevice(addr *net.UDPAddr, ttl uint16, now time.Time) {
+	s.bdtMu.Lock()
+	defer s.bdtMu.Unlock()
+	if s.fdt == nil {
+		s.fdt = make(map[string]ForeignDevice)
+	}
+	s.fdt[addr.String()] = ForeignDevice{
+		Address: addr,
+		TTL:     ttl,
+		Expires: now.Add(time.Duration(ttl)*time.Second + fdtGracePeriod),
+	}
+}
+
+// isForeignDevice 判断地址是否为已注册的外部设备
+func (s *BACnetServer) isForeignDevice(addr *net.UDPAddr, now time.Time) bool {
+	s.bdtMu.Lock()
+	defer s.bdtMu.Unlock()
+	fd, ok := s.fdt[addr.String()]
+	return ok && now.Before(fd.Expires)
+}
+
+// handleRegisterForeignDevice 处理Register-Foreign-Device：参数为2字节TTL
+func (s *BACnetServer) handleRegisterForeignDevice(r *encoding.Reader, from *net.UDPAddr) []byte {
+	ttl, err := r.Uint16()
+	if !s.bbmd || from == nil || err != nil {
+		return encodeBVLCResult(BVLCResultRegisterForeignDeviceNAK)
+	}
+	s.registerForeignDevice(from, ttl, s.now())
+	s.Logger().Info("外部设备已注册", "peer", from.String(), "ttl", ttl)
+	return encodeBVLCResult(BVLCResultSuccessfulCompletion)
+}
+
+// handleReadFDT 处理Read-Foreign-Device-Table，表项为B/IP地址(6) + TTL(2) + 剩余时间(2)
+func (s *BACnetServer) handleReadFDT() []byte {
+	if !s.bbmd {
+		return encodeBVLCResult(BVLCResultReadFDTNAK)
+	}
+	now := s.now()
+	var payload []byte
+	for _, fd := range s.foreignDevices(now) {
+		remaining := fd.remaining(now)
+		payload = append(payload, encodeBIPAddress(fd.Address)...)
+		payload = append(payload, byte(fd.TTL>>8), byte(fd.TTL), byte(remaining>>8), byte(remaining))
+	}
+	return encodeBVLC(BVLCReadForeignDeviceTableAck, payload)
+}
+
+// handleDeleteFDTEntry 处理Delete-Foreign-Device-Table-Entry：参数为要删除表项的B/IP地址
+func (s *BACnetServer) handleDeleteFDTEntry(r *encoding.Reader) []byte {
+	addr, err := readBIPAddress(r)
+	if !s.bbmd || err != nil {
+		return encodeBVLCResult(BVLCResultDeleteFDTEntryNAK)
+	}
+	s.bdtMu.Lock()
+	_, ok := s.fdt[addr.String()]
+	delete(s.fdt, addr.String())
+	s.bdtMu.Unlock()
+	if !ok {
+		return encodeBVLCResult(BVLCResultDeleteFDTEntryNAK)
+	}
+	s.Logger().Info("外部设备已从FDT删除", "peer", addr.String())
+	return encodeBVLCResult(BVLCResultSuccessfulCompletion)
+}
+
+// handleDistributeBroadcast 处理外部设备的Distribute-Broadcast-To-Network：
+// 转发给BDT中的其他BBMD、本地子网和其他外部设备，本设备也作为本地子网的一员处理该广播
+func (s *BACnetServer) handleDistributeBroadcast(ctx *RequestContext, npdu []byte) ([]byte, error) {
+	from := udpAddr(ctx.ReplyAddr)
+	if !s.bbmd || from == nil || !s.isForeignDevice(from, s.now()) {
+		return encodeBVLCResult(BVLCResultDistributeBroadcastToNetNAK), nil
+	}
+	frames := s.broadcastForwards(npdu, from)
+	frames = append(frames, bvlcFrame{addr: s.broadcastAddr(), data: encodeForwardedNPDU(from, npdu)})
+	s.sendFrames(frames)
+
+	response, err := s.handleBroadcastMessage(ctx, npdu)
+	if err != nil {
+		return nil, err
+	}
+	return frameResponse(response), nil
+}
+
+// foreignDeviceForwards 将Forwarded-NPDU发给除origin外的所有外部设备
+func (s *BACnetServer) foreignDeviceForwards(message []byte, origin *net.UDPAddr) []bvlcFrame {
+	var frames []bvlcFrame
+	for _, fd := range s.foreignDevices(s.now()) {
+		if fd.Address.IP.Equal(origin.IP) && fd.Address.Port == origin.Port {
+			continue
+		}
+		frames = append(frames, bvlcFrame{addr: fd.Address, data: message})
+	}
+	return frames
+}
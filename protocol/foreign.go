@@ -0,0 +1,96 @@
+package protocol
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/iotzf/bacnet-server/encoding"
+)
+
+// RegisterAsForeignDevice 设置作为外部设备注册的远程BBMD，Start后定期续订注册，
+// 广播改为经该BBMD以Distribute-Broadcast-To-Network发送
+func (s *BACnetServer) RegisterAsForeignDevice(bbmd string, ttl uint16) error {
+	addr, err := net.ResolveUDPAddr("udp4", bbmd)
+	if err != nil {
+		return fmt.Errorf("无效的BBMD地址: %v", err)
+	}
+	if ttl == 0 {
+		return fmt.Errorf("外部设备注册TTL不能为0")
+	}
+	s.bbmdAddr = addr
+	s.foreignTTL = ttl
+	return nil
+}
+
+// runForeignDeviceRegistration 向BBMD注册，并在TTL过半时续订
+func (s *BACnetServer) runForeignDeviceRegistration() {
+	s.registerWithBBMD()
+	ticker := s.Clock().NewTicker(time.Duration(s.foreignTTL) * time.Second / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			s.registerWithBBMD()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// registerWithBBMD 发送Register-Foreign-Device
+func (s *BACnetServer) registerWithBBMD() {
+	if s.transport == nil {
+		return
+	}
+	message := encodeBVLC(BVLCRegisterForeignDevice, []byte{byte(s.foreignTTL >> 8), byte(s.foreignTTL)})
+	if _, err := s.writeTo(message, s.bbmdAddr); err != nil {
+		s.Logger().Warn("向BBMD注册失败", "bbmd", s.bbmdAddr.String(), "error", err)
+	}
+}
+
+// handleBVLCResult 处理收到的BVLC-Result，BBMD接受首次注册时广播I-Am
+func (s *BACnetServer) handleBVLCResult(r *encoding.Reader, from *net.UDPAddr) error {
+	code, err := r.Uint16()
+	if err != nil {
+		return fmt.Errorf("BVLC-Result: %w", err)
+	}
+	s.Logger().Debug("收到BVLC-Result", "result", bvlcResultName(code))
+
+	if s.bbmdAddr == nil || from == nil || !from.IP.Equal(s.bbmdAddr.IP) || from.Port != s.bbmdAddr.Port {
+		return nil
+	}
+	switch code {
+	case BVLCResultSuccessfulCompletion:
+		if atomic.CompareAndSwapInt32(&s.foreignRegistered, 0, 1) {
+			s.Logger().Info("已注册为BBMD的外部设备", "bbmd", s.bbmdAddr.String())
+			s.announce()
+		}
+	case BVLCResultRegisterForeignDeviceNAK:
+		atomic.StoreInt32(&s.foreignRegistered, 0)
+	}
+	return nil
+}
+
+// broadcastNPDU 广播NPDU：外部设备模式下经BBMD分发，否则在本地子网广播
+func (s *BACnetServer) broadcastNPDU(npdu []byte) (int, error) {
+	if s.transport == nil {
+		return 0, errTransportNotInitialized
+	}
+	if s.bbmdAddr != nil {
+		return s.writeTo(encodeBVLC(BVLCDistributeBroadcastToNetwork, npdu), s.bbmdAddr)
+	}
+	return s.writeTo(encodeBVLC(BVLCOriginalBroadcastNPDU, npdu), s.broadcastAddr())
+}
+
+// announce 广播I-Am
+func (s *BACnetServer) announce() {
+	if s.device == nil {
+		return
+	}
+	if _, err := s.broadcastNPDU(append([]byte{0x01, 0x00}, s.encodeIAm()...)); err != nil {
+		s.Logger().Warn("广播I-Am失败", "error", err)
+	}
+}
@@ -0,0 +1,148 @@
+package protocol
+
+import (
+	"sync"
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// Operation 钩子收到的一次属性访问或COV订阅
+type Operation struct {
+	Peer       string                   // 客户端地址，本地调用时为空
+	Object     model.Object             // 访问的对象
+	Property   model.PropertyIdentifier // 访问的属性，SubscribeCOV时为Present_Value
+	ArrayIndex *uint32                  // 数组下标，为nil时访问整个属性
+	Value      interface{}              // 读到或要写入的值，订阅时为model.COVSubscription，钩子可以替换
+	Priority   uint8                    // 写入优先级，读取和订阅时为0
+}
+
+// Hook 拦截属性访问的钩子，返回错误时拒绝该操作。
+// 返回*Error时按其错误类别和代码应答，其他错误按操作应答读或写访问被拒绝
+type Hook func(op *Operation) error
+
+// hooks 服务端注册的钩子，按注册顺序调用
+type hooks struct {
+	mu          sync.RWMutex
+	beforeRead  []Hook
+	afterRead   []Hook
+	beforeWrite []Hook
+	afterWrite  []Hook
+	onSubscribe []Hook
+}
+
+// BeforeRead 注册读取属性前调用的钩子。钩子设置了op.Value时不再读取对象，直接以该值应答
+func (s *BACnetServer) BeforeRead(hook Hook) {
+	s.hooks.add(&s.hooks.beforeRead, hook)
+}
+
+// AfterRead 注册读取属性后调用的钩子，可以替换读到的值
+func (s *BACnetServer) AfterRead(hook Hook) {
+	s.hooks.add(&s.hooks.afterRead, hook)
+}
+
+// BeforeWrite 注册写入属性前调用的钩子，可以替换要写入的值和优先级
+func (s *BACnetServer) BeforeWrite(hook Hook) {
+	s.hooks.add(&s.hooks.beforeWrite, hook)
+}
+
+// AfterWrite 注册写入属性成功后调用的钩子，写入已生效，钩子返回的错误只记录日志
+func (s *BACnetServer) AfterWrite(hook Hook) {
+	s.hooks.add(&s.hooks.afterWrite, hook)
+}
+
+// OnSubscribe 注册创建COV订阅前调用的钩子，可以修改订阅（如限制Lifetime）
+func (s *BACnetServer) OnSubscribe(hook Hook) {
+	s.hooks.add(&s.hooks.onSubscribe, hook)
+}
+
+// add 将钩子加入列表
+func (h *hooks) add(list *[]Hook, hook Hook) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	*list = append(*list, hook)
+}
+
+// run 按注册顺序调用钩子，第一个返回错误的钩子中止操作，
+// 不是*Error的错误以errorClass和errorCode应答
+func (h *hooks) run(list *[]Hook, op *Operation, errorClass, errorCode byte) error {
+	h.mu.RLock()
+	hooks := *list
+	h.mu.RUnlock()
+	for _, hook := range hooks {
+		if err := hook(op); err != nil {
+			return asError(err, errorClass, errorCode)
+		}
+	}
+	return nil
+}
+
+// readProperty 经钩子读取属性值
+func (s *BACnetServer) readProperty(ctx *RequestContext, obj model.Object, prop model.PropertyIdentifier, arrayIndex *uint32) (interface{}, error) {
+	op := &Operation{Peer: ctx.peer(), Object: obj, Property: prop, ArrayIndex: arrayIndex}
+	if err := s.hooks.run(&s.hooks.beforeRead, op, ErrorClassProperty, ErrorCodeReadAccessDenied); err != nil {
+		return nil, err
+	}
+	if op.Value == nil {
+		value, err := readPropertyValue(obj, prop, arrayIndex)
+		if err != nil {
+			return nil, err
+		}
+		op.Value = value
+	}
+	if err := s.hooks.run(&s.hooks.afterRead, op, ErrorClassProperty, ErrorCodeReadAccessDenied); err != nil {
+		return nil, err
+	}
+	return op.Value, nil
+}
+
+// writeProperty 经写保护和钩子校验并按优先级写入属性值
+// 成功和失败的写入都记入审计日志
+func (s *BACnetServer) writeProperty(ctx *RequestContext, obj model.Object, prop model.PropertyIdentifier, value interface{}, priority uint8) error {
+	return s.writePropertyElement(ctx, obj, prop, nil, value, priority)
+}
+
+// writePropertyElement 同writeProperty，arrayIndex不为nil时value为数组的一个元素（下标0为数组长度），
+// 替换该元素后按整个属性值校验和写入，钩子收到的是元素的值
+func (s *BACnetServer) writePropertyElement(ctx *RequestContext, obj model.Object, prop model.PropertyIdentifier, arrayIndex *uint32, value interface{}, priority uint8) (err error) {
+	op := &Operation{Peer: ctx.peer(), Object: obj, Property: prop, ArrayIndex: arrayIndex, Value: value, Priority: priority}
+	defer func() { s.auditWrite(ctx, obj, prop, op.Value, op.Priority, err) }()
+	if err := s.protections.checkWrite(ctx, obj, prop); err != nil {
+		return err
+	}
+	if err := s.hooks.run(&s.hooks.beforeWrite, op, ErrorClassProperty, ErrorCodeWriteAccessDenied); err != nil {
+		return err
+	}
+	value = op.Value
+	if op.ArrayIndex != nil {
+		if value, err = model.PropertyWithElement(obj, prop, *op.ArrayIndex, op.Value); err != nil {
+			return err
+		}
+	}
+	if err := model.ValidateWrite(obj, prop, value); err != nil {
+		return err
+	}
+	if err := model.WriteWithPriority(obj, prop, value, op.Priority); err != nil {
+		return err
+	}
+	if err := s.hooks.run(&s.hooks.afterWrite, op, ErrorClassProperty, ErrorCodeWriteAccessDenied); err != nil {
+		s.Logger().Warn("AfterWrite钩子失败", "peer", op.Peer, "object", obj.GetObjectName(), "property", prop, "error", err)
+	}
+	return nil
+}
+
+// subscribe 经钩子确认订阅，返回钩子修改后的订阅
+func (s *BACnetServer) subscribe(ctx *RequestContext, obj model.Object, prop model.PropertyIdentifier, subscription model.COVSubscription) (model.COVSubscription, error) {
+	op := &Operation{Peer: ctx.peer(), Object: obj, Property: prop, Value: subscription}
+	if err := s.hooks.run(&s.hooks.onSubscribe, op, ErrorClassCov, ErrorCodeCovSubscriptionFailed); err != nil {
+		return subscription, err
+	}
+	if modified, ok := op.Value.(model.COVSubscription); ok {
+		subscription = modified
+	}
+	// 到期时间按钩子确定的有效期计算，由对象调度定期移除
+	if subscription.Lifetime > 0 {
+		subscription.Expires = subscription.Timestamp.Add(time.Duration(subscription.Lifetime) * time.Second)
+	}
+	return subscription, nil
+}
@@ -0,0 +1,92 @@
+package protocol
+
+import (
+	"github.com/iotzf/bacnet-server/encoding"
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// LifeSafetyOperationRequest LifeSafetyOperation请求结构
+type LifeSafetyOperationRequest struct {
+	ProcessID        uint32
+	RequestingSource string
+	Operation        model.LifeSafetyOperation
+	ObjectID         *model.ObjectIdentifier // 为空时作用于设备中所有生命安全对象
+}
+
+// parseLifeSafetyOperationRequest 解析LifeSafetyOperation请求
+//
+//	LifeSafetyOperation-Request ::= SEQUENCE {
+//	  requestingProcessIdentifier [0] Unsigned32,
+//	  requestingSource            [1] CharacterString,
+//	  request                     [2] BACnetLifeSafetyOperation,
+//	  objectIdentifier            [3] BACnetObjectIdentifier OPTIONAL }
+func parseLifeSafetyOperationRequest(data []byte) (LifeSafetyOperationRequest, error) {
+	var request LifeSafetyOperationRequest
+	d := encoding.NewDecoder(data)
+
+	var err error
+	if request.ProcessID, err = d.ContextUnsigned(0); err != nil {
+		return request, err
+	}
+	if request.RequestingSource, err = d.ContextCharacterString(1); err != nil {
+		return request, err
+	}
+	operation, err := d.ContextUnsigned(2)
+	if err != nil {
+		return request, err
+	}
+	request.Operation = model.LifeSafetyOperation(operation)
+
+	if d.IsContext(3) {
+		oid, err := d.ContextObjectIdentifier(3)
+		if err != nil {
+			return request, err
+		}
+		request.ObjectID = &oid
+	}
+	return request, nil
+}
+
+// handleLifeSafetyOperation 处理LifeSafetyOperation请求
+func (s *BACnetServer) handleLifeSafetyOperation(data []byte, invokeID byte) ([]byte, error) {
+	request, err := parseLifeSafetyOperationRequest(data)
+	if err != nil {
+		// 请求格式错误，按BACnet协议拒绝
+		return s.createRejectResponse(invokeID, rejectReasonFor(err)), nil
+	}
+	if request.Operation > model.LifeSafetyOperationUnsilenceVisual {
+		return s.createErrorResponse(invokeID, BACnetServiceConfirmedLifeSafetyOperation,
+			ErrorClassService, ErrorCodeValueOutOfRange), nil
+	}
+
+	var targets []*model.LifeSafety
+	if request.ObjectID != nil {
+		targetObj := s.device.FindObject(*request.ObjectID)
+		if targetObj == nil {
+			return s.createErrorResponse(invokeID, BACnetServiceConfirmedLifeSafetyOperation,
+				ErrorClassObject, ErrorCodeObjectNotExist), nil
+		}
+		lifeSafety, ok := targetObj.(*model.LifeSafety)
+		if !ok {
+			return s.createErrorResponse(invokeID, BACnetServiceConfirmedLifeSafetyOperation,
+				ErrorClassObject, ErrorCodeObjectNotOfRequiredType), nil
+		}
+		targets = append(targets, lifeSafety)
+	} else {
+		for _, obj := range s.device.Objects() {
+			if lifeSafety, ok := obj.(*model.LifeSafety); ok {
+				targets = append(targets, lifeSafety)
+			}
+		}
+	}
+
+	for _, target := range targets {
+		if err := target.Operate(request.Operation); err != nil {
+			return s.createErrorResponse(invokeID, BACnetServiceConfirmedLifeSafetyOperation,
+				ErrorClassService, ErrorCodeValueOutOfRange), nil
+		}
+	}
+
+	s.Logger().Debug("LifeSafetyOperation", "source", request.RequestingSource, "operation", request.Operation, "objects", len(targets))
+	return encodeSimpleAck(invokeID, BACnetServiceConfirmedLifeSafetyOperation), nil
+}
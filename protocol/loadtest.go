@@ -0,0 +1,349 @@
+package protocol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/iotzf/bacnet-server/encoding"
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// LoadService 负载测试发出的一种请求
+type LoadService uint8
+
+const (
+	LoadWhoIs                LoadService = iota // 只询问目标设备的Who-Is，延迟为收到I-Am的时间
+	LoadReadProperty                            // 读取对象的Present_Value
+	LoadReadPropertyMultiple                    // 读取对象的Present_Value、Status_Flags和Object_Name
+	LoadWriteProperty                           // 写入对象的Present_Value
+	LoadSubscribeCOV                            // 订阅对象的COV通知
+	loadServiceCount
+)
+
+var loadServiceNames = []string{"whois", "rp", "rpm", "wp", "cov"}
+
+func (s LoadService) String() string {
+	if int(s) < len(loadServiceNames) {
+		return loadServiceNames[s]
+	}
+	return fmt.Sprintf("service-%d", s)
+}
+
+// LoadMix 各种请求所占的权重，按LoadService索引
+type LoadMix [loadServiceCount]int
+
+// DefaultLoadMix 以读取为主、少量写入和订阅的负载
+var DefaultLoadMix = LoadMix{LoadWhoIs: 5, LoadReadProperty: 60, LoadReadPropertyMultiple: 20, LoadWriteProperty: 10, LoadSubscribeCOV: 5}
+
+// ParseLoadMix 解析whois=5,rp=60,rpm=20,wp=10,cov=5形式的权重，未给出的请求权重为0
+func ParseLoadMix(text string) (LoadMix, error) {
+	var mix LoadMix
+	total := 0
+	for _, part := range strings.Split(text, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		weight, err := strconv.Atoi(value)
+		if !ok || err != nil || weight < 0 {
+			return mix, fmt.Errorf("无效的请求权重: %q", part)
+		}
+		service := -1
+		for i, n := range loadServiceNames {
+			if n == name {
+				service = i
+			}
+		}
+		if service < 0 {
+			return mix, fmt.Errorf("未知的请求: %s，应为whois、rp、rpm、wp或cov", name)
+		}
+		mix[service] = weight
+		total += weight
+	}
+	if total == 0 {
+		return mix, errors.New("请求权重之和为0")
+	}
+	return mix, nil
+}
+
+// String 返回ParseLoadMix接受的格式，省略权重为0的请求
+func (m LoadMix) String() string {
+	var parts []string
+	for i, weight := range m {
+		if weight > 0 {
+			parts = append(parts, fmt.Sprintf("%s=%d", LoadService(i), weight))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// pick 按权重随机选择一种请求
+func (m LoadMix) pick(rnd *rand.Rand) LoadService {
+	total := 0
+	for _, weight := range m {
+		total += weight
+	}
+	n := rnd.Intn(total)
+	for i, weight := range m {
+		if n < weight {
+			return LoadService(i)
+		}
+		n -= weight
+	}
+	return LoadReadProperty
+}
+
+// LoadTest 对一个设备按固定速率发出混合请求并统计延迟。请求按计划时间发出，
+// 不等待之前的应答，因此服务端变慢时表现为延迟升高和请求被跳过，而不是速率下降
+type LoadTest struct {
+	Target   net.Addr
+	Device   uint32                   // 目标设备的实例号，Who-Is使用
+	Objects  []model.ObjectIdentifier // 读写和订阅的对象，轮流使用
+	Mix      LoadMix
+	Rate     float64       // 每秒发出的请求数
+	Duration time.Duration // 持续时间
+	Priority uint8         // WriteProperty的优先级，为0时不携带
+	Lifetime uint32        // SubscribeCOV的有效期（秒），为0时为60秒
+}
+
+// LoadStats 一种请求的统计，延迟只计成功的请求
+type LoadStats struct {
+	Service            string
+	Requests           int   // 完成的请求数
+	Errors             int   // 错误应答、拒绝和超时
+	LastError          error // 最后一个错误
+	P50, P90, P99, Max time.Duration
+}
+
+// LoadReport 负载测试的结果
+type LoadReport struct {
+	Elapsed  time.Duration
+	Skipped  int         // 到时没有空闲客户端而跳过的请求
+	Services []LoadStats // 按LoadService排列，只包含发出过的请求
+	Total    LoadStats
+}
+
+// loadJob 交给客户端执行的一个请求
+type loadJob struct {
+	service LoadService
+	object  model.ObjectIdentifier
+	value   interface{} // WriteProperty写入的值
+}
+
+// loadSample 一个请求的结果
+type loadSample struct {
+	service LoadService
+	latency time.Duration
+	err     error
+}
+
+// loadTick 检查到期请求的周期
+const loadTick = time.Millisecond
+
+// Run 用clients并发发出请求直到Duration结束或ctx取消，每个客户端同时只有一个未完成的请求，
+// 因此客户端数决定了最大并发数。开始前读取每个对象的Present_Value以确认对象存在并确定写入值的类型
+func (t *LoadTest) Run(ctx context.Context, clients []*TestClient) (*LoadReport, error) {
+	if len(clients) == 0 {
+		return nil, errors.New("没有客户端")
+	}
+	if t.Rate <= 0 || t.Duration <= 0 {
+		return nil, errors.New("速率和持续时间应为正数")
+	}
+	if t.Mix == (LoadMix{}) {
+		t.Mix = DefaultLoadMix
+	}
+	// 只有Who-Is时不需要对象
+	if onlyWhoIs := t.Mix == (LoadMix{LoadWhoIs: t.Mix[LoadWhoIs]}); len(t.Objects) == 0 && !onlyWhoIs {
+		return nil, errors.New("没有读写的对象")
+	}
+	values := make([]interface{}, len(t.Objects))
+	for i, oid := range t.Objects {
+		value, err := clients[0].ReadProperty(t.Target, oid, model.PropertyIdentifierPresentValue)
+		if err != nil {
+			return nil, fmt.Errorf("读取%s失败: %w", objectID(oid), err)
+		}
+		values[i] = value
+	}
+
+	jobs := make(chan loadJob, len(clients))
+	results := make(chan []loadSample, len(clients))
+	for _, client := range clients {
+		go func() {
+			var samples []loadSample
+			for job := range jobs {
+				start := time.Now()
+				err := t.execute(client, job)
+				samples = append(samples, loadSample{service: job.service, latency: time.Since(start), err: err})
+			}
+			results <- samples
+		}()
+	}
+
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	ticker := time.NewTicker(loadTick)
+	start := time.Now()
+	issued, skipped, next := 0, 0, 0
+	for done := false; !done; {
+		select {
+		case <-ctx.Done():
+			done = true
+		case now := <-ticker.C:
+			elapsed := now.Sub(start)
+			if elapsed >= t.Duration {
+				elapsed, done = t.Duration, true
+			}
+			for due := int(elapsed.Seconds() * t.Rate); issued < due; issued++ {
+				job := loadJob{service: t.Mix.pick(rnd)}
+				if len(t.Objects) > 0 {
+					job.object = t.Objects[next%len(t.Objects)]
+					job.value = loadWriteValue(values[next%len(t.Objects)], rnd)
+					next++
+				}
+				select {
+				case jobs <- job:
+				default:
+					skipped++
+				}
+			}
+		}
+	}
+	ticker.Stop()
+	close(jobs)
+
+	report := &LoadReport{Skipped: skipped}
+	var samples []loadSample
+	for range clients {
+		samples = append(samples, <-results...)
+	}
+	report.Elapsed = time.Since(start)
+	report.summarize(samples)
+	return report, ctx.Err()
+}
+
+// execute 用client执行一个请求
+func (t *LoadTest) execute(client *TestClient, job loadJob) error {
+	switch job.service {
+	case LoadWhoIs:
+		_, err := client.Locate(t.Target, t.Device)
+		return err
+	case LoadReadPropertyMultiple:
+		_, err := client.ReadPropertyMultiple(t.Target, []ReadAccessSpecification{{ObjectID: job.object, Properties: []PropertyReference{
+			{PropertyID: model.PropertyIdentifierPresentValue},
+			{PropertyID: model.PropertyIdentifierStatusFlags},
+			{PropertyID: model.PropertyIdentifierObjectName},
+		}}})
+		return err
+	case LoadWriteProperty:
+		return client.WriteProperty(t.Target, job.object, model.PropertyIdentifierPresentValue, job.value, t.Priority)
+	case LoadSubscribeCOV:
+		lifetime := t.Lifetime
+		if lifetime == 0 {
+			lifetime = 60
+		}
+		// 同一客户端对同一对象的重复订阅更新原有的订阅
+		return client.SubscribeCOV(t.Target, 1, job.object, lifetime, false)
+	default:
+		_, err := client.ReadProperty(t.Target, job.object, model.PropertyIdentifierPresentValue)
+		return err
+	}
+}
+
+// loadWriteValue 按对象当前值的类型生成要写入的值：实数在当前值附近变化，二进制取随机状态，其他类型写回原值
+func loadWriteValue(current interface{}, rnd *rand.Rand) interface{} {
+	switch v := current.(type) {
+	case float32:
+		return v + float32(rnd.Intn(21)-10)/10
+	case encoding.Enumerated:
+		if v <= 1 {
+			return encoding.Enumerated(rnd.Intn(2))
+		}
+	}
+	return current
+}
+
+// summarize 按请求汇总样本
+func (r *LoadReport) summarize(samples []loadSample) {
+	var latencies [loadServiceCount][]time.Duration
+	var stats [loadServiceCount]LoadStats
+	var all []time.Duration
+	for _, s := range samples {
+		st := &stats[s.service]
+		st.Requests++
+		if s.err != nil {
+			st.Errors++
+			st.LastError = s.err
+			r.Total.Errors++
+			r.Total.LastError = s.err
+			continue
+		}
+		latencies[s.service] = append(latencies[s.service], s.latency)
+		all = append(all, s.latency)
+	}
+	for i := range stats {
+		if stats[i].Requests == 0 {
+			continue
+		}
+		stats[i].Service = LoadService(i).String()
+		stats[i].percentiles(latencies[i])
+		r.Services = append(r.Services, stats[i])
+	}
+	r.Total.Service = "total"
+	r.Total.Requests = len(samples)
+	r.Total.percentiles(all)
+}
+
+// percentiles 按最近秩计算延迟的百分位数
+func (s *LoadStats) percentiles(latencies []time.Duration) {
+	if len(latencies) == 0 {
+		return
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	rank := func(p float64) time.Duration {
+		i := int(p*float64(len(latencies))+0.999999) - 1
+		if i < 0 {
+			i = 0
+		}
+		return latencies[i]
+	}
+	s.P50, s.P90, s.P99, s.Max = rank(0.50), rank(0.90), rank(0.99), latencies[len(latencies)-1]
+}
+
+// Throughput 返回每秒完成的请求数
+func (r *LoadReport) Throughput() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Total.Requests) / r.Elapsed.Seconds()
+}
+
+// WriteTo 以表格输出各请求的数量、错误和延迟百分位数
+func (r *LoadReport) WriteTo(w io.Writer) (int64, error) {
+	var sb strings.Builder
+	tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "SERVICE\tREQUESTS\tERRORS\tP50\tP90\tP99\tMAX\t")
+	for _, s := range append(r.Services, r.Total) {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t\n", s.Service, s.Requests, s.Errors,
+			roundLatency(s.P50), roundLatency(s.P90), roundLatency(s.P99), roundLatency(s.Max))
+	}
+	tw.Flush()
+	fmt.Fprintf(&sb, "%d requests in %s (%.1f/s), %d skipped\n", r.Total.Requests, r.Elapsed.Round(time.Millisecond), r.Throughput(), r.Skipped)
+	if r.Total.LastError != nil {
+		fmt.Fprintf(&sb, "last error: %v\n", r.Total.LastError)
+	}
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
+
+// roundLatency 将延迟取整到便于阅读的精度
+func roundLatency(d time.Duration) time.Duration {
+	if d >= time.Millisecond {
+		return d.Round(10 * time.Microsecond)
+	}
+	return d.Round(time.Microsecond)
+}
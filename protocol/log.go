@@ -0,0 +1,33 @@
+package protocol
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LevelPacket 逐个数据报记录处理过程的日志级别，低于slog.LevelDebug。
+// 日志级别为Debug及以上时不输出这些日志，也不为其格式化参数
+const LevelPacket = slog.LevelDebug - 4
+
+// SetLogger 设置服务端的日志，为nil时使用slog.Default()。应在Start之前调用
+func (s *BACnetServer) SetLogger(logger *slog.Logger) {
+	s.log = logger
+}
+
+// Logger 返回服务端使用的日志
+func (s *BACnetServer) Logger() *slog.Logger {
+	if s.log == nil {
+		return slog.Default()
+	}
+	return s.log
+}
+
+// packetLogging 判断是否记录逐个数据报的日志
+func (s *BACnetServer) packetLogging() bool {
+	return s.Logger().Enabled(context.Background(), LevelPacket)
+}
+
+// logPacket 以LevelPacket记录日志
+func (s *BACnetServer) logPacket(msg string, args ...any) {
+	s.Logger().Log(context.Background(), LevelPacket, msg, args...)
+}
@@ -0,0 +1,140 @@
+package protocol
+
+import (
+	"fmt"
+	"net"
+	"sync"
+)
+
+// loopbackPort 内存网段上的UDP端口号
+const loopbackPort = 47808
+
+// LoopbackNetwork 进程内模拟的BACnet/IP子网，不打开真实的套接字，
+// 用于在测试中端到端地运行服务端和客户端
+type LoopbackNetwork struct {
+	mu    sync.Mutex
+	ports map[string]*LoopbackTransport
+	next  byte
+}
+
+// NewLoopbackNetwork 创建一个空的内存网段，地址为192.0.2.0/24（TEST-NET-1）
+func NewLoopbackNetwork() *LoopbackNetwork {
+	return &LoopbackNetwork{ports: make(map[string]*LoopbackTransport)}
+}
+
+// Attach 在网段上创建一个传输，依次分配192.0.2.1、192.0.2.2……
+func (n *LoopbackNetwork) Attach() *LoopbackTransport {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.next++
+	t := &LoopbackTransport{
+		network: n,
+		addr:    &net.UDPAddr{IP: net.IPv4(192, 0, 2, n.next), Port: loopbackPort},
+		inbox:   make(chan loopbackDatagram, 64),
+		closed:  make(chan struct{}),
+	}
+	n.ports[t.addr.String()] = t
+	return t
+}
+
+// deliver 将数据报投递给目标地址，广播地址投递给除发送方外的所有传输。
+// 和UDP一样，目标不存在或接收队列已满时数据报被丢弃
+func (n *LoopbackNetwork) deliver(data []byte, from, to *net.UDPAddr) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var targets []*LoopbackTransport
+	if to.IP.Equal(net.IPv4bcast) || to.IP.Equal(net.IPv4(192, 0, 2, 255)) {
+		for _, t := range n.ports {
+			if t.addr.String() != from.String() {
+				targets = append(targets, t)
+			}
+		}
+	} else if t, ok := n.ports[to.String()]; ok {
+		targets = append(targets, t)
+	}
+	for _, t := range targets {
+		select {
+		case t.inbox <- loopbackDatagram{data: append([]byte(nil), data...), from: from}:
+		default:
+		}
+	}
+}
+
+// detach 将传输从网段移除
+func (n *LoopbackNetwork) detach(t *LoopbackTransport) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.ports, t.addr.String())
+}
+
+// loopbackDatagram 内存网段上的数据报
+type loopbackDatagram struct {
+	data []byte
+	from *net.UDPAddr
+}
+
+// LoopbackTransport 内存网段上的传输，实现Transport接口
+type LoopbackTransport struct {
+	network *LoopbackNetwork
+	addr    *net.UDPAddr
+	inbox   chan loopbackDatagram
+	closed  chan struct{}
+	close   sync.Once
+}
+
+// ReadFrom 读取下一个数据报，传输关闭后返回net.ErrClosed
+func (t *LoopbackTransport) ReadFrom(p []byte) (int, net.Addr, error) {
+	select {
+	case d := <-t.inbox:
+		return copy(p, d.data), d.from, nil
+	case <-t.closed:
+		return 0, nil, net.ErrClosed
+	}
+}
+
+// WriteTo 向网段上的地址发送数据报
+func (t *LoopbackTransport) WriteTo(p []byte, addr net.Addr) (int, error) {
+	select {
+	case <-t.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+	to := udpAddr(addr)
+	if to == nil {
+		return 0, fmt.Errorf("不支持的地址: %v", addr)
+	}
+	if len(p) > t.MTU() {
+		return 0, fmt.Errorf("数据报过长: %d字节", len(p))
+	}
+	t.network.deliver(p, t.addr, to)
+	return len(p), nil
+}
+
+// LocalAddr 返回分配的地址
+func (t *LoopbackTransport) LocalAddr() net.Addr {
+	return t.addr
+}
+
+// BroadcastAddr 返回网段的广播地址
+func (t *LoopbackTransport) BroadcastAddr() net.Addr {
+	return &net.UDPAddr{IP: net.IPv4(192, 0, 2, 255), Port: loopbackPort}
+}
+
+// ResolveAddr 解析"IP:端口"形式的地址
+func (t *LoopbackTransport) ResolveAddr(address string) (net.Addr, error) {
+	return net.ResolveUDPAddr("udp", address)
+}
+
+// MTU 返回BVLL的最大长度
+func (t *LoopbackTransport) MTU() int {
+	return bvllMaxLength
+}
+
+// Close 关闭传输并从网段移除
+func (t *LoopbackTransport) Close() error {
+	t.close.Do(func() {
+		close(t.closed)
+		t.network.detach(t)
+	})
+	return nil
+}
@@ -0,0 +1,302 @@
+package protocol
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// latencyBuckets 处理耗时直方图的上界（秒）
+var latencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// labeledCounter 按标签计数的计数器
+type labeledCounter struct {
+	mu     sync.Mutex
+	counts map[string]uint64
+}
+
+// inc 标签的计数加一
+func (c *labeledCounter) inc(label string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[string]uint64)
+	}
+	c.counts[label]++
+}
+
+// snapshot 返回各标签计数的副本
+func (c *labeledCounter) snapshot() map[string]uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[string]uint64, len(c.counts))
+	for label, n := range c.counts {
+		counts[label] = n
+	}
+	return counts
+}
+
+// histogram 固定桶的耗时直方图
+type histogram struct {
+	buckets []uint64 // 各桶的计数（不累计），最后一个为+Inf，原子访问
+	count   uint64
+	sumNano uint64
+}
+
+// observe 记录一次耗时
+func (h *histogram) observe(d time.Duration) {
+	i := sort.SearchFloat64s(latencyBuckets, d.Seconds())
+	atomic.AddUint64(&h.buckets[i], 1)
+	atomic.AddUint64(&h.count, 1)
+	atomic.AddUint64(&h.sumNano, uint64(d))
+}
+
+// metrics 服务端的运行指标
+type metrics struct {
+	datagramsIn      uint64 // 原子访问
+	datagramsOut     uint64 // 原子访问
+	covNotifications uint64 // 原子访问
+	apdus            labeledCounter
+	errors           labeledCounter
+	rejects          labeledCounter
+	aborts           labeledCounter
+	latency          histogram
+	once             sync.Once
+}
+
+// init 创建直方图的桶
+func (m *metrics) init() {
+	m.once.Do(func() {
+		m.latency.buckets = make([]uint64, len(latencyBuckets)+1)
+	})
+}
+
+// observeRequest 记录处理一个数据报的耗时
+func (m *metrics) observeRequest(d time.Duration) {
+	m.init()
+	m.latency.observe(d)
+}
+
+// Metrics 服务端运行指标的快照
+type Metrics struct {
+	DatagramsReceived    uint64            `json:"datagrams_received"`
+	DatagramsSent        uint64            `json:"datagrams_sent"`
+	MalformedPackets     uint64            `json:"malformed_packets"`
+	RateLimitedPackets   uint64            `json:"rate_limited_packets"`
+	RateLimitedDiscovery uint64            `json:"rate_limited_discovery"`
+	APDUs                map[string]uint64 `json:"apdus"`   // 收到的请求，键为"confirmed/ReadProperty"形式
+	Errors               map[string]uint64 `json:"errors"`  // 应答的Error PDU，键为"错误类别/错误代码"
+	Rejects              map[string]uint64 `json:"rejects"` // 应答的Reject PDU，键为拒绝原因
+	Aborts               map[string]uint64 `json:"aborts"`  // 收到的Abort PDU，键为放弃原因
+	COVNotifications     uint64            `json:"cov_notifications"`
+	COVSubscriptions     int               `json:"cov_subscriptions"`
+	COVDropped           uint64            `json:"cov_notifications_dropped"` // 订阅者队列已满时丢弃的COV通知数
+	COVQueueDepth        map[string]int    `json:"cov_queue_depth"`           // 各订阅者队列中等待发送的通知数，键为客户端地址
+	Requests             uint64            `json:"requests"`                  // 处理的数据报数
+	RequestSeconds       float64           `json:"request_seconds"`           // 处理数据报的总耗时
+	RequestBuckets       []uint64          `json:"request_duration_bucket"`   // 各上界的累计计数，对应latencyBuckets和+Inf
+}
+
+// Metrics 返回当前运行指标的快照，调用时不能持有设备锁
+func (s *BACnetServer) Metrics() Metrics {
+	m := &s.metrics
+	m.init()
+	snapshot := Metrics{
+		DatagramsReceived:    atomic.LoadUint64(&m.datagramsIn),
+		DatagramsSent:        atomic.LoadUint64(&m.datagramsOut),
+		MalformedPackets:     s.MalformedPackets(),
+		RateLimitedPackets:   s.RateLimitedPackets(),
+		RateLimitedDiscovery: s.RateLimitedDiscovery(),
+		APDUs:                m.apdus.snapshot(),
+		Errors:               m.errors.snapshot(),
+		Rejects:              m.rejects.snapshot(),
+		Aborts:               m.aborts.snapshot(),
+		COVNotifications:     atomic.LoadUint64(&m.covNotifications),
+		COVSubscriptions:     s.covSubscriptionCount(),
+		COVDropped:           s.COVNotificationsDropped(),
+		COVQueueDepth:        s.COVQueueDepths(),
+		Requests:             atomic.LoadUint64(&m.latency.count),
+		RequestSeconds:       time.Duration(atomic.LoadUint64(&m.latency.sumNano)).Seconds(),
+	}
+	var cumulative uint64
+	for i := range m.latency.buckets {
+		cumulative += atomic.LoadUint64(&m.latency.buckets[i])
+		snapshot.RequestBuckets = append(snapshot.RequestBuckets, cumulative)
+	}
+	return snapshot
+}
+
+// covSubscriptionCount 返回设备中所有对象当前的COV订阅数，读取时持有设备锁
+func (s *BACnetServer) covSubscriptionCount() int {
+	if s.device == nil {
+		return 0
+	}
+	s.device.Lock()
+	defer s.device.Unlock()
+	count := 0
+	for _, obj := range append([]model.Object{s.device}, s.device.Objects()...) {
+		if o, ok := obj.(interface {
+			COVSubscriptions() []model.COVSubscription
+		}); ok {
+			count += len(o.COVSubscriptions())
+		}
+	}
+	return count
+}
+
+// ExpvarFunc 返回以JSON输出运行指标的expvar变量，可由调用方以expvar.Publish发布
+func (s *BACnetServer) ExpvarFunc() expvar.Func {
+	return func() any { return s.Metrics() }
+}
+
+// MetricsHandler 返回以Prometheus文本格式输出运行指标的HTTP处理器
+func (s *BACnetServer) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		s.Metrics().writePrometheus(w)
+	})
+}
+
+// writePrometheus 以Prometheus文本格式写出指标
+func (m Metrics) writePrometheus(w io.Writer) {
+	counter := func(name, help string, value uint64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, value)
+	}
+	labeled := func(name, help string, labels []string, counts map[string]uint64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+		keys := make([]string, 0, len(counts))
+		for key := range counts {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			values := strings.SplitN(key, "/", len(labels))
+			pairs := make([]string, len(values))
+			for i, value := range values {
+				pairs[i] = fmt.Sprintf("%s=%q", labels[i], value)
+			}
+			fmt.Fprintf(w, "%s{%s} %d\n", name, strings.Join(pairs, ","), counts[key])
+		}
+	}
+
+	counter("bacnet_datagrams_received_total", "Datagrams read from the transport.", m.DatagramsReceived)
+	counter("bacnet_datagrams_sent_total", "Datagrams written to the transport.", m.DatagramsSent)
+	counter("bacnet_datagrams_malformed_total", "Datagrams whose processing panicked.", m.MalformedPackets)
+	counter("bacnet_datagrams_rate_limited_total", "Datagrams dropped by the per-source rate limit.", m.RateLimitedPackets)
+	counter("bacnet_discovery_rate_limited_total", "Who-Is and Who-Has requests left unanswered by the global rate limit.", m.RateLimitedDiscovery)
+	labeled("bacnet_apdus_received_total", "Service requests received.", []string{"type", "service"}, m.APDUs)
+	labeled("bacnet_errors_sent_total", "Error PDUs sent.", []string{"class", "code"}, m.Errors)
+	labeled("bacnet_rejects_sent_total", "Reject PDUs sent.", []string{"reason"}, m.Rejects)
+	labeled("bacnet_aborts_received_total", "Abort PDUs received.", []string{"reason"}, m.Aborts)
+	counter("bacnet_cov_notifications_sent_total", "COV notifications sent.", m.COVNotifications)
+	fmt.Fprintf(w, "# HELP bacnet_cov_subscriptions Active COV subscriptions.\n# TYPE bacnet_cov_subscriptions gauge\nbacnet_cov_subscriptions %d\n", m.COVSubscriptions)
+	counter("bacnet_cov_notifications_dropped_total", "COV notifications dropped because the subscriber queue was full.", m.COVDropped)
+	fmt.Fprintf(w, "# HELP bacnet_cov_queue_depth COV notifications waiting to be sent to a subscriber.\n# TYPE bacnet_cov_queue_depth gauge\n")
+	peers := make([]string, 0, len(m.COVQueueDepth))
+	for peer := range m.COVQueueDepth {
+		peers = append(peers, peer)
+	}
+	sort.Strings(peers)
+	for _, peer := range peers {
+		fmt.Fprintf(w, "bacnet_cov_queue_depth{peer=%q} %d\n", peer, m.COVQueueDepth[peer])
+	}
+
+	fmt.Fprintf(w, "# HELP bacnet_request_duration_seconds Time spent processing a datagram.\n# TYPE bacnet_request_duration_seconds histogram\n")
+	for i, count := range m.RequestBuckets {
+		le := "+Inf"
+		if i < len(latencyBuckets) {
+			le = strconv.FormatFloat(latencyBuckets[i], 'g', -1, 64)
+		}
+		fmt.Fprintf(w, "bacnet_request_duration_seconds_bucket{le=%q} %d\n", le, count)
+	}
+	fmt.Fprintf(w, "bacnet_request_duration_seconds_sum %g\nbacnet_request_duration_seconds_count %d\n", m.RequestSeconds, m.Requests)
+}
+
+// serveMetrics 在addr上提供指标的HTTP端点：/metrics为Prometheus格式，/debug/vars为JSON，服务端关闭时停止
+func (s *BACnetServer) serveMetrics(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", s.MetricsHandler())
+	mux.HandleFunc("/debug/vars", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.Metrics())
+	})
+	return s.serveHTTP("指标端点", addr, mux)
+}
+
+// serveHTTP 在addr上提供HTTP服务，服务端关闭时停止
+func (s *BACnetServer) serveHTTP(name, addr string, handler http.Handler) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("监听%s失败: %v", name, err)
+	}
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.Logger().Warn(name+"退出", "addr", addr, "error", err)
+		}
+	}()
+	go func() {
+		<-s.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+	s.Logger().Info(name+"已启动", "addr", listener.Addr().String())
+	return nil
+}
+
+// writeTo 经传输发送数据报，计数、跟踪并抓包。通信中断时丢弃数据报，按已发送返回
+func (s *BACnetServer) writeTo(p []byte, addr net.Addr) (int, error) {
+	if s.offline.Load() {
+		return len(p), nil
+	}
+	s.trace("TX", addr, p)
+	s.capturePacket(false, addr, p)
+	n, err := s.transport.WriteTo(p, addr)
+	if err == nil {
+		atomic.AddUint64(&s.metrics.datagramsOut, 1)
+	}
+	return n, err
+}
+
+// countAPDU 按PDU类型和服务记录收到的请求
+func (s *BACnetServer) countAPDU(apdu *APDU) {
+	switch apdu.PDUType {
+	case BACnetAPDUTypeConfirmedServiceRequest:
+		s.metrics.apdus.inc("confirmed/" + apdu.ServiceName())
+	case BACnetAPDUTypeUnconfirmedServiceRequest:
+		s.metrics.apdus.inc("unconfirmed/" + unconfirmedServiceName(*apdu.ServiceChoice))
+	}
+}
+
+// unconfirmedServiceName 返回未确认服务的名称
+func unconfirmedServiceName(service byte) string {
+	switch service {
+	case BACnetServiceUnconfirmedIAm:
+		return "I-Am"
+	case BACnetServiceUnconfirmedIHave:
+		return "I-Have"
+	case BACnetServiceUnconfirmedCOVNotification:
+		return "UnconfirmedCOVNotification"
+	case BACnetServiceUnconfirmedEventNotification:
+		return "UnconfirmedEventNotification"
+	case BACnetServiceUnconfirmedWhoIs:
+		return "Who-Is"
+	case BACnetServiceUnconfirmedWhoHas:
+		return "Who-Has"
+	}
+	return fmt.Sprintf("0x%02x", service)
+}
@@ -0,0 +1,225 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+)
+
+// datagram 从某个套接字或传输收到的数据报
+type datagram struct {
+	data []byte
+	from net.Addr
+}
+
+// pumpDatagrams 从read读取数据报放入out，直到读取出错或done关闭
+func pumpDatagrams(read func([]byte) (int, net.Addr, error), size int, out chan<- datagram, done <-chan struct{}) {
+	for {
+		buffer := make([]byte, size)
+		n, from, err := read(buffer)
+		if err != nil {
+			return
+		}
+		select {
+		case out <- datagram{data: buffer[:n], from: from}:
+		case <-done:
+			return
+		}
+	}
+}
+
+// interfaceIPv4 返回网络接口的IPv4地址和子网，name为接口名（如eth0）或接口上的IPv4地址
+func interfaceIPv4(name string) (*net.IPNet, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(name)
+	for _, iface := range ifaces {
+		if ip == nil && iface.Name != name {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addrs {
+			ipNet, ok := a.(*net.IPNet)
+			if !ok || ipNet.IP.To4() == nil {
+				continue
+			}
+			if ip == nil || ipNet.IP.Equal(ip) {
+				mask := ipNet.Mask
+				if len(mask) == net.IPv6len {
+					mask = mask[12:]
+				}
+				return &net.IPNet{IP: ipNet.IP.To4(), Mask: mask}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("没有找到接口%s的IPv4地址", name)
+}
+
+// directedBroadcast 返回子网的定向广播地址
+func directedBroadcast(subnet *net.IPNet) net.IP {
+	ip := subnet.IP.To4()
+	broadcast := make(net.IP, net.IPv4len)
+	for i := range broadcast {
+		broadcast[i] = ip[i] | ^subnet.Mask[i]
+	}
+	return broadcast
+}
+
+// NewInterfaceTransport 在一个网络接口上监听port，name为接口名或接口上的IPv4地址。
+// 广播使用由接口子网掩码计算的定向广播地址，并另外监听该地址以收到子网上的广播
+func NewInterfaceTransport(name string, port int) (*UDPTransport, error) {
+	subnet, err := interfaceIPv4(name)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: subnet.IP, Port: port})
+	if err != nil {
+		return nil, err
+	}
+	t := &UDPTransport{conn: conn, subnet: subnet}
+
+	// 绑定到单播地址的套接字收不到广播，/32等没有广播地址的子网除外
+	broadcast := directedBroadcast(subnet)
+	if broadcast.Equal(subnet.IP) {
+		return t, nil
+	}
+	bcastConn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: broadcast, Port: conn.LocalAddr().(*net.UDPAddr).Port})
+	if err != nil {
+		slog.Warn("无法监听广播地址，将收不到子网广播", "addr", broadcast.String(), "error", err)
+		return t, nil
+	}
+	t.bcastConn = bcastConn
+	t.datagrams = make(chan datagram, 64)
+	t.closed = make(chan struct{})
+	go pumpDatagrams(conn.ReadFrom, bvllMaxLength, t.datagrams, t.closed)
+	go pumpDatagrams(bcastConn.ReadFrom, bvllMaxLength, t.datagrams, t.closed)
+	return t, nil
+}
+
+// MultiTransport 同时在多个传输（通常是多个网络接口）上收发。单播按目标所在的子网选择传输，
+// 发往IPv4受限广播地址的数据报在每个传输上以其广播地址发送
+type MultiTransport struct {
+	transports []Transport
+	datagrams  chan datagram
+	closed     chan struct{}
+	close      sync.Once
+}
+
+// NewMultiTransport 合并多个传输，第一个传输的地址作为本地地址
+func NewMultiTransport(transports ...Transport) (*MultiTransport, error) {
+	if len(transports) == 0 {
+		return nil, errors.New("没有传输")
+	}
+	t := &MultiTransport{
+		transports: transports,
+		datagrams:  make(chan datagram, 64),
+		closed:     make(chan struct{}),
+	}
+	for _, member := range transports {
+		go pumpDatagrams(member.ReadFrom, member.MTU(), t.datagrams, t.closed)
+	}
+	return t, nil
+}
+
+// ReadFrom 读取任一传输收到的下一个数据报
+func (t *MultiTransport) ReadFrom(p []byte) (int, net.Addr, error) {
+	select {
+	case d := <-t.datagrams:
+		return copy(p, d.data), d.from, nil
+	case <-t.closed:
+		return 0, nil, net.ErrClosed
+	}
+}
+
+// WriteTo 发送数据报：受限广播在每个传输上广播，单播经目标所在子网的传输发送，都不在时经第一个传输
+func (t *MultiTransport) WriteTo(p []byte, addr net.Addr) (int, error) {
+	to := udpAddr(addr)
+	if to != nil && to.IP.Equal(net.IPv4bcast) {
+		var firstErr error
+		for _, member := range t.transports {
+			if _, err := member.WriteTo(p, member.BroadcastAddr()); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return len(p), firstErr
+	}
+	return t.route(to).WriteTo(p, addr)
+}
+
+// route 返回子网包含addr的传输
+func (t *MultiTransport) route(addr *net.UDPAddr) Transport {
+	if addr != nil {
+		for _, member := range t.transports {
+			if u, ok := member.(*UDPTransport); ok && u.subnet != nil && u.subnet.Contains(addr.IP) {
+				return member
+			}
+		}
+	}
+	return t.transports[0]
+}
+
+// LocalAddr 返回第一个传输的本地地址
+func (t *MultiTransport) LocalAddr() net.Addr {
+	return t.transports[0].LocalAddr()
+}
+
+// BroadcastAddr 返回IPv4受限广播地址，发往该地址的数据报在所有传输上广播
+func (t *MultiTransport) BroadcastAddr() net.Addr {
+	port := 47808
+	if local := udpAddr(t.LocalAddr()); local != nil {
+		port = local.Port
+	}
+	return &net.UDPAddr{IP: net.IPv4bcast, Port: port}
+}
+
+// ResolveAddr 由第一个传输解析地址
+func (t *MultiTransport) ResolveAddr(address string) (net.Addr, error) {
+	return t.transports[0].ResolveAddr(address)
+}
+
+// MTU 返回各传输中最小的数据报长度
+func (t *MultiTransport) MTU() int {
+	mtu := t.transports[0].MTU()
+	for _, member := range t.transports[1:] {
+		if member.MTU() < mtu {
+			mtu = member.MTU()
+		}
+	}
+	return mtu
+}
+
+// SetDSCP 为所有支持DSCP的传输设置DSCP
+func (t *MultiTransport) SetDSCP(dscp byte) error {
+	supported := false
+	for _, member := range t.transports {
+		if d, ok := member.(dscpTransport); ok {
+			if err := d.SetDSCP(dscp); err != nil {
+				return err
+			}
+			supported = true
+		}
+	}
+	if !supported {
+		return errors.New("传输不支持DSCP")
+	}
+	return nil
+}
+
+// Close 关闭所有传输
+func (t *MultiTransport) Close() error {
+	t.close.Do(func() { close(t.closed) })
+	var firstErr error
+	for _, member := range t.transports {
+		if err := member.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,279 @@
+package protocol
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/iotzf/bacnet-server/encoding"
+)
+
+// 网络层消息类型（Clause 6.2.4）
+const (
+	NetworkMessageWhoIsRouterToNetwork          = 0x00
+	NetworkMessageIAmRouterToNetwork            = 0x01
+	NetworkMessageICouldBeRouterToNetwork       = 0x02
+	NetworkMessageRejectMessageToNetwork        = 0x03
+	NetworkMessageRouterBusyToNetwork           = 0x04
+	NetworkMessageRouterAvailableToNetwork      = 0x05
+	NetworkMessageInitializeRoutingTable        = 0x06
+	NetworkMessageInitializeRoutingTableAck     = 0x07
+	NetworkMessageEstablishConnectionToNetwork  = 0x08
+	NetworkMessageDisconnectConnectionToNetwork = 0x09
+	NetworkMessageWhatIsNetworkNumber           = 0x12
+	NetworkMessageNetworkNumberIs               = 0x13
+	networkMessageProprietary                   = 0x80 // 0x80-0xFF为厂商私有消息
+)
+
+// Reject-Message-To-Network的拒绝原因
+const (
+	RejectNetworkOther          = 0
+	RejectNetworkUnknown        = 1 // 路由器不知道如何到达目标网络
+	RejectNetworkBusy           = 2
+	RejectNetworkUnknownMessage = 3
+	RejectNetworkMessageTooLong = 4
+	RejectNetworkSecurityError  = 5
+	RejectNetworkAddressError   = 6
+)
+
+// networkMessageNames 网络层消息名称
+var networkMessageNames = map[byte]string{
+	NetworkMessageWhoIsRouterToNetwork:          "Who-Is-Router-To-Network",
+	NetworkMessageIAmRouterToNetwork:            "I-Am-Router-To-Network",
+	NetworkMessageICouldBeRouterToNetwork:       "I-Could-Be-Router-To-Network",
+	NetworkMessageRejectMessageToNetwork:        "Reject-Message-To-Network",
+	NetworkMessageRouterBusyToNetwork:           "Router-Busy-To-Network",
+	NetworkMessageRouterAvailableToNetwork:      "Router-Available-To-Network",
+	NetworkMessageInitializeRoutingTable:        "Initialize-Routing-Table",
+	NetworkMessageInitializeRoutingTableAck:     "Initialize-Routing-Table-Ack",
+	NetworkMessageEstablishConnectionToNetwork:  "Establish-Connection-To-Network",
+	NetworkMessageDisconnectConnectionToNetwork: "Disconnect-Connection-To-Network",
+	NetworkMessageWhatIsNetworkNumber:           "What-Is-Network-Number",
+	NetworkMessageNetworkNumberIs:               "Network-Number-Is",
+}
+
+// networkMessageName 返回网络层消息类型的名称
+func networkMessageName(messageType byte) string {
+	if name, ok := networkMessageNames[messageType]; ok {
+		return name
+	}
+	if messageType >= networkMessageProprietary {
+		return fmt.Sprintf("Proprietary(0x%02X)", messageType)
+	}
+	return fmt.Sprintf("Unknown(0x%02X)", messageType)
+}
+
+// route 经其他路由器到达的网络
+type route struct {
+	port   *RouterPort
+	router []byte // 下一跳路由器在端口链路上的MAC
+}
+
+// learnedRoute 返回从I-Am-Router-To-Network学到的到network的路由
+func (s *BACnetServer) learnedRoute(network uint16) (route, bool) {
+	s.routerMu.Lock()
+	defer s.routerMu.Unlock()
+	r, ok := s.routes[network]
+	return r, ok
+}
+
+// learnRoute 记录经port上的路由器router可以到达network
+func (s *BACnetServer) learnRoute(network uint16, port *RouterPort, router []byte) {
+	s.routerMu.Lock()
+	defer s.routerMu.Unlock()
+	if s.routes == nil {
+		s.routes = make(map[uint16]route)
+	}
+	s.routes[network] = route{port: port, router: append([]byte(nil), router...)}
+}
+
+// forgetRoute 删除学到的到network的路由
+func (s *BACnetServer) forgetRoute(network uint16) {
+	s.routerMu.Lock()
+	defer s.routerMu.Unlock()
+	delete(s.routes, network)
+}
+
+// reachableNetworks 返回经本路由器可以到达、但不在端口except上的网络，按网络号排序
+func (s *BACnetServer) reachableNetworks(except *RouterPort) []uint16 {
+	var networks []uint16
+	for _, port := range s.routerPorts {
+		if port != except {
+			networks = append(networks, port.Network)
+		}
+	}
+	s.routerMu.Lock()
+	for network, r := range s.routes {
+		if r.port != except {
+			networks = append(networks, network)
+		}
+	}
+	s.routerMu.Unlock()
+	sort.Slice(networks, func(i, j int) bool { return networks[i] < networks[j] })
+	return networks
+}
+
+// encodeNetworkList 编码网络层消息类型及其后的网络号列表
+func encodeNetworkList(messageType byte, networks []uint16) []byte {
+	message := []byte{messageType}
+	for _, network := range networks {
+		message = append(message, byte(network>>8), byte(network))
+	}
+	return message
+}
+
+// sendNetworkMessage 在端口上发送本地网络层消息，mac为空时在链路上广播
+func (p *RouterPort) sendNetworkMessage(message []byte, mac []byte) {
+	p.send(NPDU{Version: 0x01, Control: ControlInfo{NetworkMessageFlag: true}}, message, mac)
+}
+
+// replyNetworkMessage 向端口上mac发来的请求回复网络层消息，请求经路由器转发时发往其源网络和源地址
+func (p *RouterPort) replyNetworkMessage(request NPDU, message []byte, mac []byte) {
+	reply := replyNPDU(request)
+	reply.Control.NetworkMessageFlag = true
+	p.send(reply, message, mac)
+}
+
+// announceRoutes 在每个端口上广播I-Am-Router-To-Network，列出经其他端口可以到达的网络
+func (s *BACnetServer) announceRoutes() {
+	for _, port := range s.routerPorts {
+		if networks := s.reachableNetworks(port); len(networks) > 0 {
+			port.sendNetworkMessage(encodeNetworkList(NetworkMessageIAmRouterToNetwork, networks), nil)
+		}
+	}
+}
+
+// handleNetworkMessage 处理发给本节点的网络层消息，port为收到消息的路由器端口，
+// 未启用路由时为nil，此时本设备不是路由器，只记录收到的消息
+func (s *BACnetServer) handleNetworkMessage(port *RouterPort, npdu NPDU, data []byte, mac []byte) error {
+	r := encoding.NewReader(data)
+	messageType, err := r.Byte()
+	if err != nil {
+		return fmt.Errorf("网络层消息: %w", err)
+	}
+	if s.packetLogging() {
+		s.logPacket("收到网络层消息", "message", networkMessageName(messageType))
+	}
+	if port == nil || messageType >= networkMessageProprietary {
+		return nil
+	}
+
+	switch messageType {
+	case NetworkMessageWhoIsRouterToNetwork:
+		return s.handleWhoIsRouterToNetwork(port, npdu, r, mac)
+	case NetworkMessageIAmRouterToNetwork:
+		return s.handleIAmRouterToNetwork(port, r, mac)
+	case NetworkMessageRejectMessageToNetwork:
+		return s.handleRejectMessageToNetwork(r)
+	case NetworkMessageWhatIsNetworkNumber:
+		// 只应答本地网段上的请求
+		if npdu.SourceNetwork == nil && npdu.DestinationNetwork == nil {
+			port.sendNetworkMessage([]byte{NetworkMessageNetworkNumberIs, byte(port.Network >> 8), byte(port.Network), 1}, nil)
+		}
+		return nil
+	case NetworkMessageNetworkNumberIs:
+		return s.handleNetworkNumberIs(port, r)
+	case NetworkMessageInitializeRoutingTable:
+		return s.handleInitializeRoutingTable(port, npdu, r, mac)
+	case NetworkMessageInitializeRoutingTableAck:
+		return nil
+	case NetworkMessageICouldBeRouterToNetwork, NetworkMessageRouterBusyToNetwork, NetworkMessageRouterAvailableToNetwork:
+		return nil
+	default:
+		s.rejectMessageToNetwork(port, npdu, mac, RejectNetworkUnknownMessage, 0)
+		return nil
+	}
+}
+
+// handleWhoIsRouterToNetwork 应答Who-Is-Router-To-Network。未指定网络时列出所有经其他端口可达的网络；
+// 指定的网络不可达时将请求转发到其他端口，由那里的路由器应答
+func (s *BACnetServer) handleWhoIsRouterToNetwork(port *RouterPort, npdu NPDU, r *encoding.Reader, mac []byte) error {
+	networks := s.reachableNetworks(port)
+	if r.Len() == 0 {
+		if len(networks) > 0 {
+			port.sendNetworkMessage(encodeNetworkList(NetworkMessageIAmRouterToNetwork, networks), nil)
+		}
+		return nil
+	}
+
+	network, err := r.Uint16()
+	if err != nil {
+		return fmt.Errorf("Who-Is-Router-To-Network: %w", err)
+	}
+	for _, n := range networks {
+		if n == network {
+			port.sendNetworkMessage(encodeNetworkList(NetworkMessageIAmRouterToNetwork, []uint16{network}), nil)
+			return nil
+		}
+	}
+	if network == port.Network {
+		return nil
+	}
+	forwarded := NPDU{Version: 0x01, Control: ControlInfo{NetworkMessageFlag: true}, SourceNetwork: &port.Network, SourceMAC: mac}
+	if npdu.SourceNetwork != nil {
+		forwarded.SourceNetwork, forwarded.SourceMAC = npdu.SourceNetwork, npdu.SourceMAC
+	}
+	for _, other := range s.routerPorts {
+		if other != port {
+			other.send(forwarded, encodeNetworkList(NetworkMessageWhoIsRouterToNetwork, []uint16{network}), nil)
+		}
+	}
+	return nil
+}
+
+// handleIAmRouterToNetwork 记录经发送方路由器可以到达的网络，并在其他端口上转播
+func (s *BACnetServer) handleIAmRouterToNetwork(port *RouterPort, r *encoding.Reader, mac []byte) error {
+	var learned []uint16
+	for r.Len() > 0 {
+		network, err := r.Uint16()
+		if err != nil {
+			return fmt.Errorf("I-Am-Router-To-Network: %w", err)
+		}
+		if s.routerPort(network) != nil {
+			continue // 直接连接的网络不经其他路由器
+		}
+		s.learnRoute(network, port, mac)
+		learned = append(learned, network)
+	}
+	if len(learned) == 0 {
+		return nil
+	}
+	for _, other := range s.routerPorts {
+		if other != port {
+			other.sendNetworkMessage(encodeNetworkList(NetworkMessageIAmRouterToNetwork, learned), nil)
+		}
+	}
+	return nil
+}
+
+// handleRejectMessageToNetwork 记录拒绝原因，网络不可达时删除到该网络的路由
+func (s *BACnetServer) handleRejectMessageToNetwork(r *encoding.Reader) error {
+	reason, err := r.Byte()
+	if err != nil {
+		return fmt.Errorf("Reject-Message-To-Network: %w", err)
+	}
+	network, err := r.Uint16()
+	if err != nil {
+		return fmt.Errorf("Reject-Message-To-Network: %w", err)
+	}
+	s.Logger().Warn("发往网络的消息被拒绝", "network", network, "reason", reason)
+	if reason == RejectNetworkUnknown {
+		s.forgetRoute(network)
+	}
+	return nil
+}
+
+// handleNetworkNumberIs 检查网段上其他节点配置的网络号是否与端口一致
+func (s *BACnetServer) handleNetworkNumberIs(port *RouterPort, r *encoding.Reader) error {
+	network, err := r.Uint16()
+	if err != nil {
+		return fmt.Errorf("Network-Number-Is: %w", err)
+	}
+	configured, err := r.Byte()
+	if err != nil {
+		return fmt.Errorf("Network-Number-Is: %w", err)
+	}
+	if configured == 1 && network != port.Network {
+		s.Logger().Warn("网络号冲突", "configured", port.Network, "peer_network", network)
+	}
+	return nil
+}
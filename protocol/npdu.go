@@ -1,8 +1,9 @@
 package protocol
 
 import (
-	"encoding/binary"
 	"fmt"
+
+	"github.com/iotzf/bacnet-server/encoding"
 )
 
 // NPDU 表示BACnet NPDU可选头部字段的解析结果
@@ -102,80 +103,105 @@ func ParseControl(data byte) ControlInfo {
 // 返回 NPDU 结构体、APDU 起始偏移和错误（若格式不符合或越界）
 func ParseNPDU(data []byte) (NPDU, int, error) {
 	var npdu NPDU
+	r := encoding.NewReader(data)
 
 	// 最少需要版本和控制字节
-	if len(data) < 2 {
-		return npdu, 0, fmt.Errorf("NPDU too short")
+	version, err := r.Byte()
+	if err != nil {
+		return npdu, 0, fmt.Errorf("NPDU too short: %w", err)
 	}
-
-	npdu.Version = data[0]
-	npdu.Control = ParseControl(data[1])
+	control, err := r.Byte()
+	if err != nil {
+		return npdu, 0, fmt.Errorf("NPDU too short: %w", err)
+	}
+	npdu.Version = version
+	npdu.Control = ParseControl(control)
 	if npdu.Version != 0x01 {
 		return npdu, 0, fmt.Errorf("unsupported NPDU version: %02x", npdu.Version)
 	}
 
-	offset := 2
-
-	// 如果设置了 destination specified (常见使用 0x20 位)，解析目标网络与目标MAC
+	// 目标网络与目标MAC：DNET(2) + DLEN(1) + DADR
 	if npdu.Control.DestinationSpecified {
-		// 需要至少 DNET(2) + DLEN(1)
-		if offset+3 > len(data) {
-			return npdu, 0, fmt.Errorf("NPDU too short for destination fields")
+		dnet, dmac, err := readNetworkAddress(r)
+		if err != nil {
+			return npdu, 0, fmt.Errorf("NPDU destination: %w", err)
 		}
-		dnet := binary.BigEndian.Uint16(data[offset : offset+2])
-		offset += 2
-		dlen := int(data[offset])
-		offset++
-		if offset+dlen > len(data) {
-			return npdu, 0, fmt.Errorf("NPDU too short for destination MAC")
-		}
-		dmac := make([]byte, dlen)
-		copy(dmac, data[offset:offset+dlen])
-		offset += dlen
-
-		npdu.DestinationNetwork = new(uint16)
-		*npdu.DestinationNetwork = dnet
+		npdu.DestinationNetwork = &dnet
 		npdu.DestinationMAC = dmac
 	}
 
-	// 若剩余足够，尝试解析源网络与源MAC（很多实现不强制存在，仅当足够数据时解析）
-	if npdu.Control.SourceSpecified && len(data)-offset >= 3 {
-		// SNET(2) + SLEN(1)
-		snet := binary.BigEndian.Uint16(data[offset : offset+2])
-		offset += 2
-		slen := int(data[offset])
-		offset++
-		if offset+slen > len(data) {
-			return npdu, 0, fmt.Errorf("NPDU too short for source MAC")
+	// 源网络与源MAC：SNET(2) + SLEN(1) + SADR
+	if npdu.Control.SourceSpecified {
+		snet, smac, err := readNetworkAddress(r)
+		if err != nil {
+			return npdu, 0, fmt.Errorf("NPDU source: %w", err)
 		}
-		smac := make([]byte, slen)
-		copy(smac, data[offset:offset+slen])
-		offset += slen
-
-		npdu.SourceNetwork = new(uint16)
-		*npdu.SourceNetwork = snet
+		npdu.SourceNetwork = &snet
 		npdu.SourceMAC = smac
 	}
 
-	// 如果还有字节，通常是hop count(1)
-	if npdu.Control.DestinationSpecified && offset < len(data) {
-		h := data[offset]
-		offset++
-		npdu.HopCount = new(byte)
-		*npdu.HopCount = h
+	// 指定目标时必须带hop count
+	if npdu.Control.DestinationSpecified {
+		h, err := r.Byte()
+		if err != nil {
+			return npdu, 0, fmt.Errorf("NPDU hop count: %w", err)
+		}
+		npdu.HopCount = &h
 	}
 
-	if offset > len(data) {
-		return npdu, 0, fmt.Errorf("NPDU parsing overflow")
+	return npdu, r.Offset(), nil
+}
+
+// replyNPDU 返回对请求的应答NPDU：请求经路由器转发（带源网络）时以其源网络和源地址作为目标，
+// 使下游路由器能将应答送达；优先级与请求相同
+func replyNPDU(request NPDU) NPDU {
+	reply := NPDU{Version: 0x01, Control: ControlInfo{Priority: request.Control.Priority}}
+	if request.SourceNetwork != nil {
+		hop := byte(defaultHopCount)
+		reply.DestinationNetwork, reply.DestinationMAC, reply.HopCount = request.SourceNetwork, request.SourceMAC, &hop
 	}
+	return reply
+}
 
-	return npdu, offset, nil
+// readNetworkAddress 读取网络号、MAC长度和MAC地址
+func readNetworkAddress(r *encoding.Reader) (uint16, []byte, error) {
+	network, err := r.Uint16()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, err := r.Byte()
+	if err != nil {
+		return 0, nil, err
+	}
+	mac, err := r.Bytes(int(length))
+	if err != nil {
+		return 0, nil, err
+	}
+	return network, append([]byte(nil), mac...), nil
 }
 
 // Encode 将 NPDU 编码为字节序列（不包含BVLC头）
-// 用于构造发送时的NPDU部分
+// 用于构造发送时的NPDU部分，控制字节中目标与源的标志由对应字段是否存在决定
 func (n NPDU) Encode() []byte {
-	out := []byte{n.Version}
+	return n.appendTo(nil)
+}
+
+// appendTo 将编码的NPDU追加到dst
+func (n NPDU) appendTo(out []byte) []byte {
+	control := byte(n.Control.Priority) & 0x03
+	if n.Control.NetworkMessageFlag {
+		control |= 0x80
+	}
+	if n.DestinationNetwork != nil {
+		control |= 0x20
+	}
+	if n.SourceNetwork != nil {
+		control |= 0x08
+	}
+	if n.Control.ExpectingReply {
+		control |= 0x04
+	}
+	out = append(out, n.Version, control)
 
 	if n.DestinationNetwork != nil {
 		out = append(out, byte((*n.DestinationNetwork)>>8), byte(*n.DestinationNetwork))
@@ -200,8 +226,13 @@ func (n NPDU) Encode() []byte {
 		}
 	}
 
-	if n.HopCount != nil {
-		out = append(out, *n.HopCount)
+	// 指定目标时必须带hop count
+	if n.DestinationNetwork != nil {
+		if n.HopCount != nil {
+			out = append(out, *n.HopCount)
+		} else {
+			out = append(out, defaultHopCount)
+		}
 	}
 
 	return out
@@ -0,0 +1,37 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/iotzf/bacnet-server/model"
+	"github.com/iotzf/bacnet-server/opcua"
+)
+
+// serveOPCUA 在addr上启动内嵌的OPC UA服务端，经OPC UA的写入与经BACnet的写入一样
+// 受属性保护、写入钩子和元数据检查的约束，以优先级16写入可命令属性
+func (s *BACnetServer) serveOPCUA(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("监听OPC UA失败: %v", err)
+	}
+	server := opcua.NewServer(s.device, opcua.Config{
+		Write: func(obj model.Object, prop model.PropertyIdentifier, value interface{}) error {
+			return s.writeProperty(nil, obj, prop, value, 16)
+		},
+		Logger: s.Logger(),
+		Clock:  s.Clock(),
+	})
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, net.ErrClosed) {
+			s.Logger().Warn("OPC UA服务端退出", "addr", addr, "error", err)
+		}
+	}()
+	go func() {
+		<-s.Done()
+		server.Close()
+	}()
+	s.Logger().Info("OPC UA服务端已启动", "addr", listener.Addr().String())
+	return nil
+}
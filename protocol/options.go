@@ -0,0 +1,287 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// DefaultPort BACnet/IP的标准UDP端口（0xBAC0）
+const DefaultPort = 47808
+
+// maxBIPAPDULength BACnet/IP上APDU的最大长度
+const maxBIPAPDULength = 1476
+
+// Options 创建服务端的配置，零值字段使用默认值。新的配置项只扩展该结构，不改变NewServer的签名
+type Options struct {
+	// 传输
+	Address          string    // 监听的UDP地址，为空时为":47808"
+	Interfaces       []string  // 只在这些网络接口（接口名或IPv4地址）上监听，端口取自Address
+	ReusePort        bool      // 以SO_REUSEPORT与本机其他BACnet协议栈共享端口
+	BroadcastAddress string    // 本地广播的目的地址（ip:port），为空时使用传输的广播地址
+	DSCP             byte      // 发出数据报的DSCP（0-63），为0时不标记
+	Transport        Transport // 使用的传输，不为nil时忽略以上监听配置
+
+	// BBMD和外部设备
+	BDT         []BDTEntry // 广播分发表，不为空时作为BBMD运行
+	ForeignBBMD string     // 作为外部设备注册的远程BBMD地址（ip:port），为空时不注册
+	ForeignTTL  uint16     // 外部设备注册的TTL（秒），为0时为60
+
+	// 路由
+	Network uint16 // BACnet/IP网络的网络号，不为0时启用路由
+
+	// 事务，不为0时写入设备的对应属性
+	APDUTimeout time.Duration // 确认请求等待应答的时间（APDU_Timeout）
+	APDURetries int           // 确认请求超时后的重试次数（Number_Of_APDU_Retries）
+	MaxAPDU     uint32        // 接受的最大APDU长度（Max_APDU_Length_Accepted），不超过1476
+
+	// 时间主站
+	TimeSyncInterval time.Duration // 定期发送时间同步的周期（Time_Synchronization_Interval），整分钟，为0时不发送
+	UTCTimeSync      bool          // 没有配置接收者时广播UTCTimeSynchronization而不是TimeSynchronization
+
+	// 访问控制
+	ACL      []ACLRule // 按来源地址允许或拒绝确认服务，按顺序匹配
+	ReadOnly bool      // 拒绝WriteServices中修改设备状态的服务
+
+	// 限速
+	RateLimit          float64 // 每个来源IP每秒处理的数据报数，为0时不限速
+	RateBurst          int     // 每个来源IP允许的突发数据报数，为0时为1
+	DiscoveryRateLimit float64 // 全局每秒应答的Who-Is和Who-Has数，为0时不限速
+
+	// 写保护
+	Protections []WriteProtection // 经网络写入时受保护的对象和属性
+	Engineers   []string          // 具有工程师角色的客户端来源（IP或子网）
+
+	// 审计
+	AuditLogger    *model.Recipient // 转发审计通知的审计记录设备，为nil时只记录到审计日志对象
+	AuditConfirmed bool             // 以ConfirmedAuditNotification转发并等待应答
+
+	// 处理
+	Workers          int           // 处理数据报的goroutine数，为0时为1。同一来源的数据报按收到的顺序处理，访问设备的部分持有设备锁
+	COVQueueSize     int           // 每个订阅者等待发送的COV通知数上限，队列满时丢弃新的通知，为0时为64
+	StateFile        string        // 持久化可命令对象优先级数组的文件，为空时不持久化
+	SnapshotFile     string        // 定期保存现场值、优先级数组、日程和日志缓冲区的快照文件，启动时从中恢复，为空时不保存
+	SnapshotInterval time.Duration // 保存快照的周期，为0时为1分钟
+	QuarantineDir    string        // 引发panic的数据报的隔离目录，为空时不保存
+	Logger           *slog.Logger  // 为nil时使用slog.Default()
+	Clock            model.Clock   // 服务端和设备使用的时钟，为nil时沿用设备的时钟（Device.SetClock，默认为系统时钟）
+	MetricsAddress   string        // 提供/metrics（Prometheus）和/debug/vars（JSON）的HTTP地址，为空时不启动
+	CaptureFile      string        // 以pcap格式记录收发的B/IP数据报的文件，为空时不抓包
+	DashboardAddress string        // 提供网页仪表盘的HTTP地址，为空时不启动
+	OPCUAAddress     string        // 内嵌OPC UA服务端（opc.tcp）的监听地址，为空时不启动
+	ConsoleAddress   string        // 交互式控制台的监听地址，unix:开头时为Unix套接字，否则为TCP地址，为空时不启动
+
+	// 回调
+	OnError func(peer string, err error) // 处理数据报失败时调用，在处理数据报的goroutine中执行
+}
+
+// NewServer 按options创建device的BACnet服务端，调用Start后开始处理请求
+func NewServer(device *model.Device, options Options) (*BACnetServer, error) {
+	if err := options.apply(device); err != nil {
+		return nil, err
+	}
+	transport := options.Transport
+	if transport == nil {
+		var err error
+		if transport, err = options.listen(); err != nil {
+			return nil, err
+		}
+	}
+	server, err := newBACnetServer(device, transport, options.StateFile)
+	if err == nil {
+		err = server.configure(options)
+	}
+	if err != nil {
+		if options.Transport == nil {
+			transport.Close()
+		}
+		return nil, err
+	}
+	return server, nil
+}
+
+// NewBACnetServer 在host上创建一个基于UDP的BACnet服务端，stateFile不为空时从中恢复可命令对象的优先级数组
+//
+// Deprecated: 使用NewServer(device, Options{Address: host, StateFile: stateFile})。
+func NewBACnetServer(device *model.Device, host string, stateFile string) (*BACnetServer, error) {
+	return NewServer(device, Options{Address: host, StateFile: stateFile})
+}
+
+// NewBACnetServerWithTransport 创建使用给定传输的BACnet服务端
+//
+// Deprecated: 使用NewServer(device, Options{Transport: transport, StateFile: stateFile})。
+func NewBACnetServerWithTransport(device *model.Device, transport Transport, stateFile string) (*BACnetServer, error) {
+	return NewServer(device, Options{Transport: transport, StateFile: stateFile})
+}
+
+// apply 校验事务配置并写入设备属性
+func (o Options) apply(device *model.Device) error {
+	if o.MaxAPDU != 0 && (o.MaxAPDU < 50 || o.MaxAPDU > maxBIPAPDULength) {
+		return fmt.Errorf("无效的最大APDU长度: %d", o.MaxAPDU)
+	}
+	if o.APDUTimeout < 0 || o.APDURetries < 0 || o.Workers < 0 {
+		return errors.New("超时、重试次数和处理goroutine数不能为负数")
+	}
+	if o.APDUTimeout != 0 {
+		device.WriteProperty(model.PropertyIdentifierAPDUTimeout, uint32(o.APDUTimeout/time.Millisecond))
+	}
+	if o.APDURetries != 0 {
+		device.WriteProperty(model.PropertyIdentifierNumberOfAPDURetries, uint32(o.APDURetries))
+	}
+	if o.MaxAPDU != 0 {
+		device.WriteProperty(model.PropertyIdentifierMaxAPDULengthAccepted, o.MaxAPDU)
+	}
+	if o.TimeSyncInterval < 0 || o.TimeSyncInterval%time.Minute != 0 {
+		return fmt.Errorf("时间同步周期应为整分钟: %v", o.TimeSyncInterval)
+	}
+	if o.TimeSyncInterval != 0 {
+		device.WriteProperty(model.PropertyIdentifierTimeSynchronizationInterval, uint32(o.TimeSyncInterval/time.Minute))
+	}
+	if _, ok := device.Properties[model.PropertyIdentifierUTCTimeSynchronizationRecipients]; o.UTCTimeSync && !ok {
+		// MAC为空的接收者即本地广播
+		device.WriteProperty(model.PropertyIdentifierUTCTimeSynchronizationRecipients, []model.Recipient{{Address: &model.Address{}}})
+	}
+	if o.SnapshotInterval < 0 {
+		return errors.New("快照周期不能为负数")
+	}
+	// 先恢复快照，状态文件在每次命令后保存，随后恢复时覆盖快照中较旧的优先级数组
+	if o.SnapshotFile != "" {
+		if err := model.LoadSnapshot(device, o.SnapshotFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listen 按监听配置创建UDP传输
+func (o Options) listen() (Transport, error) {
+	address := o.Address
+	if address == "" {
+		address = ":" + strconv.Itoa(DefaultPort)
+	}
+	if len(o.Interfaces) == 0 {
+		if o.ReusePort {
+			return NewSharedUDPTransport(address)
+		}
+		return NewUDPTransport(address)
+	}
+
+	_, portText, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portText)
+	if err != nil {
+		return nil, fmt.Errorf("无效的端口: %s", portText)
+	}
+	var transports []Transport
+	for _, name := range o.Interfaces {
+		transport, err := NewInterfaceTransport(name, port)
+		if err != nil {
+			for _, t := range transports {
+				t.Close()
+			}
+			return nil, err
+		}
+		transports = append(transports, transport)
+	}
+	if len(transports) == 1 {
+		return transports[0], nil
+	}
+	return NewMultiTransport(transports...)
+}
+
+// configure 应用依赖服务端的配置
+func (s *BACnetServer) configure(o Options) error {
+	s.log = o.Logger
+	if o.Clock != nil {
+		s.SetClock(o.Clock)
+	}
+	s.quarantineDir = o.QuarantineDir
+	s.workers = o.Workers
+	s.onError = o.OnError
+	s.readOnly = o.ReadOnly
+	s.snapshotFile = o.SnapshotFile
+	s.snapshotInterval = o.SnapshotInterval
+	if o.COVQueueSize < 0 {
+		return errors.New("COV通知队列长度不能为负数")
+	}
+	s.covQueues.size = o.COVQueueSize
+	s.auditLogger = o.AuditLogger
+	s.auditConfirmed = o.AuditConfirmed
+	if err := s.SetACL(o.ACL); err != nil {
+		return err
+	}
+	if err := s.SetEngineers(o.Engineers); err != nil {
+		return err
+	}
+	for _, p := range o.Protections {
+		s.Protect(p.Object, p.Property, p.Level)
+	}
+	if o.RateLimit < 0 || o.DiscoveryRateLimit < 0 {
+		return errors.New("限速不能为负数")
+	}
+	s.SetRateLimit(o.RateLimit, o.RateBurst)
+	s.SetDiscoveryRateLimit(o.DiscoveryRateLimit)
+	if o.BroadcastAddress != "" {
+		addr, err := net.ResolveUDPAddr("udp4", o.BroadcastAddress)
+		if err != nil {
+			return fmt.Errorf("无效的广播地址: %v", err)
+		}
+		s.broadcast = addr
+	}
+	if o.DSCP != 0 {
+		if err := s.SetDSCP(o.DSCP); err != nil {
+			return err
+		}
+	}
+	if o.ForeignBBMD != "" {
+		ttl := o.ForeignTTL
+		if ttl == 0 {
+			ttl = 60
+		}
+		if err := s.RegisterAsForeignDevice(o.ForeignBBMD, ttl); err != nil {
+			return err
+		}
+	}
+	if len(o.BDT) > 0 {
+		s.SetBroadcastDistributionTable(o.BDT)
+	}
+	if o.Network != 0 {
+		if err := s.EnableRouting(o.Network); err != nil {
+			return err
+		}
+	}
+	if o.CaptureFile != "" {
+		capture, err := CreatePcapFile(o.CaptureFile)
+		if err != nil {
+			return fmt.Errorf("创建抓包文件失败: %v", err)
+		}
+		s.captureFile = capture
+		s.SetCapture(capture)
+	}
+	if o.MetricsAddress != "" {
+		if err := s.serveMetrics(o.MetricsAddress); err != nil {
+			return err
+		}
+	}
+	if o.DashboardAddress != "" {
+		if err := s.serveDashboard(o.DashboardAddress); err != nil {
+			return err
+		}
+	}
+	if o.OPCUAAddress != "" {
+		if err := s.serveOPCUA(o.OPCUAAddress); err != nil {
+			return err
+		}
+	}
+	if o.ConsoleAddress != "" {
+		return s.serveConsole(o.ConsoleAddress)
+	}
+	return nil
+}
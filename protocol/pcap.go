@@ -0,0 +1,268 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// pcap文件格式（libpcap）的常量
+const (
+	pcapMagic        = 0xA1B2C3D4 // 微秒精度时间戳
+	pcapVersionMajor = 2
+	pcapVersionMinor = 4
+	pcapSnapLen      = 65535
+	pcapLinkTypeRaw  = 101 // LINKTYPE_RAW：数据包以IPv4头部开始
+	ipv4HeaderLength = 20
+	udpHeaderLength  = 8
+)
+
+// PcapWriter 以libpcap格式写出UDP数据报，每个数据报前加上IPv4和UDP头部，可直接用Wireshark打开
+type PcapWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer // 由CreatePcapFile打开的文件，Close时关闭
+}
+
+// NewPcapWriter 创建写入w的PcapWriter并写出文件头
+func NewPcapWriter(w io.Writer) (*PcapWriter, error) {
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header[0:], pcapMagic)
+	binary.LittleEndian.PutUint16(header[4:], pcapVersionMajor)
+	binary.LittleEndian.PutUint16(header[6:], pcapVersionMinor)
+	binary.LittleEndian.PutUint32(header[16:], pcapSnapLen)
+	binary.LittleEndian.PutUint32(header[20:], pcapLinkTypeRaw)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &PcapWriter{w: w}, nil
+}
+
+// CreatePcapFile 创建（或截断）path并返回写入它的PcapWriter
+func CreatePcapFile(path string) (*PcapWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := NewPcapWriter(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	p.closer = f
+	return p, nil
+}
+
+// WritePacket 写出一个从src发往dst的UDP数据报，只支持IPv4地址
+func (p *PcapWriter) WritePacket(t time.Time, src, dst *net.UDPAddr, payload []byte) error {
+	if src == nil || dst == nil || src.IP.To4() == nil || dst.IP.To4() == nil {
+		return errors.New("pcap只支持IPv4的UDP地址")
+	}
+	total := ipv4HeaderLength + udpHeaderLength + len(payload)
+	if total > pcapSnapLen {
+		return fmt.Errorf("数据报太长: %d字节", len(payload))
+	}
+	packet := make([]byte, 16+total)
+
+	// 记录头：时间戳秒、微秒，保存长度和原始长度
+	binary.LittleEndian.PutUint32(packet[0:], uint32(t.Unix()))
+	binary.LittleEndian.PutUint32(packet[4:], uint32(t.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(packet[8:], uint32(total))
+	binary.LittleEndian.PutUint32(packet[12:], uint32(total))
+
+	ip := packet[16:]
+	ip[0] = 0x45 // 版本4，头部长度5个32位字
+	binary.BigEndian.PutUint16(ip[2:], uint16(total))
+	ip[6] = 0x40 // 不分片
+	ip[8] = 64   // TTL
+	ip[9] = 17   // UDP
+	copy(ip[12:16], src.IP.To4())
+	copy(ip[16:20], dst.IP.To4())
+	binary.BigEndian.PutUint16(ip[10:], ipv4Checksum(ip[:ipv4HeaderLength]))
+
+	// UDP校验和在IPv4中可选，为0表示未计算
+	udp := ip[ipv4HeaderLength:]
+	binary.BigEndian.PutUint16(udp[0:], uint16(src.Port))
+	binary.BigEndian.PutUint16(udp[2:], uint16(dst.Port))
+	binary.BigEndian.PutUint16(udp[4:], uint16(udpHeaderLength+len(payload)))
+	copy(udp[udpHeaderLength:], payload)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err := p.w.Write(packet)
+	return err
+}
+
+// Close 关闭由CreatePcapFile打开的文件，其他PcapWriter不做处理
+func (p *PcapWriter) Close() error {
+	if p.closer == nil {
+		return nil
+	}
+	return p.closer.Close()
+}
+
+// ipv4Checksum 计算IPv4头部校验和，校验和字段须为0
+func ipv4Checksum(header []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(header); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(header[i:]))
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xFFFF + sum>>16
+	}
+	return ^uint16(sum)
+}
+
+// SetCapture 将此后收发的每个B/IP数据报写入p，p为nil时停止抓包。可在运行中随时调用，
+// 停止后由调用方关闭p
+func (s *BACnetServer) SetCapture(p *PcapWriter) {
+	s.capture.Store(p)
+}
+
+// capturePacket 抓包开启时写出一个数据报，received为true时从peer收到，否则发往peer
+func (s *BACnetServer) capturePacket(received bool, peer net.Addr, data []byte) {
+	p := s.capture.Load()
+	if p == nil {
+		return
+	}
+	local := &net.UDPAddr{IP: net.IPv4zero, Port: DefaultPort}
+	if s.transport != nil {
+		if addr := udpAddr(s.transport.LocalAddr()); addr != nil && addr.IP.To4() != nil && !addr.IP.IsUnspecified() {
+			local = addr
+		} else if addr != nil {
+			local = &net.UDPAddr{IP: net.IPv4zero, Port: addr.Port}
+		}
+	}
+	remote := udpAddr(peer)
+	if remote == nil {
+		return
+	}
+	src, dst := local, remote
+	if received {
+		src, dst = remote, local
+	}
+	if err := p.WritePacket(time.Now(), src, dst, data); err != nil {
+		s.Logger().Debug("写入抓包失败", "peer", peer.String(), "error", err)
+	}
+}
+
+// CapturedPacket 从pcap文件读出的一个UDP数据报
+type CapturedPacket struct {
+	Time        time.Time
+	Source      *net.UDPAddr
+	Destination *net.UDPAddr
+	Payload     []byte
+}
+
+// pcap文件中可以读出的链路类型
+const (
+	pcapLinkTypeEthernet = 1
+	pcapLinkTypeLinuxSLL = 113
+)
+
+// ReadPcap 读出pcap文件中的全部IPv4 UDP数据报，支持两种字节序、微秒和纳秒时间戳，
+// 以及原始IP、以太网和Linux cooked链路类型。其他协议的数据包和IP分片被跳过
+func ReadPcap(r io.Reader) ([]CapturedPacket, error) {
+	header := make([]byte, 24)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("读取pcap文件头失败: %w", err)
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	nanosecond := false
+	switch binary.LittleEndian.Uint32(header) {
+	case pcapMagic:
+	case 0xA1B23C4D:
+		nanosecond = true
+	default:
+		order = binary.BigEndian
+		switch binary.BigEndian.Uint32(header) {
+		case pcapMagic:
+		case 0xA1B23C4D:
+			nanosecond = true
+		default:
+			return nil, fmt.Errorf("不是pcap文件: % X", header[:4])
+		}
+	}
+	linkType := order.Uint32(header[20:]) & 0xFFFF
+
+	var packets []CapturedPacket
+	record := make([]byte, 16)
+	for {
+		if _, err := io.ReadFull(r, record); err != nil {
+			if errors.Is(err, io.EOF) {
+				return packets, nil
+			}
+			return nil, fmt.Errorf("读取pcap记录失败: %w", err)
+		}
+		length := order.Uint32(record[8:])
+		if length > pcapSnapLen*4 {
+			return nil, fmt.Errorf("pcap记录太长: %d字节", length)
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, fmt.Errorf("读取pcap记录失败: %w", err)
+		}
+		fraction := time.Duration(order.Uint32(record[4:]))
+		if !nanosecond {
+			fraction *= time.Microsecond
+		}
+		t := time.Unix(int64(order.Uint32(record[0:])), int64(fraction))
+		if packet, ok := parseCapturedUDP(linkType, data); ok {
+			packet.Time = t
+			packets = append(packets, packet)
+		}
+	}
+}
+
+// parseCapturedUDP 去掉链路层头部并解析IPv4 UDP数据报
+func parseCapturedUDP(linkType uint32, data []byte) (CapturedPacket, bool) {
+	switch linkType {
+	case pcapLinkTypeRaw:
+	case pcapLinkTypeEthernet:
+		if len(data) < 14 {
+			return CapturedPacket{}, false
+		}
+		etherType, offset := binary.BigEndian.Uint16(data[12:]), 14
+		if etherType == 0x8100 && len(data) >= 18 { // 802.1Q VLAN标签
+			etherType, offset = binary.BigEndian.Uint16(data[16:]), 18
+		}
+		if etherType != 0x0800 {
+			return CapturedPacket{}, false
+		}
+		data = data[offset:]
+	case pcapLinkTypeLinuxSLL:
+		if len(data) < 16 || binary.BigEndian.Uint16(data[14:]) != 0x0800 {
+			return CapturedPacket{}, false
+		}
+		data = data[16:]
+	default:
+		return CapturedPacket{}, false
+	}
+
+	if len(data) < ipv4HeaderLength || data[0]>>4 != 4 || data[9] != 17 {
+		return CapturedPacket{}, false
+	}
+	headerLength := int(data[0]&0x0F) * 4
+	total := int(binary.BigEndian.Uint16(data[2:]))
+	if binary.BigEndian.Uint16(data[6:])&0x3FFF != 0 { // 分片
+		return CapturedPacket{}, false
+	}
+	if total > len(data) || headerLength+udpHeaderLength > total {
+		return CapturedPacket{}, false
+	}
+	udp := data[headerLength:total]
+	udpLength := int(binary.BigEndian.Uint16(udp[4:]))
+	if udpLength < udpHeaderLength || udpLength > len(udp) {
+		return CapturedPacket{}, false
+	}
+	return CapturedPacket{
+		Source:      &net.UDPAddr{IP: net.IP(append([]byte(nil), data[12:16]...)), Port: int(binary.BigEndian.Uint16(udp[0:]))},
+		Destination: &net.UDPAddr{IP: net.IP(append([]byte(nil), data[16:20]...)), Port: int(binary.BigEndian.Uint16(udp[2:]))},
+		Payload:     append([]byte(nil), udp[udpHeaderLength:udpLength]...),
+	}, true
+}
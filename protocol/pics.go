@@ -0,0 +1,369 @@
+package protocol
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// picsServiceNames BACnetServicesSupported各位对应的EPICS服务名称
+var picsServiceNames = []string{
+	"AcknowledgeAlarm", "ConfirmedCOVNotification", "ConfirmedEventNotification", "GetAlarmSummary",
+	"GetEnrollmentSummary", "SubscribeCOV", "AtomicReadFile", "AtomicWriteFile",
+	"AddListElement", "RemoveListElement", "CreateObject", "DeleteObject",
+	"ReadProperty", "ReadPropertyConditional", "ReadPropertyMultiple", "WriteProperty",
+	"WritePropertyMultiple", "DeviceCommunicationControl", "ConfirmedPrivateTransfer", "ConfirmedTextMessage",
+	"ReinitializeDevice", "VT-Open", "VT-Close", "VT-Data",
+	"Authenticate", "RequestKey", "I-Am", "I-Have",
+	"UnconfirmedCOVNotification", "UnconfirmedEventNotification", "UnconfirmedPrivateTransfer", "UnconfirmedTextMessage",
+	"TimeSynchronization", "Who-Has", "Who-Is", "ReadRange",
+	"UTCTimeSynchronization", "LifeSafetyOperation", "SubscribeCOVProperty", "GetEventInformation",
+	"WriteGroup", "SubscribeCOVPropertyMultiple", "ConfirmedCOVNotificationMultiple", "UnconfirmedCOVNotificationMultiple",
+	"ConfirmedAuditNotification", "AuditLogQuery", "UnconfirmedAuditNotification", "Who-Am-I",
+	"You-Are",
+}
+
+// picsInitiated 只由本设备发起的服务，其余置位的服务由本设备执行
+var picsInitiated = map[int]bool{
+	1: true, 2: true, servicesSupportedIAm: true, servicesSupportedIHave: true,
+	servicesSupportedUnconfirmedCOV: true, servicesSupportedUnconfirmedEvent: true,
+	42: true, 43: true, 44: true, 46: true, 47: true,
+}
+
+// picsBIBBs BIBB及其要求的服务位和对象类型，全部满足时声明支持
+var picsBIBBs = []struct {
+	name        string
+	services    []int
+	objectTypes []model.ObjectType
+}{
+	{"DS-RP-B", []int{servicesSupportedReadProperty}, nil},
+	{"DS-RPM-B", []int{servicesSupportedReadPropertyMultiple}, nil},
+	{"DS-WP-B", []int{servicesSupportedWriteProperty}, nil},
+	{"DS-WPM-B", []int{servicesSupportedWritePropertyMultiple}, nil},
+	{"DS-COV-B", []int{servicesSupportedSubscribeCOV}, nil},
+	{"DS-COVP-B", []int{servicesSupportedSubscribeCOVProperty}, nil},
+	{"AE-N-I-B", []int{servicesSupportedUnconfirmedEvent}, []model.ObjectType{model.ObjectTypeNotificationClass}},
+	{"AE-ACK-B", []int{servicesSupportedAcknowledgeAlarm}, nil},
+	{"AE-ASUM-B", []int{3}, nil},
+	{"AE-ESUM-B", []int{4}, nil},
+	{"AE-INFO-B", []int{39}, nil},
+	{"AE-LS-B", []int{servicesSupportedLifeSafetyOperation}, []model.ObjectType{model.ObjectTypeLifeSafetyPoint}},
+	{"T-VMT-I-B", []int{servicesSupportedReadRange}, []model.ObjectType{model.ObjectTypeTrendLog}},
+	{"T-VMMV-I-B", []int{servicesSupportedReadRange}, []model.ObjectType{model.ObjectTypeTrendLogMultiple}},
+	{"DM-DDB-B", []int{servicesSupportedWhoIs, servicesSupportedIAm}, nil},
+	{"DM-DOB-B", []int{servicesSupportedWhoHas, servicesSupportedIHave}, nil},
+	{"DM-DCC-B", []int{17}, nil},
+	{"DM-PT-B", []int{18}, nil},
+	{"DM-RD-B", []int{20}, nil},
+	{"DM-TS-B", []int{32}, nil},
+	{"DM-UTC-B", []int{36}, nil},
+	{"DM-LM-B", []int{8, 9}, nil},
+	{"DM-OCD-B", []int{10, 11}, nil},
+}
+
+// PICSService 一个应用服务及本设备是否发起、执行它
+type PICSService struct {
+	Name     string
+	Initiate bool
+	Execute  bool
+}
+
+// PICS 协议实现一致性声明的内容，由服务端实际支持的服务、对象类型和对象数据库生成，
+// 写出前可以补充服务端不知道的信息（如未作为路由器端口的MS/TP链路）
+type PICS struct {
+	VendorName       string
+	ProductName      string
+	ModelNumber      string
+	Description      string
+	FirmwareRevision string
+	SoftwareVersion  string
+	ProtocolRevision uint32 // 为0时不写出
+	BIBBs            []string
+	Services         []PICSService
+	ObjectTypes      []model.ObjectType
+	DataLinks        []string
+	MaxAPDU          uint32
+	Objects          []model.Object // 设备对象在前
+}
+
+// PICS 按设备的Protocol_Services_Supported、Protocol_Object_Types_Supported和对象数据库生成一致性声明
+func (s *BACnetServer) PICS() *PICS {
+	text := func(prop model.PropertyIdentifier) string {
+		value, _ := s.device.ReadProperty(prop)
+		text, _ := value.(string)
+		return text
+	}
+	p := &PICS{
+		VendorName:       text(model.PropertyIdentifierVendorName),
+		ProductName:      text(model.PropertyIdentifierDeviceType),
+		ModelNumber:      text(model.PropertyIdentifierModelName),
+		Description:      text(model.PropertyIdentifierDescription),
+		FirmwareRevision: text(model.PropertyIdentifierFirmwareRevision),
+		SoftwareVersion:  text(model.PropertyIdentifierApplicationSoftwareVersion),
+		MaxAPDU:          1024,
+		Objects:          append([]model.Object{s.device}, s.device.Objects()...),
+	}
+	if p.Description == "" {
+		p.Description = s.device.GetObjectName()
+	}
+	if revision, ok := readProperty(s.device, model.PropertyIdentifierProtocolRevision).(uint32); ok {
+		p.ProtocolRevision = revision
+	}
+	if maxAPDU, ok := readProperty(s.device, model.PropertyIdentifierMaxAPDULengthAccepted).(uint32); ok {
+		p.MaxAPDU = maxAPDU
+	}
+
+	// 接受COV订阅即会向要求确认的订阅者发送确认COV通知
+	services, _ := readProperty(s.device, model.PropertyIdentifierProtocolServicesSupported).(model.BitString)
+	cov := services.Bit(servicesSupportedSubscribeCOV) || services.Bit(servicesSupportedSubscribeCOVProperty)
+	// 作为时间主站时发起时间同步
+	timeMaster := s.device.TimeSynchronizationInterval() > 0
+	// 配置了审计记录设备时发起审计通知
+	auditService := -1
+	if s.auditLogger != nil {
+		auditService = 46
+		if s.auditConfirmed {
+			auditService = 44
+		}
+	}
+	for i := 0; i < len(picsServiceNames); i++ {
+		supported := services.Bit(i)
+		service := PICSService{Name: picsServiceNames[i], Initiate: supported && picsInitiated[i], Execute: supported && !picsInitiated[i]}
+		service.Initiate = service.Initiate || i == 1 && cov
+		service.Initiate = service.Initiate || (i == 32 || i == 36) && timeMaster
+		service.Initiate = service.Initiate || i == auditService
+		if service.Initiate || service.Execute {
+			p.Services = append(p.Services, service)
+		}
+	}
+
+	objectTypes, _ := readProperty(s.device, model.PropertyIdentifierProtocolObjectTypesSupported).(model.BitString)
+	for i := 0; i < objectTypes.Len(); i++ {
+		if objectTypes.Bit(i) {
+			p.ObjectTypes = append(p.ObjectTypes, model.ObjectType(i))
+		}
+	}
+
+	for _, bibb := range picsBIBBs {
+		supported := true
+		for _, service := range bibb.services {
+			supported = supported && services.Bit(service)
+		}
+		for _, objType := range bibb.objectTypes {
+			supported = supported && objectTypes.Bit(int(objType))
+		}
+		if supported {
+			p.BIBBs = append(p.BIBBs, bibb.name)
+		}
+	}
+
+	p.DataLinks = s.picsDataLinks()
+	return p
+}
+
+// picsDataLinks 返回服务端的数据链路选项
+func (s *BACnetServer) picsDataLinks() []string {
+	var links []string
+	if s.transport != nil {
+		link := "BACnet IP, (Annex J)"
+		if s.bbmd {
+			link += ", BACnet Broadcast Management Device (BBMD)"
+		} else if s.bbmdAddr != nil {
+			link += ", Foreign Device"
+		}
+		links = append(links, link)
+	}
+	for _, port := range s.routerPorts {
+		if port == s.ipPort {
+			continue
+		}
+		switch {
+		case isVirtualNetwork(port.link):
+			links = append(links, fmt.Sprintf("Other: virtual network %d", port.Network))
+		case len(port.link.LocalMAC()) == 1:
+			links = append(links, fmt.Sprintf("MS/TP master (Clause 9), network %d", port.Network))
+		default:
+			links = append(links, fmt.Sprintf("Other: network %d", port.Network))
+		}
+	}
+	return links
+}
+
+// isVirtualNetwork 判断数据链路是否为虚拟网络
+func isVirtualNetwork(link Datalink) bool {
+	_, ok := link.(*VirtualNetwork)
+	return ok
+}
+
+// WriteTo 以EPICS文本格式写出一致性声明，可写属性的值后标注W
+func (p *PICS) WriteTo(w io.Writer) (int64, error) {
+	var b bytes.Buffer
+	b.WriteString("PICS 0\nBACnet Protocol Implementation Conformance Statement\n\n")
+	fmt.Fprintf(&b, "Vendor Name: %s\n", picsString(p.VendorName))
+	fmt.Fprintf(&b, "Product Name: %s\n", picsString(p.ProductName))
+	fmt.Fprintf(&b, "Product Model Number: %s\n", picsString(p.ModelNumber))
+	fmt.Fprintf(&b, "Product Description: %s\n", picsString(p.Description))
+	fmt.Fprintf(&b, "Application Software Version: %s\n", picsString(p.SoftwareVersion))
+	fmt.Fprintf(&b, "Firmware Revision: %s\n", picsString(p.FirmwareRevision))
+	if p.ProtocolRevision != 0 {
+		fmt.Fprintf(&b, "BACnet Protocol Revision: %d\n", p.ProtocolRevision)
+	}
+
+	picsSection(&b, "BIBBs Supported", p.BIBBs)
+
+	services := make([]string, len(p.Services))
+	for i, service := range p.Services {
+		var roles []string
+		if service.Initiate {
+			roles = append(roles, "Initiate")
+		}
+		if service.Execute {
+			roles = append(roles, "Execute")
+		}
+		services[i] = fmt.Sprintf("%-34s %s", service.Name, strings.Join(roles, " "))
+	}
+	picsSection(&b, "BACnet Standard Application Services Supported", services)
+
+	objectTypes := make([]string, len(p.ObjectTypes))
+	for i, objType := range p.ObjectTypes {
+		objectTypes[i] = picsObjectTypeName(objType)
+	}
+	picsSection(&b, "Standard Object Types Supported", objectTypes)
+
+	picsSection(&b, "Data Link Layer Option", p.DataLinks)
+	picsSection(&b, "Character Sets Supported", []string{"ISO 10646 (UTF-8)"})
+	picsSection(&b, "Special Functionality", []string{fmt.Sprintf("Maximum APDU size in octets: %d", p.MaxAPDU)})
+
+	b.WriteString("\nList of Objects in test device:\n{\n")
+	for i, obj := range p.Objects {
+		b.WriteString("  {\n")
+		objType := obj.GetObjectIdentifier().Type
+		for _, prop := range model.ExpandPropertyReference(obj, model.PropertyIdentifierAll) {
+			value, err := model.ReadPropertyValue(obj, prop)
+			if err != nil {
+				continue
+			}
+			meta, _ := model.LookupPropertyMetadata(objType, prop)
+			fmt.Fprintf(&b, "    %s: %s", prop, picsValue(value, meta.Datatype))
+			if meta.Writable {
+				b.WriteString(" W")
+			}
+			b.WriteString("\n")
+		}
+		if i < len(p.Objects)-1 {
+			b.WriteString("  },\n")
+		} else {
+			b.WriteString("  }\n")
+		}
+	}
+	b.WriteString("}\n\nEnd of BACnet Protocol Implementation Conformance Statement\n")
+
+	n, err := w.Write(b.Bytes())
+	return int64(n), err
+}
+
+// picsSection 写出以花括号包围、每行一项的EPICS段落
+func picsSection(b *bytes.Buffer, title string, lines []string) {
+	fmt.Fprintf(b, "\n%s:\n{\n", title)
+	for _, line := range lines {
+		fmt.Fprintf(b, "  %s\n", line)
+	}
+	b.WriteString("}\n")
+}
+
+// picsObjectTypeName 返回EPICS中的对象类型名称，如Analog Input、Multi-state Value
+func picsObjectTypeName(objType model.ObjectType) string {
+	words := strings.Split(objType.String(), "-")
+	for i, word := range words {
+		if word != "" {
+			words[i] = strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return strings.NewReplacer("Multi State", "Multi-state", "Characterstring", "CharacterString",
+		"Datetime", "DateTime", "Octetstring", "OctetString").Replace(strings.Join(words, " "))
+}
+
+// picsString 返回EPICS字符串，EPICS字符串中不能出现双引号
+func picsString(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "'") + `"`
+}
+
+// picsValue 以EPICS语法表示属性值，无法表示的构造类型写为?（不指定值）
+func picsValue(value interface{}, datatype model.Datatype) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		// 二值对象的Present_Value等属性是BACnetBinaryPV枚举
+		if datatype == model.DatatypeEnumerated {
+			if v {
+				return "active"
+			}
+			return "inactive"
+		}
+		if v {
+			return "T"
+		}
+		return "F"
+	case string:
+		return picsString(v)
+	case model.ObjectIdentifier:
+		return fmt.Sprintf("(%s, %d)", v.Type, v.Instance)
+	case model.BitString:
+		bits := make([]string, v.Len())
+		for i := range bits {
+			bits[i] = picsValue(v.Bit(i), model.DatatypeBoolean)
+		}
+		return "{" + strings.Join(bits, ",") + "}"
+	case []byte:
+		return fmt.Sprintf("X'%X'", v)
+	case float32:
+		return picsFloat(float64(v), 32)
+	case float64:
+		return picsFloat(v, 64)
+	case time.Time:
+		if v.IsZero() {
+			return "?"
+		}
+		return fmt.Sprintf("{(%d-%s-%d, %s), %s}", v.Day(), v.Month(), v.Year(), v.Weekday(), v.Format("15:04:05.00"))
+	case uint8:
+		// Status_Flags以低4位表示
+		if datatype == model.DatatypeBitString {
+			return picsValue(model.BitString{v&1 != 0, v&2 != 0, v&4 != 0, v&8 != 0}, datatype)
+		}
+	case fmt.Stringer:
+		return v.String()
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		items := make([]string, rv.Len())
+		for i := range items {
+			items[i] = picsValue(rv.Index(i).Interface(), model.DatatypeAny)
+		}
+		return "{" + strings.Join(items, ", ") + "}"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprint(value)
+	}
+	return "?"
+}
+
+// picsFloat 按原精度写出实数，整数值也带小数点以区别于Unsigned
+func picsFloat(f float64, bitSize int) string {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "?"
+	}
+	text := strconv.FormatFloat(f, 'g', -1, bitSize)
+	if !strings.ContainsAny(text, ".e") {
+		text += ".0"
+	}
+	return text
+}
@@ -0,0 +1,135 @@
+package protocol
+
+import (
+	"fmt"
+
+	"github.com/iotzf/bacnet-server/encoding"
+)
+
+// PrivateTransfer 收到的厂商私有服务请求
+type PrivateTransfer struct {
+	Peer          string // 客户端地址
+	VendorID      uint32
+	ServiceNumber uint32
+	Parameters    []byte // serviceParameters的编码内容，不含上下文标签[2]；没有参数时为nil
+	Confirmed     bool   // 是否为ConfirmedPrivateTransfer
+}
+
+// PrivateTransferHandler 处理厂商私有服务，返回的结果（编码后的BACnet值）作为ComplexAck的resultBlock，
+// 为nil时应答不带resultBlock，UnconfirmedPrivateTransfer的结果被丢弃。
+// 返回*Error时以其错误类别和代码应答，其他错误应答services类的other
+type PrivateTransferHandler func(request PrivateTransfer) ([]byte, error)
+
+// privateTransferKey 厂商私有服务的注册键
+type privateTransferKey struct {
+	vendorID      uint32
+	serviceNumber uint32
+}
+
+// HandlePrivateTransfer 注册厂商vendorID的私有服务serviceNumber的处理函数，handler为nil时取消注册。
+// 没有注册的私有服务以services类的optional-functionality-not-supported错误应答
+func (s *BACnetServer) HandlePrivateTransfer(vendorID, serviceNumber uint32, handler PrivateTransferHandler) {
+	key := privateTransferKey{vendorID: vendorID, serviceNumber: serviceNumber}
+	if handler == nil {
+		s.privateTransfers.Delete(key)
+		return
+	}
+	s.privateTransfers.Store(key, handler)
+}
+
+// parsePrivateTransferRequest 解析私有服务请求
+//
+//	ConfirmedPrivateTransfer-Request ::= SEQUENCE {
+//	  vendorID          [0] Unsigned16,
+//	  serviceNumber     [1] Unsigned,
+//	  serviceParameters [2] ABSTRACT-SYNTAX.&Type OPTIONAL }
+//
+// UnconfirmedPrivateTransfer-Request的格式相同
+func parsePrivateTransferRequest(data []byte) (PrivateTransfer, error) {
+	var request PrivateTransfer
+	d := encoding.NewDecoder(data)
+	var err error
+	if request.VendorID, err = d.ContextUnsigned(0); err != nil {
+		return request, err
+	}
+	if request.ServiceNumber, err = d.ContextUnsigned(1); err != nil {
+		return request, err
+	}
+	if d.IsOpening(2) {
+		if request.Parameters, err = d.Constructed(2); err != nil {
+			return request, err
+		}
+	}
+	if !d.Done() {
+		return request, fmt.Errorf("私有服务请求有多余的数据")
+	}
+	return request, nil
+}
+
+// handlePrivateTransfer 处理ConfirmedPrivateTransfer和UnconfirmedPrivateTransfer，未确认请求没有应答
+func (s *BACnetServer) handlePrivateTransfer(ctx *RequestContext, data []byte, invokeID byte, confirmed bool) ([]byte, error) {
+	request, err := parsePrivateTransferRequest(data)
+	if err != nil {
+		if !confirmed {
+			return nil, fmt.Errorf("UnconfirmedPrivateTransfer: %w", err)
+		}
+		return s.createRejectResponse(invokeID, rejectReasonFor(err)), nil
+	}
+	request.Peer = ctx.ClientAddr
+	request.Confirmed = confirmed
+	s.logPacket("收到PrivateTransfer", "peer", ctx.ClientAddr, "vendor_id", request.VendorID, "service_number", request.ServiceNumber,
+		"confirmed", confirmed, "parameter_bytes", len(request.Parameters))
+
+	var result []byte
+	handler, ok := s.privateTransfers.Load(privateTransferKey{vendorID: request.VendorID, serviceNumber: request.ServiceNumber})
+	if ok {
+		result, err = handler.(PrivateTransferHandler)(request)
+	} else {
+		err = &Error{Class: ErrorClassService, Code: ErrorCodeOptionalFunctionalityNotSupported}
+	}
+	if !confirmed {
+		if err != nil {
+			s.Logger().Debug("UnconfirmedPrivateTransfer处理失败", "peer", ctx.ClientAddr, "vendor_id", request.VendorID,
+				"service_number", request.ServiceNumber, "error", err)
+		}
+		return nil, nil
+	}
+	if err != nil {
+		e := asError(err, ErrorClassService, ErrorCodeOther)
+		return s.createPrivateTransferError(invokeID, request, e), nil
+	}
+	return encodeComplexAck(invokeID, BACnetServiceConfirmedPrivateTransfer, encodePrivateTransfer(request.VendorID, request.ServiceNumber, result)), nil
+}
+
+// encodePrivateTransfer 编码私有服务的请求或ConfirmedPrivateTransfer-ACK，data为nil时不带[2]
+//
+//	ConfirmedPrivateTransfer-ACK ::= SEQUENCE {
+//	  vendorID      [0] Unsigned16,
+//	  serviceNumber [1] Unsigned,
+//	  resultBlock   [2] ABSTRACT-SYNTAX.&Type OPTIONAL }
+func encodePrivateTransfer(vendorID, serviceNumber uint32, data []byte) []byte {
+	out := encoding.EncodeContextUnsigned(0, vendorID)
+	out = append(out, encoding.EncodeContextUnsigned(1, serviceNumber)...)
+	if data != nil {
+		out = append(out, encoding.EncodeConstructed(2, data)...)
+	}
+	return out
+}
+
+// createPrivateTransferError 创建ConfirmedPrivateTransfer的错误应答
+//
+//	ConfirmedPrivateTransfer-Error ::= SEQUENCE {
+//	  errorType       [0] Error,
+//	  vendorID        [1] Unsigned16,
+//	  serviceNumber   [2] Unsigned,
+//	  errorParameters [3] ABSTRACT-SYNTAX.&Type OPTIONAL }
+func (s *BACnetServer) createPrivateTransferError(invokeID byte, request PrivateTransfer, e *Error) []byte {
+	s.metrics.errors.inc(fmt.Sprintf("%d/%d", e.Class, e.Code))
+	response := []byte{BACnetAPDUTypeError << 4, invokeID, BACnetServiceConfirmedPrivateTransfer}
+	response = append(response, encoding.EncodeOpeningTag(0)...)
+	response = append(response, encoding.EncodeEnumerated(uint32(e.Class))...)
+	response = append(response, encoding.EncodeEnumerated(uint32(e.Code))...)
+	response = append(response, encoding.EncodeClosingTag(0)...)
+	response = append(response, encoding.EncodeContextUnsigned(1, request.VendorID)...)
+	return append(response, encoding.EncodeContextUnsigned(2, request.ServiceNumber)...)
+}
@@ -0,0 +1,110 @@
+package protocol
+
+import (
+	"net"
+	"sync"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// Role 客户端的角色，决定可以写入哪些受保护的属性
+type Role int
+
+const (
+	RoleOperator Role = iota // 默认角色，只能写入未保护的属性
+	RoleEngineer             // 工程师，还可以写入EngineerOnly的属性
+)
+
+// ProtectionLevel 属性或对象的写保护级别
+type ProtectionLevel int
+
+const (
+	ProtectionNone         ProtectionLevel = iota // 不保护
+	ProtectionEngineerOnly                        // 只有工程师角色可以经网络写入
+	ProtectionReadOnly                            // 任何客户端都不能经网络写入
+)
+
+// WriteProtection 一条写保护配置，只限制经网络的写入，本地API仍可修改
+type WriteProtection struct {
+	Object   model.ObjectIdentifier   // 保护的对象
+	Property model.PropertyIdentifier // 保护的属性，为PropertyIdentifierAll时保护对象的所有属性
+	Level    ProtectionLevel
+}
+
+// protectionKey 写保护表的键
+type protectionKey struct {
+	object   model.ObjectIdentifier
+	property model.PropertyIdentifier
+}
+
+// protections 写保护表和工程师角色的来源
+type protections struct {
+	mu        sync.RWMutex
+	levels    map[protectionKey]ProtectionLevel
+	engineers []*net.IPNet
+}
+
+// Protect 设置对象属性的写保护级别，prop为PropertyIdentifierAll时设置整个对象，
+// 对象和属性都有配置时取较严格的级别
+func (s *BACnetServer) Protect(oid model.ObjectIdentifier, prop model.PropertyIdentifier, level ProtectionLevel) {
+	p := &s.protections
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key := protectionKey{object: oid, property: prop}
+	if level == ProtectionNone {
+		delete(p.levels, key)
+		return
+	}
+	if p.levels == nil {
+		p.levels = make(map[protectionKey]ProtectionLevel)
+	}
+	p.levels[key] = level
+}
+
+// SetEngineers 设置具有工程师角色的客户端来源（IP或子网），其他客户端为操作员
+func (s *BACnetServer) SetEngineers(sources []string) error {
+	engineers := make([]*net.IPNet, 0, len(sources))
+	for _, source := range sources {
+		network, err := parseSource(source)
+		if err != nil {
+			return err
+		}
+		engineers = append(engineers, network)
+	}
+	p := &s.protections
+	p.mu.Lock()
+	p.engineers = engineers
+	p.mu.Unlock()
+	return nil
+}
+
+// roleOf 返回客户端的角色
+func (p *protections) roleOf(peer string) Role {
+	ip := peerIP(peer)
+	if ip == nil {
+		return RoleOperator
+	}
+	for _, network := range p.engineers {
+		if network.Contains(ip) {
+			return RoleEngineer
+		}
+	}
+	return RoleOperator
+}
+
+// checkWrite 检查ctx的客户端是否可以写入对象属性，拒绝时返回write-access-denied错误
+func (p *protections) checkWrite(ctx *RequestContext, obj model.Object, prop model.PropertyIdentifier) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	oid := obj.GetObjectIdentifier()
+	level := p.levels[protectionKey{object: oid, property: model.PropertyIdentifierAll}]
+	if propLevel := p.levels[protectionKey{object: oid, property: prop}]; propLevel > level {
+		level = propLevel
+	}
+	switch {
+	case level == ProtectionReadOnly,
+		level == ProtectionEngineerOnly && p.roleOf(ctx.peer()) != RoleEngineer:
+		return &Error{Class: ErrorClassProperty, Code: ErrorCodeWriteAccessDenied}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package protocol
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// SetQuarantineDir 设置隔离目录，处理时引发panic的数据报会写入该目录，为空时不保存
+func (s *BACnetServer) SetQuarantineDir(dir string) {
+	s.quarantineDir = dir
+}
+
+// MalformedPackets 返回处理时引发panic的数据报数量
+func (s *BACnetServer) MalformedPackets() uint64 {
+	return atomic.LoadUint64(&s.malformedPackets)
+}
+
+// processDatagram 处理一个数据报，处理过程中的panic被恢复为错误，
+// 以免单个畸形报文使接收循环退出
+func (s *BACnetServer) processDatagram(ctx *RequestContext, data []byte) (response []byte, err error) {
+	from := ctx.ClientAddr
+	defer func() {
+		if r := recover(); r != nil {
+			count := atomic.AddUint64(&s.malformedPackets, 1)
+			stack := debug.Stack()
+			s.Logger().Error("处理数据报时发生panic", "peer", from, "count", count, "error", r, "data", fmt.Sprintf("% X", data), "stack", string(stack))
+			s.quarantine(count, data, from, r, stack)
+			response, err = nil, fmt.Errorf("处理数据报时发生panic: %v", r)
+		}
+	}()
+	return s.processBACnetMessage(ctx, data)
+}
+
+// quarantine 将引发panic的数据报写入隔离目录：.bin为原始数据，.txt记录来源、错误和调用栈
+func (s *BACnetServer) quarantine(count uint64, data []byte, from string, reason interface{}, stack []byte) {
+	if s.quarantineDir == "" {
+		return
+	}
+	if err := os.MkdirAll(s.quarantineDir, 0o755); err != nil {
+		s.Logger().Error("创建隔离目录失败", "dir", s.quarantineDir, "error", err)
+		return
+	}
+
+	name := fmt.Sprintf("%s-%d-%s", time.Now().Format("20060102-150405.000"), count,
+		strings.NewReplacer(":", "_", "[", "", "]", "").Replace(from))
+	base := filepath.Join(s.quarantineDir, name)
+	if err := os.WriteFile(base+".bin", data, 0o644); err != nil {
+		s.Logger().Error("保存隔离数据报失败", "error", err)
+		return
+	}
+	report := fmt.Sprintf("来源: %s\n错误: %v\n数据: % X\n\n%s", from, reason, data, stack)
+	if err := os.WriteFile(base+".txt", []byte(report), 0o644); err != nil {
+		s.Logger().Error("保存隔离报告失败", "error", err)
+	}
+}
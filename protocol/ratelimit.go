@@ -0,0 +1,131 @@
+package protocol
+
+import (
+	"math"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxRateBuckets 按来源限速时保留的令牌桶数量上限，超过时清理已装满的桶
+const maxRateBuckets = 4096
+
+// tokenBucket 令牌桶，按rate每秒补充令牌，最多burst个
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take 补充令牌后取走一个，没有令牌时返回false
+func (b *tokenBucket) take(now time.Time, rate, burst float64) bool {
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// rateLimiter 按来源IP和全局Who-Is/Who-Has限速
+type rateLimiter struct {
+	mu               sync.Mutex
+	rate             float64                 // 每个来源每秒的数据报数，为0时不限速
+	burst            float64                 // 每个来源允许的突发数据报数
+	buckets          map[string]*tokenBucket // 键为来源IP
+	discoveryRate    float64                 // 全局每秒应答的Who-Is和Who-Has数，为0时不限速
+	discoveryBurst   float64                 // 全局允许的突发数，至少为1
+	discovery        tokenBucket
+	dropped          uint64 // 按来源限速丢弃的数据报数，原子访问
+	droppedDiscovery uint64 // 全局限速丢弃的Who-Is和Who-Has数，原子访问
+}
+
+// SetRateLimit 按来源IP限制每秒处理的数据报数，burst为允许的突发数，rate为0时不限速。
+// 超出的数据报在处理前丢弃，使单个客户端无法占满处理循环
+func (s *BACnetServer) SetRateLimit(rate float64, burst int) {
+	l := &s.limiter
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if burst < 1 {
+		burst = 1
+	}
+	l.rate, l.burst = rate, float64(burst)
+	l.buckets = nil
+}
+
+// SetDiscoveryRateLimit 全局限制每秒应答的Who-Is和Who-Has数，防止广播风暴时
+// 大量发送I-Am和I-Have，rate为0时不限速
+func (s *BACnetServer) SetDiscoveryRateLimit(rate float64) {
+	l := &s.limiter
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.discoveryRate, l.discoveryBurst = rate, math.Max(rate, 1)
+	l.discovery = tokenBucket{tokens: l.discoveryBurst, last: s.now()}
+}
+
+// RateLimitedPackets 返回按来源限速丢弃的数据报数量
+func (s *BACnetServer) RateLimitedPackets() uint64 {
+	return atomic.LoadUint64(&s.limiter.dropped)
+}
+
+// RateLimitedDiscovery 返回全局限速丢弃的Who-Is和Who-Has数量
+func (s *BACnetServer) RateLimitedDiscovery() uint64 {
+	return atomic.LoadUint64(&s.limiter.droppedDiscovery)
+}
+
+// allow 判断来自addr的数据报是否在限速内，超出时计数并返回false
+func (l *rateLimiter) allow(addr net.Addr, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate <= 0 {
+		return true
+	}
+	key := addr.String()
+	if host, _, err := net.SplitHostPort(key); err == nil {
+		key = host
+	}
+	bucket, ok := l.buckets[key]
+	if !ok {
+		if l.buckets == nil {
+			l.buckets = make(map[string]*tokenBucket)
+		}
+		if len(l.buckets) >= maxRateBuckets {
+			l.prune(now)
+		}
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = bucket
+	}
+	if !bucket.take(now, l.rate, l.burst) {
+		atomic.AddUint64(&l.dropped, 1)
+		return false
+	}
+	return true
+}
+
+// prune 删除已经补满的令牌桶，它们与新建的桶没有区别
+func (l *rateLimiter) prune(now time.Time) {
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.last) >= full {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// allowDiscovery 判断是否应答一个Who-Is或Who-Has，超出全局限速时计数并返回false
+func (l *rateLimiter) allowDiscovery(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.discoveryRate <= 0 {
+		return true
+	}
+	if !l.discovery.take(now, l.discoveryRate, l.discoveryBurst) {
+		atomic.AddUint64(&l.droppedDiscovery, 1)
+		return false
+	}
+	return true
+}
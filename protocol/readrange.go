@@ -0,0 +1,323 @@
+package protocol
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/iotzf/bacnet-server/encoding"
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// ReadRange 范围类型
+const (
+	ReadRangeAll              = 0
+	ReadRangeByPosition       = 3
+	ReadRangeBySequenceNumber = 6
+	ReadRangeByTime           = 7
+)
+
+// ReadRangeRequest ReadRange请求结构
+type ReadRangeRequest struct {
+	ObjectID      model.ObjectIdentifier
+	PropertyID    model.PropertyIdentifier
+	ArrayIndex    *uint32
+	RangeType     int       // ReadRangeAll/ByPosition/BySequenceNumber/ByTime
+	Reference     uint32    // 参考位置或参考序列号
+	ReferenceTime time.Time // 按时间读取时的参考时间
+	Count         int32     // 正数向后读取，负数向前读取
+}
+
+// parseReadRangeRequest 解析ReadRange请求
+//
+//	ReadRange-Request ::= SEQUENCE {
+//	  objectIdentifier   [0] BACnetObjectIdentifier,
+//	  propertyIdentifier [1] BACnetPropertyIdentifier,
+//	  propertyArrayIndex [2] Unsigned OPTIONAL,
+//	  range CHOICE {
+//	    byPosition       [3] SEQUENCE { referenceIndex Unsigned, count INTEGER },
+//	    bySequenceNumber [6] SEQUENCE { referenceSequenceNumber Unsigned32, count INTEGER },
+//	    byTime           [7] SEQUENCE { referenceTime BACnetDateTime, count INTEGER }
+//	  } OPTIONAL }
+func parseReadRangeRequest(data []byte) (ReadRangeRequest, error) {
+	var request ReadRangeRequest
+	d := encoding.NewDecoder(data)
+
+	var err error
+	if request.ObjectID, err = d.ContextObjectIdentifier(0); err != nil {
+		return request, err
+	}
+	propertyID, err := d.ContextUnsigned(1)
+	if err != nil {
+		return request, err
+	}
+	request.PropertyID = model.PropertyIdentifier(propertyID)
+
+	if d.IsContext(2) {
+		index, err := d.ContextUnsigned(2)
+		if err != nil {
+			return request, err
+		}
+		request.ArrayIndex = &index
+	}
+
+	switch {
+	case d.Done():
+		request.RangeType = ReadRangeAll
+		return request, nil
+	case d.IsOpening(ReadRangeByPosition):
+		request.RangeType = ReadRangeByPosition
+		d.Opening(ReadRangeByPosition)
+		if request.Reference, err = d.ApplicationUnsigned(); err != nil {
+			return request, err
+		}
+	case d.IsOpening(ReadRangeBySequenceNumber):
+		request.RangeType = ReadRangeBySequenceNumber
+		d.Opening(ReadRangeBySequenceNumber)
+		if request.Reference, err = d.ApplicationUnsigned(); err != nil {
+			return request, err
+		}
+	case d.IsOpening(ReadRangeByTime):
+		request.RangeType = ReadRangeByTime
+		d.Opening(ReadRangeByTime)
+		if request.ReferenceTime, err = d.ApplicationDateTime(); err != nil {
+			return request, err
+		}
+	default:
+		return request, fmt.Errorf("未知的ReadRange范围类型")
+	}
+
+	if request.Count, err = d.ApplicationSigned(); err != nil {
+		return request, err
+	}
+	if err := d.Closing(uint8(request.RangeType)); err != nil {
+		return request, err
+	}
+	return request, nil
+}
+
+// selectLogRecords 按请求的范围从缓冲区中选取记录
+// 返回选中的记录以及结果标志：first-item、last-item、more-items
+func selectLogRecords(records []model.LogRecord, request ReadRangeRequest) ([]model.LogRecord, [3]bool) {
+	var flags [3]bool
+	total := len(records)
+	if total == 0 {
+		return nil, flags
+	}
+
+	// 计算起止下标 [start, end)
+	start, end := 0, total
+	count := int(request.Count)
+	switch request.RangeType {
+	case ReadRangeByPosition, ReadRangeBySequenceNumber:
+		ref := -1
+		if request.RangeType == ReadRangeByPosition {
+			ref = int(request.Reference) - 1
+		} else {
+			for i, r := range records {
+				if r.SequenceNumber == request.Reference {
+					ref = i
+					break
+				}
+			}
+		}
+		if ref < 0 || ref >= total || count == 0 {
+			return nil, flags
+		}
+		if count > 0 {
+			start, end = ref, ref+count
+		} else {
+			start, end = ref+count+1, ref+1
+		}
+	case ReadRangeByTime:
+		if count == 0 {
+			return nil, flags
+		}
+		if count > 0 {
+			// 时间严格晚于参考时间的第一条记录开始
+			start = total
+			for i, r := range records {
+				if r.Timestamp.After(request.ReferenceTime) {
+					start = i
+					break
+				}
+			}
+			end = start + count
+		} else {
+			// 时间严格早于参考时间的最后一条记录结束
+			end = 0
+			for i := total - 1; i >= 0; i-- {
+				if records[i].Timestamp.Before(request.ReferenceTime) {
+					end = i + 1
+					break
+				}
+			}
+			start = end + count
+		}
+	}
+
+	// more-items：范围被缓冲区边界截断之外还有可选的记录
+	if count > 0 && end < total {
+		flags[2] = true
+	}
+	if count < 0 && start > 0 {
+		flags[2] = true
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end > total {
+		end = total
+	}
+	if start >= end {
+		return nil, [3]bool{}
+	}
+
+	flags[0] = start == 0
+	flags[1] = end == total
+	return records[start:end], flags
+}
+
+// handleReadRange 处理ReadRange请求
+func (s *BACnetServer) handleReadRange(data []byte, invokeID byte) ([]byte, error) {
+	request, err := parseReadRangeRequest(data)
+	if err != nil {
+		// 请求格式错误，按BACnet协议拒绝
+		return s.createRejectResponse(invokeID, rejectReasonFor(err)), nil
+	}
+
+	targetObj := s.device.FindObject(request.ObjectID)
+	if targetObj == nil {
+		return s.createErrorResponse(invokeID, BACnetServiceConfirmedReadRange,
+			ErrorClassObject, ErrorCodeObjectNotExist), nil
+	}
+
+	logObj, ok := targetObj.(model.LogObject)
+	if !ok || request.PropertyID != model.PropertyIdentifierLogBuffer {
+		return s.createErrorResponse(invokeID, BACnetServiceConfirmedReadRange,
+			ErrorClassProperty, ErrorCodePropertyNotExist), nil
+	}
+
+	records, flags := selectLogRecords(logObj.Buffer().Records, request)
+
+	out := encoding.EncodeContextObjectIdentifier(0, request.ObjectID)
+	out = append(out, encoding.EncodeContextUnsigned(1, uint32(request.PropertyID))...)
+	if request.ArrayIndex != nil {
+		out = append(out, encoding.EncodeContextUnsigned(2, *request.ArrayIndex)...)
+	}
+	out = append(out, encoding.EncodeContextBitString(3, flags[:])...)
+	out = append(out, encoding.EncodeContextUnsigned(4, uint32(len(records)))...)
+	out = append(out, encoding.EncodeOpeningTag(5)...)
+	for _, record := range records {
+		out = append(out, s.encodeLogRecord(record)...)
+	}
+	out = append(out, encoding.EncodeClosingTag(5)...)
+	if len(records) > 0 && (request.RangeType == ReadRangeBySequenceNumber || request.RangeType == ReadRangeByTime) {
+		out = append(out, encoding.EncodeContextUnsigned(6, records[0].SequenceNumber)...)
+	}
+
+	s.logPacket("ReadRange", "object", targetObj.GetObjectName(), "records", len(records))
+
+	return encodeComplexAck(invokeID, BACnetServiceConfirmedReadRange, out), nil
+}
+
+// encodeLogRecord 编码一条日志记录
+//
+//	BACnetEventLogRecord ::= SEQUENCE {
+//	  timestamp [0] BACnetDateTime,
+//	  logDatum  [1] CHOICE { log-status [0] BACnetLogStatus, notification [1] ConfirmedEventNotification-Request } }
+//
+//	BACnetLogRecord ::= SEQUENCE {
+//	  timestamp    [0] BACnetDateTime,
+//	  logDatum     [1] CHOICE { log-status [0], boolean-value [1], real-value [2], enumerated-value [3],
+//	                            unsigned-value [4], integer-value [5], null-value [7], failure [8], any-value [10] },
+//	  status-flags [2] BACnetStatusFlags OPTIONAL }
+//
+//	BACnetAuditLogRecord ::= SEQUENCE {
+//	  timestamp [0] BACnetDateTime,
+//	  logDatum  [1] CHOICE { log-status [0] BACnetLogStatus, audit-notification [1] BACnetAuditNotification, time-change [2] REAL } }
+//
+//	BACnetLogMultipleRecord ::= SEQUENCE {
+//	  timestamp [0] BACnetDateTime,
+//	  logData   [1] CHOICE { log-status [0] BACnetLogStatus, log-data [1] SEQUENCE OF CHOICE {...} } }
+func (s *BACnetServer) encodeLogRecord(record model.LogRecord) []byte {
+	out := encodeDateTime(0, record.Timestamp)
+	out = append(out, encoding.EncodeOpeningTag(1)...)
+	switch datum := record.Datum.(type) {
+	case model.LogStatus:
+		out = append(out, encoding.EncodeContextBitString(0, logStatusBits(datum))...)
+	case model.EventNotification:
+		out = append(out, encoding.EncodeOpeningTag(1)...)
+		out = append(out, s.encodeEventNotification(datum)...)
+		out = append(out, encoding.EncodeClosingTag(1)...)
+	case model.AuditNotification:
+		out = append(out, encoding.EncodeOpeningTag(1)...)
+		out = append(out, encodeAuditNotification(record.Timestamp, datum)...)
+		out = append(out, encoding.EncodeClosingTag(1)...)
+	case model.LogMultipleDatum:
+		out = append(out, encoding.EncodeOpeningTag(1)...)
+		for _, value := range datum {
+			out = append(out, encodeLogDatumValue(value, 1)...)
+		}
+		out = append(out, encoding.EncodeClosingTag(1)...)
+	default:
+		out = append(out, encodeLogDatumValue(datum, 0)...)
+	}
+	out = append(out, encoding.EncodeClosingTag(1)...)
+	if record.StatusFlags != nil {
+		out = append(out, encoding.EncodeContextBitString(2, model.StatusFlagsBits(*record.StatusFlags))...)
+	}
+	return out
+}
+
+// encodeLogDatumValue 按值类型编码日志值选项
+// 多对象趋势日志的log-data选项从boolean-value [0]开始，比单对象趋势日志的选项编号小offset
+func encodeLogDatumValue(value interface{}, offset uint8) []byte {
+	switch v := value.(type) {
+	case nil:
+		return encoding.EncodeContextNull(7 - offset)
+	case bool:
+		return encoding.EncodeContextBoolean(1-offset, v)
+	case float32:
+		return encoding.EncodeContextReal(2-offset, v)
+	case float64:
+		return encoding.EncodeContextReal(2-offset, float32(v))
+	case model.EventState:
+		return encoding.EncodeContextEnumerated(3-offset, uint32(v))
+	case model.LoggingType:
+		return encoding.EncodeContextEnumerated(3-offset, uint32(v))
+	case uint8:
+		return encoding.EncodeContextUnsigned(4-offset, uint32(v))
+	case uint16:
+		return encoding.EncodeContextUnsigned(4-offset, uint32(v))
+	case uint32:
+		return encoding.EncodeContextUnsigned(4-offset, v)
+	case int:
+		return encoding.EncodeContextSigned(5-offset, int32(v))
+	case int32:
+		return encoding.EncodeContextSigned(5-offset, v)
+	case model.LogFailure:
+		out := encoding.EncodeOpeningTag(8 - offset)
+		out = append(out, encoding.EncodeEnumerated(ErrorClassProperty)...)
+		out = append(out, encoding.EncodeEnumerated(ErrorCodePropertyNotReadable)...)
+		return append(out, encoding.EncodeClosingTag(8-offset)...)
+	}
+
+	out := encoding.EncodeOpeningTag(10 - 2*offset)
+	switch v := value.(type) {
+	case string:
+		out = append(out, encoding.EncodeCharacterString(v)...)
+	case time.Time:
+		out = append(out, encoding.EncodeDate(encoding.NewDate(v))...)
+		out = append(out, encoding.EncodeTime(encoding.NewTime(v))...)
+	case model.ObjectIdentifier:
+		out = append(out, encoding.EncodeObjectIdentifier(v)...)
+	default:
+		out = append(out, encoding.EncodeNull()...)
+	}
+	return append(out, encoding.EncodeClosingTag(10-2*offset)...)
+}
+
+// logStatusBits 将日志状态转换为位串：log-disabled、buffer-purged、log-interrupted
+func logStatusBits(status model.LogStatus) model.BitString {
+	return model.BitStringFromUint(3, uint64(status))
+}
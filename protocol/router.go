@@ -0,0 +1,228 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/iotzf/bacnet-server/encoding"
+)
+
+const (
+	// globalBroadcastNetwork 全局广播的目标网络号
+	globalBroadcastNetwork = 0xFFFF
+	// defaultHopCount 本设备发起的带目标网络的NPDU的跳数
+	defaultHopCount = 255
+)
+
+// Datalink 路由器端口的数据链路
+type Datalink interface {
+	// SendNPDU 向链路上的mac发送NPDU，mac为空时在链路上广播
+	SendNPDU(npdu []byte, mac []byte) error
+	// LocalMAC 返回本节点在链路上的MAC地址
+	LocalMAC() []byte
+}
+
+// RouterPort 路由器端口：连接一个BACnet网络的数据链路
+type RouterPort struct {
+	Network uint16
+	link    Datalink
+	server  *BACnetServer
+}
+
+// validNetworkNumber 检查端口的网络号，0和0xFFFF不能分配给网络
+func validNetworkNumber(network uint16) error {
+	if network == 0 || network == globalBroadcastNetwork {
+		return fmt.Errorf("无效的网络号: %d", network)
+	}
+	return nil
+}
+
+// EnableRouting 以network作为BACnet/IP网络的网络号启用路由，
+// 之后用AddRouterPort添加其他网络，需在Start之前调用
+func (s *BACnetServer) EnableRouting(network uint16) error {
+	if err := validNetworkNumber(network); err != nil {
+		return err
+	}
+	if s.ipPort != nil {
+		return errors.New("路由已启用")
+	}
+	s.ipPort = &RouterPort{Network: network, link: bipDatalink{server: s}, server: s}
+	s.routerPorts = []*RouterPort{s.ipPort}
+	return nil
+}
+
+// AddRouterPort 添加连接到network的数据链路，从链路收到的NPDU通过返回端口的HandleNPDU提交
+func (s *BACnetServer) AddRouterPort(network uint16, link Datalink) (*RouterPort, error) {
+	if s.ipPort == nil {
+		return nil, errors.New("路由未启用")
+	}
+	if err := validNetworkNumber(network); err != nil {
+		return nil, err
+	}
+	if s.routerPort(network) != nil {
+		return nil, fmt.Errorf("网络%d已连接到其他端口", network)
+	}
+	port := &RouterPort{Network: network, link: link, server: s}
+	s.routerPorts = append(s.routerPorts, port)
+	if s.IsRunning() {
+		s.announceRoutes()
+	}
+	return port, nil
+}
+
+// RouterPorts 返回路由器端口，第一个为BACnet/IP端口，未启用路由时返回nil
+func (s *BACnetServer) RouterPorts() []*RouterPort {
+	return append([]*RouterPort(nil), s.routerPorts...)
+}
+
+// routerPort 返回直接连接到network的端口
+func (s *BACnetServer) routerPort(network uint16) *RouterPort {
+	for _, port := range s.routerPorts {
+		if port.Network == network {
+			return port
+		}
+	}
+	return nil
+}
+
+// HandleNPDU 处理从端口上mac收到的NPDU：发往其他网络的被转发，
+// 发给本设备的交给服务端处理，返回应在该链路上发回mac的NPDU
+func (p *RouterPort) HandleNPDU(npdu []byte, mac []byte) ([]byte, error) {
+	return p.server.handleNPDU(p, npdu, mac, fmt.Sprintf("%d:%x", p.Network, mac))
+}
+
+// isLocalMAC 判断mac是否为本节点在端口链路上的地址
+func (p *RouterPort) isLocalMAC(mac []byte) bool {
+	if p == p.server.ipPort {
+		addr, err := readBIPAddress(encoding.NewReader(mac))
+		return err == nil && len(mac) == bipAddressLength && p.server.isLocalBIPAddress(addr)
+	}
+	return bytes.Equal(mac, p.link.LocalMAC())
+}
+
+// send 在端口上发送NPDU，mac为空时在链路上广播
+func (p *RouterPort) send(npdu NPDU, payload []byte, mac []byte) {
+	if err := p.link.SendNPDU(append(npdu.Encode(), payload...), mac); err != nil {
+		p.server.Logger().Warn("发送NPDU失败", "network", p.Network, "error", err)
+	}
+}
+
+// routeNPDU 路由从端口in上mac收到的NPDU，payload为NPDU头部之后的内容。
+// 返回消息是否还应由本设备处理（没有目标网络、全局广播或发给本节点的消息）
+func (s *BACnetServer) routeNPDU(in *RouterPort, npdu NPDU, payload []byte, mac []byte) bool {
+	if in.isLocalMAC(mac) {
+		return false // 本节点自己发出的广播
+	}
+	if !npdu.Control.DestinationSpecified {
+		return true
+	}
+	dnet := *npdu.DestinationNetwork
+	if dnet == in.Network {
+		return len(npdu.DestinationMAC) == 0 || in.isLocalMAC(npdu.DestinationMAC)
+	}
+	if *npdu.HopCount == 0 {
+		s.Logger().Warn("丢弃跳数耗尽的NPDU", "network", dnet)
+		return false
+	}
+
+	// 转发的消息带上源网络和源地址，应答才能原路返回
+	forwarded := NPDU{
+		Version: 0x01,
+		Control: ControlInfo{
+			NetworkMessageFlag: npdu.Control.NetworkMessageFlag,
+			ExpectingReply:     npdu.Control.ExpectingReply,
+			Priority:           npdu.Control.Priority,
+		},
+		SourceNetwork: &in.Network,
+		SourceMAC:     mac,
+	}
+	if npdu.SourceNetwork != nil {
+		forwarded.SourceNetwork, forwarded.SourceMAC = npdu.SourceNetwork, npdu.SourceMAC
+	}
+
+	if dnet == globalBroadcastNetwork {
+		hop := *npdu.HopCount - 1
+		if hop > 0 {
+			forwarded.DestinationNetwork, forwarded.HopCount = npdu.DestinationNetwork, &hop
+			for _, port := range s.routerPorts {
+				if port != in {
+					port.send(forwarded, payload, nil)
+				}
+			}
+		}
+		return true
+	}
+
+	out := s.routerPort(dnet)
+	if out == nil {
+		// 经其他路由器到达的网络保留目标网络，交给下一跳路由器
+		next, ok := s.learnedRoute(dnet)
+		if !ok || next.port == in {
+			s.rejectMessageToNetwork(in, npdu, mac, RejectNetworkUnknown, dnet)
+			return false
+		}
+		hop := *npdu.HopCount - 1
+		if hop == 0 {
+			s.Logger().Warn("丢弃跳数耗尽的NPDU", "network", dnet)
+			return false
+		}
+		forwarded.DestinationNetwork, forwarded.DestinationMAC, forwarded.HopCount = npdu.DestinationNetwork, npdu.DestinationMAC, &hop
+		next.port.send(forwarded, payload, next.router)
+		return false
+	}
+	if len(npdu.DestinationMAC) > 0 && out.isLocalMAC(npdu.DestinationMAC) {
+		return true
+	}
+	// 目标网络直接连接，去掉目标网络后在该链路上投递
+	out.send(forwarded, payload, npdu.DestinationMAC)
+	return false
+}
+
+// rejectMessageToNetwork 向消息的发送方回复Reject-Message-To-Network
+func (s *BACnetServer) rejectMessageToNetwork(in *RouterPort, npdu NPDU, mac []byte, reason byte, network uint16) {
+	s.Logger().Warn("拒绝发往网络的消息", "network", network, "reason", reason)
+	in.replyNetworkMessage(npdu, []byte{NetworkMessageRejectMessageToNetwork, reason, byte(network >> 8), byte(network)}, mac)
+}
+
+// bipDatalink 服务端的BACnet/IP传输作为路由器端口的数据链路
+type bipDatalink struct {
+	server *BACnetServer
+}
+
+// SendNPDU 单播到mac表示的B/IP地址，mac为空时广播
+func (l bipDatalink) SendNPDU(npdu []byte, mac []byte) error {
+	if len(mac) == 0 {
+		_, err := l.server.broadcastNPDU(npdu)
+		return err
+	}
+	addr, err := readBIPAddress(encoding.NewReader(mac))
+	if err != nil || len(mac) != bipAddressLength {
+		return fmt.Errorf("无效的B/IP地址: % X", mac)
+	}
+	if l.server.transport == nil {
+		return errTransportNotInitialized
+	}
+	_, err = l.server.writeTo(encodeBVLC(BVLCOriginalUnicastNPDU, npdu), addr)
+	return err
+}
+
+// LocalMAC 返回监听的B/IP地址
+func (l bipDatalink) LocalMAC() []byte {
+	if l.server.transport == nil {
+		return nil
+	}
+	if addr := udpAddr(l.server.transport.LocalAddr()); addr != nil {
+		return encodeBIPAddress(addr)
+	}
+	return nil
+}
+
+// bipMAC 返回B/IP地址的6字节MAC表示
+func bipMAC(addr net.Addr) []byte {
+	if a := udpAddr(addr); a != nil {
+		return encodeBIPAddress(a)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package protocol
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/iotzf/bacnet-server/encoding"
+)
+
+// RoutingTableEntry 路由表条目
+type RoutingTableEntry struct {
+	Network uint16
+	PortID  byte   // 端口号，从1开始，对应RouterPorts的顺序
+	Direct  bool   // 网络直接连接到该端口
+	Router  []byte // 下一跳路由器在端口链路上的MAC，为空时在端口链路上广播
+}
+
+// RoutingTable 返回路由表：直接连接的网络和学到的经其他路由器到达的网络，按网络号排序
+func (s *BACnetServer) RoutingTable() []RoutingTableEntry {
+	var table []RoutingTableEntry
+	for _, port := range s.routerPorts {
+		table = append(table, RoutingTableEntry{Network: port.Network, PortID: s.portID(port), Direct: true})
+	}
+	s.routerMu.Lock()
+	for network, r := range s.routes {
+		table = append(table, RoutingTableEntry{Network: network, PortID: s.portID(r.port), Router: append([]byte(nil), r.router...)})
+	}
+	s.routerMu.Unlock()
+	sort.Slice(table, func(i, j int) bool { return table[i].Network < table[j].Network })
+	return table
+}
+
+// portID 返回端口在路由表中的端口号
+func (s *BACnetServer) portID(port *RouterPort) byte {
+	for i, p := range s.routerPorts {
+		if p == port {
+			return byte(i + 1)
+		}
+	}
+	return 0
+}
+
+// handleInitializeRoutingTable 处理Initialize-Routing-Table：端口数为0时以完整路由表应答，
+// 否则按条目更新路由表，端口号为0的条目删除到该网络的路由
+func (s *BACnetServer) handleInitializeRoutingTable(port *RouterPort, npdu NPDU, r *encoding.Reader, mac []byte) error {
+	count, err := r.Byte()
+	if err != nil {
+		return fmt.Errorf("Initialize-Routing-Table: %w", err)
+	}
+	if count == 0 {
+		port.replyNetworkMessage(npdu, encodeRoutingTableAck(s.RoutingTable()), mac)
+		return nil
+	}
+
+	for i := 0; i < int(count); i++ {
+		network, err := r.Uint16()
+		if err != nil {
+			return fmt.Errorf("Initialize-Routing-Table: %w", err)
+		}
+		id, err := r.Byte()
+		if err != nil {
+			return fmt.Errorf("Initialize-Routing-Table: %w", err)
+		}
+		infoLength, err := r.Byte()
+		if err != nil {
+			return fmt.Errorf("Initialize-Routing-Table: %w", err)
+		}
+		if _, err := r.Bytes(int(infoLength)); err != nil {
+			return fmt.Errorf("Initialize-Routing-Table: %w", err)
+		}
+
+		switch {
+		case s.routerPort(network) != nil:
+			s.Logger().Warn("忽略直接连接网络的路由表条目", "network", network)
+		case id == 0:
+			s.forgetRoute(network)
+		case int(id) > len(s.routerPorts):
+			s.Logger().Warn("忽略端口号不存在的路由表条目", "network", network, "port", id)
+		default:
+			// 消息中没有下一跳路由器的地址，发往该网络的消息在端口链路上广播
+			s.learnRoute(network, s.routerPorts[id-1], nil)
+		}
+	}
+	port.replyNetworkMessage(npdu, []byte{NetworkMessageInitializeRoutingTableAck}, mac)
+	return nil
+}
+
+// encodeRoutingTableAck 编码带路由表的Initialize-Routing-Table-Ack，每个条目为DNET、端口号和空的端口信息
+func encodeRoutingTableAck(table []RoutingTableEntry) []byte {
+	if len(table) > 255 {
+		table = table[:255]
+	}
+	message := []byte{NetworkMessageInitializeRoutingTableAck, byte(len(table))}
+	for _, entry := range table {
+		message = append(message, byte(entry.Network>>8), byte(entry.Network), entry.PortID, 0)
+	}
+	return message
+}
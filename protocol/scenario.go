@@ -0,0 +1,141 @@
+package protocol
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SetOffline 模拟通信中断：为true时丢弃收到和要发送的全部B/IP数据报，设备对网络不可见，
+// 对象和模拟照常运行，恢复后积压的COV通知不会补发
+func (s *BACnetServer) SetOffline(offline bool) {
+	if s.offline.Swap(offline) != offline {
+		s.Logger().Info("通信状态已改变", "offline", offline)
+	}
+}
+
+// Offline 返回是否在模拟通信中断
+func (s *BACnetServer) Offline() bool {
+	return s.offline.Load()
+}
+
+// Scenario 按时间执行的测试序列，用于向监控软件复现确定的故障过程
+type Scenario struct {
+	Name  string
+	Steps []ScenarioStep // 按时间排序
+}
+
+// ScenarioStep 场景的一步：到达At时执行一条控制台命令，如drive analog-input:1 35、
+// alarm analog-input:1 fault、comms down或expire analog-input:1
+type ScenarioStep struct {
+	At      time.Duration // 相对场景开始的时间
+	Command string
+}
+
+// ScenarioProgress 一步执行后的进度
+type ScenarioProgress struct {
+	Step    int // 从1开始
+	Total   int
+	At      time.Duration
+	Command string
+	Output  string // 命令的输出，去掉末尾的换行
+	Err     error
+}
+
+// scenarioTick 场景检查到期步骤的周期
+const scenarioTick = 100 * time.Millisecond
+
+// Validate 检查步骤的时间是否有序、命令是否存在
+func (sc *Scenario) Validate() error {
+	if len(sc.Steps) == 0 {
+		return errors.New("场景没有步骤")
+	}
+	for i, step := range sc.Steps {
+		if _, _, err := scenarioCommand(step.Command); err != nil {
+			return fmt.Errorf("第%d步: %w", i+1, err)
+		}
+		if step.At < 0 || i > 0 && step.At < sc.Steps[i-1].At {
+			return fmt.Errorf("第%d步: 时间%s早于上一步", i+1, step.At)
+		}
+	}
+	return nil
+}
+
+// scenarioCommand 切分并查找一步的控制台命令，不允许会话相关的命令
+func scenarioCommand(line string) (consoleCommand, []string, error) {
+	args := splitConsoleLine(line)
+	if len(args) == 0 {
+		return consoleCommand{}, nil, errors.New("命令为空")
+	}
+	command, ok := consoleCommands[args[0]]
+	switch args[0] {
+	case "help", "quit", "exit":
+		ok = false
+	}
+	if !ok {
+		return consoleCommand{}, nil, fmt.Errorf("不支持的命令: %s", args[0])
+	}
+	return command, args[1:], nil
+}
+
+// RunScenario 按服务端时钟执行场景，每步执行后调用progress（可为nil）。
+// 单步失败不中止场景，结束后返回失败的步数；ctx取消时返回ctx.Err()
+func (s *BACnetServer) RunScenario(ctx context.Context, sc *Scenario, progress func(ScenarioProgress)) error {
+	if err := sc.Validate(); err != nil {
+		return err
+	}
+	clock := s.Clock()
+	ticker := clock.NewTicker(scenarioTick)
+	defer ticker.Stop()
+	start := clock.Now()
+	s.Logger().Info("场景开始", "scenario", sc.Name, "steps", len(sc.Steps))
+
+	failed, next := 0, 0
+	now := start
+	for {
+		for ; next < len(sc.Steps) && now.Sub(start) >= sc.Steps[next].At; next++ {
+			p := s.runScenarioStep(sc, next)
+			if p.Err != nil {
+				failed++
+			}
+			if progress != nil {
+				progress(p)
+			}
+		}
+		if next == len(sc.Steps) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			s.Logger().Warn("场景已取消", "scenario", sc.Name, "completed", next, "steps", len(sc.Steps))
+			return ctx.Err()
+		case now = <-ticker.C():
+		}
+	}
+	s.Logger().Info("场景结束", "scenario", sc.Name, "steps", len(sc.Steps), "failed", failed)
+	if failed > 0 {
+		return fmt.Errorf("场景%s有%d步失败", sc.Name, failed)
+	}
+	return nil
+}
+
+// runScenarioStep 执行场景的第i步
+func (s *BACnetServer) runScenarioStep(sc *Scenario, i int) ScenarioProgress {
+	step := sc.Steps[i]
+	p := ScenarioProgress{Step: i + 1, Total: len(sc.Steps), At: step.At, Command: step.Command}
+	command, args, err := scenarioCommand(step.Command)
+	var out bytes.Buffer
+	if err == nil {
+		err = s.runConsoleCommand(command, &out, args)
+	}
+	p.Output, p.Err = strings.TrimRight(out.String(), "\n"), err
+	if err != nil {
+		s.Logger().Warn("场景步骤失败", "scenario", sc.Name, "step", p.Step, "command", step.Command, "error", err)
+	} else {
+		s.Logger().Info("场景步骤", "scenario", sc.Name, "step", p.Step, "command", step.Command)
+	}
+	return p
+}
@@ -0,0 +1,141 @@
+package protocol
+
+import (
+	"sync"
+	"time"
+)
+
+// segmentedTransmit I-Am和设备对象中的Segmentation_Supported：只支持分段发送应答
+const segmentedTransmit = 1
+
+// maxAPDULengths 确认请求中最大APDU长度的编码（ASHRAE 135 第20.1.2.5节）
+var maxAPDULengths = [...]int{50, 128, 206, 480, 1024, 1476}
+
+// MaxAPDULengthAccepted 返回确认请求的发送方接受的最大APDU长度，保留的编码按50处理
+func (a *APDU) MaxAPDULengthAccepted() int {
+	if a.MaxResponse == nil {
+		return maxBIPAPDULength
+	}
+	if n := int(*a.MaxResponse & 0x0F); n < len(maxAPDULengths) {
+		return maxAPDULengths[n]
+	}
+	return maxAPDULengths[0]
+}
+
+// MaxSegmentsAccepted 返回确认请求的发送方接受的最大分段数，0表示未指定，超过64时为-1（不限制）
+func (a *APDU) MaxSegmentsAccepted() int {
+	if a.MaxResponse == nil {
+		return 0
+	}
+	switch n := *a.MaxResponse >> 4 & 0x07; n {
+	case 0:
+		return 0
+	case 7:
+		return -1
+	default:
+		return 1 << n
+	}
+}
+
+// SegmentedResponseAccepted 返回确认请求的发送方是否接受分段应答（SA标志）
+func (a *APDU) SegmentedResponseAccepted() bool {
+	return a.PDUType == BACnetAPDUTypeConfirmedServiceRequest && a.ControlFlags&0x02 != 0
+}
+
+// segmentedResponse 一个分段发送中的ComplexAck
+type segmentedResponse struct {
+	segments [][]byte // 编码完成的各个分段
+	sent     int      // 最后发送的分段
+	expires  time.Time
+}
+
+// segmenter 管理等待SegmentAck的分段应答。窗口大小固定为1：每收到一个SegmentAck发送下一个分段，
+// 分段或SegmentAck丢失时客户端重复的SegmentAck使服务端重发
+type segmenter struct {
+	mu      sync.Mutex
+	pending map[transactionKey]*segmentedResponse
+}
+
+// start 登记分段应答并返回第一个分段，同一客户端重复使用的InvokeID替换之前的应答
+func (m *segmenter) start(addr string, invokeID byte, segments [][]byte, now time.Time, timeout time.Duration) []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pending == nil {
+		m.pending = make(map[transactionKey]*segmentedResponse)
+	}
+	for key, pending := range m.pending {
+		if now.After(pending.expires) {
+			delete(m.pending, key)
+		}
+	}
+	m.pending[transactionKey{addr: addr, invokeID: invokeID}] = &segmentedResponse{segments: segments, expires: now.Add(timeout)}
+	return segments[0]
+}
+
+// ack 处理SegmentAck，返回需要发送的分段；最后一个分段被确认或没有对应的应答时返回nil
+func (m *segmenter) ack(addr string, invokeID byte, sequence byte, now time.Time, timeout time.Duration) []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := transactionKey{addr: addr, invokeID: invokeID}
+	pending, ok := m.pending[key]
+	if !ok || now.After(pending.expires) {
+		delete(m.pending, key)
+		return nil
+	}
+	switch sequence {
+	case byte(pending.sent):
+		pending.sent++
+	case byte(pending.sent - 1):
+		// 重复的SegmentAck：上一个分段没有送达，重发
+	default:
+		return nil
+	}
+	if pending.sent == len(pending.segments) {
+		delete(m.pending, key)
+		return nil
+	}
+	pending.expires = now.Add(timeout)
+	return pending.segments[pending.sent]
+}
+
+// abort 客户端放弃时丢弃分段应答
+func (m *segmenter) abort(addr string, invokeID byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.pending, transactionKey{addr: addr, invokeID: invokeID})
+}
+
+// fitResponse 使应答符合确认请求的最大APDU长度：超长的ComplexAck在客户端接受分段时分段发送，
+// 否则以缓冲区溢出放弃
+func (s *BACnetServer) fitResponse(ctx *RequestContext, request *APDU, response []byte) []byte {
+	limit := request.MaxAPDULengthAccepted()
+	if len(response) <= limit {
+		return response
+	}
+	invokeID := *request.InvokeID
+	if response[0]>>4 != BACnetAPDUTypeComplexAck || !request.SegmentedResponseAccepted() {
+		s.Logger().Debug("应答超过客户端接受的最大APDU长度", "peer", ctx.ClientAddr, "invoke_id", invokeID, "bytes", len(response), "max_apdu", limit)
+		return s.createAbortResponse(invokeID, AbortReasonBufferOverflow)
+	}
+
+	// 分段的ComplexAck头部：PDU类型和SEG/MOR标志、invokeID、序号、建议窗口大小、服务选择
+	const headerLength = 5
+	service, data := response[2], response[3:]
+	size := limit - headerLength
+	count := (len(data) + size - 1) / size
+	if maxSegments := request.MaxSegmentsAccepted(); maxSegments > 0 && count > maxSegments {
+		s.Logger().Debug("应答超过客户端接受的最大分段数", "peer", ctx.ClientAddr, "invoke_id", invokeID, "segments", count, "max_segments", maxSegments)
+		return s.createAbortResponse(invokeID, AbortReasonBufferOverflow)
+	}
+	segments := make([][]byte, count)
+	for i := range segments {
+		chunk := data[i*size : min((i+1)*size, len(data))]
+		control := byte(BACnetAPDUTypeComplexAck<<4 | 0x08)
+		if i < count-1 {
+			control |= 0x04
+		}
+		segments[i] = append([]byte{control, invokeID, byte(i), 1, service}, chunk...)
+	}
+	s.logPacket("分段发送应答", "peer", ctx.ClientAddr, "invoke_id", invokeID, "bytes", len(response), "segments", count)
+	return s.segments.start(ctx.ClientAddr, invokeID, segments, s.now(), s.device.APDUTimeout())
+}
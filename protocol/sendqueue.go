@@ -0,0 +1,74 @@
+package protocol
+
+import (
+	"sync"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// recipientQueueSize 每个通知接收者最多排队等待发送的请求数
+const recipientQueueSize = 64
+
+// recipientQueue 一个通知接收者的发送队列，有请求时由一个发送goroutine按顺序发送，队列空后退出
+type recipientQueue struct {
+	sends   []func()
+	sending bool // 是否有发送goroutine
+}
+
+// recipientFanout 按通知接收者排队的后台发送。同一接收者的事件通知、审计通知和时间同步按产生的顺序发出，
+// 确认请求在应答或超时后才发送下一个；不同接收者互不阻塞
+type recipientFanout struct {
+	mu     sync.Mutex
+	queues map[string]*recipientQueue
+}
+
+// enqueueToRecipient 将send放入接收者的队列，需要时启动发送goroutine。服务端已开始关闭时不再发送，
+// 队列已满时丢弃并记录日志；what为日志中请求的名称
+func (s *BACnetServer) enqueueToRecipient(recipient model.Recipient, what string, send func()) {
+	if s.transport == nil {
+		return
+	}
+	key := recipientString(recipient)
+	f := &s.recipientQueues
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.queues == nil {
+		f.queues = make(map[string]*recipientQueue)
+	}
+	q, ok := f.queues[key]
+	if !ok {
+		q = &recipientQueue{}
+		f.queues[key] = q
+	}
+	if len(q.sends) >= recipientQueueSize {
+		s.Logger().Warn(what+"的发送队列已满，丢弃", "recipient", key)
+		return
+	}
+	if !q.sending {
+		if !s.track() {
+			return
+		}
+		q.sending = true
+		go s.sendRecipientQueue(key, q)
+	}
+	q.sends = append(q.sends, send)
+}
+
+// sendRecipientQueue 依次执行接收者队列中的发送，队列空时移除队列并退出；Shutdown等待已排队的请求发送完
+func (s *BACnetServer) sendRecipientQueue(key string, q *recipientQueue) {
+	defer s.inflight.Done()
+	f := &s.recipientQueues
+	for {
+		f.mu.Lock()
+		if len(q.sends) == 0 {
+			q.sending = false
+			delete(f.queues, key)
+			f.mu.Unlock()
+			return
+		}
+		send := q.sends[0]
+		q.sends = q.sends[1:]
+		f.mu.Unlock()
+		send()
+	}
+}
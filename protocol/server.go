@@ -0,0 +1,2335 @@
+package protocol
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"net"
+	"reflect"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/iotzf/bacnet-server/encoding"
+	"github.com/iotzf/bacnet-server/model"
+	"github.com/iotzf/bacnet-server/simulation"
+)
+
+// BACnetServer 实现BACnet服务端
+type BACnetServer struct {
+	device            *model.Device
+	transport         Transport
+	log               *slog.Logger                 // 为nil时使用slog.Default()
+	clock             model.Clock                  // 为nil时使用设备的时钟
+	workers           int                          // 处理数据报的goroutine数，不大于1时在读取的goroutine中处理
+	onError           func(peer string, err error) // 处理数据报失败时的回调，可为nil
+	broadcast         *net.UDPAddr                 // 配置的本地广播地址，为nil时使用传输的广播地址
+	running           int32                        // 是否在运行，原子访问
+	stateFile         string                       // 优先级数组状态文件，为空时不持久化
+	snapshotFile      string                       // 设备快照文件，为空时不保存
+	snapshotInterval  time.Duration                // 保存快照的周期
+	lastSnapshot      time.Time                    // 上次保存快照的时间，只在对象调度中访问
+	lastTimeSync      time.Time                    // 上次发送定期时间同步的时间，只在对象调度中访问
+	transactions      transactionManager           // 本设备发起的确认请求
+	segments          segmenter                    // 等待SegmentAck的分段应答
+	covQueues         covFanout                    // 按订阅者排队发送的COV通知
+	recipientQueues   recipientFanout              // 按接收者排队发送的事件通知、审计通知和时间同步
+	bindings          bindingTable                 // 从I-Am学到的设备地址
+	quarantineDir     string                       // 引发panic的数据报的隔离目录，为空时不保存
+	malformedPackets  uint64                       // 引发panic的数据报数量，原子访问
+	bbmd              bool                         // 是否作为BBMD运行
+	bdt               []BDTEntry                   // 广播分发表
+	fdt               map[string]ForeignDevice     // 外部设备表，键为B/IP地址
+	bdtMu             sync.Mutex                   // 保护bdt和fdt
+	bbmdAddr          *net.UDPAddr                 // 外部设备模式下注册的远程BBMD
+	foreignTTL        uint16                       // 外部设备注册的TTL（秒）
+	foreignRegistered int32                        // 是否已被BBMD接受注册，原子访问
+	ipPort            *RouterPort                  // 路由器的BACnet/IP端口，为nil时不路由
+	routerPorts       []*RouterPort                // 路由器端口，第一个为ipPort
+	routes            map[uint16]route             // 经其他路由器到达的网络
+	routerMu          sync.Mutex                   // 保护routes
+	virtualNetworks   []*VirtualNetwork            // 路由器上的虚拟网络
+	lifecycle         sync.Mutex                   // 保护stopping、inflight的登记和以下通道的创建
+	stopping          bool                         // 已开始关闭，不再接收新的数据报
+	inflight          sync.WaitGroup               // 处理中的请求、对象调度和后台的确认COV通知
+	stop              chan struct{}                // 开始关闭时关闭，通知后台任务退出
+	done              chan struct{}                // 关闭完成时关闭
+	readerDone        chan struct{}                // handleRequests退出时关闭
+	hooks             hooks                        // 拦截属性访问的钩子
+	acl               []aclRule                    // 访问控制列表，按顺序匹配
+	readOnly          bool                         // 只读模式，拒绝修改设备状态的服务
+	protections       protections                  // 属性和对象的写保护
+	limiter           rateLimiter                  // 按来源和全局的请求限速
+	metrics           metrics                      // 运行指标
+	tracer            atomic.Pointer[tracer]       // 数据报跟踪输出，为nil时不跟踪
+	capture           atomic.Pointer[PcapWriter]   // 抓包输出，为nil时不抓包
+	captureFile       *PcapWriter                  // 按配置创建的抓包文件，关闭服务端时关闭
+	simulation        *simulation.Engine           // 控制台sim命令操纵的模拟引擎，为nil时不可用
+	offline           atomic.Bool                  // 模拟通信中断，丢弃收发的全部B/IP数据报
+	dashboardAPIs     sync.Map                     // 其他组件注册的仪表盘接口，键为/api/下的名称
+	privateTransfers  sync.Map                     // 厂商私有服务的处理函数，键为privateTransferKey
+	auditLogger       *model.Recipient             // 转发审计通知的审计记录设备，为nil时不转发
+	auditConfirmed    bool                         // 以ConfirmedAuditNotification转发
+	shutdownOnce      sync.Once
+	shutdownErr       error
+}
+
+// shutdownTimeout Stop等待处理中的请求完成的时间
+const shutdownTimeout = 5 * time.Second
+
+// errServerStopped 服务端已停止，不再发送新的确认请求
+var errServerStopped = errors.New("服务端已停止")
+
+// RequestContext 一个请求的来源，沿处理链传递，使并发的请求互不干扰
+type RequestContext struct {
+	ClientAddr string         // 客户端地址，用于COV订阅和匹配本设备发起的事务
+	ReplyAddr  net.Addr       // 应答的B/IP地址，Forwarded-NPDU时为原始发送方，其他数据链路上为nil
+	Source     *model.Address // 请求经路由器转发时NPDU中的源网络和源地址，本地网络上的请求为nil
+}
+
+// peer 返回请求的客户端地址，本地调用（ctx为nil）时为空
+func (ctx *RequestContext) peer() string {
+	if ctx == nil {
+		return ""
+	}
+	return ctx.ClientAddr
+}
+
+// newBACnetServer 创建使用给定传输的BACnet服务端，stateFile不为空时从中恢复可命令对象的优先级数组
+func newBACnetServer(device *model.Device, transport Transport, stateFile string) (*BACnetServer, error) {
+	server := &BACnetServer{
+		device:    device,
+		transport: transport,
+		stateFile: stateFile,
+	}
+
+	// 恢复重启前的命令状态
+	if stateFile != "" {
+		if err := model.LoadCommandState(device, stateFile); err != nil {
+			return nil, fmt.Errorf("加载状态文件失败: %v", err)
+		}
+	}
+
+	device.Properties[model.PropertyIdentifierProtocolServicesSupported] = servicesSupported()
+	device.SetPropertyProvider(model.PropertyIdentifierDeviceAddressBinding, model.ProviderFuncs{
+		Read: func(model.Object, model.PropertyIdentifier) (interface{}, error) {
+			return server.AddressBindings(), nil
+		},
+	})
+
+	// 设置对象的通知发送器，使COV和事件通知能真正发送出去
+	server.attachNotifier(device)
+	for _, obj := range device.Objects() {
+		server.attachNotifier(obj)
+	}
+
+	return server, nil
+}
+
+// servicesSupported 返回设备Protocol_Services_Supported位串，置位本服务端实现的服务
+func servicesSupported() model.BitString {
+	bits := model.NewBitString(servicesSupportedCount)
+	for _, service := range []int{
+		servicesSupportedAcknowledgeAlarm, servicesSupportedSubscribeCOV,
+		servicesSupportedAtomicReadFile, servicesSupportedAtomicWriteFile,
+		servicesSupportedReadProperty, servicesSupportedReadPropertyMultiple,
+		servicesSupportedWriteProperty, servicesSupportedWritePropertyMultiple,
+		servicesSupportedIAm, servicesSupportedIHave,
+		servicesSupportedUnconfirmedCOV, servicesSupportedUnconfirmedEvent,
+		servicesSupportedWhoHas, servicesSupportedWhoIs,
+		servicesSupportedReadRange, servicesSupportedLifeSafetyOperation,
+		servicesSupportedSubscribeCOVProperty, servicesSupportedPrivateTransfer,
+		servicesSupportedUnconfirmedTransfer,
+	} {
+		bits.Set(service, true)
+	}
+	return bits
+}
+
+// Lock 锁定服务端的设备，见model.Device.Lock。服务端处理请求和运行对象调度器时持有设备锁，
+// 在其他goroutine中访问设备或其对象、调用AddObject等方法时先调用Lock。没有设备时不加锁
+func (s *BACnetServer) Lock() {
+	if s.device != nil {
+		s.device.Lock()
+	}
+}
+
+// Unlock 解锁服务端的设备
+func (s *BACnetServer) Unlock() {
+	if s.device != nil {
+		s.device.Unlock()
+	}
+}
+
+// attachNotifier 为支持通知的对象设置服务端作为通知发送器
+func (s *BACnetServer) attachNotifier(obj model.Object) {
+	if n, ok := obj.(interface {
+		SetNotifier(model.NotificationSender)
+	}); ok {
+		n.SetNotifier(s)
+	}
+}
+
+// Start 启动BACnet服务端，ctx取消时按Stop关闭
+func (s *BACnetServer) Start(ctx context.Context) {
+	s.lifecycle.Lock()
+	s.initLifecycle()
+	if s.transport != nil {
+		s.readerDone = make(chan struct{})
+	}
+	s.lifecycle.Unlock()
+	atomic.StoreInt32(&s.running, 1)
+
+	s.device.WriteProperty(model.PropertyIdentifierTimeOfDeviceRestart, s.now())
+	if s.transport != nil {
+		s.Logger().Info("BACnet服务端已启动", "addr", s.transport.LocalAddr().String(),
+			"device", s.device.GetObjectIdentifier().Instance, "name", s.device.GetObjectName())
+	} else {
+		s.Logger().Info("BACnet服务端已启动", "device", s.device.GetObjectIdentifier().Instance, "name", s.device.GetObjectName())
+	}
+
+	if s.transport != nil {
+		go s.handleRequests()
+	}
+	// 定时器在返回前创建，测试中Start之后的Advance一定会触发它
+	s.inflight.Add(1)
+	go s.runObjectScheduler(s.Clock().NewTicker(time.Second))
+	if s.bbmdAddr != nil {
+		go s.runForeignDeviceRegistration()
+	}
+	if s.ipPort != nil {
+		s.announceRoutes()
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.Stop()
+		case <-s.stop:
+		}
+	}()
+}
+
+// initLifecycle 创建生命周期通道，调用时持有lifecycle
+func (s *BACnetServer) initLifecycle() {
+	if s.stop == nil {
+		s.stop = make(chan struct{})
+		s.done = make(chan struct{})
+	}
+}
+
+// IsRunning 判断服务端是否在运行
+func (s *BACnetServer) IsRunning() bool {
+	return atomic.LoadInt32(&s.running) == 1
+}
+
+// Done 返回服务端关闭完成时关闭的通道
+func (s *BACnetServer) Done() <-chan struct{} {
+	s.lifecycle.Lock()
+	defer s.lifecycle.Unlock()
+	s.initLifecycle()
+	return s.done
+}
+
+// track 登记一个处理中的任务，服务端已开始关闭时返回false
+func (s *BACnetServer) track() bool {
+	s.lifecycle.Lock()
+	defer s.lifecycle.Unlock()
+	if s.stopping {
+		return false
+	}
+	s.inflight.Add(1)
+	return true
+}
+
+// runObjectScheduler 每秒执行一次设备中需要周期处理的对象（如趋势日志），移除到期的COV订阅并定期保存快照
+func (s *BACnetServer) runObjectScheduler(ticker model.Ticker) {
+	defer s.inflight.Done()
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C():
+			s.device.Lock()
+			s.device.Execute(now)
+			for _, sub := range s.device.ExpireCOVSubscriptions(now) {
+				s.Logger().Debug("COV订阅已到期", "peer", sub.ClientAddress, "subscription", sub.SubscriptionID)
+			}
+			s.snapshotIfDue(now)
+			s.timeSyncIfDue(now)
+			s.device.Unlock()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Stop 停止BACnet服务端，最多等待shutdownTimeout让处理中的请求完成
+func (s *BACnetServer) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	s.Shutdown(ctx)
+}
+
+// Shutdown 优雅关闭服务端：不再接收新的数据报，等待处理中的请求和已排队的COV通知完成，
+// ctx到期时不再等待；随后关闭传输并保存命令状态。可以重复和并发调用，都在关闭完成后返回第一次关闭的结果
+func (s *BACnetServer) Shutdown(ctx context.Context) error {
+	s.shutdownOnce.Do(func() {
+		s.shutdownErr = s.shutdown(ctx)
+	})
+	return s.shutdownErr
+}
+
+// shutdown 执行一次关闭
+func (s *BACnetServer) shutdown(ctx context.Context) error {
+	s.lifecycle.Lock()
+	s.initLifecycle()
+	s.stopping = true
+	close(s.stop)
+	s.lifecycle.Unlock()
+	atomic.StoreInt32(&s.running, 0)
+
+	drained := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(drained)
+	}()
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+		s.Logger().Warn("等待处理中的请求超时", "error", err)
+	}
+
+	for _, v := range s.virtualNetworks {
+		v.stop()
+	}
+	if s.transport != nil {
+		s.transport.Close()
+		if s.readerDone != nil && err == nil {
+			<-s.readerDone
+		}
+	}
+	if s.captureFile != nil {
+		s.SetCapture(nil)
+		s.captureFile.Close()
+	}
+	s.Lock()
+	s.saveCommandState()
+	s.saveSnapshot()
+	s.Unlock()
+	close(s.done)
+	s.Logger().Info("BACnet服务端已停止")
+	return err
+}
+
+// 添加对象到BACnet服务器，对象名重复时返回错误
+func (s *BACnetServer) AddObject(obj model.Object) error {
+	if err := s.device.AddObject(obj); err != nil {
+		return err
+	}
+	s.attachNotifier(obj)
+	return nil
+}
+
+// saveCommandState 保存可命令对象的优先级数组和Relinquish_Default
+func (s *BACnetServer) saveCommandState() {
+	if s.stateFile == "" || s.device == nil {
+		return
+	}
+	if err := model.SaveCommandState(s.device, s.stateFile); err != nil {
+		s.Logger().Error("保存状态文件失败", "file", s.stateFile, "error", err)
+	}
+}
+
+// isCommandState 判断属性写入是否改变了需要持久化的命令状态
+func isCommandState(obj model.Object, prop model.PropertyIdentifier) bool {
+	c, ok := obj.(model.CommandableObject)
+	return ok && (c.Commandable(prop) || prop == model.PropertyIdentifierRelinquishDefault)
+}
+
+// SimulateDataChange 模拟设备数据变化并触发COV通知
+// 此方法仅用于演示目的，可以手动调用以测试COV通知功能
+func (s *BACnetServer) SimulateDataChange(objectID model.ObjectIdentifier, property model.PropertyIdentifier, newValue interface{}) {
+	s.Lock()
+	defer s.Unlock()
+	s.simulateDataChange(objectID, property, newValue)
+}
+
+// simulateDataChange 模拟设备数据变化，调用时持有设备锁
+func (s *BACnetServer) simulateDataChange(objectID model.ObjectIdentifier, property model.PropertyIdentifier, newValue interface{}) {
+	targetObject := s.device.FindObject(objectID)
+	if targetObject == nil {
+		s.Logger().Warn("未找到模拟对象", "object_type", objectID.Type, "instance", objectID.Instance)
+		return
+	}
+
+	// 获取当前值
+	oldValue, _ := targetObject.ReadProperty(property)
+
+	// 更新属性值（会自动触发NotifySubscribers），Present_Value按现场驱动更新处理，对象停用时被忽略
+	if updater, ok := targetObject.(model.PresentValueUpdater); ok && property == model.PropertyIdentifierPresentValue {
+		updater.UpdatePresentValue(newValue)
+	} else {
+		targetObject.WriteProperty(property, newValue)
+	}
+
+	s.Logger().Debug("模拟数据变化", "object_type", objectID.Type, "instance", objectID.Instance, "property", property, "old", oldValue, "new", newValue)
+}
+
+// SendCOVNotification 发送COV通知给订阅者。通知放入该客户端的队列后立即返回，由后台按顺序发送，
+// 队列已满时丢弃通知并返回错误
+func (s *BACnetServer) SendCOVNotification(subscription model.COVSubscription, values []model.COVValue) error {
+	return s.queueCOVNotification(subscription, values, false)
+}
+
+// SendConfirmedCOVNotification 以确认请求发送COV通知，放入该客户端的队列，在后台等待应答，超时按设备的重试次数重发
+func (s *BACnetServer) SendConfirmedCOVNotification(subscription model.COVSubscription, values []model.COVValue) error {
+	return s.queueCOVNotification(subscription, values, true)
+}
+
+// queueCOVNotification 编码COV通知并放入客户端的队列。通知在处理请求的过程中触发，
+// 不能阻塞写入属性的调用方和接收应答的循环；关闭服务端时等待正在发送的通知完成
+func (s *BACnetServer) queueCOVNotification(subscription model.COVSubscription, values []model.COVValue, confirmed bool) error {
+	if s.transport == nil {
+		return errTransportNotInitialized
+	}
+	addr, err := s.transport.ResolveAddr(subscription.ClientAddress)
+	if err != nil {
+		return fmt.Errorf("无效的客户端地址: %v", err)
+	}
+	parameters, err := s.encodeCOVNotificationParameters(subscription, values)
+	if err != nil {
+		return err
+	}
+	return s.enqueueCOV(covNotification{
+		clientAddr:     subscription.ClientAddress,
+		addr:           addr,
+		dest:           subscription.RemoteAddress,
+		subscriptionID: subscription.SubscriptionID,
+		parameters:     parameters,
+		confirmed:      confirmed,
+	})
+}
+
+// encodeCOVNotificationParameters 编码COV通知参数：订阅者进程ID、本设备和监控对象的标识符、
+// 订阅的剩余时间（秒，永久有效的订阅为0）和属性值列表
+func (s *BACnetServer) encodeCOVNotificationParameters(subscription model.COVSubscription, values []model.COVValue) ([]byte, error) {
+	notification := COVNotification{
+		SubscriberProcessID: subscription.SubscriberProcessID,
+		InitiatingDevice:    s.device.GetObjectIdentifier(),
+		MonitoredObject:     subscription.ObjectIdentifier,
+	}
+	if !subscription.Expires.IsZero() {
+		if remaining := subscription.Expires.Sub(s.now()); remaining > 0 {
+			notification.TimeRemaining = uint32(remaining / time.Second)
+		}
+	}
+	for _, v := range values {
+		notification.Values = append(notification.Values, PropertyValue{PropertyID: v.PropertyIdentifier, Value: encodeBACnetValue(wireValue(subscription.ObjectIdentifier.Type, v.PropertyIdentifier, v.Value))})
+	}
+	return encoding.Marshal(notification)
+}
+
+// handleRequests 处理接收到的BACnet请求，直到传输在关闭时被关闭
+func (s *BACnetServer) handleRequests() {
+	defer close(s.readerDone)
+	buffer := make([]byte, s.transport.MTU())
+
+	// 每个处理goroutine有自己的队列，同一来源的数据报总是进入同一队列，按收到的顺序处理
+	var queues []chan datagram
+	if s.workers > 1 {
+		queues = make([]chan datagram, s.workers)
+		for i := range queues {
+			queue := make(chan datagram, 1)
+			queues[i] = queue
+			defer close(queue)
+			go func() {
+				for d := range queue {
+					s.handleDatagram(d.data, d.from)
+					s.inflight.Done()
+				}
+			}()
+		}
+	}
+
+	for {
+		n, addr, err := s.transport.ReadFrom(buffer)
+		if err != nil {
+			if !s.IsRunning() {
+				return
+			}
+			s.Logger().Warn("读取数据报失败", "error", err)
+			continue
+		}
+		if n == 0 {
+			continue
+		}
+		atomic.AddUint64(&s.metrics.datagramsIn, 1)
+		s.trace("RX", addr, buffer[:n])
+		s.capturePacket(true, addr, buffer[:n])
+		if s.offline.Load() || !s.limiter.allow(addr, s.now()) {
+			continue
+		}
+		if s.track() {
+			if queues == nil {
+				s.handleDatagram(buffer[:n], addr)
+				s.inflight.Done()
+			} else {
+				// 交给处理goroutine的数据报复制一份，读取缓冲区随即被下一个数据报覆盖
+				h := fnv.New32a()
+				h.Write([]byte(addr.String()))
+				queues[h.Sum32()%uint32(len(queues))] <- datagram{data: append([]byte(nil), buffer[:n]...), from: addr}
+			}
+		} else if isReplyDatagram(buffer[:n]) {
+			// 开始关闭后只处理对本设备确认请求的应答，使等待中的通知能够完成
+			s.handleDatagram(buffer[:n], addr)
+		}
+	}
+}
+
+// isReplyDatagram 判断B/IP单播数据报是否是对确认请求的应答
+func isReplyDatagram(data []byte) bool {
+	if len(data) < bvlcHeaderLength || data[0] != BVLCTypeBACnetIP || data[1] != BVLCOriginalUnicastNPDU {
+		return false
+	}
+	npdu, offset, err := ParseNPDU(data[bvlcHeaderLength:])
+	if err != nil || npdu.Control.NetworkMessageFlag {
+		return false
+	}
+	apdu := data[bvlcHeaderLength+offset:]
+	return len(apdu) > 0 && isTransactionResponse(apdu[0]>>4)
+}
+
+// handleDatagram 处理一个数据报并发送应答
+func (s *BACnetServer) handleDatagram(data []byte, addr net.Addr) {
+	if s.packetLogging() {
+		s.logPacket("收到数据报", "peer", addr.String(), "bytes", len(data))
+	}
+
+	// 客户端地址随请求传递，用于COV订阅和发送应答
+	ctx := &RequestContext{ClientAddr: addr.String(), ReplyAddr: addr}
+
+	// 解析并处理BACnet消息
+	start := time.Now()
+	response, err := s.processDatagram(ctx, data)
+	s.metrics.observeRequest(time.Since(start))
+	if err != nil {
+		s.Logger().Debug("处理BACnet消息失败", "peer", ctx.ClientAddr, "error", err)
+		if s.onError != nil {
+			s.onError(ctx.ClientAddr, err)
+		}
+		return
+	}
+
+	// 如果有响应需要发送
+	if len(response) > 0 {
+		if _, err := s.writeTo(response, ctx.ReplyAddr); err != nil {
+			s.Logger().Warn("发送应答失败", "peer", ctx.ClientAddr, "error", err)
+		}
+	}
+}
+
+// processBACnetMessage 处理BACnet消息并返回响应
+func (s *BACnetServer) processBACnetMessage(ctx *RequestContext, data []byte) ([]byte, error) {
+	// BVLC头部：类型(1) + 功能(1) + 长度(2)
+	r := encoding.NewReader(data)
+	header, err := r.Bytes(4)
+	if err != nil {
+		return nil, fmt.Errorf("BACnet message too short: %w", err)
+	}
+	bvlc := header[0]
+	bvlcFunction := header[1]
+	bvlcLength := binary.BigEndian.Uint16(header[2:4])
+
+	// 检查BVLC类型 (应该是0x81表示BACnet/IP)
+	if bvlc != BVLCTypeBACnetIP {
+		return nil, fmt.Errorf("unknown BVLC type: %02x", bvlc)
+	}
+	if int(bvlcLength) != len(data) {
+		return nil, fmt.Errorf("BVLC length mismatch: expected %d, got %d", bvlcLength, len(data))
+	}
+
+	// 处理不同类型的BVLC函数
+	var response []byte
+	switch bvlcFunction {
+	case BVLCOriginalUnicastNPDU: // 原始单播NPDU
+		response, err = s.handleOriginalUDPMessage(ctx, r.Remaining())
+	case BVLCOriginalBroadcastNPDU: // 原始广播NPDU 用于向网络中的所有BACnet设备发送消息（如Who-Is请求）
+		s.sendFrames(s.broadcastForwards(r.Remaining(), udpAddr(ctx.ReplyAddr)))
+		response, err = s.handleBroadcastMessage(ctx, r.Remaining())
+	case BVLCForwardedNPDU: // BBMD转发的广播，应答直接发给原始发送方
+		response, err = s.handleForwardedNPDU(ctx, r)
+	case BVLCResult:
+		return nil, s.handleBVLCResult(r, udpAddr(ctx.ReplyAddr))
+	case BVLCWriteBroadcastDistributionTable:
+		return s.handleWriteBDT(r), nil
+	case BVLCReadBroadcastDistributionTable:
+		return s.handleReadBDT(), nil
+	case BVLCRegisterForeignDevice:
+		return s.handleRegisterForeignDevice(r, udpAddr(ctx.ReplyAddr)), nil
+	case BVLCReadForeignDeviceTable:
+		return s.handleReadFDT(), nil
+	case BVLCDeleteForeignDeviceTableEntry:
+		return s.handleDeleteFDTEntry(r), nil
+	case BVLCDistributeBroadcastToNetwork:
+		return s.handleDistributeBroadcast(ctx, r.Remaining())
+	default:
+		s.Logger().Debug("不支持的BVLC功能", "peer", ctx.ClientAddr, "function", bvlcFunction)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return frameResponse(response), nil
+}
+
+// handleForwardedNPDU 处理Forwarded-NPDU：BVLC头部后为原始发送方的B/IP地址，随后为NPDU
+func (s *BACnetServer) handleForwardedNPDU(ctx *RequestContext, r *encoding.Reader) ([]byte, error) {
+	origin, err := readBIPAddress(r)
+	if err != nil {
+		return nil, fmt.Errorf("Forwarded-NPDU: %w", err)
+	}
+	s.logPacket("收到Forwarded-NPDU", "peer", ctx.ClientAddr, "origin", origin)
+	s.sendFrames(s.peerForwards(r.Remaining(), origin, udpAddr(ctx.ReplyAddr)))
+	ctx.ClientAddr = origin.String()
+	ctx.ReplyAddr = origin
+	return s.handleBroadcastMessage(ctx, r.Remaining())
+}
+
+// HandleNPDU 处理来自其他数据链路（如MS/TP）的NPDU，返回应答NPDU，无应答时返回nil。
+// from用于标识COV订阅的客户端
+func (s *BACnetServer) HandleNPDU(npdu []byte, from string) ([]byte, error) {
+	return s.handleNPDU(nil, npdu, nil, from)
+}
+
+// handleNPDU 处理从路由器端口port上mac收到的NPDU，port为nil时不路由
+func (s *BACnetServer) handleNPDU(port *RouterPort, data []byte, mac []byte, from string) ([]byte, error) {
+	ctx := &RequestContext{ClientAddr: from}
+	npdu, offset, err := ParseNPDU(data)
+	if err != nil {
+		return nil, err
+	}
+	if port != nil && !s.routeNPDU(port, npdu, data[offset:], mac) {
+		return nil, nil
+	}
+	response, err := s.handleLocalNPDU(ctx, port, npdu, data[offset:], mac)
+	if err != nil {
+		return nil, err
+	}
+	if framed := frameResponse(response); len(framed) > bvlcHeaderLength {
+		return framed[bvlcHeaderLength:], nil
+	}
+	return nil, nil
+}
+
+// handleOriginalUDPMessage 处理原始UDP消息
+func (s *BACnetServer) handleOriginalUDPMessage(ctx *RequestContext, data []byte) ([]byte, error) {
+	npdu, offset, err := ParseNPDU(data)
+	if err != nil {
+		return nil, err
+	}
+	mac := bipMAC(ctx.ReplyAddr)
+	if s.ipPort != nil && !s.routeNPDU(s.ipPort, npdu, data[offset:], mac) {
+		return nil, nil
+	}
+	return s.handleLocalNPDU(ctx, s.ipPort, npdu, data[offset:], mac)
+}
+
+// handleBroadcastMessage 处理广播消息
+func (s *BACnetServer) handleBroadcastMessage(ctx *RequestContext, data []byte) ([]byte, error) {
+	npdu, offset, err := ParseNPDU(data)
+	if err != nil {
+		return nil, err
+	}
+	if s.packetLogging() {
+		s.logPacket("NPDU", "peer", ctx.ClientAddr, "control", npdu.Control.String())
+	}
+	mac := bipMAC(ctx.ReplyAddr)
+	if s.ipPort != nil && !s.routeNPDU(s.ipPort, npdu, data[offset:], mac) {
+		return nil, nil
+	}
+	return s.handleLocalNPDU(ctx, s.ipPort, npdu, data[offset:], mac)
+}
+
+// handleLocalNPDU 处理从路由器端口port上mac收到、发给本设备的NPDU，data为NPDU头部之后的内容
+func (s *BACnetServer) handleLocalNPDU(ctx *RequestContext, port *RouterPort, npdu NPDU, data []byte, mac []byte) ([]byte, error) {
+	if npdu.Control.NetworkMessageFlag {
+		return nil, s.handleNetworkMessage(port, npdu, data, mac)
+	}
+	s.learnAddress(ctx, npdu, data)
+	if npdu.SourceNetwork != nil {
+		ctx.Source = &model.Address{Network: *npdu.SourceNetwork, MAC: npdu.SourceMAC}
+	}
+	response, err := s.handleBACnetAPDU(ctx, data)
+	return frameReply(npdu, response), err
+}
+
+// handleBACnetAPDU 处理BACnet APDU消息
+func (s *BACnetServer) handleBACnetAPDU(ctx *RequestContext, data []byte) ([]byte, error) {
+	// 检查数据长度
+	if len(data) < 2 {
+		return nil, fmt.Errorf("APDU too short")
+	}
+
+	// 获取APDU类型
+	apdu, err := ParseAPDU(data)
+	if err != nil {
+		return nil, err
+	}
+	if s.packetLogging() {
+		s.logPacket("APDU", "peer", ctx.ClientAddr, "apdu", apdu.String())
+	}
+
+	// 本设备发起的确认请求的应答交给等待的事务
+	if isTransactionResponse(apdu.PDUType) {
+		s.transactions.complete(ctx.ClientAddr, apdu)
+	}
+
+	// 根据APDU类型处理请求
+	switch apdu.PDUType {
+	case BACnetAPDUTypeConfirmedServiceRequest:
+		// Confirmed service request 需要 invokeID 和 serviceChoice
+		if apdu.InvokeID == nil || apdu.ServiceChoice == nil {
+			return nil, fmt.Errorf("confirmed service request missing invokeID or serviceChoice")
+		}
+
+		invokeID := *apdu.InvokeID
+		s.countAPDU(apdu)
+		if s.packetLogging() {
+			s.logPacket("收到确认请求", "peer", ctx.ClientAddr, "service", apdu.ServiceName(), "invoke_id", invokeID)
+		}
+		if errorClass, errorCode, ok := s.checkAccess(ctx, *apdu.ServiceChoice); !ok {
+			s.Logger().Warn("拒绝确认请求", "peer", ctx.ClientAddr, "service", apdu.ServiceName(), "reason", errorCodeName(uint32(errorCode)))
+			return s.createErrorResponse(invokeID, *apdu.ServiceChoice, errorClass, errorCode), nil
+		}
+		s.Lock()
+		defer s.Unlock()
+		response, err := s.handleConfirmedService(ctx, apdu, invokeID)
+		if err != nil || len(response) == 0 {
+			return response, err
+		}
+		return s.fitResponse(ctx, apdu, response), nil
+	case BACnetAPDUTypeUnconfirmedServiceRequest:
+		// Unconfirmed service request 可能没有 invokeID
+		if apdu.ServiceChoice == nil {
+			return nil, fmt.Errorf("unconfirmed service request missing serviceChoice")
+		}
+		s.countAPDU(apdu)
+
+		s.Lock()
+		defer s.Unlock()
+		switch *apdu.ServiceChoice {
+		case BACnetServiceUnconfirmedWhoIs:
+			s.logPacket("收到Who-Is", "peer", ctx.ClientAddr)
+			if !s.limiter.allowDiscovery(s.now()) {
+				return nil, nil
+			}
+			if !s.whoIsMatches(apdu.Payload) {
+				return nil, nil
+			}
+			return s.createIAmResponse(), nil
+		case BACnetServiceUnconfirmedWhoHas:
+			s.logPacket("收到Who-Has", "peer", ctx.ClientAddr)
+			if !s.limiter.allowDiscovery(s.now()) {
+				return nil, nil
+			}
+			return s.handleWhoHas(apdu.Payload)
+		case BACnetServiceUnconfirmedIAm:
+			// 地址绑定在learnAddress中记录
+			return nil, nil
+		case BACnetServiceUnconfirmedPrivateTransfer:
+			return s.handlePrivateTransfer(ctx, apdu.Payload, 0, false)
+		default:
+			return nil, fmt.Errorf("Unsupported unconfirmed service type: 0x%02x\n", *apdu.ServiceChoice)
+		}
+	case BACnetAPDUTypeSimpleAck:
+		// 按照BACnet协议规范处理SimpleAck
+		// SimpleAck用于确认接收到确认服务请求并成功处理
+		invokeID := "未知"
+		serviceName := "未知"
+
+		if apdu.InvokeID != nil {
+			invokeID = fmt.Sprintf("0x%02x", *apdu.InvokeID)
+		}
+
+		if apdu.ServiceChoice != nil {
+			serviceName = apdu.ServiceName()
+		}
+
+		// 记录SimpleAck信息，符合BACnet协议规范的处理
+		s.logPacket("收到SimpleAck", "peer", ctx.ClientAddr, "service", serviceName, "invoke_id", invokeID)
+
+		// 根据BACnet协议，服务器接收到SimpleAck通常不需要回复
+		return nil, nil
+	case BACnetAPDUTypeComplexAck:
+		// 按照BACnet协议规范处理ComplexAck APDU
+		// ComplexAck用于确认服务请求并提供复杂的响应数据
+		invokeID := "未知"
+		serviceName := "未知"
+		segmented := "否"
+		moreFollows := "否"
+		sequenceNumber := 0
+		proposedWindowSize := 0
+		payloadSize := len(apdu.Payload)
+
+		// 获取InvokeID（请求标识）
+		if apdu.InvokeID != nil {
+			invokeID = fmt.Sprintf("0x%02x", *apdu.InvokeID)
+		}
+
+		// 获取控制标志信息
+		// 解析分段控制标志（SEG=0x08，MOR=0x04）
+		if (apdu.ControlFlags)&0x08 == 0x08 {
+			segmented = "是"
+		}
+		if (apdu.ControlFlags)&0x04 == 0x04 {
+			moreFollows = "是"
+		}
+
+		// 获取服务名称
+		if apdu.ServiceChoice != nil {
+			serviceName = apdu.ServiceName()
+		}
+
+		// 解析分段信息（如果适用）
+		if segmented == "是" && apdu.SequenceNumber != nil && apdu.ProposedWindowSize != nil {
+			sequenceNumber = int(*apdu.SequenceNumber)
+			proposedWindowSize = int(*apdu.ProposedWindowSize)
+			// 记录分段信息
+			s.logPacket("收到ComplexAck", "peer", ctx.ClientAddr, "service", serviceName, "invoke_id", invokeID,
+				"segmented", segmented, "more_follows", moreFollows, "sequence", sequenceNumber, "window", proposedWindowSize, "payload_bytes", payloadSize)
+		} else {
+			// 非分段ComplexAck
+			s.logPacket("收到ComplexAck", "peer", ctx.ClientAddr, "service", serviceName, "invoke_id", invokeID,
+				"segmented", segmented, "payload_bytes", payloadSize)
+		}
+
+		// 根据BACnet协议，服务器收到ComplexAck通常不需要回复
+		return nil, nil
+	case BACnetAPDUTypeSegmentAck:
+		// 按照BACnet协议规范处理SegmentAck APDU
+		// SegmentAck用于在分段传输场景中确认收到的数据段
+		invokeID := "未知"
+		sequenceNumber := 0
+		proposedWindowSize := 0
+		neglectStart := "否"
+		fragmented := "否"
+		serverInitiated := "否"
+
+		// 获取InvokeID（请求标识）
+		if apdu.InvokeID != nil {
+			invokeID = fmt.Sprintf("0x%02x", *apdu.InvokeID)
+		}
+
+		// 解析控制标志
+		// 第一位表示Neglect Start
+		if (apdu.ControlFlags)&0x08 == 0x08 {
+			neglectStart = "是"
+		}
+		// 第二位表示Fragmented
+		if (apdu.ControlFlags)&0x04 == 0x04 {
+			fragmented = "是"
+		}
+		// 第四位表示Server Initiated
+		if (apdu.ControlFlags)&0x01 == 0x01 {
+			serverInitiated = "是"
+		}
+
+		// 序列号和提议窗口大小由ParseAPDU解析
+		if apdu.SequenceNumber != nil && apdu.ProposedWindowSize != nil {
+			sequenceNumber = int(*apdu.SequenceNumber)
+			proposedWindowSize = int(*apdu.ProposedWindowSize)
+		}
+
+		// 记录SegmentAck信息，符合BACnet协议规范的处理
+		s.logPacket("收到SegmentAck", "peer", ctx.ClientAddr, "invoke_id", invokeID, "sequence", sequenceNumber, "window", proposedWindowSize,
+			"neglect_start", neglectStart, "fragmented", fragmented, "server_initiated", serverInitiated)
+
+		// 客户端确认分段应答后发送下一个分段
+		if apdu.InvokeID != nil && apdu.SequenceNumber != nil && serverInitiated == "否" {
+			return s.segments.ack(ctx.ClientAddr, *apdu.InvokeID, *apdu.SequenceNumber, s.now(), s.device.APDUTimeout()), nil
+		}
+		return nil, nil
+	case BACnetAPDUTypeError:
+		// 按照BACnet协议规范处理Error APDU
+		// Error用于指示服务请求已被拒绝，并提供错误详情
+		invokeID := "未知"
+		serviceName := "未知"
+
+		// 获取InvokeID（如果存在）
+		if apdu.InvokeID != nil {
+			invokeID = fmt.Sprintf("0x%02x", *apdu.InvokeID)
+		}
+
+		// 获取服务名称（如果存在）
+		if apdu.ServiceChoice != nil {
+			serviceName = apdu.ServiceName()
+		}
+
+		// 解析错误类别和错误代码（payload中的两个应用标签Enumerated）
+		classCode, code, err := decodeErrorPayload(apdu.Payload)
+		if err != nil {
+			s.Logger().Debug("收到的Error APDU无效", "peer", ctx.ClientAddr, "service", serviceName, "invoke_id", invokeID, "error", err)
+			return nil, nil
+		}
+		errorClass, errorCode := errorClassName(classCode), errorCodeName(code)
+
+		// 记录Error信息，符合BACnet协议规范的处理
+		s.Logger().Debug("收到Error", "peer", ctx.ClientAddr, "service", serviceName, "invoke_id", invokeID,
+			"error_class", errorClass, "error_code", errorCode)
+
+		// 根据BACnet协议，服务器接收到Error通常不需要回复
+		return nil, nil
+	case BACnetAPDUTypeReject:
+		// 按照BACnet协议规范处理Reject APDU
+		// Reject用于指示请求因格式或语义错误而被拒绝
+		rejectReason := "未知"
+		reasonCode := uint8(0)
+		invokeID := "未知"
+
+		// 获取InvokeID（如果存在）
+		if apdu.InvokeID != nil {
+			invokeID = fmt.Sprintf("0x%02x", *apdu.InvokeID)
+		}
+
+		// 拒绝原因代码在InvokeID之后
+		if len(apdu.Payload) > 0 {
+			reasonCode = apdu.Payload[0]
+			rejectReason = rejectReasonName(reasonCode)
+		}
+
+		// 记录Reject信息，符合BACnet协议规范的处理
+		s.Logger().Debug("收到Reject", "peer", ctx.ClientAddr, "invoke_id", invokeID, "reason_code", reasonCode, "reason", rejectReason)
+
+		// 根据BACnet协议，服务器接收到Reject通常不需要回复
+		return nil, nil
+	case BACnetAPDUTypeAbort:
+		// 按照BACnet协议规范处理Abort APDU
+		// Abort用于指示通信会话被意外终止
+		abortReason := "未知"
+		reasonCode := uint8(0)
+		invokeID := "未知"
+		isServer := false
+
+		// 获取InvokeID（如果存在）
+		if apdu.InvokeID != nil {
+			invokeID = fmt.Sprintf("0x%02x", *apdu.InvokeID)
+		}
+
+		// 解析控制标志中的服务器发起标志
+		if (apdu.ControlFlags & 0x01) != 0 {
+			isServer = true
+		}
+
+		// 解析放弃原因代码（BACnet协议规定在适当位置）
+		if len(apdu.Payload) > 0 {
+			reasonCode = apdu.Payload[0]
+			s.metrics.aborts.inc(strconv.Itoa(int(reasonCode)))
+			abortReason = abortReasonName(reasonCode)
+		}
+		if apdu.InvokeID != nil && !isServer {
+			// 客户端放弃了分段应答
+			s.segments.abort(ctx.ClientAddr, *apdu.InvokeID)
+		}
+
+		// 记录Abort信息，符合BACnet协议规范的处理
+		s.Logger().Debug("收到Abort", "peer", ctx.ClientAddr, "invoke_id", invokeID, "server", isServer,
+			"reason_code", reasonCode, "reason", abortReason)
+
+		// 根据BACnet协议，服务器接收到Abort通常不需要回复
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("Unhandled APDU: % x\n", data)
+	}
+}
+
+// readObjectIdentifier 读取4字节的对象标识符（类型10位，实例22位）
+func readObjectIdentifier(r *encoding.Reader) (model.ObjectIdentifier, error) {
+	value, err := r.Bytes(4)
+	if err != nil {
+		return model.ObjectIdentifier{}, fmt.Errorf("对象标识符: %w", err)
+	}
+	return encoding.DecodeObjectIdentifierValue(value), nil
+}
+
+// readPropertyIdentifier 读取2字节大端序的属性标识符
+func readPropertyIdentifier(r *encoding.Reader) (model.PropertyIdentifier, error) {
+	value, err := r.Uint16()
+	if err != nil {
+		return 0, fmt.Errorf("属性标识符: %w", err)
+	}
+	return model.PropertyIdentifier(value), nil
+}
+
+// expectEnd 检查请求数据已全部读取
+func expectEnd(r *encoding.Reader) error {
+	if r.Len() > 0 {
+		return fmt.Errorf("请求包含%d字节多余数据: %w", r.Len(), encoding.ErrTrailingData)
+	}
+	return nil
+}
+
+// readPropertyValue 读取属性值，指定数组下标时只读取数组长度或单个元素
+func readPropertyValue(obj model.Object, prop model.PropertyIdentifier, arrayIndex *uint32) (interface{}, error) {
+	if arrayIndex != nil {
+		return model.ReadPropertyElement(obj, prop, *arrayIndex)
+	}
+	return model.ReadPropertyValue(obj, prop)
+}
+
+// wireValue 返回属性值在网络上的表示：对象模型以bool表示的BACnetBinaryPV（二值对象的Present_Value、
+// Relinquish_Default、Alarm_Value及优先级数组的元素）编码为枚举inactive(0)、active(1)
+func wireValue(objType model.ObjectType, prop model.PropertyIdentifier, value interface{}) interface{} {
+	if prop == model.PropertyIdentifierPriorityArray {
+		prop = model.PropertyIdentifierRelinquishDefault
+	}
+	if meta, ok := model.LookupPropertyMetadata(objType, prop); !ok || meta.Datatype != model.DatatypeEnumerated {
+		return value
+	}
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return encoding.Enumerated(1)
+		}
+		return encoding.Enumerated(0)
+	case model.PriorityArray:
+		for i, slot := range v {
+			v[i] = wireValue(objType, prop, slot)
+		}
+		return v
+	}
+	return value
+}
+
+// readError 将读取属性的错误映射为BACnet错误类别和代码，钩子返回的*Error保持不变
+func readError(err error) (byte, byte) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Class, e.Code
+	}
+	return ErrorClassProperty, readErrorCode(err)
+}
+
+// readErrorCode 将读取属性时的模型错误映射为BACnet错误代码
+func readErrorCode(err error) byte {
+	switch {
+	case errors.Is(err, model.ErrInvalidArrayIndex):
+		return ErrorCodeInvalidArrayIndex
+	case errors.Is(err, model.ErrPropertyIsNotAnArray):
+		return ErrorCodePropertyIsNotAnArray
+	}
+	return ErrorCodePropertyNotExist
+}
+
+// encodeObjectIdentifier 编码对象标识符为BACnet格式
+func encodeObjectIdentifier(oid model.ObjectIdentifier) []byte {
+	// BACnet格式：类型占10位，实例占22位
+	typeAndInstance := uint32(oid.Type)<<22 | (oid.Instance & 0x3FFFFF)
+	return []byte{
+		byte(typeAndInstance >> 24),
+		byte(typeAndInstance >> 16),
+		byte(typeAndInstance >> 8),
+		byte(typeAndInstance),
+	}
+}
+
+// encodePropertyIdentifier 编码属性标识符为BACnet格式
+func encodePropertyIdentifier(propID model.PropertyIdentifier) []byte {
+	// BACnet协议中，属性标识符使用2字节大端序格式编码
+	// 确保属性标识符在2字节范围内
+	if uint32(propID) > 0xFFFF {
+		// 如果超出范围，返回一个默认值或错误处理
+		// 这里我们使用大端序编码，但限制在2字节内
+		return []byte{
+			byte(0xFF),
+			byte(0xFF),
+		}
+	}
+
+	// 正确的大端序编码实现
+	return []byte{
+		byte(uint32(propID) >> 8), // 高字节
+		byte(propID & 0xFF),       // 低字节
+	}
+}
+
+// encodeBACnetValue 按应用标签编码属性值，数组类属性依次编码各元素
+func encodeBACnetValue(value interface{}) []byte {
+	w := encoding.GetWriter()
+	defer encoding.PutWriter(w)
+	writeBACnetValue(w, value)
+	return append([]byte(nil), w.Bytes()...)
+}
+
+// writeBACnetValue 将属性值编码到w，规则同encodeBACnetValue
+func writeBACnetValue(w *encoding.Writer, value interface{}) {
+	switch v := value.(type) {
+	case model.PriorityArray:
+		// 16个优先级依次编码，未命令的优先级编码为NULL
+		for _, slot := range v {
+			writeBACnetValue(w, slot)
+		}
+	case []model.ObjectIdentifier:
+		for _, oid := range v {
+			w.Application(oid)
+		}
+	case time.Time:
+		// 时间类属性（如Time_Of_Device_Restart）按BACnetTimeStamp编码
+		w.Write(encodeTimeStampValue(v))
+	case model.TimeStamp:
+		w.Write(encoding.EncodeTimeStamp(v))
+	case model.DateTime:
+		w.Write(encoding.EncodeDateTime(v.Time))
+	case model.Prescale:
+		w.Write(encoding.EncodePrescale(v))
+	case model.Scale:
+		w.Write(encoding.EncodeScale(v))
+	case model.DateRange:
+		w.Write(encoding.EncodeDateRange(v))
+	case model.DeviceObjectPropertyReference:
+		w.Write(encoding.EncodeDeviceObjectPropertyReference(v))
+	case []model.DeviceObjectPropertyReference:
+		for _, ref := range v {
+			w.Write(encoding.EncodeDeviceObjectPropertyReference(ref))
+		}
+	case model.ObjectPropertyReference:
+		w.Write(encoding.EncodeObjectPropertyReference(v))
+	case model.SetpointReference:
+		// 未引用其他属性时编码为空序列
+		if v.Reference != nil {
+			w.Write(encoding.EncodeConstructed(0, encoding.EncodeObjectPropertyReference(*v.Reference)))
+		}
+	case []model.Destination:
+		for _, dest := range v {
+			w.Write(encoding.EncodeDestination(dest))
+		}
+	case []model.Recipient:
+		for _, recipient := range v {
+			w.Write(encoding.EncodeRecipient(recipient))
+		}
+	case []model.AddressBinding:
+		for _, binding := range v {
+			w.Application(binding.Device)
+			w.Application(uint32(binding.Address.Network))
+			w.Application(binding.Address.MAC)
+		}
+	case []model.TimeValue:
+		// Weekly_Schedule的数组元素
+		w.Write(encodeDailySchedule(v))
+	case [7][]model.TimeValue:
+		for _, day := range v {
+			w.Write(encodeDailySchedule(day))
+		}
+	case []string:
+		// State_Text等CharacterString数组
+		for _, text := range v {
+			w.CharacterString(text)
+		}
+	case []model.CalendarEntry:
+		for _, entry := range v {
+			w.Write(encoding.EncodeCalendarEntry(entry))
+		}
+	case model.SpecialEvent:
+		// Exception_Schedule的数组元素
+		w.Write(encodeSpecialEvent(v))
+	case []model.SpecialEvent:
+		for _, event := range v {
+			w.Write(encodeSpecialEvent(event))
+		}
+	default:
+		if err := w.Application(value); err != nil {
+			// 未知类型，返回空值
+			slog.Warn("编码属性值失败", "error", err)
+			w.Application(nil)
+		}
+	}
+}
+
+// ReadPropertyRequest ReadProperty请求结构
+type ReadPropertyRequest struct {
+	ObjectID   model.ObjectIdentifier   `bacnet:"0"`
+	PropertyID model.PropertyIdentifier `bacnet:"1,enum"`
+	ArrayIndex *uint32                  `bacnet:"2"` // 为空时读取整个属性
+}
+
+// parseReadPropertyRequest 解析ReadProperty请求
+//
+//	ReadProperty-Request ::= SEQUENCE {
+//	  objectIdentifier   [0] BACnetObjectIdentifier,
+//	  propertyIdentifier [1] BACnetPropertyIdentifier,
+//	  propertyArrayIndex [2] Unsigned OPTIONAL }
+func parseReadPropertyRequest(data []byte) (ReadPropertyRequest, error) {
+	var request ReadPropertyRequest
+	err := encoding.Unmarshal(data, &request)
+	return request, err
+}
+
+// writeReadPropertyAck 编码ReadProperty-ACK的服务数据
+//
+//	ReadProperty-ACK ::= SEQUENCE {
+//	  objectIdentifier   [0] BACnetObjectIdentifier,
+//	  propertyIdentifier [1] BACnetPropertyIdentifier,
+//	  propertyArrayIndex [2] Unsigned OPTIONAL,
+//	  propertyValue      [3] ABSTRACT-SYNTAX.&Type }
+func writeReadPropertyAck(w *encoding.Writer, request ReadPropertyRequest, value interface{}) error {
+	w.ContextObjectIdentifier(0, request.ObjectID)
+	w.ContextEnumerated(1, uint32(request.PropertyID))
+	if request.ArrayIndex != nil {
+		w.ContextUnsigned(2, *request.ArrayIndex)
+	}
+	w.OpeningTag(3)
+	if err := writeValueForProperty(w, request.PropertyID, value); err != nil {
+		return err
+	}
+	w.ClosingTag(3)
+	return nil
+}
+
+// handleConfirmedService 按服务选择处理确认请求
+func (s *BACnetServer) handleConfirmedService(ctx *RequestContext, apdu *APDU, invokeID byte) ([]byte, error) {
+	switch *apdu.ServiceChoice {
+	case BACnetServiceConfirmedReadProperty:
+		return s.handleReadProperty(ctx, apdu.Payload, invokeID)
+	case BACnetServiceConfirmedWriteProperty:
+		return s.handleWriteProperty(ctx, apdu.Payload, invokeID)
+	case BACnetServiceConfirmedReadPropertyMultiple:
+		return s.handleReadPropertyMultiple(ctx, apdu.Payload, invokeID)
+	case BACnetServiceConfirmedWritePropertyMultiple:
+		return s.handleWritePropertyMultiple(ctx, apdu.Payload, invokeID)
+	case BACnetServiceConfirmedAcknowledgeAlarm:
+		return s.handleAcknowledgeAlarm(apdu.Payload, invokeID)
+	case BACnetServiceConfirmedAtomicReadFile:
+		return s.handleAtomicReadFile(apdu.Payload, invokeID)
+	case BACnetServiceConfirmedAtomicWriteFile:
+		return s.handleAtomicWriteFile(ctx, apdu.Payload, invokeID)
+	case BACnetServiceConfirmedSubscribeCOV:
+		return s.handleSubscribeCOV(ctx, apdu.Payload, invokeID)
+	case BACnetServiceConfirmedSubscribeCOVProperty:
+		return s.handleSubscribeCOVProperty(ctx, apdu.Payload, invokeID)
+	case BACnetServiceConfirmedReadRange:
+		return s.handleReadRange(apdu.Payload, invokeID)
+	case BACnetServiceConfirmedLifeSafetyOperation:
+		return s.handleLifeSafetyOperation(apdu.Payload, invokeID)
+	case BACnetServiceConfirmedPrivateTransfer:
+		return s.handlePrivateTransfer(ctx, apdu.Payload, invokeID, true)
+	default:
+		return s.createRejectResponse(invokeID, RejectReasonUnrecognizedService), nil
+	}
+}
+
+// handleReadProperty 处理读取属性请求
+func (s *BACnetServer) handleReadProperty(ctx *RequestContext, data []byte, invokeID byte) ([]byte, error) {
+	request, err := parseReadPropertyRequest(data)
+	if err != nil {
+		// 请求格式错误，按BACnet协议拒绝
+		return s.createRejectResponse(invokeID, rejectReasonFor(err)), nil
+	}
+	objectID, propertyID, arrayIndex := request.ObjectID, request.PropertyID, request.ArrayIndex
+
+	// 查找对象
+	var targetObj model.Object
+
+	// 检查是否是设备对象本身
+	if objectID.Type == model.ObjectTypeDevice && objectID.Instance == s.device.GetObjectIdentifier().Instance {
+		targetObj = s.device
+	} else {
+		// 在设备的对象列表中查找
+		targetObj = s.device.FindObject(objectID)
+	}
+
+	// 对象不存在
+	if targetObj == nil {
+		return s.createErrorResponse(invokeID, BACnetServiceConfirmedReadProperty, ErrorClassObject, ErrorCodeObjectNotExist), nil
+	}
+
+	// 经钩子读取属性值，数组元素（如未命令的优先级）可以为NULL
+	value, err := s.readProperty(ctx, targetObj, propertyID, arrayIndex)
+	if err != nil {
+		errorClass, errorCode := readError(err)
+		return s.createErrorResponse(invokeID, BACnetServiceConfirmedReadProperty, errorClass, errorCode), nil
+	}
+	if value == nil && arrayIndex == nil {
+		return s.createErrorResponse(invokeID, BACnetServiceConfirmedReadProperty, ErrorClassProperty, ErrorCodePropertyNotExist), nil
+	}
+
+	// 编码属性值
+	ack := newComplexAckWriter(invokeID, BACnetServiceConfirmedReadProperty)
+	if err := writeReadPropertyAck(ack.Writer, request, wireValue(objectID.Type, propertyID, value)); err != nil {
+		ack.discard()
+		return s.createErrorResponse(invokeID, BACnetServiceConfirmedReadProperty, ErrorClassProperty, ErrorCodeInvalidDataType), nil
+	}
+	return ack.finish(), nil
+}
+
+// writeValueForProperty 将属性值编码到w，注册了编码函数的专有属性使用自定义编码
+func writeValueForProperty(w *encoding.Writer, prop model.PropertyIdentifier, value interface{}) error {
+	if p, ok := model.LookupProprietaryProperty(prop); ok && p.Encode != nil {
+		encoded, err := p.Encode(value)
+		if err != nil {
+			return err
+		}
+		w.Write(encoded)
+		return nil
+	}
+	writeBACnetValue(w, value)
+	return nil
+}
+
+// decodeValueForProperty 解码属性值，注册了解码函数的专有属性使用自定义解码，构造类型的标准属性按属性解码
+func decodeValueForProperty(prop model.PropertyIdentifier, data []byte) (interface{}, int, error) {
+	if p, ok := model.LookupProprietaryProperty(prop); ok && p.Decode != nil {
+		return p.Decode(data)
+	}
+	if value, n, ok, err := decodeConstructedValue(prop, data); ok {
+		return value, n, err
+	}
+	return decodeBACnetValue(data)
+}
+
+// decodeElementForProperty 解码写入数组属性单个元素的值，下标0的数组长度和没有元素解码函数的属性按单个应用标签值解码
+func decodeElementForProperty(prop model.PropertyIdentifier, index uint32, data []byte) (interface{}, int, error) {
+	if index != 0 {
+		if value, n, ok, err := decodeConstructedElement(prop, data); ok {
+			return value, n, err
+		}
+	}
+	return decodeBACnetValue(data)
+}
+
+// coerceWriteValue 将解码出的值转换为属性当前值使用的Go类型，
+// 如BACnetBinaryPV转换为bool，EventState等枚举转换为对应的命名类型，Signed转换为属性使用的整数宽度
+func coerceWriteValue(obj model.Object, prop model.PropertyIdentifier, value interface{}) interface{} {
+	if bits, ok := value.(model.BitString); ok {
+		// 以uint8保存的位标志（如Member_Status_Flags）按位转换
+		if current, _ := obj.ReadProperty(prop); reflect.ValueOf(current).Kind() == reflect.Uint8 && bits.Len() <= 8 {
+			return uint8(bits.Uint())
+		}
+		return bits
+	}
+	switch v := value.(type) {
+	case int32:
+		return coerceSigned(obj, prop, int64(v), value)
+	case int64:
+		return coerceSigned(obj, prop, v, value)
+	case float32:
+		// Real写入Double属性
+		if current, _ := obj.ReadProperty(prop); reflect.ValueOf(current).Kind() == reflect.Float64 {
+			return float64(v)
+		}
+		return value
+	}
+	enum, isEnum := value.(encoding.Enumerated)
+	number, isUnsigned := value.(uint32)
+	if !isEnum && !isUnsigned {
+		return value
+	}
+	if isEnum {
+		number = uint32(enum)
+	}
+
+	current, _ := obj.ReadProperty(prop)
+	target := reflect.ValueOf(current)
+	switch target.Kind() {
+	case reflect.Bool:
+		// BACnetBinaryPV：inactive(0)、active(1)
+		if isEnum && number <= 1 {
+			return number == 1
+		}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		if !target.OverflowUint(uint64(number)) {
+			return reflect.ValueOf(number).Convert(target.Type()).Interface()
+		}
+	}
+	return number
+}
+
+// coerceSigned 将Signed值转换为属性当前值的整数类型，超出范围时保持原值由属性校验
+func coerceSigned(obj model.Object, prop model.PropertyIdentifier, number int64, value interface{}) interface{} {
+	current, _ := obj.ReadProperty(prop)
+	target := reflect.ValueOf(current)
+	switch target.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !target.OverflowInt(number) {
+			return reflect.ValueOf(number).Convert(target.Type()).Interface()
+		}
+	}
+	return value
+}
+
+// decodeBACnetValue 解码一个应用标签值
+func decodeBACnetValue(data []byte) (interface{}, int, error) {
+	return encoding.DecodeApplication(data)
+}
+
+// WritePropertyRequest WriteProperty请求结构
+type WritePropertyRequest struct {
+	ObjectID   model.ObjectIdentifier   `bacnet:"0"`
+	PropertyID model.PropertyIdentifier `bacnet:"1,enum"`
+	ArrayIndex *uint32                  `bacnet:"2"`          // 为空时写入整个属性
+	Value      []byte                   `bacnet:"3,raw"`      // propertyValue开始/结束标签之间的编码值
+	Priority   uint8                    `bacnet:"4,optional"` // 请求未携带优先级时为16
+}
+
+// parseWritePropertyRequest 解析WriteProperty请求
+//
+//	WriteProperty-Request ::= SEQUENCE {
+//	  objectIdentifier   [0] BACnetObjectIdentifier,
+//	  propertyIdentifier [1] BACnetPropertyIdentifier,
+//	  propertyArrayIndex [2] Unsigned OPTIONAL,
+//	  propertyValue      [3] ABSTRACT-SYNTAX.&Type,
+//	  priority           [4] Unsigned (1..16) OPTIONAL }
+func parseWritePropertyRequest(data []byte) (WritePropertyRequest, error) {
+	request := WritePropertyRequest{Priority: 16}
+	err := encoding.Unmarshal(data, &request)
+	return request, err
+}
+
+// handleWriteProperty 处理写入属性请求
+func (s *BACnetServer) handleWriteProperty(ctx *RequestContext, data []byte, invokeID byte) ([]byte, error) {
+	request, err := parseWritePropertyRequest(data)
+	if err != nil {
+		// 请求格式错误，按BACnet协议拒绝
+		return s.createRejectResponse(invokeID, rejectReasonFor(err)), nil
+	}
+	objectID, propertyID, priority := request.ObjectID, request.PropertyID, request.Priority
+
+	// 验证优先级值是否在有效范围内
+	if priority < 1 || priority > 16 {
+		return s.createErrorResponse(invokeID, BACnetServiceConfirmedWriteProperty, ErrorClassProperty, ErrorCodeInvalidParameterDataType), nil
+	}
+
+	// 解码属性值，propertyValue中只能有一个值；指定数组下标时为一个数组元素
+	var value interface{}
+	var n int
+	if request.ArrayIndex != nil {
+		value, n, err = decodeElementForProperty(propertyID, *request.ArrayIndex, request.Value)
+	} else {
+		value, n, err = decodeValueForProperty(propertyID, request.Value)
+	}
+	if errors.Is(err, encoding.ErrCharacterSetNotSupported) {
+		return s.createErrorResponse(invokeID, BACnetServiceConfirmedWriteProperty, ErrorClassProperty, ErrorCodeCharacterSetNotSupported), nil
+	}
+	if errors.Is(err, model.ErrInvalidDataType) {
+		return s.createErrorResponse(invokeID, BACnetServiceConfirmedWriteProperty, ErrorClassProperty, ErrorCodeInvalidDataType), nil
+	}
+	if err != nil {
+		return s.createErrorResponse(invokeID, BACnetServiceConfirmedWriteProperty, ErrorClassService, ErrorCodeValueOutOfRange), nil
+	}
+	if n != len(request.Value) {
+		return s.createErrorResponse(invokeID, BACnetServiceConfirmedWriteProperty, ErrorClassProperty, ErrorCodeInvalidDataType), nil
+	}
+
+	// 查找对象
+	var targetObj model.Object
+
+	// 检查是否是设备对象本身
+	if objectID.Type == model.ObjectTypeDevice && objectID.Instance == s.device.GetObjectIdentifier().Instance {
+		targetObj = s.device
+	} else {
+		// 在设备的对象列表中查找
+		targetObj = s.device.FindObject(objectID)
+	}
+
+	// 对象不存在
+	if targetObj == nil {
+		return s.createErrorResponse(invokeID, BACnetServiceConfirmedWriteProperty, ErrorClassObject, ErrorCodeObjectNotExist), nil
+	}
+
+	// 按属性元数据检查属性是否存在、是否可写及数据类型，再按优先级写入
+	// 可命令属性写入NULL表示释放该优先级；写入数组元素时替换该元素后写入整个数组
+	if request.ArrayIndex == nil {
+		value = coerceWriteValue(targetObj, propertyID, value)
+	}
+	if err := s.writePropertyElement(ctx, targetObj, propertyID, request.ArrayIndex, value, priority); err != nil {
+		errorClass, errorCode := writeErrorCode(err)
+		return s.createErrorResponse(invokeID, BACnetServiceConfirmedWriteProperty, errorClass, errorCode), nil
+	}
+	if isCommandState(targetObj, propertyID) {
+		s.saveCommandState()
+	}
+
+	// 构建SimpleAck响应
+	response := encodeSimpleAck(invokeID, BACnetServiceConfirmedWriteProperty)
+
+	return response, nil
+}
+
+// writeErrorCode 将写属性错误映射为BACnet错误类和错误码
+func writeErrorCode(err error) (byte, byte) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Class, e.Code
+	}
+	if errors.Is(err, model.ErrValueOutOfRange) {
+		return ErrorClassProperty, ErrorCodeValueOutOfRange
+	}
+	if errors.Is(err, model.ErrWriteAccessDenied) {
+		return ErrorClassProperty, ErrorCodeWriteAccessDenied
+	}
+	if errors.Is(err, model.ErrUnknownProperty) {
+		return ErrorClassProperty, ErrorCodePropertyNotExist
+	}
+	if errors.Is(err, model.ErrInvalidDataType) {
+		return ErrorClassProperty, ErrorCodeInvalidDataType
+	}
+	if errors.Is(err, model.ErrDuplicateName) {
+		return ErrorClassProperty, ErrorCodeDuplicateName
+	}
+	if errors.Is(err, model.ErrRemoteReference) {
+		return ErrorClassProperty, ErrorCodeOptionalFunctionalityNotSupported
+	}
+	if errors.Is(err, model.ErrInvalidArrayIndex) || errors.Is(err, model.ErrPropertyIsNotAnArray) {
+		return ErrorClassProperty, readErrorCode(err)
+	}
+	// 属性不可写
+	return ErrorClassProperty, ErrorCodePropertyNotWritable
+}
+
+// PropertyReference BACnetPropertyReference，ArrayIndex为空时引用整个属性
+type PropertyReference struct {
+	PropertyID model.PropertyIdentifier `bacnet:"0,enum"`
+	ArrayIndex *uint32                  `bacnet:"1"`
+}
+
+// ReadAccessSpecification ReadPropertyMultiple请求中一个对象的读取规范
+type ReadAccessSpecification struct {
+	ObjectID   model.ObjectIdentifier
+	Properties []PropertyReference
+}
+
+// parseReadPropertyMultipleRequest 解析ReadPropertyMultiple请求
+//
+//	ReadPropertyMultiple-Request ::= SEQUENCE OF ReadAccessSpecification
+//	ReadAccessSpecification ::= SEQUENCE {
+//	  objectIdentifier         [0] BACnetObjectIdentifier,
+//	  listOfPropertyReferences [1] SEQUENCE OF BACnetPropertyReference }
+//	BACnetPropertyReference ::= SEQUENCE {
+//	  propertyIdentifier [0] BACnetPropertyIdentifier,
+//	  propertyArrayIndex [1] Unsigned OPTIONAL }
+func parseReadPropertyMultipleRequest(data []byte) ([]ReadAccessSpecification, error) {
+	var specs []ReadAccessSpecification
+	d := encoding.NewDecoder(data)
+	for !d.Done() {
+		var spec ReadAccessSpecification
+		var err error
+		if spec.ObjectID, err = d.ContextObjectIdentifier(0); err != nil {
+			return nil, err
+		}
+		if err := d.Opening(1); err != nil {
+			return nil, err
+		}
+		for !d.IsClosing(1) {
+			propertyID, err := d.ContextEnumerated(0)
+			if err != nil {
+				return nil, err
+			}
+			ref := PropertyReference{PropertyID: model.PropertyIdentifier(propertyID)}
+			if d.IsContext(1) {
+				index, err := d.ContextUnsigned(1)
+				if err != nil {
+					return nil, err
+				}
+				ref.ArrayIndex = &index
+			}
+			spec.Properties = append(spec.Properties, ref)
+		}
+		d.Closing(1)
+		if len(spec.Properties) == 0 {
+			return nil, fmt.Errorf("对象%v的属性引用列表为空", spec.ObjectID)
+		}
+		specs = append(specs, spec)
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("ReadPropertyMultiple请求不包含读取规范")
+	}
+	return specs, nil
+}
+
+// writeReadAccessResult 编码一个属性的读取结果，编码属性值失败时改为编码propertyAccessError
+//
+//	SEQUENCE {
+//	  propertyIdentifier [2] BACnetPropertyIdentifier,
+//	  propertyArrayIndex [3] Unsigned OPTIONAL,
+//	  readResult CHOICE {
+//	    propertyValue       [4] ABSTRACT-SYNTAX.&Type,
+//	    propertyAccessError [5] Error } }
+func writeReadAccessResult(w *encoding.Writer, prop model.PropertyIdentifier, arrayIndex *uint32, value interface{}) {
+	mark := w.Len()
+	w.ContextEnumerated(2, uint32(prop))
+	if arrayIndex != nil {
+		w.ContextUnsigned(3, *arrayIndex)
+	}
+	w.OpeningTag(4)
+	if err := writeValueForProperty(w, prop, value); err != nil {
+		w.Truncate(mark)
+		writeReadAccessError(w, prop, arrayIndex, ErrorClassProperty, readErrorCode(err))
+		return
+	}
+	w.ClosingTag(4)
+}
+
+// writeReadAccessError 编码带propertyAccessError的读取结果
+func writeReadAccessError(w *encoding.Writer, prop model.PropertyIdentifier, arrayIndex *uint32, errorClass, errorCode byte) {
+	w.ContextEnumerated(2, uint32(prop))
+	if arrayIndex != nil {
+		w.ContextUnsigned(3, *arrayIndex)
+	}
+	w.OpeningTag(5)
+	w.Enumerated(uint32(errorClass))
+	w.Enumerated(uint32(errorCode))
+	w.ClosingTag(5)
+}
+
+// handleReadPropertyMultiple 处理读取多个属性请求
+func (s *BACnetServer) handleReadPropertyMultiple(ctx *RequestContext, data []byte, invokeID byte) ([]byte, error) {
+	specs, err := parseReadPropertyMultipleRequest(data)
+	if err != nil {
+		// 请求格式错误，按BACnet协议拒绝
+		return s.createRejectResponse(invokeID, rejectReasonFor(err)), nil
+	}
+
+	ack := newComplexAckWriter(invokeID, BACnetServiceConfirmedReadPropertyMultiple)
+	for _, spec := range specs {
+		// 查找对象
+		var targetObj model.Object
+		if spec.ObjectID.Type == model.ObjectTypeDevice && spec.ObjectID.Instance == s.device.GetObjectIdentifier().Instance {
+			targetObj = s.device
+		} else {
+			targetObj = s.device.FindObject(spec.ObjectID)
+		}
+
+		// ReadAccessResult ::= SEQUENCE { objectIdentifier [0], listOfResults [1] }
+		ack.ContextObjectIdentifier(0, spec.ObjectID)
+		ack.OpeningTag(1)
+		for _, ref := range spec.Properties {
+			// 对象不存在时每个属性引用都返回对象错误
+			if targetObj == nil {
+				writeReadAccessError(ack.Writer, ref.PropertyID, ref.ArrayIndex, ErrorClassObject, ErrorCodeObjectNotExist)
+				continue
+			}
+
+			// ALL、REQUIRED、OPTIONAL按属性元数据展开为具体属性，带数组下标时只读取单个属性
+			propIDs := []model.PropertyIdentifier{ref.PropertyID}
+			if ref.ArrayIndex == nil {
+				propIDs = model.ExpandPropertyReference(targetObj, ref.PropertyID)
+			}
+			for _, propID := range propIDs {
+				value, err := s.readProperty(ctx, targetObj, propID, ref.ArrayIndex)
+				if err == nil && value == nil && ref.ArrayIndex == nil {
+					err = model.ErrUnknownProperty
+				}
+				if err != nil {
+					errorClass, errorCode := readError(err)
+					writeReadAccessError(ack.Writer, propID, ref.ArrayIndex, errorClass, errorCode)
+					continue
+				}
+				writeReadAccessResult(ack.Writer, propID, ref.ArrayIndex, wireValue(spec.ObjectID.Type, propID, value))
+			}
+		}
+		ack.ClosingTag(1)
+	}
+
+	return ack.finish(), nil
+}
+
+// parseWriteAccessSpec 解析写入访问规范：对象标识符后跟若干（属性标识符、优先级、属性值）
+func parseWriteAccessSpec(data []byte) (model.ObjectIdentifier, []struct {
+	PropertyID model.PropertyIdentifier
+	Value      interface{}
+	Priority   uint8
+}, int, error) {
+	var propertyValues []struct {
+		PropertyID model.PropertyIdentifier
+		Value      interface{}
+		Priority   uint8
+	}
+	r := encoding.NewReader(data)
+
+	objectID, err := readObjectIdentifier(r)
+	if err != nil {
+		return model.ObjectIdentifier{}, nil, r.Offset(), err
+	}
+
+	for r.Len() > 0 {
+		propID, err := readPropertyIdentifier(r)
+		if err != nil {
+			return objectID, propertyValues, r.Offset(), err
+		}
+		priority, err := r.Byte()
+		if err != nil {
+			return objectID, propertyValues, r.Offset(), fmt.Errorf("优先级: %w", err)
+		}
+		if r.Len() == 0 {
+			return objectID, propertyValues, r.Offset(), fmt.Errorf("缺少属性值: %w", encoding.ErrTruncated)
+		}
+		value, n, err := decodeValueForProperty(propID, r.Remaining())
+		if err != nil {
+			return objectID, propertyValues, r.Offset(), fmt.Errorf("属性值: %w", err)
+		}
+		r.Bytes(n)
+
+		propertyValues = append(propertyValues, struct {
+			PropertyID model.PropertyIdentifier
+			Value      interface{}
+			Priority   uint8
+		}{propID, value, priority})
+	}
+
+	return objectID, propertyValues, r.Offset(), nil
+}
+
+// createWritePropertyMultipleErrorResponse 创建WritePropertyMultiple错误响应
+func (s *BACnetServer) createWritePropertyMultipleErrorResponse(invokeID byte, writeAccessSpecs []struct {
+	ObjectID       model.ObjectIdentifier
+	PropertyErrors []struct {
+		PropertyID model.PropertyIdentifier
+		ErrorClass byte
+		ErrorCode  byte
+	}
+}) []byte {
+	// 添加错误信息
+	var response []byte
+	for _, spec := range writeAccessSpecs {
+		// 添加对象标识符
+		response = append(response, encodeObjectIdentifier(spec.ObjectID)...)
+
+		// 添加属性错误列表
+		for _, propErr := range spec.PropertyErrors {
+			// 添加属性标识符
+			propertyIDBytes := []byte{
+				byte(propErr.PropertyID >> 8),
+				byte(propErr.PropertyID),
+			}
+			response = append(response, propertyIDBytes...)
+
+			// 添加错误信息
+			errorInfo := []byte{
+				0x11, // 上下文标签1，表示错误
+				propErr.ErrorClass,
+				propErr.ErrorCode,
+			}
+			response = append(response, errorInfo...)
+		}
+	}
+
+	return encodeComplexAck(invokeID, BACnetServiceConfirmedWritePropertyMultiple, response)
+}
+
+// handleWritePropertyMultiple 处理写入多个属性请求
+func (s *BACnetServer) handleWritePropertyMultiple(ctx *RequestContext, data []byte, invokeID byte) ([]byte, error) {
+	var offset int
+	var hasErrors bool
+	var errorSpecs []struct {
+		ObjectID       model.ObjectIdentifier
+		PropertyErrors []struct {
+			PropertyID model.PropertyIdentifier
+			ErrorClass byte
+			ErrorCode  byte
+		}
+	}
+
+	if len(data) == 0 {
+		return s.createRejectResponse(invokeID, RejectReasonMissingRequiredParameter), nil
+	}
+
+	// 解析请求中的所有写入访问规范
+	for offset < len(data) {
+		// 解析写入访问规范
+		objectID, propertyValues, specOffset, err := parseWriteAccessSpec(data[offset:])
+		if err != nil {
+			s.logPacket("WritePropertyMultiple请求格式错误", "invoke_id", invokeID, "error", err)
+			return s.createRejectResponse(invokeID, rejectReasonFor(err)), nil
+		}
+		offset += specOffset
+
+		// 查找目标对象
+		var targetObj model.Object
+		if objectID.Type == model.ObjectTypeDevice && objectID.Instance == s.device.GetObjectIdentifier().Instance {
+			targetObj = s.device
+		} else {
+			targetObj = s.device.FindObject(objectID)
+		}
+
+		// 处理每个属性的写入
+		spec := struct {
+			ObjectID       model.ObjectIdentifier
+			PropertyErrors []struct {
+				PropertyID model.PropertyIdentifier
+				ErrorClass byte
+				ErrorCode  byte
+			}
+		}{ObjectID: objectID}
+
+		objectExists := targetObj != nil
+
+		for _, propVal := range propertyValues {
+			var errorClass, errorCode byte
+
+			if !objectExists {
+				// 对象不存在
+				errorClass = ErrorClassObject
+				errorCode = ErrorCodeObjectNotExist
+			} else {
+				// 经钩子写入属性，使用默认优先级16（简化处理）
+				value := coerceWriteValue(targetObj, propVal.PropertyID, propVal.Value)
+				if err := s.writeProperty(ctx, targetObj, propVal.PropertyID, value, 16); err != nil {
+					errorClass, errorCode = writeErrorCode(err)
+				} else if isCommandState(targetObj, propVal.PropertyID) {
+					s.saveCommandState()
+				}
+			}
+
+			// 如果有错误，添加到错误规范中
+			if errorClass != 0 {
+				hasErrors = true
+				spec.PropertyErrors = append(spec.PropertyErrors, struct {
+					PropertyID model.PropertyIdentifier
+					ErrorClass byte
+					ErrorCode  byte
+				}{propVal.PropertyID, errorClass, errorCode})
+			}
+		}
+
+		// 如果有错误，添加到错误规范列表
+		if len(spec.PropertyErrors) > 0 {
+			errorSpecs = append(errorSpecs, spec)
+		}
+	}
+
+	if hasErrors {
+		// 有错误，返回包含错误信息的ComplexAck响应
+		return s.createWritePropertyMultipleErrorResponse(invokeID, errorSpecs), nil
+	} else {
+		// 全部成功，返回SimpleAck响应
+		response := encodeSimpleAck(invokeID, BACnetServiceConfirmedWritePropertyMultiple)
+		return response, nil
+	}
+}
+
+// 告警状态常量
+const (
+	EventStateNormal        = 0x00 // 正常
+	EventStateFault         = 0x01 // 故障
+	EventStateOffnormal     = 0x02 // 异常
+	EventStateHighAlarm     = 0x03 // 高告警
+	EventStateLowAlarm      = 0x04 // 低告警
+	EventStateHighHighAlarm = 0x05 // 高高告警
+	EventStateLowLowAlarm   = 0x06 // 低低告警
+)
+
+// AcknowledgeAlarmRequest 告警确认请求结构
+type AcknowledgeAlarmRequest struct {
+	AcknowledgingProcessID uint32
+	EventObjectID          model.ObjectIdentifier
+	EventStateAcknowledged model.EventState
+	TimeStamp              time.Time
+	AcknowledgmentSource   string
+	TimeOfAcknowledgment   time.Time
+}
+
+// 解析告警确认请求数据
+//
+//	AcknowledgeAlarm-Request ::= SEQUENCE {
+//	  acknowledgingProcessIdentifier [0] Unsigned32,
+//	  eventObjectIdentifier          [1] BACnetObjectIdentifier,
+//	  eventStateAcknowledged         [2] BACnetEventState,
+//	  timeStamp                      [3] BACnetTimeStamp,
+//	  acknowledgmentSource           [4] CharacterString,
+//	  timeOfAcknowledgment           [5] BACnetTimeStamp }
+func parseAcknowledgeAlarmRequest(data []byte, now time.Time) (AcknowledgeAlarmRequest, error) {
+	var request AcknowledgeAlarmRequest
+	d := encoding.NewDecoder(data)
+
+	processID, err := d.ContextUnsigned(0)
+	if err != nil {
+		return request, err
+	}
+	request.AcknowledgingProcessID = processID
+
+	if request.EventObjectID, err = d.ContextObjectIdentifier(1); err != nil {
+		return request, err
+	}
+
+	state, err := d.ContextUnsigned(2)
+	if err != nil {
+		return request, err
+	}
+	request.EventStateAcknowledged = model.EventState(state)
+
+	if request.TimeStamp, _, err = decodeTimeStamp(d, 3, now); err != nil {
+		return request, err
+	}
+	if request.AcknowledgmentSource, err = d.ContextCharacterString(4); err != nil {
+		return request, err
+	}
+	if request.TimeOfAcknowledgment, _, err = decodeTimeStamp(d, 5, now); err != nil {
+		return request, err
+	}
+
+	return request, nil
+}
+
+// handleAcknowledgeAlarm 处理告警确认请求
+func (s *BACnetServer) handleAcknowledgeAlarm(data []byte, invokeID byte) ([]byte, error) {
+	// 解析告警确认请求数据
+	request, err := parseAcknowledgeAlarmRequest(data, s.now())
+	if err != nil {
+		// 请求格式错误，按BACnet协议拒绝
+		return s.createRejectResponse(invokeID, rejectReasonFor(err)), nil
+	}
+
+	// 查找对应的对象
+	var targetObj model.Object
+	if request.EventObjectID.Type == model.ObjectTypeDevice && request.EventObjectID.Instance == s.device.GetObjectIdentifier().Instance {
+		targetObj = s.device
+	} else {
+		// 在设备的对象列表中查找
+		targetObj = s.device.FindObject(request.EventObjectID)
+	}
+
+	// 对象不存在
+	if targetObj == nil {
+		return s.createErrorResponse(invokeID, BACnetServiceConfirmedAcknowledgeAlarm,
+			ErrorClassObject, ErrorCodeObjectNotExist), nil
+	}
+
+	alarmObj, ok := targetObj.(model.Alarmable)
+	if !ok {
+		return s.createErrorResponse(invokeID, BACnetServiceConfirmedAcknowledgeAlarm,
+			ErrorClassObject, ErrorCodeObjectNotOfRequiredType), nil
+	}
+
+	// 确认转换，时间戳不匹配时返回invalid-time-stamp
+	if err := alarmObj.AcknowledgeAlarm(request.EventStateAcknowledged, request.TimeStamp); err != nil {
+		return s.createErrorResponse(invokeID, BACnetServiceConfirmedAcknowledgeAlarm,
+			ErrorClassService, ErrorCodeInvalidTimeStamp), nil
+	}
+
+	s.Logger().Debug("告警确认", "object", targetObj.GetObjectName(), "event_state", request.EventStateAcknowledged,
+		"process_id", request.AcknowledgingProcessID, "source", request.AcknowledgmentSource)
+
+	// 构建SimpleAck响应
+	response := encodeSimpleAck(invokeID, BACnetServiceConfirmedAcknowledgeAlarm)
+
+	return response, nil
+}
+
+// 文件读取请求结构
+type FileReadRequest struct {
+	FileID      model.ObjectIdentifier
+	StartOffset uint32
+	ReadCount   uint32
+}
+
+// 文件写入请求结构
+type FileWriteRequest struct {
+	FileID      model.ObjectIdentifier
+	StartOffset uint32
+	WriteData   []byte
+}
+
+// 解析文件读取请求：文件对象标识符、起始偏移量(4)、读取字节数(4)
+func parseFileReadRequest(data []byte) (FileReadRequest, error) {
+	var request FileReadRequest
+	r := encoding.NewReader(data)
+
+	var err error
+	if request.FileID, err = readObjectIdentifier(r); err != nil {
+		return request, err
+	}
+	if request.StartOffset, err = r.Uint32(); err != nil {
+		return request, fmt.Errorf("起始偏移量: %w", err)
+	}
+	if request.ReadCount, err = r.Uint32(); err != nil {
+		return request, fmt.Errorf("读取字节数: %w", err)
+	}
+	return request, expectEnd(r)
+}
+
+// 解析文件写入请求：文件对象标识符、起始偏移量(4)、数据长度(4)、数据
+func parseFileWriteRequest(data []byte) (FileWriteRequest, error) {
+	var request FileWriteRequest
+	r := encoding.NewReader(data)
+
+	var err error
+	if request.FileID, err = readObjectIdentifier(r); err != nil {
+		return request, err
+	}
+	if request.StartOffset, err = r.Uint32(); err != nil {
+		return request, fmt.Errorf("起始偏移量: %w", err)
+	}
+	dataLength, err := r.Uint32()
+	if err != nil {
+		return request, fmt.Errorf("数据长度: %w", err)
+	}
+	// 数据长度不能超出请求范围
+	if uint64(dataLength) > uint64(r.Len()) {
+		return request, fmt.Errorf("写入数据长度%d超出请求范围: %w", dataLength, encoding.ErrTruncated)
+	}
+	request.WriteData, _ = r.Bytes(int(dataLength))
+	return request, expectEnd(r)
+}
+
+// handleAtomicReadFile 处理文件读取请求
+func (s *BACnetServer) handleAtomicReadFile(data []byte, invokeID byte) ([]byte, error) {
+	// 解析文件读取请求
+	request, err := parseFileReadRequest(data)
+	if err != nil {
+		// 请求格式错误，按BACnet协议拒绝
+		return s.createRejectResponse(invokeID, rejectReasonFor(err)), nil
+	}
+
+	// 查找文件对象
+	fileObj := s.device.FindObject(request.FileID)
+	if fileObj == nil {
+		return s.createErrorResponse(invokeID, BACnetServiceConfirmedAtomicReadFile,
+			ErrorClassObject, ErrorCodeObjectNotExist), nil
+	}
+
+	// 类型断言为BACnetFile
+	bacFile, ok := fileObj.(*model.BACnetFile)
+	if !ok {
+		return s.createErrorResponse(invokeID, BACnetServiceConfirmedAtomicReadFile,
+			ErrorClassObject, ErrorCodeInvalidDataType), nil
+	}
+
+	// 读取文件数据
+	fileData, err := bacFile.ReadFile(request.StartOffset, request.ReadCount)
+	if err != nil {
+		return s.createErrorResponse(invokeID, BACnetServiceConfirmedAtomicReadFile,
+			ErrorClassFile, ErrorCodeFileAccessDenied), nil
+	}
+
+	// 构建ComplexAck响应
+	serviceData := []byte{
+		0x02,                            // 标记文件读取数据
+		0x04,                            // 起始偏移量长度
+		byte(request.StartOffset >> 24), // 起始偏移量
+		byte(request.StartOffset >> 16),
+		byte(request.StartOffset >> 8),
+		byte(request.StartOffset),
+		0x04,                      // 数据长度
+		byte(len(fileData) >> 24), // 数据长度值
+		byte(len(fileData) >> 16),
+		byte(len(fileData) >> 8),
+		byte(len(fileData)),
+	}
+
+	// 添加实际文件数据
+	response := encodeComplexAck(invokeID, BACnetServiceConfirmedAtomicReadFile, append(serviceData, fileData...))
+
+	s.logPacket("文件读取", "object", fileObj.GetObjectName(), "offset", request.StartOffset, "bytes", len(fileData))
+
+	return response, nil
+}
+
+// handleAtomicWriteFile 处理文件写入请求
+func (s *BACnetServer) handleAtomicWriteFile(ctx *RequestContext, data []byte, invokeID byte) ([]byte, error) {
+	// 解析文件写入请求
+	request, err := parseFileWriteRequest(data)
+	if err != nil {
+		// 请求格式错误，按BACnet协议拒绝
+		return s.createRejectResponse(invokeID, rejectReasonFor(err)), nil
+	}
+
+	// 查找文件对象
+	fileObj := s.device.FindObject(request.FileID)
+	if fileObj == nil {
+		return s.createErrorResponse(invokeID, BACnetServiceConfirmedAtomicWriteFile,
+			ErrorClassObject, ErrorCodeObjectNotExist), nil
+	}
+
+	// 类型断言为BACnetFile
+	bacFile, ok := fileObj.(*model.BACnetFile)
+	if !ok {
+		return s.createErrorResponse(invokeID, BACnetServiceConfirmedAtomicWriteFile,
+			ErrorClassObject, ErrorCodeInvalidDataType), nil
+	}
+
+	// 写入文件数据
+	err = bacFile.WriteFile(request.StartOffset, request.WriteData)
+	s.audit(ctx, model.AuditNotification{Operation: model.AuditOperationWrite, TargetObject: &request.FileID},
+		auditFileError(err))
+	if err != nil {
+		return s.createErrorResponse(invokeID, BACnetServiceConfirmedAtomicWriteFile,
+			ErrorClassFile, ErrorCodeFileAccessDenied), nil
+	}
+
+	// 构建SimpleAck响应
+	response := encodeSimpleAck(invokeID, BACnetServiceConfirmedAtomicWriteFile)
+
+	s.Logger().Debug("文件写入", "object", fileObj.GetObjectName(), "offset", request.StartOffset,
+		"bytes", len(request.WriteData), "size", len(bacFile.FileData))
+
+	return response, nil
+}
+
+// SubscribeCOVRequest SubscribeCOV请求，确认通知标志和有效期都缺少时为取消订阅
+type SubscribeCOVRequest struct {
+	SubscriberProcessID uint32                 `bacnet:"0"`
+	ObjectID            model.ObjectIdentifier `bacnet:"1"`
+	IssueConfirmedNotif *bool                  `bacnet:"2"`
+	Lifetime            *uint32                `bacnet:"3"` // 秒，为0时订阅永久有效
+}
+
+// Cancellation 判断请求是否为取消订阅
+func (r SubscribeCOVRequest) Cancellation() bool {
+	return r.IssueConfirmedNotif == nil && r.Lifetime == nil
+}
+
+// subscription 按请求创建订阅，缺少的参数取默认值：非确认通知，永久有效
+func (r SubscribeCOVRequest) subscription() model.COVSubscription {
+	subscription := model.COVSubscription{
+		SubscriberProcessID: r.SubscriberProcessID,
+		ObjectIdentifier:    r.ObjectID,
+	}
+	if r.IssueConfirmedNotif != nil {
+		subscription.IssueConfirmedCOVNotifications = *r.IssueConfirmedNotif
+	}
+	if r.Lifetime != nil {
+		subscription.Lifetime = *r.Lifetime
+	}
+	return subscription
+}
+
+// SubscribeCOVPropertyRequest SubscribeCOVProperty请求，订阅对象的一个属性
+type SubscribeCOVPropertyRequest struct {
+	SubscribeCOVRequest
+	Property     PropertyReference `bacnet:"4"`
+	COVIncrement *float32          `bacnet:"5"` // 订阅的COV增量，目前按对象的COV_Increment通知
+}
+
+// parseSubscribeCOVRequest 解析SubscribeCOV请求
+//
+//	SubscribeCOV-Request ::= SEQUENCE {
+//	  subscriberProcessIdentifier [0] Unsigned32,
+//	  monitoredObjectIdentifier   [1] BACnetObjectIdentifier,
+//	  issueConfirmedNotifications [2] BOOLEAN OPTIONAL,
+//	  lifetime                    [3] Unsigned OPTIONAL }
+func parseSubscribeCOVRequest(data []byte) (SubscribeCOVRequest, error) {
+	var request SubscribeCOVRequest
+	err := encoding.Unmarshal(data, &request)
+	return request, err
+}
+
+// parseSubscribeCOVPropertyRequest 解析SubscribeCOVProperty请求
+//
+//	SubscribeCOVProperty-Request ::= SEQUENCE {
+//	  subscriberProcessIdentifier [0] Unsigned32,
+//	  monitoredObjectIdentifier   [1] BACnetObjectIdentifier,
+//	  issueConfirmedNotifications [2] BOOLEAN OPTIONAL,
+//	  lifetime                    [3] Unsigned OPTIONAL,
+//	  monitoredPropertyIdentifier [4] BACnetPropertyReference,
+//	  covIncrement                [5] REAL OPTIONAL }
+func parseSubscribeCOVPropertyRequest(data []byte) (SubscribeCOVPropertyRequest, error) {
+	var request SubscribeCOVPropertyRequest
+	err := encoding.Unmarshal(data, &request)
+	return request, err
+}
+
+// PropertyValue BACnetPropertyValue，Value为value开始/结束标签之间的编码值
+type PropertyValue struct {
+	PropertyID model.PropertyIdentifier `bacnet:"0,enum"`
+	ArrayIndex *uint32                  `bacnet:"1"`
+	Value      []byte                   `bacnet:"2,raw"`
+	Priority   uint8                    `bacnet:"3,optional"`
+}
+
+// COVNotification COV通知的参数，确认和非确认通知相同
+//
+//	COVNotification-Request ::= SEQUENCE {
+//	  subscriberProcessIdentifier [0] Unsigned32,
+//	  initiatingDeviceIdentifier  [1] BACnetObjectIdentifier,
+//	  monitoredObjectIdentifier   [2] BACnetObjectIdentifier,
+//	  timeRemaining               [3] Unsigned,
+//	  listOfValues                [4] SEQUENCE OF BACnetPropertyValue }
+type COVNotification struct {
+	SubscriberProcessID uint32                 `bacnet:"0"`
+	InitiatingDevice    model.ObjectIdentifier `bacnet:"1"`
+	MonitoredObject     model.ObjectIdentifier `bacnet:"2"`
+	TimeRemaining       uint32                 `bacnet:"3"` // 秒，永久有效的订阅为0
+	Values              []PropertyValue        `bacnet:"4"`
+}
+
+// 全局原子计数器，用于生成唯一的订阅ID
+var subscriptionCounter uint32
+
+// 生成唯一的订阅ID
+func generateSubscriptionID(now time.Time) uint32 {
+	// 结合时间戳的高32位和原子递增计数器，减少冲突可能性
+	timestamp := uint32(now.UnixNano() >> 32)
+	counter := atomic.AddUint32(&subscriptionCounter, 1)
+	// 通过位运算组合时间戳和计数器，生成更唯一的ID
+	return (timestamp & 0xFFFF0000) | (counter & 0x0000FFFF)
+}
+
+// removeSubscriberCOV 移除同一订阅者（客户端地址、远程网络地址和进程ID）对该对象同一组属性的订阅，返回被移除订阅的ID。
+// 订阅者重复订阅时更新原有的订阅，而不是再增加一个
+func removeSubscriberCOV(obj model.COVSubscribable, ctx *RequestContext, processID uint32, properties []model.PropertyIdentifier) (uint32, bool) {
+	subscribable, ok := obj.(interface {
+		COVSubscriptions() []model.COVSubscription
+	})
+	if !ok {
+		return 0, false
+	}
+	for _, sub := range subscribable.COVSubscriptions() {
+		if sub.ClientAddress == ctx.ClientAddr && sameAddress(sub.RemoteAddress, ctx.Source) &&
+			sub.SubscriberProcessID == processID && slices.Equal(sub.MonitoredProperties, properties) {
+			obj.RemoveCOVSubscription(sub.SubscriptionID)
+			return sub.SubscriptionID, true
+		}
+	}
+	return 0, false
+}
+
+// sameAddress 判断两个远程网络地址是否相同，nil表示本地网络
+func sameAddress(a, b *model.Address) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Network == b.Network && bytes.Equal(a.MAC, b.MAC)
+}
+
+// handleSubscribeCOV 处理订阅变化通知请求
+func (s *BACnetServer) handleSubscribeCOV(ctx *RequestContext, data []byte, invokeID byte) ([]byte, error) {
+	// 解析订阅请求
+	request, err := parseSubscribeCOVRequest(data)
+	if err != nil {
+		// 请求格式错误，按BACnet协议拒绝
+		return s.createRejectResponse(invokeID, rejectReasonFor(err)), nil
+	}
+	return s.subscribeCOV(ctx, invokeID, BACnetServiceConfirmedSubscribeCOV, request.subscription(), request.Cancellation(), nil)
+}
+
+// handleSubscribeCOVProperty 处理属性订阅变化通知请求
+func (s *BACnetServer) handleSubscribeCOVProperty(ctx *RequestContext, data []byte, invokeID byte) ([]byte, error) {
+	// 解析属性订阅请求
+	request, err := parseSubscribeCOVPropertyRequest(data)
+	if err != nil {
+		// 请求格式错误，按BACnet协议拒绝
+		return s.createRejectResponse(invokeID, rejectReasonFor(err)), nil
+	}
+	return s.subscribeCOV(ctx, invokeID, BACnetServiceConfirmedSubscribeCOVProperty, request.subscription(), request.Cancellation(),
+		[]model.PropertyIdentifier{request.Property.PropertyID})
+}
+
+// subscribeCOV 创建、更新或取消订阅者对对象的COV订阅，成功时应答SimpleAck。
+// properties为SubscribeCOVProperty监控的属性，为空时监控对象的全部属性
+func (s *BACnetServer) subscribeCOV(ctx *RequestContext, invokeID, service byte, subscription model.COVSubscription, cancel bool, properties []model.PropertyIdentifier) ([]byte, error) {
+	// 查找目标对象
+	targetObj := s.device.FindObject(subscription.ObjectIdentifier)
+	if targetObj == nil {
+		return s.createErrorResponse(invokeID, service, ErrorClassObject, ErrorCodeObjectNotExist), nil
+	}
+
+	// 类型断言为支持COV订阅的对象
+	bacObj, ok := targetObj.(model.COVSubscribable)
+	if !ok {
+		return s.createErrorResponse(invokeID, service, ErrorClassCov, ErrorCodeCovObject), nil
+	}
+
+	// 检查属性是否存在
+	for _, prop := range properties {
+		if _, err := targetObj.ReadProperty(prop); err != nil {
+			return s.createErrorResponse(invokeID, service, ErrorClassCov, ErrorCodeCovProperty), nil
+		}
+	}
+
+	if cancel {
+		// 取消不存在的订阅同样成功
+		if id, removed := removeSubscriberCOV(bacObj, ctx, subscription.SubscriberProcessID, properties); removed {
+			s.Logger().Debug("取消COV订阅", "peer", ctx.ClientAddr, "subscription", id, "object", targetObj.GetObjectName())
+		}
+		return encodeSimpleAck(invokeID, service), nil
+	}
+
+	subscription.SubscriptionID = generateSubscriptionID(s.now())
+	subscription.DeviceID = s.device.GetObjectIdentifier().Instance
+	subscription.MonitoredProperties = append([]model.PropertyIdentifier{}, properties...) // 空列表表示监控所有属性
+	subscription.Timestamp = s.now()
+	subscription.ClientAddress = ctx.ClientAddr
+	subscription.RemoteAddress = ctx.Source
+
+	// 经钩子确认后添加订阅，钩子收到第一个监控的属性
+	monitored := model.PropertyIdentifierPresentValue
+	if len(properties) > 0 {
+		monitored = properties[0]
+	}
+	subscription, err := s.subscribe(ctx, targetObj, monitored, subscription)
+	if err != nil {
+		errorClass, errorCode := writeErrorCode(err)
+		return s.createErrorResponse(invokeID, service, errorClass, errorCode), nil
+	}
+	// 同一订阅者的订阅被替换，保留原来的订阅ID
+	if id, replaced := removeSubscriberCOV(bacObj, ctx, subscription.SubscriberProcessID, properties); replaced {
+		subscription.SubscriptionID = id
+	}
+	bacObj.AddCOVSubscription(subscription)
+
+	s.Logger().Debug("创建COV订阅", "peer", ctx.ClientAddr, "subscription", subscription.SubscriptionID,
+		"process", subscription.SubscriberProcessID, "object", targetObj.GetObjectName(),
+		"lifetime", subscription.Lifetime, "properties", properties)
+
+	return encodeSimpleAck(invokeID, service), nil
+}
+
+// whoIsMatches 判断Who-Is请求的设备实例范围是否包含本设备，未带范围时匹配所有设备，
+// 范围编码错误时不应答
+//
+//	Who-Is-Request ::= SEQUENCE {
+//	  deviceInstanceRangeLowLimit  [0] Unsigned (0..4194303) OPTIONAL, -- 须与高限同时出现
+//	  deviceInstanceRangeHighLimit [1] Unsigned (0..4194303) OPTIONAL }
+func (s *BACnetServer) whoIsMatches(data []byte) bool {
+	if s.device == nil || len(data) == 0 {
+		return true
+	}
+	d := encoding.NewDecoder(data)
+	low, err := d.ContextUnsigned(0)
+	if err != nil {
+		return false
+	}
+	high, err := d.ContextUnsigned(1)
+	if err != nil {
+		return false
+	}
+	instance := s.device.GetObjectIdentifier().Instance
+	return instance >= low && instance <= high
+}
+
+// createIAmResponse 创建I-Am响应消息
+func (s *BACnetServer) createIAmResponse() []byte {
+	if s.device == nil {
+		return []byte{}
+	}
+	return encodeBVLC(BVLCOriginalUnicastNPDU, append([]byte{0x01, 0x00}, s.encodeIAm()...))
+}
+
+// encodeIAm 编码I-Am APDU
+//
+//	I-Am-Request ::= SEQUENCE {
+//	  iAmDeviceIdentifier   BACnetObjectIdentifier,
+//	  maxAPDULengthAccepted Unsigned,
+//	  segmentationSupported BACnetSegmentation,
+//	  vendorID              Unsigned16 }
+func (s *BACnetServer) encodeIAm() []byte {
+	maxAPDULengthAccepted, ok := s.device.Properties[model.PropertyIdentifierMaxAPDULengthAccepted].(uint32)
+	if !ok {
+		maxAPDULengthAccepted = model.DefaultMaxAPDULengthAccepted
+	}
+	vendorID, ok := s.device.Properties[model.PropertyIdentifierVendorIdentifier].(uint32)
+	if !ok {
+		vendorID = model.DefaultVendorIdentifier
+	}
+	apdu := []byte{BACnetAPDUTypeUnconfirmedServiceRequest << 4, BACnetServiceUnconfirmedIAm}
+	apdu = append(apdu, encoding.EncodeObjectIdentifier(s.device.GetObjectIdentifier())...)
+	apdu = append(apdu, encoding.EncodeUnsigned(maxAPDULengthAccepted)...)
+	apdu = append(apdu, encoding.EncodeEnumerated(segmentedTransmit)...)
+	return append(apdu, encoding.EncodeUnsigned(vendorID)...)
+}
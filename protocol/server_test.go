@@ -0,0 +1,4919 @@
+package protocol
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iotzf/bacnet-server/encoding"
+	"github.com/iotzf/bacnet-server/model"
+	"github.com/iotzf/bacnet-server/simulation"
+)
+
+func TestBACnetServer_processBACnetMessage(t *testing.T) {
+	type fields struct {
+		device    *model.Device
+		transport Transport
+	}
+	type args struct {
+		data []byte
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		want    []byte
+		wantErr bool
+	}{
+		// TODO: Add test cases.
+		{
+			name: "who is 81 0b 00 08 01 00 10 08",
+			fields: fields{
+				device:    nil,
+				transport: nil,
+			},
+			args: args{
+				data: []byte{0x81, 0x0b, 0x00, 0x08, 0x01, 0x00, 0x10, 0x08},
+			},
+			want:    []byte{},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &BACnetServer{
+				device:    tt.fields.device,
+				transport: tt.fields.transport,
+			}
+			got, err := s.processBACnetMessage(&RequestContext{}, tt.args.data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("processBACnetMessage() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("processBACnetMessage() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAcknowledgeAlarm(t *testing.T) {
+	// 转换时间带有百分之一秒以下的部分，请求中的时间戳只精确到百分之一秒
+	clock := model.NewFakeClock(time.Date(2024, 3, 1, 9, 30, 15, 123456789, time.Local))
+
+	device := model.NewDevice(1, "Test Device", "")
+	device.SetClock(clock)
+	sensor := model.NewAnalogInput(1, "Zone Temp", model.UnitsDegreesCelsius)
+	device.AddObject(sensor)
+	s := &BACnetServer{device: device}
+	sensor.GenerateEvent(model.EventStateHighLimit, "too warm")
+	if acked := sensor.GetAckedTransitions(); acked&(1<<model.TransitionIndexToOffNormal) != 0 {
+		t.Fatalf("Acked_Transitions = %03b after the event, want to-offnormal unacknowledged", acked)
+	}
+
+	acknowledge := func(timeStamp time.Time) []byte {
+		t.Helper()
+		payload := append(encoding.EncodeContextUnsigned(0, 7), encoding.EncodeContextObjectIdentifier(1, sensor.GetObjectIdentifier())...)
+		payload = append(payload, encoding.EncodeContextEnumerated(2, uint32(model.EventStateHighLimit))...)
+		payload = append(payload, encodeTimeStamp(3, timeStamp)...)
+		payload = append(payload, encoding.EncodeContextCharacterString(4, "operator")...)
+		payload = append(payload, encodeTimeStamp(5, clock.Now())...)
+		response, err := s.handleBACnetAPDU(&RequestContext{}, append([]byte{BACnetAPDUTypeConfirmedServiceRequest << 4, 0x05, 3, BACnetServiceConfirmedAcknowledgeAlarm}, payload...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+
+	// 时间戳与记录的转换时间不一致时返回invalid-time-stamp，转换仍未确认
+	want := s.createErrorResponse(3, BACnetServiceConfirmedAcknowledgeAlarm, ErrorClassService, ErrorCodeInvalidTimeStamp)
+	if response := acknowledge(clock.Now().Add(time.Second)); !bytes.Equal(response, want) {
+		t.Errorf("acknowledge with wrong time stamp = % X, want % X", response, want)
+	}
+	if acked := sensor.GetAckedTransitions(); acked&(1<<model.TransitionIndexToOffNormal) != 0 {
+		t.Errorf("Acked_Transitions = %03b after a rejected acknowledgement", acked)
+	}
+
+	// 时间戳在百分之一秒精度内一致时确认成功，Acked_Transitions的to-offnormal位置位
+	if response := acknowledge(clock.Now().Truncate(10 * time.Millisecond)); !bytes.Equal(response, encodeSimpleAck(3, BACnetServiceConfirmedAcknowledgeAlarm)) {
+		t.Fatalf("acknowledge = % X, want SimpleAck", response)
+	}
+	if acked := sensor.GetAckedTransitions(); acked != model.AckedTransitionsAll {
+		t.Errorf("Acked_Transitions = %03b, want all acknowledged", acked)
+	}
+}
+
+// TestAcknowledgeAlarmStandardEncoding 以标准编码的AcknowledgeAlarm请求（服务选择码0）确认告警
+func TestAcknowledgeAlarmStandardEncoding(t *testing.T) {
+	clock := model.NewFakeClock(time.Date(2024, 3, 1, 9, 30, 15, 120000000, time.Local))
+
+	device := model.NewDevice(1, "Test Device", "")
+	device.SetClock(clock)
+	sensor := model.NewAnalogInput(1, "Zone Temp", model.UnitsDegreesCelsius)
+	device.AddObject(sensor)
+	s := &BACnetServer{device: device}
+	sensor.GenerateEvent(model.EventStateHighLimit, "too warm")
+
+	// 进程ID 7，Analog-Input,1，high-limit，转换时间2024-03-01 09:30:15.12，确认来源"operator"
+	apdu, _ := hex.DecodeString("00050100" + "0907" + "1c00000001" + "2903" +
+		"3e2ea47c030105b4091e0f0c2f3f" + "4d09006f70657261746f72" +
+		"5e2ea47c030105b4091e0f0c2f5f")
+	response, err := s.handleBACnetAPDU(&RequestContext{}, apdu)
+	if want := []byte{BACnetAPDUTypeSimpleAck << 4, 0x01, 0x00}; err != nil || !bytes.Equal(response, want) {
+		t.Fatalf("response = % X, %v; want % X", response, err, want)
+	}
+	if acked := sensor.GetAckedTransitions(); acked != model.AckedTransitionsAll {
+		t.Errorf("Acked_Transitions = %03b, want all acknowledged", acked)
+	}
+}
+
+func TestSelectLogRecords(t *testing.T) {
+	buffer := model.NewLogBuffer(10)
+	for i := 0; i < 5; i++ {
+		buffer.Append(time.Unix(int64(100+i), 0), model.LogStatus(0))
+	}
+
+	tests := []struct {
+		name      string
+		request   ReadRangeRequest
+		wantSeqs  []uint32
+		wantFlags [3]bool
+	}{
+		{"all", ReadRangeRequest{RangeType: ReadRangeAll}, []uint32{1, 2, 3, 4, 5}, [3]bool{true, true, false}},
+		{"by position forward", ReadRangeRequest{RangeType: ReadRangeByPosition, Reference: 2, Count: 2}, []uint32{2, 3}, [3]bool{false, false, true}},
+		{"by position backward", ReadRangeRequest{RangeType: ReadRangeByPosition, Reference: 2, Count: -5}, []uint32{1, 2}, [3]bool{true, false, false}},
+		{"by sequence number", ReadRangeRequest{RangeType: ReadRangeBySequenceNumber, Reference: 4, Count: 10}, []uint32{4, 5}, [3]bool{false, true, false}},
+		{"by time after", ReadRangeRequest{RangeType: ReadRangeByTime, ReferenceTime: time.Unix(102, 0), Count: 1}, []uint32{4}, [3]bool{false, false, true}},
+		{"by time before", ReadRangeRequest{RangeType: ReadRangeByTime, ReferenceTime: time.Unix(102, 0), Count: -1}, []uint32{2}, [3]bool{false, false, true}},
+		{"unknown sequence number", ReadRangeRequest{RangeType: ReadRangeBySequenceNumber, Reference: 99, Count: 1}, nil, [3]bool{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, flags := selectLogRecords(buffer.Records, tt.request)
+			var seqs []uint32
+			for _, r := range records {
+				seqs = append(seqs, r.SequenceNumber)
+			}
+			if !reflect.DeepEqual(seqs, tt.wantSeqs) || flags != tt.wantFlags {
+				t.Errorf("selectLogRecords() = %v %v, want %v %v", seqs, flags, tt.wantSeqs, tt.wantFlags)
+			}
+		})
+	}
+}
+
+func TestEncodeLogDatumValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  interface{}
+		offset uint8
+		want   []byte
+	}{
+		{"real", float32(1), 0, []byte{0x2C, 0x3F, 0x80, 0x00, 0x00}},
+		{"real multiple", float32(1), 1, []byte{0x1C, 0x3F, 0x80, 0x00, 0x00}},
+		{"unsigned", uint32(5), 0, []byte{0x49, 0x05}},
+		{"unsigned multiple", uint32(5), 1, []byte{0x39, 0x05}},
+		{"boolean multiple", true, 1, []byte{0x09, 0x01}},
+		{"null", nil, 0, []byte{0x78}},
+		{"null multiple", nil, 1, []byte{0x68}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := encodeLogDatumValue(tt.value, tt.offset); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("encodeLogDatumValue() = % X, want % X", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrendLogStartStopTime(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	trendLog := model.NewTrendLog(1, "Trend", 10)
+	multiple := model.NewTrendLogMultiple(1, "Trends", 10)
+	device.AddObject(trendLog)
+	device.AddObject(multiple)
+	s := &BACnetServer{device: device}
+
+	start := time.Date(2024, 3, 1, 8, 0, 0, 0, time.Local)
+	for _, obj := range []model.Object{trendLog, multiple} {
+		// Start_Time以BACnetDateTime（Date后接Time）写入和读取，不带BACnetTimeStamp的[2]标签
+		value := encoding.EncodeDateTime(start)
+		got, _ := s.handleWriteProperty(nil, encodeWritePropertyRequest(obj.GetObjectIdentifier(), model.PropertyIdentifierStartTime, value, 16), 1)
+		if len(got) == 0 || got[0] != BACnetAPDUTypeSimpleAck<<4 {
+			t.Errorf("%v write Start_Time: got % X", obj.GetObjectIdentifier(), got)
+		}
+		response, _ := s.handleReadProperty(nil, EncodeReadPropertyRequest(obj.GetObjectIdentifier(), model.PropertyIdentifierStartTime, nil), 2)
+		if got := readPropertyAckValue(t, response); !bytes.Equal(got, value) {
+			t.Errorf("%v Start_Time = % X, want % X", obj.GetObjectIdentifier(), got, value)
+		}
+
+		// 未指定的Stop_Time编码为全通配符，写入全通配符清除限制
+		response, _ = s.handleReadProperty(nil, EncodeReadPropertyRequest(obj.GetObjectIdentifier(), model.PropertyIdentifierStopTime, nil), 3)
+		unspecified := encoding.EncodeDateTime(time.Time{})
+		if got := readPropertyAckValue(t, response); !bytes.Equal(got, unspecified) {
+			t.Errorf("%v Stop_Time = % X, want % X", obj.GetObjectIdentifier(), got, unspecified)
+		}
+		s.handleWriteProperty(nil, encodeWritePropertyRequest(obj.GetObjectIdentifier(), model.PropertyIdentifierStartTime, unspecified, 16), 4)
+		if value, _ := obj.ReadProperty(model.PropertyIdentifierStartTime); !value.(model.DateTime).IsZero() {
+			t.Errorf("%v Start_Time after unspecified write = %v", obj.GetObjectIdentifier(), value)
+		}
+
+		// 单独的Date或时间戳不是BACnetDateTime
+		for _, bad := range [][]byte{encoding.EncodeDate(encoding.NewDate(start)), encoding.EncodeTimeStamp(model.TimeStamp{DateTime: &start})} {
+			got, _ := s.handleWriteProperty(nil, encodeWritePropertyRequest(obj.GetObjectIdentifier(), model.PropertyIdentifierStartTime, bad, 16), 5)
+			if len(got) == 0 || got[0] != BACnetAPDUTypeError<<4 {
+				t.Errorf("%v write Start_Time % X: got % X, want error", obj.GetObjectIdentifier(), bad, got)
+			}
+		}
+	}
+}
+
+func TestWriteGlobalGroupMembers(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	sensor := model.NewAnalogValue(1, "Sensor", model.UnitsDegreesCelsius)
+	group := model.NewGlobalGroup(1, "Zone")
+	device.AddObject(sensor)
+	device.AddObject(group)
+	s := &BACnetServer{device: device}
+
+	write := func(deviceID model.ObjectIdentifier) []byte {
+		member := model.DeviceObjectPropertyReference{
+			ObjectIdentifier: sensor.GetObjectIdentifier(), PropertyIdentifier: model.PropertyIdentifierPresentValue, DeviceIdentifier: &deviceID,
+		}
+		response, _ := s.handleWriteProperty(nil, encodeWritePropertyRequest(group.GetObjectIdentifier(), model.PropertyIdentifierGroupMembers, encoding.EncodeDeviceObjectPropertyReference(member), 16), 1)
+		return response
+	}
+	if got := write(device.GetObjectIdentifier()); got[0] != BACnetAPDUTypeSimpleAck<<4 || len(group.GroupMembers) != 1 {
+		t.Errorf("write local member: got % X, members %+v", got, group.GroupMembers)
+	}
+	// 不支持引用其他设备的成员
+	got := write(model.ObjectIdentifier{Type: model.ObjectTypeDevice, Instance: 2})
+	if want := s.createErrorResponse(1, BACnetServiceConfirmedWriteProperty, ErrorClassProperty, ErrorCodeOptionalFunctionalityNotSupported); !bytes.Equal(got, want) {
+		t.Errorf("write remote member: got % X, want % X", got, want)
+	}
+}
+
+func TestHandleWritePropertyMultiStateRange(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	mode := model.NewMultiStateValue(1, "Mode", []string{"Off", "Heat", "Cool"})
+	device.AddObject(mode)
+	s := &BACnetServer{device: device}
+
+	request := func(state byte) []byte {
+		return encodeWritePropertyRequest(mode.GetObjectIdentifier(), model.PropertyIdentifierPresentValue, encoding.EncodeUnsigned(uint32(state)), 16)
+	}
+
+	got, _ := s.handleWriteProperty(nil, request(4), 1)
+	want := s.createErrorResponse(1, BACnetServiceConfirmedWriteProperty, ErrorClassProperty, ErrorCodeValueOutOfRange)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("write state 4: got % X, want % X", got, want)
+	}
+
+	s.handleWriteProperty(nil, request(3), 1)
+	if text := mode.CurrentStateText(); text != "Cool" {
+		t.Errorf("write state 3: state text = %q, want %q", text, "Cool")
+	}
+}
+
+func TestHandleLifeSafetyOperationReset(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	detector := model.NewLifeSafetyPoint(1, "Smoke Detector")
+	device.AddObject(detector)
+	s := &BACnetServer{device: device}
+
+	detector.SetTrackingValue(model.LifeSafetyStateAlarm)
+	detector.SetTrackingValue(model.LifeSafetyStateQuiet)
+	if detector.State() != model.LifeSafetyStateAlarm {
+		t.Fatalf("alarm not latched: state = %d", detector.State())
+	}
+
+	data := encoding.EncodeContextUnsigned(0, 1)
+	data = append(data, encoding.EncodeContextCharacterString(1, "panel")...)
+	data = append(data, encoding.EncodeContextEnumerated(2, uint32(model.LifeSafetyOperationReset))...)
+	data = append(data, encoding.EncodeContextObjectIdentifier(3, detector.GetObjectIdentifier())...)
+
+	got, _ := s.handleLifeSafetyOperation(data, 7)
+	if want := encodeSimpleAck(7, BACnetServiceConfirmedLifeSafetyOperation); !reflect.DeepEqual(got, want) {
+		t.Errorf("response = % X, want % X", got, want)
+	}
+	if detector.State() != model.LifeSafetyStateQuiet {
+		t.Errorf("state after reset = %d, want quiet", detector.State())
+	}
+}
+
+func TestHandleLifeSafetyOperation(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	detector := model.NewLifeSafetyPoint(1, "Smoke Detector")
+	zone := model.NewLifeSafetyZone(1, "Floor 1")
+	sensor := model.NewAnalogInput(1, "Temperature", model.UnitsDegreesCelsius)
+	for _, obj := range []model.Object{detector, zone, sensor} {
+		device.AddObject(obj)
+	}
+	s := &BACnetServer{device: device}
+
+	request := func(operation model.LifeSafetyOperation, target *model.ObjectIdentifier) []byte {
+		data := encoding.EncodeContextUnsigned(0, 1)
+		data = append(data, encoding.EncodeContextCharacterString(1, "panel")...)
+		data = append(data, encoding.EncodeContextEnumerated(2, uint32(operation))...)
+		if target != nil {
+			data = append(data, encoding.EncodeContextObjectIdentifier(3, *target)...)
+		}
+		return data
+	}
+	detectorID, sensorID := detector.GetObjectIdentifier(), sensor.GetObjectIdentifier()
+	missing := model.ObjectIdentifier{Type: model.ObjectTypeLifeSafetyPoint, Instance: 9}
+
+	// 消音和取消消音作用于指定对象，不指定对象时作用于所有生命安全对象
+	detector.SetTrackingValue(model.LifeSafetyStateAlarm)
+	zone.SetTrackingValue(model.LifeSafetyStateAlarm)
+	s.handleLifeSafetyOperation(request(model.LifeSafetyOperationSilenceAudible, &detectorID), 1)
+	if detector.Silenced != model.SilencedStateAudibleSilenced || zone.Silenced != model.SilencedStateUnsilenced {
+		t.Errorf("silence-audible: detector %d, zone %d", detector.Silenced, zone.Silenced)
+	}
+	s.handleLifeSafetyOperation(request(model.LifeSafetyOperationSilence, nil), 2)
+	if detector.Silenced != model.SilencedStateAllSilenced || zone.Silenced != model.SilencedStateAllSilenced {
+		t.Errorf("silence all: detector %d, zone %d", detector.Silenced, zone.Silenced)
+	}
+	response, _ := s.handleReadProperty(nil, EncodeReadPropertyRequest(detectorID, model.PropertyIdentifierOperationExpected, nil), 3)
+	if got := readPropertyAckValue(t, response); !bytes.Equal(got, encoding.EncodeEnumerated(uint32(model.LifeSafetyOperationReset))) {
+		t.Errorf("Operation_Expected after silence = % X, want reset", got)
+	}
+	s.handleLifeSafetyOperation(request(model.LifeSafetyOperationUnsilence, &detectorID), 4)
+	if detector.Silenced != model.SilencedStateUnsilenced || zone.Silenced != model.SilencedStateAllSilenced {
+		t.Errorf("unsilence: detector %d, zone %d", detector.Silenced, zone.Silenced)
+	}
+
+	// 未知操作、不存在的对象和其他类型的对象返回错误
+	errorTests := []struct {
+		name      string
+		operation model.LifeSafetyOperation
+		target    *model.ObjectIdentifier
+		class     byte
+		code      byte
+	}{
+		{"unknown operation", model.LifeSafetyOperationUnsilenceVisual + 1, &detectorID, ErrorClassService, ErrorCodeValueOutOfRange},
+		{"missing object", model.LifeSafetyOperationSilence, &missing, ErrorClassObject, ErrorCodeObjectNotExist},
+		{"not a life safety object", model.LifeSafetyOperationSilence, &sensorID, ErrorClassObject, ErrorCodeObjectNotOfRequiredType},
+	}
+	for _, tt := range errorTests {
+		got, _ := s.handleLifeSafetyOperation(request(tt.operation, tt.target), 5)
+		if want := s.createErrorResponse(5, BACnetServiceConfirmedLifeSafetyOperation, tt.class, tt.code); !bytes.Equal(got, want) {
+			t.Errorf("%s: got % X, want % X", tt.name, got, want)
+		}
+	}
+	if detector.Silenced != model.SilencedStateUnsilenced {
+		t.Errorf("rejected operation changed Silenced to %d", detector.Silenced)
+	}
+
+	// Mode只接受Accepted_Modes中的值，Operation_Expected只读
+	write := func(prop model.PropertyIdentifier, value uint32) []byte {
+		got, _ := s.handleWriteProperty(nil, encodeWritePropertyRequest(detectorID, prop, encoding.EncodeEnumerated(value), 16), 6)
+		return got
+	}
+	if got := write(model.PropertyIdentifierMode, uint32(model.LifeSafetyModeTest)); got[0] != BACnetAPDUTypeSimpleAck<<4 || detector.Mode != model.LifeSafetyModeTest {
+		t.Errorf("write Mode test: got % X, Mode = %d", got, detector.Mode)
+	}
+	if got := write(model.PropertyIdentifierMode, uint32(model.LifeSafetyModeArmed)); !bytes.Equal(got, s.createErrorResponse(6, BACnetServiceConfirmedWriteProperty, ErrorClassProperty, ErrorCodeValueOutOfRange)) {
+		t.Errorf("write Mode armed: got % X, want value-out-of-range", got)
+	}
+	if got := write(model.PropertyIdentifierOperationExpected, uint32(model.LifeSafetyOperationNone)); got[0] != BACnetAPDUTypeError<<4 {
+		t.Errorf("write Operation_Expected: got % X, want error", got)
+	}
+}
+
+func TestHandleWritePropertyOutOfService(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	door := model.NewBinaryInput(1, "Door Contact")
+	device.AddObject(door)
+	s := &BACnetServer{device: device}
+
+	request := func(property model.PropertyIdentifier, value bool) []byte {
+		// Present_Value为BACnetBinaryPV枚举，Out_Of_Service为布尔
+		encoded := encoding.EncodeBoolean(value)
+		if property == model.PropertyIdentifierPresentValue {
+			encoded = encoding.EncodeEnumerated(0)
+			if value {
+				encoded = encoding.EncodeEnumerated(1)
+			}
+		}
+		return encodeWritePropertyRequest(door.GetObjectIdentifier(), property, encoded, 16)
+	}
+
+	got, _ := s.handleWriteProperty(nil, request(model.PropertyIdentifierPresentValue, true), 1)
+	want := s.createErrorResponse(1, BACnetServiceConfirmedWriteProperty, ErrorClassProperty, ErrorCodeWriteAccessDenied)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("write in service: got % X, want % X", got, want)
+	}
+
+	s.handleWriteProperty(nil, request(model.PropertyIdentifierOutOfService, true), 1)
+	s.handleWriteProperty(nil, request(model.PropertyIdentifierPresentValue, true), 1)
+	door.UpdatePresentValue(false)
+	if !door.Active() {
+		t.Errorf("driver update overrode out of service value")
+	}
+	if flags := door.GetStatusFlags(); flags&model.StatusFlagOutOfService == 0 {
+		t.Errorf("status flags = %04b, want OUT_OF_SERVICE", flags)
+	}
+
+	s.handleWriteProperty(nil, request(model.PropertyIdentifierOutOfService, false), 1)
+	if door.Active() || door.GetStatusFlags() != 0 {
+		t.Errorf("back in service: active = %v, flags = %04b", door.Active(), door.GetStatusFlags())
+	}
+}
+
+func TestHandleWritePropertyRelinquish(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	valve := model.NewAnalogOutput(1, "Valve", model.UnitsPercent)
+	device.AddObject(valve)
+	s := &BACnetServer{device: device}
+
+	request := func(priority byte, value interface{}) []byte {
+		return encodeWritePropertyRequest(valve.GetObjectIdentifier(), model.PropertyIdentifierPresentValue, encodeBACnetValue(value), priority)
+	}
+
+	s.handleWriteProperty(nil, request(8, float32(50)), 1)
+	s.handleWriteProperty(nil, request(16, float32(10)), 1)
+	if valve.Value() != 50 {
+		t.Errorf("present value = %v, want 50", valve.Value())
+	}
+	array, _ := valve.ReadProperty(model.PropertyIdentifierPriorityArray)
+	want := model.PriorityArray{7: float32(50), 15: float32(10)}
+	if !reflect.DeepEqual(array, want) {
+		t.Errorf("priority array = %v, want %v", array, want)
+	}
+
+	s.handleWriteProperty(nil, request(8, nil), 1)
+	if valve.Value() != 10 {
+		t.Errorf("after relinquish 8: present value = %v, want 10", valve.Value())
+	}
+	valve.WriteProperty(model.PropertyIdentifierRelinquishDefault, float32(5))
+	s.handleWriteProperty(nil, request(16, nil), 1)
+	if valve.Value() != 5 {
+		t.Errorf("after relinquish 16: present value = %v, want relinquish default 5", valve.Value())
+	}
+
+	got, _ := s.handleWriteProperty(nil, request(0, float32(1)), 1)
+	if got[0] != BACnetAPDUTypeError<<4 {
+		t.Errorf("priority 0 accepted: % X", got)
+	}
+}
+
+func TestCommandStatePersistence(t *testing.T) {
+	newDevice := func() (*model.Device, *model.Analog) {
+		device := model.NewDevice(1, "Test Device", "")
+		valve := model.NewAnalogOutput(1, "Valve", model.UnitsPercent)
+		device.AddObject(valve)
+		return device, valve
+	}
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	device, valve := newDevice()
+	valve.WriteProperty(model.PropertyIdentifierRelinquishDefault, float32(5))
+	valve.WritePropertyWithPriority(model.PropertyIdentifierPresentValue, float32(80), 8)
+	s := &BACnetServer{device: device, stateFile: path}
+	s.saveCommandState()
+
+	restored, restoredValve := newDevice()
+	if err := model.LoadCommandState(restored, path); err != nil {
+		t.Fatalf("LoadCommandState() error = %v", err)
+	}
+	if restoredValve.Value() != 80 {
+		t.Errorf("restored present value = %v, want 80", restoredValve.Value())
+	}
+	want, _ := valve.ReadProperty(model.PropertyIdentifierPriorityArray)
+	if got, _ := restoredValve.ReadProperty(model.PropertyIdentifierPriorityArray); !reflect.DeepEqual(got, want) {
+		t.Errorf("restored priority array = %v, want %v", got, want)
+	}
+	restoredValve.WritePropertyWithPriority(model.PropertyIdentifierPresentValue, nil, 8)
+	if restoredValve.Value() != 5 {
+		t.Errorf("restored relinquish default = %v, want 5", restoredValve.Value())
+	}
+}
+
+func TestSnapshotPersistence(t *testing.T) {
+	start := time.Date(2024, 1, 15, 8, 0, 0, 0, time.UTC)
+	newDevice := func() *model.Device {
+		device := model.NewDevice(1, "Test Device", "")
+		device.AddObject(model.NewAnalogInput(1, "Zone Temp", model.UnitsDegreesCelsius))
+		device.AddObject(model.NewAnalogOutput(1, "Valve", model.UnitsPercent))
+		device.AddObject(model.NewMultiStateValue(1, "Mode", []string{"Off", "Heat", "Cool"}))
+		device.AddObject(model.NewSchedule(1, "Occupancy", 16.0))
+		device.AddObject(model.NewTrendLog(1, "Zone Temp Trend", 3))
+		device.AddObject(model.NewTrendLogMultiple(1, "Climate Trend", 10))
+		device.AddObject(model.NewEventLog(1, "Events", 10))
+		return device
+	}
+	find := func(device *model.Device, objType model.ObjectType) model.Object {
+		return device.FindObject(model.ObjectIdentifier{Type: objType, Instance: 1})
+	}
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	device := newDevice()
+	find(device, model.ObjectTypeAnalogInput).(model.PresentValueUpdater).UpdatePresentValue(21.5)
+	find(device, model.ObjectTypeAnalogOutput).(model.CommandableObject).WritePropertyWithPriority(model.PropertyIdentifierPresentValue, float32(40), 8)
+	find(device, model.ObjectTypeMultiStateValue).WriteProperty(model.PropertyIdentifierPresentValue, uint32(3))
+	schedule := find(device, model.ObjectTypeSchedule).(*model.Schedule)
+	schedule.WeeklySchedule[2] = []model.TimeValue{{Time: 7 * time.Hour, Value: 21.0}, {Time: 19 * time.Hour, Value: nil}}
+	holiday := model.Date{Year: model.DateTimeAny, Month: 12, Day: 25, Weekday: model.DateTimeAny}
+	schedule.ExceptionSchedule = []model.SpecialEvent{{Calendar: &model.CalendarEntry{Date: &holiday}, TimeValues: []model.TimeValue{{Value: 12.0}}, Priority: 3}}
+	trend := find(device, model.ObjectTypeTrendLog).(*model.TrendLog)
+	for i := 0; i < 4; i++ {
+		trend.Log.Append(start.Add(time.Duration(i)*time.Minute), float32(20+i))
+	}
+	trend.Log.Append(start.Add(5*time.Minute), model.LogFailure{Err: errors.New("unreachable")})
+	find(device, model.ObjectTypeTrendLogMultiple).(*model.TrendLogMultiple).Log.Append(start, model.LogMultipleDatum{float32(21), model.LogFailure{Err: errors.New("no such object")}})
+	find(device, model.ObjectTypeEventLog).(*model.EventLog).LogNotification(start, model.EventNotification{
+		EventObject: trend.GetObjectIdentifier(), TimeStamp: start, NotificationClass: 1, Priority: 10,
+		EventType: model.EventTypeBufferReady, ToState: model.EventStateNormal,
+		EventValues: model.BufferReadyEventValues{PreviousNotification: 1, CurrentNotification: 4},
+	})
+
+	// 快照按对象调度的周期保存，关闭时再保存一次
+	clock := model.NewFakeClock(start)
+	network := NewLoopbackNetwork()
+	s, err := NewServer(device, Options{Transport: network.Attach(), Clock: clock, SnapshotFile: path, SnapshotInterval: 10 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Start(context.Background())
+	clock.Advance(12 * time.Second)
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("snapshot not written after the interval: %v", err)
+	}
+	s.Stop()
+
+	restored := newDevice()
+	if _, err := NewServer(restored, Options{Transport: network.Attach(), SnapshotFile: path}); err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	for _, check := range []struct {
+		objType model.ObjectType
+		prop    model.PropertyIdentifier
+	}{
+		{model.ObjectTypeAnalogInput, model.PropertyIdentifierPresentValue},
+		{model.ObjectTypeAnalogOutput, model.PropertyIdentifierPriorityArray},
+		{model.ObjectTypeMultiStateValue, model.PropertyIdentifierPresentValue},
+		{model.ObjectTypeSchedule, model.PropertyIdentifierWeeklySchedule},
+		{model.ObjectTypeSchedule, model.PropertyIdentifierExceptionSchedule},
+		{model.ObjectTypeTrendLog, model.PropertyIdentifierRecordCount},
+		{model.ObjectTypeTrendLog, model.PropertyIdentifierTotalRecordCount},
+	} {
+		want, _ := find(device, check.objType).ReadProperty(check.prop)
+		if got, _ := find(restored, check.objType).ReadProperty(check.prop); !reflect.DeepEqual(got, want) {
+			t.Errorf("%v property %d = %v, want %v", check.objType, check.prop, got, want)
+		}
+	}
+	for _, objType := range []model.ObjectType{model.ObjectTypeTrendLog, model.ObjectTypeTrendLogMultiple, model.ObjectTypeEventLog} {
+		want := find(device, objType).(interface{ Buffer() *model.LogBuffer }).Buffer().Records
+		got := find(restored, objType).(interface{ Buffer() *model.LogBuffer }).Buffer().Records
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%v records = %v, want %v", objType, got, want)
+		}
+	}
+	if restoreTime, _ := restored.ReadProperty(model.PropertyIdentifierLastRestoreTime); restoreTime.(time.Time).IsZero() {
+		t.Error("Last_Restore_Time not set")
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	temp := model.NewAnalogInput(1, "Zone Temp", model.UnitsDegreesCelsius)
+	temp.UpdatePresentValue(21.5)
+	temp.SetEventState(model.EventStateHighLimit)
+	temp.SetStatusFlags(model.StatusFlagInAlarm)
+	device.AddObject(temp)
+	setpoint := model.NewAnalogValue(1, "Setpoint", model.UnitsDegreesCelsius)
+	device.AddObject(setpoint)
+	s := &BACnetServer{device: device}
+	s.Protect(temp.GetObjectIdentifier(), model.PropertyIdentifierOutOfService, ProtectionReadOnly)
+	server := httptest.NewServer(s.DashboardHandler())
+	defer server.Close()
+
+	get := func(path string, v interface{}) {
+		t.Helper()
+		response, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s: %s", path, response.Status)
+		}
+		if err := json.NewDecoder(response.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put := func(path, body string) int {
+		t.Helper()
+		request, _ := http.NewRequest(http.MethodPut, server.URL+path, strings.NewReader(body))
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		return response.StatusCode
+	}
+
+	var objects []objectSummary
+	get("/api/objects", &objects)
+	if len(objects) != 3 || objects[1].ID != "analog-input:1" || objects[1].PresentValue != 21.5 || objects[1].Units != "degrees-celsius" ||
+		!reflect.DeepEqual(objects[1].StatusFlags, []string{"in-alarm"}) {
+		t.Errorf("objects = %+v", objects)
+	}
+
+	var alarms []alarmView
+	get("/api/alarms", &alarms)
+	if len(alarms) != 1 || alarms[0].EventState != "high-limit" || alarms[0].Priority != defaultEventPriority {
+		t.Errorf("alarms = %+v", alarms)
+	}
+
+	// 按优先级写入可命令对象，写保护同样生效
+	if code := put("/api/objects/analog-value/1/present-value", `{"value": 23, "priority": 8}`); code != http.StatusOK {
+		t.Errorf("write status = %d", code)
+	}
+	if got, _ := setpoint.ReadProperty(model.PropertyIdentifierPresentValue); got != float32(23) {
+		t.Errorf("setpoint = %#v", got)
+	}
+	if code := put("/api/objects/analog-input/1/out-of-service", `{"value": true}`); code != http.StatusConflict {
+		t.Errorf("protected write status = %d", code)
+	}
+	if code := put("/api/objects/analog-value/1/present-value", `{"value": "warm"}`); code != http.StatusBadRequest {
+		t.Errorf("invalid value status = %d", code)
+	}
+
+	// 其他组件注册的接口
+	s.HandleDashboardAPI("harvest", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, []string{"trend-log:1"})
+	}))
+	var harvest []string
+	get("/api/harvest", &harvest)
+	if len(harvest) != 1 {
+		t.Errorf("/api/harvest = %v", harvest)
+	}
+	if response, err := http.Get(server.URL + "/api/unknown"); err != nil || response.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /api/unknown: %v", err)
+	}
+
+	var detail struct {
+		Object     objectSummary  `json:"object"`
+		Properties []propertyView `json:"properties"`
+	}
+	get("/api/objects/analog-value/1", &detail)
+	var priorityArray interface{}
+	for _, p := range detail.Properties {
+		if p.ID == model.PropertyIdentifierPriorityArray {
+			priorityArray = p.Value
+		}
+	}
+	if slots, ok := priorityArray.([]interface{}); !ok || len(slots) != 16 || slots[7] != 23.0 {
+		t.Errorf("Priority_Array = %v", priorityArray)
+	}
+
+	// 事件流连接时推送全部对象
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/events", nil)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	reader := bufio.NewReader(response.Body)
+	if line, _ := reader.ReadString('\n'); line != "event: objects\n" {
+		t.Fatalf("event line = %q", line)
+	}
+	line, _ := reader.ReadString('\n')
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &objects); err != nil || len(objects) != 3 {
+		t.Errorf("event data = %q", line)
+	}
+
+	// 回到normal但有未确认转换的对象同样列出，可以按事件状态、通知类和优先级过滤
+	nc := model.NewBACnetObject(model.ObjectTypeNotificationClass, 5, "Critical")
+	nc.Properties[model.PropertyIdentifierPriority] = uint32(10)
+	device.AddObject(nc)
+	fan := model.NewBinaryValue(1, "Fan Alarm")
+	fan.SetNotificationClass(5)
+	fan.GenerateEvent(model.EventStateOffNormal, "fan failed")
+	fan.GenerateEvent(model.EventStateNormal, "fan running")
+	device.AddObject(fan)
+	get("/api/alarms?notificationClass=5&priority=0-63", &alarms)
+	if len(alarms) != 1 || alarms[0].ID != "binary-value:1" || alarms[0].EventState != "normal" || alarms[0].Priority != 10 ||
+		!reflect.DeepEqual(alarms[0].UnackedTransitions, []string{"to-offnormal", "to-normal"}) || len(alarms[0].EventTimeStamps) != 2 {
+		t.Errorf("alarms of class 5 = %+v", alarms)
+	}
+	for query, want := range map[string]int{"": 2, "eventState=high-limit": 1, "eventState=normal&eventState=high-limit": 2, "priority=64": 0, "notificationClass=1": 0} {
+		get("/api/alarms?"+query, &alarms)
+		if len(alarms) != want {
+			t.Errorf("alarms?%s = %+v, want %d", query, alarms, want)
+		}
+	}
+	for _, query := range []string{"eventState=hot", "priority=64-10", "notificationClass=x"} {
+		if response, err := http.Get(server.URL + "/api/alarms?" + query); err != nil || response.StatusCode != http.StatusBadRequest {
+			t.Errorf("GET /api/alarms?%s: %v", query, err)
+		}
+	}
+}
+
+func TestConsole(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	temp := model.NewAnalogInput(1, "Zone Temp", model.UnitsDegreesCelsius)
+	device.AddObject(temp)
+	setpoint := model.NewAnalogValue(1, "Setpoint", model.UnitsDegreesCelsius)
+	device.AddObject(setpoint)
+	temp.AddCOVSubscription(model.COVSubscription{SubscriptionID: 7, SubscriberProcessID: 7, ClientAddress: "192.168.1.10:47808"})
+	temp.AddCOVSubscription(model.COVSubscription{SubscriptionID: 8, SubscriberProcessID: 8, ClientAddress: "192.168.1.11:47808"})
+	s := &BACnetServer{device: device}
+	s.Protect(temp.GetObjectIdentifier(), model.PropertyIdentifierOutOfService, ProtectionReadOnly)
+	s.transactions.begin("192.168.1.10:47808", BACnetServiceConfirmedCOVNotification, time.Now())
+
+	script := strings.Join([]string{
+		"set analog-value:1 present-value 23 8",
+		"set Setpoint description \"Zone setpoint\"",
+		"set analog-input:1 out-of-service true",
+		"drive analog-input:1 30.5",
+		"alarm analog-input:1 high-limit too warm",
+		"objects analog-input",
+		"subscriptions",
+		"expire analog-input:1 7",
+		"transactions",
+		"bogus",
+		"quit",
+		"set analog-value:1 present-value 99",
+	}, "\n")
+	var out bytes.Buffer
+	if err := s.ServeConsole(strings.NewReader(script), &out); err != nil {
+		t.Fatal(err)
+	}
+	output := out.String()
+
+	if got := setpoint.PriorityArray(model.PropertyIdentifierPresentValue)[7]; got != float32(23) {
+		t.Errorf("priority 8 = %#v", got)
+	}
+	if got, _ := setpoint.ReadProperty(model.PropertyIdentifierDescription); got != "Zone setpoint" {
+		t.Errorf("description = %q", got)
+	}
+	if got, _ := temp.ReadProperty(model.PropertyIdentifierPresentValue); got != float32(30.5) {
+		t.Errorf("driven value = %#v", got)
+	}
+	if temp.GetEventState() != model.EventStateHighLimit || temp.GetStatusFlags()&model.StatusFlagInAlarm == 0 {
+		t.Errorf("event state = %v, flags = %b", temp.GetEventState(), temp.GetStatusFlags())
+	}
+	if subs := temp.COVSubscriptions(); len(subs) != 1 || subs[0].SubscriptionID != 8 {
+		t.Errorf("subscriptions = %+v", subs)
+	}
+	for _, want := range []string{
+		"analog-value:1 present-value = 23",
+		"error: ", // 写保护
+		"analog-input:1 event-state = high-limit",
+		"in-alarm",
+		"192.168.1.11:47808",
+		"1 subscription(s) removed",
+		"ConfirmedCOVNotification",
+		`unknown command "bogus"`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "= 99") {
+		t.Error("command after quit was executed")
+	}
+
+	// 清除告警后恢复正常
+	if err := s.ServeConsole(strings.NewReader(`clear "Zone Temp"`), io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if temp.GetEventState() != model.EventStateNormal || temp.GetStatusFlags()&model.StatusFlagInAlarm != 0 {
+		t.Errorf("after clear: event state = %v, flags = %b", temp.GetEventState(), temp.GetStatusFlags())
+	}
+
+	// 运行中修改模拟曲线
+	engine := simulation.NewEngine()
+	s.SetSimulation(engine)
+	out.Reset()
+	script = "sim analog-input:1 kind=sine min=10 max=30 period=2m\nsim analog-input:1 pause\nsim\nsim analog-value:1 stop\nsim analog-input:1 wave=big"
+	if err := s.ServeConsole(strings.NewReader(script), &out); err != nil {
+		t.Fatal(err)
+	}
+	if profile, ok := engine.Profile(temp.GetObjectIdentifier()); !ok || profile.Kind != simulation.Sine || profile.Offset != 20 || profile.Period != 2*time.Minute {
+		t.Errorf("profile = %+v", profile)
+	}
+	if points := engine.Points(); len(points) != 1 || !points[0].Paused {
+		t.Errorf("points = %+v", points)
+	}
+	if output := out.String(); !strings.Contains(output, "paused") || strings.Count(output, "error: ") != 2 {
+		t.Errorf("sim output:\n%s", output)
+	}
+
+	if err := engine.AddRule(device, simulation.Rule{Name: "mirror", Target: temp, Value: "analog-value:1"}); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := s.ServeConsole(strings.NewReader("rules"), &out); err != nil {
+		t.Fatal(err)
+	}
+	if output := out.String(); !strings.Contains(output, "mirror") || !strings.Contains(output, "analog-input:1") {
+		t.Errorf("rules output:\n%s", output)
+	}
+}
+
+func TestScenario(t *testing.T) {
+	network := NewLoopbackNetwork()
+	device := model.NewDevice(1234, "Scenario Device", "")
+	sensor := model.NewAnalogValue(1, "Sensor", model.UnitsDegreesCelsius)
+	device.AddObject(sensor)
+	server, err := newBACnetServer(device, network.Attach(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := model.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	server.SetClock(clock)
+	server.Start(context.Background())
+	defer server.Stop()
+	serverAddr := server.transport.LocalAddr()
+
+	client := NewTestClient(network.Attach(), 200*time.Millisecond)
+	defer client.Close()
+	if err := client.SubscribeCOV(serverAddr, 1, sensor.GetObjectIdentifier(), 300, false); err != nil {
+		t.Fatalf("SubscribeCOV() = %v", err)
+	}
+
+	scenario := &Scenario{Name: "comms loss", Steps: []ScenarioStep{
+		{At: 0, Command: "drive analog-value:1 30"},
+		{At: 10 * time.Second, Command: "comms down"},
+		{At: 20 * time.Second, Command: "comms up"},
+		{At: 20 * time.Second, Command: "expire analog-value:1"},
+		{At: 30 * time.Second, Command: "drive analog-value:9 1"},
+	}}
+	progress := make(chan ScenarioProgress, len(scenario.Steps))
+	result := make(chan error, 1)
+	go func() {
+		result <- server.RunScenario(context.Background(), scenario, func(p ScenarioProgress) { progress <- p })
+	}()
+	next := func(want int) ScenarioProgress {
+		t.Helper()
+		p := <-progress
+		if p.Step != want || p.Total != len(scenario.Steps) {
+			t.Fatalf("progress = %+v, want step %d", p, want)
+		}
+		return p
+	}
+
+	if p := next(1); p.Err != nil || !strings.Contains(p.Output, "30") {
+		t.Errorf("step 1 = %+v", p)
+	}
+	if _, err := client.Notification(); err != nil {
+		t.Errorf("COV notification after drive: %v", err)
+	}
+
+	// 通信中断时设备不应答
+	clock.Advance(10 * time.Second)
+	next(2)
+	if _, err := client.ReadProperty(serverAddr, sensor.GetObjectIdentifier(), model.PropertyIdentifierPresentValue); err == nil {
+		t.Error("ReadProperty() while comms down: want timeout")
+	}
+
+	clock.Advance(10 * time.Second)
+	next(3)
+	if p := next(4); p.Err != nil || p.At != 20*time.Second {
+		t.Errorf("step 4 = %+v", p)
+	}
+	if value, err := client.ReadProperty(serverAddr, sensor.GetObjectIdentifier(), model.PropertyIdentifierPresentValue); err != nil || value != float32(30) {
+		t.Errorf("ReadProperty() after comms up = %v, %v", value, err)
+	}
+	server.Lock()
+	if subs := sensor.COVSubscriptions(); len(subs) != 0 {
+		t.Errorf("subscriptions after expire = %+v", subs)
+	}
+	server.Unlock()
+
+	// 失败的步骤不中止场景，结束时报告失败的步数
+	clock.Advance(10 * time.Second)
+	if p := next(5); p.Err == nil {
+		t.Errorf("step 5 = %+v, want error", p)
+	}
+	if err := <-result; err == nil || !strings.Contains(err.Error(), "1步失败") {
+		t.Errorf("RunScenario() = %v", err)
+	}
+
+	for _, bad := range []*Scenario{
+		{Name: "empty"},
+		{Steps: []ScenarioStep{{Command: "quit"}}},
+		{Steps: []ScenarioStep{{At: time.Minute, Command: "comms down"}, {At: time.Second, Command: "comms up"}}},
+	} {
+		if err := server.RunScenario(context.Background(), bad, nil); err == nil {
+			t.Errorf("RunScenario(%+v): want error", bad.Steps)
+		}
+	}
+}
+
+func TestHandleWritePropertyMetadata(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	sensor := model.NewAnalogInput(1, "Sensor", model.UnitsDegreesCelsius)
+	device.AddObject(sensor)
+	s := &BACnetServer{device: device}
+
+	request := func(property model.PropertyIdentifier, value interface{}) []byte {
+		return encodeWritePropertyRequest(sensor.GetObjectIdentifier(), property, encodeBACnetValue(value), 16)
+	}
+
+	tests := []struct {
+		name     string
+		property model.PropertyIdentifier
+		value    interface{}
+		code     byte
+	}{
+		{"unknown property", model.PropertyIdentifierNumberOfStates, uint8(3), ErrorCodePropertyNotExist},
+		{"read only", model.PropertyIdentifierUnits, uint8(1), ErrorCodeWriteAccessDenied},
+		{"wrong datatype", model.PropertyIdentifierOutOfService, uint8(1), ErrorCodeInvalidDataType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := s.handleWriteProperty(nil, request(tt.property, tt.value), 1)
+			want := s.createErrorResponse(1, BACnetServiceConfirmedWriteProperty, ErrorClassProperty, tt.code)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got % X, want % X", got, want)
+			}
+		})
+	}
+
+	required := model.ExpandPropertyReference(sensor, model.PropertyIdentifierRequired)
+	want := []model.PropertyIdentifier{
+		model.PropertyIdentifierObjectIdentifier, model.PropertyIdentifierObjectName, model.PropertyIdentifierObjectType,
+		model.PropertyIdentifierPresentValue, model.PropertyIdentifierUnits, model.PropertyIdentifierStatusFlags,
+		model.PropertyIdentifierEventState, model.PropertyIdentifierOutOfService,
+	}
+	if !reflect.DeepEqual(required, want) {
+		t.Errorf("REQUIRED = %v, want %v", required, want)
+	}
+}
+
+func TestProprietaryProperty(t *testing.T) {
+	const (
+		vendorType     model.ObjectType         = 200
+		vendorProperty model.PropertyIdentifier = 600
+	)
+	// 专有属性以一个字节的原始值编码
+	err := model.RegisterProprietaryProperty(model.ProprietaryProperty{
+		Identifier: vendorProperty,
+		Name:       "fan-stage",
+		Encode: func(value interface{}) ([]byte, error) {
+			return []byte{0xF0, value.(byte)}, nil
+		},
+		Decode: func(data []byte) (interface{}, int, error) {
+			return data[1], 2, nil
+		},
+	}, model.DatatypeUnsigned, vendorType)
+	if err != nil {
+		t.Fatalf("RegisterProprietaryProperty() error = %v", err)
+	}
+	if err := model.RegisterProprietaryObjectType(model.ProprietaryObjectType{Type: vendorType, Name: "fan-controller"}); err != nil {
+		t.Fatalf("RegisterProprietaryObjectType() error = %v", err)
+	}
+	if err := model.RegisterProprietaryObjectType(model.ProprietaryObjectType{Type: vendorType, Name: "duplicate"}); err == nil {
+		t.Error("duplicate object type registration: want error")
+	}
+
+	device := model.NewDevice(1, "Test Device", "")
+	fan := model.NewBACnetObject(vendorType, 1, "Fan")
+	device.AddObject(fan)
+	s := &BACnetServer{device: device}
+
+	s.handleWriteProperty(nil, encodeWritePropertyRequest(fan.GetObjectIdentifier(), vendorProperty, []byte{0xF0, 3}, 16), 1)
+
+	got, _ := s.handleReadProperty(nil, EncodeReadPropertyRequest(fan.GetObjectIdentifier(), vendorProperty, nil), 2)
+	if want := []byte{0xF0, 3}; !reflect.DeepEqual(readPropertyAckValue(t, got), want) {
+		t.Errorf("read value = % X, want % X", got, want)
+	}
+	if name := vendorProperty.String(); name != "fan-stage" {
+		t.Errorf("String() = %q, want fan-stage", name)
+	}
+}
+
+func TestHandleReadPropertyObjectList(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	sensor := model.NewAnalogInput(1, "Sensor", model.UnitsDegreesCelsius)
+	humidity := model.NewAnalogInput(2, "Humidity", model.UnitsPercent)
+	device.AddObject(sensor)
+	device.AddObject(humidity)
+	s := &BACnetServer{device: device}
+
+	read := func(index uint32) []byte {
+		request := EncodeReadPropertyRequest(device.GetObjectIdentifier(), model.PropertyIdentifierObjectList, &index)
+		response, _ := s.handleReadProperty(nil, request, 1)
+		return response
+	}
+
+	if got, want := readPropertyAckValue(t, read(0)), encodeBACnetValue(uint32(3)); !bytes.Equal(got, want) {
+		t.Errorf("Object_List[0] = % X, want count % X", got, want)
+	}
+	if got, want := readPropertyAckValue(t, read(3)), encodeBACnetValue(humidity.GetObjectIdentifier()); !bytes.Equal(got, want) {
+		t.Errorf("Object_List[3] = % X, want % X", got, want)
+	}
+	if got := read(4); got[0] != BACnetAPDUTypeError<<4 || got[len(got)-1] != ErrorCodeInvalidArrayIndex {
+		t.Errorf("Object_List[4] = % X, want invalid-array-index error", got)
+	}
+
+	device.RemoveObject(sensor.GetObjectIdentifier())
+	if got, want := readPropertyAckValue(t, read(2)), encodeBACnetValue(humidity.GetObjectIdentifier()); !bytes.Equal(got, want) {
+		t.Errorf("Object_List[2] after remove = % X, want % X", got, want)
+	}
+}
+
+// readPropertyAckValue 从ReadProperty的ComplexAck中取出propertyValue的内容
+func readPropertyAckValue(t *testing.T, response []byte) []byte {
+	t.Helper()
+	if len(response) < 3 || response[0] != BACnetAPDUTypeComplexAck<<4 {
+		t.Fatalf("response = % X, want ComplexAck", response)
+	}
+	d := encoding.NewDecoder(response[3:])
+	d.ContextObjectIdentifier(0)
+	d.ContextEnumerated(1)
+	if d.IsContext(2) {
+		d.ContextUnsigned(2)
+	}
+	value, err := d.Constructed(3)
+	if err != nil {
+		t.Fatalf("ReadProperty-ACK % X: %v", response, err)
+	}
+	return value
+}
+
+func TestParseReadPropertyRequest(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	sensor := model.NewAnalogInput(1, "Sensor", model.UnitsDegreesCelsius)
+	device.AddObject(sensor)
+	s := &BACnetServer{device: device}
+
+	// 典型客户端请求：0C 00000001 19 55
+	request := []byte{0x0C, 0x00, 0x00, 0x00, 0x01, 0x19, 0x55}
+	response, _ := s.handleReadProperty(nil, request, 7)
+	want := append([]byte{0x0C, 0x00, 0x00, 0x00, 0x01, 0x19, 0x55}, 0x3E)
+	want = append(append(want, encodeBACnetValue(float32(0))...), 0x3F)
+	if !bytes.Equal(response, encodeComplexAck(7, BACnetServiceConfirmedReadProperty, want)) {
+		t.Errorf("ReadProperty-ACK = % X, want % X", response, want)
+	}
+
+	// 两字节属性标识符和数组下标
+	index := uint32(1)
+	got, err := parseReadPropertyRequest(EncodeReadPropertyRequest(sensor.GetObjectIdentifier(), 512, &index))
+	if err != nil || got.PropertyID != 512 || got.ArrayIndex == nil || *got.ArrayIndex != 1 {
+		t.Errorf("parseReadPropertyRequest() = %+v, %v", got, err)
+	}
+
+	malformed := [][]byte{
+		{},
+		{0x00, 0x00, 0x00, 0x01, 0x00, 0x55}, // 旧的原始字节格式
+		{0x0C, 0x00, 0x00, 0x00, 0x01},       // 缺少属性标识符
+		append(EncodeReadPropertyRequest(sensor.GetObjectIdentifier(), 85, nil), 0x21, 0x01),
+	}
+	for _, data := range malformed {
+		if _, err := parseReadPropertyRequest(data); err == nil {
+			t.Errorf("parseReadPropertyRequest(% X) expected error", data)
+		}
+	}
+}
+
+// encodeWritePropertyRequest 按标准格式编码WriteProperty请求，value为已编码的属性值
+func encodeWritePropertyRequest(oid model.ObjectIdentifier, prop model.PropertyIdentifier, value []byte, priority byte) []byte {
+	data := encoding.EncodeContextObjectIdentifier(0, oid)
+	data = append(data, encoding.EncodeContextEnumerated(1, uint32(prop))...)
+	data = append(data, encoding.EncodeConstructed(3, value)...)
+	return append(data, encoding.EncodeContextUnsigned(4, uint32(priority))...)
+}
+
+func TestWritePropertyArrayElements(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	schedule := model.NewSchedule(1, "Occupancy", float32(18))
+	mode := model.NewMultiStateValue(1, "Mode", []string{"Off", "Heat", "Cool"})
+	calendar := model.NewCalendar(1, "Holidays")
+	device.AddObject(schedule)
+	device.AddObject(mode)
+	device.AddObject(calendar)
+	s := &BACnetServer{device: device}
+	var hooked []*uint32
+	s.BeforeWrite(func(op *Operation) error {
+		hooked = append(hooked, op.ArrayIndex)
+		return nil
+	})
+
+	write := func(obj model.Object, prop model.PropertyIdentifier, index *uint32, value []byte) []byte {
+		t.Helper()
+		request, err := encoding.Marshal(WritePropertyRequest{ObjectID: obj.GetObjectIdentifier(), PropertyID: prop, ArrayIndex: index, Value: value, Priority: 16})
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, _ := s.handleWriteProperty(nil, request, 1)
+		return response
+	}
+	ack := encodeSimpleAck(1, BACnetServiceConfirmedWriteProperty)
+	at := func(index uint32) *uint32 { return &index }
+
+	// Weekly_Schedule[3]只替换星期三
+	wednesday := []model.TimeValue{{Time: 7 * time.Hour, Value: float32(21)}, {Time: 19 * time.Hour, Value: float32(18)}}
+	if got := write(schedule, model.PropertyIdentifierWeeklySchedule, at(3), encodeBACnetValue(wednesday)); !bytes.Equal(got, ack) {
+		t.Fatalf("write Weekly_Schedule[3] = % X, want SimpleAck", got)
+	}
+	if !reflect.DeepEqual(schedule.WeeklySchedule[2], wednesday) || len(schedule.WeeklySchedule[1]) != 0 {
+		t.Errorf("Weekly_Schedule = %v", schedule.WeeklySchedule)
+	}
+	if len(hooked) != 1 || hooked[0] == nil || *hooked[0] != 3 {
+		t.Errorf("BeforeWrite array index = %v, want 3", hooked)
+	}
+	for _, check := range []struct {
+		index uint32
+		value []byte
+		code  byte
+	}{
+		{0, encoding.EncodeUnsigned(6), ErrorCodeWriteAccessDenied}, // 固定7天
+		{8, encodeBACnetValue(wednesday), ErrorCodeInvalidArrayIndex},
+	} {
+		if got := write(schedule, model.PropertyIdentifierWeeklySchedule, at(check.index), check.value); got[len(got)-1] != check.code {
+			t.Errorf("write Weekly_Schedule[%d] = % X, want error code %d", check.index, got, check.code)
+		}
+	}
+
+	// Exception_Schedule整个写入后替换一个元素，优先级超出范围时拒绝
+	newYear := model.Date{Year: 0xFF, Month: 1, Day: 1, Weekday: 0xFF}
+	events := []model.SpecialEvent{
+		{Calendar: &model.CalendarEntry{Date: &newYear}, TimeValues: []model.TimeValue{{Value: float32(12)}}, Priority: 3},
+		{CalendarReference: &model.ObjectIdentifier{Type: model.ObjectTypeCalendar, Instance: 1}, TimeValues: []model.TimeValue{}, Priority: 5},
+	}
+	if got := write(schedule, model.PropertyIdentifierExceptionSchedule, nil, encodeBACnetValue(events)); !bytes.Equal(got, ack) {
+		t.Fatalf("write Exception_Schedule = % X, want SimpleAck", got)
+	}
+	events[1].Priority = 7
+	if got := write(schedule, model.PropertyIdentifierExceptionSchedule, at(2), encodeBACnetValue(events[1])); !bytes.Equal(got, ack) {
+		t.Fatalf("write Exception_Schedule[2] = % X, want SimpleAck", got)
+	}
+	if !reflect.DeepEqual(schedule.ExceptionSchedule, events) {
+		t.Errorf("Exception_Schedule = %+v, want %+v", schedule.ExceptionSchedule, events)
+	}
+	invalid, _ := encoding.EncodeSpecialEvent(model.SpecialEvent{Calendar: &model.CalendarEntry{Date: &newYear}, Priority: 17})
+	if got := write(schedule, model.PropertyIdentifierExceptionSchedule, at(1), invalid); bytes.Equal(got, ack) || schedule.ExceptionSchedule[0].Priority != 3 {
+		t.Errorf("write Exception_Schedule[1] priority 17 = % X, want error", got)
+	}
+
+	// State_Text整个写入、单个元素写入和下标0调整长度
+	texts := []string{"Off", "Low", "Medium", "High"}
+	if got := write(mode, model.PropertyIdentifierStateText, nil, encodeBACnetValue(texts)); !bytes.Equal(got, ack) || mode.NumberOfStates() != 4 {
+		t.Fatalf("write State_Text = % X, states %d", got, mode.NumberOfStates())
+	}
+	if got := write(mode, model.PropertyIdentifierStateText, at(2), encoding.EncodeCharacterString("Eco")); !bytes.Equal(got, ack) || mode.StateText[1] != "Eco" {
+		t.Errorf("write State_Text[2] = % X, state text %v", got, mode.StateText)
+	}
+	if got := write(mode, model.PropertyIdentifierStateText, at(0), encoding.EncodeUnsigned(2)); !bytes.Equal(got, ack) || !reflect.DeepEqual(mode.StateText, []string{"Off", "Eco"}) {
+		t.Errorf("write State_Text[0] = % X, state text %v", got, mode.StateText)
+	}
+	read, _ := s.handleReadProperty(nil, EncodeReadPropertyRequest(mode.GetObjectIdentifier(), model.PropertyIdentifierStateText, nil), 2)
+	if got := readPropertyAckValue(t, read); !bytes.Equal(got, encodeBACnetValue([]string{"Off", "Eco"})) {
+		t.Errorf("read State_Text = % X", got)
+	}
+	for _, check := range []struct {
+		name  string
+		index *uint32
+		value []byte
+		code  byte
+	}{
+		{"element type", nil, append(encoding.EncodeCharacterString("Off"), encoding.EncodeReal(1)...), ErrorCodeInvalidDataType},
+		{"empty list", nil, nil, ErrorCodeValueOutOfRange},
+		{"element", at(1), encoding.EncodeReal(1), ErrorCodeInvalidDataType},
+		{"index", at(3), encoding.EncodeCharacterString("High"), ErrorCodeInvalidArrayIndex},
+	} {
+		if got := write(mode, model.PropertyIdentifierStateText, check.index, check.value); got[len(got)-1] != check.code {
+			t.Errorf("write State_Text %s = % X, want error code %d", check.name, got, check.code)
+		}
+	}
+	if got := write(mode, model.PropertyIdentifierPresentValue, at(1), encoding.EncodeUnsigned(1)); got[len(got)-1] != ErrorCodePropertyIsNotAnArray {
+		t.Errorf("write Present_Value[1] = % X, want property-is-not-an-array", got)
+	}
+
+	// Date_List整个写入
+	entries := []model.CalendarEntry{
+		{Date: &newYear},
+		{WeekNDay: &model.WeekNDay{Month: 0xFF, WeekOfMonth: 0xFF, DayOfWeek: 7}},
+	}
+	if got := write(calendar, model.PropertyIdentifierDateList, nil, encodeBACnetValue(entries)); !bytes.Equal(got, ack) || !reflect.DeepEqual(calendar.DateList, entries) {
+		t.Errorf("write Date_List = % X, date list %+v", got, calendar.DateList)
+	}
+}
+
+func TestParseWritePropertyRequest(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	valve := model.NewAnalogOutput(1, "Valve", model.UnitsPercent)
+	device.AddObject(valve)
+	s := &BACnetServer{device: device}
+
+	// 典型客户端请求：0C 00400001 19 55 3E 44 42480000 3F 49 08
+	request := []byte{0x0C, 0x00, 0x40, 0x00, 0x01, 0x19, 0x55, 0x3E, 0x44, 0x42, 0x48, 0x00, 0x00, 0x3F, 0x49, 0x08}
+	got, err := parseWritePropertyRequest(request)
+	if err != nil || got.Priority != 8 || got.PropertyID != model.PropertyIdentifierPresentValue ||
+		!bytes.Equal(got.Value, encoding.EncodeReal(50)) {
+		t.Fatalf("parseWritePropertyRequest() = %+v, %v", got, err)
+	}
+	if response, _ := s.handleWriteProperty(nil, request, 1); !bytes.Equal(response, []byte{0x20, 1, BACnetServiceConfirmedWriteProperty}) {
+		t.Fatalf("write = % X, want SimpleAck", response)
+	}
+	if array, _ := valve.ReadProperty(model.PropertyIdentifierPriorityArray); array.(model.PriorityArray)[7] != float32(50) {
+		t.Errorf("priority array = %v, want 50 at priority 8", array)
+	}
+
+	// 未携带优先级时按16写入
+	if got, err := parseWritePropertyRequest(request[:len(request)-2]); err != nil || got.Priority != 16 {
+		t.Errorf("without priority = %+v, %v", got, err)
+	}
+
+	// 单个属性的数组下标
+	index := append(request[:7:7], 0x29, 0x01)
+	index = append(index, request[7:]...)
+	response, _ := s.handleWriteProperty(nil, index, 1)
+	if response[len(response)-1] != ErrorCodePropertyIsNotAnArray {
+		t.Errorf("write with array index = % X, want property-is-not-an-array", response)
+	}
+
+	malformed := [][]byte{
+		{0x00, 0x40, 0x00, 0x01, 0x00, 0x55, 16, 0x44, 0x42, 0x48, 0x00, 0x00}, // 旧的原始字节格式
+		request[:13], // 缺少结束标签
+		append(request[:14:14], 0x4A, 0x01, 0x00), // 优先级超出一个字节
+		append(append([]byte(nil), request...), 0x21, 0x01),
+	}
+	for _, data := range malformed {
+		if _, err := parseWritePropertyRequest(data); err == nil {
+			t.Errorf("parseWritePropertyRequest(% X) expected error", data)
+		}
+	}
+}
+
+func TestHandleReadPropertyMultiple(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	sensor := model.NewAnalogInput(1, "Sensor", model.UnitsDegreesCelsius)
+	device.AddObject(sensor)
+	s := &BACnetServer{device: device}
+	missing := model.ObjectIdentifier{Type: model.ObjectTypeAnalogInput, Instance: 9}
+
+	// 典型客户端请求：0C 00000001 1E 09 55 09 75 09 4A 1F 0C 00000009 1E 09 55 1F
+	request := []byte{
+		0x0C, 0x00, 0x00, 0x00, 0x01, 0x1E, 0x09, 0x55, 0x09, 0x75, 0x09, 0x4A, 0x1F,
+		0x0C, 0x00, 0x00, 0x00, 0x09, 0x1E, 0x09, 0x55, 0x1F,
+	}
+	specs, err := parseReadPropertyMultipleRequest(request)
+	if err != nil || len(specs) != 2 || len(specs[0].Properties) != 3 || specs[1].ObjectID != missing {
+		t.Fatalf("parseReadPropertyMultipleRequest() = %+v, %v", specs, err)
+	}
+
+	propertyError := func(class, code byte) []byte {
+		return encoding.EncodeConstructed(5, append(encoding.EncodeEnumerated(uint32(class)), encoding.EncodeEnumerated(uint32(code))...))
+	}
+	var results []byte
+	results = append(results, encoding.EncodeContextEnumerated(2, uint32(model.PropertyIdentifierPresentValue))...)
+	results = append(results, encoding.EncodeConstructed(4, encodeBACnetValue(float32(0)))...)
+	results = append(results, encoding.EncodeContextEnumerated(2, uint32(model.PropertyIdentifierUnits))...)
+	results = append(results, encoding.EncodeConstructed(4, encodeBACnetValue(model.UnitsDegreesCelsius))...)
+	results = append(results, encoding.EncodeContextEnumerated(2, uint32(model.PropertyIdentifierNumberOfStates))...)
+	results = append(results, propertyError(ErrorClassProperty, ErrorCodePropertyNotExist)...)
+	want := encoding.EncodeContextObjectIdentifier(0, sensor.GetObjectIdentifier())
+	want = append(want, encoding.EncodeConstructed(1, results)...)
+	want = append(want, encoding.EncodeContextObjectIdentifier(0, missing)...)
+	missingResult := append(encoding.EncodeContextEnumerated(2, uint32(model.PropertyIdentifierPresentValue)), propertyError(ErrorClassObject, ErrorCodeObjectNotExist)...)
+	want = append(want, encoding.EncodeConstructed(1, missingResult)...)
+
+	response, _ := s.handleReadPropertyMultiple(nil, request, 3)
+	if !bytes.Equal(response, encodeComplexAck(3, BACnetServiceConfirmedReadPropertyMultiple, want)) {
+		t.Errorf("ReadPropertyMultiple-ACK = % X, want % X", response, want)
+	}
+
+	// 带数组下标的属性引用
+	index := []byte{0x0C, 0x02, 0x00, 0x00, 0x01, 0x1E, 0x09, 0x57, 0x19, 0x00, 0x1F}
+	if specs, err := parseReadPropertyMultipleRequest(index); err != nil || *specs[0].Properties[0].ArrayIndex != 0 {
+		t.Errorf("array index reference = %+v, %v", specs, err)
+	}
+
+	malformed := [][]byte{
+		{},
+		{0x0C, 0x00, 0x00, 0x00, 0x01, 0x1E, 0x1F},       // 空的属性引用列表
+		{0x0C, 0x00, 0x00, 0x00, 0x01, 0x1E, 0x09, 0x55}, // 缺少结束标签
+		{0x00, 0x00, 0x00, 0x01, 0x00, 0x55},             // 旧的原始字节格式
+	}
+	for _, data := range malformed {
+		if _, err := parseReadPropertyMultipleRequest(data); err == nil {
+			t.Errorf("parseReadPropertyMultipleRequest(% X) expected error", data)
+		}
+	}
+}
+
+func TestBinaryPresentValueEncoding(t *testing.T) {
+	device := model.NewDevice(1234, "Test Device", "")
+	fan := model.NewBinaryOutput(1, "Fan")
+	device.AddObject(fan)
+	if err := fan.WritePropertyWithPriority(model.PropertyIdentifierPresentValue, true, 8); err != nil {
+		t.Fatal(err)
+	}
+	s := &BACnetServer{device: device}
+	oid := fan.GetObjectIdentifier()
+	active, inactive := encoding.EncodeEnumerated(1), encoding.EncodeEnumerated(0)
+
+	// BACnetBinaryPV以枚举编码，Out_Of_Service等BOOLEAN属性不变
+	read := func(prop model.PropertyIdentifier, index *uint32) []byte {
+		t.Helper()
+		request := EncodeReadPropertyRequest(oid, prop, index)
+		response, err := s.handleReadProperty(&RequestContext{}, request, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ack, err := ParseAPDU(response)
+		if err != nil || ack.PDUType != BACnetAPDUTypeComplexAck {
+			t.Fatalf("ReadProperty(%v) = % X, %v", prop, response, err)
+		}
+		return ack.Payload
+	}
+	eight := uint32(8)
+	for _, test := range []struct {
+		prop  model.PropertyIdentifier
+		index *uint32
+		want  []byte
+	}{
+		{model.PropertyIdentifierPresentValue, nil, active},
+		{model.PropertyIdentifierRelinquishDefault, nil, inactive},
+		{model.PropertyIdentifierPriorityArray, &eight, active},
+		{model.PropertyIdentifierOutOfService, nil, encoding.EncodeBoolean(false)},
+	} {
+		if payload := read(test.prop, test.index); !bytes.HasSuffix(payload, encoding.EncodeConstructed(3, test.want)) {
+			t.Errorf("ReadProperty(%v) = % X, want value % X", test.prop, payload, test.want)
+		}
+	}
+	if payload := read(model.PropertyIdentifierPriorityArray, nil); !bytes.Contains(payload, append(append([]byte{0x00}, active...), 0x00)) {
+		t.Errorf("ReadProperty(Priority_Array) = % X", payload)
+	}
+
+	// COV通知中的Present_Value同样以枚举编码
+	parameters, err := s.encodeCOVNotificationParameters(model.COVSubscription{ObjectIdentifier: oid}, covValue(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values := append(encoding.EncodeContextEnumerated(0, uint32(model.PropertyIdentifierPresentValue)), encoding.EncodeConstructed(2, active)...); !bytes.HasSuffix(parameters, encoding.EncodeConstructed(4, values)) {
+		t.Errorf("notification parameters = % X", parameters)
+	}
+}
+
+func TestReadPropertyMultipleCapturedFrame(t *testing.T) {
+	device := model.NewDevice(1234, "Test Device", "")
+	sensor := model.NewAnalogInput(1, "Sensor", model.UnitsDegreesCelsius)
+	device.AddObject(sensor)
+	sensor.UpdatePresentValue(float32(42))
+	s := &BACnetServer{device: device}
+
+	// 头端以B/IP单播发出的完整帧，接受分段应答（02 75）：
+	// Device,1234的Object_Name、Object_Identifier和Analog-Input,1的Present_Value、Status_Flags、Units
+	frame := "81 0a 00 22 01 04 02 75 03 0e" +
+		" 0c 02 00 04 d2 1e 09 4d 09 4b 1f" +
+		" 0c 00 00 00 01 1e 09 55 09 6f 09 75 1f"
+	// 每个对象一个read-access-result，属性值在4E/4F之间
+	want := "81 0a 00 48 01 00 30 03 0e" +
+		" 0c 02 00 04 d2 1e 29 4d 4e 75 0c 00 54 65 73 74 20 44 65 76 69 63 65 4f 29 4b 4e c4 02 00 04 d2 4f 1f" +
+		" 0c 00 00 00 01 1e 29 55 4e 44 42 28 00 00 4f 29 6f 4e 82 04 00 4f 29 75 4e 91 3e 4f 1f"
+	request, _ := hex.DecodeString(strings.ReplaceAll(frame, " ", ""))
+	response, err := s.processBACnetMessage(&RequestContext{ClientAddr: "192.168.1.20:47808"}, request)
+	if got := hex.EncodeToString(response); err != nil || got != strings.ReplaceAll(want, " ", "") {
+		t.Errorf("ReadPropertyMultiple response = %s, %v\nwant %s", got, err, strings.ReplaceAll(want, " ", ""))
+	}
+}
+
+func TestParseAPDUAcks(t *testing.T) {
+	simple, err := ParseAPDU(encodeSimpleAck(5, BACnetServiceConfirmedWriteProperty))
+	if err != nil || *simple.InvokeID != 5 || *simple.ServiceChoice != BACnetServiceConfirmedWriteProperty {
+		t.Errorf("SimpleAck = %v, %v", simple, err)
+	}
+
+	complexAck, err := ParseAPDU(encodeComplexAck(6, BACnetServiceConfirmedReadProperty, []byte{0x0C}))
+	if err != nil || *complexAck.InvokeID != 6 || *complexAck.ServiceChoice != BACnetServiceConfirmedReadProperty ||
+		!bytes.Equal(complexAck.Payload, []byte{0x0C}) {
+		t.Errorf("ComplexAck = %v, %v", complexAck, err)
+	}
+
+	// 分段ComplexAck：SEG|MOR，序号2，窗口4
+	segmented, err := ParseAPDU([]byte{0x3C, 7, 2, 4, BACnetServiceConfirmedReadPropertyMultiple, 0xAA})
+	if err != nil || *segmented.SequenceNumber != 2 || *segmented.ProposedWindowSize != 4 ||
+		*segmented.ServiceChoice != BACnetServiceConfirmedReadPropertyMultiple || !bytes.Equal(segmented.Payload, []byte{0xAA}) {
+		t.Errorf("segmented ComplexAck = %v, %v", segmented, err)
+	}
+
+	reject, err := ParseAPDU([]byte{0x60, 8, RejectReasonInvalidTag})
+	if err != nil || *reject.InvokeID != 8 || !bytes.Equal(reject.Payload, []byte{RejectReasonInvalidTag}) {
+		t.Errorf("Reject = %v, %v", reject, err)
+	}
+
+	for _, data := range [][]byte{{0x20, 5}, {0x30, 6}, {0x38, 7, 2, 4}, {0x00, 0x05, 1}, {0x60}, {0x40, 1, 2}} {
+		if _, err := ParseAPDU(data); err == nil {
+			t.Errorf("ParseAPDU(% X) expected error", data)
+		}
+	}
+}
+
+func TestCreateErrorResponse(t *testing.T) {
+	s := &BACnetServer{}
+	got := s.createErrorResponse(9, BACnetServiceConfirmedReadProperty, ErrorClassProperty, ErrorCodeUnknownProperty)
+	// 50 09 0C 91 02 91 20：property / unknown-property
+	if want := []byte{0x50, 9, 0x0C, 0x91, 0x02, 0x91, 0x20}; !bytes.Equal(got, want) {
+		t.Errorf("createErrorResponse() = % X, want % X", got, want)
+	}
+
+	apdu, err := ParseAPDU(got)
+	if err != nil || *apdu.InvokeID != 9 || *apdu.ServiceChoice != BACnetServiceConfirmedReadProperty {
+		t.Fatalf("ParseAPDU() = %v, %v", apdu, err)
+	}
+	class, code, err := decodeErrorPayload(apdu.Payload)
+	if err != nil || class != ErrorClassProperty || code != ErrorCodeUnknownProperty {
+		t.Errorf("decodeErrorPayload() = %d, %d, %v", class, code, err)
+	}
+
+	// WritePropertyMultiple等服务的错误以上下文标签0包裹
+	wrapped := append(append([]byte{0x0E}, got[3:]...), 0x0F, 0x1E, 0x1F)
+	if class, code, err := decodeErrorPayload(wrapped); err != nil || class != ErrorClassProperty || code != ErrorCodeUnknownProperty {
+		t.Errorf("decodeErrorPayload(wrapped) = %d, %d, %v", class, code, err)
+	}
+	if _, _, err := decodeErrorPayload([]byte{0x02, 0x03}); err == nil {
+		t.Error("decodeErrorPayload(raw bytes) expected error")
+	}
+}
+
+func TestDeviceDatabaseRevision(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	sensor := model.NewAnalogInput(1, "Sensor", model.UnitsDegreesCelsius)
+	start := device.DatabaseRevision()
+
+	device.AddObject(sensor)
+	sensor.WriteProperty(model.PropertyIdentifierObjectName, "Supply Temp")
+	device.RemoveObject(sensor.GetObjectIdentifier())
+	if got, want := device.DatabaseRevision(), start+3; got != want {
+		t.Errorf("Database_Revision = %d, want %d", got, want)
+	}
+
+	// 删除后的对象改名不影响设备配置
+	sensor.WriteProperty(model.PropertyIdentifierObjectName, "Removed")
+	if got, want := device.DatabaseRevision(), start+3; got != want {
+		t.Errorf("Database_Revision after removed rename = %d, want %d", got, want)
+	}
+}
+
+func TestSendConfirmedRequestRetries(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	device.WriteProperty(model.PropertyIdentifierAPDUTimeout, uint32(20))
+	device.WriteProperty(model.PropertyIdentifierNumberOfAPDURetries, uint32(2))
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	peer, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+	s := &BACnetServer{device: device, transport: &UDPTransport{conn: conn}}
+	peerAddr := peer.LocalAddr().(*net.UDPAddr)
+
+	// 对端不应答：首次发送加2次重试
+	if _, err := s.sendConfirmedRequest(peerAddr, BACnetServiceConfirmedCOVNotification, nil); err == nil {
+		t.Fatal("sendConfirmedRequest() without reply: want error")
+	}
+	peer.SetReadDeadline(time.Now().Add(time.Second))
+	buffer := make([]byte, 1500)
+	for i := 0; i < 3; i++ {
+		if _, _, err := peer.ReadFromUDP(buffer); err != nil {
+			t.Fatalf("request %d not received: %v", i+1, err)
+		}
+	}
+
+	// 第二次发送时才应答
+	go func() {
+		peer.ReadFromUDP(buffer)
+		n, _, _ := peer.ReadFromUDP(buffer)
+		invokeID := buffer[n-2]
+		s.transactions.complete(peerAddr.String(), &APDU{PDUType: BACnetAPDUTypeSimpleAck, InvokeID: &invokeID})
+	}()
+	if _, err := s.sendConfirmedRequest(peerAddr, BACnetServiceConfirmedCOVNotification, nil); err != nil {
+		t.Errorf("sendConfirmedRequest() with reply on retry: %v", err)
+	}
+}
+
+func TestObjectNameUniqueness(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	sensor := model.NewAnalogInput(1, "Sensor", model.UnitsDegreesCelsius)
+	device.AddObject(sensor)
+	if err := device.AddObject(model.NewAnalogInput(2, "Sensor", model.UnitsDegreesCelsius)); !errors.Is(err, model.ErrDuplicateName) {
+		t.Errorf("AddObject() duplicate name error = %v, want ErrDuplicateName", err)
+	}
+	s := &BACnetServer{device: device}
+
+	rename := func(name string) []byte {
+		request := encodeWritePropertyRequest(sensor.GetObjectIdentifier(), model.PropertyIdentifierObjectName, encoding.EncodeCharacterString(name), 16)
+		response, _ := s.handleWriteProperty(nil, request, 1)
+		return response
+	}
+	if got := rename("Test Device"); got[len(got)-1] != ErrorCodeDuplicateName {
+		t.Errorf("rename to device name = % X, want duplicate-name error", got)
+	}
+	rename("Supply Temp")
+
+	response, err := s.handleWhoHas(encoding.EncodeContextCharacterString(3, "Supply Temp"))
+	if err != nil || !bytes.Contains(response, encoding.EncodeObjectIdentifier(sensor.GetObjectIdentifier())) {
+		t.Errorf("Who-Has(Supply Temp) = % X, %v; want I-Have for sensor", response, err)
+	}
+	if response, _ := s.handleWhoHas(encoding.EncodeContextCharacterString(3, "Sensor")); response != nil {
+		t.Errorf("Who-Has(old name) = % X, want no reply", response)
+	}
+}
+
+func TestWhoHasStandardEncoding(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	device.AddObject(model.NewAnalogInput(1, "Sensor", model.UnitsDegreesCelsius))
+	s := &BACnetServer{device: device}
+
+	// I-Have：设备device,1、对象analog-input,1和对象名Sensor
+	iHave := []byte{0x01, 0x00, 0x10, 0x01, 0xC4, 0x02, 0x00, 0x00, 0x01, 0xC4, 0x00, 0x00, 0x00, 0x01, 0x75, 0x07, 0x00, 'S', 'e', 'n', 's', 'o', 'r'}
+	requests := map[string][]byte{
+		// Who-Has（服务选择7）按对象名查找
+		"by name": {0x01, 0x00, 0x10, 0x07, 0x3D, 0x07, 0x00, 'S', 'e', 'n', 's', 'o', 'r'},
+		// Who-Has按对象标识符查找，带设备实例范围
+		"by identifier": {0x01, 0x00, 0x10, 0x07, 0x09, 0x00, 0x19, 0x0A, 0x2C, 0x00, 0x00, 0x00, 0x01},
+	}
+	for name, request := range requests {
+		if response, err := s.HandleNPDU(request, "mstp:9"); err != nil || !bytes.Equal(response, iHave) {
+			t.Errorf("%s: HandleNPDU(Who-Has) = % X, %v; want % X", name, response, err, iHave)
+		}
+	}
+	// 服务选择9是UTCTimeSynchronization，不是Who-Has
+	if response, _ := s.HandleNPDU([]byte{0x01, 0x00, 0x10, 0x09, 0x3D, 0x07, 0x00, 'S', 'e', 'n', 's', 'o', 'r'}, "mstp:9"); response != nil {
+		t.Errorf("service choice 9 answered with % X", response)
+	}
+}
+
+func TestWhoIsRange(t *testing.T) {
+	s := &BACnetServer{device: model.NewDevice(1234, "Test Device", "")}
+	iAm := append([]byte{0x01, 0x00}, s.encodeIAm()...)
+	tests := map[string]struct {
+		request  []byte
+		answered bool
+	}{
+		"no limits":       {[]byte{0x01, 0x00, 0x10, 0x08}, true},
+		"in range":        {append([]byte{0x01, 0x00}, EncodeWhoIs(1000, 2000)...), true},
+		"exact instance":  {append([]byte{0x01, 0x00}, EncodeWhoIs(1234, 1234)...), true},
+		"below range":     {append([]byte{0x01, 0x00}, EncodeWhoIs(1235, 4194303)...), false},
+		"above range":     {append([]byte{0x01, 0x00}, EncodeWhoIs(0, 1233)...), false},
+		"low limit only":  {[]byte{0x01, 0x00, 0x10, 0x08, 0x0A, 0x04, 0xD2}, false},
+		"high limit only": {[]byte{0x01, 0x00, 0x10, 0x08, 0x1A, 0x04, 0xD2}, false},
+	}
+	for name, test := range tests {
+		response, err := s.HandleNPDU(test.request, "192.0.2.9:47808")
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if answered := bytes.Equal(response, iAm); answered != test.answered || (!answered && response != nil) {
+			t.Errorf("%s: response = % X, want answered %v", name, response, test.answered)
+		}
+	}
+}
+
+func TestWritePropertyCharacterSets(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	sensor := model.NewAnalogInput(1, "Sensor", model.UnitsDegreesCelsius)
+	device.AddObject(sensor)
+	s := &BACnetServer{device: device}
+
+	rename := func(value []byte) []byte {
+		request := encodeWritePropertyRequest(sensor.GetObjectIdentifier(), model.PropertyIdentifierObjectName, value, 16)
+		response, _ := s.handleWriteProperty(nil, request, 1)
+		return response
+	}
+
+	// UCS-2客户端写入的对象名转换为UTF-8保存
+	ucs2, _ := encoding.EncodeCharacterStringCharset("送风温度", encoding.CharacterSetUCS2)
+	if got := rename(ucs2); got[0] != BACnetAPDUTypeSimpleAck<<4 {
+		t.Fatalf("UCS-2 rename = % X, want SimpleAck", got)
+	}
+	if name := sensor.GetObjectName(); name != "送风温度" {
+		t.Errorf("object name = %q, want 送风温度", name)
+	}
+	if device.FindObjectByName("送风温度") != sensor {
+		t.Error("name index not updated for UCS-2 rename")
+	}
+
+	got := rename([]byte{0x73, encoding.CharacterSetDBCS, 0x82, 0xA0})
+	want := s.createErrorResponse(1, BACnetServiceConfirmedWriteProperty, ErrorClassProperty, ErrorCodeCharacterSetNotSupported)
+	if !bytes.Equal(got, want) {
+		t.Errorf("DBCS rename = % X, want % X", got, want)
+	}
+}
+
+func TestReadPropertyBitStrings(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	sensor := model.NewAnalogInput(1, "Sensor", model.UnitsDegreesCelsius)
+	device.AddObject(sensor)
+	device.Properties[model.PropertyIdentifierProtocolServicesSupported] = servicesSupported()
+	sensor.Properties[model.PropertyIdentifierAckedTransitions] = uint8(1<<model.TransitionIndexToOffNormal | 1<<model.TransitionIndexToNormal)
+	s := &BACnetServer{device: device}
+
+	read := func(oid model.ObjectIdentifier, prop model.PropertyIdentifier) model.BitString {
+		t.Helper()
+		response, _ := s.handleReadProperty(nil, EncodeReadPropertyRequest(oid, prop, nil), 1)
+		value, _, err := encoding.DecodeApplication(readPropertyAckValue(t, response))
+		bits, ok := value.(model.BitString)
+		if err != nil || !ok {
+			t.Fatalf("property %d = %#v, %v, want BitString", prop, value, err)
+		}
+		return bits
+	}
+
+	services := read(device.GetObjectIdentifier(), model.PropertyIdentifierProtocolServicesSupported)
+	if services.Len() != servicesSupportedCount || !services.Bit(servicesSupportedReadProperty) || !services.Bit(servicesSupportedWhoIs) || services.Bit(13) {
+		t.Errorf("Protocol_Services_Supported = %v", services)
+	}
+	types := read(device.GetObjectIdentifier(), model.PropertyIdentifierProtocolObjectTypesSupported)
+	if !types.Bit(int(model.ObjectTypeAnalogInput)) || !types.Bit(int(model.ObjectTypeDevice)) {
+		t.Errorf("Protocol_Object_Types_Supported = %v", types)
+	}
+	if acked := read(sensor.GetObjectIdentifier(), model.PropertyIdentifierAckedTransitions); !reflect.DeepEqual(acked, model.BitString{true, false, true}) {
+		t.Errorf("Acked_Transitions = %v, want [true false true]", acked)
+	}
+
+	// Status_Flags由Event_State、Reliability和Out_Of_Service推导，变化时以位串通知订阅者
+	recorder := &covRecorder{}
+	sensor.SetNotifier(recorder)
+	sensor.AddCOVSubscription(model.COVSubscription{SubscriptionID: 1, ClientAddress: "192.168.1.10:47808",
+		MonitoredProperties: []model.PropertyIdentifier{model.PropertyIdentifierStatusFlags}})
+	if flags := read(sensor.GetObjectIdentifier(), model.PropertyIdentifierStatusFlags); !reflect.DeepEqual(flags, model.BitString{false, false, false, false}) {
+		t.Errorf("Status_Flags = %v, want all clear", flags)
+	}
+	sensor.SetEventState(model.EventStateHighLimit)
+	sensor.SetReliability(model.ReliabilityOverRange)
+	sensor.WriteProperty(model.PropertyIdentifierOutOfService, true)
+	want := model.BitString{true, true, false, true}
+	if flags := read(sensor.GetObjectIdentifier(), model.PropertyIdentifierStatusFlags); !reflect.DeepEqual(flags, want) {
+		t.Errorf("Status_Flags = %v, want %v", flags, want)
+	}
+	// 通知中Present_Value之后是Status_Flags
+	if len(recorder.values) != 3 || len(recorder.values[2]) != 2 || recorder.values[2][0].PropertyIdentifier != model.PropertyIdentifierPresentValue ||
+		!reflect.DeepEqual(recorder.values[2][1], model.COVValue{PropertyIdentifier: model.PropertyIdentifierStatusFlags, Value: want}) {
+		t.Errorf("Status_Flags COV values = %v", recorder.values)
+	}
+	if reliability, _ := sensor.ReadProperty(model.PropertyIdentifierReliability); reliability != model.ReliabilityOverRange {
+		t.Errorf("Reliability = %#v", reliability)
+	}
+
+	request := EncodeReadPropertyMultipleRequest([]ReadAccessSpecification{{ObjectID: sensor.GetObjectIdentifier(),
+		Properties: []PropertyReference{{PropertyID: model.PropertyIdentifierStatusFlags}}}})
+	response, _ := s.handleReadPropertyMultiple(nil, request, 1)
+	if results, err := DecodeReadPropertyMultipleAck(response[3:]); err != nil || len(results) != 1 || !reflect.DeepEqual(results[0].Value, want) {
+		t.Errorf("ReadPropertyMultiple(Status_Flags) = %+v, %v", results, err)
+	}
+
+	// out-of-range事件参数中的状态标志同样编码为位串
+	parameters := s.encodeEventNotification(model.EventNotification{EventObject: sensor.GetObjectIdentifier(), EventType: model.EventTypeOutOfRange,
+		ToState: model.EventStateHighLimit, EventValues: model.OutOfRangeEventValues{ExceedingValue: 31, StatusFlags: model.StatusFlagInAlarm, ExceededLimit: 30}})
+	if !bytes.Contains(parameters, encoding.EncodeContextBitString(1, model.BitString{true, false, false, false})) {
+		t.Errorf("event notification % X has no status-flags bit string", parameters)
+	}
+}
+
+// covRecorder 记录每个COV通知的属性值列表的通知发送器
+type covRecorder struct {
+	values [][]model.COVValue
+}
+
+func (r *covRecorder) SendCOVNotification(subscription model.COVSubscription, values []model.COVValue) error {
+	r.values = append(r.values, values)
+	return nil
+}
+
+// covValue 返回只有Present_Value的COV通知属性值列表
+func covValue(value interface{}) []model.COVValue {
+	return []model.COVValue{{PropertyIdentifier: model.PropertyIdentifierPresentValue, Value: value}}
+}
+
+func (r *covRecorder) SendEventNotification(notification model.EventNotification) error {
+	return nil
+}
+
+func TestPICS(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	device.Properties[model.PropertyIdentifierProtocolServicesSupported] = servicesSupported()
+	temp := model.NewAnalogInput(1, "Zone \"A\" Temp", model.UnitsDegreesCelsius)
+	temp.UpdatePresentValue(21)
+	device.AddObject(temp)
+	fan := model.NewBinaryOutput(1, "Fan")
+	device.AddObject(fan)
+	s := &BACnetServer{device: device}
+
+	var b bytes.Buffer
+	if _, err := s.PICS().WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	text := b.String()
+	for _, want := range []string{
+		"DS-RP-B\n", "DS-COV-B\n", "DM-DDB-B\n", "T-VMT-I-B\n",
+		"ReadPropertyMultiple               Execute\n",
+		"I-Am                               Initiate\n",
+		"ConfirmedCOVNotification           Initiate\n",
+		"  Analog Input\n", "  Multi-state Value\n", "  Device\n",
+		"object-identifier: (analog-input, 1)\n",
+		`object-name: "Zone 'A' Temp"`,
+		"present-value: 21.0 W\n",
+		"status-flags: {F,F,F,F}\n",
+		"units: degrees-celsius\n",
+		"priority-array: {NULL, NULL",
+		"present-value: inactive W\n",
+		"End of BACnet Protocol Implementation Conformance Statement\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("PICS missing %q:\n%s", want, text)
+		}
+	}
+	for _, unwanted := range []string{"DM-DCC-B", "ReadPropertyConditional", "BACnet IP"} {
+		if strings.Contains(text, unwanted) {
+			t.Errorf("PICS contains %q", unwanted)
+		}
+	}
+}
+
+func TestScheduleConstructedProperties(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	setpoint := model.NewAnalogValue(1, "Setpoint", model.UnitsDegreesCelsius)
+	schedule := model.NewSchedule(1, "Schedule", float32(18))
+	device.AddObject(setpoint)
+	device.AddObject(schedule)
+	s := &BACnetServer{device: device}
+
+	var weekly []byte
+	for day := 0; day < 7; day++ {
+		var content []byte
+		if day < 5 {
+			on, _ := encoding.EncodeTimeValue(model.TimeValue{Time: 8 * time.Hour, Value: float32(22)})
+			off, _ := encoding.EncodeTimeValue(model.TimeValue{Time: 18 * time.Hour})
+			content = append(on, off...)
+		}
+		weekly = append(weekly, encoding.EncodeConstructed(0, content)...)
+	}
+	period := encoding.EncodeDateRange(model.DateRange{StartDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)})
+	refs := encoding.EncodeDeviceObjectPropertyReference(model.DeviceObjectPropertyReference{
+		ObjectIdentifier: setpoint.GetObjectIdentifier(), PropertyIdentifier: model.PropertyIdentifierPresentValue,
+	})
+
+	for _, tt := range []struct {
+		prop  model.PropertyIdentifier
+		value []byte
+	}{
+		{model.PropertyIdentifierWeeklySchedule, weekly},
+		{model.PropertyIdentifierEffectivePeriod, period},
+		{model.PropertyIdentifierListOfObjectPropertyReferences, refs},
+	} {
+		request := encodeWritePropertyRequest(schedule.GetObjectIdentifier(), tt.prop, tt.value, 16)
+		if response, _ := s.handleWriteProperty(nil, request, 1); response[0] != BACnetAPDUTypeSimpleAck<<4 {
+			t.Fatalf("write property %d = % X, want SimpleAck", tt.prop, response)
+		}
+		response, _ := s.handleReadProperty(nil, EncodeReadPropertyRequest(schedule.GetObjectIdentifier(), tt.prop, nil), 2)
+		if got := readPropertyAckValue(t, response); !bytes.Equal(got, tt.value) {
+			t.Errorf("property %d = % X, want % X", tt.prop, got, tt.value)
+		}
+	}
+
+	if got := schedule.WeeklySchedule[0]; len(got) != 2 || got[0].Time != 8*time.Hour || got[0].Value != float32(22) || got[1].Value != nil {
+		t.Errorf("Weekly_Schedule[monday] = %+v", got)
+	}
+	if len(schedule.References) != 1 || schedule.References[0].ObjectIdentifier != setpoint.GetObjectIdentifier() {
+		t.Errorf("References = %+v", schedule.References)
+	}
+
+	// 只有6天的Weekly_Schedule
+	request := encodeWritePropertyRequest(schedule.GetObjectIdentifier(), model.PropertyIdentifierWeeklySchedule, weekly[:len(weekly)-2], 16)
+	if response, _ := s.handleWriteProperty(nil, request, 3); response[0] != BACnetAPDUTypeError<<4 {
+		t.Errorf("short Weekly_Schedule = % X, want Error", response)
+	}
+}
+
+func TestLoopProperties(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	setpoint := model.NewAnalogValue(1, "Setpoint", model.UnitsDegreesCelsius)
+	loop := model.NewLoop(1, "Loop")
+	loop.OutputUnits = model.UnitsPercent
+	loop.ControlledVariableUnits = model.UnitsDegreesCelsius
+	device.AddObject(setpoint)
+	device.AddObject(loop)
+	s := &BACnetServer{device: device}
+
+	reference := encoding.EncodeConstructed(0, encoding.EncodeObjectPropertyReference(model.ObjectPropertyReference{
+		ObjectIdentifier: setpoint.GetObjectIdentifier(), PropertyIdentifier: model.PropertyIdentifierPresentValue,
+	}))
+	for _, tt := range []struct {
+		name  string
+		prop  model.PropertyIdentifier
+		value []byte
+	}{
+		{"Setpoint_Reference", model.PropertyIdentifierSetpointReference, reference},
+		{"empty Setpoint_Reference", model.PropertyIdentifierSetpointReference, nil},
+		{"Setpoint", model.PropertyIdentifierSetpoint, encoding.EncodeReal(21.5)},
+		{"Proportional_Constant", model.PropertyIdentifierProportionalConstant, encoding.EncodeReal(2.5)},
+		{"Integral_Constant", model.PropertyIdentifierIntegralConstant, encoding.EncodeReal(0.1)},
+		{"Derivative_Constant", model.PropertyIdentifierDerivativeConstant, encoding.EncodeReal(0.5)},
+		{"Bias", model.PropertyIdentifierBias, encoding.EncodeReal(10)},
+		{"Maximum_Output", model.PropertyIdentifierMaximumOutput, encoding.EncodeReal(90)},
+		{"Minimum_Output", model.PropertyIdentifierMinimumOutput, encoding.EncodeReal(5)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			request := encodeWritePropertyRequest(loop.GetObjectIdentifier(), tt.prop, tt.value, 16)
+			if response, _ := s.handleWriteProperty(nil, request, 1); response[0] != BACnetAPDUTypeSimpleAck<<4 {
+				t.Fatalf("write = % X, want SimpleAck", response)
+			}
+			response, _ := s.handleReadProperty(nil, EncodeReadPropertyRequest(loop.GetObjectIdentifier(), tt.prop, nil), 2)
+			if got := readPropertyAckValue(t, response); !bytes.Equal(got, tt.value) {
+				t.Errorf("read = % X, want % X", got, tt.value)
+			}
+		})
+	}
+	if loop.SetpointReference != nil {
+		t.Errorf("SetpointReference = %+v, want nil after empty write", loop.SetpointReference)
+	}
+	if loop.Setpoint != 21.5 || loop.MaximumOutput != 90 {
+		t.Errorf("Setpoint = %v, Maximum_Output = %v", loop.Setpoint, loop.MaximumOutput)
+	}
+
+	// Present_Value、单位按REAL和Enumerated编码
+	for _, tt := range []struct {
+		prop model.PropertyIdentifier
+		want []byte
+	}{
+		{model.PropertyIdentifierPresentValue, encoding.EncodeReal(0)},
+		{model.PropertyIdentifierOutputUnits, encoding.EncodeEnumerated(uint32(model.UnitsPercent))},
+		{model.PropertyIdentifierControlledVariableUnits, encoding.EncodeEnumerated(uint32(model.UnitsDegreesCelsius))},
+		{model.PropertyIdentifierControlledVariableValue, encoding.EncodeReal(0)},
+	} {
+		response, _ := s.handleReadProperty(nil, EncodeReadPropertyRequest(loop.GetObjectIdentifier(), tt.prop, nil), 3)
+		if got := readPropertyAckValue(t, response); !bytes.Equal(got, tt.want) {
+			t.Errorf("property %d = % X, want % X", tt.prop, got, tt.want)
+		}
+	}
+}
+
+func TestAccumulatorProperties(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	accumulator := model.NewAccumulator(1, "Meter")
+	converter := model.NewPulseConverter(1, "Energy")
+	idle := model.NewPulseConverter(2, "Idle")
+	for _, obj := range []model.Object{accumulator, converter, idle} {
+		device.AddObject(obj)
+	}
+	s := &BACnetServer{device: device}
+	read := func(obj model.Object, prop model.PropertyIdentifier) []byte {
+		t.Helper()
+		response, _ := s.handleReadProperty(nil, EncodeReadPropertyRequest(obj.GetObjectIdentifier(), prop, nil), 1)
+		return readPropertyAckValue(t, response)
+	}
+
+	// Prescale和Scale只读，按BACnetPrescale和BACnetScale编码，解码得到相同的值
+	floatScale, integerScale := float32(0.5), int32(-2)
+	prescale := model.Prescale{Multiplier: 3, ModuloDivide: 2}
+	for _, tt := range []struct {
+		name  string
+		prop  model.PropertyIdentifier
+		value interface{}
+		want  []byte
+	}{
+		{"Prescale", model.PropertyIdentifierPrescale, prescale, []byte{0x09, 0x03, 0x19, 0x02}},
+		{"floatScale", model.PropertyIdentifierScale, model.Scale{FloatScale: &floatScale}, []byte{0x0C, 0x3F, 0x00, 0x00, 0x00}},
+		{"integerScale", model.PropertyIdentifierScale, model.Scale{IntegerScale: &integerScale}, []byte{0x19, 0xFE}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := accumulator.WriteProperty(tt.prop, tt.value); err != nil {
+				t.Fatal(err)
+			}
+			got := read(accumulator, tt.prop)
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("read = % X, want % X", got, tt.want)
+			}
+			if decoded, _, ok, err := decodeConstructedValue(tt.prop, got); !ok || err != nil || !reflect.DeepEqual(decoded, tt.value) {
+				t.Errorf("decode = %+v, %v, want %+v", decoded, err, tt.value)
+			}
+			request := encodeWritePropertyRequest(accumulator.GetObjectIdentifier(), tt.prop, tt.want, 16)
+			if response, _ := s.handleWriteProperty(nil, request, 2); response[0] != BACnetAPDUTypeError<<4 {
+				t.Errorf("write = % X, want Error", response)
+			}
+		})
+	}
+	if got := accumulator.Scale.Factor(); got != 0.01 {
+		t.Errorf("Scale.Factor() = %v, want 0.01", got)
+	}
+
+	// Scale_Factor和Adjust_Value按REAL读写
+	for _, tt := range []struct {
+		prop  model.PropertyIdentifier
+		value []byte
+	}{
+		{model.PropertyIdentifierScaleFactor, encoding.EncodeReal(0.01)},
+		{model.PropertyIdentifierAdjustValue, encoding.EncodeReal(2.5)},
+	} {
+		request := encodeWritePropertyRequest(converter.GetObjectIdentifier(), tt.prop, tt.value, 16)
+		if response, _ := s.handleWriteProperty(nil, request, 3); response[0] != BACnetAPDUTypeSimpleAck<<4 {
+			t.Fatalf("write property %d = % X, want SimpleAck", tt.prop, response)
+		}
+		if got := read(converter, tt.prop); !bytes.Equal(got, tt.value) {
+			t.Errorf("property %d = % X, want % X", tt.prop, got, tt.value)
+		}
+	}
+	if got := read(converter, model.PropertyIdentifierPresentValue); !bytes.Equal(got, encoding.EncodeReal(2.5)) {
+		t.Errorf("Present_Value after Adjust_Value = % X", got)
+	}
+
+	// Value_Change_Time、Update_Time按BACnetDateTime（Date后接Time）编码
+	changed := time.Date(2024, 3, 1, 8, 30, 15, 0, time.Local)
+	accumulator.SetValue(42, changed)
+	converter.AddCount(1, changed)
+	if got, want := read(accumulator, model.PropertyIdentifierValueChangeTime), encoding.EncodeDateTime(changed); !bytes.Equal(got, want) {
+		t.Errorf("Value_Change_Time = % X, want % X", got, want)
+	}
+	if got, want := read(converter, model.PropertyIdentifierUpdateTime), encoding.EncodeDateTime(changed); !bytes.Equal(got, want) {
+		t.Errorf("Update_Time = % X, want % X", got, want)
+	}
+	// 从未变化的时间编码为全通配符
+	wildcard := []byte{0xA4, 0xFF, 0xFF, 0xFF, 0xFF, 0xB4, 0xFF, 0xFF, 0xFF, 0xFF}
+	if got := read(idle, model.PropertyIdentifierCountChangeTime); !bytes.Equal(got, wildcard) {
+		t.Errorf("Count_Change_Time = % X, want % X", got, wildcard)
+	}
+}
+
+func TestAveragingTimestamps(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	sensor := model.NewAnalogValue(1, "Sensor", model.UnitsDegreesCelsius)
+	averaging := model.NewAveraging(1, "Average", 60, 2)
+	averaging.ObjectPropertyReference = &model.DeviceObjectPropertyReference{
+		ObjectIdentifier: sensor.GetObjectIdentifier(), PropertyIdentifier: model.PropertyIdentifierPresentValue,
+	}
+	device.AddObject(sensor)
+	device.AddObject(averaging)
+	s := &BACnetServer{device: device}
+
+	first := time.Date(2024, 3, 1, 8, 0, 0, 0, time.Local)
+	sensor.WriteProperty(model.PropertyIdentifierPresentValue, float32(18))
+	averaging.Execute(device, first)
+	sensor.WriteProperty(model.PropertyIdentifierPresentValue, float32(21))
+	averaging.Execute(device, first.Add(30*time.Second))
+
+	// Minimum/Maximum_Value_Timestamp为BACnetDateTime：应用标签Date后接Time，没有BACnetTimeStamp的[2]标签
+	for _, tt := range []struct {
+		prop model.PropertyIdentifier
+		want time.Time
+	}{
+		{model.PropertyIdentifierMinimumValueTimestamp, first},
+		{model.PropertyIdentifierMaximumValueTimestamp, first.Add(30 * time.Second)},
+	} {
+		response, _ := s.handleReadProperty(nil, EncodeReadPropertyRequest(averaging.GetObjectIdentifier(), tt.prop, nil), 1)
+		got := readPropertyAckValue(t, response)
+		if want := encoding.EncodeDateTime(tt.want); !bytes.Equal(got, want) {
+			t.Errorf("property %d = % X, want % X", tt.prop, got, want)
+		}
+		if decoded, err := encoding.NewDecoder(got).ApplicationDateTime(); err != nil || !decoded.Equal(tt.want) {
+			t.Errorf("property %d decoded = %v, %v, want %v", tt.prop, decoded, err, tt.want)
+		}
+	}
+}
+
+func TestWriteSignedDoubleOctetString(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	integer := model.NewIntegerValue(1, "Offset", 0)
+	large := model.NewLargeAnalogValue(1, "Energy", 0)
+	octets := model.NewOctetStringValue(1, "Raw", nil)
+	device.AddObject(integer)
+	device.AddObject(large)
+	device.AddObject(octets)
+	s := &BACnetServer{device: device}
+
+	write := func(obj model.Object, value []byte) []byte {
+		request := encodeWritePropertyRequest(obj.GetObjectIdentifier(), model.PropertyIdentifierPresentValue, value, 16)
+		response, _ := s.handleWriteProperty(nil, request, 1)
+		return response
+	}
+	read := func(obj model.Object) []byte {
+		response, _ := s.handleReadProperty(nil, EncodeReadPropertyRequest(obj.GetObjectIdentifier(), model.PropertyIdentifierPresentValue, nil), 1)
+		return readPropertyAckValue(t, response)
+	}
+
+	for _, tt := range []struct {
+		obj   model.Object
+		value []byte
+		want  interface{}
+	}{
+		{integer, encoding.EncodeSigned(-70000), int32(-70000)},
+		{large, encoding.EncodeDouble(1e300), 1e300},
+		{octets, encoding.EncodeOctetString([]byte{0x00, 0xFF, 0x10}), []byte{0x00, 0xFF, 0x10}},
+	} {
+		if got := write(tt.obj, tt.value); got[0] != BACnetAPDUTypeSimpleAck<<4 {
+			t.Fatalf("write % X = % X, want SimpleAck", tt.value, got)
+		}
+		if value, _ := tt.obj.ReadProperty(model.PropertyIdentifierPresentValue); !reflect.DeepEqual(value, tt.want) {
+			t.Errorf("Present_Value = %#v, want %#v", value, tt.want)
+		}
+		if got := read(tt.obj); !bytes.Equal(got, tt.value) {
+			t.Errorf("read back % X, want % X", got, tt.value)
+		}
+	}
+
+	// Real写入Double属性时转换为float64
+	if got := write(large, encoding.EncodeReal(2.5)); got[0] != BACnetAPDUTypeSimpleAck<<4 {
+		t.Fatalf("write Real = % X", got)
+	}
+	if got := read(large); !bytes.Equal(got, encoding.EncodeDouble(2.5)) {
+		t.Errorf("Double after Real write = % X", got)
+	}
+	// 超出Integer Value范围的64位值
+	want := s.createErrorResponse(1, BACnetServiceConfirmedWriteProperty, ErrorClassProperty, ErrorCodeValueOutOfRange)
+	if got := write(integer, encoding.EncodeSigned64(1<<40)); !bytes.Equal(got, want) {
+		t.Errorf("write 64-bit Signed = % X, want % X", got, want)
+	}
+}
+
+func TestMalformedRequestsRejected(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	setpoint := model.NewAnalogValue(1, "Setpoint", model.UnitsNoUnits)
+	device.AddObject(setpoint)
+	s := &BACnetServer{device: device}
+	oid := encoding.EncodeObjectIdentifierValue(setpoint.GetObjectIdentifier())
+
+	tests := []struct {
+		name    string
+		service byte
+		payload []byte
+		reason  byte
+	}{
+		{"ReadProperty truncated tag", BACnetServiceConfirmedReadProperty, []byte{0x0C, 0x00, 0x80}, RejectReasonMissingRequiredParameter},
+		{"ReadProperty missing property", BACnetServiceConfirmedReadProperty, append([]byte{0x0C}, oid...), RejectReasonMissingRequiredParameter},
+		{"ReadProperty trailing data", BACnetServiceConfirmedReadProperty,
+			append(EncodeReadPropertyRequest(setpoint.GetObjectIdentifier(), 85, nil), 0x21, 0x01), RejectReasonTooManyArguments},
+		{"WriteProperty wrong tag", BACnetServiceConfirmedWriteProperty, append([]byte{0x1C}, oid...), RejectReasonInvalidTag},
+		{"WriteProperty unterminated value", BACnetServiceConfirmedWriteProperty,
+			encodeWritePropertyRequest(setpoint.GetObjectIdentifier(), 85, encoding.EncodeReal(1), 8)[:10], RejectReasonMissingRequiredParameter},
+		{"WritePropertyMultiple empty", BACnetServiceConfirmedWritePropertyMultiple, nil, RejectReasonMissingRequiredParameter},
+		{"WritePropertyMultiple missing value", BACnetServiceConfirmedWritePropertyMultiple, append(oid, 0x00, 0x55, 0x10), RejectReasonMissingRequiredParameter},
+		{"AtomicReadFile truncated", BACnetServiceConfirmedAtomicReadFile, append(oid, 0x00, 0x00), RejectReasonMissingRequiredParameter},
+		{"AtomicWriteFile length overflow", BACnetServiceConfirmedAtomicWriteFile,
+			append(oid, 0, 0, 0, 0, 0xFF, 0xFF, 0xFF, 0xFF, 0x01), RejectReasonMissingRequiredParameter},
+		{"SubscribeCOV missing object", BACnetServiceConfirmedSubscribeCOV, []byte{0x09, 0x01}, RejectReasonMissingRequiredParameter},
+		{"SubscribeCOV truncated lifetime", BACnetServiceConfirmedSubscribeCOV,
+			append(encoding.EncodeContextUnsigned(0, 1), append(encoding.EncodeContextObjectIdentifier(1, setpoint.GetObjectIdentifier()), 0x3A, 0x01)...),
+			RejectReasonMissingRequiredParameter},
+		{"SubscribeCOVProperty missing property", BACnetServiceConfirmedSubscribeCOVProperty,
+			append(encoding.EncodeContextUnsigned(0, 1), encoding.EncodeContextObjectIdentifier(1, setpoint.GetObjectIdentifier())...), RejectReasonMissingRequiredParameter},
+		{"AcknowledgeAlarm empty", BACnetServiceConfirmedAcknowledgeAlarm, nil, RejectReasonMissingRequiredParameter},
+		{"ReadRange wrong tag", BACnetServiceConfirmedReadRange, []byte{0x19, 0x55}, RejectReasonInvalidTag},
+		{"LifeSafetyOperation truncated", BACnetServiceConfirmedLifeSafetyOperation, []byte{0x09}, RejectReasonMissingRequiredParameter},
+		{"unknown service", 0x7F, nil, RejectReasonUnrecognizedService},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apdu := append([]byte{BACnetAPDUTypeConfirmedServiceRequest << 4, 0x05, 9, tt.service}, tt.payload...)
+			response, err := s.handleBACnetAPDU(&RequestContext{}, apdu)
+			if want := []byte{BACnetAPDUTypeReject << 4, 9, tt.reason}; err != nil || !bytes.Equal(response, want) {
+				t.Errorf("response = % X, %v; want % X", response, err, want)
+			}
+		})
+	}
+}
+
+func TestSubscribeCOVRequests(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	setpoint := model.NewAnalogValue(1, "Setpoint", model.UnitsNoUnits)
+	device.AddObject(setpoint)
+	s := &BACnetServer{device: device}
+	ctx := &RequestContext{ClientAddr: "192.168.1.10:47808"}
+
+	// subscribe 发送订阅请求，lifetime为nil时为取消订阅，应答必须是SimpleAck
+	subscribe := func(processID uint32, property *model.PropertyIdentifier, lifetime *uint32) {
+		t.Helper()
+		confirmed := true
+		request := SubscribeCOVRequest{SubscriberProcessID: processID, ObjectID: setpoint.GetObjectIdentifier(), Lifetime: lifetime}
+		if lifetime != nil {
+			request.IssueConfirmedNotif = &confirmed
+		}
+		service := byte(BACnetServiceConfirmedSubscribeCOV)
+		payload, err := encoding.Marshal(request)
+		if property != nil {
+			service = BACnetServiceConfirmedSubscribeCOVProperty
+			payload, err = encoding.Marshal(SubscribeCOVPropertyRequest{SubscribeCOVRequest: request, Property: PropertyReference{PropertyID: *property}})
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, err := s.handleBACnetAPDU(ctx, append([]byte{BACnetAPDUTypeConfirmedServiceRequest << 4, 0x05, 9, service}, payload...))
+		if want := encodeSimpleAck(9, service); err != nil || !bytes.Equal(response, want) {
+			t.Fatalf("response = % X, %v; want % X", response, err, want)
+		}
+	}
+	lifetime := func(seconds uint32) *uint32 { return &seconds }
+	presentValue := model.PropertyIdentifierPresentValue
+
+	subscribe(7, nil, lifetime(60))
+	subs := setpoint.COVSubscriptions()
+	if len(subs) != 1 || subs[0].SubscriberProcessID != 7 || !subs[0].IssueConfirmedCOVNotifications || subs[0].Lifetime != 60 ||
+		subs[0].ClientAddress != ctx.ClientAddr || len(subs[0].MonitoredProperties) != 0 {
+		t.Fatalf("subscriptions = %+v", subs)
+	}
+	first := subs[0].SubscriptionID
+
+	// 同一订阅者再次订阅时更新原有的订阅，其他进程和属性订阅是单独的订阅
+	subscribe(7, nil, lifetime(120))
+	subscribe(8, nil, lifetime(60))
+	subscribe(7, &presentValue, lifetime(60))
+	subs = setpoint.COVSubscriptions()
+	if len(subs) != 3 {
+		t.Fatalf("%d subscriptions, want 3: %+v", len(subs), subs)
+	}
+	renewed := subs[len(subs)-3]
+	if renewed.SubscriberProcessID != 7 || renewed.Lifetime != 120 || renewed.SubscriptionID != first {
+		t.Errorf("renewed subscription = %+v", renewed)
+	}
+	if property := subs[2]; property.SubscriberProcessID != 7 || !reflect.DeepEqual(property.MonitoredProperties, []model.PropertyIdentifier{presentValue}) {
+		t.Errorf("property subscription = %+v", property)
+	}
+
+	// 不带确认通知标志和有效期的请求取消订阅，取消不存在的订阅同样成功
+	subscribe(7, nil, nil)
+	subscribe(9, nil, nil)
+	subs = setpoint.COVSubscriptions()
+	if len(subs) != 2 || subs[0].SubscriberProcessID != 8 || subs[1].SubscriberProcessID != 7 || len(subs[1].MonitoredProperties) != 1 {
+		t.Errorf("subscriptions after cancellation = %+v", subs)
+	}
+
+	// 通知携带订阅者进程ID、完整的对象标识符、剩余时间和BACnetPropertyValue列表
+	parameters, err := s.encodeCOVNotificationParameters(subs[0], covValue(float32(2.5)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append(encoding.EncodeContextUnsigned(0, 8), encoding.EncodeContextObjectIdentifier(1, device.GetObjectIdentifier())...)
+	want = append(want, encoding.EncodeContextObjectIdentifier(2, setpoint.GetObjectIdentifier())...)
+	if !bytes.HasPrefix(parameters, want) {
+		t.Errorf("notification parameters = % X, want prefix % X", parameters, want)
+	}
+	values := append(encoding.EncodeContextEnumerated(0, uint32(presentValue)), encoding.EncodeConstructed(2, encoding.EncodeReal(2.5))...)
+	if !bytes.HasSuffix(parameters, encoding.EncodeConstructed(4, values)) {
+		t.Errorf("notification parameters = % X, want values % X", parameters, values)
+	}
+	var notification COVNotification
+	if err := encoding.Unmarshal(parameters, &notification); err != nil || notification.TimeRemaining == 0 || notification.TimeRemaining > 60 {
+		t.Errorf("notification = %+v, %v", notification, err)
+	}
+}
+
+// FuzzProcessBACnetMessage 任意数据报都不能使服务器panic
+func FuzzProcessBACnetMessage(f *testing.F) {
+	device := model.NewDevice(1, "Fuzz Device", "")
+	device.AddObject(model.NewAnalogValue(1, "Setpoint", model.UnitsNoUnits))
+	device.AddObject(model.NewBACnetFile(1, "Log", model.FileAccessMethodStream))
+	s := &BACnetServer{device: device}
+
+	frame := func(apdu []byte) []byte {
+		return encodeBVLC(BVLCOriginalUnicastNPDU, append([]byte{0x01, 0x04}, apdu...))
+	}
+	confirmed := func(service byte, payload []byte) []byte {
+		return frame(append([]byte{0x00, 0x05, 1, service}, payload...))
+	}
+	oid := model.ObjectIdentifier{Type: model.ObjectTypeAnalogValue, Instance: 1}
+	f.Add([]byte{0x81, 0x0b, 0x00, 0x08, 0x01, 0x00, 0x10, 0x08})
+	f.Add(confirmed(BACnetServiceConfirmedReadProperty, EncodeReadPropertyRequest(oid, 85, nil)))
+	f.Add(confirmed(BACnetServiceConfirmedWriteProperty, encodeWritePropertyRequest(oid, 85, encoding.EncodeReal(1), 8)))
+	f.Add(confirmed(BACnetServiceConfirmedReadPropertyMultiple, []byte{0x0C, 0x00, 0x80, 0x00, 0x01, 0x1E, 0x09, 0x55, 0x1F}))
+	lifetime := uint32(60)
+	subscribe, _ := encoding.Marshal(SubscribeCOVPropertyRequest{
+		SubscribeCOVRequest: SubscribeCOVRequest{SubscriberProcessID: 1, ObjectID: oid, Lifetime: &lifetime},
+		Property:            PropertyReference{PropertyID: model.PropertyIdentifierPresentValue},
+	})
+	f.Add(confirmed(BACnetServiceConfirmedSubscribeCOVProperty, subscribe))
+	f.Add(frame([]byte{0x3C, 7, 2, 4, BACnetServiceConfirmedReadPropertyMultiple}))
+	f.Add(encodeBVLC(BVLCOriginalUnicastNPDU, []byte{0x01, 0x28, 0x00, 0x05, 0x01, 0x0A, 0x00, 0x01, 0x01, 0xFF, 0x10, 0x08}))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		s.processBACnetMessage(&RequestContext{}, data)
+	})
+}
+
+func TestProcessDatagramRecoversPanic(t *testing.T) {
+	// 没有设备时处理ReadProperty会panic
+	s := &BACnetServer{}
+	dir := filepath.Join(t.TempDir(), "quarantine")
+	s.SetQuarantineDir(dir)
+	oid := model.ObjectIdentifier{Type: model.ObjectTypeAnalogValue, Instance: 1}
+	data := encodeBVLC(BVLCOriginalUnicastNPDU, append([]byte{0x01, 0x04, 0x00, 0x05, 1, BACnetServiceConfirmedReadProperty},
+		EncodeReadPropertyRequest(oid, model.PropertyIdentifierPresentValue, nil)...))
+
+	for i := 1; i <= 2; i++ {
+		response, err := s.processDatagram(&RequestContext{ClientAddr: "[::1]:47808"}, data)
+		if err == nil || response != nil {
+			t.Fatalf("processDatagram() = % X, %v; want panic recovered as error", response, err)
+		}
+		if got := s.MalformedPackets(); got != uint64(i) {
+			t.Errorf("MalformedPackets() = %d, want %d", got, i)
+		}
+	}
+
+	saved, err := filepath.Glob(filepath.Join(dir, "*.bin"))
+	if err != nil || len(saved) != 2 {
+		t.Fatalf("quarantined files = %v, %v", saved, err)
+	}
+	if got, _ := os.ReadFile(saved[0]); !bytes.Equal(got, data) {
+		t.Errorf("quarantined datagram = % X, want % X", got, data)
+	}
+	if report, _ := os.ReadFile(strings.TrimSuffix(saved[0], ".bin") + ".txt"); !bytes.Contains(report, []byte("[::1]:47808")) {
+		t.Errorf("quarantine report = %s", report)
+	}
+
+	// 正常报文不计数
+	if _, err := s.processDatagram(&RequestContext{ClientAddr: "127.0.0.1:47808"}, []byte{0x81, 0x0b, 0x00, 0x08, 0x01, 0x00, 0x10, 0x08}); err != nil || s.MalformedPackets() != 2 {
+		t.Errorf("Who-Is: err = %v, MalformedPackets() = %d", err, s.MalformedPackets())
+	}
+}
+
+func TestBVLCFunctions(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	setpoint := model.NewAnalogValue(1, "Setpoint", model.UnitsNoUnits)
+	device.AddObject(setpoint)
+	s := &BACnetServer{device: device}
+
+	// Forwarded-NPDU：应答发给原始发送方，并带有BVLC和NPDU头部
+	apdu := append([]byte{0x00, 0x05, 3, BACnetServiceConfirmedReadProperty},
+		EncodeReadPropertyRequest(setpoint.GetObjectIdentifier(), model.PropertyIdentifierPresentValue, nil)...)
+	forwarded := encodeBVLC(BVLCForwardedNPDU, append([]byte{192, 168, 1, 20, 0xBA, 0xC0, 0x01, 0x04}, apdu...))
+	ctx := &RequestContext{ClientAddr: "10.0.0.2:47808"}
+	response, err := s.processBACnetMessage(ctx, forwarded)
+	if err != nil {
+		t.Fatalf("Forwarded-NPDU: %v", err)
+	}
+	if len(response) < 7 || !bytes.Equal(response[:2], []byte{BVLCTypeBACnetIP, BVLCOriginalUnicastNPDU}) ||
+		int(response[2])<<8|int(response[3]) != len(response) || response[6]>>4 != BACnetAPDUTypeComplexAck {
+		t.Errorf("Forwarded-NPDU response = % X", response)
+	}
+	if want := "192.168.1.20:47808"; ctx.ClientAddr != want || ctx.ReplyAddr.String() != want {
+		t.Errorf("reply address = %s, %v; want %s", ctx.ClientAddr, ctx.ReplyAddr, want)
+	}
+
+	// 截断的Forwarded-NPDU
+	if _, err := s.processBACnetMessage(&RequestContext{}, encodeBVLC(BVLCForwardedNPDU, []byte{192, 168, 1})); !errors.Is(err, encoding.ErrTruncated) {
+		t.Errorf("truncated Forwarded-NPDU: err = %v", err)
+	}
+
+	// 本设备不是BBMD，BBMD功能以NAK应答
+	response, err = s.processBACnetMessage(&RequestContext{}, encodeBVLC(BVLCRegisterForeignDevice, []byte{0x00, 0x3C}))
+	if want := []byte{0x81, 0x00, 0x00, 0x06, 0x00, 0x30}; err != nil || !bytes.Equal(response, want) {
+		t.Errorf("Register-Foreign-Device response = % X, %v; want % X", response, err, want)
+	}
+
+	// 收到的BVLC-Result不应答
+	response, err = s.processBACnetMessage(&RequestContext{}, encodeBVLCResult(BVLCResultSuccessfulCompletion))
+	if err != nil || response != nil {
+		t.Errorf("BVLC-Result response = % X, %v", response, err)
+	}
+
+	// 长度字段与数据报长度不一致
+	if _, err := s.processBACnetMessage(&RequestContext{}, []byte{0x81, 0x0a, 0x00, 0x09, 0x01, 0x00}); err == nil {
+		t.Error("length mismatch: expected error")
+	}
+}
+
+func TestBBMD(t *testing.T) {
+	s := &BACnetServer{transport: addrTransport{local: &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 47808}}}
+
+	// 未启用BBMD时拒绝BDT操作
+	if response, _ := s.processBACnetMessage(&RequestContext{}, encodeBVLC(BVLCReadBroadcastDistributionTable, nil)); !bytes.Equal(response, encodeBVLCResult(BVLCResultReadBDTNAK)) {
+		t.Errorf("Read-BDT without BBMD = % X", response)
+	}
+
+	var entries []BDTEntry
+	for _, text := range []string{"10.0.0.1:47808", "10.0.1.1:47808", "10.0.2.1:47809/255.255.255.0"} {
+		entry, err := ParseBDTEntry(text)
+		if err != nil {
+			t.Fatalf("ParseBDTEntry(%q): %v", text, err)
+		}
+		entries = append(entries, entry)
+	}
+	if _, err := ParseBDTEntry("10.0.0.1:47808/255.255"); err == nil {
+		t.Error("ParseBDTEntry accepted a short mask")
+	}
+	s.SetBroadcastDistributionTable(entries[:1])
+
+	// Write-BDT替换整个表，Read-BDT-Ack按写入的格式返回
+	var table []byte
+	for _, entry := range entries {
+		table = append(table, encodeBIPAddress(entry.Address)...)
+		table = append(table, entry.Mask...)
+	}
+	response, err := s.processBACnetMessage(&RequestContext{}, encodeBVLC(BVLCWriteBroadcastDistributionTable, table))
+	if err != nil || !bytes.Equal(response, encodeBVLCResult(BVLCResultSuccessfulCompletion)) {
+		t.Fatalf("Write-BDT = % X, %v", response, err)
+	}
+	if got := s.BroadcastDistributionTable(); len(got) != 3 || got[2].String() != "10.0.2.1:47809/255.255.255.0" {
+		t.Errorf("BDT = %v", got)
+	}
+	response, _ = s.processBACnetMessage(&RequestContext{}, encodeBVLC(BVLCReadBroadcastDistributionTable, nil))
+	if want := encodeBVLC(BVLCReadBroadcastDistributionTableAck, table); !bytes.Equal(response, want) {
+		t.Errorf("Read-BDT = % X, want % X", response, want)
+	}
+	if response, _ := s.processBACnetMessage(&RequestContext{}, encodeBVLC(BVLCWriteBroadcastDistributionTable, table[:7])); !bytes.Equal(response, encodeBVLCResult(BVLCResultWriteBDTNAK)) {
+		t.Errorf("malformed Write-BDT = % X", response)
+	}
+
+	// 本地广播转发给除自身外的BBMD，掩码非全1时发往对端子网的定向广播地址
+	npdu := []byte{0x01, 0x00, 0x10, 0x08}
+	origin := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 7), Port: 47808}
+	frames := s.broadcastForwards(npdu, origin)
+	forwarded := encodeBVLC(BVLCForwardedNPDU, append([]byte{10, 0, 0, 7, 0xBA, 0xC0}, npdu...))
+	if len(frames) != 2 || frames[0].addr.String() != "10.0.1.1:47808" || frames[1].addr.String() != "10.0.2.255:47809" ||
+		!bytes.Equal(frames[0].data, forwarded) {
+		t.Errorf("broadcastForwards = %+v", frames)
+	}
+
+	// 两跳分发的对端转发在本地子网广播，定向广播的对端和未知来源不再转发
+	if frames := s.peerForwards(npdu, origin, entries[1].Address); len(frames) != 1 || !frames[0].addr.IP.Equal(net.IPv4bcast) || !bytes.Equal(frames[0].data, forwarded) {
+		t.Errorf("peerForwards(two-hop) = %+v", frames)
+	}
+	for _, from := range []*net.UDPAddr{entries[0].Address, entries[2].Address, {IP: net.IPv4(10, 9, 9, 9), Port: 47808}} {
+		if frames := s.peerForwards(npdu, origin, from); len(frames) != 0 {
+			t.Errorf("peerForwards(from %s) = %+v", from, frames)
+		}
+	}
+}
+
+func TestForeignDeviceTable(t *testing.T) {
+	s := &BACnetServer{transport: addrTransport{local: &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 47808}}}
+	fd := &net.UDPAddr{IP: net.IPv4(172, 16, 0, 9), Port: 47808}
+	ctx := &RequestContext{ClientAddr: fd.String(), ReplyAddr: fd}
+
+	// 未启用BBMD时拒绝注册
+	register := encodeBVLC(BVLCRegisterForeignDevice, []byte{0x00, 0x3C})
+	if response, _ := s.processBACnetMessage(ctx, register); !bytes.Equal(response, encodeBVLCResult(BVLCResultRegisterForeignDeviceNAK)) {
+		t.Errorf("Register-Foreign-Device without BBMD = % X", response)
+	}
+
+	peer, _ := ParseBDTEntry("10.0.1.1:47808")
+	s.SetBroadcastDistributionTable([]BDTEntry{peer})
+
+	// 未注册的设备不能分发广播
+	whoIs := []byte{0x01, 0x00, 0x10, 0x08}
+	if response, _ := s.processBACnetMessage(ctx, encodeBVLC(BVLCDistributeBroadcastToNetwork, whoIs)); !bytes.Equal(response, encodeBVLCResult(BVLCResultDistributeBroadcastToNetNAK)) {
+		t.Errorf("Distribute-Broadcast from unregistered device = % X", response)
+	}
+
+	if response, _ := s.processBACnetMessage(ctx, register); !bytes.Equal(response, encodeBVLCResult(BVLCResultSuccessfulCompletion)) {
+		t.Fatalf("Register-Foreign-Device = % X", response)
+	}
+	response, _ := s.processBACnetMessage(ctx, encodeBVLC(BVLCReadForeignDeviceTable, nil))
+	if len(response) != 14 || response[1] != BVLCReadForeignDeviceTableAck ||
+		!bytes.Equal(response[4:12], []byte{172, 16, 0, 9, 0xBA, 0xC0, 0x00, 0x3C}) || int(response[12])<<8|int(response[13]) > 90 {
+		t.Errorf("Read-FDT = % X", response)
+	}
+
+	// 本地广播转发给对端BBMD和外部设备
+	origin := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 7), Port: 47808}
+	if frames := s.broadcastForwards(whoIs, origin); len(frames) != 2 || frames[1].addr != fd {
+		t.Errorf("broadcastForwards = %+v", frames)
+	}
+	// 外部设备分发的广播不再发回给它自己
+	if frames := s.broadcastForwards(whoIs, fd); len(frames) != 1 || frames[0].addr.String() != "10.0.1.1:47808" {
+		t.Errorf("broadcastForwards from foreign device = %+v", frames)
+	}
+	if response, err := s.processBACnetMessage(ctx, encodeBVLC(BVLCDistributeBroadcastToNetwork, whoIs)); err != nil || len(response) != 0 {
+		t.Errorf("Distribute-Broadcast = % X, %v", response, err)
+	}
+
+	// TTL加宽限时间后过期
+	now := time.Now()
+	if len(s.foreignDevices(now.Add(85*time.Second))) != 1 || len(s.foreignDevices(now.Add(91*time.Second))) != 0 {
+		t.Errorf("foreign device expiry: %v", s.ForeignDeviceTable())
+	}
+
+	s.registerForeignDevice(fd, 60, now)
+	deleteEntry := encodeBVLC(BVLCDeleteForeignDeviceTableEntry, []byte{172, 16, 0, 9, 0xBA, 0xC0})
+	if response, _ := s.processBACnetMessage(ctx, deleteEntry); !bytes.Equal(response, encodeBVLCResult(BVLCResultSuccessfulCompletion)) {
+		t.Errorf("Delete-FDT-Entry = % X", response)
+	}
+	if response, _ := s.processBACnetMessage(ctx, deleteEntry); !bytes.Equal(response, encodeBVLCResult(BVLCResultDeleteFDTEntryNAK)) {
+		t.Errorf("Delete-FDT-Entry of missing entry = % X", response)
+	}
+}
+
+func TestForeignDeviceRegistration(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	bbmd, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bbmd.Close()
+	bbmdAddr := bbmd.LocalAddr().(*net.UDPAddr)
+
+	s := &BACnetServer{device: model.NewDevice(260001, "Test Device", ""), transport: &UDPTransport{conn: conn}}
+	if err := s.RegisterAsForeignDevice(bbmdAddr.String(), 0); err == nil {
+		t.Error("RegisterAsForeignDevice() with zero TTL: want error")
+	}
+	if err := s.RegisterAsForeignDevice(bbmdAddr.String(), 300); err != nil {
+		t.Fatal(err)
+	}
+
+	receive := func() []byte {
+		t.Helper()
+		bbmd.SetReadDeadline(time.Now().Add(time.Second))
+		buffer := make([]byte, 1500)
+		n, _, err := bbmd.ReadFromUDP(buffer)
+		if err != nil {
+			t.Fatalf("nothing received by BBMD: %v", err)
+		}
+		return buffer[:n]
+	}
+
+	s.registerWithBBMD()
+	if got, want := receive(), []byte{0x81, 0x05, 0x00, 0x06, 0x01, 0x2C}; !bytes.Equal(got, want) {
+		t.Errorf("Register-Foreign-Device = % X, want % X", got, want)
+	}
+
+	// 注册被接受后经BBMD广播I-Am，续订成功不再重复广播
+	for i := 0; i < 2; i++ {
+		if _, err := s.processBACnetMessage(&RequestContext{ClientAddr: bbmdAddr.String(), ReplyAddr: bbmdAddr}, encodeBVLCResult(BVLCResultSuccessfulCompletion)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	iAm := encodeBVLC(BVLCDistributeBroadcastToNetwork, append([]byte{0x01, 0x00}, s.encodeIAm()...))
+	if got := receive(); !bytes.Equal(got, iAm) {
+		t.Errorf("I-Am broadcast = % X, want % X", got, iAm)
+	}
+	if want := []byte{0x10, 0x00, 0xC4, 0x02, 0x03, 0xF7, 0xA1, 0x22, 0x04, 0x00, 0x91, 0x01, 0x21, 0x00}; !bytes.Equal(s.encodeIAm(), want) {
+		t.Errorf("encodeIAm() = % X, want % X", s.encodeIAm(), want)
+	}
+	bbmd.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if n, _, err := bbmd.ReadFromUDP(make([]byte, 1500)); err == nil {
+		t.Errorf("unexpected second broadcast of %d bytes", n)
+	}
+}
+
+func TestHandleNPDU(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	setpoint := model.NewAnalogValue(1, "Setpoint", model.UnitsNoUnits)
+	device.AddObject(setpoint)
+	s := &BACnetServer{device: device}
+
+	// 其他数据链路上的应答只有NPDU，不带BVLC头部
+	request := append([]byte{0x01, 0x04, 0x00, 0x05, 7, BACnetServiceConfirmedReadProperty},
+		EncodeReadPropertyRequest(setpoint.GetObjectIdentifier(), model.PropertyIdentifierObjectName, nil)...)
+	response, err := s.HandleNPDU(request, "mstp:9")
+	if err != nil || len(response) < 3 || !bytes.Equal(response[:2], []byte{0x01, 0x00}) || response[2]>>4 != BACnetAPDUTypeComplexAck {
+		t.Errorf("HandleNPDU(ReadProperty) = % X, %v", response, err)
+	}
+
+	// 订阅记录请求所在数据链路上的客户端地址
+	lifetime := uint32(60)
+	payload, _ := encoding.Marshal(SubscribeCOVRequest{SubscriberProcessID: 1, ObjectID: setpoint.GetObjectIdentifier(), Lifetime: &lifetime})
+	subscribe := append([]byte{0x01, 0x04, 0x00, 0x05, 8, BACnetServiceConfirmedSubscribeCOV}, payload...)
+	if _, err := s.HandleNPDU(subscribe, "mstp:9"); err != nil || len(setpoint.Subscriptions) != 1 || setpoint.Subscriptions[0].ClientAddress != "mstp:9" {
+		t.Errorf("SubscribeCOV via HandleNPDU: %v, subscriptions = %+v", err, setpoint.Subscriptions)
+	}
+
+	whoIs := []byte{0x01, 0x00, BACnetAPDUTypeUnconfirmedServiceRequest << 4, BACnetServiceUnconfirmedWhoIs}
+	if response, err := s.HandleNPDU(whoIs, "mstp:9"); err != nil || !bytes.Equal(response, append([]byte{0x01, 0x00}, s.encodeIAm()...)) {
+		t.Errorf("HandleNPDU(Who-Is) = % X, %v", response, err)
+	}
+	if _, err := s.HandleNPDU([]byte{0x02}, "mstp:9"); err == nil {
+		t.Error("HandleNPDU() with bad NPDU version: want error")
+	}
+}
+
+// addrTransport 只提供本地地址的传输，发送的数据报被丢弃
+type addrTransport struct {
+	local *net.UDPAddr
+}
+
+func (t addrTransport) ReadFrom(p []byte) (int, net.Addr, error)     { return 0, nil, net.ErrClosed }
+func (t addrTransport) WriteTo(p []byte, addr net.Addr) (int, error) { return len(p), nil }
+func (t addrTransport) LocalAddr() net.Addr                          { return t.local }
+func (t addrTransport) BroadcastAddr() net.Addr {
+	return &net.UDPAddr{IP: net.IPv4bcast, Port: t.local.Port}
+}
+func (t addrTransport) ResolveAddr(address string) (net.Addr, error) {
+	return net.ResolveUDPAddr("udp", address)
+}
+func (t addrTransport) MTU() int     { return bvllMaxLength }
+func (t addrTransport) Close() error { return nil }
+
+func TestUDPTransport(t *testing.T) {
+	transport, err := NewUDPTransport("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.Close()
+	local := transport.LocalAddr().(*net.UDPAddr)
+	if got := transport.BroadcastAddr().String(); got != fmt.Sprintf("255.255.255.255:%d", local.Port) {
+		t.Errorf("BroadcastAddr() = %s", got)
+	}
+	device := model.NewDevice(1, "Test Device", "")
+	s, err := newBACnetServer(device, transport, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Start(context.Background())
+	defer s.Stop()
+
+	// 经传输收到的Who-Is以I-Am应答
+	client, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if _, err := client.WriteToUDP([]byte{0x81, 0x0a, 0x00, 0x08, 0x01, 0x00, 0x10, 0x08}, local); err != nil {
+		t.Fatal(err)
+	}
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	buffer := make([]byte, transport.MTU())
+	n, _, err := client.ReadFromUDP(buffer)
+	if want := s.createIAmResponse(); err != nil || !bytes.Equal(buffer[:n], want) {
+		t.Errorf("response = % X, %v; want % X", buffer[:n], err, want)
+	}
+}
+
+func TestLoadTest(t *testing.T) {
+	network := NewLoopbackNetwork()
+	device := model.NewDevice(1234, "Load Device", "")
+	setpoint := model.NewAnalogValue(1, "Setpoint", model.UnitsDegreesCelsius)
+	setpoint.WriteProperty(model.PropertyIdentifierPresentValue, float32(21))
+	fan := model.NewBinaryValue(1, "Fan")
+	device.AddObject(setpoint)
+	device.AddObject(fan)
+	server, err := newBACnetServer(device, network.Attach(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Start(context.Background())
+	defer server.Stop()
+	serverAddr := server.transport.LocalAddr()
+
+	var clients []*TestClient
+	for i := 0; i < 4; i++ {
+		client := NewTestClient(network.Attach(), 200*time.Millisecond)
+		defer client.Close()
+		clients = append(clients, client)
+	}
+
+	// ReadPropertyMultiple按应答顺序返回每个属性的值或错误
+	results, err := clients[0].ReadPropertyMultiple(serverAddr, []ReadAccessSpecification{
+		{ObjectID: setpoint.GetObjectIdentifier(), Properties: []PropertyReference{{PropertyID: model.PropertyIdentifierPresentValue}, {PropertyID: model.PropertyIdentifierObjectName}}},
+		{ObjectID: model.ObjectIdentifier{Type: model.ObjectTypeAnalogValue, Instance: 9}, Properties: []PropertyReference{{PropertyID: model.PropertyIdentifierPresentValue}}},
+	})
+	var bacnetErr *Error
+	if err != nil || len(results) != 3 || results[0].Value != float32(21) || results[1].Value != "Setpoint" ||
+		!errors.As(results[2].Err, &bacnetErr) || bacnetErr.Code != ErrorCodeObjectNotExist {
+		t.Fatalf("ReadPropertyMultiple() = %+v, %v", results, err)
+	}
+	if found, err := clients[0].Locate(serverAddr, 1234); err != nil || found.ID != device.GetObjectIdentifier() {
+		t.Errorf("Locate() = %+v, %v", found, err)
+	}
+
+	mix, err := ParseLoadMix("whois=1, rp=4, rpm=2, wp=2, cov=1")
+	if err != nil || mix != (LoadMix{1, 4, 2, 2, 1}) || mix.String() != "whois=1,rp=4,rpm=2,wp=2,cov=1" {
+		t.Fatalf("ParseLoadMix() = %v, %v", mix, err)
+	}
+	test := &LoadTest{
+		Target:   serverAddr,
+		Device:   1234,
+		Objects:  []model.ObjectIdentifier{setpoint.GetObjectIdentifier(), fan.GetObjectIdentifier()},
+		Mix:      mix,
+		Rate:     500,
+		Duration: 300 * time.Millisecond,
+		Priority: 16,
+		Lifetime: 10,
+	}
+	report, err := test.Run(context.Background(), clients)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total.Requests+report.Skipped < 100 || report.Total.Errors != 0 || len(report.Services) != 5 {
+		t.Errorf("report = %+v", report)
+	}
+	if report.Total.P50 <= 0 || report.Total.P50 > report.Total.P99 || report.Total.P99 > report.Total.Max {
+		t.Errorf("percentiles = %v %v %v", report.Total.P50, report.Total.P99, report.Total.Max)
+	}
+	var out bytes.Buffer
+	report.WriteTo(&out)
+	if !strings.Contains(out.String(), "rpm") || !strings.Contains(out.String(), "requests in") {
+		t.Errorf("report:\n%s", out.String())
+	}
+
+	for _, text := range []string{"rp", "rp=x", "bacnet=1", "rp=0"} {
+		if _, err := ParseLoadMix(text); err == nil {
+			t.Errorf("ParseLoadMix(%q): want error", text)
+		}
+	}
+	test.Objects = []model.ObjectIdentifier{{Type: model.ObjectTypeAnalogValue, Instance: 9}}
+	if _, err := test.Run(context.Background(), clients); err == nil {
+		t.Error("Run() with a missing object: want error")
+	}
+}
+
+func TestLoopbackEndToEnd(t *testing.T) {
+	network := NewLoopbackNetwork()
+	device := model.NewDevice(1234, "Loopback Device", "")
+	sensor := model.NewAnalogValue(1, "Sensor", model.UnitsDegreesCelsius)
+	sensor.UpdatePresentValue(21.5)
+	device.AddObject(sensor)
+	server, err := newBACnetServer(device, network.Attach(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Start(context.Background())
+	defer server.Stop()
+	serverAddr := server.transport.LocalAddr()
+
+	client := NewTestClient(network.Attach(), 200*time.Millisecond)
+	defer client.Close()
+
+	devices, err := client.WhoIs()
+	if err != nil || len(devices) != 1 || devices[0] != device.GetObjectIdentifier() {
+		t.Fatalf("WhoIs() = %v, %v", devices, err)
+	}
+
+	value, err := client.ReadProperty(serverAddr, sensor.GetObjectIdentifier(), model.PropertyIdentifierPresentValue)
+	if err != nil || value != float32(21.5) {
+		t.Fatalf("ReadProperty(Present_Value) = %v (%T), %v", value, value, err)
+	}
+	if _, err := client.ReadProperty(serverAddr, model.ObjectIdentifier{Type: model.ObjectTypeAnalogValue, Instance: 99}, model.PropertyIdentifierPresentValue); err == nil {
+		t.Error("ReadProperty() of missing object: want error")
+	}
+
+	// 订阅后数值变化产生未确认COV通知
+	if err := client.SubscribeCOV(serverAddr, 1, sensor.GetObjectIdentifier(), 300, false); err != nil {
+		t.Fatalf("SubscribeCOV() = %v", err)
+	}
+	server.SimulateDataChange(sensor.GetObjectIdentifier(), model.PropertyIdentifierPresentValue, 30.0)
+	notification, err := client.Notification()
+	if err != nil || notification.PDUType != BACnetAPDUTypeUnconfirmedServiceRequest || *notification.ServiceChoice != BACnetServiceUnconfirmedCOVNotification {
+		t.Fatalf("Notification() = %v, %v", notification, err)
+	}
+	cov, err := ParseCOVNotification(notification)
+	if err != nil || cov.SubscriberProcessID != 1 || cov.InitiatingDevice != device.GetObjectIdentifier() || cov.MonitoredObject != sensor.GetObjectIdentifier() ||
+		cov.TimeRemaining == 0 || cov.TimeRemaining > 300 {
+		t.Errorf("ParseCOVNotification() = %+v, %v", cov, err)
+	}
+	if value, ok, err := cov.Value(model.PropertyIdentifierPresentValue); !ok || err != nil || value != float32(30) {
+		t.Errorf("COV notification Present_Value = %v, %v, %v", value, ok, err)
+	}
+	// Present_Value之后是以位串编码的Status_Flags
+	if len(cov.Values) != 2 || cov.Values[0].PropertyID != model.PropertyIdentifierPresentValue || cov.Values[1].PropertyID != model.PropertyIdentifierStatusFlags ||
+		!bytes.Equal(cov.Values[1].Value, encoding.EncodeBitString(model.BitString{false, false, false, false})) {
+		t.Errorf("COV notification values = %+v", cov.Values)
+	}
+	if flags, ok, err := cov.Value(model.PropertyIdentifierStatusFlags); !ok || err != nil || !reflect.DeepEqual(flags, model.BitString{false, false, false, false}) {
+		t.Errorf("COV notification Status_Flags = %v (%T), %v, %v", flags, flags, ok, err)
+	}
+
+	found, err := client.Discover(serverAddr, 1000, 2000)
+	if err != nil || len(found) != 1 || found[0].ID != device.GetObjectIdentifier() || found[0].Address.String() != serverAddr.String() ||
+		found[0].MaxAPDU != 1024 || found[0].Segmentation != 1 {
+		t.Errorf("Discover() = %+v, %v", found, err)
+	}
+	if size, err := client.ReadPropertyIndex(serverAddr, device.GetObjectIdentifier(), model.PropertyIdentifierObjectList, 0); err != nil || size != uint32(2) {
+		t.Errorf("ReadPropertyIndex(Object_List, 0) = %v, %v", size, err)
+	}
+	if err := client.WriteProperty(serverAddr, sensor.GetObjectIdentifier(), model.PropertyIdentifierPresentValue, float32(25), 8); err != nil {
+		t.Fatalf("WriteProperty() = %v", err)
+	}
+	if slot := sensor.PriorityArray(model.PropertyIdentifierPresentValue)[7]; slot != float32(25) {
+		t.Errorf("priority 8 = %v", slot)
+	}
+	var bacnetErr *Error
+	err = client.WriteProperty(serverAddr, model.ObjectIdentifier{Type: model.ObjectTypeAnalogValue, Instance: 99}, model.PropertyIdentifierPresentValue, float32(1), 0)
+	if !errors.As(err, &bacnetErr) || bacnetErr.Code != ErrorCodeUnknownObject {
+		t.Errorf("WriteProperty() of missing object = %v", err)
+	}
+
+	// 关闭后的传输从网段移除
+	other := network.Attach()
+	other.Close()
+	if _, err := other.WriteTo([]byte{0x81}, serverAddr); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("WriteTo() after Close = %v", err)
+	}
+}
+
+func TestWritePropertyEnumeratedEndToEnd(t *testing.T) {
+	network := NewLoopbackNetwork()
+	device := model.NewDevice(1234, "Loopback Device", "")
+	loop := model.NewLoop(1, "Loop")
+	fan := model.NewBinaryOutput(1, "Fan")
+	zone := model.NewLifeSafetyZone(1, "Zone")
+	trend := model.NewTrendLog(1, "Trend", 10)
+	trends := model.NewTrendLogMultiple(1, "Trends", 10)
+	for _, obj := range []model.Object{loop, fan, zone, trend, trends} {
+		device.AddObject(obj)
+	}
+	server, err := newBACnetServer(device, network.Attach(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Start(context.Background())
+	defer server.Stop()
+	serverAddr := server.transport.LocalAddr()
+
+	client := NewTestClient(network.Attach(), 200*time.Millisecond)
+	defer client.Close()
+
+	// 枚举值按属性当前值的命名类型写入模型
+	tests := []struct {
+		name  string
+		obj   model.Object
+		prop  model.PropertyIdentifier
+		value uint32
+		want  interface{}
+	}{
+		{"Loop Action", loop, model.PropertyIdentifierAction, 0, model.LoopActionDirect},
+		{"Polarity", fan, model.PropertyIdentifierPolarity, 1, model.PolarityReverse},
+		{"Mode", zone, model.PropertyIdentifierMode, uint32(model.LifeSafetyModeTest), model.LifeSafetyModeTest},
+		{"Trend Log Logging_Type", trend, model.PropertyIdentifierLoggingType, uint32(model.LoggingTypeTriggered), model.LoggingTypeTriggered},
+		{"Trend Log Multiple Logging_Type", trends, model.PropertyIdentifierLoggingType, uint32(model.LoggingTypeTriggered), model.LoggingTypeTriggered},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := client.WriteProperty(serverAddr, tt.obj.GetObjectIdentifier(), tt.prop, encoding.Enumerated(tt.value), 0); err != nil {
+				t.Fatalf("WriteProperty() = %v", err)
+			}
+			if got, _ := tt.obj.ReadProperty(tt.prop); got != tt.want {
+				t.Errorf("ReadProperty() = %v (%T), want %v", got, got, tt.want)
+			}
+		})
+	}
+
+	// 超出枚举范围的值仍被拒绝
+	var bacnetErr *Error
+	if err := client.WriteProperty(serverAddr, fan.GetObjectIdentifier(), model.PropertyIdentifierPolarity, encoding.Enumerated(2), 0); !errors.As(err, &bacnetErr) {
+		t.Errorf("WriteProperty(Polarity=2) = %v, want BACnet error", err)
+	}
+}
+
+func TestProgramChangeEndToEnd(t *testing.T) {
+	network := NewLoopbackNetwork()
+	device := model.NewDevice(1234, "Loopback Device", "")
+	var changes []model.ProgramChange
+	var fail error
+	program := model.NewProgram(1, "Program", func(p *model.Program, change model.ProgramChange) error {
+		changes = append(changes, change)
+		return fail
+	})
+	device.AddObject(program)
+	server, err := newBACnetServer(device, network.Attach(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Start(context.Background())
+	defer server.Stop()
+	serverAddr := server.transport.LocalAddr()
+
+	client := NewTestClient(network.Attach(), 200*time.Millisecond)
+	defer client.Close()
+
+	request := func(change model.ProgramChange) error {
+		return client.WriteProperty(serverAddr, program.GetObjectIdentifier(), model.PropertyIdentifierProgramChange, encoding.Enumerated(change), 0)
+	}
+	readState := func() interface{} {
+		t.Helper()
+		state, err := client.ReadProperty(serverAddr, program.GetObjectIdentifier(), model.PropertyIdentifierProgramState)
+		if err != nil {
+			t.Fatalf("ReadProperty(Program_State) = %v", err)
+		}
+		return state
+	}
+
+	for _, tt := range []struct {
+		change model.ProgramChange
+		state  model.ProgramState
+	}{
+		{model.ProgramChangeLoad, model.ProgramStateHalted},
+		{model.ProgramChangeRun, model.ProgramStateRunning},
+		{model.ProgramChangeHalt, model.ProgramStateHalted},
+	} {
+		if err := request(tt.change); err != nil {
+			t.Fatalf("WriteProperty(Program_Change=%d) = %v", tt.change, err)
+		}
+		if got := readState(); got != encoding.Enumerated(tt.state) {
+			t.Errorf("after change %d Program_State = %v, want %d", tt.change, got, tt.state)
+		}
+	}
+	if want := []model.ProgramChange{model.ProgramChangeLoad, model.ProgramChangeRun, model.ProgramChangeHalt}; !reflect.DeepEqual(changes, want) {
+		t.Errorf("callback changes = %v, want %v", changes, want)
+	}
+
+	// 回调失败时程序停止并记录原因
+	fail = errors.New("script error")
+	if err := request(model.ProgramChangeRun); err != nil {
+		t.Fatalf("WriteProperty(Program_Change=run) = %v", err)
+	}
+	if got := readState(); got != encoding.Enumerated(model.ProgramStateHalted) {
+		t.Errorf("Program_State after failed run = %v, want halted", got)
+	}
+	if reason, _ := client.ReadProperty(serverAddr, program.GetObjectIdentifier(), model.PropertyIdentifierReasonForHalt); reason != encoding.Enumerated(model.ProgramErrorProgram) {
+		t.Errorf("Reason_For_Halt = %v, want program", reason)
+	}
+	if description, _ := client.ReadProperty(serverAddr, program.GetObjectIdentifier(), model.PropertyIdentifierDescriptionOfHalt); description != "script error" {
+		t.Errorf("Description_Of_Halt = %v", description)
+	}
+
+	// 当前状态下不允许的请求被拒绝，且不调用回调
+	fail = nil
+	program.SetState(model.ProgramStateIdle)
+	calls := len(changes)
+	var bacnetErr *Error
+	if err := request(model.ProgramChangeHalt); !errors.As(err, &bacnetErr) {
+		t.Errorf("WriteProperty(Program_Change=halt) in idle = %v, want BACnet error", err)
+	}
+	if len(changes) != calls {
+		t.Errorf("callback invoked for rejected change: %v", changes[calls:])
+	}
+}
+
+// recordingLink 将发送的NPDU放入队列的数据链路
+type recordingLink struct {
+	mac  []byte
+	sent chan recordedNPDU
+}
+
+type recordedNPDU struct {
+	npdu []byte
+	mac  []byte
+}
+
+func (l *recordingLink) SendNPDU(npdu []byte, mac []byte) error {
+	l.sent <- recordedNPDU{npdu: npdu, mac: mac}
+	return nil
+}
+
+func (l *recordingLink) LocalMAC() []byte {
+	return l.mac
+}
+
+// next 在超时时间内取出下一个发送的NPDU
+func (l *recordingLink) next(t *testing.T) recordedNPDU {
+	t.Helper()
+	select {
+	case sent := <-l.sent:
+		return sent
+	case <-time.After(time.Second):
+		t.Fatal("等待发送的NPDU超时")
+		return recordedNPDU{}
+	}
+}
+
+// readDatagram 在超时时间内读取一个数据报
+func readDatagram(t *testing.T, transport Transport) []byte {
+	t.Helper()
+	received := make(chan []byte, 1)
+	go func() {
+		buffer := make([]byte, transport.MTU())
+		if n, _, err := transport.ReadFrom(buffer); err == nil {
+			received <- buffer[:n]
+		}
+	}()
+	select {
+	case data := <-received:
+		return data
+	case <-time.After(time.Second):
+		t.Fatal("等待数据报超时")
+		return nil
+	}
+}
+
+func TestRouter(t *testing.T) {
+	network := NewLoopbackNetwork()
+	server, err := newBACnetServer(model.NewDevice(77, "Router", ""), network.Attach(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := &recordingLink{mac: []byte{1}, sent: make(chan recordedNPDU, 8)}
+	if _, err := server.AddRouterPort(2, link); err == nil {
+		t.Error("AddRouterPort() before EnableRouting: want error")
+	}
+	if err := server.EnableRouting(0xFFFF); err == nil {
+		t.Error("EnableRouting(0xFFFF): want error")
+	}
+	if err := server.EnableRouting(1); err != nil {
+		t.Fatal(err)
+	}
+	mstpPort, err := server.AddRouterPort(2, link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.AddRouterPort(1, &recordingLink{}); err == nil {
+		t.Error("AddRouterPort() with duplicate network: want error")
+	}
+	client := network.Attach()
+	defer client.Close()
+	clientMAC := encodeBIPAddress(client.LocalAddr().(*net.UDPAddr))
+	server.Start(context.Background())
+	defer server.Stop()
+	serverAddr := udpAddr(server.transport.LocalAddr())
+
+	// 启动时在每个端口上宣告经其他端口可达的网络
+	if data := readDatagram(t, client); !bytes.Equal(data, encodeBVLC(BVLCOriginalBroadcastNPDU, []byte{0x01, 0x80, NetworkMessageIAmRouterToNetwork, 0x00, 0x02})) {
+		t.Errorf("I-Am-Router-To-Network on IP = % X", data)
+	}
+	if sent := link.next(t); sent.mac != nil || !bytes.Equal(sent.npdu, []byte{0x01, 0x80, NetworkMessageIAmRouterToNetwork, 0x00, 0x01}) {
+		t.Errorf("I-Am-Router-To-Network on MS/TP = % X to % X", sent.npdu, sent.mac)
+	}
+	whoIs := []byte{BACnetAPDUTypeUnconfirmedServiceRequest << 4, BACnetServiceUnconfirmedWhoIs}
+	hop := byte(10)
+	dnet, snet := uint16(2), uint16(2)
+
+	// IP上发往网络2的消息去掉DNET、带上SNET/SADR后在MS/TP上投递
+	request := NPDU{Version: 0x01, DestinationNetwork: &dnet, DestinationMAC: []byte{5}, HopCount: &hop}
+	client.WriteTo(encodeBVLC(BVLCOriginalUnicastNPDU, append(request.Encode(), whoIs...)), serverAddr)
+	want := append([]byte{0x01, 0x08, 0x00, 0x01, 0x06}, clientMAC...)
+	if routed := link.next(t); !bytes.Equal(routed.mac, []byte{5}) || !bytes.Equal(routed.npdu, append(want, whoIs...)) {
+		t.Errorf("routed NPDU = % X to % X", routed.npdu, routed.mac)
+	}
+
+	// MS/TP上发往网络1的广播在IP上广播
+	dnet = 1
+	request = NPDU{Version: 0x01, DestinationNetwork: &dnet, HopCount: &hop}
+	if response, err := mstpPort.HandleNPDU(append(request.Encode(), whoIs...), []byte{7}); response != nil || err != nil {
+		t.Errorf("HandleNPDU(routed) = % X, %v", response, err)
+	}
+	if data := readDatagram(t, client); !bytes.Equal(data, encodeBVLC(BVLCOriginalBroadcastNPDU, append([]byte{0x01, 0x08, 0x00, 0x02, 0x01, 7}, whoIs...))) {
+		t.Errorf("routed to IP: % X", data)
+	}
+
+	// 全局广播转发到其他端口，跳数减一，本设备同样处理
+	dnet = globalBroadcastNetwork
+	request = NPDU{Version: 0x01, DestinationNetwork: &dnet, SourceNetwork: &snet, SourceMAC: []byte{9}, HopCount: &hop}
+	response, err := mstpPort.HandleNPDU(append(request.Encode(), whoIs...), []byte{7})
+	if err != nil || !bytes.Equal(response, append([]byte{0x01, 0x20, 0x00, 0x02, 0x01, 9, 0xFF}, server.encodeIAm()...)) {
+		t.Errorf("HandleNPDU(global broadcast) = % X, %v", response, err)
+	}
+	if data := readDatagram(t, client); !bytes.Equal(data, encodeBVLC(BVLCOriginalBroadcastNPDU, append([]byte{0x01, 0x28, 0xFF, 0xFF, 0x00, 0x00, 0x02, 0x01, 9, 9}, whoIs...))) {
+		t.Errorf("global broadcast to IP: % X", data)
+	}
+
+	// 未知网络回复Reject-Message-To-Network
+	dnet = 9
+	request = NPDU{Version: 0x01, DestinationNetwork: &dnet, HopCount: &hop}
+	mstpPort.HandleNPDU(append(request.Encode(), whoIs...), []byte{7})
+	if last := link.next(t); !bytes.Equal(last.mac, []byte{7}) ||
+		!bytes.Equal(last.npdu, []byte{0x01, 0x80, NetworkMessageRejectMessageToNetwork, RejectNetworkUnknown, 0x00, 9}) {
+		t.Errorf("reject = % X to % X", last.npdu, last.mac)
+	}
+
+	// 跳数耗尽的消息被丢弃
+	hop, dnet = 0, 1
+	request = NPDU{Version: 0x01, DestinationNetwork: &dnet, DestinationMAC: clientMAC, HopCount: &hop}
+	if response, err := mstpPort.HandleNPDU(append(request.Encode(), whoIs...), []byte{7}); response != nil || err != nil || len(link.sent) != 0 {
+		t.Errorf("HandleNPDU(hop count 0) = % X, %v", response, err)
+	}
+
+	// 没有目标网络的消息由本设备处理
+	if response, err := mstpPort.HandleNPDU(append([]byte{0x01, 0x00}, whoIs...), []byte{7}); err != nil || response == nil {
+		t.Errorf("HandleNPDU(local) = % X, %v", response, err)
+	}
+	if len(server.RouterPorts()) != 2 || server.RouterPorts()[0].Network != 1 {
+		t.Errorf("RouterPorts() = %v", server.RouterPorts())
+	}
+}
+
+func TestNetworkMessages(t *testing.T) {
+	s := &BACnetServer{device: model.NewDevice(1, "Router", "")}
+	if response, err := s.HandleNPDU([]byte{0x01, 0x80, NetworkMessageWhatIsNetworkNumber}, "mstp:1"); response != nil || err != nil {
+		t.Errorf("HandleNPDU(network message) without routing = % X, %v", response, err)
+	}
+	if err := s.EnableRouting(1); err != nil {
+		t.Fatal(err)
+	}
+	mstp := &recordingLink{mac: []byte{1}, sent: make(chan recordedNPDU, 8)}
+	port, err := s.AddRouterPort(2, mstp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := &recordingLink{mac: []byte{1}, sent: make(chan recordedNPDU, 8)}
+	if _, err := s.AddRouterPort(3, other); err != nil {
+		t.Fatal(err)
+	}
+	expect := func(link *recordingLink, want []byte) {
+		t.Helper()
+		if sent := link.next(t); sent.mac != nil || !bytes.Equal(sent.npdu, want) {
+			t.Errorf("sent % X to % X, want % X", sent.npdu, sent.mac, want)
+		}
+	}
+
+	// Who-Is-Router-To-Network列出经其他端口可达的网络
+	port.HandleNPDU([]byte{0x01, 0x80, NetworkMessageWhoIsRouterToNetwork}, []byte{7})
+	expect(mstp, []byte{0x01, 0x80, NetworkMessageIAmRouterToNetwork, 0x00, 0x01, 0x00, 0x03})
+	port.HandleNPDU([]byte{0x01, 0x80, NetworkMessageWhoIsRouterToNetwork, 0x00, 0x03}, []byte{7})
+	expect(mstp, []byte{0x01, 0x80, NetworkMessageIAmRouterToNetwork, 0x00, 0x03})
+
+	// 不可达的网络转发给其他端口上的路由器
+	port.HandleNPDU([]byte{0x01, 0x80, NetworkMessageWhoIsRouterToNetwork, 0x00, 0x09}, []byte{7})
+	expect(other, []byte{0x01, 0x88, 0x00, 0x02, 0x01, 7, NetworkMessageWhoIsRouterToNetwork, 0x00, 0x09})
+
+	// 学到经网络3上路由器5可达的网络9，并在其他端口转播
+	otherPort := s.RouterPorts()[2]
+	otherPort.HandleNPDU([]byte{0x01, 0x80, NetworkMessageIAmRouterToNetwork, 0x00, 0x09, 0x00, 0x02}, []byte{5})
+	expect(mstp, []byte{0x01, 0x80, NetworkMessageIAmRouterToNetwork, 0x00, 0x09})
+	if r, ok := s.learnedRoute(9); !ok || r.port != otherPort || !bytes.Equal(r.router, []byte{5}) {
+		t.Errorf("learnedRoute(9) = %v, %v", r, ok)
+	}
+	if _, ok := s.learnedRoute(2); ok {
+		t.Error("learnedRoute(2): directly connected network learned")
+	}
+
+	// 发往网络9的消息保留DNET、跳数减一后交给下一跳路由器
+	port.HandleNPDU([]byte{0x01, 0x20, 0x00, 0x09, 0x01, 0x42, 10, BACnetAPDUTypeUnconfirmedServiceRequest << 4, BACnetServiceUnconfirmedWhoIs}, []byte{7})
+	if sent := other.next(t); !bytes.Equal(sent.mac, []byte{5}) ||
+		!bytes.Equal(sent.npdu, []byte{0x01, 0x28, 0x00, 0x09, 0x01, 0x42, 0x00, 0x02, 0x01, 7, 9, BACnetAPDUTypeUnconfirmedServiceRequest << 4, BACnetServiceUnconfirmedWhoIs}) {
+		t.Errorf("routed via next hop = % X to % X", sent.npdu, sent.mac)
+	}
+
+	// 网络不可达的拒绝删除学到的路由
+	otherPort.HandleNPDU([]byte{0x01, 0x80, NetworkMessageRejectMessageToNetwork, RejectNetworkUnknown, 0x00, 0x09}, []byte{5})
+	if _, ok := s.learnedRoute(9); ok {
+		t.Error("learnedRoute(9) after Reject-Message-To-Network: want removed")
+	}
+
+	// What-Is-Network-Number应答端口的网络号
+	port.HandleNPDU([]byte{0x01, 0x80, NetworkMessageWhatIsNetworkNumber}, []byte{7})
+	expect(mstp, []byte{0x01, 0x80, NetworkMessageNetworkNumberIs, 0x00, 0x02, 0x01})
+
+	// 未知的消息类型被拒绝，厂商私有消息被忽略
+	port.HandleNPDU([]byte{0x01, 0x80, 0x7F}, []byte{7})
+	if sent := mstp.next(t); !bytes.Equal(sent.mac, []byte{7}) || !bytes.Equal(sent.npdu, []byte{0x01, 0x80, NetworkMessageRejectMessageToNetwork, RejectNetworkUnknownMessage, 0x00, 0x00}) {
+		t.Errorf("reject = % X to % X", sent.npdu, sent.mac)
+	}
+	port.HandleNPDU([]byte{0x01, 0x80, 0x80, 0x01, 0x04}, []byte{7})
+	if len(mstp.sent) != 0 || len(other.sent) != 0 {
+		t.Errorf("unexpected messages: %d, %d", len(mstp.sent), len(other.sent))
+	}
+}
+
+func TestInitializeRoutingTable(t *testing.T) {
+	s := &BACnetServer{device: model.NewDevice(1, "Router", "")}
+	if err := s.EnableRouting(1); err != nil {
+		t.Fatal(err)
+	}
+	link := &recordingLink{mac: []byte{1}, sent: make(chan recordedNPDU, 8)}
+	port, err := s.AddRouterPort(2, link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.learnRoute(5, port, []byte{9})
+
+	// 端口数为0的请求读取完整路由表，经路由器转发的请求应答发往其源网络
+	port.HandleNPDU([]byte{0x01, 0x88, 0x00, 0x07, 0x01, 0x03, NetworkMessageInitializeRoutingTable, 0x00}, []byte{4})
+	want := []byte{0x01, 0xA0, 0x00, 0x07, 0x01, 0x03, 0xFF, NetworkMessageInitializeRoutingTableAck, 3,
+		0x00, 0x01, 1, 0, 0x00, 0x02, 2, 0, 0x00, 0x05, 2, 0}
+	if sent := link.next(t); !bytes.Equal(sent.mac, []byte{4}) || !bytes.Equal(sent.npdu, want) {
+		t.Errorf("Initialize-Routing-Table-Ack = % X to % X", sent.npdu, sent.mac)
+	}
+
+	// 写入路由表：删除网络5，经端口2到达网络6，忽略直接连接的网络和不存在的端口
+	port.HandleNPDU([]byte{0x01, 0x80, NetworkMessageInitializeRoutingTable, 4,
+		0x00, 0x05, 0, 0, 0x00, 0x06, 2, 2, 0xAA, 0xBB, 0x00, 0x01, 2, 0, 0x00, 0x08, 3, 0}, []byte{4})
+	if sent := link.next(t); !bytes.Equal(sent.mac, []byte{4}) || !bytes.Equal(sent.npdu, []byte{0x01, 0x80, NetworkMessageInitializeRoutingTableAck}) {
+		t.Errorf("Initialize-Routing-Table-Ack = % X to % X", sent.npdu, sent.mac)
+	}
+	table := s.RoutingTable()
+	if len(table) != 3 || table[0].Network != 1 || !table[0].Direct || table[1].Network != 2 || table[1].PortID != 2 ||
+		table[2].Network != 6 || table[2].PortID != 2 || table[2].Direct || table[2].Router != nil {
+		t.Errorf("RoutingTable() = %+v", table)
+	}
+
+	// 截断的条目返回错误
+	if _, err := port.HandleNPDU([]byte{0x01, 0x80, NetworkMessageInitializeRoutingTable, 1, 0x00}, []byte{4}); err == nil {
+		t.Error("HandleNPDU(truncated Initialize-Routing-Table): want error")
+	}
+}
+
+func TestRoutedReply(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	setpoint := model.NewAnalogValue(1, "Setpoint", model.UnitsNoUnits)
+	device.AddObject(setpoint)
+	s := &BACnetServer{device: device}
+
+	// 经路由器转发的请求带SNET/SADR，应答以其作为DNET/DADR并带跳数
+	request := append([]byte{0x01, 0x0D, 0x00, 0x05, 0x02, 0x0A, 0x0B, 0x00, 0x05, 3, BACnetServiceConfirmedReadProperty},
+		EncodeReadPropertyRequest(setpoint.GetObjectIdentifier(), model.PropertyIdentifierObjectName, nil)...)
+	response, err := s.HandleNPDU(request, "mstp:9")
+	if err != nil || len(response) < 9 || !bytes.Equal(response[:8], []byte{0x01, 0x21, 0x00, 0x05, 0x02, 0x0A, 0x0B, 0xFF}) || response[8]>>4 != BACnetAPDUTypeComplexAck {
+		t.Errorf("HandleNPDU(routed ReadProperty) = % X, %v", response, err)
+	}
+
+	// 已编码为完整帧的应答（I-Am）同样换成带目标网络的NPDU
+	whoIs := []byte{0x01, 0x08, 0x00, 0x05, 0x01, 0x0A, BACnetAPDUTypeUnconfirmedServiceRequest << 4, BACnetServiceUnconfirmedWhoIs}
+	want := encodeBVLC(BVLCOriginalUnicastNPDU, append([]byte{0x01, 0x20, 0x00, 0x05, 0x01, 0x0A, 0xFF}, s.encodeIAm()...))
+	if response, err := s.processBACnetMessage(&RequestContext{}, encodeBVLC(BVLCOriginalUnicastNPDU, whoIs)); err != nil || !bytes.Equal(response, want) {
+		t.Errorf("processBACnetMessage(routed Who-Is) = % X, %v", response, err)
+	}
+
+	// 本地请求的应答不带目标网络
+	whoIs = []byte{0x01, 0x00, BACnetAPDUTypeUnconfirmedServiceRequest << 4, BACnetServiceUnconfirmedWhoIs}
+	if response, err := s.processBACnetMessage(&RequestContext{}, encodeBVLC(BVLCOriginalUnicastNPDU, whoIs)); err != nil || !bytes.Equal(response, s.createIAmResponse()) {
+		t.Errorf("processBACnetMessage(Who-Is) = % X, %v", response, err)
+	}
+}
+
+func TestRoutedCOVNotification(t *testing.T) {
+	network := NewLoopbackNetwork()
+	device := model.NewDevice(1, "Test Device", "")
+	setpoint := model.NewAnalogValue(1, "Setpoint", model.UnitsNoUnits)
+	device.AddObject(setpoint)
+	s, err := NewServer(device, Options{Transport: network.Attach()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Start(context.Background())
+	defer s.Stop()
+	router := network.Attach()
+	defer router.Close()
+	read := func() []byte {
+		t.Helper()
+		buffer := make([]byte, router.MTU())
+		n, _, err := router.ReadFrom(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return buffer[:n]
+	}
+
+	// 远程网络5上的两个订阅者经同一个路由器以相同的进程ID订阅，各自保留订阅
+	lifetime := uint32(300)
+	payload, _ := EncodeSubscribeCOVRequest(SubscribeCOVRequest{SubscriberProcessID: 7, ObjectID: setpoint.GetObjectIdentifier(), IssueConfirmedNotif: new(bool), Lifetime: &lifetime})
+	for i, sadr := range []byte{0x0A, 0x0B} {
+		npdu := []byte{0x01, 0x0C, 0x00, 0x05, 0x01, sadr}
+		request := encodeBVLC(BVLCOriginalUnicastNPDU, append(npdu, EncodeConfirmedRequest(byte(i), BACnetServiceConfirmedSubscribeCOV, payload)...))
+		if _, err := router.WriteTo(request, s.transport.LocalAddr()); err != nil {
+			t.Fatal(err)
+		}
+		if reply := read(); len(reply) < 12 || reply[11] != BACnetAPDUTypeSimpleAck<<4 {
+			t.Fatalf("SubscribeCOV reply = % x", reply)
+		}
+	}
+	s.Lock()
+	subscriptions := setpoint.COVSubscriptions()
+	s.Unlock()
+	if len(subscriptions) != 2 || subscriptions[0].RemoteAddress == nil || subscriptions[0].RemoteAddress.Network != 5 {
+		t.Fatalf("subscriptions = %+v", subscriptions)
+	}
+
+	// 通知以订阅者的网络和地址为NPDU的目标，由路由器转发
+	s.SimulateDataChange(setpoint.GetObjectIdentifier(), model.PropertyIdentifierPresentValue, float32(30))
+	var sadrs []byte
+	for range subscriptions {
+		data := read()
+		if len(data) < 13 || !bytes.Equal(data[4:9], []byte{0x01, 0x20, 0x00, 0x05, 0x01}) || data[10] != 0xFF ||
+			data[11] != BACnetAPDUTypeUnconfirmedServiceRequest<<4 || data[12] != BACnetServiceUnconfirmedCOVNotification {
+			t.Fatalf("routed COV notification = % x", data)
+		}
+		sadrs = append(sadrs, data[9])
+	}
+	slices.Sort(sadrs)
+	if !bytes.Equal(sadrs, []byte{0x0A, 0x0B}) {
+		t.Errorf("notification destinations = % x", sadrs)
+	}
+}
+
+func TestSetDSCP(t *testing.T) {
+	transport, err := NewUDPTransport("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.Close()
+	s := &BACnetServer{transport: transport}
+	if err := s.SetDSCP(64); err == nil {
+		t.Error("SetDSCP(64): want error")
+	}
+	if err := s.SetDSCP(46); (err == nil) != (runtime.GOOS == "linux") {
+		t.Errorf("SetDSCP(46) on %s = %v", runtime.GOOS, err)
+	}
+	s.transport = NewLoopbackNetwork().Attach()
+	if err := s.SetDSCP(46); err == nil {
+		t.Error("SetDSCP() on loopback transport: want error")
+	}
+
+	// 本设备发起的确认请求置位期望应答
+	npdu := NPDU{Version: 0x01, Control: ControlInfo{ExpectingReply: true, Priority: PriorityUrgent}}
+	if got := npdu.Encode(); !bytes.Equal(got, []byte{0x01, 0x05}) {
+		t.Errorf("Encode() = % X", got)
+	}
+}
+
+func TestInterfaceTransport(t *testing.T) {
+	if _, err := NewInterfaceTransport("no-such-interface0", 0); err == nil {
+		t.Error("NewInterfaceTransport(missing interface): want error")
+	}
+	transport, err := NewInterfaceTransport("127.0.0.1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.Close()
+	port := transport.LocalAddr().(*net.UDPAddr).Port
+	if got := transport.BroadcastAddr().String(); got != fmt.Sprintf("127.255.255.255:%d", port) {
+		t.Errorf("BroadcastAddr() = %s", got)
+	}
+
+	// 发往单播地址和子网广播地址的数据报都能收到
+	client, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	targets := []net.Addr{transport.LocalAddr()}
+	if transport.bcastConn != nil {
+		targets = append(targets, transport.BroadcastAddr())
+	}
+	for _, target := range targets {
+		if _, err := client.WriteTo([]byte{0x81, 0x0b, 0x00, 0x04}, target); err != nil {
+			t.Fatal(err)
+		}
+		if data := readDatagram(t, transport); !bytes.Equal(data, []byte{0x81, 0x0b, 0x00, 0x04}) {
+			t.Errorf("datagram to %s = % X", target, data)
+		}
+	}
+}
+
+func TestMultiTransport(t *testing.T) {
+	first, second := NewLoopbackNetwork(), NewLoopbackNetwork()
+	multi, err := NewMultiTransport(first.Attach(), second.Attach())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer multi.Close()
+	peer1, peer2 := first.Attach(), second.Attach()
+	defer peer1.Close()
+	defer peer2.Close()
+
+	// 受限广播在每个网段上广播
+	if _, err := multi.WriteTo([]byte{1}, multi.BroadcastAddr()); err != nil {
+		t.Fatal(err)
+	}
+	for _, peer := range []*LoopbackTransport{peer1, peer2} {
+		if data := readDatagram(t, peer); !bytes.Equal(data, []byte{1}) {
+			t.Errorf("broadcast = % X", data)
+		}
+	}
+
+	// 两个网段上收到的数据报都能读取
+	peer1.WriteTo([]byte{2}, multi.LocalAddr())
+	peer2.WriteTo([]byte{3}, multi.transports[1].LocalAddr())
+	received := map[byte]bool{}
+	for i := 0; i < 2; i++ {
+		received[readDatagram(t, multi)[0]] = true
+	}
+	if !received[2] || !received[3] {
+		t.Errorf("received = %v", received)
+	}
+
+	multi.Close()
+	if _, _, err := multi.ReadFrom(make([]byte, 10)); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("ReadFrom() after Close = %v", err)
+	}
+	if _, err := NewMultiTransport(); err == nil {
+		t.Error("NewMultiTransport(): want error")
+	}
+}
+
+func TestSharedUDPTransport(t *testing.T) {
+	first, err := NewSharedUDPTransport("127.0.0.1:0")
+	if runtime.GOOS != "linux" {
+		if err == nil {
+			first.Close()
+			t.Fatal("NewSharedUDPTransport() on non-Linux: want error")
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+
+	// 共享模式下同一端口可以再次绑定，普通模式不行
+	addr := first.LocalAddr().String()
+	second, err := NewSharedUDPTransport(addr)
+	if err != nil {
+		t.Fatalf("NewSharedUDPTransport(%s) = %v", addr, err)
+	}
+	defer second.Close()
+	if plain, err := NewUDPTransport(addr); err == nil {
+		plain.Close()
+		t.Errorf("NewUDPTransport(%s) on shared port: want error", addr)
+	}
+}
+
+func TestVirtualNetwork(t *testing.T) {
+	network := NewLoopbackNetwork()
+	server, err := newBACnetServer(model.NewDevice(100, "Router", ""), network.Attach(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.AddVirtualNetwork(5); err == nil {
+		t.Error("AddVirtualNetwork() without routing: want error")
+	}
+	if err := server.EnableRouting(1); err != nil {
+		t.Fatal(err)
+	}
+	virtual, err := server.AddVirtualNetwork(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 虚拟设备沿用路由器所在服务端的访问控制，按B/IP请求者的IP匹配
+	if err := server.SetACL([]ACLRule{{Source: "192.0.2.2", Services: []byte{BACnetServiceConfirmedWriteProperty}, Deny: true}}); err != nil {
+		t.Fatal(err)
+	}
+	for instance := uint32(101); instance <= 103; instance++ {
+		if _, err := virtual.AddDevice(model.NewDevice(instance, fmt.Sprintf("VAV-%d", instance), "")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := virtual.AddDevice(model.NewDevice(102, "Duplicate", "")); err == nil {
+		t.Error("AddDevice() with duplicate instance: want error")
+	}
+	if devices := virtual.Devices(); len(devices) != 3 || devices[0].GetObjectIdentifier().Instance != 101 {
+		t.Errorf("Devices() = %v", devices)
+	}
+
+	client := network.Attach()
+	defer client.Close()
+	server.Start(context.Background())
+	defer server.Stop()
+	readDatagram(t, client) // 启动时的I-Am-Router-To-Network
+
+	// 全局广播的Who-Is由路由器上的设备和每个虚拟设备分别应答，虚拟设备的应答带SNET/SADR
+	whoIs := []byte{0x01, 0x20, 0xFF, 0xFF, 0x00, 0xFF, BACnetAPDUTypeUnconfirmedServiceRequest << 4, BACnetServiceUnconfirmedWhoIs}
+	client.WriteTo(encodeBVLC(BVLCOriginalBroadcastNPDU, whoIs), client.BroadcastAddr())
+	sources := map[string]bool{}
+	for i := 0; i < 4; i++ {
+		data := readDatagram(t, client)
+		npdu, offset, err := ParseNPDU(data[bvlcHeaderLength:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		apdu := data[bvlcHeaderLength+offset:]
+		if apdu[1] != BACnetServiceUnconfirmedIAm {
+			t.Fatalf("response = % X", data)
+		}
+		source := "local"
+		if npdu.SourceNetwork != nil {
+			source = fmt.Sprintf("%d:%x", *npdu.SourceNetwork, npdu.SourceMAC)
+		}
+		sources[source] = true
+	}
+	for _, want := range []string{"local", "5:000065", "5:000066", "5:000067"} {
+		if !sources[want] {
+			t.Errorf("no I-Am from %s: %v", want, sources)
+		}
+	}
+
+	// 带范围的Who-Is只由范围内的虚拟设备应答
+	ranged := append([]byte{0x01, 0x20, 0xFF, 0xFF, 0x00, 0xFF}, EncodeWhoIs(102, 102)...)
+	client.WriteTo(encodeBVLC(BVLCOriginalBroadcastNPDU, ranged), client.BroadcastAddr())
+	data := readDatagram(t, client)
+	if npdu, _, err := ParseNPDU(data[bvlcHeaderLength:]); err != nil || npdu.SourceNetwork == nil || !bytes.Equal(npdu.SourceMAC, []byte{0, 0, 102}) {
+		t.Fatalf("ranged Who-Is response = % X, %v", data, err)
+	}
+
+	// 以DNET/DADR访问虚拟设备，之前的Who-Is没有其他应答
+	request := append([]byte{0x01, 0x24, 0x00, 0x05, 0x03, 0x00, 0x00, 102, 0xFF,
+		BACnetAPDUTypeConfirmedServiceRequest << 4, 0x05, 1, BACnetServiceConfirmedReadProperty},
+		EncodeReadPropertyRequest(model.ObjectIdentifier{Type: model.ObjectTypeDevice, Instance: 102}, model.PropertyIdentifierObjectName, nil)...)
+	client.WriteTo(encodeBVLC(BVLCOriginalUnicastNPDU, request), server.transport.LocalAddr())
+	data = readDatagram(t, client)
+	npdu, offset, err := ParseNPDU(data[bvlcHeaderLength:])
+	if err != nil || npdu.SourceNetwork == nil || *npdu.SourceNetwork != 5 || !bytes.Equal(npdu.SourceMAC, []byte{0, 0, 102}) || npdu.DestinationNetwork != nil {
+		t.Fatalf("ReadProperty response NPDU = % X, %v", data, err)
+	}
+	ack, err := ParseAPDU(data[bvlcHeaderLength+offset:])
+	if err != nil || ack.PDUType != BACnetAPDUTypeComplexAck || !bytes.Contains(ack.Payload, []byte("VAV-102")) {
+		t.Errorf("ReadProperty response = % X, %v", data, err)
+	}
+
+	write, err := EncodeWritePropertyRequest(model.ObjectIdentifier{Type: model.ObjectTypeDevice, Instance: 102}, model.PropertyIdentifierLocation, "Roof", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request = append([]byte{0x01, 0x24, 0x00, 0x05, 0x03, 0x00, 0x00, 102, 0xFF,
+		BACnetAPDUTypeConfirmedServiceRequest << 4, 0x05, 2, BACnetServiceConfirmedWriteProperty}, write...)
+	client.WriteTo(encodeBVLC(BVLCOriginalUnicastNPDU, request), server.transport.LocalAddr())
+	data = readDatagram(t, client)
+	_, offset, _ = ParseNPDU(data[bvlcHeaderLength:])
+	if apdu := data[bvlcHeaderLength+offset:]; apdu[0] != BACnetAPDUTypeError<<4 || !bytes.HasSuffix(apdu, []byte{0x91, ErrorClassSecurity, 0x91, ErrorCodeAccessDenied}) {
+		t.Errorf("WriteProperty denied by ACL response = % X", data)
+	}
+}
+
+func TestRequestContextConcurrent(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	s := &BACnetServer{device: device}
+
+	// 并发处理来自不同发送方的Forwarded-NPDU，每个请求的应答地址互不影响
+	var wg sync.WaitGroup
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func(host byte) {
+			defer wg.Done()
+			ctx := &RequestContext{ClientAddr: "10.0.0.2:47808"}
+			forwarded := encodeBVLC(BVLCForwardedNPDU, []byte{192, 168, 1, host, 0xBA, 0xC0, 0x01, 0x00, 0x10, 0x08})
+			if _, err := s.processBACnetMessage(ctx, forwarded); err != nil {
+				t.Errorf("Forwarded-NPDU from %d: %v", host, err)
+				return
+			}
+			if want := fmt.Sprintf("192.168.1.%d:47808", host); ctx.ClientAddr != want || ctx.ReplyAddr.String() != want {
+				t.Errorf("reply address = %s, %v; want %s", ctx.ClientAddr, ctx.ReplyAddr, want)
+			}
+		}(byte(i))
+	}
+	wg.Wait()
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	newServer := func(t *testing.T) *BACnetServer {
+		transport, err := NewUDPTransport("127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		device := model.NewDevice(1, "Test Device", "")
+		device.Properties[model.PropertyIdentifierAPDUTimeout] = uint32(10000)
+		device.Properties[model.PropertyIdentifierNumberOfAPDURetries] = uint32(0)
+		s, err := newBACnetServer(device, transport, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	peer, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+
+	// 关闭时等待已发出的确认COV通知得到应答，并发的Shutdown都在关闭完成后返回
+	s := newServer(t)
+	s.Start(context.Background())
+	if err := s.SendConfirmedCOVNotification(model.COVSubscription{SubscriptionID: 1, ClientAddress: peer.LocalAddr().String()}, covValue(float32(1))); err != nil {
+		t.Fatal(err)
+	}
+	buffer := make([]byte, 1500)
+	peer.SetReadDeadline(time.Now().Add(time.Second))
+	n, from, err := peer.ReadFromUDP(buffer)
+	if err != nil || n < 9 {
+		t.Fatalf("confirmed COV notification: %d bytes, %v", n, err)
+	}
+	invokeID := buffer[8]
+
+	results := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			results <- s.Shutdown(ctx)
+		}()
+	}
+	select {
+	case <-s.Done():
+		t.Fatal("shutdown finished before the pending notification was acknowledged")
+	case <-time.After(50 * time.Millisecond):
+	}
+	ack := encodeBVLC(BVLCOriginalUnicastNPDU, append([]byte{0x01, 0x00}, encodeSimpleAck(invokeID, BACnetServiceConfirmedCOVNotification)...))
+	if _, err := peer.WriteToUDP(ack, from); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := <-results; err != nil {
+			t.Errorf("Shutdown() = %v", err)
+		}
+	}
+	if s.IsRunning() {
+		t.Error("IsRunning() after Shutdown = true")
+	}
+	if err := s.SendConfirmedCOVNotification(model.COVSubscription{SubscriptionID: 1, ClientAddress: peer.LocalAddr().String()}, covValue(float32(1))); !errors.Is(err, errServerStopped) {
+		t.Errorf("SendConfirmedCOVNotification after Shutdown = %v", err)
+	}
+	s.Stop()
+
+	// 通知没有应答时到期返回，不等待APDU超时
+	s = newServer(t)
+	s.Start(context.Background())
+	if err := s.SendConfirmedCOVNotification(model.COVSubscription{SubscriptionID: 1, ClientAddress: peer.LocalAddr().String()}, covValue(float32(1))); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := s.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > time.Second {
+		t.Errorf("Shutdown() = %v after %v; want deadline exceeded", err, time.Since(start))
+	}
+
+	// Start的上下文取消时关闭
+	s = newServer(t)
+	ctx, cancel = context.WithCancel(context.Background())
+	s.Start(ctx)
+	cancel()
+	select {
+	case <-s.Done():
+	case <-time.After(time.Second):
+		t.Fatal("server did not stop when its context was cancelled")
+	}
+}
+
+func TestCOVQueue(t *testing.T) {
+	transport, err := NewUDPTransport("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	device := model.NewDevice(1, "Test Device", "")
+	device.Properties[model.PropertyIdentifierAPDUTimeout] = uint32(10000)
+	device.Properties[model.PropertyIdentifierNumberOfAPDURetries] = uint32(0)
+	s, err := NewServer(device, Options{Transport: transport, COVQueueSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Start(context.Background())
+	defer s.Stop()
+
+	slow, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slow.Close()
+	fast, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fast.Close()
+	read := func(peer *net.UDPConn) ([]byte, net.Addr) {
+		t.Helper()
+		buffer := make([]byte, 1500)
+		peer.SetReadDeadline(time.Now().Add(time.Second))
+		n, from, err := peer.ReadFromUDP(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return buffer[:n], from
+	}
+
+	// 第一个确认通知等待应答，后面的排队，队列满时丢弃
+	slowAddr := slow.LocalAddr().String()
+	if err := s.SendConfirmedCOVNotification(model.COVSubscription{SubscriptionID: 1, ClientAddress: slowAddr}, covValue(float32(1))); err != nil {
+		t.Fatal(err)
+	}
+	first, from := read(slow)
+	for i := 2; i <= 3; i++ {
+		if err := s.SendConfirmedCOVNotification(model.COVSubscription{SubscriptionID: 1, ClientAddress: slowAddr}, covValue(float32(i))); err != nil {
+			t.Fatalf("notification %d: %v", i, err)
+		}
+	}
+	if err := s.SendConfirmedCOVNotification(model.COVSubscription{SubscriptionID: 1, ClientAddress: slowAddr}, covValue(float32(4))); !errors.Is(err, errCOVQueueFull) {
+		t.Errorf("notification to a full queue = %v, want %v", err, errCOVQueueFull)
+	}
+	if depths := s.COVQueueDepths(); depths[slowAddr] != 2 {
+		t.Errorf("COVQueueDepths() = %v, want 2 for %s", depths, slowAddr)
+	}
+
+	// 慢的订阅者不影响其他订阅者
+	if err := s.SendCOVNotification(model.COVSubscription{SubscriptionID: 2, ClientAddress: fast.LocalAddr().String()}, covValue(float32(5))); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := read(fast); len(data) < 8 || data[6] != BACnetAPDUTypeUnconfirmedServiceRequest<<4 || data[7] != BACnetServiceUnconfirmedCOVNotification {
+		t.Errorf("unconfirmed COV notification = % x", data)
+	}
+
+	m := s.Metrics()
+	if m.COVDropped != 1 || m.COVQueueDepth[slowAddr] != 2 {
+		t.Errorf("COVDropped = %d, COVQueueDepth = %v", m.COVDropped, m.COVQueueDepth)
+	}
+	var out strings.Builder
+	m.writePrometheus(&out)
+	for _, line := range []string{
+		"bacnet_cov_notifications_dropped_total 1",
+		fmt.Sprintf("bacnet_cov_queue_depth{peer=%q} 2", slowAddr),
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("Prometheus output missing %q:\n%s", line, out.String())
+		}
+	}
+
+	// 应答后按顺序发送排队的通知
+	acknowledge := func(request []byte) {
+		t.Helper()
+		ack := encodeBVLC(BVLCOriginalUnicastNPDU, append([]byte{0x01, 0x00}, encodeSimpleAck(request[8], BACnetServiceConfirmedCOVNotification)...))
+		if _, err := slow.WriteTo(ack, from); err != nil {
+			t.Fatal(err)
+		}
+	}
+	acknowledge(first)
+	next, _ := read(slow)
+	if next[8] == first[8] {
+		t.Errorf("queued notification reused invoke ID %d", next[8])
+	}
+	if depths := s.COVQueueDepths(); depths[slowAddr] != 1 {
+		t.Errorf("COVQueueDepths() after ack = %v, want 1 for %s", depths, slowAddr)
+	}
+	acknowledge(next)
+	last, _ := read(slow)
+	acknowledge(last)
+}
+
+func TestPacketLogging(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	s := &BACnetServer{device: device}
+	request := EncodeReadPropertyRequest(device.GetObjectIdentifier(), model.PropertyIdentifierObjectName, nil)
+	apdu := append([]byte{BACnetAPDUTypeConfirmedServiceRequest << 4, 0x05, 0x07, BACnetServiceConfirmedReadProperty}, request...)
+	ctx := &RequestContext{ClientAddr: "192.168.1.10:47808"}
+
+	var out bytes.Buffer
+	s.SetLogger(slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: LevelPacket})))
+	if _, err := s.handleBACnetAPDU(ctx, apdu); err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"peer=192.168.1.10:47808", "service=ReadProperty", "invoke_id=7"} {
+		if !strings.Contains(out.String(), field) {
+			t.Errorf("packet log missing %s: %s", field, out.String())
+		}
+	}
+
+	// Debug级别不输出逐包日志
+	out.Reset()
+	s.SetLogger(slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	if _, err := s.handleBACnetAPDU(ctx, apdu); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("packet logs written at debug level: %s", out.String())
+	}
+}
+
+func TestSegmentedResponse(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	device.WriteProperty(model.PropertyIdentifierDescription, strings.Repeat("x", 600))
+	s := &BACnetServer{device: device}
+	ctx := &RequestContext{ClientAddr: "192.168.1.10:47808"}
+	request := EncodeReadPropertyRequest(device.GetObjectIdentifier(), model.PropertyIdentifierDescription, nil)
+	handle := func(apdu []byte) []byte {
+		t.Helper()
+		response, err := s.handleBACnetAPDU(ctx, apdu)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+	readProperty := func(control, maxResponse, invokeID byte) []byte {
+		return handle(append([]byte{BACnetAPDUTypeConfirmedServiceRequest<<4 | control, maxResponse, invokeID, BACnetServiceConfirmedReadProperty}, request...))
+	}
+
+	// 客户端接受1476字节时不分段
+	full := readProperty(0, 0x05, 1)
+	if len(full) < 600 || full[0] != BACnetAPDUTypeComplexAck<<4 {
+		t.Fatalf("unsegmented response = % X", full[:4])
+	}
+
+	// 超过客户端接受的480字节且不接受分段时放弃
+	abort := readProperty(0, 0x03, 2)
+	if !bytes.Equal(abort, []byte{BACnetAPDUTypeAbort<<4 | 0x01, 2, AbortReasonBufferOverflow}) {
+		t.Errorf("oversized response = % X, want buffer-overflow abort", abort)
+	}
+	if apdu, _ := ParseAPDU(abort); apdu == nil || !strings.Contains(apdu.Err().Error(), "缓冲区溢出") {
+		t.Errorf("abort error = %v", apdu.Err())
+	}
+
+	// 接受分段时按480字节分段，每个SegmentAck发送下一个分段
+	first := readProperty(0x02, 0x03, 3)
+	if len(first) != 480 || first[0] != 0x3C || first[2] != 0 || first[3] != 1 || first[4] != BACnetServiceConfirmedReadProperty {
+		t.Fatalf("first segment = % X (%d bytes)", first[:5], len(first))
+	}
+	segmentAck := func(sequence byte) []byte {
+		return handle([]byte{BACnetAPDUTypeSegmentAck << 4, 3, sequence, 1})
+	}
+	second := segmentAck(0)
+	if len(second) == 0 || second[0] != 0x38 || second[2] != 1 {
+		t.Fatalf("second segment = % X", second)
+	}
+	if again := segmentAck(0); !bytes.Equal(again, second) {
+		t.Error("segment not resent after a duplicate SegmentAck")
+	}
+	if joined := append(append([]byte{}, first[5:]...), second[5:]...); !bytes.Equal(joined, full[3:]) {
+		t.Error("segments do not reassemble into the response")
+	}
+	if last := segmentAck(1); last != nil {
+		t.Errorf("response after the last SegmentAck = % X", last)
+	}
+
+	// 分段数超过客户端接受的最大分段数时放弃：最大APDU为128字节时需要5个分段
+	if abort := readProperty(0x02, 0x21, 4); !bytes.Equal(abort, []byte{BACnetAPDUTypeAbort<<4 | 0x01, 4, AbortReasonBufferOverflow}) {
+		t.Errorf("response over max segments = % X, want buffer-overflow abort", abort)
+	}
+	if apdu, _ := ParseAPDU([]byte{0, 0x21, 4, BACnetServiceConfirmedReadProperty}); apdu.MaxAPDULengthAccepted() != 128 || apdu.MaxSegmentsAccepted() != 4 {
+		t.Errorf("max APDU %d, max segments %d", apdu.MaxAPDULengthAccepted(), apdu.MaxSegmentsAccepted())
+	}
+}
+
+func TestPrivateTransfer(t *testing.T) {
+	s := &BACnetServer{device: model.NewDevice(1, "Test Device", "")}
+	ctx := &RequestContext{ClientAddr: "192.168.1.10:47808"}
+	var received []PrivateTransfer
+	s.HandlePrivateTransfer(555, 1, func(request PrivateTransfer) ([]byte, error) {
+		received = append(received, request)
+		if len(request.Parameters) == 0 {
+			return nil, &Error{Class: ErrorClassService, Code: ErrorCodeMissingRequiredParameter}
+		}
+		return encoding.EncodeCharacterString("done"), nil
+	})
+	s.HandlePrivateTransfer(555, 2, func(request PrivateTransfer) ([]byte, error) {
+		return nil, errors.New("failed")
+	})
+	confirmed := func(vendorID, serviceNumber uint32, parameters []byte) *APDU {
+		t.Helper()
+		response, err := s.handleBACnetAPDU(ctx, EncodeConfirmedRequest(3, BACnetServiceConfirmedPrivateTransfer, EncodePrivateTransferRequest(vendorID, serviceNumber, parameters)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		apdu, err := ParseAPDU(response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return apdu
+	}
+
+	// 结果放在ComplexAck的resultBlock中
+	parameters := append(encoding.EncodeUnsigned(7), encoding.EncodeReal(1.5)...)
+	ack := confirmed(555, 1, parameters)
+	vendorID, serviceNumber, result, err := DecodePrivateTransferAck(ack.Payload)
+	if ack.PDUType != BACnetAPDUTypeComplexAck || err != nil || vendorID != 555 || serviceNumber != 1 || !bytes.Equal(result, encoding.EncodeCharacterString("done")) {
+		t.Errorf("ack = %v, result % X, %v", ack, result, err)
+	}
+	if len(received) != 1 || !bytes.Equal(received[0].Parameters, parameters) || !received[0].Confirmed || received[0].Peer != ctx.ClientAddr {
+		t.Errorf("handler received %+v", received)
+	}
+
+	// 处理函数的错误以ConfirmedPrivateTransfer-Error应答
+	var bacnetErr *Error
+	for _, tt := range []struct {
+		serviceNumber uint32
+		parameters    []byte
+		code          byte
+	}{
+		{1, nil, ErrorCodeMissingRequiredParameter},
+		{2, nil, ErrorCodeOther},
+		{3, nil, ErrorCodeOptionalFunctionalityNotSupported},
+	} {
+		reply := confirmed(555, tt.serviceNumber, tt.parameters)
+		if !errors.As(reply.Err(), &bacnetErr) || bacnetErr.Class != ErrorClassService || bacnetErr.Code != tt.code {
+			t.Errorf("service %d: %v", tt.serviceNumber, reply.Err())
+		}
+		d := encoding.NewDecoder(reply.Payload)
+		d.Constructed(0)
+		if vendorID, _ := d.ContextUnsigned(1); vendorID != 555 {
+			t.Errorf("service %d: error payload % X", tt.serviceNumber, reply.Payload)
+		}
+	}
+
+	// 未确认请求调用处理函数，没有应答
+	unconfirmed := append([]byte{BACnetAPDUTypeUnconfirmedServiceRequest << 4, BACnetServiceUnconfirmedPrivateTransfer}, EncodePrivateTransferRequest(555, 1, parameters)...)
+	if response, err := s.handleBACnetAPDU(ctx, unconfirmed); response != nil || err != nil || len(received) != 3 || received[2].Confirmed {
+		t.Errorf("UnconfirmedPrivateTransfer = % X, %v, received %+v", response, err, received)
+	}
+
+	// 格式错误的请求被拒绝
+	response, _ := s.handleBACnetAPDU(ctx, EncodeConfirmedRequest(4, BACnetServiceConfirmedPrivateTransfer, encoding.EncodeContextUnsigned(0, 555)))
+	if !bytes.Equal(response, []byte{BACnetAPDUTypeReject << 4, 4, RejectReasonMissingRequiredParameter}) {
+		t.Errorf("malformed request = % X", response)
+	}
+}
+
+func TestAddressBinding(t *testing.T) {
+	network := NewLoopbackNetwork()
+	device := model.NewDevice(1, "Test Device", "")
+	remote := model.ObjectIdentifier{Type: model.ObjectTypeDevice, Instance: 2001}
+	allDay := model.Destination{ValidDays: model.BitStringFromUint(7, 0x7F), ToTime: model.Time{Hour: 23, Minute: 59, Second: 59, Hundredths: 99}}
+	confirmed, toNormal := allDay, allDay
+	confirmed.Recipient = model.Recipient{Device: &remote}
+	confirmed.ProcessIdentifier = 42
+	confirmed.IssueConfirmedNotifications = true
+	toNormal.Recipient = model.Recipient{Address: &model.Address{}}
+	toNormal.Transitions = model.BitStringFromUint(3, 0x04)
+	nc := model.NewBACnetObject(model.ObjectTypeNotificationClass, 5, "Alarms")
+	nc.WriteProperty(model.PropertyIdentifierRecipientList, []model.Destination{confirmed, toNormal})
+	device.AddObject(nc)
+	s, err := NewServer(device, Options{Transport: network.Attach(), APDUTimeout: 200 * time.Millisecond, APDURetries: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Start(context.Background())
+	defer s.Stop()
+	c := NewTestClient(network.Attach(), time.Second)
+	defer c.Close()
+	next := func(pduType, service byte) *APDU {
+		t.Helper()
+		for {
+			apdu, err := c.Notification()
+			if err != nil {
+				t.Fatalf("waiting for service %d: %v", service, err)
+			}
+			if apdu.PDUType == pduType && *apdu.ServiceChoice == service {
+				return apdu
+			}
+		}
+	}
+
+	// 接收者只知道设备实例号时先用Who-Is查找地址，只接收to-normal的接收者不收到offnormal通知
+	s.SendEventNotification(model.EventNotification{EventObject: device.GetObjectIdentifier(), NotificationClass: 5, ToState: model.EventStateOffNormal})
+	whoIs := next(BACnetAPDUTypeUnconfirmedServiceRequest, BACnetServiceUnconfirmedWhoIs)
+	if !bytes.Equal(whoIs.Payload, EncodeWhoIs(2001, 2001)[2:]) {
+		t.Errorf("Who-Is = % X", whoIs.Payload)
+	}
+	iAm := append([]byte{BACnetAPDUTypeUnconfirmedServiceRequest << 4, BACnetServiceUnconfirmedIAm}, encoding.EncodeObjectIdentifier(remote)...)
+	iAm = append(iAm, encoding.EncodeUnsigned(1476)...)
+	iAm = append(iAm, encoding.EncodeEnumerated(3)...)
+	iAm = append(iAm, encoding.EncodeUnsigned(0)...)
+	c.send(iAm, nil)
+	notification := next(BACnetAPDUTypeConfirmedServiceRequest, BACnetServiceConfirmedEventNotification)
+	if processID, err := encoding.NewDecoder(notification.Payload).ContextUnsigned(0); err != nil || processID != 42 {
+		t.Errorf("notification process ID = %d, %v", processID, err)
+	}
+
+	offNormal, _ := s.notificationRecipients(model.EventNotification{NotificationClass: 5, ToState: model.EventStateOffNormal})
+	normal, _ := s.notificationRecipients(model.EventNotification{NotificationClass: 5, ToState: model.EventStateNormal})
+	if len(offNormal) != 1 || len(normal) != 2 {
+		t.Errorf("recipients: offnormal %d, normal %d", len(offNormal), len(normal))
+	}
+
+	// 绑定表即Device_Address_Binding
+	local := bipMAC(c.transport.LocalAddr())
+	bindings := s.AddressBindings()
+	if len(bindings) != 1 || bindings[0].Device != remote || bindings[0].Address.Network != 0 || !bytes.Equal(bindings[0].Address.MAC, local) {
+		t.Fatalf("AddressBindings() = %+v", bindings)
+	}
+	value, err := model.ReadPropertyValue(device, model.PropertyIdentifierDeviceAddressBinding)
+	want := append(encoding.EncodeObjectIdentifier(remote), encoding.EncodeUnsigned(0)...)
+	want = append(want, encoding.EncodeOctetString(local)...)
+	if err != nil || !bytes.Equal(encodeBACnetValue(value), want) {
+		t.Errorf("Device_Address_Binding = % X, %v", encodeBACnetValue(value), err)
+	}
+
+	// 经路由器转发的I-Am记录源网络，请求发给路由器
+	router := &net.UDPAddr{IP: net.IPv4(192, 0, 2, 200), Port: 47808}
+	source := uint16(7)
+	routed := append([]byte{BACnetAPDUTypeUnconfirmedServiceRequest << 4, BACnetServiceUnconfirmedIAm}, encoding.EncodeObjectIdentifier(model.ObjectIdentifier{Type: model.ObjectTypeDevice, Instance: 3001})...)
+	routed = append(routed, iAm[7:]...)
+	s.learnAddress(&RequestContext{ClientAddr: router.String(), ReplyAddr: router}, NPDU{SourceNetwork: &source, SourceMAC: []byte{0x15}}, routed)
+	addr, dest, err := s.resolveRecipient(model.Recipient{Device: &model.ObjectIdentifier{Type: model.ObjectTypeDevice, Instance: 3001}})
+	if err != nil || addr.String() != router.String() || dest == nil || dest.Network != 7 || !bytes.Equal(dest.MAC, []byte{0x15}) {
+		t.Errorf("resolveRecipient(3001) = %v, %+v, %v", addr, dest, err)
+	}
+
+	// 没有应答Who-Is的设备按重试次数放弃
+	if _, err := s.resolveDevice(4001); !errors.Is(err, errDeviceNotFound) {
+		t.Errorf("resolveDevice(4001) = %v", err)
+	}
+}
+
+func TestTimeSynchronization(t *testing.T) {
+	network := NewLoopbackNetwork()
+	device := model.NewDevice(1, "Test Device", "")
+	s, err := NewServer(device, Options{Transport: network.Attach(), TimeSyncInterval: 15 * time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewServer(model.NewDevice(2, "Other", ""), Options{Transport: network.Attach(), TimeSyncInterval: 90 * time.Second}); err == nil {
+		t.Error("NewServer(TimeSyncInterval: 90s) = nil error")
+	}
+	device.WriteProperty(model.PropertyIdentifierAlignIntervals, true)
+	device.WriteProperty(model.PropertyIdentifierIntervalOffset, uint32(5))
+	device.WriteProperty(model.PropertyIdentifierUTCOffset, int32(-60))
+	clock := model.NewFakeClock(time.Date(2024, 1, 15, 8, 2, 0, 0, time.UTC))
+	s.SetClock(clock)
+	c := NewTestClient(network.Attach(), time.Second)
+	defer c.Close()
+	expect := func(service byte, want time.Time) {
+		t.Helper()
+		apdu, err := c.Notification()
+		if err != nil || *apdu.ServiceChoice != service {
+			t.Fatalf("Notification() = %v, %v; want service %d", apdu, err, service)
+		}
+		wantPayload := append(encoding.EncodeDate(model.NewDate(want)), encoding.EncodeTime(model.NewTime(want))...)
+		if !bytes.Equal(apdu.Payload, wantPayload) {
+			t.Errorf("time synchronization = % X, want % X", apdu.Payload, wantPayload)
+		}
+	}
+
+	// 启动后立即发送，没有接收者时广播本地时间（UTC_Offset为-60即UTC+1）
+	s.timeSyncIfDue(clock.Now())
+	expect(BACnetServiceUnconfirmedTimeSynchronization, time.Date(2024, 1, 15, 9, 2, 0, 0, time.UTC))
+
+	// 对齐时在本地时间9:05开始的周期内发送一次
+	for _, step := range []time.Duration{time.Minute, 4 * time.Minute, 10 * time.Minute} {
+		clock.Advance(step)
+		s.timeSyncIfDue(clock.Now())
+	}
+	if want := time.Date(2024, 1, 15, 8, 7, 0, 0, time.UTC); !s.lastTimeSync.Equal(want) {
+		t.Errorf("last time synchronization = %v, want %v", s.lastTimeSync, want)
+	}
+	expect(BACnetServiceUnconfirmedTimeSynchronization, time.Date(2024, 1, 15, 9, 7, 0, 0, time.UTC))
+	clock.Advance(4 * time.Minute)
+	s.timeSyncIfDue(clock.Now())
+	expect(BACnetServiceUnconfirmedTimeSynchronization, time.Date(2024, 1, 15, 9, 21, 0, 0, time.UTC))
+
+	// 配置了接收者时只发给接收者，UTC接收者收到UTC时间
+	recipients := []model.Recipient{{Address: &model.Address{MAC: bipMAC(c.transport.LocalAddr())}}}
+	device.WriteProperty(model.PropertyIdentifierUTCTimeSynchronizationRecipients, recipients)
+	s.SendTimeSynchronization()
+	expect(BACnetServiceUnconfirmedUTCTimeSynchronization, time.Date(2024, 1, 15, 8, 21, 0, 0, time.UTC))
+	value, _ := device.ReadProperty(model.PropertyIdentifierUTCTimeSynchronizationRecipients)
+	if got, want := encodeBACnetValue(value), encoding.EncodeRecipient(recipients[0]); !bytes.Equal(got, want) {
+		t.Errorf("UTC_Time_Synchronization_Recipients = % X, want % X", got, want)
+	}
+	if decoded, _, ok, err := decodeConstructedValue(model.PropertyIdentifierUTCTimeSynchronizationRecipients, encodeBACnetValue(value)); !ok || err != nil || !reflect.DeepEqual(decoded, recipients) {
+		t.Errorf("decode recipients = %+v, %v", decoded, err)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	network := NewLoopbackNetwork()
+	device := model.NewDevice(1, "Test Device", "")
+	setpoint := model.NewAnalogValue(1, "Setpoint", model.UnitsDegreesCelsius)
+	device.AddObject(setpoint)
+	auditLog := model.NewAuditLog(1, "Audit Log", 10)
+	device.AddObject(auditLog)
+	loggerTransport := network.Attach()
+	logger := &model.Recipient{Address: &model.Address{MAC: bipMAC(loggerTransport.LocalAddr())}}
+	s, err := NewServer(device, Options{Transport: network.Attach(), AuditLogger: logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Start(context.Background())
+	defer s.Stop()
+	serverAddr := s.transport.LocalAddr()
+	auditor := NewTestClient(loggerTransport, time.Second)
+	defer auditor.Close()
+	c := NewTestClient(network.Attach(), time.Second)
+	defer c.Close()
+
+	if err := c.WriteProperty(serverAddr, setpoint.GetObjectIdentifier(), model.PropertyIdentifierPresentValue, float32(21.5), 8); err != nil {
+		t.Fatalf("WriteProperty() = %v", err)
+	}
+	if err := c.WriteProperty(serverAddr, setpoint.GetObjectIdentifier(), model.PropertyIdentifierObjectIdentifier, setpoint.GetObjectIdentifier(), 16); err == nil {
+		t.Fatal("WriteProperty(Object_Identifier) = nil error")
+	}
+
+	// 成功和失败的写入都记入审计日志，来源为客户端的B/IP地址
+	if n := auditLog.Log.Count(); n != 2 {
+		t.Fatalf("audit records = %d, want 2", n)
+	}
+	written, _ := auditLog.Log.Records[0].Datum.(model.AuditNotification)
+	source := bipMAC(c.transport.LocalAddr())
+	if written.Operation != model.AuditOperationWrite || *written.TargetObject != setpoint.GetObjectIdentifier() || *written.TargetProperty != model.PropertyIdentifierPresentValue ||
+		written.TargetPriority != 8 || written.TargetValue != float32(21.5) || written.Result != nil || !bytes.Equal(written.SourceDevice.Address.MAC, source) {
+		t.Errorf("audit record = %+v", written)
+	}
+	denied, _ := auditLog.Log.Records[1].Datum.(model.AuditNotification)
+	if denied.Result == nil || denied.Result.Class != ErrorClassProperty || denied.TargetPriority != 0 {
+		t.Errorf("failed write audit record = %+v", denied)
+	}
+
+	// 审计通知转发给审计记录设备
+	for _, record := range auditLog.Log.Records {
+		apdu, err := auditor.Notification()
+		if err != nil || *apdu.ServiceChoice != BACnetServiceUnconfirmedAuditNotification {
+			t.Fatalf("audit notification = %v, %v", apdu, err)
+		}
+		want := encoding.EncodeConstructed(0, encodeAuditNotification(record.Timestamp, record.Datum.(model.AuditNotification)))
+		if !bytes.Equal(apdu.Payload, want) {
+			t.Errorf("audit notification = % X, want % X", apdu.Payload, want)
+		}
+	}
+
+	// 本地操作的来源为本设备
+	s.writeProperty(nil, setpoint, model.PropertyIdentifierDescription, "local", 16)
+	local, _ := auditLog.Log.Records[2].Datum.(model.AuditNotification)
+	if local.SourceDevice.Device == nil || *local.SourceDevice.Device != device.GetObjectIdentifier() {
+		t.Errorf("local audit record source = %+v", local.SourceDevice)
+	}
+
+	// 审计记录随快照保存和恢复
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	if err := model.SaveSnapshot(device, path); err != nil {
+		t.Fatal(err)
+	}
+	restored := model.NewDevice(1, "Test Device", "")
+	restoredLog := model.NewAuditLog(1, "Audit Log", 10)
+	restored.AddObject(restoredLog)
+	if err := model.LoadSnapshot(restored, path); err != nil {
+		t.Fatal(err)
+	}
+	if got := restoredLog.Log.Records; len(got) != 3 || !reflect.DeepEqual(got[0].Datum, written) {
+		t.Errorf("restored audit records = %+v", got)
+	}
+}
+
+func TestRecipientQueue(t *testing.T) {
+	network := NewLoopbackNetwork()
+	s, err := newBACnetServer(model.NewDevice(1, "Test Device", ""), network.Attach(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := model.Recipient{Address: &model.Address{MAC: []byte{1}}}
+	second := model.Recipient{Address: &model.Address{MAC: []byte{2}}}
+
+	// 同一接收者的请求按放入的顺序逐个发送，阻塞的接收者不影响其他接收者
+	var mu sync.Mutex
+	var sent []int
+	release := make(chan struct{})
+	s.enqueueToRecipient(first, "test", func() { <-release })
+	for i := 0; i < 20; i++ {
+		i := i
+		s.enqueueToRecipient(first, "test", func() {
+			mu.Lock()
+			sent = append(sent, i)
+			mu.Unlock()
+		})
+	}
+	done := make(chan struct{})
+	s.enqueueToRecipient(second, "test", func() { close(done) })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("second recipient blocked by the first")
+	}
+	close(release)
+
+	// 关闭服务端时等待已排队的请求发送完
+	s.Stop()
+	for i, n := range sent {
+		if n != i {
+			t.Fatalf("sent = %v, want in order", sent)
+		}
+	}
+	if len(sent) != 20 {
+		t.Errorf("sent %d requests, want 20", len(sent))
+	}
+	s.enqueueToRecipient(first, "test", func() { t.Error("sent after Stop") })
+}
+
+func TestNewServer(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s, err := NewServer(model.NewDevice(1, "Test Device", ""), Options{Address: "127.0.0.1:0", QuarantineDir: "quarantine", Logger: logger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.transport.Close()
+	if s.Logger() != logger || s.quarantineDir != "quarantine" {
+		t.Errorf("options not applied: logger %v, quarantine dir %q", s.Logger(), s.quarantineDir)
+	}
+
+	// 指定Transport时不再监听Address，事务配置写入设备属性
+	network := NewLoopbackNetwork()
+	transport := network.Attach()
+	errs := make(chan string, 1)
+	device := model.NewDevice(2, "Test Device", "")
+	setpoint := model.NewAnalogValue(1, "Setpoint", model.UnitsDegreesCelsius)
+	device.AddObject(setpoint)
+	s, err = NewServer(device, Options{
+		Address:          "invalid",
+		Transport:        transport,
+		BroadcastAddress: "192.168.1.255:47808",
+		APDUTimeout:      2 * time.Second,
+		APDURetries:      1,
+		MaxAPDU:          480,
+		Workers:          4,
+		OnError:          func(peer string, err error) { errs <- peer },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.transport != transport || s.Logger() != slog.Default() || s.broadcastAddr().String() != "192.168.1.255:47808" {
+		t.Errorf("transport %v, logger %v, broadcast %v", s.transport, s.Logger(), s.broadcastAddr())
+	}
+	if device.APDUTimeout() != 2*time.Second || device.NumberOfAPDURetries() != 1 {
+		t.Errorf("APDU timeout %v, retries %d", device.APDUTimeout(), device.NumberOfAPDURetries())
+	}
+	s.Start(context.Background())
+	defer s.Stop()
+
+	// 多个处理goroutine时请求照常应答，I-Am中是配置的最大APDU长度
+	client := NewTestClient(network.Attach(), 200*time.Millisecond)
+	defer client.Close()
+	name, err := client.ReadProperty(transport.LocalAddr(), device.GetObjectIdentifier(), model.PropertyIdentifierObjectName)
+	if err != nil || name != "Test Device" {
+		t.Errorf("ReadProperty(Object_Name) = %v, %v", name, err)
+	}
+	if iAm := s.encodeIAm(); !bytes.Contains(iAm, []byte{0x22, 0x01, 0xE0}) {
+		t.Errorf("I-Am % X: want max APDU 480", iAm)
+	}
+	raw := network.Attach()
+	defer raw.Close()
+	raw.WriteTo([]byte{0x81, 0x0A, 0x00, 0x05, 0x02}, transport.LocalAddr())
+	select {
+	case peer := <-errs:
+		if peer != raw.LocalAddr().String() {
+			t.Errorf("OnError peer = %s, want %s", peer, raw.LocalAddr())
+		}
+	case <-time.After(time.Second):
+		t.Error("OnError not called for a malformed datagram")
+	}
+
+	// 同一来源连续发出的写请求按收到的顺序处理，最后一次写入的值生效
+	for i := 1; i <= 20; i++ {
+		request := append([]byte{0x00, 0x05, byte(i), BACnetServiceConfirmedWriteProperty},
+			encodeWritePropertyRequest(setpoint.GetObjectIdentifier(), model.PropertyIdentifierPresentValue, encodeBACnetValue(float32(i)), 16)...)
+		npdu := append([]byte{0x01, 0x04}, request...)
+		raw.WriteTo(append([]byte{0x81, 0x0A, 0x00, byte(len(npdu) + 4)}, npdu...), transport.LocalAddr())
+	}
+	for i := 1; i <= 20; i++ {
+		if reply := readDatagram(t, raw); len(reply) < 9 || reply[6] != 0x20 {
+			t.Errorf("WriteProperty reply % X: want SimpleACK", reply)
+		}
+	}
+	s.Lock()
+	if value, _ := setpoint.ReadProperty(model.PropertyIdentifierPresentValue); value != float32(20) {
+		t.Errorf("Present_Value = %v, want 20", value)
+	}
+	s.Unlock()
+
+	if _, err := NewServer(model.NewDevice(3, "Test Device", ""), Options{Transport: network.Attach(), MaxAPDU: 2000}); err == nil {
+		t.Error("NewServer() with MaxAPDU 2000: want error")
+	}
+}
+
+func TestHooks(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	temp := model.NewAnalogValue(1, "Temperature", model.UnitsDegreesCelsius)
+	device.AddObject(temp)
+	s := &BACnetServer{device: device}
+	ctx := &RequestContext{ClientAddr: "192.168.1.20:47808"}
+	oid := temp.GetObjectIdentifier()
+
+	// BeforeWrite可以拒绝或修改写入，AfterWrite收到最终写入的值
+	var written interface{}
+	s.BeforeWrite(func(op *Operation) error {
+		if op.Peer == "192.168.1.99:47808" {
+			return errors.New("denied")
+		}
+		if v, ok := op.Value.(float32); ok && v > 100 {
+			op.Value = float32(100)
+		}
+		return nil
+	})
+	s.AfterWrite(func(op *Operation) error {
+		written = op.Value
+		return nil
+	})
+	request := encodeWritePropertyRequest(oid, model.PropertyIdentifierPresentValue, encoding.EncodeReal(150), 16)
+	if response, _ := s.handleWriteProperty(ctx, request, 1); response[0] != BACnetAPDUTypeSimpleAck<<4 {
+		t.Fatalf("write response % X", response)
+	}
+	if value, _ := temp.ReadProperty(model.PropertyIdentifierPresentValue); written != float32(100) || value != float32(100) {
+		t.Errorf("written %v, present value %v: want 100", written, value)
+	}
+	response, _ := s.handleWriteProperty(&RequestContext{ClientAddr: "192.168.1.99:47808"}, request, 2)
+	if want := s.createErrorResponse(2, BACnetServiceConfirmedWriteProperty, ErrorClassProperty, ErrorCodeWriteAccessDenied); !bytes.Equal(response, want) {
+		t.Errorf("vetoed write = % X, want % X", response, want)
+	}
+
+	// BeforeRead设置值时不再读取对象，AfterRead返回*Error时按其类别和代码应答
+	s.BeforeRead(func(op *Operation) error {
+		if op.Property == model.PropertyIdentifierDescription {
+			op.Value = "from hook"
+		}
+		return nil
+	})
+	s.AfterRead(func(op *Operation) error {
+		if op.Property == model.PropertyIdentifierUnits {
+			return &Error{Class: ErrorClassSecurity, Code: ErrorCodeOther}
+		}
+		return nil
+	})
+	if value, err := s.readProperty(ctx, temp, model.PropertyIdentifierDescription, nil); err != nil || value != "from hook" {
+		t.Errorf("read Description = %v, %v", value, err)
+	}
+	response, _ = s.handleReadProperty(ctx, EncodeReadPropertyRequest(oid, model.PropertyIdentifierUnits, nil), 3)
+	if want := s.createErrorResponse(3, BACnetServiceConfirmedReadProperty, ErrorClassSecurity, ErrorCodeOther); !bytes.Equal(response, want) {
+		t.Errorf("read Units = % X, want % X", response, want)
+	}
+
+	// OnSubscribe可以限制订阅的有效期
+	s.OnSubscribe(func(op *Operation) error {
+		sub := op.Value.(model.COVSubscription)
+		if sub.Lifetime > 600 {
+			sub.Lifetime = 600
+		}
+		op.Value = sub
+		return nil
+	})
+	sub, err := s.subscribe(ctx, temp, model.PropertyIdentifierPresentValue, model.COVSubscription{Lifetime: 3600})
+	if err != nil || sub.Lifetime != 600 {
+		t.Errorf("subscribe lifetime = %d, %v", sub.Lifetime, err)
+	}
+}
+
+func TestPropertyProvider(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	setpoint := model.NewAnalogValue(1, "Setpoint", model.UnitsDegreesCelsius)
+	sensor := model.NewAnalogInput(2, "Sensor", model.UnitsDegreesCelsius)
+	device.AddObject(setpoint)
+	device.AddObject(sensor)
+	s := &BACnetServer{device: device}
+
+	// 可写的提供者收到写入的值和优先级，对象内存中的值不变
+	register := float32(21)
+	var priority uint8
+	setpoint.SetPropertyProvider(model.PropertyIdentifierPresentValue, model.ProviderFuncs{
+		Read: func(obj model.Object, prop model.PropertyIdentifier) (interface{}, error) { return register, nil },
+		Write: func(obj model.Object, prop model.PropertyIdentifier, value interface{}, p uint8) error {
+			register, priority = value.(float32), p
+			return nil
+		},
+	})
+	request := encodeWritePropertyRequest(setpoint.GetObjectIdentifier(), model.PropertyIdentifierPresentValue, encoding.EncodeReal(23.5), 8)
+	if response, _ := s.handleWriteProperty(nil, request, 1); response[0] != BACnetAPDUTypeSimpleAck<<4 {
+		t.Fatalf("write response % X", response)
+	}
+	if register != 23.5 || priority != 8 || setpoint.Value() != 0 {
+		t.Errorf("register %v, priority %d, in-memory value %v", register, priority, setpoint.Value())
+	}
+	if value, err := readPropertyValue(setpoint, model.PropertyIdentifierPresentValue, nil); err != nil || value != float32(23.5) {
+		t.Errorf("read Present_Value = %v, %v", value, err)
+	}
+
+	// 只读的提供者拒绝写入，返回*Error时按其类别和代码应答
+	sensor.SetPropertyProvider(model.PropertyIdentifierPresentValue, model.ProviderFuncs{
+		Read: func(obj model.Object, prop model.PropertyIdentifier) (interface{}, error) {
+			return nil, &Error{Class: ErrorClassCommunication, Code: ErrorCodeTimeout}
+		},
+	})
+	if err := model.WriteWithPriority(sensor, model.PropertyIdentifierPresentValue, float32(1), 16); !errors.Is(err, model.ErrWriteAccessDenied) {
+		t.Errorf("write read-only provider: err = %v", err)
+	}
+	response, _ := s.handleReadProperty(nil, EncodeReadPropertyRequest(sensor.GetObjectIdentifier(), model.PropertyIdentifierPresentValue, nil), 2)
+	if want := s.createErrorResponse(2, BACnetServiceConfirmedReadProperty, ErrorClassCommunication, ErrorCodeTimeout); !bytes.Equal(response, want) {
+		t.Errorf("read failing provider = % X, want % X", response, want)
+	}
+
+	// 解除绑定后恢复使用对象内存中的值
+	setpoint.SetPropertyProvider(model.PropertyIdentifierPresentValue, nil)
+	if value, _ := readPropertyValue(setpoint, model.PropertyIdentifierPresentValue, nil); value != float32(0) {
+		t.Errorf("read after unbinding = %v, want 0", value)
+	}
+}
+
+func TestAccessControl(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	temp := model.NewAnalogValue(1, "Temperature", model.UnitsDegreesCelsius)
+	device.AddObject(temp)
+	s := &BACnetServer{device: device}
+	if err := s.SetACL([]ACLRule{
+		{Source: "10.0.0.5", Services: WriteServices},
+		{Source: "10.0.0.0/8", Services: WriteServices, Deny: true},
+		{Source: "172.16.0.0/12", Deny: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	write := append([]byte{0x00, 0x05, 1, BACnetServiceConfirmedWriteProperty},
+		encodeWritePropertyRequest(temp.GetObjectIdentifier(), model.PropertyIdentifierPresentValue, encoding.EncodeReal(20), 16)...)
+	read := append([]byte{0x00, 0x05, 1, BACnetServiceConfirmedReadProperty},
+		EncodeReadPropertyRequest(temp.GetObjectIdentifier(), model.PropertyIdentifierPresentValue, nil)...)
+	accessDenied := s.createErrorResponse(1, BACnetServiceConfirmedWriteProperty, ErrorClassSecurity, ErrorCodeAccessDenied)
+	tests := []struct {
+		peer    string
+		request []byte
+		denied  bool
+	}{
+		{"10.0.0.5:47808", write, false},
+		{"10.1.2.3:47808", write, true},
+		{"10.1.2.3:47808", read, false},
+		{"172.16.1.1:47808", read, true},
+		{"192.168.1.20:47808", write, false},
+		{"mstp:5", write, false},
+	}
+	for _, tt := range tests {
+		response, err := s.handleBACnetAPDU(&RequestContext{ClientAddr: tt.peer}, tt.request)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.peer, err)
+		}
+		if denied := response[0] == BACnetAPDUTypeError<<4; denied != tt.denied {
+			t.Errorf("%s service 0x%02x: response % X, want denied %v", tt.peer, tt.request[3], response, tt.denied)
+		}
+	}
+	if response, _ := s.handleBACnetAPDU(&RequestContext{ClientAddr: "10.1.2.3:47808"}, write); !bytes.Equal(response, accessDenied) {
+		t.Errorf("denied write = % X, want % X", response, accessDenied)
+	}
+
+	// 只读模式拒绝所有来源的写入，读取不受影响
+	s.SetReadOnly(true)
+	response, _ := s.handleBACnetAPDU(&RequestContext{ClientAddr: "10.0.0.5:47808"}, write)
+	if want := s.createErrorResponse(1, BACnetServiceConfirmedWriteProperty, ErrorClassSecurity, ErrorCodeWriteAccessDenied); !bytes.Equal(response, want) {
+		t.Errorf("read-only write = % X, want % X", response, want)
+	}
+	if response, _ := s.handleBACnetAPDU(&RequestContext{ClientAddr: "10.0.0.5:47808"}, read); response[0] != BACnetAPDUTypeComplexAck<<4 {
+		t.Errorf("read-only read = % X", response)
+	}
+
+	if err := s.SetACL([]ACLRule{{Source: "10.0.0.0/33"}}); err == nil {
+		t.Error("SetACL() with an invalid subnet: want error")
+	}
+}
+
+func TestWriteProtection(t *testing.T) {
+	device := model.NewDevice(1, "Test Device", "")
+	setpoint := model.NewAnalogValue(1, "Setpoint", model.UnitsDegreesCelsius)
+	limit := model.NewAnalogValue(2, "High Limit", model.UnitsDegreesCelsius)
+	device.AddObject(setpoint)
+	device.AddObject(limit)
+	s := &BACnetServer{device: device}
+	if err := s.SetEngineers([]string{"10.0.0.0/24"}); err != nil {
+		t.Fatal(err)
+	}
+	s.Protect(setpoint.GetObjectIdentifier(), model.PropertyIdentifierPresentValue, ProtectionEngineerOnly)
+	s.Protect(limit.GetObjectIdentifier(), model.PropertyIdentifierAll, ProtectionReadOnly)
+
+	operator := &RequestContext{ClientAddr: "192.168.1.20:47808"}
+	engineer := &RequestContext{ClientAddr: "10.0.0.7:47808"}
+	write := func(obj model.Object, prop model.PropertyIdentifier, value []byte) []byte {
+		return encodeWritePropertyRequest(obj.GetObjectIdentifier(), prop, value, 16)
+	}
+	denied := s.createErrorResponse(1, BACnetServiceConfirmedWriteProperty, ErrorClassProperty, ErrorCodeWriteAccessDenied)
+	tests := []struct {
+		name    string
+		ctx     *RequestContext
+		request []byte
+		denied  bool
+	}{
+		{"operator writes engineer-only property", operator, write(setpoint, model.PropertyIdentifierPresentValue, encoding.EncodeReal(22)), true},
+		{"operator writes unprotected property", operator, write(setpoint, model.PropertyIdentifierDescription, encoding.EncodeCharacterString("Zone")), false},
+		{"engineer writes engineer-only property", engineer, write(setpoint, model.PropertyIdentifierPresentValue, encoding.EncodeReal(22)), false},
+		{"engineer writes read-only object", engineer, write(limit, model.PropertyIdentifierPresentValue, encoding.EncodeReal(30)), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, _ := s.handleWriteProperty(tt.ctx, tt.request, 1)
+			if got := bytes.Equal(response, denied); got != tt.denied {
+				t.Errorf("response % X, want denied %v", response, tt.denied)
+			}
+		})
+	}
+
+	// 本地API不受写保护限制
+	if err := model.WriteWithPriority(limit, model.PropertyIdentifierPresentValue, float32(30), 16); err != nil || limit.Value() != 30 {
+		t.Errorf("local write: %v, value %v", err, limit.Value())
+	}
+	s.Protect(limit.GetObjectIdentifier(), model.PropertyIdentifierAll, ProtectionNone)
+	if response, _ := s.handleWriteProperty(operator, write(limit, model.PropertyIdentifierPresentValue, encoding.EncodeReal(25)), 1); bytes.Equal(response, denied) {
+		t.Error("write after removing protection denied")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	s := &BACnetServer{device: model.NewDevice(1, "Test Device", "")}
+	s.SetRateLimit(10, 2)
+	now := time.Now()
+	client := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 20), Port: 47808}
+	other := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 21), Port: 47808}
+
+	// 突发2个之后丢弃，每100ms补充一个令牌，不同端口视为同一来源，其他来源不受影响
+	for i, want := range []bool{true, true, false} {
+		if got := s.limiter.allow(client, now); got != want {
+			t.Errorf("datagram %d: allow = %v, want %v", i, got, want)
+		}
+	}
+	if s.limiter.allow(&net.UDPAddr{IP: client.IP, Port: 47809}, now) {
+		t.Error("other port of a limited source allowed")
+	}
+	if !s.limiter.allow(other, now) {
+		t.Error("other source limited")
+	}
+	if !s.limiter.allow(client, now.Add(100*time.Millisecond)) {
+		t.Error("token not refilled after 100ms")
+	}
+	if got := s.RateLimitedPackets(); got != 2 {
+		t.Errorf("RateLimitedPackets() = %d, want 2", got)
+	}
+
+	// 全局限制Who-Is的应答，超出的不应答I-Am
+	s.SetDiscoveryRateLimit(1)
+	whoIs := []byte{0x10, BACnetServiceUnconfirmedWhoIs}
+	ctx := &RequestContext{ClientAddr: client.String()}
+	if response, _ := s.handleBACnetAPDU(ctx, whoIs); len(response) == 0 {
+		t.Error("first Who-Is not answered")
+	}
+	if response, _ := s.handleBACnetAPDU(ctx, whoIs); len(response) != 0 {
+		t.Errorf("second Who-Is answered with % X", response)
+	}
+	if got := s.RateLimitedDiscovery(); got != 1 {
+		t.Errorf("RateLimitedDiscovery() = %d, want 1", got)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	network := NewLoopbackNetwork()
+	transport := network.Attach()
+	device := model.NewDevice(1, "Test Device", "")
+	temp := model.NewAnalogValue(1, "Temperature", model.UnitsDegreesCelsius)
+	device.AddObject(temp)
+	s, err := NewServer(device, Options{Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Start(context.Background())
+	defer s.Stop()
+
+	client := NewTestClient(network.Attach(), 200*time.Millisecond)
+	defer client.Close()
+	if _, err := client.ReadProperty(transport.LocalAddr(), temp.GetObjectIdentifier(), model.PropertyIdentifierPresentValue); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ReadProperty(transport.LocalAddr(), temp.GetObjectIdentifier(), model.PropertyIdentifierEventTimeStamps); err == nil {
+		t.Fatal("ReadProperty(Event_Time_Stamps): want error")
+	}
+	if err := client.SubscribeCOV(transport.LocalAddr(), 1, temp.GetObjectIdentifier(), 60, false); err != nil {
+		t.Fatal(err)
+	}
+
+	m := s.Metrics()
+	if m.DatagramsReceived != 3 || m.DatagramsSent != 3 || m.Requests != 3 {
+		t.Errorf("datagrams received %d, sent %d, requests %d: want 3", m.DatagramsReceived, m.DatagramsSent, m.Requests)
+	}
+	if m.APDUs["confirmed/ReadProperty"] != 2 || m.APDUs["confirmed/SubscribeCOV"] != 1 {
+		t.Errorf("APDUs = %v", m.APDUs)
+	}
+	if key := fmt.Sprintf("%d/%d", ErrorClassProperty, ErrorCodeUnknownProperty); m.Errors[key] != 1 {
+		t.Errorf("Errors = %v, want %s", m.Errors, key)
+	}
+	if m.COVSubscriptions != 1 {
+		t.Errorf("COVSubscriptions = %d, want 1", m.COVSubscriptions)
+	}
+
+	var out strings.Builder
+	m.writePrometheus(&out)
+	for _, line := range []string{
+		"bacnet_datagrams_received_total 3",
+		`bacnet_apdus_received_total{type="confirmed",service="ReadProperty"} 2`,
+		"bacnet_cov_subscriptions 1",
+		`bacnet_request_duration_seconds_bucket{le="+Inf"} 3`,
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("Prometheus output missing %q:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestTrace(t *testing.T) {
+	network := NewLoopbackNetwork()
+	transport := network.Attach()
+	device := model.NewDevice(1, "Test Device", "")
+	s, err := NewServer(device, Options{Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	s.SetTrace(&out)
+	if !s.Tracing() {
+		t.Fatal("Tracing() = false after SetTrace")
+	}
+	s.Start(context.Background())
+	defer s.Stop()
+
+	client := NewTestClient(network.Attach(), 200*time.Millisecond)
+	defer client.Close()
+	if _, err := client.ReadProperty(transport.LocalAddr(), device.GetObjectIdentifier(), model.PropertyIdentifierObjectName); err != nil {
+		t.Fatal(err)
+	}
+	trace := out.String()
+	for _, want := range []string{
+		"RX from " + client.transport.LocalAddr().String(),
+		"TX to " + client.transport.LocalAddr().String(),
+		"Function: Original-Unicast-NPDU (0x0A)",
+		"APDU Type: ConfirmedServiceRequest (0)",
+		"Service Choice: ReadProperty (12)",
+		"APDU Type: ComplexAck (3)",
+		"Character String: Test Device",
+		"00000000  81 0a",
+	} {
+		if !strings.Contains(trace, want) {
+			t.Errorf("trace missing %q:\n%s", want, trace)
+		}
+	}
+
+	// 关闭跟踪后不再输出
+	s.SetTrace(nil)
+	out.Reset()
+	client.ReadProperty(transport.LocalAddr(), device.GetObjectIdentifier(), model.PropertyIdentifierObjectName)
+	if out.Len() != 0 {
+		t.Errorf("trace after SetTrace(nil):\n%s", out.String())
+	}
+}
+
+func TestDecodeFrame(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame []byte
+		want  []string
+	}{
+		{"Who-Is broadcast", []byte{0x81, 0x0B, 0x00, 0x08, 0x01, 0x20, 0xFF, 0xFF, 0x00, 0xFF, 0x10, 0x08},
+			[]string{"Original-Broadcast-NPDU", "Destination Network: 65535", "Hop Count: 255", "Service Choice: Who-Is (8)"}},
+		{"error", encodeBVLC(BVLCOriginalUnicastNPDU, []byte{0x01, 0x00, 0x50, 0x01, 0x0C, 0x91, 0x02, 0x91, 0x20}),
+			[]string{"APDU Type: Error (5)", "Error Class: 属性错误 (2)", "Error Code: 属性不存在 (32)"}},
+		{"network message", encodeBVLC(BVLCOriginalBroadcastNPDU, []byte{0x01, 0x80, 0x00}),
+			[]string{"Network Message: Who-Is-Router-To-Network (0x00)"}},
+		{"truncated", []byte{0x81, 0x0A}, []string{"[Malformed: 81 0A]"}},
+		{"constructed", encodeBVLC(BVLCOriginalUnicastNPDU, []byte{0x01, 0x00, 0x30, 0x01, 0x0C, 0x3E, 0x44, 0x41, 0xB0, 0x00, 0x00, 0x3F}),
+			[]string{"    {[3]\n            Real: 22\n        }[3]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DecodeFrame(tt.frame)
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("DecodeFrame() missing %q:\n%s", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestPcapCapture(t *testing.T) {
+	network := NewLoopbackNetwork()
+	transport := network.Attach()
+	device := model.NewDevice(1, "Test Device", "")
+	s, err := NewServer(device, Options{Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	capture, err := NewPcapWriter(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetCapture(capture)
+	s.Start(context.Background())
+	defer s.Stop()
+
+	client := NewTestClient(network.Attach(), 200*time.Millisecond)
+	defer client.Close()
+	if _, err := client.ReadProperty(transport.LocalAddr(), device.GetObjectIdentifier(), model.PropertyIdentifierObjectName); err != nil {
+		t.Fatal(err)
+	}
+	s.SetCapture(nil)
+
+	data := out.Bytes()
+	if len(data) < 24 || binary.LittleEndian.Uint32(data) != pcapMagic || binary.LittleEndian.Uint32(data[20:]) != pcapLinkTypeRaw {
+		t.Fatalf("pcap header % X", data[:min(len(data), 24)])
+	}
+	server := transport.LocalAddr().(*net.UDPAddr)
+	peer := client.transport.LocalAddr().(*net.UDPAddr)
+	var packets [][2]*net.UDPAddr
+	for data = data[24:]; len(data) >= 16; {
+		length := int(binary.LittleEndian.Uint32(data[8:]))
+		ip := data[16 : 16+length]
+		if ipv4Checksum(ip[:ipv4HeaderLength]) != 0 || ip[9] != 17 || int(binary.BigEndian.Uint16(ip[2:])) != length {
+			t.Errorf("invalid IPv4 header % X", ip[:ipv4HeaderLength])
+		}
+		udp := ip[ipv4HeaderLength:]
+		src := &net.UDPAddr{IP: net.IP(ip[12:16]), Port: int(binary.BigEndian.Uint16(udp[0:]))}
+		dst := &net.UDPAddr{IP: net.IP(ip[16:20]), Port: int(binary.BigEndian.Uint16(udp[2:]))}
+		if udp[udpHeaderLength] != BVLCTypeBACnetIP {
+			t.Errorf("UDP payload % X: want a BVLC frame", udp[udpHeaderLength:])
+		}
+		packets = append(packets, [2]*net.UDPAddr{src, dst})
+		data = data[16+length:]
+	}
+	if len(packets) != 2 || packets[0][0].String() != peer.String() || packets[0][1].String() != server.String() ||
+		packets[1][0].String() != server.String() || packets[1][1].String() != peer.String() {
+		t.Errorf("captured packets %v: want request %s -> %s and its reply", packets, peer, server)
+	}
+}
+
+// updateCorpus 为true时以实际应答重写testdata/corpus中文本用例的期望应答
+var updateCorpus = flag.Bool("update-corpus", false, "rewrite expected responses in testdata/corpus/*.txt")
+
+// corpusDevice 回放用例使用的设备，对象和属性值固定，使应答可以逐字节比较
+func corpusDevice() *model.Device {
+	device := model.NewDevice(1234, "Corpus Device", "Lab")
+	device.AddObject(model.NewAnalogInput(1, "Zone Temp", model.UnitsDegreesCelsius))
+	device.AddObject(model.NewAnalogValue(1, "Setpoint", model.UnitsDegreesCelsius))
+	device.AddObject(model.NewBinaryOutput(1, "Fan"))
+	return device
+}
+
+// replayStep 回放的一个请求和期望的结果
+type replayStep struct {
+	line     int    // 文本用例中请求所在的行，pcap用例中为数据包序号
+	peer     string // 请求的来源
+	request  []byte
+	response []byte // 期望的应答，为nil时不应答
+	fails    bool   // 期望处理出错
+}
+
+// parseHexFixture 解析文本用例：">"行为十六进制的请求，其后的"<"行为期望的应答，"!"行表示处理出错，
+// "#"开头的行为注释
+func parseHexFixture(data []byte) ([]replayStep, error) {
+	var steps []replayStep
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] != '>' && len(steps) == 0 {
+			return nil, fmt.Errorf("line %d: expected result before any request", i+1)
+		}
+		bytes, err := hex.DecodeString(strings.ReplaceAll(line[1:], " ", ""))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		switch line[0] {
+		case '>':
+			steps = append(steps, replayStep{line: i + 1, peer: "192.168.1.20:47808", request: bytes})
+		case '<':
+			steps[len(steps)-1].response = bytes
+		case '!':
+			steps[len(steps)-1].fails = true
+		default:
+			return nil, fmt.Errorf("line %d: unknown prefix %q", i+1, line[0])
+		}
+	}
+	return steps, nil
+}
+
+// formatHexFixture 以实际结果替换文本用例中的期望结果，注释和请求保持不变
+func formatHexFixture(data []byte, steps []replayStep) []byte {
+	var out strings.Builder
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	step := 0
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && (trimmed[0] == '<' || trimmed[0] == '!') {
+			continue
+		}
+		out.WriteString(line + "\n")
+		if trimmed == "" || trimmed[0] != '>' {
+			continue
+		}
+		switch s := steps[step]; {
+		case s.fails:
+			out.WriteString("!\n")
+		case s.response != nil:
+			fmt.Fprintf(&out, "< % x\n", s.response)
+		default:
+			out.WriteString("<\n")
+		}
+		step++
+	}
+	return []byte(out.String())
+}
+
+// pcapSteps 将抓包中的数据报转为回放步骤：第一个数据报的目的地址为服务端，
+// 发往服务端的是请求，服务端发出的紧随其后的数据报是应答
+func pcapSteps(packets []CapturedPacket) []replayStep {
+	if len(packets) == 0 {
+		return nil
+	}
+	server := packets[0].Destination.String()
+	var steps []replayStep
+	for i, p := range packets {
+		switch {
+		case p.Destination.String() == server:
+			steps = append(steps, replayStep{line: i + 1, peer: p.Source.String(), request: p.Payload})
+		case p.Source.String() == server && len(steps) > 0 && steps[len(steps)-1].response == nil:
+			steps[len(steps)-1].response = p.Payload
+		}
+	}
+	return steps
+}
+
+// replay 依次以processBACnetMessage处理请求，返回实际的结果
+func replay(s *BACnetServer, steps []replayStep) []replayStep {
+	got := make([]replayStep, len(steps))
+	for i, step := range steps {
+		addr, _ := net.ResolveUDPAddr("udp", step.peer)
+		response, err := s.processBACnetMessage(&RequestContext{ClientAddr: step.peer, ReplyAddr: addr}, step.request)
+		got[i] = step
+		got[i].response, got[i].fails = nil, err != nil
+		if len(response) > 0 {
+			got[i].response = response
+		}
+	}
+	return got
+}
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2024, 1, 15, 8, 0, 0, 0, time.UTC)
+	clock := model.NewFakeClock(start)
+
+	network := NewLoopbackNetwork()
+	transport := network.Attach()
+	device := model.NewDevice(1, "Test Device", "")
+	temp := model.NewAnalogValue(1, "Zone Temp", model.UnitsDegreesCelsius)
+	device.AddObject(temp)
+	trend := model.NewTrendLog(1, "Zone Temp Trend", 100)
+	trend.LogReference = &model.DeviceObjectPropertyReference{ObjectIdentifier: temp.GetObjectIdentifier(), PropertyIdentifier: model.PropertyIdentifierPresentValue}
+	device.AddObject(trend)
+	s, err := NewServer(device, Options{Transport: transport, Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Start(context.Background())
+
+	client := NewTestClient(network.Attach(), 200*time.Millisecond)
+	defer client.Close()
+	if err := client.SubscribeCOV(transport.LocalAddr(), 1, temp.GetObjectIdentifier(), 60, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SubscribeCOV(transport.LocalAddr(), 2, temp.GetObjectIdentifier(), 600, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// 快进2分钟：有效期60秒的订阅到期，趋势日志按60秒的间隔记录两次
+	clock.Advance(2 * time.Minute)
+	s.Stop()
+
+	if restart, _ := device.ReadProperty(model.PropertyIdentifierTimeOfDeviceRestart); restart != start {
+		t.Errorf("Time_Of_Device_Restart = %v, want %v", restart, start)
+	}
+	subscriptions := temp.COVSubscriptions()
+	if len(subscriptions) != 1 || subscriptions[0].Lifetime != 600 || !subscriptions[0].Expires.Equal(start.Add(10*time.Minute)) {
+		t.Errorf("subscriptions after 2 minutes = %+v, want only the 600 s one", subscriptions)
+	}
+	var times []time.Time
+	for _, record := range trend.Log.Records {
+		times = append(times, record.Timestamp)
+	}
+	if want := []time.Time{start.Add(time.Second), start.Add(61 * time.Second)}; !reflect.DeepEqual(times, want) {
+		t.Errorf("trend records at %v, want %v", times, want)
+	}
+	// 对象使用服务端配置的时钟
+	if got := temp.Clock().Now(); !got.Equal(start.Add(2 * time.Minute)) {
+		t.Errorf("object clock Now() = %v", got)
+	}
+}
+
+func TestOutOfRangeTimeDelay(t *testing.T) {
+	start := time.Date(2024, 1, 15, 8, 0, 0, 0, time.UTC)
+	clock := model.NewFakeClock(start)
+
+	device := model.NewDevice(1, "Test Device", "")
+	device.SetClock(clock)
+	temp := model.NewAnalogValue(1, "Zone Temp", model.UnitsDegreesCelsius)
+	device.AddObject(temp)
+	for prop, value := range map[model.PropertyIdentifier]interface{}{
+		model.PropertyIdentifierHighLimit:       float32(30),
+		model.PropertyIdentifierLowLimit:        float32(10),
+		model.PropertyIdentifierDeadband:        float32(2),
+		model.PropertyIdentifierLimitEnable:     model.BitString{true, true},
+		model.PropertyIdentifierTimeDelay:       uint32(10),
+		model.PropertyIdentifierTimeDelayNormal: uint32(30),
+	} {
+		if err := temp.WriteProperty(prop, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// step 将时钟前进d后写入Present_Value并执行一次对象调度
+	step := func(d time.Duration, value float32, want model.EventState) {
+		t.Helper()
+		clock.Advance(d)
+		if err := temp.WriteProperty(model.PropertyIdentifierPresentValue, value); err != nil {
+			t.Fatal(err)
+		}
+		device.Execute(clock.Now())
+		if state := temp.GetEventState(); state != want {
+			t.Fatalf("at +%v with %v: Event_State = %v, want %v", clock.Now().Sub(start), value, state, want)
+		}
+	}
+
+	// 越限持续不足Time_Delay时不报警，中途回到限值以内重新计时
+	step(0, 31, model.EventStateNormal)
+	step(5*time.Second, 31, model.EventStateNormal)
+	step(time.Second, 29, model.EventStateNormal)
+	step(time.Second, 31, model.EventStateNormal)
+	step(9*time.Second, 31, model.EventStateNormal)
+	step(time.Second, 31, model.EventStateHighLimit)
+	if stamp := temp.GetEventTimeStamps()[model.TransitionIndexToOffNormal]; !stamp.Equal(start.Add(17 * time.Second)) {
+		t.Errorf("to-offnormal time stamp = %v", stamp)
+	}
+
+	// 在Deadband以内不恢复，低于High_Limit-Deadband持续Time_Delay_Normal后恢复
+	step(time.Minute, 29, model.EventStateHighLimit)
+	step(time.Second, 27, model.EventStateHighLimit)
+	step(29*time.Second, 27, model.EventStateHighLimit)
+	step(time.Second, 27, model.EventStateNormal)
+
+	// 禁用限值时立即恢复
+	step(time.Second, 5, model.EventStateNormal)
+	step(10*time.Second, 5, model.EventStateLowLimit)
+	temp.WriteProperty(model.PropertyIdentifierLimitEnable, model.BitString{false, true})
+	step(time.Second, 5, model.EventStateNormal)
+
+	// Event_Detection_Enable为false时不检测
+	temp.WriteProperty(model.PropertyIdentifierEventDetectionEnable, false)
+	step(time.Second, 35, model.EventStateNormal)
+	step(time.Minute, 35, model.EventStateNormal)
+}
+
+// TestReplayCorpus 回放testdata/corpus中记录的客户端请求（十六进制文本或pcap），逐字节比较应答
+func TestReplayCorpus(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "corpus", "*"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("corpus files = %v, %v", files, err)
+	}
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var steps []replayStep
+			if filepath.Ext(file) == ".pcap" {
+				packets, err := ReadPcap(bytes.NewReader(data))
+				if err != nil {
+					t.Fatal(err)
+				}
+				steps = pcapSteps(packets)
+			} else if steps, err = parseHexFixture(data); err != nil {
+				t.Fatal(err)
+			}
+			if len(steps) == 0 {
+				t.Fatal("no requests")
+			}
+
+			got := replay(&BACnetServer{device: corpusDevice()}, steps)
+			if *updateCorpus && filepath.Ext(file) == ".txt" {
+				if err := os.WriteFile(file, formatHexFixture(data, got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			for i, step := range steps {
+				if got[i].fails != step.fails || !bytes.Equal(got[i].response, step.response) {
+					t.Errorf("%s:%d: request\n%s\ngot % X (fails %v)\n%s\nwant % X (fails %v)\n%s", file, step.line,
+						DecodeFrame(step.request), got[i].response, got[i].fails, DecodeFrame(got[i].response),
+						step.response, step.fails, DecodeFrame(step.response))
+				}
+			}
+		})
+	}
+}
+
+// benchmarkDevice 创建包含大量点位的设备，用于请求处理基准测试
+func benchmarkDevice(b *testing.B, points uint32) *model.Device {
+	b.Helper()
+	device := model.NewDevice(1, "Benchmark Device", "")
+	for i := uint32(1); i <= points; i++ {
+		if err := device.AddObject(model.NewAnalogValue(i, fmt.Sprintf("Point %d", i), model.UnitsNoUnits)); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return device
+}
+
+// benchmarkRequest 以B/IP单播帧处理请求，统计每次应答的分配
+func benchmarkRequest(b *testing.B, s *BACnetServer, apdu []byte) {
+	b.Helper()
+	request := encodeBVLC(BVLCOriginalUnicastNPDU, append([]byte{0x01, 0x04}, apdu...))
+	ctx := &RequestContext{ClientAddr: "192.168.1.20:47808"}
+	if response, err := s.processBACnetMessage(ctx, request); err != nil || len(response) < 7 || response[6]>>4 != BACnetAPDUTypeComplexAck {
+		b.Fatalf("response = % X, %v", response, err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.processBACnetMessage(ctx, request)
+	}
+}
+
+func BenchmarkReadProperty(b *testing.B) {
+	s := &BACnetServer{device: benchmarkDevice(b, 100)}
+	oid := model.ObjectIdentifier{Type: model.ObjectTypeAnalogValue, Instance: 50}
+	benchmarkRequest(b, s, append([]byte{0x00, 0x05, 1, BACnetServiceConfirmedReadProperty},
+		EncodeReadPropertyRequest(oid, model.PropertyIdentifierPresentValue, nil)...))
+}
+
+func BenchmarkReadPropertyMultiple(b *testing.B) {
+	s := &BACnetServer{device: benchmarkDevice(b, 100)}
+	// 10个对象，每个读取Present_Value、Status_Flags和Object_Name
+	var specs []byte
+	for i := uint32(1); i <= 10; i++ {
+		specs = append(specs, encoding.EncodeContextObjectIdentifier(0, model.ObjectIdentifier{Type: model.ObjectTypeAnalogValue, Instance: i})...)
+		specs = append(specs, 0x1E, 0x09, byte(model.PropertyIdentifierPresentValue), 0x09, byte(model.PropertyIdentifierStatusFlags),
+			0x09, byte(model.PropertyIdentifierObjectName), 0x1F)
+	}
+	benchmarkRequest(b, s, append([]byte{0x00, 0x05, 1, BACnetServiceConfirmedReadPropertyMultiple}, specs...))
+}
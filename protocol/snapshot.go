@@ -0,0 +1,42 @@
+package protocol
+
+import (
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// defaultSnapshotInterval 未配置周期时保存快照的周期
+const defaultSnapshotInterval = time.Minute
+
+// snapshotIfDue 距上次保存超过快照周期时保存快照，在对象调度中调用，与趋势日志的记录不会并发
+func (s *BACnetServer) snapshotIfDue(now time.Time) {
+	if s.snapshotFile == "" {
+		return
+	}
+	interval := s.snapshotInterval
+	if interval == 0 {
+		interval = defaultSnapshotInterval
+	}
+	if s.lastSnapshot.IsZero() {
+		s.lastSnapshot = now
+		return
+	}
+	if now.Sub(s.lastSnapshot) < interval {
+		return
+	}
+	s.lastSnapshot = now
+	s.saveSnapshot()
+}
+
+// saveSnapshot 保存设备快照
+func (s *BACnetServer) saveSnapshot() {
+	if s.snapshotFile == "" || s.device == nil {
+		return
+	}
+	if err := model.SaveSnapshot(s.device, s.snapshotFile); err != nil {
+		s.Logger().Error("保存快照文件失败", "file", s.snapshotFile, "error", err)
+		return
+	}
+	s.Logger().Debug("已保存快照", "file", s.snapshotFile)
+}
@@ -0,0 +1,36 @@
+package protocol
+
+import "syscall"
+
+// soReusePort Linux的SO_REUSEPORT选项（syscall包未定义，x86、ARM等常见架构上为15）
+const soReusePort = 0xf
+
+// setTOS 设置套接字发出的IPv4数据报的ToS和IPv6数据报的Traffic Class，至少一个成功即可
+func setTOS(raw syscall.RawConn, tos int) error {
+	var ipv4Err, ipv6Err error
+	err := raw.Control(func(fd uintptr) {
+		ipv4Err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS, tos)
+		ipv6Err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, tos)
+	})
+	if err != nil {
+		return err
+	}
+	if ipv4Err != nil && ipv6Err != nil {
+		return ipv4Err
+	}
+	return nil
+}
+
+// setReusePort 在绑定前为套接字设置SO_REUSEADDR和SO_REUSEPORT
+func setReusePort(raw syscall.RawConn) error {
+	var sockErr error
+	err := raw.Control(func(fd uintptr) {
+		if sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1); sockErr == nil {
+			sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePort, 1)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
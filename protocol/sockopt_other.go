@@ -0,0 +1,18 @@
+//go:build !linux
+
+package protocol
+
+import (
+	"errors"
+	"syscall"
+)
+
+// setTOS 设置套接字发出数据报的ToS，目前只支持Linux
+func setTOS(raw syscall.RawConn, tos int) error {
+	return errors.New("DSCP目前只支持Linux")
+}
+
+// setReusePort 设置SO_REUSEADDR和SO_REUSEPORT，目前只支持Linux
+func setReusePort(raw syscall.RawConn) error {
+	return errors.New("共享端口目前只支持Linux")
+}
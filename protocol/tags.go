@@ -0,0 +1,49 @@
+package protocol
+
+import (
+	"time"
+
+	"github.com/iotzf/bacnet-server/encoding"
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// encodeDateTime 以开始/结束标签包裹编码BACnetDateTime
+func encodeDateTime(number uint8, t time.Time) []byte {
+	out := encoding.EncodeDate(encoding.NewDate(t))
+	out = append(out, encoding.EncodeTime(encoding.NewTime(t))...)
+	return encoding.EncodeConstructed(number, out)
+}
+
+// encodeTimeStamp 编码BACnetTimeStamp（使用dateTime选项），零值时间编码为全通配符
+func encodeTimeStamp(number uint8, t time.Time) []byte {
+	return encoding.EncodeConstructed(number, encodeTimeStampValue(t))
+}
+
+// encodeTimeStampValue 编码不带外层上下文标签的BACnetTimeStamp，用作属性值
+func encodeTimeStampValue(t time.Time) []byte {
+	return encoding.EncodeTimeStamp(model.TimeStamp{DateTime: &t})
+}
+
+// decodeTimeStamp 读取BACnetTimeStamp，返回时间（序列号选项返回零值时间）和序列号，
+// 仅包含时间的选项以now的日期补全
+func decodeTimeStamp(d *encoding.Decoder, number uint8, now time.Time) (time.Time, uint32, error) {
+	if err := d.Opening(number); err != nil {
+		return time.Time{}, 0, err
+	}
+	ts, err := d.TimeStamp()
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	if err := d.Closing(number); err != nil {
+		return time.Time{}, 0, err
+	}
+
+	switch {
+	case ts.Time != nil:
+		// time选项：仅包含时间，日期取今天
+		return encoding.DateTime(encoding.NewDate(now), *ts.Time), 0, nil
+	case ts.SequenceNumber != nil:
+		return time.Time{}, *ts.SequenceNumber, nil
+	}
+	return *ts.DateTime, 0, nil
+}
@@ -0,0 +1,236 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// TestClient 最小BACnet客户端：发送Who-Is、ReadProperty、ReadPropertyMultiple、WriteProperty和SubscribeCOV，
+// 并收集收到的通知。测试中通常与LoopbackTransport一起使用，命令行工具的客户端子命令使用UDP传输
+type TestClient struct {
+	transport     Transport
+	timeout       time.Duration
+	mu            sync.Mutex
+	invokeID      byte
+	responses     chan clientMessage // 对确认请求的应答
+	notifications chan clientMessage // 收到的未确认请求和确认通知
+}
+
+// clientMessage 客户端收到的请求及其来源
+type clientMessage struct {
+	apdu *APDU
+	from net.Addr
+}
+
+// DiscoveredDevice 应答Who-Is的设备，取自I-Am
+type DiscoveredDevice struct {
+	ID           model.ObjectIdentifier
+	Address      net.Addr
+	MaxAPDU      uint32
+	Segmentation uint32
+	VendorID     uint32
+}
+
+// NewTestClient 创建在transport上收发的客户端，timeout为等待应答的时间
+func NewTestClient(transport Transport, timeout time.Duration) *TestClient {
+	c := &TestClient{
+		transport:     transport,
+		timeout:       timeout,
+		responses:     make(chan clientMessage, 16),
+		notifications: make(chan clientMessage, 64),
+	}
+	go c.receive()
+	return c
+}
+
+// Close 关闭客户端的传输
+func (c *TestClient) Close() error {
+	return c.transport.Close()
+}
+
+// receive 接收数据报，应答交给等待中的请求，通知放入通知队列，确认通知以SimpleAck应答
+func (c *TestClient) receive() {
+	buffer := make([]byte, c.transport.MTU())
+	for {
+		n, from, err := c.transport.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+		apdu, err := DecodeDatagram(buffer[:n])
+		if err != nil {
+			continue
+		}
+		queue := c.responses
+		switch apdu.PDUType {
+		case BACnetAPDUTypeUnconfirmedServiceRequest:
+			queue = c.notifications
+		case BACnetAPDUTypeConfirmedServiceRequest:
+			c.transport.WriteTo(EncodeDatagram(EncodeSimpleAck(*apdu.InvokeID, *apdu.ServiceChoice), false), from)
+			queue = c.notifications
+		}
+		select {
+		case queue <- clientMessage{apdu: apdu, from: from}:
+		default: // 没有取走的消息直接丢弃
+		}
+	}
+}
+
+// send 发送APDU，to为nil时发往广播地址
+func (c *TestClient) send(apdu []byte, to net.Addr) error {
+	broadcast := to == nil
+	if broadcast {
+		to = c.transport.BroadcastAddr()
+	}
+	_, err := c.transport.WriteTo(EncodeDatagram(apdu, broadcast), to)
+	return err
+}
+
+// request 发送确认请求并等待对应的应答，Error、Reject和Abort应答作为错误返回
+func (c *TestClient) request(server net.Addr, service byte, payload []byte) (*APDU, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.invokeID++
+	invokeID := c.invokeID
+	if err := c.send(EncodeConfirmedRequest(invokeID, service, payload), server); err != nil {
+		return nil, err
+	}
+
+	deadline := time.After(c.timeout)
+	for {
+		select {
+		case m := <-c.responses:
+			apdu := m.apdu
+			if apdu.InvokeID == nil || *apdu.InvokeID != invokeID {
+				continue // 之前超时的请求的迟到应答
+			}
+			if err := apdu.Err(); err != nil {
+				return nil, err
+			}
+			return apdu, nil
+		case <-deadline:
+			return nil, fmt.Errorf("请求超时: 服务=%d, InvokeID=%d", service, invokeID)
+		}
+	}
+}
+
+// WhoIs 广播Who-Is，返回在超时时间内应答I-Am的设备
+func (c *TestClient) WhoIs() ([]model.ObjectIdentifier, error) {
+	found, err := c.Discover(nil, 0, 0)
+	var devices []model.ObjectIdentifier
+	for _, device := range found {
+		devices = append(devices, device.ID)
+	}
+	return devices, err
+}
+
+// Discover 向to（为nil时广播）发送Who-Is，返回在超时时间内应答I-Am的设备。
+// high不为0时只询问实例号在low到high之间的设备
+func (c *TestClient) Discover(to net.Addr, low, high uint32) ([]DiscoveredDevice, error) {
+	if err := c.send(EncodeWhoIs(low, high), to); err != nil {
+		return nil, err
+	}
+	var devices []DiscoveredDevice
+	deadline := time.After(c.timeout)
+	for {
+		select {
+		case m := <-c.notifications:
+			if m.apdu.ServiceChoice == nil || *m.apdu.ServiceChoice != BACnetServiceUnconfirmedIAm {
+				continue
+			}
+			if device, err := DecodeIAm(m.apdu.Payload); err == nil {
+				device.Address = m.from
+				devices = append(devices, device)
+			}
+		case <-deadline:
+			return devices, nil
+		}
+	}
+}
+
+// Locate 向to发送只询问instance的Who-Is，返回第一个应答的设备，超时时返回错误
+func (c *TestClient) Locate(to net.Addr, instance uint32) (DiscoveredDevice, error) {
+	if err := c.send(EncodeWhoIs(instance, instance), to); err != nil {
+		return DiscoveredDevice{}, err
+	}
+	deadline := time.After(c.timeout)
+	for {
+		select {
+		case m := <-c.notifications:
+			if m.apdu.ServiceChoice == nil || *m.apdu.ServiceChoice != BACnetServiceUnconfirmedIAm {
+				continue
+			}
+			if device, err := DecodeIAm(m.apdu.Payload); err == nil && device.ID.Instance == instance {
+				device.Address = m.from
+				return device, nil
+			}
+		case <-deadline:
+			return DiscoveredDevice{}, fmt.Errorf("设备%d没有应答I-Am", instance)
+		}
+	}
+}
+
+// ReadProperty 读取属性值，返回解码后的应用标签值，值为数组或列表时返回[]interface{}
+func (c *TestClient) ReadProperty(server net.Addr, oid model.ObjectIdentifier, prop model.PropertyIdentifier) (interface{}, error) {
+	return c.readProperty(server, oid, prop, nil)
+}
+
+// ReadPropertyIndex 读取数组属性的一个元素，index为0时读取数组长度
+func (c *TestClient) ReadPropertyIndex(server net.Addr, oid model.ObjectIdentifier, prop model.PropertyIdentifier, index uint32) (interface{}, error) {
+	return c.readProperty(server, oid, prop, &index)
+}
+
+// readProperty 发送ReadProperty请求并解码应答中的属性值
+func (c *TestClient) readProperty(server net.Addr, oid model.ObjectIdentifier, prop model.PropertyIdentifier, index *uint32) (interface{}, error) {
+	ack, err := c.request(server, BACnetServiceConfirmedReadProperty, EncodeReadPropertyRequest(oid, prop, index))
+	if err != nil {
+		return nil, err
+	}
+	return DecodeReadPropertyAck(ack.Payload)
+}
+
+// ReadPropertyMultiple 在一个请求中读取多个对象的属性，按应答的顺序返回每个属性的结果，
+// ALL等特殊属性引用由服务端展开
+func (c *TestClient) ReadPropertyMultiple(server net.Addr, specs []ReadAccessSpecification) ([]PropertyResult, error) {
+	ack, err := c.request(server, BACnetServiceConfirmedReadPropertyMultiple, EncodeReadPropertyMultipleRequest(specs))
+	if err != nil {
+		return nil, err
+	}
+	return DecodeReadPropertyMultipleAck(ack.Payload)
+}
+
+// WriteProperty 写入属性值，value按EncodeApplication编码（nil写入NULL以释放命令），
+// priority为0时不携带优先级
+func (c *TestClient) WriteProperty(server net.Addr, oid model.ObjectIdentifier, prop model.PropertyIdentifier, value interface{}, priority uint8) error {
+	payload, err := EncodeWritePropertyRequest(oid, prop, value, priority)
+	if err != nil {
+		return err
+	}
+	_, err = c.request(server, BACnetServiceConfirmedWriteProperty, payload)
+	return err
+}
+
+// SubscribeCOV 以订阅者进程ID processID订阅对象的COV通知，lifetime为0时订阅永久有效。
+// 以相同的进程ID再次订阅同一对象时更新原有的订阅
+func (c *TestClient) SubscribeCOV(server net.Addr, processID uint32, oid model.ObjectIdentifier, lifetime uint32, confirmed bool) error {
+	payload, err := EncodeSubscribeCOVRequest(SubscribeCOVRequest{SubscriberProcessID: processID, ObjectID: oid, IssueConfirmedNotif: &confirmed, Lifetime: &lifetime})
+	if err != nil {
+		return err
+	}
+	_, err = c.request(server, BACnetServiceConfirmedSubscribeCOV, payload)
+	return err
+}
+
+// Notification 等待下一个收到的通知（未确认请求或确认COV通知）
+func (c *TestClient) Notification() (*APDU, error) {
+	select {
+	case m := <-c.notifications:
+		return m.apdu, nil
+	case <-time.After(c.timeout):
+		return nil, errors.New("等待通知超时")
+	}
+}
@@ -0,0 +1,83 @@
+package protocol
+
+import (
+	"time"
+
+	"github.com/iotzf/bacnet-server/encoding"
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// 时间同步服务的服务选择
+const (
+	BACnetServiceUnconfirmedTimeSynchronization    = 0x06
+	BACnetServiceUnconfirmedUTCTimeSynchronization = 0x09
+)
+
+// timeSyncIfDue 到了Time_Synchronization_Interval的周期时发送时间同步，在对象调度中调用。
+// 不对齐时启动后立即发送一次，之后每隔一个周期发送；Align_Intervals为真时在本地时间零点起
+// 每个周期的开始（加上Interval_Offset）之后发送，周期为一小时的因数时即对齐到整点
+func (s *BACnetServer) timeSyncIfDue(now time.Time) {
+	interval := s.device.TimeSynchronizationInterval()
+	if interval <= 0 {
+		s.lastTimeSync = time.Time{}
+		return
+	}
+	due := s.lastTimeSync.IsZero() || now.Sub(s.lastTimeSync) >= interval
+	if align, offset := s.device.AlignIntervals(); align && !s.lastTimeSync.IsZero() {
+		start := periodStart(s.device.LocalTime(now), interval, offset%interval)
+		due = s.device.LocalTime(s.lastTimeSync).Before(start)
+	}
+	if !due {
+		return
+	}
+	s.lastTimeSync = now
+	s.SendTimeSynchronization()
+}
+
+// periodStart 返回local所在的同步周期的开始：从当天零点起每隔interval一个周期，整体偏移offset
+func periodStart(local time.Time, interval, offset time.Duration) time.Time {
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+	elapsed := local.Sub(midnight) - offset
+	periods := elapsed / interval
+	if elapsed < 0 {
+		periods--
+	}
+	return midnight.Add(offset + periods*interval)
+}
+
+// SendTimeSynchronization 以服务端时钟的当前时间向Time_Synchronization_Recipients发送TimeSynchronization（本地时间）、
+// 向UTC_Time_Synchronization_Recipients发送UTCTimeSynchronization；两者都没有配置时广播TimeSynchronization
+func (s *BACnetServer) SendTimeSynchronization() {
+	if s.transport == nil {
+		return
+	}
+	now := s.now()
+	local, _ := readProperty(s.device, model.PropertyIdentifierTimeSynchronizationRecipients).([]model.Recipient)
+	utc, _ := readProperty(s.device, model.PropertyIdentifierUTCTimeSynchronizationRecipients).([]model.Recipient)
+	localRequest := encodeTimeSynchronization(BACnetServiceUnconfirmedTimeSynchronization, s.device.LocalTime(now))
+	if len(local) == 0 && len(utc) == 0 {
+		if _, err := s.broadcastNPDU(append([]byte{0x01, 0x00}, localRequest...)); err != nil {
+			s.Logger().Warn("广播时间同步失败", "error", err)
+		}
+		return
+	}
+	utcRequest := encodeTimeSynchronization(BACnetServiceUnconfirmedUTCTimeSynchronization, now.UTC())
+	for _, recipient := range local {
+		s.sendToRecipient(recipient, localRequest, "时间同步")
+	}
+	for _, recipient := range utc {
+		s.sendToRecipient(recipient, utcRequest, "UTC时间同步")
+	}
+	s.logPacket("已发送时间同步", "recipients", len(local), "utc_recipients", len(utc))
+}
+
+// encodeTimeSynchronization 编码时间同步请求
+//
+//	TimeSynchronization-Request ::= SEQUENCE { time BACnetDateTime }
+//
+// UTCTimeSynchronization-Request的格式相同
+func encodeTimeSynchronization(service byte, t time.Time) []byte {
+	apdu := []byte{BACnetAPDUTypeUnconfirmedServiceRequest << 4, service}
+	apdu = append(apdu, encoding.EncodeDate(model.NewDate(t))...)
+	return append(apdu, encoding.EncodeTime(model.NewTime(t))...)
+}
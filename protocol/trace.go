@@ -0,0 +1,262 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iotzf/bacnet-server/encoding"
+)
+
+// bvlcFunctionNames BVLC功能码名称
+var bvlcFunctionNames = map[byte]string{
+	BVLCResult:                            "BVLC-Result",
+	BVLCWriteBroadcastDistributionTable:   "Write-Broadcast-Distribution-Table",
+	BVLCReadBroadcastDistributionTable:    "Read-Broadcast-Distribution-Table",
+	BVLCReadBroadcastDistributionTableAck: "Read-Broadcast-Distribution-Table-Ack",
+	BVLCForwardedNPDU:                     "Forwarded-NPDU",
+	BVLCRegisterForeignDevice:             "Register-Foreign-Device",
+	BVLCReadForeignDeviceTable:            "Read-Foreign-Device-Table",
+	BVLCReadForeignDeviceTableAck:         "Read-Foreign-Device-Table-Ack",
+	BVLCDeleteForeignDeviceTableEntry:     "Delete-Foreign-Device-Table-Entry",
+	BVLCDistributeBroadcastToNetwork:      "Distribute-Broadcast-To-Network",
+	BVLCOriginalUnicastNPDU:               "Original-Unicast-NPDU",
+	BVLCOriginalBroadcastNPDU:             "Original-Broadcast-NPDU",
+	BVLCSecureBVLL:                        "Secure-BVLL",
+}
+
+// bvlcFunctionName 返回BVLC功能码的名称
+func bvlcFunctionName(function byte) string {
+	if name, ok := bvlcFunctionNames[function]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(0x%02X)", function)
+}
+
+// tracer 跟踪输出，为nil时不跟踪
+type tracer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// SetTrace 将此后收发的每个B/IP数据报以分层解码和十六进制转储写入w，w为nil时关闭跟踪。
+// 可在运行中随时调用
+func (s *BACnetServer) SetTrace(w io.Writer) {
+	if w == nil {
+		s.tracer.Store(nil)
+		return
+	}
+	s.tracer.Store(&tracer{w: w})
+}
+
+// Tracing 判断是否正在跟踪数据报
+func (s *BACnetServer) Tracing() bool {
+	return s.tracer.Load() != nil
+}
+
+// trace 跟踪开启时写出一个数据报，direction为"RX"或"TX"
+func (s *BACnetServer) trace(direction string, peer net.Addr, data []byte) {
+	t := s.tracer.Load()
+	if t == nil {
+		return
+	}
+	text := fmt.Sprintf("%s %s %s %s (%d bytes)\n%s%s\n", time.Now().Format("15:04:05.000000"), direction,
+		traceArrow(direction), peer, len(data), DecodeFrame(data), hex.Dump(data))
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	io.WriteString(t.w, text)
+}
+
+// traceArrow 返回方向对应的说明
+func traceArrow(direction string) string {
+	if direction == "TX" {
+		return "to"
+	}
+	return "from"
+}
+
+// DecodeFrame 以类似Wireshark的分层格式解码B/IP帧：BVLC、NPDU、APDU和服务参数的标签，
+// 无法解析的部分以十六进制给出
+func DecodeFrame(data []byte) string {
+	var sb strings.Builder
+	decodeBVLC(&sb, data)
+	return sb.String()
+}
+
+// decodeBVLC 解码BVLC层及其承载的NPDU
+func decodeBVLC(sb *strings.Builder, data []byte) {
+	sb.WriteString("BACnet Virtual Link Control\n")
+	if len(data) < bvlcHeaderLength {
+		fmt.Fprintf(sb, "    [Malformed: % X]\n", data)
+		return
+	}
+	fmt.Fprintf(sb, "    Type: 0x%02X\n", data[0])
+	fmt.Fprintf(sb, "    Function: %s (0x%02X)\n", bvlcFunctionName(data[1]), data[1])
+	fmt.Fprintf(sb, "    Length: %d\n", binary.BigEndian.Uint16(data[2:4]))
+	payload := data[bvlcHeaderLength:]
+	switch data[1] {
+	case BVLCOriginalUnicastNPDU, BVLCOriginalBroadcastNPDU, BVLCDistributeBroadcastToNetwork:
+		decodeNPDU(sb, payload)
+	case BVLCForwardedNPDU:
+		origin, err := readBIPAddress(encoding.NewReader(payload))
+		if err != nil {
+			fmt.Fprintf(sb, "    [Malformed: % X]\n", payload)
+			return
+		}
+		fmt.Fprintf(sb, "    Original Source: %s\n", origin)
+		decodeNPDU(sb, payload[bipAddressLength:])
+	case BVLCResult:
+		if len(payload) >= 2 {
+			code := binary.BigEndian.Uint16(payload)
+			fmt.Fprintf(sb, "    Result: %s (0x%04X)\n", bvlcResultName(code), code)
+		}
+	case BVLCRegisterForeignDevice:
+		if len(payload) >= 2 {
+			fmt.Fprintf(sb, "    TTL: %d\n", binary.BigEndian.Uint16(payload))
+		}
+	default:
+		if len(payload) > 0 {
+			fmt.Fprintf(sb, "    Data: % X\n", payload)
+		}
+	}
+}
+
+// decodeNPDU 解码NPDU头部及其承载的APDU或网络层消息
+func decodeNPDU(sb *strings.Builder, data []byte) {
+	sb.WriteString("Building Automation and Control Network NPDU\n")
+	npdu, offset, err := ParseNPDU(data)
+	if err != nil {
+		fmt.Fprintf(sb, "    [Malformed: %v]\n", err)
+		return
+	}
+	fmt.Fprintf(sb, "    Version: %d\n", npdu.Version)
+	fmt.Fprintf(sb, "    Control: 0x%02X (%s)\n", data[1], npdu.Control)
+	if npdu.DestinationNetwork != nil {
+		fmt.Fprintf(sb, "    Destination Network: %d\n", *npdu.DestinationNetwork)
+		fmt.Fprintf(sb, "    Destination MAC: [% X]\n", npdu.DestinationMAC)
+	}
+	if npdu.SourceNetwork != nil {
+		fmt.Fprintf(sb, "    Source Network: %d\n", *npdu.SourceNetwork)
+		fmt.Fprintf(sb, "    Source MAC: [% X]\n", npdu.SourceMAC)
+	}
+	if npdu.HopCount != nil {
+		fmt.Fprintf(sb, "    Hop Count: %d\n", *npdu.HopCount)
+	}
+	payload := data[offset:]
+	if npdu.Control.NetworkMessageFlag {
+		if len(payload) == 0 {
+			sb.WriteString("    [Malformed: missing message type]\n")
+			return
+		}
+		fmt.Fprintf(sb, "    Network Message: %s (0x%02X)\n", networkMessageName(payload[0]), payload[0])
+		if len(payload) > 1 {
+			fmt.Fprintf(sb, "    Data: % X\n", payload[1:])
+		}
+		return
+	}
+	decodeAPDU(sb, payload)
+}
+
+// decodeAPDU 解码APDU头部和服务参数
+func decodeAPDU(sb *strings.Builder, data []byte) {
+	sb.WriteString("Building Automation and Control Network APDU\n")
+	apdu, err := ParseAPDU(data)
+	if err != nil {
+		fmt.Fprintf(sb, "    [Malformed: %v]\n", err)
+		return
+	}
+	fmt.Fprintf(sb, "    APDU Type: %s (%d)\n", pduTypeName(apdu.PDUType), apdu.PDUType)
+	if apdu.ControlFlags != 0 {
+		fmt.Fprintf(sb, "    Flags: 0x%X\n", apdu.ControlFlags)
+	}
+	if apdu.InvokeID != nil {
+		fmt.Fprintf(sb, "    Invoke ID: %d\n", *apdu.InvokeID)
+	}
+	if apdu.SequenceNumber != nil {
+		fmt.Fprintf(sb, "    Sequence Number: %d\n", *apdu.SequenceNumber)
+	}
+	if apdu.ServiceChoice != nil {
+		name := apdu.ServiceName()
+		if apdu.PDUType == BACnetAPDUTypeUnconfirmedServiceRequest {
+			name = unconfirmedServiceName(*apdu.ServiceChoice)
+		}
+		fmt.Fprintf(sb, "    Service Choice: %s (%d)\n", name, *apdu.ServiceChoice)
+	}
+	switch {
+	case apdu.PDUType == BACnetAPDUTypeReject && len(apdu.Payload) > 0:
+		fmt.Fprintf(sb, "    Reject Reason: %s (%d)\n", rejectReasonName(apdu.Payload[0]), apdu.Payload[0])
+		return
+	case apdu.PDUType == BACnetAPDUTypeAbort && len(apdu.Payload) > 0:
+		fmt.Fprintf(sb, "    Abort Reason: %d\n", apdu.Payload[0])
+		return
+	case apdu.PDUType == BACnetAPDUTypeError:
+		if class, code, err := decodeErrorPayload(apdu.Payload); err == nil {
+			fmt.Fprintf(sb, "    Error Class: %s (%d)\n", errorClassName(class), class)
+			fmt.Fprintf(sb, "    Error Code: %s (%d)\n", errorCodeName(code), code)
+			return
+		}
+	}
+	if len(apdu.Payload) > 0 {
+		sb.WriteString("    Service Parameters\n")
+		decodeTags(sb, apdu.Payload, 2)
+	}
+}
+
+// decodeTags 逐个解码标签：应用标签给出类型和值，上下文标签给出原始字节，开始和结束标签之间缩进
+func decodeTags(sb *strings.Builder, data []byte, depth int) {
+	base := depth
+	for len(data) > 0 {
+		tag, n, err := encoding.DecodeTag(data)
+		if err != nil {
+			fmt.Fprintf(sb, "%s[Malformed: % X]\n", strings.Repeat("    ", depth), data)
+			return
+		}
+		switch {
+		case tag.Opening:
+			fmt.Fprintf(sb, "%s{[%d]\n", strings.Repeat("    ", depth), tag.Number)
+			depth++
+			data = data[n:]
+			continue
+		case tag.Closing:
+			if depth > base {
+				depth--
+			}
+			fmt.Fprintf(sb, "%s}[%d]\n", strings.Repeat("    ", depth), tag.Number)
+			data = data[n:]
+			continue
+		}
+		indent := strings.Repeat("    ", depth)
+		if !tag.Context {
+			value, size, err := encoding.DecodeApplication(data)
+			if err != nil {
+				fmt.Fprintf(sb, "%s[Malformed: % X]\n", indent, data)
+				return
+			}
+			fmt.Fprintf(sb, "%s%s: %v\n", indent, applicationTagName(tag.Number), value)
+			data = data[size:]
+			continue
+		}
+		end := n + int(tag.Length)
+		if tag.Length > uint32(len(data)) || end > len(data) {
+			fmt.Fprintf(sb, "%s[Malformed: % X]\n", indent, data)
+			return
+		}
+		fmt.Fprintf(sb, "%s[%d]: % X\n", indent, tag.Number, data[n:end])
+		data = data[end:]
+	}
+}
+
+// applicationTagName 返回应用标签的类型名称
+func applicationTagName(number uint8) string {
+	names := [...]string{"Null", "Boolean", "Unsigned", "Signed", "Real", "Double", "Octet String",
+		"Character String", "Bit String", "Enumerated", "Date", "Time", "Object Identifier"}
+	if int(number) < len(names) {
+		return names[number]
+	}
+	return fmt.Sprintf("Application Tag %d", number)
+}
@@ -0,0 +1,167 @@
+package protocol
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// 本设备发起的确认通知服务
+const (
+	BACnetServiceConfirmedCOVNotification   = 0x01
+	BACnetServiceConfirmedEventNotification = 0x02
+)
+
+// transactionKey 标识一个由本设备发起、等待应答的确认请求
+type transactionKey struct {
+	addr     string
+	invokeID byte
+}
+
+// transaction 一个等待应答的确认请求
+type transaction struct {
+	service  byte
+	started  time.Time
+	response chan *APDU
+}
+
+// transactionManager 管理本设备发起的确认请求，分配InvokeID并把应答交给等待者
+type transactionManager struct {
+	mu           sync.Mutex
+	nextInvokeID byte
+	pending      map[transactionKey]*transaction
+}
+
+// TransactionInfo 描述一个等待应答的确认请求
+type TransactionInfo struct {
+	Peer     string
+	InvokeID byte
+	Service  byte
+	Started  time.Time
+}
+
+// begin 分配一个空闲的InvokeID并登记等待应答
+func (m *transactionManager) begin(addr string, service byte, now time.Time) (byte, chan *APDU, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pending == nil {
+		m.pending = make(map[transactionKey]*transaction)
+	}
+	for i := 0; i < 256; i++ {
+		invokeID := m.nextInvokeID
+		m.nextInvokeID++
+		key := transactionKey{addr: addr, invokeID: invokeID}
+		if _, busy := m.pending[key]; !busy {
+			t := &transaction{service: service, started: now, response: make(chan *APDU, 1)}
+			m.pending[key] = t
+			return invokeID, t.response, nil
+		}
+	}
+	return 0, nil, fmt.Errorf("没有空闲的InvokeID: %s", addr)
+}
+
+// end 结束事务并释放InvokeID
+func (m *transactionManager) end(addr string, invokeID byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.pending, transactionKey{addr: addr, invokeID: invokeID})
+}
+
+// complete 将收到的应答交给对应事务，没有匹配的事务时返回false
+func (m *transactionManager) complete(addr string, apdu *APDU) bool {
+	if apdu.InvokeID == nil {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.pending[transactionKey{addr: addr, invokeID: *apdu.InvokeID}]
+	if !ok {
+		return false
+	}
+	select {
+	case t.response <- apdu:
+	default: // 重复的应答直接丢弃
+	}
+	return true
+}
+
+// list 返回等待应答的事务，按开始时间排序
+func (m *transactionManager) list() []TransactionInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	infos := make([]TransactionInfo, 0, len(m.pending))
+	for key, t := range m.pending {
+		infos = append(infos, TransactionInfo{Peer: key.addr, InvokeID: key.invokeID, Service: t.service, Started: t.started})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if !infos[i].Started.Equal(infos[j].Started) {
+			return infos[i].Started.Before(infos[j].Started)
+		}
+		return infos[i].InvokeID < infos[j].InvokeID
+	})
+	return infos
+}
+
+// Transactions 返回本设备发起、仍在等待应答的确认请求
+func (s *BACnetServer) Transactions() []TransactionInfo {
+	return s.transactions.list()
+}
+
+// isTransactionResponse 判断APDU是否是对确认请求的应答
+func isTransactionResponse(pduType byte) bool {
+	switch pduType {
+	case BACnetAPDUTypeSimpleAck, BACnetAPDUTypeComplexAck, BACnetAPDUTypeError, BACnetAPDUTypeReject, BACnetAPDUTypeAbort:
+		return true
+	}
+	return false
+}
+
+// apduRetries 返回设备的APDU_Timeout和Number_Of_APDU_Retries。在后台发送请求的goroutine中调用，读取时持有设备锁
+func (s *BACnetServer) apduRetries() (time.Duration, int) {
+	s.device.Lock()
+	defer s.device.Unlock()
+	return s.device.APDUTimeout(), s.device.NumberOfAPDURetries()
+}
+
+// sendConfirmedRequest 发送确认请求并等待应答，超时和重试次数在每次调用时读取设备的APDU_Timeout和Number_Of_APDU_Retries
+func (s *BACnetServer) sendConfirmedRequest(addr net.Addr, service byte, payload []byte) (*APDU, error) {
+	return s.sendRoutedRequest(addr, nil, service, payload)
+}
+
+// sendRoutedRequest 经addr发送确认请求，dest不为nil时NPDU带目标网络和MAC，由addr处的路由器转发
+func (s *BACnetServer) sendRoutedRequest(addr net.Addr, dest *model.Address, service byte, payload []byte) (*APDU, error) {
+	if s.transport == nil {
+		return nil, errTransportNotInitialized
+	}
+	invokeID, response, err := s.transactions.begin(addr.String(), service, s.now())
+	if err != nil {
+		return nil, err
+	}
+	defer s.transactions.end(addr.String(), invokeID)
+
+	// 0x05: 不接受分段应答，最大APDU长度1476
+	apdu := append([]byte{BACnetAPDUTypeConfirmedServiceRequest << 4, 0x05, invokeID, service}, payload...)
+	message := encodeBVLC(BVLCOriginalUnicastNPDU, append(destinationNPDU(dest, true).Encode(), apdu...))
+
+	done := s.Done()
+	timeout, retries := s.apduRetries()
+	for attempt := 0; attempt <= retries; attempt++ {
+		if _, err := s.writeTo(message, addr); err != nil {
+			return nil, fmt.Errorf("发送确认请求失败: %v", err)
+		}
+		select {
+		case reply := <-response:
+			return reply, nil
+		case <-done:
+			// 关闭服务端时等待超时，传输已关闭
+			return nil, errServerStopped
+		case <-time.After(timeout):
+			s.Logger().Warn("确认请求超时", "peer", addr.String(), "invoke_id", invokeID, "attempt", attempt+1)
+		}
+	}
+	return nil, fmt.Errorf("确认请求无应答: 目标=%s, 已重试%d次", addr, retries)
+}
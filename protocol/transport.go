@@ -0,0 +1,157 @@
+package protocol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"syscall"
+)
+
+// bvllMaxLength BACnet/IP数据报（BVLL）的最大长度：1476字节APDU加NPDU和BVLC头部
+const bvllMaxLength = 1497
+
+// errTransportNotInitialized 服务端没有可用的传输
+var errTransportNotInitialized = errors.New("传输未初始化")
+
+// Transport 服务端收发BVLL数据报的传输层。BACnet/IP使用UDPTransport，
+// 其他传输（如测试用的内存传输）只要实现该接口即可接入，APDU层不受影响
+type Transport interface {
+	// ReadFrom 读取一个数据报，返回其源地址
+	ReadFrom(p []byte) (n int, addr net.Addr, err error)
+	// WriteTo 向addr发送一个数据报
+	WriteTo(p []byte, addr net.Addr) (n int, err error)
+	// LocalAddr 返回本地地址
+	LocalAddr() net.Addr
+	// BroadcastAddr 返回本地广播地址，不支持广播时返回nil
+	BroadcastAddr() net.Addr
+	// ResolveAddr 将地址字符串（如COV订阅中保存的客户端地址）解析为该传输的地址
+	ResolveAddr(address string) (net.Addr, error)
+	// MTU 返回数据报的最大长度
+	MTU() int
+	// Close 关闭传输，阻塞中的ReadFrom返回错误
+	Close() error
+}
+
+// UDPTransport 基于UDP套接字的BACnet/IP传输
+type UDPTransport struct {
+	conn      *net.UDPConn
+	subnet    *net.IPNet    // 绑定的接口子网，监听所有接口时为nil
+	bcastConn *net.UDPConn  // 监听子网定向广播的套接字，可为nil
+	datagrams chan datagram // bcastConn不为nil时两个套接字收到的数据报
+	closed    chan struct{} // bcastConn不为nil时在Close时关闭
+	close     sync.Once
+}
+
+// NewUDPTransport 在host（如":47808"）上监听UDP
+func NewUDPTransport(host string) (*UDPTransport, error) {
+	addr, err := net.ResolveUDPAddr("udp", host)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &UDPTransport{conn: conn}, nil
+}
+
+// NewSharedUDPTransport 以SO_REUSEADDR和SO_REUSEPORT在host上监听UDP，
+// 使服务端可以与本机上已在同一端口运行的其他BACnet协议栈共存。
+// 共享端口时广播送达每个套接字，单播只送达其中一个，需要可靠单播时改用临时端口并注册为外部设备
+func NewSharedUDPTransport(host string) (*UDPTransport, error) {
+	config := net.ListenConfig{Control: func(network, address string, c syscall.RawConn) error {
+		return setReusePort(c)
+	}}
+	conn, err := config.ListenPacket(context.Background(), "udp", host)
+	if err != nil {
+		return nil, err
+	}
+	return &UDPTransport{conn: conn.(*net.UDPConn)}, nil
+}
+
+// ReadFrom 读取一个UDP数据报
+func (t *UDPTransport) ReadFrom(p []byte) (int, net.Addr, error) {
+	if t.bcastConn == nil {
+		return t.conn.ReadFrom(p)
+	}
+	select {
+	case d := <-t.datagrams:
+		return copy(p, d.data), d.from, nil
+	case <-t.closed:
+		return 0, nil, net.ErrClosed
+	}
+}
+
+// WriteTo 发送一个UDP数据报
+func (t *UDPTransport) WriteTo(p []byte, addr net.Addr) (int, error) {
+	return t.conn.WriteTo(p, addr)
+}
+
+// LocalAddr 返回监听的UDP地址
+func (t *UDPTransport) LocalAddr() net.Addr {
+	return t.conn.LocalAddr()
+}
+
+// BroadcastAddr 返回本地端口上的广播地址：绑定到接口时为子网的定向广播地址，否则为IPv4受限广播地址
+func (t *UDPTransport) BroadcastAddr() net.Addr {
+	port := t.conn.LocalAddr().(*net.UDPAddr).Port
+	if t.subnet != nil {
+		return &net.UDPAddr{IP: directedBroadcast(t.subnet), Port: port}
+	}
+	return &net.UDPAddr{IP: net.IPv4bcast, Port: port}
+}
+
+// ResolveAddr 解析"IP:端口"形式的地址
+func (t *UDPTransport) ResolveAddr(address string) (net.Addr, error) {
+	return net.ResolveUDPAddr("udp", address)
+}
+
+// MTU 返回BVLL的最大长度
+func (t *UDPTransport) MTU() int {
+	return bvllMaxLength
+}
+
+// SetDSCP 设置发出数据报的DSCP（IP头部ToS字段的高6位），用于楼宇网络的QoS
+func (t *UDPTransport) SetDSCP(dscp byte) error {
+	if dscp > 63 {
+		return fmt.Errorf("无效的DSCP: %d", dscp)
+	}
+	raw, err := t.conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	return setTOS(raw, int(dscp)<<2)
+}
+
+// Close 关闭UDP套接字
+func (t *UDPTransport) Close() error {
+	if t.bcastConn != nil {
+		t.close.Do(func() { close(t.closed) })
+		t.bcastConn.Close()
+	}
+	return t.conn.Close()
+}
+
+// dscpTransport 支持设置DSCP的传输
+type dscpTransport interface {
+	SetDSCP(dscp byte) error
+}
+
+// SetDSCP 为服务端发出的数据报设置DSCP，传输不支持时返回错误
+func (s *BACnetServer) SetDSCP(dscp byte) error {
+	t, ok := s.transport.(dscpTransport)
+	if !ok {
+		return errors.New("传输不支持DSCP")
+	}
+	return t.SetDSCP(dscp)
+}
+
+// udpAddr 返回B/IP地址，addr不是UDP地址时返回nil
+func udpAddr(addr net.Addr) *net.UDPAddr {
+	if a, ok := addr.(*net.UDPAddr); ok {
+		return a
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package protocol
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/iotzf/bacnet-server/encoding"
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// VirtualNetwork 进程内的虚拟BACnet网络，连接在路由器的一个端口上。
+// 网络上的每个设备由独立的服务端处理，MAC地址为3字节的设备实例号，
+// 其他网络上的客户端以DNET/DADR访问，全局广播的Who-Is由每个设备分别应答
+type VirtualNetwork struct {
+	port    *RouterPort
+	mu      sync.Mutex
+	devices map[string]*BACnetServer // 键为MAC
+}
+
+// AddVirtualNetwork 在路由器上添加网络号为network的虚拟网络
+func (s *BACnetServer) AddVirtualNetwork(network uint16) (*VirtualNetwork, error) {
+	v := &VirtualNetwork{devices: make(map[string]*BACnetServer)}
+	port, err := s.AddRouterPort(network, v)
+	if err != nil {
+		return nil, err
+	}
+	v.port = port
+	s.virtualNetworks = append(s.virtualNetworks, v)
+	return v, nil
+}
+
+// virtualMAC 返回设备在虚拟网络上的MAC地址
+func virtualMAC(device *model.Device) []byte {
+	instance := device.GetObjectIdentifier().Instance
+	return []byte{byte(instance >> 16), byte(instance >> 8), byte(instance)}
+}
+
+// AddDevice 将设备加入虚拟网络，返回处理该设备请求的服务端
+func (v *VirtualNetwork) AddDevice(device *model.Device) (*BACnetServer, error) {
+	mac := string(virtualMAC(device))
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if _, ok := v.devices[mac]; ok {
+		return nil, fmt.Errorf("虚拟网络%d上已有设备%d", v.port.Network, device.GetObjectIdentifier().Instance)
+	}
+	server, err := newBACnetServer(device, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	server.inherit(v.port.server)
+	server.Start(context.Background())
+	v.devices[mac] = server
+	return server, nil
+}
+
+// inherit 沿用路由器所在服务端加入设备时的日志、时钟、访问控制、工程师来源和限速配置
+func (s *BACnetServer) inherit(parent *BACnetServer) {
+	s.log = parent.log
+	if parent.clock != nil {
+		s.SetClock(parent.clock)
+	}
+	s.acl = parent.acl
+	s.readOnly = parent.readOnly
+
+	parent.protections.mu.RLock()
+	s.protections.engineers = parent.protections.engineers
+	parent.protections.mu.RUnlock()
+
+	parent.limiter.mu.Lock()
+	rate, burst, discoveryRate := parent.limiter.rate, parent.limiter.burst, parent.limiter.discoveryRate
+	parent.limiter.mu.Unlock()
+	s.SetRateLimit(rate, int(burst))
+	s.SetDiscoveryRateLimit(discoveryRate)
+}
+
+// Devices 返回虚拟网络上的设备，按实例号排序
+func (v *VirtualNetwork) Devices() []*model.Device {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	devices := make([]*model.Device, 0, len(v.devices))
+	for _, server := range v.devices {
+		devices = append(devices, server.device)
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].GetObjectIdentifier().Instance < devices[j].GetObjectIdentifier().Instance
+	})
+	return devices
+}
+
+// SendNPDU 将路由器转发的NPDU交给mac对应的设备，mac为空时交给所有设备；
+// 设备的应答再经路由器端口送回请求所在的网络
+func (v *VirtualNetwork) SendNPDU(npdu []byte, mac []byte) error {
+	v.mu.Lock()
+	var targets []*BACnetServer
+	if len(mac) == 0 {
+		for _, server := range v.devices {
+			targets = append(targets, server)
+		}
+	} else if server, ok := v.devices[string(mac)]; ok {
+		targets = append(targets, server)
+	}
+	v.mu.Unlock()
+
+	requester := v.requester(npdu)
+	for _, server := range targets {
+		source := virtualMAC(server.device)
+		from := requester
+		if from == "" {
+			from = fmt.Sprintf("%d:%x", v.port.Network, source)
+		}
+		response, err := server.HandleNPDU(npdu, from)
+		if err != nil {
+			v.port.server.Logger().Warn("虚拟设备处理NPDU失败", "device", server.device.GetObjectIdentifier().Instance, "error", err)
+			continue
+		}
+		if response != nil {
+			v.port.HandleNPDU(response, source)
+		}
+	}
+	return nil
+}
+
+// requester 返回NPDU中源地址的字符串形式，来自B/IP网络时为ip:port，使访问控制
+// 按请求者的IP匹配；没有源地址时返回空串
+func (v *VirtualNetwork) requester(data []byte) string {
+	npdu, _, err := ParseNPDU(data)
+	if err != nil || npdu.SourceNetwork == nil {
+		return ""
+	}
+	if ipPort := v.port.server.ipPort; ipPort != nil && *npdu.SourceNetwork == ipPort.Network && len(npdu.SourceMAC) == bipAddressLength {
+		if addr, err := readBIPAddress(encoding.NewReader(npdu.SourceMAC)); err == nil {
+			return addr.String()
+		}
+	}
+	return fmt.Sprintf("%d:%x", *npdu.SourceNetwork, npdu.SourceMAC)
+}
+
+// LocalMAC 路由器在虚拟网络上没有地址
+func (v *VirtualNetwork) LocalMAC() []byte {
+	return nil
+}
+
+// stop 停止虚拟设备
+func (v *VirtualNetwork) stop() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, server := range v.devices {
+		server.Stop()
+	}
+}
@@ -0,0 +1,102 @@
+package protocol
+
+import (
+	"fmt"
+
+	"github.com/iotzf/bacnet-server/encoding"
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// WhoHasRequest Who-Has请求结构
+type WhoHasRequest struct {
+	LowLimit   *uint32 // 设备实例范围下限，为空时不限制
+	HighLimit  *uint32 // 设备实例范围上限
+	ObjectID   *model.ObjectIdentifier
+	ObjectName string
+}
+
+// parseWhoHasRequest 解析Who-Has请求
+//
+//	Who-Has-Request ::= SEQUENCE {
+//	  limits SEQUENCE {
+//	    deviceInstanceRangeLowLimit  [0] Unsigned (0..4194303),
+//	    deviceInstanceRangeHighLimit [1] Unsigned (0..4194303) } OPTIONAL,
+//	  object CHOICE {
+//	    objectIdentifier [2] BACnetObjectIdentifier,
+//	    objectName       [3] CharacterString } }
+func parseWhoHasRequest(data []byte) (WhoHasRequest, error) {
+	var request WhoHasRequest
+	d := encoding.NewDecoder(data)
+
+	if d.IsContext(0) {
+		low, err := d.ContextUnsigned(0)
+		if err != nil {
+			return request, err
+		}
+		high, err := d.ContextUnsigned(1)
+		if err != nil {
+			return request, err
+		}
+		request.LowLimit, request.HighLimit = &low, &high
+	}
+
+	switch {
+	case d.IsContext(2):
+		oid, err := d.ContextObjectIdentifier(2)
+		if err != nil {
+			return request, err
+		}
+		request.ObjectID = &oid
+	case d.IsContext(3):
+		name, err := d.ContextCharacterString(3)
+		if err != nil {
+			return request, err
+		}
+		request.ObjectName = name
+	default:
+		return request, fmt.Errorf("Who-Has请求缺少对象标识符或对象名")
+	}
+	return request, nil
+}
+
+// handleWhoHas 处理Who-Has请求，本设备包含所查找的对象时以I-Have应答
+func (s *BACnetServer) handleWhoHas(data []byte) ([]byte, error) {
+	request, err := parseWhoHasRequest(data)
+	if err != nil {
+		return nil, err
+	}
+
+	deviceID := s.device.GetObjectIdentifier()
+	if request.LowLimit != nil && (deviceID.Instance < *request.LowLimit || deviceID.Instance > *request.HighLimit) {
+		return nil, nil
+	}
+
+	var obj model.Object
+	if request.ObjectID != nil {
+		if *request.ObjectID == deviceID {
+			obj = s.device
+		} else {
+			obj = s.device.FindObject(*request.ObjectID)
+		}
+	} else {
+		obj = s.device.FindObjectByName(request.ObjectName)
+	}
+	if obj == nil {
+		return nil, nil
+	}
+	return s.createIHaveResponse(obj), nil
+}
+
+// createIHaveResponse 创建I-Have应答
+//
+//	I-Have-Request ::= SEQUENCE {
+//	  deviceIdentifier BACnetObjectIdentifier,
+//	  objectIdentifier BACnetObjectIdentifier,
+//	  objectName       CharacterString }
+func (s *BACnetServer) createIHaveResponse(obj model.Object) []byte {
+	apdu := []byte{BACnetAPDUTypeUnconfirmedServiceRequest << 4, BACnetServiceUnconfirmedIHave}
+	apdu = append(apdu, encoding.EncodeObjectIdentifier(s.device.GetObjectIdentifier())...)
+	apdu = append(apdu, encoding.EncodeObjectIdentifier(obj.GetObjectIdentifier())...)
+	apdu = append(apdu, encoding.EncodeCharacterString(obj.GetObjectName())...)
+	return encodeBVLC(BVLCOriginalUnicastNPDU, append([]byte{0x01, 0x00}, apdu...))
+}
@@ -0,0 +1,471 @@
+// Package proxy 把下游BACnet设备的对象镜像为本地代理对象：用client包发现设备、按周期读取
+// Present_Value和Status_Flags，写入代理对象时写穿到下游设备。服务端因此可以作为"虚拟BACnet集中器"，
+// 代表NAT后面或其他网段上的一个站点
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/iotzf/bacnet-server/client"
+	"github.com/iotzf/bacnet-server/encoding"
+	"github.com/iotzf/bacnet-server/model"
+	"github.com/iotzf/bacnet-server/protocol"
+)
+
+// maxInstance 对象实例号的最大值，4194303保留为未初始化
+const maxInstance = 0x3FFFFE
+
+// ErrOffline 下游设备尚未发现或通信中断，写穿被拒绝
+var ErrOffline = errors.New("下游设备离线")
+
+// Objects 代理对象加入的对象集合，*model.Device和*protocol.BACnetServer都实现该接口。
+// 服务端已启动时应传入*protocol.BACnetServer，新对象的值变化才会通知COV订阅者。
+// 代理在加入和更新代理对象时持有其锁，访问下游设备时不持有
+type Objects interface {
+	sync.Locker
+	AddObject(obj model.Object) error
+}
+
+// Device 一个被镜像的下游设备
+type Device struct {
+	Instance       uint32                   // 下游设备的实例号
+	Address        net.Addr                 // 设备地址，为nil时用Who-Is发现，通信中断后重新发现
+	Objects        []model.ObjectIdentifier // 镜像的对象，为空时镜像Object_List中全部模拟量和二进制对象
+	InstanceOffset uint32                   // 代理对象的实例号 = 下游对象的实例号 + InstanceOffset
+	NamePrefix     string                   // 代理对象名称的前缀，为空时为"<设备实例号>."
+}
+
+// pointObject 代理的模拟量或二进制对象
+type pointObject interface {
+	model.CommandableObject
+	SetPropertyProvider(prop model.PropertyIdentifier, provider model.PropertyProvider)
+	SetStatusFlags(flags uint8)
+	SetReliability(reliability model.Reliability)
+}
+
+// point 一个下游对象及其代理对象
+type point struct {
+	remote model.ObjectIdentifier
+	object pointObject
+}
+
+// device 镜像中的下游设备
+type device struct {
+	Device
+	addr     net.Addr // 当前地址，由Proxy.mu保护
+	points   []*point
+	skipped  map[model.ObjectIdentifier]bool // 无法镜像的对象，不再重试
+	mirrored bool                            // 已创建全部代理对象
+}
+
+// Proxy 按周期发现下游设备、创建代理对象并刷新其值。下游对象的Status_Flags原样镜像，
+// 通信中断时代理对象的Reliability为communication-failure，Status_Flags置FAULT
+type Proxy struct {
+	Logger *slog.Logger // 为nil时使用slog.Default()
+	Clock  model.Clock  // 刷新周期使用的时钟，为nil时使用model.SystemClock
+
+	client   *client.Client
+	objects  Objects
+	interval time.Duration
+	mu       sync.Mutex // 保护devices和各设备的addr
+	devices  []*device
+}
+
+// New 创建代理，代理对象加入objects，每interval刷新一次
+func New(c *client.Client, objects Objects, interval time.Duration) *Proxy {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	return &Proxy{client: c, objects: objects, interval: interval}
+}
+
+// logger 返回代理使用的日志
+func (p *Proxy) logger() *slog.Logger {
+	if p.Logger != nil {
+		return p.Logger
+	}
+	return slog.Default()
+}
+
+// clock 返回代理使用的时钟
+func (p *Proxy) clock() model.Clock {
+	if p.Clock != nil {
+		return p.Clock
+	}
+	return model.SystemClock
+}
+
+// AddDevice 加入要镜像的下游设备，代理对象在第一次联系到设备时创建
+func (p *Proxy) AddDevice(d Device) error {
+	for _, oid := range d.Objects {
+		if !supported(oid.Type) {
+			return fmt.Errorf("代理不支持对象类型%s", oid.Type)
+		}
+	}
+	if d.NamePrefix == "" {
+		d.NamePrefix = fmt.Sprintf("%d.", d.Instance)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, other := range p.devices {
+		if other.Instance == d.Instance {
+			return fmt.Errorf("设备%d已在代理中", d.Instance)
+		}
+	}
+	p.devices = append(p.devices, &device{Device: d, addr: d.Address, skipped: make(map[model.ObjectIdentifier]bool)})
+	return nil
+}
+
+// Close 关闭客户端
+func (p *Proxy) Close() error {
+	return p.client.Close()
+}
+
+// Run 立即刷新一次，之后每个周期刷新，直到ctx取消
+func (p *Proxy) Run(ctx context.Context) {
+	ticker := p.clock().NewTicker(p.interval)
+	defer ticker.Stop()
+	p.Poll(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			p.Poll(ctx)
+		}
+	}
+}
+
+// Poll 依次处理每个下游设备：没有地址时发现设备，尚未镜像时创建代理对象，然后读取全部代理对象的值
+func (p *Proxy) Poll(ctx context.Context) {
+	p.mu.Lock()
+	devices := append([]*device(nil), p.devices...)
+	p.mu.Unlock()
+	for _, d := range devices {
+		if ctx.Err() != nil {
+			return
+		}
+		err := p.poll(ctx, d)
+		if err == nil {
+			continue
+		}
+		p.logger().Warn("代理下游设备失败", "device", d.Instance, "error", err)
+		p.objects.Lock()
+		for _, pt := range d.points {
+			setFault(pt.object, true)
+		}
+		p.objects.Unlock()
+		if d.Address == nil {
+			// 设备可能换了地址，下个周期重新发现
+			p.client.Forget(d.Instance)
+			p.setAddress(d, nil)
+		}
+	}
+}
+
+// poll 处理一个下游设备
+func (p *Proxy) poll(ctx context.Context, d *device) error {
+	addr := p.address(d)
+	if addr == nil {
+		found, err := p.client.Device(ctx, d.Instance)
+		if err != nil {
+			return err
+		}
+		addr = found.Address
+		p.setAddress(d, addr)
+		p.logger().Info("发现下游设备", "device", d.Instance, "address", addr)
+	}
+	if !d.mirrored {
+		if err := p.mirror(ctx, d, addr); err != nil {
+			return err
+		}
+	}
+	return p.refresh(ctx, d, addr)
+}
+
+// address 返回设备的当前地址
+func (p *Proxy) address(d *device) net.Addr {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return d.addr
+}
+
+// setAddress 设置设备的当前地址
+func (p *Proxy) setAddress(d *device, addr net.Addr) {
+	p.mu.Lock()
+	d.addr = addr
+	p.mu.Unlock()
+}
+
+// mirror 为尚未镜像的下游对象创建代理对象。不存在的对象或无法加入的代理对象记录日志后跳过，
+// 通信失败时返回错误，下个周期继续
+func (p *Proxy) mirror(ctx context.Context, d *device, addr net.Addr) error {
+	objects := d.Objects
+	if len(objects) == 0 {
+		list, err := p.objectList(ctx, d, addr)
+		if err != nil {
+			return err
+		}
+		for _, oid := range list {
+			if supported(oid.Type) {
+				objects = append(objects, oid)
+			}
+		}
+	}
+	mirrored := make(map[model.ObjectIdentifier]bool, len(d.points))
+	for _, pt := range d.points {
+		mirrored[pt.remote] = true
+	}
+	for _, oid := range objects {
+		if mirrored[oid] || d.skipped[oid] {
+			continue
+		}
+		pt, err := p.newPoint(ctx, d, addr, oid)
+		if errors.Is(err, client.ErrTimeout) || ctx.Err() != nil {
+			return err
+		}
+		if err != nil {
+			p.logger().Warn("无法镜像下游对象", "device", d.Instance, "object", oid, "error", err)
+			d.skipped[oid] = true
+			continue
+		}
+		d.points = append(d.points, pt)
+	}
+	d.mirrored = true
+	p.logger().Info("已镜像下游设备", "device", d.Instance, "objects", len(d.points))
+	return nil
+}
+
+// objectList 读取下游设备的Object_List；整个读取失败时（如应答超过设备的最大APDU）逐个下标读取
+func (p *Proxy) objectList(ctx context.Context, d *device, addr net.Addr) ([]model.ObjectIdentifier, error) {
+	deviceID := model.ObjectIdentifier{Type: model.ObjectTypeDevice, Instance: d.Instance}
+	value, err := p.client.ReadProperty(ctx, addr, deviceID, model.PropertyIdentifierObjectList)
+	if err == nil {
+		var list []model.ObjectIdentifier
+		values, ok := value.([]interface{})
+		if !ok {
+			values = []interface{}{value}
+		}
+		for _, v := range values {
+			if oid, ok := v.(model.ObjectIdentifier); ok {
+				list = append(list, oid)
+			}
+		}
+		return list, nil
+	}
+	if errors.Is(err, client.ErrTimeout) || ctx.Err() != nil {
+		return nil, err
+	}
+	value, err = p.client.ReadPropertyIndex(ctx, addr, deviceID, model.PropertyIdentifierObjectList, 0)
+	if err != nil {
+		return nil, err
+	}
+	count, ok := value.(uint32)
+	if !ok {
+		return nil, fmt.Errorf("Object_List长度类型无效: %T", value)
+	}
+	list := make([]model.ObjectIdentifier, 0, count)
+	for i := uint32(1); i <= count; i++ {
+		value, err := p.client.ReadPropertyIndex(ctx, addr, deviceID, model.PropertyIdentifierObjectList, i)
+		if err != nil {
+			return nil, err
+		}
+		if oid, ok := value.(model.ObjectIdentifier); ok {
+			list = append(list, oid)
+		}
+	}
+	return list, nil
+}
+
+// newPoint 读取下游对象的名称和单位，创建代理对象并加入对象集合
+func (p *Proxy) newPoint(ctx context.Context, d *device, addr net.Addr, oid model.ObjectIdentifier) (*point, error) {
+	name, err := p.client.ReadProperty(ctx, addr, oid, model.PropertyIdentifierObjectName)
+	if err != nil {
+		return nil, err
+	}
+	text, _ := name.(string)
+	text = d.NamePrefix + text
+	instance := oid.Instance + d.InstanceOffset
+	if instance > maxInstance {
+		return nil, fmt.Errorf("代理对象的实例号%d超出范围", instance)
+	}
+	var object pointObject
+	switch oid.Type {
+	case model.ObjectTypeAnalogInput, model.ObjectTypeAnalogOutput, model.ObjectTypeAnalogValue:
+		units := model.UnitsNoUnits
+		if value, err := p.client.ReadProperty(ctx, addr, oid, model.PropertyIdentifierUnits); err == nil {
+			if u, ok := value.(encoding.Enumerated); ok {
+				units = model.EngineeringUnits(u)
+			}
+		}
+		switch oid.Type {
+		case model.ObjectTypeAnalogInput:
+			object = model.NewAnalogInput(instance, text, units)
+		case model.ObjectTypeAnalogOutput:
+			object = model.NewAnalogOutput(instance, text, units)
+		default:
+			object = model.NewAnalogValue(instance, text, units)
+		}
+	case model.ObjectTypeBinaryInput:
+		object = model.NewBinaryInput(instance, text)
+	case model.ObjectTypeBinaryOutput:
+		object = model.NewBinaryOutput(instance, text)
+	default:
+		object = model.NewBinaryValue(instance, text)
+	}
+	pt := &point{remote: oid, object: object}
+	if object.Commandable(model.PropertyIdentifierPresentValue) {
+		object.SetPropertyProvider(model.PropertyIdentifierPresentValue, model.ProviderFuncs{
+			Read: func(obj model.Object, prop model.PropertyIdentifier) (interface{}, error) {
+				return obj.ReadProperty(prop)
+			},
+			Write: func(obj model.Object, prop model.PropertyIdentifier, value interface{}, priority uint8) error {
+				return p.command(d, pt, value, priority)
+			},
+		})
+	}
+	p.objects.Lock()
+	defer p.objects.Unlock()
+	if err := p.objects.AddObject(object); err != nil {
+		return nil, err
+	}
+	return pt, nil
+}
+
+// refresh 用一个ReadPropertyMultiple读取设备全部代理对象的Present_Value和Status_Flags
+func (p *Proxy) refresh(ctx context.Context, d *device, addr net.Addr) error {
+	if len(d.points) == 0 {
+		return nil
+	}
+	specs := make([]protocol.ReadAccessSpecification, len(d.points))
+	for i, pt := range d.points {
+		specs[i] = protocol.ReadAccessSpecification{ObjectID: pt.remote, Properties: []protocol.PropertyReference{
+			{PropertyID: model.PropertyIdentifierPresentValue},
+			{PropertyID: model.PropertyIdentifierStatusFlags},
+		}}
+	}
+	results, err := p.client.ReadPropertyMultiple(ctx, addr, specs)
+	if err != nil {
+		return err
+	}
+	type state struct {
+		value interface{}
+		flags uint8
+		err   error
+	}
+	states := make(map[model.ObjectIdentifier]*state, len(d.points))
+	for _, r := range results {
+		s := states[r.ObjectID]
+		if s == nil {
+			s = &state{}
+			states[r.ObjectID] = s
+		}
+		switch {
+		case r.Err != nil:
+			s.err = r.Err
+		case r.PropertyID == model.PropertyIdentifierPresentValue:
+			s.value = r.Value
+		case r.PropertyID == model.PropertyIdentifierStatusFlags:
+			s.flags = statusFlags(r.Value)
+		}
+	}
+	p.objects.Lock()
+	defer p.objects.Unlock()
+	for _, pt := range d.points {
+		s := states[pt.remote]
+		if s == nil || s.err != nil {
+			setFault(pt.object, true)
+			continue
+		}
+		p.update(pt, s.value)
+		pt.object.SetStatusFlags(s.flags)
+		setFault(pt.object, false)
+	}
+	return nil
+}
+
+// update 把下游的值写入代理对象。可命令对象写入Relinquish_Default，
+// 代理对象本身不保留优先级命令，Present_Value因此跟随下游设备的有效值
+func (p *Proxy) update(pt *point, value interface{}) {
+	if e, ok := value.(encoding.Enumerated); ok {
+		value = uint32(e)
+	}
+	var err error
+	if pt.object.Commandable(model.PropertyIdentifierPresentValue) {
+		err = pt.object.WriteProperty(model.PropertyIdentifierRelinquishDefault, value)
+	} else if updater, ok := pt.object.(model.PresentValueUpdater); ok {
+		err = updater.UpdatePresentValue(value)
+	}
+	if err != nil {
+		p.logger().Warn("更新代理对象失败", "object", pt.object.GetObjectName(), "value", value, "error", err)
+	}
+}
+
+// command 将写入代理对象Present_Value的命令以同一优先级写穿到下游对象，成功后读回下游的有效值。
+// 由属性提供者在写入代理对象时调用，调用方持有对象集合的锁
+func (p *Proxy) command(d *device, pt *point, value interface{}, priority uint8) error {
+	addr := p.address(d)
+	if addr == nil {
+		return ErrOffline
+	}
+	if active, ok := value.(bool); ok {
+		// 二进制对象的Present_Value为BACnetBinaryPV枚举
+		value = encoding.Enumerated(0)
+		if active {
+			value = encoding.Enumerated(1)
+		}
+	}
+	ctx := context.Background()
+	if err := p.client.WriteProperty(ctx, addr, pt.remote, model.PropertyIdentifierPresentValue, value, priority); err != nil {
+		p.logger().Warn("写穿下游对象失败", "device", d.Instance, "object", pt.remote, "value", value, "error", err)
+		return err
+	}
+	effective, err := p.client.ReadProperty(ctx, addr, pt.remote, model.PropertyIdentifierPresentValue)
+	if err != nil {
+		return nil
+	}
+	p.update(pt, effective)
+	return nil
+}
+
+// supported 判断对象类型能否被代理
+func supported(t model.ObjectType) bool {
+	switch t {
+	case model.ObjectTypeAnalogInput, model.ObjectTypeAnalogOutput, model.ObjectTypeAnalogValue,
+		model.ObjectTypeBinaryInput, model.ObjectTypeBinaryOutput, model.ObjectTypeBinaryValue:
+		return true
+	}
+	return false
+}
+
+// statusFlags 将读到的Status_Flags（位串或无符号整数）转换为状态标志位
+func statusFlags(value interface{}) uint8 {
+	switch v := value.(type) {
+	case model.BitString:
+		var flags uint8
+		for i := 0; i < 4; i++ {
+			if v.Bit(i) {
+				flags |= 1 << i
+			}
+		}
+		return flags
+	case uint32:
+		return uint8(v)
+	}
+	return 0
+}
+
+// setFault 下游设备通信中断时将代理对象的Reliability置为communication-failure，Status_Flags的FAULT位随之置位
+func setFault(object pointObject, fault bool) {
+	reliability := model.ReliabilityNoFaultDetected
+	if fault {
+		reliability = model.ReliabilityCommunicationFailure
+	}
+	object.SetReliability(reliability)
+}
@@ -0,0 +1,105 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/iotzf/bacnet-server/client"
+	"github.com/iotzf/bacnet-server/model"
+	"github.com/iotzf/bacnet-server/protocol"
+)
+
+func TestProxy(t *testing.T) {
+	network := protocol.NewLoopbackNetwork()
+	remote := model.NewDevice(2001, "Remote Site", "")
+	temp := model.NewAnalogInput(1, "Zone Temp", model.UnitsDegreesCelsius)
+	temp.UpdatePresentValue(21.5)
+	setpoint := model.NewAnalogValue(2, "Zone Setpoint", model.UnitsDegreesCelsius)
+	setpoint.WriteProperty(model.PropertyIdentifierRelinquishDefault, 22.0)
+	fan := model.NewBinaryOutput(1, "Fan")
+	remote.AddObject(temp)
+	remote.AddObject(setpoint)
+	remote.AddObject(fan)
+	remote.AddObject(model.NewMultiStateValue(1, "Mode", []string{"Off", "Heat", "Cool"}))
+	server, err := protocol.NewServer(remote, protocol.Options{Transport: network.Attach()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Start(context.Background())
+	defer server.Stop()
+
+	c, err := client.New(client.Options{Transport: network.Attach(), Timeout: 100 * time.Millisecond, Retries: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	local := model.NewDevice(1001, "Concentrator", "")
+	proxy := New(c, local, time.Second)
+	if err := proxy.AddDevice(Device{Instance: 2001, InstanceOffset: 1000}); err != nil {
+		t.Fatal(err)
+	}
+	if err := proxy.AddDevice(Device{Instance: 2001}); err == nil {
+		t.Error("duplicate device accepted")
+	}
+	if err := proxy.AddDevice(Device{Instance: 2002, Objects: []model.ObjectIdentifier{{Type: model.ObjectTypeSchedule, Instance: 1}}}); err == nil {
+		t.Error("unsupported object type accepted")
+	}
+
+	// 发现设备并镜像Object_List中的模拟量和二进制对象
+	ctx := context.Background()
+	proxy.Poll(ctx)
+	if objects := local.Objects(); len(objects) != 3 {
+		t.Fatalf("%d proxy objects, want 3", len(objects))
+	}
+	find := func(oid model.ObjectIdentifier) model.Object {
+		t.Helper()
+		obj := local.FindObject(oid)
+		if obj == nil {
+			t.Fatalf("proxy object %v not found", oid)
+		}
+		return obj
+	}
+	localTemp := find(model.ObjectIdentifier{Type: model.ObjectTypeAnalogInput, Instance: 1001}).(*model.Analog)
+	localSetpoint := find(model.ObjectIdentifier{Type: model.ObjectTypeAnalogValue, Instance: 1002}).(*model.Analog)
+	localFan := find(model.ObjectIdentifier{Type: model.ObjectTypeBinaryOutput, Instance: 1001}).(*model.Binary)
+	if localTemp.GetObjectName() != "2001.Zone Temp" || localTemp.Units != model.UnitsDegreesCelsius {
+		t.Errorf("proxy object %q in %v", localTemp.GetObjectName(), localTemp.Units)
+	}
+	if localTemp.Value() != 21.5 || localSetpoint.Value() != 22 || localFan.Active() {
+		t.Errorf("mirrored values: %v, %v, %v", localTemp.Value(), localSetpoint.Value(), localFan.Active())
+	}
+
+	// 下游的变化在下次刷新时镜像，Status_Flags原样镜像
+	temp.UpdatePresentValue(23.0)
+	temp.SetStatusFlags(model.StatusFlagOverridden)
+	proxy.Poll(ctx)
+	if localTemp.Value() != 23 || localTemp.GetStatusFlags() != model.StatusFlagOverridden {
+		t.Errorf("after poll: %v, flags %04b", localTemp.Value(), localTemp.GetStatusFlags())
+	}
+
+	// 写入代理对象时以同一优先级写穿到下游
+	if err := model.WriteWithPriority(localSetpoint, model.PropertyIdentifierPresentValue, float32(24), 8); err != nil {
+		t.Fatalf("write through: %v", err)
+	}
+	if setpoint.Value() != 24 || setpoint.PriorityArray(model.PropertyIdentifierPresentValue)[7] != float32(24) || localSetpoint.Value() != 24 {
+		t.Errorf("after write: remote %v, local %v", setpoint.Value(), localSetpoint.Value())
+	}
+	if err := model.WriteWithPriority(localFan, model.PropertyIdentifierPresentValue, true, 8); err != nil || !fan.Active() || !localFan.Active() {
+		t.Errorf("write binary: %v, remote %v, local %v", err, fan.Active(), localFan.Active())
+	}
+	if err := model.WriteWithPriority(localSetpoint, model.PropertyIdentifierPresentValue, nil, 8); err != nil || setpoint.Value() != 22 {
+		t.Errorf("relinquish: %v, remote %v", err, setpoint.Value())
+	}
+
+	// 通信中断时Reliability为communication-failure并置FAULT，写穿返回错误
+	server.Stop()
+	proxy.Poll(ctx)
+	if localTemp.GetStatusFlags()&model.StatusFlagFault == 0 || localTemp.GetReliability() != model.ReliabilityCommunicationFailure {
+		t.Errorf("flags %04b, reliability %d after the device went offline", localTemp.GetStatusFlags(), localTemp.GetReliability())
+	}
+	if err := model.WriteWithPriority(localSetpoint, model.PropertyIdentifierPresentValue, float32(25), 8); !errors.Is(err, ErrOffline) {
+		t.Errorf("write while offline = %v", err)
+	}
+}
@@ -0,0 +1,611 @@
+package simulation
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// Expr 编译后的规则表达式。表达式的值都是数值，布尔值为1和0，非0为真：
+//
+//   - 对象引用analog-input:1读取Present_Value，analog-input:1.status-flags读取其他属性，
+//     也可以用对象名称"Supply Fan"引用；self为规则目标对象的Present_Value
+//   - 字面量：数字、时长（5m、30s、2h、500ms，以秒计）、true/false、active/inactive
+//   - 运算符：|| && ! == != < <= > >= + - * / %，名称与减号之间需要空格
+//   - 函数：min、max、abs、clamp(x, lo, hi)、if(c, a, b)、random(lo, hi)、hour()，
+//     lag(x, tau)为时间常数tau的一阶惯性，delay(x, d)为延迟d的纯滞后
+//
+// lag和delay在每个调用处保存状态，同一个Expr不能被多个规则共用
+type Expr struct {
+	text string
+	root node
+}
+
+// evalContext 一次求值的环境
+type evalContext struct {
+	now  time.Time
+	rand *rand.Rand
+	self model.Object // 规则的目标对象，可为nil
+}
+
+// node 表达式语法树的节点
+type node interface {
+	eval(ctx *evalContext) (float64, error)
+}
+
+// Compile 编译表达式，对象引用在device中查找
+func Compile(text string, device *model.Device) (*Expr, error) {
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens, device: device}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("表达式%q: 多余的%q", text, p.tokens[p.pos].text)
+	}
+	return &Expr{text: text, root: root}, nil
+}
+
+// String 返回表达式的源文本
+func (e *Expr) String() string {
+	return e.text
+}
+
+// eval 求值
+func (e *Expr) eval(ctx *evalContext) (float64, error) {
+	return e.root.eval(ctx)
+}
+
+// tokenKind 词法单元的种类
+type tokenKind uint8
+
+const (
+	tokenNumber tokenKind = iota
+	tokenName             // 函数或常量名
+	tokenObject           // 对象引用
+	tokenOperator
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	number float64
+}
+
+// durationUnits 时长字面量的单位，按秒计
+var durationUnits = []struct {
+	suffix  string
+	seconds float64
+}{{"ms", 0.001}, {"s", 1}, {"m", 60}, {"h", 3600}}
+
+// tokenize 切分词法单元
+func tokenize(text string) ([]token, error) {
+	var tokens []token
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || r == '.':
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			number, err := strconv.ParseFloat(string(runes[start:i]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("无效的数字: %s", string(runes[start:i]))
+			}
+			for _, unit := range durationUnits {
+				end := i + len(unit.suffix)
+				if end <= len(runes) && string(runes[i:end]) == unit.suffix && (end == len(runes) || !isNameRune(runes[end])) {
+					number *= unit.seconds
+					i = end
+					break
+				}
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[start:i]), number: number})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && isNameRune(runes[i]) {
+				i++
+			}
+			// 类型:实例号，可以跟.属性名
+			if i+1 < len(runes) && runes[i] == ':' && unicode.IsDigit(runes[i+1]) {
+				i++
+				for i < len(runes) && unicode.IsDigit(runes[i]) {
+					i++
+				}
+				if i+1 < len(runes) && runes[i] == '.' && unicode.IsLetter(runes[i+1]) {
+					i++
+					for i < len(runes) && isNameRune(runes[i]) {
+						i++
+					}
+				}
+				tokens = append(tokens, token{kind: tokenObject, text: string(runes[start:i])})
+				continue
+			}
+			tokens = append(tokens, token{kind: tokenName, text: string(runes[start:i])})
+		case r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("引号不匹配")
+			}
+			tokens = append(tokens, token{kind: tokenObject, text: string(runes[i : end+1])})
+			i = end + 1
+		default:
+			op := string(r)
+			if i+1 < len(runes) {
+				switch two := string(runes[i : i+2]); two {
+				case "&&", "||", "==", "!=", "<=", ">=":
+					op = two
+				}
+			}
+			if !strings.Contains("+-*/%()!<>,", op) && len(op) == 1 {
+				return nil, fmt.Errorf("无效的字符: %q", r)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: op})
+			i += len(op)
+		}
+	}
+	return tokens, nil
+}
+
+// isNameRune 判断是否是名称中的字符，名称可以包含连字符（如analog-input）
+func isNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-'
+}
+
+// exprParser 按优先级递归下降解析
+type exprParser struct {
+	tokens []token
+	pos    int
+	device *model.Device
+}
+
+// peek 返回当前的运算符，不是运算符时返回空
+func (p *exprParser) peek() string {
+	if p.pos < len(p.tokens) && p.tokens[p.pos].kind == tokenOperator {
+		return p.tokens[p.pos].text
+	}
+	return ""
+}
+
+// expect 跳过指定的运算符
+func (p *exprParser) expect(op string) error {
+	if p.peek() != op {
+		if p.pos < len(p.tokens) {
+			return fmt.Errorf("应为%q，实际为%q", op, p.tokens[p.pos].text)
+		}
+		return fmt.Errorf("应为%q，表达式已结束", op)
+	}
+	p.pos++
+	return nil
+}
+
+// binary 解析左结合的二元运算，operand解析更高优先级的部分
+func (p *exprParser) binary(operand func() (node, error), ops ...string) (node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek()
+		matched := false
+		for _, candidate := range ops {
+			matched = matched || op == candidate
+		}
+		if !matched {
+			return left, nil
+		}
+		p.pos++
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseOr() (node, error) {
+	return p.binary(p.parseAnd, "||")
+}
+
+func (p *exprParser) parseAnd() (node, error) {
+	return p.binary(p.parseComparison, "&&")
+}
+
+func (p *exprParser) parseComparison() (node, error) {
+	return p.binary(p.parseSum, "==", "!=", "<", "<=", ">", ">=")
+}
+
+func (p *exprParser) parseSum() (node, error) {
+	return p.binary(p.parseProduct, "+", "-")
+}
+
+func (p *exprParser) parseProduct() (node, error) {
+	return p.binary(p.parseUnary, "*", "/", "%")
+}
+
+func (p *exprParser) parseUnary() (node, error) {
+	if op := p.peek(); op == "-" || op == "!" {
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: op, operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (node, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("表达式不完整")
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	switch t.kind {
+	case tokenNumber:
+		return constNode(t.number), nil
+	case tokenObject:
+		return p.reference(t.text)
+	case tokenName:
+		if p.peek() == "(" {
+			return p.call(t.text)
+		}
+		switch strings.ToLower(t.text) {
+		case "true", "active":
+			return constNode(1), nil
+		case "false", "inactive":
+			return constNode(0), nil
+		case "self":
+			return selfNode{}, nil
+		}
+		return nil, fmt.Errorf("未知的名称: %s", t.text)
+	}
+	if t.text == "(" {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expect(")")
+	}
+	return nil, fmt.Errorf("意外的%q", t.text)
+}
+
+// reference 解析对象引用
+func (p *exprParser) reference(text string) (node, error) {
+	if name, ok := strings.CutPrefix(text, "\""); ok {
+		name = strings.TrimSuffix(name, "\"")
+		for _, obj := range p.device.Objects() {
+			if obj.GetObjectName() == name {
+				return &referenceNode{object: obj, property: model.PropertyIdentifierPresentValue}, nil
+			}
+		}
+		return nil, fmt.Errorf("对象不存在: %s", name)
+	}
+	oidText, propText, hasProperty := strings.Cut(text, ".")
+	oid, err := model.ParseObjectIdentifier(oidText)
+	if err != nil {
+		return nil, err
+	}
+	var obj model.Object
+	if oid == p.device.GetObjectIdentifier() {
+		obj = p.device
+	} else if obj = p.device.FindObject(oid); obj == nil {
+		return nil, fmt.Errorf("对象不存在: %s", oidText)
+	}
+	prop := model.PropertyIdentifierPresentValue
+	if hasProperty {
+		if prop, err = model.ParsePropertyIdentifier(propText); err != nil {
+			return nil, err
+		}
+	}
+	return &referenceNode{object: obj, property: prop}, nil
+}
+
+// functionArity 函数的参数个数，-1为至少一个
+var functionArity = map[string]int{
+	"min": -1, "max": -1, "abs": 1, "clamp": 3, "if": 3, "random": 2, "hour": 0, "lag": 2, "delay": 2,
+}
+
+// call 解析函数调用
+func (p *exprParser) call(name string) (node, error) {
+	name = strings.ToLower(name)
+	arity, ok := functionArity[name]
+	if !ok {
+		return nil, fmt.Errorf("未知的函数: %s", name)
+	}
+	p.pos++ // (
+	var args []node
+	for p.peek() != ")" {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.pos++ // )
+	if (arity >= 0 && len(args) != arity) || (arity < 0 && len(args) == 0) {
+		return nil, fmt.Errorf("函数%s的参数个数错误: %d", name, len(args))
+	}
+	switch name {
+	case "lag":
+		return &lagNode{input: args[0], tau: args[1]}, nil
+	case "delay":
+		return &delayNode{input: args[0], delay: args[1]}, nil
+	}
+	return &callNode{name: name, args: args}, nil
+}
+
+// constNode 常量
+type constNode float64
+
+func (n constNode) eval(*evalContext) (float64, error) { return float64(n), nil }
+
+// selfNode 规则目标对象的Present_Value
+type selfNode struct{}
+
+func (selfNode) eval(ctx *evalContext) (float64, error) {
+	if ctx.self == nil {
+		return 0, fmt.Errorf("没有目标对象")
+	}
+	return readNumber(ctx.self, model.PropertyIdentifierPresentValue)
+}
+
+// referenceNode 对象属性
+type referenceNode struct {
+	object   model.Object
+	property model.PropertyIdentifier
+}
+
+func (n *referenceNode) eval(*evalContext) (float64, error) {
+	return readNumber(n.object, n.property)
+}
+
+// readNumber 读取属性并转换为数值：布尔值为1和0，状态标志等位串按无符号整数
+func readNumber(obj model.Object, prop model.PropertyIdentifier) (float64, error) {
+	value, err := model.ReadPropertyValue(obj, prop)
+	if err != nil {
+		return 0, fmt.Errorf("读取%s的%s失败: %v", obj.GetObjectName(), prop, err)
+	}
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case model.BitString:
+		return float64(v.Uint()), nil
+	case nil:
+		return 0, nil
+	}
+	rv := reflect.ValueOf(value)
+	switch {
+	case rv.CanFloat():
+		return rv.Float(), nil
+	case rv.CanInt():
+		return float64(rv.Int()), nil
+	case rv.CanUint():
+		return float64(rv.Uint()), nil
+	}
+	return 0, fmt.Errorf("%s的%s不是数值: %v", obj.GetObjectName(), prop, value)
+}
+
+// unaryNode 取负和逻辑非
+type unaryNode struct {
+	op      string
+	operand node
+}
+
+func (n *unaryNode) eval(ctx *evalContext) (float64, error) {
+	v, err := n.operand.eval(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if n.op == "-" {
+		return -v, nil
+	}
+	return truth(v == 0), nil
+}
+
+// binaryNode 二元运算，&&和||短路求值
+type binaryNode struct {
+	op          string
+	left, right node
+}
+
+func (n *binaryNode) eval(ctx *evalContext) (float64, error) {
+	a, err := n.left.eval(ctx)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case n.op == "&&" && a == 0:
+		return 0, nil
+	case n.op == "||" && a != 0:
+		return 1, nil
+	}
+	b, err := n.right.eval(ctx)
+	if err != nil {
+		return 0, err
+	}
+	switch n.op {
+	case "&&", "||":
+		return truth(b != 0), nil
+	case "==":
+		return truth(a == b), nil
+	case "!=":
+		return truth(a != b), nil
+	case "<":
+		return truth(a < b), nil
+	case "<=":
+		return truth(a <= b), nil
+	case ">":
+		return truth(a > b), nil
+	case ">=":
+		return truth(a >= b), nil
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	case "/":
+		if b == 0 {
+			return 0, fmt.Errorf("除数为0")
+		}
+		return a / b, nil
+	}
+	if b == 0 {
+		return 0, fmt.Errorf("除数为0")
+	}
+	return math.Mod(a, b), nil
+}
+
+// truth 将布尔值转换为1和0
+func truth(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// callNode 无状态的函数调用
+type callNode struct {
+	name string
+	args []node
+}
+
+func (n *callNode) eval(ctx *evalContext) (float64, error) {
+	if n.name == "if" {
+		c, err := n.args[0].eval(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if c != 0 {
+			return n.args[1].eval(ctx)
+		}
+		return n.args[2].eval(ctx)
+	}
+	if n.name == "hour" {
+		t := ctx.now
+		return float64(t.Hour()) + float64(t.Minute())/60 + float64(t.Second())/3600, nil
+	}
+	args := make([]float64, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(ctx)
+		if err != nil {
+			return 0, err
+		}
+		args[i] = v
+	}
+	switch n.name {
+	case "min":
+		v := args[0]
+		for _, a := range args[1:] {
+			v = math.Min(v, a)
+		}
+		return v, nil
+	case "max":
+		v := args[0]
+		for _, a := range args[1:] {
+			v = math.Max(v, a)
+		}
+		return v, nil
+	case "abs":
+		return math.Abs(args[0]), nil
+	case "clamp":
+		return math.Max(args[1], math.Min(args[2], args[0])), nil
+	}
+	// random
+	return args[0] + (args[1]-args[0])*ctx.rand.Float64(), nil
+}
+
+// lagNode 一阶惯性：输出以时间常数tau（秒）趋近输入，首次求值时从目标对象的当前值开始
+type lagNode struct {
+	input, tau node
+	started    bool
+	last       time.Time
+	output     float64
+}
+
+func (n *lagNode) eval(ctx *evalContext) (float64, error) {
+	x, err := n.input.eval(ctx)
+	if err != nil {
+		return 0, err
+	}
+	tau, err := n.tau.eval(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if !n.started {
+		n.started, n.last, n.output = true, ctx.now, x
+		if ctx.self != nil {
+			if current, err := readNumber(ctx.self, model.PropertyIdentifierPresentValue); err == nil {
+				n.output = current
+			}
+		}
+		return n.output, nil
+	}
+	dt := ctx.now.Sub(n.last).Seconds()
+	n.last = ctx.now
+	if tau <= 0 {
+		n.output = x
+	} else if dt > 0 {
+		n.output += (x - n.output) * (1 - math.Exp(-dt/tau))
+	}
+	return n.output, nil
+}
+
+// delayNode 纯滞后：返回d秒之前的输入，历史不足时返回最早的输入
+type delayNode struct {
+	input, delay node
+	history      []delayed
+}
+
+// delayed 带时间的输入值
+type delayed struct {
+	at    time.Time
+	value float64
+}
+
+func (n *delayNode) eval(ctx *evalContext) (float64, error) {
+	x, err := n.input.eval(ctx)
+	if err != nil {
+		return 0, err
+	}
+	d, err := n.delay.eval(ctx)
+	if err != nil {
+		return 0, err
+	}
+	n.history = append(n.history, delayed{ctx.now, x})
+	cutoff := ctx.now.Add(-time.Duration(d * float64(time.Second)))
+	// 保留cutoff之前的最后一个样本
+	i := 0
+	for i+1 < len(n.history) && !n.history[i+1].at.After(cutoff) {
+		i++
+	}
+	n.history = n.history[i:]
+	return n.history[0].value, nil
+}
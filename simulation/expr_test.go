@@ -0,0 +1,165 @@
+package simulation
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// testDevice 返回表达式测试使用的设备：温度21.5，设定值24，风机运行
+func testDevice() (*model.Device, *model.Analog, *model.Analog, *model.Binary) {
+	device := model.NewDevice(1, "Test Device", "")
+	temp := model.NewAnalogInput(1, "Supply Temp", model.UnitsDegreesCelsius)
+	temp.UpdatePresentValue(21.5)
+	setpoint := model.NewAnalogValue(1, "Setpoint", model.UnitsDegreesCelsius)
+	setpoint.WriteProperty(model.PropertyIdentifierPresentValue, float32(24))
+	fan := model.NewBinaryOutput(1, "Supply Fan")
+	fan.WriteProperty(model.PropertyIdentifierPresentValue, true)
+	device.AddObject(temp)
+	device.AddObject(setpoint)
+	device.AddObject(fan)
+	return device, temp, setpoint, fan
+}
+
+func TestExpr(t *testing.T) {
+	device, temp, _, _ := testDevice()
+	tests := []struct {
+		text string
+		want float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"-2 - -3", 1},
+		{"7 % 4", 3},
+		{"5m + 30s", 330},
+		{"500ms", 0.5},
+		{"analog-input:1", 21.5},
+		{"analog-value:1 - analog-input:1", 2.5},
+		{"analog-input:1-1", 20.5},
+		{"\"Supply Fan\" == active", 1},
+		{"binary-output:1 && analog-input:1 > 30", 0},
+		{"!binary-output:1 || analog-input:1 <= 21.5", 1},
+		{"analog-input:1.out-of-service", 0},
+		{"analog-input:1.units", float64(model.UnitsDegreesCelsius)},
+		{"min(3, 1, 2) + max(4, 5) + abs(-1)", 7},
+		{"clamp(analog-input:1, 0, 20)", 20},
+		{"if(binary-output:1, 1, 2)", 1},
+		{"self + 1", 22.5},
+		{"hour()", 13.5},
+	}
+	ctx := &evalContext{now: time.Date(2024, 1, 1, 13, 30, 0, 0, time.UTC), rand: rand.New(rand.NewSource(1)), self: temp}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			expr, err := Compile(tt.text, device)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			got, err := expr.eval(ctx)
+			if err != nil {
+				t.Fatalf("eval() error = %v", err)
+			}
+			if math.Abs(got-tt.want) > 1e-6 {
+				t.Errorf("= %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, text := range []string{"", "1 +", "(1", "analog-input:9", "\"Nobody\"", "foo", "foo(1)", "min()", "clamp(1, 2)", "1 = 2", "1 2", "analog-input:1.no-such-property"} {
+		if _, err := Compile(text, device); err == nil {
+			t.Errorf("Compile(%q): want error", text)
+		}
+	}
+	expr, _ := Compile("1 / (analog-input:1 - 21.5)", device)
+	if _, err := expr.eval(ctx); err == nil {
+		t.Error("division by zero: want error")
+	}
+}
+
+func TestLagAndDelay(t *testing.T) {
+	device, temp, setpoint, _ := testDevice()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	lag, _ := Compile("lag(analog-value:1, 5m)", device)
+	delay, _ := Compile("delay(analog-value:1, 1m)", device)
+	ctx := &evalContext{now: start, self: temp}
+
+	// 惯性从目标对象的当前值开始，一个时间常数后走完约63%
+	if got, _ := lag.eval(ctx); got != 21.5 {
+		t.Errorf("lag at start = %v, want 21.5", got)
+	}
+	if got, _ := delay.eval(ctx); got != 24 {
+		t.Errorf("delay at start = %v, want 24", got)
+	}
+	setpoint.WriteProperty(model.PropertyIdentifierPresentValue, float32(30))
+	ctx.now = start.Add(30 * time.Second)
+	if got, _ := delay.eval(ctx); got != 24 {
+		t.Errorf("delay at 30s = %v, want 24", got)
+	}
+	ctx.now = start.Add(5 * time.Minute)
+	want := 21.5 + (30-21.5)*(1-math.Exp(-1))
+	if got, _ := lag.eval(ctx); math.Abs(got-want) > 1e-6 {
+		t.Errorf("lag after one time constant = %v, want %v", got, want)
+	}
+	if got, _ := delay.eval(ctx); got != 30 {
+		t.Errorf("delay at 5m = %v, want 30", got)
+	}
+}
+
+func TestRules(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := model.NewFakeClock(start)
+	device, temp, setpoint, fan := testDevice()
+	device.SetClock(clock)
+	engine := NewEngine()
+	engine.Clock = clock
+	if err := engine.AddRule(device, Rule{Name: "follow", Target: temp, When: "binary-output:1", Value: "analog-value:1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.AddRule(device, Rule{Name: "too warm", Target: temp, When: "analog-input:1 > 25", Event: model.EventStateHighLimit}); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func() interface{} {
+		value, _ := temp.ReadProperty(model.PropertyIdentifierPresentValue)
+		return value
+	}
+	engine.Step(start)
+	if read() != float32(24) || temp.GetEventState() != model.EventStateNormal {
+		t.Errorf("after first step: temp = %v, event state = %v", read(), temp.GetEventState())
+	}
+
+	// 条件成立时产生事件，不成立时恢复正常
+	setpoint.WriteProperty(model.PropertyIdentifierPresentValue, float32(27))
+	engine.Step(start.Add(time.Second))
+	engine.Step(start.Add(2 * time.Second))
+	if read() != float32(27) || temp.GetEventState() != model.EventStateHighLimit {
+		t.Errorf("too warm: temp = %v, event state = %v", read(), temp.GetEventState())
+	}
+	fan.WriteProperty(model.PropertyIdentifierPresentValue, false)
+	setpoint.WriteProperty(model.PropertyIdentifierPresentValue, float32(20))
+	engine.Step(start.Add(3 * time.Second))
+	if read() != float32(27) {
+		t.Errorf("fan off: temp = %v, want unchanged", read())
+	}
+	temp.UpdatePresentValue(22.0)
+	engine.Step(start.Add(4 * time.Second))
+	if temp.GetEventState() != model.EventStateNormal {
+		t.Errorf("recovered: event state = %v", temp.GetEventState())
+	}
+	if rules := engine.Rules(); len(rules) != 2 || rules[0].Active || rules[1].Active {
+		t.Errorf("rules = %+v", rules)
+	}
+
+	for _, r := range []Rule{
+		{Name: "no action", Target: temp, When: "true"},
+		{Name: "no target", Value: "1"},
+		{Name: "bad value", Target: temp, Value: "analog-value:1 *"},
+	} {
+		if err := engine.AddRule(device, r); err == nil || !strings.Contains(err.Error(), r.Name) {
+			t.Errorf("AddRule(%s): err = %v", r.Name, err)
+		}
+	}
+}
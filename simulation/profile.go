@@ -0,0 +1,280 @@
+package simulation
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kind 模拟曲线的形状
+type Kind uint8
+
+const (
+	Random     Kind = iota // 每个周期在范围内取均匀分布的随机值
+	Sine                   // 正弦波
+	Ramp                   // 锯齿波：一个Period内从下限升到上限
+	RandomWalk             // 随机游走：每步变化不超过Step，限制在范围内
+	Square                 // 方波：前半个Period为上限，后半个为下限
+	Playback               // 按时间回放CSV文件中的样本，播完后从头循环
+)
+
+var kindNames = []string{"random", "sine", "ramp", "random-walk", "square", "csv"}
+
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return fmt.Sprintf("kind-%d", k)
+}
+
+// ParseKind 按名称解析曲线形状，名称为random、sine、ramp、random-walk、square或csv
+func ParseKind(name string) (Kind, error) {
+	for i, n := range kindNames {
+		if n == name {
+			return Kind(i), nil
+		}
+	}
+	return 0, fmt.Errorf("不支持的模拟方式: %q", name)
+}
+
+// Sample CSV回放的一个样本
+type Sample struct {
+	At    time.Duration // 相对回放开始的时间
+	Value float64
+}
+
+// Profile 一个点位的模拟曲线。数值范围为Offset±Amplitude，在此基础上叠加标准差为Noise的高斯噪声
+type Profile struct {
+	Kind      Kind
+	Offset    float64       // 中心值
+	Amplitude float64       // 振幅，范围的一半
+	Period    time.Duration // 正弦、锯齿和方波的周期，为0时为1分钟
+	Noise     float64       // 噪声的标准差
+	Step      float64       // 随机游走每步的最大变化，为0时为振幅的十分之一
+	File      string        // CSV回放的文件
+	Samples   []Sample      // CSV回放的样本，由File加载
+	Interval  time.Duration // 更新周期，为0时为5秒
+}
+
+// period 返回曲线的周期
+func (p *Profile) period() time.Duration {
+	if p.Period > 0 {
+		return p.Period
+	}
+	return time.Minute
+}
+
+// interval 返回更新周期
+func (p *Profile) interval() time.Duration {
+	if p.Interval > 0 {
+		return p.Interval
+	}
+	return 5 * time.Second
+}
+
+// value 计算开始后elapsed时的值，last为上一次的值（随机游走使用）
+func (p *Profile) value(elapsed time.Duration, last float64, rnd *rand.Rand) float64 {
+	phase := math.Mod(float64(elapsed), float64(p.period())) / float64(p.period())
+	low, high := p.Offset-p.Amplitude, p.Offset+p.Amplitude
+	var v float64
+	switch p.Kind {
+	case Sine:
+		v = p.Offset + p.Amplitude*math.Sin(2*math.Pi*phase)
+	case Ramp:
+		v = low + (high-low)*phase
+	case Square:
+		v = high
+		if phase >= 0.5 {
+			v = low
+		}
+	case RandomWalk:
+		step := p.Step
+		if step == 0 {
+			step = p.Amplitude / 10
+		}
+		v = math.Max(low, math.Min(high, last+step*(2*rnd.Float64()-1)))
+	case Playback:
+		v = p.sample(elapsed)
+	default:
+		v = low + (high-low)*rnd.Float64()
+	}
+	if p.Noise > 0 {
+		v += rnd.NormFloat64() * p.Noise
+	}
+	return v
+}
+
+// sample 返回回放到elapsed时的样本值。样本都没有时间时按更新周期逐个回放
+func (p *Profile) sample(elapsed time.Duration) float64 {
+	n := len(p.Samples)
+	if n == 0 {
+		return p.Offset
+	}
+	last := p.Samples[n-1].At
+	if last == 0 {
+		return p.Samples[int(elapsed/p.interval())%n].Value
+	}
+	// 最后一个样本保持一个更新周期后从头循环
+	elapsed %= last + p.interval()
+	value := p.Samples[0].Value
+	for _, s := range p.Samples {
+		if s.At > elapsed {
+			break
+		}
+		value = s.Value
+	}
+	return value
+}
+
+// Set 按名称设置一个参数，名称为kind、offset、amplitude、min、max、period、noise、step、file或interval。
+// min和max与当前的另一端一起换算为Offset和Amplitude；file加载CSV样本并切换到回放
+func (p *Profile) Set(name, value string) error {
+	switch name {
+	case "kind":
+		kind, err := ParseKind(value)
+		if err != nil {
+			return err
+		}
+		p.Kind = kind
+		return nil
+	case "period", "interval":
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return fmt.Errorf("无效的%s: %s", name, value)
+		}
+		if name == "period" {
+			p.Period = d
+		} else {
+			p.Interval = d
+		}
+		return nil
+	case "file":
+		samples, err := LoadCSV(value)
+		if err != nil {
+			return err
+		}
+		p.Kind, p.File, p.Samples = Playback, value, samples
+		return nil
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("无效的%s: %s", name, value)
+	}
+	switch name {
+	case "offset":
+		p.Offset = number
+	case "amplitude":
+		p.Amplitude = math.Abs(number)
+	case "min":
+		p.SetRange(number, math.Max(number, p.Offset+p.Amplitude))
+	case "max":
+		p.SetRange(math.Min(number, p.Offset-p.Amplitude), number)
+	case "noise":
+		p.Noise = math.Abs(number)
+	case "step":
+		p.Step = math.Abs(number)
+	default:
+		return fmt.Errorf("未知的模拟参数: %s", name)
+	}
+	return nil
+}
+
+// SetRange 将数值范围设置为[low, high]
+func (p *Profile) SetRange(low, high float64) {
+	if low > high {
+		low, high = high, low
+	}
+	p.Offset, p.Amplitude = (low+high)/2, (high-low)/2
+}
+
+// String 返回曲线的参数，格式与Set接受的名称一致
+func (p Profile) String() string {
+	var sb strings.Builder
+	sb.WriteString(p.Kind.String())
+	if p.Kind == Playback {
+		fmt.Fprintf(&sb, " file=%s samples=%d", p.File, len(p.Samples))
+	} else {
+		fmt.Fprintf(&sb, " min=%g max=%g", p.Offset-p.Amplitude, p.Offset+p.Amplitude)
+	}
+	switch p.Kind {
+	case Sine, Ramp, Square:
+		fmt.Fprintf(&sb, " period=%s", p.period())
+	case RandomWalk:
+		if p.Step != 0 {
+			fmt.Fprintf(&sb, " step=%g", p.Step)
+		}
+	}
+	if p.Noise != 0 {
+		fmt.Fprintf(&sb, " noise=%g", p.Noise)
+	}
+	fmt.Fprintf(&sb, " interval=%s", p.interval())
+	return sb.String()
+}
+
+// LoadCSV 读取回放样本。每行为一个值，或者时间和值两列：时间写为秒数或time.ParseDuration的格式（如1m30s），
+// 第一行不是数值时作为表头跳过
+func LoadCSV(path string) ([]Sample, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadCSV(f)
+}
+
+// ReadCSV 从r读取回放样本，格式见LoadCSV
+func ReadCSV(r io.Reader) ([]Sample, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+	var samples []Sample
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var sample Sample
+		switch len(record) {
+		case 1:
+			sample.Value, err = strconv.ParseFloat(record[0], 64)
+		case 2:
+			if sample.At, err = parseOffset(record[0]); err == nil {
+				sample.Value, err = strconv.ParseFloat(record[1], 64)
+			}
+		default:
+			err = fmt.Errorf("应为一列或两列")
+		}
+		if err != nil {
+			if line == 1 {
+				continue // 表头
+			}
+			return nil, fmt.Errorf("第%d行: %v", line, err)
+		}
+		if n := len(samples); n > 0 && sample.At < samples[n-1].At {
+			return nil, fmt.Errorf("第%d行: 时间应递增", line)
+		}
+		samples = append(samples, sample)
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("没有样本")
+	}
+	return samples, nil
+}
+
+// parseOffset 解析秒数或时长
+func parseOffset(text string) (time.Duration, error) {
+	if seconds, err := strconv.ParseFloat(text, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	return time.ParseDuration(text)
+}
@@ -0,0 +1,164 @@
+package simulation
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// Rule 一条行为规则：条件成立时按Value更新目标对象的Present_Value，
+// 设置了Event时条件成立使目标对象转换到该事件状态，条件不再成立时恢复正常。
+// 规则在模拟曲线之后求值，条件成立时覆盖曲线的值
+type Rule struct {
+	Name     string
+	Target   model.Object
+	When     string           // 条件表达式，为空时总是成立
+	Value    string           // Present_Value的表达式，为空时不更新
+	Event    model.EventState // 条件成立时的事件状态，为normal时不产生事件
+	Message  string           // 事件通知的消息文本，为空时使用规则名称
+	Interval time.Duration    // 求值周期，为0时为1秒
+}
+
+// RuleStatus 规则的当前状态
+type RuleStatus struct {
+	Name   string
+	Target model.ObjectIdentifier
+	Active bool    // 条件最近一次是否成立
+	Value  float64 // Value最近一次的值
+	Err    error   // 最近一次求值的错误
+}
+
+// rule 引擎中的规则
+type rule struct {
+	Rule
+	when, value *Expr
+	next        time.Time
+	active      bool
+	result      float64
+	err         error
+}
+
+// eventGenerator 可以产生事件的对象
+type eventGenerator interface {
+	GenerateEvent(state model.EventState, message string)
+}
+
+// AddRule 编译规则的表达式并加入引擎，表达式中的对象在device中查找
+func (e *Engine) AddRule(device *model.Device, r Rule) error {
+	if r.Target == nil {
+		return fmt.Errorf("规则%s没有目标对象", r.Name)
+	}
+	if r.Value == "" && r.Event == model.EventStateNormal {
+		return fmt.Errorf("规则%s既不更新值也不产生事件", r.Name)
+	}
+	if _, ok := r.Target.(eventGenerator); r.Event != model.EventStateNormal && !ok {
+		return fmt.Errorf("%s不支持事件", r.Target.GetObjectName())
+	}
+	compiled := &rule{Rule: r}
+	var err error
+	if r.When != "" {
+		if compiled.when, err = Compile(r.When, device); err != nil {
+			return fmt.Errorf("规则%s的条件: %w", r.Name, err)
+		}
+	}
+	if r.Value != "" {
+		if compiled.value, err = Compile(r.Value, device); err != nil {
+			return fmt.Errorf("规则%s的值: %w", r.Name, err)
+		}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	compiled.next = e.clock().Now()
+	e.rules = append(e.rules, compiled)
+	return nil
+}
+
+// Rules 返回全部规则的状态，按加入的顺序
+func (e *Engine) Rules() []RuleStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	statuses := make([]RuleStatus, len(e.rules))
+	for i, r := range e.rules {
+		statuses[i] = RuleStatus{Name: r.Name, Target: r.Target.GetObjectIdentifier(), Active: r.active, Value: r.result, Err: r.err}
+	}
+	return statuses
+}
+
+// ruleAction 规则求值的结果
+type ruleAction struct {
+	rule   *rule
+	value  interface{} // Present_Value的新值，为nil时不更新
+	event  bool        // 是否转换事件状态
+	active bool        // 条件是否成立
+}
+
+// evaluate 求值规则的条件和值，调用时持有e.mu
+func (e *Engine) evaluate(r *rule, now time.Time) (ruleAction, error) {
+	ctx := &evalContext{now: now, rand: e.rand, self: r.Target}
+	active := true
+	if r.when != nil {
+		v, err := r.when.eval(ctx)
+		if err != nil {
+			return ruleAction{}, err
+		}
+		active = v != 0
+	}
+	a := ruleAction{rule: r, event: r.Event != model.EventStateNormal && active != r.active, active: active}
+	r.active = active
+	if active && r.value != nil {
+		v, err := r.value.eval(ctx)
+		if err != nil {
+			return ruleAction{}, err
+		}
+		r.result = v
+		a.value = presentValue(r.Target, v)
+	}
+	return a, nil
+}
+
+// evaluateRules 求值在now时到期的规则并执行结果
+func (e *Engine) evaluateRules(now time.Time) {
+	var actions []ruleAction
+	var failed []RuleStatus
+	e.mu.Lock()
+	for _, r := range e.rules {
+		if now.Before(r.next) {
+			continue
+		}
+		interval := r.Interval
+		if interval <= 0 {
+			interval = time.Second
+		}
+		r.next = now.Add(interval)
+
+		a, err := e.evaluate(r, now)
+		if err != nil && r.err == nil {
+			failed = append(failed, RuleStatus{Name: r.Name, Err: err}) // 只在开始出错时记录一次
+		}
+		r.err = err
+		if err == nil && (a.value != nil || a.event) {
+			actions = append(actions, a)
+		}
+	}
+	e.mu.Unlock()
+
+	for _, r := range failed {
+		e.logger().Warn("规则求值失败", "rule", r.Name, "error", r.Err)
+	}
+	for _, a := range actions {
+		if a.value != nil {
+			e.update(a.rule.Target, a.value)
+		}
+		if a.event {
+			state, message := a.rule.Event, a.rule.Message
+			if message == "" {
+				message = a.rule.Name
+			}
+			if !a.active {
+				state, message = model.EventStateNormal, message+"：已恢复"
+			}
+			a.rule.Target.(eventGenerator).GenerateEvent(state, message)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+// Package simulation 按每个点位配置的曲线（正弦、锯齿、随机游走、方波、随机值或CSV回放）
+// 周期性地更新对象的Present_Value，运行中可以修改曲线、暂停和恢复点位。
+// 规则用表达式描述点位之间的关系和告警逻辑，使模拟的设备表现得像真实设备
+package simulation
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+// tick 引擎检查到期点位的周期
+const tick = 100 * time.Millisecond
+
+// point 引擎中的一个点位
+type point struct {
+	object  model.Object
+	profile Profile
+	paused  bool
+	stopped time.Time   // 暂停的时间
+	start   time.Time   // 曲线的起点
+	next    time.Time   // 下一次更新的时间
+	last    float64     // 上一次计算的值，随机游走由此继续
+	value   interface{} // 上一次写入的值
+}
+
+// Status 点位的当前状态
+type Status struct {
+	Object  model.ObjectIdentifier
+	Name    string
+	Profile Profile
+	Paused  bool
+	Value   interface{} // 最近一次写入的值，尚未更新时为nil
+}
+
+// Engine 按曲线和规则周期性地更新对象的Present_Value：输入对象按现场值更新（停用时被忽略），
+// 其他对象直接写入。二进制量按0.5取阈值，多态量取整并限制在1到Number_Of_States之间
+type Engine struct {
+	Logger *slog.Logger // 为nil时使用slog.Default()
+	Locker sync.Locker  // Step访问对象期间持有的锁，通常为对象所在的*model.Device，为nil时不加锁
+	Clock  model.Clock  // 曲线起点和周期更新使用的时钟，为nil时使用model.SystemClock
+
+	mu     sync.Mutex // 保护points、rules和rand
+	points []*point
+	rules  []*rule
+	rand   *rand.Rand
+}
+
+// NewEngine 创建没有点位的引擎
+func NewEngine() *Engine {
+	return &Engine{rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// logger 返回引擎使用的日志
+func (e *Engine) logger() *slog.Logger {
+	if e.Logger != nil {
+		return e.Logger
+	}
+	return slog.Default()
+}
+
+// clock 返回引擎使用的时钟
+func (e *Engine) clock() model.Clock {
+	if e.Clock != nil {
+		return e.Clock
+	}
+	return model.SystemClock
+}
+
+// Set 设置对象的模拟曲线，对象尚未模拟时加入引擎。未给出范围时二进制量为0到1，
+// 多态量覆盖全部状态，模拟量以当前值为中心。引擎运行中调用时调用方持有Locker
+func (e *Engine) Set(obj model.Object, profile Profile) error {
+	if value, err := obj.ReadProperty(model.PropertyIdentifierPresentValue); err != nil || value == nil {
+		return fmt.Errorf("%s没有Present_Value", obj.GetObjectName())
+	}
+	if profile.Kind > Playback {
+		return fmt.Errorf("不支持的模拟方式: %s", profile.Kind)
+	}
+	if profile.Kind == Playback && len(profile.Samples) == 0 {
+		if profile.File == "" {
+			return fmt.Errorf("CSV回放没有样本")
+		}
+		samples, err := LoadCSV(profile.File)
+		if err != nil {
+			return err
+		}
+		profile.Samples = samples
+	}
+	if profile.Offset == 0 && profile.Amplitude == 0 {
+		profile.Offset, profile.Amplitude = defaultRange(obj)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := e.clock().Now()
+	for _, p := range e.points {
+		if p.object.GetObjectIdentifier() == obj.GetObjectIdentifier() {
+			p.profile = profile
+			p.next = now
+			return nil
+		}
+	}
+	e.points = append(e.points, &point{object: obj, profile: profile, start: now, next: now, last: profile.Offset})
+	return nil
+}
+
+// Profile 返回对象的模拟曲线，对象未模拟时返回false
+func (e *Engine) Profile(oid model.ObjectIdentifier) (Profile, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if p := e.find(oid); p != nil {
+		return p.profile, true
+	}
+	return Profile{}, false
+}
+
+// Remove 停止模拟对象，对象保持最后的值
+func (e *Engine) Remove(oid model.ObjectIdentifier) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i, p := range e.points {
+		if p.object.GetObjectIdentifier() == oid {
+			e.points = append(e.points[:i], e.points[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Pause 暂停或恢复对象的模拟，恢复时曲线从暂停处继续
+func (e *Engine) Pause(oid model.ObjectIdentifier, paused bool) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	p := e.find(oid)
+	if p == nil {
+		return false
+	}
+	now := e.clock().Now()
+	switch {
+	case paused && !p.paused:
+		p.stopped = now
+	case !paused && p.paused:
+		// 跳过暂停的时间
+		p.start = p.start.Add(now.Sub(p.stopped))
+		p.next = now
+	}
+	p.paused = paused
+	return true
+}
+
+// Points 返回全部点位的状态，按加入的顺序
+func (e *Engine) Points() []Status {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	statuses := make([]Status, len(e.points))
+	for i, p := range e.points {
+		statuses[i] = Status{
+			Object:  p.object.GetObjectIdentifier(),
+			Name:    p.object.GetObjectName(),
+			Profile: p.profile,
+			Paused:  p.paused,
+			Value:   p.value,
+		}
+	}
+	return statuses
+}
+
+// find 查找对象的点位，调用时持有e.mu
+func (e *Engine) find(oid model.ObjectIdentifier) *point {
+	for _, p := range e.points {
+		if p.object.GetObjectIdentifier() == oid {
+			return p
+		}
+	}
+	return nil
+}
+
+// Run 立即更新一次全部点位，之后按各自的周期更新，直到ctx取消
+func (e *Engine) Run(ctx context.Context) {
+	ticker := e.clock().NewTicker(tick)
+	defer ticker.Stop()
+	e.Step(e.clock().Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C():
+			e.Step(now)
+		}
+	}
+}
+
+// Step 更新在now时到期的点位，然后求值到期的规则
+func (e *Engine) Step(now time.Time) {
+	type update struct {
+		object model.Object
+		value  interface{}
+	}
+	if e.Locker != nil {
+		e.Locker.Lock()
+		defer e.Locker.Unlock()
+	}
+	var updates []update
+	e.mu.Lock()
+	for _, p := range e.points {
+		if p.paused || now.Before(p.next) {
+			continue
+		}
+		p.last = p.profile.value(now.Sub(p.start), p.last, e.rand)
+		p.value = presentValue(p.object, p.last)
+		p.next = p.next.Add(p.profile.interval())
+		if p.next.Before(now) {
+			p.next = now.Add(p.profile.interval()) // 落后太多时不追赶
+		}
+		updates = append(updates, update{p.object, p.value})
+	}
+	e.mu.Unlock()
+
+	// 写入时会发送COV通知，不持有锁
+	for _, u := range updates {
+		e.update(u.object, u.value)
+	}
+	e.evaluateRules(now)
+}
+
+// update 写入对象的Present_Value
+func (e *Engine) update(obj model.Object, value interface{}) {
+	var err error
+	if updater, ok := obj.(model.PresentValueUpdater); ok {
+		err = updater.UpdatePresentValue(value)
+	} else {
+		err = obj.WriteProperty(model.PropertyIdentifierPresentValue, value)
+	}
+	if err != nil {
+		e.logger().Warn("更新模拟值失败", "object", obj.GetObjectName(), "value", value, "error", err)
+	}
+}
+
+// presentValue 将曲线的值转换为对象Present_Value的类型
+func presentValue(obj model.Object, v float64) interface{} {
+	meta, _ := model.LookupPropertyMetadata(obj.GetObjectIdentifier().Type, model.PropertyIdentifierPresentValue)
+	switch meta.Datatype {
+	case model.DatatypeReal, model.DatatypeDouble:
+	default:
+		v = math.Round(v)
+		if states := numberOfStates(obj); states > 0 {
+			v = math.Max(1, math.Min(float64(states), v))
+		}
+	}
+	if value, err := model.CoerceValue(obj, model.PropertyIdentifierPresentValue, v); err == nil {
+		return value
+	}
+	return v
+}
+
+// defaultRange 返回对象默认的中心值和振幅
+func defaultRange(obj model.Object) (float64, float64) {
+	current, _ := obj.ReadProperty(model.PropertyIdentifierPresentValue)
+	if _, ok := current.(bool); ok {
+		return 0.5, 0.5
+	}
+	if states := numberOfStates(obj); states > 0 {
+		return float64(states+1) / 2, float64(states) / 2
+	}
+	switch v := current.(type) {
+	case float32:
+		return float64(v), 0
+	case float64:
+		return v, 0
+	}
+	return 0, 0
+}
+
+// numberOfStates 返回多态对象的状态数，其他对象返回0
+func numberOfStates(obj model.Object) uint32 {
+	value, err := obj.ReadProperty(model.PropertyIdentifierNumberOfStates)
+	if err != nil {
+		return 0
+	}
+	switch v := value.(type) {
+	case uint32:
+		return v
+	case uint8:
+		return uint32(v)
+	}
+	return 0
+}
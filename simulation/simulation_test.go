@@ -0,0 +1,162 @@
+package simulation
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iotzf/bacnet-server/model"
+)
+
+func TestProfileValue(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	tests := []struct {
+		name    string
+		profile Profile
+		elapsed time.Duration
+		want    float64
+	}{
+		{"sine peak", Profile{Kind: Sine, Offset: 20, Amplitude: 5, Period: 4 * time.Minute}, time.Minute, 25},
+		{"sine trough", Profile{Kind: Sine, Offset: 20, Amplitude: 5, Period: 4 * time.Minute}, 3 * time.Minute, 15},
+		{"ramp", Profile{Kind: Ramp, Offset: 50, Amplitude: 50, Period: 10 * time.Second}, 12500 * time.Millisecond, 25},
+		{"square high", Profile{Kind: Square, Offset: 1, Amplitude: 1}, 10 * time.Second, 2},
+		{"square low", Profile{Kind: Square, Offset: 1, Amplitude: 1}, 40 * time.Second, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.profile.value(tt.elapsed, 0, rnd); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("value = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	walk := Profile{Kind: RandomWalk, Offset: 10, Amplitude: 2, Step: 0.5}
+	last := walk.Offset
+	for i := 0; i < 1000; i++ {
+		next := walk.value(0, last, rnd)
+		if next < 8 || next > 12 || math.Abs(next-last) > 0.5 {
+			t.Fatalf("random walk step %d: %v -> %v", i, last, next)
+		}
+		last = next
+	}
+	random := Profile{Kind: Random, Offset: 4.5, Amplitude: 1.5}
+	for i := 0; i < 1000; i++ {
+		if v := random.value(0, 0, rnd); v < 3 || v > 6 {
+			t.Fatalf("random value %v outside 3-6", v)
+		}
+	}
+}
+
+func TestPlayback(t *testing.T) {
+	samples, err := ReadCSV(strings.NewReader("time,value\n0,10\n30s,20\n1m,30\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	timed := Profile{Kind: Playback, Samples: samples, Interval: 10 * time.Second}
+	for _, tt := range []struct {
+		elapsed time.Duration
+		want    float64
+	}{{0, 10}, {29 * time.Second, 10}, {45 * time.Second, 20}, {65 * time.Second, 30}, {70 * time.Second, 10}} {
+		if got := timed.value(tt.elapsed, 0, nil); got != tt.want {
+			t.Errorf("value at %s = %v, want %v", tt.elapsed, got, tt.want)
+		}
+	}
+
+	// 只有值的样本按更新周期逐个回放
+	samples, err = ReadCSV(strings.NewReader("1\n2\n3\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stepped := Profile{Kind: Playback, Samples: samples, Interval: time.Second}
+	if got := stepped.value(4*time.Second, 0, nil); got != 2 {
+		t.Errorf("stepped value = %v, want 2", got)
+	}
+
+	for _, input := range []string{"", "0,1\nabc\n", "1m,1\n30s,2\n", "1,2,3\n4,5,6\n"} {
+		if _, err := ReadCSV(strings.NewReader(input)); err == nil {
+			t.Errorf("ReadCSV(%q): want error", input)
+		}
+	}
+}
+
+func TestProfileSet(t *testing.T) {
+	var p Profile
+	for _, arg := range []string{"kind=sine", "min=10", "max=30", "period=2m", "noise=0.2", "interval=1s"} {
+		name, value, _ := strings.Cut(arg, "=")
+		if err := p.Set(name, value); err != nil {
+			t.Fatalf("Set(%s): %v", arg, err)
+		}
+	}
+	if p.Kind != Sine || p.Offset != 20 || p.Amplitude != 10 || p.Period != 2*time.Minute || p.Noise != 0.2 || p.Interval != time.Second {
+		t.Errorf("profile = %+v", p)
+	}
+	if got, want := p.String(), "sine min=10 max=30 period=2m0s noise=0.2 interval=1s"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	for _, arg := range [][2]string{{"kind", "triangle"}, {"period", "soon"}, {"gain", "2"}, {"file", "missing.csv"}} {
+		if err := p.Set(arg[0], arg[1]); err == nil {
+			t.Errorf("Set(%s=%s): want error", arg[0], arg[1])
+		}
+	}
+}
+
+func TestEngine(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := model.NewFakeClock(start)
+
+	temp := model.NewAnalogInput(1, "Temp", model.UnitsDegreesCelsius)
+	fan := model.NewBinaryValue(1, "Fan")
+	mode := model.NewMultiStateValue(1, "Mode", []string{"Off", "Low", "High"})
+	engine := NewEngine()
+	engine.Clock = clock
+	if err := engine.Set(temp, Profile{Kind: Square, Offset: 20, Amplitude: 2, Period: time.Minute, Interval: 10 * time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Set(fan, Profile{Kind: Square, Period: time.Minute, Interval: 10 * time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.Set(mode, Profile{Kind: Ramp, Period: time.Minute, Interval: 10 * time.Second}); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(obj model.Object) interface{} {
+		value, _ := obj.ReadProperty(model.PropertyIdentifierPresentValue)
+		return value
+	}
+	engine.Step(start)
+	if read(temp) != float32(22) || read(fan) != true || read(mode) != uint32(1) {
+		t.Errorf("at start: temp = %v, fan = %v, mode = %v", read(temp), read(fan), read(mode))
+	}
+	// 未到更新周期的点位不更新
+	engine.Step(start.Add(5 * time.Second))
+	if points := engine.Points(); points[0].Value != float32(22) {
+		t.Errorf("points = %+v", points)
+	}
+	engine.Step(start.Add(40 * time.Second))
+	if read(temp) != float32(18) || read(fan) != false || read(mode) != uint32(3) {
+		t.Errorf("at 40s: temp = %v, fan = %v, mode = %v", read(temp), read(fan), read(mode))
+	}
+
+	// 暂停后不更新，恢复后曲线从暂停处继续
+	clock.Advance(40 * time.Second)
+	engine.Pause(temp.GetObjectIdentifier(), true)
+	clock.Advance(30 * time.Second)
+	engine.Step(clock.Now())
+	if read(temp) != float32(18) {
+		t.Errorf("paused temp = %v", read(temp))
+	}
+	engine.Pause(temp.GetObjectIdentifier(), false)
+	engine.Step(clock.Now())
+	if read(temp) != float32(18) {
+		t.Errorf("resumed temp = %v", read(temp))
+	}
+
+	if !engine.Remove(fan.GetObjectIdentifier()) || len(engine.Points()) != 2 {
+		t.Errorf("Remove: points = %+v", engine.Points())
+	}
+	if err := engine.Set(model.NewEventLog(1, "Log", 10), Profile{}); err == nil {
+		t.Error("Set on object without Present_Value: want error")
+	}
+}